@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 
+	"golang.org/x/net/context"
+
 	"github.com/pachyderm/pachyderm/src/client/admin"
 	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
 	"github.com/pachyderm/pachyderm/src/client/pkg/pbutil"
@@ -21,7 +23,9 @@ func (c APIClient) InspectCluster() (*admin.ClusterInfo, error) {
 
 // Extract all cluster state, call f with each operation.
 func (c APIClient) Extract(objects bool, f func(op *admin.Op) error) error {
-	extractClient, err := c.AdminAPIClient.Extract(c.Ctx(), &admin.ExtractRequest{NoObjects: !objects})
+	ctx, cancel := context.WithCancel(c.Ctx())
+	defer cancel()
+	extractClient, err := c.AdminAPIClient.Extract(ctx, &admin.ExtractRequest{NoObjects: !objects})
 	if err != nil {
 		return grpcutil.ScrubGRPC(err)
 	}
@@ -63,7 +67,9 @@ func (c APIClient) ExtractWriter(objects bool, w io.Writer) error {
 
 // ExtractURL extracts all cluster state and marshalls it to object storage.
 func (c APIClient) ExtractURL(url string) error {
-	extractClient, err := c.AdminAPIClient.Extract(c.Ctx(), &admin.ExtractRequest{URL: url})
+	ctx, cancel := context.WithCancel(c.Ctx())
+	defer cancel()
+	extractClient, err := c.AdminAPIClient.Extract(ctx, &admin.ExtractRequest{URL: url})
 	if err != nil {
 		return grpcutil.ScrubGRPC(err)
 	}
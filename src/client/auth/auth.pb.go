@@ -56,7 +56,7 @@ func (x Scope) String() string {
 	return proto.EnumName(Scope_name, int32(x))
 }
 func (Scope) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{0}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{0}
 }
 
 type TokenInfo_TokenSource int32
@@ -82,7 +82,7 @@ func (x TokenInfo_TokenSource) String() string {
 	return proto.EnumName(TokenInfo_TokenSource_name, int32(x))
 }
 func (TokenInfo_TokenSource) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{15, 0}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{15, 0}
 }
 
 // ActivateRequest mirrors AuthenticateRequest. The caller is authenticated via
@@ -112,7 +112,7 @@ func (m *ActivateRequest) Reset()         { *m = ActivateRequest{} }
 func (m *ActivateRequest) String() string { return proto.CompactTextString(m) }
 func (*ActivateRequest) ProtoMessage()    {}
 func (*ActivateRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{0}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{0}
 }
 func (m *ActivateRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -169,7 +169,7 @@ func (m *ActivateResponse) Reset()         { *m = ActivateResponse{} }
 func (m *ActivateResponse) String() string { return proto.CompactTextString(m) }
 func (*ActivateResponse) ProtoMessage()    {}
 func (*ActivateResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{1}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{1}
 }
 func (m *ActivateResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -215,7 +215,7 @@ func (m *DeactivateRequest) Reset()         { *m = DeactivateRequest{} }
 func (m *DeactivateRequest) String() string { return proto.CompactTextString(m) }
 func (*DeactivateRequest) ProtoMessage()    {}
 func (*DeactivateRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{2}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{2}
 }
 func (m *DeactivateRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -254,7 +254,7 @@ func (m *DeactivateResponse) Reset()         { *m = DeactivateResponse{} }
 func (m *DeactivateResponse) String() string { return proto.CompactTextString(m) }
 func (*DeactivateResponse) ProtoMessage()    {}
 func (*DeactivateResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{3}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{3}
 }
 func (m *DeactivateResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -302,7 +302,7 @@ func (m *IDProvider) Reset()         { *m = IDProvider{} }
 func (m *IDProvider) String() string { return proto.CompactTextString(m) }
 func (*IDProvider) ProtoMessage()    {}
 func (*IDProvider) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{4}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{4}
 }
 func (m *IDProvider) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -379,7 +379,7 @@ func (m *IDProvider_SAMLOptions) Reset()         { *m = IDProvider_SAMLOptions{}
 func (m *IDProvider_SAMLOptions) String() string { return proto.CompactTextString(m) }
 func (*IDProvider_SAMLOptions) ProtoMessage()    {}
 func (*IDProvider_SAMLOptions) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{4, 0}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{4, 0}
 }
 func (m *IDProvider_SAMLOptions) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -450,7 +450,7 @@ func (m *AuthConfig) Reset()         { *m = AuthConfig{} }
 func (m *AuthConfig) String() string { return proto.CompactTextString(m) }
 func (*AuthConfig) ProtoMessage()    {}
 func (*AuthConfig) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{5}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{5}
 }
 func (m *AuthConfig) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -537,7 +537,7 @@ func (m *AuthConfig_SAMLServiceOptions) Reset()         { *m = AuthConfig_SAMLSe
 func (m *AuthConfig_SAMLServiceOptions) String() string { return proto.CompactTextString(m) }
 func (*AuthConfig_SAMLServiceOptions) ProtoMessage()    {}
 func (*AuthConfig_SAMLServiceOptions) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{5, 0}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{5, 0}
 }
 func (m *AuthConfig_SAMLServiceOptions) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -611,7 +611,7 @@ func (m *GetConfigurationRequest) Reset()         { *m = GetConfigurationRequest
 func (m *GetConfigurationRequest) String() string { return proto.CompactTextString(m) }
 func (*GetConfigurationRequest) ProtoMessage()    {}
 func (*GetConfigurationRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{6}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{6}
 }
 func (m *GetConfigurationRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -651,7 +651,7 @@ func (m *GetConfigurationResponse) Reset()         { *m = GetConfigurationRespon
 func (m *GetConfigurationResponse) String() string { return proto.CompactTextString(m) }
 func (*GetConfigurationResponse) ProtoMessage()    {}
 func (*GetConfigurationResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{7}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{7}
 }
 func (m *GetConfigurationResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -698,7 +698,7 @@ func (m *SetConfigurationRequest) Reset()         { *m = SetConfigurationRequest
 func (m *SetConfigurationRequest) String() string { return proto.CompactTextString(m) }
 func (*SetConfigurationRequest) ProtoMessage()    {}
 func (*SetConfigurationRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{8}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{8}
 }
 func (m *SetConfigurationRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -744,7 +744,7 @@ func (m *SetConfigurationResponse) Reset()         { *m = SetConfigurationRespon
 func (m *SetConfigurationResponse) String() string { return proto.CompactTextString(m) }
 func (*SetConfigurationResponse) ProtoMessage()    {}
 func (*SetConfigurationResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{9}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{9}
 }
 func (m *SetConfigurationResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -784,7 +784,7 @@ func (m *GetAdminsRequest) Reset()         { *m = GetAdminsRequest{} }
 func (m *GetAdminsRequest) String() string { return proto.CompactTextString(m) }
 func (*GetAdminsRequest) ProtoMessage()    {}
 func (*GetAdminsRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{10}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{10}
 }
 func (m *GetAdminsRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -825,7 +825,7 @@ func (m *GetAdminsResponse) Reset()         { *m = GetAdminsResponse{} }
 func (m *GetAdminsResponse) String() string { return proto.CompactTextString(m) }
 func (*GetAdminsResponse) ProtoMessage()    {}
 func (*GetAdminsResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{11}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{11}
 }
 func (m *GetAdminsResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -874,7 +874,7 @@ func (m *ModifyAdminsRequest) Reset()         { *m = ModifyAdminsRequest{} }
 func (m *ModifyAdminsRequest) String() string { return proto.CompactTextString(m) }
 func (*ModifyAdminsRequest) ProtoMessage()    {}
 func (*ModifyAdminsRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{12}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{12}
 }
 func (m *ModifyAdminsRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -927,7 +927,7 @@ func (m *ModifyAdminsResponse) Reset()         { *m = ModifyAdminsResponse{} }
 func (m *ModifyAdminsResponse) String() string { return proto.CompactTextString(m) }
 func (*ModifyAdminsResponse) ProtoMessage()    {}
 func (*ModifyAdminsResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{13}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{13}
 }
 func (m *ModifyAdminsResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -977,7 +977,7 @@ func (m *OTPInfo) Reset()         { *m = OTPInfo{} }
 func (m *OTPInfo) String() string { return proto.CompactTextString(m) }
 func (*OTPInfo) ProtoMessage()    {}
 func (*OTPInfo) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{14}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{14}
 }
 func (m *OTPInfo) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1036,7 +1036,7 @@ func (m *TokenInfo) Reset()         { *m = TokenInfo{} }
 func (m *TokenInfo) String() string { return proto.CompactTextString(m) }
 func (*TokenInfo) ProtoMessage()    {}
 func (*TokenInfo) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{15}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{15}
 }
 func (m *TokenInfo) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1099,7 +1099,7 @@ func (m *AuthenticateRequest) Reset()         { *m = AuthenticateRequest{} }
 func (m *AuthenticateRequest) String() string { return proto.CompactTextString(m) }
 func (*AuthenticateRequest) ProtoMessage()    {}
 func (*AuthenticateRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{16}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{16}
 }
 func (m *AuthenticateRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1156,7 +1156,7 @@ func (m *AuthenticateResponse) Reset()         { *m = AuthenticateResponse{} }
 func (m *AuthenticateResponse) String() string { return proto.CompactTextString(m) }
 func (*AuthenticateResponse) ProtoMessage()    {}
 func (*AuthenticateResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{17}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{17}
 }
 func (m *AuthenticateResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1202,7 +1202,7 @@ func (m *WhoAmIRequest) Reset()         { *m = WhoAmIRequest{} }
 func (m *WhoAmIRequest) String() string { return proto.CompactTextString(m) }
 func (*WhoAmIRequest) ProtoMessage()    {}
 func (*WhoAmIRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{18}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{18}
 }
 func (m *WhoAmIRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1244,7 +1244,7 @@ func (m *WhoAmIResponse) Reset()         { *m = WhoAmIResponse{} }
 func (m *WhoAmIResponse) String() string { return proto.CompactTextString(m) }
 func (*WhoAmIResponse) ProtoMessage()    {}
 func (*WhoAmIResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{19}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{19}
 }
 func (m *WhoAmIResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1309,7 +1309,7 @@ func (m *ACL) Reset()         { *m = ACL{} }
 func (m *ACL) String() string { return proto.CompactTextString(m) }
 func (*ACL) ProtoMessage()    {}
 func (*ACL) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{20}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{20}
 }
 func (m *ACL) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1356,7 +1356,7 @@ func (m *Users) Reset()         { *m = Users{} }
 func (m *Users) String() string { return proto.CompactTextString(m) }
 func (*Users) ProtoMessage()    {}
 func (*Users) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{21}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{21}
 }
 func (m *Users) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1403,7 +1403,7 @@ func (m *Groups) Reset()         { *m = Groups{} }
 func (m *Groups) String() string { return proto.CompactTextString(m) }
 func (*Groups) ProtoMessage()    {}
 func (*Groups) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{22}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{22}
 }
 func (m *Groups) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1453,7 +1453,7 @@ func (m *AuthorizeRequest) Reset()         { *m = AuthorizeRequest{} }
 func (m *AuthorizeRequest) String() string { return proto.CompactTextString(m) }
 func (*AuthorizeRequest) ProtoMessage()    {}
 func (*AuthorizeRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{23}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{23}
 }
 func (m *AuthorizeRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1510,7 +1510,7 @@ func (m *AuthorizeResponse) Reset()         { *m = AuthorizeResponse{} }
 func (m *AuthorizeResponse) String() string { return proto.CompactTextString(m) }
 func (*AuthorizeResponse) ProtoMessage()    {}
 func (*AuthorizeResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{24}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{24}
 }
 func (m *AuthorizeResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1565,7 +1565,7 @@ func (m *GetScopeRequest) Reset()         { *m = GetScopeRequest{} }
 func (m *GetScopeRequest) String() string { return proto.CompactTextString(m) }
 func (*GetScopeRequest) ProtoMessage()    {}
 func (*GetScopeRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{25}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{25}
 }
 func (m *GetScopeRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1622,7 +1622,7 @@ func (m *GetScopeResponse) Reset()         { *m = GetScopeResponse{} }
 func (m *GetScopeResponse) String() string { return proto.CompactTextString(m) }
 func (*GetScopeResponse) ProtoMessage()    {}
 func (*GetScopeResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{26}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{26}
 }
 func (m *GetScopeResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1680,7 +1680,7 @@ func (m *SetScopeRequest) Reset()         { *m = SetScopeRequest{} }
 func (m *SetScopeRequest) String() string { return proto.CompactTextString(m) }
 func (*SetScopeRequest) ProtoMessage()    {}
 func (*SetScopeRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{27}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{27}
 }
 func (m *SetScopeRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1740,7 +1740,7 @@ func (m *SetScopeResponse) Reset()         { *m = SetScopeResponse{} }
 func (m *SetScopeResponse) String() string { return proto.CompactTextString(m) }
 func (*SetScopeResponse) ProtoMessage()    {}
 func (*SetScopeResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{28}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{28}
 }
 func (m *SetScopeResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1780,7 +1780,7 @@ func (m *GetACLRequest) Reset()         { *m = GetACLRequest{} }
 func (m *GetACLRequest) String() string { return proto.CompactTextString(m) }
 func (*GetACLRequest) ProtoMessage()    {}
 func (*GetACLRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{29}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{29}
 }
 func (m *GetACLRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1833,7 +1833,7 @@ func (m *ACLEntry) Reset()         { *m = ACLEntry{} }
 func (m *ACLEntry) String() string { return proto.CompactTextString(m) }
 func (*ACLEntry) ProtoMessage()    {}
 func (*ACLEntry) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{30}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{30}
 }
 func (m *ACLEntry) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1900,7 +1900,7 @@ func (m *GetACLResponse) Reset()         { *m = GetACLResponse{} }
 func (m *GetACLResponse) String() string { return proto.CompactTextString(m) }
 func (*GetACLResponse) ProtoMessage()    {}
 func (*GetACLResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{31}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{31}
 }
 func (m *GetACLResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1955,7 +1955,7 @@ func (m *SetACLRequest) Reset()         { *m = SetACLRequest{} }
 func (m *SetACLRequest) String() string { return proto.CompactTextString(m) }
 func (*SetACLRequest) ProtoMessage()    {}
 func (*SetACLRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{32}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{32}
 }
 func (m *SetACLRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2008,7 +2008,7 @@ func (m *SetACLResponse) Reset()         { *m = SetACLResponse{} }
 func (m *SetACLResponse) String() string { return proto.CompactTextString(m) }
 func (*SetACLResponse) ProtoMessage()    {}
 func (*SetACLResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{33}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{33}
 }
 func (m *SetACLResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2052,7 +2052,7 @@ func (m *GetAuthTokenRequest) Reset()         { *m = GetAuthTokenRequest{} }
 func (m *GetAuthTokenRequest) String() string { return proto.CompactTextString(m) }
 func (*GetAuthTokenRequest) ProtoMessage()    {}
 func (*GetAuthTokenRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{34}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{34}
 }
 func (m *GetAuthTokenRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2109,7 +2109,7 @@ func (m *GetAuthTokenResponse) Reset()         { *m = GetAuthTokenResponse{} }
 func (m *GetAuthTokenResponse) String() string { return proto.CompactTextString(m) }
 func (*GetAuthTokenResponse) ProtoMessage()    {}
 func (*GetAuthTokenResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{35}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{35}
 }
 func (m *GetAuthTokenResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2166,7 +2166,7 @@ func (m *ExtendAuthTokenRequest) Reset()         { *m = ExtendAuthTokenRequest{}
 func (m *ExtendAuthTokenRequest) String() string { return proto.CompactTextString(m) }
 func (*ExtendAuthTokenRequest) ProtoMessage()    {}
 func (*ExtendAuthTokenRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{36}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{36}
 }
 func (m *ExtendAuthTokenRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2219,7 +2219,7 @@ func (m *ExtendAuthTokenResponse) Reset()         { *m = ExtendAuthTokenResponse
 func (m *ExtendAuthTokenResponse) String() string { return proto.CompactTextString(m) }
 func (*ExtendAuthTokenResponse) ProtoMessage()    {}
 func (*ExtendAuthTokenResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{37}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{37}
 }
 func (m *ExtendAuthTokenResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2259,7 +2259,7 @@ func (m *RevokeAuthTokenRequest) Reset()         { *m = RevokeAuthTokenRequest{}
 func (m *RevokeAuthTokenRequest) String() string { return proto.CompactTextString(m) }
 func (*RevokeAuthTokenRequest) ProtoMessage()    {}
 func (*RevokeAuthTokenRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{38}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{38}
 }
 func (m *RevokeAuthTokenRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2305,7 +2305,7 @@ func (m *RevokeAuthTokenResponse) Reset()         { *m = RevokeAuthTokenResponse
 func (m *RevokeAuthTokenResponse) String() string { return proto.CompactTextString(m) }
 func (*RevokeAuthTokenResponse) ProtoMessage()    {}
 func (*RevokeAuthTokenResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{39}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{39}
 }
 func (m *RevokeAuthTokenResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2334,6 +2334,107 @@ func (m *RevokeAuthTokenResponse) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_RevokeAuthTokenResponse proto.InternalMessageInfo
 
+// GetS3Credentials registers the caller's token with the S3 gateway, and
+// returns the credentials that S3 clients sign requests with
+type GetS3CredentialsRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetS3CredentialsRequest) Reset()         { *m = GetS3CredentialsRequest{} }
+func (m *GetS3CredentialsRequest) String() string { return proto.CompactTextString(m) }
+func (*GetS3CredentialsRequest) ProtoMessage()    {}
+func (*GetS3CredentialsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{40}
+}
+func (m *GetS3CredentialsRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *GetS3CredentialsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_GetS3CredentialsRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (dst *GetS3CredentialsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetS3CredentialsRequest.Merge(dst, src)
+}
+func (m *GetS3CredentialsRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *GetS3CredentialsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetS3CredentialsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetS3CredentialsRequest proto.InternalMessageInfo
+
+type GetS3CredentialsResponse struct {
+	// access_key_id is the SHA-256 hash of the caller's token, which (unlike
+	// the token) may appear in requests, URLs and logs
+	AccessKeyID string `protobuf:"bytes,1,opt,name=access_key_id,json=accessKeyId,proto3" json:"access_key_id,omitempty"`
+	// secret_key is a random key that's registered with the caller's token. It
+	// signs requests but is never sent, and unlike the token, it only grants
+	// access through the S3 gateway
+	SecretKey            string   `protobuf:"bytes,2,opt,name=secret_key,json=secretKey,proto3" json:"secret_key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetS3CredentialsResponse) Reset()         { *m = GetS3CredentialsResponse{} }
+func (m *GetS3CredentialsResponse) String() string { return proto.CompactTextString(m) }
+func (*GetS3CredentialsResponse) ProtoMessage()    {}
+func (*GetS3CredentialsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{41}
+}
+func (m *GetS3CredentialsResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *GetS3CredentialsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_GetS3CredentialsResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (dst *GetS3CredentialsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetS3CredentialsResponse.Merge(dst, src)
+}
+func (m *GetS3CredentialsResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *GetS3CredentialsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetS3CredentialsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetS3CredentialsResponse proto.InternalMessageInfo
+
+func (m *GetS3CredentialsResponse) GetAccessKeyID() string {
+	if m != nil {
+		return m.AccessKeyID
+	}
+	return ""
+}
+
+func (m *GetS3CredentialsResponse) GetSecretKey() string {
+	if m != nil {
+		return m.SecretKey
+	}
+	return ""
+}
+
 type SetGroupsForUserRequest struct {
 	Username             string   `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
 	Groups               []string `protobuf:"bytes,2,rep,name=groups,proto3" json:"groups,omitempty"`
@@ -2346,7 +2447,7 @@ func (m *SetGroupsForUserRequest) Reset()         { *m = SetGroupsForUserRequest
 func (m *SetGroupsForUserRequest) String() string { return proto.CompactTextString(m) }
 func (*SetGroupsForUserRequest) ProtoMessage()    {}
 func (*SetGroupsForUserRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{40}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{42}
 }
 func (m *SetGroupsForUserRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2399,7 +2500,7 @@ func (m *SetGroupsForUserResponse) Reset()         { *m = SetGroupsForUserRespon
 func (m *SetGroupsForUserResponse) String() string { return proto.CompactTextString(m) }
 func (*SetGroupsForUserResponse) ProtoMessage()    {}
 func (*SetGroupsForUserResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{41}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{43}
 }
 func (m *SetGroupsForUserResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2441,7 +2542,7 @@ func (m *ModifyMembersRequest) Reset()         { *m = ModifyMembersRequest{} }
 func (m *ModifyMembersRequest) String() string { return proto.CompactTextString(m) }
 func (*ModifyMembersRequest) ProtoMessage()    {}
 func (*ModifyMembersRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{42}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{44}
 }
 func (m *ModifyMembersRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2501,7 +2602,7 @@ func (m *ModifyMembersResponse) Reset()         { *m = ModifyMembersResponse{} }
 func (m *ModifyMembersResponse) String() string { return proto.CompactTextString(m) }
 func (*ModifyMembersResponse) ProtoMessage()    {}
 func (*ModifyMembersResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{43}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{45}
 }
 func (m *ModifyMembersResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2541,7 +2642,7 @@ func (m *GetGroupsRequest) Reset()         { *m = GetGroupsRequest{} }
 func (m *GetGroupsRequest) String() string { return proto.CompactTextString(m) }
 func (*GetGroupsRequest) ProtoMessage()    {}
 func (*GetGroupsRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{44}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{46}
 }
 func (m *GetGroupsRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2588,7 +2689,7 @@ func (m *GetGroupsResponse) Reset()         { *m = GetGroupsResponse{} }
 func (m *GetGroupsResponse) String() string { return proto.CompactTextString(m) }
 func (*GetGroupsResponse) ProtoMessage()    {}
 func (*GetGroupsResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{45}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{47}
 }
 func (m *GetGroupsResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2635,7 +2736,7 @@ func (m *GetUsersRequest) Reset()         { *m = GetUsersRequest{} }
 func (m *GetUsersRequest) String() string { return proto.CompactTextString(m) }
 func (*GetUsersRequest) ProtoMessage()    {}
 func (*GetUsersRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{46}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{48}
 }
 func (m *GetUsersRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2682,7 +2783,7 @@ func (m *GetUsersResponse) Reset()         { *m = GetUsersResponse{} }
 func (m *GetUsersResponse) String() string { return proto.CompactTextString(m) }
 func (*GetUsersResponse) ProtoMessage()    {}
 func (*GetUsersResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{47}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{49}
 }
 func (m *GetUsersResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2738,7 +2839,7 @@ func (m *GetOneTimePasswordRequest) Reset()         { *m = GetOneTimePasswordReq
 func (m *GetOneTimePasswordRequest) String() string { return proto.CompactTextString(m) }
 func (*GetOneTimePasswordRequest) ProtoMessage()    {}
 func (*GetOneTimePasswordRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{48}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{50}
 }
 func (m *GetOneTimePasswordRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2785,7 +2886,7 @@ func (m *GetOneTimePasswordResponse) Reset()         { *m = GetOneTimePasswordRe
 func (m *GetOneTimePasswordResponse) String() string { return proto.CompactTextString(m) }
 func (*GetOneTimePasswordResponse) ProtoMessage()    {}
 func (*GetOneTimePasswordResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_auth_88ed6f4e558dc5e3, []int{49}
+	return fileDescriptor_auth_8a76284ea2be56e1, []int{51}
 }
 func (m *GetOneTimePasswordResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -2867,6 +2968,8 @@ func init() {
 	proto.RegisterType((*ExtendAuthTokenResponse)(nil), "auth.ExtendAuthTokenResponse")
 	proto.RegisterType((*RevokeAuthTokenRequest)(nil), "auth.RevokeAuthTokenRequest")
 	proto.RegisterType((*RevokeAuthTokenResponse)(nil), "auth.RevokeAuthTokenResponse")
+	proto.RegisterType((*GetS3CredentialsRequest)(nil), "auth.GetS3CredentialsRequest")
+	proto.RegisterType((*GetS3CredentialsResponse)(nil), "auth.GetS3CredentialsResponse")
 	proto.RegisterType((*SetGroupsForUserRequest)(nil), "auth.SetGroupsForUserRequest")
 	proto.RegisterType((*SetGroupsForUserResponse)(nil), "auth.SetGroupsForUserResponse")
 	proto.RegisterType((*ModifyMembersRequest)(nil), "auth.ModifyMembersRequest")
@@ -2914,6 +3017,7 @@ type APIClient interface {
 	GetAuthToken(ctx context.Context, in *GetAuthTokenRequest, opts ...grpc.CallOption) (*GetAuthTokenResponse, error)
 	ExtendAuthToken(ctx context.Context, in *ExtendAuthTokenRequest, opts ...grpc.CallOption) (*ExtendAuthTokenResponse, error)
 	RevokeAuthToken(ctx context.Context, in *RevokeAuthTokenRequest, opts ...grpc.CallOption) (*RevokeAuthTokenResponse, error)
+	GetS3Credentials(ctx context.Context, in *GetS3CredentialsRequest, opts ...grpc.CallOption) (*GetS3CredentialsResponse, error)
 	SetGroupsForUser(ctx context.Context, in *SetGroupsForUserRequest, opts ...grpc.CallOption) (*SetGroupsForUserResponse, error)
 	ModifyMembers(ctx context.Context, in *ModifyMembersRequest, opts ...grpc.CallOption) (*ModifyMembersResponse, error)
 	GetGroups(ctx context.Context, in *GetGroupsRequest, opts ...grpc.CallOption) (*GetGroupsResponse, error)
@@ -3073,6 +3177,15 @@ func (c *aPIClient) RevokeAuthToken(ctx context.Context, in *RevokeAuthTokenRequ
 	return out, nil
 }
 
+func (c *aPIClient) GetS3Credentials(ctx context.Context, in *GetS3CredentialsRequest, opts ...grpc.CallOption) (*GetS3CredentialsResponse, error) {
+	out := new(GetS3CredentialsResponse)
+	err := c.cc.Invoke(ctx, "/auth.API/GetS3Credentials", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *aPIClient) SetGroupsForUser(ctx context.Context, in *SetGroupsForUserRequest, opts ...grpc.CallOption) (*SetGroupsForUserResponse, error) {
 	out := new(SetGroupsForUserResponse)
 	err := c.cc.Invoke(ctx, "/auth.API/SetGroupsForUser", in, out, opts...)
@@ -3141,6 +3254,7 @@ type APIServer interface {
 	GetAuthToken(context.Context, *GetAuthTokenRequest) (*GetAuthTokenResponse, error)
 	ExtendAuthToken(context.Context, *ExtendAuthTokenRequest) (*ExtendAuthTokenResponse, error)
 	RevokeAuthToken(context.Context, *RevokeAuthTokenRequest) (*RevokeAuthTokenResponse, error)
+	GetS3Credentials(context.Context, *GetS3CredentialsRequest) (*GetS3CredentialsResponse, error)
 	SetGroupsForUser(context.Context, *SetGroupsForUserRequest) (*SetGroupsForUserResponse, error)
 	ModifyMembers(context.Context, *ModifyMembersRequest) (*ModifyMembersResponse, error)
 	GetGroups(context.Context, *GetGroupsRequest) (*GetGroupsResponse, error)
@@ -3440,6 +3554,24 @@ func _API_RevokeAuthToken_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _API_GetS3Credentials_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetS3CredentialsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).GetS3Credentials(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/auth.API/GetS3Credentials",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).GetS3Credentials(ctx, req.(*GetS3CredentialsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _API_SetGroupsForUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(SetGroupsForUserRequest)
 	if err := dec(in); err != nil {
@@ -3598,6 +3730,10 @@ var _API_serviceDesc = grpc.ServiceDesc{
 			MethodName: "RevokeAuthToken",
 			Handler:    _API_RevokeAuthToken_Handler,
 		},
+		{
+			MethodName: "GetS3Credentials",
+			Handler:    _API_GetS3Credentials_Handler,
+		},
 		{
 			MethodName: "SetGroupsForUser",
 			Handler:    _API_SetGroupsForUser_Handler,
@@ -4988,6 +5124,60 @@ func (m *RevokeAuthTokenResponse) MarshalTo(dAtA []byte) (int, error) {
 	return i, nil
 }
 
+func (m *GetS3CredentialsRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *GetS3CredentialsRequest) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i += copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	return i, nil
+}
+
+func (m *GetS3CredentialsResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *GetS3CredentialsResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.AccessKeyID) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintAuth(dAtA, i, uint64(len(m.AccessKeyID)))
+		i += copy(dAtA[i:], m.AccessKeyID)
+	}
+	if len(m.SecretKey) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintAuth(dAtA, i, uint64(len(m.SecretKey)))
+		i += copy(dAtA[i:], m.SecretKey)
+	}
+	if m.XXX_unrecognized != nil {
+		i += copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	return i, nil
+}
+
 func (m *SetGroupsForUserRequest) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -6074,6 +6264,38 @@ func (m *RevokeAuthTokenResponse) Size() (n int) {
 	return n
 }
 
+func (m *GetS3CredentialsRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *GetS3CredentialsResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.AccessKeyID)
+	if l > 0 {
+		n += 1 + l + sovAuth(uint64(l))
+	}
+	l = len(m.SecretKey)
+	if l > 0 {
+		n += 1 + l + sovAuth(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
 func (m *SetGroupsForUserRequest) Size() (n int) {
 	if m == nil {
 		return 0
@@ -10283,6 +10505,166 @@ func (m *RevokeAuthTokenResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *GetS3CredentialsRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowAuth
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: GetS3CredentialsRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: GetS3CredentialsRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipAuth(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthAuth
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *GetS3CredentialsResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowAuth
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: GetS3CredentialsResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: GetS3CredentialsResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AccessKeyID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAuth
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAuth
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.AccessKeyID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SecretKey", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAuth
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAuth
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SecretKey = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipAuth(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthAuth
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func (m *SetGroupsForUserRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
@@ -11217,126 +11599,131 @@ var (
 	ErrIntOverflowAuth   = fmt.Errorf("proto: integer overflow")
 )
 
-func init() { proto.RegisterFile("client/auth/auth.proto", fileDescriptor_auth_88ed6f4e558dc5e3) }
-
-var fileDescriptor_auth_88ed6f4e558dc5e3 = []byte{
-	// 1873 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x58, 0x5b, 0x73, 0xe3, 0x48,
-	0x15, 0x8e, 0xed, 0xc4, 0x97, 0x63, 0x27, 0x76, 0x3a, 0x5e, 0xc7, 0xd1, 0xee, 0x24, 0x41, 0xa9,
-	0x62, 0xc3, 0x52, 0xe5, 0x0c, 0x19, 0x06, 0x96, 0x1d, 0x0a, 0x70, 0x12, 0xaf, 0xd7, 0x8b, 0x73,
-	0x41, 0xf2, 0xcc, 0x2c, 0xbc, 0xb8, 0x64, 0xa9, 0xc7, 0x11, 0x63, 0x5b, 0x46, 0x17, 0x33, 0xe1,
-	0x05, 0xfe, 0x05, 0x3c, 0xc1, 0xbf, 0xa1, 0x78, 0x84, 0x3f, 0x90, 0xa2, 0x4c, 0xf1, 0x3f, 0xa8,
-	0xbe, 0xc9, 0x2d, 0x59, 0xce, 0x64, 0xd8, 0x97, 0xa4, 0xfb, 0x5c, 0xbe, 0x3e, 0x7d, 0xba, 0xfb,
-	0x7c, 0x47, 0x86, 0x9a, 0x39, 0xb2, 0xf1, 0xc4, 0x3f, 0x31, 0x02, 0xff, 0x96, 0xfe, 0x69, 0x4c,
-	0x5d, 0xc7, 0x77, 0xd0, 0x3a, 0x19, 0x2b, 0xd5, 0xa1, 0x33, 0x74, 0xa8, 0xe0, 0x84, 0x8c, 0x98,
-	0x4e, 0x39, 0x18, 0x3a, 0xce, 0x70, 0x84, 0x4f, 0xe8, 0x6c, 0x10, 0xbc, 0x39, 0xf1, 0xed, 0x31,
-	0xf6, 0x7c, 0x63, 0x3c, 0x65, 0x06, 0x6a, 0x1f, 0xca, 0x4d, 0xd3, 0xb7, 0x67, 0x86, 0x8f, 0x35,
-	0xfc, 0xbb, 0x00, 0x7b, 0x3e, 0x3a, 0x85, 0xd2, 0xd0, 0xf6, 0x6f, 0x83, 0x41, 0xdf, 0x77, 0xde,
-	0xe2, 0x49, 0x3d, 0x75, 0x98, 0x3a, 0x2e, 0x9c, 0x95, 0xe7, 0xf7, 0x07, 0xc5, 0xb6, 0xed, 0x7f,
-	0x15, 0x0c, 0x7a, 0x44, 0xac, 0x15, 0x99, 0x11, 0x9d, 0xa0, 0x3a, 0xe4, 0xbc, 0x60, 0xf0, 0x5b,
-	0x6c, 0xfa, 0xf5, 0x34, 0x31, 0xd7, 0xc4, 0x54, 0xfd, 0x01, 0x54, 0x16, 0x0b, 0x78, 0x53, 0x67,
-	0xe2, 0x61, 0xf4, 0x04, 0x60, 0x6a, 0x98, 0xb7, 0x32, 0xbe, 0x56, 0x20, 0x12, 0x0a, 0xa6, 0xee,
-	0xc0, 0xf6, 0x05, 0x36, 0xa2, 0x51, 0xa9, 0x55, 0x40, 0xb2, 0x90, 0x21, 0xa9, 0x7f, 0x4b, 0x03,
-	0x74, 0x2e, 0x6e, 0x5c, 0x67, 0x66, 0x5b, 0xd8, 0x45, 0x08, 0xd6, 0x27, 0xc6, 0x18, 0x73, 0x48,
-	0x3a, 0x46, 0x87, 0x50, 0xb4, 0xb0, 0x67, 0xba, 0xf6, 0xd4, 0xb7, 0x9d, 0x09, 0x0f, 0x4f, 0x16,
-	0xa1, 0x2f, 0x60, 0xdd, 0x33, 0xc6, 0xa3, 0x7a, 0xe6, 0x30, 0x75, 0x5c, 0x3c, 0xfd, 0xa4, 0x41,
-	0x73, 0xbb, 0x40, 0x6d, 0xe8, 0xcd, 0xcb, 0xee, 0x35, 0x35, 0xf5, 0xce, 0xf2, 0xf3, 0xfb, 0x83,
-	0x75, 0x22, 0xd0, 0xa8, 0x8f, 0xf2, 0xd7, 0x14, 0x14, 0x25, 0x3d, 0x49, 0xde, 0x18, 0xfb, 0x86,
-	0x65, 0xf8, 0x46, 0x3f, 0x70, 0x47, 0x72, 0xf2, 0x2e, 0xb9, 0xfc, 0xa5, 0xd6, 0xd5, 0x8a, 0xc2,
-	0xe8, 0xa5, 0x3b, 0x8a, 0xf8, 0xbc, 0x1b, 0x8f, 0x68, 0x88, 0xa5, 0xa8, 0xcf, 0x37, 0x97, 0x92,
-	0xcf, 0x37, 0xe3, 0x11, 0xfa, 0x14, 0xca, 0x43, 0xd7, 0x09, 0xa6, 0x7d, 0xc3, 0xf7, 0x5d, 0x7b,
-	0x10, 0xf8, 0x98, 0x86, 0x5f, 0xd0, 0xb6, 0xa8, 0xb8, 0x29, 0xa4, 0xea, 0xbf, 0x32, 0x00, 0xcd,
-	0xc0, 0xbf, 0x3d, 0x77, 0x26, 0x6f, 0xec, 0x21, 0x6a, 0xc0, 0xce, 0xc8, 0x9e, 0xe1, 0xbe, 0x49,
-	0xa7, 0xfd, 0x19, 0x76, 0x3d, 0x92, 0x15, 0x12, 0x66, 0x46, 0xdb, 0x26, 0x2a, 0x66, 0xf8, 0x8a,
-	0x29, 0xd0, 0x05, 0x94, 0x6c, 0xab, 0x3f, 0xe5, 0xa9, 0xf0, 0xea, 0xe9, 0xc3, 0xcc, 0x71, 0xf1,
-	0xb4, 0x12, 0xcf, 0x11, 0x8b, 0x76, 0x31, 0xf7, 0xb4, 0xa2, 0x6d, 0x85, 0x13, 0x84, 0xa1, 0x42,
-	0xb2, 0xd5, 0xf7, 0x66, 0x66, 0xdf, 0x61, 0x99, 0xe2, 0xd9, 0x3e, 0x62, 0x48, 0x8b, 0x08, 0x69,
-	0xb6, 0x75, 0xec, 0xce, 0x6c, 0x13, 0x8b, 0xa4, 0xd7, 0xe6, 0xf7, 0x07, 0x68, 0x59, 0xae, 0x6d,
-	0x11, 0x50, 0x7d, 0x66, 0xf2, 0xb9, 0xf2, 0xdf, 0x14, 0x24, 0x98, 0xa1, 0x23, 0xc8, 0x19, 0xa6,
-	0x27, 0x1d, 0x07, 0xcc, 0xef, 0x0f, 0xb2, 0xcd, 0x73, 0x9d, 0x9c, 0x44, 0xd6, 0x30, 0xbd, 0xf8,
-	0x21, 0x10, 0xcb, 0xf4, 0x23, 0x0e, 0xee, 0xbb, 0x90, 0xb7, 0x0c, 0xef, 0x96, 0xda, 0xd3, 0xec,
-	0x9f, 0x15, 0xe7, 0xf7, 0x07, 0xb9, 0x0b, 0xc3, 0xbb, 0x25, 0xb6, 0x39, 0xa2, 0x24, 0x76, 0xdf,
-	0x83, 0x8a, 0x87, 0x3d, 0x92, 0xcf, 0xbe, 0x15, 0xb8, 0x06, 0xbd, 0x87, 0xeb, 0xf4, 0xb4, 0xca,
-	0x5c, 0x7e, 0xc1, 0xc5, 0xe8, 0x08, 0x36, 0x2d, 0x3c, 0x08, 0x86, 0xfd, 0x91, 0x33, 0x1c, 0xda,
-	0x93, 0x61, 0x7d, 0xe3, 0x30, 0x75, 0x9c, 0xd7, 0x4a, 0x54, 0xd8, 0x65, 0x32, 0x75, 0x0f, 0x76,
-	0xdb, 0xd8, 0x67, 0xf9, 0xe2, 0x8e, 0xe2, 0x99, 0x68, 0x50, 0x5f, 0x56, 0xf1, 0x67, 0xf7, 0x23,
-	0xd8, 0x34, 0x65, 0x05, 0xcd, 0x46, 0x78, 0x98, 0x8b, 0x23, 0xd0, 0xa2, 0x66, 0xea, 0xaf, 0x60,
-	0x57, 0x4f, 0x5e, 0xee, 0xff, 0x86, 0x54, 0xa0, 0xae, 0xaf, 0x08, 0x53, 0x45, 0x50, 0x69, 0x63,
-	0xbf, 0x69, 0x8d, 0xed, 0x89, 0x27, 0xb6, 0xf5, 0x7d, 0xd8, 0x96, 0x64, 0x7c, 0x3f, 0x35, 0xc8,
-	0x1a, 0x54, 0x52, 0x4f, 0x1d, 0x66, 0x8e, 0x0b, 0x1a, 0x9f, 0xa9, 0x3f, 0x87, 0x9d, 0x4b, 0xc7,
-	0xb2, 0xdf, 0xdc, 0x45, 0x30, 0x50, 0x05, 0x32, 0x86, 0x65, 0x71, 0x5b, 0x32, 0x24, 0x00, 0x2e,
-	0x1e, 0x3b, 0x33, 0x4c, 0xaf, 0x75, 0x41, 0xe3, 0x33, 0xb5, 0x06, 0xd5, 0x28, 0x00, 0x8f, 0x6c,
-	0x02, 0xb9, 0xeb, 0xde, 0x4d, 0x67, 0xf2, 0xc6, 0x91, 0x0b, 0x5e, 0x2a, 0x52, 0xf0, 0x50, 0x07,
-	0x90, 0x38, 0x6c, 0xfc, 0x6e, 0x6a, 0xf3, 0xbc, 0xa4, 0x69, 0x5e, 0x94, 0x06, 0xab, 0xc7, 0x0d,
-	0x51, 0x8f, 0x1b, 0x3d, 0x51, 0x8f, 0xb5, 0x6d, 0xee, 0xd5, 0x0a, 0x9d, 0xd4, 0x3f, 0xa7, 0xa0,
-	0x40, 0x4b, 0xe2, 0x7b, 0x96, 0x7c, 0x06, 0x59, 0xcf, 0x09, 0x5c, 0x13, 0xd3, 0x65, 0xb6, 0x4e,
-	0x3f, 0x66, 0xe9, 0x0f, 0x5d, 0xd9, 0x48, 0xa7, 0x26, 0x1a, 0x37, 0x55, 0x5f, 0x40, 0x51, 0x12,
-	0xa3, 0x22, 0xe4, 0x3a, 0x57, 0xaf, 0x9a, 0xdd, 0xce, 0x45, 0x65, 0x0d, 0x55, 0xa0, 0xd4, 0x7c,
-	0xd9, 0xfb, 0xaa, 0x75, 0xd5, 0xeb, 0x9c, 0x37, 0x7b, 0xad, 0x4a, 0x0a, 0x6d, 0x42, 0xa1, 0xdd,
-	0xea, 0xf5, 0x7b, 0xd7, 0xbf, 0x6c, 0x5d, 0x55, 0xd2, 0x6a, 0x00, 0x3b, 0xe4, 0x70, 0xf1, 0xc4,
-	0xb7, 0xcd, 0x6f, 0x49, 0x1d, 0x9f, 0xc1, 0xb6, 0x33, 0xc1, 0x7d, 0x42, 0x4c, 0xfd, 0xa9, 0xe1,
-	0x79, 0xbf, 0x77, 0x5c, 0x8b, 0x57, 0xe9, 0xb2, 0x33, 0xc1, 0x24, 0x41, 0x37, 0x5c, 0xac, 0x3e,
-	0x87, 0x6a, 0x74, 0xd9, 0xc7, 0x11, 0x4a, 0x19, 0x36, 0x5f, 0xdf, 0x3a, 0xcd, 0x71, 0x47, 0x5c,
-	0xa7, 0x01, 0x6c, 0x09, 0x01, 0x47, 0x50, 0x20, 0x1f, 0x78, 0xd8, 0x95, 0xd8, 0x23, 0x9c, 0xa3,
-	0x3d, 0xc8, 0xdb, 0x5e, 0x9f, 0x5e, 0x2e, 0x1a, 0x58, 0x5e, 0xcb, 0xd9, 0x1e, 0xbd, 0x1a, 0x68,
-	0x0f, 0x32, 0xbe, 0xcf, 0x1e, 0x7f, 0xe6, 0x2c, 0x37, 0xbf, 0x3f, 0xc8, 0xf4, 0x7a, 0x5d, 0x8d,
-	0xc8, 0xd4, 0x3f, 0xa5, 0x20, 0xd3, 0x3c, 0xef, 0xa2, 0xa7, 0x90, 0xc3, 0x13, 0xdf, 0xb5, 0x31,
-	0xbb, 0xa6, 0xc5, 0xd3, 0x1a, 0x7f, 0x1c, 0xe7, 0xdd, 0x46, 0x8b, 0x29, 0xc8, 0xbf, 0x3b, 0x4d,
-	0x98, 0x29, 0x6d, 0x28, 0xc9, 0x0a, 0x72, 0x71, 0xdf, 0xe2, 0x3b, 0x1e, 0x16, 0x19, 0xa2, 0xef,
-	0xc0, 0xc6, 0xcc, 0x18, 0x05, 0xe2, 0xbc, 0x8b, 0x0c, 0x51, 0x37, 0x9d, 0x29, 0xd6, 0x98, 0xe6,
-	0x8b, 0xf4, 0xe7, 0x29, 0xf5, 0x8f, 0xb0, 0xf1, 0xd2, 0x23, 0xf5, 0xf7, 0x73, 0x28, 0x88, 0xdd,
-	0x88, 0x28, 0x14, 0xe6, 0x43, 0xf5, 0xf4, 0x2f, 0x55, 0xb2, 0x48, 0x16, 0xc6, 0xca, 0x4f, 0x61,
-	0x2b, 0xaa, 0x4c, 0x88, 0xa6, 0x2a, 0x47, 0x93, 0x97, 0x03, 0x08, 0x20, 0xdb, 0x26, 0x74, 0xe4,
-	0xa1, 0xa7, 0x90, 0xa5, 0xc4, 0x24, 0x96, 0xaf, 0xb3, 0xe5, 0x99, 0x96, 0xff, 0x63, 0x8b, 0x73,
-	0x3b, 0xe5, 0x27, 0x50, 0x94, 0xc4, 0x1f, 0xb4, 0x6c, 0x07, 0x2a, 0xe4, 0x9a, 0x38, 0xae, 0xfd,
-	0x87, 0xf0, 0x6a, 0x22, 0x58, 0x77, 0xf1, 0xd4, 0x11, 0xad, 0x01, 0x19, 0x93, 0x34, 0x7a, 0x24,
-	0x67, 0x89, 0x69, 0xa4, 0x1a, 0xf5, 0x19, 0x6c, 0x4b, 0x50, 0xfc, 0xb2, 0xec, 0x03, 0x18, 0x42,
-	0x68, 0x51, 0xc4, 0xbc, 0x26, 0x49, 0xd4, 0x73, 0x28, 0xb7, 0xb1, 0xcf, 0x70, 0xf8, 0xf2, 0x0f,
-	0xdd, 0xaf, 0x2a, 0x6c, 0x90, 0x70, 0x3c, 0x5e, 0x85, 0xd8, 0x44, 0xfd, 0x31, 0x2d, 0x83, 0x1c,
-	0x84, 0x2f, 0x7c, 0x04, 0x59, 0x1a, 0x16, 0xcb, 0x62, 0x2c, 0x62, 0xae, 0x52, 0x2d, 0x28, 0xeb,
-	0x1f, 0xb0, 0xba, 0x48, 0x4c, 0x3a, 0x29, 0x31, 0x99, 0x95, 0x89, 0x41, 0x50, 0xd1, 0x63, 0xe1,
-	0xa9, 0x47, 0xb0, 0x49, 0xaa, 0xf4, 0x79, 0xf7, 0x81, 0xa4, 0xab, 0x1d, 0xc8, 0x37, 0xcf, 0xbb,
-	0xec, 0x50, 0x1f, 0x8a, 0xeb, 0x11, 0x87, 0xe3, 0xc0, 0x96, 0x58, 0x8f, 0x27, 0xe8, 0x38, 0xfe,
-	0xd8, 0xb6, 0xc2, 0xc7, 0x16, 0x7d, 0x64, 0xe8, 0x19, 0x6c, 0xba, 0xce, 0xc0, 0xf1, 0xfb, 0xc2,
-	0x3e, 0x9d, 0x68, 0x5f, 0xa2, 0x46, 0xfc, 0x39, 0xaa, 0x97, 0xb0, 0xa9, 0xbf, 0x6f, 0x83, 0x72,
-	0x0c, 0xe9, 0x07, 0x63, 0x50, 0x2b, 0xb0, 0xa5, 0x47, 0xe2, 0x57, 0xbf, 0x86, 0x1d, 0xb2, 0xa3,
-	0xc0, 0x67, 0x95, 0x4b, 0x2c, 0xb3, 0xba, 0xf4, 0xf3, 0x02, 0x94, 0x4e, 0x28, 0x40, 0x5f, 0x42,
-	0x35, 0x8a, 0xc5, 0x73, 0x54, 0x85, 0x0d, 0xb9, 0x4e, 0xb2, 0xc9, 0x03, 0x1d, 0x7c, 0x07, 0x6a,
-	0xad, 0x77, 0x3e, 0x9e, 0x58, 0x4b, 0x61, 0x25, 0x23, 0x3d, 0x10, 0xd2, 0x1e, 0xec, 0x2e, 0x41,
-	0xf1, 0x9d, 0x37, 0xa0, 0xa6, 0xe1, 0x99, 0xf3, 0x16, 0x3f, 0x6e, 0x15, 0x02, 0xb5, 0x64, 0xcf,
-	0xa1, 0x2e, 0x69, 0xbf, 0xc2, 0x8a, 0xc7, 0x97, 0x8e, 0x4b, 0xea, 0xd7, 0x63, 0x1e, 0x42, 0x2d,
-	0x2c, 0x51, 0xbc, 0x1b, 0x60, 0x33, 0xde, 0xab, 0xc4, 0xe0, 0xf8, 0x52, 0xaf, 0x44, 0xa7, 0x70,
-	0x89, 0xc7, 0x03, 0xd2, 0xf6, 0x2e, 0x62, 0xa6, 0xde, 0x22, 0x66, 0x3a, 0x11, 0x1d, 0x48, 0x3a,
-	0xa9, 0x03, 0xc9, 0x44, 0x3a, 0x90, 0x5d, 0xf8, 0x28, 0x86, 0x1b, 0xa6, 0x89, 0x54, 0x05, 0x16,
-	0xcc, 0x23, 0x36, 0xc5, 0x1b, 0x27, 0x61, 0xbf, 0x68, 0x9c, 0xa4, 0x62, 0xbc, 0xd8, 0xe9, 0xa7,
-	0xb4, 0x6e, 0x51, 0x4a, 0x78, 0x70, 0x23, 0xea, 0x53, 0x1a, 0x05, 0x37, 0xe4, 0xa0, 0x9f, 0xc4,
-	0x39, 0xa6, 0x20, 0xf1, 0x88, 0xfa, 0x1c, 0xf6, 0xda, 0xd8, 0xbf, 0x8e, 0xf2, 0xf9, 0x7b, 0xaf,
-	0xb7, 0xfa, 0x14, 0x94, 0x24, 0x37, 0xbe, 0x24, 0x82, 0x75, 0xd3, 0xb1, 0xc2, 0xcf, 0x3d, 0x32,
-	0xfe, 0xec, 0x87, 0xb0, 0x41, 0x6b, 0x04, 0xca, 0xc3, 0xfa, 0xd5, 0xf5, 0x55, 0xab, 0xb2, 0x86,
-	0x00, 0xb2, 0x5a, 0xab, 0x79, 0xd1, 0xd2, 0x2a, 0x29, 0x32, 0x7e, 0xad, 0x75, 0x7a, 0x2d, 0xad,
-	0x92, 0x46, 0x05, 0xd8, 0xb8, 0x7e, 0x7d, 0xd5, 0xd2, 0x2a, 0x99, 0xd3, 0xbf, 0x17, 0x21, 0xd3,
-	0xbc, 0xe9, 0xa0, 0x17, 0x90, 0x17, 0x5f, 0xab, 0xe8, 0x23, 0xfe, 0x6c, 0xa3, 0x1f, 0xa2, 0x4a,
-	0x2d, 0x2e, 0xe6, 0x27, 0xb3, 0x86, 0x9a, 0x00, 0x8b, 0x4f, 0x54, 0xb4, 0xcb, 0xec, 0x96, 0xbe,
-	0x64, 0x95, 0xfa, 0xb2, 0x22, 0x84, 0xd0, 0x69, 0x62, 0x23, 0x7d, 0x31, 0x7a, 0xc2, 0xa9, 0x32,
-	0xb9, 0x05, 0x57, 0xf6, 0x57, 0xa9, 0x65, 0x50, 0x7d, 0x05, 0xa8, 0xfe, 0x30, 0xa8, 0xbe, 0x1a,
-	0xf4, 0x67, 0x50, 0x08, 0x3b, 0x72, 0x54, 0x0b, 0x63, 0x88, 0xb4, 0xdc, 0xca, 0xee, 0x92, 0x3c,
-	0xf4, 0x6f, 0x43, 0x49, 0xee, 0xb1, 0xd1, 0x1e, 0x33, 0x4d, 0x68, 0xdc, 0x15, 0x25, 0x49, 0x25,
-	0x03, 0xc9, 0x3d, 0xa1, 0x00, 0x4a, 0x68, 0x4f, 0x05, 0x50, 0x52, 0x0b, 0xc9, 0x76, 0x14, 0x52,
-	0xbd, 0xd8, 0x51, 0xbc, 0x8d, 0x10, 0x3b, 0x5a, 0xea, 0x09, 0xd4, 0x35, 0xf4, 0x1c, 0xb2, 0xac,
-	0xa9, 0x44, 0x3b, 0xcc, 0x28, 0xd2, 0x73, 0x2a, 0xd5, 0xa8, 0x30, 0x74, 0x7b, 0x01, 0x79, 0xc1,
-	0xf3, 0xe2, 0xca, 0xc5, 0x9a, 0x07, 0xa5, 0x16, 0x17, 0xcb, 0xce, 0x7a, 0xcc, 0x59, 0x4f, 0x76,
-	0xd6, 0x97, 0x9d, 0x9f, 0x43, 0x96, 0xd1, 0xa7, 0x08, 0x38, 0x42, 0xde, 0x22, 0xe0, 0x28, 0xc3,
-	0x32, 0x37, 0x3d, 0xe2, 0xa6, 0x27, 0xb9, 0xe9, 0x71, 0xb7, 0x36, 0x94, 0x64, 0x3a, 0x12, 0xe7,
-	0x94, 0x40, 0x77, 0xe2, 0x9c, 0x92, 0xd8, 0x4b, 0x5d, 0x43, 0x37, 0x50, 0x8e, 0x91, 0x08, 0xe2,
-	0xbf, 0xd9, 0x24, 0xd3, 0x94, 0xf2, 0x64, 0x85, 0x56, 0x46, 0x8c, 0x71, 0x89, 0x40, 0x4c, 0xa6,
-	0x24, 0x81, 0xb8, 0x8a, 0x80, 0xc4, 0x93, 0x8b, 0x70, 0x86, 0xf4, 0xe4, 0x92, 0xa8, 0x49, 0x7a,
-	0x72, 0xc9, 0x54, 0xb3, 0x86, 0xbe, 0x86, 0xcd, 0x08, 0x29, 0xa0, 0xc8, 0xc3, 0x88, 0x32, 0x90,
-	0xf2, 0x71, 0xa2, 0x2e, 0xf6, 0x7c, 0x79, 0x73, 0xbe, 0xb8, 0x5f, 0x11, 0x62, 0x91, 0x9e, 0x6f,
-	0x94, 0x40, 0xc2, 0x5b, 0xcb, 0xbe, 0x2e, 0x16, 0xb7, 0x56, 0xa6, 0x0e, 0xe9, 0xd6, 0x46, 0x88,
-	0x42, 0x5d, 0x43, 0xbf, 0x06, 0xb4, 0x5c, 0xd5, 0xd1, 0x41, 0x68, 0x9f, 0x4c, 0x13, 0xca, 0xe1,
-	0x6a, 0x03, 0x01, 0x7d, 0xf6, 0x8b, 0x7f, 0xcc, 0xf7, 0x53, 0xff, 0x9c, 0xef, 0xa7, 0xfe, 0x3d,
-	0xdf, 0x4f, 0xfd, 0xe5, 0x3f, 0xfb, 0x6b, 0xbf, 0x69, 0xb0, 0x8f, 0xcd, 0x86, 0xe9, 0x8c, 0x4f,
-	0xc8, 0x27, 0xe1, 0x9d, 0x85, 0x5d, 0x79, 0xe4, 0xb9, 0xe6, 0x89, 0xf4, 0xcb, 0xea, 0x20, 0x4b,
-	0xbf, 0xcd, 0x9f, 0xfd, 0x2f, 0x00, 0x00, 0xff, 0xff, 0xc5, 0xb4, 0xe4, 0xe8, 0x6f, 0x15, 0x00,
-	0x00,
+func init() { proto.RegisterFile("client/auth/auth.proto", fileDescriptor_auth_8a76284ea2be56e1) }
+
+var fileDescriptor_auth_8a76284ea2be56e1 = []byte{
+	// 1954 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x58, 0xdd, 0x72, 0xe3, 0x48,
+	0x15, 0x8e, 0xed, 0xc4, 0xb1, 0x8f, 0x9d, 0xc4, 0xe9, 0x64, 0x1d, 0x47, 0xbb, 0x93, 0x04, 0x4d,
+	0x15, 0x1b, 0x96, 0x2a, 0x67, 0x48, 0x18, 0x58, 0x76, 0x28, 0xc0, 0x71, 0xbc, 0x5e, 0xef, 0x38,
+	0x3f, 0x48, 0x9e, 0x99, 0x85, 0x1b, 0x95, 0x2c, 0xf5, 0x38, 0x62, 0x6c, 0xcb, 0xe8, 0xc7, 0x4c,
+	0xb8, 0x81, 0xb7, 0x80, 0x2a, 0xaa, 0xe0, 0x75, 0xb8, 0x84, 0x17, 0x48, 0x51, 0xa6, 0x78, 0x0f,
+	0xaa, 0xff, 0xe4, 0x96, 0x2c, 0x67, 0xb2, 0x70, 0x93, 0xa8, 0xcf, 0xcf, 0xd7, 0xa7, 0x4f, 0x77,
+	0x9f, 0xef, 0xb4, 0xa1, 0x6a, 0x0d, 0x1d, 0x3c, 0x0e, 0x4e, 0xcc, 0x30, 0xb8, 0xa5, 0x7f, 0xea,
+	0x13, 0xcf, 0x0d, 0x5c, 0xb4, 0x4a, 0xbe, 0x95, 0xdd, 0x81, 0x3b, 0x70, 0xa9, 0xe0, 0x84, 0x7c,
+	0x31, 0x9d, 0x72, 0x38, 0x70, 0xdd, 0xc1, 0x10, 0x9f, 0xd0, 0x51, 0x3f, 0x7c, 0x7b, 0x12, 0x38,
+	0x23, 0xec, 0x07, 0xe6, 0x68, 0xc2, 0x0c, 0x54, 0x03, 0xb6, 0x1a, 0x56, 0xe0, 0x4c, 0xcd, 0x00,
+	0x6b, 0xf8, 0xb7, 0x21, 0xf6, 0x03, 0x74, 0x0a, 0xe5, 0x81, 0x13, 0xdc, 0x86, 0x7d, 0x23, 0x70,
+	0xdf, 0xe1, 0x71, 0x2d, 0x73, 0x94, 0x39, 0x2e, 0x9e, 0x6f, 0xcd, 0xee, 0x0f, 0x4b, 0x6d, 0x27,
+	0xf8, 0x2a, 0xec, 0xf7, 0x88, 0x58, 0x2b, 0x31, 0x23, 0x3a, 0x40, 0x35, 0x58, 0xf7, 0xc3, 0xfe,
+	0x6f, 0xb0, 0x15, 0xd4, 0xb2, 0xc4, 0x5c, 0x13, 0x43, 0xf5, 0x07, 0x50, 0x99, 0x4f, 0xe0, 0x4f,
+	0xdc, 0xb1, 0x8f, 0xd1, 0x13, 0x80, 0x89, 0x69, 0xdd, 0xca, 0xf8, 0x5a, 0x91, 0x48, 0x28, 0x98,
+	0xba, 0x03, 0xdb, 0x17, 0xd8, 0x8c, 0x47, 0xa5, 0xee, 0x02, 0x92, 0x85, 0x0c, 0x49, 0xfd, 0x5b,
+	0x16, 0xa0, 0x73, 0x71, 0xe3, 0xb9, 0x53, 0xc7, 0xc6, 0x1e, 0x42, 0xb0, 0x3a, 0x36, 0x47, 0x98,
+	0x43, 0xd2, 0x6f, 0x74, 0x04, 0x25, 0x1b, 0xfb, 0x96, 0xe7, 0x4c, 0x02, 0xc7, 0x1d, 0xf3, 0xf0,
+	0x64, 0x11, 0xfa, 0x02, 0x56, 0x7d, 0x73, 0x34, 0xac, 0xe5, 0x8e, 0x32, 0xc7, 0xa5, 0xd3, 0x4f,
+	0xea, 0x34, 0xb7, 0x73, 0xd4, 0xba, 0xde, 0xb8, 0xec, 0x5e, 0x53, 0x53, 0xff, 0xbc, 0x30, 0xbb,
+	0x3f, 0x5c, 0x25, 0x02, 0x8d, 0xfa, 0x28, 0x7f, 0xcd, 0x40, 0x49, 0xd2, 0x93, 0xe4, 0x8d, 0x70,
+	0x60, 0xda, 0x66, 0x60, 0x1a, 0xa1, 0x37, 0x94, 0x93, 0x77, 0xc9, 0xe5, 0xaf, 0xb4, 0xae, 0x56,
+	0x12, 0x46, 0xaf, 0xbc, 0x61, 0xcc, 0xe7, 0xfd, 0x68, 0x48, 0x43, 0x2c, 0xc7, 0x7d, 0xbe, 0xb9,
+	0x94, 0x7c, 0xbe, 0x19, 0x0d, 0xd1, 0xa7, 0xb0, 0x35, 0xf0, 0xdc, 0x70, 0x62, 0x98, 0x41, 0xe0,
+	0x39, 0xfd, 0x30, 0xc0, 0x34, 0xfc, 0xa2, 0xb6, 0x49, 0xc5, 0x0d, 0x21, 0x55, 0xff, 0x99, 0x03,
+	0x68, 0x84, 0xc1, 0x6d, 0xd3, 0x1d, 0xbf, 0x75, 0x06, 0xa8, 0x0e, 0x3b, 0x43, 0x67, 0x8a, 0x0d,
+	0x8b, 0x0e, 0x8d, 0x29, 0xf6, 0x7c, 0x92, 0x15, 0x12, 0x66, 0x4e, 0xdb, 0x26, 0x2a, 0x66, 0xf8,
+	0x9a, 0x29, 0xd0, 0x05, 0x94, 0x1d, 0xdb, 0x98, 0xf0, 0x54, 0xf8, 0xb5, 0xec, 0x51, 0xee, 0xb8,
+	0x74, 0x5a, 0x49, 0xe6, 0x88, 0x45, 0x3b, 0x1f, 0xfb, 0x5a, 0xc9, 0xb1, 0xa3, 0x01, 0xc2, 0x50,
+	0x21, 0xd9, 0x32, 0xfc, 0xa9, 0x65, 0xb8, 0x2c, 0x53, 0x3c, 0xdb, 0x4f, 0x19, 0xd2, 0x3c, 0x42,
+	0x9a, 0x6d, 0x1d, 0x7b, 0x53, 0xc7, 0xc2, 0x22, 0xe9, 0xd5, 0xd9, 0xfd, 0x21, 0x5a, 0x94, 0x6b,
+	0x9b, 0x04, 0x54, 0x9f, 0x5a, 0x7c, 0xac, 0xfc, 0x27, 0x03, 0x29, 0x66, 0xe8, 0x29, 0xac, 0x9b,
+	0x96, 0x2f, 0x6d, 0x07, 0xcc, 0xee, 0x0f, 0xf3, 0x8d, 0xa6, 0x4e, 0x76, 0x22, 0x6f, 0x5a, 0x7e,
+	0x72, 0x13, 0x88, 0x65, 0xf6, 0x11, 0x1b, 0xf7, 0x5d, 0x28, 0xd8, 0xa6, 0x7f, 0x4b, 0xed, 0x69,
+	0xf6, 0xcf, 0x4b, 0xb3, 0xfb, 0xc3, 0xf5, 0x0b, 0xd3, 0xbf, 0x25, 0xb6, 0xeb, 0x44, 0x49, 0xec,
+	0xbe, 0x07, 0x15, 0x1f, 0xfb, 0x24, 0x9f, 0x86, 0x1d, 0x7a, 0x26, 0x3d, 0x87, 0xab, 0x74, 0xb7,
+	0xb6, 0xb8, 0xfc, 0x82, 0x8b, 0xd1, 0x53, 0xd8, 0xb0, 0x71, 0x3f, 0x1c, 0x18, 0x43, 0x77, 0x30,
+	0x70, 0xc6, 0x83, 0xda, 0xda, 0x51, 0xe6, 0xb8, 0xa0, 0x95, 0xa9, 0xb0, 0xcb, 0x64, 0xea, 0x3e,
+	0xec, 0xb5, 0x71, 0xc0, 0xf2, 0xc5, 0x1d, 0xc5, 0x35, 0xd1, 0xa0, 0xb6, 0xa8, 0xe2, 0xd7, 0xee,
+	0x47, 0xb0, 0x61, 0xc9, 0x0a, 0x9a, 0x8d, 0x68, 0x33, 0xe7, 0x5b, 0xa0, 0xc5, 0xcd, 0xd4, 0x5f,
+	0xc2, 0x9e, 0x9e, 0x3e, 0xdd, 0xff, 0x0c, 0xa9, 0x40, 0x4d, 0x5f, 0x12, 0xa6, 0x8a, 0xa0, 0xd2,
+	0xc6, 0x41, 0xc3, 0x1e, 0x39, 0x63, 0x5f, 0x2c, 0xeb, 0xfb, 0xb0, 0x2d, 0xc9, 0xf8, 0x7a, 0xaa,
+	0x90, 0x37, 0xa9, 0xa4, 0x96, 0x39, 0xca, 0x1d, 0x17, 0x35, 0x3e, 0x52, 0x7f, 0x0e, 0x3b, 0x97,
+	0xae, 0xed, 0xbc, 0xbd, 0x8b, 0x61, 0xa0, 0x0a, 0xe4, 0x4c, 0xdb, 0xe6, 0xb6, 0xe4, 0x93, 0x00,
+	0x78, 0x78, 0xe4, 0x4e, 0x31, 0x3d, 0xd6, 0x45, 0x8d, 0x8f, 0xd4, 0x2a, 0xec, 0xc6, 0x01, 0x78,
+	0x64, 0x63, 0x58, 0xbf, 0xee, 0xdd, 0x74, 0xc6, 0x6f, 0x5d, 0xb9, 0xe0, 0x65, 0x62, 0x05, 0x0f,
+	0x75, 0x00, 0x89, 0xcd, 0xc6, 0xef, 0x27, 0x0e, 0xcf, 0x4b, 0x96, 0xe6, 0x45, 0xa9, 0xb3, 0x7a,
+	0x5c, 0x17, 0xf5, 0xb8, 0xde, 0x13, 0xf5, 0x58, 0xdb, 0xe6, 0x5e, 0xad, 0xc8, 0x49, 0xfd, 0x53,
+	0x06, 0x8a, 0xb4, 0x24, 0x7e, 0x60, 0xca, 0x33, 0xc8, 0xfb, 0x6e, 0xe8, 0x59, 0x98, 0x4e, 0xb3,
+	0x79, 0xfa, 0x31, 0x4b, 0x7f, 0xe4, 0xca, 0xbe, 0x74, 0x6a, 0xa2, 0x71, 0x53, 0xf5, 0x05, 0x94,
+	0x24, 0x31, 0x2a, 0xc1, 0x7a, 0xe7, 0xea, 0x75, 0xa3, 0xdb, 0xb9, 0xa8, 0xac, 0xa0, 0x0a, 0x94,
+	0x1b, 0xaf, 0x7a, 0x5f, 0xb5, 0xae, 0x7a, 0x9d, 0x66, 0xa3, 0xd7, 0xaa, 0x64, 0xd0, 0x06, 0x14,
+	0xdb, 0xad, 0x9e, 0xd1, 0xbb, 0x7e, 0xd9, 0xba, 0xaa, 0x64, 0xd5, 0x10, 0x76, 0xc8, 0xe6, 0xe2,
+	0x71, 0xe0, 0x58, 0xff, 0x27, 0x75, 0x7c, 0x06, 0xdb, 0xee, 0x18, 0x1b, 0x84, 0x98, 0x8c, 0x89,
+	0xe9, 0xfb, 0xbf, 0x73, 0x3d, 0x9b, 0x57, 0xe9, 0x2d, 0x77, 0x8c, 0x49, 0x82, 0x6e, 0xb8, 0x58,
+	0x7d, 0x0e, 0xbb, 0xf1, 0x69, 0x1f, 0x47, 0x28, 0x5b, 0xb0, 0xf1, 0xe6, 0xd6, 0x6d, 0x8c, 0x3a,
+	0xe2, 0x38, 0xf5, 0x61, 0x53, 0x08, 0x38, 0x82, 0x02, 0x85, 0xd0, 0xc7, 0x9e, 0xc4, 0x1e, 0xd1,
+	0x18, 0xed, 0x43, 0xc1, 0xf1, 0x0d, 0x7a, 0xb8, 0x68, 0x60, 0x05, 0x6d, 0xdd, 0xf1, 0xe9, 0xd1,
+	0x40, 0xfb, 0x90, 0x0b, 0x02, 0x76, 0xf9, 0x73, 0xe7, 0xeb, 0xb3, 0xfb, 0xc3, 0x5c, 0xaf, 0xd7,
+	0xd5, 0x88, 0x4c, 0xfd, 0x63, 0x06, 0x72, 0x8d, 0x66, 0x17, 0x3d, 0x83, 0x75, 0x3c, 0x0e, 0x3c,
+	0x07, 0xb3, 0x63, 0x5a, 0x3a, 0xad, 0xf2, 0xcb, 0xd1, 0xec, 0xd6, 0x5b, 0x4c, 0x41, 0xfe, 0xdd,
+	0x69, 0xc2, 0x4c, 0x69, 0x43, 0x59, 0x56, 0x90, 0x83, 0xfb, 0x0e, 0xdf, 0xf1, 0xb0, 0xc8, 0x27,
+	0xfa, 0x0e, 0xac, 0x4d, 0xcd, 0x61, 0x28, 0xf6, 0xbb, 0xc4, 0x10, 0x75, 0xcb, 0x9d, 0x60, 0x8d,
+	0x69, 0xbe, 0xc8, 0x7e, 0x9e, 0x51, 0xff, 0x00, 0x6b, 0xaf, 0x7c, 0x52, 0x7f, 0x3f, 0x87, 0xa2,
+	0x58, 0x8d, 0x88, 0x42, 0x61, 0x3e, 0x54, 0x4f, 0xff, 0x52, 0x25, 0x8b, 0x64, 0x6e, 0xac, 0xfc,
+	0x14, 0x36, 0xe3, 0xca, 0x94, 0x68, 0x76, 0xe5, 0x68, 0x0a, 0x72, 0x00, 0x21, 0xe4, 0xdb, 0x84,
+	0x8e, 0x7c, 0xf4, 0x0c, 0xf2, 0x94, 0x98, 0xc4, 0xf4, 0x35, 0x36, 0x3d, 0xd3, 0xf2, 0x7f, 0x6c,
+	0x72, 0x6e, 0xa7, 0xfc, 0x04, 0x4a, 0x92, 0xf8, 0x5b, 0x4d, 0xdb, 0x81, 0x0a, 0x39, 0x26, 0xae,
+	0xe7, 0xfc, 0x3e, 0x3a, 0x9a, 0x08, 0x56, 0x3d, 0x3c, 0x71, 0x45, 0x6b, 0x40, 0xbe, 0x49, 0x1a,
+	0x7d, 0x92, 0xb3, 0xd4, 0x34, 0x52, 0x8d, 0x7a, 0x06, 0xdb, 0x12, 0x14, 0x3f, 0x2c, 0x07, 0x00,
+	0xa6, 0x10, 0xda, 0x14, 0xb1, 0xa0, 0x49, 0x12, 0xb5, 0x09, 0x5b, 0x6d, 0x1c, 0x30, 0x1c, 0x3e,
+	0xfd, 0x43, 0xe7, 0x6b, 0x17, 0xd6, 0x48, 0x38, 0x3e, 0xaf, 0x42, 0x6c, 0xa0, 0xfe, 0x98, 0x96,
+	0x41, 0x0e, 0xc2, 0x27, 0x7e, 0x0a, 0x79, 0x1a, 0x16, 0xcb, 0x62, 0x22, 0x62, 0xae, 0x52, 0x6d,
+	0xd8, 0xd2, 0xbf, 0xc5, 0xec, 0x22, 0x31, 0xd9, 0xb4, 0xc4, 0xe4, 0x96, 0x26, 0x06, 0x41, 0x45,
+	0x4f, 0x84, 0xa7, 0x3e, 0x85, 0x0d, 0x52, 0xa5, 0x9b, 0xdd, 0x07, 0x92, 0xae, 0x76, 0xa0, 0xd0,
+	0x68, 0x76, 0xd9, 0xa6, 0x3e, 0x14, 0xd7, 0x23, 0x36, 0xc7, 0x85, 0x4d, 0x31, 0x1f, 0x4f, 0xd0,
+	0x71, 0xf2, 0xb2, 0x6d, 0x46, 0x97, 0x2d, 0x7e, 0xc9, 0xd0, 0x19, 0x6c, 0x78, 0x6e, 0xdf, 0x0d,
+	0x0c, 0x61, 0x9f, 0x4d, 0xb5, 0x2f, 0x53, 0x23, 0x7e, 0x1d, 0xd5, 0x4b, 0xd8, 0xd0, 0x3f, 0xb4,
+	0x40, 0x39, 0x86, 0xec, 0x83, 0x31, 0xa8, 0x15, 0xd8, 0xd4, 0x63, 0xf1, 0xab, 0x5f, 0xc3, 0x0e,
+	0x59, 0x51, 0x18, 0xb0, 0xca, 0x25, 0xa6, 0x59, 0x5e, 0xfa, 0x79, 0x01, 0xca, 0xa6, 0x14, 0xa0,
+	0x2f, 0x61, 0x37, 0x8e, 0xc5, 0x73, 0xb4, 0x0b, 0x6b, 0x72, 0x9d, 0x64, 0x83, 0x07, 0x3a, 0xf8,
+	0x0e, 0x54, 0x5b, 0xef, 0x03, 0x3c, 0xb6, 0x17, 0xc2, 0x4a, 0x47, 0x7a, 0x20, 0xa4, 0x7d, 0xd8,
+	0x5b, 0x80, 0xe2, 0x2b, 0xaf, 0x43, 0x55, 0xc3, 0x53, 0xf7, 0x1d, 0x7e, 0xdc, 0x2c, 0x04, 0x6a,
+	0xc1, 0x9e, 0x43, 0xb1, 0xf6, 0x48, 0x3f, 0x6b, 0x7a, 0xd8, 0x26, 0x54, 0x61, 0x0e, 0xa3, 0x3e,
+	0x62, 0x4c, 0xdb, 0xa3, 0x84, 0x8a, 0xe7, 0xe5, 0x0c, 0x36, 0x4c, 0xcb, 0xc2, 0xbe, 0x6f, 0xbc,
+	0xc3, 0x77, 0x86, 0x63, 0xcb, 0xec, 0xd5, 0xa0, 0x8a, 0x97, 0xf8, 0xae, 0x73, 0xa1, 0x95, 0xcc,
+	0x68, 0x60, 0x13, 0xe6, 0xf1, 0xb1, 0xe5, 0xe1, 0x80, 0x38, 0xf1, 0xcc, 0x15, 0x99, 0xe4, 0x25,
+	0xbe, 0x53, 0x2f, 0x69, 0xeb, 0xc4, 0xea, 0xd8, 0x97, 0xae, 0x47, 0x4a, 0xe9, 0x63, 0xee, 0x64,
+	0x35, 0xaa, 0x96, 0xbc, 0x31, 0x61, 0x23, 0xde, 0x36, 0x25, 0xe0, 0xf8, 0xaa, 0x5f, 0x8b, 0xa6,
+	0xe5, 0x12, 0x8f, 0xfa, 0xa4, 0x03, 0x9f, 0xa7, 0x8f, 0x7a, 0x8b, 0xf4, 0xd1, 0x81, 0x68, 0x86,
+	0xb2, 0x69, 0xcd, 0x50, 0x2e, 0xd6, 0x0c, 0xed, 0xc1, 0x47, 0x09, 0xdc, 0x68, 0xc7, 0x48, 0x81,
+	0x62, 0xc1, 0x3c, 0x62, 0x51, 0xbc, 0x87, 0x13, 0xf6, 0xf3, 0x1e, 0x4e, 0xe2, 0x85, 0xf9, 0x4a,
+	0x3f, 0xa5, 0x25, 0x94, 0xb2, 0xd3, 0x83, 0x0b, 0x51, 0x9f, 0xd1, 0x28, 0xb8, 0x21, 0x07, 0xfd,
+	0x24, 0x49, 0x77, 0x45, 0x89, 0xd2, 0xd4, 0xe7, 0xb0, 0xdf, 0xc6, 0xc1, 0x75, 0xbc, 0xb5, 0xf8,
+	0xe0, 0x4d, 0x53, 0x9f, 0x81, 0x92, 0xe6, 0xc6, 0xa7, 0x44, 0xb0, 0x6a, 0xb9, 0x76, 0xf4, 0xf2,
+	0x24, 0xdf, 0x9f, 0xfd, 0x10, 0xd6, 0x68, 0xb9, 0x42, 0x05, 0x58, 0xbd, 0xba, 0xbe, 0x6a, 0x55,
+	0x56, 0x10, 0x40, 0x5e, 0x6b, 0x35, 0x2e, 0x5a, 0x5a, 0x25, 0x43, 0xbe, 0xdf, 0x68, 0x9d, 0x5e,
+	0x4b, 0xab, 0x64, 0x51, 0x11, 0xd6, 0xae, 0xdf, 0x5c, 0xb5, 0xb4, 0x4a, 0xee, 0xf4, 0x2f, 0x65,
+	0xc8, 0x35, 0x6e, 0x3a, 0xe8, 0x05, 0x14, 0xc4, 0xc3, 0x19, 0x7d, 0xc4, 0x2b, 0x48, 0xfc, 0x4d,
+	0xac, 0x54, 0x93, 0x62, 0xbe, 0x33, 0x2b, 0xa8, 0x01, 0x30, 0x7f, 0x2d, 0xa3, 0x3d, 0x66, 0xb7,
+	0xf0, 0xa8, 0x56, 0x6a, 0x8b, 0x8a, 0x08, 0x42, 0xa7, 0x89, 0x8d, 0xb5, 0xe8, 0xe8, 0x09, 0x67,
+	0xed, 0xf4, 0xd7, 0x80, 0x72, 0xb0, 0x4c, 0x2d, 0x83, 0xea, 0x4b, 0x40, 0xf5, 0x87, 0x41, 0xf5,
+	0xe5, 0xa0, 0x3f, 0x83, 0x62, 0xf4, 0x38, 0x40, 0xd5, 0x28, 0x86, 0x58, 0xf7, 0xaf, 0xec, 0x2d,
+	0xc8, 0x23, 0xff, 0x36, 0x94, 0xe5, 0x76, 0x1f, 0xed, 0x33, 0xd3, 0x94, 0x37, 0x84, 0xa2, 0xa4,
+	0xa9, 0x64, 0x20, 0xb9, 0x3d, 0x15, 0x40, 0x29, 0x9d, 0xb2, 0x00, 0x4a, 0xeb, 0x66, 0xd9, 0x8a,
+	0xa2, 0xae, 0x43, 0xac, 0x28, 0xd9, 0xd1, 0x88, 0x15, 0x2d, 0xb4, 0x27, 0xea, 0x0a, 0x7a, 0x0e,
+	0x79, 0xd6, 0xdf, 0xa2, 0x1d, 0x66, 0x14, 0x6b, 0x7f, 0x95, 0xdd, 0xb8, 0x30, 0x72, 0x7b, 0x01,
+	0x05, 0xd1, 0x72, 0x88, 0x23, 0x97, 0xe8, 0x63, 0x94, 0x6a, 0x52, 0x2c, 0x3b, 0xeb, 0x09, 0x67,
+	0x3d, 0xdd, 0x59, 0x5f, 0x74, 0x7e, 0x0e, 0x79, 0xc6, 0xe4, 0x22, 0xe0, 0x58, 0x1f, 0x21, 0x02,
+	0x8e, 0x93, 0x3d, 0x73, 0xd3, 0x63, 0x6e, 0x7a, 0x9a, 0x9b, 0x9e, 0x74, 0x6b, 0x43, 0x59, 0x66,
+	0x46, 0xb1, 0x4f, 0x29, 0xcc, 0x2b, 0xf6, 0x29, 0x8d, 0x48, 0xd5, 0x15, 0x74, 0x03, 0x5b, 0x09,
+	0x3e, 0x43, 0xfc, 0xe7, 0xa3, 0x74, 0xc6, 0x54, 0x9e, 0x2c, 0xd1, 0xca, 0x88, 0x09, 0x5a, 0x13,
+	0x88, 0xe9, 0xec, 0x28, 0x10, 0x97, 0x71, 0xa1, 0xb8, 0xc7, 0x31, 0xca, 0x93, 0xee, 0x71, 0x1a,
+	0x4b, 0x4a, 0xf7, 0x38, 0x95, 0x29, 0xa3, 0x7b, 0x1c, 0x23, 0x22, 0xe9, 0x1e, 0xa7, 0xf1, 0x9d,
+	0x74, 0x8f, 0xd3, 0xf9, 0x6b, 0x05, 0x7d, 0x0d, 0x1b, 0x31, 0xa6, 0x41, 0xb1, 0xdb, 0x16, 0xa7,
+	0x35, 0xe5, 0xe3, 0x54, 0x5d, 0xa2, 0x26, 0xf0, 0xc7, 0xc7, 0xfc, 0xd0, 0xc6, 0xd8, 0x4a, 0xaa,
+	0x09, 0x71, 0x56, 0x8a, 0xae, 0x02, 0x7b, 0x3d, 0xcd, 0xaf, 0x82, 0xcc, 0x47, 0xd2, 0x55, 0x88,
+	0xb1, 0x8f, 0xba, 0x82, 0x7e, 0x05, 0x68, 0x91, 0x2a, 0xd0, 0x61, 0x64, 0x9f, 0xce, 0x3d, 0xca,
+	0xd1, 0x72, 0x03, 0x01, 0x7d, 0xfe, 0x8b, 0xbf, 0xcf, 0x0e, 0x32, 0xff, 0x98, 0x1d, 0x64, 0xfe,
+	0x35, 0x3b, 0xc8, 0xfc, 0xf9, 0xdf, 0x07, 0x2b, 0xbf, 0xae, 0xb3, 0xc7, 0x74, 0xdd, 0x72, 0x47,
+	0x27, 0xe4, 0xc9, 0x7b, 0x67, 0x63, 0x4f, 0xfe, 0xf2, 0x3d, 0xeb, 0x44, 0xfa, 0xe5, 0xb8, 0x9f,
+	0xa7, 0xbf, 0x3d, 0x9c, 0xfd, 0x37, 0x00, 0x00, 0xff, 0xff, 0xb5, 0xcf, 0xba, 0x0b, 0x4f, 0x16,
+	0x00, 0x00,
 }
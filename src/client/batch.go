@@ -0,0 +1,200 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultBatchMemoryBudget is the number of bytes a BatchWriter buffers
+// before flushing, unless overridden
+const DefaultBatchMemoryBudget = 64 * 1024 * 1024
+
+// BatchWriter coalesces many small PutFile and PutObject calls, buffering
+// their data in memory and sending it to pachd in bulk. This greatly reduces
+// the number of round trips made by workloads that write thousands of tiny
+// files. Buffered data is sent when the writer's memory budget would be
+// exceeded, when Flush() is called, and when the writer is closed, so errors
+// writing a file or object may be returned by a later call rather than by
+// the PutFile or PutObject call itself.
+//
+// All files buffered by a BatchWriter are sent on a single PutFile stream.
+// Each object still needs its own PutObject stream, but these are opened
+// concurrently when the writer flushes, so their round trips overlap.
+//
+// A BatchWriter is safe for concurrent use. Writes to the same file are
+// applied in the order in which they're made.
+type BatchWriter struct {
+	c      APIClient
+	budget int
+
+	mu       sync.Mutex
+	files    []*batchedFile
+	objects  []*batchedObject
+	buffered int
+	closed   bool
+}
+
+type batchedFile struct {
+	file      *pfs.File
+	overwrite bool
+	data      []byte
+}
+
+type batchedObject struct {
+	data []byte
+	tags []string
+}
+
+// NewBatchWriter returns a BatchWriter that buffers up to 'memoryBudget' bytes
+// of file and object data before flushing (DefaultBatchMemoryBudget if
+// memoryBudget is 0). Close must be called once the writer is no longer
+// needed, to send any data that is still buffered.
+func (c APIClient) NewBatchWriter(memoryBudget int) *BatchWriter {
+	if memoryBudget <= 0 {
+		memoryBudget = DefaultBatchMemoryBudget
+	}
+	return &BatchWriter{c: c, budget: memoryBudget}
+}
+
+// PutFile appends the contents of 'reader' to the file at 'path'. Data larger
+// than the writer's memory budget is written immediately rather than
+// buffered.
+func (b *BatchWriter) PutFile(repoName string, commitID string, path string, reader io.Reader) error {
+	return b.putFile(NewFile(repoName, commitID, path), false, reader)
+}
+
+// PutFileOverwrite is like PutFile, but replaces the file's contents rather
+// than appending to them
+func (b *BatchWriter) PutFileOverwrite(repoName string, commitID string, path string, reader io.Reader) error {
+	return b.putFile(NewFile(repoName, commitID, path), true, reader)
+}
+
+func (b *BatchWriter) putFile(file *pfs.File, overwrite bool, reader io.Reader) error {
+	data, err := ioutil.ReadAll(io.LimitReader(reader, int64(b.budget)+1))
+	if err != nil {
+		return err
+	}
+	if len(data) <= b.budget {
+		return b.add(len(data), func() {
+			b.files = append(b.files, &batchedFile{file: file, overwrite: overwrite, data: data})
+		})
+	}
+	// the file is too big to buffer, so flush any earlier writes (which may
+	// be to the same file) and then write it directly
+	if err := b.Flush(); err != nil {
+		return err
+	}
+	r := io.MultiReader(bytes.NewReader(data), reader)
+	if overwrite {
+		_, err = b.c.PutFileOverwrite(file.Commit.Repo.Name, file.Commit.ID, file.Path, r, 0)
+	} else {
+		_, err = b.c.PutFile(file.Commit.Repo.Name, file.Commit.ID, file.Path, r)
+	}
+	return err
+}
+
+// PutObject puts 'data' into the object store and tags it with 'tags'. The
+// returned object is computed locally (an object's hash only depends on its
+// content), so it can be used before the object has been flushed.
+func (b *BatchWriter) PutObject(data []byte, tags ...string) (*pfs.Object, error) {
+	hash := pfs.NewHash()
+	hash.Write(data)
+	object := &pfs.Object{Hash: pfs.EncodeHash(hash.Sum(nil))}
+	if len(data) > b.budget {
+		if _, _, err := b.c.PutObject(bytes.NewReader(data), tags...); err != nil {
+			return nil, err
+		}
+		return object, nil
+	}
+	if err := b.add(len(data), func() {
+		b.objects = append(b.objects, &batchedObject{data: data, tags: tags})
+	}); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// add buffers 'size' more bytes, calling 'appendFn' with b.mu held to record
+// the write. If the write would exceed the memory budget, the writer is
+// flushed first.
+func (b *BatchWriter) add(size int, appendFn func()) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return fmt.Errorf("batch writer is closed")
+	}
+	if b.buffered+size > b.budget {
+		if err := b.flushLocked(); err != nil {
+			return err
+		}
+	}
+	appendFn()
+	b.buffered += size
+	return nil
+}
+
+// Flush sends all buffered data to pachd
+func (b *BatchWriter) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.flushLocked()
+}
+
+func (b *BatchWriter) flushLocked() error {
+	files, objects := b.files, b.objects
+	b.files, b.objects, b.buffered = nil, nil, 0
+
+	var eg errgroup.Group
+	if len(files) > 0 {
+		eg.Go(func() (retErr error) {
+			pfc, err := b.c.NewPutFileClient()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err := pfc.Close(); err != nil && retErr == nil {
+					retErr = err
+				}
+			}()
+			for _, f := range files {
+				r := bytes.NewReader(f.data)
+				if f.overwrite {
+					_, err = pfc.PutFileOverwrite(f.file.Commit.Repo.Name, f.file.Commit.ID, f.file.Path, r, 0)
+				} else {
+					_, err = pfc.PutFile(f.file.Commit.Repo.Name, f.file.Commit.ID, f.file.Path, r)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	for _, o := range objects {
+		o := o
+		b.c.limiter.Acquire()
+		eg.Go(func() error {
+			defer b.c.limiter.Release()
+			_, _, err := b.c.PutObject(bytes.NewReader(o.data), o.tags...)
+			return err
+		})
+	}
+	return eg.Wait()
+}
+
+// Close flushes any buffered data. Further writes return errors.
+func (b *BatchWriter) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	return b.flushLocked()
+}
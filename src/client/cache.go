@@ -0,0 +1,207 @@
+package client
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// DefaultCacheSize is the number of entries a client's metadata cache holds
+// if WithCache() is passed a size of 0
+const DefaultCacheSize = 10000
+
+// purgingMethods are the RPCs whose effects can't be attributed to a single
+// repo (e.g. deleting a commit also deletes its subvenance, and changing an
+// ACL changes what the caller may see), so the whole cache is purged when
+// they're called
+var purgingMethods = map[string]bool{
+	"/pfs.API/DeleteCommit":      true,
+	"/pfs.API/DeleteAll":         true,
+	"/pps.API/CreatePipeline":    true,
+	"/pps.API/DeletePipeline":    true,
+	"/pps.API/DeleteAll":         true,
+	"/admin.API/Restore":         true,
+	"/auth.API/Activate":         true,
+	"/auth.API/Deactivate":       true,
+	"/auth.API/ModifyAdmins":     true,
+	"/auth.API/SetScope":         true,
+	"/auth.API/SetACL":           true,
+	"/auth.API/ModifyMembers":    true,
+	"/auth.API/SetGroupsForUser": true,
+}
+
+// metadataCache caches the results of InspectRepo and InspectCommit (see
+// WithCache()). It's shared by all copies of a client, so entries are keyed
+// by the token of the RPC that fetched them, as what pachd returns depends on
+// the caller's access.
+type metadataCache struct {
+	ttl   time.Duration
+	cache *lru.Cache
+}
+
+// cacheKey identifies a cached RepoInfo (if 'commit' is "") or CommitInfo
+type cacheKey struct {
+	token, repo, commit string
+}
+
+type cacheEntry struct {
+	info  proto.Message
+	added time.Time
+}
+
+func newMetadataCache(size int, ttl time.Duration) (*metadataCache, error) {
+	if size == 0 {
+		size = DefaultCacheSize
+	}
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &metadataCache{ttl: ttl, cache: cache}, nil
+}
+
+// get copies the cached value for 'key' into 'reply', returning false if
+// there's no such value or it has expired
+func (m *metadataCache) get(key cacheKey, reply interface{}) bool {
+	value, ok := m.cache.Get(key)
+	if !ok {
+		return false
+	}
+	entry := value.(*cacheEntry)
+	if m.ttl > 0 && time.Since(entry.added) > m.ttl {
+		m.cache.Remove(key)
+		return false
+	}
+	msg := reply.(proto.Message)
+	msg.Reset()
+	proto.Merge(msg, entry.info)
+	return true
+}
+
+func (m *metadataCache) add(key cacheKey, info proto.Message) {
+	m.cache.Add(key, &cacheEntry{info: proto.Clone(info), added: time.Now()})
+}
+
+// invalidate removes the cached RepoInfos for 'repo' (for all repos if 'repo'
+// is ""), and its cached CommitInfos if 'commits' is true
+func (m *metadataCache) invalidate(repo string, commits bool) {
+	for _, k := range m.cache.Keys() {
+		key := k.(cacheKey)
+		if (repo == "" || key.repo == repo) && (commits || key.commit == "") {
+			m.cache.Remove(key)
+		}
+	}
+}
+
+// invalidateFor removes the entries that the RPC 'method' (with request 'req')
+// may have made stale
+func (m *metadataCache) invalidateFor(method string, req interface{}) {
+	if purgingMethods[method] {
+		m.cache.Purge()
+		return
+	}
+	switch r := req.(type) {
+	case *pfs.DeleteRepoRequest:
+		if r.All {
+			m.cache.Purge()
+		} else {
+			m.invalidate(r.GetRepo().GetName(), true)
+		}
+	case *pfs.CreateRepoRequest:
+		m.invalidate(r.GetRepo().GetName(), false)
+	case *pfs.StartCommitRequest:
+		m.invalidate(r.GetParent().GetRepo().GetName(), false)
+	case *pfs.BuildCommitRequest:
+		m.invalidate(r.GetParent().GetRepo().GetName(), false)
+	case *pfs.FinishCommitRequest:
+		m.invalidate(r.GetCommit().GetRepo().GetName(), false)
+	case *pfs.CreateBranchRequest:
+		m.invalidate(r.GetHead().GetRepo().GetName(), false)
+		m.invalidate(r.GetBranch().GetRepo().GetName(), false)
+	case *pfs.DeleteBranchRequest:
+		m.invalidate(r.GetBranch().GetRepo().GetName(), false)
+	case *pfs.CopyFileRequest:
+		m.invalidate(r.GetDst().GetCommit().GetRepo().GetName(), false)
+	case *pfs.DeleteFileRequest:
+		m.invalidate(r.GetFile().GetCommit().GetRepo().GetName(), false)
+	}
+}
+
+// tokenFromContext returns the auth token in 'ctx's outgoing metadata, if any
+func tokenFromContext(ctx context.Context) string {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok || len(md.Get(auth.ContextTokenKey)) == 0 {
+		return ""
+	}
+	return md.Get(auth.ContextTokenKey)[0]
+}
+
+// unaryInterceptor returns an interceptor that serves InspectRepo and
+// InspectCommit from the cache where possible, and that invalidates the cache
+// when other RPCs modify the repos in it.
+//
+// Only finished commits are cached, as their contents can't change, and they
+// are only cached by ID: a commit inspected through a branch (e.g.
+// "master") is cached under its ID, as the branch may move. Repos, whose size
+// and branches change as commits are made, are only cached if the cache has a
+// TTL.
+func (m *metadataCache) unaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		token := tokenFromContext(ctx)
+		switch method {
+		case "/pfs.API/InspectRepo":
+			repo := req.(*pfs.InspectRepoRequest).GetRepo().GetName()
+			if m.ttl <= 0 {
+				break
+			}
+			key := cacheKey{token: token, repo: repo}
+			if m.get(key, reply) {
+				return nil
+			}
+			if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+				return err
+			}
+			m.add(key, reply.(*pfs.RepoInfo))
+			return nil
+		case "/pfs.API/InspectCommit":
+			commit := req.(*pfs.InspectCommitRequest).GetCommit()
+			key := cacheKey{token: token, repo: commit.GetRepo().GetName(), commit: commit.GetID()}
+			if m.get(key, reply) {
+				return nil
+			}
+			if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+				return err
+			}
+			info := reply.(*pfs.CommitInfo)
+			if info.Finished != nil && info.Commit != nil {
+				key.commit = info.Commit.ID
+				m.add(key, info)
+			}
+			return nil
+		}
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		// the RPC may have taken effect even if it failed
+		m.invalidateFor(method, req)
+		return err
+	}
+}
+
+// streamInterceptor returns an interceptor that invalidates the cache when
+// streaming RPCs modify the repos in it. The repos modified by PutFile are
+// only known once its requests are sent, so all cached repos are invalidated
+// when a PutFile stream is opened.
+func (m *metadataCache) streamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if method == "/pfs.API/PutFile" {
+			m.invalidate("", false)
+		}
+		m.invalidateFor(method, nil)
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
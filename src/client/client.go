@@ -1,19 +1,24 @@
 package client
 
 import (
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/context"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer/roundrobin"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
@@ -31,6 +36,7 @@ import (
 	"github.com/pachyderm/pachyderm/src/client/limit"
 	"github.com/pachyderm/pachyderm/src/client/pfs"
 	"github.com/pachyderm/pachyderm/src/client/pkg/config"
+	"github.com/pachyderm/pachyderm/src/client/pkg/dryrun"
 	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
 	"github.com/pachyderm/pachyderm/src/client/pps"
 	"github.com/pachyderm/pachyderm/src/client/version/versionpb"
@@ -94,6 +100,14 @@ type APIClient struct {
 	// The trusted CAs, for authenticating a pachd server over TLS
 	caCerts *x509.CertPool
 
+	// serverName, if set, is the name that pachd's TLS certificate is
+	// verified against, instead of the host in 'addr'
+	serverName string
+
+	// poolSize is the number of connections opened to pachd (see
+	// WithConnectionPool)
+	poolSize int
+
 	// clientConn is a cached grpc connection to 'addr'
 	clientConn *grpc.ClientConn
 
@@ -120,6 +134,14 @@ type APIClient struct {
 	// The context used in requests, can be set with WithCtx
 	ctx context.Context
 
+	// dryRun, if set, collects the changes reported by dry runs of this
+	// client's calls (see WithDryRun)
+	dryRun *DryRun
+
+	// progress, if set, reports the progress of this client's file transfers
+	// (see WithProgress)
+	progress *progressTracker
+
 	portForwarder *PortForwarder
 }
 
@@ -137,10 +159,37 @@ const DefaultMaxConcurrentStreams = 100
 // for a connection to be established unless overridden by WithDialTimeout()
 const DefaultDialTimeout = 30 * time.Second
 
+// DefaultKeepalive is the keepalive configuration that APIClients use unless
+// overridden by WithKeepalive()
+var DefaultKeepalive = keepalive.ClientParameters{
+	Time:                20 * time.Second, // if 20s since last msg (any kind), ping
+	Timeout:             20 * time.Second, // if no response to ping for 20s, reset
+	PermitWithoutStream: true,             // send ping even if no active RPCs
+}
+
 type clientSettings struct {
 	maxConcurrentStreams int
 	dialTimeout          time.Duration
 	caCerts              *x509.CertPool
+	clientCert           *tls.Certificate
+	serverName           string
+	poolSize             int
+	portForwardFallback  bool
+	keepalive            keepalive.ClientParameters
+	maxMsgSize           int
+	gzip                 bool
+	userAgent            string
+	unaryInterceptors    []grpc.UnaryClientInterceptor
+	streamInterceptors   []grpc.StreamClientInterceptor
+	retryPolicy          *RetryPolicy
+	metrics              *Metrics
+	tracer               Tracer
+	dialer               Dialer
+	tokenRefresher       TokenRefresher
+	cache                bool
+	cacheSize            int
+	cacheTTL             time.Duration
+	rateLimit            *rateLimiter
 }
 
 // NewFromAddress constructs a new APIClient for the server at addr.
@@ -149,6 +198,8 @@ func NewFromAddress(addr string, options ...Option) (*APIClient, error) {
 	settings := clientSettings{
 		maxConcurrentStreams: DefaultMaxConcurrentStreams,
 		dialTimeout:          DefaultDialTimeout,
+		keepalive:            DefaultKeepalive,
+		maxMsgSize:           grpcutil.MaxMsgSize,
 	}
 	for _, option := range options {
 		if err := option(&settings); err != nil {
@@ -156,11 +207,13 @@ func NewFromAddress(addr string, options ...Option) (*APIClient, error) {
 		}
 	}
 	c := &APIClient{
-		addr:    addr,
-		caCerts: settings.caCerts,
-		limiter: limit.New(settings.maxConcurrentStreams),
+		addr:       addr,
+		caCerts:    settings.caCerts,
+		serverName: settings.serverName,
+		poolSize:   settings.poolSize,
+		limiter:    limit.New(settings.maxConcurrentStreams),
 	}
-	if err := c.connect(settings.dialTimeout); err != nil {
+	if err := c.connect(&settings); err != nil {
 		return nil, err
 	}
 	return c, nil
@@ -215,6 +268,37 @@ func WithAdditionalRootCAs(pemBytes []byte) Option {
 	}
 }
 
+// WithClientCert instructs the New* functions to create a client that
+// presents the x509 certificate at 'certPath' (signed with the private key at
+// 'keyPath') when establishing a TLS connection to pachd. This is needed when
+// pachd, or a load balancer in front of it, requires mutual TLS. Connections
+// use TLS when a client certificate is set, even if no root CAs are given (in
+// which case the system certs are trusted).
+func WithClientCert(certPath, keyPath string) Option {
+	return func(settings *clientSettings) error {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return fmt.Errorf("could not load client cert from \"%s\" and \"%s\": %v", certPath, keyPath, err)
+		}
+		settings.clientCert = &cert
+		return nil
+	}
+}
+
+// WithClientCertPEM is like WithClientCert, but takes the PEM-encoded
+// certificate and private key directly. Introduced to pass the client cert in
+// the Pachyderm config
+func WithClientCertPEM(certPEMBytes, keyPEMBytes []byte) Option {
+	return func(settings *clientSettings) error {
+		cert, err := tls.X509KeyPair(certPEMBytes, keyPEMBytes)
+		if err != nil {
+			return fmt.Errorf("could not parse client cert: %v", err)
+		}
+		settings.clientCert = &cert
+		return nil
+	}
+}
+
 // WithDialTimeout instructs the New* functions to use 't' as the deadline to
 // connect to pachd
 func WithDialTimeout(t time.Duration) Option {
@@ -224,6 +308,217 @@ func WithDialTimeout(t time.Duration) Option {
 	}
 }
 
+// WithKeepalive instructs the New* functions to create a client that pings
+// pachd according to 'params', instead of DefaultKeepalive
+func WithKeepalive(params keepalive.ClientParameters) Option {
+	return func(settings *clientSettings) error {
+		settings.keepalive = params
+		return nil
+	}
+}
+
+// WithMaxMsgSize instructs the New* functions to create a client that sends
+// and receives messages of up to 'size' bytes, instead of
+// grpcutil.MaxMsgSize
+func WithMaxMsgSize(size int) Option {
+	return func(settings *clientSettings) error {
+		if size <= 0 {
+			return fmt.Errorf("max message size must be positive, but was %d", size)
+		}
+		settings.maxMsgSize = size
+		return nil
+	}
+}
+
+// WithGZIPCompression instructs the New* functions to create a client that
+// compresses the messages it sends to pachd with gzip. This trades CPU for
+// bandwidth, which may help over slow links.
+func WithGZIPCompression() Option {
+	return func(settings *clientSettings) error {
+		settings.gzip = true
+		return nil
+	}
+}
+
+// WithUserAgent instructs the New* functions to create a client that
+// identifies itself to pachd with the user agent 'userAgent' (which gRPC
+// prefixes to its own)
+func WithUserAgent(userAgent string) Option {
+	return func(settings *clientSettings) error {
+		settings.userAgent = userAgent
+		return nil
+	}
+}
+
+// WithUnaryInterceptor instructs the New* functions to create a client that
+// passes every unary RPC it makes through 'interceptor' (e.g. to add logging,
+// metrics or rate limiting). If several interceptors are added, the first one
+// added is the outermost.
+func WithUnaryInterceptor(interceptor grpc.UnaryClientInterceptor) Option {
+	return func(settings *clientSettings) error {
+		settings.unaryInterceptors = append(settings.unaryInterceptors, interceptor)
+		return nil
+	}
+}
+
+// WithStreamInterceptor instructs the New* functions to create a client that
+// passes every streaming RPC it makes (e.g. PutFile and GetFile) through
+// 'interceptor'. If several interceptors are added, the first one added is
+// the outermost.
+func WithStreamInterceptor(interceptor grpc.StreamClientInterceptor) Option {
+	return func(settings *clientSettings) error {
+		settings.streamInterceptors = append(settings.streamInterceptors, interceptor)
+		return nil
+	}
+}
+
+// WithRetryPolicy instructs the New* functions to create a client that
+// retries RPCs failing with one of policy.RetryableCodes (e.g. while pachd is
+// restarting), backing off between attempts as described by 'policy'. Streaming
+// RPCs are only retried if the stream can't be opened. Note that a retried RPC
+// may have taken effect on the first attempt, so retryable codes should be
+// limited to errors (like codes.Unavailable) where that's safe for the caller.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(settings *clientSettings) error {
+		if err := policy.validate(); err != nil {
+			return err
+		}
+		settings.retryPolicy = &policy
+		return nil
+	}
+}
+
+// WithTokenRefresher instructs the New* functions to create a client that,
+// when an RPC fails because the client's auth token has expired, obtains a new
+// token with 'refresher' and retries the RPC once with it (see
+// RefreshByAuthenticating()). The new token is then used by all subsequent
+// RPCs, including those sent by copies of the client made with WithCtx().
+// Streaming RPCs are only retried if their token expired before the stream
+// was opened.
+func WithTokenRefresher(refresher TokenRefresher) Option {
+	return func(settings *clientSettings) error {
+		settings.tokenRefresher = refresher
+		return nil
+	}
+}
+
+// WithCache instructs the New* functions to create a client that caches the
+// results of InspectRepo and InspectCommit, which tools that walk the commit
+// graph otherwise fetch repeatedly. The cache holds up to 'size' entries
+// (DefaultCacheSize if 'size' is 0), evicting the least recently used, and
+// entries expire after 'ttl' (never, if 'ttl' is 0).
+//
+// Only finished commits are cached, and a commit inspected through a branch
+// name is always fetched from pachd (as the branch may have moved). Repos are
+// only cached if 'ttl' is set, as their size and branches change. Writes made
+// through the client invalidate the entries they affect, but changes made by
+// other clients are only seen once entries expire. Note that the child
+// commits and subvenance of a finished commit may still grow, so callers that
+// depend on those should set a short 'ttl'.
+func WithCache(size int, ttl time.Duration) Option {
+	return func(settings *clientSettings) error {
+		if size < 0 {
+			return fmt.Errorf("cache size must be non-negative, but was %d", size)
+		}
+		if ttl < 0 {
+			return fmt.Errorf("cache TTL must be non-negative, but was %v", ttl)
+		}
+		settings.cache = true
+		settings.cacheSize = size
+		settings.cacheTTL = ttl
+		return nil
+	}
+}
+
+// WithRateLimit instructs the New* functions to create a client that sends at
+// most 'qps' RPCs per second on average, and at most 'burst' RPCs at once,
+// so that e.g. a sync service that writes many files can't starve other
+// users of the same pachd. Calls wait until they're allowed to proceed (or
+// until their context is done). Each RPC counts once however much data it
+// carries, so a PutFile stream writing many files counts as one RPC, and
+// retried RPCs count once per attempt.
+func WithRateLimit(qps float64, burst int) Option {
+	return func(settings *clientSettings) error {
+		if qps <= 0 {
+			return fmt.Errorf("rate limit must be positive, but was %v", qps)
+		}
+		if burst < 1 {
+			return fmt.Errorf("rate limit burst must be at least 1, but was %d", burst)
+		}
+		settings.rateLimit = newRateLimiter(qps, burst)
+		return nil
+	}
+}
+
+// WithMetrics instructs the New* functions to create a client that records
+// metrics about the RPCs it makes in 'metrics' (see NewMetrics())
+func WithMetrics(metrics *Metrics) Option {
+	return func(settings *clientSettings) error {
+		settings.metrics = metrics
+		return nil
+	}
+}
+
+// WithTracer instructs the New* functions to create a client that starts a
+// span with 'tracer' for each RPC it makes, and that propagates the span's
+// trace context to pachd in the RPC's metadata (see Tracer)
+func WithTracer(tracer Tracer) Option {
+	return func(settings *clientSettings) error {
+		settings.tracer = tracer
+		return nil
+	}
+}
+
+// WithDialer instructs the New* functions to create a client that opens its
+// connections to pachd with 'dialer' (e.g. to traverse a proxy or an overlay
+// network). By default, clients connect through the proxy given by the
+// HTTPS_PROXY or HTTP_PROXY environment variables (which may be an HTTP or a
+// SOCKS5 proxy), unless pachd's address is excluded by NO_PROXY. 'dialer'
+// replaces this, so it must handle any proxying itself.
+func WithDialer(dialer Dialer) Option {
+	return func(settings *clientSettings) error {
+		settings.dialer = dialer
+		return nil
+	}
+}
+
+// WithServerName instructs the New* functions to verify pachd's TLS
+// certificate against 'name' (and to send 'name' via SNI) instead of the host
+// in pachd's address. This is needed when connecting to a TLS-enabled pachd
+// through a port forwarder, as the address is then localhost, which pachd's
+// certificate is not issued for.
+func WithServerName(name string) Option {
+	return func(settings *clientSettings) error {
+		settings.serverName = name
+		return nil
+	}
+}
+
+// WithConnectionPool instructs the New* functions to open 'size' connections
+// to pachd rather than one, and to send each RPC over the next connection in
+// turn. A single connection's HTTP/2 flow control can limit the throughput of
+// many concurrent streams (e.g. parallel PutFile calls), which a pool avoids.
+func WithConnectionPool(size int) Option {
+	return func(settings *clientSettings) error {
+		if size < 1 {
+			return fmt.Errorf("connection pool size must be at least 1, but was %d", size)
+		}
+		settings.poolSize = size
+		return nil
+	}
+}
+
+// WithPortForwardFallback instructs NewOnUserMachine to implicitly port
+// forward to pachd if pachd can't be reached at the configured address. The
+// port forwarder is closed when the client is closed. Other New* functions
+// ignore this option.
+func WithPortForwardFallback() Option {
+	return func(settings *clientSettings) error {
+		settings.portForwardFallback = true
+		return nil
+	}
+}
+
 // WithAdditionalPachdCert instructs the New* functions to additionally trust
 // the signed cert mounted in Pachd's cert volume. This is used by Pachd
 // when connecting to itself (if no cert is present, the clients cert pool
@@ -243,6 +538,17 @@ func WithAdditionalPachdCert() Option {
 
 func getCertOptionsFromEnv() ([]Option, error) {
 	var options []Option
+	if serverName, ok := os.LookupEnv("PACH_TLS_SERVER_NAME"); ok {
+		options = append(options, WithServerName(serverName))
+	}
+	certPath, certOK := os.LookupEnv("PACH_CLIENT_CERT")
+	keyPath, keyOK := os.LookupEnv("PACH_CLIENT_KEY")
+	if certOK != keyOK {
+		return nil, fmt.Errorf("PACH_CLIENT_CERT and PACH_CLIENT_KEY must be set together")
+	}
+	if certOK {
+		options = append(options, WithClientCert(certPath, keyPath))
+	}
 	if certPaths, ok := os.LookupEnv("PACH_CA_CERTS"); ok {
 		paths := strings.Split(certPaths, ",")
 		for _, p := range paths {
@@ -270,6 +576,37 @@ func getCertOptionsFromEnv() ([]Option, error) {
 	return options, nil
 }
 
+// getCertOptionsFromConfig returns the options for connecting to pachd with
+// the base64-encoded PEM certs in a Pachyderm config (any of which may be
+// empty)
+func getCertOptionsFromConfig(serverCAs, clientCert, clientKey string) ([]Option, error) {
+	var options []Option
+	if serverCAs != "" {
+		pemBytes, err := base64.StdEncoding.DecodeString(serverCAs)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode server CA certs in config: %v", err)
+		}
+		options = append(options, WithAdditionalRootCAs(pemBytes))
+	}
+	if clientCert != "" || clientKey != "" {
+		certPEMBytes, err := base64.StdEncoding.DecodeString(clientCert)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode client cert in config: %v", err)
+		}
+		keyPEMBytes, err := base64.StdEncoding.DecodeString(clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode client key in config: %v", err)
+		}
+		options = append(options, WithClientCertPEM(certPEMBytes, keyPEMBytes))
+	}
+	if len(options) > 0 {
+		if serverName, ok := os.LookupEnv("PACH_TLS_SERVER_NAME"); ok {
+			options = append(options, WithServerName(serverName))
+		}
+	}
+	return options, nil
+}
+
 // getUserMachineAddrAndOpts is a helper for NewOnUserMachine that uses
 // environment variables, config files, etc to figure out which address a user
 // running a command should connect to.
@@ -286,14 +623,11 @@ func getUserMachineAddrAndOpts(cfg *config.Config) (string, []Option, error) {
 	// 2) Get target address from global config if possible
 	if cfg != nil && cfg.V1 != nil && cfg.V1.PachdAddress != "" {
 		// Also get cert info from config (if set)
-		if cfg.V1.ServerCAs != "" {
-			pemBytes, err := base64.StdEncoding.DecodeString(cfg.V1.ServerCAs)
-			if err != nil {
-				return "", nil, fmt.Errorf("could not decode server CA certs in config: %v", err)
-			}
-			return cfg.V1.PachdAddress, []Option{WithAdditionalRootCAs(pemBytes)}, nil
+		options, err := getCertOptionsFromConfig(cfg.V1.ServerCAs, cfg.V1.ClientCert, cfg.V1.ClientKey)
+		if err != nil {
+			return "", nil, err
 		}
-		return cfg.V1.PachdAddress, nil, nil
+		return cfg.V1.PachdAddress, options, nil
 	}
 
 	// 3) Use default address (broadcast) if nothing else works
@@ -304,7 +638,10 @@ func getUserMachineAddrAndOpts(cfg *config.Config) (string, []Option, error) {
 	return "", options, nil
 }
 
-func portForwarder() *PortForwarder {
+// portForwarder implicitly forwards pachd's ports, returning the port
+// forwarder and the local port that pachd was bound to. If the default pachd
+// port is already taken, a free port is used instead.
+func portForwarder() (*PortForwarder, int) {
 	log.Debugln("Attempting to implicitly enable port forwarding...")
 
 	// NOTE: this will always use the default namespace; if a custom
@@ -313,30 +650,40 @@ func portForwarder() *PortForwarder {
 	fw, err := NewPortForwarder("", ioutil.Discard, os.Stderr)
 	if err != nil {
 		log.Errorf("Implicit port forwarding was not enabled because the kubernetes config could not be read: %v", err)
-		return nil
+		return nil, 0
 	}
 	if err = fw.Lock(); err != nil {
 		log.Warningf("Implicit port forwarding was not enabled because the pidfile could not be written to. Most likely this means that port forwarding is running in another instance of `pachctl`: %v", err)
-		return nil
+		return nil, 0
 	}
+	fw.FreePortFallback = true
 
 	var eg errgroup.Group
-	
+	var pachdPort int
+
+	eg.Go(func() error {
+		var err error
+		pachdPort, err = fw.RunForDaemon(0)
+		return err
+	})
+
 	eg.Go(func() error {
-		return fw.RunForDaemon(0)
+		_, err := fw.RunForSAMLACS(0)
+		return err
 	})
 
 	eg.Go(func() error {
-		return fw.RunForSAMLACS(0)
+		_, err := fw.RunForOIDC(0)
+		return err
 	})
 
 	if err = eg.Wait(); err != nil {
 		fw.Close()
 		log.Errorf("Implicit port forwarding was not enabled because of an error: %v", err)
-		return nil
+		return nil, 0
 	}
 
-	return fw
+	return fw, pachdPort
 }
 
 // NewOnUserMachine constructs a new APIClient using env vars that may be set
@@ -365,12 +712,35 @@ func NewOnUserMachine(reportMetrics bool, portForward bool, prefix string, optio
 		addr = fmt.Sprintf("0.0.0.0:%s", DefaultPachdNodePort)
 
 		if portForward {
-			fw = portForwarder()	
+			var pachdPort int
+			if fw, pachdPort = portForwarder(); fw != nil {
+				addr = fmt.Sprintf("0.0.0.0:%d", pachdPort)
+			}
 		}
 	}
 
-	client, err := NewFromAddress(addr, append(options, cfgOptions...)...)
+	options = append(options, cfgOptions...)
+	client, err := NewFromAddress(addr, options...)
+	if err != nil && fw == nil && strings.Contains(err.Error(), "context deadline exceeded") {
+		var settings clientSettings
+		for _, option := range options {
+			if err := option(&settings); err != nil {
+				return nil, err
+			}
+		}
+		if settings.portForwardFallback {
+			var pachdPort int
+			if fw, pachdPort = portForwarder(); fw != nil {
+				log.Infof("could not reach pachd at %q, connecting through an implicit port forwarder instead", addr)
+				addr = fmt.Sprintf("0.0.0.0:%d", pachdPort)
+				client, err = NewFromAddress(addr, options...)
+			}
+		}
+	}
 	if err != nil {
+		if fw != nil {
+			fw.Close()
+		}
 		if strings.Contains(err.Error(), "context deadline exceeded") {
 			// port always starts after last colon, but net.SplitHostPort returns an
 			// error on a hostport without a colon, which this might be
@@ -431,13 +801,15 @@ func NewInCluster(options ...Option) (*APIClient, error) {
 	return NewFromAddress(fmt.Sprintf("%s:%s", host, port), options...)
 }
 
-// Close the connection to gRPC
-func (c *APIClient) Close() {
-	c.clientConn.Close()
-
+// Close the connection to gRPC, and stop the client's port forwarder (if any)
+func (c *APIClient) Close() error {
+	retErr := c.clientConn.Close()
 	if c.portForwarder != nil {
-		c.portForwarder.Close()	
+		if err := c.portForwarder.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
 	}
+	return retErr
 }
 
 // DeleteAll deletes everything in the cluster.
@@ -489,22 +861,105 @@ func DefaultDialOptions() []grpc.DialOption {
 	}
 }
 
-func (c *APIClient) connect(timeout time.Duration) error {
-	keepaliveOpt := grpc.WithKeepaliveParams(keepalive.ClientParameters{
-		Time:                20 * time.Second, // if 20s since last msg (any kind), ping
-		Timeout:             20 * time.Second, // if no response to ping for 20s, reset
-		PermitWithoutStream: true,             // send ping even if no active RPCs
-	})
-	dialOptions := append(DefaultDialOptions(), keepaliveOpt)
-	if c.caCerts == nil {
+func (c *APIClient) connect(settings *clientSettings) error {
+	dialOptions := append(DefaultDialOptions(),
+		grpc.WithKeepaliveParams(settings.keepalive),
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(settings.maxMsgSize),
+			grpc.MaxCallSendMsgSize(settings.maxMsgSize),
+		),
+	)
+	if settings.gzip {
+		dialOptions = append(dialOptions,
+			grpc.WithCompressor(grpc.NewGZIPCompressor()),
+			grpc.WithDecompressor(grpc.NewGZIPDecompressor()),
+		)
+	}
+	if settings.userAgent != "" {
+		dialOptions = append(dialOptions, grpc.WithUserAgent(settings.userAgent))
+	}
+	dialer := settings.dialer
+	if dialer == nil {
+		dialer = dialProxied
+	}
+	dialOptions = append(dialOptions, grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return dialer(ctx, addr)
+	}))
+	// Errors are converted to *Errors outermost, so that every error the
+	// caller sees is converted, then calls that can't be dry run are rejected
+	// before anything else sees them. Cache hits return before any RPC is
+	// traced or measured. Spans and metrics are recorded next, so that an
+	// RPC's span and duration include its retries (and time spent waiting for
+	// the rate limit), and retries are outside the rate limit and the
+	// caller's interceptors, so that those see each attempt.
+	unaryInterceptors := []grpc.UnaryClientInterceptor{errorUnaryInterceptor, dryRunUnaryInterceptor}
+	streamInterceptors := []grpc.StreamClientInterceptor{errorStreamInterceptor, dryRunStreamInterceptor}
+	if settings.cache {
+		cache, err := newMetadataCache(settings.cacheSize, settings.cacheTTL)
+		if err != nil {
+			return err
+		}
+		unaryInterceptors = append(unaryInterceptors, cache.unaryInterceptor())
+		streamInterceptors = append(streamInterceptors, cache.streamInterceptor())
+	}
+	if settings.tracer != nil {
+		unaryInterceptors = append(unaryInterceptors, tracingUnaryInterceptor(settings.tracer))
+		streamInterceptors = append(streamInterceptors, tracingStreamInterceptor(settings.tracer))
+	}
+	if settings.metrics != nil {
+		unaryInterceptors = append(unaryInterceptors, settings.metrics.unaryInterceptor())
+		streamInterceptors = append(streamInterceptors, settings.metrics.streamInterceptor())
+	}
+	if settings.retryPolicy != nil {
+		unaryInterceptors = append(unaryInterceptors, settings.retryPolicy.unaryInterceptor(settings.metrics))
+		streamInterceptors = append(streamInterceptors, settings.retryPolicy.streamInterceptor(settings.metrics))
+	}
+	if settings.rateLimit != nil {
+		unaryInterceptors = append(unaryInterceptors, settings.rateLimit.unaryInterceptor())
+		streamInterceptors = append(streamInterceptors, settings.rateLimit.streamInterceptor())
+	}
+	if settings.tokenRefresher != nil {
+		refresh := newTokenRefresh(settings.tokenRefresher, c)
+		unaryInterceptors = append(unaryInterceptors, refresh.unaryInterceptor())
+		streamInterceptors = append(streamInterceptors, refresh.streamInterceptor())
+	}
+	unaryInterceptors = append(unaryInterceptors, settings.unaryInterceptors...)
+	streamInterceptors = append(streamInterceptors, settings.streamInterceptors...)
+	dialOptions = append(dialOptions,
+		grpc.WithUnaryInterceptor(chainUnaryInterceptors(unaryInterceptors)),
+		grpc.WithStreamInterceptor(chainStreamInterceptors(streamInterceptors)),
+	)
+	target, serverName := c.addr, c.serverName
+	useTLS := c.caCerts != nil || settings.clientCert != nil
+	if c.poolSize > 1 {
+		target = poolTarget(c.addr, c.poolSize)
+		dialOptions = append(dialOptions, grpc.WithBalancerName(roundrobin.Name))
+		// gRPC would otherwise derive the server's name from the pool
+		// target, rather than from pachd's address
+		if !useTLS {
+			dialOptions = append(dialOptions, grpc.WithAuthority(c.addr))
+		} else if serverName == "" {
+			if host, _, err := net.SplitHostPort(c.addr); err == nil {
+				serverName = host
+			} else {
+				serverName = c.addr
+			}
+		}
+	}
+	if !useTLS {
 		dialOptions = append(dialOptions, grpc.WithInsecure())
 	} else {
-		tlsCreds := credentials.NewClientTLSFromCert(c.caCerts, "")
-		dialOptions = append(dialOptions, grpc.WithTransportCredentials(tlsCreds))
+		tlsConfig := &tls.Config{RootCAs: c.caCerts, ServerName: serverName}
+		if settings.clientCert != nil {
+			tlsConfig.Certificates = []tls.Certificate{*settings.clientCert}
+		}
+		dialOptions = append(dialOptions, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
 	}
-	dialOptions = append(dialOptions, grpc.WithTimeout(timeout))
+	dialOptions = append(dialOptions, grpc.WithTimeout(settings.dialTimeout))
 	// TODO(msteffen) switch to grpc.DialContext instead
-	clientConn, err := grpc.Dial(c.addr, dialOptions...)
+	clientConn, err := grpc.Dial(target, dialOptions...)
 	if err != nil {
 		return err
 	}
@@ -539,6 +994,9 @@ func (c *APIClient) AddMetadata(ctx context.Context) context.Context {
 		clientData["userid"] = c.metricsUserID
 		clientData["prefix"] = c.metricsPrefix
 	}
+	if c.dryRun != nil {
+		clientData[dryrun.MetadataKey] = "true"
+	}
 
 	// Rescue any metadata pairs already in 'ctx' (otherwise
 	// metadata.NewOutgoingContext() would drop them). Note that this is similar
@@ -553,11 +1011,16 @@ func (c *APIClient) AddMetadata(ctx context.Context) context.Context {
 			finalMD[k] = v
 		}
 	}
-	return metadata.NewOutgoingContext(ctx, finalMD)
+	ctx = metadata.NewOutgoingContext(ctx, finalMD)
+	if c.dryRun != nil {
+		ctx = context.WithValue(ctx, dryRunKey{}, c.dryRun)
+	}
+	return ctx
 }
 
-// Ctx is a convenience function that returns adds Pachyderm authn metadata
-// to context.Background().
+// Ctx is a convenience function that adds Pachyderm authn metadata to the
+// client's context (set by WithCtx), or to context.Background() if the client
+// has no context.
 func (c *APIClient) Ctx() context.Context {
 	if c.ctx == nil {
 		return c.AddMetadata(context.Background())
@@ -565,10 +1028,17 @@ func (c *APIClient) Ctx() context.Context {
 	return c.AddMetadata(c.ctx)
 }
 
-// WithCtx returns a new APIClient that uses ctx for requests it sends. Note
-// that the new APIClient will still use the authentication token and metrics
-// metadata of this client, so this is only useful for propagating other
-// context-associated metadata.
+// WithCtx returns a new APIClient that uses ctx for requests it sends. Every
+// client method derives its requests' contexts from ctx, so this is how
+// deadlines and cancellation are applied to individual calls, including
+// long-running streams such as PutFile, GetFile, ListJob and FlushCommit:
+//
+//   ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+//   defer cancel()
+//   err := c.WithCtx(ctx).GetFile(repo, commit, path, 0, 0, w)
+//
+// Note that the new APIClient will still use the authentication token and
+// metrics metadata of this client.
 func (c *APIClient) WithCtx(ctx context.Context) *APIClient {
 	result := *c // copy c
 	result.ctx = ctx
@@ -580,3 +1050,87 @@ func (c *APIClient) WithCtx(ctx context.Context) *APIClient {
 func (c *APIClient) SetAuthToken(token string) {
 	c.authenticationToken = token
 }
+
+// chainUnaryInterceptors combines 'interceptors' into a single interceptor,
+// as gRPC only accepts one per connection. interceptors[0] is the outermost.
+func chainUnaryInterceptors(interceptors []grpc.UnaryClientInterceptor) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		next := invoker
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return interceptor(ctx, method, req, reply, cc, inner, opts...)
+			}
+		}
+		return next(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// chainStreamInterceptors combines 'interceptors' into a single interceptor,
+// as gRPC only accepts one per connection. interceptors[0] is the outermost.
+func chainStreamInterceptors(interceptors []grpc.StreamClientInterceptor) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		next := streamer
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				return interceptor(ctx, desc, cc, method, inner, opts...)
+			}
+		}
+		return next(ctx, desc, cc, method, opts...)
+	}
+}
+
+// observedStream wraps a client stream, calling 'sent' and 'received' with
+// each message sent and received on it, and 'finished' with the RPC's result
+// once the stream finishes. Any of the callbacks may be nil.
+type observedStream struct {
+	grpc.ClientStream
+	// serverStreams is true if the server may send several messages on the
+	// stream, in which case the stream only finishes when RecvMsg fails
+	serverStreams bool
+	sent          func(msg interface{})
+	received      func(msg interface{})
+	finished      func(err error)
+	once          sync.Once
+}
+
+func (s *observedStream) SendMsg(msg interface{}) error {
+	err := s.ClientStream.SendMsg(msg)
+	if err == nil {
+		if s.sent != nil {
+			s.sent(msg)
+		}
+	} else if err != io.EOF {
+		// io.EOF means the RPC failed, but the error itself is only
+		// returned by RecvMsg
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *observedStream) RecvMsg(msg interface{}) error {
+	err := s.ClientStream.RecvMsg(msg)
+	switch {
+	case err == nil:
+		if s.received != nil {
+			s.received(msg)
+		}
+		if !s.serverStreams {
+			s.finish(nil) // the server's only response has been received
+		}
+	case err == io.EOF:
+		s.finish(nil)
+	default:
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *observedStream) finish(err error) {
+	s.once.Do(func() {
+		if s.finished != nil {
+			s.finished(err)
+		}
+	})
+}
@@ -0,0 +1,413 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/config"
+	"github.com/pachyderm/pachyderm/src/client/pkg/dryrun"
+	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestChainUnaryInterceptors(t *testing.T) {
+	var calls []string
+	interceptor := func(name string) grpc.UnaryClientInterceptor {
+		return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			calls = append(calls, name+" before")
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			calls = append(calls, name+" after")
+			return err
+		}
+	}
+	chain := chainUnaryInterceptors([]grpc.UnaryClientInterceptor{interceptor("a"), interceptor("b")})
+	err := chain(context.Background(), "/test", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls = append(calls, "invoke "+method)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a before", "b before", "invoke /test", "b after", "a after"}, calls)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		Multiplier:     2,
+		Jitter:         0.5,
+		RetryableCodes: []codes.Code{codes.Unavailable},
+	}
+	require.NoError(t, policy.validate())
+	interceptor := policy.unaryInterceptor(nil)
+	call := func(errs ...error) (int, error) {
+		attempts := 0
+		err := interceptor(context.Background(), "/test", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			attempts++
+			if attempts <= len(errs) {
+				return errs[attempts-1]
+			}
+			return nil
+		})
+		return attempts, err
+	}
+	unavailable := status.Error(codes.Unavailable, "pachd is restarting")
+
+	// retryable errors are retried until the call succeeds
+	attempts, err := call(unavailable, unavailable)
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+
+	// ...or the policy's attempts are exhausted
+	attempts, err = call(unavailable, unavailable, unavailable, unavailable)
+	require.Equal(t, unavailable, err)
+	require.Equal(t, 3, attempts)
+
+	// other errors are returned immediately
+	notFound := status.Error(codes.NotFound, "repo not found")
+	attempts, err = call(notFound)
+	require.Equal(t, notFound, err)
+	require.Equal(t, 1, attempts)
+}
+
+type testTracer struct {
+	spans []string
+	ended []error
+}
+
+func (t *testTracer) StartSpan(ctx context.Context, method string) (context.Context, func(error)) {
+	t.spans = append(t.spans, method)
+	return ctx, func(err error) { t.ended = append(t.ended, err) }
+}
+
+func (t *testTracer) Inject(ctx context.Context, md metadata.MD) {
+	md.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+}
+
+func TestTracingUnaryInterceptor(t *testing.T) {
+	tracer := &testTracer{}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authn-token", "abc")
+	err := tracingUnaryInterceptor(tracer)(ctx, "/pfs.API/PutFile", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, ok := metadata.FromOutgoingContext(ctx)
+		require.True(t, ok)
+		require.Equal(t, []string{"abc"}, md.Get("authn-token"))
+		require.Equal(t, []string{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}, md.Get("traceparent"))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"/pfs.API/PutFile"}, tracer.spans)
+	require.Equal(t, []error{nil}, tracer.ended)
+	// the caller's metadata isn't modified
+	md, _ := metadata.FromOutgoingContext(ctx)
+	require.Equal(t, 0, len(md.Get("traceparent")))
+}
+
+func TestTypedErrors(t *testing.T) {
+	for msg, kind := range map[string]error{
+		"repo foo not found":                                                      ErrRepoNotFound,
+		"pachyderm_pfs/repos foo not found":                                       ErrRepoNotFound,
+		"file /dir/a b not found in repo foo at commit 1234":                      ErrFileNotFound,
+		"commit 1234 in repo foo has already finished":                            ErrCommitFinished,
+		"robot:alice is not authorized to perform this operation on the repo foo": ErrNotAuthorized,
+		"pipeline bar is paused, but still has running workers":                   ErrPipelinePaused,
+		"commit 1234 not found in repo foo":                                       nil,
+	} {
+		err := newError(status.Error(codes.Unknown, msg))
+		pachErr, ok := err.(*Error)
+		require.True(t, ok)
+		require.Equal(t, msg, err.Error())
+		require.Equal(t, codes.Unknown, pachErr.Code())
+		require.True(t, kind == pachErr.kind)
+		if kind != nil {
+			require.True(t, pachErr.Is(kind))
+		}
+		// typed errors survive scrubbing
+		require.Equal(t, err, grpcutil.ScrubGRPC(err))
+	}
+	// errors that don't come from gRPC are unchanged
+	require.Equal(t, io.EOF, newError(io.EOF))
+}
+
+func TestProxyHandshakes(t *testing.T) {
+	// SOCKS5, against the handshake used by the port forwarder's proxy
+	client, server := net.Pipe()
+	go func() {
+		host, port, err := socks5Handshake(server)
+		require.NoError(t, err)
+		require.Equal(t, "pachd.example.com", host)
+		require.Equal(t, 650, port)
+		require.NoError(t, socks5Reply(server, socks5Succeeded))
+	}()
+	require.NoError(t, socks5Dial(client, "pachd.example.com:650", &url.URL{Scheme: "socks5", Host: "proxy:1080"}))
+
+	// HTTP CONNECT
+	client, server = net.Pipe()
+	go func() {
+		req, err := http.ReadRequest(bufio.NewReader(server))
+		require.NoError(t, err)
+		require.Equal(t, http.MethodConnect, req.Method)
+		require.Equal(t, "pachd.example.com:650", req.Host)
+		require.Equal(t, "Basic dXNlcjpwYXNz", req.Header.Get("Proxy-Authorization"))
+		_, err = server.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\nafter"))
+		require.NoError(t, err)
+	}()
+	require.NoError(t, httpConnect(client, "pachd.example.com:650", &url.URL{Scheme: "http", Host: "proxy", User: url.UserPassword("user", "pass")}))
+	// data sent after the proxy's response isn't lost
+	after := make([]byte, 5)
+	_, err := io.ReadFull(client, after)
+	require.NoError(t, err)
+	require.Equal(t, "after", string(after))
+}
+
+func TestContexts(t *testing.T) {
+	contexts := NewContexts(&config.Config{V1: &config.ConfigV1{
+		Contexts: map[string]*config.Context{
+			"prod":    {PachdAddress: "pachd.prod:650"},
+			"staging": {PachdAddress: "pachd.staging:650", SessionToken: "abc"},
+		},
+		ActiveContext: "staging",
+	}})
+	require.Equal(t, []string{"prod", "staging"}, contexts.Names())
+	require.Equal(t, "staging", contexts.Active())
+	c, err := contexts.Get("")
+	require.NoError(t, err)
+	require.Equal(t, "pachd.staging:650", c.PachdAddress)
+	c, err = contexts.Get("prod")
+	require.NoError(t, err)
+	require.Equal(t, "pachd.prod:650", c.PachdAddress)
+	_, err = contexts.Get("dev")
+	require.YesError(t, err)
+
+	_, err = NewContexts(&config.Config{}).Get("")
+	require.YesError(t, err)
+}
+
+func TestTokenRefresh(t *testing.T) {
+	refreshes := 0
+	r := newTokenRefresh(func(c *APIClient) (string, error) {
+		refreshes++
+		require.Equal(t, "", c.authenticationToken)
+		return "new", nil
+	}, &APIClient{})
+	interceptor := r.unaryInterceptor()
+	var sent []string
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		token := md.Get(auth.ContextTokenKey)[0]
+		sent = append(sent, token)
+		if token == "old" {
+			return auth.ErrBadToken
+		}
+		return nil
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), auth.ContextTokenKey, "old")
+
+	// the RPC is retried with a refreshed token
+	require.NoError(t, interceptor(ctx, "/pfs.API/InspectRepo", nil, nil, nil, invoker))
+	require.Equal(t, []string{"old", "new"}, sent)
+	require.Equal(t, 1, refreshes)
+
+	// later RPCs with the expired token use the refreshed one
+	sent = nil
+	require.NoError(t, interceptor(ctx, "/pfs.API/InspectRepo", nil, nil, nil, invoker))
+	require.Equal(t, []string{"new"}, sent)
+	require.Equal(t, 1, refreshes)
+}
+
+func TestMetadataCache(t *testing.T) {
+	cache, err := newMetadataCache(0, time.Minute)
+	require.NoError(t, err)
+	interceptor := cache.unaryInterceptor()
+	calls := 0
+	finished := true
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		switch r := reply.(type) {
+		case *pfs.CommitInfo:
+			*r = pfs.CommitInfo{Commit: NewCommit("repo", "abc")}
+			if finished {
+				r.Finished = &types.Timestamp{}
+			}
+		case *pfs.RepoInfo:
+			*r = pfs.RepoInfo{Repo: NewRepo("repo")}
+		}
+		return nil
+	}
+	inspectCommit := func(id string) *pfs.CommitInfo {
+		info := &pfs.CommitInfo{}
+		require.NoError(t, interceptor(context.Background(), "/pfs.API/InspectCommit",
+			&pfs.InspectCommitRequest{Commit: NewCommit("repo", id)}, info, nil, invoker))
+		return info
+	}
+	inspectRepo := func() {
+		require.NoError(t, interceptor(context.Background(), "/pfs.API/InspectRepo",
+			&pfs.InspectRepoRequest{Repo: NewRepo("repo")}, &pfs.RepoInfo{}, nil, invoker))
+	}
+
+	// open commits aren't cached
+	finished = false
+	inspectCommit("abc")
+	inspectCommit("abc")
+	require.Equal(t, 2, calls)
+
+	// finished commits are cached by ID, but not by branch
+	finished = true
+	calls = 0
+	inspectCommit("master")
+	require.Equal(t, "abc", inspectCommit("abc").Commit.ID)
+	inspectCommit("master")
+	require.Equal(t, 2, calls)
+
+	// repos are cached until a write invalidates them
+	calls = 0
+	inspectRepo()
+	inspectRepo()
+	require.Equal(t, 1, calls)
+	require.NoError(t, interceptor(context.Background(), "/pfs.API/FinishCommit",
+		&pfs.FinishCommitRequest{Commit: NewCommit("repo", "def")}, &types.Empty{}, nil, invoker))
+	inspectRepo()
+	inspectCommit("abc")
+	require.Equal(t, 3, calls)
+
+	// deleting a commit purges everything
+	require.NoError(t, interceptor(context.Background(), "/pfs.API/DeleteCommit",
+		&pfs.DeleteCommitRequest{Commit: NewCommit("repo", "abc")}, &types.Empty{}, nil, invoker))
+	calls = 0
+	inspectCommit("abc")
+	require.Equal(t, 1, calls)
+}
+
+func TestDryRun(t *testing.T) {
+	var sent []string
+	honored := true
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		sent = append(sent, method)
+		md, _ := metadata.FromOutgoingContext(ctx)
+		if md.Get(dryrun.MetadataKey)[0] != "true" || !honored {
+			return nil
+		}
+		for _, opt := range opts {
+			if header, ok := opt.(grpc.HeaderCallOption); ok {
+				*header.HeaderAddr = metadata.MD{
+					dryrun.MetadataKey: []string{"true"},
+					dryrun.ChangeKey:   []string{"delete repo " + req.(*pfs.DeleteRepoRequest).Repo.Name},
+				}
+			}
+		}
+		return nil
+	}
+	d := &DryRun{}
+	c := (&APIClient{}).WithDryRun(d)
+	call := func(method string, req interface{}) error {
+		return dryRunUnaryInterceptor(c.Ctx(), method, req, &types.Empty{}, nil, invoker)
+	}
+
+	// changes reported by pachd are collected
+	require.NoError(t, call("/pfs.API/DeleteRepo", &pfs.DeleteRepoRequest{Repo: NewRepo("a")}))
+	require.NoError(t, call("/pfs.API/DeleteRepo", &pfs.DeleteRepoRequest{Repo: NewRepo("b")}))
+	require.Equal(t, []string{"delete repo a", "delete repo b"}, d.Changes())
+
+	// reads are sent, but other writes are not
+	require.NoError(t, call("/pfs.API/InspectRepo", &pfs.InspectRepoRequest{}))
+	err := call("/pfs.API/StartCommit", &pfs.StartCommitRequest{})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	require.Equal(t, []string{"/pfs.API/DeleteRepo", "/pfs.API/DeleteRepo", "/pfs.API/InspectRepo"}, sent)
+
+	// a pachd that ignores the dry run is detected
+	honored = false
+	require.YesError(t, call("/pfs.API/DeleteRepo", &pfs.DeleteRepoRequest{Repo: NewRepo("c")}))
+	require.Equal(t, 2, len(d.Changes()))
+}
+
+func TestRateLimit(t *testing.T) {
+	// the limiter starts with 'burst' tokens, and (at one RPC per hour)
+	// doesn't refill during the test
+	interceptor := newRateLimiter(1.0/3600, 2).unaryInterceptor()
+	calls := 0
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, interceptor(ctx, "/pfs.API/InspectRepo", nil, nil, nil, invoker))
+	require.NoError(t, interceptor(ctx, "/pfs.API/InspectRepo", nil, nil, nil, invoker))
+	err := interceptor(ctx, "/pfs.API/InspectRepo", nil, nil, nil, invoker)
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	require.Equal(t, 2, calls)
+}
+
+// testLogs is a logsClient that returns 'msgs', then 'err' (or io.EOF)
+type testLogs struct {
+	msgs []*pps.LogMessage
+	err  error
+}
+
+func (l *testLogs) Recv() (*pps.LogMessage, error) {
+	if len(l.msgs) == 0 {
+		if l.err != nil {
+			return nil, l.err
+		}
+		return nil, io.EOF
+	}
+	msg := l.msgs[0]
+	l.msgs = l.msgs[1:]
+	return msg, nil
+}
+
+func TestLogsFanIn(t *testing.T) {
+	fanIn := func(streams ...*testLogs) *LogsIter {
+		ctx, cancel := context.WithCancel(context.Background())
+		f := &logsFanIn{msgs: make(chan *pps.LogMessage), ctx: ctx, cancel: cancel}
+		done := make(chan struct{})
+		for _, stream := range streams {
+			go func(stream *testLogs) {
+				f.forward("pipeline p", &LogsIter{logsClient: stream})
+				done <- struct{}{}
+			}(stream)
+		}
+		go func() {
+			for range streams {
+				<-done
+			}
+			close(f.msgs)
+		}()
+		return &LogsIter{logsClient: f}
+	}
+
+	// all messages are merged, and can be printed with their source
+	iter := fanIn(
+		&testLogs{msgs: []*pps.LogMessage{
+			{PipelineName: "a", JobID: "1", Message: "foo"},
+			{PipelineName: "a", JobID: "1", Message: "bar"},
+		}},
+		&testLogs{msgs: []*pps.LogMessage{{PipelineName: "b", Message: "baz"}}},
+	)
+	var lines []string
+	for iter.Next() {
+		lines = append(lines, iter.Line())
+	}
+	require.NoError(t, iter.Err())
+	require.ElementsEqual(t, []string{"a/1: foo", "a/1: bar", "b: baz"}, lines)
+
+	// a failing stream ends iteration
+	iter = fanIn(&testLogs{}, &testLogs{err: fmt.Errorf("no workers")})
+	for iter.Next() {
+	}
+	require.YesError(t, iter.Err())
+}
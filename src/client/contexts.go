@@ -0,0 +1,122 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/pachyderm/pachyderm/src/client/pkg/config"
+)
+
+// Contexts is the set of named cluster configurations ("contexts") in a
+// Pachyderm config, which programs that talk to several Pachyderm clusters
+// use to construct a client for each cluster by name (similar to kubectl
+// contexts)
+type Contexts struct {
+	contexts map[string]*config.Context
+	active   string
+}
+
+// LoadContexts returns the contexts in this machine's Pachyderm config (see
+// config.Read())
+func LoadContexts() (*Contexts, error) {
+	cfg, err := config.Read()
+	if err != nil {
+		return nil, err
+	}
+	return NewContexts(cfg), nil
+}
+
+// NewContexts returns the contexts in 'cfg'
+func NewContexts(cfg *config.Config) *Contexts {
+	c := &Contexts{contexts: make(map[string]*config.Context)}
+	if cfg != nil && cfg.V1 != nil {
+		for name, context := range cfg.V1.Contexts {
+			if context != nil {
+				c.contexts[name] = context
+			}
+		}
+		c.active = cfg.V1.ActiveContext
+	}
+	return c
+}
+
+// Names returns the names of all contexts, in sorted order
+func (c *Contexts) Names() []string {
+	var names []string
+	for name := range c.contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Active returns the name of the active context, or "" if none is set
+func (c *Contexts) Active() string {
+	return c.active
+}
+
+// Get returns the context named 'name' ("" for the active context)
+func (c *Contexts) Get(name string) (*config.Context, error) {
+	if name == "" {
+		if c.active == "" {
+			return nil, fmt.Errorf("no active context is set")
+		}
+		name = c.active
+	}
+	context, ok := c.contexts[name]
+	if !ok {
+		return nil, fmt.Errorf("context %q not found", name)
+	}
+	return context, nil
+}
+
+// NewClient constructs a new APIClient for the cluster in the context named
+// 'name' ("" for the active context), using the context's address, TLS
+// certificates and session token. 'options' are applied after the context's
+// settings. If the context has no address, the client connects through a port
+// forwarder (using the context's kubernetes context and namespace), which is
+// closed when the client is closed.
+func (c *Contexts) NewClient(name string, options ...Option) (*APIClient, error) {
+	context, err := c.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	contextOptions, err := getCertOptionsFromConfig(context.ServerCAs, context.ClientCert, context.ClientKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid context %q: %v", name, err)
+	}
+	options = append(contextOptions, options...)
+
+	addr := context.PachdAddress
+	var fw *PortForwarder
+	if addr == "" {
+		var fwOptions []PortForwarderOption
+		if context.KubeContext != "" {
+			fwOptions = append(fwOptions, WithKubeContext(context.KubeContext))
+		}
+		fw, err = NewPortForwarder(context.Namespace, ioutil.Discard, os.Stderr, fwOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("could not port forward to pachd for context %q: %v", name, err)
+		}
+		fw.FreePortFallback = true
+		pachdPort, err := fw.RunForDaemon(0)
+		if err != nil {
+			fw.Close()
+			return nil, fmt.Errorf("could not port forward to pachd for context %q: %v", name, err)
+		}
+		addr = fmt.Sprintf("localhost:%d", pachdPort)
+	}
+
+	client, err := NewFromAddress(addr, options...)
+	if err != nil {
+		if fw != nil {
+			fw.Close()
+		}
+		return nil, fmt.Errorf("could not connect to pachd at %q: %v", addr, err)
+	}
+	client.authenticationToken = context.SessionToken
+	client.portForwarder = fw
+	return client, nil
+}
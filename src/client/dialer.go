@@ -0,0 +1,342 @@
+package client
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// socks5UserPass is the SOCKS5 username/password authentication method (see
+// RFC 1929)
+const socks5UserPass = 2
+
+// Dialer opens a connection to the pachd address 'addr' (see WithDialer())
+type Dialer func(ctx context.Context, addr string) (net.Conn, error)
+
+// proxyFromEnvironment returns the URL of the proxy that connections to 'addr'
+// should go through, according to the HTTPS_PROXY, HTTP_PROXY and NO_PROXY
+// environment variables (HTTPS_PROXY takes precedence, and lowercase names
+// are accepted too), or nil if connections to 'addr' shouldn't be proxied.
+// Connections to localhost and to in-cluster addresses (see inCluster()) are
+// never proxied.
+func proxyFromEnvironment(addr string) (*url.URL, error) {
+	var proxy string
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		if proxy = os.Getenv(name); proxy != "" {
+			break
+		}
+	}
+	if proxy == "" {
+		return nil, nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %v", addr, err)
+	}
+	if inCluster(host) || noProxy(host, port) {
+		return nil, nil
+	}
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy address %q: %v", proxy, err)
+	}
+	return proxyURL, nil
+}
+
+// inCluster returns true if 'host' is localhost, an address of this machine,
+// pachd's in-cluster service address, or a kubernetes service name, none of
+// which are reachable through a proxy outside the cluster
+func inCluster(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	switch {
+	case host == "localhost" || strings.HasSuffix(host, ".localhost"):
+		return true
+	case host == os.Getenv("PACHD_SERVICE_HOST"):
+		return true
+	case strings.HasSuffix(host, ".svc") || strings.HasSuffix(host, ".cluster.local"):
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() || ip.IsUnspecified() {
+		return true
+	}
+	// e.g. pachd connecting to itself at its pod's IP
+	localAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, localAddr := range localAddrs {
+		if ipNet, ok := localAddr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// noProxy returns true if NO_PROXY excludes 'host' (and 'port') from being
+// proxied. As with HTTP clients, NO_PROXY is a comma-separated list of host
+// names (which also match their subdomains, with or without a leading '.'),
+// IP addresses and CIDR ranges, each optionally with a port, or "*".
+func noProxy(host, port string) bool {
+	value := os.Getenv("NO_PROXY")
+	if value == "" {
+		value = os.Getenv("no_proxy")
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return true
+		}
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && ipNet.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if entryHost, entryPort, err := net.SplitHostPort(entry); err == nil {
+			if entryPort != port {
+				continue
+			}
+			entry = entryHost
+		}
+		if entryIP := net.ParseIP(entry); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		entry = strings.TrimPrefix(entry, "*")
+		if host == strings.TrimPrefix(entry, ".") || strings.HasSuffix(host, "."+strings.TrimPrefix(entry, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialDirect connects to 'addr', which may be a "unix:<path>" address
+func dialDirect(ctx context.Context, addr string) (net.Conn, error) {
+	network := "tcp"
+	if strings.HasPrefix(addr, "unix:") {
+		network, addr = "unix", strings.TrimPrefix(strings.TrimPrefix(addr, "unix:"), "//")
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, addr)
+}
+
+// dialProxied is the Dialer that clients use unless WithDialer() is passed. It
+// connects to 'addr' through the proxy given by the environment (see
+// proxyFromEnvironment()), if any. HTTP proxies are traversed with a CONNECT
+// request, and SOCKS5 proxies (e.g. HTTPS_PROXY=socks5://host:1080) with a
+// SOCKS5 CONNECT request.
+func dialProxied(ctx context.Context, addr string) (net.Conn, error) {
+	if strings.HasPrefix(addr, "unix:") {
+		return dialDirect(ctx, addr)
+	}
+	proxyURL, err := proxyFromEnvironment(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy configuration: %v", err)
+	}
+	if proxyURL == nil {
+		return dialDirect(ctx, addr)
+	}
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		defaultPort := "80"
+		if strings.HasPrefix(proxyURL.Scheme, "socks5") {
+			defaultPort = "1080"
+		}
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), defaultPort)
+	}
+	conn, err := dialDirect(ctx, proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to proxy %s: %v", proxyAddr, err)
+	}
+	// bound the handshake by the dial's deadline
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	switch proxyURL.Scheme {
+	case "http", "":
+		err = httpConnect(conn, addr, proxyURL)
+	case "socks5", "socks5h":
+		err = socks5Dial(conn, addr, proxyURL)
+	default:
+		err = fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not connect to %s through proxy %s: %v", addr, proxyAddr, err)
+	}
+	return conn, nil
+}
+
+// httpConnect asks the HTTP proxy connected to by 'conn' to open a tunnel to
+// 'addr'
+func httpConnect(conn net.Conn, addr string, proxyURL *url.URL) error {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	// read the response a byte at a time, so that no data sent through the
+	// tunnel after the response is buffered (and lost)
+	resp, err := http.ReadResponse(bufio.NewReaderSize(byteReader{conn}, 1), req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("proxy responded with %q", resp.Status)
+	}
+	return nil
+}
+
+// byteReader reads at most one byte at a time from a connection
+type byteReader struct {
+	conn net.Conn
+}
+
+func (r byteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return r.conn.Read(p)
+}
+
+// socks5Dial asks the SOCKS5 proxy connected to by 'conn' to connect to
+// 'addr'. The proxy resolves 'addr', so names that only resolve on the
+// proxy's side (e.g. cluster-internal names) work.
+func socks5Dial(conn net.Conn, addr string, proxyURL *url.URL) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("invalid port in %q", addr)
+	}
+	if len(host) > 255 {
+		return fmt.Errorf("host name %q is too long", host)
+	}
+
+	// greeting: VER NMETHODS METHODS...
+	methods := []byte{socks5NoAuth}
+	if proxyURL.User != nil {
+		methods = []byte{socks5UserPass}
+	}
+	greeting := append([]byte{socks5Version, byte(len(methods))}, methods...)
+	if _, err := conn.Write(greeting); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	switch reply[1] {
+	case socks5NoAuth:
+	case socks5UserPass:
+		if err := socks5Authenticate(conn, proxyURL.User); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("proxy requires an unsupported authentication method")
+	}
+
+	// request: VER CMD RSV ATYP DST.ADDR DST.PORT
+	request := []byte{socks5Version, socks5Connect, 0}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		request = append(append(request, socks5IPv4), ip.To4()...)
+	} else if ip != nil {
+		request = append(append(request, socks5IPv6), ip.To16()...)
+	} else {
+		request = append(append(request, socks5Domain, byte(len(host))), host...)
+	}
+	request = append(request, 0, 0)
+	binary.BigEndian.PutUint16(request[len(request)-2:], uint16(port))
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	// reply: VER REP RSV ATYP BND.ADDR BND.PORT
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[1] != socks5Succeeded {
+		return fmt.Errorf("proxy failed to connect (SOCKS5 reply %d)", header[1])
+	}
+	var boundAddrLen int
+	switch header[3] {
+	case socks5IPv4:
+		boundAddrLen = net.IPv4len
+	case socks5IPv6:
+		boundAddrLen = net.IPv6len
+	case socks5Domain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return err
+		}
+		boundAddrLen = int(length[0])
+	default:
+		return fmt.Errorf("proxy replied with unsupported address type %d", header[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, boundAddrLen+2))
+	return err
+}
+
+// socks5Authenticate authenticates with a SOCKS5 proxy using 'user's
+// username and password (see RFC 1929)
+func socks5Authenticate(conn net.Conn, user *url.Userinfo) error {
+	username := user.Username()
+	password, _ := user.Password()
+	if len(username) > 255 || len(password) > 255 {
+		return fmt.Errorf("proxy username or password is too long")
+	}
+	request := []byte{1, byte(len(username))}
+	request = append(request, username...)
+	request = append(request, byte(len(password)))
+	request = append(request, password...)
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[1] != 0 {
+		return fmt.Errorf("proxy rejected username and password")
+	}
+	return nil
+}
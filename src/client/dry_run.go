@@ -0,0 +1,166 @@
+package client
+
+import (
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/pachyderm/pachyderm/src/client/pkg/dryrun"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// dryRunMethods are the RPCs that pachd can dry run
+var dryRunMethods = map[string]bool{
+	"/pfs.API/CreateRepo":       true,
+	"/pfs.API/DeleteRepo":       true,
+	"/pfs.API/DeleteCommit":     true,
+	"/pfs.API/DeleteBranch":     true,
+	"/pps.API/ApplyPipelines":   true,
+	"/pps.API/CreatePipeline":   true,
+	"/pps.API/DeletePipeline":   true,
+	"/pps.API/RollbackPipeline": true,
+}
+
+// readOnlyMethods are the RPCs that don't change pachd's state, which a
+// client in dry run mode still sends (e.g. so that ListRepo can be used to
+// decide what to delete)
+var readOnlyMethods = map[string]bool{
+	"/admin.API/Extract":            true,
+	"/admin.API/ExtractPipeline":    true,
+	"/admin.API/InspectCluster":     true,
+	"/auth.API/Authorize":           true,
+	"/auth.API/GetACL":              true,
+	"/auth.API/GetAdmins":           true,
+	"/auth.API/GetConfiguration":    true,
+	"/auth.API/GetGroups":           true,
+	"/auth.API/GetScope":            true,
+	"/auth.API/GetUsers":            true,
+	"/auth.API/WhoAmI":              true,
+	"/debug.Debug/Dump":             true,
+	"/enterprise.API/GetState":      true,
+	"/health.Health/Health":         true,
+	"/pfs.API/DiffFile":             true,
+	"/pfs.API/FlushCommit":          true,
+	"/pfs.API/GetFile":              true,
+	"/pfs.API/GlobFile":             true,
+	"/pfs.API/GlobFileStream":       true,
+	"/pfs.API/InspectBranch":        true,
+	"/pfs.API/InspectCommit":        true,
+	"/pfs.API/InspectFile":          true,
+	"/pfs.API/InspectRepo":          true,
+	"/pfs.API/ListBranch":           true,
+	"/pfs.API/ListCommit":           true,
+	"/pfs.API/ListCommitStream":     true,
+	"/pfs.API/ListFile":             true,
+	"/pfs.API/ListFileStream":       true,
+	"/pfs.API/ListRepo":             true,
+	"/pfs.API/SubscribeCommit":      true,
+	"/pfs.API/WalkFile":             true,
+	"/pfs.ObjectAPI/CheckObject":    true,
+	"/pfs.ObjectAPI/GetBlocks":      true,
+	"/pfs.ObjectAPI/GetObject":      true,
+	"/pfs.ObjectAPI/GetObjects":     true,
+	"/pfs.ObjectAPI/GetTag":         true,
+	"/pfs.ObjectAPI/InspectObject":  true,
+	"/pfs.ObjectAPI/InspectTag":     true,
+	"/pfs.ObjectAPI/ListObjects":    true,
+	"/pfs.ObjectAPI/ListTags":       true,
+	"/pps.API/FlushJob":             true,
+	"/pps.API/GetLogs":              true,
+	"/pps.API/InspectDatum":         true,
+	"/pps.API/InspectJob":           true,
+	"/pps.API/InspectPipeline":      true,
+	"/pps.API/InspectScheduler":     true,
+	"/pps.API/ListDatum":            true,
+	"/pps.API/ListDatumStream":      true,
+	"/pps.API/ListJob":              true,
+	"/pps.API/ListJobStream":        true,
+	"/pps.API/ListPipeline":         true,
+	"/pps.API/ListPipelineVersions": true,
+	"/pps.API/ValidatePipeline":     true,
+	"/versionpb.API/GetVersion":     true,
+}
+
+// DryRun collects the changes that pachd reports for the calls made by a
+// client returned by WithDryRun
+type DryRun struct {
+	mu      sync.Mutex
+	changes []string
+}
+
+// Changes returns the changes that the dry run calls made so far would have
+// made, in the order that the calls returned
+func (d *DryRun) Changes() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.changes...)
+}
+
+func (d *DryRun) add(changes []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.changes = append(d.changes, changes...)
+}
+
+// dryRunKey is the context key under which AddMetadata stores a client's
+// DryRun, for the dry run interceptors
+type dryRunKey struct{}
+
+// WithDryRun returns a new APIClient whose calls that would change pachd's
+// state are validated by pachd, but not applied. Instead, pachd describes the
+// changes that each call would have made, and they are added to 'd':
+//
+//	d := &client.DryRun{}
+//	if err := c.WithDryRun(d).DeletePipeline("edges", false); err != nil {
+//		return err // e.g. the pipeline doesn't exist
+//	}
+//	for _, change := range d.Changes() {
+//		fmt.Println(change) // e.g. "delete repo edges and all of its commits"
+//	}
+//
+// CreateRepo, DeleteRepo, DeleteBranch, DeleteCommit, CreatePipeline and
+// DeletePipeline (including updates, and deleting all repos or pipelines)
+// support dry runs. Calls that only read state are sent as usual, and any
+// other call fails without being sent.
+func (c *APIClient) WithDryRun(d *DryRun) *APIClient {
+	result := *c // copy c
+	result.dryRun = d
+	return &result
+}
+
+// errDryRunUnsupported returns the error that a client in dry run mode
+// returns for calls that can't be dry run
+func errDryRunUnsupported(method string) error {
+	return status.Errorf(codes.FailedPrecondition, "%s can't be dry run", path.Base(method))
+}
+
+func dryRunUnaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	d, ok := ctx.Value(dryRunKey{}).(*DryRun)
+	if !ok || readOnlyMethods[method] {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	if !dryRunMethods[method] {
+		return errDryRunUnsupported(method)
+	}
+	var header metadata.MD
+	if err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header))...); err != nil {
+		return err
+	}
+	if len(header[dryrun.MetadataKey]) == 0 {
+		return fmt.Errorf("pachd did not perform a dry run of %s, so the call may "+
+			"have been applied (pachd may be too old to support dry runs)", path.Base(method))
+	}
+	d.add(header[dryrun.ChangeKey])
+	return nil
+}
+
+func dryRunStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if _, ok := ctx.Value(dryRunKey{}).(*DryRun); ok && !readOnlyMethods[method] {
+		return nil, errDryRunUnsupported(method)
+	}
+	return streamer(ctx, desc, cc, method, opts...)
+}
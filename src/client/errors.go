@@ -0,0 +1,131 @@
+package client
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Sentinel errors identifying conditions reported by pachd. Errors returned
+// by an APIClient match these via errors.Is, e.g.
+// errors.Is(err, client.ErrRepoNotFound), so callers needn't parse error
+// messages.
+var (
+	// ErrRepoNotFound indicates that a repo doesn't exist
+	ErrRepoNotFound = errors.New("repo not found")
+	// ErrFileNotFound indicates that a file doesn't exist in a commit
+	ErrFileNotFound = errors.New("file not found")
+	// ErrCommitFinished indicates that a commit can't be written to, as it
+	// has already finished
+	ErrCommitFinished = errors.New("commit has already finished")
+	// ErrNotAuthorized indicates that the caller doesn't have the access
+	// needed to perform an operation (see auth.ErrNotAuthorized)
+	ErrNotAuthorized = errors.New("not authorized to perform this operation")
+	// ErrPipelinePaused indicates that an operation can't be performed as a
+	// pipeline is paused (i.e. stopped)
+	ErrPipelinePaused = errors.New("pipeline is paused")
+)
+
+// errorKinds maps each sentinel error to a function that recognizes pachd's
+// error messages for that condition. pachd's errors only reach the client
+// as messages, so their types can't be recovered any other way.
+var errorKinds = []struct {
+	kind    error
+	matches func(msg string) bool
+}{
+	{ErrRepoNotFound, regexp.MustCompile(`(^|[ /])repos? [^ ]+ not found`).MatchString},
+	{ErrFileNotFound, regexp.MustCompile(`file .+ not found in repo [^ ]+ at commit [^ ]+`).MatchString},
+	{ErrCommitFinished, regexp.MustCompile(`commit [^ ]+ in repo [^ ]+ has already finished`).MatchString},
+	{ErrNotAuthorized, func(msg string) bool { return auth.IsErrNotAuthorized(errors.New(msg)) }},
+	{ErrPipelinePaused, regexp.MustCompile(`pipeline [^ ]+ is paused`).MatchString},
+}
+
+// Error is the type of the errors that pachd returns to an APIClient. Its
+// message is pachd's error message (without gRPC's "rpc error: code = ..."
+// prefix), and it matches the sentinel error (e.g. ErrRepoNotFound) for the
+// condition it reports, if any, via errors.Is. Its gRPC status is preserved,
+// so status.Code() still works on it.
+type Error struct {
+	status *status.Status
+	kind   error
+}
+
+// newError returns an *Error for the gRPC error 'err', or 'err' unchanged if
+// it didn't come from gRPC
+func newError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	e := &Error{status: s}
+	for _, k := range errorKinds {
+		if k.matches(s.Message()) {
+			e.kind = k.kind
+			break
+		}
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.status.Message()
+}
+
+// Code returns the gRPC status code that pachd returned with the error
+func (e *Error) Code() codes.Code {
+	return e.status.Code()
+}
+
+// GRPCStatus returns the gRPC status that pachd returned with the error
+func (e *Error) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// Is returns true if 'target' is the sentinel error for the condition 'e'
+// reports. It's used by errors.Is.
+func (e *Error) Is(target error) bool {
+	return e.kind != nil && e.kind == target
+}
+
+func errorUnaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return newError(invoker(ctx, method, req, reply, cc, opts...))
+}
+
+func errorStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		return nil, newError(err)
+	}
+	return &errorStream{stream}, nil
+}
+
+// errorStream converts the errors returned by a stream into *Errors
+type errorStream struct {
+	grpc.ClientStream
+}
+
+// Errors that don't come from gRPC, in particular io.EOF (which callers
+// compare against directly), are returned unchanged
+
+func (s *errorStream) SendMsg(msg interface{}) error {
+	return newError(s.ClientStream.SendMsg(msg))
+}
+
+func (s *errorStream) RecvMsg(msg interface{}) error {
+	return newError(s.ClientStream.RecvMsg(msg))
+}
+
+func (s *errorStream) CloseSend() error {
+	return newError(s.ClientStream.CloseSend())
+}
@@ -0,0 +1,367 @@
+package client
+
+import (
+	"io"
+
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+)
+
+// DefaultPageSize is the number of items that the iterators returned by
+// ListRepoIter, ListCommitIter, ListFileIter, ListJobIter and ListDatumIter
+// fetch from pachd at a time, if no page size is given.
+const DefaultPageSize = 1000
+
+// pageIterator holds the state shared by all iterators. It fetches one page of
+// items at a time with 'fetch', which also reports whether there are more
+// pages to fetch.
+//
+// Pages are fetched by offset, so if items are added to the front of the
+// listing between two pages, the items at the end of a page would be returned
+// again at the start of the next. pageIterator skips items whose key was on
+// the previous page, so that each item is returned once. Items deleted during
+// iteration may cause other items to be skipped.
+type pageIterator struct {
+	fetch func(page int64) (items []interface{}, more bool, err error)
+	key   func(item interface{}) string
+
+	page    int64
+	items   []interface{}
+	current interface{}
+	prev    map[string]bool
+	done    bool
+	err     error
+}
+
+func (it *pageIterator) next() bool {
+	for len(it.items) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		items, more, err := it.fetch(it.page)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page++
+		it.done = !more
+		keys := make(map[string]bool, len(items))
+		for _, item := range items {
+			key := it.key(item)
+			if !it.prev[key] {
+				it.items = append(it.items, item)
+			}
+			keys[key] = true
+		}
+		it.prev = keys
+	}
+	it.current, it.items = it.items[0], it.items[1:]
+	return true
+}
+
+func pageSizeOrDefault(pageSize int64) int64 {
+	if pageSize <= 0 {
+		return DefaultPageSize
+	}
+	return pageSize
+}
+
+// RepoIterator iterates over the repos in a cluster, fetching them from pachd
+// one page at a time:
+//
+//	it := c.ListRepoIter(0)
+//	for it.Next() {
+//		repoInfo := it.RepoInfo()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type RepoIterator struct {
+	it pageIterator
+}
+
+// Next advances the iterator to the next repo. It returns false once there
+// are no more repos, or an error occurs (see Err).
+func (i *RepoIterator) Next() bool {
+	return i.it.next()
+}
+
+// RepoInfo returns the repo that the iterator is at
+func (i *RepoIterator) RepoInfo() *pfs.RepoInfo {
+	return i.it.current.(*pfs.RepoInfo)
+}
+
+// Err returns the error (if any) that ended iteration
+func (i *RepoIterator) Err() error {
+	return i.it.err
+}
+
+// ListRepoIter returns an iterator over all repos, fetching 'pageSize' repos
+// at a time (DefaultPageSize if 'pageSize' is 0).
+func (c APIClient) ListRepoIter(pageSize int64) *RepoIterator {
+	pageSize = pageSizeOrDefault(pageSize)
+	return &RepoIterator{pageIterator{
+		fetch: func(page int64) ([]interface{}, bool, error) {
+			response, err := c.PfsAPIClient.ListRepo(
+				c.Ctx(),
+				&pfs.ListRepoRequest{
+					PageSize: pageSize,
+					Page:     page,
+				},
+			)
+			if err != nil {
+				return nil, false, grpcutil.ScrubGRPC(err)
+			}
+			items := make([]interface{}, len(response.RepoInfo))
+			for i, repoInfo := range response.RepoInfo {
+				items[i] = repoInfo
+			}
+			return items, int64(len(items)) == pageSize, nil
+		},
+		key: func(item interface{}) string {
+			return item.(*pfs.RepoInfo).Repo.Name
+		},
+	}}
+}
+
+// CommitIterator iterates over commits, fetching them from pachd one page at
+// a time. See RepoIterator for an example.
+type CommitIterator struct {
+	it pageIterator
+}
+
+// Next advances the iterator to the next commit. It returns false once there
+// are no more commits, or an error occurs (see Err).
+func (i *CommitIterator) Next() bool {
+	return i.it.next()
+}
+
+// CommitInfo returns the commit that the iterator is at
+func (i *CommitIterator) CommitInfo() *pfs.CommitInfo {
+	return i.it.current.(*pfs.CommitInfo)
+}
+
+// Err returns the error (if any) that ended iteration
+func (i *CommitIterator) Err() error {
+	return i.it.err
+}
+
+// ListCommitIter returns an iterator over the commits that ListCommit would
+// return, fetching 'pageSize' commits at a time (DefaultPageSize if
+// 'pageSize' is 0).
+//
+// If 'to' is set, each page starts at the parent of the last commit on the
+// previous page, so iterating over a long chain of commits doesn't require
+// pachd to walk from 'to' for every page.
+func (c APIClient) ListCommitIter(repoName string, to string, from string, number uint64, pageSize int64) *CommitIterator {
+	pageSize = pageSizeOrDefault(pageSize)
+	request := &pfs.ListCommitRequest{
+		Repo: NewRepo(repoName),
+	}
+	if from != "" {
+		request.From = NewCommit(repoName, from)
+	}
+	if to != "" {
+		request.To = NewCommit(repoName, to)
+	}
+	remaining := number // only used if 'number' is nonzero
+	return &CommitIterator{pageIterator{
+		fetch: func(page int64) ([]interface{}, bool, error) {
+			request.Number = number
+			if request.To != nil {
+				// walk from the cursor in request.To, rather than paging
+				request.Number = uint64(pageSize)
+				if number != 0 && remaining < request.Number {
+					request.Number = remaining
+				}
+			} else {
+				request.PageSize = pageSize
+				request.Page = page
+			}
+			response, err := c.PfsAPIClient.ListCommit(c.Ctx(), request)
+			if err != nil {
+				return nil, false, grpcutil.ScrubGRPC(err)
+			}
+			items := make([]interface{}, len(response.CommitInfo))
+			for i, commitInfo := range response.CommitInfo {
+				items[i] = commitInfo
+			}
+			more := int64(len(items)) == pageSize
+			if request.To != nil && len(items) > 0 {
+				parent := response.CommitInfo[len(items)-1].ParentCommit
+				remaining -= uint64(len(items))
+				more = more && parent != nil && (number == 0 || remaining > 0)
+				if parent != nil {
+					request.To = parent
+				}
+			}
+			return items, more, nil
+		},
+		key: func(item interface{}) string {
+			return item.(*pfs.CommitInfo).Commit.ID
+		},
+	}}
+}
+
+// FileIterator iterates over the files in a directory, fetching them from
+// pachd one page at a time. See RepoIterator for an example.
+type FileIterator struct {
+	it pageIterator
+}
+
+// Next advances the iterator to the next file. It returns false once there
+// are no more files, or an error occurs (see Err).
+func (i *FileIterator) Next() bool {
+	return i.it.next()
+}
+
+// FileInfo returns the file that the iterator is at
+func (i *FileIterator) FileInfo() *pfs.FileInfo {
+	return i.it.current.(*pfs.FileInfo)
+}
+
+// Err returns the error (if any) that ended iteration
+func (i *FileIterator) Err() error {
+	return i.it.err
+}
+
+// ListFileIter returns an iterator over the files that ListFile would return,
+// in lexicographic order of their paths, fetching 'pageSize' files at a time
+// (DefaultPageSize if 'pageSize' is 0). Each page starts after the last file
+// of the previous page, so huge directories can be listed without pachd or
+// the client holding all of their files at once. 'path' must be a directory
+// or file, rather than a glob pattern.
+func (c APIClient) ListFileIter(repoName string, commitID string, path string, pageSize int64) *FileIterator {
+	pageSize = pageSizeOrDefault(pageSize)
+	request := &pfs.ListFileRequest{
+		File:     NewFile(repoName, commitID, path),
+		PageSize: pageSize,
+	}
+	return &FileIterator{pageIterator{
+		fetch: func(page int64) ([]interface{}, bool, error) {
+			response, err := c.PfsAPIClient.ListFile(c.Ctx(), request)
+			if err != nil {
+				return nil, false, grpcutil.ScrubGRPC(err)
+			}
+			items := make([]interface{}, len(response.FileInfo))
+			for i, fileInfo := range response.FileInfo {
+				items[i] = fileInfo
+			}
+			if len(items) > 0 {
+				request.Cursor = response.FileInfo[len(items)-1].File.Path
+			}
+			return items, int64(len(items)) == pageSize, nil
+		},
+		key: func(item interface{}) string {
+			return item.(*pfs.FileInfo).File.Path
+		},
+	}}
+}
+
+// JobIterator iterates over jobs, fetching them from pachd one page at a
+// time. See RepoIterator for an example.
+type JobIterator struct {
+	it pageIterator
+}
+
+// Next advances the iterator to the next job. It returns false once there are
+// no more jobs, or an error occurs (see Err).
+func (i *JobIterator) Next() bool {
+	return i.it.next()
+}
+
+// JobInfo returns the job that the iterator is at
+func (i *JobIterator) JobInfo() *pps.JobInfo {
+	return i.it.current.(*pps.JobInfo)
+}
+
+// Err returns the error (if any) that ended iteration
+func (i *JobIterator) Err() error {
+	return i.it.err
+}
+
+// ListJobIter returns an iterator over the jobs that ListJob would return,
+// fetching 'pageSize' jobs at a time (DefaultPageSize if 'pageSize' is 0).
+func (c APIClient) ListJobIter(pipelineName string, inputCommit []*pfs.Commit, outputCommit *pfs.Commit, pageSize int64) *JobIterator {
+	pageSize = pageSizeOrDefault(pageSize)
+	var pipeline *pps.Pipeline
+	if pipelineName != "" {
+		pipeline = NewPipeline(pipelineName)
+	}
+	return &JobIterator{pageIterator{
+		fetch: func(page int64) ([]interface{}, bool, error) {
+			response, err := c.PpsAPIClient.ListJob(
+				c.Ctx(),
+				&pps.ListJobRequest{
+					Pipeline:     pipeline,
+					InputCommit:  inputCommit,
+					OutputCommit: outputCommit,
+					PageSize:     pageSize,
+					Page:         page,
+				},
+			)
+			if err != nil {
+				return nil, false, grpcutil.ScrubGRPC(err)
+			}
+			items := make([]interface{}, len(response.JobInfo))
+			for i, jobInfo := range response.JobInfo {
+				items[i] = jobInfo
+			}
+			return items, int64(len(items)) == pageSize, nil
+		},
+		key: func(item interface{}) string {
+			return item.(*pps.JobInfo).Job.ID
+		},
+	}}
+}
+
+// DatumIterator iterates over the datums in a job, fetching them from pachd
+// one page at a time. See RepoIterator for an example.
+type DatumIterator struct {
+	it pageIterator
+}
+
+// Next advances the iterator to the next datum. It returns false once there
+// are no more datums, or an error occurs (see Err).
+func (i *DatumIterator) Next() bool {
+	return i.it.next()
+}
+
+// DatumInfo returns the datum that the iterator is at
+func (i *DatumIterator) DatumInfo() *pps.DatumInfo {
+	return i.it.current.(*pps.DatumInfo)
+}
+
+// Err returns the error (if any) that ended iteration
+func (i *DatumIterator) Err() error {
+	return i.it.err
+}
+
+// ListDatumIter returns an iterator over the datums in the job 'jobID',
+// fetching 'pageSize' datums at a time (DefaultPageSize if 'pageSize' is 0).
+func (c APIClient) ListDatumIter(jobID string, pageSize int64) *DatumIterator {
+	pageSize = pageSizeOrDefault(pageSize)
+	return &DatumIterator{pageIterator{
+		fetch: func(page int64) ([]interface{}, bool, error) {
+			response, err := c.ListDatum(jobID, pageSize, page)
+			if err != nil {
+				// pachd returns EOF for pages past the last one (including
+				// the first page of a job with no datums)
+				if err.Error() == io.EOF.Error() {
+					return nil, false, nil
+				}
+				return nil, false, err
+			}
+			items := make([]interface{}, len(response.DatumInfos))
+			for i, datumInfo := range response.DatumInfos {
+				items[i] = datumInfo
+			}
+			return items, page+1 < response.TotalPages, nil
+		},
+		key: func(item interface{}) string {
+			return item.(*pps.DatumInfo).Datum.ID
+		},
+	}}
+}
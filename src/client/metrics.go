@@ -0,0 +1,149 @@
+package client
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	bytesSent     = "sent"
+	bytesReceived = "received"
+)
+
+// Metrics records Prometheus metrics about the RPCs made by the clients it's
+// passed to (see WithMetrics()): the latency and result of each RPC, the
+// number of bytes sent and received on streaming RPCs (e.g. PutFile and
+// GetFile), and the number of retries made under a RetryPolicy. Metrics is a
+// prometheus.Collector, so an application exposes them by registering it and
+// serving the registry with promhttp, e.g.:
+//
+//	metrics := client.NewMetrics()
+//	prometheus.MustRegister(metrics)
+//	http.Handle("/metrics", promhttp.Handler())
+//	c, err := client.NewOnUserMachine(false, false, "user", client.WithMetrics(metrics))
+type Metrics struct {
+	rpcDuration   *prometheus.HistogramVec
+	streamedBytes *prometheus.CounterVec
+	retries       *prometheus.CounterVec
+}
+
+// NewMetrics returns a new, unregistered Metrics. A single Metrics may be
+// shared by several clients.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		rpcDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: "pachyderm",
+				Subsystem: "client",
+				Name:      "rpc_duration_seconds",
+				Help:      "Time taken by RPCs to pachd (including retries), by method and status code",
+				Buckets:   prometheus.ExponentialBuckets(0.001, 2.0, 20), // up to ~9 minutes
+			},
+			[]string{
+				"method",
+				"code",
+			},
+		),
+		streamedBytes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "pachyderm",
+				Subsystem: "client",
+				Name:      "streamed_bytes_total",
+				Help:      "Bytes sent and received on streaming RPCs to pachd, by method and direction (sent|received)",
+			},
+			[]string{
+				"method",
+				"direction",
+			},
+		),
+		retries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "pachyderm",
+				Subsystem: "client",
+				Name:      "retries_total",
+				Help:      "Number of times RPCs to pachd were retried under the client's retry policy, by method",
+			},
+			[]string{
+				"method",
+			},
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.rpcDuration.Describe(ch)
+	m.streamedBytes.Describe(ch)
+	m.retries.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.rpcDuration.Collect(ch)
+	m.streamedBytes.Collect(ch)
+	m.retries.Collect(ch)
+}
+
+// The record* methods are no-ops on a nil *Metrics, so that callers needn't
+// check whether metrics are enabled
+
+func (m *Metrics) recordRPC(method string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.rpcDuration.WithLabelValues(method, status.Code(err).String()).Observe(time.Since(start).Seconds())
+}
+
+func (m *Metrics) recordBytes(method, direction string, msg interface{}) {
+	if m == nil {
+		return
+	}
+	if pb, ok := msg.(proto.Message); ok {
+		m.streamedBytes.WithLabelValues(method, direction).Add(float64(proto.Size(pb)))
+	}
+}
+
+func (m *Metrics) recordRetry(method string) {
+	if m == nil {
+		return
+	}
+	m.retries.WithLabelValues(method).Inc()
+}
+
+func (m *Metrics) unaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		m.recordRPC(method, start, err)
+		return err
+	}
+}
+
+func (m *Metrics) streamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			m.recordRPC(method, start, err)
+			return nil, err
+		}
+		return &observedStream{
+			ClientStream:  stream,
+			serverStreams: desc.ServerStreams,
+			sent: func(msg interface{}) {
+				m.recordBytes(method, bytesSent, msg)
+			},
+			received: func(msg interface{}) {
+				m.recordBytes(method, bytesReceived, msg)
+			},
+			finished: func(err error) {
+				m.recordRPC(method, start, err)
+			},
+		}, nil
+	}
+}
@@ -3,14 +3,22 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"crypto/sha256"
 	"fmt"
 	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gogo/protobuf/types"
 	"github.com/pachyderm/pachyderm/src/client/pfs"
 	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
 	"github.com/pachyderm/pachyderm/src/server/pkg/errutil"
+	"golang.org/x/sync/errgroup"
 )
 
 // NewRepo creates a pfs.Repo.
@@ -77,6 +85,115 @@ func (c APIClient) CreateRepo(repoName string) error {
 	return grpcutil.ScrubGRPC(err)
 }
 
+// UpdateRepoRetention sets the retention policy of a repo (see
+// pfs.RetentionPolicy), which determines how long its commits are kept. A nil
+// 'retention' removes the repo's policy, so that its commits are kept forever.
+func (c APIClient) UpdateRepoRetention(repoName string, retention *pfs.RetentionPolicy) error {
+	repoInfo, err := c.InspectRepo(repoName)
+	if err != nil {
+		return err
+	}
+	if retention == nil {
+		retention = &pfs.RetentionPolicy{}
+	}
+	_, err = c.PfsAPIClient.CreateRepo(
+		c.Ctx(),
+		&pfs.CreateRepoRequest{
+			Repo:        NewRepo(repoName),
+			Description: repoInfo.Description,
+			Update:      true,
+			Retention:   retention,
+		},
+	)
+	return grpcutil.ScrubGRPC(err)
+}
+
+// UpdateRepoCompression sets how the contents of files subsequently written
+// to a repo are compressed in object storage (see pfs.CompressionSpec). A nil
+// 'compression' disables compression for the repo.
+func (c APIClient) UpdateRepoCompression(repoName string, compression *pfs.CompressionSpec) error {
+	repoInfo, err := c.InspectRepo(repoName)
+	if err != nil {
+		return err
+	}
+	if compression == nil {
+		compression = &pfs.CompressionSpec{}
+	}
+	_, err = c.PfsAPIClient.CreateRepo(
+		c.Ctx(),
+		&pfs.CreateRepoRequest{
+			Repo:        NewRepo(repoName),
+			Description: repoInfo.Description,
+			Update:      true,
+			Compression: compression,
+		},
+	)
+	return grpcutil.ScrubGRPC(err)
+}
+
+// UpdateRepoEncryption sets the key with which the contents of files
+// subsequently written to a repo are encrypted in object storage (see
+// pfs.EncryptionSpec). An empty 'key' disables encryption for the repo.
+func (c APIClient) UpdateRepoEncryption(repoName string, key string) error {
+	repoInfo, err := c.InspectRepo(repoName)
+	if err != nil {
+		return err
+	}
+	_, err = c.PfsAPIClient.CreateRepo(
+		c.Ctx(),
+		&pfs.CreateRepoRequest{
+			Repo:        NewRepo(repoName),
+			Description: repoInfo.Description,
+			Update:      true,
+			Encryption:  &pfs.EncryptionSpec{Key: key},
+		},
+	)
+	return grpcutil.ScrubGRPC(err)
+}
+
+// SetRepoQuota sets the quota of a repo, which limits the data that can be
+// written to it (see pfs.RepoQuota). A nil 'quota' removes the repo's quota.
+// Only cluster admins may set quotas.
+func (c APIClient) SetRepoQuota(repoName string, quota *pfs.RepoQuota) error {
+	_, err := c.PfsAPIClient.SetRepoQuota(
+		c.Ctx(),
+		&pfs.SetRepoQuotaRequest{
+			Repo:  NewRepo(repoName),
+			Quota: quota,
+		},
+	)
+	return grpcutil.ScrubGRPC(err)
+}
+
+// SetRepoSchema sets the schema of a repo, which the files written to it are
+// validated against (see pfs.RepoSchema). A nil 'schema' removes the repo's
+// schema. Only the repo's owners may set its schema.
+func (c APIClient) SetRepoSchema(repoName string, schema *pfs.RepoSchema) error {
+	_, err := c.PfsAPIClient.SetRepoSchema(
+		c.Ctx(),
+		&pfs.SetRepoSchemaRequest{
+			Repo:   NewRepo(repoName),
+			Schema: schema,
+		},
+	)
+	return grpcutil.ScrubGRPC(err)
+}
+
+// InspectStorage reports how much object storage PFS uses, broken down by
+// repo (see pfs.StorageInfo). If 'repoNames' are given, the breakdown only
+// includes those repos. Only cluster admins may call it.
+func (c APIClient) InspectStorage(repoNames ...string) (*pfs.StorageInfo, error) {
+	request := &pfs.InspectStorageRequest{}
+	for _, repoName := range repoNames {
+		request.Repos = append(request.Repos, NewRepo(repoName))
+	}
+	storageInfo, err := c.PfsAPIClient.InspectStorage(c.Ctx(), request)
+	if err != nil {
+		return nil, grpcutil.ScrubGRPC(err)
+	}
+	return storageInfo, nil
+}
+
 // InspectRepo returns info about a specific Repo.
 func (c APIClient) InspectRepo(repoName string) (*pfs.RepoInfo, error) {
 	resp, err := c.PfsAPIClient.InspectRepo(
@@ -152,6 +269,24 @@ func (c APIClient) StartCommit(repoName string, branch string) (*pfs.Commit, err
 	return commit, nil
 }
 
+// StartCommitWithMetadata is like StartCommit, but attaches 'metadata' (e.g.
+// labels or annotations) to the new commit. It's returned in the commit's
+// CommitInfo, and commits can be filtered by it with ListCommitWithMetadata.
+func (c APIClient) StartCommitWithMetadata(repoName string, branch string, metadata map[string]string) (*pfs.Commit, error) {
+	commit, err := c.PfsAPIClient.StartCommit(
+		c.Ctx(),
+		&pfs.StartCommitRequest{
+			Parent:   NewCommit(repoName, ""),
+			Branch:   branch,
+			Metadata: metadata,
+		},
+	)
+	if err != nil {
+		return nil, grpcutil.ScrubGRPC(err)
+	}
+	return commit, nil
+}
+
 // BuildCommit builds a commit in a single call from an existing HashTree that
 // has already been written to the object store. Note this is a more advanced
 // pattern for creating commits that's mostly used internally.
@@ -216,6 +351,19 @@ func (c APIClient) FinishCommit(repoName string, commitID string) error {
 	return grpcutil.ScrubGRPC(err)
 }
 
+// FinishCommitWithMetadata is like FinishCommit, but also adds 'metadata' to
+// the commit's metadata, replacing the values of any keys it already has.
+func (c APIClient) FinishCommitWithMetadata(repoName string, commitID string, metadata map[string]string) error {
+	_, err := c.PfsAPIClient.FinishCommit(
+		c.Ctx(),
+		&pfs.FinishCommitRequest{
+			Commit:   NewCommit(repoName, commitID),
+			Metadata: metadata,
+		},
+	)
+	return grpcutil.ScrubGRPC(err)
+}
+
 // InspectCommit returns info about a specific Commit.
 func (c APIClient) InspectCommit(repoName string, commitID string) (*pfs.CommitInfo, error) {
 	return c.inspectCommit(repoName, commitID, pfs.CommitState_STARTED)
@@ -269,9 +417,30 @@ func (c APIClient) ListCommit(repoName string, to string, from string, number ui
 // `number` determines how many commits are returned.  If `number` is 0,
 // all commits that match the aforementioned criteria are returned.
 func (c APIClient) ListCommitF(repoName string, to string, from string, number uint64, f func(*pfs.CommitInfo) error) error {
+	return c.listCommitF(repoName, to, from, number, nil, f)
+}
+
+// ListCommitWithMetadata is like ListCommit, but only returns the commits
+// whose metadata contains every key/value pair in 'metadata'. `number` limits
+// the number of matching commits returned.
+func (c APIClient) ListCommitWithMetadata(repoName string, to string, from string, number uint64, metadata map[string]string) ([]*pfs.CommitInfo, error) {
+	var result []*pfs.CommitInfo
+	if err := c.listCommitF(repoName, to, from, number, metadata, func(ci *pfs.CommitInfo) error {
+		result = append(result, ci)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c APIClient) listCommitF(repoName string, to string, from string, number uint64, metadata map[string]string, f func(*pfs.CommitInfo) error) error {
+	ctx, cancel := context.WithCancel(c.Ctx())
+	defer cancel()
 	req := &pfs.ListCommitRequest{
-		Repo:   NewRepo(repoName),
-		Number: number,
+		Repo:     NewRepo(repoName),
+		Number:   number,
+		Metadata: metadata,
 	}
 	if from != "" {
 		req.From = NewCommit(repoName, from)
@@ -279,7 +448,7 @@ func (c APIClient) ListCommitF(repoName string, to string, from string, number u
 	if to != "" {
 		req.To = NewCommit(repoName, to)
 	}
-	stream, err := c.PfsAPIClient.ListCommitStream(c.Ctx(), req)
+	stream, err := c.PfsAPIClient.ListCommitStream(ctx, req)
 	if err != nil {
 		return grpcutil.ScrubGRPC(err)
 	}
@@ -322,6 +491,26 @@ func (c APIClient) CreateBranch(repoName string, branch string, commit string, p
 	return grpcutil.ScrubGRPC(err)
 }
 
+// CreateBranchTrigger creates or updates a branch (like CreateBranch, with no
+// provenance) whose head is moved to the head of another branch once
+// 'trigger's conditions are met (see pfs.Trigger). A nil 'trigger' removes
+// the branch's trigger.
+func (c APIClient) CreateBranchTrigger(repoName string, branch string, commit string, trigger *pfs.Trigger) error {
+	var head *pfs.Commit
+	if commit != "" {
+		head = NewCommit(repoName, commit)
+	}
+	_, err := c.PfsAPIClient.CreateBranch(
+		c.Ctx(),
+		&pfs.CreateBranchRequest{
+			Branch:  NewBranch(repoName, branch),
+			Head:    head,
+			Trigger: trigger,
+		},
+	)
+	return grpcutil.ScrubGRPC(err)
+}
+
 // InspectBranch returns information on a specific PFS branch
 func (c APIClient) InspectBranch(repoName string, branch string) (*pfs.BranchInfo, error) {
 	branchInfo, err := c.PfsAPIClient.InspectBranch(
@@ -353,6 +542,21 @@ func (c APIClient) SetBranch(repoName string, commit string, branch string) erro
 	return c.CreateBranch(repoName, branch, commit, nil)
 }
 
+// SetBranchProtection sets the protection of a branch, which restricts the
+// changes that users can make to it (see pfs.BranchProtection). A nil
+// 'protection' removes the branch's protection. Setting a branch's
+// protection requires the OWNER scope on its repo.
+func (c APIClient) SetBranchProtection(repoName string, branch string, protection *pfs.BranchProtection) error {
+	_, err := c.PfsAPIClient.SetBranchProtection(
+		c.Ctx(),
+		&pfs.SetBranchProtectionRequest{
+			Branch:     NewBranch(repoName, branch),
+			Protection: protection,
+		},
+	)
+	return grpcutil.ScrubGRPC(err)
+}
+
 // DeleteBranch deletes a branch, but leaves the commits themselves intact.
 // In other words, those commits can still be accessed via commit IDs and
 // other branches they happen to be on.
@@ -367,6 +571,37 @@ func (c APIClient) DeleteBranch(repoName string, branch string, force bool) erro
 	return grpcutil.ScrubGRPC(err)
 }
 
+// DeleteBranchOptions are the options of DeleteBranchWithOptions
+type DeleteBranchOptions struct {
+	// Force is as in DeleteBranch
+	Force bool
+	// Cascade, if set, also deletes the branches that are provenant on the
+	// branch, rather than failing if there are any
+	Cascade bool
+	// DeleteCommits, if set, also deletes the commits that are only reachable
+	// from the deleted branches (and their downstream commits)
+	DeleteCommits bool
+}
+
+// DeleteBranchWithOptions is like DeleteBranch, but can also delete the
+// branches downstream of the branch, and the commits that only the deleted
+// branches reach. 'opts' may be nil.
+func (c APIClient) DeleteBranchWithOptions(repoName string, branch string, opts *DeleteBranchOptions) error {
+	if opts == nil {
+		opts = &DeleteBranchOptions{}
+	}
+	_, err := c.PfsAPIClient.DeleteBranch(
+		c.Ctx(),
+		&pfs.DeleteBranchRequest{
+			Branch:        NewBranch(repoName, branch),
+			Force:         opts.Force,
+			Cascade:       opts.Cascade,
+			DeleteCommits: opts.DeleteCommits,
+		},
+	)
+	return grpcutil.ScrubGRPC(err)
+}
+
 // DeleteCommit deletes a commit.
 // Note it is currently not implemented.
 func (c APIClient) DeleteCommit(repoName string, commitID string) error {
@@ -379,6 +614,59 @@ func (c APIClient) DeleteCommit(repoName string, commitID string) error {
 	return grpcutil.ScrubGRPC(err)
 }
 
+// SquashCommit collapses the commits from 'fromCommitID' through
+// 'toCommitID' (usually a branch) into one commit: the commit 'toCommitID'
+// keeps its files, but the other commits in the range are deleted, and the
+// parent of 'fromCommitID' becomes its parent. 'fromCommitID' must be an
+// ancestor of 'toCommitID', and the commits in the range must be finished
+// input commits that aren't the head of a branch or the parent of commits
+// outside the range. The deleted commits' data is freed by the next garbage
+// collection.
+func (c APIClient) SquashCommit(repoName string, fromCommitID string, toCommitID string) error {
+	_, err := c.PfsAPIClient.SquashCommit(
+		c.Ctx(),
+		&pfs.SquashCommitRequest{
+			From: NewCommit(repoName, fromCommitID),
+			To:   NewCommit(repoName, toCommitID),
+		},
+	)
+	return grpcutil.ScrubGRPC(err)
+}
+
+// ProvenanceQueryOptions are the options of ProvenanceQuery
+type ProvenanceQueryOptions struct {
+	// Direction is the direction in which the query walks from the commit
+	// (upstream by default)
+	Direction pfs.ProvenanceDirection
+	// MaxDepth, if set, limits how many provenance relationships the query
+	// follows from the commit
+	MaxDepth int64
+	// Repos, if set, limits the result to commits in these repos
+	Repos []string
+}
+
+// ProvenanceQuery returns the commits upstream and/or downstream of a
+// commit, and the direct provenance relationships between them as a list of
+// edges. 'opts' may be nil.
+func (c APIClient) ProvenanceQuery(repoName string, commitID string, opts *ProvenanceQueryOptions) (*pfs.ProvenanceQueryResponse, error) {
+	if opts == nil {
+		opts = &ProvenanceQueryOptions{}
+	}
+	request := &pfs.ProvenanceQueryRequest{
+		Commit:    NewCommit(repoName, commitID),
+		Direction: opts.Direction,
+		MaxDepth:  opts.MaxDepth,
+	}
+	for _, repo := range opts.Repos {
+		request.Repos = append(request.Repos, NewRepo(repo))
+	}
+	response, err := c.PfsAPIClient.ProvenanceQuery(c.Ctx(), request)
+	if err != nil {
+		return nil, grpcutil.ScrubGRPC(err)
+	}
+	return response, nil
+}
+
 // FlushCommit returns an iterator that returns commits that have the
 // specified `commits` as provenance.  Note that the iterator can block if
 // jobs have not successfully completed. This in effect waits for all of the
@@ -406,6 +694,12 @@ func (c APIClient) FlushCommit(commits []*pfs.Commit, toRepos []*pfs.Repo) (Comm
 	return &commitInfoIterator{stream, cancel}, nil
 }
 
+// FlushCommitCtx is like FlushCommit, but the stream uses ctx, so it's closed
+// when ctx is cancelled or its deadline passes.
+func (c APIClient) FlushCommitCtx(ctx context.Context, commits []*pfs.Commit, toRepos []*pfs.Repo) (CommitInfoIterator, error) {
+	return c.WithCtx(ctx).FlushCommit(commits, toRepos)
+}
+
 // FlushCommitF calls f with commits that have the specified `commits` as
 // provenance. Note that it can block if jobs have not successfully
 // completed. This in effect waits for all of the jobs that are triggered by a
@@ -418,8 +712,10 @@ func (c APIClient) FlushCommit(commits []*pfs.Commit, toRepos []*pfs.Repo) (Comm
 // no matter what, FlushCommit just allows you to wait for them to complete and
 // see their output once they do.
 func (c APIClient) FlushCommitF(commits []*pfs.Commit, toRepos []*pfs.Repo, f func(*pfs.CommitInfo) error) error {
+	ctx, cancel := context.WithCancel(c.Ctx())
+	defer cancel()
 	stream, err := c.PfsAPIClient.FlushCommit(
-		c.Ctx(),
+		ctx,
 		&pfs.FlushCommitRequest{
 			Commits: commits,
 			ToRepos: toRepos,
@@ -442,6 +738,12 @@ func (c APIClient) FlushCommitF(commits []*pfs.Commit, toRepos []*pfs.Repo, f fu
 	}
 }
 
+// FlushCommitFCtx is like FlushCommitF, but it uses ctx for the request, so it
+// returns an error when ctx is cancelled or its deadline passes.
+func (c APIClient) FlushCommitFCtx(ctx context.Context, commits []*pfs.Commit, toRepos []*pfs.Repo, f func(*pfs.CommitInfo) error) error {
+	return c.WithCtx(ctx).FlushCommitF(commits, toRepos, f)
+}
+
 // CommitInfoIterator wraps a stream of commits and makes them easy to iterate.
 type CommitInfoIterator interface {
 	Next() (*pfs.CommitInfo, error)
@@ -489,9 +791,17 @@ func (c APIClient) SubscribeCommit(repo string, branch string, from string, stat
 	return &commitInfoIterator{stream, cancel}, nil
 }
 
+// SubscribeCommitCtx is like SubscribeCommit, but the stream uses ctx, so it's
+// closed when ctx is cancelled or its deadline passes.
+func (c APIClient) SubscribeCommitCtx(ctx context.Context, repo string, branch string, from string, state pfs.CommitState) (CommitInfoIterator, error) {
+	return c.WithCtx(ctx).SubscribeCommit(repo, branch, from, state)
+}
+
 // SubscribeCommitF is like ListCommit but it calls a callback function with
 // the results rather than returning an iterator.
 func (c APIClient) SubscribeCommitF(repo, branch, from string, state pfs.CommitState, f func(*pfs.CommitInfo) error) error {
+	ctx, cancel := context.WithCancel(c.Ctx())
+	defer cancel()
 	req := &pfs.SubscribeCommitRequest{
 		Repo:   NewRepo(repo),
 		Branch: branch,
@@ -500,7 +810,7 @@ func (c APIClient) SubscribeCommitF(repo, branch, from string, state pfs.CommitS
 	if from != "" {
 		req.From = NewCommit(repo, from)
 	}
-	stream, err := c.PfsAPIClient.SubscribeCommit(c.Ctx(), req)
+	stream, err := c.PfsAPIClient.SubscribeCommit(ctx, req)
 	if err != nil {
 		return grpcutil.ScrubGRPC(err)
 	}
@@ -515,6 +825,36 @@ func (c APIClient) SubscribeCommitF(repo, branch, from string, state pfs.CommitS
 	}
 }
 
+// SubscribeCommitFCtx is like SubscribeCommitF, but it uses ctx for the
+// request, so it returns an error when ctx is cancelled or its deadline passes.
+func (c APIClient) SubscribeCommitFCtx(ctx context.Context, repo, branch, from string, state pfs.CommitState, f func(*pfs.CommitInfo) error) error {
+	return c.WithCtx(ctx).SubscribeCommitF(repo, branch, from, state, f)
+}
+
+// SubscribeCommits is like SubscribeCommit, but returns the commits on
+// 'branch' in several repos in one stream: the repos in 'repos' and those
+// whose names match the glob 'pattern' (if it's set), including repos
+// created later. The branches' existing commits are returned first, oldest
+// first across all of the repos, unless 'newOnly' is set.
+func (c APIClient) SubscribeCommits(repos []string, pattern string, branch string, newOnly bool, state pfs.CommitState) (CommitInfoIterator, error) {
+	ctx, cancel := context.WithCancel(c.Ctx())
+	req := &pfs.SubscribeCommitsRequest{
+		Pattern: pattern,
+		Branch:  branch,
+		State:   state,
+		New:     newOnly,
+	}
+	for _, repo := range repos {
+		req.Repos = append(req.Repos, NewRepo(repo))
+	}
+	stream, err := c.PfsAPIClient.SubscribeCommits(ctx, req)
+	if err != nil {
+		cancel()
+		return nil, grpcutil.ScrubGRPC(err)
+	}
+	return &commitInfoIterator{stream, cancel}, nil
+}
+
 // PutObjectAsync puts a value into the object store asynchronously.
 func (c APIClient) PutObjectAsync(tags []*pfs.Tag) (*PutObjectWriteCloserAsync, error) {
 	w, err := c.newPutObjectWriteCloserAsync(tags)
@@ -526,8 +866,22 @@ func (c APIClient) PutObjectAsync(tags []*pfs.Tag) (*PutObjectWriteCloserAsync,
 
 // PutObject puts a value into the object store and tags it with 0 or more tags.
 func (c APIClient) PutObject(_r io.Reader, tags ...string) (object *pfs.Object, _ int64, retErr error) {
+	return c.PutObjectCompressed(_r, nil, tags...)
+}
+
+// PutObjectCompressed is like PutObject, but asks pachd to compress the
+// object in object storage as described by compression. If compression is
+// nil, pachd doesn't compress the object.
+func (c APIClient) PutObjectCompressed(_r io.Reader, compression *pfs.CompressionSpec, tags ...string) (object *pfs.Object, _ int64, retErr error) {
+	return c.PutObjectEncrypted(_r, compression, nil, tags...)
+}
+
+// PutObjectEncrypted is like PutObjectCompressed, but also asks pachd to
+// encrypt the object in object storage as described by encryption. If
+// encryption is nil, pachd doesn't encrypt the object.
+func (c APIClient) PutObjectEncrypted(_r io.Reader, compression *pfs.CompressionSpec, encryption *pfs.EncryptionSpec, tags ...string) (object *pfs.Object, _ int64, retErr error) {
 	r := grpcutil.ReaderWrapper{_r}
-	w, err := c.newPutObjectWriteCloser(tags...)
+	w, err := c.newPutObjectWriteCloser(compression, encryption, tags...)
 	if err != nil {
 		return nil, 0, grpcutil.ScrubGRPC(err)
 	}
@@ -552,9 +906,28 @@ func (c APIClient) PutObject(_r io.Reader, tags ...string) (object *pfs.Object,
 // PutObjectSplit is the same as PutObject except that the data is splitted
 // into several smaller objects.  This is primarily useful if you'd like to
 // be able to resume upload.
-func (c APIClient) PutObjectSplit(_r io.Reader) (objects []*pfs.Object, _ int64, retErr error) {
+func (c APIClient) PutObjectSplit(_r io.Reader) ([]*pfs.Object, int64, error) {
+	objects, written, err := c.PutObjectSplitCompressed(_r, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	return objects.Objects, written, nil
+}
+
+// PutObjectSplitCompressed is like PutObjectSplit, but asks pachd to
+// compress the objects in object storage as described by compression. If
+// compression is nil, pachd doesn't compress the objects. It also returns
+// the size of each object, and whether it was already in object storage.
+func (c APIClient) PutObjectSplitCompressed(_r io.Reader, compression *pfs.CompressionSpec) (*pfs.Objects, int64, error) {
+	return c.PutObjectSplitEncrypted(_r, compression, nil)
+}
+
+// PutObjectSplitEncrypted is like PutObjectSplitCompressed, but also asks
+// pachd to encrypt the objects in object storage as described by encryption.
+// If encryption is nil, pachd doesn't encrypt the objects.
+func (c APIClient) PutObjectSplitEncrypted(_r io.Reader, compression *pfs.CompressionSpec, encryption *pfs.EncryptionSpec) (objects *pfs.Objects, _ int64, retErr error) {
 	r := grpcutil.ReaderWrapper{_r}
-	w, err := c.newPutObjectSplitWriteCloser()
+	w, err := c.newPutObjectSplitWriteCloser(compression, encryption)
 	if err != nil {
 		return nil, 0, grpcutil.ScrubGRPC(err)
 	}
@@ -666,7 +1039,9 @@ func (c APIClient) TagObject(hash string, tags ...string) error {
 
 // ListObject lists objects stored in pfs.
 func (c APIClient) ListObject(f func(*pfs.Object) error) error {
-	listObjectClient, err := c.ObjectAPIClient.ListObjects(c.Ctx(), &pfs.ListObjectsRequest{})
+	ctx, cancel := context.WithCancel(c.Ctx())
+	defer cancel()
+	listObjectClient, err := c.ObjectAPIClient.ListObjects(ctx, &pfs.ListObjectsRequest{})
 	if err != nil {
 		return grpcutil.ScrubGRPC(err)
 	}
@@ -735,7 +1110,9 @@ func (c APIClient) ReadTag(tag string) ([]byte, error) {
 
 // ListTag lists tags stored in pfs.
 func (c APIClient) ListTag(f func(*pfs.ListTagsResponse) error) error {
-	listTagClient, err := c.ObjectAPIClient.ListTags(c.Ctx(), &pfs.ListTagsRequest{IncludeObject: true})
+	ctx, cancel := context.WithCancel(c.Ctx())
+	defer cancel()
+	listTagClient, err := c.ObjectAPIClient.ListTags(ctx, &pfs.ListTagsRequest{IncludeObject: true})
 	if err != nil {
 		return grpcutil.ScrubGRPC(err)
 	}
@@ -790,20 +1167,32 @@ type PutFileClient interface {
 	// delimiter is used to tell PFS how to break the input into blocks.
 	PutFileSplit(repoName string, commitID string, path string, delimiter pfs.Delimiter, targetFileDatums int64, targetFileBytes int64, headerRecords int64, overwrite bool, reader io.Reader) (_ int, retErr error)
 
+	// PutFileAppendRecords appends the data in reader to a single file, after
+	// pachd has checked that it consists of complete records delimited by
+	// delimiter (see PutFileRequest.AppendRecords).
+	PutFileAppendRecords(repoName string, commitID string, path string, delimiter pfs.Delimiter, reader io.Reader) (_ int, retErr error)
+
 	// PutFileURL puts a file using the content found at a URL.
 	// The URL is sent to the server which performs the request.
 	// recursive allows for recursive scraping of some types URLs. For example on s3:// urls.
 	PutFileURL(repoName string, commitID string, path string, url string, recursive bool, overwrite bool) error
 
+	// PutFileTar writes the regular files in the tar archive read from reader
+	// under path, preserving their paths and modes. The archive is expanded
+	// by pachd (see PutFileRequest.Tar). If overwrite is true, the files are
+	// overwritten rather than appended to.
+	PutFileTar(repoName string, commitID string, path string, reader io.Reader, overwrite bool) (_ int, retErr error)
+
 	// Close must be called after you're done using a PutFileClient.
 	// Further requests will throw errors.
 	Close() error
 }
 
 type putFileClient struct {
-	c      pfs.API_PutFileClient
-	mu     sync.Mutex
-	oneoff bool // indicates a one time use putFileClient
+	c        pfs.API_PutFileClient
+	mu       sync.Mutex
+	oneoff   bool // indicates a one time use putFileClient
+	progress *progressTracker
 }
 
 // NewPutFileClient returns a new client for putting files into pfs in a single request.
@@ -812,15 +1201,15 @@ func (c APIClient) NewPutFileClient() (PutFileClient, error) {
 	if err != nil {
 		return nil, grpcutil.ScrubGRPC(err)
 	}
-	return &putFileClient{c: pfc}, nil
+	return &putFileClient{c: pfc, progress: c.progress}, nil
 }
 
-func (c APIClient) newOneoffPutFileClient() (PutFileClient, error) {
+func (c APIClient) newOneoffPutFileClient() (*putFileClient, error) {
 	pfc, err := c.PfsAPIClient.PutFile(c.Ctx())
 	if err != nil {
 		return nil, grpcutil.ScrubGRPC(err)
 	}
-	return &putFileClient{c: pfc, oneoff: true}, nil
+	return &putFileClient{c: pfc, oneoff: true, progress: c.progress}, nil
 }
 
 // PutFileWriter writes a file to PFS.
@@ -858,6 +1247,7 @@ func (c *putFileClient) PutFileOverwrite(repoName string, commitID string, path
 	if err != nil {
 		return 0, grpcutil.ScrubGRPC(err)
 	}
+	writer.totalBytes = readerSize(reader)
 	defer func() {
 		if err := writer.Close(); err != nil && retErr == nil {
 			retErr = err
@@ -870,10 +1260,15 @@ func (c *putFileClient) PutFileOverwrite(repoName string, commitID string, path
 //PutFileSplit writes a file to PFS from a reader
 // delimiter is used to tell PFS how to break the input into blocks
 func (c *putFileClient) PutFileSplit(repoName string, commitID string, path string, delimiter pfs.Delimiter, targetFileDatums int64, targetFileBytes int64, headerRecords int64, overwrite bool, reader io.Reader) (_ int, retErr error) {
-	writer, err := c.PutFileSplitWriter(repoName, commitID, path, delimiter, targetFileDatums, targetFileBytes, headerRecords, overwrite)
+	var overwriteIndex *pfs.OverwriteIndex
+	if overwrite {
+		overwriteIndex = &pfs.OverwriteIndex{}
+	}
+	writer, err := c.newPutFileWriteCloser(repoName, commitID, path, delimiter, targetFileDatums, targetFileBytes, headerRecords, overwriteIndex)
 	if err != nil {
 		return 0, grpcutil.ScrubGRPC(err)
 	}
+	writer.totalBytes = readerSize(reader)
 	defer func() {
 		if err := writer.Close(); err != nil && retErr == nil {
 			retErr = err
@@ -885,15 +1280,42 @@ func (c *putFileClient) PutFileSplit(repoName string, commitID string, path stri
 	return int(written), grpcutil.ScrubGRPC(err)
 }
 
+// PutFileAppendRecords appends the data in reader to a single file, after
+// pachd has checked that it consists of complete records delimited by
+// delimiter (see PutFileRequest.AppendRecords).
+func (c *putFileClient) PutFileAppendRecords(repoName string, commitID string, path string, delimiter pfs.Delimiter, reader io.Reader) (_ int, retErr error) {
+	writer, err := c.newPutFileWriteCloser(repoName, commitID, path, delimiter, 0, 0, 0, nil)
+	if err != nil {
+		return 0, grpcutil.ScrubGRPC(err)
+	}
+	writer.request.AppendRecords = true
+	writer.totalBytes = readerSize(reader)
+	defer func() {
+		if err := writer.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	written, err := io.Copy(writer, reader)
+	return int(written), grpcutil.ScrubGRPC(err)
+}
+
 // PutFileURL puts a file using the content found at a URL.
 // The URL is sent to the server which performs the request.
 // recursive allow for recursive scraping of some types URLs for example on s3:// urls.
 func (c *putFileClient) PutFileURL(repoName string, commitID string, path string, url string, recursive bool, overwrite bool) (retErr error) {
+	return c.putFileURL(&pfs.PutFileRequest{
+		File:      NewFile(repoName, commitID, path),
+		Url:       url,
+		Recursive: recursive,
+	}, overwrite)
+}
+
+// putFileURL sends 'request', which must have a URL
+func (c *putFileClient) putFileURL(request *pfs.PutFileRequest, overwrite bool) (retErr error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	var overwriteIndex *pfs.OverwriteIndex
 	if overwrite {
-		overwriteIndex = &pfs.OverwriteIndex{}
+		request.OverwriteIndex = &pfs.OverwriteIndex{}
 	}
 	if c.oneoff {
 		defer func() {
@@ -902,17 +1324,38 @@ func (c *putFileClient) PutFileURL(repoName string, commitID string, path string
 			}
 		}()
 	}
-	if err := c.c.Send(&pfs.PutFileRequest{
-		File:           NewFile(repoName, commitID, path),
-		Url:            url,
-		Recursive:      recursive,
-		OverwriteIndex: overwriteIndex,
-	}); err != nil {
+	if err := c.c.Send(request); err != nil {
 		return grpcutil.ScrubGRPC(err)
 	}
 	return nil
 }
 
+// PutFileTar writes the regular files in the tar archive read from reader
+// under path, preserving their paths and modes. The archive is expanded by
+// pachd (see PutFileRequest.Tar). If overwrite is true, the files are
+// overwritten rather than appended to.
+func (c *putFileClient) PutFileTar(repoName string, commitID string, path string, reader io.Reader, overwrite bool) (_ int, retErr error) {
+	var overwriteIndex *pfs.OverwriteIndex
+	if overwrite {
+		overwriteIndex = &pfs.OverwriteIndex{}
+	}
+	writer, err := c.newPutFileWriteCloser(repoName, commitID, path, pfs.Delimiter_NONE, 0, 0, 0, overwriteIndex)
+	if err != nil {
+		return 0, grpcutil.ScrubGRPC(err)
+	}
+	writer.request.Tar = true
+	writer.totalBytes = readerSize(reader)
+	defer func() {
+		if err := writer.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	buf := grpcutil.GetBuffer()
+	defer grpcutil.PutBuffer(buf)
+	written, err := io.CopyBuffer(writer, reader, buf)
+	return int(written), grpcutil.ScrubGRPC(err)
+}
+
 // Close must be called after you're done using a putFileClient.
 // Further requests will throw errors.
 func (c *putFileClient) Close() error {
@@ -953,6 +1396,12 @@ func (c APIClient) PutFile(repoName string, commitID string, path string, reader
 	return pfc.PutFile(repoName, commitID, path, reader)
 }
 
+// PutFileCtx is like PutFile, but it uses ctx for the request, so the upload
+// is aborted when ctx is cancelled or its deadline passes.
+func (c APIClient) PutFileCtx(ctx context.Context, repoName string, commitID string, path string, reader io.Reader) (int, error) {
+	return c.WithCtx(ctx).PutFile(repoName, commitID, path, reader)
+}
+
 // PutFileOverwrite is like PutFile but it overwrites the file rather than
 // appending to it.  overwriteIndex allows you to specify the index of the
 // object starting from which you'd like to overwrite.  If you want to
@@ -965,6 +1414,30 @@ func (c APIClient) PutFileOverwrite(repoName string, commitID string, path strin
 	return pfc.PutFileOverwrite(repoName, commitID, path, reader, overwriteIndex)
 }
 
+// PutFileWithMetadata is like PutFile, but also adds 'metadata' (e.g. labels
+// or annotations) to the file's metadata, replacing the values of any keys it
+// already has. The file's metadata is returned in its FileInfo, and is
+// removed if the file is deleted or overwritten.
+func (c APIClient) PutFileWithMetadata(repoName string, commitID string, path string, reader io.Reader, metadata map[string]string) (_ int, retErr error) {
+	pfc, err := c.newOneoffPutFileClient()
+	if err != nil {
+		return 0, err
+	}
+	writer, err := pfc.newPutFileWriteCloser(repoName, commitID, path, pfs.Delimiter_NONE, 0, 0, 0, nil)
+	if err != nil {
+		return 0, grpcutil.ScrubGRPC(err)
+	}
+	writer.request.Metadata = metadata
+	writer.totalBytes = readerSize(reader)
+	defer func() {
+		if err := writer.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	written, err := io.Copy(writer, reader)
+	return int(written), grpcutil.ScrubGRPC(err)
+}
+
 //PutFileSplit writes a file to PFS from a reader
 // delimiter is used to tell PFS how to break the input into blocks
 func (c APIClient) PutFileSplit(repoName string, commitID string, path string, delimiter pfs.Delimiter, targetFileDatums int64, targetFileBytes int64, headerRecords int64, overwrite bool, reader io.Reader) (_ int, retErr error) {
@@ -976,6 +1449,18 @@ func (c APIClient) PutFileSplit(repoName string, commitID string, path string, d
 	return pfc.PutFileSplit(repoName, commitID, path, delimiter, targetFileDatums, targetFileBytes, headerRecords, overwrite, reader)
 }
 
+// PutFileAppendRecords appends the data in reader to a single file, after
+// pachd has checked that it consists of complete records delimited by
+// delimiter, so that a streaming producer can append to the file without
+// corrupting its record boundaries (see PutFileRequest.AppendRecords).
+func (c APIClient) PutFileAppendRecords(repoName string, commitID string, path string, delimiter pfs.Delimiter, reader io.Reader) (_ int, retErr error) {
+	pfc, err := c.newOneoffPutFileClient()
+	if err != nil {
+		return 0, err
+	}
+	return pfc.PutFileAppendRecords(repoName, commitID, path, delimiter, reader)
+}
+
 // PutFileURL puts a file using the content found at a URL.
 // The URL is sent to the server which performs the request.
 // recursive allow for recursive scraping of some types URLs for example on s3:// urls.
@@ -987,8 +1472,23 @@ func (c APIClient) PutFileURL(repoName string, commitID string, path string, url
 	return pfc.PutFileURL(repoName, commitID, path, url, recursive, overwrite)
 }
 
+// PutFileTar writes the regular files in the tar archive read from reader
+// under path, preserving their paths and modes. The archive is expanded by
+// pachd, so a directory tree can be written without a request per file. If
+// overwrite is true, the files are overwritten rather than appended to.
+func (c APIClient) PutFileTar(repoName string, commitID string, path string, reader io.Reader, overwrite bool) (_ int, retErr error) {
+	pfc, err := c.newOneoffPutFileClient()
+	if err != nil {
+		return 0, err
+	}
+	return pfc.PutFileTar(repoName, commitID, path, reader, overwrite)
+}
+
 // CopyFile copys a file from one pfs location to another. It can be used on
-// directories or regular files.
+// directories or regular files, and the source and destination may be in
+// different repos. The copy is done by pachd, and refers to the same stored
+// data as the source rather than copying it, so it's cheap even for large
+// files. If 'dstCommit' is a branch, the copy is made in a new commit on it.
 func (c APIClient) CopyFile(srcRepo, srcCommit, srcPath, dstRepo, dstCommit, dstPath string, overwrite bool) error {
 	if _, err := c.PfsAPIClient.CopyFile(c.Ctx(),
 		&pfs.CopyFileRequest{
@@ -1001,6 +1501,34 @@ func (c APIClient) CopyFile(srcRepo, srcCommit, srcPath, dstRepo, dstCommit, dst
 	return nil
 }
 
+// MoveFile moves a file or directory from 'srcPath' to 'dstPath' in the open
+// commit 'commitID', overwriting anything at 'dstPath'. Like CopyFile, only
+// metadata is changed, so moving large files or directories is cheap. Readers
+// of the commit's branch see the move all at once, when the commit is
+// finished.
+func (c APIClient) MoveFile(repoName string, commitID string, srcPath string, dstPath string) error {
+	src, dst := path.Join("/", srcPath), path.Join("/", dstPath)
+	if src == dst {
+		return nil
+	}
+	if src == "/" || strings.HasPrefix(dst, src+"/") || strings.HasPrefix(src, dst+"/") || dst == "/" {
+		return fmt.Errorf("cannot move %s to %s, as one is inside the other", src, dst)
+	}
+	commitInfo, err := c.InspectCommit(repoName, commitID)
+	if err != nil {
+		return err
+	}
+	if commitInfo.Finished != nil {
+		return fmt.Errorf("cannot move %s to %s: commit %s is finished", src, dst, commitInfo.Commit.ID)
+	}
+	// use the commit's ID, in case 'commitID' is a branch whose head changes
+	commitID = commitInfo.Commit.ID
+	if err := c.CopyFile(repoName, commitID, src, repoName, commitID, dst, true); err != nil {
+		return err
+	}
+	return c.DeleteFile(repoName, commitID, src)
+}
+
 // GetFile returns the contents of a file at a specific Commit.
 // offset specifies a number of bytes that should be skipped in the beginning of the file.
 // size limits the total amount of data returned, note you will get fewer bytes
@@ -1011,6 +1539,21 @@ func (c APIClient) GetFile(repoName string, commitID string, path string, offset
 		c.limiter.Acquire()
 		defer c.limiter.Release()
 	}
+	var pw *progressWriter
+	if c.progress != nil {
+		pw = &progressWriter{w: writer, progress: c.progress, path: path, totalBytes: size}
+		if size == 0 {
+			fileInfo, err := c.InspectFile(repoName, commitID, path)
+			if err != nil {
+				return err
+			}
+			pw.totalBytes = int64(fileInfo.SizeBytes) - offset
+			if pw.totalBytes < 0 {
+				pw.totalBytes = 0
+			}
+		}
+		writer = pw
+	}
 	apiGetFileClient, err := c.getFile(repoName, commitID, path, offset, size)
 	if err != nil {
 		return grpcutil.ScrubGRPC(err)
@@ -1018,6 +1561,46 @@ func (c APIClient) GetFile(repoName string, commitID string, path string, offset
 	if err := grpcutil.WriteFromStreamingBytesClient(apiGetFileClient, writer); err != nil {
 		return grpcutil.ScrubGRPC(err)
 	}
+	if pw != nil {
+		c.progress.report(path, pw.bytes, pw.totalBytes, true)
+	}
+	return nil
+}
+
+// GetFileCtx is like GetFile, but it uses ctx for the request, so the download
+// is aborted when ctx is cancelled or its deadline passes.
+func (c APIClient) GetFileCtx(ctx context.Context, repoName string, commitID string, path string, offset int64, size int64, writer io.Writer) error {
+	return c.WithCtx(ctx).GetFile(repoName, commitID, path, offset, size, writer)
+}
+
+// GetFileVerified writes the contents of a file at a specific Commit to
+// 'writer', like GetFile, and checks that they match the checksums recorded
+// when the file was written (see FileInfo.ContentSha256), returning an error
+// if they don't. The contents are written to 'writer' before they're checked,
+// so if an error is returned, 'writer' may hold corrupt data. Files that have
+// no recorded checksums (e.g. files built by several PutFiles) can't be
+// verified, so GetFileVerified returns an error before reading them.
+func (c APIClient) GetFileVerified(repoName string, commitID string, path string, writer io.Writer) error {
+	fileInfo, err := c.InspectFile(repoName, commitID, path)
+	if err != nil {
+		return err
+	}
+	if fileInfo.FileType != pfs.FileType_FILE {
+		return fmt.Errorf("%s@%s:%s is not a regular file", repoName, commitID, path)
+	}
+	if fileInfo.ContentSha256 == nil || fileInfo.ContentMd5 == nil {
+		return fmt.Errorf("%s@%s:%s has no checksums to verify", repoName, commitID, path)
+	}
+	// read from the commit that was inspected, in case 'commitID' is a branch
+	sha256Hash, md5Hash := sha256.New(), md5.New()
+	if err := c.GetFile(repoName, fileInfo.File.Commit.ID, path, 0, 0,
+		io.MultiWriter(writer, sha256Hash, md5Hash)); err != nil {
+		return err
+	}
+	if !bytes.Equal(sha256Hash.Sum(nil), fileInfo.ContentSha256) ||
+		!bytes.Equal(md5Hash.Sum(nil), fileInfo.ContentMd5) {
+		return fmt.Errorf("the contents of %s@%s:%s don't match its checksums", repoName, fileInfo.File.Commit.ID, path)
+	}
 	return nil
 }
 
@@ -1034,26 +1617,187 @@ func (c APIClient) GetFileReader(repoName string, commitID string, path string,
 	return grpcutil.NewStreamingBytesReader(apiGetFileClient, nil), nil
 }
 
+// GetFileReaderCtx is like GetFileReader, but the returned reader's stream
+// uses ctx, so reads fail once ctx is cancelled or its deadline passes.
+func (c APIClient) GetFileReaderCtx(ctx context.Context, repoName string, commitID string, path string, offset int64, size int64) (io.Reader, error) {
+	return c.WithCtx(ctx).GetFileReader(repoName, commitID, path, offset, size)
+}
+
 // GetFileReadSeeker returns a reader for the contents of a file at a specific
-// Commit that permits Seeking to different points in the file.
+// Commit that permits Seeking to different points in the file (see
+// GetFileReaderAt).
 func (c APIClient) GetFileReadSeeker(repoName string, commitID string, path string) (io.ReadSeeker, error) {
-	fileInfo, err := c.InspectFile(repoName, commitID, path)
+	r, err := c.GetFileReaderAt(repoName, commitID, path)
 	if err != nil {
 		return nil, err
 	}
-	reader, err := c.GetFileReader(repoName, commitID, path, 0, 0)
+	return r, nil
+}
+
+// FileReaderChunkSize is the size of the ranges of a file that a FileReader
+// reads at once when it's read sequentially.
+const FileReaderChunkSize = 4 * 1024 * 1024
+
+// GetFileReaderAt returns a FileReader of the contents of a file at a specific
+// Commit. Nothing is read until the FileReader is, and then only the ranges
+// of the file that are read are fetched, so e.g. the footer of a large file
+// can be read without downloading the rest of it. If 'commitID' is a branch,
+// the file is read from the commit that the branch points to when
+// GetFileReaderAt is called.
+func (c APIClient) GetFileReaderAt(repoName string, commitID string, path string) (*FileReader, error) {
+	fileInfo, err := c.InspectFile(repoName, commitID, path)
 	if err != nil {
 		return nil, err
 	}
-	return &getFileReadSeeker{
-		Reader: reader,
-		file:   NewFile(repoName, commitID, path),
-		offset: 0,
-		size:   int64(fileInfo.SizeBytes),
-		c:      c,
+	if fileInfo.FileType != pfs.FileType_FILE {
+		return nil, fmt.Errorf("cannot read %s, as it is not a file", path)
+	}
+	if fileInfo.File != nil && fileInfo.File.Commit != nil {
+		commitID = fileInfo.File.Commit.ID // read every range from the same commit
+	}
+	return &FileReader{
+		c:    c,
+		file: NewFile(repoName, commitID, path),
+		size: int64(fileInfo.SizeBytes),
 	}, nil
 }
 
+// GetCommitArchive writes the files under 'path' in a commit to 'writer', as
+// a single archive in 'format' that pachd assembles, rather than reading each
+// file with GetFile. Paths in the archive are relative to the root of the
+// commit. If 'path' is empty, the whole commit is archived.
+func (c APIClient) GetCommitArchive(repoName string, commitID string, path string, format pfs.ArchiveFormat, writer io.Writer) error {
+	getCommitArchiveClient, err := c.PfsAPIClient.GetCommitArchive(
+		c.Ctx(),
+		&pfs.GetCommitArchiveRequest{
+			File:   NewFile(repoName, commitID, path),
+			Format: format,
+		},
+	)
+	if err != nil {
+		return grpcutil.ScrubGRPC(err)
+	}
+	if err := grpcutil.WriteFromStreamingBytesClient(getCommitArchiveClient, writer); err != nil {
+		return grpcutil.ScrubGRPC(err)
+	}
+	return nil
+}
+
+// GetCommitManifest writes a manifest of the files under 'path' in a commit
+// (their paths, sizes, hashes and metadata) to 'writer', in 'format'. If
+// 'path' is empty, every file in the commit is listed.
+func (c APIClient) GetCommitManifest(repoName string, commitID string, path string, format pfs.ManifestFormat, writer io.Writer) error {
+	getCommitManifestClient, err := c.PfsAPIClient.GetCommitManifest(
+		c.Ctx(),
+		&pfs.GetCommitManifestRequest{
+			File:   NewFile(repoName, commitID, path),
+			Format: format,
+		},
+	)
+	if err != nil {
+		return grpcutil.ScrubGRPC(err)
+	}
+	if err := grpcutil.WriteFromStreamingBytesClient(getCommitManifestClient, writer); err != nil {
+		return grpcutil.ScrubGRPC(err)
+	}
+	return nil
+}
+
+// GetFileURL returns a signed URL at which the file at 'path' in 'commitID'
+// can be downloaded over HTTP, without credentials, until 'ttl' has passed
+// (an hour if 'ttl' is 0; at most a week). This lets files be shared with
+// dashboards and with users who don't have pachctl. The URL is for the commit
+// that 'commitID' refers to when GetFileURL is called, even if 'commitID' is
+// a branch, and that commit must be finished (files in open commits may still
+// change, so GetFileURL returns an error for them).
+//
+// 'httpAddress' is the "host:port" at which pachd's HTTP server is reachable.
+// If it's empty, the host in this client's address and port 30652 (the port
+// on which pachd's HTTP server is exposed, and which 'pachctl port-forward'
+// forwards) are used.
+func (c APIClient) GetFileURL(repoName string, commitID string, path string, ttl time.Duration, httpAddress string) (string, error) {
+	if httpAddress == "" {
+		host, _, err := net.SplitHostPort(c.addr)
+		if err != nil {
+			return "", err
+		}
+		httpAddress = net.JoinHostPort(host, strconv.Itoa(pfsLocalPort))
+	}
+	resp, err := c.PfsAPIClient.GetFileURL(
+		c.Ctx(),
+		&pfs.GetFileURLRequest{
+			File: NewFile(repoName, commitID, path),
+			Ttl:  types.DurationProto(ttl),
+		},
+	)
+	if err != nil {
+		return "", grpcutil.ScrubGRPC(err)
+	}
+	return "http://" + httpAddress + resp.Path, nil
+}
+
+// DefaultGetFileParallelism is the number of concurrent reads that
+// GetFileParallel uses, if no parallelism is given.
+const DefaultGetFileParallelism = 8
+
+// GetFileParallel writes the contents of a file at a specific Commit to
+// 'writer', like GetFile, but fetches it in 'parallelism' ranges
+// (DefaultGetFileParallelism if 'parallelism' is 0) that are read
+// concurrently and written to their offsets in 'writer'. This is faster than
+// GetFile for large files, whose download by a single stream is limited by
+// latency or by CPU. 'writer' is typically an *os.File.
+//
+// If 'commitID' is a branch, all ranges are read from the commit that the
+// branch points to when GetFileParallel is called. If an error is returned,
+// 'writer' may hold part of the file.
+func (c APIClient) GetFileParallel(repoName string, commitID string, path string, parallelism int, writer io.WriterAt) error {
+	if parallelism <= 0 {
+		parallelism = DefaultGetFileParallelism
+	}
+	fileInfo, err := c.InspectFile(repoName, commitID, path)
+	if err != nil {
+		return err
+	}
+	if fileInfo.FileType != pfs.FileType_FILE {
+		return fmt.Errorf("cannot get %s, as it is not a file", path)
+	}
+	if fileInfo.File != nil && fileInfo.File.Commit != nil {
+		commitID = fileInfo.File.Commit.ID // read every range from the same commit
+	}
+	size := int64(fileInfo.SizeBytes)
+	rangeSize := (size + int64(parallelism) - 1) / int64(parallelism)
+	eg, ctx := errgroup.WithContext(c.Ctx())
+	pachClient := c.withoutProgress().WithCtx(ctx) // progress is reported below
+	var mu sync.Mutex
+	var received int64
+	for offset := int64(0); offset < size; offset += rangeSize {
+		offset, n := offset, rangeSize
+		if offset+n > size {
+			n = size - offset
+		}
+		eg.Go(func() error {
+			w := &offsetWriter{w: writer, offset: offset, onWrite: func(n int) {
+				mu.Lock()
+				defer mu.Unlock()
+				received += int64(n)
+				c.progress.report(path, received, size, false)
+			}}
+			if err := pachClient.GetFile(repoName, commitID, path, offset, n, w); err != nil {
+				return err
+			}
+			if w.offset != offset+n {
+				return fmt.Errorf("expected %d bytes at offset %d of %s, but got %d", n, offset, path, w.offset-offset)
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+	c.progress.report(path, size, size, true)
+	return nil
+}
+
 func (c APIClient) getFile(repoName string, commitID string, path string, offset int64,
 	size int64) (pfs.API_GetFileClient, error) {
 	return c.PfsAPIClient.GetFile(
@@ -1110,11 +1854,40 @@ func (c APIClient) ListFileHistory(repoName string, commitID string, path string
 
 // ListFileF returns info about all files in a Commit under path, calling f with each FileInfo.
 func (c APIClient) ListFileF(repoName string, commitID string, path string, history int64, f func(fi *pfs.FileInfo) error) error {
+	return c.ListFileFilteredF(repoName, commitID, path, history, nil, f)
+}
+
+// ListFileFCtx is like ListFileF, but it uses ctx for the request, so it
+// returns an error when ctx is cancelled or its deadline passes.
+func (c APIClient) ListFileFCtx(ctx context.Context, repoName string, commitID string, path string, history int64, f func(fi *pfs.FileInfo) error) error {
+	return c.WithCtx(ctx).ListFileF(repoName, commitID, path, history, f)
+}
+
+// ListFileFiltered is like ListFile, but only returns the files that satisfy
+// 'filter' (e.g. those larger than some size, or with some metadata). The
+// filter is applied by pachd, so files that don't satisfy it aren't sent.
+func (c APIClient) ListFileFiltered(repoName string, commitID string, path string, filter *pfs.FileFilter) ([]*pfs.FileInfo, error) {
+	var result []*pfs.FileInfo
+	if err := c.ListFileFilteredF(repoName, commitID, path, 0, filter, func(fi *pfs.FileInfo) error {
+		result = append(result, fi)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListFileFilteredF is like ListFileF, but only calls f with the files that
+// satisfy 'filter' (which may be nil).
+func (c APIClient) ListFileFilteredF(repoName string, commitID string, path string, history int64, filter *pfs.FileFilter, f func(fi *pfs.FileInfo) error) error {
+	ctx, cancel := context.WithCancel(c.Ctx())
+	defer cancel()
 	fs, err := c.PfsAPIClient.ListFileStream(
-		c.Ctx(),
+		ctx,
 		&pfs.ListFileRequest{
 			File:    NewFile(repoName, commitID, path),
 			History: history,
+			Filter:  filter,
 		},
 	)
 	if err != nil {
@@ -1139,12 +1912,28 @@ func (c APIClient) ListFileF(repoName string, commitID string, path string, hist
 // GlobFile returns files that match a given glob pattern in a given commit.
 // The pattern is documented here:
 // https://golang.org/pkg/path/filepath/#Match
+// In addition, '**' matches any number of directories, and '{a,b}' matches
+// either of the alternatives 'a' and 'b' (e.g. "/{train,test}/**.csv").
 func (c APIClient) GlobFile(repoName string, commitID string, pattern string) ([]*pfs.FileInfo, error) {
+	return c.globFile(repoName, commitID, pattern, pfs.PatternType_GLOB)
+}
+
+// GlobFileRegex is like GlobFile, but returns the files whose paths match the
+// regular expression 'pattern' (in RE2 syntax). The whole of each path must
+// match, e.g. "/(train|test)/[^/]*\\.csv".
+func (c APIClient) GlobFileRegex(repoName string, commitID string, pattern string) ([]*pfs.FileInfo, error) {
+	return c.globFile(repoName, commitID, pattern, pfs.PatternType_REGEX)
+}
+
+func (c APIClient) globFile(repoName string, commitID string, pattern string, patternType pfs.PatternType) ([]*pfs.FileInfo, error) {
+	ctx, cancel := context.WithCancel(c.Ctx())
+	defer cancel()
 	fs, err := c.PfsAPIClient.GlobFileStream(
-		c.Ctx(),
+		ctx,
 		&pfs.GlobFileRequest{
-			Commit:  NewCommit(repoName, commitID),
-			Pattern: pattern,
+			Commit:      NewCommit(repoName, commitID),
+			Pattern:     pattern,
+			PatternType: patternType,
 		},
 	)
 	if err != nil {
@@ -1170,6 +1959,38 @@ func (c APIClient) GlobFile(repoName string, commitID string, pattern string) ([
 // paths and have identical content are omitted.
 func (c APIClient) DiffFile(newRepoName, newCommitID, newPath, oldRepoName,
 	oldCommitID, oldPath string, shallow bool) ([]*pfs.FileInfo, []*pfs.FileInfo, error) {
+	resp, err := c.DiffFileWithOptions(newRepoName, newCommitID, newPath,
+		oldRepoName, oldCommitID, oldPath, &DiffFileOptions{Shallow: shallow})
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.NewFiles, resp.OldFiles, nil
+}
+
+// DiffFileOptions are the options of DiffFileWithOptions
+type DiffFileOptions struct {
+	// Shallow is as in DiffFile
+	Shallow bool
+	// Content, if set, also returns unified diffs of the contents of the files
+	// that changed (files that aren't valid UTF-8 text are reported as
+	// differing binary files)
+	Content bool
+	// MaxContentDiffBytes is the size of the largest file whose contents are
+	// diffed (1MB if 0)
+	MaxContentDiffBytes int64
+	// DetectRenames, if set, also returns the removed files whose contents
+	// match an added file
+	DetectRenames bool
+}
+
+// DiffFileWithOptions is like DiffFile, but returns pachd's whole response,
+// which also holds content diffs and renames if 'opts' requests them. 'opts'
+// may be nil.
+func (c APIClient) DiffFileWithOptions(newRepoName, newCommitID, newPath, oldRepoName,
+	oldCommitID, oldPath string, opts *DiffFileOptions) (*pfs.DiffFileResponse, error) {
+	if opts == nil {
+		opts = &DiffFileOptions{}
+	}
 	var oldFile *pfs.File
 	if oldRepoName != "" {
 		oldFile = NewFile(oldRepoName, oldCommitID, oldPath)
@@ -1177,15 +1998,18 @@ func (c APIClient) DiffFile(newRepoName, newCommitID, newPath, oldRepoName,
 	resp, err := c.PfsAPIClient.DiffFile(
 		c.Ctx(),
 		&pfs.DiffFileRequest{
-			NewFile: NewFile(newRepoName, newCommitID, newPath),
-			OldFile: oldFile,
-			Shallow: shallow,
+			NewFile:             NewFile(newRepoName, newCommitID, newPath),
+			OldFile:             oldFile,
+			Shallow:             opts.Shallow,
+			Content:             opts.Content,
+			MaxContentDiffBytes: opts.MaxContentDiffBytes,
+			DetectRenames:       opts.DetectRenames,
 		},
 	)
 	if err != nil {
-		return nil, nil, grpcutil.ScrubGRPC(err)
+		return nil, grpcutil.ScrubGRPC(err)
 	}
-	return resp.NewFiles, resp.OldFiles, nil
+	return resp, nil
 }
 
 // WalkFn is the type of the function called for each file in Walk.
@@ -1196,8 +2020,10 @@ type WalkFn func(*pfs.FileInfo) error
 // Walk walks the pfs filesystem rooted at path. walkFn will be called for each
 // file found under path, this includes both regular files and directories.
 func (c APIClient) Walk(repoName string, commitID string, path string, f WalkFn) error {
+	ctx, cancel := context.WithCancel(c.Ctx())
+	defer cancel()
 	fs, err := c.PfsAPIClient.WalkFile(
-		c.Ctx(),
+		ctx,
 		&pfs.WalkFileRequest{File: NewFile(repoName, commitID, path)})
 	if err != nil {
 		return grpcutil.ScrubGRPC(err)
@@ -1237,6 +2063,11 @@ type putFileWriteCloser struct {
 	request *pfs.PutFileRequest
 	sent    bool
 	c       *putFileClient
+
+	// the progress of the write, for c.progress
+	path       string
+	written    int64
+	totalBytes int64
 }
 
 func (c *putFileClient) newPutFileWriteCloser(repoName string, commitID string, path string, delimiter pfs.Delimiter, targetFileDatums int64, targetFileBytes int64, headerRecords int64, overwriteIndex *pfs.OverwriteIndex) (*putFileWriteCloser, error) {
@@ -1250,7 +2081,9 @@ func (c *putFileClient) newPutFileWriteCloser(repoName string, commitID string,
 			HeaderRecords:    headerRecords,
 			OverwriteIndex:   overwriteIndex,
 		},
-		c: c,
+		c:          c,
+		path:       path,
+		totalBytes: -1,
 	}, nil
 }
 
@@ -1279,12 +2112,19 @@ func (w *putFileWriteCloser) Write(p []byte) (int, error) {
 		// TODO(msteffen): can other fields be zeroed as well?
 		w.request.File = nil
 		bytesWritten += len(actualP)
+		w.written += int64(len(actualP))
+		w.c.progress.report(w.path, w.written, w.totalBytes, false)
 	}
 	return bytesWritten, nil
 }
 
 func (w *putFileWriteCloser) Close() (retErr error) {
 	defer w.c.mu.Unlock()
+	defer func() {
+		if retErr == nil {
+			w.c.progress.report(w.path, w.written, w.totalBytes, true)
+		}
+	}()
 	if w.c.oneoff {
 		defer func() {
 			if err := w.c.Close(); err != nil && retErr == nil {
@@ -1308,7 +2148,7 @@ type putObjectWriteCloser struct {
 	object  *pfs.Object
 }
 
-func (c APIClient) newPutObjectWriteCloser(tags ...string) (*putObjectWriteCloser, error) {
+func (c APIClient) newPutObjectWriteCloser(compression *pfs.CompressionSpec, encryption *pfs.EncryptionSpec, tags ...string) (*putObjectWriteCloser, error) {
 	client, err := c.ObjectAPIClient.PutObject(c.Ctx())
 	if err != nil {
 		return nil, grpcutil.ScrubGRPC(err)
@@ -1319,7 +2159,9 @@ func (c APIClient) newPutObjectWriteCloser(tags ...string) (*putObjectWriteClose
 	}
 	return &putObjectWriteCloser{
 		request: &pfs.PutObjectRequest{
-			Tags: _tags,
+			Tags:        _tags,
+			Compression: compression,
+			Encryption:  encryption,
 		},
 		client: client,
 	}, nil
@@ -1331,6 +2173,8 @@ func (w *putObjectWriteCloser) Write(p []byte) (int, error) {
 		return 0, grpcutil.ScrubGRPC(err)
 	}
 	w.request.Tags = nil
+	w.request.Compression = nil
+	w.request.Encryption = nil
 	return len(p), nil
 }
 
@@ -1426,17 +2270,20 @@ func (w *PutObjectWriteCloserAsync) Object() (*pfs.Object, error) {
 type putObjectSplitWriteCloser struct {
 	request *pfs.PutObjectRequest
 	client  pfs.ObjectAPI_PutObjectSplitClient
-	objects []*pfs.Object
+	objects *pfs.Objects
 }
 
-func (c APIClient) newPutObjectSplitWriteCloser() (*putObjectSplitWriteCloser, error) {
+func (c APIClient) newPutObjectSplitWriteCloser(compression *pfs.CompressionSpec, encryption *pfs.EncryptionSpec) (*putObjectSplitWriteCloser, error) {
 	client, err := c.ObjectAPIClient.PutObjectSplit(c.Ctx())
 	if err != nil {
 		return nil, grpcutil.ScrubGRPC(err)
 	}
 	return &putObjectSplitWriteCloser{
-		request: &pfs.PutObjectRequest{},
-		client:  client,
+		request: &pfs.PutObjectRequest{
+			Compression: compression,
+			Encryption:  encryption,
+		},
+		client: client,
 	}, nil
 }
 
@@ -1445,6 +2292,8 @@ func (w *putObjectSplitWriteCloser) Write(p []byte) (int, error) {
 	if err := w.client.Send(w.request); err != nil {
 		return 0, grpcutil.ScrubGRPC(err)
 	}
+	w.request.Compression = nil
+	w.request.Encryption = nil
 	return len(p), nil
 }
 
@@ -1453,44 +2302,143 @@ func (w *putObjectSplitWriteCloser) Close() error {
 	if err != nil {
 		return grpcutil.ScrubGRPC(err)
 	}
-	w.objects = objects.Objects
+	w.objects = objects
 	return nil
 }
 
-type getFileReadSeeker struct {
-	io.Reader
-	file   *pfs.File
+// offsetWriter writes to 'w' sequentially, starting at 'offset', and calls
+// 'onWrite' (if set) with the number of bytes written by each write
+type offsetWriter struct {
+	w       io.WriterAt
+	offset  int64
+	onWrite func(n int)
+}
+
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	n, err := o.w.WriteAt(p, o.offset)
+	o.offset += int64(n)
+	if o.onWrite != nil {
+		o.onWrite(n)
+	}
+	return n, err
+}
+
+// FileReader reads a file in PFS (see GetFileReaderAt). It implements
+// io.ReaderAt, and each ReadAt fetches just the range that's read, so
+// FileReaders can be read concurrently with ReadAt (e.g. by zip or Parquet
+// readers). It also implements io.ReadSeeker, and sequential reads are
+// streamed in ranges of FileReaderChunkSize bytes, so that seeking abandons
+// at most the rest of one range. Read and Seek aren't safe for concurrent use.
+type FileReader struct {
+	c    APIClient
+	file *pfs.File
+	size int64
+
 	offset int64
-	size   int64
-	c      APIClient
+	// chunk reads the range of the file from 'offset' to 'chunkEnd'. It's nil
+	// if the range hasn't been requested yet. cancel cancels its request.
+	chunk    io.Reader
+	chunkEnd int64
+	cancel   context.CancelFunc
 }
 
-func (r *getFileReadSeeker) Seek(offset int64, whence int) (int64, error) {
-	getFileReader := func(offset int64) (io.Reader, error) {
-		return r.c.GetFileReader(r.file.Commit.Repo.Name, r.file.Commit.ID, r.file.Path, offset, 0)
+// Size returns the size of the file.
+func (r *FileReader) Size() int64 {
+	return r.size
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *FileReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("cannot read %s at negative offset %d", r.file.Path, off)
 	}
-	switch whence {
-	case io.SeekStart:
-		reader, err := getFileReader(offset)
-		if err != nil {
-			return r.offset, err
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	n := int64(len(p))
+	if off+n > r.size {
+		n = r.size - off
+	}
+	if n == 0 {
+		return 0, nil // GetFile reads the whole file if its size is 0
+	}
+	ctx, cancel := context.WithCancel(r.c.Ctx())
+	defer cancel()
+	reader, err := r.c.WithCtx(ctx).GetFileReader(r.file.Commit.Repo.Name, r.file.Commit.ID, r.file.Path, off, n)
+	if err != nil {
+		return 0, err
+	}
+	read, err := io.ReadFull(reader, p[:n])
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil && n < int64(len(p)) {
+		err = io.EOF
+	}
+	return read, err
+}
+
+// Read implements io.Reader.
+func (r *FileReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.chunk == nil {
+		n := int64(FileReaderChunkSize)
+		if r.offset+n > r.size {
+			n = r.size - r.offset
 		}
-		r.offset = offset
-		r.Reader = reader
-	case io.SeekCurrent:
-		reader, err := getFileReader(r.offset + offset)
+		ctx, cancel := context.WithCancel(r.c.Ctx())
+		chunk, err := r.c.WithCtx(ctx).GetFileReader(r.file.Commit.Repo.Name, r.file.Commit.ID, r.file.Path, r.offset, n)
 		if err != nil {
-			return r.offset, err
+			cancel()
+			return 0, err
 		}
-		r.offset += offset
-		r.Reader = reader
-	case io.SeekEnd:
-		reader, err := getFileReader(r.size - offset)
-		if err != nil {
-			return r.offset, err
+		r.chunk, r.chunkEnd, r.cancel = chunk, r.offset+n, cancel
+	}
+	n, err := r.chunk.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF {
+		r.closeChunk()
+		if r.offset < r.chunkEnd {
+			return n, io.ErrUnexpectedEOF
+		}
+		if n == 0 {
+			return r.Read(p) // read the next range
 		}
-		r.offset = r.size - offset
-		r.Reader = reader
+		err = nil // the next Read reads the next range
+	}
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (r *FileReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return r.offset, fmt.Errorf("cannot seek to negative offset %d in %s", offset, r.file.Path)
+	}
+	if offset != r.offset {
+		r.closeChunk()
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+// Close releases the range that's being read, if any. It doesn't need to be
+// called if the FileReader has been read to the end.
+func (r *FileReader) Close() error {
+	r.closeChunk()
+	return nil
+}
+
+func (r *FileReader) closeChunk() {
+	if r.cancel != nil {
+		r.cancel()
 	}
-	return r.offset, nil
+	r.chunk, r.cancel = nil, nil
 }
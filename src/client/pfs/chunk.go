@@ -0,0 +1,121 @@
+package pfs
+
+import (
+	"bufio"
+	"io"
+)
+
+// PutObjectSplit splits the data it's given into chunks at boundaries chosen
+// by the data itself (content-defined chunking), rather than at fixed
+// offsets. A boundary is placed wherever a rolling hash of the last 64 bytes
+// matches a pattern, so inserting or removing bytes in a large file only
+// changes the chunks around the edit, and the rest of the file's chunks are
+// deduplicated against the objects already in storage.
+
+const (
+	// MinChunkSize is the minimum size of the chunks (other than the last
+	// chunk) that PutObjectSplit splits data into
+	MinChunkSize = int64(1024 * 1024) // 1 MB
+	// avgChunkBits sets how often a chunk boundary occurs after
+	// MinChunkSize: on average, once every 2^avgChunkBits bytes (8 MB)
+	avgChunkBits = 23
+
+	chunkerBufSize = 1024 * 1024
+)
+
+// gearTable maps each byte to a pseudo-random value that's mixed into the
+// rolling hash. It must never change, as chunk boundaries (and so the hashes
+// of the objects that files are stored in) depend on it.
+var gearTable [256]uint64
+
+func init() {
+	// splitmix64, with a fixed seed
+	state := uint64(0)
+	for i := range gearTable {
+		state += 0x9E3779B97F4A7C15
+		z := state
+		z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+		z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+		gearTable[i] = z ^ (z >> 31)
+	}
+}
+
+// A Chunker splits the data read from a reader into content-defined chunks,
+// as PutObjectSplit does. Read returns the data in the current chunk, and
+// io.EOF at the end of it; Next then moves to the next chunk.
+type Chunker struct {
+	r *bufio.Reader
+	// min and max bound the size of each chunk, and a boundary occurs when
+	// the bits of 'hash' in 'mask' are 0
+	min, max int64
+	mask     uint64
+	hash     uint64
+	n        int64 // bytes read from the current chunk
+	end      bool  // the end of the current chunk has been read
+}
+
+// NewChunker returns a Chunker that splits the data in 'r' into chunks of
+// between MinChunkSize and ChunkSize bytes.
+func NewChunker(r io.Reader) *Chunker {
+	return &Chunker{
+		r:    bufio.NewReaderSize(r, chunkerBufSize),
+		min:  MinChunkSize,
+		max:  ChunkSize,
+		mask: ^(^uint64(0) >> avgChunkBits),
+	}
+}
+
+// Read reads data from the current chunk. It returns io.EOF at the end of the
+// chunk.
+func (c *Chunker) Read(p []byte) (int, error) {
+	if c.end {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if c.r.Buffered() == 0 {
+		if _, err := c.r.Peek(1); err != nil {
+			if err == io.EOF {
+				c.end = true
+			}
+			return 0, err
+		}
+	}
+	buf, err := c.r.Peek(c.r.Buffered())
+	if err != nil {
+		return 0, err
+	}
+	if len(buf) > len(p) {
+		buf = buf[:len(p)]
+	}
+	for i, b := range buf {
+		c.hash = c.hash<<1 + gearTable[b]
+		c.n++
+		if (c.n >= c.min && c.hash&c.mask == 0) || c.n >= c.max {
+			buf = buf[:i+1]
+			c.end = true
+			break
+		}
+	}
+	n := copy(p, buf)
+	if _, err := c.r.Discard(n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Next moves to the next chunk, once the current chunk has been read to
+// io.EOF. It returns false if there's no more data, i.e. if the chunk that
+// was just read was the last one. (The first chunk is empty only if there's
+// no data at all.)
+func (c *Chunker) Next() (bool, error) {
+	if _, err := c.r.Peek(1); err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+	c.hash, c.n, c.end = 0, 0, false
+	return true, nil
+}
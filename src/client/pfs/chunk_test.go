@@ -0,0 +1,76 @@
+package pfs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+)
+
+// chunks splits 'data' into chunks of between 'min' and 'max' bytes, with a
+// boundary on average every 2^bits bytes after 'min'
+func chunks(t *testing.T, data []byte, min, max int64, bits uint) [][]byte {
+	c := NewChunker(bytes.NewReader(data))
+	c.min, c.max, c.mask = min, max, ^uint64(0)<<(64-bits)
+	var result [][]byte
+	for {
+		chunk, err := ioutil.ReadAll(c)
+		require.NoError(t, err)
+		result = append(result, chunk)
+		more, err := c.Next()
+		require.NoError(t, err)
+		if !more {
+			return result
+		}
+	}
+}
+
+func TestChunker(t *testing.T) {
+	data := make([]byte, 1024*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+	result := chunks(t, data, 1024, 16*1024, 12)
+	require.True(t, len(result) > 1)
+	require.Equal(t, data, bytes.Join(result, nil))
+	for i, chunk := range result {
+		require.True(t, len(chunk) <= 16*1024)
+		if i < len(result)-1 {
+			require.True(t, len(chunk) >= 1024)
+		}
+	}
+
+	// Constant data never matches the boundary pattern, so it's split into
+	// chunks of the maximum size
+	result = chunks(t, bytes.Repeat([]byte("p"), 40*1024), 1024, 16*1024, 12)
+	require.Equal(t, 3, len(result))
+	require.Equal(t, 16*1024, len(result[0]))
+	require.Equal(t, 8*1024, len(result[2]))
+
+	// Empty data is a single, empty chunk
+	result = chunks(t, nil, 1024, 16*1024, 12)
+	require.Equal(t, 1, len(result))
+	require.Equal(t, 0, len(result[0]))
+}
+
+func TestChunkerEdit(t *testing.T) {
+	data := make([]byte, 1024*1024)
+	rand.New(rand.NewSource(2)).Read(data)
+	// Insert a few bytes near the start of the data
+	edited := append(append(append([]byte{}, data[:5000]...), "edit"...), data[5000:]...)
+	before := chunks(t, data, 1024, 16*1024, 12)
+	after := chunks(t, edited, 1024, 16*1024, 12)
+	// Only the chunks around the edit should differ
+	seen := make(map[string]bool)
+	for _, chunk := range before {
+		seen[string(chunk)] = true
+	}
+	var changed int
+	for _, chunk := range after {
+		if !seen[string(chunk)] {
+			changed++
+		}
+	}
+	require.True(t, changed <= 2)
+	require.True(t, len(after) > 10)
+}
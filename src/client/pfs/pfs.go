@@ -9,10 +9,16 @@ import (
 )
 
 var (
-	// ChunkSize is the size of file chunks when resumable upload is used
+	// ChunkSize is the maximum size of the chunks that PutObjectSplit splits
+	// data into (see Chunker)
 	ChunkSize = int64(512 * 1024 * 1024) // 512 MB
 )
 
+// FileModeMetadataKey is the metadata key under which the mode of a file put
+// from a tar archive (see PutFileRequest.tar) is recorded, in octal (e.g.
+// "0755"). GetCommitArchive gives files their recorded modes.
+const FileModeMetadataKey = "pfs.mode"
+
 // FullID prints repoName/CommitID
 func (c *Commit) FullID() string {
 	return fmt.Sprintf("%s/%s", c.Repo.Name, c.ID)
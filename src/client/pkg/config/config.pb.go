@@ -45,7 +45,7 @@ func (m *Config) Reset()         { *m = Config{} }
 func (m *Config) String() string { return proto.CompactTextString(m) }
 func (*Config) ProtoMessage()    {}
 func (*Config) Descriptor() ([]byte, []int) {
-	return fileDescriptor_config_3513b4c1056a9a4e, []int{0}
+	return fileDescriptor_config_c92679605a32c2c3, []int{0}
 }
 func (m *Config) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -98,10 +98,20 @@ type ConfigV1 struct {
 	// Trusted root certificates (overrides installed certificates), formatted
 	// as base64-encoded PEM
 	ServerCAs string `protobuf:"bytes,3,opt,name=server_cas,json=serverCas,proto3" json:"server_cas,omitempty"`
+	// A client certificate and its private key, formatted as base64-encoded
+	// PEM, which pachctl presents to pachd (or to a load balancer in front of
+	// pachd) when establishing a TLS connection (i.e. for mutual TLS)
+	ClientCert string `protobuf:"bytes,4,opt,name=client_cert,json=clientCert,proto3" json:"client_cert,omitempty"`
+	ClientKey  string `protobuf:"bytes,5,opt,name=client_key,json=clientKey,proto3" json:"client_key,omitempty"`
 	// A secret token identifying the current pachctl user within their
 	// pachyderm cluster. This is included in all RPCs sent by pachctl, and used
 	// to determine if pachctl actions are authorized.
-	SessionToken         string   `protobuf:"bytes,1,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
+	SessionToken string `protobuf:"bytes,1,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
+	// Named configurations for the Pachyderm clusters this user works with
+	// (similar to kubectl contexts). See client.Contexts.
+	Contexts map[string]*Context `protobuf:"bytes,6,rep,name=contexts,proto3" json:"contexts,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// The context used by clients created with client.Contexts.NewClient("")
+	ActiveContext        string   `protobuf:"bytes,7,opt,name=active_context,json=activeContext,proto3" json:"active_context,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -111,7 +121,7 @@ func (m *ConfigV1) Reset()         { *m = ConfigV1{} }
 func (m *ConfigV1) String() string { return proto.CompactTextString(m) }
 func (*ConfigV1) ProtoMessage()    {}
 func (*ConfigV1) Descriptor() ([]byte, []int) {
-	return fileDescriptor_config_3513b4c1056a9a4e, []int{1}
+	return fileDescriptor_config_c92679605a32c2c3, []int{1}
 }
 func (m *ConfigV1) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -154,6 +164,20 @@ func (m *ConfigV1) GetServerCAs() string {
 	return ""
 }
 
+func (m *ConfigV1) GetClientCert() string {
+	if m != nil {
+		return m.ClientCert
+	}
+	return ""
+}
+
+func (m *ConfigV1) GetClientKey() string {
+	if m != nil {
+		return m.ClientKey
+	}
+	return ""
+}
+
 func (m *ConfigV1) GetSessionToken() string {
 	if m != nil {
 		return m.SessionToken
@@ -161,9 +185,133 @@ func (m *ConfigV1) GetSessionToken() string {
 	return ""
 }
 
+func (m *ConfigV1) GetContexts() map[string]*Context {
+	if m != nil {
+		return m.Contexts
+	}
+	return nil
+}
+
+func (m *ConfigV1) GetActiveContext() string {
+	if m != nil {
+		return m.ActiveContext
+	}
+	return ""
+}
+
+// Context specifies how to connect to one Pachyderm cluster. Its fields mirror
+// the connection fields of ConfigV1.
+// DO NOT change or remove field numbers from this proto, as if you do, user
+// configs containing contexts will become unparseable.
+type Context struct {
+	// A host:port pointing at the cluster's pachd
+	PachdAddress string `protobuf:"bytes,1,opt,name=pachd_address,json=pachdAddress,proto3" json:"pachd_address,omitempty"`
+	// Trusted root certificates for the cluster, formatted as base64-encoded
+	// PEM
+	ServerCAs string `protobuf:"bytes,2,opt,name=server_cas,json=serverCas,proto3" json:"server_cas,omitempty"`
+	// A client certificate and its private key for mutual TLS, formatted as
+	// base64-encoded PEM
+	ClientCert string `protobuf:"bytes,3,opt,name=client_cert,json=clientCert,proto3" json:"client_cert,omitempty"`
+	ClientKey  string `protobuf:"bytes,4,opt,name=client_key,json=clientKey,proto3" json:"client_key,omitempty"`
+	// A secret token identifying the user within the cluster
+	SessionToken string `protobuf:"bytes,5,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
+	// The kubernetes context and namespace that the cluster is deployed in. If
+	// pachd_address is unset, clients for this context port-forward to pachd
+	// through the kubernetes API.
+	KubeContext          string   `protobuf:"bytes,6,opt,name=kube_context,json=kubeContext,proto3" json:"kube_context,omitempty"`
+	Namespace            string   `protobuf:"bytes,7,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Context) Reset()         { *m = Context{} }
+func (m *Context) String() string { return proto.CompactTextString(m) }
+func (*Context) ProtoMessage()    {}
+func (*Context) Descriptor() ([]byte, []int) {
+	return fileDescriptor_config_c92679605a32c2c3, []int{2}
+}
+func (m *Context) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Context) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Context.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (dst *Context) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Context.Merge(dst, src)
+}
+func (m *Context) XXX_Size() int {
+	return m.Size()
+}
+func (m *Context) XXX_DiscardUnknown() {
+	xxx_messageInfo_Context.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Context proto.InternalMessageInfo
+
+func (m *Context) GetPachdAddress() string {
+	if m != nil {
+		return m.PachdAddress
+	}
+	return ""
+}
+
+func (m *Context) GetServerCAs() string {
+	if m != nil {
+		return m.ServerCAs
+	}
+	return ""
+}
+
+func (m *Context) GetClientCert() string {
+	if m != nil {
+		return m.ClientCert
+	}
+	return ""
+}
+
+func (m *Context) GetClientKey() string {
+	if m != nil {
+		return m.ClientKey
+	}
+	return ""
+}
+
+func (m *Context) GetSessionToken() string {
+	if m != nil {
+		return m.SessionToken
+	}
+	return ""
+}
+
+func (m *Context) GetKubeContext() string {
+	if m != nil {
+		return m.KubeContext
+	}
+	return ""
+}
+
+func (m *Context) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*Config)(nil), "config.Config")
 	proto.RegisterType((*ConfigV1)(nil), "config.ConfigV1")
+	proto.RegisterMapType((map[string]*Context)(nil), "config.ConfigV1.ContextsEntry")
+	proto.RegisterType((*Context)(nil), "config.Context")
 }
 func (m *Config) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
@@ -235,6 +383,115 @@ func (m *ConfigV1) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintConfig(dAtA, i, uint64(len(m.ServerCAs)))
 		i += copy(dAtA[i:], m.ServerCAs)
 	}
+	if len(m.ClientCert) > 0 {
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.ClientCert)))
+		i += copy(dAtA[i:], m.ClientCert)
+	}
+	if len(m.ClientKey) > 0 {
+		dAtA[i] = 0x2a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.ClientKey)))
+		i += copy(dAtA[i:], m.ClientKey)
+	}
+	if len(m.Contexts) > 0 {
+		for k, _ := range m.Contexts {
+			dAtA[i] = 0x32
+			i++
+			v := m.Contexts[k]
+			msgSize := 0
+			if v != nil {
+				msgSize = v.Size()
+				msgSize += 1 + sovConfig(uint64(msgSize))
+			}
+			mapSize := 1 + len(k) + sovConfig(uint64(len(k))) + msgSize
+			i = encodeVarintConfig(dAtA, i, uint64(mapSize))
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintConfig(dAtA, i, uint64(len(k)))
+			i += copy(dAtA[i:], k)
+			if v != nil {
+				dAtA[i] = 0x12
+				i++
+				i = encodeVarintConfig(dAtA, i, uint64(v.Size()))
+				n2, err := v.MarshalTo(dAtA[i:])
+				if err != nil {
+					return 0, err
+				}
+				i += n2
+			}
+		}
+	}
+	if len(m.ActiveContext) > 0 {
+		dAtA[i] = 0x3a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.ActiveContext)))
+		i += copy(dAtA[i:], m.ActiveContext)
+	}
+	if m.XXX_unrecognized != nil {
+		i += copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	return i, nil
+}
+
+func (m *Context) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Context) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.PachdAddress) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.PachdAddress)))
+		i += copy(dAtA[i:], m.PachdAddress)
+	}
+	if len(m.ServerCAs) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.ServerCAs)))
+		i += copy(dAtA[i:], m.ServerCAs)
+	}
+	if len(m.ClientCert) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.ClientCert)))
+		i += copy(dAtA[i:], m.ClientCert)
+	}
+	if len(m.ClientKey) > 0 {
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.ClientKey)))
+		i += copy(dAtA[i:], m.ClientKey)
+	}
+	if len(m.SessionToken) > 0 {
+		dAtA[i] = 0x2a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.SessionToken)))
+		i += copy(dAtA[i:], m.SessionToken)
+	}
+	if len(m.KubeContext) > 0 {
+		dAtA[i] = 0x32
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.KubeContext)))
+		i += copy(dAtA[i:], m.KubeContext)
+	}
+	if len(m.Namespace) > 0 {
+		dAtA[i] = 0x3a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.Namespace)))
+		i += copy(dAtA[i:], m.Namespace)
+	}
 	if m.XXX_unrecognized != nil {
 		i += copy(dAtA[i:], m.XXX_unrecognized)
 	}
@@ -288,6 +545,71 @@ func (m *ConfigV1) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovConfig(uint64(l))
 	}
+	l = len(m.ClientCert)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.ClientKey)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	if len(m.Contexts) > 0 {
+		for k, v := range m.Contexts {
+			_ = k
+			_ = v
+			l = 0
+			if v != nil {
+				l = v.Size()
+				l += 1 + sovConfig(uint64(l))
+			}
+			mapEntrySize := 1 + len(k) + sovConfig(uint64(len(k))) + l
+			n += mapEntrySize + 1 + sovConfig(uint64(mapEntrySize))
+		}
+	}
+	l = len(m.ActiveContext)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *Context) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.PachdAddress)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.ServerCAs)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.ClientCert)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.ClientKey)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.SessionToken)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.KubeContext)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.Namespace)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -536,59 +858,523 @@ func (m *ConfigV1) Unmarshal(dAtA []byte) error {
 			}
 			m.ServerCAs = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipConfig(dAtA[iNdEx:])
-			if err != nil {
-				return err
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ClientCert", wireType)
 			}
-			if skippy < 0 {
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthConfig
 			}
-			if (iNdEx + skippy) > l {
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func skipConfig(dAtA []byte) (n int, err error) {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return 0, ErrIntOverflowConfig
-			}
-			if iNdEx >= l {
-				return 0, io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
+			m.ClientCert = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ClientKey", wireType)
 			}
-		}
-		wireType := int(wire & 0x7)
-		switch wireType {
-		case 0:
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
-					return 0, ErrIntOverflowConfig
+					return ErrIntOverflowConfig
 				}
 				if iNdEx >= l {
-					return 0, io.ErrUnexpectedEOF
+					return io.ErrUnexpectedEOF
 				}
+				b := dAtA[iNdEx]
 				iNdEx++
-				if dAtA[iNdEx-1] < 0x80 {
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ClientKey = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Contexts", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Contexts == nil {
+				m.Contexts = make(map[string]*Context)
+			}
+			var mapkey string
+			var mapvalue *Context
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowConfig
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowConfig
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthConfig
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var mapmsglen int
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowConfig
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						mapmsglen |= (int(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					if mapmsglen < 0 {
+						return ErrInvalidLengthConfig
+					}
+					postmsgIndex := iNdEx + mapmsglen
+					if mapmsglen < 0 {
+						return ErrInvalidLengthConfig
+					}
+					if postmsgIndex > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = &Context{}
+					if err := mapvalue.Unmarshal(dAtA[iNdEx:postmsgIndex]); err != nil {
+						return err
+					}
+					iNdEx = postmsgIndex
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipConfig(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthConfig
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.Contexts[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ActiveContext", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ActiveContext = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipConfig(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Context) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowConfig
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Context: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Context: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PachdAddress", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PachdAddress = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ServerCAs", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ServerCAs = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ClientCert", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ClientCert = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ClientKey", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ClientKey = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SessionToken", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SessionToken = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field KubeContext", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.KubeContext = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Namespace", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Namespace = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipConfig(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func skipConfig(dAtA []byte) (n int, err error) {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return 0, ErrIntOverflowConfig
+			}
+			if iNdEx >= l {
+				return 0, io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		wireType := int(wire & 0x7)
+		switch wireType {
+		case 0:
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				iNdEx++
+				if dAtA[iNdEx-1] < 0x80 {
 					break
 				}
 			}
@@ -664,27 +1450,38 @@ var (
 )
 
 func init() {
-	proto.RegisterFile("client/pkg/config/config.proto", fileDescriptor_config_3513b4c1056a9a4e)
-}
-
-var fileDescriptor_config_3513b4c1056a9a4e = []byte{
-	// 279 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x92, 0x4b, 0xce, 0xc9, 0x4c,
-	0xcd, 0x2b, 0xd1, 0x2f, 0xc8, 0x4e, 0xd7, 0x4f, 0xce, 0xcf, 0x4b, 0xcb, 0x84, 0x51, 0x7a, 0x05,
-	0x45, 0xf9, 0x25, 0xf9, 0x42, 0x6c, 0x10, 0x9e, 0x94, 0x48, 0x7a, 0x7e, 0x7a, 0x3e, 0x58, 0x48,
-	0x1f, 0xc4, 0x82, 0xc8, 0x2a, 0xf9, 0x73, 0xb1, 0x39, 0x83, 0xe5, 0x85, 0x94, 0xb9, 0xd8, 0x4b,
-	0x8b, 0x53, 0x8b, 0xe2, 0x33, 0x53, 0x24, 0x18, 0x15, 0x18, 0x35, 0x38, 0x9d, 0xb8, 0x1e, 0xdd,
-	0x93, 0x67, 0x0b, 0x2d, 0x4e, 0x2d, 0xf2, 0x74, 0x09, 0x62, 0x03, 0x49, 0x79, 0xa6, 0x08, 0x29,
-	0x70, 0x31, 0x95, 0x19, 0x4a, 0x30, 0x29, 0x30, 0x6a, 0x70, 0x1b, 0x09, 0xe8, 0x41, 0xed, 0x81,
-	0x18, 0x10, 0x66, 0x18, 0xc4, 0x54, 0x66, 0xa8, 0xd4, 0xc4, 0xc8, 0xc5, 0x01, 0x13, 0x10, 0x52,
-	0xe6, 0xe2, 0x2d, 0x4e, 0x2d, 0x2e, 0xce, 0xcc, 0xcf, 0x8b, 0x2f, 0xc9, 0xcf, 0x4e, 0xcd, 0x83,
-	0x98, 0x1c, 0xc4, 0x03, 0x15, 0x0c, 0x01, 0x89, 0x81, 0x14, 0x15, 0x24, 0x26, 0x67, 0xa4, 0xc4,
-	0x27, 0xa6, 0xa4, 0x14, 0xa5, 0x16, 0x17, 0x83, 0x8d, 0xe7, 0x0c, 0xe2, 0x01, 0x0b, 0x3a, 0x42,
-	0xc4, 0x84, 0x74, 0xb8, 0xb8, 0x8a, 0x53, 0x8b, 0xca, 0x52, 0x8b, 0xe2, 0x93, 0x13, 0x8b, 0x25,
-	0x98, 0xc1, 0x0e, 0xe4, 0x7d, 0x74, 0x4f, 0x9e, 0x33, 0x18, 0x2c, 0xea, 0xec, 0x58, 0x1c, 0xc4,
-	0x09, 0x51, 0xe0, 0x9c, 0x58, 0xec, 0xe4, 0x76, 0xe2, 0x91, 0x1c, 0xe3, 0x85, 0x47, 0x72, 0x8c,
-	0x0f, 0x1e, 0xc9, 0x31, 0xce, 0x78, 0x2c, 0xc7, 0x10, 0x65, 0x92, 0x9e, 0x59, 0x92, 0x51, 0x9a,
-	0xa4, 0x97, 0x9c, 0x9f, 0xab, 0x0f, 0x32, 0xb8, 0x32, 0x25, 0xb5, 0x08, 0x99, 0x55, 0x5c, 0x94,
-	0xac, 0x8f, 0x11, 0x90, 0x49, 0x6c, 0xe0, 0x40, 0x32, 0x06, 0x04, 0x00, 0x00, 0xff, 0xff, 0xb3,
-	0xd7, 0x99, 0xe3, 0x64, 0x01, 0x00, 0x00,
+	proto.RegisterFile("client/pkg/config/config.proto", fileDescriptor_config_c92679605a32c2c3)
+}
+
+var fileDescriptor_config_c92679605a32c2c3 = []byte{
+	// 462 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x93, 0xcd, 0x6e, 0xd3, 0x40,
+	0x14, 0x85, 0xb1, 0xd3, 0x38, 0xf5, 0x4d, 0x02, 0xd5, 0x88, 0x85, 0x55, 0x81, 0x13, 0x52, 0x55,
+	0xca, 0x02, 0xc5, 0x4a, 0x61, 0x81, 0xba, 0x6b, 0x03, 0x48, 0x15, 0x48, 0x48, 0xe6, 0x67, 0xc1,
+	0xc6, 0x9a, 0x8c, 0x2f, 0xa9, 0x95, 0xd6, 0x13, 0xcd, 0x4c, 0x2c, 0xb2, 0xe5, 0x29, 0x78, 0x24,
+	0x96, 0x3c, 0x41, 0x85, 0xcc, 0x23, 0xf0, 0x02, 0x68, 0x7e, 0x42, 0x4b, 0x83, 0x14, 0xb1, 0xf2,
+	0x9d, 0xef, 0x9e, 0x39, 0x9e, 0x7b, 0x34, 0x03, 0x31, 0xbb, 0x28, 0xb0, 0x54, 0xc9, 0x62, 0x3e,
+	0x4b, 0x18, 0x2f, 0x3f, 0x15, 0xeb, 0xcf, 0x68, 0x21, 0xb8, 0xe2, 0x24, 0xb0, 0xab, 0xfd, 0xfb,
+	0x33, 0x3e, 0xe3, 0x06, 0x25, 0xba, 0xb2, 0xdd, 0xc1, 0x1b, 0x08, 0x26, 0xa6, 0x4f, 0x0e, 0xa0,
+	0xb5, 0x94, 0x28, 0xb2, 0x22, 0x8f, 0xbc, 0xbe, 0x37, 0x0c, 0x4f, 0xa1, 0xbe, 0xea, 0x05, 0xef,
+	0x25, 0x8a, 0xb3, 0xe7, 0x69, 0xa0, 0x5b, 0x67, 0x39, 0xe9, 0x83, 0x5f, 0x8d, 0x23, 0xbf, 0xef,
+	0x0d, 0xdb, 0x47, 0x7b, 0x23, 0xf7, 0x1f, 0x6b, 0xf0, 0x61, 0x9c, 0xfa, 0xd5, 0x78, 0xf0, 0xcb,
+	0x87, 0xdd, 0x35, 0x20, 0x07, 0xd0, 0x95, 0x28, 0x65, 0xc1, 0xcb, 0x4c, 0xf1, 0x39, 0x96, 0xd6,
+	0x39, 0xed, 0x38, 0xf8, 0x4e, 0x33, 0x2d, 0x5a, 0x50, 0x76, 0x9e, 0x67, 0x34, 0xcf, 0x05, 0x4a,
+	0x69, 0xec, 0xc3, 0xb4, 0x63, 0xe0, 0x89, 0x65, 0xe4, 0x31, 0x80, 0x44, 0x51, 0xa1, 0xc8, 0x18,
+	0x95, 0x51, 0xc3, 0x1c, 0xb0, 0x5b, 0x5f, 0xf5, 0xc2, 0xb7, 0x86, 0x4e, 0x4e, 0x64, 0x1a, 0x5a,
+	0xc1, 0x84, 0x4a, 0xd2, 0x83, 0xb6, 0x4d, 0x25, 0x63, 0x28, 0x54, 0xb4, 0x63, 0x0c, 0xc1, 0xa2,
+	0x09, 0x0a, 0x45, 0x1e, 0x82, 0x5b, 0x65, 0x73, 0x5c, 0x45, 0x4d, 0xd3, 0x0f, 0x2d, 0x79, 0x85,
+	0x2b, 0x72, 0x0c, 0xbb, 0x8c, 0x97, 0x0a, 0x3f, 0x2b, 0x19, 0x05, 0xfd, 0xc6, 0xb0, 0x7d, 0x14,
+	0xdf, 0x1e, 0x56, 0x17, 0x46, 0xf0, 0xa2, 0x54, 0x62, 0x95, 0xfe, 0xd1, 0x93, 0x43, 0xb8, 0x4b,
+	0x99, 0x2a, 0x2a, 0xcc, 0x1c, 0x8a, 0x5a, 0xc6, 0xbe, 0x6b, 0xa9, 0xdb, 0xb6, 0xff, 0x1a, 0xba,
+	0x7f, 0x39, 0x90, 0x3d, 0x68, 0xe8, 0xb3, 0xd8, 0x84, 0x74, 0x49, 0x0e, 0xa1, 0x59, 0xd1, 0x8b,
+	0x25, 0xba, 0xbc, 0xef, 0xdd, 0x38, 0x82, 0xde, 0x97, 0xda, 0xee, 0xb1, 0xff, 0xcc, 0x1b, 0x7c,
+	0xf1, 0xa1, 0xe5, 0xf0, 0x66, 0x9e, 0xde, 0xd6, 0x3c, 0xfd, 0xff, 0xcb, 0xb3, 0xb1, 0x25, 0xcf,
+	0x9d, 0xdb, 0x79, 0x6e, 0xdc, 0x83, 0xe6, 0x3f, 0xee, 0xc1, 0x23, 0xe8, 0xcc, 0x97, 0xd3, 0xeb,
+	0xd8, 0x02, 0xa3, 0x69, 0x6b, 0xb6, 0x1e, 0xed, 0x01, 0x84, 0x25, 0xbd, 0x44, 0xb9, 0xa0, 0x0c,
+	0x5d, 0xac, 0xd7, 0xe0, 0xf4, 0xe5, 0xb7, 0x3a, 0xf6, 0xbe, 0xd7, 0xb1, 0xf7, 0xa3, 0x8e, 0xbd,
+	0xaf, 0x3f, 0xe3, 0x3b, 0x1f, 0x9f, 0xce, 0x0a, 0x75, 0xbe, 0x9c, 0x8e, 0x18, 0xbf, 0x4c, 0xf4,
+	0xf8, 0xab, 0x1c, 0xc5, 0xcd, 0x4a, 0x0a, 0x96, 0x6c, 0x3c, 0x9f, 0x69, 0x60, 0x9e, 0xc6, 0x93,
+	0xdf, 0x01, 0x00, 0x00, 0xff, 0xff, 0xfe, 0xae, 0x8c, 0xdf, 0x5a, 0x03, 0x00, 0x00,
 }
@@ -0,0 +1,41 @@
+// Package dryrun implements dry runs of mutating RPCs. A client requests a
+// dry run by setting MetadataKey in an RPC's metadata. RPCs that support dry
+// runs then validate the request as usual, but instead of applying it, they
+// report the changes they would have made in the response header (under
+// ChangeKey) and return.
+package dryrun
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// MetadataKey is the metadata key that requests a dry run (when set to
+	// "true"). RPCs that perform a dry run also set it in their response
+	// header, so that clients can tell that the dry run was honored.
+	MetadataKey = "pach-dry-run"
+	// ChangeKey is the response header key under which a dry run reports the
+	// changes it would have made, one change per value
+	ChangeKey = "pach-dry-run-change"
+)
+
+// IsDryRun returns true if the RPC whose context is 'ctx' requested a dry run
+func IsDryRun(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	values := md[MetadataKey]
+	return len(values) > 0 && values[0] == "true"
+}
+
+// Report reports the changes that the dry run whose context is 'ctx' would
+// have made. It must be called at most once, before the RPC returns.
+func Report(ctx context.Context, changes ...string) error {
+	return grpc.SetHeader(ctx, metadata.MD{
+		MetadataKey: []string{"true"},
+		ChangeKey:   changes,
+	})
+}
@@ -7,12 +7,17 @@ import (
 )
 
 // ScrubGRPC removes GRPC error code information from 'err' if it came from
-// GRPC (and returns it unchanged otherwise)
+// GRPC (and returns it unchanged otherwise). Errors that carry a GRPC status
+// but whose message already omits the code (e.g. *client.Error) are returned
+// unchanged, so that their type is preserved.
 func ScrubGRPC(err error) error {
 	if err == nil {
 		return nil
 	}
 	if s, ok := status.FromError(err); ok {
+		if err.Error() == s.Message() {
+			return err
+		}
 		return errors.New(s.Message())
 	}
 	return err
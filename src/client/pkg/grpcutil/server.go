@@ -77,6 +77,9 @@ func Serve(
 				MinTime:             5 * time.Second,
 				PermitWithoutStream: true,
 			}),
+			// accept requests from clients created with
+			// client.WithGZIPCompression()
+			grpc.RPCDecompressor(grpc.NewGZIPDecompressor()),
 		}
 		if server.PublicPortTLSAllowed {
 			// Validate environment
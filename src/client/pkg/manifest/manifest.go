@@ -0,0 +1,271 @@
+// Package manifest reads, writes and diffs manifests of the files in PFS
+// commits (see pfs.ManifestEntry), so that external tools can audit the
+// data in a commit, or what changed between two commits, without reading it.
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+)
+
+// csvHeader is the header row of CSV manifests
+var csvHeader = []string{"path", "size_bytes", "hash", "content_sha256", "content_md5", "metadata"}
+
+// NewEntry returns the manifest entry of the file described by 'fileInfo'
+func NewEntry(fileInfo *pfs.FileInfo) *pfs.ManifestEntry {
+	return &pfs.ManifestEntry{
+		Path:          fileInfo.File.Path,
+		SizeBytes:     fileInfo.SizeBytes,
+		Hash:          hex.EncodeToString(fileInfo.Hash),
+		ContentSha256: hex.EncodeToString(fileInfo.ContentSha256),
+		ContentMd5:    hex.EncodeToString(fileInfo.ContentMd5),
+		Metadata:      fileInfo.Metadata,
+	}
+}
+
+// Writer writes manifest entries to an io.Writer
+type Writer struct {
+	w         *bufio.Writer
+	csv       *csv.Writer // nil if the manifest is in JSON
+	marshaler *jsonpb.Marshaler
+}
+
+// NewWriter returns a Writer that writes a manifest in 'format' to 'w'. Flush
+// must be called once all entries have been written.
+func NewWriter(w io.Writer, format pfs.ManifestFormat) (*Writer, error) {
+	mw := &Writer{w: bufio.NewWriter(w), marshaler: &jsonpb.Marshaler{OrigName: true}}
+	switch format {
+	case pfs.ManifestFormat_MANIFEST_JSON:
+	case pfs.ManifestFormat_MANIFEST_CSV:
+		mw.csv = csv.NewWriter(mw.w)
+		if err := mw.csv.Write(csvHeader); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unrecognized manifest format %v", format)
+	}
+	return mw, nil
+}
+
+// Write writes 'entry' to the manifest
+func (w *Writer) Write(entry *pfs.ManifestEntry) error {
+	if w.csv == nil {
+		if err := w.marshaler.Marshal(w.w, entry); err != nil {
+			return err
+		}
+		return w.w.WriteByte('\n')
+	}
+	var metadata string
+	if len(entry.Metadata) > 0 {
+		// encoding/json sorts the keys, so equal metadata is encoded equally
+		encoded, err := json.Marshal(entry.Metadata)
+		if err != nil {
+			return err
+		}
+		metadata = string(encoded)
+	}
+	return w.csv.Write([]string{
+		entry.Path,
+		strconv.FormatUint(entry.SizeBytes, 10),
+		entry.Hash,
+		entry.ContentSha256,
+		entry.ContentMd5,
+		metadata,
+	})
+}
+
+// Flush writes any buffered entries
+func (w *Writer) Flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return w.w.Flush()
+}
+
+// Read reads the entries of a manifest in either format from 'r'
+func Read(r io.Reader) ([]*pfs.ManifestEntry, error) {
+	br := bufio.NewReader(r)
+	first, err := br.Peek(1)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if first[0] == '{' {
+		return readJSON(br)
+	}
+	return readCSV(br)
+}
+
+func readJSON(r *bufio.Reader) ([]*pfs.ManifestEntry, error) {
+	var entries []*pfs.ManifestEntry
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			entry := &pfs.ManifestEntry{}
+			if err := jsonpb.Unmarshal(bytes.NewReader(data), entry); err != nil {
+				return nil, fmt.Errorf("invalid manifest entry on line %d: %v", line, err)
+			}
+			entries = append(entries, entry)
+		}
+		if err == io.EOF {
+			return entries, nil
+		}
+	}
+}
+
+func readCSV(r io.Reader) ([]*pfs.ManifestEntry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest header: %v", err)
+	}
+	for i, column := range csvHeader {
+		if header[i] != column {
+			return nil, fmt.Errorf("invalid manifest header: column %d is %q, but should be %q", i+1, header[i], column)
+		}
+	}
+	var entries []*pfs.ManifestEntry
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.ParseUint(row[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size of %s in manifest: %v", row[0], err)
+		}
+		entry := &pfs.ManifestEntry{
+			Path:          row[0],
+			SizeBytes:     size,
+			Hash:          row[2],
+			ContentSha256: row[3],
+			ContentMd5:    row[4],
+		}
+		if row[5] != "" {
+			if err := json.Unmarshal([]byte(row[5]), &entry.Metadata); err != nil {
+				return nil, fmt.Errorf("invalid metadata of %s in manifest: %v", row[0], err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// ChangeType is how a file differs between two manifests
+type ChangeType string
+
+const (
+	// Added files are only in the new manifest
+	Added ChangeType = "added"
+	// Removed files are only in the old manifest
+	Removed ChangeType = "removed"
+	// Modified files are in both manifests, with different contents or
+	// metadata
+	Modified ChangeType = "modified"
+)
+
+// Change is a difference between two manifests
+type Change struct {
+	Path string     `json:"path"`
+	Type ChangeType `json:"type"`
+	// Fields are the fields of a modified file that differ
+	// ("contents", "metadata")
+	Fields []string `json:"fields,omitempty"`
+	// Old and New are the file's entries in the old and new manifests (Old is
+	// nil if the file was added, and New is nil if it was removed)
+	Old *pfs.ManifestEntry `json:"old,omitempty"`
+	New *pfs.ManifestEntry `json:"new,omitempty"`
+}
+
+// Diff returns the differences between the manifests 'from' and 'to', sorted
+// by path. A file's contents are compared by their SHA-256 checksums if both
+// entries have one, and by their sizes and PFS hashes otherwise.
+func Diff(from, to []*pfs.ManifestEntry) ([]*Change, error) {
+	oldEntries, err := byPath(from)
+	if err != nil {
+		return nil, err
+	}
+	newEntries, err := byPath(to)
+	if err != nil {
+		return nil, err
+	}
+	var changes []*Change
+	for path, oldEntry := range oldEntries {
+		newEntry, ok := newEntries[path]
+		if !ok {
+			changes = append(changes, &Change{Path: path, Type: Removed, Old: oldEntry})
+			continue
+		}
+		var fields []string
+		if !sameContents(oldEntry, newEntry) {
+			fields = append(fields, "contents")
+		}
+		if !sameMetadata(oldEntry.Metadata, newEntry.Metadata) {
+			fields = append(fields, "metadata")
+		}
+		if len(fields) > 0 {
+			changes = append(changes, &Change{Path: path, Type: Modified, Fields: fields, Old: oldEntry, New: newEntry})
+		}
+	}
+	for path, newEntry := range newEntries {
+		if _, ok := oldEntries[path]; !ok {
+			changes = append(changes, &Change{Path: path, Type: Added, New: newEntry})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+func byPath(entries []*pfs.ManifestEntry) (map[string]*pfs.ManifestEntry, error) {
+	result := make(map[string]*pfs.ManifestEntry, len(entries))
+	for _, entry := range entries {
+		if _, ok := result[entry.Path]; ok {
+			return nil, fmt.Errorf("manifest lists %s more than once", entry.Path)
+		}
+		result[entry.Path] = entry
+	}
+	return result, nil
+}
+
+func sameContents(a, b *pfs.ManifestEntry) bool {
+	if a.SizeBytes != b.SizeBytes {
+		return false
+	}
+	if a.ContentSha256 != "" && b.ContentSha256 != "" {
+		return a.ContentSha256 == b.ContentSha256
+	}
+	return a.Hash == b.Hash
+}
+
+func sameMetadata(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if value, ok := b[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package manifest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	entries := []*pfs.ManifestEntry{
+		{Path: "/foo", SizeBytes: 3, Hash: "abcd", ContentSha256: "ef01", ContentMd5: "2345"},
+		{Path: "/dir/bar,baz", SizeBytes: 0, Hash: "6789", Metadata: map[string]string{"owner": "qux", "mode": "0644"}},
+	}
+	for _, format := range []pfs.ManifestFormat{pfs.ManifestFormat_MANIFEST_JSON, pfs.ManifestFormat_MANIFEST_CSV} {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, format)
+		require.NoError(t, err)
+		for _, entry := range entries {
+			require.NoError(t, w.Write(entry))
+		}
+		require.NoError(t, w.Flush())
+		read, err := Read(&buf)
+		require.NoError(t, err)
+		require.Equal(t, len(entries), len(read))
+		for i, entry := range entries {
+			require.Equal(t, entry.Path, read[i].Path)
+			require.Equal(t, entry.SizeBytes, read[i].SizeBytes)
+			require.Equal(t, entry.Hash, read[i].Hash)
+			require.Equal(t, entry.ContentSha256, read[i].ContentSha256)
+			require.Equal(t, entry.ContentMd5, read[i].ContentMd5)
+			require.True(t, sameMetadata(entry.Metadata, read[i].Metadata))
+		}
+	}
+
+	// empty manifests
+	read, err := Read(strings.NewReader(""))
+	require.NoError(t, err)
+	require.Equal(t, 0, len(read))
+	_, err = Read(strings.NewReader("path,size\n"))
+	require.YesError(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	from := []*pfs.ManifestEntry{
+		{Path: "/same", SizeBytes: 1, Hash: "a", ContentSha256: "1"},
+		// rewritten in storage, but with the same contents
+		{Path: "/rewritten", SizeBytes: 1, Hash: "b", ContentSha256: "2"},
+		{Path: "/changed", SizeBytes: 1, Hash: "c"},
+		{Path: "/labelled", SizeBytes: 1, Hash: "d"},
+		{Path: "/removed", SizeBytes: 1, Hash: "e"},
+	}
+	to := []*pfs.ManifestEntry{
+		{Path: "/same", SizeBytes: 1, Hash: "a", ContentSha256: "1"},
+		{Path: "/rewritten", SizeBytes: 1, Hash: "f", ContentSha256: "2"},
+		{Path: "/changed", SizeBytes: 2, Hash: "g"},
+		{Path: "/labelled", SizeBytes: 1, Hash: "d", Metadata: map[string]string{"k": "v"}},
+		{Path: "/added", SizeBytes: 1, Hash: "h"},
+	}
+	changes, err := Diff(from, to)
+	require.NoError(t, err)
+	require.Equal(t, 4, len(changes))
+	require.Equal(t, "/added", changes[0].Path)
+	require.Equal(t, Added, changes[0].Type)
+	require.Equal(t, "/changed", changes[1].Path)
+	require.Equal(t, Modified, changes[1].Type)
+	require.Equal(t, []string{"contents"}, changes[1].Fields)
+	require.Equal(t, "/labelled", changes[2].Path)
+	require.Equal(t, []string{"metadata"}, changes[2].Fields)
+	require.Equal(t, "/removed", changes[3].Path)
+	require.Equal(t, Removed, changes[3].Type)
+
+	_, err = Diff(append(from, from[0]), to)
+	require.YesError(t, err)
+}
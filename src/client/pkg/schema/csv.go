@@ -0,0 +1,52 @@
+package schema
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+)
+
+func newCSVValidator(s *pfs.CSVSchema) (func(io.Reader) error, error) {
+	if len(s.Columns) == 0 {
+		return nil, fmt.Errorf("a CSV schema must have at least one column")
+	}
+	comma := ','
+	if s.Delimiter != "" {
+		r, size := utf8.DecodeRuneInString(s.Delimiter)
+		if size != len(s.Delimiter) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+			return nil, fmt.Errorf("invalid CSV delimiter %q", s.Delimiter)
+		}
+		comma = r
+	}
+	return func(r io.Reader) error {
+		csvReader := csv.NewReader(r)
+		csvReader.Comma = comma
+		csvReader.FieldsPerRecord = len(s.Columns)
+		csvReader.ReuseRecord = true
+		header, err := csvReader.Read()
+		if err == io.EOF {
+			return fmt.Errorf("missing header row")
+		}
+		if err != nil {
+			return err
+		}
+		for i, column := range s.Columns {
+			if header[i] != column {
+				return fmt.Errorf("header row is %q, but should be %q",
+					strings.Join(header, string(comma)), strings.Join(s.Columns, string(comma)))
+			}
+		}
+		for {
+			if _, err := csvReader.Read(); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+		}
+	}, nil
+}
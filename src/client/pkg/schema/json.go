@@ -0,0 +1,373 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+)
+
+// jsonSchema is a compiled JSON Schema. Only a subset of JSON Schema's
+// validation keywords are supported (see pfs.JSONSchema).
+type jsonSchema struct {
+	// never is set by the schema 'false', which matches nothing
+	never                bool
+	types                []string
+	enum                 []interface{}
+	properties           map[string]*jsonSchema
+	required             []string
+	additionalProperties *jsonSchema
+	items                *jsonSchema
+	minItems, maxItems   *int
+	minimum, maximum     *float64
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+}
+
+// jsonAnnotations are the JSON Schema keywords that don't affect validation
+var jsonAnnotations = map[string]bool{
+	"$schema":     true,
+	"$id":         true,
+	"id":          true,
+	"$comment":    true,
+	"title":       true,
+	"description": true,
+	"default":     true,
+	"examples":    true,
+}
+
+var jsonTypes = map[string]bool{
+	"null":    true,
+	"boolean": true,
+	"integer": true,
+	"number":  true,
+	"string":  true,
+	"array":   true,
+	"object":  true,
+}
+
+func newJSONValidator(s *pfs.JSONSchema) (func(io.Reader) error, error) {
+	decoder := json.NewDecoder(strings.NewReader(s.Schema))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("could not parse JSON schema: %v", err)
+	}
+	schema, err := compileJSONSchema(doc, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %v", err)
+	}
+	return func(r io.Reader) error {
+		decoder := json.NewDecoder(r)
+		decoder.UseNumber()
+		for i := 1; ; i++ {
+			var value interface{}
+			if err := decoder.Decode(&value); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return fmt.Errorf("could not parse JSON value %d: %v", i, err)
+			}
+			if err := schema.validate(value, ""); err != nil {
+				return fmt.Errorf("JSON value %d %v", i, err)
+			}
+		}
+	}, nil
+}
+
+// compileJSONSchema compiles the schema 'doc', found at 'path' in the schema
+// document
+func compileJSONSchema(doc interface{}, path string) (*jsonSchema, error) {
+	switch doc := doc.(type) {
+	case bool:
+		return &jsonSchema{never: !doc}, nil
+	case map[string]interface{}:
+		s := &jsonSchema{}
+		var err error
+		for keyword, value := range doc {
+			if jsonAnnotations[keyword] {
+				continue
+			}
+			keywordPath := path + "/" + keyword
+			switch keyword {
+			case "type":
+				s.types, err = jsonStrings(value, keywordPath)
+				for _, t := range s.types {
+					if !jsonTypes[t] {
+						return nil, fmt.Errorf("%s: unknown type %q", keywordPath, t)
+					}
+				}
+			case "enum":
+				var ok bool
+				if s.enum, ok = value.([]interface{}); !ok {
+					return nil, fmt.Errorf("%s must be an array", keywordPath)
+				}
+			case "properties":
+				properties, ok := value.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("%s must be an object", keywordPath)
+				}
+				s.properties = make(map[string]*jsonSchema)
+				for name, property := range properties {
+					if s.properties[name], err = compileJSONSchema(property, keywordPath+"/"+name); err != nil {
+						return nil, err
+					}
+				}
+			case "required":
+				s.required, err = jsonStrings(value, keywordPath)
+			case "additionalProperties":
+				s.additionalProperties, err = compileJSONSchema(value, keywordPath)
+			case "items":
+				s.items, err = compileJSONSchema(value, keywordPath)
+			case "minItems":
+				s.minItems, err = jsonCount(value, keywordPath)
+			case "maxItems":
+				s.maxItems, err = jsonCount(value, keywordPath)
+			case "minLength":
+				s.minLength, err = jsonCount(value, keywordPath)
+			case "maxLength":
+				s.maxLength, err = jsonCount(value, keywordPath)
+			case "minimum":
+				s.minimum, err = jsonNumber(value, keywordPath)
+			case "maximum":
+				s.maximum, err = jsonNumber(value, keywordPath)
+			case "pattern":
+				pattern, ok := value.(string)
+				if !ok {
+					return nil, fmt.Errorf("%s must be a string", keywordPath)
+				}
+				if s.pattern, err = regexp.Compile(pattern); err != nil {
+					return nil, fmt.Errorf("%s: %v", keywordPath, err)
+				}
+			default:
+				return nil, fmt.Errorf("%s: unsupported keyword", keywordPath)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	default:
+		if path == "" {
+			path = "schema"
+		}
+		return nil, fmt.Errorf("%s must be an object or a boolean", path)
+	}
+}
+
+// jsonStrings returns the string or array of strings 'value'
+func jsonStrings(value interface{}, path string) ([]string, error) {
+	if s, ok := value.(string); ok {
+		return []string{s}, nil
+	}
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a string or an array of strings", path)
+	}
+	result := make([]string, len(values))
+	for i, v := range values {
+		if result[i], ok = v.(string); !ok {
+			return nil, fmt.Errorf("%s must be a string or an array of strings", path)
+		}
+	}
+	return result, nil
+}
+
+func jsonNumber(value interface{}, path string) (*float64, error) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a number", path)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &f, nil
+}
+
+func jsonCount(value interface{}, path string) (*int, error) {
+	f, err := jsonNumber(value, path)
+	if err != nil || *f < 0 || *f != math.Trunc(*f) {
+		return nil, fmt.Errorf("%s must be a non-negative integer", path)
+	}
+	n := int(*f)
+	return &n, nil
+}
+
+// jsonType returns the JSON Schema type of 'value' ("number" for all numbers)
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func isInteger(n json.Number) bool {
+	f, err := n.Float64()
+	return err == nil && f == math.Trunc(f)
+}
+
+// jsonEqual returns true if 'a' and 'b' are the same JSON value
+func jsonEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, aErr := a.Float64()
+		bf, bErr := b.Float64()
+		return aErr == nil && bErr == nil && af == bf
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			if w, ok := b[k]; !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// validate returns an error if 'value', found at 'path' (a JSON pointer) in
+// the value being validated, doesn't match 's'
+func (s *jsonSchema) validate(value interface{}, path string) error {
+	fail := func(format string, args ...interface{}) error {
+		p := path
+		if p == "" {
+			p = "/"
+		}
+		return fmt.Errorf("at %q: %s", p, fmt.Sprintf(format, args...))
+	}
+	if s.never {
+		return fail("no value is allowed")
+	}
+	if len(s.types) > 0 {
+		t := jsonType(value)
+		var ok bool
+		for _, want := range s.types {
+			if want == t || (want == "integer" && t == "number" && isInteger(value.(json.Number))) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fail("expected %s, got %s", strings.Join(s.types, " or "), t)
+		}
+	}
+	if s.enum != nil {
+		var ok bool
+		for _, e := range s.enum {
+			if jsonEqual(e, value) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			var buf bytes.Buffer
+			json.NewEncoder(&buf).Encode(s.enum)
+			return fail("value must be one of %s", strings.TrimSpace(buf.String()))
+		}
+	}
+	switch value := value.(type) {
+	case json.Number:
+		f, err := value.Float64()
+		if err != nil {
+			return fail("%v", err)
+		}
+		if s.minimum != nil && f < *s.minimum {
+			return fail("%v is less than the minimum of %v", value, *s.minimum)
+		}
+		if s.maximum != nil && f > *s.maximum {
+			return fail("%v is greater than the maximum of %v", value, *s.maximum)
+		}
+	case string:
+		length := utf8.RuneCountInString(value)
+		if s.minLength != nil && length < *s.minLength {
+			return fail("string is shorter than the minimum length of %d", *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			return fail("string is longer than the maximum length of %d", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(value) {
+			return fail("string doesn't match the pattern %q", s.pattern.String())
+		}
+	case []interface{}:
+		if s.minItems != nil && len(value) < *s.minItems {
+			return fail("array has fewer than the minimum of %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(value) > *s.maxItems {
+			return fail("array has more than the maximum of %d items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range value {
+				if err := s.items.validate(item, fmt.Sprintf("%s/%d", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := value[name]; !ok {
+				return fail("missing required property %q", name)
+			}
+		}
+		// Validate properties in order, so that errors are deterministic
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			propertyPath := path + "/" + jsonPointerEscaper.Replace(name)
+			if property, ok := s.properties[name]; ok {
+				if err := property.validate(value[name], propertyPath); err != nil {
+					return err
+				}
+			} else if s.additionalProperties != nil {
+				if s.additionalProperties.never {
+					return fail("unexpected property %q", name)
+				}
+				if err := s.additionalProperties.validate(value[name], propertyPath); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
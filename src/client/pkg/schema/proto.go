@@ -0,0 +1,263 @@
+package schema
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+)
+
+// maxMessageSize is the largest delimited message that's read, so that a
+// corrupt size can't exhaust pachd's memory (it's protobuf's default limit)
+const maxMessageSize = 64 * 1024 * 1024
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// protoMessage is a message type, compiled from its descriptor
+type protoMessage struct {
+	name   string
+	fields map[uint64]*protoField
+	// required holds the message's required (proto2) fields
+	required []*protoField
+	// checkUTF8 is set for proto3 messages, whose strings must be UTF-8
+	checkUTF8 bool
+}
+
+type protoField struct {
+	name     string
+	number   uint64
+	typ      descriptor.FieldDescriptorProto_Type
+	repeated bool
+	message  *protoMessage // set if typ is TYPE_MESSAGE
+}
+
+// protoTypes compiles the message types in a FileDescriptorSet
+type protoTypes struct {
+	descriptors map[string]*descriptor.DescriptorProto
+	syntax      map[string]string
+	compiled    map[string]*protoMessage
+}
+
+func newProtoValidator(s *pfs.ProtoSchema) (func(io.Reader) error, error) {
+	var set descriptor.FileDescriptorSet
+	if err := proto.Unmarshal(s.DescriptorSet, &set); err != nil {
+		return nil, fmt.Errorf("could not parse protobuf descriptor set: %v", err)
+	}
+	types := &protoTypes{
+		descriptors: make(map[string]*descriptor.DescriptorProto),
+		syntax:      make(map[string]string),
+		compiled:    make(map[string]*protoMessage),
+	}
+	for _, file := range set.File {
+		prefix := ""
+		if file.GetPackage() != "" {
+			prefix = "." + file.GetPackage()
+		}
+		types.add(prefix, file.GetSyntax(), file.MessageType)
+	}
+	name := s.Message
+	if !strings.HasPrefix(name, ".") {
+		name = "." + name
+	}
+	message, err := types.compile(name)
+	if err != nil {
+		return nil, err
+	}
+	if !s.Delimited {
+		return func(r io.Reader) error {
+			data, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			return message.validate(data)
+		}, nil
+	}
+	return func(r io.Reader) error {
+		br := bufio.NewReader(r)
+		for i := 1; ; i++ {
+			size, err := binary.ReadUvarint(br)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("could not read the size of message %d: %v", i, err)
+			}
+			if size > maxMessageSize {
+				return fmt.Errorf("message %d is %d bytes, which is over the limit of %d bytes", i, size, maxMessageSize)
+			}
+			data := make([]byte, size)
+			if _, err := io.ReadFull(br, data); err != nil {
+				return fmt.Errorf("could not read message %d: %v", i, err)
+			}
+			if err := message.validate(data); err != nil {
+				return fmt.Errorf("message %d: %v", i, err)
+			}
+		}
+	}, nil
+}
+
+// add adds 'messages', and the types nested in them, to 't'
+func (t *protoTypes) add(prefix string, syntax string, messages []*descriptor.DescriptorProto) {
+	for _, m := range messages {
+		name := prefix + "." + m.GetName()
+		t.descriptors[name] = m
+		t.syntax[name] = syntax
+		t.add(name, syntax, m.NestedType)
+	}
+}
+
+// compile returns the message type with the fully-qualified 'name'
+func (t *protoTypes) compile(name string) (*protoMessage, error) {
+	if m, ok := t.compiled[name]; ok {
+		return m, nil
+	}
+	d, ok := t.descriptors[name]
+	if !ok {
+		return nil, fmt.Errorf("message type %q is not in the descriptor set", strings.TrimPrefix(name, "."))
+	}
+	m := &protoMessage{
+		name:      strings.TrimPrefix(name, "."),
+		fields:    make(map[uint64]*protoField),
+		checkUTF8: t.syntax[name] == "proto3",
+	}
+	// Add 'm' before compiling its fields, as messages may be recursive
+	t.compiled[name] = m
+	for _, f := range d.Field {
+		field := &protoField{
+			name:     f.GetName(),
+			number:   uint64(f.GetNumber()),
+			typ:      f.GetType(),
+			repeated: f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED,
+		}
+		switch field.typ {
+		case descriptor.FieldDescriptorProto_TYPE_GROUP:
+			return nil, fmt.Errorf("field %s.%s is a group, which isn't supported", m.name, field.name)
+		case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+			var err error
+			if field.message, err = t.compile(f.GetTypeName()); err != nil {
+				return nil, err
+			}
+		}
+		m.fields[field.number] = field
+		if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED {
+			m.required = append(m.required, field)
+		}
+	}
+	return m, nil
+}
+
+// wireType returns the wire type of the (unpacked) values of 'f'
+func (f *protoField) wireType() uint64 {
+	switch f.typ {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return wireFixed64
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return wireFixed32
+	case descriptor.FieldDescriptorProto_TYPE_STRING,
+		descriptor.FieldDescriptorProto_TYPE_BYTES,
+		descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return wireBytes
+	default:
+		return wireVarint
+	}
+}
+
+// next splits the value of wire type 'wireType' at the start of 'data' from
+// the rest of 'data'. For wireBytes values, the returned value excludes its
+// size.
+func next(data []byte, wireType uint64) (value []byte, rest []byte, err error) {
+	switch wireType {
+	case wireVarint:
+		if _, n := binary.Uvarint(data); n > 0 {
+			return data[:n], data[n:], nil
+		}
+	case wireFixed64:
+		if len(data) >= 8 {
+			return data[:8], data[8:], nil
+		}
+	case wireFixed32:
+		if len(data) >= 4 {
+			return data[:4], data[4:], nil
+		}
+	case wireBytes:
+		if size, n := binary.Uvarint(data); n > 0 && size <= uint64(len(data)-n) {
+			return data[n : n+int(size)], data[n+int(size):], nil
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported wire type %d", wireType)
+	}
+	return nil, nil, io.ErrUnexpectedEOF
+}
+
+// validate returns an error if 'data' isn't a serialized 'm'
+func (m *protoMessage) validate(data []byte) error {
+	seen := make(map[uint64]bool)
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("%s: invalid field tag", m.name)
+		}
+		data = data[n:]
+		number, wireType := tag>>3, tag&7
+		field, ok := m.fields[number]
+		if !ok {
+			return fmt.Errorf("%s: unknown field %d", m.name, number)
+		}
+		var value []byte
+		var err error
+		if value, data, err = next(data, wireType); err != nil {
+			return fmt.Errorf("%s.%s: %v", m.name, field.name, err)
+		}
+		seen[number] = true
+		if wireType == field.wireType() {
+			if err := field.validate(value, m.checkUTF8); err != nil {
+				return fmt.Errorf("%s.%s: %v", m.name, field.name, err)
+			}
+			continue
+		}
+		// Repeated scalars may be packed
+		if wireType != wireBytes || !field.repeated || field.wireType() == wireBytes {
+			return fmt.Errorf("%s.%s: wire type %d doesn't match the field's type %s",
+				m.name, field.name, wireType, field.typ)
+		}
+		for len(value) > 0 {
+			if _, value, err = next(value, field.wireType()); err != nil {
+				return fmt.Errorf("%s.%s: invalid packed value: %v", m.name, field.name, err)
+			}
+		}
+	}
+	for _, field := range m.required {
+		if !seen[field.number] {
+			return fmt.Errorf("%s: missing required field %s", m.name, field.name)
+		}
+	}
+	return nil
+}
+
+// validate returns an error if 'value' isn't a valid value of 'f'
+func (f *protoField) validate(value []byte, checkUTF8 bool) error {
+	switch {
+	case f.message != nil:
+		return f.message.validate(value)
+	case f.typ == descriptor.FieldDescriptorProto_TYPE_STRING && checkUTF8 && !utf8.Valid(value):
+		return fmt.Errorf("string is not valid UTF-8")
+	}
+	return nil
+}
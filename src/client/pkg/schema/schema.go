@@ -0,0 +1,90 @@
+// Package schema validates the content of files against the schemas of the
+// repos that they're in (see pfs.RepoSchema).
+package schema
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	globlib "github.com/gobwas/glob"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+)
+
+// Validator validates files against a pfs.RepoSchema
+type Validator struct {
+	glob     globlib.Glob // nil if the schema applies to every file
+	validate func(io.Reader) error
+}
+
+// NewValidator returns a Validator for 's', or an error if 's' isn't a valid
+// schema
+func NewValidator(s *pfs.RepoSchema) (*Validator, error) {
+	v := &Validator{}
+	if s.Glob != "" {
+		pattern := s.Glob
+		if !strings.HasPrefix(pattern, "/") {
+			pattern = "/" + pattern
+		}
+		g, err := globlib.Compile(pattern, '/')
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %v", s.Glob, err)
+		}
+		v.glob = g
+	}
+	var formats int
+	var err error
+	if s.Json != nil {
+		formats++
+		v.validate, err = newJSONValidator(s.Json)
+	}
+	if s.Csv != nil {
+		formats++
+		v.validate, err = newCSVValidator(s.Csv)
+	}
+	if s.Proto != nil {
+		formats++
+		v.validate, err = newProtoValidator(s.Proto)
+	}
+	if formats != 1 {
+		return nil, fmt.Errorf("exactly one of json, csv and proto must be set in a repo schema")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Matches returns true if the schema applies to the file at 'path'
+func (v *Validator) Matches(path string) bool {
+	if v.glob == nil {
+		return true
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return v.glob.Match(path)
+}
+
+// Validate reads a file's content from 'r', and returns an error describing
+// the first part of it that doesn't match the schema
+func (v *Validator) Validate(r io.Reader) error {
+	return v.validate(r)
+}
+
+// ErrViolation represents an error where a commit is rejected because files
+// in it don't match its repo's schema. pachd returns it as
+// ErrSchemaViolation.
+type ErrViolation struct {
+	Commit     *pfs.Commit
+	Violations []*pfs.SchemaViolation
+}
+
+func (e ErrViolation) Error() string {
+	first := e.Violations[0]
+	msg := fmt.Sprintf("file %v in commit %v doesn't match the schema of repo %v: %v", first.Path, e.Commit.ID, e.Commit.Repo.Name, first.Error)
+	if len(e.Violations) > 1 {
+		msg += fmt.Sprintf(" (and %d other files don't match it)", len(e.Violations)-1)
+	}
+	return msg
+}
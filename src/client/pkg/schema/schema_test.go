@@ -0,0 +1,137 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+)
+
+func validate(t *testing.T, s *pfs.RepoSchema, content string) error {
+	v, err := NewValidator(s)
+	require.NoError(t, err)
+	return v.Validate(strings.NewReader(content))
+}
+
+func TestNewValidator(t *testing.T) {
+	_, err := NewValidator(&pfs.RepoSchema{})
+	require.YesError(t, err)
+	_, err = NewValidator(&pfs.RepoSchema{
+		Json: &pfs.JSONSchema{Schema: `{}`},
+		Csv:  &pfs.CSVSchema{Columns: []string{"a"}},
+	})
+	require.YesError(t, err)
+	_, err = NewValidator(&pfs.RepoSchema{Json: &pfs.JSONSchema{Schema: `{"type": "object", "oneOf": []}`}})
+	require.YesError(t, err)
+	_, err = NewValidator(&pfs.RepoSchema{Json: &pfs.JSONSchema{Schema: `{"type": "thing"}`}})
+	require.YesError(t, err)
+	_, err = NewValidator(&pfs.RepoSchema{Csv: &pfs.CSVSchema{}})
+	require.YesError(t, err)
+	_, err = NewValidator(&pfs.RepoSchema{Csv: &pfs.CSVSchema{Columns: []string{"a"}, Delimiter: "ab"}})
+	require.YesError(t, err)
+	_, err = NewValidator(&pfs.RepoSchema{Glob: "[", Csv: &pfs.CSVSchema{Columns: []string{"a"}}})
+	require.YesError(t, err)
+
+	v, err := NewValidator(&pfs.RepoSchema{Glob: "data/*.csv", Csv: &pfs.CSVSchema{Columns: []string{"a"}}})
+	require.NoError(t, err)
+	require.True(t, v.Matches("/data/1.csv"))
+	require.True(t, v.Matches("data/1.csv"))
+	require.False(t, v.Matches("/data/1.json"))
+	require.False(t, v.Matches("/data/dir/1.csv"))
+	v, err = NewValidator(&pfs.RepoSchema{Csv: &pfs.CSVSchema{Columns: []string{"a"}}})
+	require.NoError(t, err)
+	require.True(t, v.Matches("/any/file"))
+}
+
+func TestJSON(t *testing.T) {
+	s := &pfs.RepoSchema{Json: &pfs.JSONSchema{Schema: `{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title": "person",
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 1, "pattern": "^[A-Z]"},
+			"age": {"type": "integer", "minimum": 0, "maximum": 150},
+			"role": {"enum": ["admin", "user", null]},
+			"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2}
+		},
+		"required": ["name"],
+		"additionalProperties": false
+	}`}}
+	require.NoError(t, validate(t, s, `{"name": "Ann", "age": 30, "role": "admin", "tags": ["a", "b"]}`))
+	// JSON lines, and files with no values, are valid
+	require.NoError(t, validate(t, s, "{\"name\": \"Ann\", \"age\": 30.0}\n{\"name\": \"Bob\", \"role\": null}\n"))
+	require.NoError(t, validate(t, s, ""))
+
+	for content, expected := range map[string]string{
+		`{"name": "Ann"`:                           "could not parse JSON value 1",
+		`[]`:                                       `JSON value 1 at "/": expected object, got array`,
+		`{"age": 1}`:                               `missing required property "name"`,
+		`{"name": "ann"}`:                          `at "/name": string doesn't match the pattern`,
+		`{"name": ""}`:                             `at "/name": string is shorter`,
+		`{"name": "Ann", "age": 1.5}`:              `at "/age": expected integer, got number`,
+		`{"name": "Ann", "age": -1}`:               `at "/age": -1 is less than the minimum of 0`,
+		`{"name": "Ann", "role": "root"}`:          `at "/role": value must be one of ["admin","user",null]`,
+		`{"name": "Ann", "tags": ["a", 1]}`:        `at "/tags/1": expected string, got number`,
+		`{"name": "Ann", "tags": ["a", "b", "c"]}`: `at "/tags": array has more than the maximum of 2 items`,
+		`{"name": "Ann", "other": 1}`:              `at "/": unexpected property "other"`,
+		"{\"name\": \"Ann\"}\n{\"name\": 1}":       `JSON value 2 at "/name"`,
+	} {
+		err := validate(t, s, content)
+		require.YesError(t, err)
+		require.True(t, strings.Contains(err.Error(), expected), "%q: %v", content, err)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	s := &pfs.RepoSchema{Csv: &pfs.CSVSchema{Columns: []string{"id", "name"}}}
+	require.NoError(t, validate(t, s, "id,name\n1,a\n2,\"b,c\"\n"))
+	require.NoError(t, validate(t, s, "id,name\n"))
+	require.YesError(t, validate(t, s, ""))
+	require.YesError(t, validate(t, s, "id,nom\n1,a\n"))
+	require.YesError(t, validate(t, s, "id\n1\n"))
+	err := validate(t, s, "id,name\n1,a\n2\n")
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "line 3"), err.Error())
+
+	s.Csv.Delimiter = "\t"
+	require.NoError(t, validate(t, s, "id\tname\n1\ta\n"))
+	require.YesError(t, validate(t, s, "id,name\n1,a\n"))
+}
+
+func TestProto(t *testing.T) {
+	fd, _ := descriptor.ForMessage(&pfs.Commit{})
+	set, err := proto.Marshal(&descriptor.FileDescriptorSet{File: []*descriptor.FileDescriptorProto{fd}})
+	require.NoError(t, err)
+	_, err = NewValidator(&pfs.RepoSchema{Proto: &pfs.ProtoSchema{DescriptorSet: set, Message: "pfs.Nonexistent"}})
+	require.YesError(t, err)
+	_, err = NewValidator(&pfs.RepoSchema{Proto: &pfs.ProtoSchema{DescriptorSet: []byte("garbage"), Message: "pfs.Commit"}})
+	require.YesError(t, err)
+
+	commit, err := proto.Marshal(&pfs.Commit{Repo: &pfs.Repo{Name: "repo"}, ID: "id"})
+	require.NoError(t, err)
+	s := &pfs.RepoSchema{Proto: &pfs.ProtoSchema{DescriptorSet: set, Message: "pfs.Commit"}}
+	require.NoError(t, validate(t, s, string(commit)))
+	require.NoError(t, validate(t, s, ""))
+	// Truncated message
+	require.YesError(t, validate(t, s, string(commit[:len(commit)-1])))
+	// Unknown field (3, varint)
+	err = validate(t, s, string(append(append([]byte{}, commit...), 3<<3, 1)))
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "unknown field 3"), err.Error())
+	// Wrong wire type for the nested Repo's name (field 1, varint)
+	err = validate(t, s, string([]byte{1<<3 | 2, 2, 1 << 3, 1}))
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "pfs.Commit.repo: pfs.Repo.name"), err.Error())
+	// Invalid UTF-8 in a proto3 string
+	require.YesError(t, validate(t, s, string([]byte{2<<3 | 2, 1, 0xff})))
+
+	s.Proto.Delimited = true
+	delimited := append(proto.EncodeVarint(uint64(len(commit))), commit...)
+	require.NoError(t, validate(t, s, string(delimited)+string(delimited)))
+	err = validate(t, s, string(delimited)+string(delimited[:len(delimited)-1]))
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "message 2"), err.Error())
+}
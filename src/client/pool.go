@@ -0,0 +1,59 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/resolver"
+)
+
+// poolScheme is the gRPC resolver scheme used to open a pool of connections
+// to pachd (see WithConnectionPool()). A target of the form
+// "pachd-pool:///<size>/<host:port>" resolves to <size> copies of
+// <host:port>, which gRPC's round_robin balancer connects to separately and
+// then alternates RPCs between.
+const poolScheme = "pachd-pool"
+
+func init() {
+	resolver.Register(poolResolverBuilder{})
+}
+
+// poolTarget returns the gRPC dial target for a pool of 'size' connections to
+// 'addr'
+func poolTarget(addr string, size int) string {
+	return fmt.Sprintf("%s:///%d/%s", poolScheme, size, addr)
+}
+
+type poolResolverBuilder struct{}
+
+func (poolResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOption) (resolver.Resolver, error) {
+	parts := strings.SplitN(target.Endpoint, "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("malformed connection pool target %q", target.Endpoint)
+	}
+	size, err := strconv.Atoi(parts[0])
+	if err != nil || size < 1 {
+		return nil, fmt.Errorf("invalid connection pool size in target %q", target.Endpoint)
+	}
+	addrs := make([]resolver.Address, size)
+	for i := range addrs {
+		// The balancer keys connections by their whole resolver.Address, so
+		// distinct metadata makes it open a connection for each copy of
+		// the address
+		addrs[i] = resolver.Address{Addr: parts[1], Metadata: i}
+	}
+	cc.NewAddress(addrs)
+	return poolResolver{}, nil
+}
+
+func (poolResolverBuilder) Scheme() string {
+	return poolScheme
+}
+
+// poolResolver is a no-op, as a pool's addresses never change
+type poolResolver struct{}
+
+func (poolResolver) ResolveNow(resolver.ResolveNowOption) {}
+
+func (poolResolver) Close() {}
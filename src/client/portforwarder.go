@@ -1,60 +1,201 @@
 package client
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/rand"
-	"net/http"
-	"sync"
-	"path"
+	"net"
+	"net/url"
 	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/facebookgo/pidfile"
+	"golang.org/x/net/context"
 
+	v1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/portforward"
-	"k8s.io/client-go/transport/spdy"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
-	pachdLocalPort = 30650
-	samlAcsLocalPort = 30654
-	dashUILocalPort = 30080
+	// DefaultHealthCheckInterval is the default value of
+	// PortForwarder.HealthCheckInterval
+	DefaultHealthCheckInterval = 30 * time.Second
+	// DefaultReadyTimeout is the default value of PortForwarder.ReadyTimeout
+	DefaultReadyTimeout = time.Minute
+
+	pachdLocalPort         = 30650
+	samlAcsLocalPort       = 30654
+	oidcLocalPort          = 30657
+	dashUILocalPort        = 30080
 	dashWebSocketLocalPort = 30081
-	pfsLocalPort = 30652
+	pfsLocalPort           = 30652
+	s3gatewayLocalPort     = 30600
+)
+
+// The default ports of Pachyderm's services on their pods. The Run* methods
+// discover the actual ports from the cluster's service specs, and only fall
+// back to these if discovery fails.
+const (
+	PachdRemotePort         = 650
+	SAMLACSRemotePort       = 654
+	OIDCRemotePort          = 657
+	DashUIRemotePort        = 8080
+	DashWebSocketRemotePort = 8081
+	PFSRemotePort           = 30652
+	S3GatewayRemotePort     = 600
+)
+
+// PodSelection determines which of the pods matching an app's label
+// selector a PortForwarder tunnels to.
+type PodSelection int
+
+const (
+	// RandomPod chooses a random pod. This is the default.
+	RandomPod PodSelection = iota
+	// ReadyPod chooses a random pod among those that are in the Ready
+	// condition and aren't terminating.
+	ReadyPod
+	// NewestReadyPod chooses the most recently created pod among those that
+	// are in the Ready condition and aren't terminating. This is useful
+	// during rolling updates, when old pods may still match the selector.
+	NewestReadyPod
 )
 
 // PortForwarder handles proxying local traffic to a kubernetes pod
 type PortForwarder struct {
-	core corev1.CoreV1Interface
-	client rest.Interface
-	config *rest.Config
-	namespace string
-	stdout io.Writer
-	stderr io.Writer
-	stopChansLock *sync.Mutex
-	stopChans []chan struct{}
-	shutdown bool
+	// PodName, if set, is the name of the pod that tunnels are established
+	// to, regardless of the app they are run for.
+	PodName string
+	// PodSelection is the strategy used to choose a pod when PodName isn't
+	// set.
+	PodSelection PodSelection
+	// FreePortFallback, if set, causes tunnels whose requested local port is
+	// already bound to listen on a free ephemeral port instead of failing.
+	// The Run* methods return the local port that was actually bound.
+	FreePortFallback bool
+	// HealthCheckInterval is how often each tunnel checks that its remote
+	// port is reachable. Health checks are disabled if it is 0.
+	HealthCheckInterval time.Duration
+	// ReadyTimeout is how long the Run* methods wait for the chosen pod to
+	// accept a port forwarding connection (e.g. while it's still starting)
+	// before giving up. If it is 0, the Run* methods don't wait, and fail if
+	// the pod can't be connected to immediately.
+	ReadyTimeout time.Duration
+
+	core        corev1.CoreV1Interface
+	client      rest.Interface
+	config      *rest.Config
+	namespace   string
+	kubeContext string
+	stdout      io.Writer
+	stderr      io.Writer
+	tunnelsLock *sync.Mutex
+	tunnels     []*tunnel
+	shutdown    bool
+	onReconnect func(ReconnectEvent)
+	onHealth    func(TunnelStatus)
+	// heldLock is the pidfile written by Lock(), if any
+	heldLock string
+	// control is the listener on which ServeControl() serves management
+	// requests, if any
+	control net.Listener
+	// proxies are the SOCKS5 proxies started by RunSOCKS5Proxy()
+	proxies []*socksProxy
+}
+
+type portForwarderSettings struct {
+	kubeConfigPath    string
+	kubeContext       string
+	impersonateUser   string
+	impersonateGroups []string
+}
+
+// PortForwarderOption is a creation option that may be passed to
+// NewPortForwarder()
+type PortForwarderOption func(*portForwarderSettings) error
+
+// WithKubeConfig instructs NewPortForwarder to read the kubernetes config at
+// 'path', instead of using the default loading rules ($KUBECONFIG, then
+// ~/.kube/config)
+func WithKubeConfig(path string) PortForwarderOption {
+	return func(settings *portForwarderSettings) error {
+		settings.kubeConfigPath = path
+		return nil
+	}
+}
+
+// WithKubeContext instructs NewPortForwarder to connect to the cluster in the
+// kubernetes context named 'name', instead of the current context
+func WithKubeContext(name string) PortForwarderOption {
+	return func(settings *portForwarderSettings) error {
+		settings.kubeContext = name
+		return nil
+	}
+}
+
+// WithImpersonation instructs NewPortForwarder to act as 'user' (and,
+// optionally, as a member of 'groups') when talking to the kubernetes API
+func WithImpersonation(user string, groups ...string) PortForwarderOption {
+	return func(settings *portForwarderSettings) error {
+		if user == "" {
+			return fmt.Errorf("cannot impersonate an empty user")
+		}
+		settings.impersonateUser = user
+		settings.impersonateGroups = groups
+		return nil
+	}
 }
 
 // NewPortForwarder creates a new port forwarder
-func NewPortForwarder(namespace string, stdout, stderr io.Writer) (*PortForwarder, error) {
+func NewPortForwarder(namespace string, stdout, stderr io.Writer, options ...PortForwarderOption) (*PortForwarder, error) {
 	if namespace == "" {
 		namespace = "default"
 	}
 
+	var settings portForwarderSettings
+	for _, option := range options {
+		if err := option(&settings); err != nil {
+			return nil, err
+		}
+	}
+
 	rules := clientcmd.NewDefaultClientConfigLoadingRules()
-	overrides := &clientcmd.ConfigOverrides{}
+	rules.ExplicitPath = settings.kubeConfigPath
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: settings.kubeContext,
+	}
+	overrides.AuthInfo.Impersonate = settings.impersonateUser
+	overrides.AuthInfo.ImpersonateGroups = settings.impersonateGroups
 	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
 	config, err := kubeConfig.ClientConfig()
 	if err != nil {
 		return nil, err
 	}
 
+	kubeContext := settings.kubeContext
+	if kubeContext == "" {
+		rawConfig, err := kubeConfig.RawConfig()
+		if err != nil {
+			return nil, err
+		}
+		kubeContext = rawConfig.CurrentContext
+	}
+
 	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
@@ -62,148 +203,756 @@ func NewPortForwarder(namespace string, stdout, stderr io.Writer) (*PortForwarde
 
 	core := client.CoreV1()
 
-	return &PortForwarder {
-		core: core,
-		client: core.RESTClient(),
-		config: config,
-		namespace: namespace,
-		stdout: stdout,
-		stderr: stderr,
-		stopChansLock: &sync.Mutex{},
-		stopChans: []chan struct{}{},
-		shutdown: false,
+	return &PortForwarder{
+		core:                core,
+		client:              core.RESTClient(),
+		config:              config,
+		namespace:           namespace,
+		kubeContext:         kubeContext,
+		stdout:              stdout,
+		stderr:              stderr,
+		HealthCheckInterval: DefaultHealthCheckInterval,
+		ReadyTimeout:        DefaultReadyTimeout,
+		tunnelsLock:         &sync.Mutex{},
+		shutdown:            false,
 	}, nil
 }
 
+// ReconnectEvent describes an attempt by a PortForwarder to re-establish a
+// tunnel after its connection to a pod was lost.
+type ReconnectEvent struct {
+	// Selector is the label selector of the pods that the tunnel forwards to
+	Selector   map[string]string
+	LocalPort  int
+	RemotePort int
+	// PodName is the pod that the tunnel was re-established to. It is empty
+	// if the attempt failed.
+	PodName string
+	// Err is the error that caused the reconnect attempt to fail, if any.
+	// Failed attempts are retried with exponential backoff.
+	Err error
+}
+
+// OnReconnect registers a callback that is invoked every time the port
+// forwarder tries to re-establish a dropped tunnel (e.g. because pachd was
+// restarted or its pod was rescheduled).
+func (f *PortForwarder) OnReconnect(cb func(ReconnectEvent)) {
+	f.tunnelsLock.Lock()
+	defer f.tunnelsLock.Unlock()
+	f.onReconnect = cb
+}
+
+// OnHealthChange registers a callback that is invoked every time a tunnel's
+// health check starts failing or starts succeeding again.
+func (f *PortForwarder) OnHealthChange(cb func(TunnelStatus)) {
+	f.tunnelsLock.Lock()
+	defer f.tunnelsLock.Unlock()
+	f.onHealth = cb
+}
+
+// Status returns the current state of each of the port forwarder's tunnels
+func (f *PortForwarder) Status() []TunnelStatus {
+	f.tunnelsLock.Lock()
+	tunnels := append([]*tunnel(nil), f.tunnels...)
+	f.tunnelsLock.Unlock()
+	result := make([]TunnelStatus, len(tunnels))
+	for i, t := range tunnels {
+		result[i] = t.status()
+	}
+	return result
+}
+
+// Stats summarizes the traffic forwarded by all of a PortForwarder's tunnels
+type Stats struct {
+	// ActiveTunnels is the number of tunnels that are currently open
+	ActiveTunnels int
+	// HealthyTunnels is the number of open tunnels that are healthy
+	HealthyTunnels    int
+	ActiveConnections int64
+	TotalConnections  int64
+	BytesIn           int64
+	BytesOut          int64
+}
+
+// Stats returns the combined traffic statistics of the port forwarder's open
+// tunnels. Per-port statistics are available via Status().
+func (f *PortForwarder) Stats() Stats {
+	var stats Stats
+	for _, s := range f.Status() {
+		stats.ActiveTunnels++
+		if s.Healthy {
+			stats.HealthyTunnels++
+		}
+		stats.ActiveConnections += s.ActiveConnections
+		stats.TotalConnections += s.TotalConnections
+		stats.BytesIn += s.BytesIn
+		stats.BytesOut += s.BytesOut
+	}
+	return stats
+}
+
 // Run starts the port forwarder. Returns after initialization is begun,
-// returning any initialization errors.
-func (f *PortForwarder) Run(appName string, localPort, remotePort int) error {
-	podNameSelector := map[string]string {
+// returning the bound local port and any initialization errors. If the
+// connection to the pod is lost after initialization, Run's tunnel is
+// re-established in the background to a pod matching the same selector.
+func (f *PortForwarder) Run(appName string, localPort, remotePort int) (int, error) {
+	return f.RunWithContext(context.Background(), appName, localPort, remotePort)
+}
+
+// RunWithContext is like Run, but the tunnel (and any attempts to reconnect
+// it) is torn down when 'ctx' is cancelled, so that port forwarding can be
+// tied to the lifetime of a request or an embedding program.
+func (f *PortForwarder) RunWithContext(ctx context.Context, appName string, localPort, remotePort int) (int, error) {
+	return f.runForSelector(ctx, map[string]string{
 		"suite": "pachyderm",
-		"app": appName,
+		"app":   appName,
+	}, localPort, remotePort)
+}
+
+// RunForSelector is like Run, but forwards to a pod matching an arbitrary
+// label selector, rather than to one of Pachyderm's own pods. This can be
+// used to reach services deployed alongside Pachyderm, such as custom dash
+// builds or sidecars.
+func (f *PortForwarder) RunForSelector(selector map[string]string, localPort, remotePort int) (int, error) {
+	return f.runForSelector(context.Background(), selector, localPort, remotePort)
+}
+
+func (f *PortForwarder) runForSelector(ctx context.Context, selector map[string]string, localPort, remotePort int) (int, error) {
+	return f.runTunnel(ctx, &tunnel{
+		forwarder:  f,
+		selector:   selector,
+		localPort:  localPort,
+		remotePort: remotePort,
+		stopChan:   make(chan struct{}),
+	})
+}
+
+// runTunnel opens 't' and registers it with the port forwarder, returning the
+// tunnel's bound local port (or 0, if it listens on a unix socket)
+func (f *PortForwarder) runTunnel(ctx context.Context, t *tunnel) (int, error) {
+	if len(t.selector) == 0 && t.pod == "" {
+		return 0, fmt.Errorf("cannot forward to pods matching an empty selector")
+	}
+
+	// Bind the tunnel's listener before the tunnel is registered, as it may
+	// be closed by other goroutines (e.g. Close()) from then on
+	if !t.proxied {
+		if err := t.listen(f.FreePortFallback); err != nil {
+			return 0, err
+		}
+	}
+
+	// Ensure that the port forwarder isn't already shutdown, and append the
+	// tunnel so this forwarder can be closed
+	f.tunnelsLock.Lock()
+	if f.shutdown {
+		f.tunnelsLock.Unlock()
+		t.close()
+		return 0, fmt.Errorf("port forwarder is shutdown")
+	}
+	f.tunnels = append(f.tunnels, t)
+	// Make Close() wait until t's goroutines have all been started
+	t.wg.Add(1)
+	f.tunnelsLock.Unlock()
+	defer t.wg.Done()
+
+	t.goRun(func() {
+		select {
+		case <-ctx.Done():
+			f.stop(t)
+		case <-t.stopChan:
+		}
+	})
+
+	if err := t.connectWhenReady(ctx, f.ReadyTimeout); err != nil {
+		f.stop(t)
+		return 0, err
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if isClosed(t.stopChan) {
+		return 0, fmt.Errorf("port forwarder is shutdown")
+	}
+	if !t.proxied {
+		t.goRun(t.serve)
+	}
+	t.goRun(t.monitor)
+	if f.HealthCheckInterval > 0 {
+		t.goRun(func() { t.healthCheck(f.HealthCheckInterval) })
+	}
+	return t.localPort, nil
+}
+
+// choosePod returns the name of the pod that a tunnel for the pods matching
+// 'selector' should be established to, according to f.PodName and
+// f.PodSelection
+func (f *PortForwarder) choosePod(selector map[string]string) (string, error) {
+	if f.PodName != "" {
+		return f.PodName, nil
 	}
 
 	podList, err := f.core.Pods(f.namespace).List(metav1.ListOptions{
-		LabelSelector: metav1.FormatLabelSelector(metav1.SetAsLabelSelector(podNameSelector)),
+		LabelSelector: metav1.FormatLabelSelector(metav1.SetAsLabelSelector(selector)),
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "ListOptions",
 			APIVersion: "v1",
 		},
 	})
 	if err != nil {
-		return err
+		return "", err
 	}
 	if len(podList.Items) == 0 {
-		return fmt.Errorf("No pods found for app %s", appName)
+		if app, ok := selector["app"]; ok {
+			return "", fmt.Errorf("No pods found for app %s", app)
+		}
+		return "", fmt.Errorf("No pods found for selector %s", metav1.FormatLabelSelector(metav1.SetAsLabelSelector(selector)))
 	}
+	return selectPod(podList.Items, f.PodSelection)
+}
 
-	// Choose a random pod
-	podName := podList.Items[rand.Intn(len(podList.Items))].Name
+// servicePort looks up the pod port that the port named 'portName' of the
+// kubernetes service 'serviceName' is forwarded to. Tunnels are established
+// to pods directly, so ports that the service doesn't expose, or that it
+// routes to named container ports, are looked up in the service's pods
+// (whose app is 'serviceName'). It returns whether such a port exists.
+func (f *PortForwarder) servicePort(serviceName, portName string) (int, bool, error) {
+	service, err := f.core.Services(f.namespace).Get(serviceName, metav1.GetOptions{})
+	if err != nil {
+		if !kubeerrors.IsNotFound(err) {
+			return 0, false, err
+		}
+		service = nil
+	}
+	if port, ok := lookupServicePort(service, nil, portName); ok {
+		return port, true, nil
+	}
+	podList, err := f.core.Pods(f.namespace).List(metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(metav1.SetAsLabelSelector(map[string]string{
+			"suite": "pachyderm",
+			"app":   serviceName,
+		})),
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	port, ok := lookupServicePort(service, podList.Items, portName)
+	return port, ok, nil
+}
 
-	url := f.client.Post().
-		Resource("pods").
-		Namespace(f.namespace).
-		Name(podName).
-		SubResource("portforward").
-		URL()
+// lookupServicePort returns the pod port that the port named 'portName' of
+// 'service' (which may be nil, if there's no such service) is forwarded to,
+// resolving named target ports against the container ports of 'pods'. If the
+// service doesn't have the port, it returns the pods' container port with
+// that name, if any.
+func lookupServicePort(service *v1.Service, pods []v1.Pod, portName string) (int, bool) {
+	containerPortName := portName
+	if service != nil {
+		for _, port := range service.Spec.Ports {
+			if port.Name != portName {
+				continue
+			}
+			switch {
+			case port.TargetPort.Type == intstr.String:
+				containerPortName = port.TargetPort.StrVal
+			case port.TargetPort.IntVal != 0:
+				return int(port.TargetPort.IntVal), true
+			default:
+				return int(port.Port), true // the target port defaults to the port
+			}
+			break
+		}
+	}
+	for _, pod := range pods {
+		for _, container := range pod.Spec.Containers {
+			for _, port := range container.Ports {
+				if port.Name == containerPortName {
+					return int(port.ContainerPort), true
+				}
+			}
+		}
+	}
+	return 0, false
+}
 
-	transport, upgrader, err := spdy.RoundTripperFor(f.config)
-	if err != nil {
-		return err
+// remotePort discovers the pod port that the service 'serviceName' routes its
+// port named 'portName' to, so that forwarding keeps working in deployments
+// with customized ports. If the port can't be discovered (e.g. because the
+// port forwarder isn't allowed to read services), 'defaultPort' is returned.
+func (f *PortForwarder) remotePort(serviceName, portName string, defaultPort int) int {
+	if port, ok, err := f.servicePort(serviceName, portName); err == nil && ok {
+		return port
 	}
+	return defaultPort
+}
 
-	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", url)
-	ports := []string{fmt.Sprintf("%d:%d", localPort, remotePort)}
-	readyChan := make(chan struct{}, 1)
-	stopChan := make(chan struct{}, 1)
+// optionalRemotePort is like remotePort, but for ports that the cluster may
+// not serve at all (e.g. pachd's auth callbacks). It returns 0 if neither
+// the service nor its pods expose the port, in which case it shouldn't be
+// forwarded. If the port forwarder isn't allowed to look the port up,
+// 'defaultPort' is returned, as in remotePort.
+func (f *PortForwarder) optionalRemotePort(serviceName, portName string, defaultPort int) (int, error) {
+	port, ok, err := f.servicePort(serviceName, portName)
+	switch {
+	case kubeerrors.IsForbidden(err):
+		return defaultPort, nil
+	case err != nil:
+		return 0, fmt.Errorf("could not look up port %s of %s: %v", portName, serviceName, err)
+	case !ok:
+		return 0, nil
+	}
+	return port, nil
+}
 
-	// Ensure that the port forwarder isn't already shutdown, and append the
-	// shutdown channel so this forwarder can be closed
-	f.stopChansLock.Lock()
-	if f.shutdown {
-		f.stopChansLock.Unlock()
-		return fmt.Errorf("port forwarder is shutdown")
+// podEvents returns a description of the most recent kubernetes events
+// involving the pod 'podName' (e.g. image pull failures or failed scheduling),
+// suitable for appending to an error message, or "" if there are none
+func (f *PortForwarder) podEvents(podName string) string {
+	if podName == "" {
+		return ""
+	}
+	events, err := f.core.Events(f.namespace).List(metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("involvedObject.name", podName).String(),
+	})
+	if err != nil || len(events.Items) == 0 {
+		return ""
+	}
+	items := events.Items
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].LastTimestamp.Before(&items[j].LastTimestamp)
+	})
+	const maxEvents = 5
+	if len(items) > maxEvents {
+		items = items[len(items)-maxEvents:]
 	}
-	f.stopChans = append(f.stopChans, stopChan)
-	f.stopChansLock.Unlock()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "\nrecent events for pod %s:", podName)
+	for _, event := range items {
+		fmt.Fprintf(&buf, "\n  %s\t%s\t%s", event.Type, event.Reason, event.Message)
+	}
+	return buf.String()
+}
 
-	fw, err := portforward.New(dialer, ports, stopChan, readyChan, f.stdout, f.stderr)
-	if err != nil {
-		return err
+// selectPod picks a pod from 'pods' according to 'strategy'
+func selectPod(pods []v1.Pod, strategy PodSelection) (string, error) {
+	if strategy == RandomPod {
+		return pods[rand.Intn(len(pods))].Name, nil
+	}
+	var ready []v1.Pod
+	for _, pod := range pods {
+		if pod.DeletionTimestamp == nil && isPodReady(pod) {
+			ready = append(ready, pod)
+		}
+	}
+	if len(ready) == 0 {
+		return "", fmt.Errorf("none of the %d matching pods are ready", len(pods))
 	}
+	switch strategy {
+	case ReadyPod:
+		return ready[rand.Intn(len(ready))].Name, nil
+	case NewestReadyPod:
+		newest := ready[0]
+		for _, pod := range ready[1:] {
+			if newest.CreationTimestamp.Before(&pod.CreationTimestamp) {
+				newest = pod
+			}
+		}
+		return newest.Name, nil
+	default:
+		return "", fmt.Errorf("unrecognized pod selection strategy: %d", strategy)
+	}
+}
 
-	errChan := make(chan error, 1)
-	go func() { errChan <- fw.ForwardPorts() }()
+func isPodReady(pod v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
 
-	select {
-	case err = <- errChan:
-		return fmt.Errorf("port forwarding failed: %v", err)
-	case <- fw.Ready:
-		return nil
+func (f *PortForwarder) notifyReconnect(e ReconnectEvent) {
+	f.tunnelsLock.Lock()
+	cb := f.onReconnect
+	f.tunnelsLock.Unlock()
+	if cb != nil {
+		cb(e)
+	}
+}
+
+func (f *PortForwarder) notifyHealthChange(s TunnelStatus) {
+	f.tunnelsLock.Lock()
+	cb := f.onHealth
+	f.tunnelsLock.Unlock()
+	if cb != nil {
+		cb(s)
 	}
 }
 
+// stop tears down 't', if it hasn't been torn down already
+func (f *PortForwarder) stop(t *tunnel) {
+	f.tunnelsLock.Lock()
+	defer f.tunnelsLock.Unlock()
+	for i, other := range f.tunnels {
+		if other == t {
+			f.tunnels = append(f.tunnels[:i], f.tunnels[i+1:]...)
+			t.close()
+			return
+		}
+	}
+}
+
+// RunOnSocket is like Run, but listens for local connections on a unix domain
+// socket at 'socketPath' instead of a TCP port. The socket is only accessible
+// by the current user, and is removed when the port forwarder is closed.
+func (f *PortForwarder) RunOnSocket(appName string, socketPath string, remotePort int) error {
+	_, err := f.runTunnel(context.Background(), &tunnel{
+		forwarder: f,
+		selector: map[string]string{
+			"suite": "pachyderm",
+			"app":   appName,
+		},
+		socketPath: socketPath,
+		remotePort: remotePort,
+		stopChan:   make(chan struct{}),
+	})
+	return err
+}
+
+// RunForDaemonOnSocket creates a port forwarder for the pachd daemon that
+// listens on the unix domain socket at 'socketPath'.
+func (f *PortForwarder) RunForDaemonOnSocket(socketPath string) error {
+	return f.RunOnSocket("pachd", socketPath, f.remotePort("pachd", "api-grpc-port", PachdRemotePort))
+}
+
 // RunForDaemon creates a port forwarder for the pachd daemon.
-func (f *PortForwarder) RunForDaemon(localPort int) error {
+func (f *PortForwarder) RunForDaemon(localPort int) (int, error) {
 	if localPort == 0 {
 		localPort = pachdLocalPort
 	}
-	return f.Run("pachd", localPort, 650)
+	return f.Run("pachd", localPort, f.remotePort("pachd", "api-grpc-port", PachdRemotePort))
 }
 
-// RunForSAMLACS creates a port forwarder for SAML ACS.
-func (f *PortForwarder) RunForSAMLACS(localPort int) error {
+// RunForSAMLACS creates a port forwarder for SAML ACS. If neither pachd's
+// service nor its pods expose a SAML port ("saml-port"), no port is
+// forwarded, and RunForSAMLACS returns a local port of 0 and no error.
+func (f *PortForwarder) RunForSAMLACS(localPort int) (int, error) {
+	remotePort, err := f.optionalRemotePort("pachd", "saml-port", SAMLACSRemotePort)
+	if err != nil || remotePort == 0 {
+		return 0, err
+	}
 	if localPort == 0 {
 		localPort = samlAcsLocalPort
 	}
 	// TODO(ys): using a suite selector because the original code had that.
 	// check if it is necessary.
-	return f.Run("pachd", localPort, 654)
+	return f.Run("pachd", localPort, remotePort)
+}
+
+// RunForOIDC creates a port forwarder for pachd's OIDC callback. If neither
+// pachd's service nor its pods expose an OIDC port ("oidc-port"), no port is
+// forwarded, and RunForOIDC returns a local port of 0 and no error.
+func (f *PortForwarder) RunForOIDC(localPort int) (int, error) {
+	remotePort, err := f.optionalRemotePort("pachd", "oidc-port", OIDCRemotePort)
+	if err != nil || remotePort == 0 {
+		return 0, err
+	}
+	if localPort == 0 {
+		localPort = oidcLocalPort
+	}
+	return f.Run("pachd", localPort, remotePort)
 }
 
 // RunForDashUI creates a port forwarder for the dash UI.
-func (f *PortForwarder) RunForDashUI(localPort int) error {
+func (f *PortForwarder) RunForDashUI(localPort int) (int, error) {
 	if localPort == 0 {
 		localPort = dashUILocalPort
 	}
-	return f.Run("dash", localPort, 8080)
+	return f.Run("dash", localPort, f.remotePort("dash", "dash-http", DashUIRemotePort))
 }
 
 // RunForDashWebSocket creates a port forwarder for the dash websocket.
-func (f *PortForwarder) RunForDashWebSocket(localPort int) error {
+func (f *PortForwarder) RunForDashWebSocket(localPort int) (int, error) {
 	if localPort == 0 {
 		localPort = dashWebSocketLocalPort
 	}
-	return f.Run("dash", localPort, 8081)
+	return f.Run("dash", localPort, f.remotePort("dash", "grpc-proxy-http", DashWebSocketRemotePort))
 }
 
 // RunForPFS creates a port forwarder for PFS over HTTP.
-func (f *PortForwarder) RunForPFS(localPort int) error {
+func (f *PortForwarder) RunForPFS(localPort int) (int, error) {
 	if localPort == 0 {
 		localPort = pfsLocalPort
 	}
-	return f.Run("pachd", localPort, 30652)
+	return f.Run("pachd", localPort, f.remotePort("pachd", "api-http-port", PFSRemotePort))
+}
+
+// RunForS3Gateway creates a port forwarder for pachd's S3 gateway. If pachd's
+// service doesn't expose an S3 gateway port, no port is forwarded, and
+// RunForS3Gateway returns a local port of 0 and no error.
+func (f *PortForwarder) RunForS3Gateway(localPort int) (int, error) {
+	remotePort, err := f.optionalRemotePort("pachd", "s3gateway-port", S3GatewayRemotePort)
+	if err != nil || remotePort == 0 {
+		return 0, err
+	}
+	if localPort == 0 {
+		localPort = s3gatewayLocalPort
+	}
+	return f.Run("pachd", localPort, remotePort)
+}
+
+// StandardPorts holds a local port for each of the standard Pachyderm
+// services forwarded by RunAll(). When passed to RunAll, a port of 0 means
+// that the service's default local port is used.
+type StandardPorts struct {
+	Pachd int
+	// PachdSocket, if set, is the path of a unix socket that pachd is
+	// forwarded to instead of the local port 'Pachd'
+	PachdSocket   string
+	SAMLACS       int
+	OIDC          int
+	DashUI        int
+	DashWebSocket int
+	PFS           int
+	S3Gateway     int
+}
+
+// RunAllResult describes the tunnels opened by RunAll()
+type RunAllResult struct {
+	// Ports holds the local port bound for each service. A port is 0 if the
+	// service wasn't forwarded, because of an error or because the cluster
+	// doesn't expose it.
+	Ports StandardPorts
+	// Errors holds the error encountered forwarding each service that
+	// couldn't be forwarded, keyed by the name of its Ports field (e.g.
+	// "Pachd")
+	Errors map[string]error
+}
+
+// Err returns one of the errors in r.Errors, or nil if every service was
+// forwarded successfully
+func (r *RunAllResult) Err() error {
+	for _, name := range []string{"Pachd", "SAMLACS", "OIDC", "DashUI", "DashWebSocket", "PFS", "S3Gateway"} {
+		if err, ok := r.Errors[name]; ok {
+			return fmt.Errorf("could not forward %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// RunAll concurrently forwards all of the standard Pachyderm services (pachd,
+// SAML ACS, OIDC, the dash UI and websocket, PFS over HTTP and the S3
+// gateway) to the local ports in 'ports'. Services that fail to be forwarded
+// don't prevent the others from being forwarded; their errors are reported in
+// the result.
+func (f *PortForwarder) RunAll(ports StandardPorts) *RunAllResult {
+	result := &RunAllResult{Errors: make(map[string]error)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	run := func(name string, bound *int, runFn func() (int, error)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			port, err := runFn()
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Errors[name] = err
+				return
+			}
+			*bound = port
+		}()
+	}
+
+	if ports.PachdSocket != "" {
+		run("Pachd", new(int), func() (int, error) {
+			return 0, f.RunForDaemonOnSocket(ports.PachdSocket)
+		})
+		result.Ports.PachdSocket = ports.PachdSocket
+	} else {
+		run("Pachd", &result.Ports.Pachd, func() (int, error) { return f.RunForDaemon(ports.Pachd) })
+	}
+	run("SAMLACS", &result.Ports.SAMLACS, func() (int, error) { return f.RunForSAMLACS(ports.SAMLACS) })
+	run("OIDC", &result.Ports.OIDC, func() (int, error) { return f.RunForOIDC(ports.OIDC) })
+	run("DashUI", &result.Ports.DashUI, func() (int, error) { return f.RunForDashUI(ports.DashUI) })
+	run("DashWebSocket", &result.Ports.DashWebSocket, func() (int, error) { return f.RunForDashWebSocket(ports.DashWebSocket) })
+	run("PFS", &result.Ports.PFS, func() (int, error) { return f.RunForPFS(ports.PFS) })
+	run("S3Gateway", &result.Ports.S3Gateway, func() (int, error) { return f.RunForS3Gateway(ports.S3Gateway) })
+	wg.Wait()
+
+	if _, ok := result.Errors["Pachd"]; ok {
+		result.Ports.PachdSocket = ""
+	}
+	return result
+}
+
+// PortForwardLock describes a pidfile written by PortForwarder.Lock()
+type PortForwardLock struct {
+	// Path is the location of the pidfile
+	Path        string
+	KubeContext string
+	Namespace   string
+	// PID is the process ID of the port forwarder holding the lock
+	PID int
+	// Stale is true if the process holding the lock is no longer running
+	Stale bool
+}
+
+// noKubeContextDir is the directory in portForwardLockDir() that holds the
+// pidfiles of port forwarders with no kube context (e.g. because the kube
+// config doesn't set a current context)
+const noKubeContextDir = "_default"
+
+// portForwardLockDir returns the directory containing port forwarding
+// pidfiles. Pidfiles are stored at <dir>/<kube context>/<namespace>.pid, so
+// that port forwarding to several clusters or namespaces can run at once.
+func portForwardLockDir() string {
+	return path.Join(os.Getenv("HOME"), ".pachyderm/port-forward")
+}
+
+func (f *PortForwarder) pidfilePath() string {
+	contextDir := noKubeContextDir
+	if f.kubeContext != "" {
+		contextDir = url.PathEscape(f.kubeContext)
+	}
+	return path.Join(portForwardLockDir(), contextDir, f.namespace+".pid")
 }
 
 // Lock uses pidfiles to ensure that only one port forwarder is running across
-// one or more `pachctl` instances
+// one or more `pachctl` instances for the forwarder's kube context and
+// namespace
 func (f *PortForwarder) Lock() error {
-	pidfile.SetPidfilePath(path.Join(os.Getenv("HOME"), ".pachyderm/port-forward.pid"))
-	return pidfile.Write()
+	lockPath := f.pidfilePath()
+	pidfile.SetPidfilePath(lockPath)
+	if pid, err := pidfile.Read(); err == nil && pid != os.Getpid() && processRunning(pid) {
+		return fmt.Errorf("port forwarding to namespace %q in context %q is already running (pid %d); if this is wrong, remove %s", f.namespace, f.kubeContext, pid, lockPath)
+	}
+	if err := pidfile.Write(); err != nil {
+		return err
+	}
+	f.tunnelsLock.Lock()
+	defer f.tunnelsLock.Unlock()
+	f.heldLock = lockPath
+	return nil
 }
 
-// Close shuts down port forwarding.
-func (f *PortForwarder) Close() {
-	f.stopChansLock.Lock()
-	defer f.stopChansLock.Unlock()
+// ListPortForwardLocks returns the pidfiles written by port forwarders on
+// this machine, including stale ones left behind by processes that exited
+// without cleaning up
+func ListPortForwardLocks() ([]PortForwardLock, error) {
+	var locks []PortForwardLock
+	dir := portForwardLockDir()
+	contexts, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	for _, contextDir := range contexts {
+		if !contextDir.IsDir() {
+			continue
+		}
+		kubeContext, err := url.PathUnescape(contextDir.Name())
+		if err != nil {
+			continue // not written by Lock()
+		}
+		if contextDir.Name() == noKubeContextDir {
+			kubeContext = ""
+		}
+		files, err := ioutil.ReadDir(path.Join(dir, contextDir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if file.IsDir() || path.Ext(file.Name()) != ".pid" {
+				continue
+			}
+			lockPath := path.Join(dir, contextDir.Name(), file.Name())
+			data, err := ioutil.ReadFile(lockPath)
+			if err != nil {
+				return nil, err
+			}
+			pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil {
+				continue // not written by Lock()
+			}
+			locks = append(locks, PortForwardLock{
+				Path:        lockPath,
+				KubeContext: kubeContext,
+				Namespace:   strings.TrimSuffix(file.Name(), ".pid"),
+				PID:         pid,
+				Stale:       !processRunning(pid),
+			})
+		}
+	}
+	return locks, nil
+}
 
-	if f.shutdown {
-		panic("port forwarder already shutdown")
+// ReleasePortForwardLock removes the pidfile described by 'lock', allowing
+// another port forwarder to run for its kube context and namespace. Unless
+// 'force' is set, only stale locks are released.
+func ReleasePortForwardLock(lock PortForwardLock, force bool) error {
+	if !force && processRunning(lock.PID) {
+		return fmt.Errorf("port forwarding lock %s is held by running process %d", lock.Path, lock.PID)
+	}
+	if err := os.Remove(lock.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// processRunning returns true if a process with the given pid exists
+func processRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
 	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
 
+// Close shuts down port forwarding, and waits for all of the port
+// forwarder's tunnels to exit. It is safe to call Close more than once (calls
+// after the first have no effect).
+func (f *PortForwarder) Close() error {
+	f.tunnelsLock.Lock()
+	if f.shutdown {
+		f.tunnelsLock.Unlock()
+		return nil
+	}
 	f.shutdown = true
+	tunnels := f.tunnels
+	f.tunnels = nil
+	heldLock := f.heldLock
+	control := f.control
+	proxies := f.proxies
+	f.proxies = nil
+	f.tunnelsLock.Unlock()
+
+	if control != nil {
+		control.Close()
+	}
+	// stop the proxies first, so that they don't open new tunnels. Their
+	// connections are served by tunnels, and end when the tunnels are closed.
+	for _, p := range proxies {
+		p.listener.Close()
+	}
+
+	for _, t := range tunnels {
+		t.close()
+	}
+	for _, t := range tunnels {
+		t.wg.Wait()
+	}
+	for _, p := range proxies {
+		p.wg.Wait()
+	}
 
-	for _, stopChan := range f.stopChans {
-		close(stopChan)
+	if heldLock != "" {
+		if err := os.Remove(heldLock); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not remove port forwarding pidfile: %v", err)
+		}
 	}
+	return nil
 }
@@ -0,0 +1,218 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"time"
+)
+
+// controlTimeout bounds requests made to a running port forwarder's control
+// socket
+const controlTimeout = 10 * time.Second
+
+// Forward describes a tunnel opened by a port forwarder running on this
+// machine, as reported by ListForwards()
+type Forward struct {
+	KubeContext string
+	Namespace   string
+	// PID is the process ID of the port forwarder serving the tunnel
+	PID        int
+	Selector   map[string]string
+	PodName    string
+	LocalPort  int
+	SocketPath string
+	RemotePort int
+	BytesIn    int64
+	BytesOut   int64
+	Healthy    bool
+	// LastError is the text of the most recent error encountered by the
+	// tunnel, if any
+	LastError string
+}
+
+// controlSocketPath returns the path of the unix socket on which the port
+// forwarder serves management requests. It sits alongside the forwarder's
+// pidfile.
+func (f *PortForwarder) controlSocketPath() string {
+	return path.Join(portForwardLockDir(), url.PathEscape(f.kubeContext), f.namespace+".sock")
+}
+
+// ServeControl starts serving management requests (used by ListForwards and
+// StopForward) on a unix socket in ~/.pachyderm/port-forward, so that other
+// processes can inspect and stop this port forwarder's tunnels. It should be
+// called after Lock(), and stops serving when the port forwarder is closed.
+func (f *PortForwarder) ServeControl() error {
+	socketPath := f.controlSocketPath()
+	if err := os.MkdirAll(path.Dir(socketPath), 0700); err != nil {
+		return err
+	}
+	// a control socket left behind by a previous forwarder for this context
+	// and namespace is stale, as this forwarder holds the lock
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	t := &tunnel{socketPath: socketPath}
+	if err := t.listenUnix(); err != nil {
+		return err
+	}
+
+	f.tunnelsLock.Lock()
+	if f.shutdown {
+		f.tunnelsLock.Unlock()
+		t.listener.Close()
+		return fmt.Errorf("port forwarder is shutdown")
+	}
+	f.control = t.listener
+	f.tunnelsLock.Unlock()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/forwards", f.handleListForwards)
+	mux.HandleFunc("/stop", f.handleStopForward)
+	go http.Serve(t.listener, mux)
+	return nil
+}
+
+func (f *PortForwarder) handleListForwards(w http.ResponseWriter, r *http.Request) {
+	var forwards []Forward
+	for _, s := range f.Status() {
+		forward := Forward{
+			KubeContext: f.kubeContext,
+			Namespace:   f.namespace,
+			PID:         os.Getpid(),
+			Selector:    s.Selector,
+			PodName:     s.PodName,
+			LocalPort:   s.LocalPort,
+			SocketPath:  s.SocketPath,
+			RemotePort:  s.RemotePort,
+			BytesIn:     s.BytesIn,
+			BytesOut:    s.BytesOut,
+			Healthy:     s.Healthy,
+		}
+		if s.LastError != nil {
+			forward.LastError = s.LastError.Error()
+		}
+		forwards = append(forwards, forward)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(forwards)
+}
+
+func (f *PortForwarder) handleStopForward(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "stop must be a POST request", http.StatusMethodNotAllowed)
+		return
+	}
+	localPort, err := strconv.Atoi(r.FormValue("port"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid port: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := f.StopForward(localPort); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+}
+
+// StopForward closes the tunnel listening on 'localPort', leaving the port
+// forwarder's other tunnels open
+func (f *PortForwarder) StopForward(localPort int) error {
+	f.tunnelsLock.Lock()
+	var found *tunnel
+	for _, t := range f.tunnels {
+		if t.socketPath == "" && !t.proxied && t.localPort == localPort {
+			found = t
+			break
+		}
+	}
+	f.tunnelsLock.Unlock()
+	if found == nil {
+		return fmt.Errorf("no tunnel is listening on port %d", localPort)
+	}
+	f.stop(found)
+	found.wg.Wait()
+	return nil
+}
+
+// controlClient returns an HTTP client that sends requests to the port
+// forwarder control socket at 'socketPath'
+func controlClient(socketPath string) *http.Client {
+	return &http.Client{
+		Timeout: controlTimeout,
+		Transport: &http.Transport{
+			Dial: func(network, addr string) (net.Conn, error) {
+				return net.Dial("unix", socketPath)
+			},
+		},
+	}
+}
+
+// controlSocketForLock returns the control socket of the port forwarder
+// holding 'lock'
+func controlSocketForLock(lock PortForwardLock) string {
+	return path.Join(path.Dir(lock.Path), lock.Namespace+".sock")
+}
+
+// ListForwards returns the tunnels opened by all port forwarders running on
+// this machine that serve management requests (see ServeControl())
+func ListForwards() ([]Forward, error) {
+	locks, err := ListPortForwardLocks()
+	if err != nil {
+		return nil, err
+	}
+	var result []Forward
+	for _, lock := range locks {
+		if lock.Stale {
+			continue
+		}
+		socketPath := controlSocketForLock(lock)
+		if _, err := os.Stat(socketPath); err != nil {
+			continue // this forwarder doesn't serve management requests
+		}
+		resp, err := controlClient(socketPath).Get("http://port-forwarder/forwards")
+		if err != nil {
+			return nil, fmt.Errorf("could not reach port forwarder %d: %v", lock.PID, err)
+		}
+		var forwards []Forward
+		err = json.NewDecoder(resp.Body).Decode(&forwards)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not decode tunnels of port forwarder %d: %v", lock.PID, err)
+		}
+		result = append(result, forwards...)
+	}
+	return result, nil
+}
+
+// StopForward closes the tunnel listening on 'localPort' in the port
+// forwarder running for 'kubeContext' and 'namespace', without stopping the
+// forwarder's other tunnels
+func StopForward(kubeContext, namespace string, localPort int) error {
+	locks, err := ListPortForwardLocks()
+	if err != nil {
+		return err
+	}
+	for _, lock := range locks {
+		if lock.Stale || lock.KubeContext != kubeContext || lock.Namespace != namespace {
+			continue
+		}
+		resp, err := controlClient(controlSocketForLock(lock)).PostForm(
+			"http://port-forwarder/stop", url.Values{"port": {strconv.Itoa(localPort)}})
+		if err != nil {
+			return fmt.Errorf("could not reach port forwarder %d: %v", lock.PID, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			message, _ := ioutil.ReadAll(resp.Body)
+			return fmt.Errorf("could not stop forwarding port %d: %s", localPort, message)
+		}
+		return nil
+	}
+	return fmt.Errorf("no port forwarder is running for namespace %q in context %q", namespace, kubeContext)
+}
@@ -0,0 +1,244 @@
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func testPod(name string, created time.Time, ready bool, terminating bool) v1.Pod {
+	status := v1.ConditionFalse
+	if ready {
+		status = v1.ConditionTrue
+	}
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Status: v1.PodStatus{
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: status}},
+		},
+	}
+	if terminating {
+		now := metav1.Now()
+		pod.DeletionTimestamp = &now
+	}
+	return pod
+}
+
+func TestSelectPod(t *testing.T) {
+	now := time.Now()
+	pods := []v1.Pod{
+		testPod("old", now.Add(-time.Hour), true, false),
+		testPod("terminating", now.Add(-time.Minute), true, true),
+		testPod("unready", now, false, false),
+		testPod("new", now.Add(-2*time.Minute), true, false),
+	}
+
+	name, err := selectPod(pods, NewestReadyPod)
+	require.NoError(t, err)
+	require.Equal(t, "new", name)
+
+	for i := 0; i < 10; i++ {
+		name, err = selectPod(pods, ReadyPod)
+		require.NoError(t, err)
+		require.EqualOneOf(t, []string{"old", "new"}, name)
+	}
+
+	_, err = selectPod(pods[1:3], ReadyPod)
+	require.YesError(t, err)
+
+	name, err = selectPod(pods[2:3], RandomPod)
+	require.NoError(t, err)
+	require.Equal(t, "unready", name)
+}
+
+func testPachdService(ports ...v1.ServicePort) *v1.Service {
+	return &v1.Service{Spec: v1.ServiceSpec{Ports: ports}}
+}
+
+func testPachdPods(ports ...v1.ContainerPort) []v1.Pod {
+	return []v1.Pod{{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: "pachd", Ports: ports}},
+		},
+	}}
+}
+
+func TestLookupSAMLPort(t *testing.T) {
+	// The service routes its port to the pod port of the same number
+	service := testPachdService(
+		v1.ServicePort{Name: "api-grpc-port", Port: 650},
+		v1.ServicePort{Name: "saml-port", Port: 654},
+	)
+	port, ok := lookupServicePort(service, nil, "saml-port")
+	require.True(t, ok)
+	require.Equal(t, 654, port)
+
+	// ...or to another port
+	service.Spec.Ports[1].TargetPort = intstr.FromInt(1654)
+	port, ok = lookupServicePort(service, nil, "saml-port")
+	require.True(t, ok)
+	require.Equal(t, 1654, port)
+
+	// ...or to a named container port
+	service.Spec.Ports[1].TargetPort = intstr.FromString("saml")
+	_, ok = lookupServicePort(service, nil, "saml-port")
+	require.False(t, ok)
+	port, ok = lookupServicePort(service, testPachdPods(v1.ContainerPort{Name: "saml", ContainerPort: 2654}), "saml-port")
+	require.True(t, ok)
+	require.Equal(t, 2654, port)
+
+	// A port that neither the service nor the pods expose isn't found
+	_, ok = lookupServicePort(testPachdService(service.Spec.Ports[0]), testPachdPods(), "saml-port")
+	require.False(t, ok)
+}
+
+func TestLookupOIDCPort(t *testing.T) {
+	service := testPachdService(
+		v1.ServicePort{Name: "api-grpc-port", Port: 650},
+		v1.ServicePort{Name: "saml-port", Port: 654},
+	)
+	pods := testPachdPods(
+		v1.ContainerPort{Name: "api-grpc-port", ContainerPort: 650},
+		v1.ContainerPort{Name: "saml-port", ContainerPort: 654},
+	)
+	// pachd doesn't serve OIDC callbacks
+	_, ok := lookupServicePort(service, pods, "oidc-port")
+	require.False(t, ok)
+	_, ok = lookupServicePort(nil, nil, "oidc-port")
+	require.False(t, ok)
+
+	// Tunnels go to pods, so a container port that the service doesn't
+	// expose is found
+	pods[0].Spec.Containers[0].Ports = append(pods[0].Spec.Containers[0].Ports,
+		v1.ContainerPort{Name: "oidc-port", ContainerPort: 657})
+	port, ok := lookupServicePort(service, pods, "oidc-port")
+	require.True(t, ok)
+	require.Equal(t, 657, port)
+
+	service.Spec.Ports = append(service.Spec.Ports, v1.ServicePort{
+		Name:       "oidc-port",
+		Port:       30657,
+		TargetPort: intstr.FromInt(657),
+	})
+	port, ok = lookupServicePort(service, nil, "oidc-port")
+	require.True(t, ok)
+	require.Equal(t, 657, port)
+}
+
+// testPortForwarder returns a port forwarder whose kubernetes API server
+// ('server') rejects every request, so that its tunnels never connect
+func testPortForwarder(t *testing.T) (f *PortForwarder, server *httptest.Server) {
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	config := &rest.Config{Host: server.URL}
+	client, err := kubernetes.NewForConfig(config)
+	require.NoError(t, err)
+	core := client.CoreV1()
+	return &PortForwarder{
+		PodName:     "pachd-0",
+		core:        core,
+		client:      core.RESTClient(),
+		config:      config,
+		namespace:   "default",
+		stderr:      ioutil.Discard,
+		tunnelsLock: &sync.Mutex{},
+	}, server
+}
+
+func TestRunTunnelCancelled(t *testing.T) {
+	f, server := testPortForwarder(t)
+	defer server.Close()
+	defer f.Close()
+	for i := 0; i < 10; i++ {
+		// The cancelled tunnel is stopped while it's still being opened
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := f.RunWithContext(ctx, "pachd", 0, 650)
+		require.YesError(t, err)
+	}
+	require.Equal(t, 0, len(f.Status()))
+
+	// Closing the port forwarder while tunnels are being opened stops them
+	var eg errgroup.Group
+	for i := 0; i < 10; i++ {
+		eg.Go(func() error {
+			f.RunWithContext(context.Background(), "pachd", 0, 650)
+			return nil
+		})
+	}
+	require.NoError(t, f.Close())
+	require.NoError(t, eg.Wait())
+	require.Equal(t, 0, len(f.Status()))
+}
+
+func TestPortForwardLocks(t *testing.T) {
+	home, err := ioutil.TempDir("", "port-forward-locks")
+	require.NoError(t, err)
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	require.NoError(t, os.Setenv("HOME", home))
+
+	// a forwarder with no kube context, and one whose context isn't a valid
+	// directory name
+	noContext := &PortForwarder{namespace: "default", tunnelsLock: &sync.Mutex{}}
+	require.NoError(t, noContext.Lock())
+	require.Equal(t, path.Join(home, ".pachyderm/port-forward/_default/default.pid"), noContext.heldLock)
+	withContext := &PortForwarder{namespace: "pachyderm", kubeContext: "gke_project/cluster", tunnelsLock: &sync.Mutex{}}
+	require.NoError(t, withContext.Lock())
+
+	locks, err := ListPortForwardLocks()
+	require.NoError(t, err)
+	require.Equal(t, 2, len(locks))
+	require.Equal(t, noContext.heldLock, locks[0].Path)
+	require.Equal(t, "", locks[0].KubeContext)
+	require.Equal(t, "default", locks[0].Namespace)
+	require.Equal(t, os.Getpid(), locks[0].PID)
+	require.False(t, locks[0].Stale)
+	require.Equal(t, withContext.heldLock, locks[1].Path)
+	require.Equal(t, "gke_project/cluster", locks[1].KubeContext)
+	require.Equal(t, "pachyderm", locks[1].Namespace)
+
+	// locks held by running processes are only released by force
+	require.YesError(t, ReleasePortForwardLock(locks[0], false))
+	for _, lock := range locks {
+		require.NoError(t, ReleasePortForwardLock(lock, true))
+	}
+	locks, err = ListPortForwardLocks()
+	require.NoError(t, err)
+	require.Equal(t, 0, len(locks))
+}
+
+func TestClientCloseStopsPortForwarder(t *testing.T) {
+	f, server := testPortForwarder(t)
+	defer server.Close()
+	conn, err := grpc.Dial("localhost:0", grpc.WithInsecure())
+	require.NoError(t, err)
+	// closing the connection again fails, which mustn't leave the port
+	// forwarder running
+	require.NoError(t, conn.Close())
+	c := &APIClient{clientConn: conn, portForwarder: f}
+	require.YesError(t, c.Close())
+	f.tunnelsLock.Lock()
+	defer f.tunnelsLock.Unlock()
+	require.True(t, f.shutdown)
+}
@@ -3,9 +3,14 @@ package client
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"io"
+	"path"
+	"sync"
 	"time"
 
+	"golang.org/x/net/context"
+
 	"github.com/pachyderm/pachyderm/src/client/pfs"
 	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
 	"github.com/pachyderm/pachyderm/src/client/pps"
@@ -48,6 +53,16 @@ const (
 	// PPSWorkerVolume is the name of the volume in which workers store
 	// data.
 	PPSWorkerVolume = "pachyderm-worker"
+	// PPSSharedVolume is the name of the volume that a worker's user container
+	// shares with the pipeline's init containers and sidecars, and
+	// PPSSharedPrefix is where it's mounted
+	PPSSharedVolume = "pach-shared"
+	PPSSharedPrefix = "/pach-shared"
+	// PPSSecretsPrefix is where the kubernetes secrets that are loaded into
+	// environment variables are mounted, so that workers can pick up their
+	// new values when they're rotated. The secret named 'XXX' is mounted at
+	// '/pach-secrets/XXX'.
+	PPSSecretsPrefix = "/pach-secrets"
 	// PPSWorkerUserContainerName is the name of the container that runs
 	// the user code to process data.
 	PPSWorkerUserContainerName = "user"
@@ -151,6 +166,26 @@ func NewUnionInput(input ...*pps.Input) *pps.Input {
 	}
 }
 
+// NewJoinInput returns an input which joins other inputs (which must be PFS
+// inputs with JoinOn set) on their files' keys. That means that each
+// combination of files with the same key, one from each input, will be seen
+// by the job / pipeline.
+func NewJoinInput(input ...*pps.Input) *pps.Input {
+	return &pps.Input{
+		Join: input,
+	}
+}
+
+// NewGroupInput returns an input which groups the files in other inputs
+// (which must be PFS inputs with GroupBy set) by their keys. That means that
+// all of the files with the same key will be seen together by the job /
+// pipeline.
+func NewGroupInput(input ...*pps.Input) *pps.Input {
+	return &pps.Input{
+		Group: input,
+	}
+}
+
 // NewCronInput returns an input which will trigger based on a timed schedule.
 // It uses cron syntax to specify the schedule. The input will be exposed to
 // jobs as `/pfs/<name>/time` which will contain a timestamp.
@@ -238,6 +273,12 @@ func (c APIClient) ListJob(pipelineName string, inputCommit []*pfs.Commit, outpu
 	return result, nil
 }
 
+// ListJobCtx is like ListJob, but it uses ctx for the request, so it returns
+// an error when ctx is cancelled or its deadline passes.
+func (c APIClient) ListJobCtx(ctx context.Context, pipelineName string, inputCommit []*pfs.Commit, outputCommit *pfs.Commit) ([]*pps.JobInfo, error) {
+	return c.WithCtx(ctx).ListJob(pipelineName, inputCommit, outputCommit)
+}
+
 // ListJobF returns info about all jobs, calling f with each JobInfo.
 // If f returns an error iteration of jobs will stop and ListJobF will return
 // that error, unless the error is errutil.ErrBreak in which case it will
@@ -247,12 +288,14 @@ func (c APIClient) ListJob(pipelineName string, inputCommit []*pfs.Commit, outpu
 // The order of the inputCommits doesn't matter.
 // If outputCommit is non-nil then only the job which created that commit as output will be returned.
 func (c APIClient) ListJobF(pipelineName string, inputCommit []*pfs.Commit, outputCommit *pfs.Commit, f func(*pps.JobInfo) error) error {
+	ctx, cancel := context.WithCancel(c.Ctx())
+	defer cancel()
 	var pipeline *pps.Pipeline
 	if pipelineName != "" {
 		pipeline = NewPipeline(pipelineName)
 	}
 	client, err := c.PpsAPIClient.ListJobStream(
-		c.Ctx(),
+		ctx,
 		&pps.ListJobRequest{
 			Pipeline:     pipeline,
 			InputCommit:  inputCommit,
@@ -277,17 +320,25 @@ func (c APIClient) ListJobF(pipelineName string, inputCommit []*pfs.Commit, outp
 	}
 }
 
+// ListJobFCtx is like ListJobF, but it uses ctx for the request, so it returns
+// an error when ctx is cancelled or its deadline passes.
+func (c APIClient) ListJobFCtx(ctx context.Context, pipelineName string, inputCommit []*pfs.Commit, outputCommit *pfs.Commit, f func(*pps.JobInfo) error) error {
+	return c.WithCtx(ctx).ListJobF(pipelineName, inputCommit, outputCommit, f)
+}
+
 // FlushJob calls f with all the jobs which were triggered by commits.
 // If toPipelines is non-nil then only the jobs between commits and those
 // pipelines in the DAG will be returned.
 func (c APIClient) FlushJob(commits []*pfs.Commit, toPipelines []string, f func(*pps.JobInfo) error) error {
+	ctx, cancel := context.WithCancel(c.Ctx())
+	defer cancel()
 	req := &pps.FlushJobRequest{
 		Commits: commits,
 	}
 	for _, pipeline := range toPipelines {
 		req.ToPipelines = append(req.ToPipelines, NewPipeline(pipeline))
 	}
-	client, err := c.PpsAPIClient.FlushJob(c.Ctx(), req)
+	client, err := c.PpsAPIClient.FlushJob(ctx, req)
 	if err != nil {
 		return grpcutil.ScrubGRPC(err)
 	}
@@ -305,6 +356,12 @@ func (c APIClient) FlushJob(commits []*pfs.Commit, toPipelines []string, f func(
 	}
 }
 
+// FlushJobCtx is like FlushJob, but it uses ctx for the request, so it returns
+// an error when ctx is cancelled or its deadline passes.
+func (c APIClient) FlushJobCtx(ctx context.Context, commits []*pfs.Commit, toPipelines []string, f func(*pps.JobInfo) error) error {
+	return c.WithCtx(ctx).FlushJob(commits, toPipelines, f)
+}
+
 // FlushJobAll returns all the jobs which were triggered by commits.
 // If toPipelines is non-nil then only the jobs between commits and those
 // pipelines in the DAG will be returned.
@@ -357,8 +414,10 @@ func (c APIClient) RestartDatum(jobID string, datumFilter []string) error {
 
 // ListDatum returns info about all datums in a Job
 func (c APIClient) ListDatum(jobID string, pageSize int64, page int64) (*pps.ListDatumResponse, error) {
+	ctx, cancel := context.WithCancel(c.Ctx())
+	defer cancel()
 	client, err := c.PpsAPIClient.ListDatumStream(
-		c.Ctx(),
+		ctx,
 		&pps.ListDatumRequest{
 			Job:      NewJob(jobID),
 			PageSize: pageSize,
@@ -389,8 +448,10 @@ func (c APIClient) ListDatum(jobID string, pageSize int64, page int64) (*pps.Lis
 
 // ListDatumF returns info about all datums in a Job, calling f with each datum info.
 func (c APIClient) ListDatumF(jobID string, pageSize int64, page int64, f func(di *pps.DatumInfo) error) error {
+	ctx, cancel := context.WithCancel(c.Ctx())
+	defer cancel()
 	client, err := c.PpsAPIClient.ListDatumStream(
-		c.Ctx(),
+		ctx,
 		&pps.ListDatumRequest{
 			Job:      NewJob(jobID),
 			PageSize: pageSize,
@@ -437,11 +498,17 @@ func (c APIClient) InspectDatum(jobID string, datumID string) (*pps.DatumInfo, e
 // be fetched with 'Next()'. The log message received can be examined with
 // 'Message()', and any errors can be examined with 'Err()'.
 type LogsIter struct {
-	logsClient pps.API_GetLogsClient
+	logsClient logsClient
 	msg        *pps.LogMessage
 	err        error
 }
 
+// logsClient is the stream of log messages that a LogsIter iterates over:
+// either a single GetLogs stream, or a logsFanIn
+type logsClient interface {
+	Recv() (*pps.LogMessage, error)
+}
+
 // Next retrieves the next relevant log message from pachd
 func (l *LogsIter) Next() bool {
 	if l.err != nil {
@@ -461,6 +528,23 @@ func (l *LogsIter) Message() *pps.LogMessage {
 	return l.msg
 }
 
+// Line returns the most recently retrieved log message as a line of text,
+// prefixed with the pipeline and job that logged it (e.g.
+// "edges/5f6c...: processing datum"), which distinguishes the messages of
+// the different pipelines and jobs that GetLogsMulti merges
+func (l *LogsIter) Line() string {
+	var source string
+	switch {
+	case l.msg.PipelineName != "" && l.msg.JobID != "":
+		source = l.msg.PipelineName + "/" + l.msg.JobID
+	case l.msg.PipelineName != "":
+		source = l.msg.PipelineName
+	default:
+		source = l.msg.JobID
+	}
+	return fmt.Sprintf("%s: %s", source, l.msg.Message)
+}
+
 // Err retrieves any errors encountered in the course of calling 'Next()'.
 func (l *LogsIter) Err() error {
 	if l.err == io.EOF {
@@ -506,6 +590,157 @@ func (c APIClient) GetLogs(
 	return resp
 }
 
+// GetLogsCtx is like GetLogs, but the logs stream uses ctx, so following logs
+// stops when ctx is cancelled or its deadline passes.
+func (c APIClient) GetLogsCtx(
+	ctx context.Context,
+	pipelineName string,
+	jobID string,
+	data []string,
+	datumID string,
+	master bool,
+	follow bool,
+	tail int64,
+) *LogsIter {
+	return c.WithCtx(ctx).GetLogs(pipelineName, jobID, data, datumID, master, follow, tail)
+}
+
+// LogsSelector selects the pipelines and jobs whose logs GetLogsMulti merges
+type LogsSelector struct {
+	// Pipelines are patterns (see path.Match) that select the pipelines with
+	// matching names, e.g. "edges-*"
+	Pipelines []string
+	// Filter, if set, is called with each pipeline whose name matches one of
+	// Pipelines, and only the pipelines for which it returns true are
+	// selected. This selects pipelines by their other properties, such as
+	// their description or inputs.
+	Filter func(*pps.PipelineInfo) bool
+	// Jobs are the IDs of jobs to select
+	Jobs []string
+}
+
+// GetLogsMulti is like GetLogs, but merges the logs of all of the pipelines
+// and jobs selected by 'selector', so that operators can follow the output of
+// an entire DAG at once. Messages from different pipelines and jobs are
+// interleaved in the order they arrive; LogsIter.Line() prefixes each with
+// its pipeline and job.
+//
+// Pipelines are selected when GetLogsMulti is called, so pipelines created
+// later aren't followed. Iteration ends once every stream has ended, or with
+// the first stream that fails. If 'follow' is set, streams don't end, so
+// callers must cancel the client's context (see WithCtx) to stop following.
+func (c APIClient) GetLogsMulti(
+	selector LogsSelector,
+	data []string,
+	master bool,
+	follow bool,
+	tail int64,
+) *LogsIter {
+	var pipelines []string
+	if len(selector.Pipelines) > 0 {
+		pipelineInfos, err := c.ListPipeline()
+		if err != nil {
+			return &LogsIter{err: err}
+		}
+		for _, pipelineInfo := range pipelineInfos {
+			matched, err := matchAny(selector.Pipelines, pipelineInfo.Pipeline.Name)
+			if err != nil {
+				return &LogsIter{err: err}
+			}
+			if matched && (selector.Filter == nil || selector.Filter(pipelineInfo)) {
+				pipelines = append(pipelines, pipelineInfo.Pipeline.Name)
+			}
+		}
+	}
+	if len(pipelines) == 0 && len(selector.Jobs) == 0 {
+		return &LogsIter{err: fmt.Errorf("no pipelines or jobs match %v", selector.Pipelines)}
+	}
+
+	ctx, cancel := context.WithCancel(c.Ctx())
+	pachClient := c.WithCtx(ctx)
+	fanIn := &logsFanIn{
+		msgs:   make(chan *pps.LogMessage),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	var wg sync.WaitGroup
+	forward := func(source string, iter *LogsIter) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fanIn.forward(source, iter)
+		}()
+	}
+	for _, pipeline := range pipelines {
+		forward("pipeline "+pipeline, pachClient.GetLogs(pipeline, "", data, "", master, follow, tail))
+	}
+	for _, jobID := range selector.Jobs {
+		forward("job "+jobID, pachClient.GetLogs("", jobID, data, "", master, follow, tail))
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		close(fanIn.msgs)
+	}()
+	return &LogsIter{logsClient: fanIn}
+}
+
+func matchAny(patterns []string, name string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := path.Match(pattern, name)
+		if err != nil {
+			return false, fmt.Errorf("invalid pipeline pattern %q: %v", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// logsFanIn merges the log streams of several GetLogs calls
+type logsFanIn struct {
+	msgs   chan *pps.LogMessage
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu  sync.Mutex
+	err error // the first error returned by any stream
+}
+
+// forward sends the messages of 'iter' to f.msgs. If 'iter' fails, forward
+// records its error and stops the other streams.
+func (f *logsFanIn) forward(source string, iter *LogsIter) {
+	for iter.Next() {
+		select {
+		case f.msgs <- iter.Message():
+		case <-f.ctx.Done():
+			return
+		}
+	}
+	if err := iter.Err(); err != nil && f.ctx.Err() == nil {
+		f.mu.Lock()
+		if f.err == nil {
+			f.err = fmt.Errorf("error getting logs of %s: %v", source, err)
+		}
+		f.mu.Unlock()
+		f.cancel()
+	}
+}
+
+func (f *logsFanIn) Recv() (*pps.LogMessage, error) {
+	msg, ok := <-f.msgs
+	if !ok {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.err != nil {
+			return nil, f.err
+		}
+		return nil, io.EOF
+	}
+	return msg, nil
+}
+
 // CreatePipeline creates a new pipeline, pipelines are the main computation
 // object in PPS they create a flow of data from a set of input Repos to an
 // output Repo (which has the same name as the pipeline). Whenever new data is
@@ -553,6 +788,40 @@ func (c APIClient) CreatePipeline(
 	return grpcutil.ScrubGRPC(err)
 }
 
+// ValidatePipeline checks the pipeline spec 'request' against the cluster
+// without creating the pipeline, and returns the issues found with it. Issues
+// of severity pps.IssueSeverity_ISSUE_ERROR would stop the pipeline from
+// being created, or its workers from running.
+func (c APIClient) ValidatePipeline(request *pps.CreatePipelineRequest) ([]*pps.PipelineIssue, error) {
+	response, err := c.PpsAPIClient.ValidatePipeline(c.Ctx(), request)
+	if err != nil {
+		return nil, grpcutil.ScrubGRPC(err)
+	}
+	return response.Issues, nil
+}
+
+// ApplyPipelines creates and updates pipelines so that they match 'specs'
+// and, if 'prune' is set, deletes the pipelines that aren't in 'specs'.
+// Every spec is validated before any pipeline is changed. Pipelines are
+// created and updated in dependency order, and if one fails, the changes
+// already made are undone. Pruned pipelines are deleted last, once every
+// other change has been made. It returns the changes made, in order.
+// If 'reprocess' is set, updated pipelines reprocess all datums.
+func (c APIClient) ApplyPipelines(specs []*pps.CreatePipelineRequest, prune bool, reprocess bool) ([]*pps.PipelineChange, error) {
+	response, err := c.PpsAPIClient.ApplyPipelines(
+		c.Ctx(),
+		&pps.ApplyPipelinesRequest{
+			Pipelines: specs,
+			Prune:     prune,
+			Reprocess: reprocess,
+		},
+	)
+	if err != nil {
+		return nil, grpcutil.ScrubGRPC(err)
+	}
+	return response.Changes, nil
+}
+
 // InspectPipeline returns info about a specific pipeline.
 func (c APIClient) InspectPipeline(pipelineName string) (*pps.PipelineInfo, error) {
 	pipelineInfo, err := c.PpsAPIClient.InspectPipeline(
@@ -576,6 +845,36 @@ func (c APIClient) ListPipeline() ([]*pps.PipelineInfo, error) {
 	return pipelineInfos.PipelineInfo, nil
 }
 
+// ListPipelineVersions returns every version of a pipeline's spec, newest
+// first.
+func (c APIClient) ListPipelineVersions(pipelineName string) ([]*pps.PipelineInfo, error) {
+	pipelineInfos, err := c.PpsAPIClient.ListPipelineVersions(
+		c.Ctx(),
+		&pps.ListPipelineVersionsRequest{
+			Pipeline: NewPipeline(pipelineName),
+		},
+	)
+	if err != nil {
+		return nil, grpcutil.ScrubGRPC(err)
+	}
+	return pipelineInfos.PipelineInfo, nil
+}
+
+// RollbackPipeline updates a pipeline to the spec of its version 'version'
+// (see ListPipelineVersions). If 'reprocess' is set, the pipeline reprocesses
+// all datums.
+func (c APIClient) RollbackPipeline(pipelineName string, version uint64, reprocess bool) error {
+	_, err := c.PpsAPIClient.RollbackPipeline(
+		c.Ctx(),
+		&pps.RollbackPipelineRequest{
+			Pipeline:  NewPipeline(pipelineName),
+			Version:   version,
+			Reprocess: reprocess,
+		},
+	)
+	return grpcutil.ScrubGRPC(err)
+}
+
 // DeletePipeline deletes a pipeline along with its output Repo.
 func (c APIClient) DeletePipeline(name string, force bool) error {
 	_, err := c.PpsAPIClient.DeletePipeline(
@@ -677,6 +976,121 @@ func (c APIClient) GarbageCollect(memoryBytes int64) error {
 	return grpcutil.ScrubGRPC(err)
 }
 
+// StartGarbageCollect starts garbage collection in the background and
+// returns immediately. Its progress can be followed with
+// InspectGarbageCollect. 'memoryBytes' is as in GarbageCollect.
+func (c APIClient) StartGarbageCollect(memoryBytes int64) (*pps.GarbageCollectStatus, error) {
+	status, err := c.PpsAPIClient.StartGarbageCollect(
+		c.Ctx(),
+		&pps.GarbageCollectRequest{MemoryBytes: memoryBytes},
+	)
+	return status, grpcutil.ScrubGRPC(err)
+}
+
+// InspectGarbageCollect returns the status of the current (or most recent)
+// garbage collection, and the garbage collection schedule.
+func (c APIClient) InspectGarbageCollect() (*pps.GarbageCollectStatus, error) {
+	status, err := c.PpsAPIClient.InspectGarbageCollect(c.Ctx(), &types.Empty{})
+	return status, grpcutil.ScrubGRPC(err)
+}
+
+// PauseGarbageCollect pauses the running garbage collection. It stops once
+// it has finished its current batch of deletions.
+func (c APIClient) PauseGarbageCollect() (*pps.GarbageCollectStatus, error) {
+	status, err := c.PpsAPIClient.PauseGarbageCollect(c.Ctx(), &types.Empty{})
+	return status, grpcutil.ScrubGRPC(err)
+}
+
+// ResumeGarbageCollect resumes a paused garbage collection.
+func (c APIClient) ResumeGarbageCollect() (*pps.GarbageCollectStatus, error) {
+	status, err := c.PpsAPIClient.ResumeGarbageCollect(c.Ctx(), &types.Empty{})
+	return status, grpcutil.ScrubGRPC(err)
+}
+
+// SetGarbageCollectSchedule makes pachd run garbage collection on the
+// schedule given by 'cronSpec' (a standard cron expression, e.g.
+// "0 3 * * *"). An empty 'cronSpec' removes the schedule.
+func (c APIClient) SetGarbageCollectSchedule(cronSpec string, memoryBytes int64) error {
+	var schedule *pps.GarbageCollectSchedule
+	if cronSpec != "" {
+		schedule = &pps.GarbageCollectSchedule{
+			CronSpec:    cronSpec,
+			MemoryBytes: memoryBytes,
+		}
+	}
+	_, err := c.PpsAPIClient.SetGarbageCollectSchedule(
+		c.Ctx(),
+		&pps.SetGarbageCollectScheduleRequest{Schedule: schedule},
+	)
+	return grpcutil.ScrubGRPC(err)
+}
+
+// CreateReplication starts replicating 'branches' to the cluster whose pachd
+// is at 'target.PachdAddress'. The PPS master copies their finished commits
+// to the target in the background, keeping their IDs. If 'update' is set, it
+// replaces the target and branches of the existing replication 'name'.
+func (c APIClient) CreateReplication(name string, target *pps.ReplicationTarget, branches []*pfs.Branch, update bool) error {
+	_, err := c.PpsAPIClient.CreateReplication(
+		c.Ctx(),
+		&pps.CreateReplicationRequest{
+			Replication: &pps.Replication{Name: name},
+			Target:      target,
+			Branches:    branches,
+			Update:      update,
+		},
+	)
+	return grpcutil.ScrubGRPC(err)
+}
+
+// InspectReplication returns a replication, and the progress of each of its
+// branches.
+func (c APIClient) InspectReplication(name string) (*pps.ReplicationInfo, error) {
+	replicationInfo, err := c.PpsAPIClient.InspectReplication(
+		c.Ctx(),
+		&pps.InspectReplicationRequest{Replication: &pps.Replication{Name: name}},
+	)
+	return replicationInfo, grpcutil.ScrubGRPC(err)
+}
+
+// ListReplication returns every replication.
+func (c APIClient) ListReplication() ([]*pps.ReplicationInfo, error) {
+	replicationInfos, err := c.PpsAPIClient.ListReplication(
+		c.Ctx(),
+		&pps.ListReplicationRequest{},
+	)
+	if err != nil {
+		return nil, grpcutil.ScrubGRPC(err)
+	}
+	return replicationInfos.ReplicationInfo, nil
+}
+
+// DeleteReplication stops a replication. The commits that it has copied are
+// kept in the target cluster.
+func (c APIClient) DeleteReplication(name string) error {
+	_, err := c.PpsAPIClient.DeleteReplication(
+		c.Ctx(),
+		&pps.DeleteReplicationRequest{Replication: &pps.Replication{Name: name}},
+	)
+	return grpcutil.ScrubGRPC(err)
+}
+
+// SetSchedulerLimits sets the limits on the jobs that run at once. If
+// 'limits' is nil, the limits are removed.
+func (c APIClient) SetSchedulerLimits(limits *pps.SchedulerLimits) error {
+	_, err := c.PpsAPIClient.SetSchedulerLimits(
+		c.Ctx(),
+		&pps.SetSchedulerLimitsRequest{Limits: limits},
+	)
+	return grpcutil.ScrubGRPC(err)
+}
+
+// InspectScheduler returns the scheduler's limits, and the jobs that are
+// running and queued.
+func (c APIClient) InspectScheduler() (*pps.SchedulerStatus, error) {
+	status, err := c.PpsAPIClient.InspectScheduler(c.Ctx(), &types.Empty{})
+	return status, grpcutil.ScrubGRPC(err)
+}
+
 // GetDatumTotalTime sums the timing stats from a DatumInfo
 func GetDatumTotalTime(s *pps.ProcessStats) time.Duration {
 	totalDuration := time.Duration(0)
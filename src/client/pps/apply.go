@@ -0,0 +1,164 @@
+package pps
+
+import (
+	"fmt"
+)
+
+// PlanApplyPipelines returns the changes that make the pipelines 'existing'
+// match the specs in 'request', in the order in which they must be made. A
+// spec is created if no pipeline of its name exists, and updated unless
+// 'unchanged' reports that the existing pipeline already matches it.
+// Pipelines are created and updated after the pipelines whose output they
+// read, and (if request.Prune is set) the pipelines that aren't in the
+// request are deleted last, downstream pipelines first, so that a failed
+// apply can be undone before anything is deleted.
+func PlanApplyPipelines(existing []*PipelineInfo, request *ApplyPipelinesRequest,
+	unchanged func(spec *CreatePipelineRequest, pipelineInfo *PipelineInfo) bool) ([]*PipelineChange, error) {
+	specs := make(map[string]*CreatePipelineRequest)
+	var names []string
+	for _, spec := range request.Pipelines {
+		name := spec.GetPipeline().GetName()
+		if name == "" {
+			return nil, fmt.Errorf("every pipeline spec must have a name")
+		}
+		if _, ok := specs[name]; ok {
+			return nil, fmt.Errorf("pipeline %s is specified more than once", name)
+		}
+		specs[name] = spec
+		names = append(names, name)
+	}
+	pipelineInfos := make(map[string]*PipelineInfo)
+	var deleted []string
+	for _, pipelineInfo := range existing {
+		name := pipelineInfo.Pipeline.Name
+		pipelineInfos[name] = pipelineInfo
+		if _, ok := specs[name]; !ok && request.Prune {
+			deleted = append(deleted, name)
+		}
+	}
+
+	var changes []*PipelineChange
+	order, err := dependencyOrder(names, func(name string) []string { return inputRepos(specs[name].Input) })
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range order {
+		change := &PipelineChange{Pipeline: &Pipeline{Name: name}}
+		pipelineInfo, ok := pipelineInfos[name]
+		switch {
+		case !ok:
+			change.Type = PipelineChangeType_PIPELINE_CREATE
+		case unchanged(specs[name], pipelineInfo):
+			change.Type = PipelineChangeType_PIPELINE_UNCHANGED
+		default:
+			change.Type = PipelineChangeType_PIPELINE_UPDATE
+		}
+		changes = append(changes, change)
+	}
+
+	isDeleted := make(map[string]bool)
+	for _, name := range deleted {
+		isDeleted[name] = true
+	}
+	for _, name := range names {
+		for _, repo := range inputRepos(specs[name].Input) {
+			if isDeleted[repo] {
+				return nil, fmt.Errorf("pipeline %s reads from pipeline %s, which would be deleted", name, repo)
+			}
+		}
+	}
+	order, err = dependencyOrder(deleted, func(name string) []string { return inputRepos(pipelineInfos[name].Input) })
+	if err != nil {
+		return nil, err
+	}
+	for i := len(order) - 1; i >= 0; i-- {
+		changes = append(changes, &PipelineChange{
+			Pipeline: &Pipeline{Name: order[i]},
+			Type:     PipelineChangeType_PIPELINE_DELETE,
+		})
+	}
+	return changes, nil
+}
+
+// inputRepos returns the repos that 'input' reads from
+func inputRepos(input *Input) []string {
+	if input == nil {
+		return nil
+	}
+	var repos []string
+	VisitInput(input, func(input *Input) {
+		if input.Pfs != nil {
+			repos = append(repos, input.Pfs.Repo)
+		}
+		if input.Atom != nil {
+			repos = append(repos, input.Atom.Repo)
+		}
+	})
+	return repos
+}
+
+// dependencyOrder sorts 'names' so that each name comes after the names in
+// 'names' that it depends on (as returned by 'dependencies'). Otherwise,
+// names keep their order.
+func dependencyOrder(names []string, dependencies func(name string) []string) ([]string, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	for _, name := range names {
+		state[name] = unvisited
+	}
+	var order []string
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("pipelines depend on each other in a cycle: %v", append(path, name))
+		}
+		state[name] = visiting
+		for _, dependency := range dependencies(name) {
+			if _, ok := state[dependency]; ok {
+				if err := visit(dependency, append(path, name)); err != nil {
+					return err
+				}
+			}
+		}
+		state[name] = visited
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// RollbackSpec returns the spec of the update that rolls the pipeline whose
+// versions are 'pipelineInfos' (newest first) back to request.Version
+func RollbackSpec(pipelineInfos []*PipelineInfo, request *RollbackPipelineRequest) (*CreatePipelineRequest, error) {
+	name := request.Pipeline.Name
+	if len(pipelineInfos) > 0 && pipelineInfos[0].Version == request.Version {
+		return nil, fmt.Errorf("pipeline %s is already at version %d", name, request.Version)
+	}
+	for _, pipelineInfo := range pipelineInfos {
+		if pipelineInfo.Version != request.Version {
+			continue
+		}
+		spec := PipelineReqFromInfo(pipelineInfo)
+		spec.Update = true
+		spec.Reprocess = request.Reprocess
+		if spec.Reprocess {
+			// datums processed with the old version's salt may be skipped, so a
+			// new salt is generated
+			spec.Salt = ""
+		}
+		return spec, nil
+	}
+	return nil, fmt.Errorf("pipeline %s has no version %d", name, request.Version)
+}
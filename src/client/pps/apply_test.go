@@ -0,0 +1,112 @@
+package pps
+
+import (
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+)
+
+func pipelineSpec(name string, inputs ...string) *CreatePipelineRequest {
+	spec := &CreatePipelineRequest{Pipeline: &Pipeline{Name: name}}
+	if len(inputs) > 0 {
+		spec.Input = &Input{}
+		for _, input := range inputs {
+			spec.Input.Cross = append(spec.Input.Cross, &Input{Pfs: &PFSInput{Repo: input, Glob: "/*"}})
+		}
+	}
+	return spec
+}
+
+func existingPipeline(name string, inputs ...string) *PipelineInfo {
+	spec := pipelineSpec(name, inputs...)
+	return &PipelineInfo{Pipeline: spec.Pipeline, Input: spec.Input, Description: "existing"}
+}
+
+func planned(changes []*PipelineChange) []string {
+	var result []string
+	for _, change := range changes {
+		result = append(result, change.Type.String()+" "+change.Pipeline.Name)
+	}
+	return result
+}
+
+func TestPlanApplyPipelines(t *testing.T) {
+	unchanged := func(spec *CreatePipelineRequest, pipelineInfo *PipelineInfo) bool {
+		return spec.Description == pipelineInfo.Description
+	}
+	existing := []*PipelineInfo{
+		existingPipeline("edges", "images"),
+		existingPipeline("montage", "edges", "images"),
+		existingPipeline("stats", "montage"),
+		existingPipeline("other", "images"),
+		existingPipeline("report", "stats"),
+	}
+	montage := pipelineSpec("montage", "edges", "thumbnails")
+	edges := pipelineSpec("edges", "images")
+	edges.Description = "existing"
+	request := &ApplyPipelinesRequest{Pipelines: []*CreatePipelineRequest{
+		montage,
+		pipelineSpec("thumbnails", "images"),
+		edges,
+	}}
+	changes, err := PlanApplyPipelines(existing, request, unchanged)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"PIPELINE_UNCHANGED edges",
+		"PIPELINE_CREATE thumbnails",
+		"PIPELINE_UPDATE montage",
+	}, planned(changes))
+
+	// pruning deletes downstream pipelines first
+	request.Prune = true
+	changes, err = PlanApplyPipelines(existing, request, unchanged)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"PIPELINE_UNCHANGED edges",
+		"PIPELINE_CREATE thumbnails",
+		"PIPELINE_UPDATE montage",
+		"PIPELINE_DELETE report",
+		"PIPELINE_DELETE other",
+		"PIPELINE_DELETE stats",
+	}, planned(changes))
+	request.Pipelines = append(request.Pipelines, pipelineSpec("report", "stats"))
+	_, err = PlanApplyPipelines(existing, request, unchanged)
+	require.YesError(t, err)
+
+	// invalid sets of specs
+	for _, specs := range [][]*CreatePipelineRequest{
+		{pipelineSpec("a"), pipelineSpec("a")},
+		{pipelineSpec("")},
+		{pipelineSpec("a", "b"), pipelineSpec("b", "c"), pipelineSpec("c", "a")},
+	} {
+		_, err = PlanApplyPipelines(nil, &ApplyPipelinesRequest{Pipelines: specs}, unchanged)
+		require.YesError(t, err)
+	}
+}
+
+func TestRollbackSpec(t *testing.T) {
+	versions := []*PipelineInfo{
+		{Pipeline: &Pipeline{Name: "edges"}, Version: 3, Transform: &Transform{Image: "opencv:3"}, Salt: "c"},
+		{Pipeline: &Pipeline{Name: "edges"}, Version: 2, Transform: &Transform{Image: "opencv:2"}, Salt: "b"},
+		{Pipeline: &Pipeline{Name: "edges"}, Version: 1, Transform: &Transform{Image: "opencv:1"}, Salt: "a"},
+	}
+	request := &RollbackPipelineRequest{Pipeline: &Pipeline{Name: "edges"}, Version: 2}
+	spec, err := RollbackSpec(versions, request)
+	require.NoError(t, err)
+	require.True(t, spec.Update)
+	require.False(t, spec.Reprocess)
+	require.Equal(t, "opencv:2", spec.Transform.Image)
+
+	request.Version, request.Reprocess = 1, true
+	spec, err = RollbackSpec(versions, request)
+	require.NoError(t, err)
+	require.True(t, spec.Reprocess)
+	require.Equal(t, "", spec.Salt)
+	require.Equal(t, "opencv:1", spec.Transform.Image)
+
+	for _, version := range []uint64{3, 4} {
+		request.Version = version
+		_, err = RollbackSpec(versions, request)
+		require.YesError(t, err)
+	}
+}
@@ -0,0 +1,114 @@
+package pps
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	globlib "github.com/gobwas/glob"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+)
+
+var (
+	// imageReferenceRe matches docker image references: an optional registry,
+	// a lower-case repository, and an optional tag and digest
+	imageReferenceRe = regexp.MustCompile(`^` +
+		`(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?/)?` +
+		`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+		`(?::[\w][\w.-]{0,127})?` +
+		`(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,})?$`)
+	// imageTagRe matches the tag of a docker image reference
+	imageTagRe = regexp.MustCompile(`:[\w][\w.-]{0,127}(?:@|$)`)
+)
+
+// NewPipelineIssue returns a pipeline issue of 'severity', concerning
+// 'field' (which may be empty)
+func NewPipelineIssue(severity IssueSeverity, field string, format string, args ...interface{}) *PipelineIssue {
+	return &PipelineIssue{
+		Severity: severity,
+		Field:    field,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// InputField returns the name by which pipeline issues refer to the field
+// 'field' of 'input' (e.g. "input.pfs[images].glob"). Inputs are named as in
+// the spec, so PFS inputs without a name are named after their repo.
+func InputField(input *Input, field string) string {
+	var prefix string
+	switch {
+	case input.Pfs != nil:
+		prefix = fmt.Sprintf("input.pfs[%s]", orDefault(input.Pfs.Name, input.Pfs.Repo))
+	case input.Atom != nil:
+		prefix = fmt.Sprintf("input.atom[%s]", orDefault(input.Atom.Name, input.Atom.Repo))
+	case input.Cron != nil:
+		prefix = fmt.Sprintf("input.cron[%s]", input.Cron.Name)
+	case input.Git != nil:
+		prefix = fmt.Sprintf("input.git[%s]", input.Git.Name)
+	default:
+		prefix = "input"
+	}
+	if field == "" {
+		return prefix
+	}
+	return prefix + "." + field
+}
+
+func orDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// LintPipeline returns the problems with the pipeline spec 'request' that
+// can be found without a cluster, such as malformed images and globs. The
+// PPS API's ValidatePipeline returns these issues, along with those that it
+// finds by checking the spec against the cluster.
+func LintPipeline(request *CreatePipelineRequest) []*PipelineIssue {
+	var issues []*PipelineIssue
+	if request.Pipeline == nil || request.Pipeline.Name == "" {
+		issues = append(issues, NewPipelineIssue(IssueSeverity_ISSUE_ERROR, "pipeline.name", "pipeline has no name"))
+	}
+	if image := request.GetTransform().GetImage(); image != "" {
+		if !imageReferenceRe.MatchString(image) {
+			issues = append(issues, NewPipelineIssue(IssueSeverity_ISSUE_ERROR, "transform.image",
+				"%q is not a valid image reference", image))
+		} else if tag := imageTagRe.FindString(image[strings.LastIndex(image, "/")+1:]); tag == "" || strings.HasPrefix(tag, ":latest") {
+			issues = append(issues, NewPipelineIssue(IssueSeverity_ISSUE_WARNING, "transform.image",
+				"image %q uses the \"latest\" tag, so workers may run different versions of it; pin a tag or digest", image))
+		}
+	}
+	if request.Input == nil {
+		issues = append(issues, NewPipelineIssue(IssueSeverity_ISSUE_ERROR, "input", "pipeline has no input"))
+		return issues
+	}
+	VisitInput(request.Input, func(input *Input) {
+		var glob string
+		var globType pfs.PatternType
+		switch {
+		case input.Pfs != nil:
+			glob, globType = input.Pfs.Glob, input.Pfs.GlobType
+		case input.Atom != nil:
+			glob, globType = input.Atom.Glob, input.Atom.GlobType
+		default:
+			return
+		}
+		if glob == "" {
+			issues = append(issues, NewPipelineIssue(IssueSeverity_ISSUE_ERROR, InputField(input, "glob"), "input must specify a glob"))
+			return
+		}
+		if globType == pfs.PatternType_REGEX {
+			if _, err := regexp.Compile(glob); err != nil {
+				issues = append(issues, NewPipelineIssue(IssueSeverity_ISSUE_ERROR, InputField(input, "glob"),
+					"input glob %q is not a valid regular expression: %v", glob, err))
+			}
+			return
+		}
+		if _, err := globlib.Compile("/"+strings.TrimPrefix(glob, "/"), '/'); err != nil {
+			issues = append(issues, NewPipelineIssue(IssueSeverity_ISSUE_ERROR, InputField(input, "glob"),
+				"input glob %q is not a valid glob pattern: %v", glob, err))
+		}
+	})
+	return issues
+}
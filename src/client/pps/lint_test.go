@@ -0,0 +1,59 @@
+package pps
+
+import (
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+)
+
+func lintFields(request *CreatePipelineRequest) map[string]IssueSeverity {
+	fields := make(map[string]IssueSeverity)
+	for _, issue := range LintPipeline(request) {
+		fields[issue.Field] = issue.Severity
+	}
+	return fields
+}
+
+func TestLintPipeline(t *testing.T) {
+	pfsInput := func(name, glob string, globType pfs.PatternType) *Input {
+		return &Input{Pfs: &PFSInput{Repo: "images", Name: name, Glob: glob, GlobType: globType}}
+	}
+	request := &CreatePipelineRequest{
+		Pipeline:  &Pipeline{Name: "edges"},
+		Transform: &Transform{Image: "registry.example.com:5000/team/opencv:1.2@sha256:0123456789abcdef0123456789abcdef"},
+		Input:     pfsInput("", "/*", pfs.PatternType_GLOB),
+	}
+	require.Equal(t, 0, len(LintPipeline(request)))
+
+	for image, severity := range map[string]IssueSeverity{
+		"opencv":                IssueSeverity_ISSUE_WARNING,
+		"localhost:5000/opencv": IssueSeverity_ISSUE_WARNING,
+		"opencv:latest":         IssueSeverity_ISSUE_WARNING,
+		"OpenCV:1.2":            IssueSeverity_ISSUE_ERROR,
+		"opencv:1.2 --rm":       IssueSeverity_ISSUE_ERROR,
+	} {
+		request.Transform.Image = image
+		fields := lintFields(request)
+		require.Equal(t, 1, len(fields))
+		require.Equal(t, severity, fields["transform.image"])
+	}
+	request.Transform.Image = "opencv:1.2"
+
+	request.Input = &Input{Cross: []*Input{
+		pfsInput("", "/[a", pfs.PatternType_GLOB),
+		pfsInput("labels", "/(a", pfs.PatternType_REGEX),
+		pfsInput("regex", "/a.*", pfs.PatternType_REGEX),
+		{Atom: &AtomInput{Repo: "old"}},
+	}}
+	fields := lintFields(request)
+	require.Equal(t, 3, len(fields))
+	require.Equal(t, IssueSeverity_ISSUE_ERROR, fields["input.pfs[images].glob"])
+	require.Equal(t, IssueSeverity_ISSUE_ERROR, fields["input.pfs[labels].glob"])
+	require.Equal(t, IssueSeverity_ISSUE_ERROR, fields["input.atom[old].glob"])
+
+	fields = lintFields(&CreatePipelineRequest{})
+	require.Equal(t, 2, len(fields))
+	require.Equal(t, IssueSeverity_ISSUE_ERROR, fields["pipeline.name"])
+	require.Equal(t, IssueSeverity_ISSUE_ERROR, fields["input"])
+}
@@ -21,6 +21,14 @@ func VisitInput(input *Input, f func(*Input)) {
 		for _, input := range input.Union {
 			VisitInput(input, f)
 		}
+	case input.Join != nil:
+		for _, input := range input.Join {
+			VisitInput(input, f)
+		}
+	case input.Group != nil:
+		for _, input := range input.Group {
+			VisitInput(input, f)
+		}
 	}
 	f(input)
 }
@@ -40,6 +48,14 @@ func InputName(input *Input) string {
 		if len(input.Union) > 0 {
 			return InputName(input.Union[0])
 		}
+	case input.Join != nil:
+		if len(input.Join) > 0 {
+			return InputName(input.Join[0])
+		}
+	case input.Group != nil:
+		if len(input.Group) > 0 {
+			return InputName(input.Group[0])
+		}
 	}
 	return ""
 }
@@ -55,6 +71,10 @@ func SortInput(input *Input) {
 			SortInputs(input.Cross)
 		case input.Union != nil:
 			SortInputs(input.Union)
+		case input.Join != nil:
+			SortInputs(input.Join)
+		case input.Group != nil:
+			SortInputs(input.Group)
 		}
 	})
 }
@@ -124,3 +144,40 @@ func ValidateGitCloneURL(url string) error {
 
 	return nil
 }
+
+// PipelineReqFromInfo converts a PipelineInfo into a CreatePipelineRequest.
+func PipelineReqFromInfo(pipelineInfo *PipelineInfo) *CreatePipelineRequest {
+	return &CreatePipelineRequest{
+		Pipeline:           pipelineInfo.Pipeline,
+		Transform:          pipelineInfo.Transform,
+		ParallelismSpec:    pipelineInfo.ParallelismSpec,
+		HashtreeSpec:       pipelineInfo.HashtreeSpec,
+		Egress:             pipelineInfo.Egress,
+		OutputBranch:       pipelineInfo.OutputBranch,
+		ScaleDownThreshold: pipelineInfo.ScaleDownThreshold,
+		ResourceRequests:   pipelineInfo.ResourceRequests,
+		ResourceLimits:     pipelineInfo.ResourceLimits,
+		Input:              pipelineInfo.Input,
+		Description:        pipelineInfo.Description,
+		CacheSize:          pipelineInfo.CacheSize,
+		EnableStats:        pipelineInfo.EnableStats,
+		Batch:              pipelineInfo.Batch,
+		MaxQueueSize:       pipelineInfo.MaxQueueSize,
+		Service:            pipelineInfo.Service,
+		ChunkSpec:          pipelineInfo.ChunkSpec,
+		DatumTimeout:       pipelineInfo.DatumTimeout,
+		JobTimeout:         pipelineInfo.JobTimeout,
+		Salt:               pipelineInfo.Salt,
+		Standby:            pipelineInfo.Standby,
+		StandbyIdleTimeout: pipelineInfo.StandbyIdleTimeout,
+		DatumFailurePolicy: pipelineInfo.DatumFailurePolicy,
+		Priority:           pipelineInfo.Priority,
+		DatumTries:         pipelineInfo.DatumTries,
+		SchedulingSpec:     pipelineInfo.SchedulingSpec,
+		PodSpec:            pipelineInfo.PodSpec,
+		PodPatch:           pipelineInfo.PodPatch,
+		Metadata:           pipelineInfo.Metadata,
+		InitContainers:     pipelineInfo.InitContainers,
+		Sidecars:           pipelineInfo.Sidecars,
+	}
+}
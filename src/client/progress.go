@@ -0,0 +1,113 @@
+package client
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// Progress describes the progress of a file transfer, and is passed to the
+// ProgressFunc given to WithProgress
+type Progress struct {
+	// Path is the path (in PFS) of the file being transferred
+	Path string
+	// Bytes is the number of bytes of the file transferred so far
+	Bytes int64
+	// TotalBytes is the size of the file, or -1 if it isn't known (e.g. if
+	// it's being uploaded from a stream)
+	TotalBytes int64
+	// FilesCompleted is the number of files whose transfer has finished,
+	// including this one if its transfer just finished
+	FilesCompleted int
+}
+
+// ProgressFunc is called with the progress of file transfers (see
+// WithProgress)
+type ProgressFunc func(Progress)
+
+// progressTracker calls a ProgressFunc, counting the files whose transfer has
+// finished. A nil *progressTracker reports nothing.
+type progressTracker struct {
+	f              ProgressFunc
+	mu             sync.Mutex
+	filesCompleted int
+}
+
+func (p *progressTracker) report(path string, bytes, totalBytes int64, completed bool) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if completed {
+		p.filesCompleted++
+	}
+	p.f(Progress{
+		Path:           path,
+		Bytes:          bytes,
+		TotalBytes:     totalBytes,
+		FilesCompleted: p.filesCompleted,
+	})
+}
+
+// WithProgress returns a new APIClient that calls 'f' as data is sent or
+// received by its file transfers, e.g. to render a progress bar. 'f' is
+// called after each message that's sent or received, and once more when a
+// file's transfer has finished. Calls to 'f' are serialized, even if files
+// are transferred concurrently.
+//
+// PutFile, PutFileOverwrite, PutFileSplit, PutFileWriter (including through
+// the PutFileClient returned by NewPutFileClient), PutFileResumable,
+// PutFileDir, GetFile and GetFileParallel report their progress. Files that pachd
+// fetches itself, with PutFileURL, are not reported.
+func (c *APIClient) WithProgress(f ProgressFunc) *APIClient {
+	result := *c // copy c
+	result.progress = &progressTracker{f: f}
+	return &result
+}
+
+// withoutProgress returns a copy of 'c' that doesn't report progress, for
+// calls that report their progress themselves
+func (c *APIClient) withoutProgress() *APIClient {
+	result := *c // copy c
+	result.progress = nil
+	return &result
+}
+
+// readerSize returns the number of bytes remaining in 'r', or -1 if that
+// can't be determined without reading it
+func readerSize(r io.Reader) int64 {
+	switch r := r.(type) {
+	case interface{ Len() int }: // e.g. bytes.Reader, strings.Reader
+		return int64(r.Len())
+	case *os.File:
+		fileInfo, err := r.Stat()
+		if err != nil || !fileInfo.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return fileInfo.Size() - offset
+	default:
+		return -1
+	}
+}
+
+// progressWriter reports the progress of the file 'path' as it's written to
+// 'w'
+type progressWriter struct {
+	w          io.Writer
+	progress   *progressTracker
+	path       string
+	bytes      int64
+	totalBytes int64
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.bytes += int64(n)
+	p.progress.report(p.path, p.bytes, p.totalBytes, false)
+	return n, err
+}
@@ -0,0 +1,300 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	globlib "github.com/gobwas/glob"
+	"github.com/pachyderm/pachyderm/src/client/limit"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"golang.org/x/sync/errgroup"
+)
+
+// PachIgnoreFile is the name of the file that lists the files in a directory
+// that PutFileDir doesn't upload
+const PachIgnoreFile = ".pachignore"
+
+// DefaultPutFileDirParallelism is the number of files that PutFileDir
+// uploads at a time, if no parallelism is given.
+const DefaultPutFileDirParallelism = 8
+
+// PutFileDirOptions are the options of PutFileDir. The zero value uploads
+// every file (except those ignored by a .pachignore file).
+type PutFileDirOptions struct {
+	// Include, if set, are patterns (see PutFileDir) that select the files to
+	// upload. Files that don't match any of them aren't uploaded.
+	Include []string
+	// Exclude are patterns that select files not to upload, in addition to
+	// those listed in the directory's .pachignore file
+	Exclude []string
+	// Parallelism is the number of files to upload at a time
+	// (DefaultPutFileDirParallelism if 0)
+	Parallelism int
+	// Overwrite, if set, overwrites files in PFS rather than appending to them
+	Overwrite bool
+	// Sync, if set, only uploads the files whose contents differ from the
+	// file at the same path in PFS (see FileHash), and overwrites them. This
+	// makes repeatedly syncing a directory into a repo cheap, as only the
+	// changed files are sent.
+	Sync bool
+}
+
+// FileHash returns the hash that pachd gives (in FileInfo.Hash) to a file
+// whose contents are read from 'r', if the file is written by a single
+// PutFile. Files written by several PutFile calls, or split with a
+// delimiter, may have different hashes even if their contents are the same.
+func FileHash(r io.Reader) ([]byte, error) {
+	// pachd stores a file's contents as objects named by the hashes of their
+	// contents, one for each of the chunks that pfs.Chunker splits it into (see
+	// PutObjectSplit). The file's hash is the hash of the objects' names.
+	fileHash := sha256.New()
+	chunker := pfs.NewChunker(r)
+	for {
+		objectHash := pfs.NewHash()
+		if _, err := io.Copy(objectHash, chunker); err != nil {
+			return nil, err
+		}
+		fileHash.Write([]byte(pfs.EncodeHash(objectHash.Sum(nil))))
+		more, err := chunker.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			return fileHash.Sum(nil), nil
+		}
+	}
+}
+
+// ignoreRule is one pattern in a .pachignore file or PutFileDirOptions
+type ignoreRule struct {
+	glob    globlib.Glob
+	negate  bool // the pattern began with '!', so it re-includes files
+	dirOnly bool // the pattern ended with '/', so it only matches directories
+	rooted  bool // the pattern contains '/', so it matches whole paths
+}
+
+func parseIgnoreRule(pattern string) (*ignoreRule, error) {
+	r := &ignoreRule{}
+	if strings.HasPrefix(pattern, "!") {
+		r.negate = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		r.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if strings.Contains(pattern, "/") {
+		r.rooted = true
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+	g, err := globlib.Compile(pattern, '/')
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	r.glob = g
+	return r, nil
+}
+
+// matches returns true if 'r' matches the file or directory at 'relPath'
+// (slash-separated, relative to the directory being uploaded)
+func (r *ignoreRule) matches(relPath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.rooted {
+		return r.glob.Match(relPath)
+	}
+	return r.glob.Match(path.Base(relPath))
+}
+
+// ignored returns true if the last of 'rules' that matches 'relPath' excludes
+// it, the way a .gitignore file's rules are applied
+func ignored(rules []*ignoreRule, relPath string, isDir bool) bool {
+	result := false
+	for _, r := range rules {
+		if r.matches(relPath, isDir) {
+			result = !r.negate
+		}
+	}
+	return result
+}
+
+// readPachIgnore parses the .pachignore file in 'dir', if there is one. Each
+// line is a pattern, except for blank lines and lines beginning with '#'.
+func readPachIgnore(dir string) ([]*ignoreRule, error) {
+	f, err := os.Open(filepath.Join(dir, PachIgnoreFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var rules []*ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := parseIgnoreRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %v", filepath.Join(dir, PachIgnoreFile), err)
+		}
+		rules = append(rules, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", filepath.Join(dir, PachIgnoreFile), err)
+	}
+	return rules, nil
+}
+
+// PutFileDir uploads the files under the local directory 'localPath' to
+// 'prefix' in 'commitID', preserving their paths relative to 'localPath'
+// (e.g. localPath/a/b is uploaded to prefix/a/b). Files are uploaded
+// 'opts.Parallelism' at a time. Only regular files are uploaded, so symlinks
+// and empty directories are skipped.
+//
+// If 'localPath' contains a .pachignore file, the files it lists (and the
+// .pachignore file itself) aren't uploaded. Like a .gitignore file, it lists
+// one pattern per line, and:
+//   - a pattern with no '/' matches file and directory names at any depth
+//     (e.g. "*.tmp"), while other patterns match paths relative to
+//     'localPath' (e.g. "build/*.o"). '*' doesn't match '/', but '**' does.
+//   - a pattern ending in '/' only matches directories, and excluding a
+//     directory excludes everything in it.
+//   - a pattern beginning with '!' re-includes the files it matches that an
+//     earlier pattern excluded.
+//
+// 'opts.Exclude' lists more such patterns, which are applied after those in
+// the .pachignore file, and 'opts.Include' (if set) restricts the upload to
+// the files matching one of its patterns. 'opts' may be nil.
+//
+// If 'opts.Sync' is set, the hashes of the files under 'prefix' are fetched
+// from pachd first, and the files whose local hash matches are skipped.
+//
+// As with PutFile, if 'commitID' is a branch, each file is uploaded in a
+// separate commit, so 'commitID' should usually be an open commit.
+func (c APIClient) PutFileDir(localPath string, repoName string, commitID string, prefix string, opts *PutFileDirOptions) error {
+	if opts == nil {
+		opts = &PutFileDirOptions{}
+	}
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultPutFileDirParallelism
+	}
+	rules, err := readPachIgnore(localPath)
+	if err != nil {
+		return err
+	}
+	for _, pattern := range opts.Exclude {
+		r, err := parseIgnoreRule(pattern)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, r)
+	}
+	var includes []*ignoreRule
+	for _, pattern := range opts.Include {
+		r, err := parseIgnoreRule(pattern)
+		if err != nil {
+			return err
+		}
+		includes = append(includes, r)
+	}
+
+	// existing maps the paths of the files under 'prefix' to their hashes
+	existing := make(map[string][]byte)
+	if opts.Sync {
+		if err := c.Walk(repoName, commitID, prefix, func(fileInfo *pfs.FileInfo) error {
+			if fileInfo.FileType == pfs.FileType_FILE {
+				existing[path.Join("/", fileInfo.File.Path)] = fileInfo.Hash
+			}
+			return nil
+		}); err != nil && !errors.Is(err, ErrFileNotFound) {
+			return err // 'prefix' not existing yet is fine, as nothing is skipped
+		}
+	}
+
+	limiter := limit.New(parallelism)
+	eg, ctx := errgroup.WithContext(c.Ctx())
+	pachClient := c.WithCtx(ctx)
+	if err := filepath.Walk(localPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err() // an upload failed; eg.Wait() returns its error
+		}
+		rel, err := filepath.Rel(localPath, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if info.IsDir() {
+			if ignored(rules, rel, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || rel == PachIgnoreFile || ignored(rules, rel, false) {
+			return nil
+		}
+		if len(includes) > 0 && !ignored(includes, rel, false) { // no include matches
+			return nil
+		}
+		pfsPath := path.Join(prefix, rel)
+		limiter.Acquire()
+		eg.Go(func() (retErr error) {
+			defer limiter.Release()
+			f, err := os.Open(filePath)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err := f.Close(); err != nil && retErr == nil {
+					retErr = err
+				}
+			}()
+			if hash, ok := existing[path.Join("/", pfsPath)]; ok {
+				localHash, err := FileHash(f)
+				if err != nil {
+					return fmt.Errorf("error hashing %s: %v", filePath, err)
+				}
+				if bytes.Equal(hash, localHash) {
+					return nil // unchanged
+				}
+				if _, err := f.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+			}
+			if opts.Overwrite || opts.Sync {
+				_, err = pachClient.PutFileOverwrite(repoName, commitID, pfsPath, f, 0)
+			} else {
+				_, err = pachClient.PutFile(repoName, commitID, pfsPath, f)
+			}
+			if err != nil {
+				return fmt.Errorf("error uploading %s to %s: %v", filePath, pfsPath, err)
+			}
+			return nil
+		})
+		return nil
+	}); err != nil {
+		if waitErr := eg.Wait(); waitErr != nil {
+			return waitErr
+		}
+		return err
+	}
+	return eg.Wait()
+}
@@ -0,0 +1,199 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// DefaultResumableChunkSize is the size of the chunks that PutFileResumable
+// uploads, if no chunk size is given.
+const DefaultResumableChunkSize = 64 * 1024 * 1024
+
+// resumableCheckpoint is the contents of a PutFileResumable checkpoint file.
+// It records the chunks of the file that have been uploaded so far.
+type resumableCheckpoint struct {
+	Repo      string           `json:"repo"`
+	Commit    string           `json:"commit"`
+	Path      string           `json:"path"`
+	ChunkSize int64            `json:"chunk_size"`
+	Chunks    []resumableChunk `json:"chunks"`
+}
+
+type resumableChunk struct {
+	Hash string `json:"hash"` // hex-encoded SHA256 of the chunk
+	Size int64  `json:"size"`
+}
+
+func (cp *resumableCheckpoint) sizeBytes() int64 {
+	var size int64
+	for _, chunk := range cp.Chunks {
+		size += chunk.Size
+	}
+	return size
+}
+
+// readCheckpoint reads the checkpoint at 'checkpointPath', or returns nil if
+// there is none
+func readCheckpoint(checkpointPath string) (*resumableCheckpoint, error) {
+	data, err := ioutil.ReadFile(checkpointPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read checkpoint: %v", err)
+	}
+	cp := &resumableCheckpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("could not parse checkpoint %s: %v", checkpointPath, err)
+	}
+	return cp, nil
+}
+
+// writeCheckpoint writes 'cp' to 'checkpointPath'. The checkpoint is written
+// to a temporary file that is then renamed, so that a crash can't leave a
+// partially-written checkpoint behind.
+func writeCheckpoint(checkpointPath string, cp *resumableCheckpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmpPath := checkpointPath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("could not write checkpoint: %v", err)
+	}
+	if err := os.Rename(tmpPath, checkpointPath); err != nil {
+		return fmt.Errorf("could not write checkpoint: %v", err)
+	}
+	return nil
+}
+
+// PutFileResumable writes a file to PFS from a reader, like
+// PutFileOverwrite, but uploads it in chunks of 'chunkSize' bytes
+// (DefaultResumableChunkSize if 'chunkSize' is 0) and records each chunk in
+// the checkpoint file 'checkpointPath' once it has been uploaded. If the
+// upload is interrupted, calling PutFileResumable again with the same
+// arguments and a reader over the same data skips the chunks that were
+// already uploaded. The checkpoint file is removed once the upload finishes.
+//
+// The skipped chunks are still read from 'reader', and are compared with the
+// checkpoint so that a file that has changed since the upload began isn't
+// corrupted. Any writes to the PFS file by other clients during the upload
+// cause resuming to fail.
+//
+// Each chunk is a separate PutFile, so 'commitID' should be an open commit:
+// writes to a branch would create a commit for every chunk. The returned int
+// is the number of bytes uploaded by this call, excluding skipped chunks.
+func (c APIClient) PutFileResumable(repoName string, commitID string, path string, reader io.Reader, checkpointPath string, chunkSize int64) (int, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultResumableChunkSize
+	}
+	cp, err := readCheckpoint(checkpointPath)
+	if err != nil {
+		return 0, err
+	}
+	if cp == nil {
+		cp = &resumableCheckpoint{
+			Repo:      repoName,
+			Commit:    commitID,
+			Path:      path,
+			ChunkSize: chunkSize,
+		}
+	} else if cp.Repo != repoName || cp.Commit != commitID || cp.Path != path {
+		return 0, fmt.Errorf("checkpoint %s is for an upload to %s@%s:%s, not %s@%s:%s",
+			checkpointPath, cp.Repo, cp.Commit, cp.Path, repoName, commitID, path)
+	} else if cp.ChunkSize != chunkSize {
+		return 0, fmt.Errorf("checkpoint %s uses a chunk size of %d bytes, not %d",
+			checkpointPath, cp.ChunkSize, chunkSize)
+	}
+
+	// A chunk is recorded in the checkpoint after it's uploaded, so if the
+	// upload was interrupted in between, the file may hold one more chunk
+	// than the checkpoint does. 'unrecorded' is the size of that chunk.
+	var unrecorded int64
+	if len(cp.Chunks) > 0 {
+		fileInfo, err := c.InspectFile(repoName, commitID, path)
+		if err != nil {
+			return 0, fmt.Errorf("could not resume upload to %s@%s:%s: %v", repoName, commitID, path, err)
+		}
+		unrecorded = int64(fileInfo.SizeBytes) - cp.sizeBytes()
+		if unrecorded < 0 || unrecorded > chunkSize {
+			return 0, fmt.Errorf("could not resume upload to %s@%s:%s: the file is %d bytes, but "+
+				"checkpoint %s records %d bytes (was it modified during the upload?)",
+				repoName, commitID, path, fileInfo.SizeBytes, checkpointPath, cp.sizeBytes())
+		}
+	}
+	recorded := len(cp.Chunks)
+
+	// each chunk is uploaded with a separate PutFile, so progress is reported
+	// here rather than by PutFile
+	pachClient := c.withoutProgress()
+	totalBytes := readerSize(reader)
+	var read int64
+	var written int
+	var i int // the index of the current chunk
+	buf := make([]byte, chunkSize)
+	for ; ; i++ {
+		n, err := io.ReadFull(reader, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return written, err
+		}
+		if n == 0 && i > 0 {
+			break
+		}
+		chunk := buf[:n]
+		sum := sha256.Sum256(chunk)
+		hash := hex.EncodeToString(sum[:])
+		switch {
+		case i < recorded:
+			if cp.Chunks[i].Hash != hash || cp.Chunks[i].Size != int64(n) {
+				return written, fmt.Errorf("the data being uploaded differs from chunk %d "+
+					"in checkpoint %s (was it modified during the upload?)", i, checkpointPath)
+			}
+		case i == recorded && unrecorded > 0:
+			if unrecorded != int64(n) {
+				return written, fmt.Errorf("could not resume upload to %s@%s:%s: the file holds %d "+
+					"unrecorded bytes, but the next chunk is %d bytes", repoName, commitID, path, unrecorded, n)
+			}
+			unrecorded = 0
+			cp.Chunks = append(cp.Chunks, resumableChunk{Hash: hash, Size: int64(n)})
+			if err := writeCheckpoint(checkpointPath, cp); err != nil {
+				return written, err
+			}
+		default:
+			if i == 0 {
+				_, err = pachClient.PutFileOverwrite(repoName, commitID, path, bytes.NewReader(chunk), 0)
+			} else {
+				_, err = pachClient.PutFile(repoName, commitID, path, bytes.NewReader(chunk))
+			}
+			if err != nil {
+				return written, err
+			}
+			written += n
+			cp.Chunks = append(cp.Chunks, resumableChunk{Hash: hash, Size: int64(n)})
+			if err := writeCheckpoint(checkpointPath, cp); err != nil {
+				return written, err
+			}
+		}
+		read += int64(n)
+		c.progress.report(path, read, totalBytes, false)
+		if int64(n) < chunkSize {
+			i++
+			break
+		}
+	}
+	if i < recorded || unrecorded > 0 {
+		return written, fmt.Errorf("the data being uploaded is shorter than checkpoint %s "+
+			"records (was it modified during the upload?)", checkpointPath)
+	}
+	if err := os.Remove(checkpointPath); err != nil && !os.IsNotExist(err) {
+		return written, fmt.Errorf("could not remove checkpoint: %v", err)
+	}
+	c.progress.report(path, read, totalBytes, true)
+	return written, nil
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/client/limit"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/server/pkg/backoff"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultPutFileURLParallelism is the number of URLs that PutFileURLs has
+// pachd fetch at a time, if no parallelism is given.
+const DefaultPutFileURLParallelism = 8
+
+// PutFileURLOptions are the options of PutFileURLs
+type PutFileURLOptions struct {
+	// Recursive and Overwrite are as in PutFileURL
+	Recursive bool
+	Overwrite bool
+	// Parallelism is the number of URLs fetched at a time
+	// (DefaultPutFileURLParallelism if 0)
+	Parallelism int
+	// Retries is the number of times that fetching each URL is retried if it
+	// fails. Retrying a URL that pachd fetched, but whose response was lost,
+	// appends its contents again unless Overwrite is set.
+	Retries int
+	// Headers are HTTP headers sent with requests for http:// and https://
+	// URLs, e.g. {"Authorization": "Bearer <token>"}
+	Headers map[string]string
+	// Credentials, if set, are used to read s3:// and gs:// URLs, instead of
+	// the credentials of pachd's object store
+	Credentials *pfs.URLCredentials
+}
+
+// PutFileURLs is like PutFileURL, but puts the content of many URLs: 'urls'
+// maps the path of each file to put to the URL to fetch it from. The URLs are
+// fetched by pachd 'opts.Parallelism' at a time, each URL is retried
+// 'opts.Retries' times, and the first URL that can't be fetched is returned
+// as an error. 'opts' may be nil.
+func (c APIClient) PutFileURLs(repoName string, commitID string, urls map[string]string, opts *PutFileURLOptions) error {
+	if opts == nil {
+		opts = &PutFileURLOptions{}
+	}
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultPutFileURLParallelism
+	}
+	if opts.Retries < 0 {
+		return fmt.Errorf("retries must be at least 0, but was %d", opts.Retries)
+	}
+	// fetch the URLs in a deterministic order
+	paths := make([]string, 0, len(urls))
+	for p := range urls {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	limiter := limit.New(parallelism)
+	eg, ctx := errgroup.WithContext(c.Ctx())
+	pachClient := c.WithCtx(ctx)
+	for _, p := range paths {
+		p := p
+		limiter.Acquire()
+		if ctx.Err() != nil {
+			limiter.Release()
+			break // a URL failed; eg.Wait() returns its error
+		}
+		eg.Go(func() error {
+			defer limiter.Release()
+			if err := pachClient.putFileURLWithRetry(repoName, commitID, p, urls[p], opts); err != nil {
+				return fmt.Errorf("error putting %s at %s: %v", urls[p], p, err)
+			}
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+func (c APIClient) putFileURLWithRetry(repoName, commitID, path, url string, opts *PutFileURLOptions) error {
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = 0 // bounded by opts.Retries instead
+	for retries := 0; ; retries++ {
+		pfc, err := c.newOneoffPutFileClient()
+		if err == nil {
+			err = pfc.putFileURL(&pfs.PutFileRequest{
+				File:           NewFile(repoName, commitID, path),
+				Url:            url,
+				Recursive:      opts.Recursive,
+				UrlHeaders:     opts.Headers,
+				UrlCredentials: opts.Credentials,
+			}, opts.Overwrite)
+		}
+		if err == nil || retries >= opts.Retries {
+			return err
+		}
+		select {
+		case <-time.After(b.NextBackOff()):
+		case <-c.Ctx().Done():
+			return err
+		}
+	}
+}
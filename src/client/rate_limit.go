@@ -0,0 +1,49 @@
+package client
+
+import (
+	"golang.org/x/net/context"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// rateLimiter throttles the RPCs that a client sends (see WithRateLimit)
+type rateLimiter struct {
+	limiter *rate.Limiter
+}
+
+func newRateLimiter(qps float64, burst int) *rateLimiter {
+	return &rateLimiter{limiter: rate.NewLimiter(rate.Limit(qps), burst)}
+}
+
+// wait blocks until the next RPC may be sent. If 'ctx' is done first (or its
+// deadline would pass first), it returns a Canceled or DeadlineExceeded error,
+// as if the RPC had been sent and had failed that way.
+func (r *rateLimiter) wait(ctx context.Context) error {
+	if err := r.limiter.Wait(ctx); err != nil {
+		if ctx.Err() == context.Canceled {
+			return status.Errorf(codes.Canceled, "waiting for the client's rate limit: %v", err)
+		}
+		return status.Errorf(codes.DeadlineExceeded, "waiting for the client's rate limit: %v", err)
+	}
+	return nil
+}
+
+func (r *rateLimiter) unaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if err := r.wait(ctx); err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func (r *rateLimiter) streamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if err := r.wait(ctx); err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/server/pkg/backoff"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy describes how a client retries RPCs that fail with transient
+// errors (see WithRetryPolicy())
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times an RPC is attempted,
+	// including the first attempt
+	MaxAttempts int
+	// InitialBackoff is the time waited before the first retry. Each
+	// subsequent wait is Multiplier times longer than the last, up to
+	// MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter randomizes each wait by up to this fraction of its length (e.g.
+	// 0.5 waits between 50% and 150% of the backoff), so that many clients
+	// retrying against a restarted pachd don't all retry at once
+	Jitter float64
+	// RetryableCodes are the gRPC status codes that cause an RPC to be
+	// retried. Any other error is returned immediately.
+	RetryableCodes []codes.Code
+}
+
+// DefaultRetryPolicy is a RetryPolicy that rides out a pachd restart (which
+// surfaces as codes.Unavailable) of up to about 30 seconds
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    8,
+	InitialBackoff: 250 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.5,
+	RetryableCodes: []codes.Code{codes.Unavailable},
+}
+
+func (p RetryPolicy) validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("retry policy must allow at least 1 attempt, but allows %d", p.MaxAttempts)
+	}
+	if p.InitialBackoff < 0 || p.MaxBackoff < p.InitialBackoff {
+		return fmt.Errorf("retry policy's backoff must satisfy 0 <= initial (%v) <= max (%v)", p.InitialBackoff, p.MaxBackoff)
+	}
+	if p.Multiplier < 1 {
+		return fmt.Errorf("retry policy's backoff multiplier must be at least 1, but was %v", p.Multiplier)
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("retry policy's jitter must be between 0 and 1, but was %v", p.Jitter)
+	}
+	return nil
+}
+
+// retryable returns true if 'err' has one of the policy's retryable codes
+func (p RetryPolicy) retryable(err error) bool {
+	code := status.Code(err)
+	for _, c := range p.RetryableCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backOff returns the sequence of waits between the policy's attempts
+func (p RetryPolicy) backOff() backoff.BackOff {
+	b := &backoff.ExponentialBackOff{
+		InitialInterval:     p.InitialBackoff,
+		RandomizationFactor: p.Jitter,
+		Multiplier:          p.Multiplier,
+		MaxInterval:         p.MaxBackoff,
+		Clock:               backoff.SystemClock,
+	}
+	b.Reset()
+	return b
+}
+
+// retry calls 'f' until it succeeds, returns a non-retryable error, the
+// policy's attempts are exhausted or 'ctx' is done. It returns f's last error.
+// Retries are recorded in 'metrics' (which may be nil).
+func (p RetryPolicy) retry(ctx context.Context, method string, metrics *Metrics, f func() error) error {
+	b := p.backOff()
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= p.MaxAttempts || !p.retryable(err) {
+			return err
+		}
+		select {
+		case <-time.After(b.NextBackOff()):
+		case <-ctx.Done():
+			return err
+		}
+		metrics.recordRetry(method)
+	}
+}
+
+// unaryInterceptor returns an interceptor that retries unary RPCs according
+// to the policy
+func (p RetryPolicy) unaryInterceptor(metrics *Metrics) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return p.retry(ctx, method, metrics, func() error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		})
+	}
+}
+
+// streamInterceptor returns an interceptor that retries opening streams
+// according to the policy. Errors that occur once a stream is open aren't
+// retried, as messages may already have been sent or received on it.
+func (p RetryPolicy) streamInterceptor(metrics *Metrics) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		var stream grpc.ClientStream
+		err := p.retry(ctx, method, metrics, func() error {
+			var err error
+			stream, err = streamer(ctx, desc, cc, method, opts...)
+			return err
+		})
+		return stream, err
+	}
+}
@@ -0,0 +1,341 @@
+package client
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+	v1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// SOCKS5 protocol constants (see RFC 1928)
+const (
+	socks5Version = 5
+
+	socks5NoAuth       = 0
+	socks5NoAcceptable = 0xff
+
+	socks5Connect = 1
+
+	socks5IPv4   = 1
+	socks5Domain = 3
+	socks5IPv6   = 4
+
+	socks5Succeeded          = 0
+	socks5HostUnreachable    = 4
+	socks5CommandUnsupported = 7
+	socks5AddressUnsupported = 8
+)
+
+// socksProxy is a SOCKS5 proxy that forwards each connection it accepts to
+// the pod or service named by the connection's destination address
+type socksProxy struct {
+	forwarder *PortForwarder
+	listener  net.Listener
+	localPort int
+	wg        sync.WaitGroup
+
+	// tunnels holds the tunnel that serves each destination, keyed by
+	// "pod/<name>:<port>" or "service/<name>:<port>"
+	tunnelsLock sync.Mutex
+	tunnels     map[string]*tunnel
+}
+
+// RunSOCKS5Proxy serves a SOCKS5 proxy on 'localPort' that forwards
+// connections to any pod or service in the port forwarder's namespace. A
+// destination may be a pod name, a service name (optionally qualified with the
+// namespace, e.g. "pachd.default.svc.cluster.local"), or the IP address of a
+// pod or service. Connections to a service are forwarded to one of the pods
+// backing it, using the service's target port. Returns the bound local port.
+func (f *PortForwarder) RunSOCKS5Proxy(localPort int) (int, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", localPort))
+	if err != nil && f.FreePortFallback {
+		listener, err = net.Listen("tcp", "localhost:0")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("unable to listen on port %d: %v", localPort, err)
+	}
+	p := &socksProxy{
+		forwarder: f,
+		listener:  listener,
+		localPort: listener.Addr().(*net.TCPAddr).Port,
+		tunnels:   make(map[string]*tunnel),
+	}
+
+	f.tunnelsLock.Lock()
+	if f.shutdown {
+		f.tunnelsLock.Unlock()
+		listener.Close()
+		return 0, fmt.Errorf("port forwarder is shutdown")
+	}
+	f.proxies = append(f.proxies, p)
+	f.tunnelsLock.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.serve()
+	}()
+	return p.localPort, nil
+}
+
+// serve accepts connections on the proxy's listener until it's closed
+func (p *socksProxy) serve() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.handle(conn)
+		}()
+	}
+}
+
+// handle negotiates a SOCKS5 CONNECT request on 'conn' and, if it succeeds,
+// forwards the connection to its destination
+func (p *socksProxy) handle(conn net.Conn) {
+	host, port, err := socks5Handshake(conn)
+	if err != nil {
+		p.logErr(fmt.Errorf("SOCKS5 handshake failed: %v", err))
+		conn.Close()
+		return
+	}
+	t, err := p.tunnel(host, port)
+	if err != nil {
+		p.logErr(fmt.Errorf("could not forward SOCKS5 connection to %s: %v", net.JoinHostPort(host, strconv.Itoa(port)), err))
+		socks5Reply(conn, socks5HostUnreachable)
+		conn.Close()
+		return
+	}
+	if err := socks5Reply(conn, socks5Succeeded); err != nil {
+		conn.Close()
+		return
+	}
+	t.handle(conn)
+}
+
+func (p *socksProxy) logErr(err error) {
+	if p.forwarder.stderr != nil {
+		fmt.Fprintln(p.forwarder.stderr, err)
+	}
+}
+
+// tunnel returns an open tunnel to the destination host:port, opening one if
+// necessary
+func (p *socksProxy) tunnel(host string, port int) (*tunnel, error) {
+	f := p.forwarder
+	t := &tunnel{
+		forwarder: f,
+		proxied:   true,
+		localPort: p.localPort,
+		stopChan:  make(chan struct{}),
+	}
+	var key string
+	if ip := net.ParseIP(host); ip != nil {
+		name, isService, err := f.lookupIP(ip.String())
+		if err != nil {
+			return nil, err
+		}
+		if isService {
+			host = name + "." + f.namespace
+		} else {
+			host = name
+		}
+	}
+	// strip the namespace and cluster domain from qualified service names
+	parts := strings.SplitN(host, ".", 3)
+	if len(parts) > 1 && parts[1] != f.namespace {
+		return nil, fmt.Errorf("%s is not in namespace %q", host, f.namespace)
+	}
+	name := parts[0]
+	service, err := f.core.Services(f.namespace).Get(name, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		if len(service.Spec.Selector) == 0 {
+			return nil, fmt.Errorf("service %s has no selector", name)
+		}
+		targetPort, err := serviceTargetPort(service, port)
+		if err != nil {
+			return nil, err
+		}
+		if targetPort.Type == intstr.String {
+			// named ports are resolved against the pod that is chosen, so
+			// pin the tunnel to that pod
+			if t.pod, err = f.choosePod(service.Spec.Selector); err != nil {
+				return nil, err
+			}
+			if t.remotePort, err = f.namedContainerPort(t.pod, targetPort.StrVal); err != nil {
+				return nil, err
+			}
+		} else {
+			t.remotePort = targetPort.IntValue()
+		}
+		t.selector = service.Spec.Selector
+		key = fmt.Sprintf("service/%s:%d", name, port)
+	case kubeerrors.IsNotFound(err) && len(parts) == 1:
+		if _, err := f.core.Pods(f.namespace).Get(name, metav1.GetOptions{}); err != nil {
+			return nil, fmt.Errorf("no service or pod named %s: %v", name, err)
+		}
+		t.pod = name
+		t.remotePort = port
+		key = fmt.Sprintf("pod/%s:%d", name, port)
+	default:
+		return nil, fmt.Errorf("could not look up service %s: %v", name, err)
+	}
+
+	p.tunnelsLock.Lock()
+	defer p.tunnelsLock.Unlock()
+	if existing, ok := p.tunnels[key]; ok && !isClosed(existing.stopChan) {
+		return existing, nil
+	}
+	if _, err := f.runTunnel(context.Background(), t); err != nil {
+		return nil, err
+	}
+	p.tunnels[key] = t
+	return t, nil
+}
+
+// lookupIP returns the name of the pod or service in the port forwarder's
+// namespace with the IP address 'ip'
+func (f *PortForwarder) lookupIP(ip string) (string, bool, error) {
+	services, err := f.core.Services(f.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return "", false, err
+	}
+	for _, service := range services.Items {
+		if service.Spec.ClusterIP == ip {
+			return service.Name, true, nil
+		}
+	}
+	pods, err := f.core.Pods(f.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return "", false, err
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.PodIP == ip {
+			return pod.Name, false, nil
+		}
+	}
+	return "", false, fmt.Errorf("no pod or service in namespace %q has IP %s", f.namespace, ip)
+}
+
+// serviceTargetPort returns the pod port that 'service' sends traffic for its
+// port 'port' to
+func serviceTargetPort(service *v1.Service, port int) (intstr.IntOrString, error) {
+	for _, servicePort := range service.Spec.Ports {
+		if int(servicePort.Port) != port {
+			continue
+		}
+		if servicePort.TargetPort.Type == intstr.Int && servicePort.TargetPort.IntVal == 0 {
+			return intstr.FromInt(port), nil // target port defaults to the port
+		}
+		return servicePort.TargetPort, nil
+	}
+	return intstr.IntOrString{}, fmt.Errorf("service %s does not expose port %d", service.Name, port)
+}
+
+// namedContainerPort returns the number of the container port named 'portName'
+// in the pod 'podName'
+func (f *PortForwarder) namedContainerPort(podName, portName string) (int, error) {
+	pod, err := f.core.Pods(f.namespace).Get(podName, metav1.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Name == portName {
+				return int(port.ContainerPort), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("pod %s has no port named %q", podName, portName)
+}
+
+// socks5Handshake reads a SOCKS5 greeting and CONNECT request from 'conn',
+// and returns the requested destination
+func socks5Handshake(conn net.Conn) (string, int, error) {
+	// greeting: VER NMETHODS METHODS...
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", 0, err
+	}
+	if header[0] != socks5Version {
+		return "", 0, fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", 0, err
+	}
+	method := byte(socks5NoAcceptable)
+	for _, m := range methods {
+		if m == socks5NoAuth {
+			method = socks5NoAuth
+		}
+	}
+	if _, err := conn.Write([]byte{socks5Version, method}); err != nil {
+		return "", 0, err
+	}
+	if method == socks5NoAcceptable {
+		return "", 0, fmt.Errorf("client does not support unauthenticated connections")
+	}
+
+	// request: VER CMD RSV ATYP DST.ADDR DST.PORT
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return "", 0, err
+	}
+	if request[1] != socks5Connect {
+		socks5Reply(conn, socks5CommandUnsupported)
+		return "", 0, fmt.Errorf("unsupported SOCKS5 command %d", request[1])
+	}
+	var host string
+	switch request[3] {
+	case socks5IPv4, socks5IPv6:
+		size := net.IPv4len
+		if request[3] == socks5IPv6 {
+			size = net.IPv6len
+		}
+		ip := make([]byte, size)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", 0, err
+		}
+		host = net.IP(ip).String()
+	case socks5Domain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", 0, err
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", 0, err
+		}
+		host = string(domain)
+	default:
+		socks5Reply(conn, socks5AddressUnsupported)
+		return "", 0, fmt.Errorf("unsupported SOCKS5 address type %d", request[3])
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", 0, err
+	}
+	return host, int(binary.BigEndian.Uint16(port)), nil
+}
+
+// socks5Reply sends a SOCKS5 reply with the status 'status' to 'conn'. The
+// bound address isn't meaningful for forwarded connections, so it's always
+// reported as 0.0.0.0:0.
+func socks5Reply(conn net.Conn, status byte) error {
+	_, err := conn.Write([]byte{socks5Version, status, 0, socks5IPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
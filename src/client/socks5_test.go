@@ -0,0 +1,193 @@
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+)
+
+// socks5Request returns a CONNECT request (or a request with the command
+// 'cmd') for the address 'addr', of the type 'addrType', and port 650
+func socks5Request(cmd byte, addrType byte, addr ...byte) []byte {
+	request := append([]byte{socks5Version, cmd, 0, addrType}, addr...)
+	return append(request, 0x02, 0x8a)
+}
+
+func TestSOCKS5Handshake(t *testing.T) {
+	greeting := []byte{socks5Version, 1, socks5NoAuth}
+	accepted := []byte{socks5Version, socks5NoAuth}
+	ipv4 := socks5Request(socks5Connect, socks5IPv4, 10, 0, 0, 1)
+	domain := socks5Request(socks5Connect, socks5Domain, append([]byte{5}, "pachd"...)...)
+	ipv6 := socks5Request(socks5Connect, socks5IPv6, 0xfd, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1)
+	reply := func(status byte) []byte {
+		return []byte{socks5Version, status, 0, socks5IPv4, 0, 0, 0, 0, 0, 0}
+	}
+	concat := func(bs ...[]byte) []byte {
+		return bytes.Join(bs, nil)
+	}
+	for _, test := range []struct {
+		name string
+		// input is sent by the client, which closes the connection after
+		// sending it if 'truncated' is set
+		input     []byte
+		truncated bool
+		host      string
+		port      int
+		err       string
+		// replies is what the proxy sends, and isn't checked if it's nil
+		replies []byte
+	}{
+		{
+			name:    "ipv4",
+			input:   concat(greeting, ipv4),
+			host:    "10.0.0.1",
+			port:    650,
+			replies: accepted,
+		},
+		{
+			name:    "domain",
+			input:   concat(greeting, domain),
+			host:    "pachd",
+			port:    650,
+			replies: accepted,
+		},
+		{
+			name:    "ipv6",
+			input:   concat(greeting, ipv6),
+			host:    "fd00::1",
+			port:    650,
+			replies: accepted,
+		},
+		{
+			name:    "no auth among several methods",
+			input:   concat([]byte{socks5Version, 3, 1, socks5UserPass, socks5NoAuth}, ipv4),
+			host:    "10.0.0.1",
+			port:    650,
+			replies: accepted,
+		},
+		{
+			name:    "no acceptable methods",
+			input:   concat([]byte{socks5Version, 1, socks5UserPass}, ipv4),
+			err:     "does not support unauthenticated connections",
+			replies: []byte{socks5Version, socks5NoAcceptable},
+		},
+		{
+			name:    "no methods",
+			input:   concat([]byte{socks5Version, 0}, ipv4),
+			err:     "does not support unauthenticated connections",
+			replies: []byte{socks5Version, socks5NoAcceptable},
+		},
+		{
+			name:    "unsupported version",
+			input:   concat([]byte{4, 1, socks5NoAuth}, ipv4),
+			err:     "unsupported SOCKS version 4",
+			replies: []byte{},
+		},
+		{
+			name:    "bind",
+			input:   concat(greeting, socks5Request(2, socks5IPv4, 10, 0, 0, 1)),
+			err:     "unsupported SOCKS5 command 2",
+			replies: concat(accepted, reply(socks5CommandUnsupported)),
+		},
+		{
+			name:    "udp associate",
+			input:   concat(greeting, socks5Request(3, socks5IPv4, 10, 0, 0, 1)),
+			err:     "unsupported SOCKS5 command 3",
+			replies: concat(accepted, reply(socks5CommandUnsupported)),
+		},
+		{
+			name:    "unsupported address type",
+			input:   concat(greeting, socks5Request(socks5Connect, 2, 10, 0, 0, 1)),
+			err:     "unsupported SOCKS5 address type 2",
+			replies: concat(accepted, reply(socks5AddressUnsupported)),
+		},
+		{
+			name:      "empty",
+			truncated: true,
+			err:       "EOF",
+			replies:   []byte{},
+		},
+		{
+			name:      "truncated greeting",
+			input:     []byte{socks5Version, 2, socks5NoAuth},
+			truncated: true,
+			err:       "EOF",
+			replies:   []byte{},
+		},
+		{
+			name:      "truncated request",
+			input:     concat(greeting, ipv4[:3]),
+			truncated: true,
+			err:       "EOF",
+		},
+		{
+			name:      "truncated ipv4 address",
+			input:     concat(greeting, ipv4[:6]),
+			truncated: true,
+			err:       "EOF",
+		},
+		{
+			name:      "truncated ipv6 address",
+			input:     concat(greeting, ipv6[:12]),
+			truncated: true,
+			err:       "EOF",
+		},
+		{
+			name:      "missing domain length",
+			input:     concat(greeting, domain[:4]),
+			truncated: true,
+			err:       "EOF",
+		},
+		{
+			name:      "truncated domain",
+			input:     concat(greeting, domain[:7]),
+			truncated: true,
+			err:       "EOF",
+		},
+		{
+			name:      "truncated port",
+			input:     concat(greeting, domain[:len(domain)-1]),
+			truncated: true,
+			err:       "EOF",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				// writes fail once the proxy closes its end
+				client.Write(test.input)
+				if test.truncated {
+					client.Close()
+				}
+			}()
+			var replies []byte
+			go func() {
+				defer wg.Done()
+				replies, _ = ioutil.ReadAll(client)
+			}()
+			host, port, err := socks5Handshake(server)
+			server.Close()
+			wg.Wait()
+			client.Close()
+
+			if test.err != "" {
+				require.YesError(t, err)
+				require.Matches(t, test.err, err.Error())
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.host, host)
+				require.Equal(t, test.port, port)
+			}
+			if test.replies != nil {
+				require.Equal(t, test.replies, append([]byte{}, replies...))
+			}
+		})
+	}
+}
@@ -0,0 +1,468 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pachyderm/pachyderm/src/client/limit"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"golang.org/x/sync/errgroup"
+)
+
+// SyncStateFile is the name of the file in which SyncDir records the state of
+// a local directory as of its last sync
+const SyncStateFile = ".pachsync"
+
+// SyncDirection is the direction in which SyncDir syncs
+type SyncDirection int
+
+const (
+	// SyncPull updates a local directory to match a PFS path
+	SyncPull SyncDirection = iota
+	// SyncPush updates a PFS path to match a local directory, in a new commit
+	SyncPush
+)
+
+func (d SyncDirection) String() string {
+	switch d {
+	case SyncPull:
+		return "pull"
+	case SyncPush:
+		return "push"
+	default:
+		return "unknown"
+	}
+}
+
+// SyncResult describes the changes made by SyncDir. Paths are relative to the
+// local directory (and to the PFS path).
+type SyncResult struct {
+	// Commit is the commit that was pulled, or the commit created by a push.
+	// It's nil if a push had no changes to make, or a pull was from an empty
+	// branch.
+	Commit *pfs.Commit
+	// Updated are the files that were created or modified
+	Updated []string
+	// Deleted are the files that were deleted
+	Deleted []string
+}
+
+// SyncConflictError is returned by SyncDir if the local directory and the
+// PFS path have both changed since they were last synced. No changes are
+// made when it's returned.
+type SyncConflictError struct {
+	// Paths are the files that have changed on both sides (relative to the
+	// local directory). For pushes, they are the files that have changed in
+	// PFS, which must be pulled first.
+	Paths []string
+}
+
+func (e *SyncConflictError) Error() string {
+	return fmt.Sprintf("conflicting changes to %d files: %s", len(e.Paths), strings.Join(e.Paths, ", "))
+}
+
+// syncState is the contents of a SyncDir state file
+type syncState struct {
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	Prefix string `json:"prefix"`
+	// Commit is the ID of the commit that the directory was last synced with
+	Commit string `json:"commit"`
+	// Files maps the path of each file as of the last sync to its hashes
+	Files map[string]syncFileState `json:"files"`
+}
+
+type syncFileState struct {
+	// Remote is the hash that pachd gave the file (see FileInfo.Hash)
+	Remote string `json:"remote"`
+	// Local is the hash of the local copy of the file (see FileHash)
+	Local string `json:"local"`
+}
+
+func readSyncState(localPath string) (*syncState, error) {
+	data, err := ioutil.ReadFile(filepath.Join(localPath, SyncStateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &syncState{Files: make(map[string]syncFileState)}, nil
+		}
+		return nil, err
+	}
+	state := &syncState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", filepath.Join(localPath, SyncStateFile), err)
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]syncFileState)
+	}
+	return state, nil
+}
+
+func writeSyncState(localPath string, state *syncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := filepath.Join(localPath, SyncStateFile)
+	if err := ioutil.WriteFile(p+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(p+".tmp", p)
+}
+
+// localFileHashes returns the hashes (see FileHash) of the files under
+// 'localPath' that aren't ignored by 'rules', by their slash-separated paths
+// relative to 'localPath'
+func localFileHashes(localPath string, rules []*ignoreRule) (map[string]string, error) {
+	hashes := make(map[string]string)
+	if err := filepath.Walk(localPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			if filePath == localPath && os.IsNotExist(err) {
+				return filepath.SkipDir // pulling creates 'localPath'
+			}
+			return err
+		}
+		rel, err := filepath.Rel(localPath, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if info.IsDir() {
+			if ignored(rules, rel, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || rel == PachIgnoreFile || rel == SyncStateFile ||
+			rel == SyncStateFile+".tmp" || ignored(rules, rel, false) {
+			return nil
+		}
+		f, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		hash, err := FileHash(f)
+		if err != nil {
+			return fmt.Errorf("error hashing %s: %v", filePath, err)
+		}
+		hashes[rel] = pfs.EncodeHash(hash)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// remoteFileHashes returns the head of 'branch' (or nil, if it has none) and
+// the hashes of the files under 'prefix' in it that aren't ignored by
+// 'rules', by their paths relative to 'prefix'
+func (c APIClient) remoteFileHashes(repoName, branch, prefix string, rules []*ignoreRule) (*pfs.Commit, map[string]string, error) {
+	hashes := make(map[string]string)
+	commitInfo, err := c.InspectCommit(repoName, branch)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "has no head") {
+			return nil, hashes, nil
+		}
+		return nil, nil, err
+	}
+	root := path.Join("/", prefix)
+	if err := c.Walk(repoName, commitInfo.Commit.ID, root, func(fileInfo *pfs.FileInfo) error {
+		if fileInfo.FileType != pfs.FileType_FILE {
+			return nil
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(path.Join("/", fileInfo.File.Path), root), "/")
+		if rel == "" || rel == PachIgnoreFile || rel == SyncStateFile ||
+			ignored(rules, rel, false) || ignoredDir(rules, rel) {
+			return nil
+		}
+		hashes[rel] = pfs.EncodeHash(fileInfo.Hash)
+		return nil
+	}); err != nil && !strings.Contains(err.Error(), "not found") {
+		return nil, nil, err
+	}
+	return commitInfo.Commit, hashes, nil
+}
+
+// ignoredDir returns true if one of the directories containing 'rel' is
+// ignored by 'rules'
+func ignoredDir(rules []*ignoreRule, rel string) bool {
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		if ignored(rules, dir, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// SyncDir syncs the local directory 'localPath' with 'prefix' on 'branch' in
+// 'repoName', in the given direction:
+//   - SyncPull downloads the files that have changed in PFS since the last
+//     sync to 'localPath', and deletes the local copies of files that have
+//     been deleted in PFS.
+//   - SyncPush uploads the files that have changed locally since the last
+//     sync in a new commit on 'branch', and deletes the files in PFS that
+//     have been deleted locally.
+//
+// SyncDir records the commit and the files that 'localPath' was synced with
+// in a state file (SyncStateFile) in 'localPath', which it uses to tell which
+// side each change was made on. If the same file has been changed on both
+// sides, SyncDir returns a *SyncConflictError and makes no changes. Like
+// 'git push', a push also fails with a *SyncConflictError if any file in PFS
+// has changed since the last sync, in which case the changes must be pulled
+// first. Before a directory's first sync, every file in it counts as a local
+// change.
+//
+// Files ignored by the .pachignore file in 'localPath' (see PutFileDir) are
+// neither uploaded nor downloaded. Every local file is read and hashed by
+// each sync.
+func (c APIClient) SyncDir(localPath string, repoName string, branch string, prefix string, direction SyncDirection) (*SyncResult, error) {
+	state, err := readSyncState(localPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(state.Files) > 0 || state.Commit != "" {
+		if state.Repo != repoName || state.Branch != branch || path.Join("/", state.Prefix) != path.Join("/", prefix) {
+			return nil, fmt.Errorf("%s was synced with %s@%s:%s, not %s@%s:%s", localPath,
+				state.Repo, state.Branch, state.Prefix, repoName, branch, prefix)
+		}
+	}
+	state.Repo, state.Branch, state.Prefix = repoName, branch, prefix
+	rules, err := readPachIgnore(localPath)
+	if err != nil {
+		return nil, err
+	}
+	local, err := localFileHashes(localPath, rules)
+	if err != nil {
+		return nil, err
+	}
+	head, remote, err := c.remoteFileHashes(repoName, branch, prefix, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	// find the files that have changed on each side since the last sync
+	paths := make(map[string]bool)
+	for _, files := range []map[string]string{local, remote} {
+		for p := range files {
+			paths[p] = true
+		}
+	}
+	for p := range state.Files {
+		paths[p] = true
+	}
+	var localChanges, remoteChanges, conflicts []string
+	for p := range paths {
+		base, synced := state.Files[p]
+		localHash, inLocal := local[p]
+		remoteHash, inRemote := remote[p]
+		localChanged := inLocal != synced || (inLocal && localHash != base.Local)
+		remoteChanged := inRemote != synced || (inRemote && remoteHash != base.Remote)
+		if localChanged {
+			localChanges = append(localChanges, p)
+		}
+		if remoteChanged {
+			remoteChanges = append(remoteChanges, p)
+		}
+		// a file deleted on both sides, or changed in the same way on both
+		// sides, isn't a conflict
+		if localChanged && remoteChanged && (inLocal || inRemote) && localHash != remoteHash {
+			conflicts = append(conflicts, p)
+		}
+	}
+	sort.Strings(localChanges)
+	sort.Strings(remoteChanges)
+	sort.Strings(conflicts)
+
+	switch direction {
+	case SyncPull:
+		if len(conflicts) > 0 {
+			return nil, &SyncConflictError{Paths: conflicts}
+		}
+		return c.syncPull(localPath, state, head, remoteChanges, remote)
+	case SyncPush:
+		if len(remoteChanges) > 0 {
+			return nil, &SyncConflictError{Paths: remoteChanges}
+		}
+		return c.syncPush(localPath, state, localChanges, local)
+	default:
+		return nil, fmt.Errorf("invalid sync direction %d", direction)
+	}
+}
+
+// syncPull applies 'changes' (files that have changed in 'head') to
+// 'localPath', and records the new state of 'localPath' in 'state'
+func (c APIClient) syncPull(localPath string, state *syncState, head *pfs.Commit, changes []string, remote map[string]string) (*SyncResult, error) {
+	result := &SyncResult{Commit: head}
+	if err := os.MkdirAll(localPath, 0755); err != nil {
+		return nil, err
+	}
+	var updated []string
+	for _, p := range changes {
+		if _, ok := remote[p]; !ok {
+			if err := os.Remove(filepath.Join(localPath, filepath.FromSlash(p))); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			delete(state.Files, p)
+			result.Deleted = append(result.Deleted, p)
+			continue
+		}
+		updated = append(updated, p)
+	}
+
+	limiter := limit.New(DefaultPutFileDirParallelism)
+	eg, ctx := errgroup.WithContext(c.Ctx())
+	pachClient := c.WithCtx(ctx)
+	localHashes := make([]string, len(updated))
+	for i, p := range updated {
+		i, p := i, p
+		limiter.Acquire()
+		eg.Go(func() error {
+			defer limiter.Release()
+			var err error
+			localHashes[i], err = pachClient.syncPullFile(localPath, head.Repo.Name, head.ID, path.Join(state.Prefix, p), p)
+			return err
+		})
+	}
+	err := eg.Wait()
+	// record the files that were downloaded, even if others failed, so that
+	// they aren't treated as local changes by the next sync
+	for i, p := range updated {
+		if localHashes[i] != "" {
+			state.Files[p] = syncFileState{Remote: remote[p], Local: localHashes[i]}
+			result.Updated = append(result.Updated, p)
+		}
+	}
+	if err == nil {
+		state.Commit = head.GetID()
+	}
+	if err := writeSyncState(localPath, state); err != nil {
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// syncPullFile downloads 'pfsPath' to 'rel' in 'localPath', and returns the
+// hash of the downloaded file
+func (c APIClient) syncPullFile(localPath, repoName, commitID, pfsPath, rel string) (retHash string, retErr error) {
+	filePath := filepath.Join(localPath, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return "", err
+	}
+	// download to a temporary file, so that a failed download doesn't leave a
+	// partial file behind
+	f, err := ioutil.TempFile(filepath.Dir(filePath), "."+filepath.Base(filePath))
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if retErr != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	if err := c.GetFile(repoName, commitID, pfsPath, 0, 0, f); err != nil {
+		f.Close()
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return "", err
+	}
+	fileHash, err := FileHash(f)
+	if err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(f.Name(), filePath); err != nil {
+		return "", err
+	}
+	return pfs.EncodeHash(fileHash), nil
+}
+
+// syncPush applies 'changes' (files that have changed in 'localPath') to the
+// PFS path in 'state' in a new commit, and records the new state of
+// 'localPath' in 'state'
+func (c APIClient) syncPush(localPath string, state *syncState, changes []string, local map[string]string) (_ *SyncResult, retErr error) {
+	result := &SyncResult{}
+	if len(changes) == 0 {
+		return result, nil
+	}
+	commit, err := c.StartCommit(state.Repo, state.Branch)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if retErr != nil {
+			c.DeleteCommit(commit.Repo.Name, commit.ID)
+		}
+	}()
+
+	limiter := limit.New(DefaultPutFileDirParallelism)
+	eg, ctx := errgroup.WithContext(c.Ctx())
+	pachClient := c.WithCtx(ctx)
+	for _, p := range changes {
+		p := p
+		pfsPath := path.Join("/", state.Prefix, p)
+		if _, ok := local[p]; !ok {
+			result.Deleted = append(result.Deleted, p)
+			limiter.Acquire()
+			eg.Go(func() error {
+				defer limiter.Release()
+				return pachClient.DeleteFile(commit.Repo.Name, commit.ID, pfsPath)
+			})
+			continue
+		}
+		result.Updated = append(result.Updated, p)
+		limiter.Acquire()
+		eg.Go(func() (retErr error) {
+			defer limiter.Release()
+			f, err := os.Open(filepath.Join(localPath, filepath.FromSlash(p)))
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err := f.Close(); err != nil && retErr == nil {
+					retErr = err
+				}
+			}()
+			_, err = pachClient.PutFileOverwrite(commit.Repo.Name, commit.ID, pfsPath, f, 0)
+			return err
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	if err := c.FinishCommit(commit.Repo.Name, commit.ID); err != nil {
+		return nil, err
+	}
+
+	// read back the hashes that pachd gave the uploaded files
+	_, remote, err := c.remoteFileHashes(state.Repo, commit.ID, state.Prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range result.Deleted {
+		delete(state.Files, p)
+	}
+	for _, p := range result.Updated {
+		state.Files[p] = syncFileState{Remote: remote[p], Local: local[p]}
+	}
+	state.Commit = commit.ID
+	result.Commit = commit
+	return result, writeSyncState(localPath, state)
+}
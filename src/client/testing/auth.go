@@ -0,0 +1,326 @@
+package testing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/server/pkg/uuid"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// authState is the state of the fake's auth service, once it's activated.
+// The fake implements a small part of pachd's auth model: tokens that are
+// never revoked or expire, cluster admins (which are the subjects that
+// activated auth), and per-repo ACLs, which PFS RPCs are authorized against.
+// PPS RPCs aren't authorized.
+type authState struct {
+	// tokens maps each token to its subject
+	tokens map[string]string
+	admins map[string]bool
+	// acls maps each repo to the scope of each subject that has access to it
+	acls map[string]map[string]auth.Scope
+}
+
+// pfsWriteMethods are the PFS RPCs that require WRITER access to the repos
+// that they refer to. DeleteRepo requires OWNER access, and the rest READER.
+var pfsWriteMethods = map[string]bool{
+	"StartCommit":  true,
+	"FinishCommit": true,
+	"DeleteCommit": true,
+	"CreateBranch": true,
+	"DeleteBranch": true,
+	"PutFile":      true,
+	"CopyFile":     true,
+	"DeleteFile":   true,
+}
+
+// callerToken returns the auth token in the metadata of 'ctx', if any
+func callerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md[auth.ContextTokenKey]) == 0 {
+		return ""
+	}
+	return md[auth.ContextTokenKey][0]
+}
+
+// caller returns the subject whose token is in the metadata of 'ctx'. It must
+// be called with s.mu held, once auth is active.
+func (s *state) caller(ctx context.Context) (string, error) {
+	token := callerToken(ctx)
+	if token == "" {
+		return "", auth.ErrNotSignedIn
+	}
+	subject, ok := s.auth.tokens[token]
+	if !ok {
+		return "", auth.ErrBadToken
+	}
+	return subject, nil
+}
+
+// scope returns the scope of 'subject' on 'repo'. It must be called with s.mu
+// held, once auth is active.
+func (s *state) scope(subject string, repo string) auth.Scope {
+	if s.auth.admins[subject] {
+		return auth.Scope_OWNER
+	}
+	return s.auth.acls[repo][subject]
+}
+
+// authorize returns an error unless the caller has at least 'required' access
+// to 'repo', or auth isn't active
+func (s *state) authorize(ctx context.Context, repo string, required auth.Scope) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.auth == nil {
+		return nil
+	}
+	subject, err := s.caller(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.repos[repo]; !ok {
+		// Let the RPC report that the repo doesn't exist
+		return nil
+	}
+	if s.scope(subject, repo) < required {
+		return &auth.ErrNotAuthorized{Subject: subject, Repo: repo, Required: required}
+	}
+	return nil
+}
+
+// authorizePFSRequest returns an error unless the caller has the access to the
+// repos that 'req' (a request of the PFS RPC 'method') refers to that the RPC
+// requires
+func (s *state) authorizePFSRequest(ctx context.Context, method string, req interface{}) error {
+	required := auth.Scope_READER
+	switch {
+	case method == "DeleteRepo":
+		required = auth.Scope_OWNER
+	case pfsWriteMethods[method]:
+		required = auth.Scope_WRITER
+	}
+	var repos []string
+	switch req := req.(type) {
+	case *pfs.CreateRepoRequest:
+		// Creating a repo only requires being signed in, and updating one
+		// requires owning it
+		return s.authorize(ctx, req.Repo.GetName(), auth.Scope_OWNER)
+	case *pfs.CopyFileRequest:
+		if err := s.authorize(ctx, req.Src.GetCommit().GetRepo().GetName(), auth.Scope_READER); err != nil {
+			return err
+		}
+		repos = append(repos, req.Dst.GetCommit().GetRepo().GetName())
+	case interface{ GetRepo() *pfs.Repo }:
+		repos = append(repos, req.GetRepo().GetName())
+	case interface{ GetCommit() *pfs.Commit }:
+		repos = append(repos, req.GetCommit().GetRepo().GetName())
+	case interface{ GetBranch() *pfs.Branch }:
+		repos = append(repos, req.GetBranch().GetRepo().GetName())
+	case interface{ GetFile() *pfs.File }:
+		repos = append(repos, req.GetFile().GetCommit().GetRepo().GetName())
+	case interface{ GetParent() *pfs.Commit }:
+		repos = append(repos, req.GetParent().GetRepo().GetName())
+	}
+	if len(repos) == 0 {
+		// The RPC doesn't refer to a repo (e.g. ListRepo), but still requires
+		// the caller to be signed in
+		repos = append(repos, "")
+	}
+	for _, repo := range repos {
+		if err := s.authorize(ctx, repo, required); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authorizePFS returns interceptors that authorize PFS RPCs once auth is
+// active, and record the creators of repos as their owners
+func (s *state) authorizePFS() (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	rpcName := func(fullMethod string) (string, bool) {
+		if !strings.HasPrefix(fullMethod, "/pfs.API/") {
+			return "", false
+		}
+		return strings.TrimPrefix(fullMethod, "/pfs.API/"), true
+	}
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		method, ok := rpcName(info.FullMethod)
+		if !ok {
+			return handler(ctx, req)
+		}
+		if err := s.authorizePFSRequest(ctx, method, req); err != nil {
+			return nil, err
+		}
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.auth == nil {
+			return resp, nil
+		}
+		subject, _ := s.caller(ctx)
+		switch req := req.(type) {
+		case *pfs.CreateRepoRequest:
+			if !req.Update {
+				s.auth.acls[req.Repo.Name] = map[string]auth.Scope{subject: auth.Scope_OWNER}
+			}
+		case *pfs.DeleteRepoRequest:
+			if req.All {
+				s.auth.acls = make(map[string]map[string]auth.Scope)
+			} else {
+				delete(s.auth.acls, req.Repo.GetName())
+			}
+		}
+		if resp, ok := resp.(*pfs.ListRepoResponse); ok {
+			for _, repoInfo := range resp.RepoInfo {
+				repoInfo.AuthInfo = &pfs.RepoAuthInfo{AccessLevel: s.scope(subject, repoInfo.Repo.Name)}
+			}
+		}
+		return resp, nil
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		method, ok := rpcName(info.FullMethod)
+		if !ok {
+			return handler(srv, ss)
+		}
+		return handler(srv, &authorizedStream{ServerStream: ss, s: s, method: method})
+	}
+	return unary, stream
+}
+
+// authorizedStream authorizes each request that's received on a streaming PFS
+// RPC
+type authorizedStream struct {
+	grpc.ServerStream
+	s      *state
+	method string
+}
+
+func (a *authorizedStream) RecvMsg(m interface{}) error {
+	if err := a.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return a.s.authorizePFSRequest(a.Context(), a.method, m)
+}
+
+// authRequests maps each auth RPC that the fake implements to a function that
+// returns an empty request of the RPC
+var authRequests = map[string]func() proto.Message{
+	"Activate":     func() proto.Message { return &auth.ActivateRequest{} },
+	"Deactivate":   func() proto.Message { return &auth.DeactivateRequest{} },
+	"WhoAmI":       func() proto.Message { return &auth.WhoAmIRequest{} },
+	"Authorize":    func() proto.Message { return &auth.AuthorizeRequest{} },
+	"GetScope":     func() proto.Message { return &auth.GetScopeRequest{} },
+	"SetScope":     func() proto.Message { return &auth.SetScopeRequest{} },
+	"GetAuthToken": func() proto.Message { return &auth.GetAuthTokenRequest{} },
+}
+
+// serveAuth serves the auth RPC 'method' on 'stream'
+func (s *state) serveAuth(method string, stream grpc.ServerStream) error {
+	newRequest, ok := authRequests[method]
+	if !ok {
+		s.mu.Lock()
+		active := s.auth != nil
+		s.mu.Unlock()
+		if !active {
+			return auth.ErrNotActivated
+		}
+		return unimplemented("/auth.API/" + method)
+	}
+	req := newRequest()
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	resp, err := s.handleAuth(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	return stream.SendMsg(resp)
+}
+
+// handleAuth returns the response to the auth request 'req'. Before auth is
+// activated, every RPC except Activate reports that auth isn't activated, as
+// in pachd.
+func (s *state) handleAuth(ctx context.Context, req proto.Message) (proto.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req, ok := req.(*auth.ActivateRequest); ok {
+		if s.auth != nil {
+			return nil, fmt.Errorf("already activated")
+		}
+		if req.Subject == "" {
+			return nil, fmt.Errorf("the fake pachd requires ActivateRequest.Subject")
+		}
+		s.auth = &authState{
+			tokens: make(map[string]string),
+			admins: map[string]bool{req.Subject: true},
+			acls:   make(map[string]map[string]auth.Scope),
+		}
+		return &auth.ActivateResponse{PachToken: s.newToken(req.Subject)}, nil
+	}
+	if s.auth == nil {
+		return nil, auth.ErrNotActivated
+	}
+	subject, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch req := req.(type) {
+	case *auth.DeactivateRequest:
+		if !s.auth.admins[subject] {
+			return nil, &auth.ErrNotAuthorized{Subject: subject, AdminOp: "DeactivateAuth"}
+		}
+		s.auth = nil
+		return &auth.DeactivateResponse{}, nil
+	case *auth.WhoAmIRequest:
+		return &auth.WhoAmIResponse{Username: subject, IsAdmin: s.auth.admins[subject]}, nil
+	case *auth.AuthorizeRequest:
+		return &auth.AuthorizeResponse{Authorized: s.scope(subject, req.Repo) >= req.Scope}, nil
+	case *auth.GetScopeRequest:
+		if req.Username == "" {
+			req.Username = subject
+		}
+		resp := &auth.GetScopeResponse{}
+		for _, repo := range req.Repos {
+			resp.Scopes = append(resp.Scopes, s.scope(req.Username, repo))
+		}
+		return resp, nil
+	case *auth.SetScopeRequest:
+		if s.scope(subject, req.Repo) < auth.Scope_OWNER {
+			return nil, &auth.ErrNotAuthorized{Subject: subject, Repo: req.Repo, Required: auth.Scope_OWNER}
+		}
+		if s.auth.acls[req.Repo] == nil {
+			s.auth.acls[req.Repo] = make(map[string]auth.Scope)
+		}
+		if req.Scope == auth.Scope_NONE {
+			delete(s.auth.acls[req.Repo], req.Username)
+		} else {
+			s.auth.acls[req.Repo][req.Username] = req.Scope
+		}
+		return &auth.SetScopeResponse{}, nil
+	case *auth.GetAuthTokenRequest:
+		if req.Subject == "" {
+			req.Subject = subject
+		}
+		if req.Subject != subject && !s.auth.admins[subject] {
+			return nil, &auth.ErrNotAuthorized{Subject: subject, AdminOp: "GetAuthToken on behalf of another user"}
+		}
+		return &auth.GetAuthTokenResponse{Subject: req.Subject, Token: s.newToken(req.Subject)}, nil
+	}
+	return nil, fmt.Errorf("unexpected auth request %T", req)
+}
+
+// newToken returns a new token for 'subject'. It must be called with s.mu
+// held, once auth is active.
+func (s *state) newToken(subject string) string {
+	token := uuid.NewWithoutDashes()
+	s.auth.tokens[token] = subject
+	return token
+}
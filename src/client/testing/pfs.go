@@ -0,0 +1,1774 @@
+package testing
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	globlib "github.com/gobwas/glob"
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/protobuf/proto"
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/client/pkg/manifest"
+	"github.com/pachyderm/pachyderm/src/client/pkg/schema"
+	"github.com/pachyderm/pachyderm/src/server/pkg/uuid"
+	"golang.org/x/net/context"
+)
+
+// getFileChunkSize is the size of the messages that GetFile sends
+const getFileChunkSize = 1024 * 1024
+
+type repo struct {
+	info     *pfs.RepoInfo
+	seq      int
+	commits  map[string]*commit
+	branches map[string]*pfs.BranchInfo
+}
+
+type commit struct {
+	info *pfs.CommitInfo
+	seq  int
+	// files maps the (cleaned) path of each file in the commit to its
+	// content. Directories are implied by the files in them. A commit's files
+	// are copied from its parent when it's started, and content is never
+	// modified in place, so commits may share content.
+	files map[string][]byte
+	// metadata maps the path of each file that has user metadata to it. Like
+	// content, metadata is copied from the parent and never modified in place.
+	metadata map[string]map[string]string
+}
+
+// mergeMetadata returns a new map holding 'base' updated with 'metadata'
+func mergeMetadata(base, metadata map[string]string) map[string]string {
+	result := make(map[string]string, len(base)+len(metadata))
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range metadata {
+		result[k] = v
+	}
+	return result
+}
+
+// hasMetadata returns true if 'info' has every key/value pair in 'metadata'
+func hasMetadata(info *pfs.CommitInfo, metadata map[string]string) bool {
+	for k, v := range metadata {
+		if value, ok := info.Metadata[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// cleanPath canonicalizes 'p' as an absolute path ("/" for the root)
+func cleanPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// dirPrefix returns the prefix shared by the paths of the files under 'dir'
+func dirPrefix(dir string) string {
+	if dir == "/" {
+		return "/"
+	}
+	return dir + "/"
+}
+
+func (s *state) newRepo(name, description string) *repo {
+	s.seq++
+	r := &repo{
+		info: &pfs.RepoInfo{
+			Repo:        client.NewRepo(name),
+			Created:     types.TimestampNow(),
+			Description: description,
+		},
+		seq:      s.seq,
+		commits:  make(map[string]*commit),
+		branches: make(map[string]*pfs.BranchInfo),
+	}
+	s.repos[name] = r
+	return r
+}
+
+func (s *state) getRepo(name string) (*repo, error) {
+	r, ok := s.repos[name]
+	if !ok {
+		return nil, fmt.Errorf("repo %v not found", name)
+	}
+	return r, nil
+}
+
+// repoInfo returns the current RepoInfo for 'r'. A repo's size is the size of
+// the head of its master branch.
+func (r *repo) repoInfo() *pfs.RepoInfo {
+	info := proto.Clone(r.info).(*pfs.RepoInfo)
+	for _, name := range r.branchNames() {
+		info.Branches = append(info.Branches, r.branches[name].Branch)
+	}
+	if master, ok := r.branches["master"]; ok && master.Head != nil {
+		info.SizeBytes = r.commits[master.Head.ID].info.SizeBytes
+		info.FileCount = uint64(len(r.commits[master.Head.ID].files))
+	}
+	return info
+}
+
+func (r *repo) branchNames() []string {
+	var names []string
+	for name := range r.branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolve returns the commit with ID 'id', or the head of the branch 'id'
+func (r *repo) resolve(id string) (*commit, error) {
+	if b, ok := r.branches[id]; ok {
+		if b.Head == nil {
+			return nil, fmt.Errorf("the branch \"%s\" has no head (create one with start-commit)", id)
+		}
+		id = b.Head.ID
+	}
+	c, ok := r.commits[id]
+	if !ok {
+		return nil, fmt.Errorf("commit %v not found in repo %v", id, r.info.Repo.Name)
+	}
+	return c, nil
+}
+
+// checkDirectWrite returns an error if 'id' is a branch whose protection
+// prevents users from writing to it, re-pointing it or deleting it. As in
+// pachd, if 'id' is a commit ID, the branches that it's the head of are
+// checked.
+func (r *repo) checkDirectWrite(id string) error {
+	if b, ok := r.branches[id]; ok {
+		if b.Protection.GetNoDirectWrites() {
+			return fmt.Errorf("branch %v in repo %v is protected: only pipelines may write to it", id, r.info.Repo.Name)
+		}
+		return nil
+	}
+	for _, name := range r.branchNames() {
+		if b := r.branches[name]; b.Head != nil && b.Head.ID == id {
+			if err := r.checkDirectWrite(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkDelete returns an error if the commit 'id' is the head, or an ancestor
+// of the head, of a branch whose protection prevents deleting its commits
+func (r *repo) checkDelete(id string) error {
+	for _, name := range r.branchNames() {
+		b := r.branches[name]
+		if !b.Protection.GetNoDeleteCommit() {
+			continue
+		}
+		for ancestor := b.Head; ancestor != nil; ancestor = r.commits[ancestor.ID].info.ParentCommit {
+			if ancestor.ID == id {
+				return fmt.Errorf("branch %v in repo %v is protected: commits in its history can't be deleted", name, r.info.Repo.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *state) resolveCommit(commit *pfs.Commit) (*repo, *commit, error) {
+	r, err := s.getRepo(commit.GetRepo().GetName())
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := r.resolve(commit.GetID())
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, c, nil
+}
+
+// startCommit starts a new commit in 'r' whose parent is 'parentID' (or the
+// head of 'branch', if 'parentID' is unset), and makes it the head of
+// 'branch'
+func (s *state) startCommit(r *repo, parentID string, branch string, description string) (*commit, error) {
+	if b, ok := r.branches[branch]; ok {
+		if len(b.Provenance) > 0 {
+			return nil, fmt.Errorf("cannot start a commit on an output branch")
+		}
+		if parentID == "" && b.Head != nil {
+			parentID = b.Head.ID
+		}
+	}
+	var parent *commit
+	if parentID != "" {
+		var err error
+		parent, err = r.resolve(parentID)
+		if err != nil {
+			return nil, fmt.Errorf("parent commit not found: %v", err)
+		}
+		if parent.info.Finished == nil {
+			return nil, fmt.Errorf("parent commit %s has not been finished", parentID)
+		}
+	}
+	s.seq++
+	c := &commit{
+		info: &pfs.CommitInfo{
+			Commit:      client.NewCommit(r.info.Repo.Name, uuid.NewWithoutDashes()),
+			Description: description,
+			Started:     types.TimestampNow(),
+		},
+		seq:      s.seq,
+		files:    make(map[string][]byte),
+		metadata: make(map[string]map[string]string),
+	}
+	if parent != nil {
+		c.info.ParentCommit = parent.info.Commit
+		parent.info.ChildCommits = append(parent.info.ChildCommits, c.info.Commit)
+		for p, data := range parent.files {
+			c.files[p] = data
+		}
+		for p, metadata := range parent.metadata {
+			c.metadata[p] = metadata
+		}
+	}
+	r.commits[c.info.Commit.ID] = c
+	if branch != "" {
+		r.setHead(branch, c.info.Commit)
+	}
+	s.notify()
+	return c, nil
+}
+
+func (r *repo) setHead(branch string, head *pfs.Commit) {
+	b, ok := r.branches[branch]
+	if !ok {
+		b = &pfs.BranchInfo{
+			Branch: client.NewBranch(r.info.Repo.Name, branch),
+			Name:   branch,
+		}
+		r.branches[branch] = b
+	}
+	b.Head = head
+}
+
+func (s *state) finishCommit(c *commit, description string) error {
+	if c.info.Finished != nil {
+		return fmt.Errorf("commit %v in repo %v has already finished", c.info.Commit.ID, c.info.Commit.Repo.Name)
+	}
+	if description != "" {
+		c.info.Description = description
+	}
+	var size uint64
+	for _, data := range c.files {
+		size += uint64(len(data))
+	}
+	if r, ok := s.repos[c.info.Commit.Repo.Name]; ok && r.info.Quota != nil {
+		quota := r.info.Quota
+		if quota.MaxBytes > 0 && size > quota.MaxBytes {
+			return fmt.Errorf("repo %v would exceed its quota: %d bytes is over the limit of %d bytes", r.info.Repo.Name, size, quota.MaxBytes)
+		}
+		if quota.MaxFiles > 0 && uint64(len(c.files)) > quota.MaxFiles {
+			return fmt.Errorf("repo %v would exceed its quota: %d files is over the limit of %d files", r.info.Repo.Name, len(c.files), quota.MaxFiles)
+		}
+	}
+	if r, ok := s.repos[c.info.Commit.Repo.Name]; ok && r.info.Schema != nil {
+		violations, err := r.checkSchema(c)
+		if err != nil {
+			return err
+		}
+		c.info.SchemaViolations = violations
+	}
+	c.info.Finished = types.TimestampNow()
+	c.info.SizeBytes = size
+	s.notify()
+	return nil
+}
+
+// checkSchema validates the files in 'c' that are new or changed since its
+// parent against the schema of 'r', as pachd does
+func (r *repo) checkSchema(c *commit) ([]*pfs.SchemaViolation, error) {
+	validator, err := schema.NewValidator(r.info.Schema)
+	if err != nil {
+		return nil, err
+	}
+	var parentFiles map[string][]byte
+	if c.info.ParentCommit != nil {
+		parentFiles = r.commits[c.info.ParentCommit.ID].files
+	}
+	var paths []string
+	for p := range c.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var violations []*pfs.SchemaViolation
+	for _, p := range paths {
+		data := c.files[p]
+		if old, ok := parentFiles[p]; (ok && bytes.Equal(old, data)) || !validator.Matches(p) {
+			continue
+		}
+		if err := validator.Validate(bytes.NewReader(data)); err != nil {
+			violations = append(violations, &pfs.SchemaViolation{Path: p, Error: err.Error()})
+		}
+	}
+	if len(violations) > 0 && r.info.Schema.Action == pfs.SchemaAction_REJECT {
+		return nil, schema.ErrViolation{Commit: c.info.Commit, Violations: violations}
+	}
+	return violations, nil
+}
+
+// openCommit returns the commit that writes to 'commit' go to. If 'commit'
+// names a branch whose head is finished (or which doesn't exist yet), a new
+// commit is started on the branch, which the caller must finish once it's
+// done writing (as pachd does), and 'started' is true.
+func (s *state) openCommit(commit *pfs.Commit) (_ *commit, started bool, _ error) {
+	r, err := s.getRepo(commit.GetRepo().GetName())
+	if err != nil {
+		return nil, false, err
+	}
+	id := commit.GetID()
+	if err := r.checkDirectWrite(id); err != nil {
+		return nil, false, err
+	}
+	if c, ok := r.commits[id]; ok {
+		if c.info.Finished != nil {
+			return nil, false, fmt.Errorf("commit %v in repo %v has already finished", id, r.info.Repo.Name)
+		}
+		return c, false, nil
+	}
+	if b, ok := r.branches[id]; ok && b.Head != nil {
+		if c := r.commits[b.Head.ID]; c.info.Finished == nil {
+			return c, false, nil
+		}
+	}
+	c, err := s.startCommit(r, "", id, "")
+	if err != nil {
+		return nil, false, err
+	}
+	return c, true, nil
+}
+
+// checkWritable returns an error if a file can't be written at 'p' in 'c'
+func checkWritable(c *commit, p string) error {
+	if p == "/" || c.isDir(p) {
+		return fmt.Errorf("cannot write to %q, as it is a directory", p)
+	}
+	for dir := path.Dir(p); dir != "/"; dir = path.Dir(dir) {
+		if _, ok := c.files[dir]; ok {
+			return fmt.Errorf("parent of %q is not a directory", p)
+		}
+	}
+	return nil
+}
+
+func (c *commit) isDir(p string) bool {
+	if p == "/" {
+		return true
+	}
+	prefix := dirPrefix(p)
+	for f := range c.files {
+		if strings.HasPrefix(f, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// paths returns the paths of all files and directories under 'dir' (including
+// 'dir' itself), in sorted order
+func (c *commit) paths(dir string) []string {
+	prefix := dirPrefix(dir)
+	seen := map[string]bool{dir: true}
+	for f := range c.files {
+		if !strings.HasPrefix(f, prefix) {
+			continue
+		}
+		for p := f; p != dir && !seen[p]; p = path.Dir(p) {
+			seen[p] = true
+		}
+	}
+	var paths []string
+	for p := range seen {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// fileInfo returns info about the file or directory at 'p' in 'c'
+func (c *commit) fileInfo(p string) (*pfs.FileInfo, error) {
+	info := &pfs.FileInfo{
+		File:      client.NewFile(c.info.Commit.Repo.Name, c.info.Commit.ID, p),
+		Committed: c.info.Finished,
+	}
+	if data, ok := c.files[p]; ok {
+		info.FileType = pfs.FileType_FILE
+		info.SizeBytes = uint64(len(data))
+		// hash files the way pachd hashes files written by one PutFile
+		fileHash, err := client.FileHash(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		info.Hash = fileHash
+		// unlike pachd, which only records the checksums of files written by
+		// one PutFile, every file's checksums are given
+		sha256Sum, md5Sum := sha256.Sum256(data), md5.Sum(data)
+		info.ContentSha256, info.ContentMd5 = sha256Sum[:], md5Sum[:]
+		info.Metadata = c.metadata[p]
+		return info, nil
+	}
+	hash := pfs.NewHash()
+	if !c.isDir(p) {
+		return nil, fmt.Errorf("file %v not found in repo %v at commit %v", p, c.info.Commit.Repo.Name, c.info.Commit.ID)
+	}
+	info.FileType = pfs.FileType_DIR
+	prefix := dirPrefix(p)
+	for _, child := range c.paths(p) {
+		if path.Dir(child) == p && child != p {
+			info.Children = append(info.Children, strings.TrimPrefix(child, prefix))
+		}
+		if data, ok := c.files[child]; ok {
+			info.SizeBytes += uint64(len(data))
+			hash.Write([]byte(child))
+			hash.Write(data)
+		}
+	}
+	info.Hash = hash.Sum(nil)
+	return info, nil
+}
+
+// pfsServer is a fake of pachd's PFS API
+type pfsServer struct {
+	*state
+}
+
+func (a *pfsServer) CreateRepo(ctx context.Context, request *pfs.CreateRepoRequest) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	name := request.GetRepo().GetName()
+	if err := validateName("repo", name); err != nil {
+		return nil, err
+	}
+	if r, ok := a.repos[name]; ok {
+		if !request.Update {
+			return nil, fmt.Errorf("repo %v already exists", name)
+		}
+		r.info.Description = request.Description
+		return &types.Empty{}, nil
+	}
+	a.newRepo(name, request.Description)
+	return &types.Empty{}, nil
+}
+
+func (a *pfsServer) InspectRepo(ctx context.Context, request *pfs.InspectRepoRequest) (*pfs.RepoInfo, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, err := a.getRepo(request.GetRepo().GetName())
+	if err != nil {
+		return nil, err
+	}
+	return r.repoInfo(), nil
+}
+
+func (a *pfsServer) ListRepo(ctx context.Context, request *pfs.ListRepoRequest) (*pfs.ListRepoResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var repos []*repo
+	for _, r := range a.repos {
+		repos = append(repos, r)
+	}
+	// newest first, as pachd returns them
+	sort.Slice(repos, func(i, j int) bool { return repos[i].seq > repos[j].seq })
+	start, end := pageBounds(len(repos), request.Page, request.PageSize)
+	repos = repos[start:end]
+	response := &pfs.ListRepoResponse{}
+	for _, r := range repos {
+		response.RepoInfo = append(response.RepoInfo, r.repoInfo())
+	}
+	return response, nil
+}
+
+func (a *pfsServer) DeleteRepo(ctx context.Context, request *pfs.DeleteRepoRequest) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if request.All {
+		a.repos = make(map[string]*repo)
+	} else {
+		name := request.GetRepo().GetName()
+		if _, err := a.getRepo(name); err != nil {
+			return nil, err
+		}
+		delete(a.repos, name)
+	}
+	a.notify()
+	return &types.Empty{}, nil
+}
+
+func (a *pfsServer) StartCommit(ctx context.Context, request *pfs.StartCommitRequest) (*pfs.Commit, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, err := a.getRepo(request.GetParent().GetRepo().GetName())
+	if err != nil {
+		return nil, err
+	}
+	if request.Branch != "" {
+		if err := r.checkDirectWrite(request.Branch); err != nil {
+			return nil, err
+		}
+	}
+	c, err := a.startCommit(r, request.Parent.ID, request.Branch, request.Description)
+	if err != nil {
+		return nil, err
+	}
+	if len(request.Metadata) > 0 {
+		c.info.Metadata = mergeMetadata(nil, request.Metadata)
+	}
+	return c.info.Commit, nil
+}
+
+func (a *pfsServer) FinishCommit(ctx context.Context, request *pfs.FinishCommitRequest) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, c, err := a.resolveCommit(request.Commit)
+	if err != nil {
+		return nil, err
+	}
+	if request.Empty && c.info.Finished == nil {
+		c.files = make(map[string][]byte)
+		c.metadata = make(map[string]map[string]string)
+	}
+	if len(request.Metadata) > 0 && c.info.Finished == nil {
+		c.info.Metadata = mergeMetadata(c.info.Metadata, request.Metadata)
+	}
+	if err := a.finishCommit(c, request.Description); err != nil {
+		return nil, err
+	}
+	return &types.Empty{}, nil
+}
+
+func (a *pfsServer) InspectCommit(ctx context.Context, request *pfs.InspectCommitRequest) (*pfs.CommitInfo, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, c, err := a.resolveCommit(request.Commit)
+	if err != nil {
+		return nil, err
+	}
+	id := c.info.Commit.ID
+	for request.BlockState == pfs.CommitState_FINISHED && c.info.Finished == nil {
+		if err := a.wait(ctx); err != nil {
+			return nil, err
+		}
+		if c = r.commits[id]; c == nil || a.repos[r.info.Repo.Name] != r {
+			return nil, fmt.Errorf("commit %v/%v was deleted", r.info.Repo.Name, id)
+		}
+	}
+	return proto.Clone(c.info).(*pfs.CommitInfo), nil
+}
+
+// listCommit returns the commits in 'request', newest first
+func (a *pfsServer) listCommit(request *pfs.ListCommitRequest) ([]*pfs.CommitInfo, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, err := a.getRepo(request.GetRepo().GetName())
+	if err != nil {
+		return nil, err
+	}
+	number := request.Number
+	if number == 0 {
+		number = ^uint64(0)
+	}
+	var commits []*commit
+	if request.To == nil {
+		if request.From != nil {
+			return nil, fmt.Errorf("cannot use `from` commit without `to` commit")
+		}
+		for _, c := range r.commits {
+			if hasMetadata(c.info, request.Metadata) {
+				commits = append(commits, c)
+			}
+		}
+		sort.Slice(commits, func(i, j int) bool { return commits[i].seq > commits[j].seq })
+		if uint64(len(commits)) > number {
+			commits = commits[:number]
+		}
+	} else {
+		c, err := r.resolve(request.To.ID)
+		if err != nil {
+			return nil, err
+		}
+		var from string
+		if request.From != nil {
+			fromCommit, err := r.resolve(request.From.ID)
+			if err != nil {
+				return nil, err
+			}
+			from = fromCommit.info.Commit.ID
+		}
+		for c != nil && c.info.Commit.ID != from && uint64(len(commits)) < number {
+			if hasMetadata(c.info, request.Metadata) {
+				commits = append(commits, c)
+			}
+			parent := c.info.ParentCommit
+			c = nil
+			if parent != nil {
+				c = r.commits[parent.ID]
+			}
+		}
+	}
+	start, end := pageBounds(len(commits), request.Page, request.PageSize)
+	commits = commits[start:end]
+	var infos []*pfs.CommitInfo
+	for _, c := range commits {
+		infos = append(infos, proto.Clone(c.info).(*pfs.CommitInfo))
+	}
+	return infos, nil
+}
+
+func (a *pfsServer) ListCommit(ctx context.Context, request *pfs.ListCommitRequest) (*pfs.CommitInfos, error) {
+	infos, err := a.listCommit(request)
+	if err != nil {
+		return nil, err
+	}
+	return &pfs.CommitInfos{CommitInfo: infos}, nil
+}
+
+func (a *pfsServer) ListCommitStream(request *pfs.ListCommitRequest, server pfs.API_ListCommitStreamServer) error {
+	infos, err := a.listCommit(request)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if err := server.Send(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteCommit deletes a commit. Its children become children of its parent,
+// and branches whose head it was point to its parent.
+func (a *pfsServer) DeleteCommit(ctx context.Context, request *pfs.DeleteCommitRequest) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, c, err := a.resolveCommit(request.Commit)
+	if err != nil {
+		return nil, err
+	}
+	id := c.info.Commit.ID
+	if err := r.checkDelete(id); err != nil {
+		return nil, err
+	}
+	a.deleteCommit(r, c)
+	return &types.Empty{}, nil
+}
+
+// deleteCommit removes 'c' from 'r', making its parent the parent of its
+// children and the head of the branches that it's the head of
+func (s *state) deleteCommit(r *repo, c *commit) {
+	id := c.info.Commit.ID
+	var parent *commit
+	if c.info.ParentCommit != nil {
+		parent = r.commits[c.info.ParentCommit.ID]
+		var children []*pfs.Commit
+		for _, child := range parent.info.ChildCommits {
+			if child.ID != id {
+				children = append(children, child)
+			}
+		}
+		parent.info.ChildCommits = append(children, c.info.ChildCommits...)
+	}
+	for _, child := range c.info.ChildCommits {
+		r.commits[child.ID].info.ParentCommit = c.info.ParentCommit
+	}
+	for _, b := range r.branches {
+		if b.Head != nil && b.Head.ID == id {
+			b.Head = c.info.ParentCommit
+		}
+	}
+	delete(r.commits, id)
+	s.notify()
+}
+
+// SquashCommit deletes the commits from 'request.From' through the parent of
+// 'request.To', and makes the parent of 'request.From' the parent of
+// 'request.To'
+func (a *pfsServer) SquashCommit(ctx context.Context, request *pfs.SquashCommitRequest) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if request.From.GetRepo().GetName() != request.To.GetRepo().GetName() {
+		return nil, fmt.Errorf("cannot squash commits in different repos")
+	}
+	r, to, err := a.resolveCommit(request.To)
+	if err != nil {
+		return nil, err
+	}
+	from, err := r.resolve(request.From.GetID())
+	if err != nil {
+		return nil, err
+	}
+	if to.info.Finished == nil {
+		return nil, fmt.Errorf("cannot squash into commit %s because it is not finished", to.info.Commit.ID)
+	}
+	heads := make(map[string]bool)
+	for _, b := range r.branches {
+		if b.Head != nil {
+			heads[b.Head.ID] = true
+		}
+	}
+	var squashed []*commit
+	for c := to; c != from; {
+		if c.info.ParentCommit == nil {
+			return nil, fmt.Errorf("commit %s is not an ancestor of commit %s", from.info.Commit.ID, to.info.Commit.ID)
+		}
+		c = r.commits[c.info.ParentCommit.ID]
+		if c.info.Finished == nil || len(c.info.ChildCommits) > 1 || heads[c.info.Commit.ID] {
+			return nil, fmt.Errorf("cannot squash commit %s", c.info.Commit.ID)
+		}
+		squashed = append(squashed, c)
+	}
+	if len(squashed) == 0 {
+		return &types.Empty{}, nil
+	}
+	if err := r.checkDelete(to.info.Commit.ID); err != nil {
+		return nil, err
+	}
+	for _, c := range squashed {
+		delete(r.commits, c.info.Commit.ID)
+	}
+	to.info.ParentCommit = from.info.ParentCommit
+	if from.info.ParentCommit != nil {
+		parent := r.commits[from.info.ParentCommit.ID]
+		for i, child := range parent.info.ChildCommits {
+			if child.ID == from.info.Commit.ID {
+				parent.info.ChildCommits[i] = to.info.Commit
+			}
+		}
+	}
+	a.notify()
+	return &types.Empty{}, nil
+}
+
+// FlushCommit returns immediately, as the fake doesn't run pipelines, so no
+// commits are ever provenant on another
+func (a *pfsServer) FlushCommit(request *pfs.FlushCommitRequest, server pfs.API_FlushCommitServer) error {
+	return nil
+}
+
+func (a *pfsServer) SubscribeCommit(request *pfs.SubscribeCommitRequest, server pfs.API_SubscribeCommitServer) error {
+	ctx := server.Context()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, err := a.getRepo(request.GetRepo().GetName())
+	if err != nil {
+		return err
+	}
+	// as with ListCommit, 'from' and its ancestors are excluded
+	sent := make(map[string]bool)
+	if request.From != nil {
+		c, err := r.resolve(request.From.ID)
+		if err != nil {
+			return err
+		}
+		for ; c != nil; c = r.commits[c.info.GetParentCommit().GetID()] {
+			sent[c.info.Commit.ID] = true
+		}
+	}
+	for {
+		if a.repos[r.info.Repo.Name] != r {
+			return fmt.Errorf("repo %v not found", r.info.Repo.Name)
+		}
+		var pending []*commit
+		if b, ok := r.branches[request.Branch]; ok && b.Head != nil {
+			for c := r.commits[b.Head.ID]; c != nil && !sent[c.info.Commit.ID]; c = r.commits[c.info.GetParentCommit().GetID()] {
+				pending = append(pending, c)
+			}
+		}
+		// send the oldest commits first, stopping at the first one that isn't
+		// in the requested state yet
+		for i := len(pending) - 1; i >= 0; i-- {
+			c := pending[i]
+			if request.State == pfs.CommitState_FINISHED && c.info.Finished == nil {
+				break
+			}
+			sent[c.info.Commit.ID] = true
+			info := proto.Clone(c.info).(*pfs.CommitInfo)
+			a.mu.Unlock()
+			err := server.Send(info)
+			a.mu.Lock()
+			if err != nil {
+				return err
+			}
+		}
+		if err := a.wait(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// SubscribeCommits is like SubscribeCommit, but sends the commits in several
+// repos, oldest first across all of them
+func (a *pfsServer) SubscribeCommits(request *pfs.SubscribeCommitsRequest, server pfs.API_SubscribeCommitsServer) error {
+	ctx := server.Context()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if request.Branch == "" {
+		return fmt.Errorf("branch must be set")
+	}
+	if len(request.Repos) == 0 && request.Pattern == "" {
+		return fmt.Errorf("either repos or a pattern must be set")
+	}
+	var g globlib.Glob
+	if request.Pattern != "" {
+		var err error
+		if g, err = globlib.Compile(request.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", request.Pattern, err)
+		}
+	}
+	explicit := make(map[string]bool)
+	for _, repo := range request.Repos {
+		if _, err := a.getRepo(repo.GetName()); err != nil {
+			return err
+		}
+		explicit[repo.GetName()] = true
+	}
+	type pendingCommit struct {
+		r *repo
+		c *commit
+	}
+	// sent holds the IDs of each repo's commits that have been sent
+	sent := make(map[*repo]map[string]bool)
+	for first := true; ; first = false {
+		for name := range explicit {
+			if _, err := a.getRepo(name); err != nil {
+				return err
+			}
+		}
+		var pending []pendingCommit
+		for name, r := range a.repos {
+			if !explicit[name] && (g == nil || !g.Match(name)) {
+				continue
+			}
+			if sent[r] == nil {
+				sent[r] = make(map[string]bool)
+			}
+			b, ok := r.branches[request.Branch]
+			if !ok || b.Head == nil {
+				continue
+			}
+			for c := r.commits[b.Head.ID]; c != nil && !sent[r][c.info.Commit.ID]; c = r.commits[c.info.GetParentCommit().GetID()] {
+				if first && request.New {
+					sent[r][c.info.Commit.ID] = true
+				} else {
+					pending = append(pending, pendingCommit{r, c})
+				}
+			}
+		}
+		sort.Slice(pending, func(i, j int) bool { return pending[i].c.seq < pending[j].c.seq })
+		// as in SubscribeCommit, each repo's commits are sent in order, so
+		// a repo's commits stop at the first one that isn't in the requested
+		// state yet
+		blocked := make(map[*repo]bool)
+		for _, p := range pending {
+			if blocked[p.r] || a.repos[p.r.info.Repo.Name] != p.r {
+				continue
+			}
+			if request.State == pfs.CommitState_FINISHED && p.c.info.Finished == nil {
+				blocked[p.r] = true
+				continue
+			}
+			sent[p.r][p.c.info.Commit.ID] = true
+			info := proto.Clone(p.c.info).(*pfs.CommitInfo)
+			a.mu.Unlock()
+			err := server.Send(info)
+			a.mu.Lock()
+			if err != nil {
+				return err
+			}
+		}
+		if err := a.wait(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// ProvenanceQuery returns only the queried commit, as the fake's commits
+// never have provenance
+func (a *pfsServer) ProvenanceQuery(ctx context.Context, request *pfs.ProvenanceQueryRequest) (*pfs.ProvenanceQueryResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, c, err := a.resolveCommit(request.Commit)
+	if err != nil {
+		return nil, err
+	}
+	return &pfs.ProvenanceQueryResponse{
+		Commits: []*pfs.CommitInfo{proto.Clone(c.info).(*pfs.CommitInfo)},
+	}, nil
+}
+
+func (a *pfsServer) BuildCommit(ctx context.Context, request *pfs.BuildCommitRequest) (*pfs.Commit, error) {
+	return nil, unimplemented("BuildCommit")
+}
+
+func (a *pfsServer) CreateBranch(ctx context.Context, request *pfs.CreateBranchRequest) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if request.Trigger != nil {
+		return nil, unimplemented("CreateBranch with a trigger")
+	}
+	branch := request.Branch
+	if branch == nil {
+		branch = client.NewBranch(request.GetHead().GetRepo().GetName(), request.SBranch)
+	}
+	r, err := a.getRepo(branch.GetRepo().GetName())
+	if err != nil {
+		return nil, err
+	}
+	if err := validateName("branch", branch.Name); err != nil {
+		return nil, err
+	}
+	var head *pfs.Commit
+	if request.Head != nil {
+		c, err := r.resolve(request.Head.ID)
+		if err != nil {
+			return nil, err
+		}
+		head = c.info.Commit
+		if request.Head.ID != branch.Name {
+			if err := r.checkDirectWrite(branch.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, p := range request.Provenance {
+		if _, ok := a.repos[p.GetRepo().GetName()]; !ok {
+			return nil, fmt.Errorf("repo %v not found", p.GetRepo().GetName())
+		}
+	}
+	r.setHead(branch.Name, head)
+	r.branches[branch.Name].Provenance = request.Provenance
+	a.notify()
+	return &types.Empty{}, nil
+}
+
+func (a *pfsServer) InspectBranch(ctx context.Context, request *pfs.InspectBranchRequest) (*pfs.BranchInfo, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, err := a.getRepo(request.GetBranch().GetRepo().GetName())
+	if err != nil {
+		return nil, err
+	}
+	b, ok := r.branches[request.Branch.Name]
+	if !ok {
+		return nil, fmt.Errorf("branch %s not found in repo %s", request.Branch.Name, r.info.Repo.Name)
+	}
+	return proto.Clone(b).(*pfs.BranchInfo), nil
+}
+
+func (a *pfsServer) ListBranch(ctx context.Context, request *pfs.ListBranchRequest) (*pfs.BranchInfos, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, err := a.getRepo(request.GetRepo().GetName())
+	if err != nil {
+		return nil, err
+	}
+	response := &pfs.BranchInfos{}
+	for _, name := range r.branchNames() {
+		response.BranchInfo = append(response.BranchInfo, proto.Clone(r.branches[name]).(*pfs.BranchInfo))
+	}
+	return response, nil
+}
+
+func (a *pfsServer) DeleteBranch(ctx context.Context, request *pfs.DeleteBranchRequest) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, err := a.getRepo(request.GetBranch().GetRepo().GetName())
+	if err != nil {
+		return nil, err
+	}
+	if request.DeleteCommits {
+		return nil, unimplemented("DeleteBranch with delete_commits")
+	}
+	if _, ok := r.branches[request.Branch.Name]; !ok {
+		return nil, fmt.Errorf("branch %s not found in repo %s", request.Branch.Name, r.info.Repo.Name)
+	}
+	if err := r.checkDirectWrite(request.Branch.Name); err != nil {
+		return nil, err
+	}
+	delete(r.branches, request.Branch.Name)
+	if request.Cascade {
+		a.deleteSubvenance(request.Branch)
+	}
+	a.notify()
+	return &types.Empty{}, nil
+}
+
+// deleteSubvenance deletes the branches that are provenant on 'branch'
+func (s *state) deleteSubvenance(branch *pfs.Branch) {
+	for _, r := range s.repos {
+		for name, b := range r.branches {
+			for _, p := range b.Provenance {
+				if p.Repo.Name == branch.Repo.Name && p.Name == branch.Name {
+					delete(r.branches, name)
+					s.deleteSubvenance(b.Branch)
+					break
+				}
+			}
+		}
+	}
+}
+
+// SetRepoQuota sets a repo's quota. Unlike pachd, the fake checks quotas
+// against each commit's size (as it reports repo sizes), when the commit is
+// finished.
+func (a *pfsServer) SetRepoQuota(ctx context.Context, request *pfs.SetRepoQuotaRequest) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, err := a.getRepo(request.GetRepo().GetName())
+	if err != nil {
+		return nil, err
+	}
+	if q := request.Quota; q != nil && q.MaxBytes == 0 && q.MaxFiles == 0 {
+		r.info.Quota = nil
+	} else {
+		r.info.Quota = request.Quota
+	}
+	return &types.Empty{}, nil
+}
+
+// SetRepoSchema sets a repo's schema. The fake doesn't check permissions, as
+// auth is never active.
+func (a *pfsServer) SetRepoSchema(ctx context.Context, request *pfs.SetRepoSchemaRequest) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, err := a.getRepo(request.GetRepo().GetName())
+	if err != nil {
+		return nil, err
+	}
+	if request.Schema != nil {
+		if _, err := schema.NewValidator(request.Schema); err != nil {
+			return nil, err
+		}
+	}
+	r.info.Schema = request.Schema
+	return &types.Empty{}, nil
+}
+
+// InspectStorage isn't supported, as the fake has no object storage
+func (a *pfsServer) InspectStorage(ctx context.Context, request *pfs.InspectStorageRequest) (*pfs.StorageInfo, error) {
+	return nil, unimplemented("InspectStorage")
+}
+
+// SetBranchProtection sets a branch's protection. As auth is never activated,
+// RequiredScope has no effect.
+func (a *pfsServer) SetBranchProtection(ctx context.Context, request *pfs.SetBranchProtectionRequest) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, err := a.getRepo(request.GetBranch().GetRepo().GetName())
+	if err != nil {
+		return nil, err
+	}
+	b, ok := r.branches[request.Branch.Name]
+	if !ok {
+		return nil, fmt.Errorf("branch %s not found in repo %s", request.Branch.Name, r.info.Repo.Name)
+	}
+	b.Protection = request.Protection
+	return &types.Empty{}, nil
+}
+
+// PutFile supports writing data sent in the request, or fetched from an
+// http:// or https:// URL, and expanding tar archives. Splitting data with a
+// delimiter and putting files from other URLs aren't supported.
+func (a *pfsServer) PutFile(server pfs.API_PutFileServer) (retErr error) {
+	var file *pfs.File
+	// started holds the commits started to hold writes to branches, which are
+	// finished once all data has been written
+	var started []*commit
+	finish := func() error {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		var retErr error
+		for _, c := range started {
+			if err := a.finishCommit(c, ""); err != nil {
+				// pachd doesn't create the commit if a PutFile to a branch
+				// fails (e.g. if it would exceed the repo's quota)
+				if r, ok := a.repos[c.info.Commit.Repo.Name]; ok {
+					a.deleteCommit(r, c)
+				}
+				if retErr == nil {
+					retErr = err
+				}
+			}
+		}
+		started = nil
+		return retErr
+	}
+	defer func() {
+		if err := finish(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	// tarRequest is the request that started the tar archive being sent,
+	// whose data is collected in tarData and expanded once it's complete
+	var tarRequest *pfs.PutFileRequest
+	var tarData []byte
+	putTar := func() error {
+		if tarRequest == nil {
+			return nil
+		}
+		request := tarRequest
+		tarRequest = nil
+		return a.putTar(request, tarData, &started)
+	}
+	for {
+		request, err := server.Recv()
+		if err == io.EOF {
+			if err := putTar(); err != nil {
+				return err
+			}
+			if err := finish(); err != nil {
+				return err
+			}
+			return server.SendAndClose(&types.Empty{})
+		}
+		if err != nil {
+			return err
+		}
+		if request.Delimiter != pfs.Delimiter_NONE {
+			return unimplemented("PutFile with a delimiter")
+		}
+		if request.File != nil || request.Url != "" {
+			if err := putTar(); err != nil {
+				return err
+			}
+		}
+		if request.Tar && request.Url == "" {
+			if request.File != nil {
+				tarRequest, tarData, file = request, nil, nil
+			} else if tarRequest == nil {
+				return fmt.Errorf("the first PutFile request must include a file")
+			}
+			tarData = append(tarData, request.Value...)
+			continue
+		}
+		if request.Url != "" {
+			data, err := getURL(server.Context(), request)
+			if err != nil {
+				return err
+			}
+			if request.Tar {
+				if err := a.putTar(request, data, &started); err != nil {
+					return err
+				}
+				file = nil
+				continue
+			}
+			if err := a.putFile(request.File, request.OverwriteIndex != nil, data, request.Metadata, &started); err != nil {
+				return err
+			}
+			file = nil // data can't follow a URL
+			continue
+		}
+		if request.OverwriteIndex != nil && request.OverwriteIndex.Index != 0 {
+			return unimplemented("PutFile with a non-zero overwrite index")
+		}
+		if request.File != nil {
+			file = request.File
+		} else if file == nil {
+			return fmt.Errorf("the first PutFile request must include a file")
+		}
+		if err := a.putFile(file, request.File != nil && request.OverwriteIndex != nil, request.Value, request.Metadata, &started); err != nil {
+			return err
+		}
+	}
+}
+
+// putTar puts the regular files in the tar archive 'data' under the path of
+// 'request.File', like pachd, appending any commits it starts to 'started'
+func (a *pfsServer) putTar(request *pfs.PutFileRequest, data []byte, started *[]*commit) error {
+	if request.OverwriteIndex != nil && request.OverwriteIndex.Index != 0 {
+		return fmt.Errorf("files in tar archives can only be overwritten from the start (overwrite index 0)")
+	}
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading tar archive: %v", err)
+		}
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+		case tar.TypeDir:
+			continue
+		default:
+			return fmt.Errorf("can't put %q from tar archive: only regular files and directories are supported", header.Name)
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		file := client.NewFile(request.File.Commit.Repo.Name, request.File.Commit.ID,
+			path.Join(request.File.Path, path.Clean("/"+header.Name)))
+		metadata := mergeMetadata(map[string]string{
+			pfs.FileModeMetadataKey: fmt.Sprintf("%04o", header.Mode&07777),
+		}, request.Metadata)
+		if err := a.putFile(file, request.OverwriteIndex != nil, content, metadata, started); err != nil {
+			return err
+		}
+	}
+}
+
+// getURL fetches the http:// or https:// URL in 'request', like pachd
+func getURL(ctx context.Context, request *pfs.PutFileRequest) ([]byte, error) {
+	u, err := url.Parse(request.Url)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || request.Recursive || request.UrlCredentials != nil {
+		return nil, unimplemented("PutFile with a non-HTTP URL")
+	}
+	if request.File == nil {
+		return nil, fmt.Errorf("PutFile requests with a URL must include a file")
+	}
+	httpReq, err := http.NewRequest("GET", request.Url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range request.UrlHeaders {
+		httpReq.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("error fetching %s: %s", request.Url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// putFile appends 'data' to 'file' (after truncating it, if 'overwrite' is
+// true) and adds 'metadata' to its metadata, appending any commits it starts
+// to 'started'
+func (a *pfsServer) putFile(file *pfs.File, overwrite bool, data []byte, metadata map[string]string, started *[]*commit) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c, isNew, err := a.openCommit(file.Commit)
+	if err != nil {
+		return err
+	}
+	if isNew {
+		*started = append(*started, c)
+	}
+	p := cleanPath(file.Path)
+	if err := checkWritable(c, p); err != nil {
+		return err
+	}
+	old := c.files[p]
+	if overwrite {
+		old = nil
+		delete(c.metadata, p)
+	}
+	content := make([]byte, 0, len(old)+len(data))
+	c.files[p] = append(append(content, old...), data...)
+	if len(metadata) > 0 {
+		c.metadata[p] = mergeMetadata(c.metadata[p], metadata)
+	}
+	return nil
+}
+
+func (a *pfsServer) CopyFile(ctx context.Context, request *pfs.CopyFileRequest) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, src, err := a.resolveCommit(request.GetSrc().GetCommit())
+	if err != nil {
+		return nil, err
+	}
+	srcPath := cleanPath(request.Src.Path)
+	if _, err := src.fileInfo(srcPath); err != nil {
+		return nil, err
+	}
+	dst, isNew, err := a.openCommit(request.GetDst().GetCommit())
+	if err != nil {
+		return nil, err
+	}
+	if isNew {
+		defer a.finishCommit(dst, "")
+	}
+	dstPath := cleanPath(request.Dst.Path)
+	if request.Overwrite {
+		dst.deleteFile(dstPath)
+	}
+	for _, p := range src.paths(srcPath) {
+		data, ok := src.files[p]
+		if !ok {
+			continue
+		}
+		target := cleanPath(dstPath + "/" + strings.TrimPrefix(p, srcPath))
+		if err := checkWritable(dst, target); err != nil {
+			return nil, err
+		}
+		old := dst.files[target]
+		content := make([]byte, 0, len(old)+len(data))
+		dst.files[target] = append(append(content, old...), data...)
+		if metadata, ok := src.metadata[p]; ok {
+			dst.metadata[target] = mergeMetadata(dst.metadata[target], metadata)
+		}
+	}
+	return &types.Empty{}, nil
+}
+
+func (a *pfsServer) GetFile(request *pfs.GetFileRequest, server pfs.API_GetFileServer) error {
+	a.mu.Lock()
+	_, c, err := a.resolveCommit(request.GetFile().GetCommit())
+	if err != nil {
+		a.mu.Unlock()
+		return err
+	}
+	p := cleanPath(request.File.Path)
+	data, ok := c.files[p]
+	isDir := !ok && c.isDir(p)
+	a.mu.Unlock()
+	if isDir {
+		return fmt.Errorf("cannot get %q, as it is a directory", p)
+	} else if !ok {
+		return fmt.Errorf("file %v not found in repo %v at commit %v", p, request.File.Commit.Repo.Name, request.File.Commit.ID)
+	}
+	if request.OffsetBytes > int64(len(data)) {
+		return nil
+	}
+	data = data[request.OffsetBytes:]
+	if request.SizeBytes > 0 && request.SizeBytes < int64(len(data)) {
+		data = data[:request.SizeBytes]
+	}
+	r := bytes.NewReader(data)
+	buf := make([]byte, getFileChunkSize)
+	for {
+		n, err := r.Read(buf)
+		if err == io.EOF {
+			return nil
+		}
+		if err := server.Send(&types.BytesValue{Value: buf[:n]}); err != nil {
+			return err
+		}
+	}
+}
+
+func (a *pfsServer) GetFileURL(ctx context.Context, request *pfs.GetFileURLRequest) (*pfs.GetFileURLResponse, error) {
+	return nil, unimplemented("GetFileURL")
+}
+
+func (a *pfsServer) InspectFile(ctx context.Context, request *pfs.InspectFileRequest) (*pfs.FileInfo, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, c, err := a.resolveCommit(request.GetFile().GetCommit())
+	if err != nil {
+		return nil, err
+	}
+	return c.fileInfo(cleanPath(request.File.Path))
+}
+
+// listFile returns info about the children of the directory in 'request', or
+// about the file in 'request' if it isn't a directory
+func (a *pfsServer) listFile(request *pfs.ListFileRequest) ([]*pfs.FileInfo, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, c, err := a.resolveCommit(request.GetFile().GetCommit())
+	if err != nil {
+		return nil, err
+	}
+	var since *commit
+	if request.GetFilter().GetModifiedSince() != nil {
+		if _, since, err = a.resolveCommit(request.Filter.ModifiedSince); err != nil {
+			return nil, err
+		}
+	}
+	if (request.PageSize != 0 || request.Cursor != "") && request.History != 0 {
+		return nil, fmt.Errorf("ListFile can't page its results if history is set")
+	}
+	p := cleanPath(request.File.Path)
+	info, err := c.fileInfo(p)
+	if err != nil {
+		return nil, err
+	}
+	infos := []*pfs.FileInfo{info}
+	if info.FileType == pfs.FileType_DIR {
+		infos = nil
+		for _, child := range info.Children {
+			childInfo, err := c.fileInfo(cleanPath(p + "/" + child))
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, childInfo)
+		}
+	}
+	// infos is sorted by path, as pachd lists files in order
+	var cursor string
+	if request.Cursor != "" {
+		cursor = cleanPath(request.Cursor)
+	}
+	var result []*pfs.FileInfo
+	for _, info := range infos {
+		if request.PageSize > 0 && int64(len(result)) == request.PageSize {
+			break
+		}
+		if cursor != "" && info.File.Path <= cursor {
+			continue
+		}
+		if request.Filter != nil && !matchesFilter(info, request.Filter, since) {
+			continue
+		}
+		if request.History == 0 {
+			result = append(result, info)
+			continue
+		}
+		history, err := r.fileHistory(c, info.File.Path, request.History)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, history...)
+	}
+	return result, nil
+}
+
+// fileHistory returns the versions of the file 'p' as of 'c', newest first,
+// the way pachd does: each version is given as of the oldest of the
+// consecutive ancestors of 'c' that the file had it in, and at most 'history'
+// versions are returned, if it's positive
+func (r *repo) fileHistory(c *commit, p string, history int64) ([]*pfs.FileInfo, error) {
+	var result []*pfs.FileInfo
+	info, err := c.fileInfo(p)
+	if err != nil {
+		return nil, err
+	}
+	for c.info.ParentCommit != nil && r.commits[c.info.ParentCommit.ID] != nil {
+		c = r.commits[c.info.ParentCommit.ID]
+		older, err := c.fileInfo(p)
+		if err != nil {
+			break // the file didn't exist before this
+		}
+		if !bytes.Equal(info.Hash, older.Hash) {
+			result = append(result, info)
+			if history > 0 && int64(len(result)) == history {
+				return result, nil
+			}
+		}
+		info = older
+	}
+	return append(result, info), nil
+}
+
+// matchesFilter returns true if 'info' satisfies 'filter', whose
+// ModifiedSince commit is 'since'
+func matchesFilter(info *pfs.FileInfo, filter *pfs.FileFilter, since *commit) bool {
+	if filter.MinSizeBytes != 0 && info.SizeBytes < filter.MinSizeBytes {
+		return false
+	}
+	if filter.MaxSizeBytes != 0 && info.SizeBytes > filter.MaxSizeBytes {
+		return false
+	}
+	for k, v := range filter.Metadata {
+		if value, ok := info.Metadata[k]; !ok || value != v {
+			return false
+		}
+	}
+	if since != nil {
+		oldInfo, err := since.fileInfo(info.File.Path)
+		return err != nil || !bytes.Equal(oldInfo.Hash, info.Hash)
+	}
+	return true
+}
+
+func (a *pfsServer) ListFile(ctx context.Context, request *pfs.ListFileRequest) (*pfs.FileInfos, error) {
+	infos, err := a.listFile(request)
+	if err != nil {
+		return nil, err
+	}
+	return &pfs.FileInfos{FileInfo: infos}, nil
+}
+
+func (a *pfsServer) ListFileStream(request *pfs.ListFileRequest, server pfs.API_ListFileStreamServer) error {
+	infos, err := a.listFile(request)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if err := server.Send(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetCommitArchive archives the files under the request's path, like pachd
+func (a *pfsServer) GetCommitArchive(request *pfs.GetCommitArchiveRequest, server pfs.API_GetCommitArchiveServer) error {
+	type entry struct {
+		info *pfs.FileInfo
+		data []byte
+	}
+	var entries []entry
+	if err := func() error {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		_, c, err := a.resolveCommit(request.GetFile().GetCommit())
+		if err != nil {
+			return err
+		}
+		p := cleanPath(request.File.Path)
+		if _, err := c.fileInfo(p); err != nil {
+			return err
+		}
+		for _, walked := range c.paths(p) {
+			info, err := c.fileInfo(walked)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry{info: info, data: c.files[walked]})
+		}
+		return nil
+	}(); err != nil {
+		return err
+	}
+	w := bufio.NewWriterSize(grpcutil.NewStreamingBytesWriter(server), getFileChunkSize)
+	var gw *gzip.Writer
+	var tw *tar.Writer
+	var zw *zip.Writer
+	switch request.Format {
+	case pfs.ArchiveFormat_TAR_GZ:
+		gw = gzip.NewWriter(w)
+		tw = tar.NewWriter(gw)
+	case pfs.ArchiveFormat_ZIP:
+		zw = zip.NewWriter(w)
+	default:
+		return fmt.Errorf("unrecognized archive format %v", request.Format)
+	}
+	for _, e := range entries {
+		name := strings.TrimPrefix(e.info.File.Path, "/")
+		if name == "" {
+			continue
+		}
+		isDir := e.info.FileType == pfs.FileType_DIR
+		if isDir {
+			name += "/"
+		}
+		if tw != nil {
+			mode, err := strconv.ParseInt(e.info.Metadata[pfs.FileModeMetadataKey], 8, 64)
+			if err != nil {
+				mode = 0644
+			}
+			header := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: mode, Size: int64(len(e.data))}
+			if isDir {
+				header = &tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: 0755}
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if _, err := tw.Write(e.data); err != nil {
+				return err
+			}
+			continue
+		}
+		fw, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(e.data); err != nil {
+			return err
+		}
+	}
+	if tw != nil {
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		if err := gw.Close(); err != nil {
+			return err
+		}
+	} else if err := zw.Close(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (a *pfsServer) GetCommitManifest(request *pfs.GetCommitManifestRequest, server pfs.API_GetCommitManifestServer) error {
+	var entries []*pfs.ManifestEntry
+	if err := func() error {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		_, c, err := a.resolveCommit(request.GetFile().GetCommit())
+		if err != nil {
+			return err
+		}
+		p := cleanPath(request.File.Path)
+		if _, err := c.fileInfo(p); err != nil {
+			return err
+		}
+		for _, walked := range c.paths(p) {
+			info, err := c.fileInfo(walked)
+			if err != nil {
+				return err
+			}
+			if info.FileType == pfs.FileType_FILE {
+				entries = append(entries, manifest.NewEntry(info))
+			}
+		}
+		return nil
+	}(); err != nil {
+		return err
+	}
+	w := bufio.NewWriterSize(grpcutil.NewStreamingBytesWriter(server), getFileChunkSize)
+	mw, err := manifest.NewWriter(w, request.Format)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := mw.Write(entry); err != nil {
+			return err
+		}
+	}
+	if err := mw.Flush(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (a *pfsServer) WalkFile(request *pfs.WalkFileRequest, server pfs.API_WalkFileServer) error {
+	var infos []*pfs.FileInfo
+	if err := func() error {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		_, c, err := a.resolveCommit(request.GetFile().GetCommit())
+		if err != nil {
+			return err
+		}
+		p := cleanPath(request.File.Path)
+		if _, err := c.fileInfo(p); err != nil {
+			return err
+		}
+		for _, walked := range c.paths(p) {
+			info, err := c.fileInfo(walked)
+			if err != nil {
+				return err
+			}
+			infos = append(infos, info)
+		}
+		return nil
+	}(); err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if err := server.Send(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// globFile returns info about the files and directories matching the pattern
+// in 'request', in sorted order
+func (a *pfsServer) globFile(request *pfs.GlobFileRequest) ([]*pfs.FileInfo, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, c, err := a.resolveCommit(request.Commit)
+	if err != nil {
+		return nil, err
+	}
+	var match func(string) bool
+	if request.PatternType == pfs.PatternType_REGEX {
+		re, err := regexp.Compile("^(?:" + request.Pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("malformed regular expression %q: %v", request.Pattern, err)
+		}
+		match = re.MatchString
+	} else {
+		g, err := globlib.Compile(cleanPath(request.Pattern), '/')
+		if err != nil {
+			return nil, fmt.Errorf("malformed glob pattern %q: %v", request.Pattern, err)
+		}
+		match = g.Match
+	}
+	var infos []*pfs.FileInfo
+	for _, p := range c.paths("/") {
+		if !match(p) {
+			continue
+		}
+		info, err := c.fileInfo(p)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (a *pfsServer) GlobFile(ctx context.Context, request *pfs.GlobFileRequest) (*pfs.FileInfos, error) {
+	infos, err := a.globFile(request)
+	if err != nil {
+		return nil, err
+	}
+	return &pfs.FileInfos{FileInfo: infos}, nil
+}
+
+func (a *pfsServer) GlobFileStream(request *pfs.GlobFileRequest, server pfs.API_GlobFileStreamServer) error {
+	infos, err := a.globFile(request)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if err := server.Send(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *pfsServer) DiffFile(ctx context.Context, request *pfs.DiffFileRequest) (*pfs.DiffFileResponse, error) {
+	return nil, unimplemented("DiffFile")
+}
+
+func (a *pfsServer) DeleteFile(ctx context.Context, request *pfs.DeleteFileRequest) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c, isNew, err := a.openCommit(request.GetFile().GetCommit())
+	if err != nil {
+		return nil, err
+	}
+	if isNew {
+		defer a.finishCommit(c, "")
+	}
+	c.deleteFile(cleanPath(request.File.Path))
+	return &types.Empty{}, nil
+}
+
+// deleteFile deletes the file or directory at 'p'
+func (c *commit) deleteFile(p string) {
+	prefix := dirPrefix(p)
+	for f := range c.files {
+		if f == p || strings.HasPrefix(f, prefix) {
+			delete(c.files, f)
+			delete(c.metadata, f)
+		}
+	}
+}
+
+func (a *pfsServer) DeleteAll(ctx context.Context, request *types.Empty) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.repos = make(map[string]*repo)
+	a.notify()
+	return &types.Empty{}, nil
+}
@@ -0,0 +1,530 @@
+package testing
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/protobuf/proto"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+	"github.com/pachyderm/pachyderm/src/server/pkg/uuid"
+	"golang.org/x/net/context"
+)
+
+// ppsServer is a fake of pachd's PPS API. It stores pipelines (and creates
+// their output repos), but never runs them, so no jobs or datums exist.
+type ppsServer struct {
+	*state
+}
+
+func (a *ppsServer) getPipeline(name string) (*pps.PipelineInfo, error) {
+	pipelineInfo, ok := a.pipelines[name]
+	if !ok {
+		return nil, fmt.Errorf("pipeline %v not found", name)
+	}
+	return pipelineInfo, nil
+}
+
+func (a *ppsServer) CreateJob(ctx context.Context, request *pps.CreateJobRequest) (*pps.Job, error) {
+	return nil, unimplemented("CreateJob")
+}
+
+func (a *ppsServer) InspectJob(ctx context.Context, request *pps.InspectJobRequest) (*pps.JobInfo, error) {
+	return nil, fmt.Errorf("job %v not found", request.GetJob().GetID())
+}
+
+func (a *ppsServer) ListJob(ctx context.Context, request *pps.ListJobRequest) (*pps.JobInfos, error) {
+	return &pps.JobInfos{}, nil
+}
+
+func (a *ppsServer) ListJobStream(request *pps.ListJobRequest, server pps.API_ListJobStreamServer) error {
+	return nil
+}
+
+func (a *ppsServer) FlushJob(request *pps.FlushJobRequest, server pps.API_FlushJobServer) error {
+	return nil
+}
+
+func (a *ppsServer) DeleteJob(ctx context.Context, request *pps.DeleteJobRequest) (*types.Empty, error) {
+	return nil, fmt.Errorf("job %v not found", request.GetJob().GetID())
+}
+
+func (a *ppsServer) StopJob(ctx context.Context, request *pps.StopJobRequest) (*types.Empty, error) {
+	return nil, fmt.Errorf("job %v not found", request.GetJob().GetID())
+}
+
+func (a *ppsServer) InspectDatum(ctx context.Context, request *pps.InspectDatumRequest) (*pps.DatumInfo, error) {
+	return nil, unimplemented("InspectDatum")
+}
+
+func (a *ppsServer) ListDatum(ctx context.Context, request *pps.ListDatumRequest) (*pps.ListDatumResponse, error) {
+	return nil, unimplemented("ListDatum")
+}
+
+func (a *ppsServer) ListDatumStream(request *pps.ListDatumRequest, server pps.API_ListDatumStreamServer) error {
+	return unimplemented("ListDatumStream")
+}
+
+func (a *ppsServer) RestartDatum(ctx context.Context, request *pps.RestartDatumRequest) (*types.Empty, error) {
+	return nil, unimplemented("RestartDatum")
+}
+
+func (a *ppsServer) CreatePipeline(ctx context.Context, request *pps.CreatePipelineRequest) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	name := request.GetPipeline().GetName()
+	if err := validateName("pipeline", name); err != nil {
+		return nil, err
+	}
+	if request.Transform == nil {
+		return nil, fmt.Errorf("pipeline %v must specify a transform", name)
+	}
+	if request.Input == nil {
+		return nil, fmt.Errorf("pipeline %v must specify an input", name)
+	}
+	outputBranch := request.OutputBranch
+	if outputBranch == "" {
+		outputBranch = "master"
+	}
+	pipelineInfo := &pps.PipelineInfo{
+		ID:                 uuid.NewWithoutDashes(),
+		Pipeline:           request.Pipeline,
+		Version:            1,
+		Transform:          request.Transform,
+		ParallelismSpec:    request.ParallelismSpec,
+		HashtreeSpec:       request.HashtreeSpec,
+		Egress:             request.Egress,
+		CreatedAt:          types.TimestampNow(),
+		State:              pps.PipelineState_PIPELINE_RUNNING,
+		OutputBranch:       outputBranch,
+		ScaleDownThreshold: request.ScaleDownThreshold,
+		ResourceRequests:   request.ResourceRequests,
+		ResourceLimits:     request.ResourceLimits,
+		Input:              request.Input,
+		Description:        request.Description,
+		CacheSize:          request.CacheSize,
+		EnableStats:        request.EnableStats,
+		Salt:               request.Salt,
+		Batch:              request.Batch,
+		MaxQueueSize:       request.MaxQueueSize,
+		Service:            request.Service,
+		ChunkSpec:          request.ChunkSpec,
+		DatumTimeout:       request.DatumTimeout,
+		JobTimeout:         request.JobTimeout,
+		Standby:            request.Standby,
+		StandbyIdleTimeout: request.StandbyIdleTimeout,
+		DatumFailurePolicy: request.DatumFailurePolicy,
+		Priority:           request.Priority,
+		DatumTries:         request.DatumTries,
+		SchedulingSpec:     request.SchedulingSpec,
+		PodSpec:            request.PodSpec,
+		PodPatch:           request.PodPatch,
+		Metadata:           request.Metadata,
+		InitContainers:     request.InitContainers,
+		Sidecars:           request.Sidecars,
+	}
+	if prev, ok := a.pipelines[name]; ok {
+		if !request.Update {
+			return nil, fmt.Errorf("pipeline %v already exists", name)
+		}
+		pipelineInfo.ID = prev.ID
+		pipelineInfo.Version = prev.Version + 1
+		pipelineInfo.CreatedAt = prev.CreatedAt
+		pipelineInfo.Stopped = prev.Stopped
+		pipelineInfo.State = prev.State
+	}
+	r, ok := a.repos[name]
+	if !ok {
+		r = a.newRepo(name, request.Description)
+	}
+	if _, ok := r.branches[outputBranch]; !ok {
+		r.setHead(outputBranch, nil)
+	}
+	a.pipelines[name] = pipelineInfo
+	a.pipelineVersions[name] = append(a.pipelineVersions[name], proto.Clone(pipelineInfo).(*pps.PipelineInfo))
+	a.notify()
+	return &types.Empty{}, nil
+}
+
+// ValidatePipeline returns the issues that pps.LintPipeline finds, along
+// with the problems that the fake's CreatePipeline would reject (and input
+// repos that don't exist). It doesn't check the spec against the cluster.
+func (a *ppsServer) ValidatePipeline(ctx context.Context, request *pps.CreatePipelineRequest) (*pps.ValidatePipelineResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	issues := pps.LintPipeline(request)
+	if name := request.GetPipeline().GetName(); name != "" {
+		if err := validateName("pipeline", name); err != nil {
+			issues = append(issues, pps.NewPipelineIssue(pps.IssueSeverity_ISSUE_ERROR, "pipeline.name", "%v", err))
+		}
+	}
+	if request.Transform == nil {
+		issues = append(issues, pps.NewPipelineIssue(pps.IssueSeverity_ISSUE_ERROR, "transform", "pipeline must specify a transform"))
+	}
+	if request.Input == nil {
+		return &pps.ValidatePipelineResponse{Issues: issues}, nil
+	}
+	pps.VisitInput(request.Input, func(input *pps.Input) {
+		var repo string
+		switch {
+		case input.Pfs != nil:
+			repo = input.Pfs.Repo
+		case input.Atom != nil:
+			repo = input.Atom.Repo
+		default:
+			return
+		}
+		if _, ok := a.repos[repo]; !ok {
+			issues = append(issues, pps.NewPipelineIssue(pps.IssueSeverity_ISSUE_ERROR, pps.InputField(input, "repo"), "repo %v not found", repo))
+		}
+	})
+	return &pps.ValidatePipelineResponse{Issues: issues}, nil
+}
+
+func (a *ppsServer) InspectPipeline(ctx context.Context, request *pps.InspectPipelineRequest) (*pps.PipelineInfo, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	pipelineInfo, err := a.getPipeline(request.GetPipeline().GetName())
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(pipelineInfo).(*pps.PipelineInfo), nil
+}
+
+func (a *ppsServer) ListPipeline(ctx context.Context, request *pps.ListPipelineRequest) (*pps.PipelineInfos, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var names []string
+	for name := range a.pipelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	response := &pps.PipelineInfos{}
+	for _, name := range names {
+		response.PipelineInfo = append(response.PipelineInfo, proto.Clone(a.pipelines[name]).(*pps.PipelineInfo))
+	}
+	return response, nil
+}
+
+// ApplyPipelines plans the changes with pps.PlanApplyPipelines (as pachd
+// does), and makes them with CreatePipeline and DeletePipeline. As in pachd,
+// if creating or updating a pipeline fails, the changes already made are
+// undone, but pipelines that are already deleted (which happens last) aren't
+// recreated. Unlike pachd, the specs aren't validated up front.
+func (a *ppsServer) ApplyPipelines(ctx context.Context, request *pps.ApplyPipelinesRequest) (*pps.ApplyPipelinesResponse, error) {
+	pipelineInfos, err := a.ListPipeline(ctx, &pps.ListPipelineRequest{})
+	if err != nil {
+		return nil, err
+	}
+	previous := make(map[string]*pps.PipelineInfo)
+	for _, pipelineInfo := range pipelineInfos.PipelineInfo {
+		previous[pipelineInfo.Pipeline.Name] = pipelineInfo
+	}
+	changes, err := pps.PlanApplyPipelines(pipelineInfos.PipelineInfo, request, func(spec *pps.CreatePipelineRequest, pipelineInfo *pps.PipelineInfo) bool {
+		spec = proto.Clone(spec).(*pps.CreatePipelineRequest)
+		spec.Update, spec.Reprocess = false, false
+		if spec.OutputBranch == "" {
+			spec.OutputBranch = "master"
+		}
+		if spec.Salt == "" {
+			spec.Salt = pipelineInfo.Salt
+		}
+		return proto.Equal(spec, pps.PipelineReqFromInfo(pipelineInfo))
+	})
+	if err != nil {
+		return nil, err
+	}
+	specs := make(map[string]*pps.CreatePipelineRequest)
+	for _, spec := range request.Pipelines {
+		specs[spec.Pipeline.Name] = spec
+	}
+	for i, change := range changes {
+		name := change.Pipeline.Name
+		switch change.Type {
+		case pps.PipelineChangeType_PIPELINE_CREATE, pps.PipelineChangeType_PIPELINE_UPDATE:
+			spec := proto.Clone(specs[name]).(*pps.CreatePipelineRequest)
+			spec.Update = change.Type == pps.PipelineChangeType_PIPELINE_UPDATE
+			_, err = a.CreatePipeline(ctx, spec)
+		case pps.PipelineChangeType_PIPELINE_DELETE:
+			_, err = a.DeletePipeline(ctx, &pps.DeletePipelineRequest{Pipeline: change.Pipeline})
+		}
+		if err != nil {
+			if change.Type != pps.PipelineChangeType_PIPELINE_DELETE {
+				a.undoPipelineChanges(ctx, changes[:i], previous)
+			}
+			return nil, fmt.Errorf("could not apply the change to pipeline %v: %v", name, err)
+		}
+	}
+	return &pps.ApplyPipelinesResponse{Changes: changes}, nil
+}
+
+func (a *ppsServer) undoPipelineChanges(ctx context.Context, changes []*pps.PipelineChange, previous map[string]*pps.PipelineInfo) {
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		switch change.Type {
+		case pps.PipelineChangeType_PIPELINE_CREATE:
+			a.DeletePipeline(ctx, &pps.DeletePipelineRequest{Pipeline: change.Pipeline})
+		case pps.PipelineChangeType_PIPELINE_UPDATE:
+			spec := pps.PipelineReqFromInfo(previous[change.Pipeline.Name])
+			spec.Update = true
+			a.CreatePipeline(ctx, spec)
+		}
+	}
+}
+
+func (a *ppsServer) ListPipelineVersions(ctx context.Context, request *pps.ListPipelineVersionsRequest) (*pps.PipelineInfos, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	name := request.GetPipeline().GetName()
+	if _, err := a.getPipeline(name); err != nil {
+		return nil, err
+	}
+	versions := a.pipelineVersions[name]
+	response := &pps.PipelineInfos{}
+	for i := len(versions) - 1; i >= 0; i-- {
+		response.PipelineInfo = append(response.PipelineInfo, proto.Clone(versions[i]).(*pps.PipelineInfo))
+	}
+	return response, nil
+}
+
+func (a *ppsServer) RollbackPipeline(ctx context.Context, request *pps.RollbackPipelineRequest) (*types.Empty, error) {
+	versions, err := a.ListPipelineVersions(ctx, &pps.ListPipelineVersionsRequest{Pipeline: request.Pipeline})
+	if err != nil {
+		return nil, err
+	}
+	spec, err := pps.RollbackSpec(versions.PipelineInfo, request)
+	if err != nil {
+		return nil, err
+	}
+	return a.CreatePipeline(ctx, spec)
+}
+
+// DeletePipeline deletes a pipeline along with its output repo
+func (a *ppsServer) DeletePipeline(ctx context.Context, request *pps.DeletePipelineRequest) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if request.All {
+		for name := range a.pipelines {
+			a.deletePipeline(name)
+		}
+	} else {
+		name := request.GetPipeline().GetName()
+		if _, err := a.getPipeline(name); err != nil {
+			return nil, err
+		}
+		a.deletePipeline(name)
+	}
+	a.notify()
+	return &types.Empty{}, nil
+}
+
+func (a *ppsServer) deletePipeline(name string) {
+	delete(a.pipelines, name)
+	delete(a.pipelineVersions, name)
+	delete(a.repos, name)
+}
+
+func (a *ppsServer) StartPipeline(ctx context.Context, request *pps.StartPipelineRequest) (*types.Empty, error) {
+	return a.setStopped(request.GetPipeline().GetName(), false)
+}
+
+func (a *ppsServer) StopPipeline(ctx context.Context, request *pps.StopPipelineRequest) (*types.Empty, error) {
+	return a.setStopped(request.GetPipeline().GetName(), true)
+}
+
+func (a *ppsServer) setStopped(name string, stopped bool) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	pipelineInfo, err := a.getPipeline(name)
+	if err != nil {
+		return nil, err
+	}
+	pipelineInfo.Stopped = stopped
+	pipelineInfo.State = pps.PipelineState_PIPELINE_RUNNING
+	if stopped {
+		pipelineInfo.State = pps.PipelineState_PIPELINE_PAUSED
+	}
+	return &types.Empty{}, nil
+}
+
+func (a *ppsServer) RerunPipeline(ctx context.Context, request *pps.RerunPipelineRequest) (*types.Empty, error) {
+	return nil, unimplemented("RerunPipeline")
+}
+
+// DeleteAll deletes all pipelines, along with their output repos
+func (a *ppsServer) DeleteAll(ctx context.Context, request *types.Empty) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for name := range a.pipelines {
+		a.deletePipeline(name)
+	}
+	a.notify()
+	return &types.Empty{}, nil
+}
+
+func (a *ppsServer) GetLogs(request *pps.GetLogsRequest, server pps.API_GetLogsServer) error {
+	return nil
+}
+
+// GarbageCollect records a successful collection, as the fake has no
+// objects to collect
+func (a *ppsServer) GarbageCollect(ctx context.Context, request *pps.GarbageCollectRequest) (*pps.GarbageCollectResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.collectGarbage()
+	return &pps.GarbageCollectResponse{}, nil
+}
+
+// collectGarbage records a successful collection. It must be called with
+// a.mu held.
+func (a *ppsServer) collectGarbage() {
+	a.gcStatus = pps.GarbageCollectStatus{
+		State:    pps.GarbageCollectState_GC_SUCCESS,
+		Started:  types.TimestampNow(),
+		Finished: types.TimestampNow(),
+		Updated:  types.TimestampNow(),
+		Schedule: a.gcStatus.Schedule,
+	}
+}
+
+// StartGarbageCollect is like GarbageCollect: the collection finishes
+// immediately
+func (a *ppsServer) StartGarbageCollect(ctx context.Context, request *pps.GarbageCollectRequest) (*pps.GarbageCollectStatus, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.collectGarbage()
+	return proto.Clone(&a.gcStatus).(*pps.GarbageCollectStatus), nil
+}
+
+func (a *ppsServer) InspectGarbageCollect(ctx context.Context, request *types.Empty) (*pps.GarbageCollectStatus, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return proto.Clone(&a.gcStatus).(*pps.GarbageCollectStatus), nil
+}
+
+// PauseGarbageCollect always fails, as collections finish immediately
+func (a *ppsServer) PauseGarbageCollect(ctx context.Context, request *types.Empty) (*pps.GarbageCollectStatus, error) {
+	return nil, fmt.Errorf("garbage collection is not running")
+}
+
+// ResumeGarbageCollect always fails, as collections finish immediately
+func (a *ppsServer) ResumeGarbageCollect(ctx context.Context, request *types.Empty) (*pps.GarbageCollectStatus, error) {
+	return nil, fmt.Errorf("garbage collection is not running")
+}
+
+// SetGarbageCollectSchedule stores the schedule, but the fake never runs
+// scheduled collections (or sets the schedule's next run)
+func (a *ppsServer) SetGarbageCollectSchedule(ctx context.Context, request *pps.SetGarbageCollectScheduleRequest) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.gcStatus.Schedule = request.Schedule
+	return &types.Empty{}, nil
+}
+
+// CreateReplication stores the replication, but the fake never copies any
+// commits to its target
+func (a *ppsServer) CreateReplication(ctx context.Context, request *pps.CreateReplicationRequest) (*types.Empty, error) {
+	name := request.GetReplication().GetName()
+	if name == "" || request.GetTarget().GetPachdAddress() == "" || len(request.Branches) == 0 {
+		return nil, fmt.Errorf("replication must have a name, a target and at least one branch")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	replicationInfo, ok := a.replications[name]
+	switch {
+	case ok && !request.Update:
+		return nil, fmt.Errorf("replication %v already exists", name)
+	case !ok && request.Update:
+		return nil, fmt.Errorf("replication %v not found", name)
+	case !ok:
+		replicationInfo = &pps.ReplicationInfo{
+			Replication: request.Replication,
+			Created:     types.TimestampNow(),
+		}
+		a.replications[name] = replicationInfo
+	}
+	replicationInfo.Target = request.Target
+	replicationInfo.Branches = request.Branches
+	return &types.Empty{}, nil
+}
+
+func (a *ppsServer) getReplication(name string) (*pps.ReplicationInfo, error) {
+	replicationInfo, ok := a.replications[name]
+	if !ok {
+		return nil, fmt.Errorf("replication %v not found", name)
+	}
+	result := proto.Clone(replicationInfo).(*pps.ReplicationInfo)
+	result.Target.AuthToken = ""
+	return result, nil
+}
+
+func (a *ppsServer) InspectReplication(ctx context.Context, request *pps.InspectReplicationRequest) (*pps.ReplicationInfo, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.getReplication(request.GetReplication().GetName())
+}
+
+func (a *ppsServer) ListReplication(ctx context.Context, request *pps.ListReplicationRequest) (*pps.ReplicationInfos, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var names []string
+	for name := range a.replications {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := &pps.ReplicationInfos{}
+	for _, name := range names {
+		replicationInfo, err := a.getReplication(name)
+		if err != nil {
+			return nil, err
+		}
+		result.ReplicationInfo = append(result.ReplicationInfo, replicationInfo)
+	}
+	return result, nil
+}
+
+func (a *ppsServer) DeleteReplication(ctx context.Context, request *pps.DeleteReplicationRequest) (*types.Empty, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	name := request.GetReplication().GetName()
+	if _, ok := a.replications[name]; !ok {
+		return nil, fmt.Errorf("replication %v not found", name)
+	}
+	delete(a.replications, name)
+	return &types.Empty{}, nil
+}
+
+// SetSchedulerLimits stores the limits, but the fake runs no jobs for them to
+// limit
+func (a *ppsServer) SetSchedulerLimits(ctx context.Context, request *pps.SetSchedulerLimitsRequest) (*types.Empty, error) {
+	if limits := request.Limits; limits != nil {
+		if limits.MaxRunningJobs < 0 || limits.MaxCpu < 0 || limits.MaxGpu < 0 {
+			return nil, fmt.Errorf("scheduler limits must not be negative")
+		}
+		for pipeline, max := range limits.PipelineMaxRunningJobs {
+			if max < 0 {
+				return nil, fmt.Errorf("the limit on pipeline %s's running jobs must not be negative", pipeline)
+			}
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.schedulerLimits = request.Limits
+	return &types.Empty{}, nil
+}
+
+// InspectScheduler returns the stored limits. No jobs are ever running or
+// queued.
+func (a *ppsServer) InspectScheduler(ctx context.Context, request *types.Empty) (*pps.SchedulerStatus, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	status := &pps.SchedulerStatus{}
+	if a.schedulerLimits != nil {
+		status.Limits = proto.Clone(a.schedulerLimits).(*pps.SchedulerLimits)
+	}
+	return status, nil
+}
+
+func (a *ppsServer) ActivateAuth(ctx context.Context, request *pps.ActivateAuthRequest) (*pps.ActivateAuthResponse, error) {
+	return nil, unimplemented("ActivateAuth")
+}
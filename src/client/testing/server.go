@@ -0,0 +1,214 @@
+// Package testing provides an in-memory fake of pachd, so that programs that
+// use the Pachyderm client (e.g. pipeline tooling and ingest code) can be unit
+// tested without a Kubernetes cluster:
+//
+//	server := testing.NewServer()
+//	defer server.Close()
+//	c, err := server.NewClient()
+//	...
+//	_, err = c.PutFile("images", "master", "/cat.png", reader)
+//
+// The fake implements PFS's repos, commits, branches and files, and PPS's
+// pipelines. Pipelines are stored (and their output repos created) but never
+// run, so tests that need a pipeline's output can write it themselves. Auth is
+// never activated. RPCs the fake doesn't support (e.g. the object API, or
+// putting files from URLs) fail with codes.Unimplemented.
+package testing
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+func validateName(kind, name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("%s name (%v) invalid: only alphanumeric characters, underscores, and dashes are allowed", kind, name)
+	}
+	return nil
+}
+
+func unimplemented(rpc string) error {
+	return status.Errorf(codes.Unimplemented, "%s is not supported by the fake pachd", rpc)
+}
+
+// pageBounds returns the bounds of page 'page' of 'pageSize' items, in a
+// listing of 'n' items. If 'pageSize' is 0, all items are on one page.
+func pageBounds(n int, page, pageSize int64) (int, int) {
+	if pageSize <= 0 {
+		return 0, n
+	}
+	if page < 0 {
+		page = 0
+	}
+	start, end := page*pageSize, (page+1)*pageSize
+	if start > int64(n) {
+		start = int64(n)
+	}
+	if end > int64(n) {
+		end = int64(n)
+	}
+	return int(start), int(end)
+}
+
+// state is the state of a fake pachd, shared by its PFS and PPS servers
+type state struct {
+	mu        sync.Mutex
+	seq       int
+	repos     map[string]*repo
+	pipelines map[string]*pps.PipelineInfo
+	// pipelineVersions are the versions of each pipeline, oldest first
+	pipelineVersions map[string][]*pps.PipelineInfo
+	gcStatus         pps.GarbageCollectStatus
+	// replications are stored, but the fake never copies any commits
+	replications map[string]*pps.ReplicationInfo
+	// schedulerLimits are stored, but the fake runs no jobs to limit
+	schedulerLimits *pps.SchedulerLimits
+	// auth is the state of the auth service, or nil if auth isn't active
+	auth *authState
+	// changed is closed (and replaced) whenever the state changes, waking
+	// RPCs that are waiting for e.g. a commit to finish
+	changed chan struct{}
+}
+
+func (s *state) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// wait blocks until the state changes or 'ctx' is done. It must be called
+// with s.mu held, which it releases while waiting.
+func (s *state) wait(ctx context.Context) error {
+	changed := s.changed
+	s.mu.Unlock()
+	defer s.mu.Lock()
+	select {
+	case <-changed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Server is an in-memory fake of pachd. Clients connect to it through
+// in-memory pipes, so it doesn't listen on any port.
+type Server struct {
+	grpcServer *grpc.Server
+	listener   *pipeListener
+}
+
+// NewServer starts a new fake pachd with no repos or pipelines. Close must be
+// called once it's no longer needed.
+func NewServer() *Server {
+	s := &state{
+		repos:            make(map[string]*repo),
+		pipelines:        make(map[string]*pps.PipelineInfo),
+		pipelineVersions: make(map[string][]*pps.PipelineInfo),
+		replications:     make(map[string]*pps.ReplicationInfo),
+		changed:          make(chan struct{}),
+	}
+	unaryAuth, streamAuth := s.authorizePFS()
+	grpcServer := grpc.NewServer(
+		grpc.MaxRecvMsgSize(grpcutil.MaxMsgSize),
+		grpc.MaxSendMsgSize(grpcutil.MaxMsgSize),
+		grpc.UnaryInterceptor(unaryAuth),
+		grpc.StreamInterceptor(streamAuth),
+		grpc.UnknownServiceHandler(s.unknownService),
+	)
+	pfs.RegisterAPIServer(grpcServer, &pfsServer{s})
+	pps.RegisterAPIServer(grpcServer, &ppsServer{s})
+	listener := newPipeListener()
+	go grpcServer.Serve(listener)
+	return &Server{
+		grpcServer: grpcServer,
+		listener:   listener,
+	}
+}
+
+// unknownService handles the RPCs of the services that the fake doesn't
+// register a server for. The auth service is served by serveAuth, as the fake
+// only implements a few of its RPCs.
+func (s *state) unknownService(srv interface{}, stream grpc.ServerStream) error {
+	method, _ := grpc.MethodFromServerStream(stream)
+	if strings.HasPrefix(method, "/auth.API/") {
+		return s.serveAuth(strings.TrimPrefix(method, "/auth.API/"), stream)
+	}
+	return unimplemented(method)
+}
+
+// NewClient returns a client connected to the fake pachd. 'options' are
+// passed to client.NewFromAddress(), except that the client always connects
+// through an in-memory pipe.
+func (s *Server) NewClient(options ...client.Option) (*client.APIClient, error) {
+	options = append(options, client.WithDialer(s.listener.dial))
+	return client.NewFromAddress("fake-pachd:650", options...)
+}
+
+// Close stops the fake pachd, closing all client connections to it
+func (s *Server) Close() {
+	s.grpcServer.Stop()
+	s.listener.Close()
+}
+
+// pipeListener is a net.Listener whose connections are in-memory pipes
+type pipeListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, fmt.Errorf("fake pachd has been closed")
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+// dial is a client.Dialer that connects to the listener
+func (l *pipeListener) dial(ctx context.Context, addr string) (net.Conn, error) {
+	serverConn, clientConn := net.Pipe()
+	select {
+	case l.conns <- serverConn:
+		return clientConn, nil
+	case <-l.closed:
+		return nil, fmt.Errorf("fake pachd has been closed")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "fake-pachd" }
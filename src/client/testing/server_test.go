@@ -0,0 +1,1684 @@
+package testing
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/manifest"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+	"golang.org/x/net/context"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestFakePFS(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	require.YesError(t, c.CreateRepo("data"))
+
+	// writes to a branch are committed automatically
+	_, err = c.PutFile("data", "master", "/dir/a", strings.NewReader("foo"))
+	require.NoError(t, err)
+	commit1, err := c.InspectCommit("data", "master")
+	require.NoError(t, err)
+	require.NotNil(t, commit1.Finished)
+
+	// explicit commits
+	commit2, err := c.StartCommit("data", "master")
+	require.NoError(t, err)
+	_, err = c.PutFile("data", commit2.ID, "/dir/a", strings.NewReader("bar"))
+	require.NoError(t, err)
+	_, err = c.PutFile("data", commit2.ID, "/b", strings.NewReader("baz"))
+	require.NoError(t, err)
+	require.NoError(t, c.FinishCommit("data", commit2.ID))
+	_, err = c.PutFile("data", commit2.ID, "/c", strings.NewReader(""))
+	require.True(t, err != nil && strings.Contains(err.Error(), "has already finished"))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.GetFile("data", "master", "/dir/a", 0, 0, &buf))
+	require.Equal(t, "foobar", buf.String())
+	buf.Reset()
+	require.NoError(t, c.GetFile("data", commit1.Commit.ID, "/dir/a", 0, 0, &buf))
+	require.Equal(t, "foo", buf.String())
+
+	fileInfos, err := c.ListFile("data", "master", "/")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(fileInfos))
+	require.Equal(t, "/b", fileInfos[0].File.Path)
+	require.Equal(t, "/dir", fileInfos[1].File.Path)
+	require.Equal(t, pfs.FileType_DIR, fileInfos[1].FileType)
+	require.Equal(t, uint64(6), fileInfos[1].SizeBytes)
+
+	fileInfos, err = c.GlobFile("data", "master", "/*/*")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(fileInfos))
+	require.Equal(t, "/dir/a", fileInfos[0].File.Path)
+
+	commitInfos, err := c.ListCommit("data", "master", "", 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(commitInfos))
+	require.Equal(t, commit2.ID, commitInfos[0].Commit.ID)
+	require.Equal(t, commit1.Commit.ID, commitInfos[0].ParentCommit.ID)
+
+	require.NoError(t, c.DeleteFile("data", "master", "/dir"))
+	_, err = c.InspectFile("data", "master", "/dir/a")
+	require.YesError(t, err)
+
+	_, err = c.InspectRepo("missing")
+	require.True(t, err != nil && strings.Contains(err.Error(), "not found"))
+}
+
+func TestFakeAuth(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	_, err = c.WhoAmI(c.Ctx(), &auth.WhoAmIRequest{})
+	require.True(t, auth.IsErrNotActivated(err))
+	resp, err := c.Activate(c.Ctx(), &auth.ActivateRequest{Subject: "admin"})
+	require.NoError(t, err)
+	c.SetAuthToken(resp.PachToken)
+	require.NoError(t, c.CreateRepo("data"))
+	_, err = c.PutFile("data", "master", "/a", strings.NewReader("foo"))
+	require.NoError(t, err)
+
+	tokenResp, err := c.GetAuthToken(c.Ctx(), &auth.GetAuthTokenRequest{Subject: "alice"})
+	require.NoError(t, err)
+	alice, err := server.NewClient()
+	require.NoError(t, err)
+	defer alice.Close()
+	alice.SetAuthToken(tokenResp.Token)
+	whoAmI, err := alice.WhoAmI(alice.Ctx(), &auth.WhoAmIRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "alice", whoAmI.Username)
+	require.False(t, whoAmI.IsAdmin)
+
+	// alice can't read "data" until she's given access to it
+	var buf bytes.Buffer
+	require.True(t, auth.IsErrNotAuthorized(alice.GetFile("data", "master", "/a", 0, 0, &buf)))
+	repoInfos, err := alice.ListRepo()
+	require.NoError(t, err)
+	require.Equal(t, auth.Scope_NONE, repoInfos[0].AuthInfo.AccessLevel)
+	_, err = c.SetScope(c.Ctx(), &auth.SetScopeRequest{Username: "alice", Repo: "data", Scope: auth.Scope_READER})
+	require.NoError(t, err)
+	require.NoError(t, alice.GetFile("data", "master", "/a", 0, 0, &buf))
+	require.Equal(t, "foo", buf.String())
+	_, err = alice.PutFile("data", "master", "/b", strings.NewReader("bar"))
+	require.True(t, auth.IsErrNotAuthorized(err))
+	require.YesError(t, alice.DeleteRepo("data", false))
+
+	// Repos are owned by their creators
+	require.NoError(t, alice.CreateRepo("mine"))
+	_, err = alice.PutFile("mine", "master", "/b", strings.NewReader("bar"))
+	require.NoError(t, err)
+
+	unauthenticated, err := server.NewClient()
+	require.NoError(t, err)
+	defer unauthenticated.Close()
+	_, err = unauthenticated.ListRepo()
+	require.True(t, auth.IsErrNotSignedIn(err))
+	unauthenticated.SetAuthToken("bad")
+	_, err = unauthenticated.ListRepo()
+	require.True(t, auth.IsErrBadToken(err))
+}
+
+func TestFakePPS(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("in"))
+	require.NoError(t, c.CreatePipeline("out", "", []string{"cp", "-r", "/pfs/in", "/pfs/out"},
+		nil, nil, client.NewPFSInput("in", "/*"), "", false))
+	pipelineInfo, err := c.InspectPipeline("out")
+	require.NoError(t, err)
+	require.Equal(t, "master", pipelineInfo.OutputBranch)
+	_, err = c.InspectRepo("out")
+	require.NoError(t, err)
+
+	require.NoError(t, c.StopPipeline("out"))
+	pipelineInfo, err = c.InspectPipeline("out")
+	require.NoError(t, err)
+	require.Equal(t, pps.PipelineState_PIPELINE_PAUSED, pipelineInfo.State)
+
+	require.NoError(t, c.DeleteAll())
+	pipelineInfos, err := c.ListPipeline()
+	require.NoError(t, err)
+	require.Equal(t, 0, len(pipelineInfos))
+}
+
+func TestIterators(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	for _, repo := range []string{"a", "b", "c"} {
+		require.NoError(t, c.CreateRepo(repo))
+	}
+	var commitIDs []string
+	for i := 0; i < 5; i++ {
+		commit, err := c.StartCommit("a", "master")
+		require.NoError(t, err)
+		require.NoError(t, c.FinishCommit("a", commit.ID))
+		commitIDs = append([]string{commit.ID}, commitIDs...)
+	}
+
+	var repos []string
+	repoIter := c.ListRepoIter(2)
+	for repoIter.Next() {
+		repos = append(repos, repoIter.RepoInfo().Repo.Name)
+	}
+	require.NoError(t, repoIter.Err())
+	require.Equal(t, []string{"c", "b", "a"}, repos)
+
+	listCommits := func(to string, number uint64) []string {
+		var ids []string
+		commitIter := c.ListCommitIter("a", to, "", number, 2)
+		for commitIter.Next() {
+			ids = append(ids, commitIter.CommitInfo().Commit.ID)
+		}
+		require.NoError(t, commitIter.Err())
+		return ids
+	}
+	require.Equal(t, commitIDs, listCommits("", 0))
+	require.Equal(t, commitIDs, listCommits("master", 0))
+	require.Equal(t, commitIDs[:3], listCommits("master", 3))
+	require.Equal(t, commitIDs[:3], listCommits("", 3))
+
+	// commits added between pages aren't returned twice
+	var ids []string
+	commitIter := c.ListCommitIter("a", "", "", 0, 2)
+	for commitIter.Next() {
+		ids = append(ids, commitIter.CommitInfo().Commit.ID)
+		if len(ids) == 1 {
+			_, err := c.StartCommit("a", "master")
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, commitIter.Err())
+	require.Equal(t, commitIDs, ids)
+
+	commitIter = c.ListCommitIter("missing", "", "", 0, 2)
+	require.False(t, commitIter.Next())
+	require.YesError(t, commitIter.Err())
+
+	jobIter := c.ListJobIter("", nil, nil, 0)
+	require.False(t, jobIter.Next())
+	require.NoError(t, jobIter.Err())
+}
+
+func TestWatchCommits(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	_, err = c.PutFile("data", "master", "/a", strings.NewReader("foo"))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := c.WithCtx(ctx).WatchCommits("data", "master", "")
+	require.NoError(t, err)
+	next := func(eventType client.CommitEventType) *pfs.CommitInfo {
+		event := <-events
+		require.NoError(t, event.Err)
+		require.Equal(t, eventType, event.Type)
+		return event.CommitInfo
+	}
+	// existing commits are sent first
+	next(client.CommitCreated)
+	next(client.CommitFinished)
+
+	commit, err := c.StartCommit("data", "master")
+	require.NoError(t, err)
+	require.Equal(t, commit.ID, next(client.CommitCreated).Commit.ID)
+	require.NoError(t, c.FinishCommit("data", commit.ID))
+	commitInfo := next(client.CommitFinished)
+	require.Equal(t, commit.ID, commitInfo.Commit.ID)
+	require.NotNil(t, commitInfo.Finished)
+
+	// canceling the context closes the channel
+	cancel()
+	for range events {
+	}
+
+	// errors are sent as the last event
+	events, err = c.WatchCommits("missing", "master", "")
+	require.NoError(t, err)
+	event := <-events
+	require.True(t, event.Err != nil && strings.Contains(event.Err.Error(), "not found"))
+	_, ok := <-events
+	require.False(t, ok)
+}
+
+func TestSubscribeCommits(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	for _, repo := range []string{"images-a", "images-b", "other"} {
+		require.NoError(t, c.CreateRepo(repo))
+	}
+	var commits []*pfs.Commit
+	for _, repo := range []string{"images-b", "other", "images-a"} {
+		commit, err := c.StartCommit(repo, "master")
+		require.NoError(t, err)
+		require.NoError(t, c.FinishCommit(repo, commit.ID))
+		commits = append(commits, commit)
+	}
+
+	iter, err := c.SubscribeCommits([]string{"other"}, "images-*", "master", false, pfs.CommitState_STARTED)
+	require.NoError(t, err)
+	defer iter.Close()
+	// existing commits are sent first, oldest first
+	for _, commit := range commits {
+		commitInfo, err := iter.Next()
+		require.NoError(t, err)
+		require.Equal(t, commit.ID, commitInfo.Commit.ID)
+	}
+	// repos that match the pattern are subscribed to once they're created
+	require.NoError(t, c.CreateRepo("images-c"))
+	commit, err := c.StartCommit("images-c", "master")
+	require.NoError(t, err)
+	commitInfo, err := iter.Next()
+	require.NoError(t, err)
+	require.Equal(t, commit.ID, commitInfo.Commit.ID)
+
+	missingIter, err := c.SubscribeCommits([]string{"missing"}, "", "master", false, pfs.CommitState_STARTED)
+	require.NoError(t, err)
+	defer missingIter.Close()
+	_, err = missingIter.Next()
+	require.YesError(t, err)
+}
+
+func TestCtxVariants(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	_, err = c.PutFile("data", "master", "/a", strings.NewReader("foo"))
+	require.NoError(t, err)
+
+	// a deadline aborts a stream that would otherwise block forever
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	var seen int
+	err = c.SubscribeCommitFCtx(ctx, "data", "master", "", pfs.CommitState_STARTED, func(*pfs.CommitInfo) error {
+		seen++
+		return nil
+	})
+	require.YesError(t, err)
+	require.Matches(t, "context deadline exceeded", err.Error())
+	require.Equal(t, 1, seen)
+
+	iter, err := c.SubscribeCommitCtx(ctx, "data", "master", "", pfs.CommitState_STARTED)
+	if err == nil {
+		defer iter.Close()
+		_, err = iter.Next()
+	}
+	require.YesError(t, err)
+
+	// a cancelled context aborts each call before it does anything
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.PutFileCtx(cancelled, "data", "master", "/b", strings.NewReader("bar"))
+	require.YesError(t, err)
+	var buf bytes.Buffer
+	err = c.GetFileCtx(cancelled, "data", "master", "/a", 0, 0, &buf)
+	require.YesError(t, err)
+	require.Matches(t, "context canceled", err.Error())
+	require.Equal(t, 0, buf.Len())
+	_, err = c.GetFileReaderCtx(cancelled, "data", "master", "/a", 0, 0)
+	require.YesError(t, err)
+	require.YesError(t, c.ListFileFCtx(cancelled, "data", "master", "/", 0, func(*pfs.FileInfo) error { return nil }))
+	require.YesError(t, c.FlushCommitFCtx(cancelled, []*pfs.Commit{client.NewCommit("data", "master")}, nil, func(*pfs.CommitInfo) error { return nil }))
+	_, err = c.ListJobCtx(cancelled, "", nil, nil)
+	require.YesError(t, err)
+	require.YesError(t, c.FlushJobCtx(cancelled, []*pfs.Commit{client.NewCommit("data", "master")}, nil, func(*pps.JobInfo) error { return nil }))
+
+	// the client's own context is unaffected
+	buf.Reset()
+	require.NoError(t, c.GetFile("data", "master", "/a", 0, 0, &buf))
+	require.Equal(t, "foo", buf.String())
+	_, err = c.InspectFile("data", "master", "/b")
+	require.YesError(t, err)
+}
+
+// failingReader returns the data in 'r' followed by an error, to simulate an
+// interrupted upload
+type failingReader struct {
+	r io.Reader
+}
+
+func (f failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, fmt.Errorf("connection lost")
+	}
+	return n, err
+}
+
+func TestPutFileResumable(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	commit, err := c.StartCommit("data", "master")
+	require.NoError(t, err)
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	checkpoint := filepath.Join(dir, "checkpoint")
+
+	content := "0123456789abcdefghij"
+	getFile := func() string {
+		var buf bytes.Buffer
+		require.NoError(t, c.GetFile("data", commit.ID, "/file", 0, 0, &buf))
+		return buf.String()
+	}
+
+	// the upload is interrupted after two chunks
+	n, err := c.PutFileResumable("data", commit.ID, "/file",
+		failingReader{strings.NewReader(content[:10])}, checkpoint, 4)
+	require.YesError(t, err)
+	require.Equal(t, 8, n)
+	require.Equal(t, content[:8], getFile())
+
+	// a third chunk is uploaded but not recorded in the checkpoint
+	_, err = c.PutFile("data", commit.ID, "/file", strings.NewReader(content[8:12]))
+	require.NoError(t, err)
+
+	// resuming with different data fails
+	_, err = c.PutFileResumable("data", commit.ID, "/file",
+		strings.NewReader("x"+content[1:]), checkpoint, 4)
+	require.YesError(t, err)
+	_, err = c.PutFileResumable("data", commit.ID, "/file",
+		strings.NewReader(content), checkpoint, 8)
+	require.YesError(t, err)
+
+	// resuming skips the chunks that were uploaded
+	n, err = c.PutFileResumable("data", commit.ID, "/file",
+		strings.NewReader(content), checkpoint, 4)
+	require.NoError(t, err)
+	require.Equal(t, 8, n)
+	require.Equal(t, content, getFile())
+	_, err = os.Stat(checkpoint)
+	require.True(t, os.IsNotExist(err))
+
+	// without a checkpoint, the file is overwritten
+	n, err = c.PutFileResumable("data", commit.ID, "/file",
+		strings.NewReader("foo"), checkpoint, 0)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.Equal(t, "foo", getFile())
+}
+
+func TestGetFileParallel(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	content := strings.Repeat("0123456789", 1000)
+	_, err = c.PutFile("data", "master", "/file", strings.NewReader(content))
+	require.NoError(t, err)
+	_, err = c.PutFile("data", "master", "/empty", strings.NewReader(""))
+	require.NoError(t, err)
+
+	f, err := ioutil.TempFile("", "file")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	for _, parallelism := range []int{0, 1, 3, 7} {
+		require.NoError(t, f.Truncate(0))
+		require.NoError(t, c.GetFileParallel("data", "master", "/file", parallelism, f))
+		data, err := ioutil.ReadFile(f.Name())
+		require.NoError(t, err)
+		require.Equal(t, content, string(data))
+	}
+
+	// more ranges than bytes
+	_, err = c.PutFile("data", "master", "/small", strings.NewReader("foo"))
+	require.NoError(t, err)
+	require.NoError(t, f.Truncate(0))
+	require.NoError(t, c.GetFileParallel("data", "master", "/small", 10, f))
+	data, err := ioutil.ReadFile(f.Name())
+	require.NoError(t, err)
+	require.Equal(t, "foo", string(data))
+
+	require.NoError(t, f.Truncate(0))
+	require.NoError(t, c.GetFileParallel("data", "master", "/empty", 0, f))
+	data, err = ioutil.ReadFile(f.Name())
+	require.NoError(t, err)
+	require.Equal(t, 0, len(data))
+
+	require.YesError(t, c.GetFileParallel("data", "master", "/missing", 0, f))
+	require.YesError(t, c.GetFileParallel("data", "master", "/", 0, f))
+}
+
+func TestGetFileReaderAt(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	// the file spans several ranges of sequential reads
+	content := strings.Repeat("0123456789", client.FileReaderChunkSize/4)
+	_, err = c.PutFile("data", "master", "/file", strings.NewReader(content))
+	require.NoError(t, err)
+
+	r, err := c.GetFileReaderAt("data", "master", "/file")
+	require.NoError(t, err)
+	defer r.Close()
+	require.Equal(t, int64(len(content)), r.Size())
+	// reads are from the commit that the branch pointed to
+	_, err = c.PutFileOverwrite("data", "master", "/file", strings.NewReader("foo"), 0)
+	require.NoError(t, err)
+
+	buf := make([]byte, 4)
+	n, err := r.ReadAt(buf, 3)
+	require.NoError(t, err)
+	require.Equal(t, "3456", string(buf[:n]))
+	n, err = r.ReadAt(buf, int64(len(content)-2))
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, "89", string(buf[:n]))
+	_, err = r.ReadAt(buf, int64(len(content)))
+	require.Equal(t, io.EOF, err)
+	var eg errgroup.Group
+	for i := 0; i < 10; i++ {
+		off := int64(i * 1001)
+		eg.Go(func() error {
+			buf := make([]byte, 10)
+			if _, err := r.ReadAt(buf, off); err != nil {
+				return err
+			}
+			if string(buf) != content[off:off+10] {
+				return fmt.Errorf("expected %q at %d, but got %q", content[off:off+10], off, buf)
+			}
+			return nil
+		})
+	}
+	require.NoError(t, eg.Wait())
+
+	offset, err := r.Seek(-5, io.SeekEnd)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)-5), offset)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "56789", string(data))
+	_, err = r.Seek(7, io.SeekStart)
+	require.NoError(t, err)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(t, err)
+	require.Equal(t, "7890", string(buf))
+	offset, err = r.Seek(2, io.SeekCurrent)
+	require.NoError(t, err)
+	require.Equal(t, int64(13), offset)
+	data, err = ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, content[13:], string(data))
+	_, err = r.Seek(-1, io.SeekStart)
+	require.YesError(t, err)
+
+	// a zip file's directory is at its end, and its files are read with ReadAt
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	w, err := zw.Create("a.txt")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("foo"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	_, err = c.PutFile("data", "master", "/file.zip", &zipped)
+	require.NoError(t, err)
+	zipReader, err := c.GetFileReaderAt("data", "master", "/file.zip")
+	require.NoError(t, err)
+	zr, err := zip.NewReader(zipReader, zipReader.Size())
+	require.NoError(t, err)
+	require.Equal(t, 1, len(zr.File))
+	rc, err := zr.File[0].Open()
+	require.NoError(t, err)
+	data, err = ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, "foo", string(data))
+
+	_, err = c.GetFileReaderAt("data", "master", "/missing")
+	require.YesError(t, err)
+	_, err = c.GetFileReaderAt("data", "master", "/")
+	require.YesError(t, err)
+}
+
+func TestGetFileVerified(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	_, err = c.PutFile("data", "master", "/file", strings.NewReader("foo"))
+	require.NoError(t, err)
+
+	fileInfo, err := c.InspectFile("data", "master", "/file")
+	require.NoError(t, err)
+	sha256Sum := sha256.Sum256([]byte("foo"))
+	require.Equal(t, sha256Sum[:], fileInfo.ContentSha256)
+	md5Sum := md5.Sum([]byte("foo"))
+	require.Equal(t, md5Sum[:], fileInfo.ContentMd5)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.GetFileVerified("data", "master", "/file", &buf))
+	require.Equal(t, "foo", buf.String())
+
+	require.YesError(t, c.GetFileVerified("data", "master", "/missing", &buf))
+	require.YesError(t, c.GetFileVerified("data", "master", "/", &buf))
+}
+
+func TestMoveFile(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	commit, err := c.StartCommit("data", "master")
+	require.NoError(t, err)
+	_, err = c.PutFile("data", commit.ID, "/dir/a", strings.NewReader("a"))
+	require.NoError(t, err)
+	_, err = c.PutFile("data", commit.ID, "/dir/sub/b", strings.NewReader("b"))
+	require.NoError(t, err)
+	_, err = c.PutFile("data", commit.ID, "/file", strings.NewReader("file"))
+	require.NoError(t, err)
+	_, err = c.PutFile("data", commit.ID, "/moved/old", strings.NewReader("old"))
+	require.NoError(t, err)
+
+	// move a directory (overwriting the one at the destination) and a file
+	require.NoError(t, c.MoveFile("data", "master", "/dir", "/moved"))
+	require.NoError(t, c.MoveFile("data", commit.ID, "file", "renamed"))
+	require.YesError(t, c.MoveFile("data", commit.ID, "/moved", "/moved/sub"))
+	require.YesError(t, c.MoveFile("data", commit.ID, "/moved/sub", "/"))
+	require.NoError(t, c.FinishCommit("data", commit.ID))
+
+	var paths []string
+	require.NoError(t, c.Walk("data", commit.ID, "/", func(fileInfo *pfs.FileInfo) error {
+		if fileInfo.FileType == pfs.FileType_FILE {
+			paths = append(paths, fileInfo.File.Path)
+		}
+		return nil
+	}))
+	require.Equal(t, []string{"/moved/a", "/moved/sub/b", "/renamed"}, paths)
+	var buf bytes.Buffer
+	require.NoError(t, c.GetFile("data", commit.ID, "/moved/sub/b", 0, 0, &buf))
+	require.Equal(t, "b", buf.String())
+
+	// files can't be moved in finished commits
+	require.YesError(t, c.MoveFile("data", commit.ID, "/renamed", "/file"))
+}
+
+func TestProgress(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	var progress []client.Progress
+	pc := c.WithProgress(func(p client.Progress) {
+		progress = append(progress, p)
+	})
+	last := func() client.Progress {
+		require.True(t, len(progress) > 0)
+		p := progress[len(progress)-1]
+		progress = nil
+		return p
+	}
+	content := strings.Repeat("0123456789", 1000)
+
+	_, err = pc.PutFile("data", "master", "/a", strings.NewReader(content))
+	require.NoError(t, err)
+	require.Equal(t, client.Progress{Path: "/a", Bytes: 10000, TotalBytes: 10000, FilesCompleted: 1}, last())
+
+	// the size of a stream isn't known
+	w, err := pc.PutFileWriter("data", "master", "/b")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("foo"))
+	require.NoError(t, err)
+	require.Equal(t, client.Progress{Path: "/b", Bytes: 3, TotalBytes: -1, FilesCompleted: 1}, last())
+	require.NoError(t, w.Close())
+	require.Equal(t, client.Progress{Path: "/b", Bytes: 3, TotalBytes: -1, FilesCompleted: 2}, last())
+
+	var buf bytes.Buffer
+	require.NoError(t, pc.GetFile("data", "master", "/a", 5000, 0, &buf))
+	require.Equal(t, client.Progress{Path: "/a", Bytes: 5000, TotalBytes: 5000, FilesCompleted: 3}, last())
+
+	f, err := ioutil.TempFile("", "file")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	require.NoError(t, pc.GetFileParallel("data", "master", "/a", 4, f))
+	require.Equal(t, 5, len(progress)) // one per range, and one when finished
+	require.Equal(t, client.Progress{Path: "/a", Bytes: 10000, TotalBytes: 10000, FilesCompleted: 4}, last())
+
+	// failed transfers don't complete
+	require.YesError(t, pc.GetFile("data", "master", "/missing", 0, 0, &buf))
+	require.Equal(t, 0, len(progress))
+
+	// clients without WithProgress don't report progress
+	_, err = c.PutFile("data", "master", "/c", strings.NewReader("foo"))
+	require.NoError(t, err)
+	require.Equal(t, 0, len(progress))
+}
+
+func TestPutFileDir(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+
+	dir, err := ioutil.TempDir("", "dir")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, p := range []string{"a", "b.tmp", "keep.tmp", "sub/c", "sub/d.o", "build/e", "sub/build/f"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(p)), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, p), []byte(p), 0644))
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, client.PachIgnoreFile),
+		[]byte("# comment\n*.tmp\n!keep.tmp\nbuild/\n"), 0644))
+
+	files := func(commitID string) []string {
+		fileInfos, err := c.GlobFile("data", commitID, "**")
+		require.NoError(t, err)
+		var paths []string
+		for _, fi := range fileInfos {
+			if fi.FileType == pfs.FileType_FILE {
+				paths = append(paths, fi.File.Path)
+			}
+		}
+		sort.Strings(paths)
+		return paths
+	}
+
+	commit, err := c.StartCommit("data", "master")
+	require.NoError(t, err)
+	require.NoError(t, c.PutFileDir(dir, "data", commit.ID, "/prefix", nil))
+	require.Equal(t, []string{"/prefix/a", "/prefix/keep.tmp", "/prefix/sub/c", "/prefix/sub/d.o"}, files(commit.ID))
+	var buf bytes.Buffer
+	require.NoError(t, c.GetFile("data", commit.ID, "/prefix/sub/c", 0, 0, &buf))
+	require.Equal(t, "sub/c", buf.String())
+	require.NoError(t, c.FinishCommit("data", commit.ID))
+
+	commit, err = c.StartCommit("data", "master")
+	require.NoError(t, err)
+	require.NoError(t, c.DeleteFile("data", commit.ID, "/prefix"))
+	require.NoError(t, c.PutFileDir(dir, "data", commit.ID, "/", &client.PutFileDirOptions{
+		Include:     []string{"sub/*", "a"},
+		Exclude:     []string{"*.o"},
+		Parallelism: 1,
+	}))
+	require.Equal(t, []string{"/a", "/sub/c"}, files(commit.ID))
+
+	// syncing only uploads the changed files
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("changed"), 0644))
+	var uploaded []string
+	pc := c.WithProgress(func(p client.Progress) {
+		if p.Bytes == p.TotalBytes {
+			uploaded = append(uploaded, p.Path)
+		}
+	})
+	require.NoError(t, pc.PutFileDir(dir, "data", commit.ID, "/", &client.PutFileDirOptions{
+		Include: []string{"sub/*", "a"},
+		Exclude: []string{"*.o"},
+		Sync:    true,
+	}))
+	require.Equal(t, "/a", uploaded[len(uploaded)-1])
+	for _, p := range uploaded {
+		require.Equal(t, "/a", p)
+	}
+	buf.Reset()
+	require.NoError(t, c.GetFile("data", commit.ID, "/a", 0, 0, &buf))
+	require.Equal(t, "changed", buf.String())
+
+	// syncing to a prefix that doesn't exist yet uploads everything, but
+	// other errors are returned
+	require.NoError(t, c.PutFileDir(dir, "data", commit.ID, "/new", &client.PutFileDirOptions{Sync: true}))
+	require.Equal(t, []string{"/a", "/new/a", "/new/keep.tmp", "/new/sub/c", "/new/sub/d.o", "/sub/c"}, files(commit.ID))
+	require.YesError(t, c.PutFileDir(dir, "missing", commit.ID, "/", &client.PutFileDirOptions{Sync: true}))
+
+	require.YesError(t, c.PutFileDir(filepath.Join(dir, "missing"), "data", commit.ID, "/", nil))
+	require.YesError(t, c.PutFileDir(dir, "missing", commit.ID, "/", nil))
+}
+
+func TestSyncDir(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+
+	tmp, err := ioutil.TempDir("", "sync")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+	dirA, dirB := filepath.Join(tmp, "a"), filepath.Join(tmp, "b")
+	write := func(dir, p, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(p)), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, p), []byte(content), 0644))
+	}
+	read := func(dir, p string) string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, p))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	// push a new directory
+	write(dirA, "a", "foo")
+	write(dirA, "sub/b", "bar")
+	result, err := c.SyncDir(dirA, "data", "master", "/dataset", client.SyncPush)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "sub/b"}, result.Updated)
+	require.Equal(t, 0, len(result.Deleted))
+	require.NotNil(t, result.Commit)
+	var buf bytes.Buffer
+	require.NoError(t, c.GetFile("data", "master", "/dataset/sub/b", 0, 0, &buf))
+	require.Equal(t, "bar", buf.String())
+
+	// pull it into another directory, and push changes from there
+	result, err = c.SyncDir(dirB, "data", "master", "/dataset", client.SyncPull)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "sub/b"}, result.Updated)
+	require.Equal(t, "foo", read(dirB, "a"))
+	write(dirB, "a", "changed")
+	write(dirB, "c", "baz")
+	require.NoError(t, os.Remove(filepath.Join(dirB, "sub/b")))
+	result, err = c.SyncDir(dirB, "data", "master", "/dataset", client.SyncPush)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "c"}, result.Updated)
+	require.Equal(t, []string{"sub/b"}, result.Deleted)
+	_, err = c.InspectFile("data", "master", "/dataset/sub/b")
+	require.YesError(t, err)
+
+	// conflicting changes are detected
+	write(dirA, "a", "conflict")
+	_, err = c.SyncDir(dirA, "data", "master", "/dataset", client.SyncPull)
+	conflict, ok := err.(*client.SyncConflictError)
+	require.True(t, ok)
+	require.Equal(t, []string{"a"}, conflict.Paths)
+	_, err = c.SyncDir(dirA, "data", "master", "/dataset", client.SyncPush)
+	conflict, ok = err.(*client.SyncConflictError)
+	require.True(t, ok)
+	require.Equal(t, []string{"a", "c", "sub/b"}, conflict.Paths)
+
+	// once the conflict is resolved, remote changes (including deletions) are
+	// pulled
+	write(dirA, "a", "foo")
+	result, err = c.SyncDir(dirA, "data", "master", "/dataset", client.SyncPull)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "c"}, result.Updated)
+	require.Equal(t, []string{"sub/b"}, result.Deleted)
+	require.Equal(t, "changed", read(dirA, "a"))
+	require.Equal(t, "baz", read(dirA, "c"))
+	_, err = os.Stat(filepath.Join(dirA, "sub/b"))
+	require.True(t, os.IsNotExist(err))
+
+	// syncing without changes does nothing
+	result, err = c.SyncDir(dirA, "data", "master", "/dataset", client.SyncPush)
+	require.NoError(t, err)
+	require.Nil(t, result.Commit)
+	require.Equal(t, 0, len(result.Updated)+len(result.Deleted))
+
+	_, err = c.SyncDir(dirA, "data", "master", "/other", client.SyncPull)
+	require.YesError(t, err)
+}
+
+func TestPutFileURLs(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		n := requests[r.URL.Path]
+		mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/flaky" && n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, "content of %s", r.URL.Path)
+	}))
+	defer web.Close()
+
+	urls := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		urls[fmt.Sprintf("/file%d", i)] = fmt.Sprintf("%s/object%d", web.URL, i)
+	}
+	urls["/flaky"] = web.URL + "/flaky"
+	commit, err := c.StartCommit("data", "master")
+	require.NoError(t, err)
+	require.NoError(t, c.PutFileURLs("data", commit.ID, urls, &client.PutFileURLOptions{
+		Parallelism: 4,
+		Retries:     1,
+		Overwrite:   true,
+		Headers:     map[string]string{"Authorization": "Bearer secret"},
+	}))
+	for i := 0; i < 20; i++ {
+		var buf bytes.Buffer
+		require.NoError(t, c.GetFile("data", commit.ID, fmt.Sprintf("/file%d", i), 0, 0, &buf))
+		require.Equal(t, fmt.Sprintf("content of /object%d", i), buf.String())
+	}
+	var buf bytes.Buffer
+	require.NoError(t, c.GetFile("data", commit.ID, "/flaky", 0, 0, &buf))
+	require.Equal(t, "content of /flaky", buf.String())
+	require.Equal(t, 2, requests["/flaky"])
+
+	// failures are returned once retries are exhausted
+	err = c.PutFileURLs("data", commit.ID, map[string]string{"/a": web.URL + "/a"}, nil)
+	require.True(t, err != nil && strings.Contains(err.Error(), "401"))
+}
+
+func TestMetadata(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+
+	commit1, err := c.StartCommitWithMetadata("data", "master", map[string]string{"team": "a", "stage": "raw"})
+	require.NoError(t, err)
+	_, err = c.PutFileWithMetadata("data", commit1.ID, "/file", strings.NewReader("foo"), map[string]string{"owner": "x"})
+	require.NoError(t, err)
+	_, err = c.PutFileWithMetadata("data", commit1.ID, "/file", strings.NewReader("bar"), map[string]string{"format": "csv"})
+	require.NoError(t, err)
+	require.NoError(t, c.FinishCommitWithMetadata("data", commit1.ID, map[string]string{"stage": "clean"}))
+	commitInfo, err := c.InspectCommit("data", commit1.ID)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"team": "a", "stage": "clean"}, commitInfo.Metadata)
+	fileInfo, err := c.InspectFile("data", commit1.ID, "/file")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"owner": "x", "format": "csv"}, fileInfo.Metadata)
+
+	// overwriting a file replaces its metadata
+	commit2, err := c.StartCommitWithMetadata("data", "master", map[string]string{"team": "b"})
+	require.NoError(t, err)
+	_, err = c.PutFileOverwrite("data", commit2.ID, "/file", strings.NewReader("baz"), 0)
+	require.NoError(t, err)
+	require.NoError(t, c.FinishCommit("data", commit2.ID))
+	fileInfo, err = c.InspectFile("data", commit2.ID, "/file")
+	require.NoError(t, err)
+	require.Equal(t, 0, len(fileInfo.Metadata))
+	fileInfo, err = c.InspectFile("data", commit1.ID, "/file")
+	require.NoError(t, err)
+	require.Equal(t, "x", fileInfo.Metadata["owner"])
+
+	// commits can be filtered by metadata
+	commitInfos, err := c.ListCommitWithMetadata("data", "", "", 0, map[string]string{"team": "a"})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(commitInfos))
+	require.Equal(t, commit1.ID, commitInfos[0].Commit.ID)
+	commitInfos, err = c.ListCommitWithMetadata("data", "master", "", 1, map[string]string{"stage": "clean"})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(commitInfos))
+	require.Equal(t, commit1.ID, commitInfos[0].Commit.ID)
+	commitInfos, err = c.ListCommitWithMetadata("data", "", "", 0, map[string]string{"team": "c"})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(commitInfos))
+}
+
+func TestGlobFileRegex(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	commit, err := c.StartCommit("data", "master")
+	require.NoError(t, err)
+	for _, p := range []string{"/train/a.csv", "/train/sub/b.csv", "/test/c.csv", "/test/d.txt", "/other/e.csv"} {
+		_, err = c.PutFile("data", commit.ID, p, strings.NewReader(p))
+		require.NoError(t, err)
+	}
+	require.NoError(t, c.FinishCommit("data", commit.ID))
+	paths := func(fileInfos []*pfs.FileInfo, err error) []string {
+		require.NoError(t, err)
+		var result []string
+		for _, fileInfo := range fileInfos {
+			result = append(result, fileInfo.File.Path)
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	expected := []string{"/test/c.csv", "/train/a.csv", "/train/sub/b.csv"}
+	require.Equal(t, expected, paths(c.GlobFile("data", "master", "/{train,test}/**.csv")))
+	require.Equal(t, expected, paths(c.GlobFileRegex("data", "master", `/(train|test)/.*\.csv`)))
+	require.Equal(t, []string{"/test/c.csv", "/train/a.csv"}, paths(c.GlobFileRegex("data", "master", `/(train|test)/[^/]*\.csv`)))
+	_, err = c.GlobFileRegex("data", "master", "(")
+	require.YesError(t, err)
+}
+
+func TestListFileFiltered(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	commit1, err := c.StartCommit("data", "master")
+	require.NoError(t, err)
+	_, err = c.PutFile("data", commit1.ID, "/small", strings.NewReader("a"))
+	require.NoError(t, err)
+	_, err = c.PutFileWithMetadata("data", commit1.ID, "/big", strings.NewReader(strings.Repeat("a", 100)), map[string]string{"label": "x"})
+	require.NoError(t, err)
+	_, err = c.PutFile("data", commit1.ID, "/unchanged", strings.NewReader("foo"))
+	require.NoError(t, err)
+	require.NoError(t, c.FinishCommit("data", commit1.ID))
+	commit2, err := c.StartCommit("data", "master")
+	require.NoError(t, err)
+	_, err = c.PutFile("data", commit2.ID, "/small", strings.NewReader("b"))
+	require.NoError(t, err)
+	_, err = c.PutFile("data", commit2.ID, "/new", strings.NewReader("new"))
+	require.NoError(t, err)
+	require.NoError(t, c.FinishCommit("data", commit2.ID))
+
+	paths := func(filter *pfs.FileFilter) []string {
+		fileInfos, err := c.ListFileFiltered("data", "master", "/", filter)
+		require.NoError(t, err)
+		var result []string
+		for _, fileInfo := range fileInfos {
+			result = append(result, fileInfo.File.Path)
+		}
+		sort.Strings(result)
+		return result
+	}
+	require.Equal(t, []string{"/big", "/new", "/small", "/unchanged"}, paths(nil))
+	require.Equal(t, []string{"/big"}, paths(&pfs.FileFilter{MinSizeBytes: 10}))
+	require.Equal(t, []string{"/small"}, paths(&pfs.FileFilter{MaxSizeBytes: 2}))
+	require.Equal(t, []string{"/big"}, paths(&pfs.FileFilter{Metadata: map[string]string{"label": "x"}}))
+	require.Equal(t, []string{"/new", "/small"}, paths(&pfs.FileFilter{ModifiedSince: client.NewCommit("data", commit1.ID)}))
+	require.Equal(t, []string{"/small"}, paths(&pfs.FileFilter{ModifiedSince: client.NewCommit("data", commit1.ID), MaxSizeBytes: 2}))
+}
+
+func TestListFileHistory(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	var commitIDs []string
+	for _, content := range []string{"a", "a", "b", "b"} {
+		commit, err := c.StartCommit("data", "master")
+		require.NoError(t, err)
+		_, err = c.PutFileOverwrite("data", commit.ID, "/file", strings.NewReader(content), 0)
+		require.NoError(t, err)
+		require.NoError(t, c.FinishCommit("data", commit.ID))
+		commitIDs = append(commitIDs, commit.ID)
+	}
+
+	// Each version is given as of the first commit that has it
+	fileInfos, err := c.ListFileHistory("data", "master", "/", -1)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(fileInfos))
+	require.Equal(t, commitIDs[2], fileInfos[0].File.Commit.ID)
+	require.Equal(t, commitIDs[0], fileInfos[1].File.Commit.ID)
+	fileInfos, err = c.ListFileHistory("data", "master", "/file", 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(fileInfos))
+	require.Equal(t, commitIDs[2], fileInfos[0].File.Commit.ID)
+	fileInfos, err = c.ListFileHistory("data", commitIDs[1], "/file", -1)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(fileInfos))
+	require.Equal(t, commitIDs[0], fileInfos[0].File.Commit.ID)
+}
+
+func TestListFileIter(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	commit, err := c.StartCommit("data", "master")
+	require.NoError(t, err)
+	var expected []string
+	for i := 0; i < 25; i++ {
+		p := fmt.Sprintf("/dir/file%02d", i)
+		_, err = c.PutFile("data", commit.ID, p, strings.NewReader(p))
+		require.NoError(t, err)
+		expected = append(expected, p)
+	}
+	require.NoError(t, c.FinishCommit("data", commit.ID))
+
+	for _, pageSize := range []int64{0, 1, 5, 7, 25} {
+		var paths []string
+		it := c.ListFileIter("data", commit.ID, "/dir", pageSize)
+		for it.Next() {
+			paths = append(paths, it.FileInfo().File.Path)
+		}
+		require.NoError(t, it.Err())
+		require.Equal(t, expected, paths)
+	}
+
+	it := c.ListFileIter("data", commit.ID, "/missing", 0)
+	require.False(t, it.Next())
+	require.YesError(t, it.Err())
+}
+
+func TestSquashCommit(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+
+	var commits []*pfs.Commit
+	for i := 0; i < 4; i++ {
+		commit, err := c.StartCommit("data", "master")
+		require.NoError(t, err)
+		_, err = c.PutFile("data", commit.ID, fmt.Sprintf("/file%d", i), strings.NewReader("foo"))
+		require.NoError(t, err)
+		require.NoError(t, c.FinishCommit("data", commit.ID))
+		commits = append(commits, commit)
+	}
+	// 'from' must be an ancestor of 'to'
+	require.YesError(t, c.SquashCommit("data", commits[3].ID, commits[1].ID))
+
+	require.NoError(t, c.SquashCommit("data", commits[1].ID, "master"))
+	commitInfos, err := c.ListCommit("data", "master", "", 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(commitInfos))
+	require.Equal(t, commits[3].ID, commitInfos[0].Commit.ID)
+	require.Equal(t, commits[0].ID, commitInfos[0].ParentCommit.ID)
+	require.Equal(t, commits[0].ID, commitInfos[1].Commit.ID)
+	fileInfos, err := c.ListFile("data", "master", "/")
+	require.NoError(t, err)
+	require.Equal(t, 4, len(fileInfos))
+	_, err = c.InspectCommit("data", commits[2].ID)
+	require.YesError(t, err)
+}
+
+func TestBranchProtection(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	_, err = c.PutFile("data", "master", "/file", strings.NewReader("foo"))
+	require.NoError(t, err)
+	head, err := c.InspectCommit("data", "master")
+	require.NoError(t, err)
+
+	require.NoError(t, c.SetBranchProtection("data", "master", &pfs.BranchProtection{
+		NoDirectWrites: true,
+		NoDeleteCommit: true,
+	}))
+	branchInfo, err := c.InspectBranch("data", "master")
+	require.NoError(t, err)
+	require.True(t, branchInfo.Protection.NoDirectWrites)
+	_, err = c.PutFile("data", "master", "/file", strings.NewReader("bar"))
+	require.YesError(t, err)
+	_, err = c.StartCommit("data", "master")
+	require.YesError(t, err)
+	require.YesError(t, c.DeleteFile("data", "master", "/file"))
+	require.YesError(t, c.DeleteCommit("data", head.Commit.ID))
+
+	// an open head of a protected branch can't be written to by its ID
+	open, err := c.StartCommit("data", "staging")
+	require.NoError(t, err)
+	require.NoError(t, c.SetBranchProtection("data", "staging", &pfs.BranchProtection{NoDirectWrites: true}))
+	_, err = c.PutFile("data", open.ID, "/file", strings.NewReader("bar"))
+	require.YesError(t, err)
+
+	// other branches can still be written to
+	_, err = c.PutFile("data", "dev", "/file", strings.NewReader("bar"))
+	require.NoError(t, err)
+	// but the protected branch can't be re-pointed at them, or deleted
+	require.YesError(t, c.CreateBranch("data", "master", "dev", nil))
+	require.YesError(t, c.DeleteBranch("data", "master", false))
+
+	require.NoError(t, c.SetBranchProtection("data", "master", nil))
+	_, err = c.PutFile("data", "master", "/file", strings.NewReader("bar"))
+	require.NoError(t, err)
+}
+
+func TestRepoQuota(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	require.NoError(t, c.SetRepoQuota("data", &pfs.RepoQuota{MaxBytes: 5, MaxFiles: 2}))
+	_, err = c.PutFile("data", "master", "/a", strings.NewReader("foo"))
+	require.NoError(t, err)
+	repoInfo, err := c.InspectRepo("data")
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), repoInfo.Quota.MaxBytes)
+	require.Equal(t, uint64(3), repoInfo.SizeBytes)
+	require.Equal(t, uint64(1), repoInfo.FileCount)
+
+	// writes that would take the repo over its quota fail
+	commit, err := c.StartCommit("data", "master")
+	require.NoError(t, err)
+	_, err = c.PutFile("data", commit.ID, "/b", strings.NewReader("bar"))
+	require.NoError(t, err)
+	require.YesError(t, c.FinishCommit("data", commit.ID))
+	require.NoError(t, c.DeleteFile("data", commit.ID, "/b"))
+	_, err = c.PutFile("data", commit.ID, "/b", strings.NewReader("b"))
+	require.NoError(t, err)
+	_, err = c.PutFile("data", commit.ID, "/c", strings.NewReader("c"))
+	require.NoError(t, err)
+	require.YesError(t, c.FinishCommit("data", commit.ID))
+	require.NoError(t, c.DeleteFile("data", commit.ID, "/c"))
+	require.NoError(t, c.FinishCommit("data", commit.ID))
+
+	require.NoError(t, c.SetRepoQuota("data", nil))
+	repoInfo, err = c.InspectRepo("data")
+	require.NoError(t, err)
+	require.Nil(t, repoInfo.Quota)
+}
+
+func TestRepoSchema(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	_, err = c.PutFile("data", "master", "/old.csv", strings.NewReader("not,the,header\n"))
+	require.NoError(t, err)
+	require.YesError(t, c.SetRepoSchema("data", &pfs.RepoSchema{Glob: "*.csv"}))
+	schema := &pfs.RepoSchema{Glob: "*.csv", Csv: &pfs.CSVSchema{Columns: []string{"id", "name"}}}
+	require.NoError(t, c.SetRepoSchema("data", schema))
+	repoInfo, err := c.InspectRepo("data")
+	require.NoError(t, err)
+	require.Equal(t, []string{"id", "name"}, repoInfo.Schema.Csv.Columns)
+
+	// Files that don't match the schema are rejected, but unchanged files and
+	// files that the schema doesn't apply to aren't validated
+	_, err = c.PutFile("data", "master", "/a.csv", strings.NewReader("id,name\n1,a\n"))
+	require.NoError(t, err)
+	_, err = c.PutFile("data", "master", "/a.txt", strings.NewReader("foo"))
+	require.NoError(t, err)
+	_, err = c.PutFile("data", "master", "/b.csv", strings.NewReader("id,name\n1\n"))
+	require.YesError(t, err)
+	require.Matches(t, "doesn.t match the schema of repo data", err.Error())
+
+	commit, err := c.StartCommit("data", "master")
+	require.NoError(t, err)
+	_, err = c.PutFileOverwrite("data", commit.ID, "/a.csv", strings.NewReader("name,id\n"), 0)
+	require.NoError(t, err)
+	err = c.FinishCommit("data", commit.ID)
+	require.Matches(t, "doesn.t match the schema of repo data", err.Error())
+	// The commit is still open, so the file can be fixed
+	_, err = c.PutFileOverwrite("data", commit.ID, "/a.csv", strings.NewReader("id,name\n2,b\n"), 0)
+	require.NoError(t, err)
+	require.NoError(t, c.FinishCommit("data", commit.ID))
+
+	// Flagged files are recorded in the commit
+	schema.Action = pfs.SchemaAction_FLAG
+	require.NoError(t, c.SetRepoSchema("data", schema))
+	_, err = c.PutFile("data", "master", "/c.csv", strings.NewReader("id\n"))
+	require.NoError(t, err)
+	commitInfo, err := c.InspectCommit("data", "master")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(commitInfo.SchemaViolations))
+	require.Equal(t, "/c.csv", commitInfo.SchemaViolations[0].Path)
+
+	require.NoError(t, c.SetRepoSchema("data", nil))
+	repoInfo, err = c.InspectRepo("data")
+	require.NoError(t, err)
+	require.Nil(t, repoInfo.Schema)
+}
+
+func TestDeleteBranchCascade(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("in"))
+	require.NoError(t, c.CreateRepo("out"))
+	require.NoError(t, c.CreateBranch("in", "master", "", nil))
+	require.NoError(t, c.CreateBranch("out", "master", "", []*pfs.Branch{client.NewBranch("in", "master")}))
+
+	require.NoError(t, c.DeleteBranchWithOptions("in", "master", &client.DeleteBranchOptions{Cascade: true}))
+	_, err = c.InspectBranch("in", "master")
+	require.YesError(t, err)
+	_, err = c.InspectBranch("out", "master")
+	require.YesError(t, err)
+}
+
+func TestGarbageCollectStatus(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	status, err := c.InspectGarbageCollect()
+	require.NoError(t, err)
+	require.Equal(t, pps.GarbageCollectState_GC_NEVER_RUN, status.State)
+
+	require.NoError(t, c.SetGarbageCollectSchedule("0 3 * * *", 0))
+	status, err = c.StartGarbageCollect(0)
+	require.NoError(t, err)
+	require.Equal(t, pps.GarbageCollectState_GC_SUCCESS, status.State)
+	require.NotNil(t, status.Finished)
+	require.Equal(t, "0 3 * * *", status.Schedule.CronSpec)
+	_, err = c.PauseGarbageCollect()
+	require.YesError(t, err)
+
+	require.NoError(t, c.SetGarbageCollectSchedule("", 0))
+	status, err = c.InspectGarbageCollect()
+	require.NoError(t, err)
+	require.Nil(t, status.Schedule)
+}
+
+func TestReplication(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	target := &pps.ReplicationTarget{PachdAddress: "dr:30650", AuthToken: "secret"}
+	branches := []*pfs.Branch{client.NewBranch("in", "master")}
+	require.NoError(t, c.CreateReplication("dr", target, branches, false))
+	require.YesError(t, c.CreateReplication("dr", target, branches, false))
+	require.YesError(t, c.CreateReplication("other", target, branches, true))
+	require.YesError(t, c.CreateReplication("other", target, nil, false))
+
+	// the target's auth token is never returned
+	replicationInfo, err := c.InspectReplication("dr")
+	require.NoError(t, err)
+	require.Equal(t, "dr:30650", replicationInfo.Target.PachdAddress)
+	require.Equal(t, "", replicationInfo.Target.AuthToken)
+	require.Equal(t, 1, len(replicationInfo.Branches))
+
+	branches = append(branches, client.NewBranch("out", "master"))
+	require.NoError(t, c.CreateReplication("dr", target, branches, true))
+	replicationInfos, err := c.ListReplication()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(replicationInfos))
+	require.Equal(t, 2, len(replicationInfos[0].Branches))
+	require.Equal(t, "", replicationInfos[0].Target.AuthToken)
+
+	require.NoError(t, c.DeleteReplication("dr"))
+	_, err = c.InspectReplication("dr")
+	require.YesError(t, err)
+	require.YesError(t, c.DeleteReplication("dr"))
+}
+
+func TestSchedulerLimits(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	status, err := c.InspectScheduler()
+	require.NoError(t, err)
+	require.Nil(t, status.Limits)
+
+	require.YesError(t, c.SetSchedulerLimits(&pps.SchedulerLimits{MaxCpu: -1}))
+	require.YesError(t, c.SetSchedulerLimits(&pps.SchedulerLimits{
+		PipelineMaxRunningJobs: map[string]int64{"out": -1},
+	}))
+	require.NoError(t, c.SetSchedulerLimits(&pps.SchedulerLimits{
+		MaxRunningJobs:         10,
+		MaxGpu:                 4,
+		PipelineMaxRunningJobs: map[string]int64{"out": 2},
+	}))
+	status, err = c.InspectScheduler()
+	require.NoError(t, err)
+	require.Equal(t, int64(10), status.Limits.MaxRunningJobs)
+	require.Equal(t, int64(4), status.Limits.MaxGpu)
+	require.Equal(t, int64(2), status.Limits.PipelineMaxRunningJobs["out"])
+
+	require.NoError(t, c.SetSchedulerLimits(nil))
+	status, err = c.InspectScheduler()
+	require.NoError(t, err)
+	require.Nil(t, status.Limits)
+}
+
+func TestValidatePipeline(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("in"))
+	request := &pps.CreatePipelineRequest{
+		Pipeline:  client.NewPipeline("out"),
+		Transform: &pps.Transform{Image: "ubuntu:18.04", Cmd: []string{"true"}},
+		Input:     client.NewPFSInput("in", "/*"),
+	}
+	issues, err := c.ValidatePipeline(request)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(issues))
+
+	request.Transform.Image = "ubuntu"
+	request.Input = client.NewCrossInput(client.NewPFSInput("in", "/[a"), client.NewPFSInput("missing", "/*"))
+	issues, err = c.ValidatePipeline(request)
+	require.NoError(t, err)
+	fields := make(map[string]pps.IssueSeverity)
+	for _, issue := range issues {
+		fields[issue.Field] = issue.Severity
+	}
+	require.Equal(t, 3, len(fields))
+	require.Equal(t, pps.IssueSeverity_ISSUE_WARNING, fields["transform.image"])
+	require.Equal(t, pps.IssueSeverity_ISSUE_ERROR, fields["input.pfs[in].glob"])
+	require.Equal(t, pps.IssueSeverity_ISSUE_ERROR, fields["input.pfs[missing].repo"])
+
+	// validating a pipeline doesn't create it
+	_, err = c.InspectPipeline("out")
+	require.YesError(t, err)
+}
+
+func TestApplyPipelines(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("in"))
+	spec := func(name, input string) *pps.CreatePipelineRequest {
+		return &pps.CreatePipelineRequest{
+			Pipeline:  client.NewPipeline(name),
+			Transform: &pps.Transform{Cmd: []string{"true"}},
+			Input:     client.NewPFSInput(input, "/*"),
+		}
+	}
+	changes, err := c.ApplyPipelines([]*pps.CreatePipelineRequest{spec("b", "a"), spec("a", "in")}, false, false)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(changes))
+	require.Equal(t, "a", changes[0].Pipeline.Name)
+	require.Equal(t, pps.PipelineChangeType_PIPELINE_CREATE, changes[0].Type)
+	require.Equal(t, "b", changes[1].Pipeline.Name)
+
+	// applying the same specs changes nothing
+	changes, err = c.ApplyPipelines([]*pps.CreatePipelineRequest{spec("a", "in"), spec("b", "a")}, false, false)
+	require.NoError(t, err)
+	require.Equal(t, pps.PipelineChangeType_PIPELINE_UNCHANGED, changes[0].Type)
+	require.Equal(t, pps.PipelineChangeType_PIPELINE_UNCHANGED, changes[1].Type)
+
+	// a failed apply is undone
+	updated := spec("a", "in")
+	updated.Description = "updated"
+	invalid := spec("d", "in")
+	invalid.Transform = nil
+	_, err = c.ApplyPipelines([]*pps.CreatePipelineRequest{updated, spec("c", "a"), invalid}, false, false)
+	require.YesError(t, err)
+	pipelineInfo, err := c.InspectPipeline("a")
+	require.NoError(t, err)
+	require.Equal(t, "", pipelineInfo.Description)
+	_, err = c.InspectPipeline("c")
+	require.YesError(t, err)
+
+	changes, err = c.ApplyPipelines([]*pps.CreatePipelineRequest{updated}, true, false)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(changes))
+	require.Equal(t, pps.PipelineChangeType_PIPELINE_UPDATE, changes[0].Type)
+	require.Equal(t, "b", changes[1].Pipeline.Name)
+	require.Equal(t, pps.PipelineChangeType_PIPELINE_DELETE, changes[1].Type)
+	pipelineInfos, err := c.ListPipeline()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(pipelineInfos))
+	require.Equal(t, "updated", pipelineInfos[0].Description)
+}
+
+func TestRollbackPipeline(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("in"))
+	for _, image := range []string{"opencv:1", "opencv:2"} {
+		require.NoError(t, c.CreatePipeline("edges", image, []string{"true"}, nil, nil, client.NewPFSInput("in", "/*"), "", image != "opencv:1"))
+	}
+	pipelineInfos, err := c.ListPipelineVersions("edges")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(pipelineInfos))
+	require.Equal(t, uint64(2), pipelineInfos[0].Version)
+	require.Equal(t, "opencv:2", pipelineInfos[0].Transform.Image)
+
+	require.YesError(t, c.RollbackPipeline("edges", 2, false))
+	require.YesError(t, c.RollbackPipeline("edges", 5, false))
+	require.NoError(t, c.RollbackPipeline("edges", 1, false))
+	pipelineInfo, err := c.InspectPipeline("edges")
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), pipelineInfo.Version)
+	require.Equal(t, "opencv:1", pipelineInfo.Transform.Image)
+	pipelineInfos, err = c.ListPipelineVersions("edges")
+	require.NoError(t, err)
+	require.Equal(t, 3, len(pipelineInfos))
+
+	require.NoError(t, c.DeletePipeline("edges", false))
+	_, err = c.ListPipelineVersions("edges")
+	require.YesError(t, err)
+}
+
+func TestGetCommitArchive(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	_, err = c.PutFile("data", "master", "/dir/bar", strings.NewReader("bar"))
+	require.NoError(t, err)
+	_, err = c.PutFile("data", "master", "/foo", strings.NewReader("foo"))
+	require.NoError(t, err)
+
+	for _, format := range []pfs.ArchiveFormat{pfs.ArchiveFormat_TAR_GZ, pfs.ArchiveFormat_ZIP} {
+		var archive bytes.Buffer
+		require.NoError(t, c.GetCommitArchive("data", "master", "", format, &archive))
+		require.Equal(t, map[string]string{
+			"dir/":    "",
+			"dir/bar": "bar",
+			"foo":     "foo",
+		}, readArchive(t, archive.Bytes(), format))
+		archive.Reset()
+		require.NoError(t, c.GetCommitArchive("data", "master", "/dir", format, &archive))
+		require.Equal(t, map[string]string{
+			"dir/":    "",
+			"dir/bar": "bar",
+		}, readArchive(t, archive.Bytes(), format))
+	}
+	require.YesError(t, c.GetCommitArchive("data", "master", "/missing", pfs.ArchiveFormat_ZIP, &bytes.Buffer{}))
+}
+
+func TestGetCommitManifest(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	_, err = c.PutFile("data", "master", "/dir/bar", strings.NewReader("bar"))
+	require.NoError(t, err)
+	_, err = c.PutFileWithMetadata("data", "master", "/foo", strings.NewReader("foo"), map[string]string{"owner": "qux"})
+	require.NoError(t, err)
+
+	for _, format := range []pfs.ManifestFormat{pfs.ManifestFormat_MANIFEST_JSON, pfs.ManifestFormat_MANIFEST_CSV} {
+		var buf bytes.Buffer
+		require.NoError(t, c.GetCommitManifest("data", "master", "", format, &buf))
+		entries, err := manifest.Read(&buf)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(entries))
+		require.Equal(t, "/dir/bar", entries[0].Path)
+		require.Equal(t, uint64(3), entries[0].SizeBytes)
+		require.Equal(t, "/foo", entries[1].Path)
+		require.Equal(t, "qux", entries[1].Metadata["owner"])
+		sum := sha256.Sum256([]byte("foo"))
+		require.Equal(t, hex.EncodeToString(sum[:]), entries[1].ContentSha256)
+	}
+
+	var before bytes.Buffer
+	require.NoError(t, c.GetCommitManifest("data", "master", "", pfs.ManifestFormat_MANIFEST_JSON, &before))
+	_, err = c.PutFile("data", "master", "/dir/bar", strings.NewReader("baz"))
+	require.NoError(t, err)
+	require.NoError(t, c.DeleteFile("data", "master", "/foo"))
+	var after bytes.Buffer
+	require.NoError(t, c.GetCommitManifest("data", "master", "/dir", pfs.ManifestFormat_MANIFEST_CSV, &after))
+	oldEntries, err := manifest.Read(&before)
+	require.NoError(t, err)
+	newEntries, err := manifest.Read(&after)
+	require.NoError(t, err)
+	changes, err := manifest.Diff(oldEntries, newEntries)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(changes))
+	require.Equal(t, manifest.Modified, changes[0].Type)
+	require.Equal(t, manifest.Removed, changes[1].Type)
+}
+
+// readArchive returns the entries of an archive returned by
+// GetCommitArchive, by name (directories' names end in "/")
+func readArchive(t *testing.T, data []byte, format pfs.ArchiveFormat) map[string]string {
+	entries := make(map[string]string)
+	if format == pfs.ArchiveFormat_ZIP {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+		for _, f := range zr.File {
+			r, err := f.Open()
+			require.NoError(t, err)
+			content, err := ioutil.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			entries[f.Name] = string(content)
+		}
+		return entries
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		entries[header.Name] = string(content)
+	}
+	return entries
+}
+
+func TestPutFileTar(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	c, err := server.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.CreateRepo("data"))
+	_, err = c.PutFileTar("data", "master", "/prefix", tarArchive(t, map[string]string{
+		"foo":           "foo",
+		"./dir/bar":     "bar",
+		"../escape/baz": "baz",
+	}, map[string]int64{"./dir/bar": 0755}), false)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.GetFile("data", "master", "/prefix/dir/bar", 0, 0, &buf))
+	require.Equal(t, "bar", buf.String())
+	fileInfo, err := c.InspectFile("data", "master", "/prefix/dir/bar")
+	require.NoError(t, err)
+	require.Equal(t, "0755", fileInfo.Metadata[pfs.FileModeMetadataKey])
+	// entries can't escape the prefix
+	_, err = c.InspectFile("data", "master", "/prefix/escape/baz")
+	require.NoError(t, err)
+
+	// the archive's modes are preserved by GetCommitArchive
+	buf.Reset()
+	require.NoError(t, c.GetCommitArchive("data", "master", "/prefix/dir", pfs.ArchiveFormat_TAR_GZ, &buf))
+	gr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if header.Name == "prefix/dir/bar" {
+			require.Equal(t, int64(0755), header.Mode)
+		}
+	}
+
+	// overwriting
+	_, err = c.PutFileTar("data", "master", "/prefix", tarArchive(t, map[string]string{"foo": "FOO"}, nil), true)
+	require.NoError(t, err)
+	buf.Reset()
+	require.NoError(t, c.GetFile("data", "master", "/prefix/foo", 0, 0, &buf))
+	require.Equal(t, "FOO", buf.String())
+}
+
+// tarArchive returns a tar archive holding 'files' (by path), with modes
+// 'modes' (0644 if unset)
+func tarArchive(t *testing.T, files map[string]string, modes map[string]int64) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		mode, ok := modes[name]
+		if !ok {
+			mode = 0644
+		}
+		require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: mode, Size: int64(len(files[name]))}))
+		_, err := tw.Write([]byte(files[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return &buf
+}
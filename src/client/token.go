@@ -0,0 +1,190 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// TokenRefresher returns a new session token for a client whose token has
+// expired (see WithTokenRefresher()). 'c' is a copy of the client that sends
+// no token, which the refresher may use to re-authenticate.
+type TokenRefresher func(c *APIClient) (string, error)
+
+// RefreshByAuthenticating returns a TokenRefresher that obtains a new token by
+// calling Authenticate with the request returned by 'request', e.g. one
+// containing a one-time password issued to the job, or a configured GitHub
+// token:
+//
+//	client.WithTokenRefresher(client.RefreshByAuthenticating(
+//		func() (*auth.AuthenticateRequest, error) {
+//			otp, err := readOTPFromSecret()
+//			return &auth.AuthenticateRequest{OneTimePassword: otp}, err
+//		}))
+func RefreshByAuthenticating(request func() (*auth.AuthenticateRequest, error)) TokenRefresher {
+	return func(c *APIClient) (string, error) {
+		req, err := request()
+		if err != nil {
+			return "", err
+		}
+		resp, err := c.AuthAPIClient.Authenticate(c.Ctx(), req)
+		if err != nil {
+			return "", grpcutil.ScrubGRPC(err)
+		}
+		return resp.PachToken, nil
+	}
+}
+
+// refreshingKey marks the contexts of RPCs sent by a TokenRefresher, which
+// mustn't themselves trigger a refresh
+type refreshingKey struct{}
+
+// tokenRefresh holds the state of a client's token refresher. It's shared by
+// all copies of the client (see APIClient.WithCtx()), as each copy carries its
+// own token.
+type tokenRefresh struct {
+	refresher TokenRefresher
+	client    *APIClient
+
+	// mu is held while refreshing, so that an expired token is only
+	// refreshed once
+	mu sync.Mutex
+	// replaced maps each expired token to the token that replaced it
+	replaced map[string]string
+}
+
+func newTokenRefresh(refresher TokenRefresher, client *APIClient) *tokenRefresh {
+	return &tokenRefresh{
+		refresher: refresher,
+		client:    client,
+		replaced:  make(map[string]string),
+	}
+}
+
+// latest returns the most recent replacement for 'token', or 'token' itself
+// if it hasn't expired
+func (r *tokenRefresh) latest(token string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.latestLocked(token)
+}
+
+func (r *tokenRefresh) latestLocked(token string) string {
+	for {
+		next, ok := r.replaced[token]
+		if !ok {
+			return token
+		}
+		token = next
+	}
+}
+
+// refresh returns a new token to replace 'expired', calling the refresher
+// unless another RPC has already done so
+func (r *tokenRefresh) refresh(expired string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.replaced[expired]; ok {
+		return r.latestLocked(expired), nil
+	}
+	c := r.client.WithCtx(context.WithValue(context.Background(), refreshingKey{}, true))
+	c.authenticationToken = ""
+	token, err := r.refresher(c)
+	if err != nil {
+		return "", err
+	}
+	r.replaced[expired] = token
+	return token, nil
+}
+
+// withToken returns a copy of 'ctx' whose outgoing metadata carries the
+// latest replacement for its auth token, along with that token ("" if 'ctx'
+// carries no token)
+func (r *tokenRefresh) withToken(ctx context.Context) (context.Context, string) {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok || len(md.Get(auth.ContextTokenKey)) == 0 {
+		return ctx, ""
+	}
+	token := md.Get(auth.ContextTokenKey)[0]
+	latest := r.latest(token)
+	if latest != token {
+		ctx = setToken(ctx, md, latest)
+	}
+	return ctx, latest
+}
+
+func setToken(ctx context.Context, md metadata.MD, token string) context.Context {
+	md = md.Copy()
+	md.Set(auth.ContextTokenKey, token)
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// expired returns true if 'err' indicates that the RPC's token had expired.
+// pachd reports expired tokens as auth.ErrBadToken.
+func expired(err error) bool {
+	return err != nil && auth.IsErrBadToken(err)
+}
+
+// unaryInterceptor returns an interceptor that sends the latest token with
+// each RPC and, if the RPC fails because its token has expired, refreshes the
+// token and retries the RPC once
+func (r *tokenRefresh) unaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if ctx.Value(refreshingKey{}) != nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		ctx, token := r.withToken(ctx)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if token == "" || !expired(err) {
+			return err
+		}
+		newToken, refreshErr := r.refresh(token)
+		if refreshErr != nil {
+			return err // the RPC's error is more useful to the caller
+		}
+		md, _ := metadata.FromOutgoingContext(ctx)
+		return invoker(setToken(ctx, md, newToken), method, req, reply, cc, opts...)
+	}
+}
+
+// streamInterceptor is like unaryInterceptor, but as messages may already
+// have been exchanged on a stream when its token is found to have expired,
+// only opening a stream is retried. A stream that fails later still refreshes
+// the token for subsequent RPCs.
+func (r *tokenRefresh) streamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if ctx.Value(refreshingKey{}) != nil {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		ctx, token := r.withToken(ctx)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if token == "" {
+			return stream, err
+		}
+		if expired(err) {
+			newToken, refreshErr := r.refresh(token)
+			if refreshErr != nil {
+				return nil, err
+			}
+			md, _ := metadata.FromOutgoingContext(ctx)
+			token = newToken
+			stream, err = streamer(setToken(ctx, md, token), desc, cc, method, opts...)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &observedStream{
+			ClientStream:  stream,
+			serverStreams: desc.ServerStreams,
+			finished: func(err error) {
+				if expired(err) {
+					r.refresh(token)
+				}
+			},
+		}, nil
+	}
+}
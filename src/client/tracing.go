@@ -0,0 +1,78 @@
+package client
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Tracer traces the RPCs made by the clients it's passed to (see
+// WithTracer()). It's implemented by the embedding application on top of its
+// tracing library, so that spans for client RPCs (PutFile, CreatePipeline,
+// etc.) join the application's traces, and so that the trace context reaches
+// pachd in each RPC's metadata. With OpenTelemetry, for example:
+//
+//	type otelTracer struct{}
+//
+//	func (otelTracer) StartSpan(ctx context.Context, method string) (context.Context, func(error)) {
+//		ctx, span := otel.Tracer("pachyderm").Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient))
+//		return ctx, func(err error) {
+//			if err != nil {
+//				span.RecordError(err)
+//				span.SetStatus(codes.Error, err.Error())
+//			}
+//			span.End()
+//		}
+//	}
+//
+//	func (otelTracer) Inject(ctx context.Context, md metadata.MD) {
+//		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(md))
+//	}
+type Tracer interface {
+	// StartSpan starts a client span for the RPC 'method' (e.g.
+	// "/pfs.API/PutFile") as a child of the span in 'ctx', if any. It returns
+	// a context containing the new span, and a function that ends the span
+	// with the RPC's result.
+	StartSpan(ctx context.Context, method string) (context.Context, func(err error))
+	// Inject writes the trace context of the span in 'ctx' into 'md' (e.g. as
+	// a W3C "traceparent" header), which is sent to pachd with the RPC
+	Inject(ctx context.Context, md metadata.MD)
+}
+
+// injectTrace returns a copy of 'ctx' whose outgoing metadata contains the
+// trace context of the span in 'ctx'
+func injectTrace(ctx context.Context, tracer Tracer) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy() // the metadata in a context must not be modified
+	} else {
+		md = metadata.MD{}
+	}
+	tracer.Inject(ctx, md)
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+func tracingUnaryInterceptor(tracer Tracer) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, end := tracer.StartSpan(ctx, method)
+		err := invoker(injectTrace(ctx, tracer), method, req, reply, cc, opts...)
+		end(err)
+		return err
+	}
+}
+
+func tracingStreamInterceptor(tracer Tracer) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, end := tracer.StartSpan(ctx, method)
+		stream, err := streamer(injectTrace(ctx, tracer), desc, cc, method, opts...)
+		if err != nil {
+			end(err)
+			return nil, err
+		}
+		return &observedStream{
+			ClientStream:  stream,
+			serverStreams: desc.ServerStreams,
+			finished:      end,
+		}, nil
+	}
+}
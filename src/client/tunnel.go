@@ -0,0 +1,536 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/server/pkg/backoff"
+	"golang.org/x/net/context"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// healthCheckTimeout is how long a tunnel's health check waits for the pod to
+// reject a forwarded connection before considering the remote port reachable
+const healthCheckTimeout = 5 * time.Second
+
+// TunnelStatus describes the state of a single tunnel opened by a
+// PortForwarder
+type TunnelStatus struct {
+	// Selector is the label selector of the pods that the tunnel forwards to
+	Selector map[string]string
+	PodName  string
+	// LocalPort is the local TCP port that the tunnel listens on, or 0 if it
+	// listens on a unix socket
+	LocalPort int
+	// SocketPath is the path of the unix socket that the tunnel listens on,
+	// if any
+	SocketPath string
+	RemotePort int
+	// BytesIn is the number of bytes copied from the pod to local connections
+	BytesIn int64
+	// BytesOut is the number of bytes copied from local connections to the
+	// pod
+	BytesOut int64
+	// ActiveConnections is the number of local connections currently being
+	// forwarded
+	ActiveConnections int64
+	// TotalConnections is the number of local connections the tunnel has
+	// accepted since it was opened
+	TotalConnections int64
+	// Healthy is false if the tunnel's connection to the pod was lost or its
+	// most recent health check failed
+	Healthy bool
+	// LastError is the most recent error encountered by the tunnel, if any
+	LastError error
+}
+
+// tunnel forwards connections accepted on a local listener to a port on a
+// pod, over a single SPDY connection to the kubernetes API server. If that
+// connection is lost, the tunnel reconnects to a (possibly different) pod
+// matching the same selector, without closing its listener.
+type tunnel struct {
+	forwarder *PortForwarder
+	selector  map[string]string
+	// pod, if set, is the pod that the tunnel forwards to, instead of one
+	// chosen using 'selector'
+	pod string
+	// proxied is set for tunnels opened by a SOCKS5 proxy, which don't have
+	// listeners of their own
+	proxied    bool
+	localPort  int
+	socketPath string
+	remotePort int
+	listener   net.Listener
+	stopChan   chan struct{}
+	closeOnce  sync.Once
+	// wg tracks the goroutines serving the tunnel, so that
+	// PortForwarder.Close() can wait for them to exit
+	wg sync.WaitGroup
+
+	bytesIn     int64 // accessed atomically
+	bytesOut    int64 // accessed atomically
+	activeConns int64 // accessed atomically
+	totalConns  int64 // accessed atomically
+
+	mu      sync.Mutex
+	podName string
+	// dialingPod is the pod most recently chosen by connect(), which may not
+	// have been connected to yet
+	dialingPod string
+	conn       httpstream.Connection
+	requestID  int
+	healthy    bool
+	lastErr    error
+}
+
+// listen binds the tunnel's unix socket, if it has one, or else its local
+// port. If 'fallback' is set and the local port is unavailable, a free
+// ephemeral port is bound instead.
+func (t *tunnel) listen(fallback bool) error {
+	if t.socketPath != "" {
+		return t.listenUnix()
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", t.localPort))
+	if err != nil && fallback {
+		listener, err = net.Listen("tcp", "localhost:0")
+	}
+	if err != nil {
+		return fmt.Errorf("unable to listen on port %d: %v", t.localPort, err)
+	}
+	t.listener = listener
+	t.localPort = listener.Addr().(*net.TCPAddr).Port
+	return nil
+}
+
+// listenUnix binds the tunnel's unix socket, and restricts access to it to the
+// current user. A stale socket left behind by a previous port forwarder is
+// replaced, but a socket that is still being served is not.
+func (t *tunnel) listenUnix() error {
+	if info, err := os.Lstat(t.socketPath); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", t.socketPath); err == nil {
+			conn.Close()
+			return fmt.Errorf("unable to listen on %s: socket is already in use", t.socketPath)
+		}
+		if err := os.Remove(t.socketPath); err != nil {
+			return fmt.Errorf("unable to remove stale socket %s: %v", t.socketPath, err)
+		}
+	}
+	// create the socket with restrictive permissions, so that there's no
+	// window in which other users can connect to it
+	oldMask := syscall.Umask(0077)
+	listener, err := net.Listen("unix", t.socketPath)
+	syscall.Umask(oldMask)
+	if err != nil {
+		return fmt.Errorf("unable to listen on %s: %v", t.socketPath, err)
+	}
+	if err := os.Chmod(t.socketPath, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("unable to set permissions of %s: %v", t.socketPath, err)
+	}
+	t.listener = listener
+	return nil
+}
+
+// connect picks a pod matching the tunnel's selector (unless the tunnel is
+// pinned to t.pod) and opens a new SPDY connection to it, replacing the
+// tunnel's existing connection (if any)
+func (t *tunnel) connect() error {
+	f := t.forwarder
+	podName := t.pod
+	if podName == "" {
+		var err error
+		if podName, err = f.choosePod(t.selector); err != nil {
+			return err
+		}
+	}
+	t.mu.Lock()
+	t.dialingPod = podName
+	t.mu.Unlock()
+
+	url := f.client.Post().
+		Resource("pods").
+		Namespace(f.namespace).
+		Name(podName).
+		SubResource("portforward").
+		URL()
+
+	transport, upgrader, err := spdy.RoundTripperFor(f.config)
+	if err != nil {
+		return err
+	}
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", url)
+	conn, _, err := dialer.Dial(portforward.PortForwardProtocolV1Name)
+	if err != nil {
+		return &podNotReadyError{
+			podName: podName,
+			err:     fmt.Errorf("port forwarding failed: error upgrading connection: %v", err),
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if isClosed(t.stopChan) {
+		// the tunnel was closed while connecting
+		conn.Close()
+		return nil
+	}
+	t.podName = podName
+	t.conn = conn
+	t.healthy = true
+	return nil
+}
+
+// podNotReadyError is returned by connect() when a pod was chosen, but
+// couldn't be connected to (e.g. because it's still starting)
+type podNotReadyError struct {
+	podName string
+	err     error
+}
+
+func (e *podNotReadyError) Error() string {
+	return e.err.Error()
+}
+
+// connectWhenReady connects the tunnel, retrying while the chosen pod isn't
+// ready to be connected to, until 'timeout' elapses or 'ctx' is done. If the
+// pod never becomes ready, the returned error includes the pod's most recent
+// events, which usually explain why. If 'timeout' is 0, only one attempt is
+// made.
+func (t *tunnel) connectWhenReady(ctx context.Context, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	b := backoff.NewInfiniteBackOff()
+	var lastErr error
+	for {
+		// connect in the background, as dialing the pod can hang. If the
+		// tunnel is stopped before connect() returns, connect() closes its
+		// connection.
+		errChan := make(chan error, 1)
+		t.goRun(func() { errChan <- t.connect() })
+		select {
+		case err := <-errChan:
+			if err == nil {
+				return nil
+			}
+			if _, ok := err.(*podNotReadyError); !ok || timeout == 0 {
+				return err // e.g. no pods match the selector
+			}
+			lastErr = err
+		case <-ctx.Done():
+		case <-t.stopChan:
+			return fmt.Errorf("port forwarder is shutdown")
+		}
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() != context.DeadlineExceeded {
+				return ctx.Err()
+			}
+			t.mu.Lock()
+			podName := t.dialingPod
+			t.mu.Unlock()
+			if lastErr == nil {
+				lastErr = fmt.Errorf("connection was not established")
+			}
+			return fmt.Errorf("timed out after %v waiting for pod %s to be ready for port forwarding: %v%s",
+				timeout, podName, lastErr, t.forwarder.podEvents(podName))
+		case <-t.stopChan:
+			return fmt.Errorf("port forwarder is shutdown")
+		case <-time.After(b.NextBackOff()):
+		}
+	}
+}
+
+// serve accepts connections on the tunnel's listener and forwards them to the
+// pod, until the listener is closed
+func (t *tunnel) serve() {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		t.goRun(func() { t.handle(conn) })
+	}
+}
+
+// monitor waits for the tunnel's connection to the pod to be lost and, unless
+// the tunnel has been closed, re-establishes it with exponential backoff
+func (t *tunnel) monitor() {
+	for {
+		t.mu.Lock()
+		conn, podName := t.conn, t.podName
+		t.mu.Unlock()
+		select {
+		case <-t.stopChan:
+			return
+		case <-conn.CloseChan():
+		}
+		if isClosed(t.stopChan) {
+			return
+		}
+		t.setHealthy(fmt.Errorf("lost connection to pod %s", podName))
+
+		// Reconnect with exponential backoff. backoff.RetryNotify isn't used
+		// because it can't be interrupted when the tunnel is closed.
+		b := backoff.NewInfiniteBackOff()
+		for {
+			err := t.connect()
+			if err == nil {
+				break
+			}
+			t.setHealthy(err)
+			t.forwarder.notifyReconnect(ReconnectEvent{
+				Selector:   t.selector,
+				LocalPort:  t.localPort,
+				RemotePort: t.remotePort,
+				Err:        err,
+			})
+			select {
+			case <-t.stopChan:
+				return
+			case <-time.After(b.NextBackOff()):
+			}
+		}
+		if isClosed(t.stopChan) {
+			return
+		}
+		t.forwarder.notifyReconnect(ReconnectEvent{
+			Selector:   t.selector,
+			LocalPort:  t.localPort,
+			RemotePort: t.remotePort,
+			PodName:    t.status().PodName,
+		})
+	}
+}
+
+// healthCheck periodically checks that the tunnel's remote port is reachable,
+// until the tunnel is closed
+func (t *tunnel) healthCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stopChan:
+			return
+		case <-ticker.C:
+		}
+		t.setHealthy(t.check())
+	}
+}
+
+// check verifies that the tunnel's remote port can be reached by opening (and
+// immediately closing) a forwarded connection to it. If the pod rejects the
+// connection, it reports why on the error stream.
+func (t *tunnel) check() error {
+	errorStream, dataStream, err := t.newStreams()
+	if err != nil {
+		return err
+	}
+	dataStream.Close()
+	errChan := make(chan error, 1)
+	go func() { errChan <- readErrorStream(errorStream, t.remotePort) }()
+	select {
+	case err := <-errChan:
+		return err
+	case <-time.After(healthCheckTimeout):
+		// the remote port accepted the connection but hasn't closed it
+		errorStream.Reset()
+		dataStream.Reset()
+		return nil
+	}
+}
+
+// newStreams creates the error and data streams that carry a single forwarded
+// connection to the pod
+func (t *tunnel) newStreams() (httpstream.Stream, httpstream.Stream, error) {
+	t.mu.Lock()
+	conn := t.conn
+	requestID := t.requestID
+	t.requestID++
+	t.mu.Unlock()
+
+	headers := http.Header{}
+	headers.Set(v1.StreamType, v1.StreamTypeError)
+	headers.Set(v1.PortHeader, strconv.Itoa(t.remotePort))
+	headers.Set(v1.PortForwardRequestIDHeader, strconv.Itoa(requestID))
+	errorStream, err := conn.CreateStream(headers)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating error stream for port %d -> %d: %v", t.localPort, t.remotePort, err)
+	}
+	// we're not writing to this stream
+	errorStream.Close()
+
+	headers.Set(v1.StreamType, v1.StreamTypeData)
+	dataStream, err := conn.CreateStream(headers)
+	if err != nil {
+		errorStream.Reset()
+		return nil, nil, fmt.Errorf("error creating forwarding stream for port %d -> %d: %v", t.localPort, t.remotePort, err)
+	}
+	return errorStream, dataStream, nil
+}
+
+// handle copies data between a local connection and the pod
+func (t *tunnel) handle(conn net.Conn) {
+	defer conn.Close()
+	atomic.AddInt64(&t.totalConns, 1)
+	atomic.AddInt64(&t.activeConns, 1)
+	defer atomic.AddInt64(&t.activeConns, -1)
+
+	errorStream, dataStream, err := t.newStreams()
+	if err != nil {
+		t.logErr(err)
+		return
+	}
+	errChan := make(chan error, 1)
+	go func() { errChan <- readErrorStream(errorStream, t.remotePort) }()
+
+	localError := make(chan struct{})
+	remoteDone := make(chan struct{})
+
+	go func() {
+		// Copy from the remote side to the local connection
+		io.Copy(&countingWriter{conn, &t.bytesIn}, dataStream)
+		// inform the select below that the remote copy is done
+		close(remoteDone)
+	}()
+
+	go func() {
+		// inform the pod we're not sending any more data after copy unblocks
+		defer dataStream.Close()
+		// Copy from the local connection to the remote side
+		if _, err := io.Copy(&countingWriter{dataStream, &t.bytesOut}, conn); err != nil {
+			// break out of the select below without waiting for the other
+			// copy to finish
+			close(localError)
+		}
+	}()
+
+	// wait for either a local->remote error or for copying from remote->local
+	// to finish
+	select {
+	case <-remoteDone:
+	case <-localError:
+	}
+
+	if err := <-errChan; err != nil {
+		t.logErr(err)
+	}
+}
+
+// readErrorStream returns the error (if any) that the pod reported for a
+// forwarded connection
+func readErrorStream(errorStream io.Reader, remotePort int) error {
+	message, err := ioutil.ReadAll(errorStream)
+	switch {
+	case err != nil:
+		return fmt.Errorf("error reading from error stream for port %d: %v", remotePort, err)
+	case len(message) > 0:
+		return fmt.Errorf("an error occurred forwarding to port %d: %s", remotePort, message)
+	}
+	return nil
+}
+
+// setHealthy records the result of a health check (or of reconnecting) and
+// notifies the port forwarder if the tunnel's health changed
+func (t *tunnel) setHealthy(err error) {
+	t.mu.Lock()
+	changed := t.healthy != (err == nil)
+	t.healthy = err == nil
+	if err != nil {
+		t.lastErr = err
+	}
+	t.mu.Unlock()
+	if changed {
+		t.forwarder.notifyHealthChange(t.status())
+	}
+}
+
+func (t *tunnel) logErr(err error) {
+	t.mu.Lock()
+	t.lastErr = err
+	t.mu.Unlock()
+	if t.forwarder.stderr != nil {
+		fmt.Fprintln(t.forwarder.stderr, err)
+	}
+}
+
+func (t *tunnel) status() TunnelStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return TunnelStatus{
+		Selector:          t.selector,
+		PodName:           t.podName,
+		LocalPort:         t.localPort,
+		SocketPath:        t.socketPath,
+		RemotePort:        t.remotePort,
+		BytesIn:           atomic.LoadInt64(&t.bytesIn),
+		BytesOut:          atomic.LoadInt64(&t.bytesOut),
+		ActiveConnections: atomic.LoadInt64(&t.activeConns),
+		TotalConnections:  atomic.LoadInt64(&t.totalConns),
+		Healthy:           t.healthy,
+		LastError:         t.lastErr,
+	}
+}
+
+// close stops the tunnel's listener and its connection to the pod
+func (t *tunnel) close() {
+	t.closeOnce.Do(func() {
+		close(t.stopChan)
+		if t.listener != nil {
+			t.listener.Close()
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.conn != nil {
+			t.conn.Close()
+		}
+	})
+}
+
+// goRun runs 'fn' in a goroutine that is tracked by t.wg
+func (t *tunnel) goRun(fn func()) {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		fn()
+	}()
+}
+
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+// countingWriter wraps an io.Writer and atomically adds the number of bytes
+// written through it to 'n'
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	atomic.AddInt64(c.n, int64(n))
+	return n, err
+}
@@ -0,0 +1,276 @@
+package client
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+	"github.com/pachyderm/pachyderm/src/server/pkg/backoff"
+	"golang.org/x/net/context"
+)
+
+// CommitEventType is the type of a CommitEvent
+type CommitEventType int
+
+const (
+	// CommitCreated is sent when a commit is started
+	CommitCreated CommitEventType = iota
+	// CommitFinished is sent when a commit is finished
+	CommitFinished
+)
+
+func (t CommitEventType) String() string {
+	switch t {
+	case CommitCreated:
+		return "created"
+	case CommitFinished:
+		return "finished"
+	default:
+		return "unknown"
+	}
+}
+
+// CommitEvent is sent by WatchCommits when a commit changes state. If Err is
+// set, watching failed and no more events will be sent.
+type CommitEvent struct {
+	Type       CommitEventType
+	CommitInfo *pfs.CommitInfo
+	Err        error
+}
+
+// JobEventType is the type of a JobEvent
+type JobEventType int
+
+const (
+	// JobCreated is sent when a job is created
+	JobCreated JobEventType = iota
+	// JobRunning is sent when a job starts processing datums
+	JobRunning
+	// JobFinished is sent when a job succeeds
+	JobFinished
+	// JobFailed is sent when a job fails or is killed
+	JobFailed
+)
+
+func (t JobEventType) String() string {
+	switch t {
+	case JobCreated:
+		return "created"
+	case JobRunning:
+		return "running"
+	case JobFinished:
+		return "finished"
+	case JobFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// JobEvent is sent by WatchJobs when a job changes state. If Err is set,
+// watching failed and no more events will be sent.
+type JobEvent struct {
+	Type    JobEventType
+	JobInfo *pps.JobInfo
+	Err     error
+}
+
+// watch calls 'f' with each commit that SubscribeCommit returns for 'repo'
+// and 'branch' in a new goroutine, until 'ctx' is done or 'f' returns false.
+// 'f' may start goroutines with 'wg' that wait for the commit to change
+// state. Once the subscription ends, 'cancel' (which must cancel 'ctx') is
+// called to stop those goroutines, and once they have returned, 'done' is
+// called with the error (if any) that ended the subscription.
+func (c APIClient) watch(ctx context.Context, cancel context.CancelFunc, repo, branch, from string,
+	f func(ci *pfs.CommitInfo, wg *sync.WaitGroup) bool, done func(error)) error {
+	iter, err := c.WithCtx(ctx).SubscribeCommit(repo, branch, from, pfs.CommitState_STARTED)
+	if err != nil {
+		return err
+	}
+	go func() {
+		var wg sync.WaitGroup
+		var err error
+		for {
+			var ci *pfs.CommitInfo
+			ci, err = iter.Next()
+			if err != nil {
+				if err == io.EOF || ctx.Err() != nil {
+					err = nil
+				}
+				break
+			}
+			if !f(ci, &wg) {
+				break
+			}
+		}
+		cancel()
+		wg.Wait()
+		done(grpcutil.ScrubGRPC(err))
+	}()
+	return nil
+}
+
+// WatchCommits returns a channel of the changes to the state of the commits
+// on 'branch' in 'repo', so that callers can react to commits without
+// polling. If 'from' is set, only commits created after 'from' are watched;
+// otherwise all existing commits on the branch are sent first.
+//
+// Watching continues until the client's context is canceled (see WithCtx),
+// after which the channel is closed. Callers must keep receiving from the
+// channel until then.
+func (c APIClient) WatchCommits(repo, branch, from string) (<-chan *CommitEvent, error) {
+	parentCtx := c.Ctx()
+	ctx, cancel := context.WithCancel(parentCtx)
+	pachClient := c.WithCtx(ctx)
+	events := make(chan *CommitEvent)
+	send := func(event *CommitEvent) bool {
+		select {
+		case events <- event:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	if err := c.watch(ctx, cancel, repo, branch, from, func(ci *pfs.CommitInfo, wg *sync.WaitGroup) bool {
+		if !send(&CommitEvent{Type: CommitCreated, CommitInfo: ci}) {
+			return false
+		}
+		if ci.Finished != nil {
+			return send(&CommitEvent{Type: CommitFinished, CommitInfo: ci})
+		}
+		wg.Add(1)
+		go func(commitID string) {
+			defer wg.Done()
+			ci, err := pachClient.BlockCommit(repo, commitID)
+			if err != nil {
+				return // the commit was deleted, or watching was stopped
+			}
+			send(&CommitEvent{Type: CommitFinished, CommitInfo: ci})
+		}(ci.Commit.ID)
+		return true
+	}, func(err error) {
+		if err != nil {
+			// 'ctx' is canceled, so wait for the caller's context instead
+			select {
+			case events <- &CommitEvent{Err: err}:
+			case <-parentCtx.Done():
+			}
+		}
+		close(events)
+	}); err != nil {
+		cancel()
+		return nil, err
+	}
+	return events, nil
+}
+
+// WatchJobs returns a channel of the changes to the state of the jobs run by
+// 'pipeline', so that callers can react to jobs without polling ListJob. If
+// 'from' is set, only jobs whose output commit was created after the output
+// commit 'from' are watched; otherwise all existing jobs are sent first.
+//
+// Jobs are found through the commits on the pipeline's output branch, and
+// each job is then followed with InspectJob. A job's JobRunning event may be
+// skipped if it runs too briefly to be observed.
+//
+// Watching continues until the client's context is canceled (see WithCtx),
+// after which the channel is closed. Callers must keep receiving from the
+// channel until then.
+func (c APIClient) WatchJobs(pipeline, from string) (<-chan *JobEvent, error) {
+	pipelineInfo, err := c.InspectPipeline(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	parentCtx := c.Ctx()
+	ctx, cancel := context.WithCancel(parentCtx)
+	pachClient := c.WithCtx(ctx)
+	events := make(chan *JobEvent)
+	send := func(event *JobEvent) bool {
+		select {
+		case events <- event:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	if err := c.watch(ctx, cancel, pipeline, pipelineInfo.OutputBranch, from, func(ci *pfs.CommitInfo, wg *sync.WaitGroup) bool {
+		wg.Add(1)
+		go func(commitID string) {
+			defer wg.Done()
+			pachClient.watchJob(ctx, pipeline, commitID, send)
+		}(ci.Commit.ID)
+		return true
+	}, func(err error) {
+		if err != nil {
+			// 'ctx' is canceled, so wait for the caller's context instead
+			select {
+			case events <- &JobEvent{Err: err}:
+			case <-parentCtx.Done():
+			}
+		}
+		close(events)
+	}); err != nil {
+		cancel()
+		return nil, err
+	}
+	return events, nil
+}
+
+// watchJob sends the events of the job whose output commit is 'commitID',
+// until the job ends or 'ctx' is done
+func (c APIClient) watchJob(ctx context.Context, repo, commitID string, send func(*JobEvent) bool) {
+	// the pipeline creates a commit's job shortly after the commit, and a job
+	// starts running once its workers have begun processing datums. Neither
+	// can be waited for directly, so wait with backoff.
+	b := backoff.NewInfiniteBackOff()
+	wait := func() bool {
+		select {
+		case <-time.After(b.NextBackOff()):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	var jobInfo *pps.JobInfo
+	for {
+		var err error
+		jobInfo, err = c.InspectJobOutputCommit(repo, commitID, false)
+		if err == nil {
+			break
+		}
+		if !strings.Contains(err.Error(), "not found") || !wait() {
+			return
+		}
+	}
+	if !send(&JobEvent{Type: JobCreated, JobInfo: jobInfo}) {
+		return
+	}
+	b.Reset()
+	for jobInfo.State == pps.JobState_JOB_STARTING {
+		if !wait() {
+			return
+		}
+		var err error
+		if jobInfo, err = c.InspectJob(jobInfo.Job.ID, false); err != nil {
+			return
+		}
+	}
+	if jobInfo.State == pps.JobState_JOB_RUNNING || jobInfo.State == pps.JobState_JOB_MERGING {
+		if !send(&JobEvent{Type: JobRunning, JobInfo: jobInfo}) {
+			return
+		}
+		var err error
+		if jobInfo, err = c.InspectJob(jobInfo.Job.ID, true); err != nil {
+			return
+		}
+	}
+	eventType := JobFailed
+	if jobInfo.State == pps.JobState_JOB_SUCCESS {
+		eventType = JobFinished
+	}
+	send(&JobEvent{Type: eventType, JobInfo: jobInfo})
+}
@@ -496,6 +496,35 @@ func UseAuthTokenCmd() *cobra.Command {
 	return setScope
 }
 
+// GetS3CredentialsCmd returns a cobra command that registers S3 credentials
+// for the user's token with the S3 gateway, and prints the credentials that S3 clients
+// should use
+func GetS3CredentialsCmd() *cobra.Command {
+	getS3Credentials := &cobra.Command{
+		Use:   "get-s3-credentials",
+		Short: "Get the credentials that S3 clients use to access the S3 gateway as the current user",
+		Long: "Get the credentials that S3 clients use to access the S3 gateway " +
+			"as the current user. The access key ID is a hash of your Pachyderm " +
+			"token, and the secret key is a random key that only grants access " +
+			"through the S3 gateway. The credentials are valid until your token " +
+			"expires or is revoked.",
+		Run: cmdutil.RunFixedArgs(0, func(args []string) error {
+			c, err := client.NewOnUserMachine(true, true, "user")
+			if err != nil {
+				return fmt.Errorf("could not connect: %v", err)
+			}
+			defer c.Close()
+			resp, err := c.GetS3Credentials(c.Ctx(), &auth.GetS3CredentialsRequest{})
+			if err != nil {
+				return grpcutil.ScrubGRPC(err)
+			}
+			fmt.Printf("Access key ID: %s\nSecret key: %s\n", resp.AccessKeyID, resp.SecretKey)
+			return nil
+		}),
+	}
+	return getS3Credentials
+}
+
 // Cmds returns a list of cobra commands for authenticating and authorizing
 // users in an auth-enabled Pachyderm cluster.
 func Cmds() []*cobra.Command {
@@ -516,6 +545,7 @@ func Cmds() []*cobra.Command {
 	auth.AddCommand(ModifyAdminsCmd())
 	auth.AddCommand(GetAuthTokenCmd())
 	auth.AddCommand(UseAuthTokenCmd())
+	auth.AddCommand(GetS3CredentialsCmd())
 	auth.AddCommand(GetConfig())
 	auth.AddCommand(SetConfig())
 	return []*cobra.Command{auth}
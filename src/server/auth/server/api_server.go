@@ -43,7 +43,6 @@ const (
 	// pachyderm token for any username in the AuthenticateRequest.GitHubToken field
 	DisableAuthenticationEnvVar = "PACHYDERM_AUTHENTICATION_DISABLED_FOR_TESTING"
 
-	tokensPrefix              = "/tokens"
 	authenticationCodesPrefix = "/auth-codes"
 	aclsPrefix                = "/acls"
 	adminsPrefix              = "/admins"
@@ -51,6 +50,17 @@ const (
 	groupsPrefix              = "/groups"
 	configPrefix              = "/config"
 
+	// TokensPrefix is the prefix (under the auth etcd prefix) of the
+	// collection of hashedToken -> TokenInfo mappings. The S3 gateway reads it
+	// to find the user whose S3 credentials signed each request.
+	TokensPrefix = "/tokens"
+
+	// S3CredentialsPrefix is the prefix (under the auth etcd prefix) of the
+	// collection of hashedToken -> secret key mappings of the S3 credentials
+	// that users have registered with GetS3Credentials. The gateway reads it
+	// to verify the signature of each request.
+	S3CredentialsPrefix = "/s3-credentials"
+
 	defaultTokenTTLSecs = 30 * 24 * 60 * 60 // 30 days
 	defaultSAMLTTLSecs  = 24 * 60 * 60      // 24 hours
 
@@ -124,6 +134,9 @@ type apiServer struct {
 	groups col.Collection
 	// collection containing the auth config (under the key configKey)
 	authConfig col.Collection
+	// s3Credentials is a collection of hashedToken -> secret key mappings of
+	// the S3 credentials that users have registered with the S3 gateway
+	s3Credentials col.Collection
 
 	// This is a cache of the PPS master token. It's set once on startup and then
 	// never updated
@@ -188,7 +201,7 @@ func NewAuthServer(pachdAddress string, etcdAddress string, etcdPrefix string, p
 		adminCache: make(map[string]struct{}),
 		tokens: col.NewCollection(
 			etcdClient,
-			path.Join(etcdPrefix, tokensPrefix),
+			path.Join(etcdPrefix, TokensPrefix),
 			nil,
 			&authclient.TokenInfo{},
 			nil,
@@ -242,6 +255,14 @@ func NewAuthServer(pachdAddress string, etcdAddress string, etcdPrefix string, p
 			nil,
 			nil,
 		),
+		s3Credentials: col.NewCollection(
+			etcdClient,
+			path.Join(etcdPrefix, S3CredentialsPrefix),
+			nil,
+			&types.StringValue{},
+			nil,
+			nil,
+		),
 		public: public,
 	}
 	go s.retrieveOrGeneratePPSToken()
@@ -621,6 +642,7 @@ func (a *apiServer) Deactivate(ctx context.Context, req *authclient.DeactivateRe
 		a.members.ReadWrite(stm).DeleteAll()
 		a.groups.ReadWrite(stm).DeleteAll()
 		a.authConfig.ReadWrite(stm).DeleteAll()
+		a.s3Credentials.ReadWrite(stm).DeleteAll()
 		return nil
 	})
 	if err != nil {
@@ -1757,6 +1779,9 @@ func (a *apiServer) RevokeAuthToken(ctx context.Context, req *authclient.RevokeA
 				AdminOp: "RevokeAuthToken on another user's token",
 			}
 		}
+		if err := a.s3Credentials.ReadWrite(stm).Delete(hashToken(req.Token)); err != nil && !col.IsErrNotFound(err) {
+			return err
+		}
 		return tokens.Delete(hashToken(req.Token))
 	}); err != nil {
 		return nil, err
@@ -1764,6 +1789,62 @@ func (a *apiServer) RevokeAuthToken(ctx context.Context, req *authclient.RevokeA
 	return &authclient.RevokeAuthTokenResponse{}, nil
 }
 
+// GetS3Credentials implements the protobuf auth.GetS3Credentials RPC. S3
+// requests are signed with a secret key, which the S3 gateway must be able to
+// look up by the request's access key ID. The secret key is random, rather
+// than the caller's token, so that S3 credentials don't grant a Pachyderm
+// session. It's stored under the token's hash (which is the access key ID)
+// until the token expires or is revoked, and calling GetS3Credentials again
+// returns the same secret key.
+func (a *apiServer) GetS3Credentials(ctx context.Context, req *authclient.GetS3CredentialsRequest) (resp *authclient.GetS3CredentialsResponse, retErr error) {
+	a.LogReq(req)
+	// The response holds the secret key, so it isn't logged
+	defer func(start time.Time) { a.LogResp(req, nil, retErr, time.Since(start)) }(time.Now())
+	if a.activationState() != full {
+		return nil, authclient.ErrNotActivated
+	}
+	token, err := getAuthToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if token == a.ppsToken {
+		return nil, fmt.Errorf("the PPS token can't be used with the S3 gateway")
+	}
+	var secretKey string
+	if _, err := col.NewSTM(ctx, a.etcdClient, func(stm col.STM) error {
+		var tokenInfo authclient.TokenInfo
+		if err := a.tokens.ReadWrite(stm).Get(hashToken(token), &tokenInfo); err != nil {
+			if col.IsErrNotFound(err) {
+				return authclient.ErrBadToken
+			}
+			return err
+		}
+		s3Credentials := a.s3Credentials.ReadWrite(stm)
+		var existing types.StringValue
+		if err := s3Credentials.Get(hashToken(token), &existing); err == nil {
+			secretKey = existing.Value
+			return nil
+		} else if !col.IsErrNotFound(err) {
+			return err
+		}
+		ttl, err := a.tokens.ReadWrite(stm).TTL(hashToken(token))
+		if err != nil {
+			return fmt.Errorf("error looking up TTL for token: %v", err)
+		}
+		secretKey = uuid.NewWithoutDashes()
+		if ttl > 0 {
+			return s3Credentials.PutTTL(hashToken(token), &types.StringValue{Value: secretKey}, ttl)
+		}
+		return s3Credentials.Put(hashToken(token), &types.StringValue{Value: secretKey})
+	}); err != nil {
+		return nil, err
+	}
+	return &authclient.GetS3CredentialsResponse{
+		AccessKeyID: hashToken(token),
+		SecretKey:   secretKey,
+	}, nil
+}
+
 // setGroupsForUserInternal is a helper function used by SetGroupsForUser, and
 // also by handleSAMLResponse (which updates group membership information based
 // on signed SAML assertions). This does no auth checks, so the caller must do
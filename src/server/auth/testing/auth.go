@@ -82,6 +82,11 @@ func (a *InactiveAPIServer) RevokeAuthToken(context.Context, *auth.RevokeAuthTok
 	return nil, auth.ErrNotActivated
 }
 
+// GetS3Credentials implements the GetS3Credentials RPC, but just returns NotActivatedError
+func (a *InactiveAPIServer) GetS3Credentials(context.Context, *auth.GetS3CredentialsRequest) (*auth.GetS3CredentialsResponse, error) {
+	return nil, auth.ErrNotActivated
+}
+
 // SetGroupsForUser implements the SetGroupsForUser RPC, but just returns NotActivatedError
 func (a *InactiveAPIServer) SetGroupsForUser(context.Context, *auth.SetGroupsForUserRequest) (*auth.SetGroupsForUserResponse, error) {
 	return nil, auth.ErrNotActivated
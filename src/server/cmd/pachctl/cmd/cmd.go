@@ -9,12 +9,17 @@ import (
 	"io/ioutil"
 	golog "log"
 	"os"
+	"os/exec"
 	"os/signal"
+	"path"
+	"strconv"
 	"strings"
+	"syscall"
 	"text/tabwriter"
 	"time"
 
 	etcd "github.com/coreos/etcd/clientv3"
+	"github.com/docker/go-units"
 	"github.com/fatih/color"
 	"github.com/gogo/protobuf/jsonpb"
 	"github.com/gogo/protobuf/types"
@@ -34,7 +39,6 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"golang.org/x/net/context"
-	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/grpclog"
 )
@@ -356,17 +360,38 @@ This resets the cluster to its initial state.`,
 	}
 	var port int
 	var samlPort int
+	var oidcPort int
+	var pachdSocket string
 	var uiPort int
 	var uiWebsocketPort int
 	var pfsPort int
+	var s3gatewayPort int
+	var socks5Port int
 	var namespace string
+	var kubeConfig string
+	var kubeContext string
+	var freePortFallback bool
+	var statsInterval time.Duration
+	var daemon bool
+	var readyTimeout time.Duration
 
 	portForward := &cobra.Command{
 		Use:   "port-forward",
 		Short: "Forward a port on the local machine to pachd. This command blocks.",
 		Long:  "Forward a port on the local machine to pachd. This command blocks.",
 		Run: cmdutil.RunFixedArgs(0, func(args []string) error {
-			fw, err := client.NewPortForwarder(namespace, ioutil.Discard, os.Stderr)
+			if daemon {
+				return daemonizePortForward()
+			}
+
+			var options []client.PortForwarderOption
+			if kubeConfig != "" {
+				options = append(options, client.WithKubeConfig(kubeConfig))
+			}
+			if kubeContext != "" {
+				options = append(options, client.WithKubeContext(kubeContext))
+			}
+			fw, err := client.NewPortForwarder(namespace, ioutil.Discard, os.Stderr, options...)
 			if err != nil {
 				return err
 			}
@@ -374,55 +399,168 @@ This resets the cluster to its initial state.`,
 			if err = fw.Lock(); err != nil {
 				return err
 			}
+			if err = fw.ServeControl(); err != nil {
+				fw.Close()
+				return err
+			}
 
-			var eg errgroup.Group
-
-			eg.Go(func() error {
-				fmt.Println("Forwarding the pachd (Pachyderm daemon) port...")
-				return fw.RunForDaemon(port)
-			})
-
-			eg.Go(func() error {
-				fmt.Println("Forwarding the SAML ACS port...")
-				return fw.RunForSAMLACS(samlPort)
-			})
-
-			eg.Go(func() error {
-				fmt.Printf("Forwarding the dash (Pachyderm dashboard) UI port to http://localhost:%v ...\n", uiPort)
-				return fw.RunForDashUI(uiPort)
-			})
-
-			eg.Go(func() error {
-				fmt.Println("Forwarding the dash (Pachyderm dashboard) websocket port...")
-				return fw.RunForDashWebSocket(uiWebsocketPort)
+			fw.OnReconnect(func(e client.ReconnectEvent) {
+				if e.Err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to reconnect port %d to %s: %v\n", e.LocalPort, e.Selector["app"], e.Err)
+					return
+				}
+				fmt.Printf("Reconnected port %d to %s pod %s\n", e.LocalPort, e.Selector["app"], e.PodName)
 			})
-
-			eg.Go(func() error {
-				fmt.Println("Forwarding the PFS port...")
-				return fw.RunForPFS(pfsPort)
+			fw.OnHealthChange(func(s client.TunnelStatus) {
+				if !s.Healthy {
+					fmt.Fprintf(os.Stderr, "Port %d (forwarded to %s pod %s) is broken: %v\n", s.LocalPort, s.Selector["app"], s.PodName, s.LastError)
+					return
+				}
+				fmt.Printf("Port %d (forwarded to %s pod %s) is healthy again\n", s.LocalPort, s.Selector["app"], s.PodName)
 			})
 
+			fw.FreePortFallback = freePortFallback
+			fw.ReadyTimeout = readyTimeout
 			defer fw.Close()
 
-			if err = eg.Wait(); err != nil {
+			result := fw.RunAll(client.StandardPorts{
+				Pachd:         port,
+				PachdSocket:   pachdSocket,
+				SAMLACS:       samlPort,
+				OIDC:          oidcPort,
+				DashUI:        uiPort,
+				DashWebSocket: uiWebsocketPort,
+				PFS:           pfsPort,
+				S3Gateway:     s3gatewayPort,
+			})
+			if err := result.Err(); err != nil {
 				return err
 			}
+			bound := result.Ports
+			if bound.PachdSocket != "" {
+				fmt.Printf("Forwarding the pachd (Pachyderm daemon) port to unix://%s\n", bound.PachdSocket)
+			} else {
+				fmt.Printf("Forwarding the pachd (Pachyderm daemon) port to localhost:%d\n", bound.Pachd)
+			}
+			if bound.SAMLACS != 0 {
+				fmt.Printf("Forwarding the SAML ACS port to localhost:%d\n", bound.SAMLACS)
+			}
+			if bound.OIDC != 0 {
+				fmt.Printf("Forwarding the OIDC callback port to localhost:%d\n", bound.OIDC)
+			}
+			fmt.Printf("Forwarding the dash (Pachyderm dashboard) UI port to http://localhost:%d\n", bound.DashUI)
+			fmt.Printf("Forwarding the dash (Pachyderm dashboard) websocket port to localhost:%d\n", bound.DashWebSocket)
+			fmt.Printf("Forwarding the PFS port to localhost:%d\n", bound.PFS)
+			if bound.S3Gateway != 0 {
+				fmt.Printf("Forwarding the S3 gateway port to http://localhost:%d\n", bound.S3Gateway)
+			}
+			if socks5Port != 0 {
+				boundPort, err := fw.RunSOCKS5Proxy(socks5Port)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Serving a SOCKS5 proxy to the pods and services in namespace %q on localhost:%d\n", namespace, boundPort)
+			}
 
 			fmt.Println("CTRL-C to exit")
 			fmt.Println("NOTE: kubernetes port-forward often outputs benign error messages, these should be ignored unless they seem to be impacting your ability to connect over the forwarded port.")
 
 			ch := make(chan os.Signal, 1)
-			signal.Notify(ch, os.Interrupt)
-			<- ch
-			return nil
+			signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+			if statsInterval <= 0 {
+				<-ch
+				return nil
+			}
+			ticker := time.NewTicker(statsInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ch:
+					return nil
+				case <-ticker.C:
+					printPortForwardStats(fw.Status())
+				}
+			}
 		}),
 	}
 	portForward.Flags().IntVarP(&port, "port", "p", 30650, "The local port to bind pachd to.")
+	portForward.Flags().StringVar(&pachdSocket, "pachd-socket", "", "If set, bind pachd to a unix socket at this path (accessible only by the current user) instead of a local port.")
 	portForward.Flags().IntVar(&samlPort, "saml-port", 30654, "The local port to bind pachd's SAML ACS to.")
+	portForward.Flags().IntVar(&oidcPort, "oidc-port", 30657, "The local port to bind pachd's OIDC callback to.")
 	portForward.Flags().IntVarP(&uiPort, "ui-port", "u", 30080, "The local port to bind Pachyderm's dash service to.")
 	portForward.Flags().IntVarP(&uiWebsocketPort, "proxy-port", "x", 30081, "The local port to bind Pachyderm's dash proxy service to.")
 	portForward.Flags().IntVarP(&pfsPort, "pfs-port", "f", 30652, "The local port to bind PFS over HTTP to.")
+	portForward.Flags().IntVarP(&s3gatewayPort, "s3gateway-port", "s", 30600, "The local port to bind the S3 gateway to.")
+	portForward.Flags().IntVar(&socks5Port, "socks5-port", 0, "If set, also serve a SOCKS5 proxy on this local port that can reach any pod or service in the namespace (e.g. pachd:650 or dash:8080).")
 	portForward.Flags().StringVar(&namespace, "namespace", "default", "Kubernetes namespace Pachyderm is deployed in.")
+	portForward.Flags().BoolVar(&freePortFallback, "free-port-fallback", false, "If a requested local port is already in use, bind a free port instead of failing.")
+	portForward.Flags().StringVar(&kubeConfig, "kubeconfig", "", "Path to the kubernetes config file to use (defaults to $KUBECONFIG or ~/.kube/config).")
+	portForward.Flags().DurationVar(&readyTimeout, "ready-timeout", client.DefaultReadyTimeout, "How long to wait for pods that are still starting to accept forwarded connections.")
+	portForward.Flags().DurationVar(&statsInterval, "stats-interval", 0, "If set, periodically print the number of connections and bytes forwarded over each port.")
+	portForward.Flags().StringVar(&kubeContext, "context", "", "The kubernetes context of the cluster Pachyderm is deployed in (defaults to the current context).")
+	portForward.Flags().BoolVar(&daemon, "daemon", false, "Run port forwarding in the background. Use 'pachctl port-forward list' and 'pachctl port-forward stop' to manage it.")
+
+	listForwards := &cobra.Command{
+		Use:   "list",
+		Short: "List the ports forwarded by background port forwarders.",
+		Long:  "List the ports forwarded by 'pachctl port-forward' processes running on this machine.",
+		Run: cmdutil.RunFixedArgs(0, func(args []string) error {
+			forwards, err := client.ListForwards()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 1, 1, ' ', 0)
+			fmt.Fprintf(w, "CONTEXT\tNAMESPACE\tPID\tLOCAL\tAPP\tPOD\tREMOTE\tHEALTHY\t\n")
+			for _, f := range forwards {
+				local := strconv.Itoa(f.LocalPort)
+				if f.SocketPath != "" {
+					local = "unix://" + f.SocketPath
+				}
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%d\t%t\t\n", f.KubeContext, f.Namespace, f.PID,
+					local, f.Selector["app"], f.PodName, f.RemotePort, f.Healthy)
+			}
+			return w.Flush()
+		}),
+	}
+	portForward.AddCommand(listForwards)
+
+	var stopContext string
+	var stopNamespace string
+	stopForward := &cobra.Command{
+		Use:   "stop <local-port>",
+		Short: "Stop forwarding a local port.",
+		Long:  "Stop forwarding a local port opened by a 'pachctl port-forward' process running on this machine, leaving its other ports open.",
+		Run: cmdutil.RunFixedArgs(1, func(args []string) error {
+			localPort, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid port %q: %v", args[0], err)
+			}
+			forwards, err := client.ListForwards()
+			if err != nil {
+				return err
+			}
+			var matches []client.Forward
+			for _, f := range forwards {
+				if f.LocalPort != localPort || f.SocketPath != "" ||
+					(stopContext != "" && f.KubeContext != stopContext) ||
+					(stopNamespace != "" && f.Namespace != stopNamespace) {
+					continue
+				}
+				matches = append(matches, f)
+			}
+			switch len(matches) {
+			case 0:
+				return fmt.Errorf("no port forwarder is forwarding port %d", localPort)
+			case 1:
+				return client.StopForward(matches[0].KubeContext, matches[0].Namespace, localPort)
+			default:
+				return fmt.Errorf("several port forwarders are forwarding port %d, use --context and --namespace to choose one", localPort)
+			}
+		}),
+	}
+	stopForward.Flags().StringVar(&stopContext, "context", "", "Only stop the port forwarder for this kubernetes context.")
+	stopForward.Flags().StringVar(&stopNamespace, "namespace", "", "Only stop the port forwarder for this kubernetes namespace.")
+	portForward.AddCommand(stopForward)
 
 	var install bool
 	var path string
@@ -476,3 +614,73 @@ func printVersionHeader(w io.Writer) {
 func printVersion(w io.Writer, component string, v *versionpb.Version) {
 	fmt.Fprintf(w, "%s\t%s\t\n", component, version.PrettyPrintVersion(v))
 }
+
+// printPortForwardStats prints the traffic forwarded over each of a port
+// forwarder's tunnels
+func printPortForwardStats(statuses []client.TunnelStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 1, 1, ' ', 0)
+	fmt.Fprintf(w, "PORT\tAPP\tPOD\tACTIVE\tCONNECTIONS\tIN\tOUT\t\n")
+	for _, s := range statuses {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%s\t%s\t\n", s.LocalPort, s.Selector["app"], s.PodName,
+			s.ActiveConnections, s.TotalConnections,
+			units.BytesSize(float64(s.BytesIn)), units.BytesSize(float64(s.BytesOut)))
+	}
+	w.Flush()
+}
+
+// daemonizePortForward re-runs the current 'pachctl port-forward' command
+// (minus --daemon) in a new session in the background, and waits for it to
+// start forwarding ports
+func daemonizePortForward() error {
+	var args []string
+	for _, arg := range os.Args[1:] {
+		if arg == "--daemon" || arg == "--daemon=true" {
+			continue
+		}
+		args = append(args, arg)
+	}
+	logDir := path.Join(os.Getenv("HOME"), ".pachyderm/port-forward")
+	if err := os.MkdirAll(logDir, 0700); err != nil {
+		return err
+	}
+	logFile, err := ioutil.TempFile(logDir, "daemon-*.log")
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not start port forwarder: %v", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	timeout := time.After(time.Minute)
+	for {
+		select {
+		case <-exited:
+			return fmt.Errorf("port forwarder exited, see %s for details", logFile.Name())
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for port forwarder %d to start, see %s for details", cmd.Process.Pid, logFile.Name())
+		case <-time.After(time.Second):
+		}
+		forwards, err := client.ListForwards()
+		if err != nil {
+			continue
+		}
+		for _, f := range forwards {
+			if f.PID == cmd.Process.Pid {
+				fmt.Printf("Port forwarding is running in the background (pid %d), logging to %s\n", cmd.Process.Pid, logFile.Name())
+				fmt.Println("Use 'pachctl port-forward list' to see forwarded ports and 'pachctl port-forward stop' to stop them")
+				return nil
+			}
+		}
+	}
+}
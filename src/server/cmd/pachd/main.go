@@ -9,10 +9,12 @@ import (
 	"runtime/debug"
 	"runtime/pprof"
 	"strconv"
+	"sync"
 	"time"
 
 	etcd "github.com/coreos/etcd/clientv3"
 	units "github.com/docker/go-units"
+	"github.com/gogo/protobuf/types"
 	"github.com/pachyderm/pachyderm/src/client"
 	adminclient "github.com/pachyderm/pachyderm/src/client/admin"
 	authclient "github.com/pachyderm/pachyderm/src/client/auth"
@@ -34,6 +36,7 @@ import (
 	eprsserver "github.com/pachyderm/pachyderm/src/server/enterprise/server"
 	"github.com/pachyderm/pachyderm/src/server/health"
 	pach_http "github.com/pachyderm/pachyderm/src/server/http"
+	"github.com/pachyderm/pachyderm/src/server/pfs/s3"
 	pfs_server "github.com/pachyderm/pachyderm/src/server/pfs/server"
 	cache_pb "github.com/pachyderm/pachyderm/src/server/pkg/cache/groupcachepb"
 	cache_server "github.com/pachyderm/pachyderm/src/server/pkg/cache/server"
@@ -43,6 +46,7 @@ import (
 	"github.com/pachyderm/pachyderm/src/server/pkg/hashtree"
 	"github.com/pachyderm/pachyderm/src/server/pkg/metrics"
 	"github.com/pachyderm/pachyderm/src/server/pkg/netutil"
+	"github.com/pachyderm/pachyderm/src/server/pkg/ppsconsts"
 	"github.com/pachyderm/pachyderm/src/server/pkg/uuid"
 	pps_server "github.com/pachyderm/pachyderm/src/server/pps/server"
 	"github.com/pachyderm/pachyderm/src/server/pps/server/githook"
@@ -74,10 +78,11 @@ func init() {
 
 type appEnv struct {
 	// Ports served by Pachd
-	Port      uint16 `env:"PORT,default=650"`
-	PProfPort uint16 `env:"PPROF_PORT,default=651"`
-	HTTPPort  uint16 `env:"HTTP_PORT,default=652"`
-	PeerPort  uint16 `env:"PEER_PORT,default=653"`
+	Port          uint16 `env:"PORT,default=650"`
+	PProfPort     uint16 `env:"PPROF_PORT,default=651"`
+	HTTPPort      uint16 `env:"HTTP_PORT,default=652"`
+	PeerPort      uint16 `env:"PEER_PORT,default=653"`
+	S3GatewayPort uint16 `env:"S3GATEWAY_PORT,default=600"`
 
 	NumShards             uint64 `env:"NUM_SHARDS,default=32"`
 	StorageRoot           string `env:"PACH_ROOT,default=/pach"`
@@ -351,7 +356,7 @@ func doFullMode(appEnvObj interface{}) (retErr error) {
 	// if *any* of these serve functions returns an arror.
 	var eg errgroup.Group
 	eg.Go(func() error {
-		httpServer, err := pach_http.NewHTTPServer(address)
+		httpServer, err := pach_http.NewHTTPServer(address, etcdAddress, path.Join(appEnv.EtcdPrefix, appEnv.PFSEtcdPrefix))
 		if err != nil {
 			return err
 		}
@@ -361,6 +366,13 @@ func doFullMode(appEnvObj interface{}) (retErr error) {
 		}
 		return fmt.Errorf("ListenAndServe: %v", err)
 	})
+	eg.Go(func() error {
+		err := http.ListenAndServe(fmt.Sprintf(":%v", appEnv.S3GatewayPort), newS3Handler(address, etcdClientV3, path.Join(appEnv.EtcdPrefix, appEnv.AuthEtcdPrefix)))
+		if err != nil {
+			log.Printf("error starting s3 gateway %v\n", err)
+		}
+		return fmt.Errorf("ListenAndServe: %v", err)
+	})
 	eg.Go(func() error {
 		err := githook.RunGitHookServer(address, etcdAddress, path.Join(appEnv.EtcdPrefix, appEnv.PPSEtcdPrefix))
 		if err != nil {
@@ -641,3 +653,95 @@ func getNamespace() string {
 	}
 	return v1.NamespaceDefault
 }
+
+// s3SessionTTL is the lifetime of the tokens that the S3 gateway gets for the
+// users whose credentials sign requests. They're replaced once half of it has
+// passed, and requests are only served while the user's S3 credentials are
+// registered, so revoking the token that registered them takes effect
+// immediately.
+const s3SessionTTL = 10 * time.Minute
+
+// s3Session is a token that the S3 gateway got for a user
+type s3Session struct {
+	token   string
+	expires time.Time
+}
+
+// newS3Handler returns the handler that serves the S3 gateway. pachd may not
+// be serving yet, so the client that serves S3 requests is connected when the
+// first one arrives. The S3 credentials that users have registered with the
+// gateway, and the tokens that they were registered with, are read from the
+// auth server's collections under 'authEtcdPrefix'. The gateway doesn't have
+// users' tokens, so it serves each user's requests with a short-lived token
+// that it gets for them with PPS's superuser token (see s3SessionTTL).
+func newS3Handler(address string, etcdClient *etcd.Client, authEtcdPrefix string) http.Handler {
+	var pachClient *client.APIClient
+	var pachClientMu sync.Mutex
+	getClient := func(r *http.Request) (*client.APIClient, error) {
+		pachClientMu.Lock()
+		defer pachClientMu.Unlock()
+		if pachClient == nil {
+			var err error
+			if pachClient, err = client.NewFromAddress(address); err != nil {
+				return nil, err
+			}
+		}
+		return pachClient.WithCtx(r.Context()), nil
+	}
+	getInternalClient := func(r *http.Request) (*client.APIClient, error) {
+		c, err := getClient(r)
+		if err != nil {
+			return nil, err
+		}
+		var superUserToken types.StringValue
+		superUserTokenCol := col.NewCollection(etcdClient, ppsconsts.PPSTokenKey, nil, &types.StringValue{}, nil, nil).ReadOnly(r.Context())
+		if err := superUserTokenCol.Get("", &superUserToken); err == nil {
+			c.SetAuthToken(superUserToken.Value)
+		} else if !col.IsErrNotFound(err) {
+			return nil, err
+		} // otherwise auth has never been activated, so no token is needed
+		return c, nil
+	}
+	s3Credentials := col.NewCollection(etcdClient, path.Join(authEtcdPrefix, authserver.S3CredentialsPrefix), nil, &types.StringValue{}, nil, nil)
+	tokens := col.NewCollection(etcdClient, path.Join(authEtcdPrefix, authserver.TokensPrefix), nil, &authclient.TokenInfo{}, nil, nil)
+	// sessions maps access key IDs to the tokens that the gateway got for
+	// their users
+	sessions := make(map[string]s3Session)
+	var sessionsMu sync.Mutex
+	getCredentials := func(r *http.Request, accessKeyID string) (string, string, error) {
+		var secretKey types.StringValue
+		if err := s3Credentials.ReadOnly(r.Context()).Get(accessKeyID, &secretKey); err != nil {
+			if col.IsErrNotFound(err) {
+				return "", "", nil
+			}
+			return "", "", err
+		}
+		sessionsMu.Lock()
+		defer sessionsMu.Unlock()
+		if session, ok := sessions[accessKeyID]; ok && time.Until(session.expires) > s3SessionTTL/2 {
+			return secretKey.Value, session.token, nil
+		}
+		var tokenInfo authclient.TokenInfo
+		if err := tokens.ReadOnly(r.Context()).Get(accessKeyID, &tokenInfo); err != nil {
+			if col.IsErrNotFound(err) {
+				return "", "", nil // the token was revoked after the lookup above
+			}
+			return "", "", err
+		}
+		c, err := getInternalClient(r)
+		if err != nil {
+			return "", "", err
+		}
+		expires := time.Now().Add(s3SessionTTL)
+		resp, err := c.GetAuthToken(c.Ctx(), &authclient.GetAuthTokenRequest{
+			Subject: tokenInfo.Subject,
+			TTL:     int64(s3SessionTTL.Seconds()),
+		})
+		if err != nil {
+			return "", "", grpcutil.ScrubGRPC(err)
+		}
+		sessions[accessKeyID] = s3Session{token: resp.Token, expires: expires}
+		return secretKey.Value, resp.Token, nil
+	}
+	return s3.NewHandler(getClient, getInternalClient, getCredentials)
+}
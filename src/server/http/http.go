@@ -1,6 +1,7 @@
 package http
 
 import (
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
@@ -11,12 +12,15 @@ import (
 
 	"github.com/pachyderm/pachyderm/src/client"
 	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/server/pfs/webdav"
+	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
 	"github.com/pachyderm/pachyderm/src/server/pkg/errutil"
+	"github.com/pachyderm/pachyderm/src/server/pkg/ppsconsts"
 
+	etcd "github.com/coreos/etcd/clientv3"
 	"github.com/gogo/protobuf/types"
 	"github.com/julienschmidt/httprouter"
 	"golang.org/x/net/context"
-	"google.golang.org/grpc/metadata"
 )
 
 // HTTPPort specifies the port the server will listen on
@@ -28,6 +32,7 @@ func versionPath(p string) string {
 
 var (
 	getFilePath = versionPath("pfs/repos/:repoName/commits/:commitID/files/*filePath")
+	webdavPath  = versionPath("pfs/webdav/*path")
 	servicePath = versionPath("pps/services/:serviceName/*path")
 	loginPath   = versionPath("auth/login")
 	logoutPath  = versionPath("auth/logout")
@@ -41,58 +46,160 @@ type server struct {
 	pachClient     *client.APIClient
 	pachClientOnce sync.Once
 	httpClient     *http.Client
+
+	// etcdClient and etcdPrefix (PFS's etcd prefix) are used to read the key
+	// that signs file URLs, which is cached in signingKey
+	etcdClient   *etcd.Client
+	etcdPrefix   string
+	signingKey   []byte
+	signingKeyMu sync.Mutex
 }
 
-// NewHTTPServer returns a Pachyderm HTTP server.
-func NewHTTPServer(address string) (http.Handler, error) {
+// NewHTTPServer returns a Pachyderm HTTP server. 'etcdPrefix' is PFS's etcd
+// prefix, under which the key that signs file URLs (see SignFileURL) is
+// stored.
+func NewHTTPServer(address string, etcdAddress string, etcdPrefix string) (http.Handler, error) {
+	etcdClient, err := etcd.New(etcd.Config{
+		Endpoints:   []string{etcdAddress},
+		DialOptions: client.DefaultDialOptions(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newServer(address, etcdClient, etcdPrefix), nil
+}
+
+func newServer(address string, etcdClient *etcd.Client, etcdPrefix string) *server {
 	router := httprouter.New()
 	s := &server{
 		router:     router,
 		address:    address,
 		httpClient: &http.Client{},
+		etcdClient: etcdClient,
+		etcdPrefix: etcdPrefix,
 	}
 
 	router.GET(getFilePath, s.getFileHandler)
+	router.HEAD(getFilePath, s.getFileHandler)
 	router.GET(servicePath, s.serviceHandler)
 
 	router.POST(loginPath, s.authLoginHandler)
 	router.POST(logoutPath, s.authLogoutHandler)
 	router.POST(servicePath, s.serviceHandler)
 
+	webdavHandler := webdav.NewHandler(path.Dir(webdavPath), s.webdavClient)
+	for _, method := range webdav.Methods {
+		router.Handler(method, webdavPath, webdavHandler)
+	}
+
 	router.NotFound = http.HandlerFunc(notFound)
-	return s, nil
+	return s
 }
 
+// getFileHandler serves a file, with support for HEAD, range and conditional
+// requests (which http.ServeContent handles), so that clients can seek into
+// large files and cache them. A file's ETag is the commit that it's read from
+// and the file's hash, and files in commits that are referenced by ID (rather
+// than by branch) never change, so they can be cached indefinitely.
 func (s *server) getFileHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	filePaths := strings.Split(ps.ByName("filePath"), "/")
+	repo, commitID, filePath := ps.ByName("repoName"), ps.ByName("commitID"), ps.ByName("filePath")
+	filePaths := strings.Split(filePath, "/")
 	fileName := filePaths[len(filePaths)-1]
-	ctx := context.Background()
-	for _, cookie := range r.Cookies() {
-		if cookie.Name == auth.ContextTokenKey {
-			ctx = metadata.NewIncomingContext(
-				ctx,
-				metadata.Pairs(auth.ContextTokenKey, cookie.Value),
-			)
-		}
-	}
 	downloadValues := r.URL.Query()["download"]
 	if len(downloadValues) == 1 && downloadValues[0] == "true" {
 		w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=\"%v\"", fileName))
 	}
-	c := s.getPachClient()
-	commitInfo, err := c.InspectCommit(ps.ByName("repoName"), ps.ByName("commitID"))
+	c := s.getPachClient().WithCtx(r.Context())
+	if cookie, err := r.Cookie(auth.ContextTokenKey); err == nil {
+		c.SetAuthToken(cookie.Value)
+	}
+	if _, ok := r.URL.Query()["signature"]; ok {
+		var err error
+		if c, err = s.signedURLClient(r, ps); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	commitInfo, err := c.InspectCommit(repo, commitID)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	// Read the commit that was inspected, in case 'commitID' is a branch that
+	// has moved since
+	fileInfo, err := c.InspectFile(repo, commitInfo.Commit.ID, filePath)
 	if err != nil {
 		httpError(w, err)
 		return
 	}
-	content, err := c.GetFileReadSeeker(ps.ByName("repoName"), ps.ByName("commitID"), ps.ByName("filePath"))
+	w.Header().Set("ETag", fmt.Sprintf(`"%s-%s"`, commitInfo.Commit.ID, hex.EncodeToString(fileInfo.Hash)))
+	if commitID == commitInfo.Commit.ID && commitInfo.Finished != nil {
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+	// The content is only read when (and where) ServeContent reads it, so
+	// conditional and range requests don't read any more of the file than
+	// they send
+	content, err := c.GetFileReaderAt(repo, commitInfo.Commit.ID, filePath)
 	if err != nil {
 		httpError(w, err)
 		return
 	}
-	modtime, err := types.TimestampFromProto(commitInfo.Finished)
+	defer content.Close()
+	modtime, _ := types.TimestampFromProto(commitInfo.Finished)
 	http.ServeContent(w, r, fileName, modtime, content)
-	return
+}
+
+// signedURLClient verifies the signature of a file URL (see SignFileURL), and
+// returns a client that can read the file it points at. The URL's signer was
+// authorized to read the file when it was signed, so if auth is active, the
+// client uses PPS's superuser token.
+func (s *server) signedURLClient(r *http.Request, ps httprouter.Params) (*client.APIClient, error) {
+	ctx := r.Context()
+	key, err := s.getSigningKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyFileURL(key, ps.ByName("repoName"), ps.ByName("commitID"), ps.ByName("filePath"), r.URL.Query()); err != nil {
+		return nil, err
+	}
+	c := s.getPachClient().WithCtx(ctx)
+	var superUserToken types.StringValue
+	superUserTokenCol := col.NewCollection(s.etcdClient, ppsconsts.PPSTokenKey, nil, &types.StringValue{}, nil, nil).ReadOnly(ctx)
+	if err := superUserTokenCol.Get("", &superUserToken); err == nil {
+		c.SetAuthToken(superUserToken.Value)
+	} else if !col.IsErrNotFound(err) {
+		return nil, err
+	} // otherwise auth has never been activated, so no token is needed
+	return c, nil
+}
+
+func (s *server) getSigningKey(ctx context.Context) ([]byte, error) {
+	s.signingKeyMu.Lock()
+	defer s.signingKeyMu.Unlock()
+	if s.signingKey == nil {
+		key, err := GetSigningKey(ctx, s.etcdClient, s.etcdPrefix)
+		if err != nil {
+			return nil, err
+		}
+		s.signingKey = key
+	}
+	return s.signingKey, nil
+}
+
+// webdavClient returns the client that serves a WebDAV request. WebDAV
+// clients can't log in, so users authenticate with HTTP basic auth, using
+// their Pachyderm token as the password (the username is ignored), or with
+// the cookie set by authLoginHandler.
+func (s *server) webdavClient(r *http.Request) (*client.APIClient, error) {
+	c := s.getPachClient().WithCtx(r.Context())
+	if _, token, ok := r.BasicAuth(); ok {
+		c.SetAuthToken(token)
+	} else if cookie, err := r.Cookie(auth.ContextTokenKey); err == nil {
+		c.SetAuthToken(cookie.Value)
+	}
+	return c, nil
 }
 
 func (s *server) serviceHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	pachtesting "github.com/pachyderm/pachyderm/src/client/testing"
+)
+
+func TestGetFile(t *testing.T) {
+	pachd := pachtesting.NewServer()
+	defer pachd.Close()
+	c, err := pachd.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	s := newServer("", nil, "")
+	s.pachClientOnce.Do(func() { s.pachClient = c })
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	require.NoError(t, c.CreateRepo("repo"))
+	_, err = c.PutFile("repo", "master", "file.txt", strings.NewReader("0123456789"))
+	require.NoError(t, err)
+	commitInfo, err := c.InspectCommit("repo", "master")
+	require.NoError(t, err)
+
+	get := func(method string, commit string, header map[string]string) (*http.Response, string) {
+		req, err := http.NewRequest(method, server.URL+"/v1/pfs/repos/repo/commits/"+commit+"/files/file.txt", nil)
+		require.NoError(t, err)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(body)
+	}
+
+	resp, body := get("GET", "master", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "0123456789", body)
+	etag := resp.Header.Get("ETag")
+	require.True(t, strings.HasPrefix(etag, `"`+commitInfo.Commit.ID+"-"))
+	// Branches move, so files read from them must be revalidated
+	require.Equal(t, "private, no-cache", resp.Header.Get("Cache-Control"))
+	resp, _ = get("GET", commitInfo.Commit.ID, nil)
+	require.Equal(t, "private, max-age=31536000, immutable", resp.Header.Get("Cache-Control"))
+
+	resp, body = get("GET", "master", map[string]string{"Range": "bytes=3-5"})
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	require.Equal(t, "345", body)
+	require.Equal(t, "bytes 3-5/10", resp.Header.Get("Content-Range"))
+	resp, body = get("GET", "master", map[string]string{"Range": "bytes=-2"})
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	require.Equal(t, "89", body)
+
+	resp, body = get("HEAD", "master", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "10", resp.Header.Get("Content-Length"))
+	require.Equal(t, etag, resp.Header.Get("ETag"))
+	require.Equal(t, "", body)
+
+	resp, body = get("GET", "master", map[string]string{"If-None-Match": etag})
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+	require.Equal(t, "", body)
+	// A new commit changes the ETag, even though the file hasn't changed
+	_, err = c.PutFile("repo", "master", "other", strings.NewReader("foo"))
+	require.NoError(t, err)
+	resp, body = get("GET", "master", map[string]string{"If-None-Match": etag})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "0123456789", body)
+
+	resp, _ = get("GET", "nonexistent", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,97 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	etcd "github.com/coreos/etcd/clientv3"
+	"golang.org/x/net/context"
+)
+
+// signingKeyKey is the etcd key (under the PFS prefix) at which the key that
+// signs file URLs is stored
+const signingKeyKey = "file_url_signing_key"
+
+// signingKeySize is the size, in bytes, of the key that signs file URLs
+const signingKeySize = 32
+
+// GetSigningKey returns the key with which pachd signs file URLs (see
+// SignFileURL), creating it the first time that it's needed. The key is
+// stored in etcd, so that a URL signed by one pachd is accepted by all of
+// them.
+func GetSigningKey(ctx context.Context, etcdClient *etcd.Client, etcdPrefix string) ([]byte, error) {
+	key := path.Join(etcdPrefix, signingKeyKey)
+	newValue := make([]byte, signingKeySize)
+	if _, err := rand.Read(newValue); err != nil {
+		return nil, err
+	}
+	// store 'newValue' unless another pachd has already stored a key
+	resp, err := etcdClient.Txn(ctx).
+		If(etcd.Compare(etcd.CreateRevision(key), "=", 0)).
+		Then(etcd.OpPut(key, hex.EncodeToString(newValue))).
+		Else(etcd.OpGet(key)).
+		Commit()
+	if err != nil {
+		return nil, fmt.Errorf("could not get file URL signing key: %v", err)
+	}
+	if resp.Succeeded {
+		return newValue, nil
+	}
+	kvs := resp.Responses[0].GetResponseRange().Kvs
+	if len(kvs) == 0 {
+		return nil, fmt.Errorf("could not get file URL signing key: key was deleted")
+	}
+	return hex.DecodeString(string(kvs[0].Value))
+}
+
+// fileURLPath returns the path at which the HTTP server serves 'filePath' in
+// 'commitID' (see getFilePath)
+func fileURLPath(repoName, commitID, filePath string) string {
+	return versionPath(path.Join("pfs/repos", repoName, "commits", commitID,
+		"files", strings.TrimPrefix(filePath, "/")))
+}
+
+// signature returns the hex-encoded HMAC of a file URL's parameters
+func signature(key []byte, repoName, commitID, filePath string, expires int64) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%d", repoName, commitID, path.Join("/", filePath), expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignFileURL returns the path and query of a URL at which the HTTP server
+// serves the file at 'filePath' in 'commitID' to anyone who has the URL, until
+// 'expires'. 'commitID' should be a commit ID rather than a branch, so that
+// the URL always returns the same contents.
+func SignFileURL(key []byte, repoName, commitID, filePath string, expires time.Time) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", signature(key, repoName, commitID, filePath, expires.Unix()))
+	return (&url.URL{
+		Path:     fileURLPath(repoName, commitID, filePath),
+		RawQuery: query.Encode(),
+	}).String()
+}
+
+// verifyFileURL returns an error unless 'query' holds a signature (created by
+// SignFileURL) for the file at 'filePath' in 'commitID' that hasn't expired
+func verifyFileURL(key []byte, repoName, commitID, filePath string, query url.Values) error {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid expiry time %q", query.Get("expires"))
+	}
+	if !hmac.Equal([]byte(query.Get("signature")), []byte(signature(key, repoName, commitID, filePath, expires))) {
+		return fmt.Errorf("invalid signature")
+	}
+	if time.Now().Unix() > expires {
+		return fmt.Errorf("URL expired at %s", time.Unix(expires, 0).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
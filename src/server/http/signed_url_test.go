@@ -0,0 +1,32 @@
+package http
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+)
+
+func TestSignFileURL(t *testing.T) {
+	key := []byte("key")
+	signed, err := url.Parse(SignFileURL(key, "repo", "commit", "/dir/file", time.Now().Add(time.Minute)))
+	require.NoError(t, err)
+	require.Equal(t, "/v1/pfs/repos/repo/commits/commit/files/dir/file", signed.Path)
+	require.NoError(t, verifyFileURL(key, "repo", "commit", "/dir/file", signed.Query()))
+
+	// the signature only covers the file it was created for
+	require.YesError(t, verifyFileURL(key, "repo", "commit", "/dir/other", signed.Query()))
+	require.YesError(t, verifyFileURL(key, "repo", "commit2", "/dir/file", signed.Query()))
+	require.YesError(t, verifyFileURL([]byte("other key"), "repo", "commit", "/dir/file", signed.Query()))
+
+	// the expiry time can't be changed
+	query := signed.Query()
+	query.Set("expires", "99999999999")
+	require.YesError(t, verifyFileURL(key, "repo", "commit", "/dir/file", query))
+
+	// expired URLs are rejected
+	expired, err := url.Parse(SignFileURL(key, "repo", "commit", "/dir/file", time.Now().Add(-time.Minute)))
+	require.NoError(t, err)
+	require.YesError(t, verifyFileURL(key, "repo", "commit", "/dir/file", expired.Query()))
+}
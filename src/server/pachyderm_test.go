@@ -2220,6 +2220,80 @@ func TestUpdatePipelineRunningJob(t *testing.T) {
 	require.Equal(t, pps.JobState_JOB_KILLED.String(), jobInfos[1].State.String())
 }
 
+func TestApplyPipelines(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+
+	c := getPachClient(t)
+	require.NoError(t, c.DeleteAll())
+	dataRepo := tu.UniqueString("TestApplyPipelines_data")
+	require.NoError(t, c.CreateRepo(dataRepo))
+	spec := func(name, input string) *pps.CreatePipelineRequest {
+		return &pps.CreatePipelineRequest{
+			Pipeline: client.NewPipeline(name),
+			Transform: &pps.Transform{
+				Cmd:   []string{"bash"},
+				Stdin: []string{fmt.Sprintf("cp /pfs/%s/* /pfs/out/", input)},
+			},
+			Input: client.NewPFSInput(input, "/*"),
+		}
+	}
+	a, b := tu.UniqueString("TestApplyPipelines_a"), tu.UniqueString("TestApplyPipelines_b")
+
+	// An invalid spec stops every change, including the valid ones before it
+	invalid := spec(tu.UniqueString("TestApplyPipelines_invalid"), dataRepo)
+	invalid.Input.Pfs.Glob = ""
+	_, err := c.ApplyPipelines([]*pps.CreatePipelineRequest{spec(a, dataRepo), invalid}, false, false)
+	require.YesError(t, err)
+	pipelineInfos, err := c.ListPipeline()
+	require.NoError(t, err)
+	require.Equal(t, 0, len(pipelineInfos))
+
+	// Pipelines may read from pipelines that the same apply creates
+	changes, err := c.ApplyPipelines([]*pps.CreatePipelineRequest{spec(b, a), spec(a, dataRepo)}, false, false)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(changes))
+	require.Equal(t, a, changes[0].Pipeline.Name)
+	require.Equal(t, pps.PipelineChangeType_PIPELINE_CREATE, changes[0].Type)
+	require.Equal(t, b, changes[1].Pipeline.Name)
+	require.Equal(t, pps.PipelineChangeType_PIPELINE_CREATE, changes[1].Type)
+
+	// Applying the same specs changes nothing
+	changes, err = c.ApplyPipelines([]*pps.CreatePipelineRequest{spec(a, dataRepo), spec(b, a)}, false, false)
+	require.NoError(t, err)
+	require.Equal(t, pps.PipelineChangeType_PIPELINE_UNCHANGED, changes[0].Type)
+	require.Equal(t, pps.PipelineChangeType_PIPELINE_UNCHANGED, changes[1].Type)
+
+	// A spec that reads from a pruned pipeline is rejected before anything
+	// is deleted
+	updated := spec(a, dataRepo)
+	updated.Description = "updated"
+	_, err = c.ApplyPipelines([]*pps.CreatePipelineRequest{updated, spec(tu.UniqueString("TestApplyPipelines_c"), b)}, true, false)
+	require.YesError(t, err)
+	pipelineInfos, err = c.ListPipeline()
+	require.NoError(t, err)
+	require.Equal(t, 2, len(pipelineInfos))
+
+	// Pruned pipelines are deleted after the others are updated
+	changes, err = c.ApplyPipelines([]*pps.CreatePipelineRequest{updated}, true, false)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(changes))
+	require.Equal(t, pps.PipelineChangeType_PIPELINE_UPDATE, changes[0].Type)
+	require.Equal(t, b, changes[1].Pipeline.Name)
+	require.Equal(t, pps.PipelineChangeType_PIPELINE_DELETE, changes[1].Type)
+	pipelineInfos, err = c.ListPipeline()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(pipelineInfos))
+	require.Equal(t, "updated", pipelineInfos[0].Description)
+
+	_, err = c.PutFile(dataRepo, "master", "file", strings.NewReader("foo"))
+	require.NoError(t, err)
+	commitIter, err := c.FlushCommit([]*pfs.Commit{client.NewCommit(dataRepo, "master")}, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(collectCommitInfos(t, commitIter)))
+}
+
 func TestManyFilesSingleCommit(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration tests in short mode")
@@ -2420,6 +2494,94 @@ func TestStandby(t *testing.T) {
 	})
 }
 
+func TestSchedulerLimits(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	c := getPachClient(t)
+	require.NoError(t, c.DeleteAll())
+	defer c.SetSchedulerLimits(nil)
+
+	require.YesError(t, c.SetSchedulerLimits(&pps.SchedulerLimits{MaxCpu: -1}))
+	require.YesError(t, c.SetSchedulerLimits(&pps.SchedulerLimits{
+		PipelineMaxRunningJobs: map[string]int64{"out": -1},
+	}))
+	require.NoError(t, c.SetSchedulerLimits(&pps.SchedulerLimits{MaxRunningJobs: 1}))
+	status, err := c.InspectScheduler()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), status.Limits.MaxRunningJobs)
+
+	dataRepo := tu.UniqueString("TestSchedulerLimits_data")
+	require.NoError(t, c.CreateRepo(dataRepo))
+	pipelines := []string{tu.UniqueString("TestSchedulerLimits"), tu.UniqueString("TestSchedulerLimits")}
+	for _, pipeline := range pipelines {
+		_, err := c.PpsAPIClient.CreatePipeline(context.Background(),
+			&pps.CreatePipelineRequest{
+				Pipeline: client.NewPipeline(pipeline),
+				Transform: &pps.Transform{
+					Cmd:   []string{"sh"},
+					Stdin: []string{"sleep 20", fmt.Sprintf("cp /pfs/%s/* /pfs/out/", dataRepo)},
+				},
+				ParallelismSpec: &pps.ParallelismSpec{Constant: 2},
+				Input:           client.NewPFSInput(dataRepo, "/"),
+			},
+		)
+		require.NoError(t, err)
+	}
+	_, err = c.PutFile(dataRepo, "master", "file", strings.NewReader("foo"))
+	require.NoError(t, err)
+
+	// One job runs while the other is queued, and only the queued job's worker
+	// master is running
+	require.NoErrorWithinTRetry(t, time.Minute, func() error {
+		status, err := c.InspectScheduler()
+		if err != nil {
+			return err
+		}
+		if len(status.Running) != 1 || len(status.Queued) != 1 {
+			return fmt.Errorf("expected 1 running and 1 queued job, but got %d and %d", len(status.Running), len(status.Queued))
+		}
+		jobInfo, err := c.InspectJob(status.Queued[0].ID, false)
+		if err != nil {
+			return err
+		}
+		if jobInfo.State != pps.JobState_JOB_STARTING {
+			return fmt.Errorf("queued job is %v", jobInfo.State)
+		}
+		pipelineInfo, err := c.InspectPipeline(jobInfo.Pipeline.Name)
+		if err != nil {
+			return err
+		}
+		rc, err := pipelineRc(t, pipelineInfo)
+		if err != nil {
+			return err
+		}
+		if *rc.Spec.Replicas != 1 {
+			return fmt.Errorf("queued job's pipeline has %d workers, but should have 1", *rc.Spec.Replicas)
+		}
+		return nil
+	})
+
+	// Both jobs finish once the first job is done
+	commitIter, err := c.FlushCommit([]*pfs.Commit{client.NewCommit(dataRepo, "master")}, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(collectCommitInfos(t, commitIter)))
+	for _, pipeline := range pipelines {
+		jobInfos, err := c.ListJob(pipeline, nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(jobInfos))
+		require.Equal(t, pps.JobState_JOB_SUCCESS, jobInfos[0].State)
+		pipelineInfo, err := c.InspectPipeline(pipeline)
+		require.NoError(t, err)
+		rc, err := pipelineRc(t, pipelineInfo)
+		require.NoError(t, err)
+		require.Equal(t, int32(2), *rc.Spec.Replicas)
+	}
+	status, err = c.InspectScheduler()
+	require.NoError(t, err)
+	require.Equal(t, 0, len(status.Queued))
+}
+
 func TestPipelineEnv(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration tests in short mode")
@@ -4217,6 +4379,119 @@ func TestUnionInput(t *testing.T) {
 	})
 }
 
+func TestJoinInput(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+
+	c := getPachClient(t)
+	require.NoError(t, c.DeleteAll())
+
+	repoA := tu.UniqueString("TestJoinInputA")
+	require.NoError(t, c.CreateRepo(repoA))
+	repoB := tu.UniqueString("TestJoinInputB")
+	require.NoError(t, c.CreateRepo(repoB))
+	var commits []*pfs.Commit
+	for repo, files := range map[string][]string{
+		repoA: {"1.a", "2.a", "3.a"},
+		repoB: {"1.b", "2.b", "4.b"},
+	} {
+		commit, err := c.StartCommit(repo, "master")
+		require.NoError(t, err)
+		commits = append(commits, commit)
+		for _, file := range files {
+			_, err = c.PutFile(repo, "master", file, strings.NewReader(file))
+			require.NoError(t, err)
+		}
+		require.NoError(t, c.FinishCommit(repo, "master"))
+	}
+
+	a := client.NewPFSInputOpts("a", repoA, "", "/(*).a", false)
+	a.Pfs.JoinOn = "$1"
+	b := client.NewPFSInputOpts("b", repoB, "", "/(*).b", false)
+	b.Pfs.JoinOn = "$1"
+	pipeline := tu.UniqueString("pipeline")
+	require.NoError(t, c.CreatePipeline(
+		pipeline,
+		"",
+		[]string{"bash"},
+		[]string{
+			"cat /pfs/a/* /pfs/b/* > /pfs/out/$(ls /pfs/a | cut -d. -f1)",
+		},
+		&pps.ParallelismSpec{
+			Constant: 1,
+		},
+		client.NewJoinInput(a, b),
+		"",
+		false,
+	))
+
+	commitIter, err := c.FlushCommit(commits, []*pfs.Repo{client.NewRepo(pipeline)})
+	require.NoError(t, err)
+	commitInfos := collectCommitInfos(t, commitIter)
+	require.Equal(t, 1, len(commitInfos))
+	outCommit := commitInfos[0].Commit
+	fileInfos, err := c.ListFile(outCommit.Repo.Name, outCommit.ID, "")
+	require.NoError(t, err)
+	// Only the keys that both repos have are joined
+	require.Equal(t, 2, len(fileInfos))
+	for _, key := range []string{"1", "2"} {
+		var buf bytes.Buffer
+		require.NoError(t, c.GetFile(outCommit.Repo.Name, outCommit.ID, key, 0, 0, &buf))
+		require.Equal(t, key+".a"+key+".b", buf.String())
+	}
+}
+
+func TestGroupInput(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+
+	c := getPachClient(t)
+	require.NoError(t, c.DeleteAll())
+
+	repo := tu.UniqueString("TestGroupInput")
+	require.NoError(t, c.CreateRepo(repo))
+	commit, err := c.StartCommit(repo, "master")
+	require.NoError(t, err)
+	for _, file := range []string{"1-x", "1-y", "1-z", "2-x", "2-y", "3-x"} {
+		_, err = c.PutFile(repo, "master", file, strings.NewReader(file))
+		require.NoError(t, err)
+	}
+	require.NoError(t, c.FinishCommit(repo, "master"))
+
+	in := client.NewPFSInputOpts("in", repo, "", "/(*)-*", false)
+	in.Pfs.GroupBy = "$1"
+	pipeline := tu.UniqueString("pipeline")
+	require.NoError(t, c.CreatePipeline(
+		pipeline,
+		"",
+		[]string{"bash"},
+		[]string{
+			// If a group's files were in several datums, their counts would be
+			// concatenated
+			"ls /pfs/in | wc -l | tr -d ' ' > /pfs/out/$(ls /pfs/in | head -n 1 | cut -d- -f1)",
+		},
+		&pps.ParallelismSpec{
+			Constant: 1,
+		},
+		client.NewGroupInput(in),
+		"",
+		false,
+	))
+
+	commitIter, err := c.FlushCommit([]*pfs.Commit{commit}, []*pfs.Repo{client.NewRepo(pipeline)})
+	require.NoError(t, err)
+	commitInfos := collectCommitInfos(t, commitIter)
+	require.Equal(t, 1, len(commitInfos))
+	outCommit := commitInfos[0].Commit
+	for key, count := range map[string]string{"1": "3\n", "2": "2\n", "3": "1\n"} {
+		var buf bytes.Buffer
+		require.NoError(t, c.GetFile(outCommit.Repo.Name, outCommit.ID, key, 0, 0, &buf))
+		require.Equal(t, count, buf.String())
+	}
+}
+
 func TestGarbageCollection(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration tests in short mode")
@@ -4359,6 +4634,53 @@ func TestGarbageCollection(t *testing.T) {
 	require.Equal(t, "barbar\n", buf.String())
 }
 
+func TestGarbageCollectStatus(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+
+	c := getPachClient(t)
+	require.NoError(t, c.DeleteAll())
+	require.NoError(t, c.GarbageCollect(0))
+
+	// Write some data and then delete it, so that GC has something to collect
+	dataRepo := tu.UniqueString("TestGarbageCollectStatus")
+	require.NoError(t, c.CreateRepo(dataRepo))
+	_, err := c.PutFile(dataRepo, "master", "foo", strings.NewReader("foo"))
+	require.NoError(t, err)
+	require.NoError(t, c.DeleteRepo(dataRepo, false))
+
+	status, err := c.StartGarbageCollect(0)
+	require.NoError(t, err)
+	require.NotNil(t, status.Started)
+	require.NoErrorWithinTRetry(t, 2*time.Minute, func() error {
+		status, err = c.InspectGarbageCollect()
+		if err != nil {
+			return err
+		}
+		if status.State != pps.GarbageCollectState_GC_SUCCESS {
+			return fmt.Errorf("expected GC to succeed, but it's in state %s", status.State)
+		}
+		return nil
+	})
+	require.True(t, status.ObjectsDeleted > 0)
+	require.True(t, status.BytesReclaimed > 0)
+	require.True(t, status.ObjectsScanned >= status.ObjectsDeleted)
+	_, err = c.PauseGarbageCollect()
+	require.YesError(t, err)
+
+	require.YesError(t, c.SetGarbageCollectSchedule("not a cron spec", 0))
+	require.NoError(t, c.SetGarbageCollectSchedule("0 3 * * *", 0))
+	status, err = c.InspectGarbageCollect()
+	require.NoError(t, err)
+	require.Equal(t, "0 3 * * *", status.Schedule.CronSpec)
+	require.NotNil(t, status.Schedule.Next)
+	require.NoError(t, c.SetGarbageCollectSchedule("", 0))
+	status, err = c.InspectGarbageCollect()
+	require.NoError(t, err)
+	require.Nil(t, status.Schedule)
+}
+
 func TestPipelineWithStats(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration tests in short mode")
@@ -3,9 +3,12 @@ package cmds
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
@@ -13,22 +16,29 @@ import (
 	"strings"
 	gosync "sync"
 	"syscall"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 
+	units "github.com/docker/go-units"
 	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/types"
 	"github.com/hanwen/go-fuse/fuse/nodefs"
 	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/auth"
 	"github.com/pachyderm/pachyderm/src/client/limit"
 	pfsclient "github.com/pachyderm/pachyderm/src/client/pfs"
 	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/client/pkg/manifest"
 	"github.com/pachyderm/pachyderm/src/server/pfs/fuse"
 	"github.com/pachyderm/pachyderm/src/server/pfs/pretty"
+	"github.com/pachyderm/pachyderm/src/server/pfs/webdav"
 	"github.com/pachyderm/pachyderm/src/server/pkg/cmdutil"
 	"github.com/pachyderm/pachyderm/src/server/pkg/sync"
 	"github.com/pachyderm/pachyderm/src/server/pkg/tabwriter"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/net/context"
 )
 
 const (
@@ -61,6 +71,54 @@ func Cmds(noMetrics *bool) []*cobra.Command {
 	}
 
 	var description string
+	var keepCommits int64
+	var keepFor time.Duration
+	var retentionBranches []string
+	retention := func() *pfsclient.RetentionPolicy {
+		policy := &pfsclient.RetentionPolicy{
+			KeepCommits: keepCommits,
+			Branches:    retentionBranches,
+		}
+		if keepFor != 0 {
+			policy.KeepDuration = types.DurationProto(keepFor)
+		}
+		return policy
+	}
+	addRetentionFlags := func(cmd *cobra.Command) {
+		cmd.Flags().Int64Var(&keepCommits, "keep-commits", 0, "Delete commits once the repo has this many newer commits (0 means no limit).")
+		cmd.Flags().DurationVar(&keepFor, "keep-for", 0, "Delete commits that finished longer ago than this, e.g. 720h (0 means no limit).")
+		cmd.Flags().StringArrayVar(&retentionBranches, "retention-branch", nil, "Only delete commits on this branch; may be given multiple times (default all branches).")
+	}
+	var compressionName string
+	var compressionLevel int32
+	compression := func() (*pfsclient.CompressionSpec, error) {
+		name := strings.ToUpper(compressionName)
+		if name == "NONE" {
+			name = pfsclient.Compression_UNCOMPRESSED.String()
+		}
+		compression, ok := pfsclient.Compression_value[name]
+		if !ok {
+			return nil, fmt.Errorf("unrecognized compression %q; only accepts one of {gzip,zstd,none}", compressionName)
+		}
+		return &pfsclient.CompressionSpec{
+			Compression: pfsclient.Compression(compression),
+			Level:       compressionLevel,
+		}, nil
+	}
+	addCompressionFlags := func(cmd *cobra.Command) {
+		cmd.Flags().StringVar(&compressionName, "compression", "", "Compress the contents of files written to the repo in object storage with this algorithm: gzip, zstd or none (default pachd's setting).")
+		cmd.Flags().Int32Var(&compressionLevel, "compression-level", 0, "The level of --compression, from 1 (fastest) to 9 for gzip or 22 for zstd (best compression); 0 means the algorithm's default.")
+	}
+	var encryptionKey string
+	encryption := func() *pfsclient.EncryptionSpec {
+		if encryptionKey == "none" {
+			return &pfsclient.EncryptionSpec{}
+		}
+		return &pfsclient.EncryptionSpec{Key: encryptionKey}
+	}
+	addEncryptionFlags := func(cmd *cobra.Command) {
+		cmd.Flags().StringVar(&encryptionKey, "encryption-key", "", "Encrypt the contents of files written to the repo in object storage with data keys wrapped by this key: awskms://<key ID, ARN or alias>, gcpkms://projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key> or vault://<transit mount>/<key>, or none (default pachd's setting).")
+	}
 	createRepo := &cobra.Command{
 		Use:   "create-repo repo-name",
 		Short: "Create a new repo.",
@@ -71,40 +129,75 @@ func Cmds(noMetrics *bool) []*cobra.Command {
 				return err
 			}
 			defer c.Close()
-			_, err = c.PfsAPIClient.CreateRepo(
-				c.Ctx(),
-				&pfsclient.CreateRepoRequest{
-					Repo:        client.NewRepo(args[0]),
-					Description: description,
-				},
-			)
+			request := &pfsclient.CreateRepoRequest{
+				Repo:        client.NewRepo(args[0]),
+				Description: description,
+				Retention:   retention(),
+			}
+			if compressionName != "" {
+				if request.Compression, err = compression(); err != nil {
+					return err
+				}
+			} else if compressionLevel != 0 {
+				return fmt.Errorf("--compression-level must be used with --compression")
+			}
+			if encryptionKey != "" {
+				request.Encryption = encryption()
+			}
+			_, err = c.PfsAPIClient.CreateRepo(c.Ctx(), request)
 			return grpcutil.ScrubGRPC(err)
 		}),
 	}
 	createRepo.Flags().StringVarP(&description, "description", "d", "", "A description of the repo.")
+	addRetentionFlags(createRepo)
+	addCompressionFlags(createRepo)
+	addEncryptionFlags(createRepo)
 
-	updateRepo := &cobra.Command{
+	var updateRepo *cobra.Command
+	updateRepo = &cobra.Command{
 		Use:   "update-repo repo-name",
 		Short: "Update a repo.",
-		Long:  "Update a repo.",
+		Long: `Update a repo.
+
+The repo's retention policy is only changed if a retention flag is given;
+passing "--keep-commits 0 --keep-for 0" removes it. Likewise, the repo's
+compression and encryption are only changed if --compression or
+--encryption-key is given, and only apply to files written after they're
+changed (changing the encryption key requires OWNER access).`,
 		Run: cmdutil.RunFixedArgs(1, func(args []string) error {
 			c, err := client.NewOnUserMachine(metrics, true, "user")
 			if err != nil {
 				return err
 			}
 			defer c.Close()
-			_, err = c.PfsAPIClient.CreateRepo(
-				c.Ctx(),
-				&pfsclient.CreateRepoRequest{
-					Repo:        client.NewRepo(args[0]),
-					Description: description,
-					Update:      true,
-				},
-			)
+			request := &pfsclient.CreateRepoRequest{
+				Repo:        client.NewRepo(args[0]),
+				Description: description,
+				Update:      true,
+			}
+			for _, flag := range []string{"keep-commits", "keep-for", "retention-branch"} {
+				if updateRepo.Flags().Changed(flag) {
+					request.Retention = retention()
+				}
+			}
+			if updateRepo.Flags().Changed("compression") {
+				if request.Compression, err = compression(); err != nil {
+					return err
+				}
+			} else if updateRepo.Flags().Changed("compression-level") {
+				return fmt.Errorf("--compression-level must be used with --compression")
+			}
+			if updateRepo.Flags().Changed("encryption-key") {
+				request.Encryption = encryption()
+			}
+			_, err = c.PfsAPIClient.CreateRepo(c.Ctx(), request)
 			return grpcutil.ScrubGRPC(err)
 		}),
 	}
 	updateRepo.Flags().StringVarP(&description, "description", "d", "", "A description of the repo.")
+	addRetentionFlags(updateRepo)
+	addCompressionFlags(updateRepo)
+	addEncryptionFlags(updateRepo)
 
 	inspectRepo := &cobra.Command{
 		Use:   "inspect-repo repo-name",
@@ -203,6 +296,148 @@ func Cmds(noMetrics *bool) []*cobra.Command {
 	deleteRepo.Flags().BoolVarP(&force, "force", "f", false, "remove the repo regardless of errors; use with care")
 	deleteRepo.Flags().BoolVar(&all, "all", false, "remove all repos")
 
+	var maxBytes string
+	var maxFiles uint64
+	setRepoQuota := &cobra.Command{
+		Use:   "set-repo-quota repo-name",
+		Short: "Set the quota of a repo.",
+		Long: `Set the quota of a repo, which limits the data that users can write to it. Running set-repo-quota with no flags removes the repo's quota. Only cluster admins may set quotas.
+
+Examples:
+
+` + codestart + `# Limit "images" to 100GB, and its commits to a million files each
+$ pachctl set-repo-quota images --max-bytes 100GB --max-files 1000000
+` + codeend,
+		Run: cmdutil.RunFixedArgs(1, func(args []string) error {
+			quota := &pfsclient.RepoQuota{MaxFiles: maxFiles}
+			if maxBytes != "" {
+				n, err := units.RAMInBytes(maxBytes)
+				if err != nil {
+					return fmt.Errorf("invalid --max-bytes: %v", err)
+				}
+				quota.MaxBytes = uint64(n)
+			}
+			c, err := client.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			return c.SetRepoQuota(args[0], quota)
+		}),
+	}
+	setRepoQuota.Flags().StringVar(&maxBytes, "max-bytes", "", "The largest size that the repo may have, e.g. 100GB.")
+	setRepoQuota.Flags().Uint64Var(&maxFiles, "max-files", 0, "The largest number of files that a commit in the repo may contain.")
+
+	var schemaGlob string
+	var jsonSchema string
+	var csvColumns []string
+	var csvDelimiter string
+	var descriptorSet string
+	var protoMessage string
+	var protoDelimited bool
+	var flagInvalid bool
+	setRepoSchema := &cobra.Command{
+		Use:   "set-repo-schema repo-name",
+		Short: "Set the schema of a repo.",
+		Long: `Set the schema of a repo, which the files written to it are validated against when their commit is finished. Commits with files that don't match the schema are rejected, unless --flag is given, in which case they're finished and the files are listed when the commit is inspected. Running set-repo-schema with no schema removes the repo's schema. Only the repo's owners may set its schema.
+
+Examples:
+
+` + codestart + `# Require that the CSV files in "sales" have the columns date, item and price
+$ pachctl set-repo-schema sales --glob '**.csv' --csv-columns date,item,price
+
+# Flag the JSON files in "events" that don't match event-schema.json
+$ pachctl set-repo-schema events --json-schema event-schema.json --flag
+
+# Require that the files in "images" are serialized image.Image protos
+$ protoc --include_imports --descriptor_set_out=image.pb image.proto
+$ pachctl set-repo-schema images --proto-descriptor-set image.pb --proto-message image.Image
+` + codeend,
+		Run: cmdutil.RunFixedArgs(1, func(args []string) error {
+			schema := &pfsclient.RepoSchema{Glob: schemaGlob}
+			if flagInvalid {
+				schema.Action = pfsclient.SchemaAction_FLAG
+			}
+			if jsonSchema != "" {
+				data, err := ioutil.ReadFile(jsonSchema)
+				if err != nil {
+					return err
+				}
+				schema.Json = &pfsclient.JSONSchema{Schema: string(data)}
+			}
+			if len(csvColumns) > 0 {
+				schema.Csv = &pfsclient.CSVSchema{Columns: csvColumns, Delimiter: csvDelimiter}
+			}
+			if descriptorSet != "" {
+				data, err := ioutil.ReadFile(descriptorSet)
+				if err != nil {
+					return err
+				}
+				schema.Proto = &pfsclient.ProtoSchema{
+					DescriptorSet: data,
+					Message:       protoMessage,
+					Delimited:     protoDelimited,
+				}
+			}
+			if schema.Json == nil && schema.Csv == nil && schema.Proto == nil {
+				schema = nil
+			}
+			c, err := client.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			return c.SetRepoSchema(args[0], schema)
+		}),
+	}
+	setRepoSchema.Flags().StringVar(&schemaGlob, "glob", "", "The files that the schema applies to (by default, all files).")
+	setRepoSchema.Flags().StringVar(&jsonSchema, "json-schema", "", "A file holding a JSON Schema that each JSON value in the files must match.")
+	setRepoSchema.Flags().StringSliceVar(&csvColumns, "csv-columns", nil, "The columns of the CSV files, which must be in their header row.")
+	setRepoSchema.Flags().StringVar(&csvDelimiter, "csv-delimiter", "", "The field delimiter of the CSV files (\",\" by default).")
+	setRepoSchema.Flags().StringVar(&descriptorSet, "proto-descriptor-set", "", "A file holding a serialized FileDescriptorSet with the type of the protobuf messages in the files.")
+	setRepoSchema.Flags().StringVar(&protoMessage, "proto-message", "", "The fully-qualified name of the type of the protobuf messages in the files.")
+	setRepoSchema.Flags().BoolVar(&protoDelimited, "proto-delimited", false, "The files contain a sequence of protobuf messages, each preceded by its varint-encoded size.")
+	setRepoSchema.Flags().BoolVar(&flagInvalid, "flag", false, "Finish commits with files that don't match the schema, and list the files in the commits, rather than rejecting the commits.")
+
+	inspectStorage := &cobra.Command{
+		Use:   "inspect-storage [repo-name...]",
+		Short: "Report how much object storage PFS uses.",
+		Long: `Report how much object storage PFS uses, broken down by repo. A repo's logical size is the total size of the distinct versions of the files in its commits, and its physical size is the size of the objects that hold them, after deduplication and compression. Orphaned objects are objects that no commit references, which garbage collection frees. Only cluster admins may inspect storage, and doing so reads every commit, so it may be slow.
+
+Examples:
+
+` + codestart + `# Report the storage used by all repos
+$ pachctl inspect-storage
+
+# Only break down the storage used by "images" and "edges"
+$ pachctl inspect-storage images edges
+` + codeend,
+		Run: cmdutil.Run(func(args []string) error {
+			c, err := client.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			storageInfo, err := c.InspectStorage(args...)
+			if err != nil {
+				return err
+			}
+			if raw {
+				return marshaller.Marshal(os.Stdout, storageInfo)
+			}
+			writer := tabwriter.NewWriter(os.Stdout, pretty.RepoStorageHeader)
+			for _, repoStorageInfo := range storageInfo.Repos {
+				pretty.PrintRepoStorageInfo(writer, repoStorageInfo)
+			}
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			fmt.Println()
+			return pretty.PrintStorageTotals(storageInfo)
+		}),
+	}
+	rawFlag(inspectStorage)
+
 	commit := &cobra.Command{
 		Use:   "commit",
 		Short: "Docs for commits.",
@@ -494,6 +729,40 @@ $ pachctl subscribe-commit test master --new
 	subscribeCommit.Flags().BoolVar(&new, "new", false, "subscribe to only new commits created from now on")
 	rawFlag(subscribeCommit)
 
+	var subscribeRepos cmdutil.RepeatedStringArg
+	var pattern string
+	subscribeCommits := &cobra.Command{
+		Use:   "subscribe-commits branch",
+		Short: "Print commits in several repos as they are created.",
+		Long: `Print the commits on a branch in several repos, as they are created, in one stream. The repos are given with --repos, or selected by a glob with --pattern (which also selects repos that are created later). By default, all existing commits on the branch are returned first, oldest first across all of the repos.
+
+Examples:
+
+` + codestart + `# subscribe to commits on branch "master" in repos "foo" and "bar"
+$ pachctl subscribe-commits master -r foo -r bar
+
+# subscribe to new commits on branch "master" in every repo whose name starts
+# with "images-"
+$ pachctl subscribe-commits master --pattern "images-*" --new
+` + codeend,
+		Run: cmdutil.RunFixedArgs(1, func(args []string) error {
+			c, err := client.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			commitIter, err := c.SubscribeCommits(subscribeRepos, pattern, args[0], new, pfsclient.CommitState_STARTED)
+			if err != nil {
+				return err
+			}
+			return printCommitIter(commitIter)
+		}),
+	}
+	subscribeCommits.Flags().VarP(&subscribeRepos, "repos", "r", "Subscribe to commits in a specific set of repos")
+	subscribeCommits.Flags().StringVar(&pattern, "pattern", "", "Subscribe to commits in the repos whose names match this glob")
+	subscribeCommits.Flags().BoolVar(&new, "new", false, "subscribe to only new commits created from now on")
+	rawFlag(subscribeCommits)
+
 	deleteCommit := &cobra.Command{
 		Use:   "delete-commit repo-name commit-id",
 		Short: "Delete an input commit.",
@@ -508,12 +777,101 @@ $ pachctl subscribe-commit test master --new
 		}),
 	}
 
+	squashCommit := &cobra.Command{
+		Use:   "squash-commit repo-name from-commit-id to-commit-id",
+		Short: "Squash a range of commits into one commit.",
+		Long: `Squash the commits from from-commit-id through to-commit-id into one commit. The files in to-commit-id are unchanged, but the other commits in the range are deleted, and the parent of from-commit-id becomes the parent of to-commit-id. The commits in the range must be finished input commits, and only to-commit-id may be the head of a branch or have more than one child. The deleted commits' data is freed by the next garbage-collect.
+
+Examples:
+
+` + codestart + `# Squash the history of master, from commit XXX to the head, into one commit
+$ pachctl squash-commit test XXX master
+` + codeend,
+		Run: cmdutil.RunFixedArgs(3, func(args []string) error {
+			client, err := client.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			return client.SquashCommit(args[0], args[1], args[2])
+		}),
+	}
+
+	var direction string
+	var maxDepth int64
+	var provenanceRepos cmdutil.RepeatedStringArg
+	provenanceCommit := &cobra.Command{
+		Use:   "provenance-commit repo-name commit-id",
+		Short: "Return the provenance graph around a commit.",
+		Long: `Return the commits upstream and/or downstream of a commit, as a list of edges. Each edge connects a commit to a commit in its direct provenance.
+
+Examples:
+
+` + codestart + `# return the commits that foo/master was computed from
+$ pachctl provenance-commit foo master
+
+# return the commits computed from foo/XXX, up to two pipelines downstream
+$ pachctl provenance-commit foo XXX --direction downstream --depth 2
+
+# return the lineage of foo/master, showing only commits in repos foo and bar
+$ pachctl provenance-commit foo master --direction both -r foo -r bar
+` + codeend,
+		Run: cmdutil.RunFixedArgs(2, func(args []string) error {
+			opts := &client.ProvenanceQueryOptions{
+				MaxDepth: maxDepth,
+				Repos:    provenanceRepos,
+			}
+			switch direction {
+			case "upstream":
+				opts.Direction = pfsclient.ProvenanceDirection_UPSTREAM
+			case "downstream":
+				opts.Direction = pfsclient.ProvenanceDirection_DOWNSTREAM
+			case "both":
+				opts.Direction = pfsclient.ProvenanceDirection_BOTH
+			default:
+				return fmt.Errorf("invalid direction %q, must be upstream, downstream or both", direction)
+			}
+			c, err := client.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			response, err := c.ProvenanceQuery(args[0], args[1], opts)
+			if err != nil {
+				return err
+			}
+			if raw {
+				return marshaller.Marshal(os.Stdout, response)
+			}
+			writer := tabwriter.NewWriter(os.Stdout, pretty.ProvenanceEdgeHeader)
+			for _, edge := range response.Edges {
+				pretty.PrintProvenanceEdge(writer, edge)
+			}
+			return writer.Flush()
+		}),
+	}
+	provenanceCommit.Flags().StringVarP(&direction, "direction", "d", "upstream", "The direction to follow from the commit: upstream, downstream or both.")
+	provenanceCommit.Flags().Int64Var(&maxDepth, "depth", 0, "The number of provenance relationships to follow from the commit (0 for no limit).")
+	provenanceCommit.Flags().VarP(&provenanceRepos, "repos", "r", "Return only commits in a specific set of repos (and the commit itself).")
+	rawFlag(provenanceCommit)
+
 	var branchProvenance cmdutil.RepeatedStringArg
 	var head string
+	var trigger pfsclient.Trigger
+	var triggerSize string
 	createBranch := &cobra.Command{
 		Use:   "create-branch <repo-name> <branch-name> [flags]",
 		Short: "Create a new branch, or update an existing branch, on a repo.",
-		Long:  "Create a new branch, or update an existing branch, on a repo, starting a commit on the branch will also create it, so there's often no need to call this.",
+		Long: `Create a new branch, or update an existing branch, on a repo, starting a commit on the branch will also create it, so there's often no need to call this.
+
+A branch with a trigger follows another branch, but only moves to its head once the trigger's conditions are met, so that pipelines that take the branch as input process many small commits at once.
+
+Examples:
+
+` + codestart + `# Move "staging" to the head of "master" once 100 commits or 1GB have been
+# added to "master", or at midnight if any commits have been added
+$ pachctl create-branch images staging --trigger master --trigger-commits 100 --trigger-size 1GB --trigger-cron "@daily"
+` + codeend,
 		Run: cmdutil.RunFixedArgs(2, func(args []string) error {
 			client, err := client.NewOnUserMachine(metrics, true, "user")
 			if err != nil {
@@ -524,11 +882,29 @@ $ pachctl subscribe-commit test master --new
 			if err != nil {
 				return err
 			}
-			return client.CreateBranch(args[0], args[1], head, provenance)
+			if trigger.Branch == "" {
+				return client.CreateBranch(args[0], args[1], head, provenance)
+			}
+			if len(provenance) > 0 {
+				return fmt.Errorf("a branch with a trigger can't have provenance")
+			}
+			if triggerSize != "" {
+				n, err := units.RAMInBytes(triggerSize)
+				if err != nil {
+					return fmt.Errorf("invalid --trigger-size: %v", err)
+				}
+				trigger.SizeBytes = n
+			}
+			return client.CreateBranchTrigger(args[0], args[1], head, &trigger)
 		}),
 	}
 	createBranch.Flags().VarP(&branchProvenance, "provenance", "p", "The provenance for the branch.")
 	createBranch.Flags().StringVarP(&head, "head", "", "", "The head of the newly created branch.")
+	createBranch.Flags().StringVar(&trigger.Branch, "trigger", "", "The branch that this branch follows, once the trigger's conditions are met.")
+	createBranch.Flags().StringVar(&trigger.CronSpec, "trigger-cron", "", "A cron spec; the trigger is met if the schedule has fired since the branch's head finished.")
+	createBranch.Flags().StringVar(&triggerSize, "trigger-size", "", "The trigger is met once this much data (e.g. 1GB) has been added to the followed branch.")
+	createBranch.Flags().Int64Var(&trigger.Commits, "trigger-commits", 0, "The trigger is met once this many commits have been added to the followed branch.")
+	createBranch.Flags().BoolVar(&trigger.All, "trigger-all", false, "Only move the branch once all of the trigger's conditions are met, rather than any of them.")
 
 	listBranch := &cobra.Command{
 		Use:   "list-branch repo-name",
@@ -586,20 +962,76 @@ $ pachctl set-branch foo test master` + codeend,
 		}),
 	}
 
+	var cascade bool
+	var deleteCommits bool
 	deleteBranch := &cobra.Command{
 		Use:   "delete-branch repo-name branch-name",
 		Short: "Delete a branch",
-		Long:  "Delete a branch, while leaving the commits intact",
+		Long: `Delete a branch, while leaving the commits intact (unless --delete-commits is given).
+
+Examples:
+
+` + codestart + `# Delete the "experiment" branch of "images", along with the branches
+# downstream of it and the commits that only those branches reach
+$ pachctl delete-branch images experiment --cascade --delete-commits
+` + codeend,
 		Run: cmdutil.RunFixedArgs(2, func(args []string) error {
+			c, err := client.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			return c.DeleteBranchWithOptions(args[0], args[1], &client.DeleteBranchOptions{
+				Force:         force,
+				Cascade:       cascade,
+				DeleteCommits: deleteCommits,
+			})
+		}),
+	}
+	deleteBranch.Flags().BoolVarP(&force, "force", "f", false, "remove the branch regardless of errors; use with care")
+	deleteBranch.Flags().BoolVar(&cascade, "cascade", false, "also delete the branches downstream of the branch")
+	deleteBranch.Flags().BoolVar(&deleteCommits, "delete-commits", false, "also delete the commits that are only reachable from the deleted branches")
+
+	var noDirectWrites bool
+	var noDeleteCommit bool
+	var requiredScope string
+	setBranchProtection := &cobra.Command{
+		Use:   "set-branch-protection repo-name branch-name",
+		Short: "Set the protection of a branch.",
+		Long: `Set the protection of a branch, which restricts the changes users can make to it. Running set-branch-protection with no flags removes the branch's protection. Requires the OWNER scope on the repo.
+
+Examples:
+
+` + codestart + `# Only allow pipelines to write to the "master" branch of "edges", and
+# don't allow its commits to be deleted
+$ pachctl set-branch-protection edges master --no-direct-writes --no-delete-commit
+
+# Require the OWNER scope to change the "master" branch of "images"
+$ pachctl set-branch-protection images master --required-scope OWNER
+` + codeend,
+		Run: cmdutil.RunFixedArgs(2, func(args []string) error {
+			protection := &pfsclient.BranchProtection{
+				NoDirectWrites: noDirectWrites,
+				NoDeleteCommit: noDeleteCommit,
+			}
+			if requiredScope != "" {
+				scope, err := auth.ParseScope(requiredScope)
+				if err != nil {
+					return err
+				}
+				protection.RequiredScope = scope
+			}
 			client, err := client.NewOnUserMachine(metrics, true, "user")
 			if err != nil {
 				return err
 			}
 			defer client.Close()
-			return client.DeleteBranch(args[0], args[1], force)
+			return client.SetBranchProtection(args[0], args[1], protection)
 		}),
 	}
-	deleteBranch.Flags().BoolVarP(&force, "force", "f", false, "remove the branch regardless of errors; use with care")
+	setBranchProtection.Flags().BoolVar(&noDirectWrites, "no-direct-writes", false, "Prevent users from starting commits on the branch or changing its files, so that only pipelines write to it.")
+	setBranchProtection.Flags().BoolVar(&noDeleteCommit, "no-delete-commit", false, "Prevent delete-commit and squash-commit from deleting commits in the branch's history.")
+	setBranchProtection.Flags().StringVar(&requiredScope, "required-scope", "", "The scope (READER, WRITER or OWNER) that users need on the repo to change the branch.")
 
 	file := &cobra.Command{
 		Use:   "file",
@@ -621,6 +1053,7 @@ $ pachctl set-branch foo test master` + codeend,
 	var targetFileDatums uint
 	var targetFileBytes uint
 	var headerRecords uint
+	var appendRecords bool
 	var putFileCommit bool
 	var overwrite bool
 	putFile := &cobra.Command{
@@ -752,19 +1185,19 @@ want to consider using commit IDs directly.
 						return fmt.Errorf("must specify filename when reading data from stdin")
 					}
 					eg.Go(func() error {
-						return putFileHelper(c, pfc, repoName, branch, joinPaths("", source), source, recursive, overwrite, limiter, split, targetFileDatums, targetFileBytes, headerRecords, filesPut)
+						return putFileHelper(c, pfc, repoName, branch, joinPaths("", source), source, recursive, overwrite, limiter, split, targetFileDatums, targetFileBytes, headerRecords, appendRecords, filesPut)
 					})
 				} else if len(sources) == 1 && len(args) == 3 {
 					// We have a single source and the user has specified a path,
 					// we use the path and ignore source (in terms of naming the file).
 					eg.Go(func() error {
-						return putFileHelper(c, pfc, repoName, branch, path, source, recursive, overwrite, limiter, split, targetFileDatums, targetFileBytes, headerRecords, filesPut)
+						return putFileHelper(c, pfc, repoName, branch, path, source, recursive, overwrite, limiter, split, targetFileDatums, targetFileBytes, headerRecords, appendRecords, filesPut)
 					})
 				} else if len(sources) > 1 && len(args) == 3 {
 					// We have multiple sources and the user has specified a path,
 					// we use that path as a prefix for the filepaths.
 					eg.Go(func() error {
-						return putFileHelper(c, pfc, repoName, branch, joinPaths(path, source), source, recursive, overwrite, limiter, split, targetFileDatums, targetFileBytes, headerRecords, filesPut)
+						return putFileHelper(c, pfc, repoName, branch, joinPaths(path, source), source, recursive, overwrite, limiter, split, targetFileDatums, targetFileBytes, headerRecords, appendRecords, filesPut)
 					})
 				}
 			}
@@ -775,13 +1208,69 @@ want to consider using commit IDs directly.
 	putFile.Flags().StringVarP(&inputFile, "input-file", "i", "", "Read filepaths or URLs from a file.  If - is used, paths are read from the standard input.")
 	putFile.Flags().BoolVarP(&recursive, "recursive", "r", false, "Recursively put the files in a directory.")
 	putFile.Flags().IntVarP(&parallelism, "parallelism", "p", DefaultParallelism, "The maximum number of files that can be uploaded in parallel.")
-	putFile.Flags().StringVar(&split, "split", "", "Split the input file into smaller files, subject to the constraints of --target-file-datums and --target-file-bytes. Permissible values are `json` and `line`.")
+	putFile.Flags().StringVar(&split, "split", "", "Split the input file into smaller files, subject to the constraints of --target-file-datums and --target-file-bytes. Permissible values are `json`, `line`, `sql`, `csv` and `proto` (varint length-prefixed protobuf messages).")
 	putFile.Flags().UintVar(&targetFileDatums, "target-file-datums", 0, "The upper bound of the number of datums that each file contains, the last file will contain fewer if the datums don't divide evenly; needs to be used with --split.")
 	putFile.Flags().UintVar(&targetFileBytes, "target-file-bytes", 0, "The target upper bound of the number of bytes that each file contains; needs to be used with --split.")
 	putFile.Flags().UintVar(&headerRecords, "header-records", 0, "the number of records that will be converted to a PFS 'header', and prepended to future retrievals of any subset of data from PFS; needs to be used with --split=(json|line|csv)")
+	putFile.Flags().BoolVar(&appendRecords, "append-records", false, "Append the input to a single file rather than splitting it, after checking that it consists of complete records delimited by --split (json, line, csv or proto). Line, json and csv input must end with a newline.")
 	putFile.Flags().BoolVarP(&putFileCommit, "commit", "c", false, "DEPRECATED: Put file(s) in a new commit.")
 	putFile.Flags().BoolVarP(&overwrite, "overwrite", "o", false, "Overwrite the existing content of the file, either from previous commits or previous calls to put-file within this commit.")
 
+	var tarPath string
+	putFileTar := &cobra.Command{
+		Use:   "put-file-tar repo-name branch [path/to/dir]",
+		Short: "Put the files in a tar archive into the filesystem.",
+		Long: `Put the regular files in a tar (or tar.gz) archive into the filesystem,
+under path/to/dir (or the root of the commit), preserving their paths and modes.
+The archive is expanded by pachd, so a directory tree can be put without a
+request per file.
+` + codestart + `# Put the files in dir.tar.gz in repo "foo" on branch "master", under "dir"
+$ pachctl put-file-tar foo master dir -f dir.tar.gz
+
+# Put a directory that's archived on the fly
+$ tar -C dir -c . | pachctl put-file-tar foo master dir
+` + codeend,
+		Run: cmdutil.RunBoundedArgs(2, 3, func(args []string) (retErr error) {
+			var path string
+			if len(args) == 3 {
+				path = args[2]
+			}
+			var r io.Reader = os.Stdin
+			if tarPath != "-" {
+				f, err := os.Open(tarPath)
+				if err != nil {
+					return err
+				}
+				defer func() {
+					if err := f.Close(); err != nil && retErr == nil {
+						retErr = err
+					}
+				}()
+				r = f
+			}
+			// tar.gz archives are decompressed here, as pachd expects a tar
+			// archive
+			br := bufio.NewReader(r)
+			r = br
+			if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+				gr, err := gzip.NewReader(br)
+				if err != nil {
+					return err
+				}
+				r = gr
+			}
+			c, err := client.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			_, err = c.PutFileTar(args[0], args[1], path, r, overwrite)
+			return err
+		}),
+	}
+	putFileTar.Flags().StringVarP(&tarPath, "file", "f", "-", "The tar or tar.gz archive to put (by default, it's read from stdin).")
+	putFileTar.Flags().BoolVarP(&overwrite, "overwrite", "o", false, "Overwrite the existing content of the files in the archive, rather than appending to it.")
+
 	copyFile := &cobra.Command{
 		Use:   "copy-file src-repo src-commit src-path dst-repo dst-commit dst-path",
 		Short: "Copy files between pfs paths.",
@@ -797,6 +1286,20 @@ want to consider using commit IDs directly.
 	}
 	copyFile.Flags().BoolVarP(&overwrite, "overwrite", "o", false, "Overwrite the existing content of the file, either from previous commits or previous calls to put-file within this commit.")
 
+	moveFile := &cobra.Command{
+		Use:   "move-file repo-name commit-id src-path dst-path",
+		Short: "Move a file or directory within an open commit.",
+		Long:  "Move a file or directory within an open commit, overwriting anything at dst-path. Only metadata is changed, so no data is copied.",
+		Run: cmdutil.RunFixedArgs(4, func(args []string) (retErr error) {
+			c, err := client.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			return c.MoveFile(args[0], args[1], args[2], args[3])
+		}),
+	}
+
 	var outputPath string
 	getFile := &cobra.Command{
 		Use:   "get-file repo-name commit-id path/to/file",
@@ -845,6 +1348,152 @@ $ pachctl get-file foo master^2 XXX
 	getFile.Flags().StringVarP(&outputPath, "output", "o", "", "The path where data will be downloaded.")
 	getFile.Flags().IntVarP(&parallelism, "parallelism", "p", DefaultParallelism, "The maximum number of files that can be downloaded in parallel")
 
+	var archiveFormat string
+	getCommitArchive := &cobra.Command{
+		Use:   "get-commit-archive repo-name commit-id [path/to/dir]",
+		Short: "Download a commit (or a directory in it) as one archive.",
+		Long: `Download the files in a commit (or under a directory in it) as one
+tar.gz or zip archive, assembled by pachd. Paths in the archive are relative to
+the root of the commit.
+` + codestart + `# download the head of branch "master" in repo "foo" as a tar.gz archive
+$ pachctl get-commit-archive foo master -o foo.tar.gz
+
+# download directory "XXX" on branch "master" in repo "foo" as a zip archive
+$ pachctl get-commit-archive foo master XXX -o XXX.zip
+` + codeend,
+		Run: cmdutil.RunBoundedArgs(2, 3, func(args []string) error {
+			format := archiveFormat
+			if format == "" {
+				format = "tar.gz"
+				if strings.HasSuffix(outputPath, ".zip") {
+					format = "zip"
+				}
+			}
+			var pfsFormat pfsclient.ArchiveFormat
+			switch format {
+			case "tar.gz", "tgz":
+				pfsFormat = pfsclient.ArchiveFormat_TAR_GZ
+			case "zip":
+				pfsFormat = pfsclient.ArchiveFormat_ZIP
+			default:
+				return fmt.Errorf("unrecognized archive format %q (must be tar.gz or zip)", format)
+			}
+			var path string
+			if len(args) == 3 {
+				path = args[2]
+			}
+			client, err := client.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			var w io.Writer = os.Stdout
+			if outputPath != "" {
+				f, err := os.Create(outputPath)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			return client.GetCommitArchive(args[0], args[1], path, pfsFormat, w)
+		}),
+	}
+	getCommitArchive.Flags().StringVarP(&outputPath, "output", "o", "", "The path to which the archive is written (by default, it's written to stdout).")
+	getCommitArchive.Flags().StringVar(&archiveFormat, "format", "", "The archive's format: tar.gz or zip. By default, it's zip if the output path ends in .zip, and tar.gz otherwise.")
+
+	var manifestFormat string
+	getCommitManifest := &cobra.Command{
+		Use:   "get-commit-manifest repo-name commit-id [path/to/dir]",
+		Short: "Write a manifest of the files in a commit.",
+		Long: `Write a manifest of the files in a commit (or under a directory in it),
+listing each file's path, size, hash, checksums and metadata, for use by
+auditing and data quality tools. Manifests can be compared with diff-manifest.
+` + codestart + `# write a manifest of the head of branch "master" in repo "foo"
+$ pachctl get-commit-manifest foo master -o manifest.json
+
+# write a manifest of directory "XXX" on branch "master" as CSV
+$ pachctl get-commit-manifest foo master XXX --format csv
+` + codeend,
+		Run: cmdutil.RunBoundedArgs(2, 3, func(args []string) error {
+			var pfsFormat pfsclient.ManifestFormat
+			switch manifestFormat {
+			case "json":
+				pfsFormat = pfsclient.ManifestFormat_MANIFEST_JSON
+			case "csv":
+				pfsFormat = pfsclient.ManifestFormat_MANIFEST_CSV
+			default:
+				return fmt.Errorf("unrecognized manifest format %q (must be json or csv)", manifestFormat)
+			}
+			var path string
+			if len(args) == 3 {
+				path = args[2]
+			}
+			client, err := client.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			var w io.Writer = os.Stdout
+			if outputPath != "" {
+				f, err := os.Create(outputPath)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			return client.GetCommitManifest(args[0], args[1], path, pfsFormat, w)
+		}),
+	}
+	getCommitManifest.Flags().StringVarP(&outputPath, "output", "o", "", "The path to which the manifest is written (by default, it's written to stdout).")
+	getCommitManifest.Flags().StringVar(&manifestFormat, "format", "json", "The manifest's format: json (one JSON object per line) or csv.")
+
+	diffManifest := &cobra.Command{
+		Use:   "diff-manifest old-manifest new-manifest",
+		Short: "Compare two manifests written by get-commit-manifest.",
+		Long: `Compare two manifests written by get-commit-manifest (in either format),
+and print the files that were added, removed or modified between them.
+` + codestart + `# compare the manifests of two commits
+$ pachctl get-commit-manifest foo XXX -o old.json
+$ pachctl get-commit-manifest foo YYY -o new.json
+$ pachctl diff-manifest old.json new.json
+` + codeend,
+		Run: cmdutil.RunFixedArgs(2, func(args []string) error {
+			var entries [2][]*pfsclient.ManifestEntry
+			for i, path := range args {
+				f, err := os.Open(path)
+				if err != nil {
+					return err
+				}
+				entries[i], err = manifest.Read(f)
+				f.Close()
+				if err != nil {
+					return fmt.Errorf("error reading %s: %v", path, err)
+				}
+			}
+			changes, err := manifest.Diff(entries[0], entries[1])
+			if err != nil {
+				return err
+			}
+			if raw {
+				encoder := json.NewEncoder(os.Stdout)
+				for _, change := range changes {
+					if err := encoder.Encode(change); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			writer := tabwriter.NewWriter(os.Stdout, pretty.ManifestChangeHeader)
+			for _, change := range changes {
+				pretty.PrintManifestChange(writer, change)
+			}
+			return writer.Flush()
+		}),
+	}
+	rawFlag(diffManifest)
+
 	inspectFile := &cobra.Command{
 		Use:   "inspect-file repo-name commit-id path/to/file",
 		Short: "Return info about a file.",
@@ -871,6 +1520,8 @@ $ pachctl get-file foo master^2 XXX
 	rawFlag(inspectFile)
 
 	var history int64
+	var minSize, maxSize, modifiedSince string
+	var metadata []string
 	listFile := &cobra.Command{
 		Use:   "list-file repo-name commit-id path/to/dir",
 		Short: "Return the files in a directory.",
@@ -897,6 +1548,13 @@ $ pachctl list-file foo master --history n
 
 # list all versions of top-level files on branch "master" in repo "foo"
 $ pachctl list-file foo master --history -1
+
+# list the files under "dir" that are larger than 1GB and were added or
+# changed since the commit "XXX"
+$ pachctl list-file foo master dir --min-size 1GB --modified-since XXX
+
+# list the top-level files whose metadata has the label "split=train"
+$ pachctl list-file foo master --metadata split=train
 ` + codeend,
 		Run: cmdutil.RunBoundedArgs(2, 3, func(args []string) error {
 			client, err := client.NewOnUserMachine(metrics, true, "user")
@@ -908,13 +1566,17 @@ $ pachctl list-file foo master --history -1
 			if len(args) == 3 {
 				path = args[2]
 			}
+			filter, err := fileFilter(args[0], minSize, maxSize, modifiedSince, metadata)
+			if err != nil {
+				return err
+			}
 			if raw {
-				return client.ListFileF(args[0], args[1], path, history, func(fi *pfsclient.FileInfo) error {
+				return client.ListFileFilteredF(args[0], args[1], path, history, filter, func(fi *pfsclient.FileInfo) error {
 					return marshaller.Marshal(os.Stdout, fi)
 				})
 			}
 			writer := tabwriter.NewWriter(os.Stdout, pretty.FileHeader)
-			if err := client.ListFileF(args[0], args[1], path, history, func(fi *pfsclient.FileInfo) error {
+			if err := client.ListFileFilteredF(args[0], args[1], path, history, filter, func(fi *pfsclient.FileInfo) error {
 				pretty.PrintFileInfo(writer, fi)
 				return nil
 			}); err != nil {
@@ -925,7 +1587,12 @@ $ pachctl list-file foo master --history -1
 	}
 	rawFlag(listFile)
 	listFile.Flags().Int64Var(&history, "history", 0, "Return revision history for files.")
+	listFile.Flags().StringVar(&minSize, "min-size", "", "Only return files at least this large (e.g. 1GB).")
+	listFile.Flags().StringVar(&maxSize, "max-size", "", "Only return files at most this large (e.g. 10MB).")
+	listFile.Flags().StringVar(&modifiedSince, "modified-since", "", "Only return files that were added or changed since this commit.")
+	listFile.Flags().StringArrayVar(&metadata, "metadata", nil, "Only return files with this metadata, given as key=value (may be repeated).")
 
+	var regex bool
 	globFile := &cobra.Command{
 		Use:   "glob-file repo-name commit-id pattern",
 		Short: "Return files that match a glob pattern in a commit.",
@@ -942,6 +1609,12 @@ $ pachctl glob-file foo master "A*"
 
 # Return files in repo "foo" on branch "master" under directory "data".
 $ pachctl glob-file foo master "data/*"
+
+# Return the CSV files in the "train" and "test" directories.
+$ pachctl glob-file foo master "{train,test}/**.csv"
+
+# Return the same files using a regular expression.
+$ pachctl glob-file foo master --regex "/(train|test)/.*\.csv"
 ` + codeend,
 		Run: cmdutil.RunFixedArgs(3, func(args []string) error {
 			client, err := client.NewOnUserMachine(metrics, true, "user")
@@ -949,7 +1622,11 @@ $ pachctl glob-file foo master "data/*"
 				return err
 			}
 			defer client.Close()
-			fileInfos, err := client.GlobFile(args[0], args[1], args[2])
+			globFile := client.GlobFile
+			if regex {
+				globFile = client.GlobFileRegex
+			}
+			fileInfos, err := globFile(args[0], args[1], args[2])
 			if err != nil {
 				return err
 			}
@@ -968,8 +1645,12 @@ $ pachctl glob-file foo master "data/*"
 		}),
 	}
 	rawFlag(globFile)
+	globFile.Flags().BoolVar(&regex, "regex", false, "Interpret the pattern as a regular expression that must match the whole of each path, rather than a glob.")
 
 	var shallow bool
+	var content bool
+	var maxDiffBytes int64
+	var renames bool
 	diffFile := &cobra.Command{
 		Use:   "diff-file new-repo-name new-commit-id new-path [old-repo-name old-commit-id old-path]",
 		Short: "Return a diff of two file trees.",
@@ -982,50 +1663,71 @@ $ pachctl diff-file foo master path
 
 # Return the diff between foo master path1 and bar master path2.
 $ pachctl diff-file foo master path1 bar master path2
+
+# Return the diff between foo master path and its parent, including the
+# changes to the contents of files and the files that were renamed.
+$ pachctl diff-file foo master path --content --renames
 ` + codeend,
 		Run: cmdutil.RunBoundedArgs(3, 6, func(args []string) error {
-			client, err := client.NewOnUserMachine(metrics, true, "user")
+			c, err := client.NewOnUserMachine(metrics, true, "user")
 			if err != nil {
 				return err
 			}
-			defer client.Close()
-			var newFiles []*pfsclient.FileInfo
-			var oldFiles []*pfsclient.FileInfo
+			defer c.Close()
+			opts := &client.DiffFileOptions{
+				Shallow:             shallow,
+				Content:             content,
+				MaxContentDiffBytes: maxDiffBytes,
+				DetectRenames:       renames,
+			}
+			var resp *pfsclient.DiffFileResponse
 			switch {
 			case len(args) == 3:
-				newFiles, oldFiles, err = client.DiffFile(args[0], args[1], args[2], "", "", "", shallow)
+				resp, err = c.DiffFileWithOptions(args[0], args[1], args[2], "", "", "", opts)
 			case len(args) == 6:
-				newFiles, oldFiles, err = client.DiffFile(args[0], args[1], args[2], args[3], args[4], args[5], shallow)
+				resp, err = c.DiffFileWithOptions(args[0], args[1], args[2], args[3], args[4], args[5], opts)
 			default:
 				return fmt.Errorf("diff-file expects either 3 or 6 args, got %d", len(args))
 			}
 			if err != nil {
 				return err
 			}
-			if len(newFiles) > 0 {
+			if len(resp.NewFiles) > 0 {
 				fmt.Println("New Files:")
 				writer := tabwriter.NewWriter(os.Stdout, pretty.FileHeader)
-				for _, fileInfo := range newFiles {
+				for _, fileInfo := range resp.NewFiles {
 					pretty.PrintFileInfo(writer, fileInfo)
 				}
 				if err := writer.Flush(); err != nil {
 					return err
 				}
 			}
-			if len(oldFiles) > 0 {
+			if len(resp.OldFiles) > 0 {
 				fmt.Println("Old Files:")
 				writer := tabwriter.NewWriter(os.Stdout, pretty.FileHeader)
-				for _, fileInfo := range oldFiles {
+				for _, fileInfo := range resp.OldFiles {
 					pretty.PrintFileInfo(writer, fileInfo)
 				}
 				if err := writer.Flush(); err != nil {
 					return err
 				}
 			}
+			if len(resp.Renames) > 0 {
+				fmt.Println("Renamed Files:")
+				for _, rename := range resp.Renames {
+					fmt.Printf("%s -> %s\n", rename.OldFile.File.Path, rename.NewFile.File.Path)
+				}
+			}
+			for _, contentDiff := range resp.ContentDiffs {
+				fmt.Print(contentDiff.UnifiedDiff)
+			}
 			return nil
 		}),
 	}
 	diffFile.Flags().BoolVarP(&shallow, "shallow", "s", false, "Specifies whether or not to diff subdirectories")
+	diffFile.Flags().BoolVar(&content, "content", false, "Print unified diffs of the contents of changed files.")
+	diffFile.Flags().Int64Var(&maxDiffBytes, "max-diff-size", 0, "The size, in bytes, of the largest file whose contents --content diffs (1MB if 0).")
+	diffFile.Flags().BoolVar(&renames, "renames", false, "Detect files that were renamed (removed files with the same contents as an added file).")
 
 	deleteFile := &cobra.Command{
 		Use:   "delete-file repo-name commit-id path/to/file",
@@ -1071,11 +1773,67 @@ $ pachctl diff-file foo master path1 bar master path2
 
 	var debug bool
 	var commits cmdutil.RepeatedStringArg
+	var cacheDir string
+	var cacheSize string
+	var readAhead string
+	var writeBack bool
+	var readWrite bool
+	// mountOptions returns the fuse options set by the flags shared by mount
+	// and mount-daemon
+	mountOptions := func() (*fuse.Options, error) {
+		cacheSizeBytes, err := units.RAMInBytes(cacheSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --cache-size: %v", err)
+		}
+		var readAheadBytes int64
+		if readAhead != "" {
+			if readAheadBytes, err = units.RAMInBytes(readAhead); err != nil {
+				return nil, fmt.Errorf("invalid --read-ahead: %v", err)
+			}
+		}
+		return &fuse.Options{
+			Fuse: &nodefs.Options{
+				Debug: debug,
+			},
+			CacheDir:  cacheDir,
+			CacheSize: cacheSizeBytes,
+			ReadAhead: readAheadBytes,
+			WriteBack: writeBack,
+			ReadWrite: readWrite,
+		}, nil
+	}
 	mount := &cobra.Command{
 		Use:   "mount path/to/mount/point",
 		Short: "Mount pfs locally. This command blocks.",
-		Long:  "Mount pfs locally. This command blocks.",
+		Long: `Mount pfs locally. This command blocks.
+
+Files read through the mount are cached on local disk, so that files which
+are read repeatedly are only downloaded once. The cache may be kept across
+mounts by passing --cache-dir. With --write-back, files in open commits may be
+written, and are uploaded when they're closed. With --write, the first write
+to each repo starts a commit on its branch, and the commits are finished when
+pfs is unmounted, so that each repo's changes appear at once.
+
+Examples:
+
+` + codestart + `# Mount pfs with a 10GB cache that's kept across mounts
+$ pachctl mount /pfs --cache-dir ~/.pachyderm/cache --cache-size 10GB
+
+# Mount the open commit on "master" in repo "foo" and write to it
+$ pachctl start-commit foo master
+$ pachctl mount /pfs --write-back
+$ cp data.csv /pfs/foo/
+
+# Edit "master" in repo "foo", committing the changes when pfs is unmounted
+$ pachctl mount /pfs --write
+$ cp data.csv /pfs/foo/
+$ pachctl unmount /pfs
+` + codeend,
 		Run: cmdutil.RunFixedArgs(1, func(args []string) error {
+			opts, err := mountOptions()
+			if err != nil {
+				return err
+			}
 			client, err := client.NewOnUserMachine(metrics, true, "fuse")
 			if err != nil {
 				return err
@@ -1086,18 +1844,142 @@ $ pachctl diff-file foo master path1 bar master path2
 			if err != nil {
 				return err
 			}
-			opts := &fuse.Options{
-				Fuse: &nodefs.Options{
-					Debug: debug,
-				},
-				Commits: commits,
-			}
+			opts.Commits = commits
 			return fuse.Mount(client, mountPoint, opts)
 		}),
 	}
-	mount.Flags().BoolVarP(&debug, "debug", "d", false, "Turn on debug messages.")
+	addMountFlags := func(cmd *cobra.Command) {
+		cmd.Flags().BoolVarP(&debug, "debug", "d", false, "Turn on debug messages.")
+		cmd.Flags().StringVar(&cacheDir, "cache-dir", "", "The directory to cache files in. If unset, a temporary directory is used and removed when pfs is unmounted.")
+		cmd.Flags().StringVar(&cacheSize, "cache-size", "1GB", "The maximum size of the cache, not counting open files (e.g. 500MB). If 0, files are only cached while they're open.")
+		cmd.Flags().StringVar(&readAhead, "read-ahead", "", "The amount of each file to download in advance of reads (e.g. 64MB). Defaults to 32MB.")
+		cmd.Flags().BoolVar(&writeBack, "write-back", false, "Allow files in open commits to be written. Written files are uploaded when they're closed.")
+		cmd.Flags().BoolVarP(&readWrite, "write", "w", false, "Mount branches read-write. Writes to each repo go to a new commit, which is finished when pfs is unmounted.")
+	}
+	addMountFlags(mount)
 	mount.Flags().VarP(&commits, "commits", "c", "Commits to mount for repos, arguments should be of the form \"repo:commit\"")
 
+	var socket string
+	var branchMounts cmdutil.RepeatedStringArg
+	mountDaemon := &cobra.Command{
+		Use:   "mount-daemon path/to/mount/point",
+		Short: "Mount branches of several repos locally, and manage them at runtime. This command blocks.",
+		Long: `Mount branches of several repos locally, and manage them at runtime. This command blocks.
+
+Each mounted branch appears at <mount point>/<repo>/<name>, where the name
+defaults to the branch's name. Branches are only read from pfs when they're
+accessed. While the daemon runs, branches can be mounted, remapped and
+unmounted with mount-branch, unmount-branch and list-mounts, which talk to the
+daemon over the unix socket given by --socket.
+
+Examples:
+
+` + codestart + `# Mount "master" of repos "images" and "labels"
+$ pachctl mount-daemon /pfs -m images@master -m labels@master
+
+# Mount branch "v2" of "images" at /pfs/images/latest, replacing any mount
+# that's already there
+$ pachctl mount-branch images@v2 latest
+
+# Mount "master" of "labels" read-write, edit it, and commit the changes
+$ pachctl mount-branch labels@master --write
+$ cp labels.csv /pfs/labels/master/
+$ pachctl commit-mount labels master
+` + codeend,
+		Run: cmdutil.RunFixedArgs(1, func(args []string) error {
+			opts, err := mountOptions()
+			if err != nil {
+				return err
+			}
+			client, err := client.NewOnUserMachine(metrics, true, "fuse")
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			m := fuse.NewMountManager(client, opts)
+			for _, arg := range branchMounts {
+				spec, err := parseMountSpec(arg)
+				if err != nil {
+					return err
+				}
+				if err := m.Mount(spec); err != nil {
+					return err
+				}
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			controlErr := make(chan error, 1)
+			go func() {
+				controlErr <- m.ServeControl(ctx, socket)
+			}()
+			if err := m.Serve(args[0]); err != nil {
+				return err
+			}
+			cancel()
+			return <-controlErr
+		}),
+	}
+	addMountFlags(mountDaemon)
+	mountDaemon.Flags().VarP(&branchMounts, "mount", "m", "Branches to mount, arguments should be of the form \"repo@branch\"")
+	mountDaemon.Flags().StringVar(&socket, "socket", defaultMountSocket(), "The unix socket to serve the daemon's control API on.")
+
+	mountBranch := &cobra.Command{
+		Use:   "mount-branch repo@branch [name]",
+		Short: "Mount a branch in a running mount-daemon.",
+		Long:  "Mount a branch in a running mount-daemon, at <mount point>/<repo>/<name>. If there's already a mount with that name, it's remapped to the branch. The name defaults to the branch's name.",
+		Run: cmdutil.RunBoundedArgs(1, 2, func(args []string) error {
+			spec, err := parseMountSpec(args[0])
+			if err != nil {
+				return err
+			}
+			if len(args) == 2 {
+				spec.Name = args[1]
+			}
+			spec.Write = readWrite
+			return fuse.NewControlClient(socket).Mount(spec)
+		}),
+	}
+	mountBranch.Flags().StringVar(&socket, "socket", defaultMountSocket(), "The unix socket that the mount-daemon serves its control API on.")
+	mountBranch.Flags().BoolVarP(&readWrite, "write", "w", false, "Mount the branch read-write. Writes go to a new commit, which is finished by commit-mount, or when the mount is remapped or unmounted.")
+
+	commitMount := &cobra.Command{
+		Use:   "commit-mount repo name",
+		Short: "Commit the writes to a branch in a running mount-daemon.",
+		Long:  "Finish the commit that writes to the read-write mount <mount point>/<repo>/<name> go to, so that they appear on the mount's branch at once. Later writes go to a new commit. Fails if any of the mount's files are open for writing.",
+		Run: cmdutil.RunFixedArgs(2, func(args []string) error {
+			return fuse.NewControlClient(socket).Commit(args[0], args[1])
+		}),
+	}
+	commitMount.Flags().StringVar(&socket, "socket", defaultMountSocket(), "The unix socket that the mount-daemon serves its control API on.")
+
+	unmountBranch := &cobra.Command{
+		Use:   "unmount-branch repo name",
+		Short: "Unmount a branch in a running mount-daemon.",
+		Long:  "Unmount the mount <mount point>/<repo>/<name> in a running mount-daemon.",
+		Run: cmdutil.RunFixedArgs(2, func(args []string) error {
+			return fuse.NewControlClient(socket).Unmount(args[0], args[1])
+		}),
+	}
+	unmountBranch.Flags().StringVar(&socket, "socket", defaultMountSocket(), "The unix socket that the mount-daemon serves its control API on.")
+
+	listMounts := &cobra.Command{
+		Use:   "list-mounts",
+		Short: "Return the branches mounted by a running mount-daemon.",
+		Long:  "Return the branches mounted by a running mount-daemon.",
+		Run: cmdutil.RunFixedArgs(0, func(args []string) error {
+			mounts, err := fuse.NewControlClient(socket).ListMounts()
+			if err != nil {
+				return err
+			}
+			writer := tabwriter.NewWriter(os.Stdout, "REPO\tNAME\tBRANCH\t\n")
+			for _, spec := range mounts {
+				fmt.Fprintf(writer, "%s\t%s\t%s\t\n", spec.Repo, spec.Name, spec.Branch)
+			}
+			return writer.Flush()
+		}),
+	}
+	listMounts.Flags().StringVar(&socket, "socket", defaultMountSocket(), "The unix socket that the mount-daemon serves its control API on.")
+
 	unmount := &cobra.Command{
 		Use:   "unmount path/to/mount/point",
 		Short: "Unmount pfs.",
@@ -1150,6 +2032,44 @@ $ pachctl diff-file foo master path1 bar master path2
 	}
 	unmount.Flags().BoolVarP(&all, "all", "a", false, "unmount all pfs mounts")
 
+	var webdavAddress string
+	serveWebDAV := &cobra.Command{
+		Use:   "serve-webdav",
+		Short: "Serve pfs over WebDAV locally. This command blocks.",
+		Long: `Serve pfs over WebDAV locally. This command blocks.
+
+Repos and their branches are served as read-only WebDAV collections, at
+/<repo>/<branch>/<path>, so that they can be browsed by the WebDAV clients built
+into Windows and macOS, and by other tools that support WebDAV. Commit IDs may be
+used in place of branch names. Files are read with pachctl's credentials.
+
+pachd also serves pfs over WebDAV, at /v1/pfs/webdav/ on its HTTP port, with
+users' Pachyderm tokens as their HTTP basic auth passwords.
+
+Examples:
+
+` + codestart + `# Serve pfs, and mount it on macOS
+$ pachctl serve-webdav --address localhost:8080
+$ mount_webdav http://localhost:8080/ /Volumes/pfs
+
+# Map pfs to a drive on Windows
+$ net use P: http://localhost:8080/
+` + codeend,
+		Run: cmdutil.RunFixedArgs(0, func(args []string) error {
+			c, err := client.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			handler := webdav.NewHandler("/", func(r *http.Request) (*client.APIClient, error) {
+				return c.WithCtx(r.Context()), nil
+			})
+			fmt.Fprintf(os.Stderr, "Serving pfs over WebDAV at http://%s/\n", webdavAddress)
+			return http.ListenAndServe(webdavAddress, handler)
+		}),
+	}
+	serveWebDAV.Flags().StringVar(&webdavAddress, "address", "localhost:8080", "The address to serve WebDAV on.")
+
 	var result []*cobra.Command
 	result = append(result, repo)
 	result = append(result, createRepo)
@@ -1157,6 +2077,9 @@ $ pachctl diff-file foo master path1 bar master path2
 	result = append(result, inspectRepo)
 	result = append(result, listRepo)
 	result = append(result, deleteRepo)
+	result = append(result, setRepoQuota)
+	result = append(result, setRepoSchema)
+	result = append(result, inspectStorage)
 	result = append(result, commit)
 	result = append(result, startCommit)
 	result = append(result, finishCommit)
@@ -1164,15 +2087,24 @@ $ pachctl diff-file foo master path1 bar master path2
 	result = append(result, listCommit)
 	result = append(result, flushCommit)
 	result = append(result, subscribeCommit)
+	result = append(result, subscribeCommits)
 	result = append(result, deleteCommit)
+	result = append(result, squashCommit)
+	result = append(result, provenanceCommit)
 	result = append(result, createBranch)
 	result = append(result, listBranch)
 	result = append(result, setBranch)
 	result = append(result, deleteBranch)
+	result = append(result, setBranchProtection)
 	result = append(result, file)
 	result = append(result, putFile)
+	result = append(result, putFileTar)
 	result = append(result, copyFile)
+	result = append(result, moveFile)
 	result = append(result, getFile)
+	result = append(result, getCommitArchive)
+	result = append(result, getCommitManifest)
+	result = append(result, diffManifest)
 	result = append(result, inspectFile)
 	result = append(result, listFile)
 	result = append(result, globFile)
@@ -1182,9 +2114,29 @@ $ pachctl diff-file foo master path1 bar master path2
 	result = append(result, getTag)
 	result = append(result, mount)
 	result = append(result, unmount)
+	result = append(result, mountDaemon)
+	result = append(result, serveWebDAV)
+	result = append(result, mountBranch)
+	result = append(result, unmountBranch)
+	result = append(result, commitMount)
+	result = append(result, listMounts)
 	return result
 }
 
+// parseMountSpec parses a mount-daemon mount of the form "repo@branch"
+func parseMountSpec(arg string) (fuse.MountSpec, error) {
+	split := strings.Split(arg, "@")
+	if len(split) != 2 || split[0] == "" || split[1] == "" {
+		return fuse.MountSpec{}, fmt.Errorf("malformed input %s, must be of the form repo@branch", arg)
+	}
+	return fuse.MountSpec{Repo: split[0], Branch: split[1]}, nil
+}
+
+// defaultMountSocket returns the default control socket of mount-daemon
+func defaultMountSocket() string {
+	return filepath.Join(os.TempDir(), "pachctl-mount.sock")
+}
+
 func parseCommits(args []string) (map[string]string, error) {
 	result := make(map[string]string)
 	for _, arg := range args {
@@ -1200,7 +2152,7 @@ func parseCommits(args []string) (map[string]string, error) {
 func putFileHelper(c *client.APIClient, pfc client.PutFileClient,
 	repo, commit, path, source string, recursive, overwrite bool, // destination
 	limiter limit.ConcurrencyLimiter,
-	split string, targetFileDatums, targetFileBytes, headerRecords uint, // split
+	split string, targetFileDatums, targetFileBytes, headerRecords uint, appendRecords bool, // split
 	filesPut *gosync.Map) (retErr error) {
 	if _, ok := filesPut.LoadOrStore(path, nil); ok {
 		return fmt.Errorf("multiple files put with the path %s, aborting, "+
@@ -1209,6 +2161,9 @@ func putFileHelper(c *client.APIClient, pfc client.PutFileClient,
 	}
 	putFile := func(reader io.ReadSeeker) error {
 		if split == "" {
+			if appendRecords {
+				return errors.New("--append-records must be used with --split")
+			}
 			if overwrite {
 				return sync.PushFile(c, pfc, client.NewFile(repo, commit, path), reader)
 			}
@@ -1226,9 +2181,18 @@ func putFileHelper(c *client.APIClient, pfc client.PutFileClient,
 			delimiter = pfsclient.Delimiter_SQL
 		case "csv":
 			delimiter = pfsclient.Delimiter_CSV
+		case "proto":
+			delimiter = pfsclient.Delimiter_PROTO
 		default:
 			return fmt.Errorf("unrecognized delimiter '%s'; only accepts one of "+
-				"{json,line,sql,csv}", split)
+				"{json,line,sql,csv,proto}", split)
+		}
+		if appendRecords {
+			if overwrite {
+				return errors.New("--append-records can't be used with --overwrite")
+			}
+			_, err := pfc.PutFileAppendRecords(repo, commit, path, delimiter, reader)
+			return err
 		}
 		_, err := pfc.PutFileSplit(repo, commit, path, delimiter, int64(targetFileDatums), int64(targetFileBytes), int64(headerRecords), overwrite, reader)
 		return err
@@ -1266,7 +2230,7 @@ func putFileHelper(c *client.APIClient, pfc client.PutFileClient,
 				// next one
 				return putFileHelper(c, pfc, repo, commit, childDest, filePath, false,
 					overwrite, limiter, split, targetFileDatums, targetFileBytes,
-					headerRecords, filesPut)
+					headerRecords, appendRecords, filesPut)
 			})
 			return nil
 		}); err != nil {
@@ -1303,3 +2267,40 @@ func joinPaths(prefix, filePath string) string {
 	}
 	return filepath.Join(prefix, filePath)
 }
+
+// fileFilter returns the filter given by list-file's flags, or nil if none of
+// them are set
+func fileFilter(repoName, minSize, maxSize, modifiedSince string, metadata []string) (*pfsclient.FileFilter, error) {
+	if minSize == "" && maxSize == "" && modifiedSince == "" && len(metadata) == 0 {
+		return nil, nil
+	}
+	filter := &pfsclient.FileFilter{}
+	for _, size := range []struct {
+		flag  string
+		value string
+		dest  *uint64
+	}{{"min-size", minSize, &filter.MinSizeBytes}, {"max-size", maxSize, &filter.MaxSizeBytes}} {
+		if size.value == "" {
+			continue
+		}
+		n, err := units.RAMInBytes(size.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s: %v", size.flag, err)
+		}
+		*size.dest = uint64(n)
+	}
+	if modifiedSince != "" {
+		filter.ModifiedSince = client.NewCommit(repoName, modifiedSince)
+	}
+	for _, kv := range metadata {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid --metadata %q, it must be of the form key=value", kv)
+		}
+		if filter.Metadata == nil {
+			filter.Metadata = make(map[string]string)
+		}
+		filter.Metadata[parts[0]] = parts[1]
+	}
+	return filter, nil
+}
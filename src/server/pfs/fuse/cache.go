@@ -0,0 +1,343 @@
+package fuse
+
+import (
+	"container/list"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pachyderm/pachyderm/src/server/pkg/uuid"
+)
+
+// The cache stores the content of the files read through a mount on local
+// disk, so that files that are read repeatedly (e.g. by training jobs that
+// make several passes over their data) are only downloaded once. Files are
+// keyed by the hash of their content, so they're shared across commits, and
+// a cache directory can be reused by later mounts. Each file is downloaded
+// in blocks as it's read, with read-ahead, so reading part of a large file
+// doesn't wait for the whole file to download.
+
+const (
+	// cacheBlockSize is the size of the blocks that files are downloaded in
+	cacheBlockSize = 4 * 1024 * 1024
+	// defaultReadAhead is the default Options.ReadAhead
+	defaultReadAhead = 8 * cacheBlockSize
+	// partialSuffix marks the files in the cache directory that haven't been
+	// downloaded completely. They're removed when the cache is opened.
+	partialSuffix = ".partial"
+)
+
+// a fetchFunc downloads 'size' bytes of a file, starting at 'offset', to 'w'
+type fetchFunc func(offset int64, size int64, w io.Writer) error
+
+type blockState int
+
+const (
+	blockMissing blockState = iota
+	blockFetching
+	blockPresent
+)
+
+type cache struct {
+	dir     string
+	maxSize int64
+
+	// mu guards the cache and all of its entries
+	mu sync.Mutex
+	// size is the total size of the entries, including the parts of partial
+	// entries that haven't been downloaded yet
+	size int64
+	// lru holds the unused entries, least recently used at the back
+	lru     *list.List
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+	// ephemeral entries are removed once they're unused, rather than kept in
+	// the cache
+	ephemeral bool
+
+	// refs counts the open files and downloads that use the entry. 'file'
+	// is open while refs > 0, and 'elem' is set (the entry is in the LRU
+	// list) while refs == 0.
+	refs int
+	file *os.File
+	elem *list.Element
+
+	// blocks is nil once the entry is complete
+	blocks []blockState
+	// err is the error from the most recent failed download
+	err  error
+	cond *sync.Cond
+}
+
+// newCache opens the cache in 'dir', which holds at most 'maxSize' bytes of
+// unused files (in use files may exceed it)
+func newCache(dir string, maxSize int64) (*cache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	c := &cache{
+		dir:     dir,
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[string]*cacheEntry),
+	}
+	// Load the files left by previous mounts, most recently used first
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(fis, func(i, j int) bool { return fis[i].ModTime().After(fis[j].ModTime()) })
+	for _, fi := range fis {
+		if fi.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(fi.Name(), partialSuffix) {
+			if err := os.Remove(filepath.Join(dir, fi.Name())); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		e := &cacheEntry{key: fi.Name(), size: fi.Size()}
+		e.cond = sync.NewCond(&c.mu)
+		e.elem = c.lru.PushBack(e)
+		c.entries[e.key] = e
+		c.size += e.size
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evict()
+	return c, nil
+}
+
+func (c *cache) path(e *cacheEntry) string {
+	if e.blocks != nil {
+		return filepath.Join(c.dir, e.key+partialSuffix)
+	}
+	return filepath.Join(c.dir, e.key)
+}
+
+// acquire returns the entry for the file whose content hash is 'key', and
+// which is 'size' bytes. If 'key' is empty, the file can't be cached, and
+// the entry is removed once it's released. Entries must be released.
+func (c *cache) acquire(key string, size int64) (*cacheEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if ok && e.size != size {
+		// this shouldn't happen, as files are keyed by their content, but
+		// don't use the cache if it does
+		key = ""
+	}
+	if key == "" || !ok {
+		e = &cacheEntry{key: key, size: size}
+		if key == "" {
+			e.key = uuid.NewWithoutDashes()
+			e.ephemeral = true
+		} else {
+			c.entries[key] = e
+		}
+		if size > 0 {
+			e.blocks = make([]blockState, (size+cacheBlockSize-1)/cacheBlockSize)
+		}
+		e.cond = sync.NewCond(&c.mu)
+		c.size += size
+	}
+	if err := c.ref(e); err != nil {
+		return nil, err
+	}
+	c.evict()
+	return e, nil
+}
+
+// ref adds a reference to 'e', opening its file if it's unused. c.mu must
+// be held.
+func (c *cache) ref(e *cacheEntry) error {
+	if e.refs == 0 {
+		f, err := os.OpenFile(c.path(e), os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return err
+		}
+		if e.blocks != nil {
+			// Partial files are sparse until they're downloaded
+			if err := f.Truncate(e.size); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		e.file = f
+		if e.elem != nil {
+			c.lru.Remove(e.elem)
+			e.elem = nil
+		}
+	}
+	e.refs++
+	return nil
+}
+
+// release removes a reference to 'e'
+func (c *cache) release(e *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unref(e)
+}
+
+// unref removes a reference to 'e'. c.mu must be held.
+func (c *cache) unref(e *cacheEntry) {
+	e.refs--
+	if e.refs > 0 {
+		return
+	}
+	e.file.Close()
+	e.file = nil
+	if e.ephemeral {
+		c.remove(e)
+		return
+	}
+	e.elem = c.lru.PushFront(e)
+	c.evict()
+}
+
+// evict removes the least recently used entries until the cache fits in
+// c.maxSize. c.mu must be held.
+func (c *cache) evict() {
+	for c.size > c.maxSize {
+		elem := c.lru.Back()
+		if elem == nil {
+			return
+		}
+		c.remove(elem.Value.(*cacheEntry))
+	}
+}
+
+// remove removes an unused entry from the cache. c.mu must be held.
+func (c *cache) remove(e *cacheEntry) {
+	if e.elem != nil {
+		c.lru.Remove(e.elem)
+		e.elem = nil
+	}
+	if c.entries[e.key] == e {
+		delete(c.entries, e.key)
+	}
+	c.size -= e.size
+	os.Remove(c.path(e))
+}
+
+// read waits until the bytes in [offset, end) of 'e' have been downloaded
+// (using 'fetch'), and starts downloading the 'readAhead' bytes after them.
+// The caller must hold a reference to 'e'.
+func (c *cache) read(e *cacheEntry, fetch fetchFunc, offset int64, end int64, readAhead int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if end > e.size {
+		end = e.size
+	}
+	if offset >= end {
+		return nil
+	}
+	c.fetch(e, fetch, offset, end)
+	c.fetch(e, fetch, end, end+readAhead)
+	first, last := offset/cacheBlockSize, (end-1)/cacheBlockSize
+	for {
+		if e.blocks == nil {
+			return nil
+		}
+		done := true
+		for i := first; i <= last; i++ {
+			switch e.blocks[i] {
+			case blockMissing:
+				// the download of this block failed
+				return e.err
+			case blockFetching:
+				done = false
+			}
+		}
+		if done {
+			return nil
+		}
+		e.cond.Wait()
+	}
+}
+
+// fetch starts downloading the missing blocks of 'e' in [offset, end).
+// c.mu must be held.
+func (c *cache) fetch(e *cacheEntry, fetch fetchFunc, offset int64, end int64) {
+	if e.blocks == nil {
+		return
+	}
+	if end > e.size {
+		end = e.size
+	}
+	for i := offset / cacheBlockSize; i*cacheBlockSize < end; i++ {
+		if e.blocks[i] != blockMissing {
+			continue
+		}
+		// download the run of missing blocks starting at i
+		first := i
+		for ; i*cacheBlockSize < end && e.blocks[i] == blockMissing; i++ {
+			e.blocks[i] = blockFetching
+		}
+		last := i - 1
+		e.refs++ // the entry's file must stay open during the download
+		go func(f *os.File) {
+			start := first * cacheBlockSize
+			size := (last+1)*cacheBlockSize - start
+			if start+size > e.size {
+				size = e.size - start
+			}
+			err := fetch(start, size, &offsetWriter{f, start})
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			defer e.cond.Broadcast()
+			defer c.unref(e)
+			state := blockPresent
+			if err != nil {
+				e.err = err
+				state = blockMissing
+			}
+			for j := first; j <= last; j++ {
+				e.blocks[j] = state
+			}
+			if err == nil {
+				c.complete(e)
+			}
+		}(e.file)
+	}
+}
+
+// complete marks 'e' as complete if all of its blocks have been downloaded.
+// c.mu must be held.
+func (c *cache) complete(e *cacheEntry) {
+	for _, state := range e.blocks {
+		if state != blockPresent {
+			return
+		}
+	}
+	partial := c.path(e)
+	e.blocks = nil
+	if err := os.Rename(partial, c.path(e)); err != nil {
+		// the entry can still be used while it's open, but it can't be
+		// found again once it's closed
+		e.ephemeral = true
+		delete(c.entries, e.key)
+	}
+}
+
+// offsetWriter writes to a file sequentially, starting at an offset
+type offsetWriter struct {
+	f      *os.File
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
@@ -0,0 +1,206 @@
+package fuse
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+)
+
+// testFetcher serves fetches from 'data', and records the offsets they
+// start at
+type testFetcher struct {
+	data []byte
+	err  error
+
+	mu      sync.Mutex
+	offsets []int64
+}
+
+func (f *testFetcher) fetch(offset int64, size int64, w io.Writer) error {
+	f.mu.Lock()
+	f.offsets = append(f.offsets, offset)
+	err := f.err
+	f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(f.data[offset : offset+size])
+	return err
+}
+
+func (f *testFetcher) fetches() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.offsets)
+}
+
+func randomData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	return data
+}
+
+func newTestCache(t *testing.T, maxSize int64) (*cache, string) {
+	dir, err := ioutil.TempDir("", "pfs-fuse-cache")
+	require.NoError(t, err)
+	c, err := newCache(dir, maxSize)
+	require.NoError(t, err)
+	return c, dir
+}
+
+func readEntry(t *testing.T, e *cacheEntry, offset int64, size int) []byte {
+	buf := make([]byte, size)
+	n, err := e.file.ReadAt(buf, offset)
+	if err != io.EOF {
+		require.NoError(t, err)
+	}
+	return buf[:n]
+}
+
+func TestCacheRead(t *testing.T) {
+	c, dir := newTestCache(t, 0)
+	defer os.RemoveAll(dir)
+	f := &testFetcher{data: randomData(3*cacheBlockSize + 100)}
+	size := int64(len(f.data))
+	e, err := c.acquire("key", size)
+	require.NoError(t, err)
+
+	// Reading the middle of the file only fetches the blocks it spans
+	require.NoError(t, c.read(e, f.fetch, cacheBlockSize+10, cacheBlockSize+20, 0))
+	require.Equal(t, 1, f.fetches())
+	require.True(t, bytes.Equal(f.data[cacheBlockSize+10:cacheBlockSize+20], readEntry(t, e, cacheBlockSize+10, 10)))
+
+	// Blocks that have been fetched aren't fetched again, and the rest of
+	// the file is fetched in one run
+	require.NoError(t, c.read(e, f.fetch, 0, size, 0))
+	require.Equal(t, 3, f.fetches())
+	require.True(t, bytes.Equal(f.data, readEntry(t, e, 0, len(f.data))))
+	require.Nil(t, e.blocks)
+	c.release(e)
+
+	// The complete file is moved out of its partial file, and is removed
+	// once it's unused, as the cache is empty
+	_, err = os.Stat(filepath.Join(dir, "key"+partialSuffix))
+	require.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "key"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestCacheReadAhead(t *testing.T) {
+	c, dir := newTestCache(t, 0)
+	defer os.RemoveAll(dir)
+	f := &testFetcher{data: randomData(4 * cacheBlockSize)}
+	e, err := c.acquire("key", int64(len(f.data)))
+	require.NoError(t, err)
+	defer c.release(e)
+
+	require.NoError(t, c.read(e, f.fetch, 0, 10, 2*cacheBlockSize))
+	// Wait for the read-ahead, which fetches the next 2 blocks
+	require.NoError(t, c.read(e, f.fetch, 2*cacheBlockSize, 2*cacheBlockSize+1, 0))
+	require.Equal(t, 2, f.fetches())
+	sort.Slice(f.offsets, func(i, j int) bool { return f.offsets[i] < f.offsets[j] })
+	require.Equal(t, []int64{0, cacheBlockSize}, f.offsets)
+	require.Equal(t, blockMissing, e.blocks[3])
+}
+
+func TestCacheFetchError(t *testing.T) {
+	c, dir := newTestCache(t, 0)
+	defer os.RemoveAll(dir)
+	f := &testFetcher{data: randomData(100), err: fmt.Errorf("fetch failed")}
+	e, err := c.acquire("key", int64(len(f.data)))
+	require.NoError(t, err)
+	defer c.release(e)
+
+	require.YesError(t, c.read(e, f.fetch, 0, 100, 0))
+	// Failed blocks are fetched again by the next read
+	f.err = nil
+	require.NoError(t, c.read(e, f.fetch, 0, 100, 0))
+	require.True(t, bytes.Equal(f.data, readEntry(t, e, 0, 100)))
+}
+
+func TestCacheEviction(t *testing.T) {
+	c, dir := newTestCache(t, 250)
+	defer os.RemoveAll(dir)
+	for i := 0; i < 3; i++ {
+		key := fmt.Sprintf("key%d", i)
+		f := &testFetcher{data: randomData(100)}
+		e, err := c.acquire(key, 100)
+		require.NoError(t, err)
+		require.NoError(t, c.read(e, f.fetch, 0, 100, 0))
+		c.release(e)
+	}
+	// The least recently used file is evicted
+	_, err := os.Stat(filepath.Join(dir, "key0"))
+	require.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "key1"))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "key2"))
+	require.NoError(t, err)
+	require.Equal(t, int64(200), c.size)
+
+	// Using a file makes it the most recently used
+	e, err := c.acquire("key1", 100)
+	require.NoError(t, err)
+	c.release(e)
+	e, err = c.acquire("key3", 100)
+	require.NoError(t, err)
+	require.NoError(t, c.read(e, (&testFetcher{data: randomData(100)}).fetch, 0, 100, 0))
+	c.release(e)
+	_, err = os.Stat(filepath.Join(dir, "key1"))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "key2"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestCachePersists(t *testing.T) {
+	c, dir := newTestCache(t, 3*cacheBlockSize)
+	defer os.RemoveAll(dir)
+	f := &testFetcher{data: randomData(100)}
+	e, err := c.acquire("complete", 100)
+	require.NoError(t, err)
+	require.NoError(t, c.read(e, f.fetch, 0, 100, 0))
+	c.release(e)
+	e, err = c.acquire("partial", 2*cacheBlockSize)
+	require.NoError(t, err)
+	require.NoError(t, c.read(e, (&testFetcher{data: randomData(2 * cacheBlockSize)}).fetch, 0, 10, 0))
+	c.release(e)
+
+	// A new cache in the same directory has the complete file, but not the
+	// partial one
+	c, err = newCache(dir, 3*cacheBlockSize)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), c.size)
+	e, err = c.acquire("complete", 100)
+	require.NoError(t, err)
+	require.Nil(t, e.blocks)
+	require.NoError(t, c.read(e, f.fetch, 0, 100, 0))
+	require.Equal(t, 1, f.fetches())
+	require.True(t, bytes.Equal(f.data, readEntry(t, e, 0, 100)))
+	c.release(e)
+	_, err = os.Stat(filepath.Join(dir, "partial"+partialSuffix))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestCacheEphemeral(t *testing.T) {
+	c, dir := newTestCache(t, 1000)
+	defer os.RemoveAll(dir)
+	f := &testFetcher{data: randomData(100)}
+	// Files without a hash aren't cached once they're closed
+	e, err := c.acquire("", 100)
+	require.NoError(t, err)
+	require.NoError(t, c.read(e, f.fetch, 0, 100, 0))
+	c.release(e)
+	fis, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(fis))
+	require.Equal(t, int64(0), c.size)
+}
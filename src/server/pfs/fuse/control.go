@@ -0,0 +1,159 @@
+package fuse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+
+	"golang.org/x/net/context"
+)
+
+// The control API lets other processes (e.g. pachctl, or a notebook
+// extension) change a MountManager's mounts. It's served over HTTP on a unix
+// socket, with the endpoints:
+//   GET  /mounts   returns the mounts, as a JSON list of MountSpecs
+//   POST /mount    mounts (or remaps) the JSON MountSpec in the body
+//   POST /unmount  unmounts the JSON MountSpec in the body (only Repo and
+//                  Name are used)
+//   POST /commit   commits the JSON MountSpec in the body (only Repo and Name
+//                  are used)
+
+// ServeControl serves the manager's control API on a unix socket at
+// 'socket', until ctx is canceled. Any existing file at 'socket' is removed.
+func (m *MountManager) ServeControl(ctx context.Context, socket string) error {
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: m.controlHandler()}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if err := server.Serve(l); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (m *MountManager) controlHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mounts", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(m.ListMounts()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	handleSpec := func(path string, f func(MountSpec) error) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			var spec MountSpec
+			if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+				http.Error(w, fmt.Sprintf("malformed mount: %v", err), http.StatusBadRequest)
+				return
+			}
+			if err := f(spec); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+		})
+	}
+	handleSpec("/mount", m.Mount)
+	handleSpec("/unmount", func(spec MountSpec) error {
+		return m.Unmount(spec.Repo, spec.Name)
+	})
+	handleSpec("/commit", func(spec MountSpec) error {
+		return m.Commit(spec.Repo, spec.Name)
+	})
+	return mux
+}
+
+// ControlClient talks to the control API of a MountManager
+type ControlClient struct {
+	client *http.Client
+}
+
+// NewControlClient returns a ControlClient for the MountManager serving its
+// control API at 'socket'
+func NewControlClient(socket string) *ControlClient {
+	return &ControlClient{
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// Mount mounts (or remaps) a branch, see MountManager.Mount
+func (c *ControlClient) Mount(spec MountSpec) error {
+	return c.post("/mount", spec)
+}
+
+// Unmount removes the mount 'name' of 'repo', see MountManager.Unmount
+func (c *ControlClient) Unmount(repo string, name string) error {
+	return c.post("/unmount", MountSpec{Repo: repo, Name: name})
+}
+
+// Commit finishes the commit that writes to the mount 'name' of 'repo' go
+// to, see MountManager.Commit
+func (c *ControlClient) Commit(repo string, name string) error {
+	return c.post("/commit", MountSpec{Repo: repo, Name: name})
+}
+
+// ListMounts returns the manager's mounts
+func (c *ControlClient) ListMounts() ([]MountSpec, error) {
+	resp, err := c.client.Get("http://mount-manager/mounts")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	var result []MountSpec
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *ControlClient) post(path string, spec MountSpec) error {
+	body, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Post("http://mount-manager"+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	msg, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%s", bytes.TrimSpace(msg))
+}
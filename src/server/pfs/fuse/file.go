@@ -1,12 +1,8 @@
 package fuse
 
 import (
-	"context"
+	"encoding/hex"
 	"io"
-	"io/ioutil"
-	"math"
-	"os"
-	"sync"
 	"syscall"
 	"time"
 
@@ -15,57 +11,47 @@ import (
 	"github.com/pachyderm/pachyderm/src/client/pfs"
 )
 
+// file is an open, read-only file. Its content is read through the mount's
+// cache.
 type file struct {
-	name    string
-	attr    *fuse.Attr
-	cancel  func()
-	pfsFile *pfs.File
-	file    *os.File
-	counter *counter
-	err     error
+	fs    *filesystem
+	name  string
+	attr  *fuse.Attr
+	entry *cacheEntry
+	fetch fetchFunc
 }
 
 func newFile(fs *filesystem, name string) (*file, fuse.Status) {
-	attr, status := fs.getAttr(name)
-	if status != fuse.OK {
-		return nil, status
-	}
-	ctx, cancel := context.WithCancel(fs.c.Ctx())
-	c := fs.c.WithCtx(ctx)
-	f, err := ioutil.TempFile("", "pfs-fuse")
+	_, pfsFile, err := fs.parsePath(name)
 	if err != nil {
-		return nil, fuse.ToStatus(err)
+		return nil, toStatus(err)
 	}
-	if err := os.Remove(f.Name()); err != nil {
-		return nil, fuse.ToStatus(err)
+	if pfsFile == nil {
+		return nil, fuse.Status(syscall.EISDIR)
 	}
-	_, pfsFile, err := fs.parsePath(name)
+	fi, err := fs.c.InspectFile(pfsFile.Commit.Repo.Name, pfsFile.Commit.ID, pfsFile.Path)
 	if err != nil {
 		return nil, toStatus(err)
 	}
-	if pfsFile == nil {
+	if fi.FileType == pfs.FileType_DIR {
 		return nil, fuse.Status(syscall.EISDIR)
 	}
-	counter := newCounter()
-	// Argument order is important here because it means that writes to w must
-	// complete writing to f before being written to counter. Thus counter can
-	// tell us conclusively at least (but not at most) a certain number of
-	// bytes has been written to f.
-	w := io.MultiWriter(f, counter)
-	result := &file{
-		attr:    attr,
-		cancel:  cancel,
-		pfsFile: pfsFile,
-		file:    f,
-		counter: counter,
+	entry, err := fs.cache.acquire(hex.EncodeToString(fi.Hash), int64(fi.SizeBytes))
+	if err != nil {
+		return nil, fuse.ToStatus(err)
 	}
-	go func() {
-		if err := c.GetFile(pfsFile.Commit.Repo.Name, pfsFile.Commit.ID, pfsFile.Path, 0, 0, w); err != nil {
-			result.err = err
-			counter.cancel()
-		}
-	}()
-	return result, fuse.OK
+	// Downloads use the mount's client (rather than being canceled when the
+	// file is closed), as other open files may be waiting for them
+	c := fs.c
+	return &file{
+		fs:    fs,
+		name:  name,
+		attr:  fileInfoAttr(fi),
+		entry: entry,
+		fetch: func(offset int64, size int64, w io.Writer) error {
+			return c.GetFile(pfsFile.Commit.Repo.Name, pfsFile.Commit.ID, pfsFile.Path, offset, size, w)
+		},
+	}, fuse.OK
 }
 
 func (f *file) Write(data []byte, off int64) (written uint32, code fuse.Status) {
@@ -83,19 +69,10 @@ func (f *file) InnerFile() nodefs.File {
 }
 
 func (f *file) Read(dest []byte, offset int64) (fuse.ReadResult, fuse.Status) {
-	waitn := offset + int64(len(dest))
-	if waitn > int64(f.attr.Size) {
-		waitn = int64(f.attr.Size)
-	}
-	f.counter.wait(waitn)
-	// check if there was an error reading the file
-	if f.err != nil {
-		return nil, toStatus(f.err)
-	}
-	if err := f.file.Sync(); err != nil {
+	if err := f.fs.cache.read(f.entry, f.fetch, offset, offset+int64(len(dest)), f.fs.readAhead); err != nil {
 		return nil, toStatus(err)
 	}
-	return fuse.ReadResultFd(f.file.Fd(), offset, len(dest)), fuse.OK
+	return fuse.ReadResultFd(f.entry.file.Fd(), offset, len(dest)), fuse.OK
 }
 
 func (f *file) Flock(flags int) fuse.Status {
@@ -108,7 +85,7 @@ func (f *file) Flush() fuse.Status {
 }
 
 func (f *file) Release() {
-	f.cancel()
+	f.fs.cache.release(f.entry)
 }
 
 func (f *file) Fsync(flags int) (code fuse.Status) {
@@ -139,40 +116,3 @@ func (f *file) Utimens(atime *time.Time, mtime *time.Time) fuse.Status {
 func (f *file) Allocate(off uint64, size uint64, mode uint32) fuse.Status {
 	return fuse.EROFS
 }
-
-type counter struct {
-	n    int64
-	mu   sync.Mutex
-	cond *sync.Cond
-}
-
-func newCounter() *counter {
-	result := &counter{}
-	result.cond = sync.NewCond(&result.mu)
-	return result
-}
-
-func (c *counter) Write(p []byte) (int, error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.n += int64(len(p))
-	c.cond.Broadcast()
-	return len(p), nil
-}
-
-// wait until more than n bytes have been written
-func (c *counter) wait(n int64) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	for c.n < n {
-		c.cond.Wait()
-	}
-}
-
-// cancel indicates that an error has occurred which will prevent any further
-// calls to Write, it causes all calls to wait() to return
-func (c *counter) cancel() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.n = math.MaxInt64
-}
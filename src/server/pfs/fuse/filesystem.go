@@ -2,6 +2,7 @@ package fuse
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/signal"
 	"path"
@@ -22,8 +23,48 @@ const (
 )
 
 // Mount pfs to mountPoint, opts may be left nil.
-func Mount(c *client.APIClient, mountPoint string, opts *Options) error {
-	nfs := pathfs.NewPathNodeFs(newFileSystem(c, opts.getCommits()), nil)
+func Mount(c *client.APIClient, mountPoint string, opts *Options) (retErr error) {
+	cache, cleanup, err := openCache(opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := cleanup(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	fs := newFileSystem(c, opts, cache)
+	if err := serve(fs, mountPoint, opts); err != nil {
+		return err
+	}
+	return fs.finishCommits()
+}
+
+// openCache opens the cache configured by opts. The returned function removes
+// the cache's directory if it's temporary, and must be called once the cache
+// is no longer used.
+func openCache(opts *Options) (*cache, func() error, error) {
+	cleanup := func() error { return nil }
+	cacheDir := opts.getCacheDir()
+	if cacheDir == "" {
+		var err error
+		cacheDir, err = ioutil.TempDir("", "pfs-fuse-cache")
+		if err != nil {
+			return nil, nil, err
+		}
+		cleanup = func() error { return os.RemoveAll(cacheDir) }
+	}
+	cache, err := newCache(cacheDir, opts.getCacheSize())
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("newCache: %v", err)
+	}
+	return cache, cleanup, nil
+}
+
+// serve mounts fs at mountPoint, and blocks until it's unmounted
+func serve(fs pathfs.FileSystem, mountPoint string, opts *Options) error {
+	nfs := pathfs.NewPathNodeFs(fs, nil)
 	server, _, err := nodefs.MountRoot(mountPoint, nfs.Root(), opts.getFuse())
 	if err != nil {
 		return fmt.Errorf("nodefs.MountRoot: %v", err)
@@ -43,12 +84,31 @@ func Mount(c *client.APIClient, mountPoint string, opts *Options) error {
 
 type filesystem struct {
 	pathfs.FileSystem
-	c         *client.APIClient
-	commits   map[string]string
+	c       *client.APIClient
+	commits map[string]string
+	// branches maps repos to the branches that they're mounted from, once
+	// they've been resolved (repos mounted at a commit have no branch)
+	branches map[string]string
+	// finished holds the commits that are known to be finished
+	finished map[string]bool
+	// started maps repos to the commits that the mount has started in them
+	// (see Options.ReadWrite)
+	started   map[string]string
 	commitsMu sync.RWMutex
+	// startMu serializes starting and finishing commits
+	startMu sync.Mutex
+
+	cache     *cache
+	readAhead int64
+	writeBack bool
+	readWrite bool
+	// writes holds the files that are open for writing, by name
+	writes   map[string]*pendingWrite
+	writesMu sync.Mutex
 }
 
-func newFileSystem(c *client.APIClient, commits map[string]string) pathfs.FileSystem {
+func newFileSystem(c *client.APIClient, opts *Options, cache *cache) *filesystem {
+	commits := opts.getCommits()
 	if commits == nil {
 		commits = make(map[string]string)
 	}
@@ -56,6 +116,14 @@ func newFileSystem(c *client.APIClient, commits map[string]string) pathfs.FileSy
 		FileSystem: pathfs.NewDefaultFileSystem(),
 		c:          c,
 		commits:    commits,
+		branches:   make(map[string]string),
+		finished:   make(map[string]bool),
+		started:    make(map[string]string),
+		cache:      cache,
+		readAhead:  opts.getReadAhead(),
+		writeBack:  opts.getWriteBack(),
+		readWrite:  opts.getReadWrite(),
+		writes:     make(map[string]*pendingWrite),
 	}
 }
 
@@ -105,18 +173,71 @@ func (fs *filesystem) OpenDir(name string, context *fuse.Context) ([]fuse.DirEnt
 			result = append(result, repoDirEntry(ri))
 		}
 	}
-	return result, fuse.OK
+	return fs.pendingDirEntries(name, result), fuse.OK
+}
+
+// pendingDirEntries adds the files in the dir 'name' that are being written,
+// but haven't been uploaded yet, to 'entries'
+func (fs *filesystem) pendingDirEntries(name string, entries []fuse.DirEntry) []fuse.DirEntry {
+	fs.writesMu.Lock()
+	defer fs.writesMu.Unlock()
+	listed := make(map[string]bool)
+	for _, entry := range entries {
+		listed[entry.Name] = true
+	}
+	for writeName := range fs.writes {
+		dir, base := path.Split(writeName)
+		if strings.TrimSuffix(dir, "/") != name || listed[base] {
+			continue
+		}
+		entries = append(entries, fuse.DirEntry{
+			Name: base,
+			Mode: modeWritableFile,
+		})
+	}
+	return entries
 }
 
 func (fs *filesystem) Open(name string, flags uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
 	f := int(flags)
 	writeFlags := os.O_WRONLY | os.O_RDWR
 	if f&writeFlags != 0 {
-		return nil, fuse.EROFS
+		return fs.openWrite(name, f&os.O_TRUNC != 0)
+	}
+	if h, ok := fs.openPending(name); ok {
+		// the file is being written, so read what's been written so far
+		return h, fuse.OK
 	}
 	return newFile(fs, name)
 }
 
+func (fs *filesystem) Create(name string, flags uint32, mode uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	return fs.openWrite(name, true)
+}
+
+func (fs *filesystem) Truncate(name string, size uint64, context *fuse.Context) fuse.Status {
+	h, status := fs.openWrite(name, false)
+	if status != fuse.OK {
+		return status
+	}
+	defer h.Release()
+	if status := h.Truncate(size); status != fuse.OK {
+		return status
+	}
+	return h.Flush()
+}
+
+func (fs *filesystem) Unlink(name string, context *fuse.Context) fuse.Status {
+	f, status := fs.writableFile(name)
+	if status != fuse.OK {
+		return status
+	}
+	if err := fs.c.DeleteFile(f.Commit.Repo.Name, f.Commit.ID, f.Path); err != nil {
+		return toStatus(err)
+	}
+	return fuse.OK
+}
+
 func (fs *filesystem) commit(repo string) (string, error) {
 	commitOrBranch := func() string {
 		fs.commitsMu.RLock()
@@ -129,6 +250,11 @@ func (fs *filesystem) commit(repo string) (string, error) {
 	}
 	// it's a branch, resolve the head and return that
 	branch := commitOrBranch
+	if branch == "" {
+		fs.commitsMu.RLock()
+		branch = fs.branches[repo]
+		fs.commitsMu.RUnlock()
+	}
 	if branch == "" {
 		branch = "master"
 	}
@@ -138,6 +264,7 @@ func (fs *filesystem) commit(repo string) (string, error) {
 	}
 	fs.commitsMu.Lock()
 	defer fs.commitsMu.Unlock()
+	fs.branches[repo] = branch
 	if bi.Head != nil {
 		fs.commits[repo] = bi.Head.ID
 	} else {
@@ -163,15 +290,18 @@ func (fs *filesystem) parsePath(name string) (*pfs.Repo, *pfs.File, error) {
 }
 
 func (fs *filesystem) getAttr(name string) (*fuse.Attr, fuse.Status) {
+	if attr, status, ok := fs.pendingAttr(name); ok {
+		return attr, status
+	}
 	r, f, err := fs.parsePath(name)
 	if err != nil {
 		return nil, toStatus(err)
 	}
 	switch {
 	case r != nil:
-		return fs.repoAttr(r)
+		return fs.writableAttr(fs.repoAttr(r))
 	case f != nil:
-		return fs.fileAttr(f)
+		return fs.writableAttr(fs.fileAttr(f))
 	default:
 		return &fuse.Attr{
 			Mode: modeDir,
@@ -179,6 +309,14 @@ func (fs *filesystem) getAttr(name string) (*fuse.Attr, fuse.Status) {
 	}
 }
 
+// writableAttr makes 'attr' writable by its owner if the mount is read-write
+func (fs *filesystem) writableAttr(attr *fuse.Attr, status fuse.Status) (*fuse.Attr, fuse.Status) {
+	if fs.readWrite && status == fuse.OK {
+		attr.Mode |= 0200
+	}
+	return attr, status
+}
+
 func (fs *filesystem) repoAttr(r *pfs.Repo) (*fuse.Attr, fuse.Status) {
 	ri, err := fs.c.InspectRepo(r.Name)
 	if err != nil {
@@ -216,10 +354,14 @@ func (fs *filesystem) fileAttr(f *pfs.File) (*fuse.Attr, fuse.Status) {
 	if err != nil {
 		return nil, toStatus(err)
 	}
+	return fileInfoAttr(fi), fuse.OK
+}
+
+func fileInfoAttr(fi *pfs.FileInfo) *fuse.Attr {
 	return &fuse.Attr{
 		Mode: fileMode(fi),
 		Size: fi.SizeBytes,
-	}, fuse.OK
+	}
 }
 
 func fileDirEntry(fi *pfs.FileInfo) fuse.DirEntry {
@@ -1,7 +1,9 @@
 package fuse
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"os"
@@ -133,14 +135,199 @@ func TestHeadlessBranch(t *testing.T) {
 	})
 }
 
-func mount(tb testing.TB, c *client.APIClient, commits map[string]string, f func(mountPoint string)) {
+func TestPersistentCache(t *testing.T) {
+	c := server.GetPachClient(t)
+	require.NoError(t, c.CreateRepo("repo"))
+	_, err := c.PutFile("repo", "master", "file", workload.NewReader(rand.New(rand.NewSource(123)), 10*MB))
+	require.NoError(t, err)
+	cacheDir, err := ioutil.TempDir("", "pfs-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+	opts := &Options{CacheDir: cacheDir, CacheSize: GB}
+	var data []byte
+	mount(t, c, opts, func(mountPoint string) {
+		data, err = ioutil.ReadFile(filepath.Join(mountPoint, "repo", "file"))
+		require.NoError(t, err)
+		require.Equal(t, 10*MB, len(data))
+	})
+	// The file stays in the cache after it's closed, and after unmounting
+	fis, err := ioutil.ReadDir(cacheDir)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(fis))
+	require.Equal(t, int64(10*MB), fis[0].Size())
+	mount(t, c, opts, func(mountPoint string) {
+		cached, err := ioutil.ReadFile(filepath.Join(mountPoint, "repo", "file"))
+		require.NoError(t, err)
+		require.Equal(t, sha256.Sum256(data), sha256.Sum256(cached))
+	})
+}
+
+func TestWriteBack(t *testing.T) {
+	c := server.GetPachClient(t)
+	require.NoError(t, c.CreateRepo("repo"))
+	commit, err := c.StartCommit("repo", "master")
+	require.NoError(t, err)
+	_, err = c.PutFile("repo", commit.ID, "existing", strings.NewReader("foo"))
+	require.NoError(t, err)
+	mount(t, c, &Options{WriteBack: true}, func(mountPoint string) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(mountPoint, "repo", "new"), []byte("bar"), 0644))
+		f, err := os.OpenFile(filepath.Join(mountPoint, "repo", "existing"), os.O_WRONLY|os.O_APPEND, 0644)
+		require.NoError(t, err)
+		_, err = f.WriteString("bar")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		require.NoError(t, os.Remove(filepath.Join(mountPoint, "repo", "new")))
+	})
+	var buf bytes.Buffer
+	require.NoError(t, c.GetFile("repo", commit.ID, "existing", 0, 0, &buf))
+	require.Equal(t, "foobar", buf.String())
+	_, err = c.InspectFile("repo", commit.ID, "new")
+	require.YesError(t, err)
+
+	// Finished commits can't be written
+	require.NoError(t, c.FinishCommit("repo", commit.ID))
+	mount(t, c, &Options{WriteBack: true}, func(mountPoint string) {
+		err := ioutil.WriteFile(filepath.Join(mountPoint, "repo", "existing"), []byte("bar"), 0644)
+		require.YesError(t, err)
+	})
+}
+
+func TestMountManagerBranches(t *testing.T) {
+	c := server.GetPachClient(t)
+	require.NoError(t, c.CreateRepo("repo"))
+	_, err := c.PutFile("repo", "master", "file", strings.NewReader("master"))
+	require.NoError(t, err)
+	_, err = c.PutFile("repo", "v1", "file", strings.NewReader("v1"))
+	require.NoError(t, err)
+	_, err = c.PutFile("repo", "v2", "file", strings.NewReader("v2"))
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "pfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	opts := &Options{Unmount: make(chan struct{})}
+	defer close(opts.Unmount)
+	m := NewMountManager(c, opts)
+	require.NoError(t, m.Mount(MountSpec{Repo: "repo", Branch: "master"}))
+	require.NoError(t, m.Mount(MountSpec{Repo: "repo", Branch: "v1", Name: "latest"}))
+	go m.Serve(dir)
+	// Gotta give the fuse mount time to come up.
+	time.Sleep(2 * time.Second)
+
+	names, err := ioutil.ReadDir(filepath.Join(dir, "repo"))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(names))
+	require.Equal(t, "latest", names[0].Name())
+	require.Equal(t, "master", names[1].Name())
+	data, err := ioutil.ReadFile(filepath.Join(dir, "repo", "master", "file"))
+	require.NoError(t, err)
+	require.Equal(t, "master", string(data))
+	data, err = ioutil.ReadFile(filepath.Join(dir, "repo", "latest", "file"))
+	require.NoError(t, err)
+	require.Equal(t, "v1", string(data))
+
+	// Remap "latest" while the mount is in use
+	require.NoError(t, m.Mount(MountSpec{Repo: "repo", Branch: "v2", Name: "latest"}))
+	require.NoErrorWithinTRetry(t, 10*time.Second, func() error {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "repo", "latest", "file"))
+		if err != nil {
+			return err
+		}
+		if string(data) != "v2" {
+			return fmt.Errorf("expected \"v2\", got %q", data)
+		}
+		return nil
+	})
+	require.NoError(t, m.Unmount("repo", "master"))
+	require.NoErrorWithinTRetry(t, 10*time.Second, func() error {
+		if _, err := os.Stat(filepath.Join(dir, "repo", "master")); !os.IsNotExist(err) {
+			return fmt.Errorf("expected repo/master to be unmounted")
+		}
+		return nil
+	})
+}
+
+func TestReadWrite(t *testing.T) {
+	c := server.GetPachClient(t)
+	require.NoError(t, c.CreateRepo("repo"))
+	_, err := c.PutFile("repo", "master", "existing", strings.NewReader("foo"))
+	require.NoError(t, err)
+	mount(t, c, &Options{ReadWrite: true}, func(mountPoint string) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(mountPoint, "repo", "new"), []byte("bar"), 0644))
+		data, err := ioutil.ReadFile(filepath.Join(mountPoint, "repo", "existing"))
+		require.NoError(t, err)
+		require.Equal(t, "foo", string(data))
+		data, err = ioutil.ReadFile(filepath.Join(mountPoint, "repo", "new"))
+		require.NoError(t, err)
+		require.Equal(t, "bar", string(data))
+		// The writes aren't on the branch until pfs is unmounted
+		commitInfo, err := c.InspectCommit("repo", "master")
+		require.NoError(t, err)
+		require.Nil(t, commitInfo.Finished)
+	})
+	require.NoErrorWithinTRetry(t, 10*time.Second, func() error {
+		commitInfo, err := c.InspectCommit("repo", "master")
+		if err != nil {
+			return err
+		}
+		if commitInfo.Finished == nil {
+			return fmt.Errorf("expected the mount's commit to be finished")
+		}
+		return nil
+	})
+	var buf bytes.Buffer
+	require.NoError(t, c.GetFile("repo", "master", "new", 0, 0, &buf))
+	require.Equal(t, "bar", buf.String())
+	commitInfos, err := c.ListCommit("repo", "master", "", 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(commitInfos))
+}
+
+func TestMountManagerWrite(t *testing.T) {
+	c := server.GetPachClient(t)
+	require.NoError(t, c.CreateRepo("repo"))
+	_, err := c.PutFile("repo", "master", "file", strings.NewReader("foo"))
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "pfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	opts := &Options{Unmount: make(chan struct{})}
+	defer close(opts.Unmount)
+	m := NewMountManager(c, opts)
+	require.NoError(t, m.Mount(MountSpec{Repo: "repo", Branch: "master", Write: true}))
+	go m.Serve(dir)
+	// Gotta give the fuse mount time to come up.
+	time.Sleep(2 * time.Second)
+
+	for i, content := range []string{"bar", "baz"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "repo", "master", "file"), []byte(content), 0644))
+		require.NoError(t, m.Commit("repo", "master"))
+		var buf bytes.Buffer
+		require.NoError(t, c.GetFile("repo", "master", "file", 0, 0, &buf))
+		require.Equal(t, content, buf.String())
+		commitInfos, err := c.ListCommit("repo", "master", "", 0)
+		require.NoError(t, err)
+		require.Equal(t, i+2, len(commitInfos))
+		require.NotNil(t, commitInfos[0].Finished)
+	}
+
+	// Commits fail while files are open for writing
+	f, err := os.OpenFile(filepath.Join(dir, "repo", "master", "file"), os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	require.YesError(t, m.Commit("repo", "master"))
+	require.NoError(t, f.Close())
+	require.NoError(t, m.Commit("repo", "master"))
+}
+
+func mount(tb testing.TB, c *client.APIClient, opts *Options, f func(mountPoint string)) {
 	dir, err := ioutil.TempDir("", "pfs")
 	require.NoError(tb, err)
 	defer os.RemoveAll(dir)
-	opts := &Options{
-		Commits: commits,
-		Unmount: make(chan struct{}),
+	if opts == nil {
+		opts = &Options{}
 	}
+	opts.Unmount = make(chan struct{})
 	defer func() {
 		close(opts.Unmount)
 	}()
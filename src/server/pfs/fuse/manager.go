@@ -0,0 +1,312 @@
+package fuse
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/nodefs"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+	"github.com/pachyderm/pachyderm/src/client"
+)
+
+// MountSpec describes a branch mounted by a MountManager. The branch's files
+// appear under <mount point>/<Repo>/<Name>.
+type MountSpec struct {
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	// Name is the name of the mount's directory, it defaults to Branch
+	Name string `json:"name,omitempty"`
+	// Write mounts the branch read-write (see Options.ReadWrite). Writes go
+	// to a commit that's finished when the mount is committed, remapped or
+	// unmounted.
+	Write bool `json:"write,omitempty"`
+}
+
+// MountManager mounts branches of any number of repos under a single mount
+// point, and allows branches to be mounted, remapped and unmounted while the
+// mount point is in use (see ServeControl). Nothing is read from pfs until a
+// mount is accessed, and each branch's head is resolved when it's first
+// accessed, as in Mount.
+type MountManager struct {
+	c    *client.APIClient
+	opts *Options
+
+	mu    sync.RWMutex
+	cache *cache
+	// mounts maps repos to the names of their mounts to the mounts
+	mounts map[string]map[string]*branchMount
+}
+
+type branchMount struct {
+	spec MountSpec
+	// fs serves the mount's files (at paths of the form <repo>/<path>), it's
+	// created when the mount is first accessed
+	fs *filesystem
+}
+
+// NewMountManager creates a MountManager which mounts nothing. opts may be
+// left nil, and opts.Commits is ignored.
+func NewMountManager(c *client.APIClient, opts *Options) *MountManager {
+	return &MountManager{
+		c:      c,
+		opts:   opts,
+		mounts: make(map[string]map[string]*branchMount),
+	}
+}
+
+// Serve mounts the manager's mounts at mountPoint, and blocks until it's
+// unmounted.
+func (m *MountManager) Serve(mountPoint string) (retErr error) {
+	cache, cleanup, err := openCache(m.opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := cleanup(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	m.mu.Lock()
+	m.cache = cache
+	m.mu.Unlock()
+	if err := serve(&managerFileSystem{
+		FileSystem: pathfs.NewDefaultFileSystem(),
+		m:          m,
+	}, mountPoint, m.opts); err != nil {
+		return err
+	}
+	for _, spec := range m.ListMounts() {
+		if err := m.Commit(spec.Repo, spec.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Mount mounts spec.Branch at <mount point>/<spec.Repo>/<spec.Name>. If
+// there's already a mount with that name, it's committed, and then remapped
+// to spec.Branch; files that are already open keep reading from the old
+// branch.
+func (m *MountManager) Mount(spec MountSpec) error {
+	if spec.Name == "" {
+		spec.Name = spec.Branch
+	}
+	if spec.Repo == "" || spec.Branch == "" {
+		return fmt.Errorf("repo and branch must be set")
+	}
+	for _, s := range []string{spec.Repo, spec.Name} {
+		if strings.Contains(s, "/") || s == "." || s == ".." {
+			return fmt.Errorf("invalid mount %s/%s", spec.Repo, spec.Name)
+		}
+	}
+	if _, ok := m.mount(spec.Repo, spec.Name); ok {
+		if err := m.Commit(spec.Repo, spec.Name); err != nil {
+			return err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.mounts[spec.Repo] == nil {
+		m.mounts[spec.Repo] = make(map[string]*branchMount)
+	}
+	m.mounts[spec.Repo][spec.Name] = &branchMount{spec: spec}
+	return nil
+}
+
+// Unmount commits and removes the mount 'name' of 'repo'
+func (m *MountManager) Unmount(repo string, name string) error {
+	if err := m.Commit(repo, name); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.mounts[repo][name]; !ok {
+		return fmt.Errorf("%s/%s is not mounted", repo, name)
+	}
+	delete(m.mounts[repo], name)
+	if len(m.mounts[repo]) == 0 {
+		delete(m.mounts, repo)
+	}
+	return nil
+}
+
+// Commit finishes the commit that writes to the mount 'name' of 'repo' go
+// to, if there is one, so that they appear on the mount's branch at once. It
+// fails if any of the mount's files are open for writing.
+func (m *MountManager) Commit(repo string, name string) error {
+	mount, ok := m.mount(repo, name)
+	if !ok {
+		return fmt.Errorf("%s/%s is not mounted", repo, name)
+	}
+	if mount.fs == nil {
+		// the mount hasn't been accessed, so it hasn't been written
+		return nil
+	}
+	return mount.fs.finishCommits()
+}
+
+// mount returns a copy of the mount 'name' of 'repo'
+func (m *MountManager) mount(repo string, name string) (branchMount, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	mount, ok := m.mounts[repo][name]
+	if !ok {
+		return branchMount{}, false
+	}
+	return *mount, true
+}
+
+// ListMounts returns the manager's mounts, sorted by repo and name
+func (m *MountManager) ListMounts() []MountSpec {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []MountSpec
+	for _, mounts := range m.mounts {
+		for _, mount := range mounts {
+			result = append(result, mount.spec)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Repo != result[j].Repo {
+			return result[i].Repo < result[j].Repo
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// filesystem returns the filesystem of the mount 'name' of 'repo', or nil if
+// there's no such mount
+func (m *MountManager) filesystem(repo string, name string) *filesystem {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mount, ok := m.mounts[repo][name]
+	if !ok {
+		return nil
+	}
+	if mount.fs == nil {
+		opts := Options{}
+		if m.opts != nil {
+			opts = *m.opts
+		}
+		opts.Commits = map[string]string{repo: mount.spec.Branch}
+		opts.ReadWrite = opts.ReadWrite || mount.spec.Write
+		mount.fs = newFileSystem(m.c, &opts, m.cache)
+	}
+	return mount.fs
+}
+
+// managerFileSystem serves a MountManager's mounts. Paths of the form
+// <repo>/<name>/<path> are passed on to the mount's filesystem as
+// <repo>/<path>.
+type managerFileSystem struct {
+	pathfs.FileSystem
+	m *MountManager
+}
+
+// resolve returns the filesystem that serves 'name', and the name that it
+// serves it at. name must be within a mount.
+func (fs *managerFileSystem) resolve(name string) (*filesystem, string, fuse.Status) {
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) < 2 {
+		return nil, "", fuse.ENOENT
+	}
+	mountFS := fs.m.filesystem(parts[0], parts[1])
+	if mountFS == nil {
+		return nil, "", fuse.ENOENT
+	}
+	if len(parts) == 2 {
+		return mountFS, parts[0], fuse.OK
+	}
+	return mountFS, parts[0] + "/" + parts[2], fuse.OK
+}
+
+// names returns the names of the mounts of 'repo', or of the repos with
+// mounts if repo is empty
+func (fs *managerFileSystem) names(repo string) []string {
+	fs.m.mu.RLock()
+	defer fs.m.mu.RUnlock()
+	var result []string
+	if repo == "" {
+		for repo := range fs.m.mounts {
+			result = append(result, repo)
+		}
+	} else {
+		for name := range fs.m.mounts[repo] {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (fs *managerFileSystem) GetAttr(name string, context *fuse.Context) (*fuse.Attr, fuse.Status) {
+	switch {
+	case name == "":
+		return &fuse.Attr{Mode: modeDir}, fuse.OK
+	case !strings.Contains(name, "/"):
+		if len(fs.names(name)) == 0 {
+			return nil, fuse.ENOENT
+		}
+		return &fuse.Attr{Mode: modeDir}, fuse.OK
+	}
+	mountFS, inner, status := fs.resolve(name)
+	if status != fuse.OK {
+		return nil, status
+	}
+	return mountFS.getAttr(inner)
+}
+
+func (fs *managerFileSystem) OpenDir(name string, context *fuse.Context) ([]fuse.DirEntry, fuse.Status) {
+	if !strings.Contains(name, "/") {
+		names := fs.names(name)
+		if name != "" && len(names) == 0 {
+			return nil, fuse.ENOENT
+		}
+		var result []fuse.DirEntry
+		for _, n := range names {
+			result = append(result, fuse.DirEntry{Name: n, Mode: modeDir})
+		}
+		return result, fuse.OK
+	}
+	mountFS, inner, status := fs.resolve(name)
+	if status != fuse.OK {
+		return nil, status
+	}
+	return mountFS.OpenDir(inner, context)
+}
+
+func (fs *managerFileSystem) Open(name string, flags uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	mountFS, inner, status := fs.resolve(name)
+	if status != fuse.OK {
+		return nil, status
+	}
+	return mountFS.Open(inner, flags, context)
+}
+
+func (fs *managerFileSystem) Create(name string, flags uint32, mode uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	mountFS, inner, status := fs.resolve(name)
+	if status != fuse.OK {
+		return nil, status
+	}
+	return mountFS.Create(inner, flags, mode, context)
+}
+
+func (fs *managerFileSystem) Truncate(name string, size uint64, context *fuse.Context) fuse.Status {
+	mountFS, inner, status := fs.resolve(name)
+	if status != fuse.OK {
+		return status
+	}
+	return mountFS.Truncate(inner, size, context)
+}
+
+func (fs *managerFileSystem) Unlink(name string, context *fuse.Context) fuse.Status {
+	mountFS, inner, status := fs.resolve(name)
+	if status != fuse.OK {
+		return status
+	}
+	return mountFS.Unlink(inner, context)
+}
@@ -0,0 +1,91 @@
+package fuse
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/pathfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	"golang.org/x/net/context"
+)
+
+func TestMountManagerControl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pfs-mount-manager")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "control.sock")
+	m := NewMountManager(nil, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.ServeControl(ctx, socket)
+	c := NewControlClient(socket)
+	require.NoErrorWithinTRetry(t, 10*time.Second, func() error {
+		_, err := c.ListMounts()
+		return err
+	})
+
+	require.NoError(t, c.Mount(MountSpec{Repo: "images", Branch: "master"}))
+	require.NoError(t, c.Mount(MountSpec{Repo: "images", Branch: "v1", Name: "train"}))
+	require.NoError(t, c.Mount(MountSpec{Repo: "labels", Branch: "master"}))
+	require.YesError(t, c.Mount(MountSpec{Repo: "labels"}))
+	require.YesError(t, c.Mount(MountSpec{Repo: "labels", Branch: "master", Name: "a/b"}))
+	mounts, err := c.ListMounts()
+	require.NoError(t, err)
+	require.Equal(t, []MountSpec{
+		{Repo: "images", Branch: "master", Name: "master"},
+		{Repo: "images", Branch: "v1", Name: "train"},
+		{Repo: "labels", Branch: "master", Name: "master"},
+	}, mounts)
+
+	// Mounts that haven't been written have nothing to commit
+	require.NoError(t, c.Commit("images", "train"))
+	require.YesError(t, c.Commit("images", "test"))
+
+	// Remap a mount to another branch
+	require.NoError(t, c.Mount(MountSpec{Repo: "images", Branch: "v2", Name: "train"}))
+	require.NoError(t, c.Unmount("labels", "master"))
+	require.YesError(t, c.Unmount("labels", "master"))
+	mounts, err = c.ListMounts()
+	require.NoError(t, err)
+	require.Equal(t, []MountSpec{
+		{Repo: "images", Branch: "master", Name: "master"},
+		{Repo: "images", Branch: "v2", Name: "train"},
+	}, mounts)
+}
+
+func TestMountManagerDirs(t *testing.T) {
+	m := NewMountManager(nil, nil)
+	fs := &managerFileSystem{FileSystem: pathfs.NewDefaultFileSystem(), m: m}
+	require.NoError(t, m.Mount(MountSpec{Repo: "images", Branch: "master"}))
+	require.NoError(t, m.Mount(MountSpec{Repo: "images", Branch: "v1", Name: "train"}))
+
+	entries, status := fs.OpenDir("", nil)
+	require.Equal(t, fuse.OK, status)
+	require.Equal(t, []fuse.DirEntry{{Name: "images", Mode: modeDir}}, entries)
+	entries, status = fs.OpenDir("images", nil)
+	require.Equal(t, fuse.OK, status)
+	require.Equal(t, []fuse.DirEntry{
+		{Name: "master", Mode: modeDir},
+		{Name: "train", Mode: modeDir},
+	}, entries)
+	_, status = fs.OpenDir("labels", nil)
+	require.Equal(t, fuse.ENOENT, status)
+	_, status = fs.GetAttr("labels", nil)
+	require.Equal(t, fuse.ENOENT, status)
+	_, status = fs.GetAttr("images/test", nil)
+	require.Equal(t, fuse.ENOENT, status)
+
+	// Mounts are only set up when they're accessed
+	require.Nil(t, m.mounts["images"]["train"].fs)
+	mountFS, inner, status := fs.resolve("images/train/dir/file")
+	require.Equal(t, fuse.OK, status)
+	require.Equal(t, "images/dir/file", inner)
+	require.Equal(t, map[string]string{"images": "v1"}, mountFS.commits)
+	require.NoError(t, m.Unmount("images", "train"))
+	_, _, status = fs.resolve("images/train/dir/file")
+	require.Equal(t, fuse.ENOENT, status)
+}
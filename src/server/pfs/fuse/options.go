@@ -13,6 +13,26 @@ type Options struct {
 	Commits map[string]string
 
 	Unmount chan struct{}
+
+	// CacheDir is the directory that the content of files read through the
+	// mount is cached in. If it's unset, a temporary directory is used, and
+	// removed when the mount is unmounted.
+	CacheDir string
+	// CacheSize is the maximum size, in bytes, of the files in the cache
+	// that aren't open. If it's 0, files are only cached while they're open.
+	CacheSize int64
+	// ReadAhead is the number of bytes after each read that are downloaded
+	// in advance. If it's 0, a default is used.
+	ReadAhead int64
+
+	// WriteBack allows files in open commits to be written. Written files
+	// are buffered locally, and uploaded when they're closed or synced.
+	WriteBack bool
+	// ReadWrite allows files to be written in repos that are mounted from a
+	// branch. The first write to a repo starts a commit on its branch, which
+	// all further writes go to (as with WriteBack), and which is finished
+	// when pfs is unmounted.
+	ReadWrite bool
 }
 
 func (o *Options) getFuse() *nodefs.Options {
@@ -35,3 +55,38 @@ func (o *Options) getUnmount() chan struct{} {
 	}
 	return o.Unmount
 }
+
+func (o *Options) getCacheDir() string {
+	if o == nil {
+		return ""
+	}
+	return o.CacheDir
+}
+
+func (o *Options) getCacheSize() int64 {
+	if o == nil {
+		return 0
+	}
+	return o.CacheSize
+}
+
+func (o *Options) getReadAhead() int64 {
+	if o == nil || o.ReadAhead == 0 {
+		return defaultReadAhead
+	}
+	return o.ReadAhead
+}
+
+func (o *Options) getWriteBack() bool {
+	if o == nil {
+		return false
+	}
+	return o.WriteBack
+}
+
+func (o *Options) getReadWrite() bool {
+	if o == nil {
+		return false
+	}
+	return o.ReadWrite
+}
@@ -0,0 +1,357 @@
+package fuse
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanwen/go-fuse/fuse"
+	"github.com/hanwen/go-fuse/fuse/nodefs"
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+)
+
+// The functions in this file implement write-back (see Options.WriteBack)
+// and read-write mounts (see Options.ReadWrite). The new content of a file
+// that's being written is buffered in a local file until it's flushed (when
+// the file is closed or synced), and then uploaded to the file's commit,
+// replacing the file.
+
+// modeWritableFile is the mode of the files that are being written
+const modeWritableFile = fuse.S_IFREG | 0644
+
+// pendingWrite holds the new content of a file that's open for writing. It's
+// shared by all of the file's open handles.
+type pendingWrite struct {
+	fs      *filesystem
+	name    string
+	pfsFile *pfs.File
+	// refs is guarded by fs.writesMu
+	refs int
+
+	mu    sync.Mutex
+	file  *os.File
+	dirty bool
+}
+
+// newPendingWrite creates a pendingWrite for the file 'pfsFile', which starts
+// empty if 'truncate' is set, and otherwise starts with the file's current
+// content
+func newPendingWrite(fs *filesystem, name string, pfsFile *pfs.File, truncate bool) (*pendingWrite, error) {
+	f, err := ioutil.TempFile("", "pfs-fuse-write")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(f.Name()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !truncate {
+		if err := fs.c.GetFile(pfsFile.Commit.Repo.Name, pfsFile.Commit.ID, pfsFile.Path, 0, 0, f); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return &pendingWrite{
+		fs:      fs,
+		name:    name,
+		pfsFile: pfsFile,
+		file:    f,
+		dirty:   truncate,
+	}, nil
+}
+
+func (w *pendingWrite) attr() (*fuse.Attr, fuse.Status) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fi, err := w.file.Stat()
+	if err != nil {
+		return nil, fuse.ToStatus(err)
+	}
+	return &fuse.Attr{
+		Mode: modeWritableFile,
+		Size: uint64(fi.Size()),
+	}, fuse.OK
+}
+
+// flush uploads the file's new content, if it's changed
+func (w *pendingWrite) flush() fuse.Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.dirty {
+		return fuse.OK
+	}
+	fi, err := w.file.Stat()
+	if err != nil {
+		return fuse.ToStatus(err)
+	}
+	if _, err := w.fs.c.PutFileOverwrite(w.pfsFile.Commit.Repo.Name, w.pfsFile.Commit.ID, w.pfsFile.Path, io.NewSectionReader(w.file, 0, fi.Size()), 0); err != nil {
+		return toStatus(err)
+	}
+	w.dirty = false
+	return fuse.OK
+}
+
+// writableFile returns the file that writes to 'name' go to. If the mount
+// is read-write, this starts a commit in the file's repo. Otherwise, only
+// files in open commits can be written, and only if write-back is enabled.
+func (fs *filesystem) writableFile(name string) (*pfs.File, fuse.Status) {
+	if !fs.writeBack && !fs.readWrite {
+		return nil, fuse.EROFS
+	}
+	_, pfsFile, err := fs.parsePath(name)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if pfsFile == nil {
+		return nil, fuse.EROFS
+	}
+	if fs.readWrite {
+		commit, err := fs.writeCommit(pfsFile.Commit.Repo.Name)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		if commit != nil {
+			pfsFile.Commit = commit
+			return pfsFile, fuse.OK
+		}
+	}
+	if !fs.writeBack || pfsFile.Commit.ID == "" {
+		return nil, fuse.EROFS
+	}
+	open, err := fs.commitOpen(pfsFile.Commit)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if !open {
+		return nil, fuse.EROFS
+	}
+	return pfsFile, fuse.OK
+}
+
+// writeCommit returns the commit that the mount has started in 'repo',
+// starting one on the repo's branch if there isn't one. It returns nil if
+// the repo is mounted at a commit, rather than a branch.
+func (fs *filesystem) writeCommit(repo string) (*pfs.Commit, error) {
+	fs.startMu.Lock()
+	defer fs.startMu.Unlock()
+	fs.commitsMu.RLock()
+	commitID, ok := fs.started[repo]
+	branch := fs.branches[repo]
+	fs.commitsMu.RUnlock()
+	if ok {
+		return client.NewCommit(repo, commitID), nil
+	}
+	if branch == "" {
+		return nil, nil
+	}
+	commit, err := fs.c.StartCommit(repo, branch)
+	if err != nil {
+		return nil, err
+	}
+	fs.commitsMu.Lock()
+	defer fs.commitsMu.Unlock()
+	fs.started[repo] = commit.ID
+	// reads see the new commit, including the writes to it
+	fs.commits[repo] = commit.ID
+	return commit, nil
+}
+
+// finishCommits finishes the commits that the mount has started, so that the
+// writes to each repo appear on its branch at once. It fails if any files
+// are open for writing. Later writes start new commits.
+func (fs *filesystem) finishCommits() error {
+	fs.startMu.Lock()
+	defer fs.startMu.Unlock()
+	// hold writesMu so that no files are opened for writing meanwhile
+	fs.writesMu.Lock()
+	defer fs.writesMu.Unlock()
+	if len(fs.writes) > 0 {
+		var names []string
+		for name := range fs.writes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("files are open for writing: %s", strings.Join(names, ", "))
+	}
+	fs.commitsMu.RLock()
+	var repos []string
+	for repo := range fs.started {
+		repos = append(repos, repo)
+	}
+	fs.commitsMu.RUnlock()
+	sort.Strings(repos)
+	for _, repo := range repos {
+		fs.commitsMu.RLock()
+		commitID := fs.started[repo]
+		fs.commitsMu.RUnlock()
+		if err := fs.c.FinishCommit(repo, commitID); err != nil {
+			return err
+		}
+		fs.commitsMu.Lock()
+		delete(fs.started, repo)
+		fs.finished[commitID] = true
+		// the next read resolves the branch's head again
+		fs.commits[repo] = fs.branches[repo]
+		fs.commitsMu.Unlock()
+	}
+	return nil
+}
+
+// openWrite opens the file 'name' for writing, creating it if it doesn't
+// exist
+func (fs *filesystem) openWrite(name string, truncate bool) (nodefs.File, fuse.Status) {
+	pfsFile, status := fs.writableFile(name)
+	if status != fuse.OK {
+		return nil, status
+	}
+	h, ok := fs.openPending(name)
+	if !ok {
+		// Download the file's current content without holding fs.writesMu,
+		// then check that another handle didn't open the file meanwhile
+		w, err := newPendingWrite(fs, name, pfsFile, truncate)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		fs.writesMu.Lock()
+		if existing, ok := fs.writes[name]; ok {
+			w.file.Close()
+			w = existing
+		} else {
+			fs.writes[name] = w
+		}
+		w.refs++
+		fs.writesMu.Unlock()
+		h = &writeHandle{File: nodefs.NewDefaultFile(), w: w}
+	}
+	if truncate {
+		if status := h.Truncate(0); status != fuse.OK {
+			h.Release()
+			return nil, status
+		}
+	}
+	return h, fuse.OK
+}
+
+// openPending returns a handle for the file 'name' if it's being written
+func (fs *filesystem) openPending(name string) (*writeHandle, bool) {
+	fs.writesMu.Lock()
+	defer fs.writesMu.Unlock()
+	w, ok := fs.writes[name]
+	if !ok {
+		return nil, false
+	}
+	w.refs++
+	return &writeHandle{File: nodefs.NewDefaultFile(), w: w}, true
+}
+
+// pendingAttr returns the attributes of the file 'name' if it's being
+// written
+func (fs *filesystem) pendingAttr(name string) (*fuse.Attr, fuse.Status, bool) {
+	fs.writesMu.Lock()
+	w, ok := fs.writes[name]
+	fs.writesMu.Unlock()
+	if !ok {
+		return nil, fuse.OK, false
+	}
+	attr, status := w.attr()
+	return attr, status, true
+}
+
+// commitOpen returns true if 'commit' hasn't been finished
+func (fs *filesystem) commitOpen(commit *pfs.Commit) (bool, error) {
+	fs.commitsMu.RLock()
+	finished := fs.finished[commit.ID]
+	fs.commitsMu.RUnlock()
+	if finished {
+		return false, nil
+	}
+	commitInfo, err := fs.c.InspectCommit(commit.Repo.Name, commit.ID)
+	if err != nil {
+		return false, err
+	}
+	if commitInfo.Finished != nil {
+		// commits can't be reopened, so there's no need to check again
+		fs.commitsMu.Lock()
+		defer fs.commitsMu.Unlock()
+		fs.finished[commit.ID] = true
+		return false, nil
+	}
+	return true, nil
+}
+
+// writeHandle is an open handle to a file that's being written
+type writeHandle struct {
+	nodefs.File
+	w *pendingWrite
+}
+
+func (h *writeHandle) String() string {
+	return h.w.name
+}
+
+func (h *writeHandle) InnerFile() nodefs.File {
+	return nil
+}
+
+func (h *writeHandle) Read(dest []byte, offset int64) (fuse.ReadResult, fuse.Status) {
+	return fuse.ReadResultFd(h.w.file.Fd(), offset, len(dest)), fuse.OK
+}
+
+func (h *writeHandle) Write(data []byte, offset int64) (uint32, fuse.Status) {
+	h.w.mu.Lock()
+	defer h.w.mu.Unlock()
+	n, err := h.w.file.WriteAt(data, offset)
+	h.w.dirty = true
+	return uint32(n), fuse.ToStatus(err)
+}
+
+func (h *writeHandle) Truncate(size uint64) fuse.Status {
+	h.w.mu.Lock()
+	defer h.w.mu.Unlock()
+	h.w.dirty = true
+	return fuse.ToStatus(h.w.file.Truncate(int64(size)))
+}
+
+func (h *writeHandle) Flush() fuse.Status {
+	return h.w.flush()
+}
+
+func (h *writeHandle) Fsync(flags int) fuse.Status {
+	return h.w.flush()
+}
+
+func (h *writeHandle) Release() {
+	fs := h.w.fs
+	fs.writesMu.Lock()
+	h.w.refs--
+	last := h.w.refs == 0
+	if last {
+		delete(fs.writes, h.w.name)
+	}
+	fs.writesMu.Unlock()
+	if last {
+		// Release can't return an error, so this is only a last attempt to
+		// upload writes that weren't flushed
+		h.w.flush()
+		h.w.file.Close()
+	}
+}
+
+func (h *writeHandle) GetAttr(out *fuse.Attr) fuse.Status {
+	attr, status := h.w.attr()
+	if status == fuse.OK {
+		*out = *attr
+	}
+	return status
+}
+
+func (h *writeHandle) Utimens(atime *time.Time, mtime *time.Time) fuse.Status {
+	// pfs doesn't store times, but editors and tools like touch set them
+	return fuse.OK
+}
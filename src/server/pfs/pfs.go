@@ -6,6 +6,7 @@ import (
 
 	"github.com/pachyderm/pachyderm/src/client/pfs"
 	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/client/pkg/schema"
 )
 
 // ErrFileNotFound represents a file-not-found error.
@@ -56,6 +57,28 @@ type ErrParentCommitNotFound struct {
 	Commit *pfs.Commit
 }
 
+// ErrBranchProtected represents an error where a change is prevented by a
+// branch's protection (e.g. from PutFile or DeleteCommit)
+type ErrBranchProtected struct {
+	Branch *pfs.Branch
+	Reason string
+}
+
+// ErrQuotaExceeded represents an error where a write is rejected because it
+// would take a repo over its quota (e.g. from PutFile or FinishCommit).
+// Resource is what the quota limits ("bytes" or "files").
+type ErrQuotaExceeded struct {
+	Repo     *pfs.Repo
+	Resource string
+	Usage    uint64
+	Limit    uint64
+}
+
+// ErrSchemaViolation represents an error where a commit is rejected because
+// files in it don't match its repo's schema (e.g. from FinishCommit or
+// PutFile)
+type ErrSchemaViolation = schema.ErrViolation
+
 func (e ErrFileNotFound) Error() string {
 	return fmt.Sprintf("file %v not found in repo %v at commit %v", e.File.Path, e.File.Commit.Repo.Name, e.File.Commit.ID)
 }
@@ -93,15 +116,34 @@ func (e ErrParentCommitNotFound) Error() string {
 	return fmt.Sprintf("parent commit %v not found in repo %v", e.Commit.ID, e.Commit.Repo.Name)
 }
 
+func (e ErrBranchProtected) Error() string {
+	return fmt.Sprintf("branch %v in repo %v is protected: %v", e.Branch.Name, e.Branch.Repo.Name, e.Reason)
+}
+
+func (e ErrQuotaExceeded) Error() string {
+	return fmt.Sprintf("repo %v would exceed its quota: %d %s is over the limit of %d %s", e.Repo.Name, e.Usage, e.Resource, e.Limit, e.Resource)
+}
+
 // ByteRangeSize returns byteRange.Upper - byteRange.Lower.
 func ByteRangeSize(byteRange *pfs.ByteRange) uint64 {
 	return byteRange.Upper - byteRange.Lower
 }
 
+// BlockRefSize returns the size of the data at blockRef, once it's decrypted
+// and decompressed (if it's encrypted or compressed).
+func BlockRefSize(blockRef *pfs.BlockRef) uint64 {
+	if blockRef.Compression != pfs.Compression_UNCOMPRESSED || blockRef.Encryption != nil {
+		return blockRef.UncompressedBytes
+	}
+	return ByteRangeSize(blockRef.Range)
+}
+
 var (
-	commitNotFoundRe = regexp.MustCompile("commit [^ ]+ not found in repo [^ ]+")
-	commitDeletedRe  = regexp.MustCompile("commit [^ ]+/[^ ]+ was deleted")
-	commitFinishedRe = regexp.MustCompile("commit [^ ]+ in repo [^ ]+ has already finished")
+	commitNotFoundRe  = regexp.MustCompile("commit [^ ]+ not found in repo [^ ]+")
+	commitDeletedRe   = regexp.MustCompile("commit [^ ]+/[^ ]+ was deleted")
+	commitFinishedRe  = regexp.MustCompile("commit [^ ]+ in repo [^ ]+ has already finished")
+	quotaExceededRe   = regexp.MustCompile("repo [^ ]+ would exceed its quota")
+	schemaViolationRe = regexp.MustCompile("file .+ in commit [^ ]+ doesn't match the schema of repo [^ ]+")
 )
 
 // IsCommitNotFoundErr returns true if 'err' has an error message that matches
@@ -130,3 +172,21 @@ func IsCommitFinishedErr(err error) bool {
 	}
 	return commitFinishedRe.MatchString(grpcutil.ScrubGRPC(err).Error())
 }
+
+// IsQuotaExceededErr returns true if 'err' has an error message that matches
+// ErrQuotaExceeded
+func IsQuotaExceededErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	return quotaExceededRe.MatchString(grpcutil.ScrubGRPC(err).Error())
+}
+
+// IsSchemaViolationErr returns true if 'err' has an error message that matches
+// ErrSchemaViolation
+func IsSchemaViolationErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	return schemaViolationRe.MatchString(grpcutil.ScrubGRPC(err).Error())
+}
@@ -1,9 +1,11 @@
 package pfs
 
 import (
+	"os"
 	"testing"
 
 	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
 	"github.com/pachyderm/pachyderm/src/client/pkg/require"
 )
 
@@ -20,4 +22,36 @@ func TestErrorMatching(t *testing.T) {
 	require.False(t, IsCommitFinishedErr(ErrCommitNotFound{c}))
 	require.False(t, IsCommitFinishedErr(ErrCommitDeleted{c}))
 	require.True(t, IsCommitFinishedErr(ErrCommitFinished{c}))
+
+	violations := []*pfs.SchemaViolation{{Path: "/a b", Error: "invalid"}, {Path: "/c", Error: "invalid"}}
+	require.True(t, IsSchemaViolationErr(ErrSchemaViolation{Commit: c, Violations: violations}))
+	require.True(t, IsSchemaViolationErr(ErrSchemaViolation{Commit: c, Violations: violations[:1]}))
+	require.False(t, IsSchemaViolationErr(ErrCommitFinished{c}))
+}
+
+func TestStorageFromEnv(t *testing.T) {
+	defer os.Unsetenv(CompressionEnvVar)
+	defer os.Unsetenv(CompressionLevelEnvVar)
+	defer os.Unsetenv(EncryptionEnvVar)
+
+	compression, err := CompressionFromEnv()
+	require.NoError(t, err)
+	require.Nil(t, compression)
+	require.Nil(t, EncryptionFromEnv())
+
+	os.Setenv(CompressionEnvVar, "zstd")
+	os.Setenv(CompressionLevelEnvVar, "3")
+	os.Setenv(EncryptionEnvVar, "vault://transit/keys/pachyderm")
+	compression, err = CompressionFromEnv()
+	require.NoError(t, err)
+	require.Equal(t, &pfs.CompressionSpec{Compression: pfs.Compression_ZSTD, Level: 3}, compression)
+	require.Equal(t, &pfs.EncryptionSpec{Key: "vault://transit/keys/pachyderm"}, EncryptionFromEnv())
+
+	os.Setenv(CompressionEnvVar, "lzma")
+	_, err = CompressionFromEnv()
+	require.YesError(t, err)
+	os.Setenv(CompressionEnvVar, "gzip")
+	os.Setenv(CompressionLevelEnvVar, "best")
+	_, err = CompressionFromEnv()
+	require.YesError(t, err)
 }
@@ -1,13 +1,17 @@
 package pretty
 
 import (
+	"encoding/hex"
 	"fmt"
 	"html/template"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/docker/go-units"
+	"github.com/gogo/protobuf/types"
 	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/manifest"
 	"github.com/pachyderm/pachyderm/src/server/pkg/pretty"
 )
 
@@ -22,6 +26,12 @@ const (
 	BranchHeader = "BRANCH\tHEAD\t\n"
 	// FileHeader is the header for files.
 	FileHeader = "COMMIT\tNAME\tTYPE\tCOMMITTED\tSIZE\t\n"
+	// RepoStorageHeader is the header for repos' storage usage.
+	RepoStorageHeader = "REPO\tLOGICAL\tPHYSICAL\tOBJECTS\t\n"
+	// ProvenanceEdgeHeader is the header for provenance edges.
+	ProvenanceEdgeHeader = "UPSTREAM\tDOWNSTREAM\t\n"
+	// ManifestChangeHeader is the header for differences between manifests.
+	ManifestChangeHeader = "PATH\tCHANGE\tSIZE\t\n"
 )
 
 // PrintRepoHeader prints a repo header.
@@ -48,13 +58,61 @@ func PrintRepoInfo(w io.Writer, repoInfo *pfs.RepoInfo) {
 	fmt.Fprintln(w)
 }
 
+// PrintRepoStorageInfo pretty-prints a repo's storage usage.
+func PrintRepoStorageInfo(w io.Writer, repoStorageInfo *pfs.RepoStorageInfo) {
+	fmt.Fprintf(w, "%s\t", repoStorageInfo.Repo.Name)
+	fmt.Fprintf(w, "%s\t", units.BytesSize(float64(repoStorageInfo.LogicalBytes)))
+	fmt.Fprintf(w, "%s\t", units.BytesSize(float64(repoStorageInfo.PhysicalBytes)))
+	fmt.Fprintf(w, "%d\t\n", repoStorageInfo.ObjectCount)
+}
+
+// PrintManifestChange pretty-prints a difference between manifests.
+func PrintManifestChange(w io.Writer, change *manifest.Change) {
+	fmt.Fprintf(w, "%s\t", change.Path)
+	switch change.Type {
+	case manifest.Modified:
+		fmt.Fprintf(w, "%s (%s)\t", change.Type, strings.Join(change.Fields, ", "))
+		fmt.Fprintf(w, "%s -> %s\t\n", units.BytesSize(float64(change.Old.SizeBytes)), units.BytesSize(float64(change.New.SizeBytes)))
+	case manifest.Added:
+		fmt.Fprintf(w, "%s\t%s\t\n", change.Type, units.BytesSize(float64(change.New.SizeBytes)))
+	default:
+		fmt.Fprintf(w, "%s\t%s\t\n", change.Type, units.BytesSize(float64(change.Old.SizeBytes)))
+	}
+}
+
+// PrintProvenanceEdge pretty-prints a provenance edge.
+func PrintProvenanceEdge(w io.Writer, edge *pfs.ProvenanceEdge) {
+	fmt.Fprintf(w, "%s/%s\t", edge.Upstream.Repo.Name, edge.Upstream.ID)
+	fmt.Fprintf(w, "%s/%s\t\n", edge.Downstream.Repo.Name, edge.Downstream.ID)
+}
+
+// PrintStorageTotals pretty-prints the totals in storage info.
+func PrintStorageTotals(storageInfo *pfs.StorageInfo) error {
+	template, err := template.New("StorageInfo").Funcs(funcMap).Parse(
+		`Logical size: {{prettySize .LogicalBytes}}
+Physical size: {{prettySize .PhysicalBytes}}
+Objects: {{.ObjectCount}}
+Orphaned objects (estimated): {{.OrphanedObjects}} ({{prettySize .OrphanedBytes}})
+`)
+	if err != nil {
+		return err
+	}
+	return template.Execute(os.Stdout, storageInfo)
+}
+
 // PrintDetailedRepoInfo pretty-prints detailed repo info.
 func PrintDetailedRepoInfo(repoInfo *pfs.RepoInfo) error {
 	template, err := template.New("RepoInfo").Funcs(funcMap).Parse(
 		`Name: {{.Repo.Name}}{{if .Description}}
 Description: {{.Description}}{{end}}
 Created: {{prettyAgo .Created}}
-Size: {{prettySize .SizeBytes}}{{if .AuthInfo}}
+Size: {{prettySize .SizeBytes}}{{if .DedupSavingsBytes}}
+Dedup savings: {{prettySize .DedupSavingsBytes}}{{end}}{{if .Retention}}
+Retention: {{retention .Retention}}{{end}}{{if .Quota}}
+Quota: {{quota .}}{{end}}{{if .Schema}}
+Schema: {{repoSchema .Schema}}{{end}}{{if .Compression}}
+Compression: {{compression .Compression}}{{end}}{{if .Encryption}}
+Encryption: {{encryption .Encryption}}{{end}}{{if .AuthInfo}}
 Access level: {{ .AuthInfo.AccessLevel.String }}{{end}}
 `)
 	if err != nil {
@@ -116,7 +174,10 @@ Parent: {{.ParentCommit.ID}}{{end}}
 Started: {{prettyAgo .Started}}{{if .Finished}}
 Finished: {{prettyAgo .Finished}} {{end}}
 Size: {{prettySize .SizeBytes}}{{if .Provenance}}
-Provenance: {{range .Provenance}} {{.Repo.Name}}/{{.ID}} {{end}} {{end}}
+Provenance: {{range .Provenance}} {{.Repo.Name}}/{{.ID}} {{end}} {{end}}{{if .Metadata}}
+Metadata: {{range $key, $value := .Metadata}} {{$key}}={{$value}} {{end}}{{end}}{{if .SchemaViolations}}
+Schema violations:{{range .SchemaViolations}}
+  {{.Path}}: {{.Error}}{{end}}{{end}}
 `)
 	if err != nil {
 		return err
@@ -153,7 +214,10 @@ func PrintDetailedFileInfo(fileInfo *pfs.FileInfo) error {
 	template, err := template.New("FileInfo").Funcs(funcMap).Parse(
 		`Path: {{.File.Path}}
 Type: {{fileType .FileType}}
-Size: {{prettySize .SizeBytes}}
+Size: {{prettySize .SizeBytes}}{{if .ContentSha256}}
+SHA256: {{hex .ContentSha256}}{{end}}{{if .ContentMd5}}
+MD5: {{hex .ContentMd5}}{{end}}{{if .Metadata}}
+Metadata: {{range $key, $value := .Metadata}} {{$key}}={{$value}} {{end}}{{end}}
 Children: {{range .Children}} {{.}} {{end}}
 `)
 	if err != nil {
@@ -176,7 +240,83 @@ func fileType(fileType pfs.FileType) string {
 }
 
 var funcMap = template.FuncMap{
-	"prettyAgo":  pretty.Ago,
-	"prettySize": pretty.Size,
-	"fileType":   fileType,
+	"prettyAgo":   pretty.Ago,
+	"prettySize":  pretty.Size,
+	"fileType":    fileType,
+	"hex":         hex.EncodeToString,
+	"retention":   retention,
+	"quota":       quota,
+	"repoSchema":  repoSchema,
+	"compression": compression,
+	"encryption":  encryption,
+}
+
+// compression describes a compression spec, e.g. "ZSTD (level 3)"
+func compression(spec *pfs.CompressionSpec) string {
+	if spec.Level == 0 {
+		return spec.Compression.String()
+	}
+	return fmt.Sprintf("%v (level %d)", spec.Compression, spec.Level)
+}
+
+// encryption describes an encryption spec: its key's URI, or "none"
+func encryption(spec *pfs.EncryptionSpec) string {
+	if spec.Key == "" {
+		return "none"
+	}
+	return spec.Key
+}
+
+// quota describes a repo's usage of its quota, e.g. "1.5 GiB of 10 GiB, 200 of
+// 1000 files"
+func quota(repoInfo *pfs.RepoInfo) string {
+	var usage []string
+	if repoInfo.Quota.MaxBytes > 0 {
+		usage = append(usage, fmt.Sprintf("%s of %s", pretty.Size(repoInfo.SizeBytes), pretty.Size(repoInfo.Quota.MaxBytes)))
+	}
+	if repoInfo.Quota.MaxFiles > 0 {
+		usage = append(usage, fmt.Sprintf("%d of %d files", repoInfo.FileCount, repoInfo.Quota.MaxFiles))
+	}
+	return strings.Join(usage, ", ")
+}
+
+// repoSchema describes a repo schema, e.g. "CSV with columns id,name for
+// files matching *.csv (REJECT)"
+func repoSchema(s *pfs.RepoSchema) string {
+	var format string
+	switch {
+	case s.Json != nil:
+		format = "JSON Schema"
+	case s.Csv != nil:
+		format = fmt.Sprintf("CSV with columns %s", strings.Join(s.Csv.Columns, ","))
+	case s.Proto != nil:
+		format = fmt.Sprintf("protobuf %s", s.Proto.Message)
+		if s.Proto.Delimited {
+			format += " (delimited)"
+		}
+	}
+	files := "all files"
+	if s.Glob != "" {
+		files = "files matching " + s.Glob
+	}
+	return fmt.Sprintf("%s for %s (%v)", format, files, s.Action)
+}
+
+// retention describes a retention policy, e.g. "keep 10 commits or 24h0m0s
+// (branches: master)"
+func retention(policy *pfs.RetentionPolicy) string {
+	var keep []string
+	if policy.KeepCommits > 0 {
+		keep = append(keep, fmt.Sprintf("%d commits", policy.KeepCommits))
+	}
+	if policy.KeepDuration != nil {
+		if d, err := types.DurationFromProto(policy.KeepDuration); err == nil {
+			keep = append(keep, d.String())
+		}
+	}
+	result := "keep " + strings.Join(keep, " or ")
+	if len(policy.Branches) > 0 {
+		result += fmt.Sprintf(" (branches: %s)", strings.Join(policy.Branches, ", "))
+	}
+	return result
 }
@@ -0,0 +1,431 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+)
+
+// Requests to the gateway are authenticated with AWS Signature Version 4
+// (https://docs.aws.amazon.com/general/latest/gr/signature-version-4.html),
+// either in the Authorization header or in the query parameters of a
+// presigned URL. Clients get their credentials from 'pachctl auth
+// get-s3-credentials', which registers their Pachyderm token with the
+// gateway: the access key ID is the token's SHA-256 hash, which isn't secret
+// (it appears in every request and presigned URL), and the secret key is a
+// random key that's registered with it, which is never sent. The secret key
+// isn't the token, so it only grants access through the gateway. The
+// signature proves that the caller has the secret key, and the request is
+// served as the token's user. Unsigned requests
+// are served anonymously, which only succeeds if auth isn't active, and so
+// are requests signed with access key IDs that aren't registered while auth
+// isn't active, so that any credentials work then.
+//
+// Payloads are checked against the payload hash that's signed in
+// X-Amz-Content-Sha256 and, in aws-chunked payloads, the chunks' signatures
+// (see payloadBody), unless the client chose not to sign them.
+
+const (
+	signV4Algorithm = "AWS4-HMAC-SHA256"
+	// amzDateFormat is the format of X-Amz-Date
+	amzDateFormat = "20060102T150405Z"
+	// scopeDateFormat is the format of the date in a credential scope
+	scopeDateFormat = "20060102"
+	// unsignedPayload is the payload hash of requests that don't sign their
+	// payload
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	// streamingPayload is the payload hash of requests whose payload is sent
+	// in signed aws-chunked encoding (see chunked.go)
+	streamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	// chunkAlgorithm is the algorithm of the signatures of aws-chunked chunks
+	chunkAlgorithm = "AWS4-HMAC-SHA256-PAYLOAD"
+	// maxClockSkew is how far the time that a request was signed can be from
+	// the gateway's clock (as in S3)
+	maxClockSkew = 15 * time.Minute
+	// maxPresignExpiry is the longest that a presigned URL can be valid for
+	// (as in S3)
+	maxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// signature is an AWS Signature Version 4 of a request
+type signature struct {
+	presigned     bool
+	accessKeyID   string
+	date          time.Time
+	scope         string
+	signedHeaders []string
+	signature     string
+	// expires is how long a presigned URL is valid for after 'date'
+	expires time.Duration
+
+	// payloadHash is the hash of the payload that was signed, and key is the
+	// key that the request was signed with, which also signs the chunks of
+	// aws-chunked payloads. Both are set by verify.
+	payloadHash string
+	key         []byte
+}
+
+// authenticate verifies the signature of 'r' at the time 'now', and returns
+// the signature and the token of the user whose credentials signed it. It
+// returns a nil
+// signature if 'r' should be served anonymously: if it isn't signed, or if
+// it's signed with an access key ID that isn't registered and auth isn't
+// active ('c' is used to check).
+func (h *handler) authenticate(r *http.Request, c *client.APIClient, now time.Time) (*signature, string, error) {
+	sig, err := parseSignature(r)
+	if err != nil || sig == nil {
+		return nil, "", err
+	}
+	secretKey, token, err := h.getCredentials(r, sig.accessKeyID)
+	if err != nil {
+		return nil, "", err
+	}
+	if secretKey == "" {
+		if _, err := c.WhoAmI(c.Ctx(), &auth.WhoAmIRequest{}); auth.IsErrNotActivated(err) {
+			return nil, "", nil
+		}
+		return nil, "", &s3Error{http.StatusForbidden, "InvalidAccessKeyId", "the access key ID is not registered; get credentials with 'pachctl auth get-s3-credentials'"}
+	}
+	if err := sig.verify(r, secretKey, now); err != nil {
+		return nil, "", err
+	}
+	return sig, token, nil
+}
+
+// parseSignature returns the signature of 'r', or nil if 'r' isn't signed
+func parseSignature(r *http.Request) (*signature, error) {
+	authorization := r.Header.Get("Authorization")
+	query := r.URL.Query()
+	switch {
+	case strings.HasPrefix(authorization, signV4Algorithm+" "):
+		return parseAuthorization(r, strings.TrimPrefix(authorization, signV4Algorithm+" "))
+	case authorization != "", has(query, "AWSAccessKeyId"):
+		return nil, &s3Error{http.StatusBadRequest, "InvalidRequest", "only AWS Signature Version 4 (" + signV4Algorithm + ") is supported"}
+	case has(query, "X-Amz-Algorithm"):
+		return parsePresigned(r)
+	default:
+		return nil, nil
+	}
+}
+
+// parseAuthorization parses the parameters of the Authorization header of
+// 'r', which follow the algorithm
+func parseAuthorization(r *http.Request, params string) (*signature, error) {
+	malformed := func(message string) error {
+		return &s3Error{http.StatusBadRequest, "AuthorizationHeaderMalformed", message}
+	}
+	values := make(map[string]string)
+	for _, param := range strings.Split(params, ",") {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) != 2 {
+			return nil, malformed(fmt.Sprintf("%q is not a key=value pair", param))
+		}
+		values[kv[0]] = kv[1]
+	}
+	for _, key := range []string{"Credential", "SignedHeaders", "Signature"} {
+		if values[key] == "" {
+			return nil, malformed(fmt.Sprintf("the Authorization header is missing %s", key))
+		}
+	}
+	var date time.Time
+	var err error
+	if amzDate := r.Header.Get("X-Amz-Date"); amzDate != "" {
+		date, err = time.Parse(amzDateFormat, amzDate)
+	} else {
+		date, err = http.ParseTime(r.Header.Get("Date"))
+	}
+	if err != nil {
+		return nil, &s3Error{http.StatusForbidden, "AccessDenied", "AWS authentication requires a valid Date or X-Amz-Date header"}
+	}
+	sig := &signature{
+		date:          date,
+		signedHeaders: strings.Split(values["SignedHeaders"], ";"),
+		signature:     values["Signature"],
+	}
+	if err := sig.parseCredential(values["Credential"]); err != nil {
+		return nil, malformed(err.Error())
+	}
+	return sig, nil
+}
+
+// parsePresigned parses the signature in the query parameters of 'r'
+func parsePresigned(r *http.Request) (*signature, error) {
+	malformed := func(message string) error {
+		return &s3Error{http.StatusBadRequest, "AuthorizationQueryParametersError", message}
+	}
+	query := r.URL.Query()
+	if query.Get("X-Amz-Algorithm") != signV4Algorithm {
+		return nil, malformed("X-Amz-Algorithm must be " + signV4Algorithm)
+	}
+	for _, key := range []string{"X-Amz-Credential", "X-Amz-Date", "X-Amz-Expires", "X-Amz-SignedHeaders", "X-Amz-Signature"} {
+		if query.Get(key) == "" {
+			return nil, malformed(fmt.Sprintf("the query parameter %s is missing", key))
+		}
+	}
+	date, err := time.Parse(amzDateFormat, query.Get("X-Amz-Date"))
+	if err != nil {
+		return nil, malformed("X-Amz-Date must be in the ISO8601 basic format (yyyyMMdd'T'HHmmss'Z')")
+	}
+	expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || expires < 1 || time.Duration(expires)*time.Second > maxPresignExpiry {
+		return nil, malformed(fmt.Sprintf("X-Amz-Expires must be between 1 and %d seconds", int(maxPresignExpiry.Seconds())))
+	}
+	sig := &signature{
+		presigned:     true,
+		date:          date,
+		signedHeaders: strings.Split(query.Get("X-Amz-SignedHeaders"), ";"),
+		signature:     query.Get("X-Amz-Signature"),
+		expires:       time.Duration(expires) * time.Second,
+	}
+	if err := sig.parseCredential(query.Get("X-Amz-Credential")); err != nil {
+		return nil, malformed(err.Error())
+	}
+	return sig, nil
+}
+
+// parseCredential parses 'credential', which is an access key ID followed by
+// the credential scope "<date>/<region>/s3/aws4_request"
+func (s *signature) parseCredential(credential string) error {
+	parts := strings.Split(credential, "/")
+	if len(parts) < 5 {
+		return fmt.Errorf("the credential %q is not of the form <access key ID>/<date>/<region>/s3/aws4_request", credential)
+	}
+	n := len(parts)
+	s.accessKeyID = strings.Join(parts[:n-4], "/")
+	s.scope = strings.Join(parts[n-4:], "/")
+	switch {
+	case parts[n-4] != s.date.UTC().Format(scopeDateFormat):
+		return fmt.Errorf("the date in the credential (%s) does not match the date of the request", parts[n-4])
+	case parts[n-2] != "s3":
+		return fmt.Errorf("the service in the credential must be s3, not %s", parts[n-2])
+	case parts[n-1] != "aws4_request":
+		return fmt.Errorf("the credential must end in aws4_request, not %s", parts[n-1])
+	}
+	return nil
+}
+
+// verify returns an error unless 's' is a valid signature of 'r' at 'now' by
+// the secret key 'secret'
+func (s *signature) verify(r *http.Request, secret string, now time.Time) error {
+	if s.presigned {
+		if s.date.After(now.Add(maxClockSkew)) {
+			return &s3Error{http.StatusForbidden, "AccessDenied", "the presigned URL is not valid yet"}
+		}
+		if now.After(s.date.Add(s.expires)) {
+			return &s3Error{http.StatusForbidden, "AccessDenied", "the presigned URL has expired"}
+		}
+	} else if d := now.Sub(s.date); d > maxClockSkew || d < -maxClockSkew {
+		return &s3Error{http.StatusForbidden, "RequestTimeTooSkewed", "the difference between the time of the request and the server's time is too large"}
+	}
+	signsHost := false
+	for _, header := range s.signedHeaders {
+		signsHost = signsHost || header == "host"
+	}
+	if !signsHost {
+		return &s3Error{http.StatusBadRequest, "InvalidRequest", "the host header must be signed"}
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if s.presigned {
+		payloadHash = r.URL.Query().Get("X-Amz-Content-Sha256")
+		if payloadHash == "" {
+			payloadHash = unsignedPayload
+		}
+	} else if payloadHash == "" {
+		return &s3Error{http.StatusBadRequest, "InvalidRequest", "the X-Amz-Content-Sha256 header is required"}
+	}
+	canonical := canonicalRequest(r, s.signedHeaders, payloadHash, s.presigned)
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{
+		signV4Algorithm,
+		s.date.UTC().Format(amzDateFormat),
+		s.scope,
+		hex.EncodeToString(canonicalHash[:]),
+	}, "\n")
+	key := signingKey(secret, s.scope)
+	if !hmac.Equal([]byte(s.signature), []byte(hex.EncodeToString(hmacSHA256(key, stringToSign)))) {
+		return &s3Error{http.StatusForbidden, "SignatureDoesNotMatch", "the request signature does not match the signature calculated with the secret key of the access key ID"}
+	}
+	s.payloadHash = payloadHash
+	s.key = key
+	return nil
+}
+
+// payloadBody returns the content of the payload 'body' of a request whose
+// payload hash (X-Amz-Content-Sha256) is 'payloadHash', and which was signed
+// with 'sig' (or nil, if it's served anonymously). aws-chunked payloads (which
+// the AWS SDKs send when they sign a payload that's streamed over HTTP) are
+// decoded. Reading content that doesn't match the payload hash, or chunks
+// whose signatures don't match, fails once the content has been read.
+func payloadBody(body io.Reader, payloadHash string, sig *signature) (io.Reader, error) {
+	switch {
+	case payloadHash == "", payloadHash == unsignedPayload:
+		return body, nil
+	case payloadHash == streamingPayload, payloadHash == streamingPayload+"-TRAILER":
+		if sig == nil {
+			// The chunks can't be verified without the signature they're
+			// chained from, but the request is served anonymously anyway
+			return newChunkedReader(body), nil
+		}
+		return newSignedChunkedReader(body, sig), nil
+	case strings.HasPrefix(payloadHash, "STREAMING-UNSIGNED-PAYLOAD"):
+		return newChunkedReader(body), nil
+	}
+	expected, err := hex.DecodeString(payloadHash)
+	if err != nil || len(expected) != sha256.Size {
+		return nil, &s3Error{http.StatusBadRequest, "InvalidArgument", fmt.Sprintf("%q is not a valid X-Amz-Content-Sha256", payloadHash)}
+	}
+	return &hashReader{r: body, hash: sha256.New(), expected: expected}, nil
+}
+
+// signedPayload returns true if payloadHash (see payloadBody) means that a
+// request's content is checked by payloadBody
+func signedPayload(payloadHash string) bool {
+	return payloadHash != "" && payloadHash != unsignedPayload && !strings.HasPrefix(payloadHash, "STREAMING-UNSIGNED-PAYLOAD")
+}
+
+// payload is the body of a request, as returned by payloadBody. 'checked' is
+// set if reading it fails when it doesn't match what was signed.
+type payload struct {
+	io.Reader
+	checked bool
+}
+
+// Close does nothing, as the http server closes the request's original body
+func (p *payload) Close() error {
+	return nil
+}
+
+// hashReader reads 'r', and fails at the end of its content unless the
+// content's SHA-256 hash is 'expected'
+type hashReader struct {
+	r        io.Reader
+	hash     hash.Hash
+	expected []byte
+}
+
+func (h *hashReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(h.hash.Sum(nil), h.expected) {
+		return n, &s3Error{http.StatusBadRequest, "XAmzContentSHA256Mismatch", "the content does not match the X-Amz-Content-Sha256 header"}
+	}
+	return n, err
+}
+
+// canonicalRequest returns the canonical form of 'r', which is what's signed
+func canonicalRequest(r *http.Request, signedHeaders []string, payloadHash string, presigned bool) string {
+	path := r.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	var params []string
+	for key, values := range r.URL.Query() {
+		if presigned && key == "X-Amz-Signature" {
+			continue
+		}
+		for _, value := range values {
+			params = append(params, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(params)
+	var headers []string
+	for _, name := range signedHeaders {
+		headers = append(headers, name+":"+headerValue(r, name)+"\n")
+	}
+	return strings.Join([]string{
+		r.Method,
+		uriEncode(path, false),
+		strings.Join(params, "&"),
+		strings.Join(headers, ""),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// headerValue returns the canonical value of the header 'name' of 'r'. Go
+// moves some headers out of r.Header into fields of 'r'.
+func headerValue(r *http.Request, name string) string {
+	switch name {
+	case "host":
+		return r.Host
+	case "content-length":
+		if r.Header.Get("Content-Length") == "" && r.ContentLength >= 0 {
+			return strconv.FormatInt(r.ContentLength, 10)
+		}
+	case "transfer-encoding":
+		if len(r.TransferEncoding) > 0 {
+			return strings.Join(r.TransferEncoding, ",")
+		}
+	}
+	var values []string
+	for _, value := range r.Header[http.CanonicalHeaderKey(name)] {
+		values = append(values, strings.Join(strings.Fields(value), " "))
+	}
+	return strings.Join(values, ",")
+}
+
+// uriEncode percent-encodes every byte of 's' except the unreserved
+// characters (and "/", unless 'encodeSlash' is set), as signatures require
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !encodeSlash {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// signingKey returns the key that's derived from 'secret' to sign requests in
+// the credential scope 'scope'
+func signingKey(secret string, scope string) []byte {
+	key := []byte("AWS4" + secret)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+	return key
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// authorize returns an error unless the user of 'c' has at least 'scope'
+// access to 'repo'. pfs checks access itself, but the gateway checks it
+// explicitly before using the repo that holds multipart uploads for users,
+// which they have no access to. Everyone has access if auth isn't active.
+func authorize(c *client.APIClient, repo string, scope auth.Scope) error {
+	resp, err := c.Authorize(c.Ctx(), &auth.AuthorizeRequest{
+		Repo:  repo,
+		Scope: scope,
+	})
+	if err != nil {
+		if auth.IsErrNotActivated(err) {
+			return nil
+		}
+		return grpcutil.ScrubGRPC(err)
+	}
+	if !resp.Authorized {
+		return &auth.ErrNotAuthorized{Repo: repo, Required: scope}
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package s3
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/server/pkg/errutil"
+)
+
+// maxKeys is the most keys that are returned by one request to list objects
+const maxKeys = 1000
+
+type locationConstraint struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+}
+
+type listAllMyBucketsResult struct {
+	XMLName xml.Name     `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListAllMyBucketsResult"`
+	Buckets []bucketInfo `xml:"Buckets>Bucket"`
+}
+
+type bucketInfo struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+// listBuckets lists a bucket for each branch of each repo that the user has
+// access to, and for the master branch of repos that don't have one yet
+func listBuckets(w http.ResponseWriter, c *client.APIClient) error {
+	repoInfos, err := c.ListRepo()
+	if err != nil {
+		return err
+	}
+	result := &listAllMyBucketsResult{}
+	for _, repoInfo := range repoInfos {
+		if repoInfo.Repo.Name == multipartRepo {
+			continue
+		}
+		// AuthInfo is only set if auth is active
+		if repoInfo.AuthInfo != nil && repoInfo.AuthInfo.AccessLevel == auth.Scope_NONE {
+			continue
+		}
+		branchInfos, err := c.ListBranch(repoInfo.Repo.Name)
+		if err != nil {
+			return err
+		}
+		branches := []string{"master"}
+		for _, branchInfo := range branchInfos {
+			if branchInfo.Branch.Name != "master" {
+				branches = append(branches, branchInfo.Branch.Name)
+			}
+		}
+		for _, branch := range branches {
+			// pfs doesn't record when branches are created
+			result.Buckets = append(result.Buckets, bucketInfo{
+				Name:         bucketName(repoInfo.Repo.Name, branch),
+				CreationDate: formatTime(timestamp(repoInfo.Created)),
+			})
+		}
+	}
+	sort.Slice(result.Buckets, func(i, j int) bool { return result.Buckets[i].Name < result.Buckets[j].Name })
+	return writeXML(w, http.StatusOK, result)
+}
+
+type object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         uint64 `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// listResult holds the fields shared by the results of ListObjects and
+// ListObjectsV2
+type listResult struct {
+	Name           string         `xml:"Name"`
+	Prefix         string         `xml:"Prefix"`
+	Delimiter      string         `xml:"Delimiter,omitempty"`
+	MaxKeys        int            `xml:"MaxKeys"`
+	EncodingType   string         `xml:"EncodingType,omitempty"`
+	IsTruncated    bool           `xml:"IsTruncated"`
+	Contents       []object       `xml:"Contents"`
+	CommonPrefixes []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type listBucketResult struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	listResult
+	Marker     string `xml:"Marker"`
+	NextMarker string `xml:"NextMarker,omitempty"`
+}
+
+type listBucketV2Result struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	listResult
+	KeyCount              int    `xml:"KeyCount"`
+	StartAfter            string `xml:"StartAfter,omitempty"`
+	ContinuationToken     string `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string `xml:"NextContinuationToken,omitempty"`
+}
+
+// listEntry is a key, or a common prefix of keys, in a listing
+type listEntry struct {
+	key string
+	// fileInfo is the file that the key refers to, or nil if the entry is a
+	// common prefix
+	fileInfo *pfs.FileInfo
+}
+
+// listObjects serves ListObjects and ListObjectsV2 (if list-type=2). Keys
+// are returned in order, and the last key (or common prefix) returned is the
+// marker (or continuation token) that continues the listing.
+func listObjects(w http.ResponseWriter, r *http.Request, c *client.APIClient, b *bucket) error {
+	query := r.URL.Query()
+	v2 := query.Get("list-type") == "2"
+	result := listResult{
+		Name:         b.name,
+		Prefix:       query.Get("prefix"),
+		Delimiter:    query.Get("delimiter"),
+		MaxKeys:      maxKeys,
+		EncodingType: query.Get("encoding-type"),
+	}
+	if s := query.Get("max-keys"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return invalidArgumentError("max-keys must be a non-negative integer")
+		}
+		if n < maxKeys {
+			result.MaxKeys = n
+		}
+	}
+	if result.EncodingType != "" && result.EncodingType != "url" {
+		return invalidArgumentError("encoding-type must be url")
+	}
+	encode := func(s string) string {
+		if result.EncodingType == "" {
+			return s
+		}
+		return strings.Replace(url.QueryEscape(s), "%2F", "/", -1)
+	}
+	marker := query.Get("marker")
+	if v2 {
+		marker = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			marker = token
+		}
+	}
+
+	commitInfo, err := b.head(c)
+	if err != nil {
+		return err
+	}
+	entries, err := listEntries(c, commitInfo, result.Prefix, result.Delimiter, false)
+	if err != nil {
+		return err
+	}
+	var next string
+	for _, entry := range entries {
+		if entry.key <= marker {
+			continue
+		}
+		if len(result.Contents)+len(result.CommonPrefixes) == result.MaxKeys {
+			result.IsTruncated = true
+			break
+		}
+		next = entry.key
+		if entry.fileInfo == nil {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: encode(entry.key)})
+			continue
+		}
+		result.Contents = append(result.Contents, object{
+			Key:          encode(entry.key),
+			LastModified: formatTime(commitTime(commitInfo)),
+			ETag:         etag(entry.fileInfo),
+			Size:         entry.fileInfo.SizeBytes,
+			StorageClass: "STANDARD",
+		})
+	}
+	if !result.IsTruncated {
+		next = ""
+	}
+	result.Prefix, result.Delimiter = encode(result.Prefix), encode(result.Delimiter)
+	if v2 {
+		return writeXML(w, http.StatusOK, &listBucketV2Result{
+			listResult:            result,
+			KeyCount:              len(result.Contents) + len(result.CommonPrefixes),
+			StartAfter:            encode(query.Get("start-after")),
+			ContinuationToken:     query.Get("continuation-token"),
+			NextContinuationToken: next,
+		})
+	}
+	return writeXML(w, http.StatusOK, &listBucketResult{
+		listResult: result,
+		Marker:     encode(marker),
+		NextMarker: encode(next),
+	})
+}
+
+// listEntries returns the keys in the commit 'commitInfo' (which may be nil,
+// if the branch has no head) that start with 'prefix', in order. Keys that
+// contain 'delimiter' after the prefix are rolled up into a single entry for
+// their common prefix, up to and including the delimiter. If 'history' is
+// set, each key has an entry for each of its versions, newest first.
+func listEntries(c *client.APIClient, commitInfo *pfs.CommitInfo, prefix string, delimiter string, history bool) ([]listEntry, error) {
+	if commitInfo == nil {
+		return nil, nil
+	}
+	var result []listEntry
+	prefixes := make(map[string]bool)
+	add := func(key string, fileInfo *pfs.FileInfo) {
+		if !strings.HasPrefix(key, prefix) {
+			return
+		}
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				p := key[:len(prefix)+i+len(delimiter)]
+				if !prefixes[p] {
+					prefixes[p] = true
+					result = append(result, listEntry{key: p})
+				}
+				return
+			}
+		}
+		if fileInfo.FileType == pfs.FileType_FILE {
+			result = append(result, listEntry{key: key, fileInfo: fileInfo})
+		}
+	}
+	// Only the directory that the prefix is in needs to be read
+	var dir string
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = prefix[:i]
+	}
+	repo, commitID := commitInfo.Commit.Repo.Name, commitInfo.Commit.ID
+	var versions int64
+	if history {
+		versions = -1
+	}
+	var err error
+	if delimiter == "/" {
+		// Each directory's keys share a common prefix, so the files in the
+		// directory's subdirectories don't need to be read
+		err = c.ListFileF(repo, commitID, dir, versions, func(fileInfo *pfs.FileInfo) error {
+			key := strings.TrimPrefix(fileInfo.File.Path, "/")
+			if fileInfo.FileType == pfs.FileType_DIR {
+				key += "/"
+			}
+			add(key, fileInfo)
+			return nil
+		})
+	} else {
+		var files []string
+		err = c.Walk(repo, commitID, dir, func(fileInfo *pfs.FileInfo) error {
+			if fileInfo.FileType == pfs.FileType_FILE {
+				if history {
+					files = append(files, fileInfo.File.Path)
+					return nil
+				}
+				add(strings.TrimPrefix(fileInfo.File.Path, "/"), fileInfo)
+			}
+			return nil
+		})
+		// Walk doesn't return history, so each file's is read separately
+		for _, file := range files {
+			if err != nil {
+				break
+			}
+			if !strings.HasPrefix(strings.TrimPrefix(file, "/"), prefix) {
+				continue
+			}
+			err = c.ListFileF(repo, commitID, file, versions, func(fileInfo *pfs.FileInfo) error {
+				add(strings.TrimPrefix(fileInfo.File.Path, "/"), fileInfo)
+				return nil
+			})
+		}
+	}
+	if err != nil && !errutil.IsNotFoundError(err) {
+		return nil, err
+	}
+	// The sort is stable, so that each key's versions stay newest first
+	sort.SliceStable(result, func(i, j int) bool { return result[i].key < result[j].key })
+	return result, nil
+}
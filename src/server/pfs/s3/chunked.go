@@ -0,0 +1,157 @@
+package s3
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// emptySHA256 is the hex SHA-256 hash of no data
+var emptySHA256 = hex.EncodeToString(sha256.New().Sum(nil))
+
+// chunkedReader decodes a body in aws-chunked encoding, in which the content
+// is sent as a series of chunks, each preceded by a line holding its size
+// (in hex) and signature:
+//
+//	<size>;chunk-signature=<signature>\r\n<data>\r\n
+//
+// and followed by an empty chunk (which may be followed by trailing
+// headers). Each chunk's signature signs its data and the previous chunk's
+// signature (or the request's, for the first chunk), so chunks can't be
+// changed, reordered or dropped.
+type chunkedReader struct {
+	r *bufio.Reader
+	// remaining is the number of bytes of the current chunk's data that
+	// haven't been read
+	remaining int64
+	done      bool
+
+	// sig is the signature of the request, if the chunks' signatures are
+	// checked. prevSignature is the signature of the previous chunk, and
+	// chunkSignature and chunkHash are the signature and hash of the current
+	// one.
+	sig            *signature
+	prevSignature  string
+	chunkSignature string
+	chunkHash      hash.Hash
+}
+
+// newChunkedReader returns a chunkedReader that doesn't check the chunks'
+// signatures
+func newChunkedReader(r io.Reader) *chunkedReader {
+	return &chunkedReader{r: bufio.NewReader(r)}
+}
+
+// newSignedChunkedReader returns a chunkedReader that checks the chunks'
+// signatures, which are chained from the request's signature 'sig'
+func newSignedChunkedReader(r io.Reader, sig *signature) *chunkedReader {
+	return &chunkedReader{
+		r:             bufio.NewReader(r),
+		sig:           sig,
+		prevSignature: sig.signature,
+		chunkHash:     sha256.New(),
+	}
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.nextChunk(); err != nil {
+			return 0, err
+		}
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	if c.sig != nil {
+		c.chunkHash.Write(p[:n])
+	}
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	if err == nil && c.remaining == 0 {
+		if err = c.readCRLF(); err == nil {
+			err = c.verifyChunk()
+		}
+	}
+	return n, err
+}
+
+// verifyChunk returns an error unless the signature of the chunk that was
+// just read is valid (if signatures are checked)
+func (c *chunkedReader) verifyChunk() error {
+	if c.sig == nil {
+		return nil
+	}
+	stringToSign := strings.Join([]string{
+		chunkAlgorithm,
+		c.sig.date.UTC().Format(amzDateFormat),
+		c.sig.scope,
+		c.prevSignature,
+		emptySHA256,
+		hex.EncodeToString(c.chunkHash.Sum(nil)),
+	}, "\n")
+	expected := hex.EncodeToString(hmacSHA256(c.sig.key, stringToSign))
+	if !hmac.Equal([]byte(c.chunkSignature), []byte(expected)) {
+		return &s3Error{http.StatusForbidden, "SignatureDoesNotMatch", "the signature of a chunk of the aws-chunked payload does not match"}
+	}
+	c.prevSignature = c.chunkSignature
+	c.chunkHash.Reset()
+	return nil
+}
+
+// nextChunk reads the line that precedes the next chunk
+func (c *chunkedReader) nextChunk() error {
+	line, err := c.r.ReadSlice('\n')
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	} else if err == bufio.ErrBufferFull {
+		return fmt.Errorf("malformed aws-chunked body: chunk header is too long")
+	} else if err != nil {
+		return err
+	}
+	header := strings.TrimSpace(string(line))
+	c.chunkSignature = ""
+	if i := strings.IndexByte(header, ';'); i >= 0 {
+		c.chunkSignature = strings.TrimPrefix(header[i+1:], "chunk-signature=")
+		header = header[:i]
+	}
+	size, err := strconv.ParseInt(header, 16, 64)
+	if err != nil || size < 0 {
+		return fmt.Errorf("malformed aws-chunked body: invalid chunk size %q", header)
+	}
+	c.remaining = size
+	if size == 0 {
+		c.done = true
+		// The final chunk has no data (and the trailing headers, if any,
+		// aren't read)
+		return c.verifyChunk()
+	}
+	return nil
+}
+
+// readCRLF reads the line break that follows each chunk's data
+func (c *chunkedReader) readCRLF() error {
+	var crlf [2]byte
+	if _, err := io.ReadFull(c.r, crlf[:]); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if string(crlf[:]) != "\r\n" {
+		return fmt.Errorf("malformed aws-chunked body: chunk data is not followed by CRLF")
+	}
+	return nil
+}
@@ -0,0 +1,476 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/server/pkg/errutil"
+	"github.com/pachyderm/pachyderm/src/server/pkg/uuid"
+)
+
+// multipartRepo holds the parts of multipart uploads until the uploads are
+// completed or aborted. It's created when it's first needed, and isn't
+// served as a bucket. Users have no access to it, so it's only read and
+// written with the handler's internal client, once the user's access to the
+// upload's bucket has been checked. Each upload is a directory in its master
+// branch:
+//
+//	<upload ID>/info       the upload's uploadInfo, as JSON
+//	<upload ID>/parts/<n>  part n of the upload (zero-padded to 5 digits)
+//	<upload ID>/object     the parts, concatenated when the upload completes
+const multipartRepo = "_s3gateway_multipart"
+
+// maxPartNumber is the highest part number of multipart uploads (as in S3)
+const maxPartNumber = 10000
+
+// maxParts is the most parts that are returned by one request to list parts
+const maxParts = 1000
+
+// uploadInfo describes a multipart upload
+type uploadInfo struct {
+	Repo      string    `json:"repo"`
+	Branch    string    `json:"branch"`
+	Key       string    `json:"key"`
+	Initiated time.Time `json:"initiated"`
+}
+
+func partPath(uploadID string, partNumber int) string {
+	return path.Join(uploadID, "parts", fmt.Sprintf("%05d", partNumber))
+}
+
+// getUpload returns the multipart upload 'uploadID' of 'key' in 'b'
+func getUpload(mc *client.APIClient, b *bucket, key string, uploadID string) (*uploadInfo, error) {
+	if !uuid.IsUUIDWithoutDashes(uploadID) {
+		return nil, noSuchUploadError(uploadID)
+	}
+	var buf bytes.Buffer
+	if err := mc.GetFile(multipartRepo, "master", path.Join(uploadID, "info"), 0, 0, &buf); err != nil {
+		if errutil.IsNotFoundError(err) {
+			return nil, noSuchUploadError(uploadID)
+		}
+		return nil, err
+	}
+	info := &uploadInfo{}
+	if err := json.Unmarshal(buf.Bytes(), info); err != nil {
+		return nil, err
+	}
+	if info.Repo != b.repo || info.Branch != b.branch || info.Key != key {
+		return nil, noSuchUploadError(uploadID)
+	}
+	return info, nil
+}
+
+// listUploadParts returns the parts of the upload 'uploadID' that have been
+// uploaded, by part number
+func listUploadParts(mc *client.APIClient, uploadID string) (map[int]*pfs.FileInfo, error) {
+	result := make(map[int]*pfs.FileInfo)
+	if err := mc.ListFileF(multipartRepo, "master", path.Join(uploadID, "parts"), 0, func(fileInfo *pfs.FileInfo) error {
+		partNumber, err := strconv.Atoi(path.Base(fileInfo.File.Path))
+		if err != nil {
+			return err
+		}
+		result[partNumber] = fileInfo
+		return nil
+	}); err != nil && !errutil.IsNotFoundError(err) {
+		return nil, err
+	}
+	return result, nil
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+func createMultipartUpload(w http.ResponseWriter, c *client.APIClient, mc *client.APIClient, b *bucket, key string) error {
+	if _, err := b.head(c); err != nil {
+		return err
+	}
+	if err := authorize(c, b.repo, auth.Scope_WRITER); err != nil {
+		return err
+	}
+	if strings.HasSuffix(key, "/") {
+		return invalidArgumentError("objects can't be uploaded to keys that end in /")
+	}
+	if err := mc.CreateRepo(multipartRepo); err != nil && !errutil.IsAlreadyExistError(err) {
+		return err
+	}
+	uploadID := uuid.NewWithoutDashes()
+	info, err := json.Marshal(&uploadInfo{
+		Repo:      b.repo,
+		Branch:    b.branch,
+		Key:       key,
+		Initiated: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := mc.PutFile(multipartRepo, "master", path.Join(uploadID, "info"), bytes.NewReader(info)); err != nil {
+		return err
+	}
+	return writeXML(w, http.StatusOK, &initiateMultipartUploadResult{
+		Bucket:   b.name,
+		Key:      key,
+		UploadID: uploadID,
+	})
+}
+
+type copyPartResult struct {
+	XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CopyPartResult"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+}
+
+// uploadPart serves UploadPart, and UploadPartCopy (if the request has an
+// x-amz-copy-source header). Parts replace earlier parts with the same
+// number.
+func uploadPart(w http.ResponseWriter, r *http.Request, c *client.APIClient, mc *client.APIClient, b *bucket, key string) error {
+	query := r.URL.Query()
+	partNumber, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > maxPartNumber {
+		return invalidArgumentError(fmt.Sprintf("partNumber must be an integer between 1 and %d", maxPartNumber))
+	}
+	if err := authorize(c, b.repo, auth.Scope_WRITER); err != nil {
+		return err
+	}
+	uploadID := query.Get("uploadId")
+	if _, err := getUpload(mc, b, key, uploadID); err != nil {
+		return err
+	}
+	if r.Header.Get("x-amz-copy-source") != "" {
+		return uploadPartCopy(w, r, c, mc, partPath(uploadID, partNumber))
+	}
+	etag, err := putContent(mc, r, multipartRepo, "master", partPath(uploadID, partNumber))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("ETag", etag)
+	return nil
+}
+
+// uploadPartCopy copies the object in the x-amz-copy-source header of 'r' (or
+// the range of it in the x-amz-copy-source-range header) to the part at
+// 'partPath'. The object is read as the user.
+func uploadPartCopy(w http.ResponseWriter, r *http.Request, c *client.APIClient, mc *client.APIClient, partPath string) error {
+	src, srcKey, srcVersionID, err := copySource(r)
+	if err != nil {
+		return err
+	}
+	commitInfo, fileInfo, err := src.fileVersion(c, srcKey, srcVersionID)
+	if err != nil {
+		return err
+	}
+	var offset, size int64
+	if byteRange := r.Header.Get("x-amz-copy-source-range"); byteRange != "" {
+		var first, last int64
+		if _, err := fmt.Sscanf(byteRange, "bytes=%d-%d", &first, &last); err != nil ||
+			first < 0 || last < first || uint64(last) >= fileInfo.SizeBytes {
+			return invalidArgumentError(fmt.Sprintf("x-amz-copy-source-range %q is not valid", byteRange))
+		}
+		offset, size = first, last-first+1
+	}
+	content, err := c.GetFileReader(src.repo, commitInfo.Commit.ID, srcKey, offset, size)
+	if err != nil {
+		return err
+	}
+	hash := md5.New()
+	if _, err := mc.PutFileOverwrite(multipartRepo, "master", partPath, io.TeeReader(content, hash), 0); err != nil {
+		return err
+	}
+	w.Header().Set("x-amz-copy-source-version-id", commitInfo.Commit.ID)
+	return writeXML(w, http.StatusOK, &copyPartResult{
+		LastModified: formatTime(time.Now()),
+		ETag:         fmt.Sprintf(`"%s"`, hex.EncodeToString(hash.Sum(nil))),
+	})
+}
+
+type completeMultipartUploadRequest struct {
+	Parts []struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	} `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// completeMultipartUpload concatenates the parts listed in the request, and
+// writes the result to the upload's key in a single commit, so that readers
+// never see a partial object
+func completeMultipartUpload(w http.ResponseWriter, r *http.Request, c *client.APIClient, mc *client.APIClient, b *bucket, key string) error {
+	if err := authorize(c, b.repo, auth.Scope_WRITER); err != nil {
+		return err
+	}
+	uploadID := r.URL.Query().Get("uploadId")
+	if _, err := getUpload(mc, b, key, uploadID); err != nil {
+		return err
+	}
+	var request completeMultipartUploadRequest
+	if err := decodeXML(r, &request); err != nil {
+		return err
+	}
+	if len(request.Parts) == 0 {
+		return malformedXMLError(fmt.Errorf("no parts were given"))
+	}
+	parts, err := listUploadParts(mc, uploadID)
+	if err != nil {
+		return err
+	}
+	previous := 0
+	for _, part := range request.Parts {
+		if part.PartNumber <= previous {
+			return &s3Error{http.StatusBadRequest, "InvalidPartOrder", "the parts must be listed in ascending order of part number"}
+		}
+		previous = part.PartNumber
+		fileInfo, ok := parts[part.PartNumber]
+		if !ok || strings.Trim(part.ETag, `"`) != strings.Trim(etag(fileInfo), `"`) {
+			return &s3Error{http.StatusBadRequest, "InvalidPart", fmt.Sprintf("part %d has not been uploaded, or its ETag does not match", part.PartNumber)}
+		}
+	}
+	objectPath := path.Join(uploadID, "object")
+	for i, part := range request.Parts {
+		if err := mc.CopyFile(multipartRepo, "master", partPath(uploadID, part.PartNumber), multipartRepo, "master", objectPath, i == 0); err != nil {
+			return err
+		}
+	}
+	if _, err := b.head(c); err != nil {
+		return err
+	}
+	// The user can't read the multipart repo, so the object is read by the
+	// internal client, but it's written by the user's client, so that the
+	// write is authorized (and subject to branch protection and quotas) like
+	// any other PutObject
+	content, err := mc.GetFileReader(multipartRepo, "master", objectPath, 0, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := c.PutFileOverwrite(b.repo, b.branch, key, content, 0); err != nil {
+		return err
+	}
+	if err := mc.DeleteFile(multipartRepo, "master", uploadID); err != nil {
+		return err
+	}
+	_, fileInfo, err := b.file(c, key)
+	if err != nil {
+		return err
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return writeXML(w, http.StatusOK, &completeMultipartUploadResult{
+		Location: fmt.Sprintf("%s://%s/%s/%s", scheme, r.Host, b.name, key),
+		Bucket:   b.name,
+		Key:      key,
+		ETag:     etag(fileInfo),
+	})
+}
+
+func abortMultipartUpload(w http.ResponseWriter, r *http.Request, c *client.APIClient, mc *client.APIClient, b *bucket, key string) error {
+	if err := authorize(c, b.repo, auth.Scope_WRITER); err != nil {
+		return err
+	}
+	uploadID := r.URL.Query().Get("uploadId")
+	if _, err := getUpload(mc, b, key, uploadID); err != nil {
+		return err
+	}
+	if err := mc.DeleteFile(multipartRepo, "master", uploadID); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+type listPartsResult struct {
+	XMLName              xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListPartsResult"`
+	Bucket               string   `xml:"Bucket"`
+	Key                  string   `xml:"Key"`
+	UploadID             string   `xml:"UploadId"`
+	StorageClass         string   `xml:"StorageClass"`
+	PartNumberMarker     int      `xml:"PartNumberMarker"`
+	NextPartNumberMarker int      `xml:"NextPartNumberMarker"`
+	MaxParts             int      `xml:"MaxParts"`
+	IsTruncated          bool     `xml:"IsTruncated"`
+	Parts                []part   `xml:"Part"`
+}
+
+type part struct {
+	PartNumber   int    `xml:"PartNumber"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         uint64 `xml:"Size"`
+}
+
+func listParts(w http.ResponseWriter, r *http.Request, c *client.APIClient, mc *client.APIClient, b *bucket, key string) error {
+	if err := authorize(c, b.repo, auth.Scope_READER); err != nil {
+		return err
+	}
+	query := r.URL.Query()
+	uploadID := query.Get("uploadId")
+	info, err := getUpload(mc, b, key, uploadID)
+	if err != nil {
+		return err
+	}
+	result := &listPartsResult{
+		Bucket:       b.name,
+		Key:          key,
+		UploadID:     uploadID,
+		StorageClass: "STANDARD",
+		MaxParts:     maxParts,
+	}
+	if s := query.Get("max-parts"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return invalidArgumentError("max-parts must be a non-negative integer")
+		}
+		if n < maxParts {
+			result.MaxParts = n
+		}
+	}
+	if s := query.Get("part-number-marker"); s != "" {
+		if result.PartNumberMarker, err = strconv.Atoi(s); err != nil {
+			return invalidArgumentError("part-number-marker must be an integer")
+		}
+	}
+	parts, err := listUploadParts(mc, uploadID)
+	if err != nil {
+		return err
+	}
+	var partNumbers []int
+	for partNumber := range parts {
+		if partNumber > result.PartNumberMarker {
+			partNumbers = append(partNumbers, partNumber)
+		}
+	}
+	sort.Ints(partNumbers)
+	for _, partNumber := range partNumbers {
+		if len(result.Parts) == result.MaxParts {
+			result.IsTruncated = true
+			break
+		}
+		result.Parts = append(result.Parts, part{
+			PartNumber: partNumber,
+			// pfs doesn't record when each part was written
+			LastModified: formatTime(info.Initiated),
+			ETag:         etag(parts[partNumber]),
+			Size:         parts[partNumber].SizeBytes,
+		})
+		result.NextPartNumberMarker = partNumber
+	}
+	return writeXML(w, http.StatusOK, result)
+}
+
+type listMultipartUploadsResult struct {
+	XMLName            xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListMultipartUploadsResult"`
+	Bucket             string   `xml:"Bucket"`
+	KeyMarker          string   `xml:"KeyMarker"`
+	UploadIDMarker     string   `xml:"UploadIdMarker"`
+	NextKeyMarker      string   `xml:"NextKeyMarker"`
+	NextUploadIDMarker string   `xml:"NextUploadIdMarker"`
+	Prefix             string   `xml:"Prefix"`
+	MaxUploads         int      `xml:"MaxUploads"`
+	IsTruncated        bool     `xml:"IsTruncated"`
+	Uploads            []upload `xml:"Upload"`
+}
+
+type upload struct {
+	Key          string `xml:"Key"`
+	UploadID     string `xml:"UploadId"`
+	StorageClass string `xml:"StorageClass"`
+	Initiated    string `xml:"Initiated"`
+}
+
+// listMultipartUploads lists the bucket's multipart uploads that are in
+// progress, ordered by key and then upload ID
+func listMultipartUploads(w http.ResponseWriter, r *http.Request, c *client.APIClient, mc *client.APIClient, b *bucket) error {
+	query := r.URL.Query()
+	if _, err := b.head(c); err != nil {
+		return err
+	}
+	if err := authorize(c, b.repo, auth.Scope_READER); err != nil {
+		return err
+	}
+	result := &listMultipartUploadsResult{
+		Bucket:         b.name,
+		KeyMarker:      query.Get("key-marker"),
+		UploadIDMarker: query.Get("upload-id-marker"),
+		Prefix:         query.Get("prefix"),
+		MaxUploads:     maxKeys,
+	}
+	if s := query.Get("max-uploads"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return invalidArgumentError("max-uploads must be a non-negative integer")
+		}
+		if n < maxKeys {
+			result.MaxUploads = n
+		}
+	}
+	var uploads []upload
+	if err := mc.ListFileF(multipartRepo, "master", "", 0, func(fileInfo *pfs.FileInfo) error {
+		uploadID := path.Base(fileInfo.File.Path)
+		var buf bytes.Buffer
+		if err := mc.GetFile(multipartRepo, "master", path.Join(uploadID, "info"), 0, 0, &buf); err != nil {
+			if errutil.IsNotFoundError(err) {
+				return nil
+			}
+			return err
+		}
+		var info uploadInfo
+		if err := json.Unmarshal(buf.Bytes(), &info); err != nil {
+			return err
+		}
+		if info.Repo != b.repo || info.Branch != b.branch || !strings.HasPrefix(info.Key, result.Prefix) {
+			return nil
+		}
+		uploads = append(uploads, upload{
+			Key:          info.Key,
+			UploadID:     uploadID,
+			StorageClass: "STANDARD",
+			Initiated:    formatTime(info.Initiated),
+		})
+		return nil
+	}); err != nil && !errutil.IsNotFoundError(err) {
+		return err
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].Key != uploads[j].Key {
+			return uploads[i].Key < uploads[j].Key
+		}
+		return uploads[i].UploadID < uploads[j].UploadID
+	})
+	for _, u := range uploads {
+		if result.KeyMarker != "" && (u.Key < result.KeyMarker ||
+			(u.Key == result.KeyMarker && (result.UploadIDMarker == "" || u.UploadID <= result.UploadIDMarker))) {
+			continue
+		}
+		if len(result.Uploads) == result.MaxUploads {
+			result.IsTruncated = true
+			break
+		}
+		result.Uploads = append(result.Uploads, u)
+		result.NextKeyMarker, result.NextUploadIDMarker = u.Key, u.UploadID
+	}
+	return writeXML(w, http.StatusOK, result)
+}
@@ -0,0 +1,341 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/server/pkg/errutil"
+)
+
+// emptyETag is the ETag of an empty object
+const emptyETag = `"d41d8cd98f00b204e9800998ecf8427e"`
+
+// maxDeleteObjects is the most objects that DeleteObjects deletes at once
+const maxDeleteObjects = 1000
+
+// file returns the file that 'key' refers to in the head commit of the
+// bucket's branch
+func (b *bucket) file(c *client.APIClient, key string) (*pfs.CommitInfo, *pfs.FileInfo, error) {
+	return b.fileVersion(c, key, "")
+}
+
+// fileVersion returns the file that 'key' refers to in the version
+// 'versionID' (see commit)
+func (b *bucket) fileVersion(c *client.APIClient, key string, versionID string) (*pfs.CommitInfo, *pfs.FileInfo, error) {
+	commitInfo, err := b.commit(c, versionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if commitInfo == nil || strings.HasSuffix(key, "/") {
+		return nil, nil, noSuchKeyError(key)
+	}
+	fileInfo, err := c.InspectFile(b.repo, commitInfo.Commit.ID, key)
+	if err != nil {
+		if errutil.IsNotFoundError(err) {
+			return nil, nil, noSuchKeyError(key)
+		}
+		return nil, nil, err
+	}
+	// directories exist in pfs, but only the files in them are objects
+	if fileInfo.FileType != pfs.FileType_FILE {
+		return nil, nil, noSuchKeyError(key)
+	}
+	return commitInfo, fileInfo, nil
+}
+
+// isNoSuchKey returns true if 'err' is the error returned for keys that don't
+// exist
+func isNoSuchKey(err error) bool {
+	e, ok := err.(*s3Error)
+	return ok && e.code == "NoSuchKey"
+}
+
+// decodeXML decodes the XML document in the body of 'r' into 'v'. The whole
+// body is read first, so that it's checked against its payload hash (see
+// payloadBody) before it's used.
+func decodeXML(r *http.Request, v interface{}) error {
+	content, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(content, v); err != nil {
+		return malformedXMLError(err)
+	}
+	return nil
+}
+
+// verifiedBody returns the content of the body of 'r', which must match its
+// Content-MD5 header, if it's set, and its payload hash or chunk signatures
+// (see payloadBody), if they're signed. Content that's checked is buffered in
+// a temporary file until it's been verified, so that corrupt content is never
+// written to pfs.
+func verifiedBody(r *http.Request) (io.ReadCloser, error) {
+	body := r.Body
+	contentMD5 := r.Header.Get("Content-MD5")
+	if p, ok := body.(*payload); contentMD5 == "" && (!ok || !p.checked) {
+		return body, nil
+	}
+	var expected []byte
+	if contentMD5 != "" {
+		var err error
+		expected, err = base64.StdEncoding.DecodeString(contentMD5)
+		if err != nil || len(expected) != md5.Size {
+			return nil, &s3Error{http.StatusBadRequest, "InvalidDigest", "the Content-MD5 header is not valid"}
+		}
+	}
+	f, err := ioutil.TempFile("", "pfs-s3-upload")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(f.Name()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(f, hash), body); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if expected != nil && !bytes.Equal(hash.Sum(nil), expected) {
+		f.Close()
+		return nil, &s3Error{http.StatusBadRequest, "BadDigest", "the content does not match the Content-MD5 header"}
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// putContent writes the body of 'r' to 'file' of 'repo' at 'commit',
+// replacing it, and returns the ETag of the new content
+func putContent(c *client.APIClient, r *http.Request, repo string, commit string, file string) (string, error) {
+	body, err := verifiedBody(r)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	hash := md5.New()
+	if _, err := c.PutFileOverwrite(repo, commit, file, io.TeeReader(body, hash), 0); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`"%s"`, hex.EncodeToString(hash.Sum(nil))), nil
+}
+
+func getObject(w http.ResponseWriter, r *http.Request, c *client.APIClient, b *bucket, key string) error {
+	commitInfo, fileInfo, err := b.fileVersion(c, key, r.URL.Query().Get("versionId"))
+	if err != nil {
+		return err
+	}
+	// Read the commit that was inspected, in case the branch has moved since
+	content, err := c.GetFileReadSeeker(b.repo, commitInfo.Commit.ID, key)
+	if err != nil {
+		return err
+	}
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", etag(fileInfo))
+	w.Header().Set("x-amz-version-id", commitInfo.Commit.ID)
+	http.ServeContent(w, r, "", commitTime(commitInfo), content)
+	return nil
+}
+
+func putObject(w http.ResponseWriter, r *http.Request, c *client.APIClient, b *bucket, key string) error {
+	if _, err := b.head(c); err != nil {
+		return err
+	}
+	if strings.HasSuffix(key, "/") {
+		// Some clients (e.g. Hadoop's) write empty objects whose keys end in
+		// "/" to mark directories, which exist implicitly in pfs
+		if _, err := io.Copy(ioutil.Discard, r.Body); err != nil {
+			return err
+		}
+		w.Header().Set("ETag", emptyETag)
+		return nil
+	}
+	etag, err := putContent(c, r, b.repo, b.branch, key)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("ETag", etag)
+	return nil
+}
+
+// copySource returns the bucket, key and version ID ("" for the latest
+// version) in the x-amz-copy-source header of 'r'
+func copySource(r *http.Request) (*bucket, string, string, error) {
+	source := r.Header.Get("x-amz-copy-source")
+	var versionID string
+	if i := strings.Index(source, "?"); i >= 0 {
+		query, err := url.ParseQuery(source[i+1:])
+		if err != nil || len(query) != 1 || !has(query, "versionId") {
+			return nil, "", "", invalidArgumentError("x-amz-copy-source can only have a versionId parameter")
+		}
+		source, versionID = source[:i], query.Get("versionId")
+	}
+	source, err := url.PathUnescape(source)
+	if err != nil {
+		return nil, "", "", invalidArgumentError(fmt.Sprintf("x-amz-copy-source is not valid: %v", err))
+	}
+	bucketName, key := splitPath("/" + strings.TrimPrefix(source, "/"))
+	if key == "" {
+		return nil, "", "", invalidArgumentError("x-amz-copy-source must be a bucket and key")
+	}
+	b, err := parseBucket(bucketName)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return b, key, versionID, nil
+}
+
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CopyObjectResult"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+}
+
+func copyObject(w http.ResponseWriter, r *http.Request, c *client.APIClient, b *bucket, key string) error {
+	src, srcKey, srcVersionID, err := copySource(r)
+	if err != nil {
+		return err
+	}
+	srcCommitInfo, _, err := src.fileVersion(c, srcKey, srcVersionID)
+	if err != nil {
+		return err
+	}
+	if _, err := b.head(c); err != nil {
+		return err
+	}
+	if strings.HasSuffix(key, "/") {
+		return invalidArgumentError("objects can't be copied to keys that end in /")
+	}
+	if err := c.CopyFile(src.repo, srcCommitInfo.Commit.ID, srcKey, b.repo, b.branch, key, true); err != nil {
+		return err
+	}
+	commitInfo, fileInfo, err := b.file(c, key)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("x-amz-copy-source-version-id", srcCommitInfo.Commit.ID)
+	return writeXML(w, http.StatusOK, &copyObjectResult{
+		LastModified: formatTime(commitTime(commitInfo)),
+		ETag:         etag(fileInfo),
+	})
+}
+
+// deleteObject deletes the object 'key'. As in S3, deleting a key that
+// doesn't exist succeeds.
+func deleteObject(w http.ResponseWriter, r *http.Request, c *client.APIClient, b *bucket, key string) error {
+	if has(r.URL.Query(), "versionId") {
+		return errDeleteVersion
+	}
+	if _, _, err := b.file(c, key); err != nil {
+		if !isNoSuchKey(err) {
+			return err
+		}
+	} else if err := c.DeleteFile(b.repo, b.branch, key); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// errDeleteVersion is returned for requests to delete versions of objects,
+// which are commits that may hold other objects
+var errDeleteVersion = notImplementedError("versions of objects can't be deleted, as they're commits")
+
+type deleteRequest struct {
+	Quiet   bool `xml:"Quiet"`
+	Objects []struct {
+		Key       string `xml:"Key"`
+		VersionID string `xml:"VersionId"`
+	} `xml:"Object"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name        `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+	Deleted []deletedObject `xml:"Deleted"`
+	Errors  []deleteError   `xml:"Error"`
+}
+
+type deletedObject struct {
+	Key string `xml:"Key"`
+}
+
+type deleteError struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// deleteObjects serves DeleteObjects, which deletes the objects listed in
+// the request in a single commit
+func deleteObjects(w http.ResponseWriter, r *http.Request, c *client.APIClient, b *bucket) error {
+	var request deleteRequest
+	if err := decodeXML(r, &request); err != nil {
+		return err
+	}
+	if len(request.Objects) > maxDeleteObjects {
+		return malformedXMLError(fmt.Errorf("at most %d objects may be deleted at once", maxDeleteObjects))
+	}
+	result := &deleteResult{}
+	deleted := func(key string) {
+		if !request.Quiet {
+			result.Deleted = append(result.Deleted, deletedObject{Key: key})
+		}
+	}
+	failed := func(key string, err error) {
+		e := toS3Error(err)
+		result.Errors = append(result.Errors, deleteError{Key: key, Code: e.code, Message: e.message})
+	}
+	var keys []string
+	for _, object := range request.Objects {
+		if object.VersionID != "" {
+			failed(object.Key, errDeleteVersion)
+			continue
+		}
+		if _, _, err := b.file(c, object.Key); err != nil {
+			if isNoSuchKey(err) {
+				deleted(object.Key)
+			} else {
+				failed(object.Key, err)
+			}
+			continue
+		}
+		keys = append(keys, object.Key)
+	}
+	if len(keys) > 0 {
+		commit, err := c.StartCommit(b.repo, b.branch)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := c.DeleteFile(b.repo, commit.ID, key); err != nil {
+				failed(key, err)
+				continue
+			}
+			deleted(key)
+		}
+		if err := c.FinishCommit(b.repo, commit.ID); err != nil {
+			return err
+		}
+	}
+	return writeXML(w, http.StatusOK, result)
+}
@@ -0,0 +1,405 @@
+// Package s3 serves pfs over the S3 API, so that tools and frameworks with S3
+// support (e.g. Spark, Flink, distcp, boto and the aws CLI) can read data from
+// branches and write results to them without pachctl.
+//
+// Each bucket is a branch of a repo. The bucket "<repo>" is the master branch
+// of the repo, and "<branch>.<repo>" is another branch (neither repo nor
+// branch names can contain "."). Objects are the files in the head commit of
+// the branch, and their keys are the files' paths. Writes go to the branch,
+// and each write (or completed multipart upload) is a new commit. Buckets are
+// versioned, and the versions of objects are the commits that they're in (see
+// versions.go), so earlier commits can be read with a versionId.
+//
+// Only path-style requests (e.g. http://<host>/<bucket>/<key>) are supported,
+// so clients must be configured to use them. Requests are authenticated with
+// AWS Signature Version 4, using credentials from 'pachctl auth
+// get-s3-credentials' (see auth.go), and each bucket is only accessible to the
+// users that have access to its repo.
+package s3
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/server/pkg/errutil"
+)
+
+// timeFormat is the format of the times in the S3 API's XML documents
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+// unsupportedSubresources are the subresources (query parameters that select
+// an operation other than the default one) that the gateway doesn't
+// implement. Requests for them fail, rather than being served as the
+// operation on the object or bucket itself.
+var unsupportedSubresources = []string{
+	"accelerate", "acl", "analytics", "attributes", "cors", "encryption",
+	"intelligent-tiering", "inventory", "legal-hold", "lifecycle", "logging",
+	"metrics", "notification", "object-lock", "ownershipControls", "policy",
+	"policyStatus", "publicAccessBlock", "replication", "requestPayment",
+	"restore", "retention", "select", "tagging", "torrent", "website",
+}
+
+type handler struct {
+	getClient         func(*http.Request) (*client.APIClient, error)
+	getInternalClient func(*http.Request) (*client.APIClient, error)
+	getCredentials    func(*http.Request, string) (string, string, error)
+}
+
+// NewHandler returns an http.Handler that serves pfs over the S3 API.
+// getClient returns an unauthenticated client for each request, which the
+// handler authenticates as the user that signed the request.
+// getInternalClient returns a client with access to every repo (e.g. with
+// PPS's superuser token, if auth is active), which the handler uses to manage
+// the parts of multipart uploads after checking the user's access to the
+// upload's bucket.
+// getCredentials returns the secret key that's registered under an access key
+// ID (see auth.go), or "" if the access key ID isn't registered, and a token
+// that authenticates as the access key's user.
+func NewHandler(getClient func(*http.Request) (*client.APIClient, error), getInternalClient func(*http.Request) (*client.APIClient, error), getCredentials func(*http.Request, string) (string, string, error)) http.Handler {
+	return &handler{getClient: getClient, getInternalClient: getInternalClient, getCredentials: getCredentials}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucketName, key := splitPath(r.URL.Path)
+	query := r.URL.Query()
+	for _, subresource := range unsupportedSubresources {
+		if _, ok := query[subresource]; ok {
+			writeError(w, r, notImplementedError(fmt.Sprintf("the %q subresource is not supported", subresource)))
+			return
+		}
+	}
+	c, err := h.getClient(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	sig, token, err := h.authenticate(r, c, time.Now())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if token != "" {
+		c.SetAuthToken(token)
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if sig != nil {
+		payloadHash = sig.payloadHash
+	}
+	body, err := payloadBody(r.Body, payloadHash, sig)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	r.Body = &payload{Reader: body, checked: signedPayload(payloadHash)}
+	switch {
+	case bucketName == "":
+		if r.Method != "GET" {
+			err = methodNotAllowedError(r.Method)
+			break
+		}
+		err = listBuckets(w, c)
+	case key == "":
+		err = h.serveBucket(w, r, c, bucketName)
+	default:
+		err = h.serveObject(w, r, c, bucketName, key)
+	}
+	if err != nil {
+		writeError(w, r, err)
+	}
+}
+
+func (h *handler) serveBucket(w http.ResponseWriter, r *http.Request, c *client.APIClient, bucketName string) error {
+	b, err := parseBucket(bucketName)
+	if err != nil {
+		return err
+	}
+	query := r.URL.Query()
+	switch r.Method {
+	case "GET":
+		switch {
+		case has(query, "location"):
+			if _, err := b.head(c); err != nil {
+				return err
+			}
+			// pfs has no regions, and clients treat an empty location as the
+			// default region
+			return writeXML(w, http.StatusOK, &locationConstraint{})
+		case has(query, "versioning"):
+			return getBucketVersioning(w, c, b)
+		case has(query, "versions"):
+			return listObjectVersions(w, r, c, b)
+		case has(query, "uploads"):
+			mc, err := h.getInternalClient(r)
+			if err != nil {
+				return err
+			}
+			return listMultipartUploads(w, r, c, mc, b)
+		default:
+			return listObjects(w, r, c, b)
+		}
+	case "HEAD":
+		_, err := b.head(c)
+		return err
+	case "PUT":
+		if has(query, "versioning") {
+			return putBucketVersioning(w, r, c, b)
+		}
+	case "POST":
+		if has(query, "delete") {
+			return deleteObjects(w, r, c, b)
+		}
+	}
+	return methodNotAllowedError(r.Method)
+}
+
+func (h *handler) serveObject(w http.ResponseWriter, r *http.Request, c *client.APIClient, bucketName string, key string) error {
+	b, err := parseBucket(bucketName)
+	if err != nil {
+		return err
+	}
+	query := r.URL.Query()
+	// mc is the client for the multipart upload that the request is for, if
+	// it's for one
+	var mc *client.APIClient
+	if has(query, "uploads") || has(query, "uploadId") {
+		if mc, err = h.getInternalClient(r); err != nil {
+			return err
+		}
+	}
+	switch r.Method {
+	case "GET", "HEAD":
+		if has(query, "uploadId") && r.Method == "GET" {
+			return listParts(w, r, c, mc, b, key)
+		}
+		return getObject(w, r, c, b, key)
+	case "PUT":
+		switch {
+		case has(query, "uploadId"):
+			return uploadPart(w, r, c, mc, b, key)
+		case r.Header.Get("x-amz-copy-source") != "":
+			return copyObject(w, r, c, b, key)
+		default:
+			return putObject(w, r, c, b, key)
+		}
+	case "POST":
+		switch {
+		case has(query, "uploads"):
+			return createMultipartUpload(w, c, mc, b, key)
+		case has(query, "uploadId"):
+			return completeMultipartUpload(w, r, c, mc, b, key)
+		}
+	case "DELETE":
+		if has(query, "uploadId") {
+			return abortMultipartUpload(w, r, c, mc, b, key)
+		}
+		return deleteObject(w, r, c, b, key)
+	}
+	return methodNotAllowedError(r.Method)
+}
+
+// splitPath splits the path of a request into its bucket and key
+func splitPath(p string) (bucket string, key string) {
+	parts := strings.SplitN(strings.TrimPrefix(p, "/"), "/", 2)
+	if len(parts) == 2 {
+		key = parts[1]
+	}
+	return parts[0], key
+}
+
+func has(query map[string][]string, param string) bool {
+	_, ok := query[param]
+	return ok
+}
+
+// bucket is the branch of a repo that a bucket refers to
+type bucket struct {
+	name   string
+	repo   string
+	branch string
+}
+
+// parseBucket returns the branch that the bucket 'name' refers to
+func parseBucket(name string) (*bucket, error) {
+	b := &bucket{name: name, repo: name, branch: "master"}
+	switch parts := strings.Split(name, "."); len(parts) {
+	case 1:
+	case 2:
+		b.branch, b.repo = parts[0], parts[1]
+	default:
+		return nil, noSuchBucketError(name)
+	}
+	if b.repo == "" || b.branch == "" || b.repo == multipartRepo {
+		return nil, noSuchBucketError(name)
+	}
+	return b, nil
+}
+
+// bucketName returns the name of the bucket for 'branch' of 'repo'
+func bucketName(repo string, branch string) string {
+	if branch == "master" {
+		return repo
+	}
+	return branch + "." + repo
+}
+
+// head returns the head commit of the bucket's branch, or nil if the branch
+// has no head. The master branch of a repo always exists as a bucket, even
+// before anything is written to it, but other branches must be created
+// first.
+func (b *bucket) head(c *client.APIClient) (*pfs.CommitInfo, error) {
+	commitInfo, err := c.InspectCommit(b.repo, b.branch)
+	if err == nil {
+		return commitInfo, nil
+	}
+	if branchInfo, err := c.InspectBranch(b.repo, b.branch); err == nil {
+		if branchInfo.Head == nil {
+			return nil, nil
+		}
+	} else if errutil.IsNotFoundError(err) {
+		if b.branch != "master" {
+			return nil, noSuchBucketError(b.name)
+		}
+		if _, err := c.InspectRepo(b.repo); err != nil {
+			if errutil.IsNotFoundError(err) {
+				return nil, noSuchBucketError(b.name)
+			}
+			return nil, err
+		}
+		return nil, nil
+	}
+	return nil, err
+}
+
+// etag returns the ETag of a file, which is the MD5 checksum of its content
+// if pfs has it (as S3 clients expect), and otherwise pfs's hash of the file
+func etag(fileInfo *pfs.FileInfo) string {
+	if len(fileInfo.ContentMd5) > 0 {
+		return fmt.Sprintf(`"%s"`, hex.EncodeToString(fileInfo.ContentMd5))
+	}
+	return fmt.Sprintf(`"%s"`, hex.EncodeToString(fileInfo.Hash))
+}
+
+// commitTime returns the time that 'commitInfo' was finished (or started, if
+// it's open)
+func commitTime(commitInfo *pfs.CommitInfo) time.Time {
+	ts := commitInfo.Finished
+	if ts == nil {
+		ts = commitInfo.Started
+	}
+	return timestamp(ts)
+}
+
+func timestamp(ts *types.Timestamp) time.Time {
+	t, err := types.TimestampFromProto(ts)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+// writeXML writes 'v' as the body of the response, with status 'status'
+func writeXML(w http.ResponseWriter, status int, v interface{}) error {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+	return nil
+}
+
+// s3Error is an error in the form that the S3 API returns errors
+type s3Error struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *s3Error) Error() string {
+	return e.message
+}
+
+func noSuchBucketError(bucket string) error {
+	return &s3Error{http.StatusNotFound, "NoSuchBucket", fmt.Sprintf("the bucket %s does not exist", bucket)}
+}
+
+func noSuchKeyError(key string) error {
+	return &s3Error{http.StatusNotFound, "NoSuchKey", fmt.Sprintf("the key %s does not exist", key)}
+}
+
+func noSuchVersionError(versionID string) error {
+	return &s3Error{http.StatusNotFound, "NoSuchVersion", fmt.Sprintf("the version %s does not exist", versionID)}
+}
+
+func noSuchUploadError(uploadID string) error {
+	return &s3Error{http.StatusNotFound, "NoSuchUpload", fmt.Sprintf("the multipart upload %s does not exist", uploadID)}
+}
+
+func invalidArgumentError(message string) error {
+	return &s3Error{http.StatusBadRequest, "InvalidArgument", message}
+}
+
+func malformedXMLError(err error) error {
+	return &s3Error{http.StatusBadRequest, "MalformedXML", fmt.Sprintf("the XML in the request is malformed: %v", err)}
+}
+
+func notImplementedError(message string) error {
+	return &s3Error{http.StatusNotImplemented, "NotImplemented", message}
+}
+
+func methodNotAllowedError(method string) error {
+	return &s3Error{http.StatusMethodNotAllowed, "MethodNotAllowed", fmt.Sprintf("the method %s is not allowed against this resource", method)}
+}
+
+type errorResponse struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+// toS3Error returns 'err' as an S3 error
+func toS3Error(err error) *s3Error {
+	if e, ok := err.(*s3Error); ok {
+		return e
+	}
+	switch {
+	case auth.IsErrNotSignedIn(err), auth.IsErrNotAuthorized(err):
+		return &s3Error{http.StatusForbidden, "AccessDenied", err.Error()}
+	case auth.IsErrBadToken(err):
+		return &s3Error{http.StatusForbidden, "InvalidAccessKeyId", "the access key ID is not a valid Pachyderm token"}
+	case errutil.IsNotFoundError(err):
+		return &s3Error{http.StatusNotFound, "NoSuchKey", err.Error()}
+	default:
+		return &s3Error{http.StatusInternalServerError, "InternalError", err.Error()}
+	}
+}
+
+// writeError writes 'err' as an S3 error response
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	e := toS3Error(err)
+	if r.Method == "HEAD" {
+		// responses to HEAD requests can't have a body
+		w.WriteHeader(e.status)
+		return
+	}
+	writeXML(w, e.status, &errorResponse{
+		Code:     e.code,
+		Message:  e.message,
+		Resource: r.URL.Path,
+	})
+}
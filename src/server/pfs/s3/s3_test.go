@@ -0,0 +1,644 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	minio "github.com/minio/minio-go"
+	"github.com/minio/minio-go/pkg/s3signer"
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	pachtesting "github.com/pachyderm/pachyderm/src/client/testing"
+)
+
+// newTestServer serves a fake pachd over S3, and returns a client of pachd,
+// an S3 client of the server, and the server's URL
+func newTestServer(t *testing.T) (*client.APIClient, *minio.Core, string, func()) {
+	pachd := pachtesting.NewServer()
+	c, err := pachd.NewClient()
+	require.NoError(t, err)
+	server := serveS3(c, nil)
+	// Auth isn't active, so any credentials are accepted
+	s3Client := newS3Client(t, server.URL, "token", "token")
+	return c, s3Client, server.URL, func() {
+		server.Close()
+		c.Close()
+		pachd.Close()
+	}
+}
+
+// s3Credential is the secret key that's registered under an access key ID,
+// and the token of the access key's user
+type s3Credential struct {
+	secretKey, token string
+}
+
+// serveS3 serves pachd over S3, using 'internalClient' to manage multipart
+// uploads. 'credentials' are the registered credentials, by their access key
+// IDs.
+func serveS3(internalClient *client.APIClient, credentials map[string]s3Credential) *httptest.Server {
+	getClient := func(r *http.Request) (*client.APIClient, error) {
+		c := internalClient.WithCtx(r.Context())
+		// The handler sets the token of the user that signed the request
+		c.SetAuthToken("")
+		return c, nil
+	}
+	getInternalClient := func(r *http.Request) (*client.APIClient, error) {
+		return internalClient.WithCtx(r.Context()), nil
+	}
+	getCredentials := func(r *http.Request, accessKeyID string) (string, string, error) {
+		credential := credentials[accessKeyID]
+		return credential.secretKey, credential.token, nil
+	}
+	return httptest.NewServer(NewHandler(getClient, getInternalClient, getCredentials))
+}
+
+func newS3Client(t *testing.T, serverURL string, accessKeyID string, secretKey string) *minio.Core {
+	u, err := url.Parse(serverURL)
+	require.NoError(t, err)
+	s3Client, err := minio.New(u.Host, accessKeyID, secretKey, false)
+	require.NoError(t, err)
+	return &minio.Core{Client: s3Client}
+}
+
+// accessKeyID returns the access key ID of 'token' (see auth.go)
+func accessKeyID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func readObject(t *testing.T, s3Client *minio.Core, bucket string, key string) string {
+	object, err := s3Client.Client.GetObject(bucket, key)
+	require.NoError(t, err)
+	defer object.Close()
+	content, err := ioutil.ReadAll(object)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func listKeys(t *testing.T, s3Client *minio.Core, bucket string, prefix string, recursive bool) []string {
+	var result []string
+	for objectInfo := range s3Client.Client.ListObjectsV2(bucket, prefix, recursive, nil) {
+		require.NoError(t, objectInfo.Err)
+		result = append(result, objectInfo.Key)
+	}
+	// minio-go returns each page's objects before its common prefixes
+	sort.Strings(result)
+	return result
+}
+
+// errorCode returns the code of the S3 error in the body of 'resp'
+func errorCode(t *testing.T, resp *http.Response) string {
+	defer resp.Body.Close()
+	var e errorResponse
+	require.NoError(t, xml.NewDecoder(resp.Body).Decode(&e))
+	return e.Code
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestPutGetList(t *testing.T) {
+	c, s3Client, _, cleanup := newTestServer(t)
+	defer cleanup()
+	require.NoError(t, c.CreateRepo("images"))
+
+	buckets, err := s3Client.Client.ListBuckets()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(buckets))
+	require.Equal(t, "images", buckets[0].Name)
+	// The master branch is a bucket before anything is written to it
+	exists, err := s3Client.Client.BucketExists("images")
+	require.NoError(t, err)
+	require.True(t, exists)
+	exists, err = s3Client.Client.BucketExists("labels")
+	require.NoError(t, err)
+	require.False(t, exists)
+	require.Equal(t, 0, len(listKeys(t, s3Client, "images", "", true)))
+
+	for _, key := range []string{"a.png", "dir/b.png", "dir/sub/c.png", "dirty.png"} {
+		_, err := s3Client.Client.PutObject("images", key, strings.NewReader(key), "image/png")
+		require.NoError(t, err)
+	}
+	var buf bytes.Buffer
+	require.NoError(t, c.GetFile("images", "master", "dir/b.png", 0, 0, &buf))
+	require.Equal(t, "dir/b.png", buf.String())
+	require.Equal(t, "dir/sub/c.png", readObject(t, s3Client, "images", "dir/sub/c.png"))
+	objectInfo, err := s3Client.Client.StatObject("images", "a.png")
+	require.NoError(t, err)
+	require.Equal(t, md5Hex("a.png"), objectInfo.ETag)
+	require.Equal(t, int64(5), objectInfo.Size)
+	require.Equal(t, "image/png", objectInfo.ContentType)
+	// Objects are overwritten, not appended to
+	_, err = s3Client.Client.PutObject("images", "a.png", strings.NewReader("new"), "image/png")
+	require.NoError(t, err)
+	require.Equal(t, "new", readObject(t, s3Client, "images", "a.png"))
+	_, err = s3Client.Client.StatObject("images", "dir")
+	require.YesError(t, err)
+	_, err = s3Client.Client.StatObject("images", "missing.png")
+	require.YesError(t, err)
+	require.Equal(t, "NoSuchKey", minio.ToErrorResponse(err).Code)
+
+	require.Equal(t, []string{"a.png", "dir/b.png", "dir/sub/c.png", "dirty.png"}, listKeys(t, s3Client, "images", "", true))
+	require.Equal(t, []string{"a.png", "dir/", "dirty.png"}, listKeys(t, s3Client, "images", "", false))
+	require.Equal(t, []string{"dir/", "dirty.png"}, listKeys(t, s3Client, "images", "dir", false))
+	require.Equal(t, []string{"dir/b.png", "dir/sub/"}, listKeys(t, s3Client, "images", "dir/", false))
+	require.Equal(t, []string{"dir/b.png", "dir/sub/c.png", "dirty.png"}, listKeys(t, s3Client, "images", "dir", true))
+	require.Equal(t, 0, len(listKeys(t, s3Client, "images", "none/", true)))
+
+	// Listings are paginated
+	result, err := s3Client.ListObjects("images", "", "", "", 2)
+	require.NoError(t, err)
+	require.True(t, result.IsTruncated)
+	require.Equal(t, 2, len(result.Contents))
+	require.Equal(t, "dir/b.png", result.NextMarker)
+	result, err = s3Client.ListObjects("images", "", result.NextMarker, "", 2)
+	require.NoError(t, err)
+	require.False(t, result.IsTruncated)
+	require.Equal(t, "dir/sub/c.png", result.Contents[0].Key)
+	require.Equal(t, "dirty.png", result.Contents[1].Key)
+
+	// Other branches are buckets named <branch>.<repo>
+	require.NoError(t, c.CreateBranch("images", "v1", "", nil))
+	_, err = s3Client.Client.PutObject("images", "a.png", strings.NewReader("v1"), "image/png")
+	require.NoError(t, err)
+	_, err = s3Client.Client.PutObject("v1.images", "a.png", strings.NewReader("v1"), "image/png")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.png"}, listKeys(t, s3Client, "v1.images", "", true))
+	for _, bucket := range []string{"v2.images", "v1.images.jpg"} {
+		exists, err = s3Client.Client.BucketExists(bucket)
+		require.NoError(t, err)
+		require.False(t, exists)
+	}
+}
+
+func TestCopyDelete(t *testing.T) {
+	c, s3Client, _, cleanup := newTestServer(t)
+	defer cleanup()
+	require.NoError(t, c.CreateRepo("input"))
+	require.NoError(t, c.CreateRepo("output"))
+	for _, key := range []string{"a", "b", "c", "dir/d"} {
+		_, err := s3Client.Client.PutObject("input", key, strings.NewReader(key), "")
+		require.NoError(t, err)
+	}
+
+	_, err := s3Client.Client.PutObject("output", "a", strings.NewReader("old"), "")
+	require.NoError(t, err)
+	require.NoError(t, s3Client.Client.CopyObject("output", "a", "/input/a", minio.NewCopyConditions()))
+	require.NoError(t, s3Client.Client.CopyObject("output", "dir/b", "/input/b", minio.NewCopyConditions()))
+	require.Equal(t, "a", readObject(t, s3Client, "output", "a"))
+	require.Equal(t, "b", readObject(t, s3Client, "output", "dir/b"))
+	err = s3Client.Client.CopyObject("output", "x", "/input/missing", minio.NewCopyConditions())
+	require.YesError(t, err)
+	require.Equal(t, "NoSuchKey", minio.ToErrorResponse(err).Code)
+
+	require.NoError(t, s3Client.Client.RemoveObject("output", "a"))
+	// Deleting keys that don't exist succeeds, but doesn't delete directories
+	require.NoError(t, s3Client.Client.RemoveObject("output", "a"))
+	require.NoError(t, s3Client.Client.RemoveObject("output", "dir"))
+	require.Equal(t, []string{"dir/b"}, listKeys(t, s3Client, "output", "", true))
+
+	commitInfos, err := c.ListCommit("input", "master", "", 0)
+	require.NoError(t, err)
+	keys := make(chan string, 4)
+	for _, key := range []string{"a", "c", "dir/d", "missing"} {
+		keys <- key
+	}
+	close(keys)
+	for err := range s3Client.Client.RemoveObjects("input", keys) {
+		require.NoError(t, err.Err)
+	}
+	require.Equal(t, []string{"b"}, listKeys(t, s3Client, "input", "", true))
+	// The objects are deleted in one commit
+	newCommitInfos, err := c.ListCommit("input", "master", "", 0)
+	require.NoError(t, err)
+	require.Equal(t, len(commitInfos)+1, len(newCommitInfos))
+}
+
+func TestMultipart(t *testing.T) {
+	c, s3Client, _, cleanup := newTestServer(t)
+	defer cleanup()
+	require.NoError(t, c.CreateRepo("output"))
+	_, err := s3Client.Client.PutObject("output", "result", strings.NewReader("old"), "")
+	require.NoError(t, err)
+
+	uploadID, err := s3Client.NewMultipartUpload("output", "result", nil)
+	require.NoError(t, err)
+	content := []string{"foo", "bar", "baz"}
+	var parts []minio.CompletePart
+	// Parts may be uploaded in any order
+	for _, i := range []int{2, 0, 1} {
+		part, err := s3Client.PutObjectPart("output", "result", uploadID, i+1, int64(len(content[i])), strings.NewReader(content[i]), nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, md5Hex(content[i]), strings.Trim(part.ETag, `"`))
+		parts = append(parts, minio.CompletePart{PartNumber: i + 1, ETag: part.ETag})
+	}
+	parts[0], parts[1], parts[2] = parts[1], parts[2], parts[0]
+	listPartsResult, err := s3Client.ListObjectParts("output", "result", uploadID, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(listPartsResult.ObjectParts))
+	require.Equal(t, 1, listPartsResult.ObjectParts[0].PartNumber)
+	uploadsResult, err := s3Client.ListMultipartUploads("output", "", "", "", "", 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(uploadsResult.Uploads))
+	require.Equal(t, uploadID, uploadsResult.Uploads[0].UploadID)
+	// The upload's repo isn't a bucket
+	buckets, err := s3Client.Client.ListBuckets()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(buckets))
+
+	// The object isn't changed until the upload is completed
+	require.Equal(t, "old", readObject(t, s3Client, "output", "result"))
+	err = s3Client.CompleteMultipartUpload("output", "result", uploadID, []minio.CompletePart{parts[1], parts[0]})
+	require.YesError(t, err)
+	require.Equal(t, "InvalidPartOrder", minio.ToErrorResponse(err).Code)
+	err = s3Client.CompleteMultipartUpload("output", "result", uploadID, []minio.CompletePart{{PartNumber: 1, ETag: "wrong"}})
+	require.YesError(t, err)
+	require.Equal(t, "InvalidPart", minio.ToErrorResponse(err).Code)
+	commitInfos, err := c.ListCommit("output", "master", "", 0)
+	require.NoError(t, err)
+	require.NoError(t, s3Client.CompleteMultipartUpload("output", "result", uploadID, parts))
+	require.Equal(t, "foobarbaz", readObject(t, s3Client, "output", "result"))
+	newCommitInfos, err := c.ListCommit("output", "master", "", 0)
+	require.NoError(t, err)
+	require.Equal(t, len(commitInfos)+1, len(newCommitInfos))
+	err = s3Client.CompleteMultipartUpload("output", "result", uploadID, parts)
+	require.YesError(t, err)
+	require.Equal(t, "NoSuchUpload", minio.ToErrorResponse(err).Code)
+
+	// Parts can be copied from other objects, and uploads aborted
+	uploadID, err = s3Client.NewMultipartUpload("output", "copy", nil)
+	require.NoError(t, err)
+	_, err = s3Client.PutObjectPart("output", "other", uploadID, 1, 3, strings.NewReader("foo"), nil, nil)
+	require.YesError(t, err)
+	require.Equal(t, "NoSuchUpload", minio.ToErrorResponse(err).Code)
+	require.NoError(t, s3Client.AbortMultipartUpload("output", "copy", uploadID))
+	_, err = s3Client.ListObjectParts("output", "copy", uploadID, 0, 0)
+	require.YesError(t, err)
+	uploadsResult, err = s3Client.ListMultipartUploads("output", "", "", "", "", 0)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(uploadsResult.Uploads))
+}
+
+func TestUploadPartCopy(t *testing.T) {
+	c, s3Client, serverURL, cleanup := newTestServer(t)
+	defer cleanup()
+	require.NoError(t, c.CreateRepo("data"))
+	_, err := s3Client.Client.PutObject("data", "src", strings.NewReader("0123456789"), "")
+	require.NoError(t, err)
+	uploadID, err := s3Client.NewMultipartUpload("data", "dst", nil)
+	require.NoError(t, err)
+
+	var parts []minio.CompletePart
+	for i, byteRange := range []string{"bytes=5-9", "bytes=0-4"} {
+		req, err := http.NewRequest("PUT", serverURL+"/data/dst?partNumber="+strconv.Itoa(i+1)+"&uploadId="+uploadID, nil)
+		require.NoError(t, err)
+		req.Header.Set("x-amz-copy-source", "/data/src")
+		req.Header.Set("x-amz-copy-source-range", byteRange)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+		require.True(t, strings.Contains(string(body), "<CopyPartResult"))
+		partsResult, err := s3Client.ListObjectParts("data", "dst", uploadID, i, 1)
+		require.NoError(t, err)
+		parts = append(parts, minio.CompletePart{PartNumber: i + 1, ETag: partsResult.ObjectParts[0].ETag})
+	}
+	require.NoError(t, s3Client.CompleteMultipartUpload("data", "dst", uploadID, parts))
+	require.Equal(t, "5678901234", readObject(t, s3Client, "data", "dst"))
+}
+
+func TestChunkedReader(t *testing.T) {
+	body := "5;chunk-signature=abc\r\nhello\r\n6;chunk-signature=def\r\n world\r\n0;chunk-signature=ghi\r\n\r\n"
+	content, err := ioutil.ReadAll(newChunkedReader(strings.NewReader(body)))
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(content))
+	// Trailing headers are ignored
+	content, err = ioutil.ReadAll(newChunkedReader(strings.NewReader("3\r\nabc\r\n0\r\nx-amz-checksum-crc32:abc=\r\n\r\n")))
+	require.NoError(t, err)
+	require.Equal(t, "abc", string(content))
+
+	for _, body := range []string{
+		"5;chunk-signature=abc\r\nhel",
+		"5;chunk-signature=abc\r\nhello",
+		"5;chunk-signature=abc\r\nhelloXX",
+		"x;chunk-signature=abc\r\nhello\r\n",
+	} {
+		_, err := ioutil.ReadAll(newChunkedReader(strings.NewReader(body)))
+		require.YesError(t, err)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	pachd := pachtesting.NewServer()
+	defer pachd.Close()
+	c, err := pachd.NewClient()
+	require.NoError(t, err)
+	defer c.Close()
+	resp, err := c.Activate(c.Ctx(), &auth.ActivateRequest{Subject: "admin"})
+	require.NoError(t, err)
+	c.SetAuthToken(resp.PachToken)
+	tokenResp, err := c.GetAuthToken(c.Ctx(), &auth.GetAuthTokenRequest{Subject: "alice"})
+	require.NoError(t, err)
+	aliceToken := tokenResp.Token
+	aliceSecret := "alice-secret"
+	server := serveS3(c, map[string]s3Credential{
+		accessKeyID(resp.PachToken): {"admin-secret", resp.PachToken},
+		accessKeyID(aliceToken):     {aliceSecret, aliceToken},
+	})
+	defer server.Close()
+	serverURL := server.URL
+	adminClient := newS3Client(t, serverURL, accessKeyID(resp.PachToken), "admin-secret")
+	s3Client := newS3Client(t, serverURL, accessKeyID(aliceToken), aliceSecret)
+
+	// alice can read "images", but has no access to "secret"
+	for _, repo := range []string{"images", "secret"} {
+		require.NoError(t, c.CreateRepo(repo))
+		_, err := c.PutFile(repo, "master", "dir/a b+c.txt", strings.NewReader("foo"))
+		require.NoError(t, err)
+	}
+	_, err = c.SetScope(c.Ctx(), &auth.SetScopeRequest{Username: "alice", Repo: "images", Scope: auth.Scope_READER})
+	require.NoError(t, err)
+	objectURL := serverURL + "/images/dir/a%20b%2Bc.txt"
+
+	// Unsigned requests are served anonymously, which fails once auth is
+	// active
+	httpResp, err := http.Get(objectURL)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+	require.Equal(t, "AccessDenied", errorCode(t, httpResp))
+
+	require.Equal(t, "foo", readObject(t, s3Client, "images", "dir/a b+c.txt"))
+	_, err = s3Client.Client.StatObject("secret", "dir/a b+c.txt")
+	require.Equal(t, "AccessDenied", minio.ToErrorResponse(err).Code)
+	_, err = s3Client.Client.PutObject("images", "new.txt", strings.NewReader("bar"), "text/plain")
+	require.Equal(t, "AccessDenied", minio.ToErrorResponse(err).Code)
+	require.Equal(t, "foo", readObject(t, adminClient, "secret", "dir/a b+c.txt"))
+
+	// The secret key must be the one that's registered under the access key
+	// ID (the token doesn't work), and access key IDs must be registered
+	badClient := newS3Client(t, serverURL, accessKeyID(aliceToken), "secret")
+	_, err = badClient.Client.StatObject("images", "dir/a b+c.txt")
+	require.Equal(t, "SignatureDoesNotMatch", minio.ToErrorResponse(err).Code)
+	badClient = newS3Client(t, serverURL, accessKeyID(aliceToken), aliceToken)
+	_, err = badClient.Client.StatObject("images", "dir/a b+c.txt")
+	require.Equal(t, "SignatureDoesNotMatch", minio.ToErrorResponse(err).Code)
+	badClient = newS3Client(t, serverURL, aliceToken, aliceSecret)
+	_, err = badClient.Client.StatObject("images", "dir/a b+c.txt")
+	require.Equal(t, "InvalidAccessKeyId", minio.ToErrorResponse(err).Code)
+	u, err := url.Parse(serverURL)
+	require.NoError(t, err)
+	v2Client, err := minio.NewV2(u.Host, accessKeyID(aliceToken), aliceSecret, false)
+	require.NoError(t, err)
+	_, err = v2Client.StatObject("images", "dir/a b+c.txt")
+	require.Equal(t, "InvalidRequest", minio.ToErrorResponse(err).Code)
+
+	// Requests signed by the AWS SDK (which encodes them differently)
+	signer := v4.NewSigner(credentials.NewStaticCredentials(accessKeyID(aliceToken), aliceSecret, ""), func(s *v4.Signer) {
+		s.DisableURIPathEscaping = true
+	})
+	req, err := http.NewRequest("GET", serverURL+"/images?list-type=2&prefix=dir%2F&delimiter=%2F&encoding-type=url", nil)
+	require.NoError(t, err)
+	_, err = signer.Sign(req, nil, "s3", "us-east-1", time.Now())
+	require.NoError(t, err)
+	httpResp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	content, err := ioutil.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, httpResp.StatusCode)
+	require.True(t, strings.Contains(string(content), "<Key>dir/a+b%2Bc.txt</Key>"))
+
+	req, err = http.NewRequest("GET", objectURL, nil)
+	require.NoError(t, err)
+	_, err = signer.Sign(req, nil, "s3", "us-east-1", time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	httpResp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+	require.Equal(t, "RequestTimeTooSkewed", errorCode(t, httpResp))
+
+	// Presigned URLs, which don't contain the secret key or the token
+	presigned, err := s3Client.Client.PresignedGetObject("images", "dir/a b+c.txt", time.Hour, nil)
+	require.NoError(t, err)
+	require.False(t, strings.Contains(presigned.String(), aliceSecret))
+	require.False(t, strings.Contains(presigned.String(), aliceToken))
+	httpResp, err = http.Get(presigned.String())
+	require.NoError(t, err)
+	content, err = ioutil.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, httpResp.StatusCode)
+	require.Equal(t, "foo", string(content))
+	// The signature is only valid for the object that was signed
+	httpResp, err = http.Get(strings.Replace(presigned.String(), "a%20b", "a%20c", 1))
+	require.NoError(t, err)
+	require.Equal(t, "SignatureDoesNotMatch", errorCode(t, httpResp))
+
+	req, err = http.NewRequest("GET", objectURL, nil)
+	require.NoError(t, err)
+	_, err = signer.Presign(req, nil, "s3", "us-east-1", time.Minute, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	httpResp, err = http.Get(req.URL.String())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+	require.Equal(t, "AccessDenied", errorCode(t, httpResp))
+
+	// Once alice can write to "images", her payloads are checked against
+	// their signed hashes, or the signatures of their chunks
+	_, err = c.SetScope(c.Ctx(), &auth.SetScopeRequest{Username: "alice", Repo: "images", Scope: auth.Scope_WRITER})
+	require.NoError(t, err)
+	_, err = s3Client.Client.PutObject("images", "new.txt", strings.NewReader("bar"), "text/plain")
+	require.NoError(t, err)
+	require.Equal(t, "bar", readObject(t, s3Client, "images", "new.txt"))
+	streamed := strings.Repeat("0123456789", 10000) // more than one chunk
+	req, err = http.NewRequest("PUT", serverURL+"/images/streamed.txt", strings.NewReader(streamed))
+	require.NoError(t, err)
+	req = s3signer.StreamingSignV4(req, accessKeyID(aliceToken), aliceSecret, "", "us-east-1", int64(len(streamed)), time.Now().UTC())
+	httpResp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	httpResp.Body.Close()
+	require.Equal(t, http.StatusOK, httpResp.StatusCode)
+	require.Equal(t, streamed, readObject(t, s3Client, "images", "streamed.txt"))
+
+	req, err = http.NewRequest("PUT", serverURL+"/images/new.txt", nil)
+	require.NoError(t, err)
+	_, err = signer.Sign(req, strings.NewReader("baz"), "s3", "us-east-1", time.Now())
+	require.NoError(t, err)
+	req.Body = ioutil.NopCloser(strings.NewReader("bad"))
+	httpResp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, httpResp.StatusCode)
+	require.Equal(t, "XAmzContentSHA256Mismatch", errorCode(t, httpResp))
+
+	body := "3;chunk-signature=" + strings.Repeat("0", 64) + "\r\nbad\r\n0;chunk-signature=" + strings.Repeat("0", 64) + "\r\n\r\n"
+	req, err = http.NewRequest("PUT", serverURL+"/images/new.txt", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Amz-Content-Sha256", "STREAMING-AWS4-HMAC-SHA256-PAYLOAD")
+	req.Header.Set("X-Amz-Decoded-Content-Length", "3")
+	_, err = signer.Sign(req, strings.NewReader(body), "s3", "us-east-1", time.Now())
+	require.NoError(t, err)
+	httpResp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+	require.Equal(t, "SignatureDoesNotMatch", errorCode(t, httpResp))
+	// Neither payload was written
+	require.Equal(t, "bar", readObject(t, s3Client, "images", "new.txt"))
+
+	// The repo that holds multipart uploads is never a bucket, even for admins
+	_, err = s3Client.NewMultipartUpload("images", "upload", nil)
+	require.NoError(t, err)
+	for _, client := range []*minio.Core{s3Client, adminClient} {
+		buckets, err := client.Client.ListBuckets()
+		require.NoError(t, err)
+		var names []string
+		for _, bucket := range buckets {
+			names = append(names, bucket.Name)
+		}
+		require.False(t, strings.Contains(strings.Join(names, ","), multipartRepo))
+		_, err = client.Client.StatObject(multipartRepo, "x")
+		require.YesError(t, err)
+	}
+	buckets, err := s3Client.Client.ListBuckets()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(buckets))
+	require.Equal(t, "images", buckets[0].Name)
+}
+
+func TestVersions(t *testing.T) {
+	c, _, serverURL, cleanup := newTestServer(t)
+	defer cleanup()
+	// The AWS SDK supports the versioning API, unlike minio-go
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:         aws.String(serverURL),
+		Region:           aws.String("us-east-1"),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("token", "token", ""),
+	})
+	require.NoError(t, err)
+	s3Client := awss3.New(sess)
+	readVersion := func(bucket string, key string, versionID string) string {
+		input := &awss3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}
+		if versionID != "" {
+			input.VersionId = aws.String(versionID)
+		}
+		output, err := s3Client.GetObject(input)
+		require.NoError(t, err)
+		defer output.Body.Close()
+		content, err := ioutil.ReadAll(output.Body)
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	require.NoError(t, c.CreateRepo("images"))
+	require.NoError(t, c.CreateRepo("labels"))
+	var commitIDs []string
+	for _, content := range []string{"foo", "bar"} {
+		_, err := c.PutFileOverwrite("images", "master", "dir/a", strings.NewReader(content), 0)
+		require.NoError(t, err)
+		commitInfo, err := c.InspectCommit("images", "master")
+		require.NoError(t, err)
+		commitIDs = append(commitIDs, commitInfo.Commit.ID)
+	}
+	_, err = c.PutFile("images", "master", "b", strings.NewReader("baz"))
+	require.NoError(t, err)
+	headInfo, err := c.InspectCommit("images", "master")
+	require.NoError(t, err)
+	require.NoError(t, c.CreateBranch("images", "v1", commitIDs[0], nil))
+
+	// Each branch is a bucket, and the master branch is a bucket before it
+	// exists
+	buckets, err := s3Client.ListBuckets(&awss3.ListBucketsInput{})
+	require.NoError(t, err)
+	var names []string
+	for _, bucket := range buckets.Buckets {
+		names = append(names, *bucket.Name)
+	}
+	require.Equal(t, []string{"images", "labels", "v1.images"}, names)
+	require.Equal(t, "foo", readVersion("v1.images", "dir/a", ""))
+
+	versioning, err := s3Client.GetBucketVersioning(&awss3.GetBucketVersioningInput{Bucket: aws.String("images")})
+	require.NoError(t, err)
+	require.Equal(t, "Enabled", *versioning.Status)
+	_, err = s3Client.PutBucketVersioning(&awss3.PutBucketVersioningInput{
+		Bucket:                  aws.String("images"),
+		VersioningConfiguration: &awss3.VersioningConfiguration{Status: aws.String("Suspended")},
+	})
+	require.YesError(t, err)
+
+	// The latest version of each object is the head commit, and earlier ones
+	// are the commits that changed the object
+	versions, err := s3Client.ListObjectVersions(&awss3.ListObjectVersionsInput{Bucket: aws.String("images")})
+	require.NoError(t, err)
+	var listed []string
+	for _, version := range versions.Versions {
+		listed = append(listed, fmt.Sprintf("%s@%s %t", *version.Key, *version.VersionId, *version.IsLatest))
+	}
+	require.Equal(t, []string{
+		"b@" + headInfo.Commit.ID + " true",
+		"dir/a@" + headInfo.Commit.ID + " true",
+		"dir/a@" + commitIDs[0] + " false",
+	}, listed)
+	versions, err = s3Client.ListObjectVersions(&awss3.ListObjectVersionsInput{
+		Bucket:    aws.String("images"),
+		Delimiter: aws.String("/"),
+		Prefix:    aws.String("dir/"),
+		MaxKeys:   aws.Int64(1),
+	})
+	require.NoError(t, err)
+	require.True(t, *versions.IsTruncated)
+	require.Equal(t, 1, len(versions.Versions))
+	require.Equal(t, headInfo.Commit.ID, *versions.Versions[0].VersionId)
+	versions, err = s3Client.ListObjectVersions(&awss3.ListObjectVersionsInput{
+		Bucket:          aws.String("images"),
+		Delimiter:       aws.String("/"),
+		Prefix:          aws.String("dir/"),
+		KeyMarker:       versions.NextKeyMarker,
+		VersionIdMarker: versions.NextVersionIdMarker,
+	})
+	require.NoError(t, err)
+	require.False(t, *versions.IsTruncated)
+	require.Equal(t, 1, len(versions.Versions))
+	require.Equal(t, commitIDs[0], *versions.Versions[0].VersionId)
+
+	// Versions can be read and copied
+	require.Equal(t, "foo", readVersion("images", "dir/a", commitIDs[0]))
+	require.Equal(t, "bar", readVersion("images", "dir/a", commitIDs[1]))
+	require.Equal(t, "bar", readVersion("images", "dir/a", ""))
+	_, err = s3Client.GetObject(&awss3.GetObjectInput{Bucket: aws.String("images"), Key: aws.String("b"), VersionId: aws.String(commitIDs[0])})
+	require.YesError(t, err)
+	_, err = s3Client.GetObject(&awss3.GetObjectInput{Bucket: aws.String("images"), Key: aws.String("b"), VersionId: aws.String("master")})
+	require.YesError(t, err)
+	_, err = s3Client.CopyObject(&awss3.CopyObjectInput{
+		Bucket:     aws.String("labels"),
+		Key:        aws.String("a"),
+		CopySource: aws.String("images/dir/a?versionId=" + commitIDs[0]),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "foo", readVersion("labels", "a", ""))
+	_, err = s3Client.DeleteObject(&awss3.DeleteObjectInput{Bucket: aws.String("images"), Key: aws.String("b"), VersionId: aws.String(commitIDs[0])})
+	require.YesError(t, err)
+}
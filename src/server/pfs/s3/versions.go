@@ -0,0 +1,198 @@
+package s3
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/server/pkg/errutil"
+	"github.com/pachyderm/pachyderm/src/server/pkg/uuid"
+)
+
+// Every bucket is versioned, as pfs keeps every commit: the ID of a version of
+// an object is the ID of a commit in which the object has that content, so
+// GETs (and copies) with a versionId read the object at that commit. The
+// latest version of each object is identified by the head commit of the
+// bucket's branch, as it's read without a versionId. Versions can't be
+// deleted through the gateway, and objects that have been deleted from the
+// branch aren't listed, but their versions can still be read.
+
+type versioningConfiguration struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ VersioningConfiguration"`
+	Status  string   `xml:"Status,omitempty"`
+}
+
+// commit returns the commit that 'versionID' refers to in the bucket's repo,
+// or the head of the bucket's branch (which may be nil) if 'versionID' is ""
+func (b *bucket) commit(c *client.APIClient, versionID string) (*pfs.CommitInfo, error) {
+	commitInfo, err := b.head(c)
+	if err != nil || versionID == "" {
+		return commitInfo, err
+	}
+	// Only commit IDs are versions, and not e.g. branch names
+	if !uuid.IsUUIDWithoutDashes(versionID) {
+		return nil, invalidArgumentError("the version ID is not valid")
+	}
+	if commitInfo, err = c.InspectCommit(b.repo, versionID); err != nil {
+		if errutil.IsNotFoundError(err) {
+			return nil, noSuchVersionError(versionID)
+		}
+		return nil, err
+	}
+	return commitInfo, nil
+}
+
+func getBucketVersioning(w http.ResponseWriter, c *client.APIClient, b *bucket) error {
+	if _, err := b.head(c); err != nil {
+		return err
+	}
+	return writeXML(w, http.StatusOK, &versioningConfiguration{Status: "Enabled"})
+}
+
+// putBucketVersioning accepts requests to enable versioning, which is always
+// enabled
+func putBucketVersioning(w http.ResponseWriter, r *http.Request, c *client.APIClient, b *bucket) error {
+	if _, err := b.head(c); err != nil {
+		return err
+	}
+	var request versioningConfiguration
+	if err := decodeXML(r, &request); err != nil {
+		return err
+	}
+	if request.Status != "Enabled" {
+		return notImplementedError("versioning can't be suspended, as pfs keeps every commit")
+	}
+	return nil
+}
+
+type listVersionsResult struct {
+	XMLName             xml.Name        `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListVersionsResult"`
+	Name                string          `xml:"Name"`
+	Prefix              string          `xml:"Prefix"`
+	KeyMarker           string          `xml:"KeyMarker"`
+	VersionIDMarker     string          `xml:"VersionIdMarker"`
+	NextKeyMarker       string          `xml:"NextKeyMarker,omitempty"`
+	NextVersionIDMarker string          `xml:"NextVersionIdMarker,omitempty"`
+	MaxKeys             int             `xml:"MaxKeys"`
+	Delimiter           string          `xml:"Delimiter,omitempty"`
+	EncodingType        string          `xml:"EncodingType,omitempty"`
+	IsTruncated         bool            `xml:"IsTruncated"`
+	Versions            []objectVersion `xml:"Version"`
+	CommonPrefixes      []commonPrefix  `xml:"CommonPrefixes"`
+}
+
+type objectVersion struct {
+	Key          string `xml:"Key"`
+	VersionID    string `xml:"VersionId"`
+	IsLatest     bool   `xml:"IsLatest"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         uint64 `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+// listObjectVersions serves ListObjectVersions, which lists the versions of
+// the objects in the head commit of the bucket's branch. Each object's
+// versions are listed newest first, and the listing continues after the
+// version in version-id-marker of the key in key-marker (or after all of the
+// versions of key-marker, if version-id-marker isn't set).
+func listObjectVersions(w http.ResponseWriter, r *http.Request, c *client.APIClient, b *bucket) error {
+	query := r.URL.Query()
+	result := &listVersionsResult{
+		Name:            b.name,
+		Prefix:          query.Get("prefix"),
+		KeyMarker:       query.Get("key-marker"),
+		VersionIDMarker: query.Get("version-id-marker"),
+		MaxKeys:         maxKeys,
+		Delimiter:       query.Get("delimiter"),
+		EncodingType:    query.Get("encoding-type"),
+	}
+	if s := query.Get("max-keys"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return invalidArgumentError("max-keys must be a non-negative integer")
+		}
+		if n < maxKeys {
+			result.MaxKeys = n
+		}
+	}
+	if result.EncodingType != "" && result.EncodingType != "url" {
+		return invalidArgumentError("encoding-type must be url")
+	}
+	if result.VersionIDMarker != "" && result.KeyMarker == "" {
+		return invalidArgumentError("version-id-marker can only be given with key-marker")
+	}
+	encode := func(s string) string {
+		if result.EncodingType == "" {
+			return s
+		}
+		return strings.Replace(url.QueryEscape(s), "%2F", "/", -1)
+	}
+
+	commitInfo, err := b.head(c)
+	if err != nil {
+		return err
+	}
+	entries, err := listEntries(c, commitInfo, result.Prefix, result.Delimiter, true)
+	if err != nil {
+		return err
+	}
+	var previous string
+	// afterMarker is set once the listing has passed the marker
+	afterMarker := result.KeyMarker == ""
+	for _, entry := range entries {
+		isLatest := entry.key != previous
+		previous = entry.key
+		var versionID string
+		if entry.fileInfo != nil {
+			versionID = entry.fileInfo.File.Commit.ID
+			if isLatest {
+				versionID = commitInfo.Commit.ID
+			}
+		}
+		if !afterMarker {
+			switch {
+			case entry.key < result.KeyMarker:
+				continue
+			case entry.key == result.KeyMarker:
+				if entry.fileInfo != nil && versionID == result.VersionIDMarker {
+					afterMarker = true
+				}
+				continue
+			}
+			afterMarker = true
+		}
+		if len(result.Versions)+len(result.CommonPrefixes) == result.MaxKeys {
+			result.IsTruncated = true
+			break
+		}
+		result.NextKeyMarker, result.NextVersionIDMarker = entry.key, versionID
+		if entry.fileInfo == nil {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: encode(entry.key)})
+			continue
+		}
+		lastModified := commitTime(commitInfo)
+		if entry.fileInfo.Committed != nil {
+			lastModified = timestamp(entry.fileInfo.Committed)
+		}
+		result.Versions = append(result.Versions, objectVersion{
+			Key:          encode(entry.key),
+			VersionID:    versionID,
+			IsLatest:     isLatest,
+			LastModified: formatTime(lastModified),
+			ETag:         etag(entry.fileInfo),
+			Size:         entry.fileInfo.SizeBytes,
+			StorageClass: "STANDARD",
+		})
+	}
+	if !result.IsTruncated {
+		result.NextKeyMarker, result.NextVersionIDMarker = "", ""
+	}
+	result.Prefix, result.Delimiter = encode(result.Prefix), encode(result.Delimiter)
+	result.KeyMarker, result.NextKeyMarker = encode(result.KeyMarker), encode(result.NextKeyMarker)
+	return writeXML(w, http.StatusOK, result)
+}
@@ -1,16 +1,21 @@
 package server
 
 import (
+	"bufio"
 	"fmt"
+	"path"
 	"sync"
 	"time"
 
 	"github.com/gogo/protobuf/types"
 	"github.com/pachyderm/pachyderm/src/client"
 	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/dryrun"
 	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/server/pkg/errutil"
 	"github.com/pachyderm/pachyderm/src/server/pkg/hashtree"
 	"github.com/pachyderm/pachyderm/src/server/pkg/log"
+	"github.com/pachyderm/pachyderm/src/server/pkg/pager"
 
 	"github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
@@ -64,6 +69,7 @@ func newAPIServer(address string, etcdAddresses []string, etcdPrefix string, tre
 		address: address,
 	}
 	go func() { s.getPachClient(context.Background()) }() // Begin dialing connection on startup
+	go s.runCronTriggers()
 	return s, nil
 }
 
@@ -71,7 +77,11 @@ func (a *apiServer) CreateRepo(ctx context.Context, request *pfs.CreateRepoReque
 	func() { a.Log(request, nil, nil, 0) }()
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 
-	if err := a.driver.createRepo(a.getPachClient(ctx), request.Repo, request.Description, request.Update); err != nil {
+	if dryrun.IsDryRun(ctx) {
+		changes, err := a.driver.createRepoDryRun(a.getPachClient(ctx), request.Repo, request.Description, request.Retention, request.Compression, request.Encryption, request.Update)
+		return reportDryRun(ctx, changes, err)
+	}
+	if err := a.driver.createRepo(a.getPachClient(ctx), request.Repo, request.Description, request.Retention, request.Compression, request.Encryption, request.Update); err != nil {
 		return nil, err
 	}
 	return &types.Empty{}, nil
@@ -89,13 +99,31 @@ func (a *apiServer) ListRepo(ctx context.Context, request *pfs.ListRepoRequest)
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 
 	repoInfos, err := a.driver.listRepo(a.getPachClient(ctx), true)
-	return repoInfos, err
+	if err != nil {
+		return nil, err
+	}
+	p := pager.New(request.Page, request.PageSize)
+	response = &pfs.ListRepoResponse{}
+	for _, repoInfo := range repoInfos.RepoInfo {
+		if ok, _ := p.Next(); ok {
+			response.RepoInfo = append(response.RepoInfo, repoInfo)
+		}
+	}
+	return response, nil
 }
 
 func (a *apiServer) DeleteRepo(ctx context.Context, request *pfs.DeleteRepoRequest) (response *types.Empty, retErr error) {
 	func() { a.Log(request, nil, nil, 0) }()
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 
+	if dryrun.IsDryRun(ctx) {
+		if request.All {
+			changes, err := a.driver.deleteAllDryRun(a.getPachClient(ctx))
+			return reportDryRun(ctx, changes, err)
+		}
+		changes, err := a.driver.deleteRepoDryRun(a.getPachClient(ctx), request.Repo, request.Force)
+		return reportDryRun(ctx, changes, err)
+	}
 	if request.All {
 		if err := a.driver.deleteAll(a.getPachClient(ctx)); err != nil {
 			return nil, err
@@ -113,7 +141,7 @@ func (a *apiServer) StartCommit(ctx context.Context, request *pfs.StartCommitReq
 	func() { a.Log(request, nil, nil, 0) }()
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 
-	commit, err := a.driver.startCommit(a.getPachClient(ctx), request.Parent, request.Branch, request.Provenance, request.Description)
+	commit, err := a.driver.startCommit(a.getPachClient(ctx), request.Parent, request.Branch, request.Provenance, request.Description, request.Metadata)
 	if err != nil {
 		return nil, err
 	}
@@ -138,7 +166,7 @@ func (a *apiServer) FinishCommit(ctx context.Context, request *pfs.FinishCommitR
 		if err := a.driver.finishOutputCommit(a.getPachClient(ctx), request.Commit, request.Trees, request.Datums, request.SizeBytes); err != nil {
 			return nil, err
 		}
-	} else if err := a.driver.finishCommit(a.getPachClient(ctx), request.Commit, request.Tree, request.Empty, request.Description); err != nil {
+	} else if err := a.driver.finishCommit(a.getPachClient(ctx), request.Commit, request.Tree, request.Empty, request.Description, request.Metadata); err != nil {
 		return nil, err
 	}
 	return &types.Empty{}, nil
@@ -155,8 +183,15 @@ func (a *apiServer) ListCommit(ctx context.Context, request *pfs.ListCommitReque
 	func() { a.Log(request, nil, nil, 0) }()
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 
-	commitInfos, err := a.driver.listCommit(a.getPachClient(ctx), request.Repo, request.To, request.From, request.Number)
-	if err != nil {
+	var commitInfos []*pfs.CommitInfo
+	p := pager.New(request.Page, request.PageSize)
+	if err := a.driver.listCommitF(a.getPachClient(ctx), request.Repo, request.To, request.From, request.Number, request.Metadata, func(ci *pfs.CommitInfo) error {
+		if ok, err := p.Next(); !ok {
+			return err
+		}
+		commitInfos = append(commitInfos, ci)
+		return nil
+	}); err != nil {
 		return nil, err
 	}
 	return &pfs.CommitInfos{
@@ -170,7 +205,11 @@ func (a *apiServer) ListCommitStream(req *pfs.ListCommitRequest, respServer pfs.
 	defer func(start time.Time) {
 		a.Log(req, fmt.Sprintf("stream containing %d commits", sent), retErr, time.Since(start))
 	}(time.Now())
-	return a.driver.listCommitF(a.getPachClient(respServer.Context()), req.Repo, req.To, req.From, req.Number, func(ci *pfs.CommitInfo) error {
+	p := pager.New(req.Page, req.PageSize)
+	return a.driver.listCommitF(a.getPachClient(respServer.Context()), req.Repo, req.To, req.From, req.Number, req.Metadata, func(ci *pfs.CommitInfo) error {
+		if ok, err := p.Next(); !ok {
+			return err
+		}
 		sent++
 		return respServer.Send(ci)
 	})
@@ -180,7 +219,7 @@ func (a *apiServer) CreateBranch(ctx context.Context, request *pfs.CreateBranchR
 	func() { a.Log(request, nil, nil, 0) }()
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 
-	if err := a.driver.createBranch(a.getPachClient(ctx), request.Branch, request.Head, request.Provenance); err != nil {
+	if err := a.driver.createBranch(a.getPachClient(ctx), request.Branch, request.Head, request.Provenance, request.Trigger); err != nil {
 		return nil, err
 	}
 	return &types.Empty{}, nil
@@ -207,7 +246,48 @@ func (a *apiServer) DeleteBranch(ctx context.Context, request *pfs.DeleteBranchR
 	func() { a.Log(request, nil, nil, 0) }()
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 
-	if err := a.driver.deleteBranch(a.getPachClient(ctx), request.Branch, request.Force); err != nil {
+	if dryrun.IsDryRun(ctx) {
+		changes, err := a.driver.deleteBranchDryRun(a.getPachClient(ctx), request.Branch, request.Force, request.Cascade, request.DeleteCommits)
+		return reportDryRun(ctx, changes, err)
+	}
+	if err := a.driver.deleteBranch(a.getPachClient(ctx), request.Branch, request.Force, request.Cascade, request.DeleteCommits); err != nil {
+		return nil, err
+	}
+	return &types.Empty{}, nil
+}
+
+func (a *apiServer) SetRepoQuota(ctx context.Context, request *pfs.SetRepoQuotaRequest) (response *types.Empty, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+
+	if err := a.driver.setRepoQuota(a.getPachClient(ctx), request.Repo, request.Quota); err != nil {
+		return nil, err
+	}
+	return &types.Empty{}, nil
+}
+
+func (a *apiServer) SetRepoSchema(ctx context.Context, request *pfs.SetRepoSchemaRequest) (response *types.Empty, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+
+	if err := a.driver.setRepoSchema(a.getPachClient(ctx), request.Repo, request.Schema); err != nil {
+		return nil, err
+	}
+	return &types.Empty{}, nil
+}
+
+func (a *apiServer) InspectStorage(ctx context.Context, request *pfs.InspectStorageRequest) (response *pfs.StorageInfo, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+
+	return a.driver.inspectStorage(a.getPachClient(ctx), request.Repos)
+}
+
+func (a *apiServer) SetBranchProtection(ctx context.Context, request *pfs.SetBranchProtectionRequest) (response *types.Empty, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+
+	if err := a.driver.setBranchProtection(a.getPachClient(ctx), request.Branch, request.Protection); err != nil {
 		return nil, err
 	}
 	return &types.Empty{}, nil
@@ -217,12 +297,37 @@ func (a *apiServer) DeleteCommit(ctx context.Context, request *pfs.DeleteCommitR
 	func() { a.Log(request, nil, nil, 0) }()
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 
+	if dryrun.IsDryRun(ctx) {
+		changes, err := a.driver.deleteCommitDryRun(a.getPachClient(ctx), request.Commit)
+		return reportDryRun(ctx, changes, err)
+	}
 	if err := a.driver.deleteCommit(a.getPachClient(ctx), request.Commit); err != nil {
 		return nil, err
 	}
 	return &types.Empty{}, nil
 }
 
+func (a *apiServer) SquashCommit(ctx context.Context, request *pfs.SquashCommitRequest) (response *types.Empty, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+
+	if dryrun.IsDryRun(ctx) {
+		changes, err := a.driver.squashCommitDryRun(a.getPachClient(ctx), request.From, request.To)
+		return reportDryRun(ctx, changes, err)
+	}
+	if err := a.driver.squashCommit(a.getPachClient(ctx), request.From, request.To); err != nil {
+		return nil, err
+	}
+	return &types.Empty{}, nil
+}
+
+func (a *apiServer) ProvenanceQuery(ctx context.Context, request *pfs.ProvenanceQueryRequest) (response *pfs.ProvenanceQueryResponse, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+
+	return a.driver.provenanceQuery(a.getPachClient(ctx), request.Commit, request.Direction, request.MaxDepth, request.Repos)
+}
+
 func (a *apiServer) FlushCommit(request *pfs.FlushCommitRequest, stream pfs.API_FlushCommitServer) (retErr error) {
 	func() { a.Log(request, nil, nil, 0) }()
 	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
@@ -237,14 +342,24 @@ func (a *apiServer) SubscribeCommit(request *pfs.SubscribeCommitRequest, stream
 	return a.driver.subscribeCommit(a.getPachClient(stream.Context()), request.Repo, request.Branch, request.From, request.State, stream.Send)
 }
 
+func (a *apiServer) SubscribeCommits(request *pfs.SubscribeCommitsRequest, stream pfs.API_SubscribeCommitsServer) (retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+
+	return a.driver.subscribeCommits(a.getPachClient(stream.Context()), request.Repos, request.Pattern, request.Branch, request.State, request.New, stream.Send)
+}
+
 func (a *apiServer) PutFile(putFileServer pfs.API_PutFileServer) (retErr error) {
 	s := newPutFileServer(putFileServer)
 	r, err := s.Peek()
 	if err != nil {
 		return err
 	}
+	// Don't log the file's contents, or the credentials used to fetch its URL
 	request := *r
 	request.Value = nil
+	request.UrlHeaders = nil
+	request.UrlCredentials = nil
 	func() { a.Log(request, nil, nil, 0) }()
 	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
 	defer drainFileServer(putFileServer)
@@ -277,6 +392,46 @@ func (a *apiServer) GetFile(request *pfs.GetFileRequest, apiGetFileServer pfs.AP
 	return grpcutil.WriteToStreamingBytesServer(file, apiGetFileServer)
 }
 
+func (a *apiServer) GetCommitArchive(request *pfs.GetCommitArchiveRequest, server pfs.API_GetCommitArchiveServer) (retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+
+	// The archive is written in small pieces, so it's buffered into
+	// reasonably-sized messages
+	w := bufio.NewWriterSize(grpcutil.NewStreamingBytesWriter(server), grpcutil.MaxMsgSize/10)
+	if err := a.driver.getCommitArchive(a.getPachClient(server.Context()), request.File, request.Format, w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (a *apiServer) GetCommitManifest(request *pfs.GetCommitManifestRequest, server pfs.API_GetCommitManifestServer) (retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+
+	w := bufio.NewWriterSize(grpcutil.NewStreamingBytesWriter(server), grpcutil.MaxMsgSize/10)
+	if err := a.driver.getCommitManifest(a.getPachClient(server.Context()), request.File, request.Format, w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (a *apiServer) GetFileURL(ctx context.Context, request *pfs.GetFileURLRequest) (response *pfs.GetFileURLResponse, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	// Don't log the response, as anyone with the signed URL can download the
+	// file until it expires
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+
+	var ttl time.Duration
+	if request.Ttl != nil {
+		var err error
+		if ttl, err = types.DurationFromProto(request.Ttl); err != nil {
+			return nil, err
+		}
+	}
+	return a.driver.getFileURL(a.getPachClient(ctx), request.File, ttl)
+}
+
 func (a *apiServer) InspectFile(ctx context.Context, request *pfs.InspectFileRequest) (response *pfs.FileInfo, retErr error) {
 	func() { a.Log(request, nil, nil, 0) }()
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
@@ -296,7 +451,7 @@ func (a *apiServer) ListFile(ctx context.Context, request *pfs.ListFileRequest)
 	}(time.Now())
 
 	var fileInfos []*pfs.FileInfo
-	if err := a.driver.listFile(a.getPachClient(ctx), request.File, request.Full, request.History, func(fi *pfs.FileInfo) error {
+	if err := a.listFilePage(a.getPachClient(ctx), request, func(fi *pfs.FileInfo) error {
 		fileInfos = append(fileInfos, fi)
 		return nil
 	}); err != nil {
@@ -313,19 +468,45 @@ func (a *apiServer) ListFileStream(request *pfs.ListFileRequest, respServer pfs.
 	defer func(start time.Time) {
 		a.Log(request, fmt.Sprintf("response stream with %d objects", sent), retErr, time.Since(start))
 	}(time.Now())
-	return a.driver.listFile(a.getPachClient(respServer.Context()), request.File, request.Full, request.History, func(fi *pfs.FileInfo) error {
+	return a.listFilePage(a.getPachClient(respServer.Context()), request, func(fi *pfs.FileInfo) error {
 		sent++
 		return respServer.Send(fi)
 	})
 }
 
+// listFilePage calls f with the files on the page of ListFile's results that
+// 'request' selects (see ListFileRequest.page_size and cursor)
+func (a *apiServer) listFilePage(pachClient *client.APIClient, request *pfs.ListFileRequest, f func(*pfs.FileInfo) error) error {
+	if request.PageSize == 0 && request.Cursor == "" {
+		return a.driver.listFile(pachClient, request.File, request.Full, request.History, request.Filter, "", f)
+	}
+	if hashtree.IsGlob(request.GetFile().GetPath()) || request.History != 0 {
+		return fmt.Errorf("ListFile can't page its results if the path is a glob pattern or history is set")
+	}
+	var cursor string
+	if request.Cursor != "" {
+		cursor = path.Join("/", request.Cursor)
+	}
+	var n int64
+	if err := a.driver.listFile(pachClient, request.File, request.Full, request.History, request.Filter, cursor, func(fi *pfs.FileInfo) error {
+		if request.PageSize > 0 && n >= request.PageSize {
+			return errutil.ErrBreak
+		}
+		n++
+		return f(fi)
+	}); err != nil && err != errutil.ErrBreak {
+		return err
+	}
+	return nil
+}
+
 func (a *apiServer) WalkFile(request *pfs.WalkFileRequest, server pfs.API_WalkFileServer) (retErr error) {
 	func() { a.Log(request, nil, nil, 0) }()
 	var sent int
 	defer func(start time.Time) {
 		a.Log(request, fmt.Sprintf("response stream with %d objects", sent), retErr, time.Since(start))
 	}(time.Now())
-	return a.driver.walkFile(a.getPachClient(server.Context()), request.File, func(fi *pfs.FileInfo) error {
+	return a.driver.walkFile(a.getPachClient(server.Context()), request.File, false, func(fi *pfs.FileInfo) error {
 		sent++
 		return server.Send(fi)
 	})
@@ -343,7 +524,7 @@ func (a *apiServer) GlobFile(ctx context.Context, request *pfs.GlobFileRequest)
 	}(time.Now())
 
 	var fileInfos []*pfs.FileInfo
-	if err := a.driver.globFile(a.getPachClient(ctx), request.Commit, request.Pattern, func(fi *pfs.FileInfo) error {
+	if err := a.driver.globFile(a.getPachClient(ctx), request.Commit, request.Pattern, request.PatternType, func(fi *pfs.FileInfo) error {
 		fileInfos = append(fileInfos, fi)
 		return nil
 	}); err != nil {
@@ -360,7 +541,7 @@ func (a *apiServer) GlobFileStream(request *pfs.GlobFileRequest, respServer pfs.
 	defer func(start time.Time) {
 		a.Log(request, fmt.Sprintf("response stream with %d objects", sent), retErr, time.Since(start))
 	}(time.Now())
-	return a.driver.globFile(a.getPachClient(respServer.Context()), request.Commit, request.Pattern, func(fi *pfs.FileInfo) error {
+	return a.driver.globFile(a.getPachClient(respServer.Context()), request.Commit, request.Pattern, request.PatternType, func(fi *pfs.FileInfo) error {
 		sent++
 		return respServer.Send(fi)
 	})
@@ -379,14 +560,29 @@ func (a *apiServer) DiffFile(ctx context.Context, request *pfs.DiffFileRequest)
 			a.Log(request, response, retErr, time.Since(start))
 		}
 	}(time.Now())
-	newFileInfos, oldFileInfos, err := a.driver.diffFile(a.getPachClient(ctx), request.NewFile, request.OldFile, request.Shallow)
+	pachClient := a.getPachClient(ctx)
+	newFileInfos, oldFileInfos, err := a.driver.diffFile(pachClient, request.NewFile, request.OldFile, request.Shallow)
 	if err != nil {
 		return nil, err
 	}
-	return &pfs.DiffFileResponse{
+	response = &pfs.DiffFileResponse{
 		NewFiles: newFileInfos,
 		OldFiles: oldFileInfos,
-	}, nil
+	}
+	newPath, oldPath := request.NewFile.GetPath(), request.NewFile.GetPath()
+	if request.OldFile != nil {
+		oldPath = request.OldFile.Path
+	}
+	if request.DetectRenames {
+		response.Renames = detectRenames(newPath, oldPath, newFileInfos, oldFileInfos)
+	}
+	if request.Content {
+		response.ContentDiffs, err = a.driver.diffFileContents(pachClient, newPath, oldPath, newFileInfos, oldFileInfos, request.MaxContentDiffBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
 }
 
 func (a *apiServer) DeleteFile(ctx context.Context, request *pfs.DeleteFileRequest) (response *types.Empty, retErr error) {
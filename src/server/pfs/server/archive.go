@@ -0,0 +1,256 @@
+package server
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"golang.org/x/net/context"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
+)
+
+// The functions in this file implement GetCommitArchive, which returns the
+// files under a path in a commit as one archive, and PutFile's expansion of
+// tar archives (see PutFileRequest.tar). GetCommitArchive walks the files
+// once, and reads each file's contents directly from its objects (or
+// blocks), so archiving a commit doesn't read its tree once per file, as
+// GetFile would.
+
+// defaultFileMode is the mode of files in archives that weren't put from a
+// tar archive (and so have no recorded mode)
+const defaultFileMode = 0644
+
+// archiveWriter writes the entries of an archive
+type archiveWriter interface {
+	// dir adds a directory to the archive
+	dir(name string, modTime time.Time) error
+	// file adds a file of 'size' bytes to the archive, and returns the writer
+	// to which its contents must be written
+	file(name string, size uint64, mode int64, modTime time.Time) (io.Writer, error)
+	// Close finishes the archive
+	Close() error
+}
+
+func newArchiveWriter(w io.Writer, format pfs.ArchiveFormat) (archiveWriter, error) {
+	switch format {
+	case pfs.ArchiveFormat_TAR_GZ:
+		gw := gzip.NewWriter(w)
+		return &tarGzWriter{gw: gw, tw: tar.NewWriter(gw)}, nil
+	case pfs.ArchiveFormat_ZIP:
+		return &zipWriter{zw: zip.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("unrecognized archive format %v", format)
+	}
+}
+
+type tarGzWriter struct {
+	gw *gzip.Writer
+	tw *tar.Writer
+}
+
+func (w *tarGzWriter) dir(name string, modTime time.Time) error {
+	return w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     0755,
+		ModTime:  modTime,
+	})
+}
+
+func (w *tarGzWriter) file(name string, size uint64, mode int64, modTime time.Time) (io.Writer, error) {
+	if err := w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     mode,
+		Size:     int64(size),
+		ModTime:  modTime,
+	}); err != nil {
+		return nil, err
+	}
+	return w.tw, nil
+}
+
+func (w *tarGzWriter) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	return w.gw.Close()
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (w *zipWriter) dir(name string, modTime time.Time) error {
+	_, err := w.zw.CreateHeader(&zip.FileHeader{
+		Name:     name + "/",
+		Modified: modTime,
+	})
+	return err
+}
+
+func (w *zipWriter) file(name string, size uint64, mode int64, modTime time.Time) (io.Writer, error) {
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modTime,
+	}
+	header.SetMode(os.FileMode(mode))
+	return w.zw.CreateHeader(header)
+}
+
+func (w *zipWriter) Close() error {
+	return w.zw.Close()
+}
+
+// getCommitArchive writes the files and directories under 'file' to 'w', as
+// an archive in 'format'. Paths in the archive are relative to the root of
+// the commit.
+func (d *driver) getCommitArchive(pachClient *client.APIClient, file *pfs.File, format pfs.ArchiveFormat, w io.Writer) error {
+	aw, err := newArchiveWriter(w, format)
+	if err != nil {
+		return err
+	}
+	buf := grpcutil.GetBuffer()
+	defer grpcutil.PutBuffer(buf)
+	found := false
+	if err := d.walkFile(pachClient, file, true, func(fileInfo *pfs.FileInfo) error {
+		found = true
+		name := strings.TrimPrefix(fileInfo.File.Path, "/")
+		if name == "" {
+			return nil // the root directory isn't an entry
+		}
+		modTime := time.Now()
+		if fileInfo.Committed != nil {
+			var err error
+			if modTime, err = types.TimestampFromProto(fileInfo.Committed); err != nil {
+				return err
+			}
+		}
+		if fileInfo.FileType == pfs.FileType_DIR {
+			return aw.dir(name, modTime)
+		}
+		fw, err := aw.file(name, fileInfo.SizeBytes, fileMode(fileInfo), modTime)
+		if err != nil {
+			return err
+		}
+		return d.copyFileContents(pachClient, fileInfo, fw, buf)
+	}); err != nil {
+		return err
+	}
+	// walking a missing path visits nothing (as does walking the root of an
+	// empty output commit, which has no tree)
+	if !found && strings.Trim(file.Path, "/") != "" {
+		return pfsserver.ErrFileNotFound{File: file}
+	}
+	return aw.Close()
+}
+
+// fileMode returns the mode recorded in the metadata of the file described by
+// 'fileInfo', or defaultFileMode if none is recorded
+func fileMode(fileInfo *pfs.FileInfo) int64 {
+	if mode, err := strconv.ParseInt(fileInfo.Metadata[pfs.FileModeMetadataKey], 8, 64); err == nil {
+		return mode
+	}
+	return defaultFileMode
+}
+
+// copyFileContents copies the contents of the file described by 'fileInfo'
+// (which must have its objects or block refs) to 'w'
+func (d *driver) copyFileContents(pachClient *client.APIClient, fileInfo *pfs.FileInfo, w io.Writer, buf []byte) error {
+	if len(fileInfo.Objects) == 0 && len(fileInfo.BlockRefs) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(pachClient.Ctx())
+	defer cancel()
+	var r io.ReadCloser
+	if len(fileInfo.BlockRefs) > 0 {
+		getBlocksClient, err := pachClient.ObjectAPIClient.GetBlocks(ctx, &pfs.GetBlocksRequest{
+			BlockRefs: fileInfo.BlockRefs,
+			TotalSize: fileInfo.SizeBytes,
+		})
+		if err != nil {
+			return err
+		}
+		r = grpcutil.NewStreamingBytesReader(getBlocksClient, cancel)
+	} else {
+		getObjectsClient, err := pachClient.ObjectAPIClient.GetObjects(ctx, &pfs.GetObjectsRequest{
+			Objects:   fileInfo.Objects,
+			TotalSize: fileInfo.SizeBytes,
+		})
+		if err != nil {
+			return err
+		}
+		r = grpcutil.NewStreamingBytesReader(getObjectsClient, cancel)
+	}
+	defer r.Close()
+	n, err := io.CopyBuffer(w, grpcutil.ReaderWrapper{Reader: r}, buf)
+	if err != nil {
+		return err
+	}
+	if uint64(n) != fileInfo.SizeBytes {
+		return fmt.Errorf("read %d bytes of %s, but it's %d bytes", n, fileInfo.File.Path, fileInfo.SizeBytes)
+	}
+	return nil
+}
+
+// putFileTar puts the regular files in the tar archive read from 'r' under
+// the path of 'req.File', as described by 'req' (which must have 'tar' set),
+// and returns the files written and their records
+func (d *driver) putFileTar(pachClient *client.APIClient, req *pfs.PutFileRequest, r io.Reader) ([]*pfs.File, []*pfs.PutFileRecords, error) {
+	switch {
+	case req.Delimiter != pfs.Delimiter_NONE || req.AppendRecords:
+		return nil, nil, fmt.Errorf("tar archives can't be split or appended as records")
+	case req.OverwriteIndex != nil && req.OverwriteIndex.Index != 0:
+		return nil, nil, fmt.Errorf("files in tar archives can only be overwritten from the start (overwrite index 0)")
+	}
+	var files []*pfs.File
+	var records []*pfs.PutFileRecords
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, records, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading tar archive: %v", err)
+		}
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+		case tar.TypeDir:
+			continue // directories are created with the files in them
+		default:
+			return nil, nil, fmt.Errorf("can't put %q from tar archive: only regular files and directories are supported", header.Name)
+		}
+		// entries can't be written outside of req.File.Path
+		file := client.NewFile(req.File.Commit.Repo.Name, req.File.Commit.ID,
+			path.Join(req.File.Path, path.Clean("/"+header.Name)))
+		fileRecords, err := d.putFile(pachClient, file, pfs.Delimiter_NONE, 0, 0, 0, req.OverwriteIndex, false, tr)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(fileRecords.Records) > 0 {
+			metadata := map[string]string{
+				pfs.FileModeMetadataKey: fmt.Sprintf("%04o", header.Mode&07777),
+			}
+			for k, v := range req.Metadata {
+				metadata[k] = v
+			}
+			fileRecords.Records[0].Metadata = metadata
+		}
+		files = append(files, file)
+		records = append(records, fileRecords)
+	}
+}
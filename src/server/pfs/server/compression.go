@@ -0,0 +1,142 @@
+package server
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
+)
+
+// The functions in this file implement the compression of file contents in
+// object storage (see pfs.CompressionSpec). PutFile asks the object API to
+// compress the objects that it writes, as configured for the file's repo (or
+// by pachd's environment), and each object is compressed separately, so that
+// objects can still be packed into blocks and read by byte range. An object's
+// BlockRef records how it was compressed, so that objects written with any
+// setting, or before compression was enabled, are read the same way.
+
+const maxZstdLevel = 22
+
+// compressionFromEnv returns the default compression set in pachd's
+// environment, or nil if none is set
+func compressionFromEnv() (*pfs.CompressionSpec, error) {
+	spec, err := pfsserver.CompressionFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCompression(spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// validateCompression returns an error if 'spec' is an invalid compression
+// spec
+func validateCompression(spec *pfs.CompressionSpec) error {
+	if spec == nil {
+		return nil
+	}
+	var maxLevel int32
+	switch spec.Compression {
+	case pfs.Compression_UNCOMPRESSED:
+	case pfs.Compression_GZIP:
+		maxLevel = gzip.BestCompression
+	case pfs.Compression_ZSTD:
+		maxLevel = maxZstdLevel
+	default:
+		return fmt.Errorf("unrecognized compression %v", spec.Compression)
+	}
+	if spec.Level < 0 || spec.Level > maxLevel {
+		return fmt.Errorf("invalid %v compression level %d: must be between 0 and %d", spec.Compression, spec.Level, maxLevel)
+	}
+	return nil
+}
+
+// compressWriter returns a writer that compresses the data written to it as
+// described by 'spec', and writes it to 'w'. The writer must be closed to
+// flush the compressed data.
+func compressWriter(w io.Writer, spec *pfs.CompressionSpec) (io.WriteCloser, error) {
+	switch spec.Compression {
+	case pfs.Compression_GZIP:
+		level := gzip.DefaultCompression
+		if spec.Level != 0 {
+			level = int(spec.Level)
+		}
+		return gzip.NewWriterLevel(w, level)
+	case pfs.Compression_ZSTD:
+		level := zstd.SpeedDefault
+		if spec.Level != 0 {
+			level = zstd.EncoderLevelFromZstd(int(spec.Level))
+		}
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(1))
+	default:
+		return nil, fmt.Errorf("can't compress data with compression %v", spec.Compression)
+	}
+}
+
+// decompressReader returns a reader that decompresses the data read from
+// 'r', which was compressed with 'compression'
+func decompressReader(r io.Reader, compression pfs.Compression) (io.ReadCloser, error) {
+	switch compression {
+	case pfs.Compression_UNCOMPRESSED:
+		return ioutil.NopCloser(r), nil
+	case pfs.Compression_GZIP:
+		return gzip.NewReader(r)
+	case pfs.Compression_ZSTD:
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("can't decompress data with compression %v", compression)
+	}
+}
+
+// fileCompression returns how the contents of files written to 'repo' should
+// be compressed (or nil if they shouldn't be)
+func (d *driver) fileCompression(pachClient *client.APIClient, repo *pfs.Repo) (*pfs.CompressionSpec, error) {
+	repoInfo := &pfs.RepoInfo{}
+	if err := d.repos.ReadOnly(pachClient.Ctx()).Get(repo.Name, repoInfo); err != nil {
+		return nil, err
+	}
+	if repoInfo.Compression != nil {
+		return repoInfo.Compression, nil
+	}
+	return d.compression, nil
+}
+
+// countWriter counts the bytes written through it to 'w'
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// blockRefReadCloser closes both the decompressor and the underlying object
+// reader of a compressed block ref
+type blockRefReadCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *blockRefReadCloser) Close() error {
+	var retErr error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}
+	return retErr
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
+	"github.com/pachyderm/pachyderm/src/server/pkg/log"
+	"github.com/pachyderm/pachyderm/src/server/pkg/obj"
+)
+
+func TestCompressionRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("foo,bar,baz\n", 10000))
+	for _, spec := range []*pfs.CompressionSpec{
+		{Compression: pfs.Compression_GZIP},
+		{Compression: pfs.Compression_GZIP, Level: 9},
+		{Compression: pfs.Compression_ZSTD},
+		{Compression: pfs.Compression_ZSTD, Level: 1},
+	} {
+		var compressed bytes.Buffer
+		w, err := compressWriter(&compressed, spec)
+		require.NoError(t, err)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		require.True(t, compressed.Len() < len(data)/10, "%v: %d bytes", spec, compressed.Len())
+
+		r, err := decompressReader(&compressed, spec.Compression)
+		require.NoError(t, err)
+		decompressed, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, data, decompressed)
+	}
+}
+
+func TestValidateCompression(t *testing.T) {
+	require.NoError(t, validateCompression(nil))
+	require.NoError(t, validateCompression(&pfs.CompressionSpec{}))
+	require.NoError(t, validateCompression(&pfs.CompressionSpec{Compression: pfs.Compression_GZIP, Level: 9}))
+	require.NoError(t, validateCompression(&pfs.CompressionSpec{Compression: pfs.Compression_ZSTD, Level: 22}))
+	require.YesError(t, validateCompression(&pfs.CompressionSpec{Compression: pfs.Compression_UNCOMPRESSED, Level: 1}))
+	require.YesError(t, validateCompression(&pfs.CompressionSpec{Compression: pfs.Compression_GZIP, Level: 10}))
+	require.YesError(t, validateCompression(&pfs.CompressionSpec{Compression: pfs.Compression_ZSTD, Level: -1}))
+	require.YesError(t, validateCompression(&pfs.CompressionSpec{Compression: 10}))
+}
+
+func TestPutObjectCompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestPutObjectCompressed")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	objClient, err := obj.NewLocalClient(dir)
+	require.NoError(t, err)
+	s := &objBlockAPIServer{Logger: log.NewLogger("pfs.BlockAPI.Obj"), dir: dir, objClient: objClient}
+
+	data := strings.Repeat("foo,bar,baz\n", 10000)
+	for _, spec := range []*pfs.CompressionSpec{
+		nil,
+		{Compression: pfs.Compression_UNCOMPRESSED},
+		{Compression: pfs.Compression_GZIP},
+		{Compression: pfs.Compression_ZSTD},
+	} {
+		// vary the data, so that each spec writes a new object
+		data = data + "qux\n"
+		object, _, _, err := s.putObject(context.Background(), strings.NewReader(data), spec, nil)
+		require.NoError(t, err)
+		blockRef := &pfs.BlockRef{}
+		require.NoError(t, s.readProto(s.objectPath(object), blockRef))
+		require.Equal(t, spec.GetCompression(), blockRef.Compression)
+		require.Equal(t, uint64(len(data)), pfsserver.BlockRefSize(blockRef))
+		if spec.GetCompression() != pfs.Compression_UNCOMPRESSED {
+			require.True(t, pfsserver.ByteRangeSize(blockRef.Range) < uint64(len(data)/10))
+		}
+
+		// read part of the object
+		r, err := s.blockRefReader(blockRef, 12, 7)
+		require.NoError(t, err)
+		part, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, "foo,bar", string(part))
+	}
+}
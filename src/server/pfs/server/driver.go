@@ -3,6 +3,8 @@ package server
 import (
 	"bufio"
 	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
@@ -21,6 +23,7 @@ import (
 	"sync"
 	"time"
 
+	"golang.org/x/net/context"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/semaphore"
 
@@ -30,6 +33,7 @@ import (
 	"github.com/pachyderm/pachyderm/src/client/limit"
 	"github.com/pachyderm/pachyderm/src/client/pfs"
 	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+	pach_http "github.com/pachyderm/pachyderm/src/server/http"
 	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
 	"github.com/pachyderm/pachyderm/src/server/pkg/ancestry"
 	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
@@ -39,6 +43,7 @@ import (
 	"github.com/pachyderm/pachyderm/src/server/pkg/pfsdb"
 	"github.com/pachyderm/pachyderm/src/server/pkg/ppsconsts"
 	"github.com/pachyderm/pachyderm/src/server/pkg/sql"
+	"github.com/pachyderm/pachyderm/src/server/pkg/textdiff"
 	"github.com/pachyderm/pachyderm/src/server/pkg/uuid"
 	"github.com/pachyderm/pachyderm/src/server/pkg/watch"
 	"github.com/sirupsen/logrus"
@@ -58,6 +63,20 @@ const (
 
 	// maxInt is the maximum value for 'int' (system-dependent). Not in 'math'!
 	maxInt = int(^uint(0) >> 1)
+
+	// defaultFileURLTTL is how long the URLs returned by GetFileURL are valid
+	// for, if no TTL is requested, and maxFileURLTTL is the longest TTL allowed
+	defaultFileURLTTL = time.Hour
+	maxFileURLTTL     = 7 * 24 * time.Hour
+
+	// defaultMaxContentDiffBytes is the size of the largest file whose
+	// contents DiffFile diffs, if no limit is requested
+	defaultMaxContentDiffBytes = 1024 * 1024
+
+	// squashBatchSize is the number of commits that squashCommit deletes in
+	// each etcd transaction, which keeps the transactions under etcd's limit
+	// on the number of operations in one transaction
+	squashBatchSize = 50
 )
 
 var (
@@ -74,6 +93,27 @@ func validateRepoName(name string) error {
 	return nil
 }
 
+// validateRetention returns an error if 'retention' is an invalid retention
+// policy
+func validateRetention(retention *pfs.RetentionPolicy) error {
+	if retention == nil {
+		return nil
+	}
+	if retention.KeepCommits < 0 {
+		return fmt.Errorf("invalid retention policy: keep_commits must be at least 0, but was %d", retention.KeepCommits)
+	}
+	if retention.KeepDuration != nil {
+		keepDuration, err := types.DurationFromProto(retention.KeepDuration)
+		if err != nil {
+			return fmt.Errorf("invalid retention policy: %v", err)
+		}
+		if keepDuration < 0 {
+			return fmt.Errorf("invalid retention policy: keep_duration must be at least 0, but was %v", keepDuration)
+		}
+	}
+	return nil
+}
+
 // IsPermissionError returns true if a given error is a permission error.
 func IsPermissionError(err error) bool {
 	return strings.Contains(err.Error(), "has already finished")
@@ -113,6 +153,13 @@ type driver struct {
 
 	// memory limiter (useful for limiting operations that could use a lot of memory)
 	memoryLimiter *semaphore.Weighted
+
+	// compression is the default compression of the contents of files (nil if
+	// they aren't compressed by default), which repos' settings override
+	compression *pfs.CompressionSpec
+	// encryption is the default encryption of the contents of files (nil if
+	// they aren't encrypted by default), which repos' settings override
+	encryption *pfs.EncryptionSpec
 }
 
 // newDriver is used to create a new Driver instance
@@ -121,6 +168,14 @@ func newDriver(etcdAddresses []string, etcdPrefix string, treeCache *hashtree.Ca
 	if treeCache == nil {
 		return nil, fmt.Errorf("cannot initialize driver with nil treeCache")
 	}
+	compression, err := compressionFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	encryption, err := encryptionFromEnv()
+	if err != nil {
+		return nil, err
+	}
 
 	// Initialize etcd client
 	etcdClient, err := etcd.New(etcd.Config{
@@ -147,6 +202,8 @@ func newDriver(etcdAddresses []string, etcdPrefix string, treeCache *hashtree.Ca
 		storageRoot: storageRoot,
 		// Allow up to a third of the requested memory to be used for memory intensive operations
 		memoryLimiter: semaphore.NewWeighted(memoryRequest / 3),
+		compression:   compression,
+		encryption:    encryption,
 	}
 	return d, nil
 }
@@ -173,6 +230,26 @@ func (d *driver) checkIsAuthorized(pachClient *client.APIClient, r *pfs.Repo, s
 	return nil
 }
 
+// checkIsAdmin returns an error if auth is active and the current user (in
+// 'pachClient') isn't a cluster admin. 'op' names the operation being
+// authorized.
+func (d *driver) checkIsAdmin(pachClient *client.APIClient, op string) error {
+	me, err := pachClient.AuthAPIClient.WhoAmI(pachClient.Ctx(), &auth.WhoAmIRequest{})
+	if auth.IsErrNotActivated(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error during authorization check: %v", grpcutil.ScrubGRPC(err))
+	}
+	if !me.IsAdmin {
+		return &auth.ErrNotAuthorized{
+			Subject: me.Username,
+			AdminOp: op,
+		}
+	}
+	return nil
+}
+
 func now() *types.Timestamp {
 	t, err := types.TimestampProto(time.Now())
 	if err != nil {
@@ -189,7 +266,7 @@ func absent(key string) etcd.Cmp {
 	return etcd.Compare(etcd.CreateRevision(key), "=", 0)
 }
 
-func (d *driver) createRepo(pachClient *client.APIClient, repo *pfs.Repo, description string, update bool) error {
+func (d *driver) createRepo(pachClient *client.APIClient, repo *pfs.Repo, description string, retention *pfs.RetentionPolicy, compression *pfs.CompressionSpec, encryption *pfs.EncryptionSpec, update bool) error {
 	ctx := pachClient.Ctx()
 	// Check that the user is logged in (user doesn't need any access level to
 	// create a repo, but they must be authenticated if auth is active)
@@ -202,8 +279,17 @@ func (d *driver) createRepo(pachClient *client.APIClient, repo *pfs.Repo, descri
 	if err := validateRepoName(repo.Name); err != nil {
 		return err
 	}
+	if err := validateRetention(retention); err != nil {
+		return err
+	}
+	if err := validateCompression(compression); err != nil {
+		return err
+	}
+	if err := validateEncryption(encryption); err != nil {
+		return err
+	}
 	if update {
-		return d.updateRepo(pachClient, repo, description)
+		return d.updateRepo(pachClient, repo, description, retention, compression, encryption)
 	}
 
 	_, err = col.NewSTM(ctx, d.etcdClient, func(stm col.STM) error {
@@ -243,13 +329,21 @@ func (d *driver) createRepo(pachClient *client.APIClient, repo *pfs.Repo, descri
 			Repo:        repo,
 			Created:     now(),
 			Description: description,
+			Compression: compression,
+			Encryption:  encryption,
+		}
+		if retention != nil {
+			repoInfo.Retention = normalizeRetention(retention)
 		}
 		return repos.Create(repo.Name, repoInfo)
 	})
 	return err
 }
 
-func (d *driver) updateRepo(pachClient *client.APIClient, repo *pfs.Repo, description string) error {
+// updateRepo sets the description of 'repo', and its retention policy,
+// compression and encryption if 'retention', 'compression' and 'encryption'
+// are set
+func (d *driver) updateRepo(pachClient *client.APIClient, repo *pfs.Repo, description string, retention *pfs.RetentionPolicy, compression *pfs.CompressionSpec, encryption *pfs.EncryptionSpec) error {
 	ctx := pachClient.Ctx()
 	_, err := col.NewSTM(ctx, d.etcdClient, func(stm col.STM) error {
 		repos := d.repos.ReadWrite(stm)
@@ -263,11 +357,33 @@ func (d *driver) updateRepo(pachClient *client.APIClient, repo *pfs.Repo, descri
 			return err
 		}
 		repoInfo.Description = description
+		if retention != nil {
+			repoInfo.Retention = normalizeRetention(retention)
+		}
+		if compression != nil {
+			repoInfo.Compression = compression
+		}
+		if encryption != nil {
+			// Only owners may change how a repo's data is protected
+			if err := d.checkIsAuthorized(pachClient, repo, auth.Scope_OWNER); err != nil {
+				return err
+			}
+			repoInfo.Encryption = encryption
+		}
 		return repos.Put(repo.Name, repoInfo)
 	})
 	return err
 }
 
+// normalizeRetention returns nil if 'retention' keeps every commit (so that
+// setting an empty policy removes a repo's policy), and 'retention' otherwise
+func normalizeRetention(retention *pfs.RetentionPolicy) *pfs.RetentionPolicy {
+	if retention.KeepCommits == 0 && retention.KeepDuration == nil {
+		return nil
+	}
+	return retention
+}
+
 func (d *driver) inspectRepo(pachClient *client.APIClient, repo *pfs.Repo, includeAuth bool) (*pfs.RepoInfo, error) {
 	ctx := pachClient.Ctx()
 	result := &pfs.RepoInfo{}
@@ -414,12 +530,22 @@ func (d *driver) deleteRepo(pachClient *client.APIClient, repo *pfs.Repo, force
 	return nil
 }
 
-func (d *driver) startCommit(pachClient *client.APIClient, parent *pfs.Commit, branch string, provenance []*pfs.Commit, description string) (*pfs.Commit, error) {
-	return d.makeCommit(pachClient, "", parent, branch, provenance, nil, nil, nil, description)
+func (d *driver) startCommit(pachClient *client.APIClient, parent *pfs.Commit, branch string, provenance []*pfs.Commit, description string, metadata map[string]string) (*pfs.Commit, error) {
+	if branch != "" {
+		if err := d.checkBranchProtection(pachClient, client.NewCommit(parent.Repo.Name, branch)); err != nil {
+			return nil, err
+		}
+	}
+	return d.makeCommit(pachClient, "", parent, branch, provenance, nil, nil, nil, description, metadata)
 }
 
 func (d *driver) buildCommit(pachClient *client.APIClient, ID string, parent *pfs.Commit, branch string, provenance []*pfs.Commit, tree *pfs.Object) (*pfs.Commit, error) {
-	return d.makeCommit(pachClient, ID, parent, branch, provenance, tree, nil, nil, "")
+	if branch != "" {
+		if err := d.checkBranchProtection(pachClient, client.NewCommit(parent.Repo.Name, branch)); err != nil {
+			return nil, err
+		}
+	}
+	return d.makeCommit(pachClient, ID, parent, branch, provenance, tree, nil, nil, "", nil)
 }
 
 // make commit makes a new commit in 'branch', with the parent 'parent' and the
@@ -432,7 +558,7 @@ func (d *driver) buildCommit(pachClient *client.APIClient, ID string, parent *pf
 //   to the new commit
 // - If neither 'parent.ID' nor 'branch' are set, the new commit will have no
 //   parent
-func (d *driver) makeCommit(pachClient *client.APIClient, ID string, parent *pfs.Commit, branch string, provenance []*pfs.Commit, treeRef *pfs.Object, recordFiles []string, records []*pfs.PutFileRecords, description string) (*pfs.Commit, error) {
+func (d *driver) makeCommit(pachClient *client.APIClient, ID string, parent *pfs.Commit, branch string, provenance []*pfs.Commit, treeRef *pfs.Object, recordFiles []string, records []*pfs.PutFileRecords, description string, metadata map[string]string) (*pfs.Commit, error) {
 	// Validate arguments:
 	if parent == nil {
 		return nil, fmt.Errorf("parent cannot be nil")
@@ -455,6 +581,7 @@ func (d *driver) makeCommit(pachClient *client.APIClient, ID string, parent *pfs
 		Commit:      newCommit,
 		Started:     now(),
 		Description: description,
+		Metadata:    metadata,
 	}
 
 	//  BuildCommit case: if the caller passed a tree reference with the commit
@@ -556,7 +683,21 @@ func (d *driver) makeCommit(pachClient *client.APIClient, ID string, parent *pfs
 					return err
 				}
 			}
-			repoInfo.SizeBytes += sizeChange(tree, parentTree)
+			fileCount, err := countFiles(tree)
+			if err != nil {
+				return err
+			}
+			sizeChange := sizeChange(tree, parentTree)
+			if len(provenance) == 0 {
+				if err := checkQuota(repoInfo, sizeChange, fileCount); err != nil {
+					return err
+				}
+				if newCommitInfo.SchemaViolations, err = d.checkSchema(pachClient, newCommit, repoInfo.Schema, tree, parentTree); err != nil {
+					return err
+				}
+			}
+			repoInfo.SizeBytes += sizeChange
+			repoInfo.FileCount = fileCount
 		} else {
 			if err := d.openCommits.ReadWrite(stm).Put(newCommit.ID, newCommit); err != nil {
 				return err
@@ -614,10 +755,13 @@ func (d *driver) makeCommit(pachClient *client.APIClient, ID string, parent *pfs
 	}); err != nil {
 		return nil, err
 	}
+	if treeRef != nil {
+		d.fireTriggers(pachClient, newCommit)
+	}
 	return newCommit, nil
 }
 
-func (d *driver) finishCommit(pachClient *client.APIClient, commit *pfs.Commit, tree *pfs.Object, empty bool, description string) (retErr error) {
+func (d *driver) finishCommit(pachClient *client.APIClient, commit *pfs.Commit, tree *pfs.Object, empty bool, description string, metadata map[string]string) (retErr error) {
 	ctx := pachClient.Ctx()
 	if err := d.checkIsAuthorized(pachClient, commit.Repo, auth.Scope_WRITER); err != nil {
 		return err
@@ -632,15 +776,25 @@ func (d *driver) finishCommit(pachClient *client.APIClient, commit *pfs.Commit,
 	if description != "" {
 		commitInfo.Description = description
 	}
+	if len(metadata) > 0 && commitInfo.Metadata == nil {
+		commitInfo.Metadata = make(map[string]string)
+	}
+	for k, v := range metadata {
+		commitInfo.Metadata[k] = v
+	}
 
 	scratchPrefix := d.scratchCommitPrefix(commit)
+	var finished bool
 	defer func() {
-		if retErr != nil {
+		if !finished {
 			return
 		}
-		// only delete the scratch space if finishCommit() ran successfully and
-		// won't need to be retried
-		_, retErr = d.etcdClient.Delete(ctx, scratchPrefix, etcd.WithPrefix())
+		// only delete the scratch space if the commit was finished and
+		// finishCommit() won't need to be retried (even if moving triggered
+		// branches failed)
+		if _, err := d.etcdClient.Delete(ctx, scratchPrefix, etcd.WithPrefix()); err != nil && retErr == nil {
+			retErr = err
+		}
 	}()
 
 	var parentTree, finishedTree hashtree.HashTree
@@ -691,6 +845,23 @@ func (d *driver) finishCommit(pachClient *client.APIClient, commit *pfs.Commit,
 
 	commitInfo.Finished = now()
 	sizeChange := sizeChange(finishedTree, parentTree)
+	var fileCount uint64
+	if finishedTree != nil {
+		fileCount, err = countFiles(finishedTree)
+		if err != nil {
+			return err
+		}
+		if len(commitInfo.Provenance) == 0 {
+			repoInfo := new(pfs.RepoInfo)
+			if err := d.repos.ReadOnly(ctx).Get(commit.Repo.Name, repoInfo); err != nil {
+				return err
+			}
+			commitInfo.SchemaViolations, err = d.checkSchema(pachClient, commit, repoInfo.Schema, finishedTree, parentTree)
+			if err != nil {
+				return err
+			}
+		}
+	}
 	_, err = col.NewSTM(ctx, d.etcdClient, func(stm col.STM) error {
 		commits := d.commits(commit.Repo.Name).ReadWrite(stm)
 		repos := d.repos.ReadWrite(stm)
@@ -700,23 +871,34 @@ func (d *driver) finishCommit(pachClient *client.APIClient, commit *pfs.Commit,
 		if err := d.openCommits.ReadWrite(stm).Delete(commit.ID); err != nil {
 			return fmt.Errorf("could not confirm that commit %s is open; this is likely a bug. err: %v", commit.ID, err)
 		}
-		if sizeChange > 0 {
+		if finishedTree != nil {
 			// update repo size
 			repoInfo := new(pfs.RepoInfo)
 			if err := repos.Get(commit.Repo.Name, repoInfo); err != nil {
 				return err
 			}
+			if len(commitInfo.Provenance) == 0 {
+				if err := checkQuota(repoInfo, sizeChange, fileCount); err != nil {
+					return err
+				}
+			}
 
 			// Increment the repo sizes by the sizes of the files that have
 			// been added in this commit.
 			repoInfo.SizeBytes += sizeChange
+			repoInfo.FileCount = fileCount
 			if err := repos.Put(commit.Repo.Name, repoInfo); err != nil {
 				return err
 			}
 		}
 		return nil
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	finished = true
+	d.fireTriggers(pachClient, commit)
+	return nil
 }
 
 func (d *driver) finishOutputCommit(pachClient *client.APIClient, commit *pfs.Commit, trees []*pfs.Object, datums *pfs.Object, size uint64) (retErr error) {
@@ -745,7 +927,11 @@ func (d *driver) finishOutputCommit(pachClient *client.APIClient, commit *pfs.Co
 		}
 		return nil
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	d.fireTriggers(pachClient, commit)
+	return nil
 }
 
 // propagateCommit selectively starts commits in or downstream of 'branch' in
@@ -1056,7 +1242,7 @@ func (d *driver) resolveCommit(stm col.STM, userCommit *pfs.Commit) (*pfs.Commit
 
 func (d *driver) listCommit(pachClient *client.APIClient, repo *pfs.Repo, to *pfs.Commit, from *pfs.Commit, number uint64) ([]*pfs.CommitInfo, error) {
 	var result []*pfs.CommitInfo
-	if err := d.listCommitF(pachClient, repo, to, from, number, func(ci *pfs.CommitInfo) error {
+	if err := d.listCommitF(pachClient, repo, to, from, number, nil, func(ci *pfs.CommitInfo) error {
 		result = append(result, ci)
 		return nil
 	}); err != nil {
@@ -1065,7 +1251,9 @@ func (d *driver) listCommit(pachClient *client.APIClient, repo *pfs.Repo, to *pf
 	return result, nil
 }
 
-func (d *driver) listCommitF(pachClient *client.APIClient, repo *pfs.Repo, to *pfs.Commit, from *pfs.Commit, number uint64, f func(*pfs.CommitInfo) error) error {
+// listCommitF calls 'f' on up to 'number' commits (all commits if 'number' is
+// 0) whose metadata includes 'metadata'
+func (d *driver) listCommitF(pachClient *client.APIClient, repo *pfs.Repo, to *pfs.Commit, from *pfs.Commit, number uint64, metadata map[string]string, f func(*pfs.CommitInfo) error) error {
 	ctx := pachClient.Ctx()
 	if err := d.checkIsAuthorized(pachClient, repo, auth.Scope_READER); err != nil {
 		return err
@@ -1113,6 +1301,9 @@ func (d *driver) listCommitF(pachClient *client.APIClient, repo *pfs.Repo, to *p
 			if number <= 0 {
 				return errutil.ErrBreak
 			}
+			if !hasMetadata(ci, metadata) {
+				return nil
+			}
 			number--
 			return f(proto.Clone(ci).(*pfs.CommitInfo))
 		}); err != nil {
@@ -1125,19 +1316,33 @@ func (d *driver) listCommitF(pachClient *client.APIClient, repo *pfs.Repo, to *p
 			if err := commits.Get(cursor.ID, &commitInfo); err != nil {
 				return err
 			}
+			cursor = commitInfo.ParentCommit
+			if !hasMetadata(&commitInfo, metadata) {
+				continue
+			}
 			if err := f(&commitInfo); err != nil {
 				if err == errutil.ErrBreak {
 					return nil
 				}
 				return err
 			}
-			cursor = commitInfo.ParentCommit
 			number--
 		}
 	}
 	return nil
 }
 
+// hasMetadata returns true if the metadata of 'commitInfo' includes every key
+// in 'metadata', with the same value
+func hasMetadata(commitInfo *pfs.CommitInfo, metadata map[string]string) bool {
+	for k, v := range metadata {
+		if value, ok := commitInfo.Metadata[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
 func (d *driver) subscribeCommit(pachClient *client.APIClient, repo *pfs.Repo, branch string, from *pfs.Commit, state pfs.CommitState, f func(*pfs.CommitInfo) error) error {
 	if from != nil && from.Repo.Name != repo.Name {
 		return fmt.Errorf("the `from` commit needs to be from repo %s", repo.Name)
@@ -1279,6 +1484,9 @@ func (d *driver) deleteCommit(pachClient *client.APIClient, userCommit *pfs.Comm
 	if err := d.checkIsAuthorized(pachClient, userCommit.Repo, auth.Scope_WRITER); err != nil {
 		return err
 	}
+	if err := d.checkCommitDeleteProtection(pachClient, userCommit); err != nil {
+		return err
+	}
 	// Main txn: Delete all downstream commits, and update subvenance of upstream commits
 	// TODO update branches inside this txn, by storing a repo's branches in its
 	// RepoInfo or its HEAD commit
@@ -1556,6 +1764,189 @@ func (d *driver) deleteCommit(pachClient *client.APIClient, userCommit *pfs.Comm
 	return nil
 }
 
+// squashCommit squashes the commits from 'from' through 'to' into 'to' (see
+// pfs.SquashCommitRequest). Only finished input commits that no other commit
+// or branch depends on can be squashed. The squashed commits' records are
+// deleted, so their trees are freed by the next garbage collection.
+func (d *driver) squashCommit(pachClient *client.APIClient, from *pfs.Commit, to *pfs.Commit) error {
+	ctx := pachClient.Ctx()
+	if from.Repo.Name != to.Repo.Name {
+		return fmt.Errorf("cannot squash commits in different repos (\"%s\" and \"%s\")", from.Repo.Name, to.Repo.Name)
+	}
+	if err := d.checkIsAuthorized(pachClient, to.Repo, auth.Scope_WRITER); err != nil {
+		return err
+	}
+	toCommit, squashed, err := d.commitsToSquash(pachClient, from, to)
+	if err != nil {
+		return err
+	}
+	// every squashed commit is an ancestor of 'toCommit' with no other
+	// children, so they're in the history of the same branches as it
+	if len(squashed) > 0 {
+		if err := d.checkDeleteProtection(pachClient, []*pfs.Commit{toCommit}); err != nil {
+			return err
+		}
+	}
+	// Delete the commits in batches, newest first, so that no transaction is
+	// too large. After each batch, the parent of 'to' is the newest commit
+	// left in the range, so the commit graph stays valid if a batch fails.
+	for len(squashed) > 0 {
+		n := squashBatchSize
+		if n > len(squashed) {
+			n = len(squashed)
+		}
+		batch := squashed[:n]
+		if _, err := col.NewSTM(ctx, d.etcdClient, func(stm col.STM) error {
+			return d.squashBatch(stm, toCommit, batch)
+		}); err != nil {
+			return fmt.Errorf("error squashing commits: %v", err)
+		}
+		squashed = squashed[n:]
+	}
+	return nil
+}
+
+// commitsToSquash resolves 'to', and returns it along with the IDs of the
+// commits that squashing 'from' through 'to' deletes (i.e. 'from' through
+// the parent of 'to'), newest first
+func (d *driver) commitsToSquash(pachClient *client.APIClient, from *pfs.Commit, to *pfs.Commit) (*pfs.Commit, []string, error) {
+	ctx := pachClient.Ctx()
+	toInfo, err := d.inspectCommit(pachClient, to, pfs.CommitState_STARTED)
+	if err != nil {
+		return nil, nil, err
+	}
+	fromInfo, err := d.inspectCommit(pachClient, from, pfs.CommitState_STARTED)
+	if err != nil {
+		return nil, nil, err
+	}
+	if toInfo.Finished == nil {
+		return nil, nil, fmt.Errorf("cannot squash into commit \"%s/%s\" because it is not finished", to.Repo.Name, toInfo.Commit.ID)
+	}
+	if len(toInfo.Provenance) > 0 {
+		return nil, nil, fmt.Errorf("cannot squash into commit \"%s/%s\" because it has non-empty provenance", to.Repo.Name, toInfo.Commit.ID)
+	}
+	repoInfo := &pfs.RepoInfo{}
+	if err := d.repos.ReadOnly(ctx).Get(to.Repo.Name, repoInfo); err != nil {
+		return nil, nil, err
+	}
+	heads, err := branchHeads(repoInfo, d.branches(to.Repo.Name).ReadOnly(ctx).Get)
+	if err != nil {
+		return nil, nil, err
+	}
+	commits := d.commits(to.Repo.Name).ReadOnly(ctx)
+	var squashed []string
+	for parent := toInfo.ParentCommit; fromInfo.Commit.ID != toInfo.Commit.ID; {
+		if parent == nil {
+			return nil, nil, fmt.Errorf("commit \"%s\" is not an ancestor of commit \"%s\"", fromInfo.Commit.ID, toInfo.Commit.ID)
+		}
+		commitInfo := &pfs.CommitInfo{}
+		if err := commits.Get(parent.ID, commitInfo); err != nil {
+			return nil, nil, err
+		}
+		if err := checkSquashable(commitInfo, heads); err != nil {
+			return nil, nil, err
+		}
+		squashed = append(squashed, parent.ID)
+		if parent.ID == fromInfo.Commit.ID {
+			break
+		}
+		parent = commitInfo.ParentCommit
+	}
+	return toInfo.Commit, squashed, nil
+}
+
+// squashBatch deletes the commits in 'ids', which must be the newest commits
+// below 'to' (newest first), and makes the parent of the last of them the
+// parent of 'to'
+func (d *driver) squashBatch(stm col.STM, to *pfs.Commit, ids []string) error {
+	commits := d.commits(to.Repo.Name).ReadWrite(stm)
+	repoInfo := &pfs.RepoInfo{}
+	if err := d.repos.ReadWrite(stm).Get(to.Repo.Name, repoInfo); err != nil {
+		return err
+	}
+	heads, err := branchHeads(repoInfo, d.branches(to.Repo.Name).ReadWrite(stm).Get)
+	if err != nil {
+		return err
+	}
+	toInfo := &pfs.CommitInfo{}
+	if err := commits.Get(to.ID, toInfo); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if toInfo.ParentCommit == nil || toInfo.ParentCommit.ID != id {
+			return fmt.Errorf("the ancestors of commit \"%s/%s\" changed while squashing", to.Repo.Name, to.ID)
+		}
+		commitInfo := &pfs.CommitInfo{}
+		if err := commits.Get(id, commitInfo); err != nil {
+			return err
+		}
+		if err := checkSquashable(commitInfo, heads); err != nil {
+			return err
+		}
+		if err := commits.Delete(id); err != nil {
+			return err
+		}
+		toInfo.ParentCommit = commitInfo.ParentCommit
+	}
+	if err := commits.Put(to.ID, toInfo); err != nil {
+		return err
+	}
+	if toInfo.ParentCommit == nil {
+		return nil
+	}
+	// replace the oldest deleted commit with 'to' in its parent's children
+	parentInfo := &pfs.CommitInfo{}
+	return commits.Update(toInfo.ParentCommit.ID, parentInfo, func() error {
+		for i, child := range parentInfo.ChildCommits {
+			if child.ID == ids[len(ids)-1] {
+				parentInfo.ChildCommits[i] = to
+			}
+		}
+		return nil
+	})
+}
+
+// checkSquashable returns an error if squashCommit can't delete the commit in
+// 'commitInfo'. 'heads' holds the IDs of the repo's branches' head commits.
+func checkSquashable(commitInfo *pfs.CommitInfo, heads map[string]bool) error {
+	commit := commitInfo.Commit
+	var reason string
+	switch {
+	case commitInfo.Finished == nil:
+		reason = "it is not finished"
+	case len(commitInfo.Provenance) > 0:
+		reason = "it has non-empty provenance"
+	case len(commitInfo.Subvenance) > 0:
+		reason = "it has downstream commits"
+	case len(commitInfo.ChildCommits) > 1:
+		reason = "it has more than one child commit"
+	case heads[commit.ID]:
+		reason = "it is the head of a branch"
+	default:
+		return nil
+	}
+	return fmt.Errorf("cannot squash commit \"%s/%s\" because %s", commit.Repo.Name, commit.ID, reason)
+}
+
+// branchHeads returns the IDs of the head commits of the branches in
+// 'repoInfo', reading the branches with 'get'
+func branchHeads(repoInfo *pfs.RepoInfo, get func(key string, val proto.Message) error) (map[string]bool, error) {
+	heads := make(map[string]bool)
+	for _, branch := range repoInfo.Branches {
+		branchInfo := &pfs.BranchInfo{}
+		if err := get(branch.Name, branchInfo); err != nil {
+			if col.IsErrNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if branchInfo.Head != nil {
+			heads[branchInfo.Head.ID] = true
+		}
+	}
+	return heads, nil
+}
+
 // createBranch creates a new branch or updates an existing branch (must be one
 // or the other). Most importantly, it sets 'branch.DirectProvenance' to
 // 'provenance' and then for all (downstream) branches, restores the invariant:
@@ -1563,11 +1954,16 @@ func (d *driver) deleteCommit(pachClient *client.APIClient, userCommit *pfs.Comm
 //
 // This invariant is assumed to hold for all branches upstream of 'branch', but not
 // for 'branch' itself once 'b.Provenance' has been set.
-func (d *driver) createBranch(pachClient *client.APIClient, branch *pfs.Branch, commit *pfs.Commit, provenance []*pfs.Branch) error {
+//
+// 'branch's trigger is replaced with 'trigger'.
+func (d *driver) createBranch(pachClient *client.APIClient, branch *pfs.Branch, commit *pfs.Commit, provenance []*pfs.Branch, trigger *pfs.Trigger) error {
 	ctx := pachClient.Ctx()
 	if err := d.checkIsAuthorized(pachClient, branch.Repo, auth.Scope_WRITER); err != nil {
 		return err
 	}
+	if err := d.validateTrigger(pachClient, branch, provenance, trigger); err != nil {
+		return err
+	}
 	// Validate request. The request must do exactly one of:
 	// 1) updating 'branch's provenance (commit is nil OR commit == branch)
 	// 2) re-pointing 'branch' at a new commit
@@ -1578,6 +1974,11 @@ func (d *driver) createBranch(pachClient *client.APIClient, branch *pfs.Branch,
 			return fmt.Errorf("cannot point branch \"%s\" at target commit \"%s/%s\" without clearing its provenance",
 				branch.Name, commit.Repo.Name, commit.ID)
 		}
+		if !sameTarget {
+			if err := d.checkBranchProtection(pachClient, client.NewCommit(branch.Repo.Name, branch.Name)); err != nil {
+				return err
+			}
+		}
 	}
 
 	_, err := col.NewSTM(ctx, d.etcdClient, func(stm col.STM) error {
@@ -1602,6 +2003,7 @@ func (d *driver) createBranch(pachClient *client.APIClient, branch *pfs.Branch,
 			branchInfo.Name = branch.Name // set in case 'branch' is new
 			branchInfo.Branch = branch
 			branchInfo.Head = commit
+			branchInfo.Trigger = trigger
 			branchInfo.DirectProvenance = nil
 			for _, provBranch := range provenance {
 				add(&branchInfo.DirectProvenance, provBranch)
@@ -1704,14 +2106,136 @@ func (d *driver) listBranch(pachClient *client.APIClient, repo *pfs.Repo) ([]*pf
 	return result, nil
 }
 
-func (d *driver) deleteBranch(pachClient *client.APIClient, branch *pfs.Branch, force bool) error {
-	if err := d.checkIsAuthorized(pachClient, branch.Repo, auth.Scope_WRITER); err != nil {
+// deleteBranch deletes 'branch', and if 'cascade' is set, the branches
+// downstream of it. If 'deleteCommits' is set, the commits that are only
+// reachable from the deleted branches are then deleted too.
+func (d *driver) deleteBranch(pachClient *client.APIClient, branch *pfs.Branch, force bool, cascade bool, deleteCommits bool) error {
+	branches, err := d.branchesToDelete(pachClient, branch, cascade)
+	if err != nil {
 		return err
 	}
-	_, err := col.NewSTM(pachClient.Ctx(), d.etcdClient, func(stm col.STM) error {
-		return d.deleteBranchSTM(stm, branch, force)
-	})
-	return err
+	for _, b := range branches {
+		if err := d.checkIsAuthorized(pachClient, b.Repo, auth.Scope_WRITER); err != nil {
+			return err
+		}
+		if err := d.checkBranchProtection(pachClient, client.NewCommit(b.Repo.Name, b.Name)); err != nil {
+			return err
+		}
+	}
+	var commits []*pfs.Commit
+	if deleteCommits {
+		if commits, err = d.exclusiveCommits(pachClient, branches); err != nil {
+			return err
+		}
+		// check before the branches are deleted, as their protection goes
+		// with them
+		if err := d.checkDeleteProtection(pachClient, commits); err != nil {
+			return err
+		}
+	}
+	if _, err := col.NewSTM(pachClient.Ctx(), d.etcdClient, func(stm col.STM) error {
+		for _, b := range branches {
+			if err := d.deleteBranchSTM(stm, b, force); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, commit := range commits {
+		// 'commit' may already have been deleted, downstream of another commit
+		if err := d.deleteCommit(pachClient, commit); err != nil && !isNotFoundErr(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// branchesToDelete returns the branches that deleting 'branch' deletes:
+// 'branch' and, if 'cascade' is set, its subvenance. Downstream branches come
+// first, so that each branch's subvenance has been deleted by the time that
+// it is.
+func (d *driver) branchesToDelete(pachClient *client.APIClient, branch *pfs.Branch, cascade bool) ([]*pfs.Branch, error) {
+	if !cascade {
+		return []*pfs.Branch{branch}, nil
+	}
+	branchInfo, err := d.inspectBranch(pachClient, branch)
+	if err != nil {
+		if col.IsErrNotFound(err) {
+			return []*pfs.Branch{branch}, nil
+		}
+		return nil, err
+	}
+	var subvInfos []*pfs.BranchInfo
+	for _, subvBranch := range branchInfo.Subvenance {
+		subvInfo, err := d.inspectBranch(pachClient, subvBranch)
+		if err != nil {
+			return nil, err
+		}
+		subvInfos = append(subvInfos, subvInfo)
+	}
+	// A branch has more provenance than the branches upstream of it
+	sort.SliceStable(subvInfos, func(i, j int) bool { return len(subvInfos[i].Provenance) > len(subvInfos[j].Provenance) })
+	var result []*pfs.Branch
+	for _, subvInfo := range subvInfos {
+		result = append(result, subvInfo.Branch)
+	}
+	return append(result, branch), nil
+}
+
+// exclusiveCommits returns the commits that are only reachable from the heads
+// of 'branches' (i.e. that aren't the head, or an ancestor of the head, of
+// another branch), newest first. Commits with provenance can't be deleted
+// directly, so they're left out (they're deleted along with the commits that
+// they're provenant on).
+func (d *driver) exclusiveCommits(pachClient *client.APIClient, branches []*pfs.Branch) ([]*pfs.Commit, error) {
+	deleted := make(map[string]bool) // "repo/branch" for each deleted branch
+	inRepos := make(map[string]bool)
+	var repos []string
+	for _, branch := range branches {
+		if !inRepos[branch.Repo.Name] {
+			inRepos[branch.Repo.Name] = true
+			repos = append(repos, branch.Repo.Name)
+		}
+		deleted[path.Join(branch.Repo.Name, branch.Name)] = true
+	}
+	var result []*pfs.Commit
+	for _, repo := range repos {
+		var heads, otherHeads []*pfs.Commit
+		branchInfo := &pfs.BranchInfo{}
+		if err := d.branches(repo).ReadOnly(pachClient.Ctx()).List(branchInfo, col.DefaultOptions, func(string) error {
+			if branchInfo.Head == nil {
+				return nil
+			}
+			if deleted[path.Join(repo, branchInfo.Branch.Name)] {
+				heads = append(heads, branchInfo.Head)
+			} else {
+				otherHeads = append(otherHeads, branchInfo.Head)
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		// walk back from each head, until reaching a commit that has already
+		// been seen. The commits reached from 'otherHeads' are kept.
+		seen := make(map[string]bool)
+		commits := d.commits(repo).ReadOnly(pachClient.Ctx())
+		for i, head := range append(otherHeads, heads...) {
+			for commit := head; commit != nil && !seen[commit.ID]; {
+				seen[commit.ID] = true
+				commitInfo := &pfs.CommitInfo{}
+				if err := commits.Get(commit.ID, commitInfo); err != nil {
+					return nil, err
+				}
+				if i >= len(otherHeads) && len(commitInfo.Provenance) == 0 {
+					result = append(result, commit)
+				}
+				commit = commitInfo.ParentCommit
+			}
+		}
+	}
+	return result, nil
 }
 
 func (d *driver) deleteBranchSTM(stm col.STM, branch *pfs.Branch, force bool) error {
@@ -1797,6 +2321,9 @@ func (d *driver) putFiles(pachClient *client.APIClient, s *putFileServer) error
 	if !uuid.IsUUIDWithoutDashes(commit.ID) {
 		branch = commit.ID
 	}
+	if err := d.checkBranchProtection(pachClient, client.NewCommit(commit.Repo.Name, commit.ID)); err != nil {
+		return err
+	}
 	commitInfo, err := d.inspectCommit(pachClient, commit, pfs.CommitState_STARTED)
 	if err != nil {
 		if (!isNotFoundErr(err) && !isNoHeadErr(err)) || branch == "" {
@@ -1816,11 +2343,31 @@ func (d *driver) putFiles(pachClient *client.APIClient, s *putFileServer) error
 	var putFileRecords []*pfs.PutFileRecords
 	var mu sync.Mutex
 	if err := forEachPutFile(s, func(req *pfs.PutFileRequest, r io.Reader) error {
+		if req.Tar {
+			tarFiles, tarRecords, err := d.putFileTar(pachClient, req, r)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for i, file := range tarFiles {
+				files = append(files, file)
+				putFilePaths = append(putFilePaths, file.Path)
+				putFileRecords = append(putFileRecords, tarRecords[i])
+			}
+			return nil
+		}
+		if len(req.Metadata) > 0 && req.Delimiter != pfs.Delimiter_NONE && !req.AppendRecords {
+			return fmt.Errorf("metadata can't be set on files that are split with a delimiter")
+		}
 		records, err := d.putFile(pachClient, req.File, req.Delimiter, req.TargetFileDatums,
-			req.TargetFileBytes, req.HeaderRecords, req.OverwriteIndex, r)
+			req.TargetFileBytes, req.HeaderRecords, req.OverwriteIndex, req.AppendRecords, r)
 		if err != nil {
 			return err
 		}
+		if len(req.Metadata) > 0 && len(records.Records) > 0 {
+			records.Records[0].Metadata = req.Metadata
+		}
 		mu.Lock()
 		defer mu.Unlock()
 		files = append(files, req.File)
@@ -1830,24 +2377,56 @@ func (d *driver) putFiles(pachClient *client.APIClient, s *putFileServer) error
 	}); err != nil {
 		return err
 	}
+	// deduplicated is the size of the data written that was already in
+	// object storage
+	var size, deduplicated uint64
+	for _, records := range putFileRecords {
+		for _, record := range records.Records {
+			size += uint64(record.SizeBytes)
+			if record.Deduplicated {
+				deduplicated += uint64(record.SizeBytes)
+			}
+		}
+	}
+	if err := d.checkPutFileQuota(pachClient, commit.Repo, size); err != nil {
+		return err
+	}
 	if oneOff {
 		// oneOff puts only work on branches, so we know branch != "". We pass
 		// a commit with no ID, that ID will be filled in with the head of
 		// branch (if it exists).
-		_, err := d.makeCommit(pachClient, "", client.NewCommit(commit.Repo.Name, ""), branch, nil, nil, putFilePaths, putFileRecords, "")
-		return err
-	}
-	for i, file := range files {
-		if err := d.upsertPutFileRecords(pachClient, file, putFileRecords[i]); err != nil {
+		if _, err := d.makeCommit(pachClient, "", client.NewCommit(commit.Repo.Name, ""), branch, nil, nil, putFilePaths, putFileRecords, "", nil); err != nil {
 			return err
 		}
+	} else {
+		for i, file := range files {
+			if err := d.upsertPutFileRecords(pachClient, file, putFileRecords[i]); err != nil {
+				return err
+			}
+		}
 	}
-	return nil
+	return d.addDedupSavings(pachClient, commit.Repo, deduplicated)
+}
+
+// addDedupSavings adds 'size' to the DedupSavingsBytes of 'repo', after
+// 'size' bytes that were already in object storage were written to it
+func (d *driver) addDedupSavings(pachClient *client.APIClient, repo *pfs.Repo, size uint64) error {
+	if size == 0 {
+		return nil
+	}
+	_, err := col.NewSTM(pachClient.Ctx(), d.etcdClient, func(stm col.STM) error {
+		repoInfo := &pfs.RepoInfo{}
+		return d.repos.ReadWrite(stm).Update(repo.Name, repoInfo, func() error {
+			repoInfo.DedupSavingsBytes += size
+			return nil
+		})
+	})
+	return err
 }
 
 func (d *driver) putFile(pachClient *client.APIClient, file *pfs.File, delimiter pfs.Delimiter,
 	targetFileDatums, targetFileBytes, headerRecords int64, overwriteIndex *pfs.OverwriteIndex,
-	reader io.Reader) (*pfs.PutFileRecords, error) {
+	appendRecords bool, reader io.Reader) (*pfs.PutFileRecords, error) {
 	if err := d.checkIsAuthorized(pachClient, file.Commit.Repo, auth.Scope_WRITER); err != nil {
 		return nil, err
 	}
@@ -1856,6 +2435,22 @@ func (d *driver) putFile(pachClient *client.APIClient, file *pfs.File, delimiter
 	if hasPutFileOptions && delimiter == pfs.Delimiter_NONE {
 		return nil, fmt.Errorf("cannot set split options--targetFileBytes, targetFileDatums, or headerRecords--with delimiter == NONE, split disabled")
 	}
+	if appendRecords {
+		switch {
+		case delimiter == pfs.Delimiter_NONE:
+			return nil, fmt.Errorf("cannot append records with delimiter == NONE")
+		case delimiter == pfs.Delimiter_SQL:
+			return nil, fmt.Errorf("cannot append records with delimiter == SQL, as SQL data can't be split without a header and footer")
+		case hasPutFileOptions:
+			return nil, fmt.Errorf("cannot set split options--targetFileBytes, targetFileDatums, or headerRecords--when appending records")
+		}
+		// Write the data as a single file, but fail if it doesn't consist of
+		// complete records
+		validator := newValidatingReader(reader, delimiter)
+		defer validator.Close()
+		reader = validator
+		delimiter = pfs.Delimiter_NONE
+	}
 	records := &pfs.PutFileRecords{}
 	if overwriteIndex != nil && overwriteIndex.Index == 0 {
 		records.Tombstone = true
@@ -1863,30 +2458,40 @@ func (d *driver) putFile(pachClient *client.APIClient, file *pfs.File, delimiter
 	if err := hashtree.ValidatePath(file.Path); err != nil {
 		return nil, err
 	}
+	compression, err := d.fileCompression(pachClient, file.Commit.Repo)
+	if err != nil {
+		return nil, err
+	}
+	encryption, err := d.fileEncryption(pachClient, file.Commit.Repo)
+	if err != nil {
+		return nil, err
+	}
 
 	if delimiter == pfs.Delimiter_NONE {
-		objects, size, err := pachClient.PutObjectSplit(reader)
+		sha256Hash, md5Hash := sha256.New(), md5.New()
+		objects, _, err := pachClient.PutObjectSplitEncrypted(io.TeeReader(reader, io.MultiWriter(sha256Hash, md5Hash)), compression, encryption)
 		if err != nil {
 			return nil, err
 		}
+		if len(objects.SizesBytes) != len(objects.Objects) || len(objects.Deduplicated) != len(objects.Objects) {
+			return nil, fmt.Errorf("malformed PutObjectSplit response: %d objects, but %d sizes", len(objects.Objects), len(objects.SizesBytes))
+		}
 
-		// Here we use the invariant that every one but the last object
-		// should have a size of ChunkSize.
-		for i, object := range objects {
+		for i, object := range objects.Objects {
 			record := &pfs.PutFileRecord{
-				ObjectHash: object.Hash,
+				ObjectHash:   object.Hash,
+				SizeBytes:    objects.SizesBytes[i],
+				Deduplicated: objects.Deduplicated[i],
 			}
 
-			if size > pfs.ChunkSize {
-				record.SizeBytes = pfs.ChunkSize
-			} else {
-				record.SizeBytes = size
-			}
-			size -= pfs.ChunkSize
-
-			// The first record takes care of the overwriting
-			if i == 0 && overwriteIndex != nil && overwriteIndex.Index != 0 {
-				record.OverwriteIndex = overwriteIndex
+			// The first record takes care of the overwriting, and holds the
+			// checksums of the data written
+			if i == 0 {
+				if overwriteIndex != nil && overwriteIndex.Index != 0 {
+					record.OverwriteIndex = overwriteIndex
+				}
+				record.ContentSha256 = sha256Hash.Sum(nil)
+				record.ContentMd5 = md5Hash.Sum(nil)
 			}
 
 			records.Records = append(records.Records, record)
@@ -1931,6 +2536,8 @@ func (d *driver) putFile(pachClient *client.APIClient, file *pfs.File, delimiter
 				value = jsonValue
 			case pfs.Delimiter_LINE:
 				value, err = bufioR.ReadBytes('\n')
+			case pfs.Delimiter_PROTO:
+				value, err = readProtoRecord(bufioR)
 			case pfs.Delimiter_SQL:
 				value, err = sqlReader.ReadRow()
 				if err == io.EOF {
@@ -1989,7 +2596,7 @@ func (d *driver) putFile(pachClient *client.APIClient, file *pfs.File, delimiter
 					eg.Go(func() error {
 						defer putObjectLimiter.Release()
 						defer d.memoryLimiter.Release(_bufferLen)
-						object, size, err := pachClient.PutObject(_buffer)
+						object, size, err := pachClient.PutObjectEncrypted(_buffer, compression, encryption)
 						if err != nil {
 							return err
 						}
@@ -2025,7 +2632,7 @@ func (d *driver) putFile(pachClient *client.APIClient, file *pfs.File, delimiter
 				putObjectLimiter.Acquire()
 				eg.Go(func() error {
 					defer putObjectLimiter.Release()
-					object, size, err := pachClient.PutObject(bytes.NewReader(value))
+					object, size, err := pachClient.PutObjectEncrypted(bytes.NewReader(value), compression, encryption)
 					if err != nil {
 						return err
 					}
@@ -2106,7 +2713,7 @@ func headerDirToPutFileRecords(tree hashtree.HashTree, path string, node *hashtr
 	return pfr, nil // TODO(msteffen) put something real here
 }
 
-func (d *driver) copyFile(pachClient *client.APIClient, src *pfs.File, dst *pfs.File, overwrite bool) error {
+func (d *driver) copyFile(pachClient *client.APIClient, src *pfs.File, dst *pfs.File, overwrite bool) (retErr error) {
 	if err := d.checkIsAuthorized(pachClient, src.Commit.Repo, auth.Scope_READER); err != nil {
 		return err
 	}
@@ -2116,6 +2723,9 @@ func (d *driver) copyFile(pachClient *client.APIClient, src *pfs.File, dst *pfs.
 	if err := hashtree.ValidatePath(dst.Path); err != nil {
 		return err
 	}
+	if err := d.checkBranchProtection(pachClient, client.NewCommit(dst.Commit.Repo.Name, dst.Commit.ID)); err != nil {
+		return err
+	}
 	branch := ""
 	if !uuid.IsUUIDWithoutDashes(dst.Commit.ID) {
 		branch = dst.Commit.ID
@@ -2143,16 +2753,44 @@ func (d *driver) copyFile(pachClient *client.APIClient, src *pfs.File, dst *pfs.
 			records = append(records, &pfs.PutFileRecords{Tombstone: true})
 		}
 	}
-	srcTree, err := d.getTreeForFile(pachClient, src)
-	if err != nil {
-		return err
-	}
 	// This is necessary so we can call filepath.Rel below
 	if !strings.HasPrefix(src.Path, "/") {
 		src.Path = "/" + src.Path
 	}
+	srcCommitInfo, err := d.inspectCommit(pachClient, src.Commit, pfs.CommitState_STARTED)
+	if err != nil {
+		return err
+	}
+	// walkSrc calls 'f' on each node under src.Path. Output commits store their
+	// files in several hashtrees (see getTrees), while other commits have one.
+	var srcTree hashtree.HashTree
+	var walkSrc func(f func(string, *hashtree.NodeProto) error) error
+	if srcCommitInfo.Finished != nil && srcCommitInfo.Tree == nil && len(srcCommitInfo.Trees) > 0 {
+		rs, err := d.getTrees(pachClient, srcCommitInfo, src.Path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			for _, r := range rs {
+				if err := r.Close(); err != nil && retErr == nil {
+					retErr = err
+				}
+			}
+		}()
+		walkSrc = func(f func(string, *hashtree.NodeProto) error) error {
+			return hashtree.Walk(rs, src.Path, f)
+		}
+	} else {
+		srcTree, err = d.getTreeForFile(pachClient, src)
+		if err != nil {
+			return err
+		}
+		walkSrc = func(f func(string, *hashtree.NodeProto) error) error {
+			return srcTree.Walk(src.Path, f)
+		}
+	}
 	var eg errgroup.Group
-	if err := srcTree.Walk(src.Path, func(walkPath string, node *hashtree.NodeProto) error {
+	if err := walkSrc(func(walkPath string, node *hashtree.NodeProto) error {
 		relPath, err := filepath.Rel(src.Path, walkPath)
 		if err != nil {
 			return fmt.Errorf("error from filepath.Rel (likely a bug): %v", err)
@@ -2161,6 +2799,9 @@ func (d *driver) copyFile(pachClient *client.APIClient, src *pfs.File, dst *pfs.
 		// Populate 'record' appropriately for this node (or skip it)
 		record := &pfs.PutFileRecords{}
 		if node.DirNode != nil && node.DirNode.Shared != nil {
+			if srcTree == nil {
+				return fmt.Errorf("cannot copy %q: headers and footers are only supported in input commits", walkPath)
+			}
 			var err error
 			record, err = headerDirToPutFileRecords(srcTree, walkPath, node)
 			if err != nil {
@@ -2187,6 +2828,23 @@ func (d *driver) copyFile(pachClient *client.APIClient, src *pfs.File, dst *pfs.
 					ObjectHash: object.Hash,
 				})
 			}
+			// Files in output commits refer to blocks rather than objects, so
+			// create objects that refer to the same data
+			for _, blockRef := range node.FileNode.BlockRefs {
+				object, err := blockRefToObject(pachClient, blockRef)
+				if err != nil {
+					return err
+				}
+				record.Records = append(record.Records, &pfs.PutFileRecord{
+					SizeBytes:  int64(pfsserver.BlockRefSize(blockRef)),
+					ObjectHash: object.Hash,
+				})
+			}
+			if len(record.Records) > 0 {
+				record.Records[0].ContentSha256 = node.FileNode.ContentSha256
+				record.Records[0].ContentMd5 = node.FileNode.ContentMd5
+				record.Records[0].Metadata = node.FileNode.Metadata
+			}
 		}
 
 		// Either upsert 'record' to etcd (if 'dst' is in an open commit) or add it
@@ -2208,12 +2866,38 @@ func (d *driver) copyFile(pachClient *client.APIClient, src *pfs.File, dst *pfs.
 	}
 	// dst is finished => all PutFileRecords are in 'records'--put in a new commit
 	if !dstIsOpenCommit {
-		_, err = d.makeCommit(pachClient, "", client.NewCommit(dst.Commit.Repo.Name, ""), branch, nil, nil, paths, records, "")
+		_, err = d.makeCommit(pachClient, "", client.NewCommit(dst.Commit.Repo.Name, ""), branch, nil, nil, paths, records, "", nil)
 		return err
 	}
 	return nil
 }
 
+// blockRefToObject creates an object whose contents are the data at
+// 'blockRef'. The object is named by the block ref rather than by the hash of
+// its contents (which would require reading it), so copying the same block ref
+// again reuses the object.
+func blockRefToObject(pachClient *client.APIClient, blockRef *pfs.BlockRef) (*pfs.Object, error) {
+	hash := pfs.NewHash()
+	fmt.Fprintf(hash, "block:%s:%d-%d", blockRef.Block.Hash, blockRef.Range.Lower, blockRef.Range.Upper)
+	object := client.NewObject(pfs.EncodeHash(hash.Sum(nil)))
+	if _, err := pachClient.ObjectAPIClient.CreateObject(pachClient.Ctx(), &pfs.CreateObjectRequest{
+		Object:   object,
+		BlockRef: blockRef,
+	}); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// hasSingleTree returns true if the files in the commit described by
+// 'commitInfo' are read from a single hashtree (see getTreeForFile): commits
+// to input repos, and commits whose tree was built whole, such as those
+// created by BuildCommit during replication. The files in other output
+// commits are split across commitInfo.Trees (see getTrees).
+func hasSingleTree(commitInfo *pfs.CommitInfo) bool {
+	return commitInfo.Provenance == nil || commitInfo.Tree != nil
+}
+
 func (d *driver) getTreeForCommit(pachClient *client.APIClient, commit *pfs.Commit) (hashtree.HashTree, error) {
 	if commit == nil || commit.ID == "" {
 		t, err := hashtree.NewDBHashTree(d.storageRoot)
@@ -2427,7 +3111,7 @@ func (d *driver) getFile(pachClient *client.APIClient, file *pfs.File, offset in
 		return nil, err
 	}
 	// Handle commits to input repos
-	if commitInfo.Provenance == nil {
+	if hasSingleTree(commitInfo) {
 		tree, err := d.getTreeForFile(pachClient, client.NewFile(file.Commit.Repo.Name, file.Commit.ID, ""))
 		if err != nil {
 			return nil, err
@@ -2563,6 +3247,47 @@ func (d *driver) getFile(pachClient *client.APIClient, file *pfs.File, offset in
 	return grpcutil.NewStreamingBytesReader(getBlocksClient, nil), nil
 }
 
+// getFileURL returns a signed URL at which pachd's HTTP server serves 'file'
+// to anyone who has the URL, for 'ttl' (defaultFileURLTTL if 0)
+func (d *driver) getFileURL(pachClient *client.APIClient, file *pfs.File, ttl time.Duration) (*pfs.GetFileURLResponse, error) {
+	if err := d.checkIsAuthorized(pachClient, file.Commit.Repo, auth.Scope_READER); err != nil {
+		return nil, err
+	}
+	if ttl == 0 {
+		ttl = defaultFileURLTTL
+	}
+	if ttl < 0 || ttl > maxFileURLTTL {
+		return nil, fmt.Errorf("file URLs must be valid for between 0 and %v, but %v was requested", maxFileURLTTL, ttl)
+	}
+	// sign the commit's ID rather than a branch, so that the URL keeps
+	// serving the same file if the branch moves. Files in open commits may
+	// still change, so they can't be signed.
+	commitInfo, err := d.inspectCommit(pachClient, file.Commit, pfs.CommitState_STARTED)
+	if err != nil {
+		return nil, err
+	}
+	if commitInfo.Finished == nil {
+		return nil, fmt.Errorf("cannot create a URL for a file in commit \"%s/%s\" because it is not finished", commitInfo.Commit.Repo.Name, commitInfo.Commit.ID)
+	}
+	file = client.NewFile(file.Commit.Repo.Name, commitInfo.Commit.ID, file.Path)
+	if _, err := d.inspectFile(pachClient, file); err != nil {
+		return nil, err
+	}
+	key, err := pach_http.GetSigningKey(pachClient.Ctx(), d.etcdClient, d.prefix)
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().Add(ttl)
+	expiresProto, err := types.TimestampProto(expires)
+	if err != nil {
+		return nil, err
+	}
+	return &pfs.GetFileURLResponse{
+		Path:    pach_http.SignFileURL(key, file.Commit.Repo.Name, file.Commit.ID, file.Path, expires),
+		Expires: expiresProto,
+	}, nil
+}
+
 // If full is false, exclude potentially large fields such as `Objects`
 // and `Children`
 func nodeToFileInfo(ci *pfs.CommitInfo, path string, node *hashtree.NodeProto, full bool) *pfs.FileInfo {
@@ -2577,6 +3302,9 @@ func nodeToFileInfo(ci *pfs.CommitInfo, path string, node *hashtree.NodeProto, f
 	}
 	if node.FileNode != nil {
 		fileInfo.FileType = pfs.FileType_FILE
+		fileInfo.ContentSha256 = node.FileNode.ContentSha256
+		fileInfo.ContentMd5 = node.FileNode.ContentMd5
+		fileInfo.Metadata = node.FileNode.Metadata
 		if full {
 			fileInfo.Objects = node.FileNode.Objects
 			fileInfo.BlockRefs = node.FileNode.BlockRefs
@@ -2653,7 +3381,7 @@ func (d *driver) inspectFile(pachClient *client.APIClient, file *pfs.File) (fi *
 		return nil, err
 	}
 	// Handle commits to input repos
-	if commitInfo.Provenance == nil {
+	if hasSingleTree(commitInfo) {
 		tree, err := d.getTreeForFile(pachClient, file)
 		if err != nil {
 			return nil, err
@@ -2689,7 +3417,10 @@ func (d *driver) inspectFile(pachClient *client.APIClient, file *pfs.File) (fi *
 	return nodeToFileInfo(commitInfo, file.Path, node, true), nil
 }
 
-func (d *driver) listFile(pachClient *client.APIClient, file *pfs.File, full bool, history int64, f func(*pfs.FileInfo) error) (retErr error) {
+// listFile calls f with the files under 'file' (a directory, file or glob
+// pattern). If 'cursor' is set, the files whose paths sort at or before it are
+// skipped, and where possible never read from the hashtree.
+func (d *driver) listFile(pachClient *client.APIClient, file *pfs.File, full bool, history int64, filter *pfs.FileFilter, cursor string, f func(*pfs.FileInfo) error) (retErr error) {
 	if err := d.checkIsAuthorized(pachClient, file.Commit.Repo, auth.Scope_READER); err != nil {
 		return err
 	}
@@ -2697,19 +3428,40 @@ func (d *driver) listFile(pachClient *client.APIClient, file *pfs.File, full boo
 	if err != nil {
 		return err
 	}
+	if filter != nil {
+		match, err := d.fileFilter(pachClient, filter)
+		if err != nil {
+			return err
+		}
+		unfiltered := f
+		f = func(fi *pfs.FileInfo) error {
+			ok, err := match(fi)
+			if err != nil || !ok {
+				return err
+			}
+			return unfiltered(fi)
+		}
+	}
+	// files are listed in order, so the files up to 'cursor' are skipped
+	skip := func(p string) bool {
+		return cursor != "" && path.Join("/", p) <= cursor
+	}
 	g, err := globlib.Compile(file.Path, '/')
 	if err != nil {
 		// TODO this should be a MalformedGlob error like the hashtree returns
 		return err
 	}
 	// Handle commits to input repos
-	if commitInfo.Provenance == nil {
+	if hasSingleTree(commitInfo) {
 		tree, err := d.getTreeForFile(pachClient, client.NewFile(file.Commit.Repo.Name, file.Commit.ID, ""))
 		if err != nil {
 			return err
 		}
 		return tree.Glob(file.Path, func(rootPath string, rootNode *hashtree.NodeProto) error {
 			if rootNode.DirNode == nil {
+				if skip(rootPath) {
+					return nil
+				}
 				if history != 0 {
 					return d.fileHistory(pachClient, client.NewFile(file.Commit.Repo.Name, file.Commit.ID, rootPath), history, f)
 				}
@@ -2719,9 +3471,14 @@ func (d *driver) listFile(pachClient *client.APIClient, file *pfs.File, full boo
 				}
 				return f(fi)
 			}
-			return tree.List(rootPath, func(node *hashtree.NodeProto) error {
+			// Seek past the children at or before 'cursor' rather than reading them
+			var after string
+			if cursor != "" && path.Dir(cursor) == path.Join("/", rootPath) {
+				after = path.Base(cursor)
+			}
+			return tree.ListAfter(rootPath, after, func(node *hashtree.NodeProto) error {
 				path := filepath.Join(rootPath, node.Name)
-				if g.Match(path) {
+				if g.Match(path) || skip(path) {
 					// Don't return the file now, it will be returned later by Glob
 					return nil
 				}
@@ -2755,6 +3512,9 @@ func (d *driver) listFile(pachClient *client.APIClient, file *pfs.File, full boo
 		}
 	}()
 	return hashtree.List(rs, file.Path, func(path string, node *hashtree.NodeProto) error {
+		if skip(path) {
+			return nil
+		}
 		if history != 0 {
 			return d.fileHistory(pachClient, client.NewFile(file.Commit.Repo.Name, file.Commit.ID, path), history, f)
 		}
@@ -2762,6 +3522,97 @@ func (d *driver) listFile(pachClient *client.APIClient, file *pfs.File, full boo
 	})
 }
 
+// fileFilter returns a function that returns true if a FileInfo satisfies
+// 'filter'
+func (d *driver) fileFilter(pachClient *client.APIClient, filter *pfs.FileFilter) (func(*pfs.FileInfo) (bool, error), error) {
+	// sinceHash returns the hash of the file at 'path' in the ModifiedSince
+	// commit, if it exists there. The commit's tree is loaded once, here,
+	// rather than for each file that's filtered.
+	var sinceHash func(path string) ([]byte, bool, error)
+	if filter.ModifiedSince != nil {
+		var err error
+		if sinceHash, err = d.commitFileHashes(pachClient, filter.ModifiedSince); err != nil {
+			return nil, err
+		}
+	}
+	return func(fi *pfs.FileInfo) (bool, error) {
+		if filter.MinSizeBytes != 0 && fi.SizeBytes < filter.MinSizeBytes {
+			return false, nil
+		}
+		if filter.MaxSizeBytes != 0 && fi.SizeBytes > filter.MaxSizeBytes {
+			return false, nil
+		}
+		for k, v := range filter.Metadata {
+			if value, ok := fi.Metadata[k]; !ok || value != v {
+				return false, nil
+			}
+		}
+		if sinceHash != nil {
+			hash, ok, err := sinceHash(fi.File.Path)
+			if err != nil || !ok {
+				return err == nil, err
+			}
+			return !bytes.Equal(hash, fi.Hash), nil
+		}
+		return true, nil
+	}, nil
+}
+
+// commitFileHashes returns a function that looks up the hashes of files in
+// 'commit'. Commits with a single tree are read through the tree cache (see
+// getTreeForCommit); the hashes in an output commit's trees are read once
+// and kept in memory.
+func (d *driver) commitFileHashes(pachClient *client.APIClient, commit *pfs.Commit) (_ func(path string) ([]byte, bool, error), retErr error) {
+	if err := d.checkIsAuthorized(pachClient, commit.Repo, auth.Scope_READER); err != nil {
+		return nil, err
+	}
+	commitInfo, err := d.inspectCommit(pachClient, commit, pfs.CommitState_STARTED)
+	if err != nil {
+		return nil, err
+	}
+	if hasSingleTree(commitInfo) {
+		tree, err := d.getTreeForFile(pachClient, client.NewFile(commit.Repo.Name, commitInfo.Commit.ID, ""))
+		if err != nil {
+			return nil, err
+		}
+		return func(path string) ([]byte, bool, error) {
+			node, err := tree.Get(path)
+			if err != nil {
+				if hashtree.Code(err) == hashtree.PathNotFound {
+					return nil, false, nil
+				}
+				return nil, false, err
+			}
+			return node.Hash, true, nil
+		}, nil
+	}
+	// an output commit with no trees (e.g. an open one) is empty
+	hashes := make(map[string][]byte)
+	if commitInfo.Trees != nil {
+		rs, err := d.getTrees(pachClient, commitInfo, "/")
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			for _, r := range rs {
+				if err := r.Close(); err != nil && retErr == nil {
+					retErr = err
+				}
+			}
+		}()
+		if err := hashtree.Walk(rs, "/", func(path string, node *hashtree.NodeProto) error {
+			hashes[path] = node.Hash
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return func(path string) ([]byte, bool, error) {
+		hash, ok := hashes[path]
+		return hash, ok, nil
+	}, nil
+}
+
 // fileHistory calls f with FileInfos for the file, starting with how it looked
 // at the referenced commit and then all past versions that are different.
 func (d *driver) fileHistory(pachClient *client.APIClient, file *pfs.File, history int64, f func(*pfs.FileInfo) error) error {
@@ -2797,7 +3648,7 @@ func (d *driver) fileHistory(pachClient *client.APIClient, file *pfs.File, histo
 	}
 }
 
-func (d *driver) walkFile(pachClient *client.APIClient, file *pfs.File, f func(*pfs.FileInfo) error) (retErr error) {
+func (d *driver) walkFile(pachClient *client.APIClient, file *pfs.File, full bool, f func(*pfs.FileInfo) error) (retErr error) {
 	if err := d.checkIsAuthorized(pachClient, file.Commit.Repo, auth.Scope_READER); err != nil {
 		return err
 	}
@@ -2806,13 +3657,13 @@ func (d *driver) walkFile(pachClient *client.APIClient, file *pfs.File, f func(*
 		return err
 	}
 	// Handle commits to input repos
-	if commitInfo.Provenance == nil {
+	if hasSingleTree(commitInfo) {
 		tree, err := d.getTreeForFile(pachClient, client.NewFile(file.Commit.Repo.Name, file.Commit.ID, file.Path))
 		if err != nil {
 			return err
 		}
 		return tree.Walk(file.Path, func(path string, node *hashtree.NodeProto) error {
-			fi, err := nodeToFileInfoHeaderFooter(commitInfo, path, node, tree, false)
+			fi, err := nodeToFileInfoHeaderFooter(commitInfo, path, node, tree, full)
 			if err != nil {
 				return err
 			}
@@ -2838,11 +3689,11 @@ func (d *driver) walkFile(pachClient *client.APIClient, file *pfs.File, f func(*
 		}
 	}()
 	return hashtree.Walk(rs, file.Path, func(path string, node *hashtree.NodeProto) error {
-		return f(nodeToFileInfo(commitInfo, path, node, false))
+		return f(nodeToFileInfo(commitInfo, path, node, full))
 	})
 }
 
-func (d *driver) globFile(pachClient *client.APIClient, commit *pfs.Commit, pattern string, f func(*pfs.FileInfo) error) (retErr error) {
+func (d *driver) globFile(pachClient *client.APIClient, commit *pfs.Commit, pattern string, patternType pfs.PatternType, f func(*pfs.FileInfo) error) (retErr error) {
 	if err := d.checkIsAuthorized(pachClient, commit.Repo, auth.Scope_READER); err != nil {
 		return err
 	}
@@ -2851,12 +3702,16 @@ func (d *driver) globFile(pachClient *client.APIClient, commit *pfs.Commit, patt
 		return err
 	}
 	// Handle commits to input repos
-	if commitInfo.Provenance == nil {
+	if hasSingleTree(commitInfo) {
 		tree, err := d.getTreeForFile(pachClient, client.NewFile(commit.Repo.Name, commit.ID, ""))
 		if err != nil {
 			return err
 		}
-		return tree.Glob(pattern, func(path string, node *hashtree.NodeProto) error {
+		glob := tree.Glob
+		if patternType == pfs.PatternType_REGEX {
+			glob = tree.GlobRegex
+		}
+		return glob(pattern, func(path string, node *hashtree.NodeProto) error {
 			fi, err := nodeToFileInfoHeaderFooter(commitInfo, path, node, tree, false)
 			if err != nil {
 				return err
@@ -2872,8 +3727,11 @@ func (d *driver) globFile(pachClient *client.APIClient, commit *pfs.Commit, patt
 		return nil
 	}
 	var rs []io.ReadCloser
-	// Handles the case when looking for a specific file/directory
-	if !hashtree.IsGlob(pattern) {
+	if patternType == pfs.PatternType_REGEX {
+		// only the parts of the trees under the regex's literal prefix are read
+		rs, err = d.getTrees(pachClient, commitInfo, hashtree.RegexLiteralPrefix(pattern))
+	} else if !hashtree.IsGlob(pattern) {
+		// Handles the case when looking for a specific file/directory
 		rs, err = d.getTree(pachClient, commitInfo, pattern)
 	} else {
 		rs, err = d.getTrees(pachClient, commitInfo, pattern)
@@ -2888,7 +3746,11 @@ func (d *driver) globFile(pachClient *client.APIClient, commit *pfs.Commit, patt
 			}
 		}
 	}()
-	return hashtree.Glob(rs, pattern, func(rootPath string, rootNode *hashtree.NodeProto) error {
+	glob := hashtree.Glob
+	if patternType == pfs.PatternType_REGEX {
+		glob = hashtree.GlobRegex
+	}
+	return glob(rs, pattern, func(rootPath string, rootNode *hashtree.NodeProto) error {
 		return f(nodeToFileInfo(commitInfo, rootPath, rootNode, false))
 	})
 }
@@ -2956,10 +3818,104 @@ func (d *driver) diffFile(pachClient *client.APIClient, newFile *pfs.File, oldFi
 	return newFileInfos, oldFileInfos, nil
 }
 
+// relPath returns 'p' relative to 'root', so that the files in a diff of two
+// different paths can be matched up
+func relPath(root, p string) string {
+	return strings.TrimPrefix(path.Join("/", p), strings.TrimSuffix(path.Join("/", root), "/"))
+}
+
+// detectRenames returns the renames among the files in a diff of 'newRoot'
+// and 'oldRoot': files in 'oldFileInfos' that are missing from 'newFileInfos'
+// and have the same contents as a file in 'newFileInfos' that is missing from
+// 'oldFileInfos'. Empty files are never considered renamed.
+func detectRenames(newRoot, oldRoot string, newFileInfos, oldFileInfos []*pfs.FileInfo) []*pfs.FileRename {
+	newPaths := make(map[string]bool)
+	for _, fi := range newFileInfos {
+		newPaths[relPath(newRoot, fi.File.Path)] = true
+	}
+	oldPaths := make(map[string]bool)
+	for _, fi := range oldFileInfos {
+		oldPaths[relPath(oldRoot, fi.File.Path)] = true
+	}
+	// added maps the hash of each added file to the added files with it
+	added := make(map[string][]*pfs.FileInfo)
+	for _, fi := range newFileInfos {
+		if fi.FileType == pfs.FileType_FILE && fi.SizeBytes > 0 && !oldPaths[relPath(newRoot, fi.File.Path)] {
+			added[string(fi.Hash)] = append(added[string(fi.Hash)], fi)
+		}
+	}
+	var renames []*pfs.FileRename
+	for _, fi := range oldFileInfos {
+		if fi.FileType != pfs.FileType_FILE || fi.SizeBytes == 0 || newPaths[relPath(oldRoot, fi.File.Path)] {
+			continue
+		}
+		if candidates := added[string(fi.Hash)]; len(candidates) > 0 {
+			renames = append(renames, &pfs.FileRename{NewFile: candidates[0], OldFile: fi})
+			added[string(fi.Hash)] = candidates[1:]
+		}
+	}
+	return renames
+}
+
+// diffFileContents returns the diffs of the contents of the files in a diff
+// of 'newRoot' and 'oldRoot' that are in both 'newFileInfos' and
+// 'oldFileInfos' (i.e. that changed), skipping files larger than 'maxBytes'
+func (d *driver) diffFileContents(pachClient *client.APIClient, newRoot, oldRoot string, newFileInfos, oldFileInfos []*pfs.FileInfo, maxBytes int64) ([]*pfs.ContentDiff, error) {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxContentDiffBytes
+	}
+	oldFiles := make(map[string]*pfs.FileInfo)
+	for _, fi := range oldFileInfos {
+		oldFiles[relPath(oldRoot, fi.File.Path)] = fi
+	}
+	var contentDiffs []*pfs.ContentDiff
+	for _, newFileInfo := range newFileInfos {
+		oldFileInfo, ok := oldFiles[relPath(newRoot, newFileInfo.File.Path)]
+		if !ok || newFileInfo.FileType != pfs.FileType_FILE || oldFileInfo.FileType != pfs.FileType_FILE ||
+			newFileInfo.SizeBytes > uint64(maxBytes) || oldFileInfo.SizeBytes > uint64(maxBytes) {
+			continue
+		}
+		newContent, err := d.readFile(pachClient, newFileInfo.File)
+		if err != nil {
+			return nil, err
+		}
+		oldContent, err := d.readFile(pachClient, oldFileInfo.File)
+		if err != nil {
+			return nil, err
+		}
+		oldName, newName := "a"+path.Join("/", oldFileInfo.File.Path), "b"+path.Join("/", newFileInfo.File.Path)
+		contentDiff := &pfs.ContentDiff{
+			NewPath: newFileInfo.File.Path,
+			OldPath: oldFileInfo.File.Path,
+		}
+		if textdiff.IsText(newContent) && textdiff.IsText(oldContent) {
+			contentDiff.UnifiedDiff = textdiff.Unified(oldName, newName, string(oldContent), string(newContent))
+		} else if !bytes.Equal(newContent, oldContent) {
+			contentDiff.UnifiedDiff = fmt.Sprintf("Binary files %s and %s differ\n", oldName, newName)
+		}
+		if contentDiff.UnifiedDiff != "" {
+			contentDiffs = append(contentDiffs, contentDiff)
+		}
+	}
+	return contentDiffs, nil
+}
+
+// readFile returns the contents of 'file'
+func (d *driver) readFile(pachClient *client.APIClient, file *pfs.File) ([]byte, error) {
+	r, err := d.getFile(pachClient, file, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
 func (d *driver) deleteFile(pachClient *client.APIClient, file *pfs.File) error {
 	if err := d.checkIsAuthorized(pachClient, file.Commit.Repo, auth.Scope_WRITER); err != nil {
 		return err
 	}
+	if err := d.checkBranchProtection(pachClient, client.NewCommit(file.Commit.Repo.Name, file.Commit.ID)); err != nil {
+		return err
+	}
 	branch := ""
 	if !uuid.IsUUIDWithoutDashes(file.Commit.ID) {
 		branch = file.Commit.ID
@@ -2972,7 +3928,7 @@ func (d *driver) deleteFile(pachClient *client.APIClient, file *pfs.File) error
 		if branch == "" {
 			return pfsserver.ErrCommitFinished{file.Commit}
 		}
-		_, err := d.makeCommit(pachClient, "", client.NewCommit(file.Commit.Repo.Name, ""), branch, nil, nil, []string{file.Path}, []*pfs.PutFileRecords{&pfs.PutFileRecords{Tombstone: true}}, "")
+		_, err := d.makeCommit(pachClient, "", client.NewCommit(file.Commit.Repo.Name, ""), branch, nil, nil, []string{file.Path}, []*pfs.PutFileRecords{&pfs.PutFileRecords{Tombstone: true}}, "", nil)
 		return err
 	}
 	return d.upsertPutFileRecords(pachClient, file, &pfs.PutFileRecords{Tombstone: true})
@@ -3050,7 +4006,22 @@ func (d *driver) applyWrite(key string, records *pfs.PutFileRecords, tree hashtr
 		if len(records.Records) == 0 {
 			return nil
 		}
+		// checksummed is the first record of the PutFile that wrote the file's
+		// contents, if its contents were written by a single PutFile
+		var checksummed *pfs.PutFileRecord
 		for _, record := range records.Records {
+			if record.ContentSha256 != nil {
+				// a PutFile's checksums describe the file only if the file was
+				// empty before it
+				checksummed = nil
+				fileNode, err := tree.Get(key)
+				if err != nil && hashtree.Code(err) != hashtree.PathNotFound {
+					return err
+				}
+				if fileNode == nil || fileNode.SubtreeSize == 0 {
+					checksummed = record
+				}
+			}
 			sizeMap[record.ObjectHash] = record.SizeBytes
 			if record.OverwriteIndex != nil {
 				// Computing size delta
@@ -3071,6 +4042,16 @@ func (d *driver) applyWrite(key string, records *pfs.PutFileRecords, tree hashtr
 					return err
 				}
 			}
+			if len(record.Metadata) > 0 {
+				if err := tree.SetFileMetadata(key, record.Metadata); err != nil {
+					return err
+				}
+			}
+		}
+		if checksummed != nil {
+			if err := tree.SetFileChecksums(key, checksummed.ContentSha256, checksummed.ContentMd5); err != nil {
+				return err
+			}
 		}
 	} else {
 		nodes, err := tree.ListAll(key)
@@ -3310,6 +4291,60 @@ func (s *putFileServer) Peek() (*pfs.PutFileRequest, error) {
 	return req, nil
 }
 
+// getURL fetches the http:// or https:// URL in 'req', sending its headers
+func getURL(ctx context.Context, req *pfs.PutFileRequest) (*http.Response, error) {
+	httpReq, err := http.NewRequest("GET", req.Url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range req.UrlHeaders {
+		httpReq.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error fetching %s: %s", req.Url, resp.Status)
+	}
+	return resp, nil
+}
+
+// newURLObjClient returns a client for the object store containing 'url',
+// which uses 'creds' if they're set, or pachd's own credentials otherwise
+func newURLObjClient(ctx context.Context, url *obj.ObjectStoreURL, creds *pfs.URLCredentials) (obj.Client, error) {
+	if creds == nil {
+		return obj.NewClientFromURLAndSecret(ctx, url, false)
+	}
+	switch url.Store {
+	case "s3":
+		return obj.NewAmazonClient(creds.S3Region, url.Bucket, &obj.AmazonCreds{
+			ID:     creds.S3Id,
+			Secret: creds.S3Secret,
+			Token:  creds.S3Token,
+		}, "")
+	case "gcs", "gs":
+		// the GCS client only reads credentials from a file, which it reads
+		// when it's created
+		f, err := ioutil.TempFile("", "gcs-key")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.WriteString(creds.GcsKey); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if err := f.Close(); err != nil {
+			return nil, err
+		}
+		return obj.NewGoogleClient(ctx, url.Bucket, f.Name())
+	default:
+		return nil, fmt.Errorf("credentials can't be given for %s:// URLs", url.Store)
+	}
+}
+
 func forEachPutFile(server pfs.API_PutFileServer, f func(*pfs.PutFileRequest, io.Reader) error) error {
 	limiter := limit.New(client.DefaultMaxConcurrentStreams)
 	var pr *io.PipeReader
@@ -3329,7 +4364,7 @@ func forEachPutFile(server pfs.API_PutFileServer, f func(*pfs.PutFileRequest, io
 				case "http":
 					fallthrough
 				case "https":
-					resp, err := http.Get(req.Url)
+					resp, err := getURL(server.Context(), req)
 					if err != nil {
 						return err
 					}
@@ -3348,7 +4383,7 @@ func forEachPutFile(server pfs.API_PutFileServer, f func(*pfs.PutFileRequest, io
 					if err != nil {
 						return fmt.Errorf("error parsing url %v: %v", req.Url, err)
 					}
-					objClient, err := obj.NewClientFromURLAndSecret(server.Context(), url, false)
+					objClient, err := newURLObjClient(server.Context(), url, req.UrlCredentials)
 					if err != nil {
 						return err
 					}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/dryrun"
+	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
+	"golang.org/x/net/context"
+)
+
+// The functions in this file validate requests the way that the driver
+// functions with the same names (minus "DryRun") do, and return the changes
+// those functions would make, for dry runs (see client/pkg/dryrun)
+
+func (d *driver) createRepoDryRun(pachClient *client.APIClient, repo *pfs.Repo, description string, retention *pfs.RetentionPolicy, compression *pfs.CompressionSpec, encryption *pfs.EncryptionSpec, update bool) ([]string, error) {
+	_, err := pachClient.AuthAPIClient.WhoAmI(pachClient.Ctx(), &auth.WhoAmIRequest{})
+	if !auth.IsErrNotActivated(err) && err != nil {
+		return nil, fmt.Errorf("error authenticating (must log in to create a repo): %v",
+			grpcutil.ScrubGRPC(err))
+	}
+	if err := validateRepoName(repo.Name); err != nil {
+		return nil, err
+	}
+	if err := validateRetention(retention); err != nil {
+		return nil, err
+	}
+	if err := validateCompression(compression); err != nil {
+		return nil, err
+	}
+	if err := validateEncryption(encryption); err != nil {
+		return nil, err
+	}
+	_, err = d.inspectRepo(pachClient, repo, !includeAuth)
+	if err != nil && !col.IsErrNotFound(err) {
+		return nil, fmt.Errorf("error checking whether \"%s\" exists: %v", repo.Name, err)
+	}
+	if update {
+		if err != nil {
+			return nil, fmt.Errorf("error updating repo: %v", err)
+		}
+		if err := d.checkIsAuthorized(pachClient, repo, auth.Scope_WRITER); err != nil {
+			return nil, err
+		}
+		changes := []string{fmt.Sprintf("update the description of repo %s", repo.Name)}
+		if retention != nil {
+			changes[0] = fmt.Sprintf("update the description and retention policy of repo %s", repo.Name)
+		}
+		if compression != nil {
+			changes = append(changes, fmt.Sprintf("compress files subsequently written to repo %s with %v", repo.Name, compression.Compression))
+		}
+		if encryption != nil {
+			if err := d.checkIsAuthorized(pachClient, repo, auth.Scope_OWNER); err != nil {
+				return nil, err
+			}
+			if encryption.Key == "" {
+				changes = append(changes, fmt.Sprintf("stop encrypting files subsequently written to repo %s", repo.Name))
+			} else {
+				changes = append(changes, fmt.Sprintf("encrypt files subsequently written to repo %s with key %s", repo.Name, encryption.Key))
+			}
+		}
+		return changes, nil
+	}
+	if err == nil {
+		return nil, fmt.Errorf("cannot create \"%s\" as it already exists", repo.Name)
+	}
+	return []string{fmt.Sprintf("create repo %s", repo.Name)}, nil
+}
+
+func (d *driver) deleteRepoDryRun(pachClient *client.APIClient, repo *pfs.Repo, force bool) ([]string, error) {
+	repoInfo, err := d.inspectRepo(pachClient, repo, !includeAuth)
+	if err != nil {
+		if col.IsErrNotFound(err) {
+			return nil, nil // deleting a repo that doesn't exist is a no-op
+		}
+		return nil, fmt.Errorf("error checking whether \"%s\" exists: %v", repo.Name, err)
+	}
+	if err := d.checkIsAuthorized(pachClient, repo, auth.Scope_OWNER); err != nil {
+		return nil, err
+	}
+	var changes []string
+	for _, branch := range repoInfo.Branches {
+		branchInfo, err := d.inspectBranch(pachClient, branch)
+		if err != nil {
+			return nil, fmt.Errorf("error inspecting branch %s: %v", branch, err)
+		}
+		// the repo's branches are deleted together, so only branches in other
+		// repos can be broken by deleting this one
+		if !force {
+			for _, subvBranch := range branchInfo.Subvenance {
+				if subvBranch.Repo.Name != repo.Name {
+					return nil, fmt.Errorf("delete branch %s: branch %s has %v as subvenance, deleting it would break those branches", branch, branch.Name, branchInfo.Subvenance)
+				}
+			}
+		}
+		changes = append(changes, fmt.Sprintf("delete branch %s@%s", repo.Name, branch.Name))
+	}
+	return append(changes, fmt.Sprintf("delete repo %s and all of its commits", repo.Name)), nil
+}
+
+func (d *driver) deleteAllDryRun(pachClient *client.APIClient) ([]string, error) {
+	repoInfos, err := d.listRepo(pachClient, !includeAuth)
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	for _, repoInfo := range repoInfos.RepoInfo {
+		repoChanges, err := d.deleteRepoDryRun(pachClient, repoInfo.Repo, true)
+		if err != nil && !auth.IsErrNotAuthorized(err) {
+			return nil, err
+		}
+		changes = append(changes, repoChanges...)
+	}
+	return changes, nil
+}
+
+func (d *driver) deleteCommitDryRun(pachClient *client.APIClient, userCommit *pfs.Commit) ([]string, error) {
+	if err := d.checkIsAuthorized(pachClient, userCommit.Repo, auth.Scope_WRITER); err != nil {
+		return nil, err
+	}
+	commitInfo, err := d.inspectCommit(pachClient, userCommit, pfs.CommitState_STARTED)
+	if err != nil {
+		return nil, fmt.Errorf("resolveCommit: %v", err)
+	}
+	if len(commitInfo.Provenance) > 0 {
+		return nil, fmt.Errorf("cannot delete the commit \"%s/%s\" because it has non-empty provenance", userCommit.Repo.Name, userCommit.ID)
+	}
+	if err := d.checkCommitDeleteProtection(pachClient, commitInfo.Commit); err != nil {
+		return nil, err
+	}
+	changes := []string{fmt.Sprintf("delete commit %s@%s", commitInfo.Commit.Repo.Name, commitInfo.Commit.ID)}
+	for _, subv := range commitInfo.Subvenance {
+		if subv.Lower.ID == subv.Upper.ID {
+			changes = append(changes, fmt.Sprintf("delete downstream commit %s@%s", subv.Lower.Repo.Name, subv.Lower.ID))
+		} else {
+			changes = append(changes, fmt.Sprintf("delete downstream commits %s@%s through %s", subv.Lower.Repo.Name, subv.Lower.ID, subv.Upper.ID))
+		}
+	}
+	return changes, nil
+}
+
+func (d *driver) squashCommitDryRun(pachClient *client.APIClient, from *pfs.Commit, to *pfs.Commit) ([]string, error) {
+	if from.Repo.Name != to.Repo.Name {
+		return nil, fmt.Errorf("cannot squash commits in different repos (\"%s\" and \"%s\")", from.Repo.Name, to.Repo.Name)
+	}
+	if err := d.checkIsAuthorized(pachClient, to.Repo, auth.Scope_WRITER); err != nil {
+		return nil, err
+	}
+	toCommit, squashed, err := d.commitsToSquash(pachClient, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if len(squashed) == 0 {
+		return nil, nil
+	}
+	if err := d.checkDeleteProtection(pachClient, []*pfs.Commit{toCommit}); err != nil {
+		return nil, err
+	}
+	return []string{fmt.Sprintf("squash commits %s@%s through %s into %s (deleting %d commits)",
+		toCommit.Repo.Name, squashed[len(squashed)-1], squashed[0], toCommit.ID, len(squashed))}, nil
+}
+
+func (d *driver) deleteBranchDryRun(pachClient *client.APIClient, branch *pfs.Branch, force bool, cascade bool, deleteCommits bool) ([]string, error) {
+	if err := d.checkIsAuthorized(pachClient, branch.Repo, auth.Scope_WRITER); err != nil {
+		return nil, err
+	}
+	if _, err := d.inspectRepo(pachClient, branch.Repo, !includeAuth); err != nil {
+		if !col.IsErrNotFound(err) || !force {
+			return nil, err
+		}
+	}
+	branchInfo, err := d.inspectBranch(pachClient, branch)
+	if err != nil {
+		if col.IsErrNotFound(err) {
+			return nil, nil // deleting a branch that doesn't exist is a no-op
+		}
+		return nil, fmt.Errorf("branches.Get: %v", err)
+	}
+	if !force && !cascade && len(branchInfo.Subvenance) > 0 {
+		return nil, fmt.Errorf("branch %s has %v as subvenance, deleting it would break those branches", branch.Name, branchInfo.Subvenance)
+	}
+	branches, err := d.branchesToDelete(pachClient, branch, cascade)
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	for _, b := range branches {
+		if err := d.checkIsAuthorized(pachClient, b.Repo, auth.Scope_WRITER); err != nil {
+			return nil, err
+		}
+		if err := d.checkBranchProtection(pachClient, client.NewCommit(b.Repo.Name, b.Name)); err != nil {
+			return nil, err
+		}
+		changes = append(changes, fmt.Sprintf("delete branch %s@%s", b.Repo.Name, b.Name))
+	}
+	if deleteCommits {
+		commits, err := d.exclusiveCommits(pachClient, branches)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.checkDeleteProtection(pachClient, commits); err != nil {
+			return nil, err
+		}
+		for _, commit := range commits {
+			changes = append(changes, fmt.Sprintf("delete commit %s@%s (and its downstream commits)", commit.Repo.Name, commit.ID))
+		}
+	}
+	return changes, nil
+}
+
+// reportDryRun reports 'changes' for the dry run whose context is 'ctx' (see
+// client/pkg/dryrun), unless 'err' is set
+func reportDryRun(ctx context.Context, changes []string, err error) (*types.Empty, error) {
+	if err != nil {
+		return nil, err
+	}
+	if err := dryrun.Report(ctx, changes...); err != nil {
+		return nil, err
+	}
+	return &types.Empty{}, nil
+}
@@ -0,0 +1,339 @@
+package server
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"golang.org/x/net/context"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
+	"github.com/pachyderm/pachyderm/src/server/pkg/kms"
+)
+
+// The functions in this file implement the encryption of file contents in
+// object storage (see pfs.EncryptionSpec). Like compression, it's configured
+// per repo (or by pachd's environment), PutFile asks the object API to
+// encrypt the objects that it writes, and an object's BlockRef records how it
+// was encrypted, so that it's decrypted transparently when it's read.
+//
+// Objects are encrypted with data keys, which are wrapped by a key in a key
+// management service (see server/pkg/kms) and stored, wrapped, in the
+// objects' BlockRefs. A data key is reused for many objects, so that writing
+// an object doesn't usually call the service, but each object is encrypted
+// with its own key, derived from the data key and a random salt.
+//
+// An encrypted object is a version byte, the salt, and then the object's
+// (compressed) data in segments of encryptedSegmentSize bytes, each sealed
+// with AES-GCM. Segments are numbered, and the last one is marked, so that
+// they can't be reordered, and the object can't be truncated, undetected.
+
+const (
+	encryptionVersion    = 1
+	encryptionSaltSize   = 16
+	encryptedSegmentSize = 64 * 1024
+	dataKeySize          = 32
+
+	// dataKeyLifetime is how long a data key is used to encrypt new objects
+	dataKeyLifetime = 15 * time.Minute
+	// unwrappedKeyCacheSize is the number of unwrapped data keys that are
+	// cached, so that reading objects doesn't usually call the key's service
+	unwrappedKeyCacheSize = 1024
+)
+
+// encryptionFromEnv returns the default encryption set in pachd's
+// environment, or nil if none is set
+func encryptionFromEnv() (*pfs.EncryptionSpec, error) {
+	spec := pfsserver.EncryptionFromEnv()
+	if spec == nil {
+		return nil, nil
+	}
+	if err := kms.Validate(spec.Key); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", pfsserver.EncryptionEnvVar, err)
+	}
+	return spec, nil
+}
+
+// validateEncryption returns an error if 'spec' is an invalid encryption
+// spec
+func validateEncryption(spec *pfs.EncryptionSpec) error {
+	if spec.GetKey() == "" {
+		return nil
+	}
+	return kms.Validate(spec.Key)
+}
+
+// fileEncryption returns how the contents of files written to 'repo' should
+// be encrypted (or nil if they shouldn't be)
+func (d *driver) fileEncryption(pachClient *client.APIClient, repo *pfs.Repo) (*pfs.EncryptionSpec, error) {
+	repoInfo := &pfs.RepoInfo{}
+	if err := d.repos.ReadOnly(pachClient.Ctx()).Get(repo.Name, repoInfo); err != nil {
+		return nil, err
+	}
+	if repoInfo.Encryption != nil {
+		return repoInfo.Encryption, nil
+	}
+	return d.encryption, nil
+}
+
+// keyring holds the keys, and the data keys, with which the object API
+// encrypts and decrypts objects
+type keyring struct {
+	mu sync.Mutex
+	// keys are the keys that have been opened, by URI
+	keys map[string]kms.Key
+	// dataKeys are the data keys with which new objects are encrypted, by the
+	// URI of the key that wraps them
+	dataKeys map[string]*dataKey
+	// unwrapped caches unwrapped data keys, by their wrapping key's URI and
+	// wrapped key
+	unwrapped *lru.Cache
+}
+
+type dataKey struct {
+	key, wrapped []byte
+	created      time.Time
+}
+
+func newKeyring() *keyring {
+	// lru.New only fails if its size is not positive
+	unwrapped, _ := lru.New(unwrappedKeyCacheSize)
+	return &keyring{
+		keys:      make(map[string]kms.Key),
+		dataKeys:  make(map[string]*dataKey),
+		unwrapped: unwrapped,
+	}
+}
+
+func (k *keyring) open(uri string) (kms.Key, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if key, ok := k.keys[uri]; ok {
+		return key, nil
+	}
+	key, err := kms.Open(uri)
+	if err != nil {
+		return nil, err
+	}
+	k.keys[uri] = key
+	return key, nil
+}
+
+// dataKey returns a data key with which to encrypt a new object, wrapped by
+// the key 'uri', and the wrapped data key
+func (k *keyring) dataKey(ctx context.Context, uri string) ([]byte, []byte, error) {
+	k.mu.Lock()
+	current, ok := k.dataKeys[uri]
+	k.mu.Unlock()
+	if ok && time.Since(current.created) < dataKeyLifetime {
+		return current.key, current.wrapped, nil
+	}
+	key, err := k.open(uri)
+	if err != nil {
+		return nil, nil, err
+	}
+	newKey := &dataKey{key: make([]byte, dataKeySize), created: time.Now()}
+	if _, err := io.ReadFull(rand.Reader, newKey.key); err != nil {
+		return nil, nil, err
+	}
+	if newKey.wrapped, err = key.Wrap(ctx, newKey.key); err != nil {
+		return nil, nil, err
+	}
+	k.mu.Lock()
+	k.dataKeys[uri] = newKey
+	k.mu.Unlock()
+	k.unwrapped.Add(uri+"\x00"+string(newKey.wrapped), newKey.key)
+	return newKey.key, newKey.wrapped, nil
+}
+
+// unwrap returns the data key with which an object encrypted as described by
+// 'encryption' was encrypted
+func (k *keyring) unwrap(ctx context.Context, encryption *pfs.BlockEncryption) ([]byte, error) {
+	cacheKey := encryption.Key + "\x00" + string(encryption.WrappedKey)
+	if dataKey, ok := k.unwrapped.Get(cacheKey); ok {
+		return dataKey.([]byte), nil
+	}
+	key, err := k.open(encryption.Key)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := key.Unwrap(ctx, encryption.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(dataKey) != dataKeySize {
+		return nil, fmt.Errorf("data key unwrapped by %s is %d bytes, but should be %d", encryption.Key, len(dataKey), dataKeySize)
+	}
+	k.unwrapped.Add(cacheKey, dataKey)
+	return dataKey, nil
+}
+
+// objectCipher returns the cipher for the object with 'salt', encrypted with
+// 'dataKey'
+func objectCipher(dataKey []byte, salt []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, dataKey)
+	mac.Write(salt)
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// segmentNonce returns the nonce of segment 'i' of an object
+func segmentNonce(i uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], i)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptWriter returns a writer that encrypts the data written to it with
+// 'dataKey', and writes it to 'w'. The writer must be closed to flush the
+// encrypted data.
+func encryptWriter(w io.Writer, dataKey []byte) (io.WriteCloser, error) {
+	header := make([]byte, 1+encryptionSaltSize)
+	header[0] = encryptionVersion
+	if _, err := io.ReadFull(rand.Reader, header[1:]); err != nil {
+		return nil, err
+	}
+	aead, err := objectCipher(dataKey, header[1:])
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptingWriter{
+		w:         w,
+		aead:      aead,
+		plaintext: make([]byte, 0, encryptedSegmentSize),
+	}, nil
+}
+
+type encryptingWriter struct {
+	w         io.Writer
+	aead      cipher.AEAD
+	plaintext []byte
+	sealed    []byte
+	segment   uint64
+}
+
+func (w *encryptingWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		// A full segment is only sealed once there's more data, so that the
+		// last segment is sealed by Close
+		if len(w.plaintext) == encryptedSegmentSize {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := encryptedSegmentSize - len(w.plaintext)
+		if n > len(p) {
+			n = len(p)
+		}
+		w.plaintext = append(w.plaintext, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *encryptingWriter) seal(last bool) error {
+	w.sealed = w.aead.Seal(w.sealed[:0], segmentNonce(w.segment, last), w.plaintext, nil)
+	w.segment++
+	w.plaintext = w.plaintext[:0]
+	_, err := w.w.Write(w.sealed)
+	return err
+}
+
+func (w *encryptingWriter) Close() error {
+	return w.seal(true)
+}
+
+// decryptReader returns a reader that decrypts the data read from 'r', which
+// was encrypted with 'dataKey'
+func decryptReader(r io.Reader, dataKey []byte) (io.Reader, error) {
+	header := make([]byte, 1+encryptionSaltSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("could not read encrypted object's header: %v", err)
+	}
+	if header[0] != encryptionVersion {
+		return nil, fmt.Errorf("unrecognized encrypted object version %d", header[0])
+	}
+	aead, err := objectCipher(dataKey, header[1:])
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{
+		r:      bufio.NewReader(r),
+		aead:   aead,
+		sealed: make([]byte, encryptedSegmentSize+aead.Overhead()),
+	}, nil
+}
+
+type decryptingReader struct {
+	r         *bufio.Reader
+	aead      cipher.AEAD
+	sealed    []byte
+	plaintext []byte
+	segment   uint64
+	done      bool
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	for len(r.plaintext) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plaintext)
+	r.plaintext = r.plaintext[n:]
+	return n, nil
+}
+
+// open decrypts the next segment
+func (r *decryptingReader) open() error {
+	n, err := io.ReadFull(r.r, r.sealed)
+	var last bool
+	switch err {
+	case nil:
+		// a full segment is the last one if nothing follows it
+		if _, err := r.r.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	case io.ErrUnexpectedEOF:
+		last = true
+	case io.EOF:
+		return fmt.Errorf("encrypted object is truncated")
+	default:
+		return err
+	}
+	plaintext, err := r.aead.Open(r.sealed[:0], segmentNonce(r.segment, last), r.sealed[:n], nil)
+	if err != nil {
+		return fmt.Errorf("could not decrypt object (it may have been modified or truncated): %v", err)
+	}
+	r.segment++
+	r.plaintext = plaintext
+	r.done = last
+	return nil
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
+	"github.com/pachyderm/pachyderm/src/server/pkg/kms"
+	"github.com/pachyderm/pachyderm/src/server/pkg/log"
+	"github.com/pachyderm/pachyderm/src/server/pkg/obj"
+)
+
+func encrypt(t *testing.T, data []byte, dataKey []byte) []byte {
+	var encrypted bytes.Buffer
+	w, err := encryptWriter(&encrypted, dataKey)
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return encrypted.Bytes()
+}
+
+func decrypt(encrypted []byte, dataKey []byte) ([]byte, error) {
+	r, err := decryptReader(bytes.NewReader(encrypted), dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	dataKey := bytes.Repeat([]byte{7}, dataKeySize)
+	for _, size := range []int{0, 1, encryptedSegmentSize - 1, encryptedSegmentSize, encryptedSegmentSize + 1, 3 * encryptedSegmentSize} {
+		data := bytes.Repeat([]byte("foo,bar,baz\n"), size/12+1)[:size]
+		encrypted := encrypt(t, data, dataKey)
+		// a byte or two of plaintext may appear in the ciphertext by chance
+		if size >= 16 {
+			require.False(t, bytes.Contains(encrypted, data[:size/2+1]))
+		}
+		decrypted, err := decrypt(encrypted, dataKey)
+		require.NoError(t, err)
+		require.Equal(t, len(data), len(decrypted))
+		require.True(t, bytes.Equal(data, decrypted))
+
+		// Encrypting the same data again uses a new salt
+		require.False(t, bytes.Equal(encrypted, encrypt(t, data, dataKey)))
+
+		// The data can't be decrypted with another key, modified, or truncated
+		// (including at a segment boundary)
+		_, err = decrypt(encrypted, bytes.Repeat([]byte{8}, dataKeySize))
+		require.YesError(t, err)
+		modified := append([]byte{}, encrypted...)
+		modified[len(modified)-1] ^= 1
+		_, err = decrypt(modified, dataKey)
+		require.YesError(t, err)
+		_, err = decrypt(encrypted[:len(encrypted)-1], dataKey)
+		require.YesError(t, err)
+		if size > encryptedSegmentSize {
+			_, err = decrypt(encrypted[:1+encryptionSaltSize+encryptedSegmentSize+16], dataKey)
+			require.YesError(t, err)
+		}
+	}
+}
+
+func TestValidateEncryption(t *testing.T) {
+	require.NoError(t, validateEncryption(nil))
+	require.NoError(t, validateEncryption(&pfs.EncryptionSpec{}))
+	require.NoError(t, validateEncryption(&pfs.EncryptionSpec{Key: "awskms://alias/pachyderm"}))
+	require.NoError(t, validateEncryption(&pfs.EncryptionSpec{Key: "vault://transit/pachyderm"}))
+	require.YesError(t, validateEncryption(&pfs.EncryptionSpec{Key: "alias/pachyderm"}))
+	require.YesError(t, validateEncryption(&pfs.EncryptionSpec{Key: "vault://pachyderm"}))
+}
+
+func TestPutObjectEncrypted(t *testing.T) {
+	os.Setenv(kms.LocalKeyEnvPrefix+"TEST", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+	defer os.Unsetenv(kms.LocalKeyEnvPrefix + "TEST")
+	dir, err := ioutil.TempDir("", "TestPutObjectEncrypted")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	objClient, err := obj.NewLocalClient(dir)
+	require.NoError(t, err)
+	s := &objBlockAPIServer{Logger: log.NewLogger("pfs.BlockAPI.Obj"), dir: dir, objClient: objClient, keys: newKeyring()}
+
+	data := strings.Repeat("foo,bar,baz\n", 10000)
+	encryption := &pfs.EncryptionSpec{Key: "local://test"}
+	plain, _, _, err := s.putObject(context.Background(), strings.NewReader(data), nil, nil)
+	require.NoError(t, err)
+	for _, compression := range []*pfs.CompressionSpec{nil, {Compression: pfs.Compression_ZSTD}} {
+		object, _, existed, err := s.putObject(context.Background(), strings.NewReader(data), compression, encryption)
+		require.NoError(t, err)
+		// encrypted objects aren't deduplicated with unencrypted ones
+		require.NotEqual(t, plain.Hash, object.Hash)
+		require.Equal(t, compression != nil, existed)
+		if existed {
+			continue
+		}
+		blockRef := &pfs.BlockRef{}
+		require.NoError(t, s.readProto(s.objectPath(object), blockRef))
+		require.Equal(t, "local://test", blockRef.Encryption.Key)
+		require.Equal(t, uint64(len(data)), pfsserver.BlockRefSize(blockRef))
+		blockReader, err := objClient.Reader(s.blockPath(blockRef.Block), 0, 0)
+		require.NoError(t, err)
+		block, err := ioutil.ReadAll(blockReader)
+		require.NoError(t, err)
+		require.NoError(t, blockReader.Close())
+		require.False(t, bytes.Contains(block, []byte("foo,bar")))
+
+		// read part of the object, with a new keyring (as another pachd would)
+		s.keys = newKeyring()
+		r, err := s.blockRefReader(blockRef, 12, 7)
+		require.NoError(t, err)
+		part, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, "foo,bar", string(part))
+	}
+
+	// compressed and encrypted objects are compressed before they're encrypted
+	data = data + "qux\n"
+	object, _, _, err := s.putObject(context.Background(), strings.NewReader(data), &pfs.CompressionSpec{Compression: pfs.Compression_ZSTD}, encryption)
+	require.NoError(t, err)
+	blockRef := &pfs.BlockRef{}
+	require.NoError(t, s.readProto(s.objectPath(object), blockRef))
+	require.Equal(t, pfs.Compression_ZSTD, blockRef.Compression)
+	require.True(t, pfsserver.ByteRangeSize(blockRef.Range) < uint64(len(data)/10))
+	r, err := s.blockRefReader(blockRef, 0, uint64(len(data)))
+	require.NoError(t, err)
+	read, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, data, string(read))
+}
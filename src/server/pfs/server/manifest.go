@@ -0,0 +1,27 @@
+package server
+
+import (
+	"io"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/manifest"
+)
+
+// getCommitManifest writes a manifest of the files under 'file' to 'w', in
+// 'format'
+func (d *driver) getCommitManifest(pachClient *client.APIClient, file *pfs.File, format pfs.ManifestFormat, w io.Writer) error {
+	mw, err := manifest.NewWriter(w, format)
+	if err != nil {
+		return err
+	}
+	if err := d.walkFile(pachClient, file, false, func(fileInfo *pfs.FileInfo) error {
+		if fileInfo.FileType != pfs.FileType_FILE {
+			return nil
+		}
+		return mw.Write(manifest.NewEntry(fileInfo))
+	}); err != nil {
+		return err
+	}
+	return mw.Flush()
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/pachyderm/pachyderm/src/client/limit"
 	pfsclient "github.com/pachyderm/pachyderm/src/client/pfs"
 	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
 	"github.com/pachyderm/pachyderm/src/server/pkg/backoff"
 	"github.com/pachyderm/pachyderm/src/server/pkg/log"
 	"github.com/pachyderm/pachyderm/src/server/pkg/obj"
@@ -62,6 +63,9 @@ type objBlockAPIServer struct {
 
 	objectIndexes     map[string]*pfsclient.ObjectIndex
 	objectIndexesLock sync.RWMutex
+
+	// keys holds the keys with which objects are encrypted
+	keys *keyring
 }
 
 // In test mode, we use unique names for cache groups, since we might want
@@ -79,6 +83,7 @@ func newObjBlockAPIServer(dir string, cacheBytes int64, etcdAddress string, objC
 		objClient:        objClient,
 		objectIndexes:    make(map[string]*pfsclient.ObjectIndex),
 		objectCacheBytes: oneCacheShare * objectCacheShares,
+		keys:             newKeyring(),
 	}
 
 	objectGroupName := "object"
@@ -208,7 +213,11 @@ func (s *objBlockAPIServer) PutObject(server pfsclient.ObjectAPI_PutObjectServer
 	putObjectReader := &putObjectReader{
 		server: server,
 	}
-	object, err := s.putObject(server.Context(), putObjectReader, false)
+	compression, encryption, err := putObjectReader.specs()
+	if err != nil {
+		return err
+	}
+	object, _, _, err := s.putObject(server.Context(), putObjectReader, compression, encryption)
 	if err != nil {
 		return err
 	}
@@ -230,30 +239,61 @@ func (s *objBlockAPIServer) PutObjectSplit(server pfsclient.ObjectAPI_PutObjectS
 	func() { s.Log(nil, nil, nil, 0) }()
 	defer func(start time.Time) { s.Log(nil, nil, retErr, time.Since(start)) }(time.Now())
 	defer drainObjectServer(server)
-	var objects []*pfsclient.Object
 	putObjectReader := &putObjectReader{
 		server: server,
 	}
+	compression, encryption, err := putObjectReader.specs()
+	if err != nil {
+		return err
+	}
+	objects := &pfsclient.Objects{}
+	chunker := pfsclient.NewChunker(putObjectReader)
 	for {
-		object, err := s.putObject(server.Context(), putObjectReader, true)
-		if object != nil {
-			objects = append(objects, object)
+		object, size, existed, err := s.putObject(server.Context(), chunker, compression, encryption)
+		if err != nil {
+			return err
 		}
+		objects.Objects = append(objects.Objects, object)
+		objects.SizesBytes = append(objects.SizesBytes, size)
+		objects.Deduplicated = append(objects.Deduplicated, existed)
+		more, err := chunker.Next()
 		if err != nil {
-			if err == io.EOF {
-				break
-			}
 			return err
 		}
+		if !more {
+			break
+		}
 	}
-	return server.SendAndClose(&pfsclient.Objects{Objects: objects})
+	return server.SendAndClose(objects)
 }
 
-func (s *objBlockAPIServer) putObject(ctx context.Context, dataReader io.Reader, split bool) (_ *pfsclient.Object, retErr error) {
+// putObject writes the data in 'dataReader' to a new object, compressed and
+// encrypted as described by 'compression' and 'encryption' (either of which
+// may be nil). It returns the object, the size of its data, and whether the
+// object already existed (in which case the data isn't stored again).
+func (s *objBlockAPIServer) putObject(ctx context.Context, dataReader io.Reader, compression *pfsclient.CompressionSpec, encryption *pfsclient.EncryptionSpec) (_ *pfsclient.Object, _ int64, _ bool, retErr error) {
 	hash := pfsclient.NewHash()
 	r := io.TeeReader(dataReader, hash)
 	block := &pfsclient.Block{Hash: uuid.NewWithoutDashes()}
-	var size int64
+	compressed := compression.GetCompression() != pfsclient.Compression_UNCOMPRESSED
+	var blockEncryption *pfsclient.BlockEncryption
+	var dataKey []byte
+	if encryption.GetKey() != "" {
+		blockEncryption = &pfsclient.BlockEncryption{Key: encryption.Key}
+		var err error
+		if dataKey, blockEncryption.WrappedKey, err = s.keys.dataKey(ctx, encryption.Key); err != nil {
+			return nil, 0, false, err
+		}
+		// Encrypted objects are named by the hash of their key as well as their
+		// data, so that they're only deduplicated with objects encrypted with the
+		// same key (and an encrypted object's name doesn't reveal the hash of its
+		// data)
+		hash.Write([]byte(encryption.Key + "\x00"))
+	}
+	// size is the size of the object's data, and blockSize is the size of
+	// what's written to its block (which differs if it's compressed or
+	// encrypted)
+	var size, blockSize int64
 	if err := func() (retErr error) {
 		blockPath := s.blockPath(block)
 		w, err := s.objClient.Writer(blockPath)
@@ -265,55 +305,76 @@ func (s *objBlockAPIServer) putObject(ctx context.Context, dataReader io.Reader,
 				retErr = err
 			}
 		}()
-		if split {
-			size, err = io.CopyN(w, r, pfsclient.ChunkSize)
-		} else {
-			buf := grpcutil.GetBuffer()
-			defer grpcutil.PutBuffer(buf)
-			size, err = io.CopyBuffer(w, r, buf)
+		counter := &countWriter{w: w}
+		var dst io.Writer = counter
+		// closers are closed in order once the data is written: the
+		// compressor (if any) first, as it writes to the encryptor
+		var closers []io.Closer
+		if blockEncryption != nil {
+			ew, err := encryptWriter(counter, dataKey)
+			if err != nil {
+				return err
+			}
+			dst = ew
+			closers = append(closers, ew)
 		}
-		if err != nil {
-			if err != io.EOF {
-				s.objClient.Delete(blockPath)
+		if compressed {
+			cw, err := compressWriter(dst, compression)
+			if err != nil {
+				return err
 			}
+			dst = cw
+			closers = append([]io.Closer{cw}, closers...)
+		}
+		buf := grpcutil.GetBuffer()
+		defer grpcutil.PutBuffer(buf)
+		if size, err = io.CopyBuffer(dst, r, buf); err != nil {
+			s.objClient.Delete(blockPath)
 			return err
 		}
+		for _, c := range closers {
+			if err := c.Close(); err != nil {
+				s.objClient.Delete(blockPath)
+				return err
+			}
+		}
+		blockSize = counter.n
 		return nil
 	}(); err != nil {
-		if err == io.EOF {
-			defer func() {
-				if retErr == nil {
-					retErr = io.EOF
-				}
-			}()
-		} else {
-			return nil, err
-		}
+		return nil, 0, false, err
 	}
 	object := &pfsclient.Object{Hash: pfsclient.EncodeHash(hash.Sum(nil))}
 	// Now that we have a hash of the object we can check if it already exists.
 	resp, err := s.CheckObject(ctx, &pfsclient.CheckObjectRequest{Object: object})
 	if err != nil {
-		return nil, err
+		return nil, 0, false, err
 	}
 	if resp.Exists {
 		// the object already exists so we delete the block we put
 		if err := s.objClient.Delete(s.blockPath(block)); err != nil {
-			return nil, err
+			return nil, 0, false, err
 		}
 	} else {
 		blockRef := &pfsclient.BlockRef{
 			Block: block,
 			Range: &pfsclient.ByteRange{
 				Lower: 0,
-				Upper: uint64(size),
+				Upper: uint64(blockSize),
 			},
 		}
+		if compressed {
+			blockRef.Compression = compression.Compression
+			blockRef.UncompressedBytes = uint64(size)
+		}
+		if blockEncryption != nil {
+			blockRef.Encryption = blockEncryption
+			blockRef.UncompressedBytes = uint64(size)
+		}
 		if err := s.writeProto(s.objectPath(object), blockRef); err != nil {
-			return nil, err
+			return nil, 0, false, err
 		}
 	}
-	return object, nil
+	return object, size, resp.Exists, nil
 }
 
 func (s *objBlockAPIServer) PutObjects(server pfsclient.ObjectAPI_PutObjectsServer) (retErr error) {
@@ -369,12 +430,11 @@ func (s *objBlockAPIServer) GetObject(request *pfsclient.Object, getObjectServer
 		logrus.Errorf("objectInfo.BlockRef.Range is nil; info: %+v; request: %v", objectInfo, request)
 		return nil
 	}
-	objectSize := objectInfo.BlockRef.Range.Upper - objectInfo.BlockRef.Range.Lower
+	objectSize := pfsserver.BlockRefSize(objectInfo.BlockRef)
 	if (objectSize) >= uint64(s.objectCacheBytes/maxCachedObjectDenom) {
 		// The object is a substantial portion of the available cache space so
 		// we bypass the cache and stream it directly out of the underlying store.
-		blockPath := s.blockPath(objectInfo.BlockRef.Block)
-		r, err := s.objClient.Reader(blockPath, objectInfo.BlockRef.Range.Lower, objectSize)
+		r, err := s.blockRefReader(objectInfo.BlockRef, 0, objectSize)
 		if err != nil {
 			return err
 		}
@@ -415,7 +475,7 @@ func (s *objBlockAPIServer) GetObjects(request *pfsclient.GetObjectsRequest, get
 			continue
 		}
 
-		objectSize := objectInfo.BlockRef.Range.Upper - objectInfo.BlockRef.Range.Lower
+		objectSize := pfsserver.BlockRefSize(objectInfo.BlockRef)
 		if offset > objectSize {
 			offset -= objectSize
 			continue
@@ -425,8 +485,7 @@ func (s *objBlockAPIServer) GetObjects(request *pfsclient.GetObjectsRequest, get
 			readSize = size
 		}
 		if request.TotalSize >= uint64(s.objectCacheBytes/maxCachedObjectDenom) {
-			blockPath := s.blockPath(objectInfo.BlockRef.Block)
-			r, err := s.objClient.Reader(blockPath, objectInfo.BlockRef.Range.Lower+offset, readSize)
+			r, err := s.blockRefReader(objectInfo.BlockRef, offset, readSize)
 			if err != nil {
 				return err
 			}
@@ -467,7 +526,7 @@ func (s *objBlockAPIServer) GetBlocks(request *pfsclient.GetBlocksRequest, getBl
 	offset := request.OffsetBytes
 	size := request.SizeBytes
 	for _, blockRef := range request.BlockRefs {
-		blockSize := blockRef.Range.Upper - blockRef.Range.Lower
+		blockSize := pfsserver.BlockRefSize(blockRef)
 		if offset > blockSize {
 			offset -= blockSize
 			continue
@@ -476,9 +535,10 @@ func (s *objBlockAPIServer) GetBlocks(request *pfsclient.GetBlocksRequest, getBl
 		if size < readSize && request.SizeBytes != 0 {
 			readSize = size
 		}
-		if request.TotalSize >= uint64(s.objectCacheBytes/maxCachedObjectDenom) {
-			blockPath := s.blockPath(blockRef.Block)
-			r, err := s.objClient.Reader(blockPath, blockRef.Range.Lower+offset, readSize)
+		// Encrypted data isn't cached by block, as block cache keys don't
+		// include how it's encrypted
+		if request.TotalSize >= uint64(s.objectCacheBytes/maxCachedObjectDenom) || blockRef.Encryption != nil {
+			r, err := s.blockRefReader(blockRef, offset, readSize)
 			if err != nil {
 				return err
 			}
@@ -492,6 +552,9 @@ func (s *objBlockAPIServer) GetBlocks(request *pfsclient.GetBlocksRequest, getBl
 		}
 		var data []byte
 		key := blockRef.Block.Hash + "|" + strconv.FormatUint(blockRef.Range.Lower, 10) + "|" + strconv.FormatUint(blockRef.Range.Upper, 10)
+		if blockRef.Compression != pfsclient.Compression_UNCOMPRESSED {
+			key += "|" + strconv.Itoa(int(blockRef.Compression))
+		}
 		sink := groupcache.AllocatingByteSliceSink(&data)
 		if err := s.blockCache.Get(getBlockServer.Context(), key, sink); err != nil {
 			return err
@@ -514,6 +577,18 @@ func (s *objBlockAPIServer) GetBlocks(request *pfsclient.GetBlocksRequest, getBl
 	return nil
 }
 
+func (s *objBlockAPIServer) CreateObject(ctx context.Context, request *pfsclient.CreateObjectRequest) (response *types.Empty, retErr error) {
+	func() { s.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { s.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if request.Object == nil || request.BlockRef == nil || request.BlockRef.Block == nil || request.BlockRef.Range == nil {
+		return nil, fmt.Errorf("both an object and a block ref must be given")
+	}
+	if err := s.writeProto(s.objectPath(request.Object), request.BlockRef); err != nil {
+		return nil, err
+	}
+	return &types.Empty{}, nil
+}
+
 func (s *objBlockAPIServer) TagObject(ctx context.Context, request *pfsclient.TagObjectRequest) (response *types.Empty, retErr error) {
 	func() { s.Log(request, nil, nil, 0) }()
 	defer func(start time.Time) { s.Log(request, response, retErr, time.Since(start)) }(time.Now())
@@ -758,10 +833,14 @@ func (s *objBlockAPIServer) compact() (retErr error) {
 				if err != nil {
 					return err
 				}
+				// the object is copied as is, so it keeps its compression and
+				// encryption
+				compression, encryption, uncompressedBytes := blockRef.Compression, blockRef.Encryption, blockRef.UncompressedBytes
 				blockRef, err = w.Write(object)
 				if err != nil {
 					return err
 				}
+				blockRef.Compression, blockRef.Encryption, blockRef.UncompressedBytes = compression, encryption, uncompressedBytes
 				mu.Lock()
 				defer mu.Unlock()
 				objectIndex.Objects[filepath.Base(name)] = blockRef
@@ -905,8 +984,10 @@ func (s *objBlockAPIServer) writeInternal(path string, data []byte) (retErr erro
 }
 
 func (s *objBlockAPIServer) blockGetter(ctx groupcache.Context, key string, dest groupcache.Sink) (retErr error) {
+	// keys are "block|lower|upper", followed by "|compression" if the block
+	// ref is compressed
 	fields := strings.Split(key, blockKeySeparator)
-	if len(fields) != 3 {
+	if len(fields) != 3 && len(fields) != 4 {
 		return fmt.Errorf("bad block key: %s", key)
 	}
 	lower, err := strconv.ParseUint(fields[1], 10, 64)
@@ -917,7 +998,18 @@ func (s *objBlockAPIServer) blockGetter(ctx groupcache.Context, key string, dest
 	if err != nil {
 		return err
 	}
-	return s.readObj(s.blockPath(client.NewBlock(fields[0])), lower, upper-lower, dest)
+	blockRef := &pfsclient.BlockRef{
+		Block: client.NewBlock(fields[0]),
+		Range: &pfsclient.ByteRange{Lower: lower, Upper: upper},
+	}
+	if len(fields) == 4 {
+		compression, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return err
+		}
+		blockRef.Compression = pfsclient.Compression(compression)
+	}
+	return s.readBlockRef(blockRef, dest)
 }
 
 func (s *objBlockAPIServer) objectGetter(ctx groupcache.Context, key string, dest groupcache.Sink) error {
@@ -1062,8 +1154,83 @@ func (s *objBlockAPIServer) readObj(path string, offset uint64, size uint64, des
 	return dest.SetBytes(buff)
 }
 
+// readBlockRef reads the data at 'blockRef' into 'dest', decrypting and
+// decompressing it if it's encrypted or compressed
 func (s *objBlockAPIServer) readBlockRef(blockRef *pfsclient.BlockRef, dest groupcache.Sink) error {
-	return s.readObj(s.blockPath(blockRef.Block), blockRef.Range.Lower, blockRef.Range.Upper-blockRef.Range.Lower, dest)
+	blockPath := s.blockPath(blockRef.Block)
+	if blockRef.Compression == pfsclient.Compression_UNCOMPRESSED && blockRef.Encryption == nil {
+		return s.readObj(blockPath, blockRef.Range.Lower, pfsserver.ByteRangeSize(blockRef.Range), dest)
+	}
+	var data []byte
+	if err := s.readObj(blockPath, blockRef.Range.Lower, pfsserver.ByteRangeSize(blockRef.Range), groupcache.AllocatingByteSliceSink(&data)); err != nil {
+		return err
+	}
+	var encrypted io.Reader = bytes.NewReader(data)
+	if blockRef.Encryption != nil {
+		var err error
+		if encrypted, err = s.decryptReader(encrypted, blockRef.Encryption); err != nil {
+			return err
+		}
+	}
+	r, err := decompressReader(encrypted, blockRef.Compression)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	data, err = ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return dest.SetBytes(data)
+}
+
+// blockRefReader returns a reader for 'size' bytes of the data at 'blockRef',
+// starting 'offset' bytes in, decrypting and decompressing it if it's
+// encrypted or compressed
+func (s *objBlockAPIServer) blockRefReader(blockRef *pfsclient.BlockRef, offset, size uint64) (_ io.ReadCloser, retErr error) {
+	blockPath := s.blockPath(blockRef.Block)
+	if blockRef.Compression == pfsclient.Compression_UNCOMPRESSED && blockRef.Encryption == nil {
+		return s.objClient.Reader(blockPath, blockRef.Range.Lower+offset, size)
+	}
+	// compressed or encrypted data can't be read from an offset, so read all
+	// of it and skip to 'offset' once it's decrypted and decompressed
+	r, err := s.objClient.Reader(blockPath, blockRef.Range.Lower, pfsserver.ByteRangeSize(blockRef.Range))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if retErr != nil {
+			r.Close()
+		}
+	}()
+	var encrypted io.Reader = r
+	if blockRef.Encryption != nil {
+		if encrypted, err = s.decryptReader(r, blockRef.Encryption); err != nil {
+			return nil, err
+		}
+	}
+	dr, err := decompressReader(encrypted, blockRef.Compression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(ioutil.Discard, dr, int64(offset)); err != nil {
+		dr.Close()
+		return nil, err
+	}
+	return &blockRefReadCloser{
+		Reader:  io.LimitReader(dr, int64(size)),
+		closers: []io.Closer{dr, r},
+	}, nil
+}
+
+// decryptReader returns a reader that decrypts the data read from 'r', which
+// was encrypted as described by 'encryption'
+func (s *objBlockAPIServer) decryptReader(r io.Reader, encryption *pfsclient.BlockEncryption) (io.Reader, error) {
+	dataKey, err := s.keys.unwrap(context.Background(), encryption)
+	if err != nil {
+		return nil, fmt.Errorf("could not unwrap object's data key with %s: %v", encryption.Key, err)
+	}
+	return decryptReader(r, dataKey)
 }
 
 func (s *objBlockAPIServer) getObjectIndex(prefix string) (*pfsclient.ObjectIndex, bool) {
@@ -1180,22 +1347,57 @@ type putObjectReader struct {
 	server putObjectServer
 	buffer bytes.Buffer
 	tags   []*pfsclient.Tag
+	// started is set once the first request has been received, and
+	// compression and encryption are the compression and encryption that it
+	// requested
+	started     bool
+	compression *pfsclient.CompressionSpec
+	encryption  *pfsclient.EncryptionSpec
 }
 
 func (r *putObjectReader) Read(p []byte) (int, error) {
 	if r.buffer.Len() == 0 {
-		request, err := r.server.Recv()
-		if err != nil {
+		if err := r.recv(); err != nil {
 			return 0, err
 		}
-		r.buffer.Reset()
-		// buffer.Write cannot error
-		r.buffer.Write(request.Value)
-		r.tags = append(r.tags, request.Tags...)
 	}
 	return r.buffer.Read(p)
 }
 
+func (r *putObjectReader) recv() error {
+	request, err := r.server.Recv()
+	if err != nil {
+		return err
+	}
+	if !r.started {
+		r.started = true
+		r.compression = request.Compression
+		r.encryption = request.Encryption
+	}
+	r.buffer.Reset()
+	// buffer.Write cannot error
+	r.buffer.Write(request.Value)
+	r.tags = append(r.tags, request.Tags...)
+	return nil
+}
+
+// specs returns the compression and encryption requested by the first
+// request (receiving it, if it hasn't been received yet)
+func (r *putObjectReader) specs() (*pfsclient.CompressionSpec, *pfsclient.EncryptionSpec, error) {
+	if !r.started {
+		if err := r.recv(); err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+	}
+	if err := validateCompression(r.compression); err != nil {
+		return nil, nil, err
+	}
+	if err := validateEncryption(r.encryption); err != nil {
+		return nil, nil, err
+	}
+	return r.compression, r.encryption, nil
+}
+
 func drainObjectServer(putObjectServer putObjectServer) {
 	for {
 		if _, err := putObjectServer.Recv(); err != nil {
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"path"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
+	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
+	"github.com/pachyderm/pachyderm/src/server/pkg/uuid"
+)
+
+// The functions in this file enforce branch protection (see
+// pfs.BranchProtection). Protection is checked before the change it guards
+// is made, like checkIsAuthorized, rather than in the change's transaction.
+
+func (d *driver) setBranchProtection(pachClient *client.APIClient, branch *pfs.Branch, protection *pfs.BranchProtection) error {
+	if err := d.checkIsAuthorized(pachClient, branch.Repo, auth.Scope_OWNER); err != nil {
+		return err
+	}
+	if protection != nil && !protection.NoDirectWrites && !protection.NoDeleteCommit &&
+		protection.RequiredScope == auth.Scope_NONE {
+		protection = nil
+	}
+	_, err := col.NewSTM(pachClient.Ctx(), d.etcdClient, func(stm col.STM) error {
+		branchInfo := &pfs.BranchInfo{}
+		return d.branches(branch.Repo.Name).ReadWrite(stm).Update(branch.Name, branchInfo, func() error {
+			branchInfo.Protection = protection
+			return nil
+		})
+	})
+	return err
+}
+
+// protectedBranches returns the branches in 'repo' that have a protection
+func (d *driver) protectedBranches(pachClient *client.APIClient, repo *pfs.Repo) ([]*pfs.BranchInfo, error) {
+	var result []*pfs.BranchInfo
+	branchInfo := &pfs.BranchInfo{}
+	if err := d.branches(repo.Name).ReadOnly(pachClient.Ctx()).List(branchInfo, col.DefaultOptions, func(string) error {
+		if branchInfo.Protection != nil {
+			result = append(result, proto.Clone(branchInfo).(*pfs.BranchInfo))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkProtectionScope returns an error if the caller doesn't have the scope
+// that 'branchInfo's protection requires
+func (d *driver) checkProtectionScope(pachClient *client.APIClient, branchInfo *pfs.BranchInfo) error {
+	if branchInfo.Protection.RequiredScope == auth.Scope_NONE {
+		return nil
+	}
+	return d.checkIsAuthorized(pachClient, branchInfo.Branch.Repo, branchInfo.Protection.RequiredScope)
+}
+
+// checkBranchProtection returns an error if the protection of the branch
+// 'commit' prevents the caller from writing to it: starting or building
+// commits on it, putting, copying or deleting files in its head, re-pointing
+// it or deleting it. If 'commit' is a branch, only that branch is read, so
+// this is cheap enough to call on every PutFile. If 'commit' is a commit ID,
+// the protection of each branch that it's the head of is checked, so that an
+// open head (e.g. a pipeline's output commit) can't be written to by ID.
+func (d *driver) checkBranchProtection(pachClient *client.APIClient, commit *pfs.Commit) error {
+	if uuid.IsUUIDWithoutDashes(commit.ID) {
+		branchInfos, err := d.protectedBranches(pachClient, commit.Repo)
+		if err != nil {
+			return err
+		}
+		for _, branchInfo := range branchInfos {
+			if branchInfo.Head == nil || branchInfo.Head.ID != commit.ID {
+				continue
+			}
+			if err := d.checkWriteProtection(pachClient, branchInfo); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	branchInfo := &pfs.BranchInfo{}
+	if err := d.branches(commit.Repo.Name).ReadOnly(pachClient.Ctx()).Get(commit.ID, branchInfo); err != nil {
+		if col.IsErrNotFound(err) {
+			return nil // a new branch has no protection
+		}
+		return err
+	}
+	if branchInfo.Protection == nil {
+		return nil
+	}
+	return d.checkWriteProtection(pachClient, branchInfo)
+}
+
+// checkWriteProtection returns an error if the protection of 'branchInfo'
+// prevents the caller from writing to the branch
+func (d *driver) checkWriteProtection(pachClient *client.APIClient, branchInfo *pfs.BranchInfo) error {
+	if branchInfo.Protection.NoDirectWrites {
+		return pfsserver.ErrBranchProtected{
+			Branch: branchInfo.Branch,
+			Reason: "only pipelines may write to it",
+		}
+	}
+	return d.checkProtectionScope(pachClient, branchInfo)
+}
+
+// checkCommitDeleteProtection returns an error if the protection of a branch
+// prevents DeleteCommit from deleting 'commit' or its downstream commits
+func (d *driver) checkCommitDeleteProtection(pachClient *client.APIClient, commit *pfs.Commit) error {
+	commitInfo, err := d.inspectCommit(pachClient, proto.Clone(commit).(*pfs.Commit), pfs.CommitState_STARTED)
+	if err != nil {
+		return err
+	}
+	// the first commit of each downstream range is an ancestor of the others
+	commits := []*pfs.Commit{commitInfo.Commit}
+	for _, subv := range commitInfo.Subvenance {
+		commits = append(commits, subv.Lower)
+	}
+	return d.checkDeleteProtection(pachClient, commits)
+}
+
+// checkDeleteProtection returns an error if the protection of a branch
+// prevents the caller from deleting 'commits' (which must be commit IDs, not
+// branches), because one of them is the head of the branch or an ancestor of
+// it. Only the descendants of 'commits' are read, so this is cheap when
+// deleting recent commits.
+func (d *driver) checkDeleteProtection(pachClient *client.APIClient, commits []*pfs.Commit) error {
+	// heads maps "repo/commit ID" to the protected branches with that head,
+	// for each repo in 'protected'
+	heads := make(map[string][]*pfs.BranchInfo)
+	protected := make(map[string]bool)
+	visited := make(map[string]bool)
+	queue := append([]*pfs.Commit(nil), commits...)
+	for len(queue) > 0 {
+		commit := queue[0]
+		queue = queue[1:]
+		key := path.Join(commit.Repo.Name, commit.ID)
+		if visited[key] {
+			continue
+		}
+		visited[key] = true
+		if _, ok := protected[commit.Repo.Name]; !ok {
+			branchInfos, err := d.protectedBranches(pachClient, commit.Repo)
+			if err != nil {
+				return err
+			}
+			protected[commit.Repo.Name] = len(branchInfos) > 0
+			for _, branchInfo := range branchInfos {
+				if branchInfo.Head != nil {
+					headKey := path.Join(commit.Repo.Name, branchInfo.Head.ID)
+					heads[headKey] = append(heads[headKey], branchInfo)
+				}
+			}
+		}
+		if !protected[commit.Repo.Name] {
+			continue // no descendant of 'commit' can be the head of a protected branch
+		}
+		for _, branchInfo := range heads[key] {
+			if branchInfo.Protection.NoDeleteCommit {
+				return pfsserver.ErrBranchProtected{
+					Branch: branchInfo.Branch,
+					Reason: "commits in its history can't be deleted",
+				}
+			}
+			if err := d.checkProtectionScope(pachClient, branchInfo); err != nil {
+				return err
+			}
+		}
+		commitInfo := &pfs.CommitInfo{}
+		if err := d.commits(commit.Repo.Name).ReadOnly(pachClient.Ctx()).Get(commit.ID, commitInfo); err != nil {
+			if col.IsErrNotFound(err) {
+				continue
+			}
+			return err
+		}
+		queue = append(queue, commitInfo.ChildCommits...)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
+)
+
+// The functions in this file implement ProvenanceQuery. A commit's
+// CommitInfo.Provenance holds its transitive provenance, and its Subvenance
+// holds ranges of its transitive subvenance, so provenanceQuery derives the
+// direct relationships between commits from them: a commit 'p' in the
+// provenance of 'c' is directly upstream of 'c' unless it's also in the
+// provenance of another commit in the provenance of 'c'.
+
+// provenanceGraph caches the commits (and repo authorization checks) that a
+// provenance query reads, and the edges it finds
+type provenanceGraph struct {
+	d          *driver
+	pachClient *client.APIClient
+
+	commitInfos map[string]*pfs.CommitInfo
+	authorized  map[string]bool
+
+	// nodes holds the commits that the query has visited, in the order it
+	// visited them, and upstream maps each one to the commits directly
+	// upstream of it
+	nodes    []*pfs.Commit
+	visited  map[string]bool
+	upstream map[string][]*pfs.Commit
+	edges    map[string]bool
+}
+
+func newProvenanceGraph(d *driver, pachClient *client.APIClient) *provenanceGraph {
+	return &provenanceGraph{
+		d:           d,
+		pachClient:  pachClient,
+		commitInfos: make(map[string]*pfs.CommitInfo),
+		authorized:  make(map[string]bool),
+		visited:     make(map[string]bool),
+		upstream:    make(map[string][]*pfs.Commit),
+		edges:       make(map[string]bool),
+	}
+}
+
+// get returns the CommitInfo of 'commit', which must be a commit ID (rather
+// than a branch name)
+func (g *provenanceGraph) get(commit *pfs.Commit) (*pfs.CommitInfo, error) {
+	key := commitKey(commit)
+	if commitInfo, ok := g.commitInfos[key]; ok {
+		return commitInfo, nil
+	}
+	if !g.authorized[commit.Repo.Name] {
+		if err := g.d.checkIsAuthorized(g.pachClient, commit.Repo, auth.Scope_READER); err != nil {
+			return nil, err
+		}
+		g.authorized[commit.Repo.Name] = true
+	}
+	commitInfo := &pfs.CommitInfo{}
+	if err := g.d.commits(commit.Repo.Name).ReadOnly(g.pachClient.Ctx()).Get(commit.ID, commitInfo); err != nil {
+		return nil, err
+	}
+	g.commitInfos[key] = commitInfo
+	return commitInfo, nil
+}
+
+func (g *provenanceGraph) addEdge(upstream, downstream *pfs.Commit) {
+	key := commitKey(upstream) + "\x00" + commitKey(downstream)
+	if g.edges[key] {
+		return
+	}
+	g.edges[key] = true
+	g.upstream[commitKey(downstream)] = append(g.upstream[commitKey(downstream)], upstream)
+}
+
+func (g *provenanceGraph) visit(commit *pfs.Commit) {
+	if !g.visited[commitKey(commit)] {
+		g.visited[commitKey(commit)] = true
+		g.nodes = append(g.nodes, commit)
+	}
+}
+
+// directProvenance returns the commits directly upstream of 'commitInfo'
+func (g *provenanceGraph) directProvenance(commitInfo *pfs.CommitInfo) ([]*pfs.Commit, error) {
+	indirect := make(map[string]bool)
+	for _, provCommit := range commitInfo.Provenance {
+		provCommitInfo, err := g.get(provCommit)
+		if err != nil {
+			return nil, err
+		}
+		for _, provProvCommit := range provCommitInfo.Provenance {
+			indirect[commitKey(provProvCommit)] = true
+		}
+	}
+	var result []*pfs.Commit
+	for _, provCommit := range commitInfo.Provenance {
+		if !indirect[commitKey(provCommit)] {
+			result = append(result, provCommit)
+		}
+	}
+	return result, nil
+}
+
+// directSubvenance returns the commits directly downstream of 'commitInfo'
+func (g *provenanceGraph) directSubvenance(commitInfo *pfs.CommitInfo) ([]*pfs.Commit, error) {
+	// Expand the subvenant commit ranges (each range holds 'upper' and its
+	// ancestors, back to 'lower')
+	var subvCommits []*pfs.Commit
+	subv := make(map[string]bool)
+	for _, subvRange := range commitInfo.Subvenance {
+		commit := subvRange.Upper
+		for commit != nil {
+			subvCommitInfo, err := g.get(commit)
+			if err != nil {
+				if col.IsErrNotFound(err) {
+					break
+				}
+				return nil, err
+			}
+			if !subv[commitKey(commit)] {
+				subv[commitKey(commit)] = true
+				subvCommits = append(subvCommits, commit)
+			}
+			if commit.ID == subvRange.Lower.ID {
+				break
+			}
+			commit = subvCommitInfo.ParentCommit
+		}
+	}
+	// A subvenant commit is direct if none of its provenance is also
+	// subvenant
+	var result []*pfs.Commit
+	for _, subvCommit := range subvCommits {
+		subvCommitInfo, err := g.get(subvCommit)
+		if err != nil {
+			return nil, err
+		}
+		direct := true
+		for _, provCommit := range subvCommitInfo.Provenance {
+			if subv[commitKey(provCommit)] {
+				direct = false
+				break
+			}
+		}
+		if direct {
+			result = append(result, subvCommit)
+		}
+	}
+	return result, nil
+}
+
+// walk visits the commits in one direction from 'root', following at most
+// 'maxDepth' edges (or any number, if it's 0)
+func (g *provenanceGraph) walk(root *pfs.Commit, upstream bool, maxDepth int64) error {
+	depth := map[string]int64{commitKey(root): 0}
+	queue := []*pfs.Commit{root}
+	for len(queue) > 0 {
+		commit := queue[0]
+		queue = queue[1:]
+		g.visit(commit)
+		if maxDepth > 0 && depth[commitKey(commit)] >= maxDepth {
+			continue
+		}
+		commitInfo, err := g.get(commit)
+		if err != nil {
+			return err
+		}
+		var next []*pfs.Commit
+		if upstream {
+			next, err = g.directProvenance(commitInfo)
+		} else {
+			next, err = g.directSubvenance(commitInfo)
+		}
+		if err != nil {
+			return err
+		}
+		for _, nextCommit := range next {
+			if upstream {
+				g.addEdge(nextCommit, commit)
+			} else {
+				g.addEdge(commit, nextCommit)
+			}
+			if _, ok := depth[commitKey(nextCommit)]; !ok {
+				depth[commitKey(nextCommit)] = depth[commitKey(commit)] + 1
+				queue = append(queue, nextCommit)
+			}
+		}
+	}
+	return nil
+}
+
+// filter returns the edges between the commits in 'g' that 'keep' accepts,
+// where two such commits are connected if there's a path between them that
+// passes only through commits that 'keep' rejects
+func (g *provenanceGraph) filter(keep func(*pfs.Commit) bool) []*pfs.ProvenanceEdge {
+	var result []*pfs.ProvenanceEdge
+	for _, commit := range g.nodes {
+		if !keep(commit) {
+			continue
+		}
+		seen := make(map[string]bool)
+		var search func(*pfs.Commit)
+		search = func(downstream *pfs.Commit) {
+			for _, upstream := range g.upstream[commitKey(downstream)] {
+				if seen[commitKey(upstream)] {
+					continue
+				}
+				seen[commitKey(upstream)] = true
+				if keep(upstream) {
+					result = append(result, &pfs.ProvenanceEdge{
+						Upstream:   upstream,
+						Downstream: commit,
+					})
+				} else {
+					search(upstream)
+				}
+			}
+		}
+		search(commit)
+	}
+	return result
+}
+
+func (d *driver) provenanceQuery(pachClient *client.APIClient, commit *pfs.Commit, direction pfs.ProvenanceDirection, maxDepth int64, repos []*pfs.Repo) (*pfs.ProvenanceQueryResponse, error) {
+	commitInfo, err := d.inspectCommit(pachClient, commit, pfs.CommitState_STARTED)
+	if err != nil {
+		return nil, err
+	}
+	g := newProvenanceGraph(d, pachClient)
+	g.commitInfos[commitKey(commitInfo.Commit)] = commitInfo
+	g.authorized[commitInfo.Commit.Repo.Name] = true
+	if direction != pfs.ProvenanceDirection_DOWNSTREAM {
+		if err := g.walk(commitInfo.Commit, true, maxDepth); err != nil {
+			return nil, err
+		}
+	}
+	if direction != pfs.ProvenanceDirection_UPSTREAM {
+		if err := g.walk(commitInfo.Commit, false, maxDepth); err != nil {
+			return nil, err
+		}
+	}
+
+	include := make(map[string]bool)
+	for _, repo := range repos {
+		include[repo.Name] = true
+	}
+	keep := func(c *pfs.Commit) bool {
+		return len(repos) == 0 || include[c.Repo.Name] || commitKey(c) == commitKey(commitInfo.Commit)
+	}
+	result := &pfs.ProvenanceQueryResponse{
+		Edges: g.filter(keep),
+	}
+	for _, c := range g.nodes {
+		if keep(c) {
+			result.Commits = append(result.Commits, g.commitInfos[commitKey(c)])
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
+	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
+	"github.com/pachyderm/pachyderm/src/server/pkg/hashtree"
+)
+
+// The functions in this file enforce repo quotas (see pfs.RepoQuota). A
+// commit's quota is checked in the transaction that adds its size to its
+// repo's, so concurrent commits can't take a repo over its quota together.
+// PutFile also checks the size of the data that it writes, so that writes to
+// a full repo fail before they reach FinishCommit.
+
+func (d *driver) setRepoQuota(pachClient *client.APIClient, repo *pfs.Repo, quota *pfs.RepoQuota) error {
+	// Only admins may set quotas, as otherwise the owner of a repo could raise
+	// its quota
+	if err := d.checkIsAdmin(pachClient, "SetRepoQuota"); err != nil {
+		return err
+	}
+	if quota != nil && quota.MaxBytes == 0 && quota.MaxFiles == 0 {
+		quota = nil
+	}
+	_, err := col.NewSTM(pachClient.Ctx(), d.etcdClient, func(stm col.STM) error {
+		repoInfo := &pfs.RepoInfo{}
+		return d.repos.ReadWrite(stm).Update(repo.Name, repoInfo, func() error {
+			repoInfo.Quota = quota
+			return nil
+		})
+	})
+	return err
+}
+
+// checkQuota returns an error if adding a commit with 'fileCount' files,
+// which adds 'sizeChange' bytes to the repo, would take 'repoInfo' over its
+// quota
+func checkQuota(repoInfo *pfs.RepoInfo, sizeChange uint64, fileCount uint64) error {
+	if repoInfo.Quota == nil {
+		return nil
+	}
+	if repoInfo.Quota.MaxBytes > 0 && repoInfo.SizeBytes+sizeChange > repoInfo.Quota.MaxBytes {
+		return pfsserver.ErrQuotaExceeded{
+			Repo:     repoInfo.Repo,
+			Resource: "bytes",
+			Usage:    repoInfo.SizeBytes + sizeChange,
+			Limit:    repoInfo.Quota.MaxBytes,
+		}
+	}
+	if repoInfo.Quota.MaxFiles > 0 && fileCount > repoInfo.Quota.MaxFiles {
+		return pfsserver.ErrQuotaExceeded{
+			Repo:     repoInfo.Repo,
+			Resource: "files",
+			Usage:    fileCount,
+			Limit:    repoInfo.Quota.MaxFiles,
+		}
+	}
+	return nil
+}
+
+// checkPutFileQuota returns an error if writing 'size' more bytes to 'repo'
+// would take it over its quota
+func (d *driver) checkPutFileQuota(pachClient *client.APIClient, repo *pfs.Repo, size uint64) error {
+	repoInfo := &pfs.RepoInfo{}
+	if err := d.repos.ReadOnly(pachClient.Ctx()).Get(repo.Name, repoInfo); err != nil {
+		return err
+	}
+	return checkQuota(repoInfo, size, 0)
+}
+
+// countFiles returns the number of files in 'tree'
+func countFiles(tree hashtree.HashTree) (uint64, error) {
+	var result uint64
+	if err := tree.Walk("/", func(path string, node *hashtree.NodeProto) error {
+		if node.FileNode != nil {
+			result++
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return result, nil
+}
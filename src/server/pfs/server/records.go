@@ -0,0 +1,187 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+)
+
+// readProtoRecord reads a record delimited by pfs.Delimiter_PROTO (a varint
+// length followed by that many bytes) from 'r', and returns it including its
+// length prefix. It returns io.EOF if 'r' has no more records.
+func readProtoRecord(r *bufio.Reader) ([]byte, error) {
+	var prefix []byte
+	var size uint64
+	for shift := uint(0); ; shift += 7 {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(prefix) > 0 {
+				return nil, fmt.Errorf("truncated protobuf record length")
+			}
+			return nil, err
+		}
+		prefix = append(prefix, b)
+		if len(prefix) > binary.MaxVarintLen64 {
+			return nil, fmt.Errorf("invalid protobuf record length")
+		}
+		size |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			break
+		}
+	}
+	if size > math.MaxInt64 {
+		return nil, fmt.Errorf("invalid protobuf record length %d", size)
+	}
+	record := bytes.NewBuffer(prefix)
+	if n, err := io.CopyN(record, r, int64(size)); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("truncated protobuf record: expected %d bytes, but only %d were written", size, n)
+		}
+		return nil, err
+	}
+	return record.Bytes(), nil
+}
+
+// lastByteWriter remembers the last byte written to it
+type lastByteWriter struct {
+	last  byte
+	empty bool
+}
+
+func (w *lastByteWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		w.last = p[len(p)-1]
+		w.empty = false
+	}
+	return len(p), nil
+}
+
+// validateRecords returns an error unless the data in 'r' is a sequence of
+// complete records delimited by 'delimiter', which can be appended to
+// safely. In particular, LINE, JSON and CSV data must end with a newline, so
+// that the next data appended starts a new record.
+func validateRecords(r io.Reader, delimiter pfs.Delimiter) error {
+	last := &lastByteWriter{empty: true}
+	bufioR := bufio.NewReader(io.TeeReader(r, last))
+	switch delimiter {
+	case pfs.Delimiter_LINE:
+		if _, err := io.Copy(ioutil.Discard, bufioR); err != nil {
+			return err
+		}
+	case pfs.Delimiter_JSON:
+		decoder := json.NewDecoder(bufioR)
+		for {
+			var value json.RawMessage
+			if err := decoder.Decode(&value); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return fmt.Errorf("invalid JSON record: %v", err)
+			}
+		}
+	case pfs.Delimiter_CSV:
+		csvReader := csv.NewReader(bufioR)
+		csvReader.FieldsPerRecord = -1
+		csvReader.ReuseRecord = true
+		for {
+			if _, err := csvReader.Read(); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return fmt.Errorf("invalid CSV record: %v", err)
+			}
+		}
+	case pfs.Delimiter_PROTO:
+		for {
+			if _, err := readProtoRecord(bufioR); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("records delimited by %s can't be appended", delimiter)
+	}
+	if !last.empty && last.last != '\n' {
+		return fmt.Errorf("the last %s record isn't terminated by a newline", delimiter)
+	}
+	return nil
+}
+
+// errReadAborted is returned by validatingReader's validation if it's closed
+// before all of its data has been read
+var errReadAborted = errors.New("read aborted")
+
+// validatingReader passes through the data read from another reader, while
+// checking (with validateRecords) that it's made of complete records. Once
+// all of the data has been read, it returns the validation error, if any,
+// instead of io.EOF.
+type validatingReader struct {
+	r      io.Reader
+	pw     *io.PipeWriter
+	done   chan error
+	err    error
+	closed bool
+}
+
+func newValidatingReader(r io.Reader, delimiter pfs.Delimiter) *validatingReader {
+	pr, pw := io.Pipe()
+	v := &validatingReader{r: r, pw: pw, done: make(chan error, 1)}
+	go func() {
+		err := validateRecords(pr, delimiter)
+		// keep reading, so that writes to 'pw' don't block after an error
+		io.Copy(ioutil.Discard, pr)
+		v.done <- err
+	}()
+	return v
+}
+
+func (v *validatingReader) Read(p []byte) (int, error) {
+	if v.closed {
+		return 0, v.finish()
+	}
+	n, err := v.r.Read(p)
+	if n > 0 {
+		if _, err := v.pw.Write(p[:n]); err != nil {
+			return n, err
+		}
+	}
+	if err == io.EOF {
+		v.pw.Close()
+		v.closed = true
+		return n, v.finish()
+	}
+	return n, err
+}
+
+// finish waits for the validation of the data read to finish, and returns
+// its error, or io.EOF if the data is valid
+func (v *validatingReader) finish() error {
+	if v.done != nil {
+		v.err = <-v.done
+		v.done = nil
+	}
+	if v.err != nil {
+		return v.err
+	}
+	return io.EOF
+}
+
+// Close stops the validation, if the data hasn't all been read
+func (v *validatingReader) Close() error {
+	if !v.closed {
+		v.pw.CloseWithError(errReadAborted)
+		v.closed = true
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+)
+
+func TestReadProtoRecord(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("\x03foo\x00\x81\x01" + strings.Repeat("a", 129)))
+	record, err := readProtoRecord(r)
+	require.NoError(t, err)
+	require.Equal(t, "\x03foo", string(record))
+	record, err = readProtoRecord(r)
+	require.NoError(t, err)
+	require.Equal(t, "\x00", string(record))
+	record, err = readProtoRecord(r)
+	require.NoError(t, err)
+	require.Equal(t, 131, len(record))
+	_, err = readProtoRecord(r)
+	require.Equal(t, io.EOF, err)
+
+	_, err = readProtoRecord(bufio.NewReader(strings.NewReader("\x81")))
+	require.YesError(t, err)
+	_, err = readProtoRecord(bufio.NewReader(strings.NewReader("\x03fo")))
+	require.YesError(t, err)
+}
+
+func TestValidateRecords(t *testing.T) {
+	for _, c := range []struct {
+		delimiter pfs.Delimiter
+		data      string
+		valid     bool
+	}{
+		{pfs.Delimiter_LINE, "", true},
+		{pfs.Delimiter_LINE, "foo\nbar\n", true},
+		{pfs.Delimiter_LINE, "foo\nbar", false},
+		{pfs.Delimiter_JSON, "{\"a\": 1}\n[2]\n", true},
+		{pfs.Delimiter_JSON, "{\"a\": 1}", false},
+		{pfs.Delimiter_JSON, "{\"a\": \n", false},
+		{pfs.Delimiter_CSV, "a,b\n\"c\nd\",e\n", true},
+		{pfs.Delimiter_CSV, "a,b\n\"c\n", false},
+		{pfs.Delimiter_CSV, "a,b", false},
+		{pfs.Delimiter_PROTO, "\x03foo\x00", true},
+		{pfs.Delimiter_PROTO, "\x03fo", false},
+		{pfs.Delimiter_SQL, "", false},
+	} {
+		err := validateRecords(strings.NewReader(c.data), c.delimiter)
+		if c.valid {
+			require.NoError(t, err, "%s: %q", c.delimiter, c.data)
+		} else {
+			require.YesError(t, err, "%s: %q", c.delimiter, c.data)
+		}
+	}
+}
+
+func TestValidatingReader(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := io.Copy(&buf, newValidatingReader(strings.NewReader("foo\nbar\n"), pfs.Delimiter_LINE))
+	require.NoError(t, err)
+	require.Equal(t, "foo\nbar\n", buf.String())
+
+	_, err = io.Copy(ioutil.Discard, newValidatingReader(strings.NewReader("foo\nbar"), pfs.Delimiter_LINE))
+	require.YesError(t, err)
+
+	// closing the reader early stops the validation
+	r := newValidatingReader(strings.NewReader("foo\nbar\n"), pfs.Delimiter_LINE)
+	_, err = r.Read(make([]byte, 2))
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+}
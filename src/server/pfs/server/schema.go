@@ -0,0 +1,125 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/client/pkg/schema"
+	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
+	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
+	"github.com/pachyderm/pachyderm/src/server/pkg/hashtree"
+)
+
+// The functions in this file validate commits against their repo's schema
+// (see pfs.RepoSchema). Files are validated when their commit is finished
+// rather than as they're written, as a file may be written by several PutFile
+// calls, but a PutFile to a branch finishes its commit, so it fails if the
+// files it writes are rejected.
+
+func (d *driver) setRepoSchema(pachClient *client.APIClient, repo *pfs.Repo, repoSchema *pfs.RepoSchema) error {
+	if err := d.checkIsAuthorized(pachClient, repo, auth.Scope_OWNER); err != nil {
+		return err
+	}
+	if repoSchema != nil {
+		if _, err := schema.NewValidator(repoSchema); err != nil {
+			return err
+		}
+	}
+	_, err := col.NewSTM(pachClient.Ctx(), d.etcdClient, func(stm col.STM) error {
+		repoInfo := &pfs.RepoInfo{}
+		return d.repos.ReadWrite(stm).Update(repo.Name, repoInfo, func() error {
+			repoInfo.Schema = repoSchema
+			return nil
+		})
+	})
+	return err
+}
+
+// checkSchema validates the files in 'tree' (the tree of 'commit') that are
+// new or changed since 'parentTree' against 'repoSchema'. If its action is
+// REJECT, it returns an ErrSchemaViolation if any files don't match, and
+// otherwise it returns the files that don't match.
+func (d *driver) checkSchema(pachClient *client.APIClient, commit *pfs.Commit, repoSchema *pfs.RepoSchema, tree hashtree.HashTree, parentTree hashtree.HashTree) ([]*pfs.SchemaViolation, error) {
+	if repoSchema == nil || tree == nil {
+		return nil, nil
+	}
+	validator, err := schema.NewValidator(repoSchema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema in repo %v: %v", commit.Repo.Name, err)
+	}
+	// Collect the files before reading them, so that the tree isn't held
+	// open while they're read
+	var paths []string
+	var objects [][]*pfs.Object
+	if err := tree.Walk("/", func(p string, node *hashtree.NodeProto) error {
+		if node.FileNode == nil || !validator.Matches(p) {
+			return nil
+		}
+		if parentTree != nil {
+			if parentNode, err := parentTree.Get(p); err == nil && bytes.Equal(parentNode.Hash, node.Hash) {
+				return nil
+			}
+		}
+		fileObjects, err := fileContent(tree, p, node)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, p)
+		objects = append(objects, fileObjects)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	var violations []*pfs.SchemaViolation
+	for i, p := range paths {
+		getObjectsClient, err := pachClient.ObjectAPIClient.GetObjects(
+			pachClient.Ctx(),
+			&pfs.GetObjectsRequest{Objects: objects[i]},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := validator.Validate(grpcutil.NewStreamingBytesReader(getObjectsClient, nil)); err != nil {
+			violations = append(violations, &pfs.SchemaViolation{
+				Path:  p,
+				Error: grpcutil.ScrubGRPC(err).Error(),
+			})
+		}
+	}
+	if len(violations) > 0 && repoSchema.Action == pfs.SchemaAction_REJECT {
+		return nil, pfsserver.ErrSchemaViolation{
+			Commit:     commit,
+			Violations: violations,
+		}
+	}
+	return violations, nil
+}
+
+// fileContent returns the objects holding the content of the file 'node' at
+// 'p' in 'tree', including its directory's shared header and footer
+func fileContent(tree hashtree.HashTree, p string, node *hashtree.NodeProto) ([]*pfs.Object, error) {
+	if !node.FileNode.HasHeaderFooter {
+		return node.FileNode.Objects, nil
+	}
+	parentNode, err := tree.Get(path.Dir(p))
+	if err != nil {
+		return nil, err
+	}
+	if parentNode.DirNode == nil || parentNode.DirNode.Shared == nil {
+		return nil, fmt.Errorf("file %q has a shared header or footer, but its parent directory does not permit shared data", p)
+	}
+	var objects []*pfs.Object
+	if parentNode.DirNode.Shared.Header != nil {
+		objects = append(objects, parentNode.DirNode.Shared.Header)
+	}
+	objects = append(objects, node.FileNode.Objects...)
+	if parentNode.DirNode.Shared.Footer != nil {
+		objects = append(objects, parentNode.DirNode.Shared.Footer)
+	}
+	return objects, nil
+}
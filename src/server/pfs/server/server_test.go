@@ -1,8 +1,13 @@
 package server
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -21,9 +26,12 @@ import (
 	"github.com/gogo/protobuf/types"
 	pclient "github.com/pachyderm/pachyderm/src/client"
 	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/manifest"
 	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
 	"github.com/pachyderm/pachyderm/src/server/pkg/ancestry"
 	"github.com/pachyderm/pachyderm/src/server/pkg/hashtree"
+	"github.com/pachyderm/pachyderm/src/server/pkg/kms"
 	"github.com/pachyderm/pachyderm/src/server/pkg/obj"
 	"github.com/pachyderm/pachyderm/src/server/pkg/sql"
 	pfssync "github.com/pachyderm/pachyderm/src/server/pkg/sync"
@@ -537,6 +545,657 @@ func TestDeleteCommit(t *testing.T) {
 	require.Equal(t, 1, len(branches))
 }
 
+func TestSquashCommit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	c := GetPachClient(t)
+	repo := tu.UniqueString("TestSquashCommit")
+	require.NoError(t, c.CreateRepo(repo))
+
+	// create enough commits that they're deleted in several batches
+	numCommits := 2*squashBatchSize + 2
+	var commits []*pfs.Commit
+	for i := 0; i < numCommits; i++ {
+		commit, err := c.StartCommit(repo, "master")
+		require.NoError(t, err)
+		_, err = c.PutFile(repo, commit.ID, fmt.Sprintf("file%d", i), strings.NewReader("foo\n"))
+		require.NoError(t, err)
+		require.NoError(t, c.FinishCommit(repo, commit.ID))
+		commits = append(commits, commit)
+	}
+
+	// the head of a branch can't be squashed
+	require.NoError(t, c.CreateBranch(repo, "other", commits[1].ID, nil))
+	require.YesError(t, c.SquashCommit(repo, commits[1].ID, "master"))
+	require.NoError(t, c.DeleteBranch(repo, "other", false))
+
+	require.NoError(t, c.SquashCommit(repo, commits[1].ID, "master"))
+	commitInfos, err := c.ListCommit(repo, "master", "", 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(commitInfos))
+	require.Equal(t, commits[numCommits-1].ID, commitInfos[0].Commit.ID)
+	require.Equal(t, commits[0].ID, commitInfos[0].ParentCommit.ID)
+	commitInfo, err := c.InspectCommit(repo, commits[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(commitInfo.ChildCommits))
+	require.Equal(t, commits[numCommits-1].ID, commitInfo.ChildCommits[0].ID)
+	_, err = c.InspectCommit(repo, commits[1].ID)
+	require.YesError(t, err)
+
+	// the squashed commit keeps its files
+	fileInfos, err := c.ListFile(repo, "master", "")
+	require.NoError(t, err)
+	require.Equal(t, numCommits, len(fileInfos))
+}
+
+func TestRepoRetention(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	c := GetPachClient(t)
+	repo := tu.UniqueString("TestRepoRetention")
+	require.NoError(t, c.CreateRepo(repo))
+
+	require.NoError(t, c.UpdateRepoRetention(repo, &pfs.RetentionPolicy{
+		KeepCommits: 10,
+		Branches:    []string{"master"},
+	}))
+	repoInfo, err := c.InspectRepo(repo)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), repoInfo.Retention.KeepCommits)
+	require.Equal(t, []string{"master"}, repoInfo.Retention.Branches)
+
+	// invalid policies are rejected, and the old policy is kept
+	require.YesError(t, c.UpdateRepoRetention(repo, &pfs.RetentionPolicy{KeepCommits: -1}))
+	repoInfo, err = c.InspectRepo(repo)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), repoInfo.Retention.KeepCommits)
+
+	// a nil policy removes the repo's policy
+	require.NoError(t, c.UpdateRepoRetention(repo, nil))
+	repoInfo, err = c.InspectRepo(repo)
+	require.NoError(t, err)
+	require.Nil(t, repoInfo.Retention)
+}
+
+func TestRepoQuota(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	c := GetPachClient(t)
+	repo := tu.UniqueString("TestRepoQuota")
+	require.NoError(t, c.CreateRepo(repo))
+	require.NoError(t, c.SetRepoQuota(repo, &pfs.RepoQuota{MaxBytes: 10, MaxFiles: 2}))
+	_, err := c.PutFile(repo, "master", "foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	repoInfo, err := c.InspectRepo(repo)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), repoInfo.Quota.MaxBytes)
+	require.Equal(t, uint64(4), repoInfo.SizeBytes)
+	require.Equal(t, uint64(1), repoInfo.FileCount)
+
+	// PutFile fails if it writes more data than the quota allows
+	_, err = c.PutFile(repo, "master", "big", strings.NewReader("0123456789\n"))
+	require.YesError(t, err)
+	require.True(t, pfsserver.IsQuotaExceededErr(err))
+
+	// FinishCommit fails if the commit has too many files
+	commit, err := c.StartCommit(repo, "master")
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, commit.ID, "bar", strings.NewReader("b"))
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, commit.ID, "buzz", strings.NewReader("b"))
+	require.NoError(t, err)
+	err = c.FinishCommit(repo, commit.ID)
+	require.YesError(t, err)
+	require.True(t, pfsserver.IsQuotaExceededErr(err))
+	require.NoError(t, c.DeleteFile(repo, commit.ID, "buzz"))
+	require.NoError(t, c.FinishCommit(repo, commit.ID))
+
+	// removing the quota allows the writes
+	require.NoError(t, c.SetRepoQuota(repo, nil))
+	_, err = c.PutFile(repo, "master", "big", strings.NewReader("0123456789\n"))
+	require.NoError(t, err)
+}
+
+func TestRepoSchema(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	c := GetPachClient(t)
+	repo := tu.UniqueString("TestRepoSchema")
+	require.NoError(t, c.CreateRepo(repo))
+	_, err := c.PutFile(repo, "master", "old.json", strings.NewReader("[]"))
+	require.NoError(t, err)
+	// Invalid schemas are rejected
+	require.YesError(t, c.SetRepoSchema(repo, &pfs.RepoSchema{Json: &pfs.JSONSchema{Schema: `{"oneOf": []}`}}))
+	schema := &pfs.RepoSchema{
+		Glob: "*.json",
+		Json: &pfs.JSONSchema{Schema: `{"type": "object", "required": ["id"]}`},
+	}
+	require.NoError(t, c.SetRepoSchema(repo, schema))
+	repoInfo, err := c.InspectRepo(repo)
+	require.NoError(t, err)
+	require.Equal(t, "*.json", repoInfo.Schema.Glob)
+
+	// PutFile fails if it writes a file that doesn't match the schema, but
+	// unchanged files and files that the schema doesn't apply to aren't
+	// validated
+	_, err = c.PutFile(repo, "master", "a.json", strings.NewReader(`{"id": 1}`+"\n"+`{"id": 2}`))
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, "master", "a.txt", strings.NewReader("[]"))
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, "master", "b.json", strings.NewReader(`{"name": "b"}`))
+	require.YesError(t, err)
+	require.True(t, pfsserver.IsSchemaViolationErr(err))
+
+	// FinishCommit fails, leaving the commit open, if a file doesn't match
+	commit, err := c.StartCommit(repo, "master")
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, commit.ID, "a.json", strings.NewReader(`{"name": "a"}`))
+	require.NoError(t, err)
+	err = c.FinishCommit(repo, commit.ID)
+	require.YesError(t, err)
+	require.True(t, pfsserver.IsSchemaViolationErr(err))
+	_, err = c.PutFileOverwrite(repo, commit.ID, "a.json", strings.NewReader(`{"id": 3}`), 0)
+	require.NoError(t, err)
+	require.NoError(t, c.FinishCommit(repo, commit.ID))
+
+	// Flagged files are recorded in their commit
+	schema.Action = pfs.SchemaAction_FLAG
+	require.NoError(t, c.SetRepoSchema(repo, schema))
+	_, err = c.PutFile(repo, "master", "b.json", strings.NewReader(`{"name": "b"}`))
+	require.NoError(t, err)
+	commitInfo, err := c.InspectCommit(repo, "master")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(commitInfo.SchemaViolations))
+	require.Equal(t, "/b.json", commitInfo.SchemaViolations[0].Path)
+
+	require.NoError(t, c.SetRepoSchema(repo, nil))
+	repoInfo, err = c.InspectRepo(repo)
+	require.NoError(t, err)
+	require.Nil(t, repoInfo.Schema)
+}
+
+func TestInspectStorage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	c := GetPachClient(t)
+	repo1 := tu.UniqueString("TestInspectStorage")
+	repo2 := tu.UniqueString("TestInspectStorage")
+	require.NoError(t, c.CreateRepo(repo1))
+	require.NoError(t, c.CreateRepo(repo2))
+
+	// The same data is written to two files in repo1, and one in repo2, so
+	// it's only stored once
+	data := generateRandomString(1024 * 1024)
+	for _, file := range []string{"a", "b"} {
+		_, err := c.PutFile(repo1, "master", file, strings.NewReader(data))
+		require.NoError(t, err)
+	}
+	_, err := c.PutFile(repo2, "master", "c", strings.NewReader(data))
+	require.NoError(t, err)
+
+	storageInfo, err := c.InspectStorage(repo1)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(storageInfo.Repos))
+	repoStorageInfo := storageInfo.Repos[0]
+	require.Equal(t, repo1, repoStorageInfo.Repo.Name)
+	require.Equal(t, uint64(2*len(data)), repoStorageInfo.LogicalBytes)
+	require.Equal(t, uint64(len(data)), repoStorageInfo.PhysicalBytes)
+	require.Equal(t, uint64(1), repoStorageInfo.ObjectCount)
+	require.True(t, storageInfo.LogicalBytes >= uint64(3*len(data)))
+
+	storageInfo, err = c.InspectStorage()
+	require.NoError(t, err)
+	var found int
+	for _, repoStorageInfo := range storageInfo.Repos {
+		if repoStorageInfo.Repo.Name == repo1 || repoStorageInfo.Repo.Name == repo2 {
+			found++
+			require.Equal(t, uint64(len(data)), repoStorageInfo.PhysicalBytes)
+		}
+	}
+	require.Equal(t, 2, found)
+
+	_, err = c.InspectStorage(tu.UniqueString("nonexistent"))
+	require.YesError(t, err)
+}
+
+func TestDedupSavings(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	c := GetPachClient(t)
+	repo := tu.UniqueString("TestDedupSavings")
+	require.NoError(t, c.CreateRepo(repo))
+
+	// A large file is split into several chunks. Chunk boundaries depend on
+	// the data, so it's generated from a fixed seed to always split the same
+	// way.
+	rng := rand.New(rand.NewSource(1))
+	dataBytes := make([]byte, 16*pfs.MinChunkSize)
+	for i := range dataBytes {
+		dataBytes[i] = byte('a' + rng.Intn(26))
+	}
+	data := string(dataBytes)
+	chunker := pfs.NewChunker(strings.NewReader(data))
+	var chunks int
+	for more := true; more; chunks++ {
+		_, err := io.Copy(ioutil.Discard, chunker)
+		require.NoError(t, err)
+		more, err = chunker.Next()
+		require.NoError(t, err)
+	}
+	require.True(t, chunks > 1)
+	_, err := c.PutFile(repo, "master", "file", strings.NewReader(data))
+	require.NoError(t, err)
+	repoInfo, err := c.InspectRepo(repo)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), repoInfo.DedupSavingsBytes)
+
+	// Writing the same data again doesn't store any new chunks
+	_, err = c.PutFile(repo, "master", "copy", strings.NewReader(data))
+	require.NoError(t, err)
+	repoInfo, err = c.InspectRepo(repo)
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(data)), repoInfo.DedupSavingsBytes)
+
+	// Editing the end of the data only stores the chunks around the edit
+	edited := data[:len(data)-100] + "edited"
+	_, err = c.PutFile(repo, "master", "edited", strings.NewReader(edited))
+	require.NoError(t, err)
+	repoInfo, err = c.InspectRepo(repo)
+	require.NoError(t, err)
+	require.True(t, repoInfo.DedupSavingsBytes > uint64(len(data)))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.GetFile(repo, "master", "edited", 0, 0, &buf))
+	require.Equal(t, edited, buf.String())
+	fileInfo, err := c.InspectFile(repo, "master", "edited")
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(edited)), fileInfo.SizeBytes)
+}
+
+func TestFileHash(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	c := GetPachClient(t)
+	repo := tu.UniqueString("TestFileHash")
+	require.NoError(t, c.CreateRepo(repo))
+
+	// client.FileHash must agree with the hashes pachd gives files, for both
+	// small files and files split into several chunks
+	rng := rand.New(rand.NewSource(1))
+	big := make([]byte, 8*pfs.MinChunkSize)
+	for i := range big {
+		big[i] = byte('a' + rng.Intn(26))
+	}
+	for _, data := range []string{"", "foo", string(big)} {
+		_, err := c.PutFileOverwrite(repo, "master", "file", strings.NewReader(data), 0)
+		require.NoError(t, err)
+		fileInfo, err := c.InspectFile(repo, "master", "file")
+		require.NoError(t, err)
+		hash, err := pclient.FileHash(strings.NewReader(data))
+		require.NoError(t, err)
+		require.Equal(t, fileInfo.Hash, hash)
+	}
+
+	// so syncing a directory only uploads the files that changed
+	dir, err := ioutil.TempDir("", "TestFileHash")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b"), big, 0644))
+	commit, err := c.StartCommit(repo, "master")
+	require.NoError(t, err)
+	sync := &pclient.PutFileDirOptions{Sync: true}
+	// the prefix doesn't exist yet
+	require.NoError(t, c.PutFileDir(dir, repo, commit.ID, "/dir", sync))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("changed"), 0644))
+	var uploaded []string
+	pc := c.WithProgress(func(p pclient.Progress) {
+		if p.Bytes == p.TotalBytes {
+			uploaded = append(uploaded, p.Path)
+		}
+	})
+	require.NoError(t, pc.PutFileDir(dir, repo, commit.ID, "/dir", sync))
+	require.True(t, len(uploaded) > 0)
+	for _, p := range uploaded {
+		require.Equal(t, "/dir/a", p)
+	}
+	var buf bytes.Buffer
+	require.NoError(t, c.GetFile(repo, commit.ID, "/dir/a", 0, 0, &buf))
+	require.Equal(t, "changed", buf.String())
+	require.YesError(t, c.PutFileDir(dir, "missing", commit.ID, "/dir", sync))
+}
+
+func TestRepoCompression(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	c := GetPachClient(t)
+	repo := tu.UniqueString("TestRepoCompression")
+	_, err := c.PfsAPIClient.CreateRepo(c.Ctx(), &pfs.CreateRepoRequest{
+		Repo:        pclient.NewRepo(repo),
+		Compression: &pfs.CompressionSpec{Compression: pfs.Compression_GZIP},
+	})
+	require.NoError(t, err)
+	repoInfo, err := c.InspectRepo(repo)
+	require.NoError(t, err)
+	require.Equal(t, pfs.Compression_GZIP, repoInfo.Compression.Compression)
+
+	// 'data' is written compressed, and read back transparently
+	data := strings.Repeat("foo,bar,baz\n", 100000)
+	_, err = c.PutFile(repo, "master", "gzip", strings.NewReader(data))
+	require.NoError(t, err)
+	require.NoError(t, c.UpdateRepoCompression(repo, &pfs.CompressionSpec{Compression: pfs.Compression_ZSTD, Level: 1}))
+	_, err = c.PutFileSplit(repo, "master", "zstd", pfs.Delimiter_LINE, 0, 0, 0, false, strings.NewReader(data))
+	require.NoError(t, err)
+	require.NoError(t, c.UpdateRepoCompression(repo, nil))
+	// (different data, as identical data would be deduplicated against the
+	// gzipped object)
+	uncompressedData := data + "qux\n"
+	_, err = c.PutFile(repo, "master", "uncompressed", strings.NewReader(uncompressedData))
+	require.NoError(t, err)
+
+	for _, file := range []struct {
+		path        string
+		data        string
+		compression pfs.Compression
+	}{
+		{"gzip", data, pfs.Compression_GZIP},
+		{"uncompressed", uncompressedData, pfs.Compression_UNCOMPRESSED},
+	} {
+		var buf bytes.Buffer
+		require.NoError(t, c.GetFile(repo, "master", file.path, 0, 0, &buf))
+		require.Equal(t, file.data, buf.String())
+		buf.Reset()
+		require.NoError(t, c.GetFile(repo, "master", file.path, 12, 7, &buf))
+		require.Equal(t, "foo,bar", buf.String())
+
+		fileInfo, err := c.InspectFile(repo, "master", file.path)
+		require.NoError(t, err)
+		require.Equal(t, uint64(len(file.data)), fileInfo.SizeBytes)
+		objectInfo, err := c.InspectObject(fileInfo.Objects[0].Hash)
+		require.NoError(t, err)
+		require.Equal(t, file.compression, objectInfo.BlockRef.Compression)
+		if file.compression != pfs.Compression_UNCOMPRESSED {
+			require.True(t, pfsserver.ByteRangeSize(objectInfo.BlockRef.Range) < uint64(len(data)/10))
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, c.GetFile(repo, "master", "zstd/0000000000000001", 0, 0, &buf))
+	require.Equal(t, "foo,bar,baz\n", buf.String())
+
+	// invalid compression levels are rejected
+	require.YesError(t, c.UpdateRepoCompression(repo, &pfs.CompressionSpec{Compression: pfs.Compression_GZIP, Level: 10}))
+}
+
+func TestRepoEncryption(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	os.Setenv(kms.LocalKeyEnvPrefix+"TESTREPOENCRYPTION", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+	defer os.Unsetenv(kms.LocalKeyEnvPrefix + "TESTREPOENCRYPTION")
+	c := GetPachClient(t)
+	repo := tu.UniqueString("TestRepoEncryption")
+	_, err := c.PfsAPIClient.CreateRepo(c.Ctx(), &pfs.CreateRepoRequest{
+		Repo:       pclient.NewRepo(repo),
+		Encryption: &pfs.EncryptionSpec{Key: "local://testrepoencryption"},
+	})
+	require.NoError(t, err)
+	repoInfo, err := c.InspectRepo(repo)
+	require.NoError(t, err)
+	require.Equal(t, "local://testrepoencryption", repoInfo.Encryption.Key)
+
+	// 'data' is written encrypted, and read back transparently
+	data := strings.Repeat("foo,bar,baz\n", 100000)
+	_, err = c.PutFile(repo, "master", "encrypted", strings.NewReader(data))
+	require.NoError(t, err)
+	_, err = c.PutFileSplit(repo, "master", "split", pfs.Delimiter_LINE, 0, 0, 0, false, strings.NewReader(data))
+	require.NoError(t, err)
+	require.NoError(t, c.UpdateRepoEncryption(repo, ""))
+	_, err = c.PutFile(repo, "master", "unencrypted", strings.NewReader(data))
+	require.NoError(t, err)
+
+	var objects []*pfs.Object
+	for _, file := range []string{"encrypted", "unencrypted"} {
+		var buf bytes.Buffer
+		require.NoError(t, c.GetFile(repo, "master", file, 0, 0, &buf))
+		require.Equal(t, data, buf.String())
+		buf.Reset()
+		require.NoError(t, c.GetFile(repo, "master", file, 12, 7, &buf))
+		require.Equal(t, "foo,bar", buf.String())
+
+		fileInfo, err := c.InspectFile(repo, "master", file)
+		require.NoError(t, err)
+		require.Equal(t, uint64(len(data)), fileInfo.SizeBytes)
+		objectInfo, err := c.InspectObject(fileInfo.Objects[0].Hash)
+		require.NoError(t, err)
+		if file == "encrypted" {
+			require.Equal(t, "local://testrepoencryption", objectInfo.BlockRef.Encryption.Key)
+		} else {
+			require.Nil(t, objectInfo.BlockRef.Encryption)
+		}
+		objects = append(objects, fileInfo.Objects[0])
+	}
+	// the same data is stored separately with and without encryption
+	require.NotEqual(t, objects[0].Hash, objects[1].Hash)
+	var buf bytes.Buffer
+	require.NoError(t, c.GetFile(repo, "master", "split/0000000000000001", 0, 0, &buf))
+	require.Equal(t, "foo,bar,baz\n", buf.String())
+
+	// invalid keys are rejected
+	require.YesError(t, c.UpdateRepoEncryption(repo, "alias/pachyderm"))
+}
+
+func TestBranchTrigger(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	c := GetPachClient(t)
+	repo := tu.UniqueString("TestBranchTrigger")
+	require.NoError(t, c.CreateRepo(repo))
+	require.NoError(t, c.CreateBranchTrigger(repo, "staging", "", &pfs.Trigger{
+		Branch:  "master",
+		Commits: 3,
+	}))
+	branchInfo, err := c.InspectBranch(repo, "staging")
+	require.NoError(t, err)
+	require.Equal(t, "master", branchInfo.Trigger.Branch)
+
+	// staging only moves once three commits have been added to master
+	for i := 0; i < 3; i++ {
+		branchInfo, err := c.InspectBranch(repo, "staging")
+		require.NoError(t, err)
+		require.Nil(t, branchInfo.Head)
+		_, err = c.PutFile(repo, "master", fmt.Sprintf("file%d", i), strings.NewReader("foo\n"))
+		require.NoError(t, err)
+	}
+	master, err := c.InspectCommit(repo, "master")
+	require.NoError(t, err)
+	staging, err := c.InspectCommit(repo, "staging")
+	require.NoError(t, err)
+	require.Equal(t, master.Commit.ID, staging.Commit.ID)
+
+	// the next commit is counted from staging's new head
+	_, err = c.PutFile(repo, "master", "file3", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	staging2, err := c.InspectCommit(repo, "staging")
+	require.NoError(t, err)
+	require.Equal(t, staging.Commit.ID, staging2.Commit.ID)
+
+	// invalid triggers are rejected
+	require.YesError(t, c.CreateBranchTrigger(repo, "master", "", &pfs.Trigger{Branch: "staging", Commits: 1}))
+	require.YesError(t, c.CreateBranchTrigger(repo, "other", "", &pfs.Trigger{Branch: "master"}))
+	require.YesError(t, c.CreateBranchTrigger(repo, "other", "", &pfs.Trigger{Branch: "master", CronSpec: "not a cron spec"}))
+}
+
+func TestDeleteBranchCascade(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	c := GetPachClient(t)
+	repo := tu.UniqueString("TestDeleteBranchCascade")
+	out := tu.UniqueString("TestDeleteBranchCascadeOut")
+	require.NoError(t, c.CreateRepo(repo))
+	require.NoError(t, c.CreateRepo(out))
+	_, err := c.PutFile(repo, "master", "foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	master, err := c.InspectCommit(repo, "master")
+	require.NoError(t, err)
+	require.NoError(t, c.CreateBranch(repo, "exp", master.Commit.ID, nil))
+	_, err = c.PutFile(repo, "exp", "bar", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+	exp, err := c.InspectCommit(repo, "exp")
+	require.NoError(t, err)
+	require.NoError(t, c.CreateBranch(out, "master", "", []*pfs.Branch{pclient.NewBranch(repo, "exp")}))
+
+	// exp can't be deleted while out@master is provenant on it
+	require.YesError(t, c.DeleteBranch(repo, "exp", false))
+
+	require.NoError(t, c.DeleteBranchWithOptions(repo, "exp", &pclient.DeleteBranchOptions{
+		Cascade:       true,
+		DeleteCommits: true,
+	}))
+	_, err = c.InspectBranch(repo, "exp")
+	require.YesError(t, err)
+	_, err = c.InspectBranch(out, "master")
+	require.YesError(t, err)
+	// the commit that only exp reached is deleted, but master's isn't
+	_, err = c.InspectCommit(repo, exp.Commit.ID)
+	require.YesError(t, err)
+	_, err = c.InspectCommit(repo, master.Commit.ID)
+	require.NoError(t, err)
+}
+
+func TestBranchProtection(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	c := GetPachClient(t)
+	repo := tu.UniqueString("TestBranchProtection")
+	require.NoError(t, c.CreateRepo(repo))
+	_, err := c.PutFile(repo, "master", "foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	commit1, err := c.InspectCommit(repo, "master")
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, "master", "bar", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, c.SetBranchProtection(repo, "master", &pfs.BranchProtection{
+		NoDirectWrites: true,
+		NoDeleteCommit: true,
+	}))
+	branchInfo, err := c.InspectBranch(repo, "master")
+	require.NoError(t, err)
+	require.True(t, branchInfo.Protection.NoDirectWrites)
+	require.True(t, branchInfo.Protection.NoDeleteCommit)
+
+	// direct writes are rejected
+	_, err = c.StartCommit(repo, "master")
+	require.YesError(t, err)
+	_, err = c.PutFile(repo, "master", "baz", strings.NewReader("baz\n"))
+	require.YesError(t, err)
+	require.YesError(t, c.DeleteFile(repo, "master", "foo"))
+	require.YesError(t, c.CopyFile(repo, "master", "foo", repo, "master", "baz", false))
+
+	// as is deleting commits in the branch's history
+	require.YesError(t, c.DeleteCommit(repo, commit1.Commit.ID))
+	require.YesError(t, c.DeleteCommit(repo, "master"))
+
+	// a commit that's only on an unprotected branch can be deleted
+	_, err = c.PutFile(repo, "dev", "baz", strings.NewReader("baz\n"))
+	require.NoError(t, err)
+	// the protected branch can't be re-pointed or deleted
+	require.YesError(t, c.CreateBranch(repo, "master", "dev", nil))
+	require.YesError(t, c.DeleteBranch(repo, "master", false))
+	require.NoError(t, c.DeleteCommit(repo, "dev"))
+
+	// deleting a branch doesn't delete commits that its protection covers
+	_, err = c.PutFile(repo, "staging", "baz", strings.NewReader("baz\n"))
+	require.NoError(t, err)
+	require.NoError(t, c.SetBranchProtection(repo, "staging", &pfs.BranchProtection{NoDeleteCommit: true}))
+	require.YesError(t, c.DeleteBranchWithOptions(repo, "staging", &pclient.DeleteBranchOptions{DeleteCommits: true}))
+	_, err = c.InspectBranch(repo, "staging")
+	require.NoError(t, err)
+
+	// removing the protection allows writes again
+	require.NoError(t, c.SetBranchProtection(repo, "master", nil))
+	_, err = c.PutFile(repo, "master", "baz", strings.NewReader("baz\n"))
+	require.NoError(t, err)
+}
+
+func TestBranchProtectionCommitID(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	c := GetPachClient(t)
+	repo := tu.UniqueString("TestBranchProtectionCommitID")
+	require.NoError(t, c.CreateRepo(repo))
+	_, err := c.PutFile(repo, "master", "foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	// an open head, like a pipeline's output commit
+	commit, err := c.StartCommit(repo, "master")
+	require.NoError(t, err)
+	require.NoError(t, c.SetBranchProtection(repo, "master", &pfs.BranchProtection{NoDirectWrites: true}))
+
+	// writes addressed to the head by its ID are rejected too
+	_, err = c.PutFile(repo, commit.ID, "bar", strings.NewReader("bar\n"))
+	require.YesError(t, err)
+	require.Matches(t, "only pipelines may write to it", err.Error())
+	require.YesError(t, c.DeleteFile(repo, commit.ID, "foo"))
+	require.YesError(t, c.CopyFile(repo, commit.ID, "foo", repo, commit.ID, "bar", false))
+	files, err := c.ListFile(repo, commit.ID, "")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(files))
+
+	// a commit that isn't the head of a protected branch can be written to
+	other, err := c.StartCommit(repo, "dev")
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, other.ID, "bar", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+}
+
+func TestReadOutputCommitWithTree(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	c := GetPachClient(t)
+	require.NoError(t, c.CreateRepo("in"))
+	require.NoError(t, c.CreateRepo("out"))
+	require.NoError(t, c.CreateBranch("out", "master", "", []*pfs.Branch{pclient.NewBranch("in", "master")}))
+	_, err := c.PutFile("in", "master", "a", strings.NewReader("foo"))
+	require.NoError(t, err)
+
+	// An output commit finished with a whole tree (rather than the split
+	// trees a pipeline writes) is read from that tree
+	_, err = c.PutFile("out", "master", "dir/b", strings.NewReader("bar"))
+	require.NoError(t, err)
+	require.NoError(t, c.FinishCommit("out", "master"))
+	var buf bytes.Buffer
+	require.NoError(t, c.GetFile("out", "master", "dir/b", 0, 0, &buf))
+	require.Equal(t, "bar", buf.String())
+	fileInfo, err := c.InspectFile("out", "master", "dir/b")
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), fileInfo.SizeBytes)
+	fileInfos, err := c.ListFile("out", "master", "dir")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(fileInfos))
+	fileInfos, err = c.GlobFile("out", "master", "/*/*")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(fileInfos))
+	var paths []string
+	require.NoError(t, c.Walk("out", "master", "/", func(fileInfo *pfs.FileInfo) error {
+		paths = append(paths, fileInfo.File.Path)
+		return nil
+	}))
+	require.Equal(t, []string{"/", "/dir", "/dir/b"}, paths)
+}
+
 func TestDeleteCommitOnlyCommitInBranch(t *testing.T) {
 	client := GetPachClient(t)
 
@@ -814,6 +1473,68 @@ func TestProvenance(t *testing.T) {
 	require.Equal(t, 4, len(commitInfo.Provenance))
 }
 
+func TestProvenanceQuery(t *testing.T) {
+	client := GetPachClient(t)
+
+	// A ─▶ B ─▶ C ─▶ D
+	//           ▲
+	// E ────────╯
+	for _, repo := range []string{"A", "B", "C", "D", "E"} {
+		require.NoError(t, client.CreateRepo(repo))
+	}
+	require.NoError(t, client.CreateBranch("B", "master", "", []*pfs.Branch{pclient.NewBranch("A", "master")}))
+	require.NoError(t, client.CreateBranch("C", "master", "", []*pfs.Branch{pclient.NewBranch("B", "master"), pclient.NewBranch("E", "master")}))
+	require.NoError(t, client.CreateBranch("D", "master", "", []*pfs.Branch{pclient.NewBranch("C", "master")}))
+	ECommit, err := client.StartCommit("E", "master")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit("E", ECommit.ID))
+	ACommit, err := client.StartCommit("A", "master")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit("A", ACommit.ID))
+
+	heads := make(map[string]string)
+	for _, repo := range []string{"A", "B", "C", "D", "E"} {
+		commitInfo, err := client.InspectCommit(repo, "master")
+		require.NoError(t, err)
+		heads[repo] = commitInfo.Commit.ID
+	}
+	edges := func(response *pfs.ProvenanceQueryResponse) []string {
+		var result []string
+		for _, edge := range response.Edges {
+			require.Equal(t, heads[edge.Upstream.Repo.Name], edge.Upstream.ID)
+			require.Equal(t, heads[edge.Downstream.Repo.Name], edge.Downstream.ID)
+			result = append(result, edge.Upstream.Repo.Name+edge.Downstream.Repo.Name)
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	response, err := client.ProvenanceQuery("D", "master", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"AB", "BC", "CD", "EC"}, edges(response))
+	require.Equal(t, 5, len(response.Commits))
+	require.Equal(t, "D", response.Commits[0].Commit.Repo.Name)
+
+	response, err = client.ProvenanceQuery("D", "master", &pclient.ProvenanceQueryOptions{MaxDepth: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"BC", "CD", "EC"}, edges(response))
+
+	response, err = client.ProvenanceQuery("A", ACommit.ID, &pclient.ProvenanceQueryOptions{
+		Direction: pfs.ProvenanceDirection_DOWNSTREAM,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"AB", "BC", "CD"}, edges(response))
+
+	// Filtering by repo connects the remaining commits through the others
+	response, err = client.ProvenanceQuery("C", "master", &pclient.ProvenanceQueryOptions{
+		Direction: pfs.ProvenanceDirection_BOTH,
+		Repos:     []string{"A", "D"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"AC", "CD"}, edges(response))
+	require.Equal(t, 3, len(response.Commits))
+}
+
 func TestSimple(t *testing.T) {
 	client := GetPachClient(t)
 
@@ -1419,18 +2140,141 @@ func TestListFile3(t *testing.T) {
 
 	fileInfos, err = client.ListFile(repo, "master", "dir")
 	require.NoError(t, err)
-	require.Equal(t, 3, len(fileInfos))
-	require.Equal(t, int(fileInfos[2].SizeBytes), len(fileContent))
+	require.Equal(t, 3, len(fileInfos))
+	require.Equal(t, int(fileInfos[2].SizeBytes), len(fileContent))
+
+	_, err = client.StartCommit(repo, "master")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, "master", "file", strings.NewReader(fileContent))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, "master"))
+
+	fileInfos, err = client.ListFile(repo, "master", "/")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(fileInfos))
+}
+
+func TestListFileFiltered(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	c := GetPachClient(t)
+	repo := tu.UniqueString("TestListFileFiltered")
+	require.NoError(t, c.CreateRepo(repo))
+
+	commit1, err := c.StartCommit(repo, "master")
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, commit1.ID, "/small", strings.NewReader("a"))
+	require.NoError(t, err)
+	_, err = c.PutFileWithMetadata(repo, commit1.ID, "/big", strings.NewReader(strings.Repeat("a", 100)), map[string]string{"label": "x"})
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, commit1.ID, "/unchanged", strings.NewReader("foo"))
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, commit1.ID, "/deleted", strings.NewReader("foo"))
+	require.NoError(t, err)
+	require.NoError(t, c.FinishCommit(repo, commit1.ID))
+	commit2, err := c.StartCommit(repo, "master")
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, commit2.ID, "/small", strings.NewReader("b"))
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, commit2.ID, "/new", strings.NewReader("new"))
+	require.NoError(t, err)
+	require.NoError(t, c.DeleteFile(repo, commit2.ID, "/deleted"))
+	require.NoError(t, c.FinishCommit(repo, commit2.ID))
+
+	paths := func(commitID string, filter *pfs.FileFilter) []string {
+		fileInfos, err := c.ListFileFiltered(repo, commitID, "/", filter)
+		require.NoError(t, err)
+		var result []string
+		for _, fileInfo := range fileInfos {
+			result = append(result, fileInfo.File.Path)
+		}
+		sort.Strings(result)
+		return result
+	}
+	since := pclient.NewCommit(repo, commit1.ID)
+	require.Equal(t, []string{"/big", "/new", "/small", "/unchanged"}, paths("master", nil))
+	require.Equal(t, []string{"/big"}, paths("master", &pfs.FileFilter{MinSizeBytes: 10}))
+	require.Equal(t, []string{"/big"}, paths("master", &pfs.FileFilter{Metadata: map[string]string{"label": "x"}}))
+	require.Equal(t, []string{"/new", "/small"}, paths("master", &pfs.FileFilter{ModifiedSince: since}))
+	require.Equal(t, []string{"/small"}, paths("master", &pfs.FileFilter{ModifiedSince: since, MaxSizeBytes: 2}))
+	// nothing is modified since the commit itself, and 'since' may be a branch
+	require.Equal(t, 0, len(paths("master", &pfs.FileFilter{ModifiedSince: pclient.NewCommit(repo, "master")})))
+
+	// files in an open commit are compared with 'since', as are files since
+	// an open commit
+	commit3, err := c.StartCommit(repo, "master")
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, commit3.ID, "/unchanged", strings.NewReader("bar"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"/new", "/small", "/unchanged"}, paths(commit3.ID, &pfs.FileFilter{ModifiedSince: since}))
+	require.Equal(t, []string{"/deleted", "/small", "/unchanged"}, paths(commit1.ID, &pfs.FileFilter{ModifiedSince: pclient.NewCommit(repo, commit3.ID)}))
 
-	_, err = client.StartCommit(repo, "master")
+	_, err = c.ListFileFiltered(repo, "master", "/", &pfs.FileFilter{ModifiedSince: pclient.NewCommit(repo, "missing")})
+	require.YesError(t, err)
+}
+
+func TestListFilePaging(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	c := GetPachClient(t)
+	repo := tu.UniqueString("TestListFilePaging")
+	require.NoError(t, c.CreateRepo(repo))
+
+	// The directory has more files than fit on one page, and a sibling that
+	// sorts after it
+	commit1, err := c.StartCommit(repo, "master")
 	require.NoError(t, err)
-	_, err = client.PutFile(repo, "master", "file", strings.NewReader(fileContent))
+	var expected []string
+	for i := 0; i < 25; i++ {
+		p := fmt.Sprintf("/dir/file%02d", i)
+		_, err = c.PutFile(repo, commit1.ID, p, strings.NewReader(p))
+		require.NoError(t, err)
+		expected = append(expected, p)
+	}
+	_, err = c.PutFile(repo, commit1.ID, "/dir2/file", strings.NewReader("foo"))
 	require.NoError(t, err)
-	require.NoError(t, client.FinishCommit(repo, "master"))
+	require.NoError(t, c.FinishCommit(repo, commit1.ID))
 
-	fileInfos, err = client.ListFile(repo, "master", "/")
+	page := func(commitID string, pageSize int64, cursor string) []string {
+		response, err := c.PfsAPIClient.ListFile(c.Ctx(), &pfs.ListFileRequest{
+			File:     pclient.NewFile(repo, commitID, "/dir"),
+			PageSize: pageSize,
+			Cursor:   cursor,
+		})
+		require.NoError(t, err)
+		var result []string
+		for _, fileInfo := range response.FileInfo {
+			result = append(result, fileInfo.File.Path)
+		}
+		return result
+	}
+	require.Equal(t, expected[:10], page("master", 10, ""))
+	require.Equal(t, expected[10:20], page("master", 10, expected[9]))
+	require.Equal(t, expected[20:], page("master", 10, expected[19]))
+	require.Equal(t, []string(nil), page("master", 10, expected[24]))
+	// a cursor without a page size returns the rest of the directory
+	require.Equal(t, expected[23:], page("master", 0, expected[22]))
+
+	var paths []string
+	it := c.ListFileIter(repo, "master", "/dir", 10)
+	for it.Next() {
+		paths = append(paths, it.FileInfo().File.Path)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, expected, paths)
+
+	// A cursor naming a file that has since been deleted still resumes after
+	// that file
+	commit2, err := c.StartCommit(repo, "master")
 	require.NoError(t, err)
-	require.Equal(t, 2, len(fileInfos))
+	require.NoError(t, c.DeleteFile(repo, commit2.ID, expected[9]))
+	require.NoError(t, c.FinishCommit(repo, commit2.ID))
+	require.Equal(t, expected[10:20], page("master", 10, expected[9]))
+	require.Equal(t, append(expected[:9:9], expected[10]), page("master", 10, ""))
+	// the previous commit still has the file
+	require.Equal(t, expected[9:19], page(commit1.ID, 10, expected[8]))
 }
 
 func TestPutFileTypeConflict(t *testing.T) {
@@ -1868,6 +2712,48 @@ func TestSubscribeCommit(t *testing.T) {
 	commitIter.Close()
 }
 
+func TestSubscribeCommits(t *testing.T) {
+	client := GetPachClient(t)
+
+	for _, repo := range []string{"images-a", "images-b", "other"} {
+		require.NoError(t, client.CreateRepo(repo))
+	}
+	var commits []*pfs.Commit
+	for _, repo := range []string{"images-b", "other", "images-a", "images-b"} {
+		commit, err := client.StartCommit(repo, "master")
+		require.NoError(t, err)
+		require.NoError(t, client.FinishCommit(repo, commit.ID))
+		commits = append(commits, commit)
+	}
+
+	commitIter, err := client.SubscribeCommits([]string{"other"}, "images-*", "master", false, pfs.CommitState_STARTED)
+	require.NoError(t, err)
+	defer commitIter.Close()
+	// Existing commits are returned first, oldest first across all repos
+	for _, commit := range commits {
+		commitInfo, err := commitIter.Next()
+		require.NoError(t, err)
+		require.Equal(t, commit, commitInfo.Commit)
+	}
+
+	// New commits are returned from existing repos, and from new repos that
+	// match the pattern
+	commit, err := client.StartCommit("other", "master")
+	require.NoError(t, err)
+	commitInfo, err := commitIter.Next()
+	require.NoError(t, err)
+	require.Equal(t, commit, commitInfo.Commit)
+	require.NoError(t, client.CreateRepo("images-c"))
+	require.NoError(t, client.CreateRepo("unmatched"))
+	_, err = client.StartCommit("unmatched", "master")
+	require.NoError(t, err)
+	commit, err = client.StartCommit("images-c", "master")
+	require.NoError(t, err)
+	commitInfo, err = commitIter.Next()
+	require.NoError(t, err)
+	require.Equal(t, commit, commitInfo.Commit)
+}
+
 func TestInspectRepoSimple(t *testing.T) {
 	client := GetPachClient(t)
 
@@ -2603,6 +3489,62 @@ func TestPutFileSplitCSV(t *testing.T) {
 	require.Equal(t, "\"\"\"this\"\"\",\"is\nonly\",\"a,test\"\n", contents.String())
 }
 
+func TestPutFileSplitProto(t *testing.T) {
+	c := GetPachClient(t)
+	repo := tu.UniqueString("TestPutFileSplitProto")
+	require.NoError(t, c.CreateRepo(repo))
+	// each record is a varint length, followed by that many bytes
+	_, err := c.PutFileSplit(repo, "master", "data", pfs.Delimiter_PROTO, 0, 0, 0, false,
+		strings.NewReader("\x03foo\x00\x06barbaz"))
+	require.NoError(t, err)
+	fileInfos, err := c.ListFile(repo, "master", "/data")
+	require.NoError(t, err)
+	require.Equal(t, 3, len(fileInfos))
+	var contents bytes.Buffer
+	require.NoError(t, c.GetFile(repo, "master", "/data/0000000000000002", 0, 0, &contents))
+	require.Equal(t, "\x06barbaz", contents.String())
+
+	// truncated records are rejected
+	_, err = c.PutFileSplit(repo, "master", "truncated", pfs.Delimiter_PROTO, 0, 0, 0, false,
+		strings.NewReader("\x03foo\x06bar"))
+	require.YesError(t, err)
+	require.Matches(t, "truncated", err.Error())
+}
+
+func TestPutFileAppendRecords(t *testing.T) {
+	c := GetPachClient(t)
+	repo := tu.UniqueString("TestPutFileAppendRecords")
+	require.NoError(t, c.CreateRepo(repo))
+
+	// complete records are appended to a single file
+	_, err := c.PutFileAppendRecords(repo, "master", "lines", pfs.Delimiter_LINE, strings.NewReader("foo\nbar\n"))
+	require.NoError(t, err)
+	_, err = c.PutFileAppendRecords(repo, "master", "lines", pfs.Delimiter_LINE, strings.NewReader("baz\n"))
+	require.NoError(t, err)
+	var contents bytes.Buffer
+	require.NoError(t, c.GetFile(repo, "master", "lines", 0, 0, &contents))
+	require.Equal(t, "foo\nbar\nbaz\n", contents.String())
+	_, err = c.PutFileAppendRecords(repo, "master", "records.json", pfs.Delimiter_JSON, strings.NewReader("{\"a\": 1}\n{\"b\": 2}\n"))
+	require.NoError(t, err)
+	_, err = c.PutFileAppendRecords(repo, "master", "records.pb", pfs.Delimiter_PROTO, strings.NewReader("\x03foo\x00"))
+	require.NoError(t, err)
+
+	// incomplete records are rejected, and the file is unchanged
+	_, err = c.PutFileAppendRecords(repo, "master", "lines", pfs.Delimiter_LINE, strings.NewReader("qux"))
+	require.YesError(t, err)
+	_, err = c.PutFileAppendRecords(repo, "master", "records.json", pfs.Delimiter_JSON, strings.NewReader("{\"c\": \n"))
+	require.YesError(t, err)
+	_, err = c.PutFileAppendRecords(repo, "master", "records.pb", pfs.Delimiter_PROTO, strings.NewReader("\x03fo"))
+	require.YesError(t, err)
+	contents.Reset()
+	require.NoError(t, c.GetFile(repo, "master", "lines", 0, 0, &contents))
+	require.Equal(t, "foo\nbar\nbaz\n", contents.String())
+
+	// SQL records can't be appended
+	_, err = c.PutFileAppendRecords(repo, "master", "sql", pfs.Delimiter_SQL, strings.NewReader(tu.TestPGDump))
+	require.YesError(t, err)
+}
+
 func TestPutFileSplitSQL(t *testing.T) {
 	c := GetPachClient(t)
 	// create repos
@@ -2923,6 +3865,62 @@ func TestDiff(t *testing.T) {
 	require.Equal(t, "dir/fizz", oldFiles[0].File.Path)
 }
 
+func TestDiffContentAndRenames(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+
+	c := GetPachClient(t)
+	repo := tu.UniqueString("TestDiffContentAndRenames")
+	require.NoError(t, c.CreateRepo(repo))
+
+	_, err := c.StartCommit(repo, "master")
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, "master", "text", strings.NewReader("a\nb\nc\n"))
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, "master", "binary", strings.NewReader("\x00\x01"))
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, "master", "old-name", strings.NewReader("moved\n"))
+	require.NoError(t, err)
+	require.NoError(t, c.FinishCommit(repo, "master"))
+
+	_, err = c.StartCommit(repo, "master")
+	require.NoError(t, err)
+	_, err = c.PutFileOverwrite(repo, "master", "text", strings.NewReader("a\nB\nc\n"), 0)
+	require.NoError(t, err)
+	_, err = c.PutFileOverwrite(repo, "master", "binary", strings.NewReader("\x00\x02"), 0)
+	require.NoError(t, err)
+	require.NoError(t, c.DeleteFile(repo, "master", "old-name"))
+	_, err = c.PutFile(repo, "master", "new-name", strings.NewReader("moved\n"))
+	require.NoError(t, err)
+	require.NoError(t, c.FinishCommit(repo, "master"))
+
+	resp, err := c.DiffFileWithOptions(repo, "master", "", "", "", "", &pclient.DiffFileOptions{
+		Content:       true,
+		DetectRenames: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(resp.Renames))
+	require.Equal(t, "/old-name", path.Join("/", resp.Renames[0].OldFile.File.Path))
+	require.Equal(t, "/new-name", path.Join("/", resp.Renames[0].NewFile.File.Path))
+	require.Equal(t, 2, len(resp.ContentDiffs))
+	diffs := make(map[string]string)
+	for _, contentDiff := range resp.ContentDiffs {
+		diffs[path.Join("/", contentDiff.NewPath)] = contentDiff.UnifiedDiff
+	}
+	require.Equal(t, "--- a/text\n+++ b/text\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n", diffs["/text"])
+	require.Equal(t, "Binary files a/binary and b/binary differ\n", diffs["/binary"])
+
+	// files larger than the limit aren't diffed
+	resp, err = c.DiffFileWithOptions(repo, "master", "", "", "", "", &pclient.DiffFileOptions{
+		Content:             true,
+		MaxContentDiffBytes: 1,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(resp.ContentDiffs))
+	require.Equal(t, 0, len(resp.Renames))
+}
+
 func TestGlob(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration tests in short mode")
@@ -3253,6 +4251,146 @@ func TestCopyFileHeaderFooter(t *testing.T) {
 		contents.String())
 }
 
+// finishOutputCommitT finishes 'commit' the way a pipeline's master does,
+// with a hashtree (whose files refer to blocks) stored as an object. As the
+// driver reads such trees straight from object storage, it also points the
+// storage env vars at the block server's storage, which is found from one of
+// its blocks.
+func finishOutputCommitT(t *testing.T, c *pclient.APIClient, commit *pfs.Commit, files map[string]string) {
+	t.Helper()
+	var paths []string
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	tree := hashtree.NewOrdered("/")
+	var size uint64
+	for _, p := range paths {
+		object, _, err := c.PutObject(strings.NewReader(files[p]))
+		require.NoError(t, err)
+		objectInfo, err := c.InspectObject(object.Hash)
+		require.NoError(t, err)
+		if dir := path.Dir(p); dir != "/" {
+			tree.PutDir(dir)
+		}
+		hash, err := pfs.DecodeHash(object.Hash)
+		require.NoError(t, err)
+		tree.PutFile(p, hash, int64(len(files[p])), &hashtree.FileNodeProto{BlockRefs: []*pfs.BlockRef{objectInfo.BlockRef}})
+		size += uint64(len(files[p]))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, tree.Serialize(&buf))
+	treeObj, _, err := c.PutObject(&buf)
+	require.NoError(t, err)
+	treeInfo, err := c.InspectObject(treeObj.Hash)
+	require.NoError(t, err)
+
+	// The block server stores blocks under its root twice over (its local
+	// client's root, then the block's path), while the driver reads them from
+	// PACH_ROOT/block under its own storage root ("/tmp")
+	matches, err := filepath.Glob("/tmp/pach_test/*/tmp/pach_test/*/block/" + treeInfo.BlockRef.Block.Hash)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(matches))
+	pachRoot := strings.TrimPrefix(filepath.Dir(filepath.Dir(matches[0])), "/tmp")
+	require.NoError(t, os.Setenv(obj.StorageBackendEnvVar, obj.Local))
+	require.NoError(t, os.Setenv(obj.PachRootEnvVar, pachRoot))
+	// The tree is small enough to have an empty index
+	objClient, err := obj.NewLocalClient("/tmp")
+	require.NoError(t, err)
+	w, err := objClient.Writer(path.Join(pachRoot, "block", treeInfo.BlockRef.Block.Hash) + hashtree.IndexPath)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	_, err = c.PfsAPIClient.FinishCommit(c.Ctx(), &pfs.FinishCommitRequest{
+		Commit:    commit,
+		Trees:     []*pfs.Object{treeObj},
+		SizeBytes: size,
+	})
+	require.NoError(t, err)
+}
+
+func TestCopyFileFromOutputCommit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	defer os.Unsetenv(obj.StorageBackendEnvVar)
+	defer os.Unsetenv(obj.PachRootEnvVar)
+
+	c := GetPachClient(t)
+	in := tu.UniqueString("TestCopyFileFromOutputCommit_in")
+	out := tu.UniqueString("TestCopyFileFromOutputCommit_out")
+	require.NoError(t, c.CreateRepo(in))
+	require.NoError(t, c.CreateRepo(out))
+	require.NoError(t, c.CreateBranch(out, "master", "", []*pfs.Branch{pclient.NewBranch(in, "master")}))
+	_, err := c.PutFile(in, "master", "a", strings.NewReader("foo"))
+	require.NoError(t, err)
+	commitInfo, err := c.InspectCommit(out, "master")
+	require.NoError(t, err)
+	finishOutputCommitT(t, c, commitInfo.Commit, map[string]string{
+		"/dir/a": "foo",
+		"/dir/b": "bar",
+		"/c":     "baz",
+	})
+	commitInfo, err = c.InspectCommit(out, "master")
+	require.NoError(t, err)
+	require.Nil(t, commitInfo.Tree)
+	require.Equal(t, 1, len(commitInfo.Trees))
+
+	// Files in an output commit are copied by creating objects that refer to
+	// their blocks, into both open commits and branches
+	dst := tu.UniqueString("TestCopyFileFromOutputCommit_dst")
+	require.NoError(t, c.CreateRepo(dst))
+	commit, err := c.StartCommit(dst, "master")
+	require.NoError(t, err)
+	require.NoError(t, c.CopyFile(out, "master", "/dir", dst, commit.ID, "/copy", false))
+	require.NoError(t, c.FinishCommit(dst, commit.ID))
+	require.NoError(t, c.CopyFile(out, "master", "/c", dst, "master", "/c", false))
+	for p, expected := range map[string]string{"/copy/a": "foo", "/copy/b": "bar", "/c": "baz"} {
+		var buf bytes.Buffer
+		require.NoError(t, c.GetFile(dst, "master", p, 0, 0, &buf))
+		require.Equal(t, expected, buf.String())
+		fileInfo, err := c.InspectFile(dst, "master", p)
+		require.NoError(t, err)
+		require.Equal(t, uint64(len(expected)), fileInfo.SizeBytes)
+	}
+
+	// Copying the same blocks again reuses their objects
+	require.NoError(t, c.CopyFile(out, "master", "/dir/a", dst, "master", "/again", false))
+	a, err := c.InspectFile(dst, "master", "/copy/a")
+	require.NoError(t, err)
+	again, err := c.InspectFile(dst, "master", "/again")
+	require.NoError(t, err)
+	require.Equal(t, a.Objects, again.Objects)
+}
+
+func TestCreateObject(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	c := GetPachClient(t)
+	object, _, err := c.PutObject(strings.NewReader("foobar"))
+	require.NoError(t, err)
+	objectInfo, err := c.InspectObject(object.Hash)
+	require.NoError(t, err)
+
+	// An object can be created from (part of) another object's block
+	blockRef := *objectInfo.BlockRef
+	blockRef.Range = &pfs.ByteRange{Lower: objectInfo.BlockRef.Range.Lower + 3, Upper: objectInfo.BlockRef.Range.Upper}
+	_, err = c.ObjectAPIClient.CreateObject(c.Ctx(), &pfs.CreateObjectRequest{
+		Object:   pclient.NewObject("bar"),
+		BlockRef: &blockRef,
+	})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, c.GetObject("bar", &buf))
+	require.Equal(t, "bar", buf.String())
+
+	_, err = c.ObjectAPIClient.CreateObject(c.Ctx(), &pfs.CreateObjectRequest{Object: pclient.NewObject("baz")})
+	require.YesError(t, err)
+	_, err = c.ObjectAPIClient.CreateObject(c.Ctx(), &pfs.CreateObjectRequest{BlockRef: &blockRef})
+	require.YesError(t, err)
+}
+
 func TestBuildCommit(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration tests in short mode")
@@ -4552,6 +5690,231 @@ func TestWalk(t *testing.T) {
 	require.Equal(t, len(expectedPaths), i)
 }
 
+func TestGetCommitArchive(t *testing.T) {
+	c := GetPachClient(t)
+	repo := "TestGetCommitArchive"
+	require.NoError(t, c.CreateRepo(repo))
+	_, err := c.PutFile(repo, "master", "dir/bar", strings.NewReader("bar"))
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, "master", "dir/dir2/buzz", strings.NewReader("buzz"))
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, "master", "foo", strings.NewReader(strings.Repeat("foo", MB)))
+	require.NoError(t, err)
+	_, err = c.PutFile(repo, "master", "empty", strings.NewReader(""))
+	require.NoError(t, err)
+
+	for _, format := range []pfs.ArchiveFormat{pfs.ArchiveFormat_TAR_GZ, pfs.ArchiveFormat_ZIP} {
+		var archive bytes.Buffer
+		require.NoError(t, c.GetCommitArchive(repo, "master", "", format, &archive))
+		require.Equal(t, map[string]string{
+			"dir/":          "",
+			"dir/bar":       "bar",
+			"dir/dir2/":     "",
+			"dir/dir2/buzz": "buzz",
+			"empty":         "",
+			"foo":           strings.Repeat("foo", MB),
+		}, readArchive(t, archive.Bytes(), format))
+
+		// paths are relative to the root of the commit
+		archive.Reset()
+		require.NoError(t, c.GetCommitArchive(repo, "master", "dir/dir2", format, &archive))
+		require.Equal(t, map[string]string{
+			"dir/dir2/":     "",
+			"dir/dir2/buzz": "buzz",
+		}, readArchive(t, archive.Bytes(), format))
+	}
+	require.YesError(t, c.GetCommitArchive(repo, "master", "missing", pfs.ArchiveFormat_ZIP, &bytes.Buffer{}))
+}
+
+func TestPutFileTar(t *testing.T) {
+	c := GetPachClient(t)
+	repo := "TestPutFileTar"
+	require.NoError(t, c.CreateRepo(repo))
+	commit, err := c.StartCommit(repo, "master")
+	require.NoError(t, err)
+
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	for _, entry := range []struct {
+		header  tar.Header
+		content string
+	}{
+		{tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0755}, ""},
+		{tar.Header{Typeflag: tar.TypeReg, Name: "dir/bar", Mode: 0755}, "bar"},
+		{tar.Header{Typeflag: tar.TypeReg, Name: "foo", Mode: 0600}, strings.Repeat("foo", MB)},
+		{tar.Header{Typeflag: tar.TypeReg, Name: "../escape", Mode: 0644}, "escape"},
+	} {
+		entry.header.Size = int64(len(entry.content))
+		require.NoError(t, tw.WriteHeader(&entry.header))
+		_, err := tw.Write([]byte(entry.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	_, err = c.PutFileTar(repo, commit.ID, "prefix", bytes.NewReader(archive.Bytes()), false)
+	require.NoError(t, err)
+	require.NoError(t, c.FinishCommit(repo, commit.ID))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.GetFile(repo, commit.ID, "prefix/foo", 0, 0, &buf))
+	require.Equal(t, strings.Repeat("foo", MB), buf.String())
+	fileInfo, err := c.InspectFile(repo, commit.ID, "prefix/dir/bar")
+	require.NoError(t, err)
+	require.Equal(t, "0755", fileInfo.Metadata[pfs.FileModeMetadataKey])
+	// entries are always put under the prefix
+	_, err = c.InspectFile(repo, commit.ID, "prefix/escape")
+	require.NoError(t, err)
+
+	// GetCommitArchive gives files the modes they were put with
+	buf.Reset()
+	require.NoError(t, c.GetCommitArchive(repo, commit.ID, "prefix", pfs.ArchiveFormat_TAR_GZ, &buf))
+	gr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	modes := make(map[string]int64)
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		modes[header.Name] = header.Mode
+	}
+	require.Equal(t, int64(0755), modes["prefix/dir/bar"])
+	require.Equal(t, int64(0600), modes["prefix/foo"])
+
+	// a put to a branch overwrites files in a new commit
+	var overwrite bytes.Buffer
+	tw = tar.NewWriter(&overwrite)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "foo", Mode: 0644, Size: 3}))
+	_, err = tw.Write([]byte("FOO"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	_, err = c.PutFileTar(repo, "master", "prefix", &overwrite, true)
+	require.NoError(t, err)
+	buf.Reset()
+	require.NoError(t, c.GetFile(repo, "master", "prefix/foo", 0, 0, &buf))
+	require.Equal(t, "FOO", buf.String())
+
+	// symlinks aren't supported
+	var symlink bytes.Buffer
+	tw = tar.NewWriter(&symlink)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "foo"}))
+	require.NoError(t, tw.Close())
+	_, err = c.PutFileTar(repo, "master", "prefix", &symlink, false)
+	require.YesError(t, err)
+}
+
+func TestGetCommitManifest(t *testing.T) {
+	c := GetPachClient(t)
+	repo := "TestGetCommitManifest"
+	require.NoError(t, c.CreateRepo(repo))
+	_, err := c.PutFile(repo, "master", "dir/bar", strings.NewReader("bar"))
+	require.NoError(t, err)
+	_, err = c.PutFileWithMetadata(repo, "master", "foo", strings.NewReader("foo"), map[string]string{"owner": "qux"})
+	require.NoError(t, err)
+	before, err := c.InspectCommit(repo, "master")
+	require.NoError(t, err)
+
+	for _, format := range []pfs.ManifestFormat{pfs.ManifestFormat_MANIFEST_JSON, pfs.ManifestFormat_MANIFEST_CSV} {
+		var buf bytes.Buffer
+		require.NoError(t, c.GetCommitManifest(repo, "master", "", format, &buf))
+		entries, err := manifest.Read(&buf)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(entries))
+		require.Equal(t, "/dir/bar", entries[0].Path)
+		require.Equal(t, uint64(3), entries[0].SizeBytes)
+		fileInfo, err := c.InspectFile(repo, "master", "foo")
+		require.NoError(t, err)
+		require.Equal(t, "/foo", entries[1].Path)
+		require.Equal(t, hex.EncodeToString(fileInfo.Hash), entries[1].Hash)
+		require.Equal(t, hex.EncodeToString(fileInfo.ContentSha256), entries[1].ContentSha256)
+		require.Equal(t, "qux", entries[1].Metadata["owner"])
+	}
+
+	_, err = c.PutFile(repo, "master", "dir/buzz", strings.NewReader("buzz"))
+	require.NoError(t, err)
+	require.NoError(t, c.DeleteFile(repo, "master", "foo"))
+	var buf bytes.Buffer
+	require.NoError(t, c.GetCommitManifest(repo, before.Commit.ID, "", pfs.ManifestFormat_MANIFEST_JSON, &buf))
+	beforeEntries, err := manifest.Read(&buf)
+	require.NoError(t, err)
+	buf.Reset()
+	require.NoError(t, c.GetCommitManifest(repo, "master", "", pfs.ManifestFormat_MANIFEST_JSON, &buf))
+	afterEntries, err := manifest.Read(&buf)
+	require.NoError(t, err)
+	changes, err := manifest.Diff(beforeEntries, afterEntries)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(changes))
+	require.Equal(t, "/dir/buzz", changes[0].Path)
+	require.Equal(t, manifest.Added, changes[0].Type)
+	require.Equal(t, "/foo", changes[1].Path)
+	require.Equal(t, manifest.Removed, changes[1].Type)
+}
+
+func TestGetFileURL(t *testing.T) {
+	c := GetPachClient(t)
+	repo := "TestGetFileURL"
+	require.NoError(t, c.CreateRepo(repo))
+	_, err := c.PutFile(repo, "master", "foo", strings.NewReader("foo"))
+	require.NoError(t, err)
+	commitInfo, err := c.InspectCommit(repo, "master")
+	require.NoError(t, err)
+
+	// URLs are signed for the commit the branch points to
+	fileURL, err := c.GetFileURL(repo, "master", "foo", 0, "localhost:30652")
+	require.NoError(t, err)
+	require.True(t, strings.Contains(fileURL, commitInfo.Commit.ID))
+	_, err = c.GetFileURL(repo, "master", "missing", 0, "localhost:30652")
+	require.YesError(t, err)
+	_, err = c.GetFileURL(repo, "master", "foo", 30*24*time.Hour, "localhost:30652")
+	require.YesError(t, err)
+
+	// files in open commits may still change, so they can't be signed
+	commit, err := c.StartCommit(repo, "master")
+	require.NoError(t, err)
+	for _, commitID := range []string{"master", commit.ID} {
+		_, err = c.GetFileURL(repo, commitID, "foo", 0, "localhost:30652")
+		require.YesError(t, err)
+		require.Matches(t, "not finished", err.Error())
+	}
+	require.NoError(t, c.FinishCommit(repo, commit.ID))
+	_, err = c.GetFileURL(repo, commit.ID, "foo", 0, "localhost:30652")
+	require.NoError(t, err)
+}
+
+// readArchive returns the entries of an archive returned by
+// GetCommitArchive, by name (directories' names end in "/")
+func readArchive(t *testing.T, data []byte, format pfs.ArchiveFormat) map[string]string {
+	entries := make(map[string]string)
+	if format == pfs.ArchiveFormat_ZIP {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+		for _, f := range zr.File {
+			r, err := f.Open()
+			require.NoError(t, err)
+			content, err := ioutil.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			entries[f.Name] = string(content)
+		}
+		return entries
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		entries[header.Name] = string(content)
+	}
+	return entries
+}
+
 func TestReadSizeLimited(t *testing.T) {
 	c := GetPachClient(t)
 	require.NoError(t, c.CreateRepo("test"))
@@ -4712,3 +6075,48 @@ func TestFileHistory(t *testing.T) {
 		require.Equal(t, i, len(fileInfos))
 	}
 }
+
+func TestBatchWriter(t *testing.T) {
+	client := GetPachClient(t)
+
+	repo := tu.UniqueString("TestBatchWriter")
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "master")
+	require.NoError(t, err)
+
+	// a small budget forces several flushes, and the big file bypasses the
+	// batch
+	w := client.NewBatchWriter(100)
+	for i := 0; i < 50; i++ {
+		require.NoError(t, w.PutFile(repo, commit.ID, fmt.Sprintf("file%d", i), strings.NewReader(fmt.Sprintf("%d\n", i))))
+	}
+	require.NoError(t, w.PutFile(repo, commit.ID, "file0", strings.NewReader("appended\n")))
+	require.NoError(t, w.PutFileOverwrite(repo, commit.ID, "file1", strings.NewReader("overwritten\n")))
+	big := strings.Repeat("big\n", 100)
+	require.NoError(t, w.PutFile(repo, commit.ID, "big", strings.NewReader(big)))
+	object, err := w.PutObject([]byte("object"), "tag")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+
+	fileInfos, err := client.ListFile(repo, commit.ID, "")
+	require.NoError(t, err)
+	require.Equal(t, 51, len(fileInfos))
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit.ID, "file0", 0, 0, &buffer))
+	require.Equal(t, "0\nappended\n", buffer.String())
+	buffer.Reset()
+	require.NoError(t, client.GetFile(repo, commit.ID, "file1", 0, 0, &buffer))
+	require.Equal(t, "overwritten\n", buffer.String())
+	buffer.Reset()
+	require.NoError(t, client.GetFile(repo, commit.ID, "big", 0, 0, &buffer))
+	require.Equal(t, big, buffer.String())
+
+	// the object computed locally matches the one stored by pachd
+	value, err := client.ReadObject(object.Hash)
+	require.NoError(t, err)
+	require.Equal(t, "object", string(value))
+	value, err = client.ReadTag("tag")
+	require.NoError(t, err)
+	require.Equal(t, "object", string(value))
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/limit"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
+	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
+	"github.com/pachyderm/pachyderm/src/server/pkg/hashtree"
+	"golang.org/x/sync/errgroup"
+)
+
+// The functions in this file implement InspectStorage (see pfs.StorageInfo).
+// It reads the tree of every finished commit to find the objects that hold
+// each repo's files, and then inspects each object once to find its size in
+// object storage, so it's expensive in large clusters.
+
+// inspectStorageParallelism is the number of objects that inspectStorage
+// inspects at once
+const inspectStorageParallelism = 100
+
+// repoStorage collects the data referenced by one repo's commits
+type repoStorage struct {
+	logicalBytes uint64
+	// files holds the distinct file versions in the repo's commits (keyed by
+	// path and hash)
+	files map[string]bool
+	// objects holds the hashes of the objects that hold the repo's file data
+	objects map[string]bool
+	// blockRefs holds the sizes of the block refs that hold the rest of the
+	// repo's file data (which jobs write to blocks directly, rather than as
+	// objects)
+	blockRefs map[string]uint64
+}
+
+func newRepoStorage() *repoStorage {
+	return &repoStorage{
+		files:     make(map[string]bool),
+		objects:   make(map[string]bool),
+		blockRefs: make(map[string]uint64),
+	}
+}
+
+// addTree adds the files in 'tree' to 's'
+func (s *repoStorage) addTree(tree hashtree.HashTree) error {
+	return tree.Walk("/", func(path string, node *hashtree.NodeProto) error {
+		if node.DirNode != nil && node.DirNode.Shared != nil {
+			for _, object := range []*pfs.Object{node.DirNode.Shared.Header, node.DirNode.Shared.Footer} {
+				if object != nil {
+					s.objects[object.Hash] = true
+				}
+			}
+		}
+		if node.FileNode == nil {
+			return nil
+		}
+		key := path + "\x00" + string(node.Hash)
+		if s.files[key] {
+			return nil
+		}
+		s.files[key] = true
+		s.logicalBytes += uint64(node.SubtreeSize)
+		for _, object := range node.FileNode.Objects {
+			s.objects[object.Hash] = true
+		}
+		for _, blockRef := range node.FileNode.BlockRefs {
+			key := fmt.Sprintf("%s:%d-%d", blockRef.Block.Hash, blockRef.Range.Lower, blockRef.Range.Upper)
+			s.blockRefs[key] = pfsserver.ByteRangeSize(blockRef.Range)
+		}
+		return nil
+	})
+}
+
+func (d *driver) inspectStorage(pachClient *client.APIClient, repos []*pfs.Repo) (*pfs.StorageInfo, error) {
+	ctx := pachClient.Ctx()
+	if err := d.checkIsAdmin(pachClient, "InspectStorage"); err != nil {
+		return nil, err
+	}
+	for _, repo := range repos {
+		if err := d.repos.ReadOnly(ctx).Get(repo.Name, &pfs.RepoInfo{}); err != nil {
+			return nil, err
+		}
+	}
+	var repoNames []string
+	if err := d.repos.ReadOnly(ctx).List(&pfs.RepoInfo{}, col.DefaultOptions, func(repoName string) error {
+		repoNames = append(repoNames, repoName)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Strings(repoNames)
+
+	// referenced holds every object that a commit references, including the
+	// commits' trees
+	referenced := make(map[string]bool)
+	storage := make(map[string]*repoStorage)
+	for _, repoName := range repoNames {
+		s := newRepoStorage()
+		storage[repoName] = s
+		commitInfo := &pfs.CommitInfo{}
+		if err := d.commits(repoName).ReadOnly(ctx).List(commitInfo, col.DefaultOptions, func(string) error {
+			for _, object := range append([]*pfs.Object{commitInfo.Tree, commitInfo.Datums}, commitInfo.Trees...) {
+				if object != nil {
+					referenced[object.Hash] = true
+				}
+			}
+			if commitInfo.Finished == nil || commitInfo.Tree == nil {
+				return nil
+			}
+			tree, err := d.getTreeForCommit(pachClient, commitInfo.Commit)
+			if err != nil {
+				return err
+			}
+			return s.addTree(tree)
+		}); err != nil {
+			return nil, err
+		}
+		for hash := range s.objects {
+			referenced[hash] = true
+		}
+	}
+
+	// Find the orphaned objects, and the size of every object that's needed
+	var orphaned []string
+	objects, err := pachClient.ObjectAPIClient.ListObjects(ctx, &pfs.ListObjectsRequest{})
+	if err != nil {
+		return nil, grpcutil.ScrubGRPC(err)
+	}
+	for {
+		object, err := objects.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, grpcutil.ScrubGRPC(err)
+		}
+		if !referenced[object.Hash] {
+			orphaned = append(orphaned, object.Hash)
+		}
+	}
+	sizes := make(map[string]uint64)
+	var sizesMu sync.Mutex
+	limiter := limit.New(inspectStorageParallelism)
+	var eg errgroup.Group
+	inspect := func(hash string) {
+		limiter.Acquire()
+		eg.Go(func() error {
+			defer limiter.Release()
+			objectInfo, err := pachClient.InspectObject(hash)
+			if err != nil {
+				return err
+			}
+			sizesMu.Lock()
+			defer sizesMu.Unlock()
+			sizes[hash] = pfsserver.ByteRangeSize(objectInfo.BlockRef.Range)
+			return nil
+		})
+	}
+	seen := make(map[string]bool)
+	for _, s := range storage {
+		for hash := range s.objects {
+			if !seen[hash] {
+				seen[hash] = true
+				inspect(hash)
+			}
+		}
+	}
+	for _, hash := range orphaned {
+		inspect(hash)
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	// Add everything up
+	result := &pfs.StorageInfo{}
+	include := make(map[string]bool)
+	for _, repo := range repos {
+		include[repo.Name] = true
+	}
+	blockRefs := make(map[string]uint64)
+	for _, repoName := range repoNames {
+		s := storage[repoName]
+		repoStorageInfo := &pfs.RepoStorageInfo{
+			Repo:         client.NewRepo(repoName),
+			LogicalBytes: s.logicalBytes,
+			ObjectCount:  uint64(len(s.objects) + len(s.blockRefs)),
+		}
+		for hash := range s.objects {
+			repoStorageInfo.PhysicalBytes += sizes[hash]
+		}
+		for key, size := range s.blockRefs {
+			repoStorageInfo.PhysicalBytes += size
+			blockRefs[key] = size
+		}
+		result.LogicalBytes += s.logicalBytes
+		if len(repos) == 0 || include[repoName] {
+			result.Repos = append(result.Repos, repoStorageInfo)
+		}
+	}
+	for hash := range seen {
+		result.PhysicalBytes += sizes[hash]
+	}
+	for _, size := range blockRefs {
+		result.PhysicalBytes += size
+	}
+	result.ObjectCount = uint64(len(seen) + len(blockRefs))
+	for _, hash := range orphaned {
+		result.OrphanedObjects++
+		result.OrphanedBytes += sizes[hash]
+	}
+	return result, nil
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+
+	globlib "github.com/gobwas/glob"
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
+	"github.com/pachyderm/pachyderm/src/server/pkg/watch"
+	"golang.org/x/net/context"
+)
+
+// subscribeCommits implements SubscribeCommits. It returns the existing
+// commits on 'branch' in every repo (merged, oldest first), and then runs
+// subscribeCommit on each repo, passing the commits they return to 'f' in
+// the order they arrive.
+func (d *driver) subscribeCommits(pachClient *client.APIClient, repos []*pfs.Repo, pattern string, branch string, state pfs.CommitState, newOnly bool, f func(*pfs.CommitInfo) error) error {
+	if branch == "" {
+		return fmt.Errorf("branch must be set")
+	}
+	if len(repos) == 0 && pattern == "" {
+		return fmt.Errorf("either repos or a pattern must be set")
+	}
+	var match func(string) bool
+	if pattern != "" {
+		g, err := globlib.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		match = g.Match
+	}
+	ctx, cancel := context.WithCancel(pachClient.Ctx())
+	defer cancel()
+	pachClient = pachClient.WithCtx(ctx)
+
+	// readable returns false if the caller can't read 'repoName', so that
+	// it's skipped by 'pattern'
+	readable := func(repoName string) (bool, error) {
+		err := d.checkIsAuthorized(pachClient, client.NewRepo(repoName), auth.Scope_READER)
+		if auth.IsErrNotAuthorized(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	// Find the repos to subscribe to. The repos collection is watched before
+	// it's listed, so that no repo created in between is missed.
+	var repoEvents <-chan *watch.Event
+	if match != nil {
+		repoWatcher, err := d.repos.ReadOnly(ctx).Watch()
+		if err != nil {
+			return err
+		}
+		defer repoWatcher.Close()
+		repoEvents = repoWatcher.Watch()
+	}
+	subscribed := make(map[string]bool)
+	var repoNames []string
+	for _, repo := range repos {
+		if err := d.checkIsAuthorized(pachClient, repo, auth.Scope_READER); err != nil {
+			return err
+		}
+		if err := d.repos.ReadOnly(ctx).Get(repo.Name, &pfs.RepoInfo{}); err != nil {
+			return err
+		}
+		if !subscribed[repo.Name] {
+			subscribed[repo.Name] = true
+			repoNames = append(repoNames, repo.Name)
+		}
+	}
+	if match != nil {
+		if err := d.repos.ReadOnly(ctx).List(&pfs.RepoInfo{}, col.DefaultOptions, func(repoName string) error {
+			if subscribed[repoName] || !match(repoName) {
+				return nil
+			}
+			ok, err := readable(repoName)
+			if err != nil {
+				return err
+			}
+			if ok {
+				subscribed[repoName] = true
+				repoNames = append(repoNames, repoName)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Return the existing commits, and note each branch's head so that
+	// subscribeCommit starts after it
+	from := make(map[string]*pfs.Commit)
+	var existing []*pfs.CommitInfo
+	for _, repoName := range repoNames {
+		commitInfos, err := d.listCommit(pachClient, client.NewRepo(repoName), client.NewCommit(repoName, branch), nil, 0)
+		if err != nil {
+			// It's ok if the branch doesn't exist yet
+			if !isNotFoundErr(err) {
+				return err
+			}
+			continue
+		}
+		if len(commitInfos) == 0 {
+			continue
+		}
+		from[repoName] = commitInfos[0].Commit
+		if !newOnly {
+			// listCommit returns commits newest first
+			for i := len(commitInfos) - 1; i >= 0; i-- {
+				existing = append(existing, commitInfos[i])
+			}
+		}
+	}
+	sort.SliceStable(existing, func(i, j int) bool {
+		a, b := existing[i].Started, existing[j].Started
+		return a.Seconds < b.Seconds || (a.Seconds == b.Seconds && a.Nanos < b.Nanos)
+	})
+	for _, commitInfo := range existing {
+		commitInfo, err := d.inspectCommit(pachClient, commitInfo.Commit, state)
+		if err != nil {
+			return err
+		}
+		if err := f(commitInfo); err != nil {
+			return err
+		}
+	}
+
+	// Subscribe to each repo. The stream ends when any of the subscriptions
+	// does.
+	commitInfos := make(chan *pfs.CommitInfo)
+	done := make(chan error, 1)
+	subscribe := func(repoName string, from *pfs.Commit) {
+		go func() {
+			err := d.subscribeCommit(pachClient, client.NewRepo(repoName), branch, from, state, func(commitInfo *pfs.CommitInfo) error {
+				select {
+				case commitInfos <- commitInfo:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+			select {
+			case done <- err:
+			default:
+			}
+		}()
+	}
+	for _, repoName := range repoNames {
+		subscribe(repoName, from[repoName])
+	}
+	for {
+		select {
+		case commitInfo := <-commitInfos:
+			if err := f(commitInfo); err != nil {
+				return err
+			}
+		case err := <-done:
+			return err
+		case event, ok := <-repoEvents:
+			if !ok {
+				return nil
+			}
+			switch event.Type {
+			case watch.EventError:
+				return event.Err
+			case watch.EventPut:
+				var repoName string
+				if err := event.Unmarshal(&repoName, &pfs.RepoInfo{}); err != nil {
+					return fmt.Errorf("Unmarshal: %v", err)
+				}
+				if subscribed[repoName] || !match(repoName) {
+					continue
+				}
+				ok, err := readable(repoName)
+				if err != nil {
+					return err
+				}
+				if ok {
+					subscribed[repoName] = true
+					subscribe(repoName, nil)
+				}
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
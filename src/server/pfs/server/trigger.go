@@ -0,0 +1,323 @@
+package server
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/robfig/cron"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/server/pkg/backoff"
+	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
+	"github.com/pachyderm/pachyderm/src/server/pkg/dlock"
+	"github.com/pachyderm/pachyderm/src/server/pkg/ppsconsts"
+)
+
+// The functions in this file implement branch triggers (see pfs.Trigger). A
+// trigger is checked after each commit finishes, by the request that
+// finished it, and moves the triggered branch with createBranch (so that the
+// downstream pipelines are started as usual). Cron triggers are also checked
+// every cronTriggerPeriod, by whichever pachd holds the trigger lock, so that
+// they fire when their schedule comes due even if no new commit finishes.
+
+const (
+	// cronTriggerPeriod is how often cron triggers are checked. Cron
+	// schedules have a resolution of a minute.
+	cronTriggerPeriod = time.Minute
+
+	triggerLockPath = "_trigger_lock"
+)
+
+// runCronTriggers checks cron triggers every cronTriggerPeriod while this
+// pachd holds the trigger lock. It runs until pachd exits.
+func (a *apiServer) runCronTriggers() {
+	d := a.driver
+	triggerLock := dlock.NewDLock(d.etcdClient, path.Join(d.prefix, triggerLockPath))
+	backoff.RetryNotify(func() error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ctx, err := triggerLock.Lock(ctx)
+		if err != nil {
+			return err
+		}
+		defer triggerLock.Unlock(ctx)
+		ticker := time.NewTicker(cronTriggerPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			pachClient, err := a.superUserClient(ctx)
+			if err != nil {
+				return err
+			}
+			if err := d.fireCronTriggers(pachClient); err != nil {
+				logrus.Errorf("error checking cron triggers: %v", err)
+			}
+		}
+	}, backoff.NewInfiniteBackOff(), func(err error, d time.Duration) error {
+		logrus.Errorf("error running cron triggers: %v; retrying in %v", err, d)
+		return nil
+	})
+}
+
+// superUserClient returns a client that uses PPS's superuser token, if auth
+// has ever been activated, as triggered branches are moved on behalf of the
+// cluster rather than of a user
+func (a *apiServer) superUserClient(ctx context.Context) (*client.APIClient, error) {
+	pachClient := a.getPachClient(ctx)
+	var superUserToken types.StringValue
+	superUserTokenCol := col.NewCollection(a.driver.etcdClient, ppsconsts.PPSTokenKey, nil, &types.StringValue{}, nil, nil).ReadOnly(ctx)
+	if err := superUserTokenCol.Get("", &superUserToken); err == nil {
+		pachClient.SetAuthToken(superUserToken.Value)
+	} else if !col.IsErrNotFound(err) {
+		return nil, err
+	} // otherwise auth has never been activated, so no token is needed
+	return pachClient, nil
+}
+
+// fireCronTriggers moves the branches with cron triggers whose schedules have
+// come due (and whose other conditions are met) to the heads of the branches
+// that they follow, along with the branches that they trigger in turn
+func (d *driver) fireCronTriggers(pachClient *client.APIClient) error {
+	var repos []string
+	repoInfo := &pfs.RepoInfo{}
+	if err := d.repos.ReadOnly(pachClient.Ctx()).List(repoInfo, col.DefaultOptions, func(string) error {
+		repos = append(repos, repoInfo.Repo.Name)
+		return nil
+	}); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, repo := range repos {
+		heads := make(map[string]*pfs.Commit)
+		var cronBranches []*pfs.BranchInfo
+		branchInfo := &pfs.BranchInfo{}
+		if err := d.branches(repo).ReadOnly(pachClient.Ctx()).List(branchInfo, col.DefaultOptions, func(string) error {
+			heads[branchInfo.Branch.Name] = branchInfo.Head
+			if branchInfo.Trigger.GetCronSpec() != "" {
+				cronBranches = append(cronBranches, proto.Clone(branchInfo).(*pfs.BranchInfo))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, branchInfo := range cronBranches {
+			head := heads[branchInfo.Trigger.Branch]
+			if head == nil || (branchInfo.Head != nil && branchInfo.Head.ID == head.ID) {
+				continue
+			}
+			if err := d.fireCronTrigger(pachClient, branchInfo, head, now); err != nil {
+				logrus.Errorf("error checking the trigger of branch %s@%s: %v", repo, branchInfo.Branch.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// fireCronTrigger moves the branch 'branchInfo' to 'head' (the head of the
+// branch that it follows) if its trigger is met at 'now'
+func (d *driver) fireCronTrigger(pachClient *client.APIClient, branchInfo *pfs.BranchInfo, head *pfs.Commit, now time.Time) error {
+	headInfo, err := d.inspectCommit(pachClient, proto.Clone(head).(*pfs.Commit), pfs.CommitState_STARTED)
+	if err != nil {
+		return err
+	}
+	if headInfo.Finished == nil {
+		return nil
+	}
+	var oldHead *pfs.CommitInfo
+	if branchInfo.Head != nil {
+		if oldHead, err = d.inspectCommit(pachClient, proto.Clone(branchInfo.Head).(*pfs.Commit), pfs.CommitState_STARTED); err != nil {
+			return err
+		}
+	}
+	met, err := d.isTriggered(pachClient, branchInfo.Trigger, oldHead, headInfo, now)
+	if err != nil || !met {
+		return err
+	}
+	if err := d.createBranch(pachClient, branchInfo.Branch, headInfo.Commit, nil, branchInfo.Trigger); err != nil {
+		return err
+	}
+	return d.triggerCommit(pachClient, headInfo.Commit)
+}
+
+// validateTrigger returns an error if 'trigger' can't be set on 'branch',
+// which will have the direct provenance 'provenance'
+func (d *driver) validateTrigger(pachClient *client.APIClient, branch *pfs.Branch, provenance []*pfs.Branch, trigger *pfs.Trigger) error {
+	if trigger == nil {
+		return nil
+	}
+	if trigger.Branch == "" {
+		return fmt.Errorf("trigger of branch %s must name the branch that it follows", branch.Name)
+	}
+	if trigger.Branch == branch.Name {
+		return fmt.Errorf("branch %s can't trigger itself", branch.Name)
+	}
+	if len(provenance) > 0 {
+		return fmt.Errorf("branch %s can't have a trigger, as it has provenance", branch.Name)
+	}
+	if trigger.CronSpec == "" && trigger.SizeBytes == 0 && trigger.Commits == 0 {
+		return fmt.Errorf("trigger of branch %s must have at least one condition", branch.Name)
+	}
+	if trigger.CronSpec != "" {
+		if _, err := cron.ParseStandard(trigger.CronSpec); err != nil {
+			return fmt.Errorf("invalid trigger cron spec %q: %v", trigger.CronSpec, err)
+		}
+	}
+	if trigger.SizeBytes < 0 {
+		return fmt.Errorf("trigger size_bytes must be at least 0, but was %d", trigger.SizeBytes)
+	}
+	if trigger.Commits < 0 {
+		return fmt.Errorf("trigger commits must be at least 0, but was %d", trigger.Commits)
+	}
+	// check that the branches that 'branch' would follow don't follow 'branch'
+	branches := d.branches(branch.Repo.Name).ReadOnly(pachClient.Ctx())
+	for name := trigger.Branch; name != ""; {
+		if name == branch.Name {
+			return fmt.Errorf("trigger of branch %s would create a cycle", branch.Name)
+		}
+		branchInfo := &pfs.BranchInfo{}
+		if err := branches.Get(name, branchInfo); err != nil {
+			if col.IsErrNotFound(err) {
+				break
+			}
+			return err
+		}
+		name = branchInfo.Trigger.GetBranch()
+	}
+	return nil
+}
+
+// fireTriggers moves the branches whose triggers are met by the newly
+// finished 'commit' (see triggerCommit). The commit is finished regardless
+// of whether its triggers could be checked, so errors are logged rather than
+// returned to the request that finished it.
+func (d *driver) fireTriggers(pachClient *client.APIClient, commit *pfs.Commit) {
+	if err := d.triggerCommit(pachClient, commit); err != nil {
+		logrus.Errorf("error checking the triggers of commit %s: %v", commit.FullID(), err)
+	}
+}
+
+// triggerCommit moves the branches whose triggers are met by the newly
+// finished 'commit' (which must be a commit ID) to it, along with the
+// branches that they trigger in turn
+func (d *driver) triggerCommit(pachClient *client.APIClient, commit *pfs.Commit) error {
+	var branchInfos []*pfs.BranchInfo
+	var triggered bool
+	branchInfo := &pfs.BranchInfo{}
+	if err := d.branches(commit.Repo.Name).ReadOnly(pachClient.Ctx()).List(branchInfo, col.DefaultOptions, func(string) error {
+		branchInfos = append(branchInfos, proto.Clone(branchInfo).(*pfs.BranchInfo))
+		triggered = triggered || branchInfo.Trigger != nil
+		return nil
+	}); err != nil {
+		return err
+	}
+	if !triggered {
+		return nil
+	}
+	commitInfo, err := d.inspectCommit(pachClient, proto.Clone(commit).(*pfs.Commit), pfs.CommitState_STARTED)
+	if err != nil {
+		return err
+	}
+	finished, err := types.TimestampFromProto(commitInfo.Finished)
+	if err != nil {
+		return err
+	}
+	// 'moved' holds the branches whose head is 'commit', whose triggered
+	// branches may need to be moved
+	var moved []string
+	for _, branchInfo := range branchInfos {
+		if branchInfo.Head != nil && branchInfo.Head.ID == commit.ID {
+			moved = append(moved, branchInfo.Branch.Name)
+		}
+	}
+	for len(moved) > 0 {
+		source := moved[0]
+		moved = moved[1:]
+		for _, branchInfo := range branchInfos {
+			if branchInfo.Trigger.GetBranch() != source ||
+				(branchInfo.Head != nil && branchInfo.Head.ID == commit.ID) {
+				continue
+			}
+			var oldHead *pfs.CommitInfo
+			if branchInfo.Head != nil {
+				oldHead, err = d.inspectCommit(pachClient, proto.Clone(branchInfo.Head).(*pfs.Commit), pfs.CommitState_STARTED)
+				if err != nil {
+					return err
+				}
+			}
+			met, err := d.isTriggered(pachClient, branchInfo.Trigger, oldHead, commitInfo, finished)
+			if err != nil {
+				return err
+			}
+			if !met {
+				continue
+			}
+			if err := d.createBranch(pachClient, branchInfo.Branch, commit, nil, branchInfo.Trigger); err != nil {
+				return fmt.Errorf("error moving triggered branch %s: %v", branchInfo.Branch.Name, err)
+			}
+			branchInfo.Head = commit
+			moved = append(moved, branchInfo.Branch.Name)
+		}
+	}
+	return nil
+}
+
+// isTriggered returns true if 'trigger' is met by 'newHead' at the time
+// 'now', given that the triggered branch's head is 'oldHead' (which is nil if
+// it has no head)
+func (d *driver) isTriggered(pachClient *client.APIClient, trigger *pfs.Trigger, oldHead, newHead *pfs.CommitInfo, now time.Time) (bool, error) {
+	result := trigger.All
+	merge := func(met bool) {
+		if trigger.All {
+			result = result && met
+		} else {
+			result = result || met
+		}
+	}
+	if trigger.CronSpec != "" {
+		schedule, err := cron.ParseStandard(trigger.CronSpec)
+		if err != nil {
+			return false, err
+		}
+		var last time.Time
+		if oldHead != nil && oldHead.Finished != nil {
+			if last, err = types.TimestampFromProto(oldHead.Finished); err != nil {
+				return false, err
+			}
+		}
+		merge(!schedule.Next(last).After(now))
+	}
+	if trigger.SizeBytes > 0 {
+		var oldSize int64
+		if oldHead != nil {
+			oldSize = int64(oldHead.SizeBytes)
+		}
+		merge(int64(newHead.SizeBytes)-oldSize >= trigger.SizeBytes)
+	}
+	if trigger.Commits > 0 {
+		// count the commits after 'oldHead', up to trigger.Commits
+		var n int64
+		commits := d.commits(newHead.Commit.Repo.Name).ReadOnly(pachClient.Ctx())
+		for commit := newHead.Commit; commit != nil && n < trigger.Commits; n++ {
+			if oldHead != nil && commit.ID == oldHead.Commit.ID {
+				break
+			}
+			commitInfo := &pfs.CommitInfo{}
+			if err := commits.Get(commit.ID, commitInfo); err != nil {
+				return false, err
+			}
+			commit = commitInfo.ParentCommit
+		}
+		merge(n >= trigger.Commits)
+	}
+	return result, nil
+}
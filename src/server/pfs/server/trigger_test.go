@@ -0,0 +1,51 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+)
+
+func TestIsTriggeredCron(t *testing.T) {
+	d := &driver{}
+	finishedAt := func(t time.Time) *pfs.CommitInfo {
+		finished, err := types.TimestampProto(t)
+		if err != nil {
+			panic(err)
+		}
+		return &pfs.CommitInfo{Finished: finished}
+	}
+	hour := time.Date(2020, 1, 1, 10, 0, 0, 0, time.Local)
+	oldHead := finishedAt(hour.Add(5 * time.Minute))
+	newHead := finishedAt(hour.Add(10 * time.Minute))
+	trigger := &pfs.Trigger{Branch: "master", CronSpec: "0 * * * *"}
+
+	// The schedule comes due on the hour after the old head was finished,
+	// whether or not a new commit is finished then
+	met, err := d.isTriggered(nil, trigger, oldHead, newHead, hour.Add(30*time.Minute))
+	require.NoError(t, err)
+	require.False(t, met)
+	met, err = d.isTriggered(nil, trigger, oldHead, newHead, hour.Add(time.Hour))
+	require.NoError(t, err)
+	require.True(t, met)
+
+	// A branch without a head is due at once
+	met, err = d.isTriggered(nil, trigger, nil, newHead, hour.Add(30*time.Minute))
+	require.NoError(t, err)
+	require.True(t, met)
+
+	// With 'all', the other conditions must be met too
+	trigger.All = true
+	trigger.SizeBytes = 100
+	newHead.SizeBytes = 50
+	met, err = d.isTriggered(nil, trigger, oldHead, newHead, hour.Add(time.Hour))
+	require.NoError(t, err)
+	require.False(t, met)
+	newHead.SizeBytes = 100
+	met, err = d.isTriggered(nil, trigger, oldHead, newHead, hour.Add(time.Hour))
+	require.NoError(t, err)
+	require.True(t, met)
+}
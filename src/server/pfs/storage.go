@@ -0,0 +1,61 @@
+package pfs
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+)
+
+const (
+	// CompressionEnvVar is the environment variable that sets pachd's default
+	// compression (the name of a pfs.Compression, e.g. "gzip"). Repos'
+	// compression settings override it.
+	CompressionEnvVar = "STORAGE_COMPRESSION"
+	// CompressionLevelEnvVar is the environment variable that sets the level
+	// of pachd's default compression
+	CompressionLevelEnvVar = "STORAGE_COMPRESSION_LEVEL"
+	// EncryptionEnvVar is the environment variable that sets the URI of the
+	// key with which pachd encrypts objects by default (see
+	// pfs.EncryptionSpec). Repos' encryption settings override it.
+	EncryptionEnvVar = "STORAGE_ENCRYPTION_KEY"
+)
+
+// StorageEnvVars are the environment variables that set pachd's default
+// compression and encryption. pachd passes them on to workers, which write
+// their output's contents to object storage themselves.
+var StorageEnvVars = []string{CompressionEnvVar, CompressionLevelEnvVar, EncryptionEnvVar}
+
+// CompressionFromEnv returns the default compression set in the environment,
+// or nil if none is set. The compression's level isn't validated.
+func CompressionFromEnv() (*pfs.CompressionSpec, error) {
+	name := os.Getenv(CompressionEnvVar)
+	if name == "" {
+		return nil, nil
+	}
+	compression, ok := pfs.Compression_value[strings.ToUpper(name)]
+	if !ok {
+		return nil, fmt.Errorf("unrecognized %s %q", CompressionEnvVar, name)
+	}
+	spec := &pfs.CompressionSpec{Compression: pfs.Compression(compression)}
+	if level := os.Getenv(CompressionLevelEnvVar); level != "" {
+		l, err := strconv.Atoi(level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %v", CompressionLevelEnvVar, level, err)
+		}
+		spec.Level = int32(l)
+	}
+	return spec, nil
+}
+
+// EncryptionFromEnv returns the default encryption set in the environment, or
+// nil if none is set. The key's URI isn't validated.
+func EncryptionFromEnv() *pfs.EncryptionSpec {
+	key := os.Getenv(EncryptionEnvVar)
+	if key == "" {
+		return nil
+	}
+	return &pfs.EncryptionSpec{Key: key}
+}
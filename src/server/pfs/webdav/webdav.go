@@ -0,0 +1,449 @@
+// Package webdav serves pfs over WebDAV, so that repos can be browsed and
+// their files downloaded by the WebDAV clients built into Windows and macOS,
+// and by tools that support WebDAV, without pachctl.
+//
+// The server is read-only (WebDAV class 1, without locking), and lays pfs out
+// as:
+//
+//	/<repo>/<branch>/<path>
+//
+// Repos and their branches are collections. Commit IDs may be used in place
+// of branch names, to read old versions of files, but only branches are
+// listed.
+package webdav
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/server/pkg/errutil"
+)
+
+// allowedMethods are the methods that the server supports
+const allowedMethods = "OPTIONS, GET, HEAD, PROPFIND"
+
+// Methods are all of the methods that WebDAV clients may use, so that
+// routers can send them to the handler (which rejects the ones that write)
+var Methods = []string{
+	"OPTIONS", "GET", "HEAD", "PROPFIND",
+	"PUT", "DELETE", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK", "PROPPATCH",
+}
+
+type handler struct {
+	prefix    string
+	getClient func(*http.Request) (*client.APIClient, error)
+}
+
+// NewHandler returns an http.Handler that serves pfs over WebDAV, at the
+// paths under 'prefix'. getClient returns the client that serves each
+// request, which determines the user that the request reads pfs as.
+func NewHandler(prefix string, getClient func(*http.Request) (*client.APIClient, error)) http.Handler {
+	return &handler{
+		prefix:    strings.TrimSuffix(prefix, "/"),
+		getClient: getClient,
+	}
+}
+
+// resource is a repo, branch (or commit), directory or file
+type resource struct {
+	// name is the resource's path relative to the handler's prefix, e.g.
+	// "repo/branch/dir"
+	name       string
+	collection bool
+	size       uint64
+	modified   time.Time
+	etag       string
+	// commitID is the commit that a file or directory is in
+	commitID string
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, ok := h.parse(r.URL.Path)
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case "OPTIONS":
+		w.Header().Set("DAV", "1")
+		w.Header().Set("Allow", allowedMethods)
+		// Windows only uses WebDAV if this is set
+		w.Header().Set("MS-Author-Via", "DAV")
+		return
+	case "GET", "HEAD", "PROPFIND":
+	default:
+		w.Header().Set("Allow", allowedMethods)
+		http.Error(w, "pfs is read-only over WebDAV", http.StatusMethodNotAllowed)
+		return
+	}
+	c, err := h.getClient(r)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	res, err := stat(c, name)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	if r.Method == "PROPFIND" {
+		err = h.propfind(w, r, c, res)
+	} else {
+		err = h.get(w, r, c, res)
+	}
+	if err != nil {
+		httpError(w, err)
+	}
+}
+
+// parse returns the resource name of the URL path 'p'
+func (h *handler) parse(p string) (string, bool) {
+	if !strings.HasPrefix(p, h.prefix+"/") && p != h.prefix {
+		return "", false
+	}
+	return strings.Trim(path.Clean("/"+strings.TrimPrefix(p, h.prefix)), "/"), true
+}
+
+// href returns the URL path of 'res'
+func (h *handler) href(res *resource) string {
+	result := h.prefix + "/"
+	if res.name != "" {
+		var escaped []string
+		for _, component := range strings.Split(res.name, "/") {
+			escaped = append(escaped, url.PathEscape(component))
+		}
+		result += strings.Join(escaped, "/")
+		if res.collection {
+			result += "/"
+		}
+	}
+	return result
+}
+
+// split splits a resource name into its repo, branch and path
+func split(name string) (repo string, branch string, file string) {
+	components := strings.SplitN(name, "/", 3)
+	switch len(components) {
+	case 3:
+		file = components[2]
+		fallthrough
+	case 2:
+		branch = components[1]
+		fallthrough
+	default:
+		repo = components[0]
+	}
+	return repo, branch, file
+}
+
+// commitTime returns the time that 'commitInfo' was finished (or started, if
+// it's open)
+func commitTime(commitInfo *pfs.CommitInfo) time.Time {
+	ts := commitInfo.Finished
+	if ts == nil {
+		ts = commitInfo.Started
+	}
+	return timestamp(ts)
+}
+
+func timestamp(ts *types.Timestamp) time.Time {
+	t, err := types.TimestampFromProto(ts)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// resolve returns the commit that 'branch' (a branch or commit ID) of 'repo'
+// refers to, or nil if it's a branch with no head
+func resolve(c *client.APIClient, repo string, branch string) (*pfs.CommitInfo, error) {
+	commitInfo, err := c.InspectCommit(repo, branch)
+	if err == nil {
+		return commitInfo, nil
+	}
+	branchInfo, branchErr := c.InspectBranch(repo, branch)
+	if branchErr == nil && branchInfo.Head == nil {
+		return nil, nil
+	}
+	return nil, err
+}
+
+func stat(c *client.APIClient, name string) (*resource, error) {
+	repo, branch, file := split(name)
+	switch {
+	case repo == "":
+		return &resource{collection: true}, nil
+	case branch == "":
+		repoInfo, err := c.InspectRepo(repo)
+		if err != nil {
+			return nil, err
+		}
+		return &resource{
+			name:       name,
+			collection: true,
+			modified:   timestamp(repoInfo.Created),
+		}, nil
+	}
+	commitInfo, err := resolve(c, repo, branch)
+	if err != nil {
+		return nil, err
+	}
+	if file == "" {
+		res := &resource{name: name, collection: true}
+		if commitInfo != nil {
+			res.modified = commitTime(commitInfo)
+		}
+		return res, nil
+	}
+	if commitInfo == nil {
+		return nil, fmt.Errorf("file %s not found", name)
+	}
+	fileInfo, err := c.InspectFile(repo, commitInfo.Commit.ID, file)
+	if err != nil {
+		return nil, err
+	}
+	return fileResource(path.Join(repo, branch), commitInfo, fileInfo), nil
+}
+
+// fileResource returns the resource for 'fileInfo', in the branch (or
+// commit) whose resource name is 'parent'
+func fileResource(parent string, commitInfo *pfs.CommitInfo, fileInfo *pfs.FileInfo) *resource {
+	res := &resource{
+		name:       path.Join(parent, strings.Trim(fileInfo.File.Path, "/")),
+		collection: fileInfo.FileType == pfs.FileType_DIR,
+		size:       fileInfo.SizeBytes,
+		modified:   commitTime(commitInfo),
+		commitID:   commitInfo.Commit.ID,
+	}
+	if len(fileInfo.Hash) > 0 {
+		res.etag = fmt.Sprintf(`"%s"`, hex.EncodeToString(fileInfo.Hash))
+	}
+	return res
+}
+
+// children returns the resources in the collection 'res'
+func children(c *client.APIClient, res *resource) ([]*resource, error) {
+	repo, branch, file := split(res.name)
+	var result []*resource
+	switch {
+	case repo == "":
+		repoInfos, err := c.ListRepo()
+		if err != nil {
+			return nil, err
+		}
+		for _, repoInfo := range repoInfos {
+			result = append(result, &resource{
+				name:       repoInfo.Repo.Name,
+				collection: true,
+				modified:   timestamp(repoInfo.Created),
+			})
+		}
+	case branch == "":
+		branchInfos, err := c.ListBranch(repo)
+		if err != nil {
+			return nil, err
+		}
+		for _, branchInfo := range branchInfos {
+			child := &resource{
+				name:       path.Join(repo, branchInfo.Name),
+				collection: true,
+			}
+			if branchInfo.Head != nil {
+				if commitInfo, err := c.InspectCommit(repo, branchInfo.Head.ID); err == nil {
+					child.modified = commitTime(commitInfo)
+				}
+			}
+			result = append(result, child)
+		}
+	default:
+		commitInfo, err := resolve(c, repo, branch)
+		if err != nil {
+			return nil, err
+		}
+		if commitInfo == nil {
+			return nil, nil
+		}
+		if err := c.ListFileF(repo, commitInfo.Commit.ID, file, 0, func(fileInfo *pfs.FileInfo) error {
+			result = append(result, fileResource(path.Join(repo, branch), commitInfo, fileInfo))
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (h *handler) get(w http.ResponseWriter, r *http.Request, c *client.APIClient, res *resource) error {
+	if res.collection {
+		return h.index(w, c, res)
+	}
+	repo, _, file := split(res.name)
+	// Read the commit that stat resolved, in case the branch has moved since
+	content, err := c.GetFileReadSeeker(repo, res.commitID, file)
+	if err != nil {
+		return err
+	}
+	if res.etag != "" {
+		w.Header().Set("ETag", res.etag)
+	}
+	http.ServeContent(w, r, path.Base(file), res.modified, content)
+	return nil
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Name}}</title></head>
+<body>
+<h1>{{.Name}}</h1>
+<ul>
+{{range .Children}}<li><a href="{{.Href}}">{{.Name}}</a></li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+// index writes an HTML listing of the collection 'res', for browsers
+func (h *handler) index(w http.ResponseWriter, c *client.APIClient, res *resource) error {
+	resources, err := children(c, res)
+	if err != nil {
+		return err
+	}
+	type link struct{ Name, Href string }
+	page := struct {
+		Name     string
+		Children []link
+	}{Name: "/" + res.name}
+	for _, child := range resources {
+		name := path.Base(child.name)
+		if child.collection {
+			name += "/"
+		}
+		page.Children = append(page.Children, link{Name: name, Href: h.href(child)})
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return indexTemplate.Execute(w, page)
+}
+
+// The types below are the XML elements of PROPFIND responses (RFC 4918)
+
+type multistatus struct {
+	XMLName   xml.Name   `xml:"D:multistatus"`
+	Namespace string     `xml:"xmlns:D,attr"`
+	Responses []response `xml:"D:response"`
+}
+
+type response struct {
+	Href     string   `xml:"D:href"`
+	Propstat propstat `xml:"D:propstat"`
+}
+
+type propstat struct {
+	Prop   prop   `xml:"D:prop"`
+	Status string `xml:"D:status"`
+}
+
+type prop struct {
+	DisplayName   string       `xml:"D:displayname"`
+	ResourceType  resourceType `xml:"D:resourcetype"`
+	ContentLength *uint64      `xml:"D:getcontentlength,omitempty"`
+	ContentType   string       `xml:"D:getcontenttype,omitempty"`
+	LastModified  string       `xml:"D:getlastmodified,omitempty"`
+	ETag          string       `xml:"D:getetag,omitempty"`
+	SupportedLock *struct{}    `xml:"D:supportedlock"`
+	LockDiscovery *struct{}    `xml:"D:lockdiscovery"`
+}
+
+type resourceType struct {
+	Collection *struct{} `xml:"D:collection,omitempty"`
+}
+
+func (h *handler) response(res *resource) response {
+	p := prop{
+		DisplayName:   path.Base("/" + res.name),
+		SupportedLock: &struct{}{},
+		LockDiscovery: &struct{}{},
+	}
+	if res.collection {
+		p.ResourceType.Collection = &struct{}{}
+	} else {
+		size := res.size
+		p.ContentLength = &size
+		p.ContentType = mime.TypeByExtension(path.Ext(res.name))
+		if p.ContentType == "" {
+			p.ContentType = "application/octet-stream"
+		}
+		p.ETag = res.etag
+	}
+	if !res.modified.IsZero() {
+		p.LastModified = res.modified.UTC().Format(http.TimeFormat)
+	}
+	return response{
+		Href: h.href(res),
+		Propstat: propstat{
+			Prop:   p,
+			Status: "HTTP/1.1 200 OK",
+		},
+	}
+}
+
+// propfind returns the properties of 'res', and of its children if the
+// request's depth is 1. All of the properties that the server supports are
+// returned, whichever ones are requested.
+func (h *handler) propfind(w http.ResponseWriter, r *http.Request, c *client.APIClient, res *resource) error {
+	depth := r.Header.Get("Depth")
+	if depth == "" {
+		depth = "infinity"
+	}
+	if depth != "0" && depth != "1" {
+		http.Error(w, "only PROPFIND requests with depth 0 or 1 are supported", http.StatusForbidden)
+		return nil
+	}
+	result := multistatus{
+		Namespace: "DAV:",
+		Responses: []response{h.response(res)},
+	}
+	if depth == "1" && res.collection {
+		resources, err := children(c, res)
+		if err != nil {
+			return err
+		}
+		for _, child := range resources {
+			result.Responses = append(result.Responses, h.response(child))
+		}
+	}
+	body, err := xml.Marshal(result)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", `application/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusMultiStatus)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+	return nil
+}
+
+func httpError(w http.ResponseWriter, err error) {
+	switch {
+	case auth.IsErrNotSignedIn(err):
+		w.Header().Set("WWW-Authenticate", `Basic realm="pachyderm"`)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case auth.IsErrNotAuthorized(err):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errutil.IsNotFoundError(err):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
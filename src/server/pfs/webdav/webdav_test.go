@@ -0,0 +1,156 @@
+package webdav
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	pachtesting "github.com/pachyderm/pachyderm/src/client/testing"
+)
+
+func newTestServer(t *testing.T) (*client.APIClient, *httptest.Server, func()) {
+	pachd := pachtesting.NewServer()
+	c, err := pachd.NewClient()
+	require.NoError(t, err)
+	server := httptest.NewServer(NewHandler("/dav", func(r *http.Request) (*client.APIClient, error) {
+		return c.WithCtx(r.Context()), nil
+	}))
+	return c, server, func() {
+		server.Close()
+		c.Close()
+		pachd.Close()
+	}
+}
+
+func do(t *testing.T, method string, url string, header map[string]string) (*http.Response, string) {
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+// propfind returns the hrefs in the response to a PROPFIND of 'url', and
+// whether each one is a collection
+func propfind(t *testing.T, url string, depth string) map[string]bool {
+	resp, body := do(t, "PROPFIND", url, map[string]string{"Depth": depth})
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	var result struct {
+		Responses []struct {
+			Href       string    `xml:"href"`
+			Collection *struct{} `xml:"propstat>prop>resourcetype>collection"`
+		} `xml:"response"`
+	}
+	require.NoError(t, xml.Unmarshal([]byte(body), &result))
+	hrefs := make(map[string]bool)
+	for _, r := range result.Responses {
+		hrefs[r.Href] = r.Collection != nil
+	}
+	return hrefs
+}
+
+func TestPropfind(t *testing.T) {
+	c, server, cleanup := newTestServer(t)
+	defer cleanup()
+	require.NoError(t, c.CreateRepo("images"))
+	_, err := c.PutFile("images", "master", "dir/a b.png", strings.NewReader("foo"))
+	require.NoError(t, err)
+	_, err = c.PutFile("images", "master", "c.png", strings.NewReader("bar"))
+	require.NoError(t, err)
+	require.NoError(t, c.CreateBranch("images", "empty", "", nil))
+
+	require.Equal(t, map[string]bool{"/dav/": true}, propfind(t, server.URL+"/dav/", "0"))
+	require.Equal(t, map[string]bool{
+		"/dav/":        true,
+		"/dav/images/": true,
+	}, propfind(t, server.URL+"/dav/", "1"))
+	require.Equal(t, map[string]bool{
+		"/dav/images/":        true,
+		"/dav/images/master/": true,
+		"/dav/images/empty/":  true,
+	}, propfind(t, server.URL+"/dav/images", "1"))
+	require.Equal(t, map[string]bool{
+		"/dav/images/master/":      true,
+		"/dav/images/master/dir/":  true,
+		"/dav/images/master/c.png": false,
+	}, propfind(t, server.URL+"/dav/images/master/", "1"))
+	require.Equal(t, map[string]bool{
+		"/dav/images/master/dir/":          true,
+		"/dav/images/master/dir/a%20b.png": false,
+	}, propfind(t, server.URL+"/dav/images/master/dir", "1"))
+	require.Equal(t, map[string]bool{
+		"/dav/images/empty/": true,
+	}, propfind(t, server.URL+"/dav/images/empty/", "1"))
+
+	resp, body := do(t, "PROPFIND", server.URL+"/dav/images/master/c.png", map[string]string{"Depth": "0"})
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	require.True(t, strings.Contains(body, "<D:getcontentlength>3</D:getcontentlength>"))
+	require.True(t, strings.Contains(body, "<D:getcontenttype>image/png</D:getcontenttype>"))
+
+	resp, _ = do(t, "PROPFIND", server.URL+"/dav/", nil)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, _ = do(t, "PROPFIND", server.URL+"/dav/labels/", map[string]string{"Depth": "1"})
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = do(t, "PROPFIND", server.URL+"/dav/images/master/d.png", map[string]string{"Depth": "1"})
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestGet(t *testing.T) {
+	c, server, cleanup := newTestServer(t)
+	defer cleanup()
+	require.NoError(t, c.CreateRepo("data"))
+	_, err := c.PutFile("data", "master", "file", strings.NewReader("foo"))
+	require.NoError(t, err)
+	commitInfo, err := c.InspectCommit("data", "master")
+	require.NoError(t, err)
+	_, err = c.PutFile("data", "master", "file", strings.NewReader("bar"))
+	require.NoError(t, err)
+
+	resp, body := do(t, "GET", server.URL+"/dav/data/master/file", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "foobar", body)
+	// Old versions can be read by commit ID
+	resp, body = do(t, "GET", server.URL+"/dav/data/"+commitInfo.Commit.ID+"/file", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "foo", body)
+	resp, body = do(t, "GET", server.URL+"/dav/data/master/file", map[string]string{"Range": "bytes=3-"})
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	require.Equal(t, "bar", body)
+
+	// Collections are listed as HTML
+	resp, body = do(t, "GET", server.URL+"/dav/data/master/", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.True(t, strings.Contains(body, `<a href="/dav/data/master/file">file</a>`))
+}
+
+func TestReadOnly(t *testing.T) {
+	_, server, cleanup := newTestServer(t)
+	defer cleanup()
+	resp, _ := do(t, "OPTIONS", server.URL+"/dav/", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "1", resp.Header.Get("DAV"))
+	var methods []string
+	for _, method := range strings.Split(resp.Header.Get("Allow"), ",") {
+		methods = append(methods, strings.TrimSpace(method))
+	}
+	sort.Strings(methods)
+	require.Equal(t, []string{"GET", "HEAD", "OPTIONS", "PROPFIND"}, methods)
+	for _, method := range []string{"PUT", "DELETE", "MKCOL", "LOCK"} {
+		resp, _ := do(t, method, server.URL+"/dav/data/master/file", nil)
+		require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+	resp, _ = do(t, "GET", server.URL+"/other", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
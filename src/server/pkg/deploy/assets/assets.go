@@ -59,7 +59,11 @@ var (
 	rolePolicyRules = []rbacv1.PolicyRule{{
 		APIGroups: []string{""},
 		Verbs:     []string{"get", "list", "watch"},
-		Resources: []string{"nodes", "pods", "pods/log", "endpoints"},
+		Resources: []string{"nodes", "pods", "pods/log", "endpoints", "events", "serviceaccounts"},
+	}, {
+		APIGroups: []string{"scheduling.k8s.io"},
+		Verbs:     []string{"get", "create"},
+		Resources: []string{"priorityclasses"},
 	}, {
 		APIGroups: []string{""},
 		Verbs:     []string{"get", "list", "watch", "create", "update", "delete"},
@@ -553,6 +557,11 @@ func PachdDeployment(opts *AssetOpts, objectStoreBackend backend, hostPath strin
 									Protocol:      "TCP",
 									Name:          "peer-port",
 								},
+								{
+									ContainerPort: 600, // also set in cmd/pachd/main.go
+									Protocol:      "TCP",
+									Name:          "s3gateway-port",
+								},
 								{
 									ContainerPort: githook.GitHookPort,
 									Protocol:      "TCP",
@@ -618,6 +627,11 @@ func PachdService(opts *AssetOpts) *v1.Service {
 					Name:     "api-http-port",
 					NodePort: 30652,
 				},
+				{
+					Port:     600, // also set in cmd/pachd/main.go
+					Name:     "s3gateway-port",
+					NodePort: 30600,
+				},
 				{
 					Port:     auth.SamlPort,
 					Name:     "saml-port",
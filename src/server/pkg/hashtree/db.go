@@ -192,7 +192,9 @@ func dirPrefix(path string) string {
 }
 
 // iterDir iterates through the nodes under path, it errors with PathNotFound if path doesn't exist, it errors with PathConflict if path exists but isn't a directory.
-func iterDir(tx *bolt.Tx, path string, f func(k, v []byte, c *bolt.Cursor) error) error {
+// If 'after' is set, the nodes whose names sort at or before it are skipped
+// without being read.
+func iterDir(tx *bolt.Tx, path string, after string, f func(k, v []byte, c *bolt.Cursor) error) error {
 	node, err := get(tx, path)
 	if err != nil {
 		return err
@@ -202,6 +204,9 @@ func iterDir(tx *bolt.Tx, path string, f func(k, v []byte, c *bolt.Cursor) error
 			path)
 	}
 	c := NewChildCursor(tx, path)
+	if after != "" {
+		c.seekAfter(after)
+	}
 	for k, v := c.K(), c.V(); k != nil; k, v = c.Next() {
 		if err := f(k, v, c.c); err != nil {
 			if err == errutil.ErrBreak {
@@ -213,8 +218,8 @@ func iterDir(tx *bolt.Tx, path string, f func(k, v []byte, c *bolt.Cursor) error
 	return nil
 }
 
-func list(tx *bolt.Tx, path string, f func(*NodeProto) error) error {
-	return iterDir(tx, path, func(_, v []byte, _ *bolt.Cursor) error {
+func list(tx *bolt.Tx, path string, after string, f func(*NodeProto) error) error {
+	return iterDir(tx, path, after, func(_, v []byte, _ *bolt.Cursor) error {
 		node := &NodeProto{}
 		if err := node.Unmarshal(v); err != nil {
 			return err
@@ -227,7 +232,16 @@ func list(tx *bolt.Tx, path string, f func(*NodeProto) error) error {
 func (h *dbHashTree) List(path string, f func(*NodeProto) error) error {
 	path = clean(path)
 	return h.View(func(tx *bolt.Tx) error {
-		return list(tx, path, f)
+		return list(tx, path, "", f)
+	})
+}
+
+// ListAfter is like List, but only calls f with the children of 'path' whose
+// names sort after 'after'.
+func (h *dbHashTree) ListAfter(path string, after string, f func(*NodeProto) error) error {
+	path = clean(path)
+	return h.View(func(tx *bolt.Tx) error {
+		return list(tx, path, after, f)
 	})
 }
 
@@ -315,6 +329,60 @@ func Glob(rs []io.ReadCloser, pattern string, f func(string, *NodeProto) error)
 	})
 }
 
+// compileRegex compiles 'pattern' into a regular expression that only matches
+// whole paths
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, errorf(MalformedGlob, "invalid regular expression: %v", err)
+	}
+	return re, nil
+}
+
+// GlobRegex executes a callback for each path that matches the regular
+// expression 'pattern'.
+func (h *dbHashTree) GlobRegex(pattern string, f func(string, *NodeProto) error) error {
+	re, err := compileRegex(pattern)
+	if err != nil {
+		return err
+	}
+	return h.View(func(tx *bolt.Tx) error {
+		c := fs(tx).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			path := externalDefault(s(k))
+			if !re.MatchString(path) {
+				continue
+			}
+			node := &NodeProto{}
+			if err := node.Unmarshal(v); err != nil {
+				return err
+			}
+			if err := f(path, node); err != nil {
+				if err == errutil.ErrBreak {
+					return nil
+				}
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GlobRegex executes a callback for each path that matches the regular
+// expression 'pattern'.
+func GlobRegex(rs []io.ReadCloser, pattern string, f func(string, *NodeProto) error) error {
+	re, err := compileRegex(pattern)
+	if err != nil {
+		return err
+	}
+	return nodes(rs, func(path string, node *NodeProto) error {
+		if re.MatchString(externalDefault(path)) {
+			return f(externalDefault(path), node)
+		}
+		return nil
+	})
+}
+
 // FSSize gets the size of the hashtree
 func (h *dbHashTree) FSSize() int64 {
 	rootNode, err := h.Get("/")
@@ -799,9 +867,16 @@ func (h *dbHashTree) putFile(path string, objects []*pfs.Object,
 		// Append new objects.  Remove existing objects if overwriting.
 		if overwriteIndex != nil && overwriteIndex.Index <= int64(len(node.FileNode.Objects)) {
 			node.FileNode.Objects = node.FileNode.Objects[:overwriteIndex.Index]
+			if overwriteIndex.Index == 0 {
+				// The whole file is replaced, so its metadata is dropped too
+				node.FileNode.Metadata = nil
+			}
 		}
 		node.SubtreeSize += sizeDelta
 		node.FileNode.Objects = append(node.FileNode.Objects, objects...)
+		// The file's contents changed, so its checksums no longer apply
+		node.FileNode.ContentSha256 = nil
+		node.FileNode.ContentMd5 = nil
 		// Put the node
 		if err := put(tx, path, node); err != nil {
 			return err
@@ -818,6 +893,46 @@ func (h *dbHashTree) putFile(path string, objects []*pfs.Object,
 	})
 }
 
+// SetFileChecksums implements the HashTree SetFileChecksums method
+func (h *dbHashTree) SetFileChecksums(path string, sha256Sum, md5Sum []byte) error {
+	path = clean(path)
+	return h.Batch(func(tx *bolt.Tx) error {
+		node, err := get(tx, path)
+		if err != nil {
+			return err
+		}
+		if node.nodetype() != file {
+			return errorf(PathConflict, "could not set checksums of %q; a file "+
+				"of type %s is there", path, node.nodetype())
+		}
+		node.FileNode.ContentSha256 = sha256Sum
+		node.FileNode.ContentMd5 = md5Sum
+		return put(tx, path, node)
+	})
+}
+
+// SetFileMetadata implements the HashTree SetFileMetadata method
+func (h *dbHashTree) SetFileMetadata(path string, metadata map[string]string) error {
+	path = clean(path)
+	return h.Batch(func(tx *bolt.Tx) error {
+		node, err := get(tx, path)
+		if err != nil {
+			return err
+		}
+		if node.nodetype() != file {
+			return errorf(PathConflict, "could not set metadata of %q; a file "+
+				"of type %s is there", path, node.nodetype())
+		}
+		if node.FileNode.Metadata == nil {
+			node.FileNode.Metadata = make(map[string]string)
+		}
+		for k, v := range metadata {
+			node.FileNode.Metadata[k] = v
+		}
+		return put(tx, path, node)
+	})
+}
+
 // PutDir creates a directory (or does nothing if one exists).
 func (h *dbHashTree) PutDir(path string) error {
 	path = clean(path)
@@ -1220,7 +1335,16 @@ func merge(ns []*MergeNode) (*MergeNode, error) {
 		// Merge file content
 		if base.nodeProto.nodetype() == file {
 			base.nodeProto.FileNode.BlockRefs = append(base.nodeProto.FileNode.BlockRefs, n.nodeProto.FileNode.BlockRefs...)
-
+			// the merged file's contents come from several files, whose
+			// checksums don't apply to it
+			base.nodeProto.FileNode.ContentSha256 = nil
+			base.nodeProto.FileNode.ContentMd5 = nil
+			for k, v := range n.nodeProto.FileNode.Metadata {
+				if base.nodeProto.FileNode.Metadata == nil {
+					base.nodeProto.FileNode.Metadata = make(map[string]string)
+				}
+				base.nodeProto.FileNode.Metadata[k] = v
+			}
 		}
 		hasher := pfs.NewHash()
 		hasher.Write(append(base.nodeProto.Hash, n.nodeProto.Hash...))
@@ -1355,7 +1479,7 @@ func canonicalize(tx *bolt.Tx, path string) error {
 		hash := sha256.New()
 		// Compute n.Hash by concatenating name + hash of all children of n.DirNode
 		// Note that the order of the children of n.DirNode are sorted when iterating.
-		if err := iterDir(tx, path, func(k, _ []byte, _ *bolt.Cursor) error {
+		if err := iterDir(tx, path, "", func(k, _ []byte, _ *bolt.Cursor) error {
 			childPath := s(k)
 			if err := canonicalize(tx, childPath); err != nil {
 				return err
@@ -1463,6 +1587,17 @@ func GlobLiteralPrefix(pattern string) string {
 	return pattern[:idx[0]]
 }
 
+// RegexLiteralPrefix returns a prefix of every path that the regular
+// expression 'pattern' matches (possibly "", if there's no such prefix)
+func RegexLiteralPrefix(pattern string) string {
+	re, err := compileRegex(pattern)
+	if err != nil {
+		return ""
+	}
+	prefix, _ := re.LiteralPrefix()
+	return prefix
+}
+
 // GetHashTreeObject is a convenience function to deserialize a HashTree from an object in the object store.
 func GetHashTreeObject(pachClient *client.APIClient, storageRoot string, treeRef *pfs.Object) (HashTree, error) {
 	return getHashTree(storageRoot, func(w io.Writer) error {
@@ -1579,6 +1714,17 @@ func (d *ChildCursor) Next() ([]byte, []byte) {
 	return k, v
 }
 
+// seekAfter moves the cursor to the first child whose name sorts after
+// 'name', skipping 'name' and everything under it.
+func (d *ChildCursor) seekAfter(name string) ([]byte, []byte) {
+	k, v := d.c.Seek(append(append(d.dir[:len(d.dir):len(d.dir)], name...), 1))
+	if !bytes.HasPrefix(k, d.dir) {
+		k, v = nil, nil
+	}
+	d.k, d.v = k, v
+	return k, v
+}
+
 func compare(a, b *ChildCursor) int {
 	switch {
 	case a.k == nil && b.k == nil:
@@ -38,17 +38,24 @@ type FileNodeProto struct {
 	// block_refs/objects. Without this signal, all calls to pfs.GetFile() would
 	// need to check the parent directory's metadata before beginning to return
 	// the file's contents, which would be slow.)
-	HasHeaderFooter      bool     `protobuf:"varint,6,opt,name=has_header_footer,json=hasHeaderFooter,proto3" json:"has_header_footer,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	HasHeaderFooter bool `protobuf:"varint,6,opt,name=has_header_footer,json=hasHeaderFooter,proto3" json:"has_header_footer,omitempty"`
+	// content_sha256 and content_md5 are checksums of this file's contents (see
+	// pfs.FileInfo). They're only set if the file was written by a single
+	// PutFile, and are cleared whenever the file is modified.
+	ContentSha256 []byte `protobuf:"bytes,7,opt,name=content_sha256,json=contentSha256,proto3" json:"content_sha256,omitempty"`
+	ContentMd5    []byte `protobuf:"bytes,8,opt,name=content_md5,json=contentMd5,proto3" json:"content_md5,omitempty"`
+	// metadata is user-provided key/value metadata (see pfs.FileInfo)
+	Metadata             map[string]string `protobuf:"bytes,9,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
 }
 
 func (m *FileNodeProto) Reset()         { *m = FileNodeProto{} }
 func (m *FileNodeProto) String() string { return proto.CompactTextString(m) }
 func (*FileNodeProto) ProtoMessage()    {}
 func (*FileNodeProto) Descriptor() ([]byte, []int) {
-	return fileDescriptor_hashtree_7c4d1e2389fbd556, []int{0}
+	return fileDescriptor_hashtree_4031bcda318008eb, []int{0}
 }
 func (m *FileNodeProto) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -98,6 +105,27 @@ func (m *FileNodeProto) GetHasHeaderFooter() bool {
 	return false
 }
 
+func (m *FileNodeProto) GetContentSha256() []byte {
+	if m != nil {
+		return m.ContentSha256
+	}
+	return nil
+}
+
+func (m *FileNodeProto) GetContentMd5() []byte {
+	if m != nil {
+		return m.ContentMd5
+	}
+	return nil
+}
+
+func (m *FileNodeProto) GetMetadata() map[string]string {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
 // Shared refers to data common to all direct children of a directory (i.e.
 // headers and footers)
 type Shared struct {
@@ -116,7 +144,7 @@ func (m *Shared) Reset()         { *m = Shared{} }
 func (m *Shared) String() string { return proto.CompactTextString(m) }
 func (*Shared) ProtoMessage()    {}
 func (*Shared) Descriptor() ([]byte, []int) {
-	return fileDescriptor_hashtree_7c4d1e2389fbd556, []int{1}
+	return fileDescriptor_hashtree_4031bcda318008eb, []int{1}
 }
 func (m *Shared) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -194,7 +222,7 @@ func (m *DirectoryNodeProto) Reset()         { *m = DirectoryNodeProto{} }
 func (m *DirectoryNodeProto) String() string { return proto.CompactTextString(m) }
 func (*DirectoryNodeProto) ProtoMessage()    {}
 func (*DirectoryNodeProto) Descriptor() ([]byte, []int) {
-	return fileDescriptor_hashtree_7c4d1e2389fbd556, []int{2}
+	return fileDescriptor_hashtree_4031bcda318008eb, []int{2}
 }
 func (m *DirectoryNodeProto) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -261,7 +289,7 @@ func (m *NodeProto) Reset()         { *m = NodeProto{} }
 func (m *NodeProto) String() string { return proto.CompactTextString(m) }
 func (*NodeProto) ProtoMessage()    {}
 func (*NodeProto) Descriptor() ([]byte, []int) {
-	return fileDescriptor_hashtree_7c4d1e2389fbd556, []int{3}
+	return fileDescriptor_hashtree_4031bcda318008eb, []int{3}
 }
 func (m *NodeProto) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -350,7 +378,7 @@ func (m *HashTreeProto) Reset()         { *m = HashTreeProto{} }
 func (m *HashTreeProto) String() string { return proto.CompactTextString(m) }
 func (*HashTreeProto) ProtoMessage()    {}
 func (*HashTreeProto) Descriptor() ([]byte, []int) {
-	return fileDescriptor_hashtree_7c4d1e2389fbd556, []int{4}
+	return fileDescriptor_hashtree_4031bcda318008eb, []int{4}
 }
 func (m *HashTreeProto) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -404,7 +432,7 @@ func (m *BucketHeader) Reset()         { *m = BucketHeader{} }
 func (m *BucketHeader) String() string { return proto.CompactTextString(m) }
 func (*BucketHeader) ProtoMessage()    {}
 func (*BucketHeader) Descriptor() ([]byte, []int) {
-	return fileDescriptor_hashtree_7c4d1e2389fbd556, []int{5}
+	return fileDescriptor_hashtree_4031bcda318008eb, []int{5}
 }
 func (m *BucketHeader) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -452,7 +480,7 @@ func (m *Index) Reset()         { *m = Index{} }
 func (m *Index) String() string { return proto.CompactTextString(m) }
 func (*Index) ProtoMessage()    {}
 func (*Index) Descriptor() ([]byte, []int) {
-	return fileDescriptor_hashtree_7c4d1e2389fbd556, []int{6}
+	return fileDescriptor_hashtree_4031bcda318008eb, []int{6}
 }
 func (m *Index) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -497,6 +525,7 @@ func (m *Index) GetOffset() uint64 {
 
 func init() {
 	proto.RegisterType((*FileNodeProto)(nil), "hashtree.FileNodeProto")
+	proto.RegisterMapType((map[string]string)(nil), "hashtree.FileNodeProto.MetadataEntry")
 	proto.RegisterType((*Shared)(nil), "hashtree.Shared")
 	proto.RegisterType((*DirectoryNodeProto)(nil), "hashtree.DirectoryNodeProto")
 	proto.RegisterType((*NodeProto)(nil), "hashtree.NodeProto")
@@ -554,6 +583,35 @@ func (m *FileNodeProto) MarshalTo(dAtA []byte) (int, error) {
 		}
 		i++
 	}
+	if len(m.ContentSha256) > 0 {
+		dAtA[i] = 0x3a
+		i++
+		i = encodeVarintHashtree(dAtA, i, uint64(len(m.ContentSha256)))
+		i += copy(dAtA[i:], m.ContentSha256)
+	}
+	if len(m.ContentMd5) > 0 {
+		dAtA[i] = 0x42
+		i++
+		i = encodeVarintHashtree(dAtA, i, uint64(len(m.ContentMd5)))
+		i += copy(dAtA[i:], m.ContentMd5)
+	}
+	if len(m.Metadata) > 0 {
+		for k, _ := range m.Metadata {
+			dAtA[i] = 0x4a
+			i++
+			v := m.Metadata[k]
+			mapSize := 1 + len(k) + sovHashtree(uint64(len(k))) + 1 + len(v) + sovHashtree(uint64(len(v)))
+			i = encodeVarintHashtree(dAtA, i, uint64(mapSize))
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintHashtree(dAtA, i, uint64(len(k)))
+			i += copy(dAtA[i:], k)
+			dAtA[i] = 0x12
+			i++
+			i = encodeVarintHashtree(dAtA, i, uint64(len(v)))
+			i += copy(dAtA[i:], v)
+		}
+	}
 	if m.XXX_unrecognized != nil {
 		i += copy(dAtA[i:], m.XXX_unrecognized)
 	}
@@ -858,6 +916,22 @@ func (m *FileNodeProto) Size() (n int) {
 	if m.HasHeaderFooter {
 		n += 2
 	}
+	l = len(m.ContentSha256)
+	if l > 0 {
+		n += 1 + l + sovHashtree(uint64(l))
+	}
+	l = len(m.ContentMd5)
+	if l > 0 {
+		n += 1 + l + sovHashtree(uint64(l))
+	}
+	if len(m.Metadata) > 0 {
+		for k, v := range m.Metadata {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovHashtree(uint64(len(k))) + 1 + len(v) + sovHashtree(uint64(len(v)))
+			n += mapEntrySize + 1 + sovHashtree(uint64(mapEntrySize))
+		}
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -1130,6 +1204,186 @@ func (m *FileNodeProto) Unmarshal(dAtA []byte) error {
 				}
 			}
 			m.HasHeaderFooter = bool(v != 0)
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ContentSha256", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHashtree
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthHashtree
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ContentSha256 = append(m.ContentSha256[:0], dAtA[iNdEx:postIndex]...)
+			if m.ContentSha256 == nil {
+				m.ContentSha256 = []byte{}
+			}
+			iNdEx = postIndex
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ContentMd5", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHashtree
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthHashtree
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ContentMd5 = append(m.ContentMd5[:0], dAtA[iNdEx:postIndex]...)
+			if m.ContentMd5 == nil {
+				m.ContentMd5 = []byte{}
+			}
+			iNdEx = postIndex
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Metadata", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHashtree
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthHashtree
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Metadata == nil {
+				m.Metadata = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowHashtree
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowHashtree
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthHashtree
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowHashtree
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthHashtree
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipHashtree(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthHashtree
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.Metadata[mapkey] = mapvalue
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipHashtree(dAtA[iNdEx:])
@@ -2096,46 +2350,51 @@ var (
 )
 
 func init() {
-	proto.RegisterFile("server/pkg/hashtree/hashtree.proto", fileDescriptor_hashtree_7c4d1e2389fbd556)
-}
-
-var fileDescriptor_hashtree_7c4d1e2389fbd556 = []byte{
-	// 582 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x53, 0x4d, 0x6f, 0xd3, 0x40,
-	0x10, 0x65, 0x6d, 0x27, 0x71, 0x26, 0xa9, 0x08, 0x0b, 0x02, 0xab, 0x42, 0x69, 0x30, 0x02, 0x05,
-	0x04, 0x89, 0x54, 0x10, 0x20, 0x8e, 0x15, 0x54, 0x25, 0x07, 0x40, 0x5b, 0x4e, 0x5c, 0x22, 0x7f,
-	0x8c, 0x6b, 0x13, 0xd7, 0x1b, 0xed, 0x3a, 0x15, 0xe9, 0xef, 0xe0, 0xc0, 0x99, 0x03, 0xff, 0x04,
-	0x89, 0x23, 0x3f, 0x01, 0x95, 0x3f, 0x82, 0xf6, 0xa3, 0x75, 0x0b, 0x3d, 0x44, 0x9a, 0xf7, 0xe6,
-	0xcd, 0xf3, 0xbc, 0xcd, 0x2e, 0x84, 0x12, 0xc5, 0x11, 0x8a, 0xe9, 0x72, 0x71, 0x30, 0xcd, 0x23,
-	0x99, 0xd7, 0x02, 0xf1, 0xac, 0x98, 0x2c, 0x05, 0xaf, 0x39, 0xf5, 0x4f, 0xf1, 0xe6, 0x8d, 0xa4,
-	0x2c, 0xb0, 0xaa, 0xa7, 0xcb, 0x4c, 0xaa, 0x9f, 0xe9, 0x87, 0xdf, 0x08, 0x6c, 0xec, 0x16, 0x25,
-	0xbe, 0xe5, 0x29, 0xbe, 0xd7, 0x13, 0xf7, 0xa0, 0xc3, 0xe3, 0x4f, 0x98, 0xd4, 0x32, 0xf0, 0x46,
-	0xee, 0xb8, 0xb7, 0xdd, 0x9b, 0x28, 0xf9, 0x3b, 0xcd, 0xb1, 0xd3, 0x1e, 0x7d, 0x04, 0x10, 0x97,
-	0x3c, 0x59, 0xcc, 0x05, 0x66, 0x32, 0x68, 0x69, 0xe5, 0x86, 0x56, 0xee, 0x28, 0x9a, 0x61, 0xc6,
-	0xba, 0xb1, 0xad, 0x24, 0x7d, 0x08, 0xd7, 0xf2, 0x48, 0xce, 0x73, 0x8c, 0x52, 0x14, 0xf3, 0x8c,
-	0xf3, 0x1a, 0x45, 0xd0, 0x1e, 0x91, 0xb1, 0xcf, 0xae, 0xe6, 0x91, 0xdc, 0xd3, 0xfc, 0xae, 0xa6,
-	0x67, 0x9e, 0x4f, 0x06, 0xce, 0xcc, 0xf3, 0x9d, 0x81, 0x3b, 0xf3, 0x7c, 0x77, 0xe0, 0x85, 0x5f,
-	0x08, 0xb4, 0xf7, 0xf3, 0x48, 0x60, 0x4a, 0xef, 0x42, 0xdb, 0x98, 0x04, 0x64, 0x44, 0xfe, 0x5d,
-	0xce, 0xb6, 0x94, 0xc8, 0x7e, 0xc2, 0xb9, 0x44, 0x64, 0x5a, 0x74, 0x0b, 0x7a, 0x76, 0x1d, 0x59,
-	0x1c, 0x63, 0xe0, 0x8e, 0xc8, 0xd8, 0x65, 0x60, 0xa8, 0xfd, 0xe2, 0x18, 0x95, 0xc0, 0x48, 0x8d,
-	0xc0, 0x33, 0x02, 0x43, 0x29, 0x41, 0x98, 0x01, 0x7d, 0x55, 0x08, 0x4c, 0x6a, 0x2e, 0xd6, 0xcd,
-	0xf9, 0x6d, 0x82, 0x9f, 0xe4, 0x45, 0x99, 0x0a, 0xac, 0x02, 0x77, 0xe4, 0x8e, 0xbb, 0xec, 0x0c,
-	0xd3, 0x31, 0xb4, 0xa5, 0xce, 0xa1, 0xdd, 0x7a, 0xdb, 0x83, 0xc9, 0xd9, 0xdf, 0x65, 0xf2, 0x31,
-	0xdb, 0x3f, 0x7f, 0x08, 0xe1, 0x0f, 0x02, 0xdd, 0xc6, 0x9f, 0x82, 0x57, 0x45, 0x87, 0xa8, 0xf3,
-	0x77, 0x99, 0xae, 0x15, 0xa7, 0x8c, 0x74, 0xdc, 0x3e, 0xd3, 0x35, 0xbd, 0x03, 0x7d, 0xb9, 0x8a,
-	0x95, 0xf7, 0xf9, 0x80, 0x3d, 0xcb, 0xe9, 0x84, 0x4f, 0xa1, 0x9b, 0x15, 0x25, 0xce, 0x2b, 0x9e,
-	0xa2, 0xdd, 0xe8, 0x56, 0xb3, 0xd1, 0x85, 0x6b, 0xc1, 0xfc, 0xcc, 0x42, 0xfa, 0x1c, 0xfc, 0xb4,
-	0x10, 0x66, 0xa8, 0xa5, 0x87, 0x6e, 0x37, 0x43, 0xff, 0x1f, 0x08, 0xeb, 0xa4, 0x85, 0x50, 0x28,
-	0xfc, 0x4e, 0x60, 0x63, 0x2f, 0x92, 0xf9, 0x07, 0x81, 0x36, 0x4b, 0x00, 0x9d, 0x23, 0x14, 0xb2,
-	0xe0, 0x95, 0x8e, 0xd3, 0x62, 0xa7, 0x90, 0x4e, 0xc1, 0xc9, 0x64, 0xe0, 0xe8, 0x6b, 0xb5, 0xd5,
-	0xd8, 0x5f, 0x18, 0x9f, 0xec, 0xca, 0xd7, 0x55, 0x2d, 0xd6, 0xcc, 0xc9, 0xe4, 0xe6, 0x0c, 0x3a,
-	0x16, 0xd2, 0x01, 0xb8, 0x0b, 0x5c, 0xdb, 0x03, 0x52, 0x25, 0x7d, 0x00, 0xad, 0xa3, 0xa8, 0x5c,
-	0xa1, 0xbd, 0x0f, 0xd7, 0x1b, 0xc3, 0x66, 0x4d, 0xa3, 0x78, 0xe9, 0xbc, 0x20, 0xe1, 0x7d, 0xe8,
-	0xef, 0xac, 0x92, 0x05, 0xd6, 0xe6, 0x5e, 0xd2, 0x9b, 0xd0, 0x8e, 0x35, 0xb6, 0x9e, 0x16, 0x85,
-	0x8f, 0xa1, 0xf5, 0xa6, 0x4a, 0xf1, 0x33, 0xed, 0x03, 0x59, 0xe8, 0x5e, 0x9f, 0x91, 0x85, 0x92,
-	0xf3, 0x2c, 0x93, 0x58, 0xeb, 0xcf, 0x79, 0xcc, 0xa2, 0x9d, 0xbd, 0x9f, 0x27, 0x43, 0xf2, 0xeb,
-	0x64, 0x48, 0x7e, 0x9f, 0x0c, 0xc9, 0xd7, 0x3f, 0xc3, 0x2b, 0x1f, 0x9f, 0x1d, 0x14, 0x75, 0xbe,
-	0x8a, 0x27, 0x09, 0x3f, 0x9c, 0x2e, 0xa3, 0x24, 0x5f, 0xa7, 0x28, 0xce, 0x57, 0x52, 0x24, 0xd3,
-	0x4b, 0x1e, 0x79, 0xdc, 0xd6, 0x8f, 0xf7, 0xc9, 0xdf, 0x00, 0x00, 0x00, 0xff, 0xff, 0x5c, 0x3f,
-	0xaa, 0x91, 0x02, 0x04, 0x00, 0x00,
+	proto.RegisterFile("server/pkg/hashtree/hashtree.proto", fileDescriptor_hashtree_4031bcda318008eb)
+}
+
+var fileDescriptor_hashtree_4031bcda318008eb = []byte{
+	// 667 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x54, 0xc1, 0x6e, 0xd3, 0x4c,
+	0x10, 0xfe, 0xd7, 0x76, 0x12, 0x67, 0x92, 0xfc, 0x84, 0xa5, 0x02, 0x2b, 0x42, 0x69, 0x30, 0x2a,
+	0x0a, 0x08, 0x12, 0x29, 0xd0, 0x82, 0xe0, 0x44, 0x05, 0x55, 0x89, 0x54, 0x40, 0x5b, 0x4e, 0x5c,
+	0x22, 0xc7, 0x1e, 0xd7, 0x26, 0x89, 0x1d, 0xed, 0x6e, 0x2a, 0xd2, 0xe7, 0xe0, 0xc0, 0x13, 0xf0,
+	0x26, 0x48, 0x1c, 0x79, 0x04, 0x54, 0x6e, 0x3c, 0x05, 0xf2, 0x7a, 0x13, 0xa7, 0x50, 0x0e, 0x96,
+	0xe6, 0xfb, 0xe6, 0x9b, 0xf1, 0xce, 0xe7, 0xf1, 0x82, 0x2b, 0x90, 0x9f, 0x22, 0xef, 0xcf, 0x27,
+	0x27, 0xfd, 0xc8, 0x13, 0x91, 0xe4, 0x88, 0xeb, 0xa0, 0x37, 0xe7, 0xa9, 0x4c, 0xa9, 0xbd, 0xc2,
+	0xad, 0x2d, 0x7f, 0x1a, 0x63, 0x22, 0xfb, 0xf3, 0x50, 0x64, 0x4f, 0x9e, 0x77, 0x7f, 0x19, 0xd0,
+	0x38, 0x88, 0xa7, 0xf8, 0x3a, 0x0d, 0xf0, 0xad, 0xaa, 0xd8, 0x81, 0x4a, 0x3a, 0xfe, 0x80, 0xbe,
+	0x14, 0x8e, 0xd5, 0x31, 0xbb, 0xb5, 0x41, 0xad, 0x97, 0xc9, 0xdf, 0x28, 0x8e, 0xad, 0x72, 0xf4,
+	0x3e, 0xc0, 0x78, 0x9a, 0xfa, 0x93, 0x11, 0xc7, 0x50, 0x38, 0x25, 0xa5, 0x6c, 0x28, 0xe5, 0x7e,
+	0x46, 0x33, 0x0c, 0x59, 0x75, 0xac, 0x23, 0x41, 0xef, 0xc1, 0xd5, 0xc8, 0x13, 0xa3, 0x08, 0xbd,
+	0x00, 0xf9, 0x28, 0x4c, 0x53, 0x89, 0xdc, 0x29, 0x77, 0x48, 0xd7, 0x66, 0x57, 0x22, 0x4f, 0x1c,
+	0x2a, 0xfe, 0x40, 0xd1, 0x74, 0x07, 0xfe, 0xf7, 0xd3, 0x44, 0x62, 0x22, 0x47, 0x22, 0xf2, 0x06,
+	0xbb, 0x7b, 0x4e, 0xa5, 0x43, 0xba, 0x75, 0xd6, 0xd0, 0xec, 0xb1, 0x22, 0xe9, 0x36, 0xd4, 0x56,
+	0xb2, 0x59, 0xb0, 0xeb, 0xd8, 0x4a, 0x03, 0x9a, 0x3a, 0x0a, 0x76, 0xe9, 0x73, 0xb0, 0x67, 0x28,
+	0xbd, 0xc0, 0x93, 0x9e, 0x53, 0x55, 0xe7, 0xdb, 0xe9, 0xad, 0xdd, 0xb9, 0x30, 0x73, 0xef, 0x48,
+	0xeb, 0x5e, 0x26, 0x92, 0x2f, 0xd9, 0xba, 0xac, 0xf5, 0x0c, 0x1a, 0x17, 0x52, 0xb4, 0x09, 0xe6,
+	0x04, 0x97, 0x0e, 0xe9, 0x90, 0x6e, 0x95, 0x65, 0x21, 0xdd, 0x82, 0xd2, 0xa9, 0x37, 0x5d, 0xa0,
+	0x63, 0x28, 0x2e, 0x07, 0x4f, 0x8d, 0x27, 0x64, 0x68, 0xd9, 0xa4, 0x69, 0x0c, 0x2d, 0xdb, 0x68,
+	0x9a, 0x43, 0xcb, 0x36, 0x9b, 0x96, 0xfb, 0x89, 0x40, 0xf9, 0x38, 0xf2, 0x38, 0x06, 0xf4, 0x36,
+	0x94, 0x73, 0x33, 0x54, 0xaf, 0x3f, 0x4c, 0xd6, 0xa9, 0x4c, 0xa4, 0xad, 0x32, 0x2e, 0x11, 0xe5,
+	0xa9, 0xcc, 0x07, 0x6d, 0xab, 0x88, 0xcf, 0xd0, 0x31, 0x3b, 0xa4, 0x6b, 0x32, 0xc8, 0xa9, 0xe3,
+	0xf8, 0x0c, 0x33, 0x41, 0x2e, 0xcd, 0x05, 0x56, 0x2e, 0xc8, 0xa9, 0x4c, 0xe0, 0x86, 0x40, 0x5f,
+	0xc4, 0x1c, 0x7d, 0x99, 0xf2, 0x65, 0xb1, 0x07, 0x2d, 0xb0, 0xfd, 0x28, 0x9e, 0x06, 0x1c, 0x13,
+	0xc7, 0xec, 0x98, 0xdd, 0x2a, 0x5b, 0x63, 0xda, 0x85, 0xb2, 0x50, 0x73, 0xa8, 0x6e, 0xb5, 0x41,
+	0xb3, 0x30, 0x36, 0x9f, 0x8f, 0xe9, 0xfc, 0xa6, 0x09, 0xee, 0x57, 0x02, 0xd5, 0xa2, 0x3f, 0x05,
+	0x2b, 0xf1, 0x66, 0xa8, 0xbd, 0x54, 0x71, 0xc6, 0x65, 0x8d, 0xd4, 0xb8, 0x75, 0xa6, 0x62, 0x7a,
+	0x0b, 0xea, 0x62, 0x31, 0xce, 0x7a, 0x6f, 0x0e, 0x58, 0xd3, 0x9c, 0x9a, 0xf0, 0x11, 0x54, 0xc3,
+	0x78, 0x8a, 0xa3, 0x24, 0x0d, 0x50, 0x9f, 0xe8, 0xc6, 0x3f, 0x3e, 0x35, 0xb3, 0x43, 0x0d, 0xe9,
+	0x63, 0xb0, 0x83, 0x98, 0xe7, 0x45, 0x25, 0x55, 0x74, 0xb3, 0x28, 0xfa, 0xdb, 0x10, 0x56, 0x09,
+	0x62, 0x9e, 0x21, 0xf7, 0x0b, 0x81, 0xc6, 0xa1, 0x27, 0xa2, 0x77, 0x1c, 0xf5, 0x2c, 0x0e, 0x54,
+	0x4e, 0x91, 0x8b, 0x38, 0x4d, 0xd4, 0x38, 0x25, 0xb6, 0x82, 0xb4, 0x0f, 0x46, 0x28, 0x1c, 0x43,
+	0xad, 0xdf, 0x76, 0xd1, 0xfe, 0x42, 0x79, 0xef, 0x40, 0xe4, 0x8b, 0x67, 0x84, 0xa2, 0x35, 0x84,
+	0x8a, 0x86, 0x97, 0x2c, 0xdb, 0xdd, 0xcd, 0x65, 0xab, 0x0d, 0xae, 0x15, 0x0d, 0x8b, 0x63, 0x16,
+	0x1b, 0xe8, 0xde, 0x81, 0xfa, 0xfe, 0xc2, 0x9f, 0xa0, 0xcc, 0xff, 0x2f, 0x7a, 0x1d, 0xca, 0x63,
+	0x85, 0x75, 0x4f, 0x8d, 0xdc, 0x07, 0x50, 0x7a, 0x95, 0x04, 0xf8, 0x91, 0xd6, 0x81, 0x4c, 0x54,
+	0xae, 0xce, 0xc8, 0x24, 0x93, 0xa7, 0x61, 0x28, 0x50, 0xaa, 0xd7, 0x59, 0x4c, 0xa3, 0xfd, 0xc3,
+	0x6f, 0xe7, 0x6d, 0xf2, 0xfd, 0xbc, 0x4d, 0x7e, 0x9c, 0xb7, 0xc9, 0xe7, 0x9f, 0xed, 0xff, 0xde,
+	0xef, 0x9d, 0xc4, 0x32, 0x5a, 0x8c, 0x7b, 0x7e, 0x3a, 0xeb, 0xcf, 0x3d, 0x3f, 0x5a, 0x06, 0xc8,
+	0x37, 0x23, 0xc1, 0xfd, 0xfe, 0x25, 0x97, 0xd5, 0xb8, 0xac, 0x2e, 0xa1, 0x87, 0xbf, 0x03, 0x00,
+	0x00, 0xff, 0xff, 0x2c, 0xd3, 0x95, 0x72, 0xca, 0x04, 0x00, 0x00,
 }
@@ -409,6 +409,52 @@ func TestRewriteChangesHash(t *testing.T) {
 	require.Equal(t, rootPre.SubtreeSize, rootPost.SubtreeSize)
 }
 
+func TestFileChecksums(t *testing.T) {
+	h := newHashTree(t)
+	require.NoError(t, h.PutFile("/foo", obj(`hash:"20c27"`), 1))
+	require.NoError(t, h.SetFileChecksums("/foo", []byte("sha256"), []byte("md5")))
+	require.NoError(t, h.Hash())
+	require.Equal(t, []byte("sha256"), getT(t, h, "/foo").FileNode.ContentSha256)
+	require.Equal(t, []byte("md5"), getT(t, h, "/foo").FileNode.ContentMd5)
+
+	// Checksums don't change the file's hash
+	h2 := newHashTree(t)
+	require.NoError(t, h2.PutFile("/foo", obj(`hash:"20c27"`), 1))
+	require.NoError(t, h2.Hash())
+	require.Equal(t, getT(t, h, "/foo").Hash, getT(t, h2, "/foo").Hash)
+
+	// Modifying the file clears its checksums
+	require.NoError(t, h.PutFile("/foo", obj(`hash:"413e7"`), 1))
+	require.NoError(t, h.Hash())
+	require.Nil(t, getT(t, h, "/foo").FileNode.ContentSha256)
+	require.Nil(t, getT(t, h, "/foo").FileNode.ContentMd5)
+
+	// Directories have no checksums
+	require.NoError(t, h.PutDir("/dir"))
+	require.YesError(t, h.SetFileChecksums("/dir", []byte("sha256"), []byte("md5")))
+	require.YesError(t, h.SetFileChecksums("/missing", []byte("sha256"), []byte("md5")))
+}
+
+func TestFileMetadata(t *testing.T) {
+	h := newHashTree(t)
+	require.NoError(t, h.PutFile("/foo", obj(`hash:"20c27"`), 1))
+	require.NoError(t, h.SetFileMetadata("/foo", map[string]string{"a": "1", "b": "2"}))
+	require.NoError(t, h.SetFileMetadata("/foo", map[string]string{"b": "3"}))
+	require.NoError(t, h.Hash())
+	require.Equal(t, map[string]string{"a": "1", "b": "3"}, getT(t, h, "/foo").FileNode.Metadata)
+
+	// Appending to the file keeps its metadata, but overwriting it doesn't
+	require.NoError(t, h.PutFile("/foo", obj(`hash:"413e7"`), 1))
+	require.NoError(t, h.Hash())
+	require.Equal(t, "1", getT(t, h, "/foo").FileNode.Metadata["a"])
+	require.NoError(t, h.PutFileOverwrite("/foo", obj(`hash:"413e7"`), &pfs.OverwriteIndex{}, 0))
+	require.NoError(t, h.Hash())
+	require.Equal(t, 0, len(getT(t, h, "/foo").FileNode.Metadata))
+
+	require.NoError(t, h.PutDir("/dir"))
+	require.YesError(t, h.SetFileMetadata("/dir", map[string]string{"a": "1"}))
+}
+
 func TestIsGlob(t *testing.T) {
 	require.True(t, IsGlob(`*`))
 	require.True(t, IsGlob(`path/to*/file`))
@@ -456,6 +502,38 @@ func TestGlobFile(t *testing.T) {
 		require.NoError(t, h.Glob(pattern, addTo(&paths)))
 		require.ElementsEqual(t, i("/dir/bar", "/dir/buzz"), paths)
 	}
+
+	// brace expansion
+	var paths []string
+	require.NoError(t, h.Glob("/{foo,dir/bar}", addTo(&paths)))
+	require.ElementsEqual(t, i("/foo", "/dir/bar"), paths)
+}
+
+func TestGlobRegex(t *testing.T) {
+	h := newHashTree(t)
+	require.NoError(t, h.PutFile("/foo", obj(`hash:"20c27"`), 1))
+	require.NoError(t, h.PutFile("/dir/bar", obj(`hash:"ebc57"`), 1))
+	require.NoError(t, h.PutFile("/dir/buzz", obj(`hash:"8e02c"`), 1))
+	require.NoError(t, h.Hash())
+
+	paths := func(pattern string) []string {
+		var result []string
+		require.NoError(t, h.GlobRegex(pattern, func(path string, _ *NodeProto) error {
+			result = append(result, path)
+			return nil
+		}))
+		return result
+	}
+	require.ElementsEqual(t, i("/"), paths("/"))
+	require.ElementsEqual(t, i("/foo", "/dir"), paths("/[^/]+"))
+	require.ElementsEqual(t, i("/dir/bar", "/dir/buzz"), paths("/dir/b.*"))
+	// the whole path must match
+	require.ElementsEqual(t, i("/dir/bar"), paths("/dir/(bar|foo)"))
+	require.Equal(t, 0, len(paths("dir")))
+	require.YesError(t, h.GlobRegex("(", func(string, *NodeProto) error { return nil }))
+
+	require.Equal(t, "/dir/b", RegexLiteralPrefix("/dir/b.*"))
+	require.Equal(t, "", RegexLiteralPrefix("(/foo|/dir)"))
 }
 
 // Test that Walk() works
@@ -557,6 +635,40 @@ func TestListEmpty(t *testing.T) {
 	require.NoError(t, tree.Glob("/*", nop))
 }
 
+func TestListAfter(t *testing.T) {
+	h := newHashTree(t)
+	for _, p := range []string{"/a", "/b", "/b/c", "/bb", "/c", "/dir/a", "/dir/b", "/dir/c"} {
+		if p == "/b" {
+			continue // "/b" is a directory, created by "/b/c"
+		}
+		require.NoError(t, h.PutFile(p, obj(`hash:"20c27"`), 1))
+	}
+	require.NoError(t, h.Hash())
+	names := func(path, after string) []string {
+		var result []string
+		require.NoError(t, h.ListAfter(path, after, func(node *NodeProto) error {
+			result = append(result, node.Name)
+			return nil
+		}))
+		return result
+	}
+	require.Equal(t, []string{"a", "b", "bb", "c", "dir"}, names("/", ""))
+	require.Equal(t, []string{"bb", "c", "dir"}, names("/", "b"))
+	require.Equal(t, []string{"c", "dir"}, names("/", "bb"))
+	// 'after' doesn't need to exist
+	require.Equal(t, []string{"b", "bb", "c", "dir"}, names("/", "aa"))
+	require.Equal(t, []string(nil), names("/", "z"))
+	require.Equal(t, []string{"c"}, names("/dir", "b"))
+	require.Equal(t, []string(nil), names("/dir", "c"))
+
+	err := h.ListAfter("/a", "b", func(*NodeProto) error { return nil })
+	require.YesError(t, err)
+	require.Equal(t, PathConflict, Code(err))
+	err = h.ListAfter("/nonexistent", "b", func(*NodeProto) error { return nil })
+	require.YesError(t, err)
+	require.Equal(t, PathNotFound, Code(err))
+}
+
 func diffTrees(t *testing.T, new, old HashTree, path string) ([]string, []string) {
 	var newFiles []string
 	var oldFiles []string
@@ -73,12 +73,20 @@ type HashTree interface {
 	// List calls f with the files and subdirectories of the directory at 'path'.
 	List(path string, f func(node *NodeProto) error) error
 
+	// ListAfter is like List, but only calls f with the children of 'path'
+	// whose names sort after 'after'.
+	ListAfter(path string, after string, f func(node *NodeProto) error) error
+
 	// ListAll is like List but aggregates its results into a slice.
 	ListAll(path string) ([]*NodeProto, error)
 
 	// Glob calls f with the file/directory paths and nodes that match 'pattern'.
 	Glob(pattern string, f func(path string, node *NodeProto) error) error
 
+	// GlobRegex is like Glob, but 'pattern' is a regular expression that must
+	// match the whole of each path (e.g. /dir/[^/]*\.csv).
+	GlobRegex(pattern string, f func(path string, node *NodeProto) error) error
+
 	// FSSize gets the size of the file system that this tree represents.
 	// It's essentially a helper around h.Get("/").SubtreeBytes
 	FSSize() int64
@@ -146,6 +154,15 @@ type HashTree interface {
 	// the size of the objects removed.
 	PutFileOverwrite(path string, objects []*pfs.Object, overwriteIndex *pfs.OverwriteIndex, sizeDelta int64) error
 
+	// SetFileChecksums sets the checksums of the contents of the file at
+	// 'path'. PutFile, PutFileHeaderFooter and PutFileOverwrite clear a file's
+	// checksums, so this must be called after the file is written.
+	SetFileChecksums(path string, sha256Sum, md5Sum []byte) error
+
+	// SetFileMetadata adds 'metadata' to the user-provided metadata of the
+	// file at 'path', replacing the values of any keys it already has.
+	SetFileMetadata(path string, metadata map[string]string) error
+
 	// PutDir creates a directory (or does nothing if one exists).
 	PutDir(path string) error
 
@@ -0,0 +1,113 @@
+package kms
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/jsonrpc"
+	"golang.org/x/net/context"
+)
+
+// awsKey is a key in AWS KMS. The vendored AWS SDK doesn't include a KMS
+// client, so this calls KMS's JSON API with the SDK's generic client.
+type awsKey struct {
+	client *client.Client
+	keyID  string
+}
+
+type awsEncryptInput struct {
+	_         struct{} `type:"structure"`
+	KeyId     *string  `type:"string"`
+	Plaintext []byte   `type:"blob"`
+}
+
+type awsEncryptOutput struct {
+	_              struct{} `type:"structure"`
+	CiphertextBlob []byte   `type:"blob"`
+}
+
+type awsDecryptInput struct {
+	_              struct{} `type:"structure"`
+	CiphertextBlob []byte   `type:"blob"`
+}
+
+type awsDecryptOutput struct {
+	_         struct{} `type:"structure"`
+	KeyId     *string  `type:"string"`
+	Plaintext []byte   `type:"blob"`
+}
+
+func newAWSKey(keyID string, region string) (Key, error) {
+	// Keys' ARNs include their region
+	if region == "" && strings.HasPrefix(keyID, "arn:") {
+		if fields := strings.Split(keyID, ":"); len(fields) > 3 {
+			region = fields[3]
+		}
+	}
+	config := aws.NewConfig()
+	if region != "" {
+		config = config.WithRegion(region)
+	}
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, fmt.Errorf("could not create AWS session: %v", err)
+	}
+	clientConfig := sess.ClientConfig("kms")
+	c := client.New(
+		*clientConfig.Config,
+		metadata.ClientInfo{
+			ServiceName:   "kms",
+			SigningName:   clientConfig.SigningName,
+			SigningRegion: clientConfig.SigningRegion,
+			Endpoint:      clientConfig.Endpoint,
+			APIVersion:    "2014-11-01",
+			JSONVersion:   "1.1",
+			TargetPrefix:  "TrentService",
+		},
+		clientConfig.Handlers,
+	)
+	c.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	c.Handlers.Build.PushBackNamed(jsonrpc.BuildHandler)
+	c.Handlers.Unmarshal.PushBackNamed(jsonrpc.UnmarshalHandler)
+	c.Handlers.UnmarshalMeta.PushBackNamed(jsonrpc.UnmarshalMetaHandler)
+	c.Handlers.UnmarshalError.PushBackNamed(jsonrpc.UnmarshalErrorHandler)
+	return &awsKey{client: c, keyID: keyID}, nil
+}
+
+func (k *awsKey) send(ctx context.Context, operation string, input, output interface{}) error {
+	req := k.client.NewRequest(&request.Operation{
+		Name:       operation,
+		HTTPMethod: "POST",
+		HTTPPath:   "/",
+	}, input, output)
+	req.SetContext(ctx)
+	if err := req.Send(); err != nil {
+		return fmt.Errorf("error calling AWS KMS %s with key %s: %v", operation, k.keyID, err)
+	}
+	return nil
+}
+
+func (k *awsKey) Wrap(ctx context.Context, dataKey []byte) ([]byte, error) {
+	output := &awsEncryptOutput{}
+	if err := k.send(ctx, "Encrypt", &awsEncryptInput{
+		KeyId:     aws.String(k.keyID),
+		Plaintext: dataKey,
+	}, output); err != nil {
+		return nil, err
+	}
+	return output.CiphertextBlob, nil
+}
+
+func (k *awsKey) Unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	output := &awsDecryptOutput{}
+	if err := k.send(ctx, "Decrypt", &awsDecryptInput{CiphertextBlob: wrapped}, output); err != nil {
+		return nil, err
+	}
+	return output.Plaintext, nil
+}
@@ -0,0 +1,82 @@
+package kms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	gcpKMSEndpoint = "https://cloudkms.googleapis.com/v1/"
+	gcpKMSScope    = "https://www.googleapis.com/auth/cloudkms"
+)
+
+// gcpKey is a key in Google Cloud KMS, which is called through its REST API
+type gcpKey struct {
+	client *http.Client
+	name   string
+}
+
+func newGCPKey(name string) (Key, error) {
+	client, err := google.DefaultClient(context.Background(), gcpKMSScope)
+	if err != nil {
+		return nil, fmt.Errorf("could not get Google Cloud credentials: %v", err)
+	}
+	return &gcpKey{client: client, name: name}, nil
+}
+
+// call calls 'method' ("encrypt" or "decrypt") on the key. Byte slices in
+// 'request' and 'response' are base64-encoded, as the API expects.
+func (k *gcpKey) call(ctx context.Context, method string, request, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", gcpKMSEndpoint+k.name+":"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := k.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("error calling Google Cloud KMS %s with key %s: %v", method, k.name, err)
+	}
+	defer resp.Body.Close()
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error calling Google Cloud KMS %s with key %s: %s: %s", method, k.name, resp.Status, body)
+	}
+	return json.Unmarshal(body, response)
+}
+
+func (k *gcpKey) Wrap(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var response struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	if err := k.call(ctx, "encrypt", struct {
+		Plaintext []byte `json:"plaintext"`
+	}{dataKey}, &response); err != nil {
+		return nil, err
+	}
+	return response.Ciphertext, nil
+}
+
+func (k *gcpKey) Unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var response struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	if err := k.call(ctx, "decrypt", struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}{wrapped}, &response); err != nil {
+		return nil, err
+	}
+	return response.Plaintext, nil
+}
@@ -0,0 +1,167 @@
+// Package kms wraps and unwraps data keys with keys held in key management
+// services (AWS KMS, Google Cloud KMS and Vault's transit secrets engine), so
+// that the data keys can be stored alongside the data they encrypt.
+package kms
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// Key is a key in a key management service, which never leaves the service
+type Key interface {
+	// Wrap encrypts 'dataKey' with the key
+	Wrap(ctx context.Context, dataKey []byte) ([]byte, error)
+	// Unwrap decrypts a data key that was encrypted by Wrap
+	Unwrap(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// LocalKeyEnvPrefix prefixes the environment variables that hold local keys:
+// the key "local://<name>" is the base64-encoded 32-byte key in
+// LocalKeyEnvPrefix + <name, in upper case>. Local keys are meant for testing,
+// as they're only as safe as the environment that holds them.
+const LocalKeyEnvPrefix = "KMS_LOCAL_KEY_"
+
+var (
+	gcpKeyRe   = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+$`)
+	localKeyRe = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+// uri is a parsed key URI
+type uri struct {
+	scheme string
+	// name identifies the key within its service
+	name   string
+	region string
+}
+
+// parseURI parses a key URI, one of:
+//
+//	awskms://<key ID, ARN or alias>[?region=<region>]
+//	gcpkms://projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>
+//	vault://<transit mount>/<key>
+//	local://<name>
+//
+// (URIs aren't parsed with net/url, as ARNs aren't valid hosts)
+func parseURI(s string) (*uri, error) {
+	parts := strings.SplitN(s, "://", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("invalid key URI %q: must be <scheme>://<key>", s)
+	}
+	u := &uri{scheme: parts[0], name: parts[1]}
+	switch u.scheme {
+	case "awskms":
+		if i := strings.Index(u.name, "?"); i >= 0 {
+			query := u.name[i+1:]
+			u.name = u.name[:i]
+			if !strings.HasPrefix(query, "region=") || strings.Contains(query, "&") {
+				return nil, fmt.Errorf("invalid key URI %q: the only supported parameter is \"region\"", s)
+			}
+			u.region = strings.TrimPrefix(query, "region=")
+		}
+		if u.name == "" {
+			return nil, fmt.Errorf("invalid key URI %q: missing key ID", s)
+		}
+	case "gcpkms":
+		if !gcpKeyRe.MatchString(u.name) {
+			return nil, fmt.Errorf("invalid key URI %q: must be gcpkms://projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>", s)
+		}
+	case "vault":
+		if i := strings.LastIndex(u.name, "/"); i <= 0 || i == len(u.name)-1 {
+			return nil, fmt.Errorf("invalid key URI %q: must be vault://<transit mount>/<key>", s)
+		}
+	case "local":
+		if !localKeyRe.MatchString(u.name) {
+			return nil, fmt.Errorf("invalid key URI %q: local key names may only contain letters, digits and underscores", s)
+		}
+	default:
+		return nil, fmt.Errorf("invalid key URI %q: unrecognized scheme %q (must be awskms, gcpkms, vault or local)", s, u.scheme)
+	}
+	return u, nil
+}
+
+// Validate returns an error if 's' isn't a valid key URI. It doesn't check
+// that the key exists.
+func Validate(s string) error {
+	_, err := parseURI(s)
+	return err
+}
+
+// Open returns the key with the URI 's'. Credentials for the key's service
+// come from pachd's environment, as they do for each service's client
+// libraries (e.g. AWS_ACCESS_KEY_ID, GOOGLE_APPLICATION_CREDENTIALS, or
+// VAULT_ADDR and VAULT_TOKEN).
+func Open(s string) (Key, error) {
+	u, err := parseURI(s)
+	if err != nil {
+		return nil, err
+	}
+	switch u.scheme {
+	case "awskms":
+		return newAWSKey(u.name, u.region)
+	case "gcpkms":
+		return newGCPKey(u.name)
+	case "vault":
+		return newVaultKey(u.name)
+	default:
+		return newLocalKey(u.name)
+	}
+}
+
+// localKey is a key held in pachd's environment
+type localKey struct {
+	aead cipher.AEAD
+}
+
+func newLocalKey(name string) (Key, error) {
+	envVar := LocalKeyEnvPrefix + strings.ToUpper(name)
+	encoded := os.Getenv(envVar)
+	if encoded == "" {
+		return nil, fmt.Errorf("local key %q is not set (%s is empty)", name, envVar)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %s: %v", envVar, err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%s must be 32 bytes, but it's %d", envVar, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &localKey{aead: aead}, nil
+}
+
+func (k *localKey) Wrap(ctx context.Context, dataKey []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return k.aead.Seal(nonce, nonce, dataKey, nil), nil
+}
+
+func (k *localKey) Unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	nonceSize := k.aead.NonceSize()
+	if len(wrapped) < nonceSize {
+		return nil, fmt.Errorf("wrapped key is too short")
+	}
+	dataKey, err := k.aead.Open(nil, wrapped[:nonceSize], wrapped[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not unwrap key: %v", err)
+	}
+	return dataKey, nil
+}
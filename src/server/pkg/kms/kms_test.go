@@ -0,0 +1,68 @@
+package kms
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	"golang.org/x/net/context"
+)
+
+func TestParseURI(t *testing.T) {
+	for s, expected := range map[string]uri{
+		"awskms://alias/pachyderm":                                       {scheme: "awskms", name: "alias/pachyderm"},
+		"awskms://1234abcd-12ab-34cd-56ef-1234567890ab?region=eu-west-1": {scheme: "awskms", name: "1234abcd-12ab-34cd-56ef-1234567890ab", region: "eu-west-1"},
+		"awskms://arn:aws:kms:us-east-2:111122223333:key/1234abcd":       {scheme: "awskms", name: "arn:aws:kms:us-east-2:111122223333:key/1234abcd"},
+		"gcpkms://projects/p/locations/global/keyRings/r/cryptoKeys/k":   {scheme: "gcpkms", name: "projects/p/locations/global/keyRings/r/cryptoKeys/k"},
+		"vault://transit/pachyderm":                                      {scheme: "vault", name: "transit/pachyderm"},
+		"vault://team/transit/pachyderm":                                 {scheme: "vault", name: "team/transit/pachyderm"},
+		"local://test_key":                                               {scheme: "local", name: "test_key"},
+	} {
+		u, err := parseURI(s)
+		require.NoError(t, err)
+		require.Equal(t, expected, *u)
+	}
+	for _, s := range []string{
+		"",
+		"alias/pachyderm",
+		"awskms://",
+		"awskms://alias/pachyderm?endpoint=localhost",
+		"gcpkms://projects/p/cryptoKeys/k",
+		"vault://pachyderm",
+		"vault://transit/",
+		"local://test-key",
+		"s3://bucket/key",
+	} {
+		require.YesError(t, Validate(s), s)
+	}
+}
+
+func TestLocalKey(t *testing.T) {
+	_, err := Open("local://test")
+	require.YesError(t, err)
+	os.Setenv(LocalKeyEnvPrefix+"TEST", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+	defer os.Unsetenv(LocalKeyEnvPrefix + "TEST")
+	os.Setenv(LocalKeyEnvPrefix+"OTHER", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32)))
+	defer os.Unsetenv(LocalKeyEnvPrefix + "OTHER")
+
+	key, err := Open("local://test")
+	require.NoError(t, err)
+	dataKey := []byte("0123456789abcdef0123456789abcdef")
+	wrapped, err := key.Wrap(context.Background(), dataKey)
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(wrapped, dataKey))
+	unwrapped, err := key.Unwrap(context.Background(), wrapped)
+	require.NoError(t, err)
+	require.Equal(t, dataKey, unwrapped)
+
+	// Other keys can't unwrap it, and it can't be modified
+	other, err := Open("local://other")
+	require.NoError(t, err)
+	_, err = other.Unwrap(context.Background(), wrapped)
+	require.YesError(t, err)
+	wrapped[len(wrapped)-1] ^= 1
+	_, err = key.Unwrap(context.Background(), wrapped)
+	require.YesError(t, err)
+}
@@ -0,0 +1,66 @@
+package kms
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path"
+
+	vault "github.com/hashicorp/vault/api"
+	"golang.org/x/net/context"
+)
+
+// vaultKey is a key in a Vault transit secrets engine
+type vaultKey struct {
+	client *vault.Client
+	// mount is where the transit engine is mounted, and key is the key's name
+	mount, key string
+}
+
+func newVaultKey(name string) (Key, error) {
+	client, err := vault.NewClient(vault.DefaultConfig())
+	if err != nil {
+		return nil, fmt.Errorf("could not create vault client: %v", err)
+	}
+	return &vaultKey{
+		client: client,
+		mount:  path.Dir(name),
+		key:    path.Base(name),
+	}, nil
+}
+
+// write calls the transit engine's 'operation' ("encrypt" or "decrypt"), and
+// returns the string field 'field' of its response
+func (k *vaultKey) write(operation string, data map[string]interface{}, field string) (string, error) {
+	secret, err := k.client.Logical().Write(path.Join(k.mount, operation, k.key), data)
+	if err != nil {
+		return "", fmt.Errorf("error calling vault %s with key %s/%s: %v", operation, k.mount, k.key, err)
+	}
+	if secret == nil {
+		return "", fmt.Errorf("empty response from vault %s with key %s/%s", operation, k.mount, k.key)
+	}
+	value, ok := secret.Data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault %s with key %s/%s didn't return a %s", operation, k.mount, k.key, field)
+	}
+	return value, nil
+}
+
+func (k *vaultKey) Wrap(ctx context.Context, dataKey []byte) ([]byte, error) {
+	ciphertext, err := k.write("encrypt", map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(dataKey),
+	}, "ciphertext")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(ciphertext), nil
+}
+
+func (k *vaultKey) Unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	plaintext, err := k.write("decrypt", map[string]interface{}{
+		"ciphertext": string(wrapped),
+	}, "plaintext")
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(plaintext)
+}
@@ -0,0 +1,54 @@
+// Package pager selects the items on one page of a listing, for list RPCs
+// that page their results (e.g. ListCommit's page_size and page fields).
+package pager
+
+import (
+	"github.com/pachyderm/pachyderm/src/server/pkg/errutil"
+)
+
+// Pager tracks the position of a listing relative to the requested page
+type Pager struct {
+	start, end int64
+	all        bool
+	n          int64
+}
+
+// New returns a Pager for the (0-indexed) page 'page', containing 'pageSize'
+// items. If 'pageSize' is 0, all items are on a single page.
+func New(page, pageSize int64) *Pager {
+	if pageSize <= 0 {
+		return &Pager{all: true}
+	}
+	if page < 0 {
+		page = 0
+	}
+	return &Pager{start: page * pageSize, end: (page + 1) * pageSize}
+}
+
+// Next must be called for each item in the listing, in order. It returns true
+// if the item is on the page, and errutil.ErrBreak once the page is complete,
+// so that callbacks passed to collection.List() can return its error to stop
+// listing:
+//
+//	p := pager.New(request.Page, request.PageSize)
+//	commits.List(commitInfo, col.DefaultOptions, func(string) error {
+//		if ok, err := p.Next(); !ok {
+//			return err
+//		}
+//		...
+//	})
+func (p *Pager) Next() (bool, error) {
+	if p.all {
+		return true, nil
+	}
+	i := p.n
+	p.n++
+	switch {
+	case i < p.start:
+		return false, nil
+	case i >= p.end:
+		return false, errutil.ErrBreak
+	default:
+		return true, nil
+	}
+}
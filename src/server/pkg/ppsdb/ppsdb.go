@@ -11,10 +11,18 @@ import (
 )
 
 const (
-	pipelinesPrefix = "/pipelines"
-	jobsPrefix      = "/jobs"
+	pipelinesPrefix    = "/pipelines"
+	jobsPrefix         = "/jobs"
+	gcPrefix           = "/garbageCollection"
+	replicationsPrefix = "/replications"
+	schedulerPrefix    = "/scheduler"
+	admissionsPrefix   = "/jobAdmissions"
 )
 
+// SchedulerLimitsKey is the key of the scheduler's limits in the
+// SchedulerLimits collection
+const SchedulerLimitsKey = "limits"
+
 var (
 	// JobsPipelineIndex maps pipeline to jobs started by the pipeline
 	JobsPipelineIndex = &col.Index{Field: "Pipeline", Multi: false}
@@ -25,6 +33,11 @@ var (
 
 	// JobsOutputIndex maps job outputs to the job that create them.
 	JobsOutputIndex = &col.Index{Field: "OutputCommit", Multi: false}
+
+	// JobsStateIndex maps job states to the jobs in that state, so that
+	// unfinished jobs can be found without reading every job. Jobs that
+	// haven't been updated since the index was added aren't in it.
+	JobsStateIndex = &col.Index{Field: "State", Multi: false}
 )
 
 // Pipelines returns a Collection of pipelines
@@ -44,9 +57,60 @@ func Jobs(etcdClient *etcd.Client, etcdPrefix string) col.Collection {
 	return col.NewCollection(
 		etcdClient,
 		path.Join(etcdPrefix, jobsPrefix),
-		[]*col.Index{JobsPipelineIndex, JobsOutputIndex},
+		[]*col.Index{JobsPipelineIndex, JobsOutputIndex, JobsStateIndex},
 		&pps.EtcdJobInfo{},
 		nil,
 		nil,
 	)
 }
+
+// GarbageCollection returns a Collection holding the status of garbage
+// collection
+func GarbageCollection(etcdClient *etcd.Client, etcdPrefix string) col.Collection {
+	return col.NewCollection(
+		etcdClient,
+		path.Join(etcdPrefix, gcPrefix),
+		nil,
+		&pps.GarbageCollectStatus{},
+		nil,
+		nil,
+	)
+}
+
+// Replications returns a Collection of replications to other clusters
+func Replications(etcdClient *etcd.Client, etcdPrefix string) col.Collection {
+	return col.NewCollection(
+		etcdClient,
+		path.Join(etcdPrefix, replicationsPrefix),
+		nil,
+		&pps.ReplicationInfo{},
+		nil,
+		nil,
+	)
+}
+
+// SchedulerLimits returns a Collection holding the limits on the jobs that
+// run at once
+func SchedulerLimits(etcdClient *etcd.Client, etcdPrefix string) col.Collection {
+	return col.NewCollection(
+		etcdClient,
+		path.Join(etcdPrefix, schedulerPrefix),
+		nil,
+		&pps.SchedulerLimits{},
+		nil,
+		nil,
+	)
+}
+
+// JobAdmissions returns a Collection of the jobs that the scheduler has
+// allowed to run, keyed by job ID
+func JobAdmissions(etcdClient *etcd.Client, etcdPrefix string) col.Collection {
+	return col.NewCollection(
+		etcdClient,
+		path.Join(etcdPrefix, admissionsPrefix),
+		nil,
+		&pps.JobAdmission{},
+		nil,
+		nil,
+	)
+}
@@ -0,0 +1,186 @@
+package ppsutil
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	globlib "github.com/gobwas/glob"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+)
+
+// templateRefRe matches the references to capture groups in a join_on or
+// group_by key, in the syntax of regexp.Expand
+var templateRefRe = regexp.MustCompile(`\$(?:\$|\{([a-zA-Z0-9_]*)\}|([a-zA-Z0-9_]*))`)
+
+// CaptureGlob returns the pattern that the files of a PFS input with the glob
+// 'glob' (of type 'globType') should be globbed with, and a regular
+// expression that matches their paths and captures the glob's capture groups,
+// which join_on and group_by keys refer to. Regular expressions are their own
+// patterns. In shell globs, parentheses are capture groups (and '\(' and '\)'
+// match literal parentheses), so they're removed from the pattern.
+func CaptureGlob(glob string, globType pfs.PatternType) (string, *regexp.Regexp, error) {
+	if globType == pfs.PatternType_REGEX {
+		re, err := regexp.Compile("^(?:" + glob + ")$")
+		if err != nil {
+			return "", nil, fmt.Errorf("glob %q is not a valid regular expression: %v", glob, err)
+		}
+		return glob, re, nil
+	}
+	glob = "/" + strings.TrimPrefix(glob, "/")
+	var pattern, expr strings.Builder
+	var groups, braces int
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case c == '\\' && i+1 < len(glob):
+			pattern.WriteString(glob[i : i+2])
+			expr.WriteString(regexp.QuoteMeta(glob[i+1 : i+2]))
+			i++
+		case c == '(':
+			groups++
+			expr.WriteString("(")
+		case c == ')':
+			if groups == 0 {
+				return "", nil, fmt.Errorf("glob %q has an unmatched ')'", glob)
+			}
+			groups--
+			expr.WriteString(")")
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			pattern.WriteString("**")
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			pattern.WriteString("*")
+			expr.WriteString("[^/]*")
+		case c == '?':
+			pattern.WriteString("?")
+			expr.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", nil, fmt.Errorf("glob %q has an unmatched '['", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			pattern.WriteString(glob[i : i+2+end])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.NewReplacer(`\`, `\\`, "[", `\[`).Replace(class) + "]")
+			i += end + 1
+		case c == '{':
+			braces++
+			pattern.WriteString("{")
+			expr.WriteString("(?:")
+		case c == '}' && braces > 0:
+			braces--
+			pattern.WriteString("}")
+			expr.WriteString(")")
+		case c == ',' && braces > 0:
+			pattern.WriteString(",")
+			expr.WriteString("|")
+		default:
+			pattern.WriteString(glob[i : i+1])
+			expr.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	if groups > 0 {
+		return "", nil, fmt.Errorf("glob %q has an unmatched '('", glob)
+	}
+	if _, err := globlib.Compile(pattern.String(), '/'); err != nil {
+		return "", nil, fmt.Errorf("glob %q is not a valid glob pattern: %v", glob, err)
+	}
+	re, err := regexp.Compile("^" + expr.String() + "$")
+	if err != nil {
+		return "", nil, fmt.Errorf("glob %q is not a valid glob pattern: %v", glob, err)
+	}
+	return pattern.String(), re, nil
+}
+
+// DatumKey returns the join_on or group_by key 'template' of the file at
+// 'path', which 're' (from CaptureGlob) captures the groups of. It returns
+// false if 're' doesn't match 'path'.
+func DatumKey(re *regexp.Regexp, template string, path string) (string, bool) {
+	path = "/" + strings.TrimPrefix(path, "/")
+	match := re.FindStringSubmatchIndex(path)
+	if match == nil {
+		return "", false
+	}
+	return string(re.ExpandString(nil, template, path, match)), true
+}
+
+// ValidateKeyTemplate returns an error if the join_on or group_by key
+// 'template' doesn't refer to any of the capture groups of 're' (from
+// CaptureGlob), or refers to groups that it doesn't have
+func ValidateKeyTemplate(re *regexp.Regexp, template string) error {
+	names := make(map[string]bool)
+	for _, name := range re.SubexpNames()[1:] {
+		if name != "" {
+			names[name] = true
+		}
+	}
+	refs := 0
+	for _, match := range templateRefRe.FindAllStringSubmatch(template, -1) {
+		if match[0] == "$$" {
+			continue
+		}
+		name := match[1] + match[2]
+		if n, err := strconv.Atoi(name); err == nil {
+			if n < 1 || n > re.NumSubexp() {
+				return fmt.Errorf("%q refers to capture group %d, but the glob has %d", template, n, re.NumSubexp())
+			}
+		} else if !names[name] {
+			return fmt.Errorf("%q refers to capture group %q, which the glob doesn't have", template, name)
+		}
+		refs++
+	}
+	if refs == 0 {
+		return fmt.Errorf("%q doesn't refer to any of the glob's capture groups (e.g. \"$1\")", template)
+	}
+	return nil
+}
+
+// ValidateJoin returns an error if the inputs in a join input aren't PFS
+// inputs with valid join_on keys
+func ValidateJoin(join []*pps.Input) error {
+	for _, input := range join {
+		if input.Pfs == nil {
+			return fmt.Errorf("the inputs in a join input must be PFS inputs")
+		}
+		if err := validateKey(input.Pfs, "join_on", input.Pfs.JoinOn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateGroup returns an error if the inputs in a group input aren't PFS
+// inputs with valid group_by keys
+func ValidateGroup(group []*pps.Input) error {
+	for _, input := range group {
+		if input.Pfs == nil {
+			return fmt.Errorf("the inputs in a group input must be PFS inputs")
+		}
+		if err := validateKey(input.Pfs, "group_by", input.Pfs.GroupBy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateKey returns an error if 'key' (the field 'field' of 'input') isn't
+// a valid key for the input's files
+func validateKey(input *pps.PFSInput, field string, key string) error {
+	if key == "" {
+		return fmt.Errorf("input %s must set %s", input.Name, field)
+	}
+	_, re, err := CaptureGlob(input.Glob, input.GlobType)
+	if err != nil {
+		return fmt.Errorf("input %s: %v", input.Name, err)
+	}
+	if err := ValidateKeyTemplate(re, key); err != nil {
+		return fmt.Errorf("invalid %s for input %s: %v", field, input.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package ppsutil
+
+import (
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+)
+
+func TestCaptureGlob(t *testing.T) {
+	for _, c := range []struct {
+		glob, pattern, path, key string
+	}{
+		{"/(*).txt", "/*.txt", "/a.txt", "$1"},
+		{"(*)/*", "/*/*", "/dir/file", "$1"},
+		{"/(*)/(*)-*", "/*/*-*", "/a/b-c", "$2$1"},
+		{"/**/(?)", "/**/?", "/a/b/c", "$1"},
+		{"/([a-c])*", "/[a-c]*", "/bx", "$1"},
+		{"/([!a-c])*", "/[!a-c]*", "/dx", "$1"},
+		{"/({x,y})-*", "/{x,y}-*", "/y-1", "$1"},
+		{`/\((*)\)`, `/\(*\)`, "/(a)", "$1"},
+		{"/(a.b)*", "/a.b*", "/a.bc", "$1"},
+	} {
+		pattern, re, err := CaptureGlob(c.glob, pfs.PatternType_GLOB)
+		require.NoError(t, err, c.glob)
+		require.Equal(t, c.pattern, pattern)
+		require.NoError(t, ValidateKeyTemplate(re, c.key))
+		_, ok := DatumKey(re, c.key, c.path)
+		require.True(t, ok, c.glob)
+	}
+
+	_, re, err := CaptureGlob("/(*)/(*)-*", pfs.PatternType_GLOB)
+	require.NoError(t, err)
+	key, ok := DatumKey(re, "$2$1", "a/b-c")
+	require.True(t, ok)
+	require.Equal(t, "ba", key)
+	_, ok = DatumKey(re, "$1", "/a/b/c-d")
+	require.False(t, ok)
+	// '.' is literal in shell globs
+	_, re, err = CaptureGlob("/(a.b)", pfs.PatternType_GLOB)
+	require.NoError(t, err)
+	_, ok = DatumKey(re, "$1", "/axb")
+	require.False(t, ok)
+
+	// Regular expressions are their own patterns
+	pattern, re, err := CaptureGlob(`/(?P<id>[0-9]+)\.csv`, pfs.PatternType_REGEX)
+	require.NoError(t, err)
+	require.Equal(t, `/(?P<id>[0-9]+)\.csv`, pattern)
+	key, ok = DatumKey(re, "${id}", "/42.csv")
+	require.True(t, ok)
+	require.Equal(t, "42", key)
+
+	for _, glob := range []string{"/(*", "/*)", "/[a-"} {
+		_, _, err := CaptureGlob(glob, pfs.PatternType_GLOB)
+		require.YesError(t, err, glob)
+	}
+	_, _, err = CaptureGlob("/(", pfs.PatternType_REGEX)
+	require.YesError(t, err)
+}
+
+func TestValidateKeyTemplate(t *testing.T) {
+	_, re, err := CaptureGlob(`/(?P<id>[0-9]+)-([a-z]+)`, pfs.PatternType_REGEX)
+	require.NoError(t, err)
+	for _, key := range []string{"$1", "${2}", "$id", "${id}-$2", "$$-$1"} {
+		require.NoError(t, ValidateKeyTemplate(re, key), key)
+	}
+	for _, key := range []string{"", "id", "$$", "$0", "$3", "$name"} {
+		require.YesError(t, ValidateKeyTemplate(re, key), key)
+	}
+}
+
+func TestValidateJoin(t *testing.T) {
+	a := client.NewPFSInput("a", "/(*).a")
+	a.Pfs.JoinOn = "$1"
+	b := client.NewPFSInput("b", "/(*).b")
+	b.Pfs.JoinOn = "$1"
+	require.NoError(t, ValidateJoin([]*pps.Input{a, b}))
+
+	b.Pfs.JoinOn = ""
+	require.YesError(t, ValidateJoin([]*pps.Input{a, b}))
+	b.Pfs.JoinOn = "$2"
+	require.YesError(t, ValidateJoin([]*pps.Input{a, b}))
+	require.YesError(t, ValidateJoin([]*pps.Input{a, client.NewCrossInput(a, b)}))
+
+	// Group inputs use group_by, not join_on
+	require.YesError(t, ValidateGroup([]*pps.Input{a}))
+	a.Pfs.GroupBy = "$1"
+	require.NoError(t, ValidateGroup([]*pps.Input{a}))
+}
@@ -0,0 +1,130 @@
+package ppsutil
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// PatchPodSpec applies the pod patch 'patch' (a pipeline's PodPatch) to
+// 'podSpec'. A pod patch is a JSON object that's merged into the pod spec
+// like a JSON merge patch (RFC 7386): objects are merged recursively, null
+// removes a field, and other values replace the field's value. Unlike a JSON
+// merge patch, but like a Kubernetes strategic merge patch, lists of objects
+// with names (e.g. containers, volumes and env vars) are merged by name: an
+// object in the patch is merged into the object in the pod spec with the
+// same name (or appended to the list, if there isn't one), and an object
+// with the field "$patch": "delete" removes the object with its name. Other
+// lists are replaced.
+func PatchPodSpec(podSpec *v1.PodSpec, patch string) error {
+	var patchValue interface{}
+	if err := json.Unmarshal([]byte(patch), &patchValue); err != nil {
+		return fmt.Errorf("could not parse pod patch: %v", err)
+	}
+	if _, ok := patchValue.(map[string]interface{}); !ok {
+		return fmt.Errorf("pod patch must be a JSON object")
+	}
+	specJSON, err := json.Marshal(podSpec)
+	if err != nil {
+		return err
+	}
+	var specValue interface{}
+	if err := json.Unmarshal(specJSON, &specValue); err != nil {
+		return err
+	}
+	patchedJSON, err := json.Marshal(mergePatch(specValue, patchValue))
+	if err != nil {
+		return err
+	}
+	var patched v1.PodSpec
+	if err := json.Unmarshal(patchedJSON, &patched); err != nil {
+		return fmt.Errorf("pod patch does not produce a valid pod spec: %v", err)
+	}
+	*podSpec = patched
+	return nil
+}
+
+// mergePatch merges the JSON value 'patch' into 'original', as described by
+// PatchPodSpec, and returns the result
+func mergePatch(original, patch interface{}) interface{} {
+	switch patch := patch.(type) {
+	case map[string]interface{}:
+		originalMap, ok := original.(map[string]interface{})
+		if !ok {
+			originalMap = make(map[string]interface{})
+		}
+		for key, value := range patch {
+			if value == nil {
+				delete(originalMap, key)
+				continue
+			}
+			originalMap[key] = mergePatch(originalMap[key], value)
+		}
+		return originalMap
+	case []interface{}:
+		originalList, ok := original.([]interface{})
+		if !ok || !namedObjects(originalList) || !namedObjects(patch) {
+			return withoutDirectives(patch)
+		}
+		return mergeNamedObjects(originalList, patch)
+	default:
+		return patch
+	}
+}
+
+// namedObjects returns true if every element of 'list' is an object with a
+// string "name" field
+func namedObjects(list []interface{}) bool {
+	for _, element := range list {
+		object, ok := element.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if _, ok := object["name"].(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeNamedObjects merges the objects in 'patch' into the objects in
+// 'original' with the same name
+func mergeNamedObjects(original, patch []interface{}) []interface{} {
+	result := append([]interface{}{}, original...)
+	for _, element := range patch {
+		object := element.(map[string]interface{})
+		name := object["name"].(string)
+		i := 0
+		for ; i < len(result); i++ {
+			if result[i].(map[string]interface{})["name"] == name {
+				break
+			}
+		}
+		if object["$patch"] == "delete" {
+			if i < len(result) {
+				result = append(result[:i], result[i+1:]...)
+			}
+			continue
+		}
+		if i < len(result) {
+			result[i] = mergePatch(result[i], object)
+		} else {
+			result = append(result, mergePatch(nil, object))
+		}
+	}
+	return result
+}
+
+// withoutDirectives returns the list 'list' without the objects that are
+// "$patch" directives, which only have meaning when merging named objects
+func withoutDirectives(list []interface{}) []interface{} {
+	var result []interface{}
+	for _, element := range list {
+		if object, ok := element.(map[string]interface{}); ok && object["$patch"] != nil {
+			continue
+		}
+		result = append(result, element)
+	}
+	return result
+}
@@ -0,0 +1,49 @@
+package ppsutil
+
+import (
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestPatchPodSpec(t *testing.T) {
+	podSpec := &v1.PodSpec{
+		Containers: []v1.Container{{
+			Name:  "user",
+			Image: "ubuntu:18.04",
+			Env:   []v1.EnvVar{{Name: "A", Value: "1"}, {Name: "B", Value: "2"}},
+		}, {
+			Name:  "storage",
+			Image: "pachd",
+		}},
+		NodeSelector: map[string]string{"disk": "ssd"},
+		Tolerations:  []v1.Toleration{{Key: "dedicated", Value: "gpu"}},
+	}
+	require.NoError(t, PatchPodSpec(podSpec, `{
+		"containers": [
+			{"name": "user", "env": [{"name": "B", "value": "3"}, {"name": "A", "$patch": "delete"}],
+			 "securityContext": {"runAsNonRoot": true}},
+			{"name": "proxy", "image": "envoy"}
+		],
+		"affinity": {"nodeAffinity": {"requiredDuringSchedulingIgnoredDuringExecution": {"nodeSelectorTerms": [
+			{"matchExpressions": [{"key": "zone", "operator": "In", "values": ["us-east-1a"]}]}
+		]}}},
+		"nodeSelector": null,
+		"tolerations": [{"key": "spot", "operator": "Exists"}]
+	}`))
+	require.Equal(t, 3, len(podSpec.Containers))
+	user := podSpec.Containers[0]
+	require.Equal(t, "ubuntu:18.04", user.Image)
+	require.Equal(t, []v1.EnvVar{{Name: "B", Value: "3"}}, user.Env)
+	require.True(t, *user.SecurityContext.RunAsNonRoot)
+	require.Equal(t, "pachd", podSpec.Containers[1].Image)
+	require.Equal(t, "envoy", podSpec.Containers[2].Image)
+	require.Equal(t, "zone", podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions[0].Key)
+	require.Equal(t, 0, len(podSpec.NodeSelector))
+	require.Equal(t, []v1.Toleration{{Key: "spot", Operator: v1.TolerationOpExists}}, podSpec.Tolerations)
+
+	require.YesError(t, PatchPodSpec(podSpec, `[]`))
+	require.YesError(t, PatchPodSpec(podSpec, `{"containers":`))
+	require.YesError(t, PatchPodSpec(podSpec, `{"containers": "user"}`))
+}
@@ -0,0 +1,143 @@
+package ppsutil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/pachyderm/pachyderm/src/client/pps"
+)
+
+// MaxPipelinePriority is the largest magnitude of a pipeline's priority. It's
+// the largest priority that Kubernetes allows user-defined PriorityClasses to
+// have.
+const MaxPipelinePriority = 1000000000
+
+// PriorityClassName returns the name of the Kubernetes PriorityClass that
+// Pachyderm creates for the workers of pipelines with priority 'priority'
+func PriorityClassName(priority int64) string {
+	if priority < 0 {
+		return fmt.Sprintf("pachyderm-priority-minus-%d", -priority)
+	}
+	return fmt.Sprintf("pachyderm-priority-%d", priority)
+}
+
+// QueuedJob is a job that hasn't started running yet, the priority of its
+// pipeline, and the resources that its pipeline's workers request
+type QueuedJob struct {
+	JobPtr   *pps.EtcdJobInfo
+	Priority int64
+	CPU      float64
+	GPU      int64
+}
+
+// SortJobQueue sorts 'queue' in the order in which the jobs should run:
+// higher-priority jobs first, and jobs of the same priority in the order in
+// which they were created.
+func SortJobQueue(queue []*QueuedJob) {
+	sort.SliceStable(queue, func(i, j int) bool {
+		if queue[i].Priority != queue[j].Priority {
+			return queue[i].Priority > queue[j].Priority
+		}
+		iStarted, jStarted := queue[i].JobPtr.Started, queue[j].JobPtr.Started
+		if iStarted == nil || jStarted == nil {
+			return iStarted != nil
+		}
+		if iStarted.Seconds != jStarted.Seconds {
+			return iStarted.Seconds < jStarted.Seconds
+		}
+		return iStarted.Nanos < jStarted.Nanos
+	})
+}
+
+// WorkerResources returns the CPU and GPUs requested by a pipeline's 'workers'
+// workers. GPUs are counted from the pipeline's resource requests, or, as
+// Kubernetes only allows GPUs to be limited, its resource limits. They're
+// counted from the GPU spec, and from the extended resources named "*/gpu"
+// (e.g. "amd.com/gpu"). The resources of the pipeline's sidecars are
+// included.
+func WorkerResources(pipelineInfo *pps.PipelineInfo, workers int) (float64, int64) {
+	var cpu float64
+	var gpu int64
+	if requests := pipelineInfo.ResourceRequests; requests != nil {
+		cpu = float64(requests.Cpu)
+		gpu = numGPUs(requests)
+	}
+	if gpu == 0 && pipelineInfo.ResourceLimits != nil {
+		gpu = numGPUs(pipelineInfo.ResourceLimits)
+	}
+	for _, sidecar := range pipelineInfo.Sidecars {
+		var sidecarGPU int64
+		if requests := sidecar.ResourceRequests; requests != nil {
+			cpu += float64(requests.Cpu)
+			sidecarGPU = numGPUs(requests)
+		}
+		if sidecarGPU == 0 && sidecar.ResourceLimits != nil {
+			sidecarGPU = numGPUs(sidecar.ResourceLimits)
+		}
+		gpu += sidecarGPU
+	}
+	return cpu * float64(workers), gpu * int64(workers)
+}
+
+func numGPUs(resources *pps.ResourceSpec) int64 {
+	gpu := resources.Gpu.GetNumber()
+	for name, amount := range resources.ExtendedResources {
+		if !strings.HasSuffix(name, "/gpu") || (resources.Gpu != nil && name == resources.Gpu.Type) {
+			continue
+		}
+		if quantity, err := resource.ParseQuantity(amount); err == nil {
+			gpu += quantity.Value()
+		}
+	}
+	return gpu
+}
+
+// ScheduleJobs returns the jobs in 'queue' that may start running, given the
+// scheduler's 'limits' and the jobs that are already 'running'. Jobs are
+// admitted in the order given by SortJobQueue. A job that's held back by its
+// pipeline's limit doesn't hold back other pipelines' jobs, but a job that's
+// held back by a cluster-wide limit holds back the jobs after it, so that
+// large jobs aren't starved by smaller, later ones. A job is always admitted
+// if no job is running, even if it requests more than the limits allow.
+func ScheduleJobs(limits *pps.SchedulerLimits, running []*pps.JobAdmission, queue []*QueuedJob) []*QueuedJob {
+	var (
+		numRunning   int64
+		cpu          float64
+		gpu          int64
+		pipelineJobs = make(map[string]int64)
+	)
+	for _, admission := range running {
+		numRunning++
+		cpu += admission.Cpu
+		gpu += admission.Gpu
+		pipelineJobs[admission.Pipeline.Name]++
+	}
+	SortJobQueue(queue)
+	var admitted []*QueuedJob
+	for _, job := range queue {
+		pipeline := job.JobPtr.Pipeline.Name
+		if max, ok := limits.PipelineMaxRunningJobs[pipeline]; ok && max > 0 && pipelineJobs[pipeline] >= max {
+			continue
+		}
+		if numRunning > 0 {
+			if limits.MaxRunningJobs > 0 && numRunning >= limits.MaxRunningJobs {
+				break
+			}
+			if limits.MaxCpu > 0 && cpu+job.CPU > limits.MaxCpu {
+				break
+			}
+			if limits.MaxGpu > 0 && gpu+job.GPU > limits.MaxGpu {
+				break
+			}
+		}
+		admitted = append(admitted, job)
+		numRunning++
+		cpu += job.CPU
+		gpu += job.GPU
+		pipelineJobs[pipeline]++
+	}
+	return admitted
+}
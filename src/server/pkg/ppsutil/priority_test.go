@@ -0,0 +1,114 @@
+package ppsutil
+
+import (
+	"testing"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	ppsclient "github.com/pachyderm/pachyderm/src/client/pps"
+)
+
+func TestPriorityClassName(t *testing.T) {
+	require.Equal(t, "pachyderm-priority-100", PriorityClassName(100))
+	require.Equal(t, "pachyderm-priority-minus-5", PriorityClassName(-5))
+}
+
+func TestSortJobQueue(t *testing.T) {
+	queuedJob := func(id string, priority int64, started int64) *QueuedJob {
+		jobPtr := &ppsclient.EtcdJobInfo{Job: &ppsclient.Job{ID: id}}
+		if started > 0 {
+			jobPtr.Started = &types.Timestamp{Seconds: started}
+		}
+		return &QueuedJob{JobPtr: jobPtr, Priority: priority}
+	}
+	queue := []*QueuedJob{
+		queuedJob("backfill1", -10, 1),
+		queuedJob("default2", 0, 20),
+		queuedJob("urgent", 100, 30),
+		queuedJob("default1", 0, 10),
+		queuedJob("unstarted", 0, 0),
+		queuedJob("backfill2", -10, 2),
+	}
+	SortJobQueue(queue)
+	var order []string
+	for _, job := range queue {
+		order = append(order, job.JobPtr.Job.ID)
+	}
+	require.Equal(t, []string{"urgent", "default1", "default2", "unstarted", "backfill1", "backfill2"}, order)
+}
+
+func TestWorkerResources(t *testing.T) {
+	pipelineInfo := &ppsclient.PipelineInfo{
+		ResourceRequests: &ppsclient.ResourceSpec{Cpu: 0.5},
+		ResourceLimits:   &ppsclient.ResourceSpec{Gpu: &ppsclient.GPUSpec{Type: "nvidia.com/gpu", Number: 1}},
+	}
+	cpu, gpu := WorkerResources(pipelineInfo, 4)
+	require.Equal(t, 2.0, cpu)
+	require.Equal(t, int64(4), gpu)
+	cpu, gpu = WorkerResources(&ppsclient.PipelineInfo{}, 4)
+	require.Equal(t, 0.0, cpu)
+	require.Equal(t, int64(0), gpu)
+	pipelineInfo = &ppsclient.PipelineInfo{
+		ResourceLimits: &ppsclient.ResourceSpec{
+			ExtendedResources: map[string]string{"amd.com/gpu": "2", "xilinx.com/fpga": "1"},
+		},
+	}
+	_, gpu = WorkerResources(pipelineInfo, 3)
+	require.Equal(t, int64(6), gpu)
+	pipelineInfo.ResourceRequests = &ppsclient.ResourceSpec{Cpu: 1}
+	pipelineInfo.Sidecars = []*ppsclient.ContainerSpec{{
+		Name:             "proxy",
+		ResourceRequests: &ppsclient.ResourceSpec{Cpu: 0.5},
+	}}
+	cpu, gpu = WorkerResources(pipelineInfo, 2)
+	require.Equal(t, 3.0, cpu)
+	require.Equal(t, int64(4), gpu)
+}
+
+func TestScheduleJobs(t *testing.T) {
+	queuedJob := func(id, pipeline string, priority int64, cpu float64) *QueuedJob {
+		return &QueuedJob{
+			JobPtr: &ppsclient.EtcdJobInfo{
+				Job:      &ppsclient.Job{ID: id},
+				Pipeline: &ppsclient.Pipeline{Name: pipeline},
+				Started:  &types.Timestamp{Seconds: int64(len(id))},
+			},
+			Priority: priority,
+			CPU:      cpu,
+		}
+	}
+	admittedIDs := func(admitted []*QueuedJob) []string {
+		var ids []string
+		for _, job := range admitted {
+			ids = append(ids, job.JobPtr.Job.ID)
+		}
+		return ids
+	}
+	running := []*ppsclient.JobAdmission{
+		{Job: &ppsclient.Job{ID: "r"}, Pipeline: &ppsclient.Pipeline{Name: "a"}, Cpu: 1},
+	}
+
+	// No limits
+	queue := []*QueuedJob{queuedJob("a1", "a", 0, 1), queuedJob("b11", "b", 0, 1)}
+	require.Equal(t, []string{"a1", "b11"}, admittedIDs(ScheduleJobs(&ppsclient.SchedulerLimits{}, running, queue)))
+
+	// Running jobs
+	limits := &ppsclient.SchedulerLimits{MaxRunningJobs: 2}
+	queue = []*QueuedJob{queuedJob("a1", "a", 0, 1), queuedJob("b11", "b", 10, 1)}
+	require.Equal(t, []string{"b11"}, admittedIDs(ScheduleJobs(limits, running, queue)))
+
+	// A pipeline's limit doesn't hold back other pipelines
+	limits = &ppsclient.SchedulerLimits{PipelineMaxRunningJobs: map[string]int64{"a": 1}}
+	queue = []*QueuedJob{queuedJob("a1", "a", 0, 1), queuedJob("b11", "b", 0, 1)}
+	require.Equal(t, []string{"b11"}, admittedIDs(ScheduleJobs(limits, running, queue)))
+
+	// A cluster limit holds back the jobs after the first job it holds back
+	limits = &ppsclient.SchedulerLimits{MaxCpu: 4}
+	queue = []*QueuedJob{queuedJob("b1", "b", 0, 2), queuedJob("b11", "b", 0, 2), queuedJob("b111", "b", 0, 0.5)}
+	require.Equal(t, []string{"b1"}, admittedIDs(ScheduleJobs(limits, running, queue)))
+
+	// A job that requests more than the limits runs by itself
+	queue = []*QueuedJob{queuedJob("b1", "b", 0, 8), queuedJob("b11", "b", 0, 1)}
+	require.Equal(t, []string{"b1"}, admittedIDs(ScheduleJobs(limits, nil, queue)))
+	require.Equal(t, 0, len(ScheduleJobs(limits, running, queue)))
+}
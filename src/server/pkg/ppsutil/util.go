@@ -25,9 +25,12 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"regexp"
 	"strings"
+	"text/template"
 	"time"
 
+	"github.com/ghodss/yaml"
 	"github.com/gogo/protobuf/jsonpb"
 	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/protobuf/types"
@@ -35,6 +38,7 @@ import (
 	"github.com/pachyderm/pachyderm/src/client/pfs"
 	"github.com/pachyderm/pachyderm/src/client/pps"
 	ppsclient "github.com/pachyderm/pachyderm/src/client/pps"
+	"github.com/pachyderm/pachyderm/src/server/pkg/backoff"
 	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
 	"github.com/pachyderm/pachyderm/src/server/pkg/ppsconsts"
 
@@ -113,15 +117,114 @@ func getResourceListFromSpec(resources *pps.ResourceSpec, cacheSize string) (*v1
 		}
 	}
 
+	for name, amount := range resources.ExtendedResources {
+		quantity, err := resource.ParseQuantity(amount)
+		if err != nil {
+			log.Warnf("error parsing %s string: %s: %+v", name, amount, err)
+		} else {
+			result[v1.ResourceName(name)] = quantity
+		}
+	}
+
 	return &result, nil
 }
 
+// IsExtendedResourceName returns true if 'name' is the name of a Kubernetes
+// extended resource (a resource advertised by a device plugin or the cluster's
+// admin, such as "nvidia.com/gpu"), rather than a resource that Kubernetes
+// itself manages
+func IsExtendedResourceName(name string) bool {
+	i := strings.Index(name, "/")
+	if i <= 0 || i == len(name)-1 || strings.Count(name, "/") > 1 {
+		return false
+	}
+	domain := name[:i]
+	return domain != "kubernetes.io" && !strings.HasSuffix(domain, ".kubernetes.io") &&
+		!strings.HasPrefix(name, "requests.")
+}
+
+// ValidateExtendedResources returns an error if 'resources' requests
+// extended resources that Kubernetes wouldn't accept. 'field' names
+// 'resources' in the error.
+func ValidateExtendedResources(field string, resources *pps.ResourceSpec) error {
+	for name, amount := range resources.GetExtendedResources() {
+		if !IsExtendedResourceName(name) {
+			return fmt.Errorf("%s.ExtendedResources: %q isn't the name of an extended resource (it must have a domain prefix, such as \"nvidia.com/gpu\")", field, name)
+		}
+		quantity, err := resource.ParseQuantity(amount)
+		if err != nil {
+			return fmt.Errorf("%s.ExtendedResources: could not parse the amount of %s %q: %v", field, name, amount, err)
+		}
+		if quantity.Sign() < 0 {
+			return fmt.Errorf("%s.ExtendedResources: the amount of %s must not be negative", field, name)
+		}
+		if quantity.MilliValue()%1000 != 0 {
+			return fmt.Errorf("%s.ExtendedResources: the amount of %s must be a whole number", field, name)
+		}
+	}
+	return nil
+}
+
+// KubeTolerations converts a pipeline's tolerations to Kubernetes
+// tolerations
+func KubeTolerations(tolerations []*pps.Toleration) []v1.Toleration {
+	var result []v1.Toleration
+	for _, toleration := range tolerations {
+		kubeToleration := v1.Toleration{
+			Key:      toleration.Key,
+			Operator: v1.TolerationOperator(toleration.Operator),
+			Value:    toleration.Value,
+			Effect:   v1.TaintEffect(toleration.Effect),
+		}
+		if toleration.TolerationSeconds != nil {
+			seconds := toleration.TolerationSeconds.Value
+			kubeToleration.TolerationSeconds = &seconds
+		}
+		result = append(result, kubeToleration)
+	}
+	return result
+}
+
+// ValidateTolerations returns an error if any of 'tolerations' is invalid
+func ValidateTolerations(tolerations []*pps.Toleration) error {
+	for i, toleration := range tolerations {
+		switch v1.TolerationOperator(toleration.Operator) {
+		case "", v1.TolerationOpEqual:
+			if toleration.Key == "" {
+				return fmt.Errorf("SchedulingSpec.Tolerations[%d]: a toleration without a key must use the operator %q", i, v1.TolerationOpExists)
+			}
+		case v1.TolerationOpExists:
+			if toleration.Value != "" {
+				return fmt.Errorf("SchedulingSpec.Tolerations[%d]: a toleration with the operator %q can't have a value", i, v1.TolerationOpExists)
+			}
+		default:
+			return fmt.Errorf("SchedulingSpec.Tolerations[%d]: unknown operator %q (it must be %q or %q)", i, toleration.Operator, v1.TolerationOpEqual, v1.TolerationOpExists)
+		}
+		switch v1.TaintEffect(toleration.Effect) {
+		case "", v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+		default:
+			return fmt.Errorf("SchedulingSpec.Tolerations[%d]: unknown effect %q", i, toleration.Effect)
+		}
+		if toleration.TolerationSeconds != nil && v1.TaintEffect(toleration.Effect) != v1.TaintEffectNoExecute {
+			return fmt.Errorf("SchedulingSpec.Tolerations[%d]: TolerationSeconds can only be set for the effect %q", i, v1.TaintEffectNoExecute)
+		}
+	}
+	return nil
+}
+
 // GetLimitsResourceListFromPipeline returns a list of resources that the pipeline,
 // maximally is limited to.
 func GetLimitsResourceListFromPipeline(pipelineInfo *pps.PipelineInfo) (*v1.ResourceList, error) {
 	return getResourceListFromSpec(pipelineInfo.ResourceLimits, pipelineInfo.CacheSize)
 }
 
+// GetContainerResourceList returns the list of resources described by
+// 'resources', the resource requests or limits of one of a pipeline's extra
+// containers
+func GetContainerResourceList(resources *pps.ResourceSpec) (*v1.ResourceList, error) {
+	return getResourceListFromSpec(resources, "0")
+}
+
 // getNumNodes attempts to retrieve the number of nodes in the current k8s
 // cluster
 func getNumNodes(kubeClient *kube.Clientset) (int, error) {
@@ -136,10 +239,15 @@ func getNumNodes(kubeClient *kube.Clientset) (int, error) {
 }
 
 // GetExpectedNumWorkers computes the expected number of workers that
-// pachyderm will start given the ParallelismSpec 'spec'.
+// pachyderm will start given the ParallelismSpec 'spec'. For autoscaled
+// pipelines, it's the most workers that may be started.
 //
 // This is only exported for testing
 func GetExpectedNumWorkers(kubeClient *kube.Clientset, spec *ppsclient.ParallelismSpec) (int, error) {
+	if spec.GetAutoscaling() != nil {
+		_, max := autoscalingBounds(spec.Autoscaling)
+		return max, nil
+	}
 	if spec == nil || (spec.Constant == 0 && spec.Coefficient == 0) {
 		return 1, nil
 	} else if spec.Constant > 0 && spec.Coefficient == 0 {
@@ -156,6 +264,50 @@ func GetExpectedNumWorkers(kubeClient *kube.Clientset, spec *ppsclient.Paralleli
 	return 0, fmt.Errorf("Unable to interpret ParallelismSpec %+v", spec)
 }
 
+// DefaultAutoscalingTargetDuration is the default target_duration of
+// autoscaled pipelines
+const DefaultAutoscalingTargetDuration = 5 * time.Minute
+
+func autoscalingBounds(spec *ppsclient.AutoscalingSpec) (min int, max int) {
+	min, max = int(spec.MinWorkers), int(spec.MaxWorkers)
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	return min, max
+}
+
+// GetAutoscaledNumWorkers returns the number of workers that a pipeline
+// autoscaled by 'spec' should run, given that it has 'queued' datums to
+// process, which take 'datumDuration' each on average (zero if the pipeline
+// hasn't processed any datums, in which case a worker is run per datum).
+func GetAutoscaledNumWorkers(spec *ppsclient.AutoscalingSpec, queued int64, datumDuration time.Duration) int {
+	min, max := autoscalingBounds(spec)
+	if queued <= 0 {
+		return min
+	}
+	workers := float64(queued)
+	if datumDuration > 0 {
+		target := DefaultAutoscalingTargetDuration
+		if spec.TargetDuration != nil {
+			if d, err := types.DurationFromProto(spec.TargetDuration); err == nil && d > 0 {
+				target = d
+			}
+		}
+		// the number of workers that process the queued datums within 'target'
+		workers = math.Ceil(float64(queued) * datumDuration.Seconds() / target.Seconds())
+	}
+	if workers < float64(min) {
+		return min
+	}
+	if workers > float64(max) {
+		return max
+	}
+	return int(workers)
+}
+
 // GetExpectedNumHashtrees computes the expected number of hashtrees that
 // Pachyderm will create given the HashtreeSpec 'spec'.
 func GetExpectedNumHashtrees(spec *ppsclient.HashtreeSpec) (int64, error) {
@@ -235,34 +387,8 @@ func JobInput(pipelineInfo *pps.PipelineInfo, outputCommitInfo *pfs.CommitInfo)
 	return jobInput
 }
 
-// PipelineReqFromInfo converts a PipelineInfo into a CreatePipelineRequest.
-func PipelineReqFromInfo(pipelineInfo *ppsclient.PipelineInfo) *ppsclient.CreatePipelineRequest {
-	return &ppsclient.CreatePipelineRequest{
-		Pipeline:           pipelineInfo.Pipeline,
-		Transform:          pipelineInfo.Transform,
-		ParallelismSpec:    pipelineInfo.ParallelismSpec,
-		HashtreeSpec:       pipelineInfo.HashtreeSpec,
-		Egress:             pipelineInfo.Egress,
-		OutputBranch:       pipelineInfo.OutputBranch,
-		ScaleDownThreshold: pipelineInfo.ScaleDownThreshold,
-		ResourceRequests:   pipelineInfo.ResourceRequests,
-		ResourceLimits:     pipelineInfo.ResourceLimits,
-		Input:              pipelineInfo.Input,
-		Description:        pipelineInfo.Description,
-		CacheSize:          pipelineInfo.CacheSize,
-		EnableStats:        pipelineInfo.EnableStats,
-		Batch:              pipelineInfo.Batch,
-		MaxQueueSize:       pipelineInfo.MaxQueueSize,
-		Service:            pipelineInfo.Service,
-		ChunkSpec:          pipelineInfo.ChunkSpec,
-		DatumTimeout:       pipelineInfo.DatumTimeout,
-		JobTimeout:         pipelineInfo.JobTimeout,
-		Salt:               pipelineInfo.Salt,
-	}
-}
-
-// PipelineManifestReader helps with unmarshalling pipeline configs from JSON. It's used by
-// create-pipeline and update-pipeline
+// PipelineManifestReader helps with unmarshalling pipeline configs from JSON
+// or YAML. It's used by create-pipeline and update-pipeline
 type PipelineManifestReader struct {
 	buf     bytes.Buffer
 	decoder *json.Decoder
@@ -270,11 +396,23 @@ type PipelineManifestReader struct {
 
 // NewPipelineManifestReader creates a new manifest reader from a path.
 func NewPipelineManifestReader(path string) (result *PipelineManifestReader, retErr error) {
+	return NewPipelineManifestReaderWithArgs(path, nil)
+}
+
+// NewPipelineManifestReaderWithArgs creates a new manifest reader from a
+// path. If 'args' is non-empty, the manifest is a Go text/template, which is
+// rendered with 'args' (e.g. {{.env}}) before it's parsed, so that one
+// manifest can describe a pipeline in several environments. Referring to a
+// parameter that isn't in 'args' is an error.
+func NewPipelineManifestReaderWithArgs(path string, args map[string]string) (result *PipelineManifestReader, retErr error) {
 	result = &PipelineManifestReader{}
-	var pipelineReader io.Reader
+	var rawBytes []byte
 	if path == "-" {
-		pipelineReader = io.TeeReader(os.Stdin, &result.buf)
 		fmt.Print("Reading from stdin.\n")
+		var err error
+		if rawBytes, err = ioutil.ReadAll(os.Stdin); err != nil {
+			return nil, err
+		}
 	} else if url, err := url.Parse(path); err == nil && url.Scheme != "" {
 		resp, err := http.Get(url.String())
 		if err != nil {
@@ -285,23 +423,77 @@ func NewPipelineManifestReader(path string) (result *PipelineManifestReader, ret
 				retErr = err
 			}
 		}()
-		rawBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
+		if rawBytes, err = ioutil.ReadAll(resp.Body); err != nil {
 			return nil, err
 		}
-		pipelineReader = io.TeeReader(strings.NewReader(string(rawBytes)), &result.buf)
 	} else {
-		rawBytes, err := ioutil.ReadFile(path)
-		if err != nil {
+		if rawBytes, err = ioutil.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if len(args) > 0 {
+		var err error
+		if rawBytes, err = renderPipelineTemplate(rawBytes, args); err != nil {
 			return nil, err
 		}
-
-		pipelineReader = io.TeeReader(strings.NewReader(string(rawBytes)), &result.buf)
 	}
-	result.decoder = json.NewDecoder(pipelineReader)
+	if !isJSON(rawBytes) {
+		var err error
+		if rawBytes, err = yamlToJSON(rawBytes); err != nil {
+			return nil, err
+		}
+	}
+	result.buf.Write(rawBytes)
+	result.decoder = json.NewDecoder(bytes.NewReader(rawBytes))
 	return result, nil
 }
 
+// renderPipelineTemplate renders the pipeline manifest template 'manifest'
+// with the parameters 'args'
+func renderPipelineTemplate(manifest []byte, args map[string]string) ([]byte, error) {
+	tmpl, err := template.New("pipeline").Option("missingkey=error").Parse(string(manifest))
+	if err != nil {
+		return nil, fmt.Errorf("malformed pipeline spec template: %v", err)
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, args); err != nil {
+		return nil, fmt.Errorf("could not render pipeline spec template: %v", err)
+	}
+	return rendered.Bytes(), nil
+}
+
+// isJSON returns true if 'manifest' is a stream of JSON objects, rather than
+// YAML (which would never start with '{', as pipeline specs are objects)
+func isJSON(manifest []byte) bool {
+	trimmed := bytes.TrimSpace(manifest)
+	return len(trimmed) == 0 || trimmed[0] == '{'
+}
+
+var (
+	// yamlDocumentSeparator separates the documents in a YAML stream
+	yamlDocumentSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+	// yamlCommentRe matches whitespace and full-line comments in YAML
+	yamlCommentRe = regexp.MustCompile(`(?m)^[ \t]*#.*$|\s+`)
+)
+
+// yamlToJSON converts the YAML documents in 'manifest' (one pipeline spec
+// each) to a stream of JSON objects
+func yamlToJSON(manifest []byte) ([]byte, error) {
+	var result bytes.Buffer
+	for i, document := range yamlDocumentSeparator.Split(string(manifest), -1) {
+		if yamlCommentRe.ReplaceAllString(document, "") == "" {
+			continue // the document is empty, or only has comments
+		}
+		converted, err := yaml.YAMLToJSON([]byte(document))
+		if err != nil {
+			return nil, fmt.Errorf("malformed pipeline spec (YAML document %d): %v", i+1, err)
+		}
+		result.Write(converted)
+		result.WriteByte('\n')
+	}
+	return result.Bytes(), nil
+}
+
 // NextCreatePipelineRequest gets the next request from the manifest reader.
 func (r *PipelineManifestReader) NextCreatePipelineRequest() (*ppsclient.CreatePipelineRequest, error) {
 	var result ppsclient.CreatePipelineRequest
@@ -357,6 +549,31 @@ func IsTerminal(state pps.JobState) bool {
 	}
 }
 
+// DatumRetryBackOff returns the backoff between the tries of a datum that
+// fails under 'policy': none unless policy.RetryBackoff is set, in which case
+// it doubles after each try, up to policy.MaxRetryBackoff (if set).
+func DatumRetryBackOff(policy *pps.DatumFailurePolicy) backoff.BackOff {
+	if policy.GetRetryBackoff() == nil {
+		return &backoff.ZeroBackOff{}
+	}
+	initial, err := types.DurationFromProto(policy.RetryBackoff)
+	if err != nil || initial <= 0 {
+		return &backoff.ZeroBackOff{}
+	}
+	b := backoff.NewInfiniteBackOff()
+	b.InitialInterval = initial
+	b.RandomizationFactor = 0
+	b.Multiplier = 2
+	b.MaxInterval = time.Duration(math.MaxInt64)
+	if policy.MaxRetryBackoff != nil {
+		if max, err := types.DurationFromProto(policy.MaxRetryBackoff); err == nil && max >= initial {
+			b.MaxInterval = max
+		}
+	}
+	b.Reset()
+	return b
+}
+
 // UpdateJobState performs the operations involved with a job state transition.
 func UpdateJobState(pipelines col.ReadWriteCollection, jobs col.ReadWriteCollection, jobPtr *pps.EtcdJobInfo, state pps.JobState, reason string) error {
 	// Update pipeline
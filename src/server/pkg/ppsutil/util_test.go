@@ -0,0 +1,183 @@
+package ppsutil
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	ppsclient "github.com/pachyderm/pachyderm/src/client/pps"
+)
+
+func readPipelines(t *testing.T, manifest string, args map[string]string) ([]*ppsclient.CreatePipelineRequest, error) {
+	f, err := ioutil.TempFile("", "pipeline")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(manifest)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	r, err := NewPipelineManifestReaderWithArgs(f.Name(), args)
+	if err != nil {
+		return nil, err
+	}
+	var requests []*ppsclient.CreatePipelineRequest
+	for {
+		request, err := r.NextCreatePipelineRequest()
+		if err == io.EOF {
+			return requests, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+}
+
+func TestPipelineManifestReader(t *testing.T) {
+	// JSON
+	requests, err := readPipelines(t, `{"pipeline": {"name": "a"}, "parallelism_spec": {"constant": 2}}
+{"pipeline": {"name": "b"}}`, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(requests))
+	require.Equal(t, "a", requests[0].Pipeline.Name)
+	require.Equal(t, uint64(2), requests[0].ParallelismSpec.Constant)
+	require.Equal(t, "b", requests[1].Pipeline.Name)
+
+	// YAML, with several documents
+	requests, err = readPipelines(t, `# pipelines
+---
+pipeline:
+  name: a
+transform:
+  cmd: [sh]
+  stdin:
+  - echo {{not a template}}
+---
+pipeline:
+  name: b
+`, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(requests))
+	require.Equal(t, "a", requests[0].Pipeline.Name)
+	require.Equal(t, []string{"echo {{not a template}}"}, requests[0].Transform.Stdin)
+	require.Equal(t, "b", requests[1].Pipeline.Name)
+
+	// templates
+	manifest := `pipeline:
+  name: edges-{{.env}}
+transform:
+  image: opencv:{{.version}}
+`
+	requests, err = readPipelines(t, manifest, map[string]string{"env": "prod", "version": "1.2"})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(requests))
+	require.Equal(t, "edges-prod", requests[0].Pipeline.Name)
+	require.Equal(t, "opencv:1.2", requests[0].Transform.Image)
+	_, err = readPipelines(t, manifest, map[string]string{"env": "prod"})
+	require.YesError(t, err)
+
+	_, err = readPipelines(t, "pipeline: [", nil)
+	require.YesError(t, err)
+	// unknown fields
+	_, err = readPipelines(t, "pipeline:\n  name: a\nparalelism_spec:\n  constant: 2\n", nil)
+	require.YesError(t, err)
+}
+
+func TestGetAutoscaledNumWorkers(t *testing.T) {
+	spec := &ppsclient.AutoscalingSpec{
+		MinWorkers:     2,
+		MaxWorkers:     10,
+		TargetDuration: types.DurationProto(time.Minute),
+	}
+	// Nothing queued
+	require.Equal(t, 2, GetAutoscaledNumWorkers(spec, 0, time.Second))
+	// Unknown datum duration: a worker per datum, up to the max
+	require.Equal(t, 5, GetAutoscaledNumWorkers(spec, 5, 0))
+	require.Equal(t, 10, GetAutoscaledNumWorkers(spec, 50, 0))
+	// 30 datums * 10s / 1m = 5 workers
+	require.Equal(t, 5, GetAutoscaledNumWorkers(spec, 30, 10*time.Second))
+	// 31 datums need a 6th worker to finish within the target
+	require.Equal(t, 6, GetAutoscaledNumWorkers(spec, 31, 10*time.Second))
+	require.Equal(t, 2, GetAutoscaledNumWorkers(spec, 1, time.Second))
+	require.Equal(t, 10, GetAutoscaledNumWorkers(spec, 1000, time.Minute))
+
+	// Defaults: at least one worker, and a target of 5 minutes
+	spec = &ppsclient.AutoscalingSpec{MaxWorkers: 4}
+	require.Equal(t, 1, GetAutoscaledNumWorkers(spec, 0, 0))
+	require.Equal(t, 2, GetAutoscaledNumWorkers(spec, 20, 30*time.Second))
+}
+
+func TestDatumRetryBackOff(t *testing.T) {
+	// No backoff by default
+	b := DatumRetryBackOff(nil)
+	require.Equal(t, time.Duration(0), b.NextBackOff())
+	b = DatumRetryBackOff(&ppsclient.DatumFailurePolicy{Action: ppsclient.DatumFailureAction_DATUM_SKIP})
+	require.Equal(t, time.Duration(0), b.NextBackOff())
+
+	// The backoff doubles, up to the max
+	b = DatumRetryBackOff(&ppsclient.DatumFailurePolicy{
+		RetryBackoff:    types.DurationProto(time.Second),
+		MaxRetryBackoff: types.DurationProto(5 * time.Second),
+	})
+	var backoffs []time.Duration
+	for i := 0; i < 5; i++ {
+		backoffs = append(backoffs, b.NextBackOff())
+	}
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, backoffs)
+}
+
+func TestExtendedResources(t *testing.T) {
+	require.True(t, IsExtendedResourceName("nvidia.com/gpu"))
+	require.True(t, IsExtendedResourceName("nvidia.com/mig-1g.5gb"))
+	require.False(t, IsExtendedResourceName("cpu"))
+	require.False(t, IsExtendedResourceName("kubernetes.io/foo"))
+	require.False(t, IsExtendedResourceName("node.kubernetes.io/foo"))
+	require.False(t, IsExtendedResourceName("nvidia.com/"))
+
+	resources := &ppsclient.ResourceSpec{
+		Memory:            "1G",
+		ExtendedResources: map[string]string{"nvidia.com/mig-1g.5gb": "2", "xilinx.com/fpga": "1"},
+	}
+	require.NoError(t, ValidateExtendedResources("ResourceLimits", resources))
+	list, err := getResourceListFromSpec(resources, "0")
+	require.NoError(t, err)
+	mig := (*list)["nvidia.com/mig-1g.5gb"]
+	require.Equal(t, int64(2), mig.Value())
+
+	require.YesError(t, ValidateExtendedResources("ResourceLimits", &ppsclient.ResourceSpec{
+		ExtendedResources: map[string]string{"gpu": "1"},
+	}))
+	require.YesError(t, ValidateExtendedResources("ResourceLimits", &ppsclient.ResourceSpec{
+		ExtendedResources: map[string]string{"nvidia.com/gpu": "0.5"},
+	}))
+	require.YesError(t, ValidateExtendedResources("ResourceLimits", &ppsclient.ResourceSpec{
+		ExtendedResources: map[string]string{"nvidia.com/gpu": "lots"},
+	}))
+	require.NoError(t, ValidateExtendedResources("ResourceLimits", nil))
+}
+
+func TestTolerations(t *testing.T) {
+	tolerations := []*ppsclient.Toleration{
+		{Key: "dedicated", Value: "gpu", Effect: "NoSchedule"},
+		{Key: "spot", Operator: "Exists", Effect: "NoExecute", TolerationSeconds: &types.Int64Value{Value: 60}},
+	}
+	require.NoError(t, ValidateTolerations(tolerations))
+	kubeTolerations := KubeTolerations(tolerations)
+	require.Equal(t, 2, len(kubeTolerations))
+	require.Equal(t, "gpu", kubeTolerations[0].Value)
+	require.Nil(t, kubeTolerations[0].TolerationSeconds)
+	require.Equal(t, int64(60), *kubeTolerations[1].TolerationSeconds)
+
+	for _, invalid := range []*ppsclient.Toleration{
+		{Value: "gpu"},
+		{Key: "dedicated", Operator: "Exists", Value: "gpu"},
+		{Key: "dedicated", Operator: "Matches"},
+		{Key: "dedicated", Effect: "NoRun"},
+		{Key: "dedicated", Effect: "NoSchedule", TolerationSeconds: &types.Int64Value{Value: 60}},
+	} {
+		require.YesError(t, ValidateTolerations([]*ppsclient.Toleration{invalid}))
+	}
+}
@@ -0,0 +1,123 @@
+// Package textdiff computes line-based diffs of text, in the unified format
+// used by diff -u and git.
+package textdiff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Context is the number of unchanged lines shown around each change
+const Context = 3
+
+// line is one line of a diff: an unchanged (' '), removed ('-') or added
+// ('+') line of text, including its newline (if it has one)
+type line struct {
+	op   byte
+	text string
+}
+
+// IsText returns true if 'data' looks like text (valid UTF-8 without NUL
+// bytes), rather than binary data that shouldn't be diffed line by line
+func IsText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) == -1
+}
+
+// Unified returns the unified diff that turns 'oldText' (named 'oldName') into
+// 'newText' (named 'newName'), or "" if they're the same.
+func Unified(oldName, newName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+	lines := diffLines(oldText, newText)
+	// oldLine[i] and newLine[i] are the number of old and new lines before
+	// lines[i]
+	oldLine, newLine := make([]int, len(lines)+1), make([]int, len(lines)+1)
+	for i, l := range lines {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if l.op != '+' {
+			oldLine[i+1]++
+		}
+		if l.op != '-' {
+			newLine[i+1]++
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", oldName, newName)
+	for i := 0; i < len(lines); {
+		for i < len(lines) && lines[i].op == ' ' {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+		// a hunk holds changes that are separated by at most 2*Context
+		// unchanged lines, and Context unchanged lines on either side
+		start, last := i-Context, i
+		if start < 0 {
+			start = 0
+		}
+		for j := i; j < len(lines) && j-last <= 2*Context; j++ {
+			if lines[j].op != ' ' {
+				last = j
+			}
+		}
+		end := last + Context + 1
+		if end > len(lines) {
+			end = len(lines)
+		}
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n",
+			hunkRange(oldLine[start], oldLine[end]), hunkRange(newLine[start], newLine[end]))
+		for _, l := range lines[start:end] {
+			buf.WriteByte(l.op)
+			buf.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				buf.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return buf.String()
+}
+
+// hunkRange formats the lines in [start, end) (0-indexed) as a hunk range
+func hunkRange(start, end int) string {
+	if end-start == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if end-start == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, end-start)
+}
+
+// diffLines returns the lines of the diff between 'oldText' and 'newText'
+func diffLines(oldText, newText string) []line {
+	dmp := diffmatchpatch.New()
+	oldChars, newChars, lineArray := dmp.DiffLinesToChars(oldText, newText)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(oldChars, newChars, false), lineArray)
+	var lines []line
+	for _, d := range diffs {
+		op := byte(' ')
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			op = '-'
+		case diffmatchpatch.DiffInsert:
+			op = '+'
+		}
+		for text := d.Text; text != ""; {
+			n := strings.IndexByte(text, '\n') + 1
+			if n == 0 {
+				n = len(text)
+			}
+			lines = append(lines, line{op: op, text: text[:n]})
+			text = text[n:]
+		}
+	}
+	return lines
+}
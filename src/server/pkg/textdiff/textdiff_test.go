@@ -0,0 +1,61 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+)
+
+func TestUnified(t *testing.T) {
+	require.Equal(t, "", Unified("a/f", "b/f", "same\n", "same\n"))
+
+	require.Equal(t, `--- a/f
++++ b/f
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+`, Unified("a/f", "b/f", "one\ntwo\nthree\n", "one\nTWO\nthree\n"))
+
+	// distant changes are in separate hunks
+	var oldLines, newLines []string
+	for i := 0; i < 20; i++ {
+		oldLines = append(oldLines, string(rune('a'+i)))
+		newLines = append(newLines, string(rune('a'+i)))
+	}
+	newLines[1], newLines[18] = "B", "S"
+	require.Equal(t, `--- old
++++ new
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -16,5 +16,5 @@
+ p
+ q
+ r
+-s
++S
+ t
+`, Unified("old", "new", strings.Join(oldLines, "\n")+"\n", strings.Join(newLines, "\n")+"\n"))
+
+	// added files and missing newlines
+	require.Equal(t, `--- /dev/null
++++ b/f
+@@ -0,0 +1,2 @@
++one
++two
+\ No newline at end of file
+`, Unified("/dev/null", "b/f", "", "one\ntwo"))
+}
+
+func TestIsText(t *testing.T) {
+	require.True(t, IsText([]byte("hello\n")))
+	require.False(t, IsText([]byte{'a', 0, 'b'}))
+	require.False(t, IsText([]byte{0xff, 0xfe}))
+}
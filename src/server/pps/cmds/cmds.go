@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/user"
 	"path"
+	"strconv"
 	"strings"
 
 	units "github.com/docker/go-units"
@@ -435,12 +436,41 @@ All jobs created by a pipeline will create commits in the pipeline's repo.
 	var username string
 	var password string
 	var pipelinePath string
+	var templateArgs []string
+	// pipelineManifestReader reads the pipeline specs in pipelinePath,
+	// rendering them with templateArgs if any are given
+	pipelineManifestReader := func() (*ppsutil.PipelineManifestReader, error) {
+		args := make(map[string]string)
+		for _, arg := range templateArgs {
+			parts := strings.SplitN(arg, "=", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return nil, fmt.Errorf("invalid --arg %q: must be key=value", arg)
+			}
+			args[parts[0]] = parts[1]
+		}
+		return ppsutil.NewPipelineManifestReaderWithArgs(pipelinePath, args)
+	}
+	pipelineTemplateDoc := `
+Pipeline specs may be written in JSON or YAML (several specs are separated by
+"---"). If --arg is given, the spec is a Go template, which is rendered with the
+args before it's parsed, e.g. the spec:
+
+  pipeline:
+    name: edges-{{.env}}
+  transform:
+    image: pachyderm/opencv:{{.version}}
+  ...
+
+is rendered for staging by:
+
+  $ pachctl create-pipeline -f edges.yaml --arg env=staging --arg version=1.2.0
+`
 	createPipeline := &cobra.Command{
 		Use:   "create-pipeline -f pipeline.json",
 		Short: "Create a new pipeline.",
-		Long:  fmt.Sprintf("Create a new pipeline from a %s", pipelineSpec),
+		Long:  fmt.Sprintf("Create a new pipeline from a %s", pipelineSpec) + pipelineTemplateDoc,
 		Run: cmdutil.RunFixedArgs(0, func(args []string) (retErr error) {
-			cfgReader, err := ppsutil.NewPipelineManifestReader(pipelinePath)
+			cfgReader, err := pipelineManifestReader()
 			if err != nil {
 				return err
 			}
@@ -477,6 +507,7 @@ All jobs created by a pipeline will create commits in the pipeline's repo.
 		}),
 	}
 	createPipeline.Flags().StringVarP(&pipelinePath, "file", "f", "-", "The file containing the pipeline, it can be a url or local file. - reads from stdin.")
+	createPipeline.Flags().StringArrayVar(&templateArgs, "arg", nil, "A key=value parameter with which the pipeline spec is rendered as a template (may be repeated).")
 	createPipeline.Flags().BoolVarP(&pushImages, "push-images", "p", false, "If true, push local docker images into the cluster registry.")
 	createPipeline.Flags().StringVarP(&registry, "registry", "r", "docker.io", "The registry to push images to.")
 	createPipeline.Flags().StringVarP(&username, "username", "u", "", "The username to push images as, defaults to your OS username.")
@@ -486,9 +517,9 @@ All jobs created by a pipeline will create commits in the pipeline's repo.
 	updatePipeline := &cobra.Command{
 		Use:   "update-pipeline -f pipeline.json",
 		Short: "Update an existing Pachyderm pipeline.",
-		Long:  fmt.Sprintf("Update a Pachyderm pipeline with a new %s", pipelineSpec),
+		Long:  fmt.Sprintf("Update a Pachyderm pipeline with a new %s", pipelineSpec) + pipelineTemplateDoc,
 		Run: cmdutil.RunFixedArgs(0, func(args []string) (retErr error) {
-			cfgReader, err := ppsutil.NewPipelineManifestReader(pipelinePath)
+			cfgReader, err := pipelineManifestReader()
 			if err != nil {
 				return err
 			}
@@ -527,12 +558,159 @@ All jobs created by a pipeline will create commits in the pipeline's repo.
 		}),
 	}
 	updatePipeline.Flags().StringVarP(&pipelinePath, "file", "f", "-", "The file containing the pipeline, it can be a url or local file. - reads from stdin.")
+	updatePipeline.Flags().StringArrayVar(&templateArgs, "arg", nil, "A key=value parameter with which the pipeline spec is rendered as a template (may be repeated).")
 	updatePipeline.Flags().BoolVarP(&pushImages, "push-images", "p", false, "If true, push local docker images into the cluster registry.")
 	updatePipeline.Flags().StringVarP(&registry, "registry", "r", "docker.io", "The registry to push images to.")
 	updatePipeline.Flags().StringVarP(&username, "username", "u", "", "The username to push images as, defaults to your OS username.")
 	updatePipeline.Flags().StringVarP(&password, "password", "", "", "Your password for the registry being pushed to.")
 	updatePipeline.Flags().BoolVar(&reprocess, "reprocess", false, "If true, reprocess datums that were already processed by previous version of the pipeline.")
 
+	var prune bool
+	var showUnchanged bool
+	applyPipelines := &cobra.Command{
+		Use:   "apply-pipelines -f pipelines.json",
+		Short: "Make the cluster's pipelines match a set of pipeline specs.",
+		Long: `Make the cluster's pipelines match a set of pipeline specs.
+
+Creates the pipelines in the specs that don't exist, and updates the ones whose
+specs have changed. If --prune is given, the pipelines that aren't in the specs
+are deleted. Pipelines are changed in dependency order (deleted pipelines are
+deleted last), and if a change fails, the changes already made are undone.
+` + pipelineTemplateDoc,
+		Run: cmdutil.RunFixedArgs(0, func(args []string) error {
+			cfgReader, err := pipelineManifestReader()
+			if err != nil {
+				return err
+			}
+			var specs []*ppsclient.CreatePipelineRequest
+			for {
+				request, err := cfgReader.NextCreatePipelineRequest()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					return err
+				}
+				specs = append(specs, request)
+			}
+			client, err := pachdclient.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return fmt.Errorf("error connecting to pachd: %v", err)
+			}
+			defer client.Close()
+			changes, err := client.ApplyPipelines(specs, prune, reprocess)
+			if err != nil {
+				return err
+			}
+			writer := tabwriter.NewWriter(os.Stdout, pretty.PipelineChangeHeader)
+			for _, change := range changes {
+				if change.Type == ppsclient.PipelineChangeType_PIPELINE_UNCHANGED && !showUnchanged {
+					continue
+				}
+				if raw {
+					if err := marshaller.Marshal(os.Stdout, change); err != nil {
+						return err
+					}
+					continue
+				}
+				pretty.PrintPipelineChange(writer, change)
+			}
+			if raw {
+				return nil
+			}
+			return writer.Flush()
+		}),
+	}
+	applyPipelines.Flags().StringVarP(&pipelinePath, "file", "f", "-", "The file containing the pipelines, it can be a url or local file. - reads from stdin.")
+	applyPipelines.Flags().StringArrayVar(&templateArgs, "arg", nil, "A key=value parameter with which the pipeline specs are rendered as a template (may be repeated).")
+	applyPipelines.Flags().BoolVar(&prune, "prune", false, "If true, delete the pipelines that aren't in the specs.")
+	applyPipelines.Flags().BoolVar(&reprocess, "reprocess", false, "If true, updated pipelines reprocess datums that were already processed by their previous versions.")
+	applyPipelines.Flags().BoolVar(&showUnchanged, "show-unchanged", false, "If true, also list the pipelines that already match their specs.")
+	rawFlag(applyPipelines)
+
+	var offline bool
+	validatePipeline := &cobra.Command{
+		Use:   "validate-pipeline -f pipeline.json",
+		Short: "Check pipeline specs without creating the pipelines.",
+		Long: `Check pipeline specs without creating the pipelines.
+
+Reports the errors that would stop the pipelines from being created or from
+running (e.g. unknown fields, invalid globs, input repos that don't exist,
+resource requests that no node can satisfy, or missing image pull secrets), and
+warnings about specs that may not behave as intended (e.g. images without a
+pinned tag). Exits with an error if any errors are found.
+
+If --offline is given, the specs are only checked for problems that can be found
+without a cluster.
+` + pipelineTemplateDoc,
+		Run: cmdutil.RunFixedArgs(0, func(args []string) error {
+			cfgReader, err := pipelineManifestReader()
+			if err != nil {
+				return err
+			}
+			var client *pachdclient.APIClient
+			if !offline {
+				client, err = pachdclient.NewOnUserMachine(metrics, true, "user")
+				if err != nil {
+					return fmt.Errorf("error connecting to pachd: %v", err)
+				}
+				defer client.Close()
+			}
+			writer := tabwriter.NewWriter(os.Stdout, pretty.PipelineIssueHeader)
+			var errors int
+			report := func(pipeline string, issues []*ppsclient.PipelineIssue) error {
+				for _, issue := range issues {
+					if issue.Severity == ppsclient.IssueSeverity_ISSUE_ERROR {
+						errors++
+					}
+					if raw {
+						if err := marshaller.Marshal(os.Stdout, issue); err != nil {
+							return err
+						}
+						continue
+					}
+					pretty.PrintPipelineIssue(writer, pipeline, issue)
+				}
+				return nil
+			}
+			for {
+				request, err := cfgReader.NextCreatePipelineRequest()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					// the specs after a malformed one can't be read
+					if err := report("-", []*ppsclient.PipelineIssue{
+						ppsclient.NewPipelineIssue(ppsclient.IssueSeverity_ISSUE_ERROR, "", "%v", err),
+					}); err != nil {
+						return err
+					}
+					break
+				}
+				var issues []*ppsclient.PipelineIssue
+				if offline {
+					issues = ppsclient.LintPipeline(request)
+				} else if issues, err = client.ValidatePipeline(request); err != nil {
+					return err
+				}
+				if err := report(request.GetPipeline().GetName(), issues); err != nil {
+					return err
+				}
+			}
+			if !raw {
+				if err := writer.Flush(); err != nil {
+					return err
+				}
+			}
+			if errors > 0 {
+				return fmt.Errorf("found %d error(s) in the pipeline specs", errors)
+			}
+			return nil
+		}),
+	}
+	validatePipeline.Flags().StringVarP(&pipelinePath, "file", "f", "-", "The file containing the pipeline, it can be a url or local file. - reads from stdin.")
+	validatePipeline.Flags().StringArrayVar(&templateArgs, "arg", nil, "A key=value parameter with which the pipeline spec is rendered as a template (may be repeated).")
+	validatePipeline.Flags().BoolVar(&offline, "offline", false, "Only check for problems that can be found without connecting to pachd.")
+	rawFlag(validatePipeline)
+
 	inspectPipeline := &cobra.Command{
 		Use:   "inspect-pipeline pipeline-name",
 		Short: "Return info about a pipeline.",
@@ -558,6 +736,66 @@ All jobs created by a pipeline will create commits in the pipeline's repo.
 	}
 	rawFlag(inspectPipeline)
 
+	listPipelineVersions := &cobra.Command{
+		Use:   "list-pipeline-versions pipeline-name",
+		Short: "Return every version of a pipeline's spec.",
+		Long:  "Return every version of a pipeline's spec, newest first. Use rollback-pipeline to restore one of them.",
+		Run: cmdutil.RunFixedArgs(1, func(args []string) error {
+			client, err := pachdclient.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			pipelineInfos, err := client.ListPipelineVersions(args[0])
+			if err != nil {
+				return err
+			}
+			if raw {
+				for _, pipelineInfo := range pipelineInfos {
+					if err := marshaller.Marshal(os.Stdout, pipelineInfo); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			writer := tabwriter.NewWriter(os.Stdout, pretty.PipelineVersionHeader)
+			for _, pipelineInfo := range pipelineInfos {
+				pretty.PrintPipelineVersion(writer, pipelineInfo)
+			}
+			return writer.Flush()
+		}),
+	}
+	rawFlag(listPipelineVersions)
+
+	var rollbackReprocess bool
+	rollbackPipeline := &cobra.Command{
+		Use:   "rollback-pipeline pipeline-name version",
+		Short: "Restore the spec of a previous version of a pipeline.",
+		Long: `Restore the spec of a previous version of a pipeline (see list-pipeline-versions).
+
+The pipeline is updated to the old spec, which makes a new version of the
+pipeline, so a rollback can itself be rolled back.
+
+Examples:
+
+	# undo the last update of pipeline "edges", which is at version 3
+	$ pachctl rollback-pipeline edges 2
+`,
+		Run: cmdutil.RunFixedArgs(2, func(args []string) error {
+			version, err := strconv.ParseUint(args[1], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid version %q: %v", args[1], err)
+			}
+			client, err := pachdclient.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			return client.RollbackPipeline(args[0], version, rollbackReprocess)
+		}),
+	}
+	rollbackPipeline.Flags().BoolVar(&rollbackReprocess, "reprocess", false, "If true, reprocess datums that were already processed by the pipeline.")
+
 	extractPipeline := &cobra.Command{
 		Use:   "extract-pipeline pipeline-name",
 		Short: "Return the manifest used to create a pipeline.",
@@ -670,7 +908,7 @@ All jobs created by a pipeline will create commits in the pipeline's repo.
 			}
 			if spec {
 				for _, pipelineInfo := range pipelineInfos {
-					if err := marshaller.Marshal(os.Stdout, ppsutil.PipelineReqFromInfo(pipelineInfo)); err != nil {
+					if err := marshaller.Marshal(os.Stdout, ppsclient.PipelineReqFromInfo(pipelineInfo)); err != nil {
 						return err
 					}
 				}
@@ -758,6 +996,7 @@ All jobs created by a pipeline will create commits in the pipeline's repo.
 	}
 
 	var memory string
+	var background bool
 	garbageCollect := &cobra.Command{
 		Use:   "garbage-collect",
 		Short: "Garbage collect unused data.",
@@ -794,10 +1033,340 @@ you can increase the amount of memory used for the bloom filters with the
 			if err != nil {
 				return err
 			}
+			if background {
+				status, err := client.StartGarbageCollect(memoryBytes)
+				if err != nil {
+					return err
+				}
+				if raw {
+					return marshaller.Marshal(os.Stdout, status)
+				}
+				return pretty.PrintGarbageCollectStatus(status)
+			}
 			return client.GarbageCollect(memoryBytes)
 		}),
 	}
 	garbageCollect.Flags().StringVarP(&memory, "memory", "m", "0", "The amount of memory to use during garbage collection. Default is 10MB.")
+	garbageCollect.Flags().BoolVarP(&background, "background", "b", false, "Start garbage collection and return immediately, rather than waiting for it to finish. Use \"pachctl inspect-garbage-collect\" to follow its progress.")
+	rawFlag(garbageCollect)
+
+	inspectGarbageCollect := &cobra.Command{
+		Use:   "inspect-garbage-collect",
+		Short: "Return the status of garbage collection.",
+		Long:  "Return the progress of the current (or most recent) garbage collection, and the garbage collection schedule.",
+		Run: cmdutil.RunFixedArgs(0, func(args []string) error {
+			client, err := pachdclient.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			status, err := client.InspectGarbageCollect()
+			if err != nil {
+				return err
+			}
+			if raw {
+				return marshaller.Marshal(os.Stdout, status)
+			}
+			return pretty.PrintGarbageCollectStatus(status)
+		}),
+	}
+	rawFlag(inspectGarbageCollect)
+
+	pauseGarbageCollect := &cobra.Command{
+		Use:   "pause-garbage-collect",
+		Short: "Pause the running garbage collection.",
+		Long:  "Pause the running garbage collection. It stops once it has finished its current batch of deletions, and can be resumed with \"pachctl resume-garbage-collect\".",
+		Run: cmdutil.RunFixedArgs(0, func(args []string) error {
+			client, err := pachdclient.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			_, err = client.PauseGarbageCollect()
+			return err
+		}),
+	}
+
+	resumeGarbageCollect := &cobra.Command{
+		Use:   "resume-garbage-collect",
+		Short: "Resume a paused garbage collection.",
+		Long:  "Resume a paused garbage collection.",
+		Run: cmdutil.RunFixedArgs(0, func(args []string) error {
+			client, err := pachdclient.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			_, err = client.ResumeGarbageCollect()
+			return err
+		}),
+	}
+
+	var scheduleMemory string
+	var clearSchedule bool
+	setGarbageCollectSchedule := &cobra.Command{
+		Use:   "set-garbage-collect-schedule [cron-spec]",
+		Short: "Run garbage collection on a schedule.",
+		Long: `Run garbage collection on a schedule, given as a standard cron expression.
+
+Examples:
+
+	# Collect garbage every day at 3am
+	$ pachctl set-garbage-collect-schedule "0 3 * * *"
+
+	# Stop collecting garbage on a schedule
+	$ pachctl set-garbage-collect-schedule --clear
+
+A scheduled collection fails (and is retried at its next scheduled time) if any
+pipelines are running when it starts.
+`,
+		Run: cmdutil.RunBoundedArgs(0, 1, func(args []string) error {
+			if clearSchedule == (len(args) == 1) {
+				return fmt.Errorf("either a cron spec or the --clear flag needs to be provided")
+			}
+			client, err := pachdclient.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			if clearSchedule {
+				return client.SetGarbageCollectSchedule("", 0)
+			}
+			memoryBytes, err := units.RAMInBytes(scheduleMemory)
+			if err != nil {
+				return err
+			}
+			return client.SetGarbageCollectSchedule(args[0], memoryBytes)
+		}),
+	}
+	setGarbageCollectSchedule.Flags().StringVarP(&scheduleMemory, "memory", "m", "0", "The amount of memory to use during scheduled garbage collections. Default is 10MB.")
+	setGarbageCollectSchedule.Flags().BoolVar(&clearSchedule, "clear", false, "Remove the garbage collection schedule.")
+
+	var targetAddress, targetToken, targetCACerts string
+	replicationTarget := func() (*ppsclient.ReplicationTarget, error) {
+		if targetAddress == "" {
+			return nil, fmt.Errorf("--target must be set")
+		}
+		target := &ppsclient.ReplicationTarget{
+			PachdAddress: targetAddress,
+			AuthToken:    targetToken,
+		}
+		if targetCACerts != "" {
+			var err error
+			if target.CaCerts, err = ioutil.ReadFile(targetCACerts); err != nil {
+				return nil, fmt.Errorf("could not read %s: %v", targetCACerts, err)
+			}
+		}
+		return target, nil
+	}
+	createReplication := func(update bool) func(args []string) error {
+		return func(args []string) error {
+			target, err := replicationTarget()
+			if err != nil {
+				return err
+			}
+			branches, err := cmdutil.ParseBranches(args[1:])
+			if err != nil {
+				return err
+			}
+			client, err := pachdclient.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			return client.CreateReplication(args[0], target, branches, update)
+		}
+	}
+	replicationFlags := func(cmd *cobra.Command) {
+		cmd.Flags().StringVar(&targetAddress, "target", "", "The address of the target cluster's pachd (e.g. dr.example.com:30650).")
+		cmd.Flags().StringVar(&targetToken, "target-token", "", "An auth token for the target cluster, if it has auth activated. The token should belong to a cluster admin, as repos are created in the target as needed.")
+		cmd.Flags().StringVar(&targetCACerts, "target-ca-certs", "", "A file of PEM-encoded certificates to check the target's certificate against. If it's set, pachd connects to the target over TLS.")
+	}
+
+	createReplicationCmd := &cobra.Command{
+		Use:   "create-replication replication-name repo/branch...",
+		Short: "Replicate branches to another cluster.",
+		Long: `Replicate branches to another cluster.
+
+Pachyderm copies the finished commits on each branch to the target cluster in
+the background, oldest first, keeping their IDs. Repos are created in the
+target cluster as needed. A commit keeps its provenance there if the commits
+in it have been replicated too, so replicate upstream branches as well as
+downstream ones. Use "pachctl inspect-replication" to follow the progress.
+
+Examples:
+
+	# replicate the master branches of "images" and "edges" to a DR cluster
+	$ pachctl create-replication dr images/master edges/master --target dr.example.com:30650 --target-token $TOKEN
+`,
+		Run: cmdutil.Run(func(args []string) error {
+			if len(args) < 2 {
+				return fmt.Errorf("a replication name and at least one branch must be provided")
+			}
+			return createReplication(false)(args)
+		}),
+	}
+	replicationFlags(createReplicationCmd)
+
+	updateReplication := &cobra.Command{
+		Use:   "update-replication replication-name repo/branch...",
+		Short: "Change a replication's target or branches.",
+		Long:  "Change a replication's target or branches. The progress of the branches that it still replicates is kept.",
+		Run: cmdutil.Run(func(args []string) error {
+			if len(args) < 2 {
+				return fmt.Errorf("a replication name and at least one branch must be provided")
+			}
+			return createReplication(true)(args)
+		}),
+	}
+	replicationFlags(updateReplication)
+
+	inspectReplication := &cobra.Command{
+		Use:   "inspect-replication replication-name",
+		Short: "Return info about a replication.",
+		Long:  "Return info about a replication, and the progress of each of its branches.",
+		Run: cmdutil.RunFixedArgs(1, func(args []string) error {
+			client, err := pachdclient.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			replicationInfo, err := client.InspectReplication(args[0])
+			if err != nil {
+				return err
+			}
+			if raw {
+				return marshaller.Marshal(os.Stdout, replicationInfo)
+			}
+			return pretty.PrintDetailedReplicationInfo(replicationInfo)
+		}),
+	}
+	rawFlag(inspectReplication)
+
+	listReplication := &cobra.Command{
+		Use:   "list-replication",
+		Short: "Return info about all replications.",
+		Long:  "Return info about all replications.",
+		Run: cmdutil.RunFixedArgs(0, func(args []string) error {
+			client, err := pachdclient.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			replicationInfos, err := client.ListReplication()
+			if err != nil {
+				return err
+			}
+			if raw {
+				for _, replicationInfo := range replicationInfos {
+					if err := marshaller.Marshal(os.Stdout, replicationInfo); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			writer := tabwriter.NewWriter(os.Stdout, pretty.ReplicationHeader)
+			for _, replicationInfo := range replicationInfos {
+				pretty.PrintReplicationInfo(writer, replicationInfo)
+			}
+			return writer.Flush()
+		}),
+	}
+	rawFlag(listReplication)
+
+	deleteReplication := &cobra.Command{
+		Use:   "delete-replication replication-name",
+		Short: "Stop a replication.",
+		Long:  "Stop a replication. The commits that it has copied are kept in the target cluster.",
+		Run: cmdutil.RunFixedArgs(1, func(args []string) error {
+			client, err := pachdclient.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			return client.DeleteReplication(args[0])
+		}),
+	}
+
+	var maxRunningJobs, maxGPU int64
+	var maxCPU float64
+	var pipelineLimits []string
+	var clearLimits bool
+	setSchedulerLimits := &cobra.Command{
+		Use:   "set-scheduler-limits",
+		Short: "Limit the jobs that run at once.",
+		Long: `Limit the jobs that run at once, across all pipelines. Jobs beyond the limits
+wait (in priority order) until running jobs finish, rather than all starting at
+once. CPU and GPU are counted from the resource requests of the workers of the
+running jobs' pipelines. A limit of 0 is no limit.
+
+Examples:
+
+	# Run at most 20 jobs, using at most 64 CPUs and 8 GPUs, at once, and at
+	# most 2 jobs of the pipeline "train"
+	$ pachctl set-scheduler-limits --max-running-jobs 20 --max-cpu 64 --max-gpu 8 --pipeline train=2
+
+	# Remove the limits
+	$ pachctl set-scheduler-limits --clear
+`,
+		Run: cmdutil.RunFixedArgs(0, func(args []string) error {
+			var limits *ppsclient.SchedulerLimits
+			if !clearLimits {
+				limits = &ppsclient.SchedulerLimits{
+					MaxRunningJobs: maxRunningJobs,
+					MaxCpu:         maxCPU,
+					MaxGpu:         maxGPU,
+				}
+				for _, pipelineLimit := range pipelineLimits {
+					parts := strings.SplitN(pipelineLimit, "=", 2)
+					if len(parts) != 2 {
+						return fmt.Errorf("invalid pipeline limit %q, it must be of the form pipeline=max-running-jobs", pipelineLimit)
+					}
+					max, err := strconv.ParseInt(parts[1], 10, 64)
+					if err != nil {
+						return fmt.Errorf("invalid pipeline limit %q: %v", pipelineLimit, err)
+					}
+					if limits.PipelineMaxRunningJobs == nil {
+						limits.PipelineMaxRunningJobs = make(map[string]int64)
+					}
+					limits.PipelineMaxRunningJobs[parts[0]] = max
+				}
+			}
+			client, err := pachdclient.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			return client.SetSchedulerLimits(limits)
+		}),
+	}
+	setSchedulerLimits.Flags().Int64Var(&maxRunningJobs, "max-running-jobs", 0, "The number of jobs that may run at once.")
+	setSchedulerLimits.Flags().Float64Var(&maxCPU, "max-cpu", 0, "The number of CPUs that the workers of running jobs may request.")
+	setSchedulerLimits.Flags().Int64Var(&maxGPU, "max-gpu", 0, "The number of GPUs that the workers of running jobs may request.")
+	setSchedulerLimits.Flags().StringSliceVar(&pipelineLimits, "pipeline", nil, "The number of jobs that a pipeline may run at once, as pipeline=max-running-jobs (may be repeated).")
+	setSchedulerLimits.Flags().BoolVar(&clearLimits, "clear", false, "Remove the limits.")
+
+	inspectScheduler := &cobra.Command{
+		Use:   "inspect-scheduler",
+		Short: "Return the scheduler's limits, and the running and queued jobs.",
+		Long:  "Return the scheduler's limits, the running jobs and the resources they're counted as using, and the queued jobs in the order in which they'll run.",
+		Run: cmdutil.RunFixedArgs(0, func(args []string) error {
+			client, err := pachdclient.NewOnUserMachine(metrics, true, "user")
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			status, err := client.InspectScheduler()
+			if err != nil {
+				return err
+			}
+			if raw {
+				return marshaller.Marshal(os.Stdout, status)
+			}
+			return pretty.PrintSchedulerStatus(status)
+		}),
+	}
+	rawFlag(inspectScheduler)
 
 	var result []*cobra.Command
 	result = append(result, job)
@@ -813,14 +1382,29 @@ you can increase the amount of memory used for the bloom filters with the
 	result = append(result, pipeline)
 	result = append(result, createPipeline)
 	result = append(result, updatePipeline)
+	result = append(result, validatePipeline)
+	result = append(result, applyPipelines)
 	result = append(result, inspectPipeline)
 	result = append(result, extractPipeline)
+	result = append(result, listPipelineVersions)
+	result = append(result, rollbackPipeline)
 	result = append(result, editPipeline)
 	result = append(result, listPipeline)
 	result = append(result, deletePipeline)
 	result = append(result, startPipeline)
 	result = append(result, stopPipeline)
 	result = append(result, garbageCollect)
+	result = append(result, inspectGarbageCollect)
+	result = append(result, pauseGarbageCollect)
+	result = append(result, resumeGarbageCollect)
+	result = append(result, setGarbageCollectSchedule)
+	result = append(result, createReplicationCmd)
+	result = append(result, updateReplication)
+	result = append(result, inspectReplication)
+	result = append(result, listReplication)
+	result = append(result, deleteReplication)
+	result = append(result, setSchedulerLimits)
+	result = append(result, inspectScheduler)
 	return result, nil
 }
 
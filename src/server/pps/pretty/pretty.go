@@ -9,6 +9,7 @@ import (
 	"strings"
 	"text/tabwriter"
 	"text/template"
+	"time"
 
 	"github.com/docker/go-units"
 	"github.com/fatih/color"
@@ -26,6 +27,15 @@ const (
 	JobHeader = "ID\tOUTPUT COMMIT\tSTARTED\tDURATION\tRESTART\tPROGRESS\tDL\tUL\tSTATE\t\n"
 	// DatumHeader is the header for datums
 	DatumHeader = "ID\tSTATUS\tTIME\t\n"
+	// ReplicationHeader is the header for replications
+	ReplicationHeader = "NAME\tTARGET\tBRANCHES\tCREATED\t\n"
+	// PipelineIssueHeader is the header for the issues found with pipeline
+	// specs
+	PipelineIssueHeader = "PIPELINE\tSEVERITY\tFIELD\tMESSAGE\t\n"
+	// PipelineChangeHeader is the header for the changes made to pipelines
+	PipelineChangeHeader = "PIPELINE\tCHANGE\t\n"
+	// PipelineVersionHeader is the header for the versions of a pipeline
+	PipelineVersionHeader = "VERSION\tIMAGE\tINPUT\tCREATED\t\n"
 )
 
 // PrintJobHeader prints a job header.
@@ -99,8 +109,9 @@ Pipeline: {{.Pipeline.Name}} {{end}} {{if .ParentJob}}
 Parent: {{.ParentJob.ID}} {{end}}
 Started: {{prettyAgo .Started}} {{if .Finished}}
 Duration: {{prettyTimeDifference .Started .Finished}} {{end}}
-State: {{jobState .State}}
-Reason: {{.Reason}}
+State: {{jobState .State}}{{if .QueuePosition}} (queue position {{.QueuePosition}}){{end}}
+Reason: {{.Reason}}{{if .Priority}}
+Priority: {{.Priority}}{{end}}
 Processed: {{.DataProcessed}}
 Failed: {{.DataFailed}}
 Skipped: {{.DataSkipped}}
@@ -114,16 +125,19 @@ Datum Timeout: {{.DatumTimeout}}
 Job Timeout: {{.JobTimeout}}
 Worker Status:
 {{workerStatus .}}Restarts: {{.Restart}}
-ParallelismSpec: {{.ParallelismSpec}}
+{{range .Preemptions}}Preempted: {{.Worker}} {{prettyAgo .Time}}: {{.Message}}
+{{end}}ParallelismSpec: {{.ParallelismSpec}}
 {{ if .ResourceRequests }}ResourceRequests:
   CPU: {{ .ResourceRequests.Cpu }}
-  Memory: {{ .ResourceRequests.Memory }} {{end}}
+  Memory: {{ .ResourceRequests.Memory }} {{range $name, $amount := .ResourceRequests.ExtendedResources}}
+  {{ $name }}: {{ $amount }} {{end}}{{end}}
 {{ if .ResourceLimits }}ResourceLimits:
   CPU: {{ .ResourceLimits.Cpu }}
   Memory: {{ .ResourceLimits.Memory }}
   {{ if .ResourceLimits.Gpu }}GPU:
     Type: {{ .ResourceLimits.Gpu.Type }} 
-    Number: {{ .ResourceLimits.Gpu.Number }} {{end}} {{end}}
+    Number: {{ .ResourceLimits.Gpu.Number }} {{end}}{{range $name, $amount := .ResourceLimits.ExtendedResources}}
+  {{ $name }}: {{ $amount }} {{end}} {{end}}
 {{ if .Service }}Service:
 	{{ if .Service.InternalPort }}InternalPort: {{ .Service.InternalPort }} {{end}}
 	{{ if .Service.ExternalPort }}ExternalPort: {{ .Service.ExternalPort }} {{end}} {{end}}Input:
@@ -149,7 +163,8 @@ func PrintDetailedPipelineInfo(pipelineInfo *ppsclient.PipelineInfo) error {
 	template, err := template.New("PipelineInfo").Funcs(funcMap).Parse(
 		`Name: {{.Pipeline.Name}}{{if .Description}}
 Description: {{.Description}}{{end}}
-Created: {{prettyAgo .CreatedAt}}
+Created: {{prettyAgo .CreatedAt}}{{if .Priority}}
+Priority: {{.Priority}}{{end}}
 State: {{pipelineState .State}}
 Stopped: {{ .Stopped }}
 Reason: {{.Reason}}
@@ -165,7 +180,9 @@ Parallelism Spec: {{.ParallelismSpec}}
     Number: {{ .ResourceLimits.Gpu.Number }} {{end}} {{end}}
 Datum Timeout: {{.DatumTimeout}}
 Job Timeout: {{.JobTimeout}}
-Input:
+{{ if .Standby }}Standby Idle Timeout: {{.StandbyIdleTimeout}}
+{{end}}{{ if .DatumFailurePolicy }}Datum Failure Policy: {{.DatumFailurePolicy}}
+{{end}}Input:
 {{pipelineInput .}}
 {{ if .GithookURL }}Githook URL: {{.GithookURL}} {{end}}
 Output Branch: {{.OutputBranch}}
@@ -186,6 +203,115 @@ Job Counts:
 	return nil
 }
 
+// PrintGarbageCollectStatus pretty prints the status of garbage collection.
+func PrintGarbageCollectStatus(status *ppsclient.GarbageCollectStatus) error {
+	template, err := template.New("GarbageCollectStatus").Funcs(funcMap).Parse(
+		`State: {{gcState .State}}{{if .Scheduled}} (scheduled){{end}}{{if .Started}}
+Started: {{prettyAgo .Started}}{{end}}{{if .Finished}}
+Duration: {{prettyTimeDifference .Started .Finished}}{{end}}{{if .Reason}}
+Reason: {{.Reason}}{{end}}
+Objects Scanned: {{.ObjectsScanned}}
+Objects Deleted: {{.ObjectsDeleted}}
+Tags Scanned: {{.TagsScanned}}
+Tags Deleted: {{.TagsDeleted}}
+Bytes Reclaimed: {{prettySize .BytesReclaimed}}
+{{if .Schedule}}Schedule: {{.Schedule.CronSpec}}
+Next Run: {{prettyTime .Schedule.Next}}
+{{else}}Schedule: none
+{{end}}`)
+	if err != nil {
+		return err
+	}
+	return template.Execute(os.Stdout, status)
+}
+
+// PrintSchedulerStatus pretty-prints the scheduler's limits, and its running
+// and queued jobs.
+func PrintSchedulerStatus(status *ppsclient.SchedulerStatus) error {
+	template, err := template.New("SchedulerStatus").Funcs(funcMap).Parse(
+		`{{with .Limits}}Max Running Jobs: {{if .MaxRunningJobs}}{{.MaxRunningJobs}}{{else}}unlimited{{end}}
+Max CPU: {{if .MaxCpu}}{{.MaxCpu}}{{else}}unlimited{{end}}
+Max GPU: {{if .MaxGpu}}{{.MaxGpu}}{{else}}unlimited{{end}}
+{{if .PipelineMaxRunningJobs}}Pipeline Limits:{{range $pipeline, $max := .PipelineMaxRunningJobs}}
+  {{$pipeline}}: {{$max}} running jobs{{end}}
+{{end}}{{else}}Limits: none
+{{end}}Running Jobs: {{len .Running}}{{range .Running}}
+  {{.Job.ID}} ({{.Pipeline.Name}}): {{.Cpu}} CPU, {{.Gpu}} GPU, admitted {{prettyAgo .Admitted}}{{end}}
+Queued Jobs: {{len .Queued}}{{range .Queued}}
+  {{.ID}}{{end}}
+`)
+	if err != nil {
+		return err
+	}
+	return template.Execute(os.Stdout, status)
+}
+
+// PrintReplicationHeader prints a replication header.
+func PrintReplicationHeader(w io.Writer) {
+	fmt.Fprint(w, ReplicationHeader)
+}
+
+// PrintReplicationInfo pretty-prints replication info.
+func PrintReplicationInfo(w io.Writer, replicationInfo *ppsclient.ReplicationInfo) {
+	var branches []string
+	for _, branch := range replicationInfo.Branches {
+		branches = append(branches, fmt.Sprintf("%s@%s", branch.Repo.Name, branch.Name))
+	}
+	fmt.Fprintf(w, "%s\t", replicationInfo.Replication.Name)
+	fmt.Fprintf(w, "%s\t", replicationInfo.Target.PachdAddress)
+	fmt.Fprintf(w, "%s\t", strings.Join(branches, ", "))
+	fmt.Fprintf(w, "%s\t\n", pretty.Ago(replicationInfo.Created))
+}
+
+// PrintPipelineVersion pretty-prints a version of a pipeline.
+func PrintPipelineVersion(w io.Writer, pipelineInfo *ppsclient.PipelineInfo) {
+	fmt.Fprintf(w, "%d\t", pipelineInfo.Version)
+	fmt.Fprintf(w, "%s\t", pipelineInfo.Transform.GetImage())
+	fmt.Fprintf(w, "%s\t", ShorthandInput(pipelineInfo.Input))
+	fmt.Fprintf(w, "%s\t\n", pretty.Ago(pipelineInfo.CreatedAt))
+}
+
+// PrintPipelineIssue pretty-prints an issue found with the spec of 'pipeline'.
+func PrintPipelineIssue(w io.Writer, pipeline string, issue *ppsclient.PipelineIssue) {
+	severity := "error"
+	if issue.Severity == ppsclient.IssueSeverity_ISSUE_WARNING {
+		severity = "warning"
+	}
+	field := issue.Field
+	if field == "" {
+		field = "-"
+	}
+	fmt.Fprintf(w, "%s\t", pipeline)
+	fmt.Fprintf(w, "%s\t", severity)
+	fmt.Fprintf(w, "%s\t", field)
+	fmt.Fprintf(w, "%s\t\n", issue.Message)
+}
+
+// PrintPipelineChange pretty-prints a change made to a pipeline.
+func PrintPipelineChange(w io.Writer, change *ppsclient.PipelineChange) {
+	fmt.Fprintf(w, "%s\t", change.Pipeline.Name)
+	fmt.Fprintf(w, "%s\t\n", strings.TrimPrefix(strings.ToLower(change.Type.String()), "pipeline_"))
+}
+
+// PrintDetailedReplicationInfo pretty-prints a replication, and the progress
+// of each of its branches.
+func PrintDetailedReplicationInfo(replicationInfo *ppsclient.ReplicationInfo) error {
+	template, err := template.New("ReplicationInfo").Funcs(funcMap).Parse(
+		`Name: {{.Replication.Name}}
+Target: {{.Target.PachdAddress}}{{if .Target.CaCerts}} (TLS){{end}}
+Created: {{prettyAgo .Created}}
+Branches:{{range .Branches}}
+  {{.Repo.Name}}@{{.Name}}{{end}}
+{{if .Status}}Progress:{{range .Status}}
+  {{.Branch.Repo.Name}}@{{.Branch.Name}}: {{.CommitsReplicated}} commits ({{prettySize .BytesReplicated}}) replicated{{if .LastCommit}}, up to {{.LastCommit.ID}}{{end}}{{if .Head}}{{if or (not .LastCommit) (ne .LastCommit.ID .Head.ID)}} (head is {{.Head.ID}}){{end}}{{end}}, checked {{prettyAgo .Updated}}{{if .Error}}
+    Error: {{.Error}}{{end}}{{end}}
+{{end}}`)
+	if err != nil {
+		return err
+	}
+	return template.Execute(os.Stdout, replicationInfo)
+}
+
 // PrintDatumInfoHeader prints a file info header.
 func PrintDatumInfoHeader(w io.Writer) {
 	fmt.Fprint(w, DatumHeader)
@@ -309,6 +435,30 @@ func pipelineState(pipelineState ppsclient.PipelineState) string {
 	return "-"
 }
 
+func gcState(gcState ppsclient.GarbageCollectState) string {
+	switch gcState {
+	case ppsclient.GarbageCollectState_GC_NEVER_RUN:
+		return "never run"
+	case ppsclient.GarbageCollectState_GC_RUNNING:
+		return color.New(color.FgYellow).SprintFunc()("running")
+	case ppsclient.GarbageCollectState_GC_PAUSED:
+		return color.New(color.FgYellow).SprintFunc()("paused")
+	case ppsclient.GarbageCollectState_GC_SUCCESS:
+		return color.New(color.FgGreen).SprintFunc()("success")
+	case ppsclient.GarbageCollectState_GC_FAILURE:
+		return color.New(color.FgRed).SprintFunc()("failure")
+	}
+	return "-"
+}
+
+func prettyTime(timestamp *types.Timestamp) string {
+	t, err := types.TimestampFromProto(timestamp)
+	if err != nil {
+		return "-"
+	}
+	return t.Local().Format(time.RFC1123)
+}
+
 func jobInput(jobInfo *ppsclient.JobInfo) string {
 	if jobInfo.Input == nil {
 		return ""
@@ -378,6 +528,18 @@ func ShorthandInput(input *ppsclient.Input) string {
 			subInput = append(subInput, ShorthandInput(input))
 		}
 		return "(" + strings.Join(subInput, " ∪ ") + ")"
+	case input.Join != nil:
+		var subInput []string
+		for _, input := range input.Join {
+			subInput = append(subInput, fmt.Sprintf("%s:%s", ShorthandInput(input), input.Pfs.GetJoinOn()))
+		}
+		return "(" + strings.Join(subInput, " ⋈ ") + ")"
+	case input.Group != nil:
+		var subInput []string
+		for _, input := range input.Group {
+			subInput = append(subInput, fmt.Sprintf("%s:%s", ShorthandInput(input), input.Pfs.GetGroupBy()))
+		}
+		return "group(" + strings.Join(subInput, ", ") + ")"
 	case input.Cron != nil:
 		return fmt.Sprintf("%s:%s", input.Cron.Name, input.Cron.Spec)
 	}
@@ -387,6 +549,8 @@ func ShorthandInput(input *ppsclient.Input) string {
 var funcMap = template.FuncMap{
 	"pipelineState":        pipelineState,
 	"jobState":             jobState,
+	"gcState":              gcState,
+	"prettyTime":           prettyTime,
 	"datumState":           datumState,
 	"workerStatus":         workerStatus,
 	"pipelineInput":        pipelineInput,
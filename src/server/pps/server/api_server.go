@@ -19,6 +19,7 @@ import (
 	"github.com/pachyderm/pachyderm/src/client/auth"
 	"github.com/pachyderm/pachyderm/src/client/limit"
 	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/dryrun"
 	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
 	"github.com/pachyderm/pachyderm/src/client/pps"
 	"github.com/pachyderm/pachyderm/src/server/pkg/ancestry"
@@ -27,6 +28,7 @@ import (
 	"github.com/pachyderm/pachyderm/src/server/pkg/hashtree"
 	"github.com/pachyderm/pachyderm/src/server/pkg/log"
 	"github.com/pachyderm/pachyderm/src/server/pkg/metrics"
+	"github.com/pachyderm/pachyderm/src/server/pkg/pager"
 	"github.com/pachyderm/pachyderm/src/server/pkg/ppsconsts"
 	"github.com/pachyderm/pachyderm/src/server/pkg/ppsdb"
 	"github.com/pachyderm/pachyderm/src/server/pkg/ppsutil"
@@ -40,6 +42,7 @@ import (
 
 	etcd "github.com/coreos/etcd/clientv3"
 	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/protobuf/types"
 	logrus "github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
@@ -50,6 +53,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	kube "k8s.io/client-go/kubernetes"
 )
 
@@ -60,6 +64,10 @@ const (
 	// DefaultDatumTries is the default number of times a datum will be tried
 	// before we give up and consider the job failed.
 	DefaultDatumTries = 3
+	// DefaultQuarantineBranch is the default branch of a pipeline's output repo
+	// that failed datums are recorded in, if its DatumFailurePolicy
+	// quarantines them.
+	DefaultQuarantineBranch = "errors"
 )
 
 var (
@@ -126,8 +134,12 @@ type apiServer struct {
 	reporter              *metrics.Reporter
 	monitorCancels        map[string]func()
 	// collections
-	pipelines col.Collection
-	jobs      col.Collection
+	pipelines       col.Collection
+	jobs            col.Collection
+	gcStatus        col.Collection
+	replications    col.Collection
+	schedulerLimits col.Collection
+	admissions      col.Collection
 }
 
 func merge(from, to map[string]bool) {
@@ -172,6 +184,18 @@ func validateNames(names map[string]bool, input *pps.Input) error {
 				return err
 			}
 		}
+	case input.Join != nil:
+		for _, input := range input.Join {
+			if err := validateNames(names, input); err != nil {
+				return err
+			}
+		}
+	case input.Group != nil:
+		for _, input := range input.Group {
+			if err := validateNames(names, input); err != nil {
+				return err
+			}
+		}
 	case input.Git != nil:
 		if names[input.Git.Name] == true {
 			return fmt.Errorf(`name "%s" was used more than once`, input.Git.Name)
@@ -187,108 +211,162 @@ func (a *apiServer) validateInput(pachClient *client.APIClient, pipelineName str
 	}
 	var result error
 	pps.VisitInput(input, func(input *pps.Input) {
-		if err := func() error {
-			set := false
-			if input.Atom != nil {
-				set = true
-				switch {
-				case len(input.Atom.Name) == 0:
-					return fmt.Errorf("input must specify a name")
-				case input.Atom.Name == "out":
-					return fmt.Errorf("input cannot be named \"out\", as pachyderm " +
-						"already creates /pfs/out to collect job output")
-				case input.Atom.Repo == "":
-					return fmt.Errorf("input must specify a repo")
-				case input.Atom.Branch == "" && !job:
-					return fmt.Errorf("input must specify a branch")
-				case len(input.Atom.Glob) == 0:
-					return fmt.Errorf("input must specify a glob")
-				}
-				// Note that input.Atom.Commit is empty if a) this is a job b) one of
-				// the job pipeline's input branches has no commits yet
-				if job && input.Atom.Commit != "" {
-					// for jobs we check that the input commit exists
-					if _, err := pachClient.InspectCommit(input.Atom.Repo, input.Atom.Commit); err != nil {
-						return err
-					}
-				} else {
-					// for pipelines we only check that the repo exists
-					if _, err := pachClient.InspectRepo(input.Atom.Repo); err != nil {
-						return err
-					}
-				}
-			}
-			if input.Pfs != nil {
-				set = true
-				switch {
-				case len(input.Pfs.Name) == 0:
-					return fmt.Errorf("input must specify a name")
-				case input.Pfs.Name == "out":
-					return fmt.Errorf("input cannot be named \"out\", as pachyderm " +
-						"already creates /pfs/out to collect job output")
-				case input.Pfs.Repo == "":
-					return fmt.Errorf("input must specify a repo")
-				case input.Pfs.Branch == "" && !job:
-					return fmt.Errorf("input must specify a branch")
-				case len(input.Pfs.Glob) == 0:
-					return fmt.Errorf("input must specify a glob")
-				}
-				// Note that input.Pfs.Commit is empty if a) this is a job b) one of
-				// the job pipeline's input branches has no commits yet
-				if job && input.Pfs.Commit != "" {
-					// for jobs we check that the input commit exists
-					if _, err := pachClient.InspectCommit(input.Pfs.Repo, input.Pfs.Commit); err != nil {
-						return err
-					}
-				} else {
-					// for pipelines we only check that the repo exists
-					if _, err := pachClient.InspectRepo(input.Pfs.Repo); err != nil {
-						return err
-					}
-				}
+		if err := a.validateInputNode(pachClient, input, job); err != nil && result == nil {
+			result = err
+		}
+	})
+	return result
+}
+
+// validateInputNode validates 'input' itself, and not the inputs in it (if
+// it's a cross or union)
+func (a *apiServer) validateInputNode(pachClient *client.APIClient, input *pps.Input, job bool) error {
+	set := false
+	if input.Atom != nil {
+		set = true
+		switch {
+		case len(input.Atom.Name) == 0:
+			return fmt.Errorf("input must specify a name")
+		case input.Atom.Name == "out":
+			return fmt.Errorf("input cannot be named \"out\", as pachyderm " +
+				"already creates /pfs/out to collect job output")
+		case input.Atom.Repo == "":
+			return fmt.Errorf("input must specify a repo")
+		case input.Atom.Branch == "" && !job:
+			return fmt.Errorf("input must specify a branch")
+		case len(input.Atom.Glob) == 0:
+			return fmt.Errorf("input must specify a glob")
+		}
+		if input.Atom.GlobType == pfs.PatternType_REGEX {
+			if _, err := regexp.Compile(input.Atom.Glob); err != nil {
+				return fmt.Errorf("input glob %q is not a valid regular expression: %v", input.Atom.Glob, err)
 			}
-			if input.Cross != nil {
-				if set {
-					return fmt.Errorf("multiple input types set")
-				}
-				set = true
+		}
+		// Note that input.Atom.Commit is empty if a) this is a job b) one of
+		// the job pipeline's input branches has no commits yet
+		if job && input.Atom.Commit != "" {
+			// for jobs we check that the input commit exists
+			if _, err := pachClient.InspectCommit(input.Atom.Repo, input.Atom.Commit); err != nil {
+				return err
 			}
-			if input.Union != nil {
-				if set {
-					return fmt.Errorf("multiple input types set")
-				}
-				set = true
+		} else {
+			// for pipelines we only check that the repo exists
+			if _, err := pachClient.InspectRepo(input.Atom.Repo); err != nil {
+				return err
 			}
-			if input.Cron != nil {
-				if set {
-					return fmt.Errorf("multiple input types set")
-				}
-				set = true
-				if _, err := cron.ParseStandard(input.Cron.Spec); err != nil {
-					return fmt.Errorf("error parsing cron-spec: %v", err)
-				}
+		}
+	}
+	if input.Pfs != nil {
+		set = true
+		switch {
+		case len(input.Pfs.Name) == 0:
+			return fmt.Errorf("input must specify a name")
+		case input.Pfs.Name == "out":
+			return fmt.Errorf("input cannot be named \"out\", as pachyderm " +
+				"already creates /pfs/out to collect job output")
+		case input.Pfs.Repo == "":
+			return fmt.Errorf("input must specify a repo")
+		case input.Pfs.Branch == "" && !job:
+			return fmt.Errorf("input must specify a branch")
+		case len(input.Pfs.Glob) == 0:
+			return fmt.Errorf("input must specify a glob")
+		}
+		if input.Pfs.GlobType == pfs.PatternType_REGEX {
+			if _, err := regexp.Compile(input.Pfs.Glob); err != nil {
+				return fmt.Errorf("input glob %q is not a valid regular expression: %v", input.Pfs.Glob, err)
 			}
-			if input.Git != nil {
-				if set {
-					return fmt.Errorf("multiple input types set")
-				}
-				set = true
-				if err := pps.ValidateGitCloneURL(input.Git.URL); err != nil {
-					return err
-				}
+		}
+		// Note that input.Pfs.Commit is empty if a) this is a job b) one of
+		// the job pipeline's input branches has no commits yet
+		if job && input.Pfs.Commit != "" {
+			// for jobs we check that the input commit exists
+			if _, err := pachClient.InspectCommit(input.Pfs.Repo, input.Pfs.Commit); err != nil {
+				return err
 			}
-			if !set {
-				return fmt.Errorf("no input set")
+		} else {
+			// for pipelines we only check that the repo exists
+			if _, err := pachClient.InspectRepo(input.Pfs.Repo); err != nil {
+				return err
 			}
-			return nil
-		}(); err != nil && result == nil {
-			result = err
 		}
-	})
-	return result
+	}
+	if input.Cross != nil {
+		if set {
+			return fmt.Errorf("multiple input types set")
+		}
+		set = true
+	}
+	if input.Union != nil {
+		if set {
+			return fmt.Errorf("multiple input types set")
+		}
+		set = true
+	}
+	if input.Join != nil {
+		if set {
+			return fmt.Errorf("multiple input types set")
+		}
+		set = true
+		if err := ppsutil.ValidateJoin(input.Join); err != nil {
+			return err
+		}
+	}
+	if input.Group != nil {
+		if set {
+			return fmt.Errorf("multiple input types set")
+		}
+		set = true
+		if err := ppsutil.ValidateGroup(input.Group); err != nil {
+			return err
+		}
+	}
+	if input.Cron != nil {
+		if set {
+			return fmt.Errorf("multiple input types set")
+		}
+		set = true
+		if _, err := cron.ParseStandard(input.Cron.Spec); err != nil {
+			return fmt.Errorf("error parsing cron-spec: %v", err)
+		}
+	}
+	if input.Git != nil {
+		if set {
+			return fmt.Errorf("multiple input types set")
+		}
+		set = true
+		if err := pps.ValidateGitCloneURL(input.Git.URL); err != nil {
+			return err
+		}
+	}
+	if !set {
+		return fmt.Errorf("no input set")
+	}
+	return nil
 }
 
 func validateTransform(transform *pps.Transform) error {
+	if transform.ServiceAccount != "" {
+		if errs := validation.IsDNS1123Subdomain(transform.ServiceAccount); len(errs) > 0 {
+			return fmt.Errorf("invalid service account %q: %s", transform.ServiceAccount, strings.Join(errs, "; "))
+		}
+	}
+	for _, secret := range transform.Secrets {
+		if secret.Vault == nil {
+			continue
+		}
+		if secret.Vault.Path == "" || secret.Vault.Role == "" {
+			return fmt.Errorf("Vault secrets must set path and role")
+		}
+		if secret.MountPath == "" && secret.EnvVar == "" {
+			return fmt.Errorf("Vault secret %s must set mount_path or env_var", secret.Vault.Path)
+		}
+		if secret.EnvVar != "" && secret.Key == "" {
+			return fmt.Errorf("Vault secret %s must set the key to load into env_var %s", secret.Vault.Path, secret.EnvVar)
+		}
+		if secret.MountPath != "" && !path.IsAbs(secret.MountPath) {
+			return fmt.Errorf("Vault secret %s's mount_path must be absolute", secret.Vault.Path)
+		}
+	}
 	return nil
 }
 
@@ -595,6 +673,9 @@ func (a *apiServer) InspectJob(ctx context.Context, request *pps.InspectJobReque
 	if err != nil {
 		return nil, err
 	}
+	if jobInfo.QueuePosition, err = a.jobQueuePosition(pachClient, jobPtr); err != nil {
+		return nil, err
+	}
 	// If the job is running we fill in WorkerStatus field, otherwise we just
 	// return the jobInfo.
 	if jobInfo.State != pps.JobState_JOB_RUNNING {
@@ -730,6 +811,7 @@ func (a *apiServer) jobInfoFromPtr(pachClient *client.APIClient, jobPtr *pps.Etc
 		Reason:        jobPtr.Reason,
 		Started:       jobPtr.Started,
 		Finished:      jobPtr.Finished,
+		Preemptions:   jobPtr.Preemptions,
 	}
 	commitInfo, err := pachClient.InspectCommit(jobPtr.OutputCommit.Repo.Name, jobPtr.OutputCommit.ID)
 	if err != nil {
@@ -781,8 +863,14 @@ func (a *apiServer) jobInfoFromPtr(pachClient *client.APIClient, jobPtr *pps.Etc
 	result.DatumTimeout = pipelineInfo.DatumTimeout
 	result.JobTimeout = pipelineInfo.JobTimeout
 	result.DatumTries = pipelineInfo.DatumTries
+	result.DatumFailurePolicy = pipelineInfo.DatumFailurePolicy
+	result.Priority = pipelineInfo.Priority
 	result.SchedulingSpec = pipelineInfo.SchedulingSpec
 	result.PodSpec = pipelineInfo.PodSpec
+	result.PodPatch = pipelineInfo.PodPatch
+	result.Metadata = pipelineInfo.Metadata
+	result.InitContainers = pipelineInfo.InitContainers
+	result.Sidecars = pipelineInfo.Sidecars
 	return result, nil
 }
 
@@ -798,7 +886,11 @@ func (a *apiServer) ListJob(ctx context.Context, request *pps.ListJobRequest) (r
 	}(time.Now())
 	pachClient := a.getPachClient().WithCtx(ctx)
 	var jobInfos []*pps.JobInfo
+	p := pager.New(request.Page, request.PageSize)
 	if err := a.listJob(pachClient, request.Pipeline, request.OutputCommit, request.InputCommit, func(ji *pps.JobInfo) error {
+		if ok, err := p.Next(); !ok {
+			return err
+		}
 		jobInfos = append(jobInfos, ji)
 		return nil
 	}); err != nil {
@@ -814,7 +906,11 @@ func (a *apiServer) ListJobStream(request *pps.ListJobRequest, resp pps.API_List
 		a.Log(request, fmt.Sprintf("stream containing %d JobInfos", sent), retErr, time.Since(start))
 	}(time.Now())
 	pachClient := a.getPachClient().WithCtx(resp.Context())
+	p := pager.New(request.Page, request.PageSize)
 	return a.listJob(pachClient, request.Pipeline, request.OutputCommit, request.InputCommit, func(ji *pps.JobInfo) error {
+		if ok, err := p.Next(); !ok {
+			return err
+		}
 		if err := resp.Send(ji); err != nil {
 			return err
 		}
@@ -1478,16 +1574,133 @@ func (a *apiServer) getLogsFromStats(pachClient *client.APIClient, request *pps.
 	return eg.Wait()
 }
 
+func validateDatumFailurePolicy(pipelineInfo *pps.PipelineInfo) error {
+	policy := pipelineInfo.DatumFailurePolicy
+	if policy == nil {
+		return nil
+	}
+	if _, ok := pps.DatumFailureAction_name[int32(policy.Action)]; !ok {
+		return fmt.Errorf("invalid DatumFailurePolicy.Action %d", policy.Action)
+	}
+	var retryBackoff, maxRetryBackoff time.Duration
+	var err error
+	if policy.RetryBackoff != nil {
+		if retryBackoff, err = types.DurationFromProto(policy.RetryBackoff); err != nil {
+			return err
+		}
+		if retryBackoff < 0 {
+			return fmt.Errorf("DatumFailurePolicy.RetryBackoff must be >= 0")
+		}
+	}
+	if policy.MaxRetryBackoff != nil {
+		if maxRetryBackoff, err = types.DurationFromProto(policy.MaxRetryBackoff); err != nil {
+			return err
+		}
+		if maxRetryBackoff < retryBackoff {
+			return fmt.Errorf("DatumFailurePolicy.MaxRetryBackoff must be >= RetryBackoff")
+		}
+	}
+	if policy.QuarantineBranch != "" {
+		if policy.Action != pps.DatumFailureAction_DATUM_QUARANTINE {
+			return fmt.Errorf("DatumFailurePolicy.QuarantineBranch can only be set if failed datums are quarantined")
+		}
+		if policy.QuarantineBranch == pipelineInfo.OutputBranch {
+			return fmt.Errorf("DatumFailurePolicy.QuarantineBranch can't be the pipeline's output branch")
+		}
+	}
+	return nil
+}
+
+// validateMetadata returns an error if the annotations and labels that
+// 'pipelineInfo' adds to its workers aren't valid, or if its labels would
+// replace the labels that Pachyderm uses to manage the workers
+func validateMetadata(pipelineInfo *pps.PipelineInfo) error {
+	metadata := pipelineInfo.Metadata
+	if metadata == nil {
+		return nil
+	}
+	for key := range metadata.Annotations {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid Metadata.Annotations key %q: %s", key, strings.Join(errs, "; "))
+		}
+	}
+	reserved := labels("")
+	reserved["version"] = ""
+	reserved["pipelineName"] = ""
+	for key, value := range metadata.Labels {
+		if _, ok := reserved[key]; ok {
+			return fmt.Errorf("Metadata.Labels can't set the label %q, which Pachyderm uses to manage workers", key)
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid Metadata.Labels key %q: %s", key, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid value %q for label %q: %s", value, key, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// validateContainers returns an error if any of the init containers or
+// sidecars that 'pipelineInfo' adds to its workers is invalid
+func validateContainers(pipelineInfo *pps.PipelineInfo) error {
+	// "init" is Pachyderm's init container
+	names := map[string]bool{
+		"init":                               true,
+		client.PPSWorkerUserContainerName:    true,
+		client.PPSWorkerSidecarContainerName: true,
+	}
+	validate := func(field string, containers []*pps.ContainerSpec) error {
+		for i, container := range containers {
+			field := fmt.Sprintf("%s[%d]", field, i)
+			if errs := validation.IsDNS1123Label(container.Name); len(errs) > 0 {
+				return fmt.Errorf("invalid %s.Name %q: %s", field, container.Name, strings.Join(errs, "; "))
+			}
+			if names[container.Name] {
+				return fmt.Errorf("%s.Name: the worker pod already has a container named %q", field, container.Name)
+			}
+			names[container.Name] = true
+			if container.Image == "" {
+				return fmt.Errorf("%s must specify an image", field)
+			}
+			if err := ppsutil.ValidateExtendedResources(field+".ResourceRequests", container.ResourceRequests); err != nil {
+				return err
+			}
+			if err := ppsutil.ValidateExtendedResources(field+".ResourceLimits", container.ResourceLimits); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := validate("InitContainers", pipelineInfo.InitContainers); err != nil {
+		return err
+	}
+	return validate("Sidecars", pipelineInfo.Sidecars)
+}
+
 func (a *apiServer) validatePipeline(pachClient *client.APIClient, pipelineInfo *pps.PipelineInfo) error {
+	if err := validatePipelineName(pipelineInfo); err != nil {
+		return err
+	}
+	if err := a.validateInput(pachClient, pipelineInfo.Pipeline.Name, pipelineInfo.Input, false); err != nil {
+		return err
+	}
+	return validatePipelineSettings(pipelineInfo)
+}
+
+func validatePipelineName(pipelineInfo *pps.PipelineInfo) error {
 	if pipelineInfo.Pipeline == nil {
 		return fmt.Errorf("pipeline has no name")
 	}
 	if !pipelineNameMatcher.MatchString(pipelineInfo.Pipeline.Name) {
 		return fmt.Errorf("Invalid pipeline name: it must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyValue',  or 'my_value',  or '12345')")
 	}
-	if err := a.validateInput(pachClient, pipelineInfo.Pipeline.Name, pipelineInfo.Input, false); err != nil {
-		return err
-	}
+	return nil
+}
+
+// validatePipelineSettings validates the parts of 'pipelineInfo' other than
+// its name and input
+func validatePipelineSettings(pipelineInfo *pps.PipelineInfo) error {
 	if err := validateTransform(pipelineInfo.Transform); err != nil {
 		return fmt.Errorf("invalid transform: %v", err)
 	}
@@ -1503,6 +1716,23 @@ func (a *apiServer) validatePipeline(pachClient *client.APIClient, pipelineInfo
 			return fmt.Errorf("contradictory parallelism strategies: must set at " +
 				"most one of ParallelismSpec.Constant and ParallelismSpec.Coefficient")
 		}
+		if autoscaling := pipelineInfo.ParallelismSpec.Autoscaling; autoscaling != nil {
+			if pipelineInfo.ParallelismSpec.Constant != 0 || pipelineInfo.ParallelismSpec.Coefficient != 0 {
+				return fmt.Errorf("contradictory parallelism strategies: ParallelismSpec.Autoscaling " +
+					"can't be set with ParallelismSpec.Constant or ParallelismSpec.Coefficient")
+			}
+			if autoscaling.MinWorkers < 1 {
+				return fmt.Errorf("ParallelismSpec.Autoscaling.MinWorkers must be > 0")
+			}
+			if autoscaling.MaxWorkers < autoscaling.MinWorkers {
+				return fmt.Errorf("ParallelismSpec.Autoscaling.MaxWorkers must be >= MinWorkers")
+			}
+			if autoscaling.TargetDuration != nil {
+				if d, err := types.DurationFromProto(autoscaling.TargetDuration); err != nil || d <= 0 {
+					return fmt.Errorf("ParallelismSpec.Autoscaling.TargetDuration must be a positive duration")
+				}
+			}
+		}
 		if pipelineInfo.Service != nil && pipelineInfo.ParallelismSpec.Constant != 1 {
 			return fmt.Errorf("services can only be run with a constant parallelism of 1")
 		}
@@ -1530,6 +1760,57 @@ func (a *apiServer) validatePipeline(pachClient *client.APIClient, pipelineInfo
 			return err
 		}
 	}
+	if err := validateDatumFailurePolicy(pipelineInfo); err != nil {
+		return err
+	}
+	if err := ppsutil.ValidateExtendedResources("ResourceRequests", pipelineInfo.ResourceRequests); err != nil {
+		return err
+	}
+	if err := ppsutil.ValidateExtendedResources("ResourceLimits", pipelineInfo.ResourceLimits); err != nil {
+		return err
+	}
+	for name, requested := range pipelineInfo.ResourceRequests.GetExtendedResources() {
+		limit, ok := pipelineInfo.ResourceLimits.GetExtendedResources()[name]
+		if !ok {
+			continue
+		}
+		limitQuantity, requestedQuantity := resource.MustParse(limit), resource.MustParse(requested)
+		if limitQuantity.Cmp(requestedQuantity) != 0 {
+			return fmt.Errorf("the amount of %s in ResourceRequests and ResourceLimits must be the same, as extended resources can't be overcommitted", name)
+		}
+	}
+	if err := ppsutil.ValidateTolerations(pipelineInfo.SchedulingSpec.GetTolerations()); err != nil {
+		return err
+	}
+	if err := validateMetadata(pipelineInfo); err != nil {
+		return err
+	}
+	if err := validateContainers(pipelineInfo); err != nil {
+		return err
+	}
+	if pipelineInfo.PodPatch != "" {
+		if err := ppsutil.PatchPodSpec(&v1.PodSpec{}, pipelineInfo.PodPatch); err != nil {
+			return fmt.Errorf("invalid PodPatch: %v", err)
+		}
+	}
+	if pipelineInfo.Priority > ppsutil.MaxPipelinePriority || pipelineInfo.Priority < -ppsutil.MaxPipelinePriority {
+		return fmt.Errorf("Priority must be between -%d and %d", ppsutil.MaxPipelinePriority, ppsutil.MaxPipelinePriority)
+	}
+	if pipelineInfo.Priority != 0 && pipelineInfo.SchedulingSpec.GetPriorityClassName() != "" {
+		return fmt.Errorf("Priority can't be set with SchedulingSpec.PriorityClassName")
+	}
+	if pipelineInfo.StandbyIdleTimeout != nil {
+		if !pipelineInfo.Standby {
+			return fmt.Errorf("StandbyIdleTimeout can only be set if Standby is true")
+		}
+		d, err := types.DurationFromProto(pipelineInfo.StandbyIdleTimeout)
+		if err != nil {
+			return err
+		}
+		if d < 0 {
+			return fmt.Errorf("StandbyIdleTimeout must be >= 0")
+		}
+	}
 	return nil
 }
 
@@ -1781,6 +2062,67 @@ func (a *apiServer) fixPipelineInputRepoACLs(pachClient *client.APIClient, pipel
 	return nil
 }
 
+// newPipelineInfo returns the PipelineInfo of the pipeline that 'request'
+// creates (before its defaults are set)
+func newPipelineInfo(request *pps.CreatePipelineRequest) *pps.PipelineInfo {
+	return &pps.PipelineInfo{
+		Pipeline:           request.Pipeline,
+		Version:            1,
+		Transform:          request.Transform,
+		ParallelismSpec:    request.ParallelismSpec,
+		HashtreeSpec:       request.HashtreeSpec,
+		Input:              request.Input,
+		OutputBranch:       request.OutputBranch,
+		Egress:             request.Egress,
+		CreatedAt:          now(),
+		ResourceRequests:   request.ResourceRequests,
+		ResourceLimits:     request.ResourceLimits,
+		Description:        request.Description,
+		CacheSize:          request.CacheSize,
+		EnableStats:        request.EnableStats,
+		Salt:               request.Salt,
+		Batch:              request.Batch,
+		MaxQueueSize:       request.MaxQueueSize,
+		Service:            request.Service,
+		ChunkSpec:          request.ChunkSpec,
+		DatumTimeout:       request.DatumTimeout,
+		JobTimeout:         request.JobTimeout,
+		Standby:            request.Standby,
+		StandbyIdleTimeout: request.StandbyIdleTimeout,
+		DatumFailurePolicy: request.DatumFailurePolicy,
+		Priority:           request.Priority,
+		DatumTries:         request.DatumTries,
+		SchedulingSpec:     request.SchedulingSpec,
+		PodSpec:            request.PodSpec,
+		PodPatch:           request.PodPatch,
+		Metadata:           request.Metadata,
+		InitContainers:     request.InitContainers,
+		Sidecars:           request.Sidecars,
+	}
+}
+
+// defaultedPipelineInfo returns the PipelineInfo that CreatePipeline would
+// store for 'request' (before salting it and sorting its input), without
+// modifying 'request'. Unlike CreatePipeline, it accepts specs without a
+// pipeline or input, so that the issues with the rest of the spec can be
+// reported.
+func defaultedPipelineInfo(request *pps.CreatePipelineRequest) *pps.PipelineInfo {
+	pipelineInfo := newPipelineInfo(proto.Clone(request).(*pps.CreatePipelineRequest))
+	pipeline, input := pipelineInfo.Pipeline, pipelineInfo.Input
+	if pipeline == nil {
+		pipelineInfo.Pipeline = &pps.Pipeline{}
+	}
+	if pipelineInfo.Transform == nil {
+		pipelineInfo.Transform = &pps.Transform{}
+	}
+	if input == nil {
+		pipelineInfo.Input = &pps.Input{}
+	}
+	setPipelineDefaults(pipelineInfo)
+	pipelineInfo.Pipeline, pipelineInfo.Input = pipeline, input
+	return pipelineInfo
+}
+
 func (a *apiServer) CreatePipeline(ctx context.Context, request *pps.CreatePipelineRequest) (response *types.Empty, retErr error) {
 	func() { a.Log(request, nil, nil, 0) }()
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
@@ -1792,39 +2134,17 @@ func (a *apiServer) CreatePipeline(ctx context.Context, request *pps.CreatePipel
 	if request.Salt == "" {
 		request.Salt = uuid.NewWithoutDashes()
 	}
-	pipelineInfo := &pps.PipelineInfo{
-		Pipeline:         request.Pipeline,
-		Version:          1,
-		Transform:        request.Transform,
-		ParallelismSpec:  request.ParallelismSpec,
-		HashtreeSpec:     request.HashtreeSpec,
-		Input:            request.Input,
-		OutputBranch:     request.OutputBranch,
-		Egress:           request.Egress,
-		CreatedAt:        now(),
-		ResourceRequests: request.ResourceRequests,
-		ResourceLimits:   request.ResourceLimits,
-		Description:      request.Description,
-		CacheSize:        request.CacheSize,
-		EnableStats:      request.EnableStats,
-		Salt:             request.Salt,
-		Batch:            request.Batch,
-		MaxQueueSize:     request.MaxQueueSize,
-		Service:          request.Service,
-		ChunkSpec:        request.ChunkSpec,
-		DatumTimeout:     request.DatumTimeout,
-		JobTimeout:       request.JobTimeout,
-		Standby:          request.Standby,
-		DatumTries:       request.DatumTries,
-		SchedulingSpec:   request.SchedulingSpec,
-		PodSpec:          request.PodSpec,
-	}
+	pipelineInfo := newPipelineInfo(request)
 	setPipelineDefaults(pipelineInfo)
 
 	// Validate new pipeline
 	if err := a.validatePipeline(pachClient, pipelineInfo); err != nil {
 		return nil, err
 	}
+	if dryrun.IsDryRun(ctx) {
+		changes, err := a.createPipelineDryRun(pachClient, request, pipelineInfo)
+		return reportDryRun(ctx, changes, err)
+	}
 	var visitErr error
 	pps.VisitInput(pipelineInfo.Input, func(input *pps.Input) {
 		if input.Cron != nil {
@@ -1904,6 +2224,7 @@ func (a *apiServer) CreatePipeline(ctx context.Context, request *pps.CreatePipel
 				// Update pipelinePtr to point to new commit
 				pipelinePtr.SpecCommit = commit
 				pipelinePtr.State = pps.PipelineState_PIPELINE_STARTING
+				pipelinePtr.Priority = pipelineInfo.Priority
 				// Clear any failure reasons
 				pipelinePtr.Reason = ""
 				return nil
@@ -1933,6 +2254,7 @@ func (a *apiServer) CreatePipeline(ctx context.Context, request *pps.CreatePipel
 		pipelinePtr := &pps.EtcdPipelineInfo{
 			SpecCommit: commit,
 			State:      pps.PipelineState_PIPELINE_STARTING,
+			Priority:   pipelineInfo.Priority,
 		}
 
 		// Generate pipeline's auth token & add pipeline to the ACLs of input/output
@@ -2059,6 +2381,10 @@ func setPipelineDefaults(pipelineInfo *pps.PipelineInfo) {
 	if pipelineInfo.DatumTries == 0 {
 		pipelineInfo.DatumTries = DefaultDatumTries
 	}
+	if pipelineInfo.DatumFailurePolicy.GetAction() == pps.DatumFailureAction_DATUM_QUARANTINE &&
+		pipelineInfo.DatumFailurePolicy.QuarantineBranch == "" {
+		pipelineInfo.DatumFailurePolicy.QuarantineBranch = DefaultQuarantineBranch
+	}
 }
 
 func (a *apiServer) InspectPipeline(ctx context.Context, request *pps.InspectPipelineRequest) (response *pps.PipelineInfo, retErr error) {
@@ -2171,6 +2497,25 @@ func (a *apiServer) DeletePipeline(ctx context.Context, request *pps.DeletePipel
 		return nil, err
 	}
 
+	if dryrun.IsDryRun(ctx) {
+		if !request.All {
+			changes, err := a.deletePipelineDryRun(pachClient, request)
+			return reportDryRun(ctx, changes, err)
+		}
+		var changes []string
+		request.Pipeline = &pps.Pipeline{}
+		pipelinePtr := &pps.EtcdPipelineInfo{}
+		if err := a.pipelines.ReadOnly(ctx).List(pipelinePtr, col.DefaultOptions, func(pipelineName string) error {
+			request.Pipeline.Name = pipelineName
+			pipelineChanges, err := a.deletePipelineDryRun(pachClient, request)
+			changes = append(changes, pipelineChanges...)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		return reportDryRun(ctx, changes, nil)
+	}
+
 	// Possibly list pipelines in etcd (skip PFS read--don't need it) and delete them
 	if request.All {
 		request.Pipeline = &pps.Pipeline{}
@@ -2553,113 +2898,147 @@ func (a *apiServer) GarbageCollect(ctx context.Context, request *pps.GarbageColl
 	if err := checkLoggedIn(pachClient); err != nil {
 		return nil, err
 	}
-	pipelineInfos, err := a.ListPipeline(ctx, &pps.ListPipelineRequest{})
-	if err != nil {
+	if err := a.startGC(ctx, false); err != nil {
+		return nil, err
+	}
+	if err := a.runGC(pachClient, request.MemoryBytes); err != nil {
 		return nil, err
 	}
+	return &pps.GarbageCollectResponse{}, nil
+}
 
-	for _, pi := range pipelineInfos.PipelineInfo {
-		if pi.State != pps.PipelineState_PIPELINE_PAUSED {
-			return nil, fmt.Errorf("all pipelines must be stopped to run garbage collection, pipeline: %s is not", pi.Pipeline.Name)
-		}
-		selector := fmt.Sprintf("pipelineName=%s", pi.Pipeline.Name)
-		pods, err := a.kubeClient.CoreV1().Pods(a.namespace).List(metav1.ListOptions{LabelSelector: selector})
-		if err != nil {
-			return nil, err
-		}
-		if len(pods.Items) != 0 {
-			return nil, fmt.Errorf("pipeline %s is paused, but still has running workers, this should resolve itself, if it doesn't you can manually delete them with kubectl delete", pi.Pipeline.Name)
+func (a *apiServer) StartGarbageCollect(ctx context.Context, request *pps.GarbageCollectRequest) (response *pps.GarbageCollectStatus, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	pachClient := a.getPachClient().WithCtx(ctx)
+	if err := checkLoggedIn(pachClient); err != nil {
+		return nil, err
+	}
+	// Check that the pipelines are stopped now, so that the caller sees the
+	// error (runGC checks again, in case one starts in the meantime)
+	if err := a.checkPipelinesStopped(pachClient); err != nil {
+		return nil, err
+	}
+	if err := a.startGC(ctx, false); err != nil {
+		return nil, err
+	}
+	// The collection outlives this request, so it runs with its own context,
+	// as the PPS superuser
+	go a.sudo(a.getPachClient().WithCtx(context.Background()), func(superUserClient *client.APIClient) error {
+		if err := a.runGC(superUserClient, request.MemoryBytes); err != nil {
+			logrus.Errorf("error running garbage collection: %v", err)
 		}
+		return nil
+	})
+	return a.inspectGC(ctx)
+}
+
+func (a *apiServer) InspectGarbageCollect(ctx context.Context, request *types.Empty) (response *pps.GarbageCollectStatus, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	return a.inspectGC(ctx)
+}
+
+func (a *apiServer) PauseGarbageCollect(ctx context.Context, request *types.Empty) (response *pps.GarbageCollectStatus, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if err := checkLoggedIn(a.getPachClient().WithCtx(ctx)); err != nil {
+		return nil, err
 	}
-	ctx = pachClient.Ctx() // pachClient will propagate auth info
-	pfsClient := pachClient.PfsAPIClient
-	objClient := pachClient.ObjectAPIClient
+	return a.setGCState(ctx, pps.GarbageCollectState_GC_RUNNING, pps.GarbageCollectState_GC_PAUSED)
+}
 
-	// Get all repos
-	repoInfos, err := pfsClient.ListRepo(ctx, &pfs.ListRepoRequest{})
-	if err != nil {
+func (a *apiServer) ResumeGarbageCollect(ctx context.Context, request *types.Empty) (response *pps.GarbageCollectStatus, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if err := checkLoggedIn(a.getPachClient().WithCtx(ctx)); err != nil {
 		return nil, err
 	}
-	specRepoInfo, err := pachClient.InspectRepo(ppsconsts.SpecRepo)
-	if err != nil {
+	return a.setGCState(ctx, pps.GarbageCollectState_GC_PAUSED, pps.GarbageCollectState_GC_RUNNING)
+}
+
+func (a *apiServer) SetGarbageCollectSchedule(ctx context.Context, request *pps.SetGarbageCollectScheduleRequest) (response *types.Empty, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if err := checkLoggedIn(a.getPachClient().WithCtx(ctx)); err != nil {
 		return nil, err
 	}
-	activeStat, err := CollectActiveObjectsAndTags(ctx, pachClient, append(repoInfos.RepoInfo, specRepoInfo), pipelineInfos.PipelineInfo, int(request.MemoryBytes), a.storageRoot)
-	if err != nil {
+	if err := a.setGCSchedule(ctx, request.Schedule); err != nil {
 		return nil, err
 	}
+	return &types.Empty{}, nil
+}
 
-	// Iterate through all objects.  If they are not active, delete them.
-	objects, err := objClient.ListObjects(ctx, &pfs.ListObjectsRequest{})
-	if err != nil {
+func (a *apiServer) CreateReplication(ctx context.Context, request *pps.CreateReplicationRequest) (response *types.Empty, retErr error) {
+	// Don't log the target's auth token
+	logRequest := proto.Clone(request).(*pps.CreateReplicationRequest)
+	if logRequest.Target != nil {
+		logRequest.Target.AuthToken = ""
+	}
+	func() { a.Log(logRequest, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(logRequest, response, retErr, time.Since(start)) }(time.Now())
+	if err := checkAdmin(a.getPachClient().WithCtx(ctx), "CreateReplication"); err != nil {
 		return nil, err
 	}
+	if err := a.createReplication(ctx, request); err != nil {
+		return nil, err
+	}
+	return &types.Empty{}, nil
+}
 
-	var objectsToDelete []*pfs.Object
-	deleteObjectsIfMoreThan := func(n int) error {
-		if len(objectsToDelete) > n {
-			if _, err := objClient.DeleteObjects(ctx, &pfs.DeleteObjectsRequest{
-				Objects: objectsToDelete,
-			}); err != nil {
-				return fmt.Errorf("error deleting objects: %v", err)
-			}
-			objectsToDelete = []*pfs.Object{}
-		}
-		return nil
+func (a *apiServer) InspectReplication(ctx context.Context, request *pps.InspectReplicationRequest) (response *pps.ReplicationInfo, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if err := checkAdmin(a.getPachClient().WithCtx(ctx), "InspectReplication"); err != nil {
+		return nil, err
 	}
-	for object, err := objects.Recv(); err != io.EOF; object, err = objects.Recv() {
-		if err != nil {
-			return nil, fmt.Errorf("error receiving objects from ListObjects: %v", err)
-		}
-		if !activeStat.Objects.TestString(object.Hash) {
-			objectsToDelete = append(objectsToDelete, object)
-		}
-		// Delete objects in batches
-		if err := deleteObjectsIfMoreThan(100); err != nil {
-			return nil, err
-		}
+	return a.inspectReplication(ctx, request.Replication.GetName())
+}
+
+func (a *apiServer) ListReplication(ctx context.Context, request *pps.ListReplicationRequest) (response *pps.ReplicationInfos, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if err := checkAdmin(a.getPachClient().WithCtx(ctx), "ListReplication"); err != nil {
+		return nil, err
 	}
-	if err := deleteObjectsIfMoreThan(0); err != nil {
+	replicationInfos, err := a.listReplication(ctx, false)
+	if err != nil {
 		return nil, err
 	}
+	return &pps.ReplicationInfos{ReplicationInfo: replicationInfos}, nil
+}
 
-	// Iterate through all tags.  If they are not active, delete them
-	tags, err := objClient.ListTags(ctx, &pfs.ListTagsRequest{})
-	if err != nil {
+func (a *apiServer) DeleteReplication(ctx context.Context, request *pps.DeleteReplicationRequest) (response *types.Empty, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if err := checkAdmin(a.getPachClient().WithCtx(ctx), "DeleteReplication"); err != nil {
 		return nil, err
 	}
-	var tagsToDelete []*pfs.Tag
-	deleteTagsIfMoreThan := func(n int) error {
-		if len(tagsToDelete) > n {
-			if _, err := objClient.DeleteTags(ctx, &pfs.DeleteTagsRequest{
-				Tags: tagsToDelete,
-			}); err != nil {
-				return fmt.Errorf("error deleting tags: %v", err)
-			}
-			tagsToDelete = []*pfs.Tag{}
-		}
-		return nil
+	if err := a.deleteReplication(ctx, request.Replication.GetName()); err != nil {
+		return nil, err
 	}
-	for resp, err := tags.Recv(); err != io.EOF; resp, err = tags.Recv() {
-		if err != nil {
-			return nil, fmt.Errorf("error receiving tags from ListTags: %v", err)
-		}
-		if !activeStat.Tags.TestString(resp.Tag.Name) {
-			tagsToDelete = append(tagsToDelete, resp.Tag)
-		}
-		if err := deleteTagsIfMoreThan(100); err != nil {
-			return nil, err
-		}
+	return &types.Empty{}, nil
+}
+
+func (a *apiServer) SetSchedulerLimits(ctx context.Context, request *pps.SetSchedulerLimitsRequest) (response *types.Empty, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if err := checkAdmin(a.getPachClient().WithCtx(ctx), "SetSchedulerLimits"); err != nil {
+		return nil, err
 	}
-	if err := deleteTagsIfMoreThan(0); err != nil {
+	if err := a.setSchedulerLimits(ctx, request.Limits); err != nil {
 		return nil, err
 	}
+	return &types.Empty{}, nil
+}
 
-	if err := a.incrementGCGeneration(ctx); err != nil {
+func (a *apiServer) InspectScheduler(ctx context.Context, request *types.Empty) (response *pps.SchedulerStatus, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if err := checkLoggedIn(a.getPachClient().WithCtx(ctx)); err != nil {
 		return nil, err
 	}
-
-	return &pps.GarbageCollectResponse{}, nil
+	return a.inspectScheduler(ctx)
 }
 
 func (a *apiServer) ActivateAuth(ctx context.Context, req *pps.ActivateAuthRequest) (resp *pps.ActivateAuthResponse, retErr error) {
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+)
+
+func TestValidateStandbyIdleTimeout(t *testing.T) {
+	pipelineInfo := func(standby bool, idleTimeout time.Duration) *pps.PipelineInfo {
+		return &pps.PipelineInfo{
+			Transform:          &pps.Transform{},
+			OutputBranch:       "master",
+			CacheSize:          "64M",
+			Standby:            standby,
+			StandbyIdleTimeout: types.DurationProto(idleTimeout),
+		}
+	}
+	require.NoError(t, validatePipelineSettings(pipelineInfo(true, time.Minute)))
+	require.NoError(t, validatePipelineSettings(pipelineInfo(true, 0)))
+	require.NoError(t, validatePipelineSettings(&pps.PipelineInfo{Transform: &pps.Transform{}, OutputBranch: "master", CacheSize: "64M"}))
+
+	err := validatePipelineSettings(pipelineInfo(false, time.Minute))
+	require.YesError(t, err)
+	require.Matches(t, "only be set if Standby is true", err.Error())
+	err = validatePipelineSettings(pipelineInfo(true, -time.Minute))
+	require.YesError(t, err)
+	require.Matches(t, "must be >= 0", err.Error())
+}
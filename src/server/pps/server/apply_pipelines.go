@@ -0,0 +1,199 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"golang.org/x/net/context"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pkg/dryrun"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+	"github.com/pachyderm/pachyderm/src/server/pkg/metrics"
+)
+
+// ApplyPipelines implements the protobuf pps.ApplyPipelines RPC
+func (a *apiServer) ApplyPipelines(ctx context.Context, request *pps.ApplyPipelinesRequest) (response *pps.ApplyPipelinesResponse, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	metricsFn := metrics.ReportUserAction(ctx, a.reporter, "ApplyPipelines")
+	defer func(start time.Time) { metricsFn(start, retErr) }(time.Now())
+	pachClient := a.getPachClient().WithCtx(ctx)
+	if err := checkLoggedIn(pachClient); err != nil {
+		return nil, err
+	}
+	pipelineInfos, err := pachClient.ListPipeline()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := pps.PlanApplyPipelines(pipelineInfos, request, pipelineUnchanged)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.validatePipelineChanges(pachClient, request, changes); err != nil {
+		return nil, err
+	}
+	response = &pps.ApplyPipelinesResponse{Changes: changes}
+	if dryrun.IsDryRun(ctx) {
+		var descriptions []string
+		for _, change := range changes {
+			if change.Type != pps.PipelineChangeType_PIPELINE_UNCHANGED {
+				descriptions = append(descriptions, describePipelineChange(change))
+			}
+		}
+		if err := dryrun.Report(ctx, descriptions...); err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+	if err := a.applyPipelineChanges(ctx, request, pipelineInfos, changes); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// pipelineUnchanged returns true if the pipeline 'pipelineInfo' already
+// matches 'spec', i.e. if updating it to 'spec' would only change its version
+func pipelineUnchanged(spec *pps.CreatePipelineRequest, pipelineInfo *pps.PipelineInfo) bool {
+	if spec.Input == nil {
+		return false // the update will fail, and report why
+	}
+	// Cron inputs without a start time start when they're created, so they
+	// are compared as if they started when the existing pipeline's inputs did
+	cronStarts := make(map[string]*types.Timestamp)
+	if pipelineInfo.Input != nil {
+		pps.VisitInput(pipelineInfo.Input, func(input *pps.Input) {
+			if input.Cron != nil {
+				cronStarts[input.Cron.Name] = input.Cron.Start
+			}
+		})
+	}
+	spec = proto.Clone(spec).(*pps.CreatePipelineRequest)
+	pps.VisitInput(spec.Input, func(input *pps.Input) {
+		if input.Cron != nil && input.Cron.Start == nil {
+			input.Cron.Start = cronStarts[input.Cron.Name]
+		}
+	})
+	specInfo := defaultedPipelineInfo(spec)
+	pps.SortInput(specInfo.Input)
+	if spec.Salt == "" {
+		specInfo.Salt = pipelineInfo.Salt
+	}
+	return proto.Equal(pps.PipelineReqFromInfo(specInfo), pps.PipelineReqFromInfo(pipelineInfo))
+}
+
+// describePipelineChange describes 'change', e.g. "create pipeline edges"
+func describePipelineChange(change *pps.PipelineChange) string {
+	verb := strings.TrimPrefix(strings.ToLower(change.Type.String()), "pipeline_")
+	return fmt.Sprintf("%s pipeline %s", verb, change.Pipeline.Name)
+}
+
+// validatePipelineChanges returns an error if any of the pipelines that
+// 'changes' (planned by PlanApplyPipelines) create or update has a spec in
+// 'request' that CreatePipeline would reject, so that no change is made
+// unless all of them can be. Inputs may read from pipelines that are created
+// by earlier changes, whose output repos don't exist yet.
+func (a *apiServer) validatePipelineChanges(pachClient *client.APIClient, request *pps.ApplyPipelinesRequest, changes []*pps.PipelineChange) error {
+	specs := make(map[string]*pps.CreatePipelineRequest)
+	for _, spec := range request.Pipelines {
+		specs[spec.Pipeline.Name] = spec
+	}
+	created := make(map[string]bool)
+	for _, change := range changes {
+		if change.Type == pps.PipelineChangeType_PIPELINE_CREATE {
+			created[change.Pipeline.Name] = true
+		}
+	}
+	for _, change := range changes {
+		if change.Type != pps.PipelineChangeType_PIPELINE_CREATE && change.Type != pps.PipelineChangeType_PIPELINE_UPDATE {
+			continue
+		}
+		var errs []string
+		for _, issue := range a.validatePipelineRequest(pachClient, specs[change.Pipeline.Name], created) {
+			if issue.Severity == pps.IssueSeverity_ISSUE_ERROR {
+				errs = append(errs, issue.Message)
+			}
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("pipeline %s is invalid: %s", change.Pipeline.Name, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// applyPipelineChanges makes 'changes' (planned by PlanApplyPipelines) in
+// order. Pipelines are only deleted once every pipeline has been created or
+// updated; if creating or updating one fails, the changes already made are
+// undone. Deleted pipelines' output repos are gone, so if deleting a
+// pipeline fails, the pipelines already deleted aren't recreated, and the
+// error says which they are.
+func (a *apiServer) applyPipelineChanges(ctx context.Context, request *pps.ApplyPipelinesRequest, pipelineInfos []*pps.PipelineInfo, changes []*pps.PipelineChange) error {
+	specs := make(map[string]*pps.CreatePipelineRequest)
+	for _, spec := range request.Pipelines {
+		specs[spec.Pipeline.Name] = spec
+	}
+	previous := make(map[string]*pps.PipelineInfo)
+	for _, pipelineInfo := range pipelineInfos {
+		previous[pipelineInfo.Pipeline.Name] = pipelineInfo
+	}
+	var applied, deletes []*pps.PipelineChange
+	for _, change := range changes {
+		switch change.Type {
+		case pps.PipelineChangeType_PIPELINE_CREATE, pps.PipelineChangeType_PIPELINE_UPDATE:
+			spec := proto.Clone(specs[change.Pipeline.Name]).(*pps.CreatePipelineRequest)
+			spec.Update = change.Type == pps.PipelineChangeType_PIPELINE_UPDATE
+			spec.Reprocess = spec.Update && (spec.Reprocess || request.Reprocess)
+			if _, err := a.CreatePipeline(ctx, spec); err != nil {
+				err = fmt.Errorf("could not %s: %v", describePipelineChange(change), err)
+				if undoErr := a.undoPipelineChanges(ctx, applied, previous); undoErr != nil {
+					err = fmt.Errorf("%v (undoing the changes already made failed: %v)", err, undoErr)
+				}
+				return err
+			}
+			applied = append(applied, change)
+		case pps.PipelineChangeType_PIPELINE_DELETE:
+			deletes = append(deletes, change)
+		}
+	}
+	var deleted []string
+	for _, change := range deletes {
+		if _, err := a.DeletePipeline(ctx, &pps.DeletePipelineRequest{Pipeline: change.Pipeline}); err != nil {
+			err = fmt.Errorf("could not %s: %v", describePipelineChange(change), err)
+			if len(deleted) > 0 {
+				err = fmt.Errorf("%v (pipelines %s were already deleted)", err, strings.Join(deleted, ", "))
+			}
+			return err
+		}
+		deleted = append(deleted, change.Pipeline.Name)
+	}
+	return nil
+}
+
+// undoPipelineChanges undoes 'changes' (creations and updates, which were
+// made in order) in reverse. 'previous' are the pipelines as they were before
+// the changes were made.
+func (a *apiServer) undoPipelineChanges(ctx context.Context, changes []*pps.PipelineChange, previous map[string]*pps.PipelineInfo) error {
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		var err error
+		switch change.Type {
+		case pps.PipelineChangeType_PIPELINE_CREATE:
+			_, err = a.DeletePipeline(ctx, &pps.DeletePipelineRequest{Pipeline: change.Pipeline})
+		case pps.PipelineChangeType_PIPELINE_UPDATE:
+			spec := pps.PipelineReqFromInfo(previous[change.Pipeline.Name])
+			spec.Update = true
+			// Reprocess restores the previous salt (which the spec has), so the
+			// datums that the pipeline processed before the update aren't
+			// reprocessed
+			spec.Reprocess = true
+			_, err = a.CreatePipeline(ctx, spec)
+		}
+		if err != nil {
+			return fmt.Errorf("could not undo %s: %v", describePipelineChange(change), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/dryrun"
+	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
+	"github.com/pachyderm/pachyderm/src/server/pkg/ppsconsts"
+	"github.com/pachyderm/pachyderm/src/server/pkg/ppsdb"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// reportDryRun reports 'changes' for the dry run whose context is 'ctx' (see
+// client/pkg/dryrun), unless 'err' is set
+func reportDryRun(ctx context.Context, changes []string, err error) (*types.Empty, error) {
+	if err != nil {
+		return nil, err
+	}
+	if err := dryrun.Report(ctx, changes...); err != nil {
+		return nil, err
+	}
+	return &types.Empty{}, nil
+}
+
+// createPipelineDryRun validates 'request' (after the checks in
+// CreatePipeline that precede it) the way CreatePipeline does, and returns
+// the changes that CreatePipeline would make
+func (a *apiServer) createPipelineDryRun(pachClient *client.APIClient, request *pps.CreatePipelineRequest, pipelineInfo *pps.PipelineInfo) ([]string, error) {
+	var changes []string
+	var visitErr error
+	pps.VisitInput(pipelineInfo.Input, func(input *pps.Input) {
+		var repo string
+		if input.Cron != nil {
+			repo = input.Cron.Repo
+		}
+		if input.Git != nil {
+			repo = input.Git.Name
+		}
+		if repo == "" || visitErr != nil {
+			return
+		}
+		if _, err := pachClient.InspectRepo(repo); err != nil {
+			if !isNotFoundErr(err) {
+				visitErr = err
+				return
+			}
+			changes = append(changes, fmt.Sprintf("create repo %s", repo))
+		}
+	})
+	if visitErr != nil {
+		return nil, visitErr
+	}
+
+	operation := pipelineOpCreate
+	if request.Update {
+		operation = pipelineOpUpdate
+	}
+	if err := a.authorizePipelineOp(pachClient, operation, pipelineInfo.Input, pipelineInfo.Pipeline.Name); err != nil {
+		return nil, err
+	}
+	pipelineName := pipelineInfo.Pipeline.Name
+	outputBranch := fmt.Sprintf("%s@%s", pipelineName, pipelineInfo.OutputBranch)
+	if request.Update {
+		oldPipelineInfo, err := a.inspectPipeline(pachClient, pipelineName)
+		if err != nil {
+			return nil, err
+		}
+		if ci, err := pachClient.InspectCommit(ppsconsts.SpecRepo, pipelineName); err != nil {
+			return nil, err
+		} else if ci.Finished == nil {
+			return nil, fmt.Errorf("the HEAD commit of this pipeline's spec branch " +
+				"is open. Either another CreatePipeline call is running or a previous " +
+				"call crashed. If you're sure no other CreatePipeline commands are " +
+				"running, you can run 'pachctl update-pipeline --clean' which will " +
+				"delete this open commit")
+		}
+		changes = append(changes,
+			fmt.Sprintf("update pipeline %s to version %d", pipelineName, oldPipelineInfo.Version+1),
+			fmt.Sprintf("update the provenance of branch %s", outputBranch))
+		if request.Reprocess {
+			changes = append(changes, fmt.Sprintf("reprocess all of the input data of pipeline %s", pipelineName))
+		}
+	} else {
+		pipelinePtr := &pps.EtcdPipelineInfo{}
+		if err := a.pipelines.ReadOnly(pachClient.Ctx()).Get(pipelineName, pipelinePtr); err == nil {
+			return nil, newErrPipelineExists(pipelineName)
+		} else if !col.IsErrNotFound(err) {
+			return nil, err
+		}
+		if _, err := pachClient.InspectRepo(pipelineName); err != nil {
+			if !isNotFoundErr(err) {
+				return nil, err
+			}
+			changes = append(changes, fmt.Sprintf("create repo %s", pipelineName))
+		}
+		changes = append(changes,
+			fmt.Sprintf("create pipeline %s", pipelineName),
+			fmt.Sprintf("create branch %s", outputBranch))
+	}
+	if pipelineInfo.EnableStats {
+		changes = append(changes, fmt.Sprintf("create or update branch %s@stats", pipelineName))
+	}
+	return changes, nil
+}
+
+// deletePipelineDryRun validates 'request' the way deletePipeline does, and
+// returns the changes that deletePipeline would make
+func (a *apiServer) deletePipelineDryRun(pachClient *client.APIClient, request *pps.DeletePipelineRequest) ([]string, error) {
+	ctx := pachClient.Ctx() // pachClient will propagate auth info
+	pipelineName := request.Pipeline.Name
+	pipelinePtr := pps.EtcdPipelineInfo{}
+	if err := a.pipelines.ReadOnly(ctx).Get(pipelineName, &pipelinePtr); err != nil {
+		if col.IsErrNotFound(err) {
+			specBranchInfo, err := pachClient.InspectBranch(ppsconsts.SpecRepo, pipelineName)
+			if err == nil && specBranchInfo.Head == nil {
+				return []string{fmt.Sprintf("delete the spec branch of partially-created pipeline %s", pipelineName)}, nil
+			}
+			return nil, fmt.Errorf("pipeline %v was not found: %v", pipelineName, err)
+		}
+		return nil, err
+	}
+	pipelineInfo, err := a.inspectPipeline(pachClient, pipelineName)
+	if err != nil {
+		pipelineInfo = &pps.PipelineInfo{Pipeline: request.Pipeline, OutputBranch: "master"}
+	}
+	if err := a.authorizePipelineOp(pachClient, pipelineOpDelete, pipelineInfo.Input, pipelineName); err != nil {
+		return nil, err
+	}
+
+	// 'ctx' carries this RPC's dry run metadata, so PFS validates deleting
+	// the output repo without deleting it
+	var header metadata.MD
+	if _, err := pachClient.PfsAPIClient.DeleteRepo(ctx, &pfs.DeleteRepoRequest{
+		Repo:  client.NewRepo(pipelineName),
+		Force: request.Force,
+	}, grpc.Header(&header)); err != nil {
+		return nil, grpcutil.ScrubGRPC(err)
+	}
+	if len(header[dryrun.MetadataKey]) == 0 {
+		return nil, fmt.Errorf("internal error: PFS did not perform a dry run of deleting repo %s (this is likely a bug)", pipelineName)
+	}
+	changes := header[dryrun.ChangeKey]
+
+	var jobs int
+	jobPtr := &pps.EtcdJobInfo{}
+	if err := a.jobs.ReadOnly(ctx).GetByIndex(ppsdb.JobsPipelineIndex, request.Pipeline, jobPtr, col.DefaultOptions, func(string) error {
+		jobs++
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if jobs > 0 {
+		changes = append(changes, fmt.Sprintf("delete the %d jobs of pipeline %s", jobs, pipelineName))
+	}
+	return append(changes,
+		fmt.Sprintf("delete the workers of pipeline %s", pipelineName),
+		fmt.Sprintf("delete pipeline %s", pipelineName)), nil
+}
@@ -0,0 +1,436 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/robfig/cron"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
+	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
+	"github.com/pachyderm/pachyderm/src/server/pkg/ppsconsts"
+)
+
+// The functions in this file run garbage collection, which deletes the
+// objects and tags that no commit or pipeline uses. The progress of a
+// collection is stored in etcd (see pps.GarbageCollectStatus), so that any
+// pachd can report, pause or resume it, and so that only one collection runs
+// at a time. The PPS master also starts collections on the schedule set by
+// SetGarbageCollectSchedule.
+
+const (
+	// gcStatusKey is the key of the status in a.gcStatus
+	gcStatusKey = "status"
+
+	// gcBatchSize is the number of objects or tags deleted at once. A
+	// collection reports its progress, and may be paused, after each batch.
+	gcBatchSize = 100
+
+	// gcHeartbeatPeriod is how often a collection reports that it's alive,
+	// and gcStaleTimeout is how long a collection may go without doing so
+	// before it's assumed to have been interrupted
+	gcHeartbeatPeriod = time.Minute
+	gcStaleTimeout    = 5 * time.Minute
+
+	// gcPausePollPeriod is how often a paused collection checks whether it's
+	// been resumed
+	gcPausePollPeriod = time.Second
+
+	// gcSchedulePeriod is how often the PPS master checks whether a scheduled
+	// collection is due
+	gcSchedulePeriod = time.Minute
+)
+
+// isGCActive returns true if 'status' describes a collection that's running
+// (or paused), and hasn't been interrupted
+func isGCActive(status *pps.GarbageCollectStatus) bool {
+	if status.State != pps.GarbageCollectState_GC_RUNNING && status.State != pps.GarbageCollectState_GC_PAUSED {
+		return false
+	}
+	updated, err := types.TimestampFromProto(status.Updated)
+	if err != nil {
+		return false
+	}
+	return time.Since(updated) < gcStaleTimeout
+}
+
+// inspectGC returns the status of garbage collection. If no collection is
+// running, but the status says that one is, it was interrupted, and is
+// reported as failed.
+func (a *apiServer) inspectGC(ctx context.Context) (*pps.GarbageCollectStatus, error) {
+	status := &pps.GarbageCollectStatus{}
+	if err := a.gcStatus.ReadOnly(ctx).Get(gcStatusKey, status); err != nil && !col.IsErrNotFound(err) {
+		return nil, err
+	}
+	if (status.State == pps.GarbageCollectState_GC_RUNNING || status.State == pps.GarbageCollectState_GC_PAUSED) && !isGCActive(status) {
+		status.State = pps.GarbageCollectState_GC_FAILURE
+		status.Reason = "garbage collection was interrupted"
+	}
+	return status, nil
+}
+
+// updateGCStatus calls 'f' on the status of garbage collection, and stores
+// the result
+func (a *apiServer) updateGCStatus(ctx context.Context, f func(status *pps.GarbageCollectStatus) error) (*pps.GarbageCollectStatus, error) {
+	status := &pps.GarbageCollectStatus{}
+	if _, err := col.NewSTM(ctx, a.etcdClient, func(stm col.STM) error {
+		status.Reset()
+		gcStatus := a.gcStatus.ReadWrite(stm)
+		if err := gcStatus.Get(gcStatusKey, status); err != nil && !col.IsErrNotFound(err) {
+			return err
+		}
+		if err := f(status); err != nil {
+			return err
+		}
+		return gcStatus.Put(gcStatusKey, status)
+	}); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// startGC records that a collection has started, or returns an error if one
+// is already running
+func (a *apiServer) startGC(ctx context.Context, scheduled bool) error {
+	_, err := a.updateGCStatus(ctx, func(status *pps.GarbageCollectStatus) error {
+		if isGCActive(status) {
+			return fmt.Errorf("garbage collection is already running")
+		}
+		*status = pps.GarbageCollectStatus{
+			State:     pps.GarbageCollectState_GC_RUNNING,
+			Scheduled: scheduled,
+			Started:   now(),
+			Updated:   now(),
+			Schedule:  status.Schedule,
+		}
+		return nil
+	})
+	return err
+}
+
+// setGCState moves the running collection from state 'from' to state 'to'
+// (i.e. pauses or resumes it)
+func (a *apiServer) setGCState(ctx context.Context, from, to pps.GarbageCollectState) (*pps.GarbageCollectStatus, error) {
+	return a.updateGCStatus(ctx, func(status *pps.GarbageCollectStatus) error {
+		if !isGCActive(status) {
+			return fmt.Errorf("garbage collection is not running")
+		}
+		if status.State != from {
+			return fmt.Errorf("garbage collection is %s, not %s", gcStateName(status.State), gcStateName(from))
+		}
+		status.State = to
+		return nil
+	})
+}
+
+// gcStateName returns a human-readable name for 'state'
+func gcStateName(state pps.GarbageCollectState) string {
+	switch state {
+	case pps.GarbageCollectState_GC_RUNNING:
+		return "running"
+	case pps.GarbageCollectState_GC_PAUSED:
+		return "paused"
+	default:
+		return "not running"
+	}
+}
+
+// setGCSchedule replaces the garbage collection schedule
+func (a *apiServer) setGCSchedule(ctx context.Context, schedule *pps.GarbageCollectSchedule) error {
+	if schedule != nil {
+		cronSchedule, err := cron.ParseStandard(schedule.CronSpec)
+		if err != nil {
+			return fmt.Errorf("invalid cron spec %q: %v", schedule.CronSpec, err)
+		}
+		if schedule.Next, err = types.TimestampProto(cronSchedule.Next(time.Now())); err != nil {
+			return err
+		}
+	}
+	_, err := a.updateGCStatus(ctx, func(status *pps.GarbageCollectStatus) error {
+		status.Schedule = schedule
+		return nil
+	})
+	return err
+}
+
+// checkPipelinesStopped returns an error unless every pipeline is stopped,
+// and has no workers, as garbage collection would delete the objects that
+// running jobs are writing
+func (a *apiServer) checkPipelinesStopped(pachClient *client.APIClient) error {
+	pipelineInfos, err := a.ListPipeline(pachClient.Ctx(), &pps.ListPipelineRequest{})
+	if err != nil {
+		return err
+	}
+	for _, pi := range pipelineInfos.PipelineInfo {
+		if pi.State != pps.PipelineState_PIPELINE_PAUSED {
+			return fmt.Errorf("all pipelines must be stopped to run garbage collection, pipeline: %s is not", pi.Pipeline.Name)
+		}
+		selector := fmt.Sprintf("pipelineName=%s", pi.Pipeline.Name)
+		pods, err := a.kubeClient.CoreV1().Pods(a.namespace).List(metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return err
+		}
+		if len(pods.Items) != 0 {
+			return fmt.Errorf("pipeline %s is paused, but still has running workers, this should resolve itself, if it doesn't you can manually delete them with kubectl delete", pi.Pipeline.Name)
+		}
+	}
+	return nil
+}
+
+// runGC runs the collection that startGC recorded, and records its result
+func (a *apiServer) runGC(pachClient *client.APIClient, memoryBytes int64) (retErr error) {
+	ctx, cancel := context.WithCancel(pachClient.Ctx())
+	defer cancel()
+	pachClient = pachClient.WithCtx(ctx)
+	defer func() {
+		// Record the result even if 'ctx' was cancelled
+		if _, err := a.updateGCStatus(context.Background(), func(status *pps.GarbageCollectStatus) error {
+			status.State = pps.GarbageCollectState_GC_SUCCESS
+			status.Reason = ""
+			if retErr != nil {
+				status.State = pps.GarbageCollectState_GC_FAILURE
+				status.Reason = retErr.Error()
+			}
+			status.Finished = now()
+			status.Updated = now()
+			return nil
+		}); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	go a.gcHeartbeat(ctx)
+
+	if err := a.checkPipelinesStopped(pachClient); err != nil {
+		return err
+	}
+	pipelineInfos, err := a.ListPipeline(ctx, &pps.ListPipelineRequest{})
+	if err != nil {
+		return err
+	}
+	repoInfos, err := pachClient.PfsAPIClient.ListRepo(ctx, &pfs.ListRepoRequest{})
+	if err != nil {
+		return err
+	}
+	specRepoInfo, err := pachClient.InspectRepo(ppsconsts.SpecRepo)
+	if err != nil {
+		return err
+	}
+	activeStat, err := CollectActiveObjectsAndTags(ctx, pachClient, append(repoInfos.RepoInfo, specRepoInfo), pipelineInfos.PipelineInfo, int(memoryBytes), a.storageRoot)
+	if err != nil {
+		return err
+	}
+
+	// 'progress' holds the counts that are reported after each batch
+	progress := &pps.GarbageCollectStatus{}
+	report := func() error {
+		return a.reportGCProgress(ctx, progress)
+	}
+
+	// Iterate through all objects.  If they are not active, delete them.
+	objClient := pachClient.ObjectAPIClient
+	objects, err := objClient.ListObjects(ctx, &pfs.ListObjectsRequest{})
+	if err != nil {
+		return err
+	}
+	var objectsToDelete []*pfs.Object
+	var bytesToDelete uint64
+	deleteObjectsIfMoreThan := func(n int) error {
+		if len(objectsToDelete) > n {
+			if _, err := objClient.DeleteObjects(ctx, &pfs.DeleteObjectsRequest{
+				Objects: objectsToDelete,
+			}); err != nil {
+				return fmt.Errorf("error deleting objects: %v", err)
+			}
+			progress.ObjectsDeleted += uint64(len(objectsToDelete))
+			progress.BytesReclaimed += bytesToDelete
+			objectsToDelete = []*pfs.Object{}
+			bytesToDelete = 0
+			return report()
+		}
+		return nil
+	}
+	for object, err := objects.Recv(); err != io.EOF; object, err = objects.Recv() {
+		if err != nil {
+			return fmt.Errorf("error receiving objects from ListObjects: %v", err)
+		}
+		progress.ObjectsScanned++
+		if !activeStat.Objects.TestString(object.Hash) {
+			objectInfo, err := pachClient.InspectObject(object.Hash)
+			if err != nil {
+				return fmt.Errorf("error inspecting object %s: %v", object.Hash, err)
+			}
+			bytesToDelete += pfsserver.ByteRangeSize(objectInfo.BlockRef.Range)
+			objectsToDelete = append(objectsToDelete, object)
+		}
+		// Delete objects in batches
+		if err := deleteObjectsIfMoreThan(gcBatchSize); err != nil {
+			return err
+		}
+	}
+	if err := deleteObjectsIfMoreThan(0); err != nil {
+		return err
+	}
+	if err := report(); err != nil {
+		return err
+	}
+
+	// Iterate through all tags.  If they are not active, delete them
+	tags, err := objClient.ListTags(ctx, &pfs.ListTagsRequest{})
+	if err != nil {
+		return err
+	}
+	var tagsToDelete []*pfs.Tag
+	deleteTagsIfMoreThan := func(n int) error {
+		if len(tagsToDelete) > n {
+			if _, err := objClient.DeleteTags(ctx, &pfs.DeleteTagsRequest{
+				Tags: tagsToDelete,
+			}); err != nil {
+				return fmt.Errorf("error deleting tags: %v", err)
+			}
+			progress.TagsDeleted += uint64(len(tagsToDelete))
+			tagsToDelete = []*pfs.Tag{}
+			return report()
+		}
+		return nil
+	}
+	for resp, err := tags.Recv(); err != io.EOF; resp, err = tags.Recv() {
+		if err != nil {
+			return fmt.Errorf("error receiving tags from ListTags: %v", err)
+		}
+		progress.TagsScanned++
+		if !activeStat.Tags.TestString(resp.Tag.Name) {
+			tagsToDelete = append(tagsToDelete, resp.Tag)
+		}
+		if err := deleteTagsIfMoreThan(gcBatchSize); err != nil {
+			return err
+		}
+	}
+	if err := deleteTagsIfMoreThan(0); err != nil {
+		return err
+	}
+	if err := report(); err != nil {
+		return err
+	}
+
+	return a.incrementGCGeneration(ctx)
+}
+
+// reportGCProgress records the counts in 'progress' in the status of the
+// running collection. If the collection has been paused, it waits until it's
+// resumed.
+func (a *apiServer) reportGCProgress(ctx context.Context, progress *pps.GarbageCollectStatus) error {
+	for {
+		status, err := a.updateGCStatus(ctx, func(status *pps.GarbageCollectStatus) error {
+			status.ObjectsScanned = progress.ObjectsScanned
+			status.ObjectsDeleted = progress.ObjectsDeleted
+			status.TagsScanned = progress.TagsScanned
+			status.TagsDeleted = progress.TagsDeleted
+			status.BytesReclaimed = progress.BytesReclaimed
+			status.Updated = now()
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if status.State != pps.GarbageCollectState_GC_PAUSED {
+			return nil
+		}
+		select {
+		case <-time.After(gcPausePollPeriod):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// gcHeartbeat reports that the running collection is alive every
+// gcHeartbeatPeriod, until 'ctx' is cancelled
+func (a *apiServer) gcHeartbeat(ctx context.Context) {
+	ticker := time.NewTicker(gcHeartbeatPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		if _, err := a.updateGCStatus(ctx, func(status *pps.GarbageCollectStatus) error {
+			status.Updated = now()
+			return nil
+		}); err != nil && ctx.Err() == nil {
+			log.Errorf("error updating garbage collection status: %v", err)
+		}
+	}
+}
+
+// scheduleGC runs garbage collection on the schedule set by
+// SetGarbageCollectSchedule, checking whether a collection is due every
+// gcSchedulePeriod until pachClient's context is cancelled. It's run by the
+// PPS master, so that only one pachd starts each scheduled collection.
+// 'pachClient' must be a superuser client.
+func (a *apiServer) scheduleGC(pachClient *client.APIClient) {
+	ticker := time.NewTicker(gcSchedulePeriod)
+	defer ticker.Stop()
+	for {
+		if err := a.runScheduledGC(pachClient); err != nil {
+			log.Errorf("PPS master: error running scheduled garbage collection: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-pachClient.Ctx().Done():
+			return
+		}
+	}
+}
+
+// runScheduledGC runs garbage collection if a scheduled collection is due
+func (a *apiServer) runScheduledGC(pachClient *client.APIClient) error {
+	ctx := pachClient.Ctx()
+	var due bool
+	if _, err := a.updateGCStatus(ctx, func(status *pps.GarbageCollectStatus) error {
+		due = false
+		if status.Schedule == nil || status.Schedule.Next == nil {
+			return nil
+		}
+		next, err := types.TimestampFromProto(status.Schedule.Next)
+		if err != nil {
+			return err
+		}
+		if time.Now().Before(next) {
+			return nil
+		}
+		// Schedule the next collection now, so that a collection that fails
+		// isn't retried until then
+		cronSchedule, err := cron.ParseStandard(status.Schedule.CronSpec)
+		if err != nil {
+			return err
+		}
+		if status.Schedule.Next, err = types.TimestampProto(cronSchedule.Next(time.Now())); err != nil {
+			return err
+		}
+		due = true
+		return nil
+	}); err != nil {
+		return err
+	}
+	if !due {
+		return nil
+	}
+	status, err := a.inspectGC(ctx)
+	if err != nil {
+		return err
+	}
+	log.Infof("PPS master: starting scheduled garbage collection")
+	if err := a.startGC(ctx, true); err != nil {
+		return err
+	}
+	return a.runGC(pachClient, status.Schedule.MemoryBytes)
+}
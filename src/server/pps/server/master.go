@@ -27,12 +27,17 @@ import (
 	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
 	"github.com/pachyderm/pachyderm/src/server/pkg/deploy/assets"
 	"github.com/pachyderm/pachyderm/src/server/pkg/dlock"
+	"github.com/pachyderm/pachyderm/src/server/pkg/ppsdb"
 	"github.com/pachyderm/pachyderm/src/server/pkg/ppsutil"
 	"github.com/pachyderm/pachyderm/src/server/pkg/watch"
 )
 
 const (
 	masterLockPath = "_master_lock"
+
+	// autoscalingInterval is how often the workers of autoscaled pipelines
+	// are scaled to match the pipelines' datum backlogs
+	autoscalingInterval = 30 * time.Second
 )
 
 var (
@@ -61,6 +66,31 @@ func (a *apiServer) master() {
 
 		log.Infof("Launching PPS master process")
 
+		// Trim repos' history while this pachd is the master
+		go a.sudo(pachClient.WithCtx(ctx), func(superUserClient *client.APIClient) error {
+			a.enforceRetention(superUserClient)
+			return nil
+		})
+		// Run scheduled garbage collection while this pachd is the master
+		go a.sudo(pachClient.WithCtx(ctx), func(superUserClient *client.APIClient) error {
+			a.scheduleGC(superUserClient)
+			return nil
+		})
+		// Copy new commits to replications' targets while this pachd is the
+		// master
+		go a.sudo(pachClient.WithCtx(ctx), func(superUserClient *client.APIClient) error {
+			a.replicate(superUserClient)
+			return nil
+		})
+		// Record preemptions of workers in their jobs while this pachd is the
+		// master
+		go a.watchPreemptions(pachClient.WithCtx(ctx))
+		// Admit queued jobs while this pachd is the master
+		go a.sudo(pachClient.WithCtx(ctx), func(superUserClient *client.APIClient) error {
+			a.schedule(superUserClient)
+			return nil
+		})
+
 		pipelineWatcher, err := a.pipelines.ReadOnly(ctx).WatchWithPrev()
 		if err != nil {
 			return fmt.Errorf("error creating watch: %+v", err)
@@ -186,7 +216,7 @@ func (a *apiServer) master() {
 						}
 					}
 					if pipelineInfo.State == pps.PipelineState_PIPELINE_RUNNING {
-						if err := a.scaleUpWorkersForPipeline(pipelineInfo); err != nil {
+						if err := a.scaleUpWorkersForPipeline(ctx, pipelineInfo); err != nil {
 							return err
 						}
 					}
@@ -337,6 +367,21 @@ func (a *apiServer) upsertWorkersForPipeline(pipelineInfo *pps.PipelineInfo) err
 			pipelineInfo.SpecCommit.ID,
 			pipelineInfo.SchedulingSpec,
 			pipelineInfo.PodSpec)
+		if pipelineInfo.Priority != 0 {
+			priorityClassName, err := a.upsertPriorityClass(pipelineInfo.Priority)
+			if err != nil {
+				return err
+			}
+			options.schedulingSpec = &pps.SchedulingSpec{
+				NodeSelector:      pipelineInfo.SchedulingSpec.GetNodeSelector(),
+				PriorityClassName: priorityClassName,
+				Tolerations:       pipelineInfo.SchedulingSpec.GetTolerations(),
+			}
+		}
+		options.podPatch = pipelineInfo.PodPatch
+		options.metadata = pipelineInfo.Metadata
+		options.initContainers = pipelineInfo.InitContainers
+		options.sidecars = pipelineInfo.Sidecars
 		// Set the pipeline name env
 		options.workerEnv = append(options.workerEnv, v1.EnvVar{
 			Name:  client.PPSPipelineNameEnv,
@@ -415,7 +460,12 @@ func (a *apiServer) scaleDownWorkersForPipeline(pipelineInfo *pps.PipelineInfo)
 	return err
 }
 
-func (a *apiServer) scaleUpWorkersForPipeline(pipelineInfo *pps.PipelineInfo) error {
+// scaleUpWorkersForPipeline scales up the workers of a running pipeline. If
+// the scheduler's limits are set, and none of the pipeline's jobs have been
+// admitted, only one worker is started: the worker master, which creates the
+// pipeline's jobs and waits for their admission. The scheduler scales the
+// workers up the rest of the way once it admits one of them.
+func (a *apiServer) scaleUpWorkersForPipeline(ctx context.Context, pipelineInfo *pps.PipelineInfo) error {
 	rc := a.kubeClient.CoreV1().ReplicationControllers(a.namespace)
 	workerRc, err := rc.Get(
 		ppsutil.PipelineRcName(pipelineInfo.Pipeline.Name, pipelineInfo.Version),
@@ -423,6 +473,29 @@ func (a *apiServer) scaleUpWorkersForPipeline(pipelineInfo *pps.PipelineInfo) er
 	if err != nil {
 		return err
 	}
+	admitted, err := a.hasAdmittedJob(ctx, pipelineInfo.Pipeline.Name)
+	if err != nil {
+		return err
+	}
+	if !admitted {
+		if *workerRc.Spec.Replicas >= 1 {
+			return nil
+		}
+		*workerRc.Spec.Replicas = 1
+		_, err = rc.Update(workerRc)
+		return err
+	}
+	if autoscaling := pipelineInfo.ParallelismSpec.GetAutoscaling(); autoscaling != nil {
+		// The autoscaler sets the number of workers, so they're only scaled up
+		// to the minimum (e.g. when the pipeline leaves standby)
+		minWorkers := int32(ppsutil.GetAutoscaledNumWorkers(autoscaling, 0, 0))
+		if *workerRc.Spec.Replicas >= minWorkers {
+			return nil
+		}
+		*workerRc.Spec.Replicas = minWorkers
+		_, err = rc.Update(workerRc)
+		return err
+	}
 	parallelism, err := ppsutil.GetExpectedNumWorkers(a.kubeClient, pipelineInfo.ParallelismSpec)
 	if err != nil {
 		log.Errorf("error getting number of workers, default to 1 worker: %v", err)
@@ -433,6 +506,72 @@ func (a *apiServer) scaleUpWorkersForPipeline(pipelineInfo *pps.PipelineInfo) er
 	return err
 }
 
+// autoscaleWorkers periodically scales the workers of the pipeline
+// 'pipelineInfo' to the number that 'autoscaling' calls for, given the datums
+// queued in its unfinished jobs. It's a helper function called by
+// monitorPipeline.
+func (a *apiServer) autoscaleWorkers(pachClient *client.APIClient, pipelineInfo *pps.PipelineInfo, autoscaling *pps.AutoscalingSpec) error {
+	ctx := pachClient.Ctx()
+	rc := a.kubeClient.CoreV1().ReplicationControllers(a.namespace)
+	rcName := ppsutil.PipelineRcName(pipelineInfo.Pipeline.Name, pipelineInfo.Version)
+	for {
+		select {
+		case <-time.After(autoscalingInterval):
+		case <-ctx.Done():
+			return context.DeadlineExceeded
+		}
+		pipelinePtr := &pps.EtcdPipelineInfo{}
+		if err := a.pipelines.ReadOnly(ctx).Get(pipelineInfo.Pipeline.Name, pipelinePtr); err != nil {
+			return err
+		}
+		if pipelinePtr.State != pps.PipelineState_PIPELINE_RUNNING {
+			continue // paused, failed or in standby, so the workers are left alone
+		}
+		if admitted, err := a.hasAdmittedJob(ctx, pipelineInfo.Pipeline.Name); err != nil {
+			return err
+		} else if !admitted {
+			continue // the worker master is waiting for the scheduler
+		}
+		var queued, processed int64
+		var processTime time.Duration
+		jobPtr := &pps.EtcdJobInfo{}
+		if err := a.jobs.ReadOnly(ctx).GetByIndex(ppsdb.JobsPipelineIndex, pipelineInfo.Pipeline, jobPtr, col.DefaultOptions, func(string) error {
+			if !ppsutil.IsTerminal(jobPtr.State) {
+				queued += jobPtr.DataTotal - jobPtr.DataProcessed - jobPtr.DataSkipped - jobPtr.DataFailed
+			}
+			if jobPtr.Stats != nil {
+				for _, d := range []*types.Duration{jobPtr.Stats.DownloadTime, jobPtr.Stats.ProcessTime, jobPtr.Stats.UploadTime} {
+					if duration, err := types.DurationFromProto(d); err == nil {
+						processTime += duration
+					}
+				}
+				processed += jobPtr.DataProcessed
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		var datumDuration time.Duration
+		if processed > 0 {
+			datumDuration = processTime / time.Duration(processed)
+		}
+		workers := int32(ppsutil.GetAutoscaledNumWorkers(autoscaling, queued, datumDuration))
+		workerRc, err := rc.Get(rcName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if *workerRc.Spec.Replicas == workers {
+			continue
+		}
+		log.Infof("autoscaling pipeline %s from %d to %d workers (%d datums queued)",
+			pipelineInfo.Pipeline.Name, *workerRc.Spec.Replicas, workers, queued)
+		*workerRc.Spec.Replicas = workers
+		if _, err := rc.Update(workerRc); err != nil {
+			return err
+		}
+	}
+}
+
 func notifyCtx(ctx context.Context, name string) func(error, time.Duration) error {
 	return func(err error, d time.Duration) error {
 		select {
@@ -473,6 +612,13 @@ func (a *apiServer) monitorPipeline(pachClient *client.APIClient, pipelineInfo *
 			})
 		}
 	})
+	if autoscaling := pipelineInfo.ParallelismSpec.GetAutoscaling(); autoscaling != nil {
+		eg.Go(func() error {
+			return backoff.RetryNotify(func() error {
+				return a.autoscaleWorkers(pachClient, pipelineInfo, autoscaling)
+			}, backoff.NewInfiniteBackOff(), notifyCtx(pachClient.Ctx(), "autoscaling workers"))
+		})
+	}
 	if !pipelineInfo.Standby {
 		// Standby is false so simply put it in RUNNING and leave it there.  This is
 		// only done with eg.Go so that we can handle all the errors in the
@@ -487,6 +633,13 @@ func (a *apiServer) monitorPipeline(pachClient *client.APIClient, pipelineInfo *
 		// Capacity 1 gives us a bit of buffer so we don't needlessly go into
 		// standby when SubscribeCommit takes too long to return.
 		ciChan := make(chan *pfs.CommitInfo, 1)
+		var idleTimeout time.Duration
+		if pipelineInfo.StandbyIdleTimeout != nil {
+			var err error
+			if idleTimeout, err = types.DurationFromProto(pipelineInfo.StandbyIdleTimeout); err != nil {
+				log.Errorf("invalid standby idle timeout for pipeline %s: %v", pipelineInfo.Pipeline.Name, err)
+			}
+		}
 		eg.Go(func() error {
 			return backoff.RetryNotify(func() error {
 				return pachClient.SubscribeCommitF(pipelineInfo.Pipeline.Name, pipelineInfo.OutputBranch, "", pfs.CommitState_READY, func(ci *pfs.CommitInfo) error {
@@ -524,9 +677,11 @@ func (a *apiServer) monitorPipeline(pachClient *client.APIClient, pipelineInfo *
 								return err
 							}
 
-							select {
-							case ci = <-ciChan:
-							default:
+							var err error
+							if ci, err = nextStandbyCommit(pachClient.Ctx(), ciChan, idleTimeout); err != nil {
+								return err
+							}
+							if ci == nil {
 								break running
 							}
 						}
@@ -554,6 +709,30 @@ func (a *apiServer) monitorPipeline(pachClient *client.APIClient, pipelineInfo *
 	}
 }
 
+// nextStandbyCommit returns the next commit from 'ciChan' if one is ready or
+// arrives within 'idleTimeout', and nil otherwise, in which case a standby
+// pipeline goes back into standby. It's a helper function called by
+// monitorPipeline.
+func nextStandbyCommit(ctx context.Context, ciChan <-chan *pfs.CommitInfo, idleTimeout time.Duration) (*pfs.CommitInfo, error) {
+	select {
+	case ci := <-ciChan:
+		return ci, nil
+	default:
+	}
+	// Stay running for the idle timeout, in case more input arrives soon
+	// after the last commit
+	timer := time.NewTimer(idleTimeout)
+	defer timer.Stop()
+	select {
+	case ci := <-ciChan:
+		return ci, nil
+	case <-timer.C:
+		return nil, nil
+	case <-ctx.Done():
+		return nil, context.DeadlineExceeded
+	}
+}
+
 // makeCronCommits makes commits to a single cron input's repo. It's
 // a helper function called by monitorPipeline.
 func (a *apiServer) makeCronCommits(pachClient *client.APIClient, in *pps.Input) error {
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+)
+
+func TestNextStandbyCommit(t *testing.T) {
+	ciChan := make(chan *pfs.CommitInfo, 1)
+	ci := &pfs.CommitInfo{Commit: client.NewCommit("out", "1")}
+
+	// A commit that's ready is returned, even with no idle timeout
+	ciChan <- ci
+	next, err := nextStandbyCommit(context.Background(), ciChan, 0)
+	require.NoError(t, err)
+	require.Equal(t, ci, next)
+
+	// With no idle timeout, the pipeline goes into standby as soon as there
+	// are no commits
+	next, err = nextStandbyCommit(context.Background(), ciChan, 0)
+	require.NoError(t, err)
+	require.Nil(t, next)
+
+	// Otherwise it waits for a commit for up to the idle timeout
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		ciChan <- ci
+	}()
+	next, err = nextStandbyCommit(context.Background(), ciChan, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, ci, next)
+	start := time.Now()
+	next, err = nextStandbyCommit(context.Background(), ciChan, 100*time.Millisecond)
+	require.NoError(t, err)
+	require.Nil(t, next)
+	require.True(t, time.Since(start) >= 100*time.Millisecond)
+
+	// and stops waiting if the pipeline is stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = nextStandbyCommit(ctx, ciChan, time.Minute)
+	require.YesError(t, err)
+}
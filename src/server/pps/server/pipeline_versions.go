@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"golang.org/x/net/context"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
+	"github.com/pachyderm/pachyderm/src/server/pkg/metrics"
+	"github.com/pachyderm/pachyderm/src/server/pkg/ppsconsts"
+)
+
+// Every version of a pipeline's spec is a commit in the pipeline's branch of
+// the spec repo, so the commits' ancestry is the pipeline's history.
+
+// ListPipelineVersions implements the protobuf pps.ListPipelineVersions RPC
+func (a *apiServer) ListPipelineVersions(ctx context.Context, request *pps.ListPipelineVersionsRequest) (response *pps.PipelineInfos, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	pachClient := a.getPachClient().WithCtx(ctx)
+	pipelineInfos, err := a.listPipelineVersions(pachClient, request.Pipeline.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &pps.PipelineInfos{PipelineInfo: pipelineInfos}, nil
+}
+
+// listPipelineVersions returns every version of the pipeline 'name', newest
+// first
+func (a *apiServer) listPipelineVersions(pachClient *client.APIClient, name string) ([]*pps.PipelineInfo, error) {
+	if err := checkLoggedIn(pachClient); err != nil {
+		return nil, err
+	}
+	pipelinePtr := pps.EtcdPipelineInfo{}
+	if err := a.pipelines.ReadOnly(pachClient.Ctx()).Get(name, &pipelinePtr); err != nil {
+		if col.IsErrNotFound(err) {
+			return nil, fmt.Errorf("pipeline \"%s\" not found", name)
+		}
+		return nil, err
+	}
+	commitInfos, err := pachClient.ListCommit(ppsconsts.SpecRepo, pipelinePtr.SpecCommit.ID, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	var result []*pps.PipelineInfo
+	for _, commitInfo := range commitInfos {
+		if commitInfo.Finished == nil {
+			continue // an update that failed or is in progress
+		}
+		var buf bytes.Buffer
+		if err := pachClient.GetFile(ppsconsts.SpecRepo, commitInfo.Commit.ID, ppsconsts.SpecFile, 0, 0, &buf); err != nil {
+			return nil, fmt.Errorf("could not read version of pipeline %s in spec commit %s: %v", name, commitInfo.Commit.ID, err)
+		}
+		pipelineInfo := &pps.PipelineInfo{}
+		if err := pipelineInfo.Unmarshal(buf.Bytes()); err != nil {
+			return nil, fmt.Errorf("could not unmarshal version of pipeline %s in spec commit %s: %v", name, commitInfo.Commit.ID, err)
+		}
+		pipelineInfo.SpecCommit = commitInfo.Commit
+		result = append(result, pipelineInfo)
+	}
+	return result, nil
+}
+
+// RollbackPipeline implements the protobuf pps.RollbackPipeline RPC
+func (a *apiServer) RollbackPipeline(ctx context.Context, request *pps.RollbackPipelineRequest) (response *types.Empty, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	metricsFn := metrics.ReportUserAction(ctx, a.reporter, "RollbackPipeline")
+	defer func(start time.Time) { metricsFn(start, retErr) }(time.Now())
+	pachClient := a.getPachClient().WithCtx(ctx)
+	pipelineInfos, err := a.listPipelineVersions(pachClient, request.Pipeline.Name)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := pps.RollbackSpec(pipelineInfos, request)
+	if err != nil {
+		return nil, err
+	}
+	// CreatePipeline authorizes the update and, in a dry run, reports it
+	return a.CreatePipeline(ctx, spec)
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gogo/protobuf/types"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	schedulingv1beta1 "k8s.io/api/scheduling/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kube_watch "k8s.io/apimachinery/pkg/watch"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+	"github.com/pachyderm/pachyderm/src/server/pkg/backoff"
+	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
+	"github.com/pachyderm/pachyderm/src/server/pkg/ppsdb"
+	"github.com/pachyderm/pachyderm/src/server/pkg/ppsutil"
+)
+
+// A pipeline's priority is implemented with Kubernetes' pod priority: its
+// workers are given a PriorityClass with the pipeline's priority, so that
+// when the cluster is short of resources, Kubernetes schedules them ahead of
+// the workers of lower-priority pipelines, and preempts those workers if
+// necessary.
+
+// upsertPriorityClass creates the PriorityClass for workers of pipelines with
+// priority 'priority' (if it doesn't exist), and returns its name
+func (a *apiServer) upsertPriorityClass(priority int64) (string, error) {
+	name := ppsutil.PriorityClassName(priority)
+	if _, err := a.kubeClient.SchedulingV1beta1().PriorityClasses().Create(&schedulingv1beta1.PriorityClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"suite": suite},
+		},
+		Value:       int32(priority),
+		Description: fmt.Sprintf("Workers of Pachyderm pipelines with priority %d", priority),
+	}); err != nil && !errors.IsAlreadyExists(err) {
+		return "", fmt.Errorf("could not create priority class %s: %v", name, err)
+	}
+	return name, nil
+}
+
+// jobQueuePosition returns the position of the job 'jobPtr' in the queue of
+// jobs that the scheduler hasn't admitted, or 0 if it's not queued
+func (a *apiServer) jobQueuePosition(pachClient *client.APIClient, jobPtr *pps.EtcdJobInfo) (int64, error) {
+	if jobPtr.State != pps.JobState_JOB_STARTING {
+		return 0, nil
+	}
+	state, err := a.readSchedulerState(pachClient.Ctx())
+	if err != nil {
+		return 0, err
+	}
+	ppsutil.SortJobQueue(state.queue)
+	for i, queued := range state.queue {
+		if queued.JobPtr.Job.ID == jobPtr.Job.ID {
+			return int64(i + 1), nil
+		}
+	}
+	return 0, nil
+}
+
+// watchPreemptions records the preemptions of workers by Kubernetes in the
+// jobs that the workers' pipelines are running. It runs while this pachd is
+// the PPS master.
+func (a *apiServer) watchPreemptions(pachClient *client.APIClient) {
+	ctx := pachClient.Ctx()
+	backoff.RetryNotify(func() error {
+		watcher, err := a.kubeClient.CoreV1().Events(a.namespace).Watch(metav1.ListOptions{
+			FieldSelector: "reason=Preempted,involvedObject.kind=Pod",
+			Watch:         true,
+		})
+		if err != nil {
+			return err
+		}
+		defer watcher.Stop()
+		for {
+			select {
+			case event, ok := <-watcher.ResultChan():
+				if !ok {
+					return fmt.Errorf("preemption event watch closed")
+				}
+				if event.Type == kube_watch.Error {
+					return fmt.Errorf("error watching preemption events: %v", event.Object)
+				}
+				kubeEvent, ok := event.Object.(*v1.Event)
+				if !ok || event.Type != kube_watch.Added {
+					continue
+				}
+				if err := a.recordPreemption(pachClient, kubeEvent); err != nil {
+					return err
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}, backoff.NewInfiniteBackOff(), notifyCtx(ctx, "watching preemptions"))
+}
+
+// recordPreemption records the preemption of a worker, described by the
+// Kubernetes event 'kubeEvent', in the unfinished jobs of the worker's
+// pipeline. Preemptions that have already been recorded are ignored.
+func (a *apiServer) recordPreemption(pachClient *client.APIClient, kubeEvent *v1.Event) error {
+	// Worker pods are named after their RC, which is labelled with its pipeline
+	podName := kubeEvent.InvolvedObject.Name
+	i := strings.LastIndex(podName, "-")
+	if i < 0 {
+		return nil
+	}
+	rc, err := a.kubeClient.CoreV1().ReplicationControllers(a.namespace).Get(podName[:i], metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil // not a worker, or its pipeline was deleted or updated
+		}
+		return err
+	}
+	pipelineName, ok := rc.Labels["pipelineName"]
+	if !ok {
+		return nil
+	}
+	eventTime := kubeEvent.LastTimestamp.Time
+	if eventTime.IsZero() {
+		eventTime = kubeEvent.CreationTimestamp.Time
+	}
+	timestamp, err := types.TimestampProto(eventTime)
+	if err != nil {
+		return err
+	}
+	preemption := &pps.JobPreemption{
+		Worker:  podName,
+		Time:    timestamp,
+		Message: kubeEvent.Message,
+	}
+	var jobIDs []string
+	jobPtr := &pps.EtcdJobInfo{}
+	if err := a.jobs.ReadOnly(pachClient.Ctx()).GetByIndex(ppsdb.JobsPipelineIndex, client.NewPipeline(pipelineName), jobPtr, col.DefaultOptions, func(jobID string) error {
+		if !ppsutil.IsTerminal(jobPtr.State) {
+			jobIDs = append(jobIDs, jobID)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, jobID := range jobIDs {
+		if _, err := col.NewSTM(pachClient.Ctx(), a.etcdClient, func(stm col.STM) error {
+			jobPtr := &pps.EtcdJobInfo{}
+			return a.jobs.ReadWrite(stm).Update(jobID, jobPtr, func() error {
+				for _, recorded := range jobPtr.Preemptions {
+					if recorded.Worker == preemption.Worker && recorded.Time.Equal(preemption.Time) {
+						return nil
+					}
+				}
+				jobPtr.Preemptions = append(jobPtr.Preemptions, preemption)
+				return nil
+			})
+		}); err != nil && !col.IsErrNotFound(err) {
+			return err
+		}
+	}
+	log.Infof("worker %s of pipeline %s was preempted: %s", podName, pipelineName, kubeEvent.Message)
+	return nil
+}
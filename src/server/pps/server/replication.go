@@ -0,0 +1,539 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/auth"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
+	"github.com/pachyderm/pachyderm/src/server/pkg/hashtree"
+)
+
+// The functions in this file replicate branches to other clusters (see
+// pps.Replication). Replications are stored in etcd, and the PPS master
+// copies the new commits on each replicated branch to the target cluster,
+// oldest first, every replicationPeriod (in a goroutine per replication). A commit is copied by reading the
+// files that changed in it, writing them to the target's object storage,
+// and building a commit with the same ID from the resulting tree, on top of
+// the copy of its parent. Each branch's progress is recorded in its
+// replication's status.
+
+const (
+	// replicationPeriod is how often the PPS master copies new commits to
+	// replications' targets
+	replicationPeriod = 30 * time.Second
+
+	// replicationBatchSize is the largest number of commits copied from one
+	// branch each replicationPeriod, so that copying a long history doesn't
+	// hold up the other branches
+	replicationBatchSize = 100
+)
+
+// checkAdmin returns an error if auth is active and the caller isn't a
+// cluster admin
+func checkAdmin(pachClient *client.APIClient, op string) error {
+	me, err := pachClient.WhoAmI(pachClient.Ctx(), &auth.WhoAmIRequest{})
+	if err != nil {
+		if auth.IsErrNotActivated(err) {
+			return nil
+		}
+		return fmt.Errorf("error during authorization check: %v", err)
+	}
+	if !me.IsAdmin {
+		return &auth.ErrNotAuthorized{
+			Subject: me.Username,
+			AdminOp: op,
+		}
+	}
+	return nil
+}
+
+func validateReplication(request *pps.CreateReplicationRequest) error {
+	if request.Replication == nil || request.Replication.Name == "" {
+		return fmt.Errorf("replication must have a name")
+	}
+	if request.Target == nil || request.Target.PachdAddress == "" {
+		return fmt.Errorf("replication %s must have a target pachd address", request.Replication.Name)
+	}
+	if len(request.Branches) == 0 {
+		return fmt.Errorf("replication %s must replicate at least one branch", request.Replication.Name)
+	}
+	seen := make(map[string]bool)
+	for _, branch := range request.Branches {
+		if branch.Repo == nil || branch.Repo.Name == "" || branch.Name == "" {
+			return fmt.Errorf("replication %s has a branch without a repo or a name", request.Replication.Name)
+		}
+		key := branch.Repo.Name + "@" + branch.Name
+		if seen[key] {
+			return fmt.Errorf("replication %s replicates %s more than once", request.Replication.Name, key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// createReplication stores a new replication, or updates an existing one
+func (a *apiServer) createReplication(ctx context.Context, request *pps.CreateReplicationRequest) error {
+	if err := validateReplication(request); err != nil {
+		return err
+	}
+	_, err := col.NewSTM(ctx, a.etcdClient, func(stm col.STM) error {
+		replications := a.replications.ReadWrite(stm)
+		name := request.Replication.Name
+		if !request.Update {
+			return replications.Create(name, &pps.ReplicationInfo{
+				Replication: request.Replication,
+				Target:      request.Target,
+				Branches:    request.Branches,
+				Created:     now(),
+			})
+		}
+		replicationInfo := &pps.ReplicationInfo{}
+		return replications.Update(name, replicationInfo, func() error {
+			replicationInfo.Target = request.Target
+			replicationInfo.Branches = request.Branches
+			// Drop the progress of branches that are no longer replicated
+			var status []*pps.ReplicationBranchStatus
+			for _, branchStatus := range replicationInfo.Status {
+				if branchIndex(request.Branches, branchStatus.Branch) >= 0 {
+					status = append(status, branchStatus)
+				}
+			}
+			replicationInfo.Status = status
+			return nil
+		})
+	})
+	return err
+}
+
+// inspectReplication returns the replication 'name', without its target's
+// auth token
+func (a *apiServer) inspectReplication(ctx context.Context, name string) (*pps.ReplicationInfo, error) {
+	replicationInfo := &pps.ReplicationInfo{}
+	if err := a.replications.ReadOnly(ctx).Get(name, replicationInfo); err != nil {
+		return nil, err
+	}
+	if replicationInfo.Target != nil {
+		replicationInfo.Target.AuthToken = ""
+	}
+	return replicationInfo, nil
+}
+
+// listReplication returns every replication, without their targets' auth
+// tokens (unless 'withTokens' is set)
+func (a *apiServer) listReplication(ctx context.Context, withTokens bool) ([]*pps.ReplicationInfo, error) {
+	var result []*pps.ReplicationInfo
+	replicationInfo := &pps.ReplicationInfo{}
+	if err := a.replications.ReadOnly(ctx).List(replicationInfo, col.DefaultOptions, func(string) error {
+		info := proto.Clone(replicationInfo).(*pps.ReplicationInfo)
+		if !withTokens && info.Target != nil {
+			info.Target.AuthToken = ""
+		}
+		result = append(result, info)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (a *apiServer) deleteReplication(ctx context.Context, name string) error {
+	_, err := col.NewSTM(ctx, a.etcdClient, func(stm col.STM) error {
+		return a.replications.ReadWrite(stm).Delete(name)
+	})
+	return err
+}
+
+// branchIndex returns the index of 'branch' in 'branches', or -1 if it's not
+// there
+func branchIndex(branches []*pfs.Branch, branch *pfs.Branch) int {
+	for i, b := range branches {
+		if b.Repo.Name == branch.Repo.Name && b.Name == branch.Name {
+			return i
+		}
+	}
+	return -1
+}
+
+// updateReplicationStatus records the progress of a branch of the
+// replication 'name'. It does nothing if the replication (or the branch) has
+// been deleted in the meantime.
+func (a *apiServer) updateReplicationStatus(ctx context.Context, name string, status *pps.ReplicationBranchStatus) error {
+	_, err := col.NewSTM(ctx, a.etcdClient, func(stm col.STM) error {
+		replications := a.replications.ReadWrite(stm)
+		replicationInfo := &pps.ReplicationInfo{}
+		if err := replications.Get(name, replicationInfo); err != nil {
+			if col.IsErrNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if branchIndex(replicationInfo.Branches, status.Branch) < 0 {
+			return nil
+		}
+		var branches []*pfs.Branch
+		for _, branchStatus := range replicationInfo.Status {
+			branches = append(branches, branchStatus.Branch)
+		}
+		if i := branchIndex(branches, status.Branch); i >= 0 {
+			replicationInfo.Status[i] = status
+		} else {
+			replicationInfo.Status = append(replicationInfo.Status, status)
+		}
+		return replications.Put(name, replicationInfo)
+	})
+	return err
+}
+
+// replicate copies new commits to replications' targets until pachClient's
+// context is cancelled. Each replication is run in a goroutine of its own
+// (see runReplicationLoop), so that a slow or unreachable target doesn't hold
+// up the others. Replications are listed every replicationPeriod, to start
+// goroutines for new ones and stop those of deleted ones. It's run by the PPS
+// master, so that only one pachd copies each commit. 'pachClient' must be a
+// superuser client.
+func (a *apiServer) replicate(pachClient *client.APIClient) {
+	running := make(map[string]context.CancelFunc)
+	defer func() {
+		for _, cancel := range running {
+			cancel()
+		}
+	}()
+	ticker := time.NewTicker(replicationPeriod)
+	defer ticker.Stop()
+	for {
+		replicationInfos, err := a.listReplication(pachClient.Ctx(), false)
+		if err != nil {
+			log.Errorf("PPS master: error listing replications: %v", err)
+		} else {
+			exists := make(map[string]bool)
+			for _, replicationInfo := range replicationInfos {
+				name := replicationInfo.Replication.Name
+				exists[name] = true
+				if _, ok := running[name]; !ok {
+					ctx, cancel := context.WithCancel(pachClient.Ctx())
+					running[name] = cancel
+					go a.runReplicationLoop(pachClient.WithCtx(ctx), name)
+				}
+			}
+			for name, cancel := range running {
+				if !exists[name] {
+					cancel()
+					delete(running, name)
+				}
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-pachClient.Ctx().Done():
+			return
+		}
+	}
+}
+
+// runReplicationLoop runs the replication 'name' every replicationPeriod,
+// until pachClient's context is cancelled. The replication is re-read each
+// time, so that updates to it take effect.
+func (a *apiServer) runReplicationLoop(pachClient *client.APIClient, name string) {
+	ticker := time.NewTicker(replicationPeriod)
+	defer ticker.Stop()
+	for {
+		replicationInfo := &pps.ReplicationInfo{}
+		if err := a.replications.ReadOnly(pachClient.Ctx()).Get(name, replicationInfo); err != nil {
+			if !col.IsErrNotFound(err) && pachClient.Ctx().Err() == nil {
+				log.Errorf("PPS master: error reading replication %s: %v", name, err)
+			}
+		} else {
+			a.runReplication(pachClient, replicationInfo)
+		}
+		select {
+		case <-ticker.C:
+		case <-pachClient.Ctx().Done():
+			return
+		}
+	}
+}
+
+// dialReplicationTarget connects to the target of a replication
+func dialReplicationTarget(target *pps.ReplicationTarget) (*client.APIClient, error) {
+	var options []client.Option
+	if len(target.CaCerts) > 0 {
+		options = append(options, client.WithAdditionalRootCAs(target.CaCerts))
+	}
+	targetClient, err := client.NewFromAddress(target.PachdAddress, options...)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to %s: %v", target.PachdAddress, err)
+	}
+	targetClient.SetAuthToken(target.AuthToken)
+	return targetClient, nil
+}
+
+// runReplication copies (up to replicationBatchSize of) the new commits on
+// each of a replication's branches to its target, and records its progress
+func (a *apiServer) runReplication(pachClient *client.APIClient, replicationInfo *pps.ReplicationInfo) {
+	name := replicationInfo.Replication.Name
+	targetClient, dialErr := dialReplicationTarget(replicationInfo.Target)
+	if dialErr == nil {
+		defer targetClient.Close()
+		targetClient = targetClient.WithCtx(pachClient.Ctx())
+	}
+
+	// Branches are replicated upstream first, so that their downstream
+	// commits' provenance has been copied by the time they're copied
+	branchInfos := make([]*pfs.BranchInfo, len(replicationInfo.Branches))
+	for i, branch := range replicationInfo.Branches {
+		branchInfo, err := pachClient.InspectBranch(branch.Repo.Name, branch.Name)
+		if err != nil {
+			// replicateBranch reports the error
+			branchInfo = &pfs.BranchInfo{Branch: branch}
+		}
+		branchInfos[i] = branchInfo
+	}
+	sort.SliceStable(branchInfos, func(i, j int) bool { return len(branchInfos[i].Provenance) < len(branchInfos[j].Provenance) })
+
+	for _, branchInfo := range branchInfos {
+		status := &pps.ReplicationBranchStatus{Branch: branchInfo.Branch}
+		for _, s := range replicationInfo.Status {
+			if branchIndex([]*pfs.Branch{s.Branch}, status.Branch) == 0 {
+				status = s
+			}
+		}
+		status.Error = ""
+		if dialErr != nil {
+			status.Error = dialErr.Error()
+		} else if err := a.replicateBranch(pachClient, targetClient, status); err != nil {
+			status.Error = err.Error()
+			log.Errorf("PPS master: error replicating %s@%s (replication %s): %v", status.Branch.Repo.Name, status.Branch.Name, name, err)
+		}
+		status.Updated = now()
+		if err := a.updateReplicationStatus(pachClient.Ctx(), name, status); err != nil {
+			log.Errorf("PPS master: error updating the status of replication %s: %v", name, err)
+		}
+	}
+}
+
+// replicateBranch copies (up to replicationBatchSize of) the finished
+// commits on a branch that haven't been copied to the target yet, oldest
+// first, and updates 'status' as it goes
+func (a *apiServer) replicateBranch(pachClient *client.APIClient, targetClient *client.APIClient, status *pps.ReplicationBranchStatus) error {
+	repo, branch := status.Branch.Repo.Name, status.Branch.Name
+	branchInfo, err := pachClient.InspectBranch(repo, branch)
+	if err != nil {
+		return err
+	}
+	status.Head = branchInfo.Head
+	if branchInfo.Head == nil {
+		return nil
+	}
+	targetRepoInfo, err := ensureReplicatedRepo(pachClient, targetClient, repo)
+	if err != nil {
+		return err
+	}
+
+	// List the commits after the last one that was replicated. If that commit
+	// has been deleted (e.g. squashed), the whole branch is listed, and the
+	// commits already in the target are skipped.
+	var commitInfos []*pfs.CommitInfo
+	if status.LastCommit != nil {
+		commitInfos, err = pachClient.ListCommit(repo, branchInfo.Head.ID, status.LastCommit.ID, 0)
+	}
+	if status.LastCommit == nil || err != nil {
+		if commitInfos, err = pachClient.ListCommit(repo, branchInfo.Head.ID, "", 0); err != nil {
+			return err
+		}
+	}
+	replicated := 0
+	for i := len(commitInfos) - 1; i >= 0 && replicated < replicationBatchSize; i-- {
+		commitInfo := commitInfos[i]
+		if commitInfo.Finished == nil {
+			break
+		}
+		if _, err := targetClient.InspectCommit(repo, commitInfo.Commit.ID); err == nil {
+			status.LastCommit = commitInfo.Commit
+			continue
+		} else if !isNotFoundErr(err) {
+			return err
+		}
+		size, err := a.replicateCommit(pachClient, targetClient, targetRepoInfo, commitInfo, branch)
+		if err != nil {
+			return fmt.Errorf("could not replicate commit %s: %v", commitInfo.Commit.ID, err)
+		}
+		status.LastCommit = commitInfo.Commit
+		status.CommitsReplicated++
+		status.BytesReplicated += size
+		replicated++
+	}
+
+	// Copying a commit moves the branch to it in the target, but if the
+	// branch's head was copied on another branch (e.g. the branch was created
+	// from it), the branch is moved here
+	if status.LastCommit == nil || status.LastCommit.ID != branchInfo.Head.ID {
+		return nil
+	}
+	targetBranchInfo, err := targetClient.InspectBranch(repo, branch)
+	if err != nil && !isNotFoundErr(err) {
+		return err
+	}
+	var provenance []*pfs.Branch
+	if err == nil {
+		if targetBranchInfo.Head != nil && targetBranchInfo.Head.ID == branchInfo.Head.ID {
+			return nil
+		}
+		provenance = targetBranchInfo.DirectProvenance
+	}
+	return targetClient.CreateBranch(repo, branch, branchInfo.Head.ID, provenance)
+}
+
+// ensureReplicatedRepo creates 'repo' in the target cluster, with the same
+// settings as it has in this one, if it doesn't exist there, and returns its
+// info in the target
+func ensureReplicatedRepo(pachClient *client.APIClient, targetClient *client.APIClient, repo string) (*pfs.RepoInfo, error) {
+	targetRepoInfo, err := targetClient.InspectRepo(repo)
+	if err == nil {
+		return targetRepoInfo, nil
+	} else if !isNotFoundErr(err) {
+		return nil, err
+	}
+	repoInfo, err := pachClient.InspectRepo(repo)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := targetClient.PfsAPIClient.CreateRepo(targetClient.Ctx(), &pfs.CreateRepoRequest{
+		Repo:        repoInfo.Repo,
+		Description: repoInfo.Description,
+		Compression: repoInfo.Compression,
+		Encryption:  repoInfo.Encryption,
+	}); err != nil && !isAlreadyExistsErr(err) {
+		return nil, grpcutil.ScrubGRPC(err)
+	}
+	return targetClient.InspectRepo(repo)
+}
+
+// replicateCommit copies 'commitInfo' to the target cluster, on 'branch',
+// and returns the number of bytes of file data that it copied. The files
+// that changed in the commit are written to the target's object storage,
+// and the commit is built from its parent's tree in the target (or from
+// scratch, if its parent hasn't been copied), so that only new data is
+// copied.
+func (a *apiServer) replicateCommit(pachClient *client.APIClient, targetClient *client.APIClient, targetRepoInfo *pfs.RepoInfo, commitInfo *pfs.CommitInfo, branch string) (_ uint64, retErr error) {
+	repo := commitInfo.Commit.Repo.Name
+	parent := client.NewCommit(repo, "")
+	var tree hashtree.HashTree
+	if commitInfo.ParentCommit != nil {
+		parentInfo, err := targetClient.InspectCommit(repo, commitInfo.ParentCommit.ID)
+		if err != nil && !isNotFoundErr(err) {
+			return 0, err
+		}
+		if err == nil {
+			parent.ID = parentInfo.Commit.ID
+			if parentInfo.Tree != nil {
+				if tree, err = hashtree.GetHashTreeObject(targetClient, a.storageRoot, parentInfo.Tree); err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+
+	// Find the files to copy: those that changed since the parent, if its
+	// tree is available, and otherwise all of them
+	var newFiles, oldFiles []*pfs.FileInfo
+	if tree != nil {
+		var err error
+		newFiles, oldFiles, err = pachClient.DiffFile(repo, commitInfo.Commit.ID, "/", repo, commitInfo.ParentCommit.ID, "/", false)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		var err error
+		if tree, err = hashtree.NewDBHashTree(a.storageRoot); err != nil {
+			return 0, err
+		}
+		if err := pachClient.Walk(repo, commitInfo.Commit.ID, "/", func(fileInfo *pfs.FileInfo) error {
+			newFiles = append(newFiles, fileInfo)
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+	}
+	defer func() {
+		if err := tree.Destroy(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+
+	// Delete the files and directories that were deleted, and the files that
+	// changed (as PutFile appends to them)
+	newPaths := make(map[string]bool)
+	for _, fileInfo := range newFiles {
+		newPaths[fileInfo.File.Path] = true
+	}
+	for _, fileInfo := range append(oldFiles, newFiles...) {
+		path := fileInfo.File.Path
+		if path == "/" || (fileInfo.FileType == pfs.FileType_DIR && newPaths[path]) {
+			continue
+		}
+		if err := tree.DeleteFile(path); err != nil && hashtree.Code(err) != hashtree.PathNotFound {
+			return 0, err
+		}
+	}
+	var size uint64
+	for _, fileInfo := range newFiles {
+		path := fileInfo.File.Path
+		if fileInfo.FileType == pfs.FileType_DIR {
+			if path != "/" {
+				if err := tree.PutDir(path); err != nil {
+					return 0, err
+				}
+			}
+			continue
+		}
+		r, err := pachClient.GetFileReader(repo, commitInfo.Commit.ID, path, 0, 0)
+		if err != nil {
+			return 0, err
+		}
+		objects, n, err := targetClient.PutObjectSplitEncrypted(r, targetRepoInfo.Compression, targetRepoInfo.Encryption)
+		if err != nil {
+			return 0, err
+		}
+		if err := tree.PutFile(path, objects.Objects, n); err != nil {
+			return 0, err
+		}
+		size += uint64(n)
+	}
+	if err := tree.Hash(); err != nil {
+		return 0, err
+	}
+	treeRef, err := hashtree.PutHashTree(targetClient, tree)
+	if err != nil {
+		return 0, err
+	}
+
+	// Keep the commit's provenance, where it has been copied
+	var provenance []*pfs.Commit
+	for _, provCommit := range commitInfo.Provenance {
+		if _, err := targetClient.InspectCommit(provCommit.Repo.Name, provCommit.ID); err == nil {
+			provenance = append(provenance, provCommit)
+		} else if !isNotFoundErr(err) {
+			return 0, err
+		}
+	}
+	if _, err := targetClient.PfsAPIClient.BuildCommit(targetClient.Ctx(), &pfs.BuildCommitRequest{
+		Parent:     parent,
+		Branch:     branch,
+		Provenance: provenance,
+		Tree:       treeRef,
+		ID:         commitInfo.Commit.ID,
+	}); err != nil {
+		return 0, grpcutil.ScrubGRPC(err)
+	}
+	return size, nil
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs/server"
+)
+
+func requireFile(t *testing.T, c *client.APIClient, repo, commit, path, expected string) {
+	var buf bytes.Buffer
+	require.NoError(t, c.GetFile(repo, commit, path, 0, 0, &buf))
+	require.Equal(t, expected, buf.String())
+}
+
+func TestReplicateBranch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	// Two clusters, each a PFS server with its own etcd prefix and storage
+	// (this needs etcd at localhost:32379, like the PFS server tests)
+	src := pfsserver.GetPachClient(t)
+	dst := pfsserver.GetPachClient(t)
+	storageRoot, err := ioutil.TempDir("", "replication")
+	require.NoError(t, err)
+	defer os.RemoveAll(storageRoot)
+	a := &apiServer{storageRoot: storageRoot}
+
+	// 'out' is provenant on 'in', as a pipeline's output repo is
+	require.NoError(t, src.CreateRepo("in"))
+	require.NoError(t, src.CreateRepo("out"))
+	require.NoError(t, src.CreateBranch("out", "master", "", []*pfs.Branch{client.NewBranch("in", "master")}))
+	_, err = src.PutFile("in", "master", "a", strings.NewReader("foo"))
+	require.NoError(t, err)
+	_, err = src.PutFile("out", "master", "a", strings.NewReader("FOO"))
+	require.NoError(t, err)
+	require.NoError(t, src.FinishCommit("out", "master"))
+	inHead, err := src.InspectCommit("in", "master")
+	require.NoError(t, err)
+	outHead, err := src.InspectCommit("out", "master")
+	require.NoError(t, err)
+
+	// Commits are copied with their IDs, contents and provenance
+	inStatus := &pps.ReplicationBranchStatus{Branch: client.NewBranch("in", "master")}
+	require.NoError(t, a.replicateBranch(src, dst, inStatus))
+	require.Equal(t, uint64(1), inStatus.CommitsReplicated)
+	require.Equal(t, uint64(3), inStatus.BytesReplicated)
+	require.Equal(t, inHead.Commit.ID, inStatus.LastCommit.ID)
+	outStatus := &pps.ReplicationBranchStatus{Branch: client.NewBranch("out", "master")}
+	require.NoError(t, a.replicateBranch(src, dst, outStatus))
+	require.Equal(t, uint64(1), outStatus.CommitsReplicated)
+
+	commitInfo, err := dst.InspectCommit("in", "master")
+	require.NoError(t, err)
+	require.Equal(t, inHead.Commit.ID, commitInfo.Commit.ID)
+	requireFile(t, dst, "in", "master", "a", "foo")
+	commitInfo, err = dst.InspectCommit("out", "master")
+	require.NoError(t, err)
+	require.Equal(t, outHead.Commit.ID, commitInfo.Commit.ID)
+	require.Equal(t, 1, len(commitInfo.Provenance))
+	require.Equal(t, inHead.Commit.ID, commitInfo.Provenance[0].ID)
+	requireFile(t, dst, "out", "master", "a", "FOO")
+
+	// Replication resumes from the last commit copied, and only copies the
+	// files that changed
+	_, err = src.PutFile("in", "master", "b", strings.NewReader("bar"))
+	require.NoError(t, err)
+	require.NoError(t, src.DeleteFile("in", "master", "a"))
+	inHead, err = src.InspectCommit("in", "master")
+	require.NoError(t, err)
+	require.NoError(t, a.replicateBranch(src, dst, inStatus))
+	require.Equal(t, uint64(3), inStatus.CommitsReplicated)
+	require.Equal(t, uint64(6), inStatus.BytesReplicated)
+	require.Equal(t, inHead.Commit.ID, inStatus.LastCommit.ID)
+	commitInfo, err = dst.InspectCommit("in", "master")
+	require.NoError(t, err)
+	require.Equal(t, inHead.Commit.ID, commitInfo.Commit.ID)
+	requireFile(t, dst, "in", "master", "b", "bar")
+	_, err = dst.InspectFile("in", "master", "a")
+	require.YesError(t, err)
+
+	// Nothing is copied when there are no new commits
+	require.NoError(t, a.replicateBranch(src, dst, inStatus))
+	require.Equal(t, uint64(3), inStatus.CommitsReplicated)
+	commitInfos, err := dst.ListCommit("in", "master", "", 0)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(commitInfos))
+}
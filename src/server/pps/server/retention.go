@@ -0,0 +1,143 @@
+package server
+
+import (
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"github.com/pachyderm/pachyderm/src/server/pkg/errutil"
+)
+
+const (
+	// retentionPeriod is how often the PPS master deletes the commits that
+	// repos' retention policies don't keep (see pfs.RetentionPolicy)
+	retentionPeriod = time.Minute
+
+	// retentionBatchSize is the largest number of commits deleted from one
+	// branch each retentionPeriod, so that trimming a long history doesn't
+	// hold up the other repos
+	retentionBatchSize = 1000
+)
+
+// enforceRetention deletes the commits that repos' retention policies don't
+// keep, every retentionPeriod, until pachClient's context is cancelled. It's
+// run by the PPS master, so that only one pachd trims each repo. 'pachClient'
+// must be a superuser client.
+func (a *apiServer) enforceRetention(pachClient *client.APIClient) {
+	ticker := time.NewTicker(retentionPeriod)
+	defer ticker.Stop()
+	for {
+		if err := trimRepos(pachClient); err != nil {
+			log.Errorf("PPS master: error enforcing retention policies: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-pachClient.Ctx().Done():
+			return
+		}
+	}
+}
+
+// trimRepos deletes (up to retentionBatchSize of) the commits on each branch
+// that its repo's retention policy doesn't keep
+func trimRepos(pachClient *client.APIClient) error {
+	repoInfos, err := pachClient.ListRepo()
+	if err != nil {
+		return err
+	}
+	for _, repoInfo := range repoInfos {
+		if repoInfo.Retention == nil {
+			continue
+		}
+		branchInfos, err := pachClient.ListBranch(repoInfo.Repo.Name)
+		if err != nil {
+			return err
+		}
+		heads := make(map[string]bool)
+		for _, branchInfo := range branchInfos {
+			if branchInfo.Head != nil {
+				heads[branchInfo.Head.ID] = true
+			}
+		}
+		for _, branchInfo := range branchInfos {
+			if !retentionApplies(repoInfo.Retention, branchInfo.Branch.Name) {
+				continue
+			}
+			n, err := trimBranch(pachClient, repoInfo.Retention, branchInfo.Branch, heads)
+			if err != nil {
+				log.Errorf("PPS master: error trimming %s@%s: %v", repoInfo.Repo.Name, branchInfo.Branch.Name, err)
+			}
+			if n > 0 {
+				log.Infof("PPS master: deleted %d commits from %s@%s, per its retention policy", n, repoInfo.Repo.Name, branchInfo.Branch.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// retentionApplies returns true if 'retention' applies to the branch 'branch'
+func retentionApplies(retention *pfs.RetentionPolicy, branch string) bool {
+	if len(retention.Branches) == 0 {
+		return true
+	}
+	for _, b := range retention.Branches {
+		if b == branch {
+			return true
+		}
+	}
+	return false
+}
+
+// trimBranch deletes up to retentionBatchSize commits on 'branch' that
+// 'retention' doesn't keep, and returns the number of commits deleted.
+// 'heads' holds the IDs of the repo's branches' heads, which aren't deleted.
+func trimBranch(pachClient *client.APIClient, retention *pfs.RetentionPolicy, branch *pfs.Branch, heads map[string]bool) (int, error) {
+	var expired []string
+	now := time.Now()
+	index := 0 // the position of each commit on 'branch', newest first
+	if err := pachClient.ListCommitF(branch.Repo.Name, branch.Name, "", 0, func(commitInfo *pfs.CommitInfo) error {
+		defer func() { index++ }()
+		if commitInfo.Finished == nil || len(commitInfo.Provenance) > 0 || heads[commitInfo.Commit.ID] ||
+			!isExpired(retention, index, commitInfo.Finished, now) {
+			return nil
+		}
+		expired = append(expired, commitInfo.Commit.ID)
+		if len(expired) >= retentionBatchSize {
+			return errutil.ErrBreak
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	for i, id := range expired {
+		if err := pachClient.DeleteCommit(branch.Repo.Name, id); err != nil {
+			return i, err
+		}
+	}
+	return len(expired), nil
+}
+
+// isExpired returns true if 'retention' doesn't keep a commit that is the
+// 'index'th newest on its branch and finished at 'finished'
+func isExpired(retention *pfs.RetentionPolicy, index int, finished *types.Timestamp, now time.Time) bool {
+	if retention.KeepCommits == 0 && retention.KeepDuration == nil {
+		return false
+	}
+	if retention.KeepCommits > 0 && int64(index) < retention.KeepCommits {
+		return false
+	}
+	if retention.KeepDuration != nil {
+		keepDuration, err := types.DurationFromProto(retention.KeepDuration)
+		if err != nil {
+			return false
+		}
+		finishedTime, err := types.TimestampFromProto(finished)
+		if err != nil || now.Sub(finishedTime) < keepDuration {
+			return false
+		}
+	}
+	return true
+}
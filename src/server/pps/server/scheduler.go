@@ -0,0 +1,338 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
+	"github.com/pachyderm/pachyderm/src/server/pkg/errutil"
+	"github.com/pachyderm/pachyderm/src/server/pkg/ppsdb"
+	"github.com/pachyderm/pachyderm/src/server/pkg/ppsutil"
+)
+
+// The scheduler bounds the jobs that run at once. Workers don't start a job
+// until the PPS master admits it (by writing a JobAdmission), and the PPS
+// master only admits queued jobs while the running jobs are within the
+// scheduler's limits (see ppsutil.ScheduleJobs). Jobs are admitted whether or
+// not limits are set, so that the limits account for every running job when
+// they're set.
+
+// schedulerPeriod is how often the PPS master admits queued jobs
+const schedulerPeriod = 5 * time.Second
+
+// unfinishedJobStates are the states of the jobs that the scheduler is
+// responsible for
+var unfinishedJobStates = []pps.JobState{
+	pps.JobState_JOB_STARTING,
+	pps.JobState_JOB_RUNNING,
+	pps.JobState_JOB_MERGING,
+}
+
+// schedulerState is the state of the jobs that the scheduler is responsible
+// for
+type schedulerState struct {
+	// admissions are the admissions of the unfinished jobs
+	admissions []*pps.JobAdmission
+	// stale are the IDs of the finished (or deleted) jobs that still have
+	// admissions
+	stale []string
+	// unadmitted are the jobs that are running without an admission (because
+	// they started before this version of pachd)
+	unadmitted []*pps.EtcdJobInfo
+	// queue is the jobs that are waiting to be admitted, in no particular
+	// order. Their resources aren't set.
+	queue []*ppsutil.QueuedJob
+}
+
+// readSchedulerState reads the jobs that are running and queued, and their
+// admissions
+func (a *apiServer) readSchedulerState(ctx context.Context) (*schedulerState, error) {
+	admissions := make(map[string]*pps.JobAdmission)
+	admission := &pps.JobAdmission{}
+	if err := a.admissions.ReadOnly(ctx).List(admission, col.DefaultOptions, func(jobID string) error {
+		admissions[jobID] = proto.Clone(admission).(*pps.JobAdmission)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	state := &schedulerState{}
+	priorities := make(map[string]int64)
+	jobPtr := &pps.EtcdJobInfo{}
+	readJob := func(jobID string) error {
+		if ppsutil.IsTerminal(jobPtr.State) {
+			return nil // the index hasn't caught up with the job
+		}
+		if admission, ok := admissions[jobID]; ok {
+			state.admissions = append(state.admissions, admission)
+			delete(admissions, jobID)
+			return nil
+		}
+		if jobPtr.State != pps.JobState_JOB_STARTING {
+			state.unadmitted = append(state.unadmitted, proto.Clone(jobPtr).(*pps.EtcdJobInfo))
+			return nil
+		}
+		name := jobPtr.Pipeline.Name
+		priority, ok := priorities[name]
+		if !ok {
+			pipelinePtr := &pps.EtcdPipelineInfo{}
+			if err := a.pipelines.ReadOnly(ctx).Get(name, pipelinePtr); err != nil {
+				if col.IsErrNotFound(err) {
+					return nil // the pipeline is being deleted
+				}
+				return err
+			}
+			priority = pipelinePtr.Priority
+			priorities[name] = priority
+		}
+		state.queue = append(state.queue, &ppsutil.QueuedJob{
+			JobPtr:   proto.Clone(jobPtr).(*pps.EtcdJobInfo),
+			Priority: priority,
+		})
+		return nil
+	}
+	// Only the unfinished jobs are read, by their state
+	for _, jobState := range unfinishedJobStates {
+		if err := a.jobs.ReadOnly(ctx).GetByIndex(ppsdb.JobsStateIndex, jobState, jobPtr, col.DefaultOptions, readJob); err != nil {
+			return nil, err
+		}
+	}
+	for jobID := range admissions {
+		state.stale = append(state.stale, jobID)
+	}
+	return state, nil
+}
+
+// hasAdmittedJob returns true if one of the unfinished jobs of the pipeline
+// 'pipelineName' has been admitted by the scheduler, or if the scheduler's
+// limits aren't set (in which case every job is admitted right away)
+func (a *apiServer) hasAdmittedJob(ctx context.Context, pipelineName string) (bool, error) {
+	limits := &pps.SchedulerLimits{}
+	if err := a.schedulerLimits.ReadOnly(ctx).Get(ppsdb.SchedulerLimitsKey, limits); err != nil {
+		if col.IsErrNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	var admitted bool
+	admission := &pps.JobAdmission{}
+	if err := a.admissions.ReadOnly(ctx).List(admission, col.DefaultOptions, func(string) error {
+		if admission.Pipeline.Name == pipelineName {
+			admitted = true
+			return errutil.ErrBreak
+		}
+		return nil
+	}); err != nil && err != errutil.ErrBreak {
+		return false, err
+	}
+	return admitted, nil
+}
+
+// inspectScheduler returns the scheduler's limits, and the jobs that are
+// running and queued
+func (a *apiServer) inspectScheduler(ctx context.Context) (*pps.SchedulerStatus, error) {
+	status := &pps.SchedulerStatus{}
+	limits := &pps.SchedulerLimits{}
+	if err := a.schedulerLimits.ReadOnly(ctx).Get(ppsdb.SchedulerLimitsKey, limits); err != nil {
+		if !col.IsErrNotFound(err) {
+			return nil, err
+		}
+	} else {
+		status.Limits = limits
+	}
+	state, err := a.readSchedulerState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status.Running = state.admissions
+	ppsutil.SortJobQueue(state.queue)
+	for _, queued := range state.queue {
+		status.Queued = append(status.Queued, queued.JobPtr.Job)
+	}
+	return status, nil
+}
+
+// setSchedulerLimits replaces the scheduler's limits with 'limits', or removes
+// them if 'limits' is nil
+func (a *apiServer) setSchedulerLimits(ctx context.Context, limits *pps.SchedulerLimits) error {
+	if limits != nil {
+		if limits.MaxRunningJobs < 0 || limits.MaxCpu < 0 || limits.MaxGpu < 0 {
+			return fmt.Errorf("scheduler limits must not be negative")
+		}
+		for pipeline, max := range limits.PipelineMaxRunningJobs {
+			if max < 0 {
+				return fmt.Errorf("the limit on pipeline %s's running jobs must not be negative", pipeline)
+			}
+		}
+	}
+	_, err := col.NewSTM(ctx, a.etcdClient, func(stm col.STM) error {
+		schedulerLimits := a.schedulerLimits.ReadWrite(stm)
+		if limits == nil {
+			if err := schedulerLimits.Delete(ppsdb.SchedulerLimitsKey); err != nil && !col.IsErrNotFound(err) {
+				return err
+			}
+			return nil
+		}
+		return schedulerLimits.Put(ppsdb.SchedulerLimitsKey, limits)
+	})
+	return err
+}
+
+// pipelineResources are the resources requested by a version of a
+// pipeline's workers
+type pipelineResources struct {
+	specCommitID string
+	cpu          float64
+	gpu          int64
+}
+
+// schedule admits queued jobs while they fit within the scheduler's limits.
+// It runs while this pachd is the PPS master.
+func (a *apiServer) schedule(pachClient *client.APIClient) {
+	resources := make(map[string]*pipelineResources)
+	ticker := time.NewTicker(schedulerPeriod)
+	defer ticker.Stop()
+	for {
+		if err := a.scheduleJobs(pachClient, resources); err != nil {
+			log.Errorf("PPS master: error scheduling jobs: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-pachClient.Ctx().Done():
+			return
+		}
+	}
+}
+
+// scheduleJobs admits the queued jobs that fit within the scheduler's limits,
+// and removes the admissions of finished jobs. 'resources' caches the
+// resources requested by pipelines' workers.
+func (a *apiServer) scheduleJobs(pachClient *client.APIClient, resources map[string]*pipelineResources) error {
+	ctx := pachClient.Ctx()
+	limits := &pps.SchedulerLimits{}
+	if err := a.schedulerLimits.ReadOnly(ctx).Get(ppsdb.SchedulerLimitsKey, limits); err != nil && !col.IsErrNotFound(err) {
+		return err
+	}
+	state, err := a.readSchedulerState(ctx)
+	if err != nil {
+		return err
+	}
+	var newAdmissions []*pps.JobAdmission
+	admit := func(jobPtr *pps.EtcdJobInfo) (*pps.JobAdmission, error) {
+		cpu, gpu, err := a.jobResources(pachClient, jobPtr.Pipeline.Name, resources)
+		if err != nil {
+			return nil, err
+		}
+		admission := &pps.JobAdmission{
+			Job:      jobPtr.Job,
+			Pipeline: jobPtr.Pipeline,
+			Cpu:      cpu,
+			Gpu:      gpu,
+			Admitted: now(),
+		}
+		newAdmissions = append(newAdmissions, admission)
+		return admission, nil
+	}
+	// Count jobs that are already running against the limits
+	running := state.admissions
+	for _, jobPtr := range state.unadmitted {
+		admission, err := admit(jobPtr)
+		if err != nil {
+			return err
+		}
+		running = append(running, admission)
+	}
+	for _, queued := range state.queue {
+		if queued.CPU, queued.GPU, err = a.jobResources(pachClient, queued.JobPtr.Pipeline.Name, resources); err != nil {
+			return err
+		}
+	}
+	for _, queued := range ppsutil.ScheduleJobs(limits, running, state.queue) {
+		if _, err := admit(queued.JobPtr); err != nil {
+			return err
+		}
+	}
+	if len(newAdmissions) == 0 && len(state.stale) == 0 {
+		return nil
+	}
+	if _, err := col.NewSTM(ctx, a.etcdClient, func(stm col.STM) error {
+		admissions := a.admissions.ReadWrite(stm)
+		for _, jobID := range state.stale {
+			if err := admissions.Delete(jobID); err != nil && !col.IsErrNotFound(err) {
+				return err
+			}
+		}
+		for _, admission := range newAdmissions {
+			if err := admissions.Put(admission.Job.ID, admission); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	// Only the worker masters of pipelines with no admitted jobs are running,
+	// so scale up the workers of the pipelines whose jobs were just admitted
+	for _, admission := range newAdmissions {
+		if err := a.scaleUpAdmittedPipeline(pachClient, admission.Pipeline.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scaleUpAdmittedPipeline scales up the workers of the pipeline
+// 'pipelineName', one of whose jobs has been admitted, if it's running
+func (a *apiServer) scaleUpAdmittedPipeline(pachClient *client.APIClient, pipelineName string) error {
+	pipelinePtr := &pps.EtcdPipelineInfo{}
+	if err := a.pipelines.ReadOnly(pachClient.Ctx()).Get(pipelineName, pipelinePtr); err != nil {
+		if col.IsErrNotFound(err) {
+			return nil // the pipeline is being deleted
+		}
+		return err
+	}
+	if pipelinePtr.State != pps.PipelineState_PIPELINE_RUNNING {
+		return nil
+	}
+	pipelineInfo, err := ppsutil.GetPipelineInfo(pachClient, pipelinePtr)
+	if err != nil {
+		return err
+	}
+	return a.scaleUpWorkersForPipeline(pachClient.Ctx(), pipelineInfo)
+}
+
+// jobResources returns the CPU and GPUs requested by the workers of the
+// pipeline 'pipelineName', which a job of that pipeline counts against the
+// scheduler's limits
+func (a *apiServer) jobResources(pachClient *client.APIClient, pipelineName string, resources map[string]*pipelineResources) (float64, int64, error) {
+	pipelinePtr := &pps.EtcdPipelineInfo{}
+	if err := a.pipelines.ReadOnly(pachClient.Ctx()).Get(pipelineName, pipelinePtr); err != nil {
+		if col.IsErrNotFound(err) {
+			return 0, 0, nil // the pipeline is being deleted
+		}
+		return 0, 0, err
+	}
+	if cached, ok := resources[pipelineName]; ok && cached.specCommitID == pipelinePtr.SpecCommit.ID {
+		return cached.cpu, cached.gpu, nil
+	}
+	pipelineInfo, err := ppsutil.GetPipelineInfo(pachClient, pipelinePtr)
+	if err != nil {
+		return 0, 0, err
+	}
+	workers, err := ppsutil.GetExpectedNumWorkers(a.kubeClient, pipelineInfo.ParallelismSpec)
+	if err != nil {
+		return 0, 0, err
+	}
+	cpu, gpu := ppsutil.WorkerResources(pipelineInfo, workers)
+	resources[pipelineName] = &pipelineResources{
+		specCommitID: pipelinePtr.SpecCommit.ID,
+		cpu:          cpu,
+		gpu:          gpu,
+	}
+	return cpu, gpu, nil
+}
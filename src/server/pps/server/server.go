@@ -58,6 +58,10 @@ func NewAPIServer(
 		reporter:              reporter,
 		pipelines:             ppsdb.Pipelines(etcdClient, etcdPrefix),
 		jobs:                  ppsdb.Jobs(etcdClient, etcdPrefix),
+		gcStatus:              ppsdb.GarbageCollection(etcdClient, etcdPrefix),
+		replications:          ppsdb.Replications(etcdClient, etcdPrefix),
+		schedulerLimits:       ppsdb.SchedulerLimits(etcdClient, etcdPrefix),
+		admissions:            ppsdb.JobAdmissions(etcdClient, etcdPrefix),
 		monitorCancels:        make(map[string]func()),
 	}
 	apiServer.validateKube()
@@ -84,14 +88,18 @@ func NewSidecarAPIServer(
 	}
 
 	apiServer := &apiServer{
-		Logger:     log.NewLogger("pps.API"),
-		address:    address,
-		etcdPrefix: etcdPrefix,
-		etcdClient: etcdClient,
-		iamRole:    iamRole,
-		reporter:   reporter,
-		pipelines:  ppsdb.Pipelines(etcdClient, etcdPrefix),
-		jobs:       ppsdb.Jobs(etcdClient, etcdPrefix),
+		Logger:          log.NewLogger("pps.API"),
+		address:         address,
+		etcdPrefix:      etcdPrefix,
+		etcdClient:      etcdClient,
+		iamRole:         iamRole,
+		reporter:        reporter,
+		pipelines:       ppsdb.Pipelines(etcdClient, etcdPrefix),
+		jobs:            ppsdb.Jobs(etcdClient, etcdPrefix),
+		gcStatus:        ppsdb.GarbageCollection(etcdClient, etcdPrefix),
+		replications:    ppsdb.Replications(etcdClient, etcdPrefix),
+		schedulerLimits: ppsdb.SchedulerLimits(etcdClient, etcdPrefix),
+		admissions:      ppsdb.JobAdmissions(etcdClient, etcdPrefix),
 	}
 	go apiServer.getPachClient() // connects back to pachd and inits spec repo
 	return apiServer, nil
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+	"github.com/pachyderm/pachyderm/src/server/pkg/ppsutil"
+)
+
+// The functions in this file implement ValidatePipeline, which checks a
+// pipeline spec without creating the pipeline. It reports the issues found
+// offline by pps.LintPipeline, the errors that CreatePipeline would
+// return (all of them, rather than the first), and problems that would only
+// show up once the pipeline's workers were scheduled, such as resource
+// requests that no node can satisfy or missing image pull secrets.
+
+// ValidatePipeline implements the protobuf pps.ValidatePipeline RPC
+func (a *apiServer) ValidatePipeline(ctx context.Context, request *pps.CreatePipelineRequest) (response *pps.ValidatePipelineResponse, retErr error) {
+	func() { a.Log(request, nil, nil, 0) }()
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	pachClient := a.getPachClient().WithCtx(ctx)
+	if err := checkLoggedIn(pachClient); err != nil {
+		return nil, err
+	}
+	return &pps.ValidatePipelineResponse{Issues: a.validatePipelineRequest(pachClient, request, nil)}, nil
+}
+
+// issues collects the issues found with a pipeline spec, dropping duplicates
+// (which are found when several checks cover the same problem)
+type issues struct {
+	issues []*pps.PipelineIssue
+	seen   map[string]bool
+}
+
+func (is *issues) add(issue *pps.PipelineIssue) {
+	if is.seen == nil {
+		is.seen = make(map[string]bool)
+	}
+	key := issue.Field + "\x00" + issue.Message
+	if is.seen[key] {
+		return
+	}
+	is.seen[key] = true
+	is.issues = append(is.issues, issue)
+}
+
+func (is *issues) addf(severity pps.IssueSeverity, field string, format string, args ...interface{}) {
+	is.add(pps.NewPipelineIssue(severity, field, format, args...))
+}
+
+// validatePipelineRequest returns the issues with the pipeline spec
+// 'request'. Inputs may read from the repos in 'pending' (e.g. of pipelines
+// that will be created first) although they don't exist yet.
+func (a *apiServer) validatePipelineRequest(pachClient *client.APIClient, request *pps.CreatePipelineRequest, pending map[string]bool) []*pps.PipelineIssue {
+	var is issues
+	for _, issue := range pps.LintPipeline(request) {
+		is.add(issue)
+	}
+	pipelineInfo := defaultedPipelineInfo(request)
+
+	if pipelineInfo.Pipeline != nil {
+		if err := validatePipelineName(pipelineInfo); err != nil {
+			is.addf(pps.IssueSeverity_ISSUE_ERROR, "pipeline.name", "%v", err)
+		}
+	}
+	if err := validatePipelineSettings(pipelineInfo); err != nil {
+		is.addf(pps.IssueSeverity_ISSUE_ERROR, "", "%v", err)
+	}
+	if pipelineInfo.Input != nil {
+		if err := validateNames(make(map[string]bool), pipelineInfo.Input); err != nil {
+			is.addf(pps.IssueSeverity_ISSUE_ERROR, "input", "%v", err)
+		}
+		pps.VisitInput(pipelineInfo.Input, func(input *pps.Input) {
+			isPending := input.Pfs != nil && pending[input.Pfs.Repo] || input.Atom != nil && pending[input.Atom.Repo]
+			if err := a.validateInputNode(pachClient, input, false); err != nil {
+				if !isPending || !isNotFoundErr(err) {
+					is.addf(pps.IssueSeverity_ISSUE_ERROR, pps.InputField(input, ""), "%v", err)
+				}
+				return
+			}
+			if isPending {
+				return
+			}
+			if input.Pfs != nil {
+				a.validateInputBranch(pachClient, &is, input, input.Pfs.Repo, input.Pfs.Branch)
+			}
+			if input.Atom != nil {
+				a.validateInputBranch(pachClient, &is, input, input.Atom.Repo, input.Atom.Branch)
+			}
+		})
+	}
+	a.validateResourceRequests(&is, pipelineInfo)
+	a.validateImagePullSecrets(&is, pipelineInfo.Transform.ImagePullSecrets)
+	a.validateServiceAccount(&is, pipelineInfo.Transform.ServiceAccount)
+	return is.issues
+}
+
+// validateInputBranch warns if the branch that 'input' reads from (which is
+// in a repo that exists) has no commits, as the pipeline won't run until it
+// does
+func (a *apiServer) validateInputBranch(pachClient *client.APIClient, is *issues, input *pps.Input, repo, branch string) {
+	branchInfo, err := pachClient.InspectBranch(repo, branch)
+	if err != nil && !isNotFoundErr(err) {
+		is.addf(pps.IssueSeverity_ISSUE_WARNING, pps.InputField(input, "branch"), "could not inspect branch %s/%s: %v", repo, branch, err)
+		return
+	}
+	if branchInfo == nil || branchInfo.Head == nil {
+		is.addf(pps.IssueSeverity_ISSUE_WARNING, pps.InputField(input, "branch"),
+			"branch %s/%s has no commits, so the pipeline won't run until it does", repo, branch)
+	}
+}
+
+// validateResourceRequests reports the resources requested by the pipeline's
+// workers that are more than any node in the cluster that the workers can be
+// scheduled on (given their node selector and tolerations) can allocate
+func (a *apiServer) validateResourceRequests(is *issues, pipelineInfo *pps.PipelineInfo) {
+	requests := make(v1.ResourceList)
+	if pipelineInfo.ResourceRequests != nil {
+		requestList, err := ppsutil.GetRequestsResourceListFromPipeline(pipelineInfo)
+		if err != nil {
+			is.addf(pps.IssueSeverity_ISSUE_ERROR, "resource_requests", "%v", err)
+			return
+		}
+		requests = *requestList
+	}
+	// Extended resources that are only limited are requested too
+	if pipelineInfo.ResourceLimits != nil {
+		limits, err := ppsutil.GetLimitsResourceListFromPipeline(pipelineInfo)
+		if err != nil {
+			is.addf(pps.IssueSeverity_ISSUE_ERROR, "resource_limits", "%v", err)
+			return
+		}
+		for name, quantity := range *limits {
+			if _, ok := requests[name]; !ok && ppsutil.IsExtendedResourceName(string(name)) {
+				requests[name] = quantity
+			}
+		}
+	}
+	nodes, err := a.kubeClient.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		is.addf(pps.IssueSeverity_ISSUE_WARNING, "resource_requests", "could not list the cluster's nodes to check the resource requests: %v", err)
+		return
+	}
+	if len(nodes.Items) == 0 {
+		return
+	}
+	var schedulable []v1.Node
+	for _, node := range nodes.Items {
+		if nodeMatches(node, pipelineInfo.SchedulingSpec) {
+			schedulable = append(schedulable, node)
+		}
+	}
+	if len(schedulable) == 0 {
+		is.addf(pps.IssueSeverity_ISSUE_ERROR, "scheduling_spec",
+			"no node in the cluster matches the pipeline's node selector and tolerates its taints")
+		return
+	}
+	for name, requested := range requests {
+		if requested.IsZero() {
+			continue
+		}
+		var largest resource.Quantity
+		for _, node := range schedulable {
+			if allocatable, ok := node.Status.Allocatable[name]; ok && allocatable.Cmp(largest) > 0 {
+				largest = allocatable
+			}
+		}
+		if requested.Cmp(largest) > 0 {
+			is.addf(pps.IssueSeverity_ISSUE_ERROR, "resource_requests", "%s",
+				describeUnsatisfiableRequest(name, requested, largest))
+		}
+	}
+}
+
+// nodeMatches returns true if workers with the scheduling spec 'spec' may be
+// scheduled on 'node': if the node has the labels in the spec's node selector,
+// and the spec's tolerations tolerate the node's NoSchedule and NoExecute
+// taints
+func nodeMatches(node v1.Node, spec *pps.SchedulingSpec) bool {
+	for key, value := range spec.GetNodeSelector() {
+		if node.Labels[key] != value {
+			return false
+		}
+	}
+	tolerations := ppsutil.KubeTolerations(spec.GetTolerations())
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == v1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for j := range tolerations {
+			if tolerations[j].ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+func describeUnsatisfiableRequest(name v1.ResourceName, requested, largest resource.Quantity) string {
+	if largest.IsZero() {
+		return fmt.Sprintf("workers request %s of %s, but no node in the cluster has any", requested.String(), name)
+	}
+	return fmt.Sprintf("workers request %s of %s, but the most that any node can allocate is %s", requested.String(), name, largest.String())
+}
+
+// validateImagePullSecrets reports the pipeline's image pull secrets that
+// don't exist in pachd's namespace, as workers' images couldn't be pulled
+func (a *apiServer) validateImagePullSecrets(is *issues, secrets []string) {
+	for _, secret := range secrets {
+		_, err := a.kubeClient.CoreV1().Secrets(a.namespace).Get(secret, metav1.GetOptions{})
+		switch {
+		case errors.IsNotFound(err):
+			is.addf(pps.IssueSeverity_ISSUE_ERROR, "transform.image_pull_secrets",
+				"image pull secret %q does not exist in namespace %q", secret, a.namespace)
+		case err != nil:
+			is.addf(pps.IssueSeverity_ISSUE_WARNING, "transform.image_pull_secrets",
+				"could not check image pull secret %q: %v", secret, err)
+		}
+	}
+}
+
+// validateServiceAccount reports the pipeline's service account if it doesn't
+// exist in pachd's namespace, as workers couldn't be created
+func (a *apiServer) validateServiceAccount(is *issues, serviceAccount string) {
+	if serviceAccount == "" {
+		return
+	}
+	_, err := a.kubeClient.CoreV1().ServiceAccounts(a.namespace).Get(serviceAccount, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		is.addf(pps.IssueSeverity_ISSUE_ERROR, "transform.service_account",
+			"service account %q does not exist in namespace %q", serviceAccount, a.namespace)
+	case err != nil:
+		is.addf(pps.IssueSeverity_ISSUE_WARNING, "transform.service_account",
+			"could not check service account %q: %v", serviceAccount, err)
+	}
+}
@@ -3,12 +3,17 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
 	"strconv"
 
 	client "github.com/pachyderm/pachyderm/src/client"
 	"github.com/pachyderm/pachyderm/src/client/enterprise"
 	"github.com/pachyderm/pachyderm/src/client/pps"
 	"github.com/pachyderm/pachyderm/src/client/version"
+	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
 	"github.com/pachyderm/pachyderm/src/server/pkg/deploy/assets"
 	"github.com/pachyderm/pachyderm/src/server/pkg/ppsutil"
 	"github.com/pachyderm/pachyderm/src/server/worker"
@@ -24,23 +29,28 @@ import (
 type workerOptions struct {
 	rcName string // Name of the replication controller managing workers
 
-	userImage        string              // The user's pipeline/job image
-	labels           map[string]string   // k8s labels attached to the RC and workers
-	annotations      map[string]string   // k8s annotations attached to the RC and workers
-	parallelism      int32               // Number of replicas the RC maintains
-	cacheSize        string              // Size of cache that sidecar uses
-	resourceRequests *v1.ResourceList    // Resources requested by pipeline/job pods
-	resourceLimits   *v1.ResourceList    // Resources requested by pipeline/job pods
-	workerEnv        []v1.EnvVar         // Environment vars set in the user container
-	volumes          []v1.Volume         // Volumes that we expose to the user container
-	volumeMounts     []v1.VolumeMount    // Paths where we mount each volume in 'volumes'
-	etcdPrefix       string              // the prefix in etcd to use
-	schedulingSpec   *pps.SchedulingSpec // the SchedulingSpec for the pipeline
+	userImage        string               // The user's pipeline/job image
+	labels           map[string]string    // k8s labels attached to the RC and workers
+	annotations      map[string]string    // k8s annotations attached to the RC and workers
+	parallelism      int32                // Number of replicas the RC maintains
+	cacheSize        string               // Size of cache that sidecar uses
+	resourceRequests *v1.ResourceList     // Resources requested by pipeline/job pods
+	resourceLimits   *v1.ResourceList     // Resources requested by pipeline/job pods
+	workerEnv        []v1.EnvVar          // Environment vars set in the user container
+	volumes          []v1.Volume          // Volumes that we expose to the user container
+	volumeMounts     []v1.VolumeMount     // Paths where we mount each volume in 'volumes'
+	etcdPrefix       string               // the prefix in etcd to use
+	schedulingSpec   *pps.SchedulingSpec  // the SchedulingSpec for the pipeline
+	metadata         *pps.Metadata        // user annotations and labels added to the workers
+	podPatch         string               // a patch applied to the pod spec after podSpec
+	initContainers   []*pps.ContainerSpec // the user's init containers, run after Pachyderm's
+	sidecars         []*pps.ContainerSpec // the user's containers run alongside the worker
 	podSpec          string
 
 	// Secrets that we mount in the worker container (e.g. for reading/writing to
 	// s3)
 	imagePullSecrets []v1.LocalObjectReference
+	serviceAccount   string // the service account that the workers run as
 	service          *pps.Service
 }
 
@@ -165,12 +175,14 @@ func (a *apiServer) workerPodSpec(options *workerOptions) (v1.PodSpec, error) {
 		RestartPolicy:                 "Always",
 		Volumes:                       options.volumes,
 		ImagePullSecrets:              options.imagePullSecrets,
+		ServiceAccountName:            options.serviceAccount,
 		TerminationGracePeriodSeconds: &zeroVal,
 		SecurityContext:               &v1.PodSecurityContext{RunAsUser: &zeroVal},
 	}
 	if options.schedulingSpec != nil {
 		podSpec.NodeSelector = options.schedulingSpec.NodeSelector
 		podSpec.PriorityClassName = options.schedulingSpec.PriorityClassName
+		podSpec.Tolerations = ppsutil.KubeTolerations(options.schedulingSpec.Tolerations)
 	}
 	resourceRequirements := v1.ResourceRequirements{
 		Requests: map[v1.ResourceName]resource.Quantity{
@@ -184,15 +196,100 @@ func (a *apiServer) workerPodSpec(options *workerOptions) (v1.PodSpec, error) {
 	if options.resourceLimits != nil {
 		resourceRequirements.Limits = *options.resourceLimits
 	}
+	limitExtendedResources(&resourceRequirements)
 	podSpec.Containers[0].Resources = resourceRequirements
+	if len(options.initContainers) > 0 || len(options.sidecars) > 0 {
+		podSpec.Volumes = append(podSpec.Volumes, v1.Volume{
+			Name: client.PPSSharedVolume,
+			VolumeSource: v1.VolumeSource{
+				EmptyDir: &v1.EmptyDirVolumeSource{},
+			},
+		})
+		sharedMount := v1.VolumeMount{
+			Name:      client.PPSSharedVolume,
+			MountPath: client.PPSSharedPrefix,
+		}
+		podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, sharedMount)
+		// The user's init containers run after Pachyderm's, which installs
+		// the worker binary
+		for _, spec := range options.initContainers {
+			container, err := extraContainer(spec, pullPolicy, sharedMount)
+			if err != nil {
+				return v1.PodSpec{}, err
+			}
+			podSpec.InitContainers = append(podSpec.InitContainers, container)
+		}
+		for _, spec := range options.sidecars {
+			container, err := extraContainer(spec, pullPolicy, sharedMount)
+			if err != nil {
+				return v1.PodSpec{}, err
+			}
+			podSpec.Containers = append(podSpec.Containers, container)
+		}
+	}
 	if options.podSpec != "" {
 		if err := json.Unmarshal([]byte(options.podSpec), &podSpec); err != nil {
 			return v1.PodSpec{}, err
 		}
 	}
+	if options.podPatch != "" {
+		if err := ppsutil.PatchPodSpec(&podSpec, options.podPatch); err != nil {
+			return v1.PodSpec{}, err
+		}
+	}
 	return podSpec, nil
 }
 
+// limitExtendedResources limits the extended resources (e.g. GPUs) in
+// 'resources' that are requested but not limited to the amount requested, as
+// Kubernetes requires extended resources to be limited
+func limitExtendedResources(resources *v1.ResourceRequirements) {
+	for name, quantity := range resources.Requests {
+		if !ppsutil.IsExtendedResourceName(string(name)) {
+			continue
+		}
+		if resources.Limits == nil {
+			resources.Limits = make(v1.ResourceList)
+		}
+		if _, ok := resources.Limits[name]; !ok {
+			resources.Limits[name] = quantity
+		}
+	}
+}
+
+// extraContainer returns the Kubernetes container for one of a pipeline's
+// init containers or sidecars, which mounts the volume that it shares with
+// the worker at 'sharedMount'
+func extraContainer(spec *pps.ContainerSpec, pullPolicy string, sharedMount v1.VolumeMount) (v1.Container, error) {
+	container := v1.Container{
+		Name:            spec.Name,
+		Image:           spec.Image,
+		Command:         spec.Cmd,
+		ImagePullPolicy: v1.PullPolicy(pullPolicy),
+		VolumeMounts:    []v1.VolumeMount{sharedMount},
+	}
+	for name, value := range spec.Env {
+		container.Env = append(container.Env, v1.EnvVar{Name: name, Value: value})
+	}
+	sort.Slice(container.Env, func(i, j int) bool { return container.Env[i].Name < container.Env[j].Name })
+	if spec.ResourceRequests != nil {
+		requests, err := ppsutil.GetContainerResourceList(spec.ResourceRequests)
+		if err != nil {
+			return v1.Container{}, err
+		}
+		container.Resources.Requests = *requests
+	}
+	if spec.ResourceLimits != nil {
+		limits, err := ppsutil.GetContainerResourceList(spec.ResourceLimits)
+		if err != nil {
+			return v1.Container{}, err
+		}
+		container.Resources.Limits = *limits
+	}
+	limitExtendedResources(&container.Resources)
+	return container, nil
+}
+
 func (a *apiServer) getWorkerOptions(pipelineName string, pipelineVersion uint64,
 	parallelism int32, resourceRequests *v1.ResourceList, resourceLimits *v1.ResourceList,
 	transform *pps.Transform, cacheSize string, service *pps.Service,
@@ -251,10 +348,39 @@ func (a *apiServer) getWorkerOptions(pipelineName string, pipelineVersion uint64
 		Name:  client.PPSSpecCommitEnv,
 		Value: specCommitID,
 	})
+	// Pass along pachd's default compression and encryption, which workers
+	// apply to the output they write to object storage
+	for _, name := range pfsserver.StorageEnvVars {
+		if value := os.Getenv(name); value != "" {
+			workerEnv = append(workerEnv, v1.EnvVar{Name: name, Value: value})
+		}
+	}
 
 	var volumes []v1.Volume
 	var volumeMounts []v1.VolumeMount
-	for _, secret := range transform.Secrets {
+	// mountedSecrets are the secrets loaded into env vars that are mounted
+	// under PPSSecretsPrefix, so that workers can re-read them when they're
+	// rotated
+	mountedSecrets := make(map[string]bool)
+	for i, secret := range transform.Secrets {
+		if secret.Vault != nil {
+			// Workers write Vault secrets to 'MountPath' themselves. It's in
+			// memory so that the secrets aren't written to the node's disk.
+			if secret.MountPath != "" {
+				name := fmt.Sprintf("pach-vault-%d", i)
+				volumes = append(volumes, v1.Volume{
+					Name: name,
+					VolumeSource: v1.VolumeSource{
+						EmptyDir: &v1.EmptyDirVolumeSource{Medium: v1.StorageMediumMemory},
+					},
+				})
+				volumeMounts = append(volumeMounts, v1.VolumeMount{
+					Name:      name,
+					MountPath: secret.MountPath,
+				})
+			}
+			continue
+		}
 		if secret.MountPath != "" {
 			volumes = append(volumes, v1.Volume{
 				Name: secret.Name,
@@ -281,6 +407,23 @@ func (a *apiServer) getWorkerOptions(pipelineName string, pipelineVersion uint64
 					},
 				},
 			})
+			if !mountedSecrets[secret.Name] {
+				mountedSecrets[secret.Name] = true
+				name := fmt.Sprintf("pach-secret-%d", i)
+				volumes = append(volumes, v1.Volume{
+					Name: name,
+					VolumeSource: v1.VolumeSource{
+						Secret: &v1.SecretVolumeSource{
+							SecretName: secret.Name,
+						},
+					},
+				})
+				volumeMounts = append(volumeMounts, v1.VolumeMount{
+					Name:      name,
+					MountPath: path.Join(client.PPSSecretsPrefix, secret.Name),
+					ReadOnly:  true,
+				})
+			}
 		}
 	}
 
@@ -330,6 +473,7 @@ func (a *apiServer) getWorkerOptions(pipelineName string, pipelineVersion uint64
 		volumes:          volumes,
 		volumeMounts:     volumeMounts,
 		imagePullSecrets: imagePullSecrets,
+		serviceAccount:   transform.ServiceAccount,
 		cacheSize:        cacheSize,
 		service:          service,
 		schedulingSpec:   schedulingSpec,
@@ -342,6 +486,22 @@ func (a *apiServer) createWorkerRc(options *workerOptions) error {
 	if err != nil {
 		return err
 	}
+	// The user's annotations and labels are added to the workers, but not the
+	// RC's selector. Pachyderm's own take precedence.
+	podLabels := make(map[string]string)
+	podAnnotations := make(map[string]string)
+	for key, value := range options.metadata.GetLabels() {
+		podLabels[key] = value
+	}
+	for key, value := range options.metadata.GetAnnotations() {
+		podAnnotations[key] = value
+	}
+	for key, value := range options.labels {
+		podLabels[key] = value
+	}
+	for key, value := range options.annotations {
+		podAnnotations[key] = value
+	}
 	rc := &v1.ReplicationController{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "ReplicationController",
@@ -358,8 +518,8 @@ func (a *apiServer) createWorkerRc(options *workerOptions) error {
 			Template: &v1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:        options.rcName,
-					Labels:      options.labels,
-					Annotations: options.annotations,
+					Labels:      podLabels,
+					Annotations: podAnnotations,
 				},
 				Spec: podSpec,
 			},
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/enterprise"
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kube "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// testKubeAPI is a minimal Kubernetes API server. It records the RCs that
+// are created, and has the service account "builder" in the namespace
+// "default" (looking up "broken" fails).
+type testKubeAPI struct {
+	rcs []*v1.ReplicationController
+}
+
+func (k *testKubeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/replicationcontrollers"):
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rc := &v1.ReplicationController{}
+		if err := json.Unmarshal(body, rc); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		k.rcs = append(k.rcs, rc)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/services"):
+		w.WriteHeader(http.StatusCreated)
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	case r.URL.Path == "/api/v1/namespaces/default/serviceaccounts/builder":
+		json.NewEncoder(w).Encode(&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "builder"}})
+	case strings.HasSuffix(r.URL.Path, "/serviceaccounts/broken"):
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&metav1.Status{Status: metav1.StatusFailure, Code: http.StatusInternalServerError})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(&metav1.Status{Status: metav1.StatusFailure, Reason: metav1.StatusReasonNotFound, Code: http.StatusNotFound})
+	}
+}
+
+// testEnterpriseServer reports that enterprise features aren't active, which
+// is all that creating workers needs from pachd
+type testEnterpriseServer struct {
+	enterprise.APIServer
+}
+
+func (testEnterpriseServer) GetState(context.Context, *enterprise.GetStateRequest) (*enterprise.GetStateResponse, error) {
+	return &enterprise.GetStateResponse{State: enterprise.State_NONE}, nil
+}
+
+// newTestKubeAPIServer returns an apiServer that talks to a testKubeAPI and
+// a pachd that only serves the enterprise API
+func newTestKubeAPIServer(t *testing.T) (*apiServer, *testKubeAPI, func()) {
+	k := &testKubeAPI{}
+	s := httptest.NewServer(k)
+	kubeClient, err := kube.NewForConfig(&rest.Config{Host: s.URL})
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer()
+	enterprise.RegisterAPIServer(grpcServer, testEnterpriseServer{})
+	go grpcServer.Serve(listener)
+	pachClient, err := client.NewFromAddress(listener.Addr().String())
+	require.NoError(t, err)
+
+	a := &apiServer{
+		kubeClient:  kubeClient,
+		namespace:   "default",
+		workerImage: "pachyderm/worker:test",
+		pachClient:  pachClient,
+	}
+	a.pachClientOnce.Do(func() {}) // don't replace 'pachClient'
+	return a, k, func() {
+		pachClient.Close()
+		grpcServer.Stop()
+		s.Close()
+	}
+}
+
+func TestWorkerServiceAccount(t *testing.T) {
+	a, k, done := newTestKubeAPIServer(t)
+	defer done()
+
+	// The workers run as the pipeline's service account, or the namespace's
+	// default one if it has none
+	for _, serviceAccount := range []string{"builder", ""} {
+		options := a.getWorkerOptions("pipeline", 1, 1, nil, nil,
+			&pps.Transform{Cmd: []string{"true"}, ServiceAccount: serviceAccount}, "64M", nil, "", nil, "")
+		require.NoError(t, a.createWorkerRc(options))
+		rc := k.rcs[len(k.rcs)-1]
+		require.Equal(t, serviceAccount, rc.Spec.Template.Spec.ServiceAccountName)
+	}
+	require.Equal(t, 2, len(k.rcs))
+}
+
+func TestValidateServiceAccount(t *testing.T) {
+	// Names that Kubernetes would reject are invalid
+	for _, name := range []string{"Builder", "build_er", "-builder", strings.Repeat("a", 254)} {
+		err := validateTransform(&pps.Transform{ServiceAccount: name})
+		require.YesError(t, err)
+		require.Matches(t, "invalid service account", err.Error())
+	}
+	for _, name := range []string{"", "builder", "ci.builder-2"} {
+		require.NoError(t, validateTransform(&pps.Transform{ServiceAccount: name}))
+	}
+
+	// The service account must exist in pachd's namespace
+	a, _, done := newTestKubeAPIServer(t)
+	defer done()
+	for _, test := range []struct {
+		serviceAccount string
+		severity       pps.IssueSeverity
+	}{
+		{"missing", pps.IssueSeverity_ISSUE_ERROR},
+		{"broken", pps.IssueSeverity_ISSUE_WARNING},
+	} {
+		var is issues
+		a.validateServiceAccount(&is, test.serviceAccount)
+		require.Equal(t, 1, len(is.issues))
+		require.Equal(t, test.severity, is.issues[0].Severity)
+		require.Equal(t, "transform.service_account", is.issues[0].Field)
+	}
+	for _, serviceAccount := range []string{"builder", ""} {
+		var is issues
+		a.validateServiceAccount(&is, serviceAccount)
+		require.Equal(t, 0, len(is.issues))
+	}
+}
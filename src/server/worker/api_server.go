@@ -41,6 +41,7 @@ import (
 	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
 	"github.com/pachyderm/pachyderm/src/client/pkg/pbutil"
 	"github.com/pachyderm/pachyderm/src/client/pps"
+	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
 	"github.com/pachyderm/pachyderm/src/server/pkg/backoff"
 	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
 	"github.com/pachyderm/pachyderm/src/server/pkg/exec"
@@ -110,6 +111,9 @@ type APIServer struct {
 	pipelines col.Collection
 	// The progress collection
 	plans col.Collection
+	// The scheduler's limits, and its admissions of jobs
+	schedulerLimits col.Collection
+	admissions      col.Collection
 
 	// Only one datum can be running at a time because they need to be
 	// accessing /pfs, runMu enforces this
@@ -123,6 +127,9 @@ type APIServer struct {
 
 	// hashtreeStorage is the where we store on disk hashtrees
 	hashtreeStorage string
+
+	// secrets loads the current values of the pipeline's secrets
+	secrets *secretLoader
 }
 
 type putObjectResponse struct {
@@ -155,7 +162,10 @@ func (a *APIServer) DatumID(data []*Input) string {
 	return hex.EncodeToString(hash.Sum(nil))
 }
 
-func (a *APIServer) getTaggedLogger(pachClient *client.APIClient, jobID string, data []*Input, enableStats bool) (*taggedLogger, error) {
+// getTaggedLogger returns a logger for the datum 'data' of the job 'jobID'. If
+// 'statsStorage' is set, the datum's stats are enabled, so the logs are also
+// written to an object (stored as 'statsStorage' describes) for its stats.
+func (a *APIServer) getTaggedLogger(pachClient *client.APIClient, jobID string, data []*Input, statsStorage *outputStorage) (*taggedLogger, error) {
 	result := &taggedLogger{
 		template:  a.logMsgTemplate, // Copy struct
 		stderrLog: log.Logger{},
@@ -178,20 +188,25 @@ func (a *APIServer) getTaggedLogger(pachClient *client.APIClient, jobID string,
 	// InputFileID is a single string id for the data from this input, it's used in logs and in
 	// the statsTree
 	result.template.DatumID = a.DatumID(data)
-	if enableStats {
+	if statsStorage != nil {
 		putObjClient, err := pachClient.ObjectAPIClient.PutObject(pachClient.Ctx())
 		if err != nil {
 			return nil, err
 		}
 		result.putObjClient = putObjClient
 		result.eg.Go(func() error {
+			// the first request says how the logs are stored
+			request := &pfs.PutObjectRequest{
+				Compression: statsStorage.compression,
+				Encryption:  statsStorage.encryption,
+			}
 			for msg := range result.msgCh {
 				for _, chunk := range grpcutil.Chunk([]byte(msg), grpcutil.MaxMsgSize/2) {
-					if err := result.putObjClient.Send(&pfs.PutObjectRequest{
-						Value: chunk,
-					}); err != nil && err != io.EOF {
+					request.Value = chunk
+					if err := result.putObjClient.Send(request); err != nil && err != io.EOF {
 						return err
 					}
+					request = &pfs.PutObjectRequest{}
 				}
 				result.objSize += int64(len(msg))
 			}
@@ -304,12 +319,16 @@ func NewAPIServer(pachClient *client.APIClient, etcdClient *etcd.Client, etcdPre
 		jobs:            ppsdb.Jobs(etcdClient, etcdPrefix),
 		pipelines:       ppsdb.Pipelines(etcdClient, etcdPrefix),
 		plans:           col.NewCollection(etcdClient, path.Join(etcdPrefix, planPrefix), nil, &Plan{}, nil, nil),
+		schedulerLimits: ppsdb.SchedulerLimits(etcdClient, etcdPrefix),
+		admissions:      ppsdb.JobAdmissions(etcdClient, etcdPrefix),
 		hashtreeStorage: hashtreeStorage,
+		secrets:         newSecretLoader(pipelineInfo.Transform.Secrets),
 	}
-	logger, err := server.getTaggedLogger(pachClient, "", nil, false)
+	logger, err := server.getTaggedLogger(pachClient, "", nil, nil)
 	if err != nil {
 		return nil, err
 	}
+	go server.secrets.watch(pachClient.Ctx(), logger)
 	resp, err := pachClient.Enterprise.GetState(context.Background(), &enterprise.GetStateRequest{})
 	if err != nil {
 		logger.Logf("failed to get enterprise state with error: %v\n", err)
@@ -540,7 +559,9 @@ func (a *APIServer) reportDeferredUserCodeStats(err error, start time.Time, stat
 }
 
 // Run user code and return the combined output of stdout and stderr.
-func (a *APIServer) runUserCode(ctx context.Context, logger *taggedLogger, environ []string, stats *pps.ProcessStats, rawDatumTimeout *types.Duration) (retErr error) {
+// runUserCode runs the pipeline's user code. Its output is logged, and its
+// stderr is also written to 'stderr', if it's non-nil.
+func (a *APIServer) runUserCode(ctx context.Context, logger *taggedLogger, environ []string, stats *pps.ProcessStats, rawDatumTimeout *types.Duration, stderr io.Writer) (retErr error) {
 	a.reportUserCodeStats(logger)
 	defer func(start time.Time) { a.reportDeferredUserCodeStats(retErr, start, stats, logger) }(time.Now())
 	logger.Logf("beginning to run user code")
@@ -568,6 +589,9 @@ func (a *APIServer) runUserCode(ctx context.Context, logger *taggedLogger, envir
 	}
 	cmd.Stdout = logger.userLogger()
 	cmd.Stderr = logger.userLogger()
+	if stderr != nil {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, stderr)
+	}
 	cmd.Env = environ
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Credential: &syscall.Credential{
@@ -645,7 +669,64 @@ func (a *APIServer) reportUploadStats(start time.Time, stats *pps.ProcessStats,
 	}
 }
 
-func (a *APIServer) uploadOutput(pachClient *client.APIClient, dir string, tag string, logger *taggedLogger, inputs []*Input, stats *pps.ProcessStats, statsTree *hashtree.Ordered) (retErr error) {
+// outputStorage is how the contents of the files that the worker writes to
+// the output repo (including the stats branch) are compressed and encrypted
+// in object storage. Hashtrees hold paths and hashes rather than files'
+// contents, and workers read them from object storage directly, so, like
+// commits' trees, they're stored as they are.
+type outputStorage struct {
+	compression *pfs.CompressionSpec
+	encryption  *pfs.EncryptionSpec
+}
+
+// getOutputStorage returns how the contents of files written to the output
+// repo should be stored: as the repo's settings say, or by pachd's defaults
+// (which pachd passes to workers in their environment), as in PutFile
+func (a *APIServer) getOutputStorage(pachClient *client.APIClient) (*outputStorage, error) {
+	repoInfo, err := pachClient.InspectRepo(a.pipelineInfo.Pipeline.Name)
+	if err != nil {
+		return nil, err
+	}
+	storage := &outputStorage{
+		compression: repoInfo.Compression,
+		encryption:  repoInfo.Encryption,
+	}
+	if storage.compression == nil {
+		if storage.compression, err = pfsserver.CompressionFromEnv(); err != nil {
+			return nil, err
+		}
+	}
+	if storage.encryption == nil {
+		storage.encryption = pfsserver.EncryptionFromEnv()
+	}
+	return storage, nil
+}
+
+// plain returns true if files' contents are stored as they are, in which case
+// the output's files are packed into a single block with PutObjects
+func (s *outputStorage) plain() bool {
+	return s.compression.GetCompression() == pfs.Compression_UNCOMPRESSED && s.encryption.GetKey() == ""
+}
+
+// putFile writes the contents of a file, read from 'r', to objects stored as
+// 's' describes, and returns the BlockRefs of the objects and the file's size
+func (s *outputStorage) putFile(pachClient *client.APIClient, r io.Reader) ([]*pfs.BlockRef, int64, error) {
+	objects, size, err := pachClient.PutObjectSplitEncrypted(r, s.compression, s.encryption)
+	if err != nil {
+		return nil, 0, err
+	}
+	var blockRefs []*pfs.BlockRef
+	for _, object := range objects.Objects {
+		objectInfo, err := pachClient.InspectObject(object.Hash)
+		if err != nil {
+			return nil, 0, err
+		}
+		blockRefs = append(blockRefs, objectInfo.BlockRef)
+	}
+	return blockRefs, size, nil
+}
+
+func (a *APIServer) uploadOutput(pachClient *client.APIClient, storage *outputStorage, dir string, tag string, logger *taggedLogger, inputs []*Input, stats *pps.ProcessStats, statsTree *hashtree.Ordered) (retErr error) {
 	defer a.reportUploadStats(time.Now(), stats, logger)
 	logger.Logf("starting to upload output")
 	defer func(start time.Time) {
@@ -655,16 +736,22 @@ func (a *APIServer) uploadOutput(pachClient *client.APIClient, dir string, tag s
 			logger.Logf("finished uploading output after %v", time.Since(start))
 		}
 	}(time.Now())
-	// Setup client for writing file data
-	putObjsClient, err := pachClient.ObjectAPIClient.PutObjects(pachClient.Ctx())
-	if err != nil {
-		return err
-	}
+	// Setup client for writing file data. PutObjects writes the data as it
+	// is, so if it's compressed or encrypted, each file is written to its own
+	// objects instead.
+	var putObjsClient pfs.ObjectAPI_PutObjectsClient
 	block := &pfs.Block{Hash: uuid.NewWithoutDashes()}
-	if err := putObjsClient.Send(&pfs.PutObjectRequest{
-		Block: block,
-	}); err != nil {
-		return err
+	if storage.plain() {
+		var err error
+		putObjsClient, err = pachClient.ObjectAPIClient.PutObjects(pachClient.Ctx())
+		if err != nil {
+			return err
+		}
+		if err := putObjsClient.Send(&pfs.PutObjectRequest{
+			Block: block,
+		}); err != nil {
+			return err
+		}
 	}
 	outputPath := filepath.Join(dir, "out")
 	buf := grpcutil.GetBuffer()
@@ -793,6 +880,20 @@ func (a *APIServer) uploadOutput(pachClient *client.APIClient, dir string, tag s
 		var size int64
 		h := pfs.NewHash()
 		r := io.TeeReader(f, h)
+		if putObjsClient == nil {
+			blockRefs, size, err := storage.putFile(pachClient, r)
+			if err != nil {
+				return err
+			}
+			n := &hashtree.FileNodeProto{BlockRefs: blockRefs}
+			hash := h.Sum(nil)
+			tree.PutFile(relPath, hash, size, n)
+			if statsTree != nil {
+				statsTree.PutFile(relPath, hash, size, n)
+			}
+			stats.UploadBytes += uint64(size)
+			return nil
+		}
 		// Write local file to object storage block
 		for {
 			n, err := r.Read(buf)
@@ -831,8 +932,10 @@ func (a *APIServer) uploadOutput(pachClient *client.APIClient, dir string, tag s
 	}); err != nil {
 		return fmt.Errorf("error walking output: %v", err)
 	}
-	if err := putObjsClient.CloseSend(); err != nil {
-		return err
+	if putObjsClient != nil {
+		if err := putObjsClient.CloseSend(); err != nil {
+			return err
+		}
 	}
 	w, err := pachClient.PutObjectAsync([]*pfs.Tag{client.NewTag(tag)})
 	if err != nil {
@@ -979,6 +1082,9 @@ type processResult struct {
 	datumsProcessed int64
 	datumsSkipped   int64
 	datumsFailed    int64
+	// skippedDatumIDs are the datums that failed, but were skipped by the
+	// pipeline's DatumFailurePolicy (they're also counted in datumsFailed)
+	skippedDatumIDs []string
 }
 
 type processFunc func(low, high int64) (*processResult, error)
@@ -1072,7 +1178,10 @@ func (a *APIServer) acquireDatums(ctx context.Context, jobID string, plan *Plan,
 							DatumID: processResult.failedDatumID,
 						})
 					}
-					return chunks.Put(fmt.Sprint(high), &ChunkState{State: State_COMPLETE})
+					return chunks.Put(fmt.Sprint(high), &ChunkState{
+						State:           State_COMPLETE,
+						SkippedDatumIDs: processResult.skippedDatumIDs,
+					})
 				}); err != nil {
 					return err
 				}
@@ -1085,7 +1194,7 @@ func (a *APIServer) acquireDatums(ctx context.Context, jobID string, plan *Plan,
 	return nil
 }
 
-func (a *APIServer) mergeDatums(ctx context.Context, pachClient *client.APIClient, jobInfo *pps.JobInfo, jobID string, plan *Plan, logger *taggedLogger, tags []*pfs.Tag, useParentHashTree bool) error {
+func (a *APIServer) mergeDatums(ctx context.Context, pachClient *client.APIClient, jobInfo *pps.JobInfo, jobID string, plan *Plan, logger *taggedLogger, tags []*pfs.Tag, skippedTags []*pfs.Tag, useParentHashTree bool) error {
 	complete := false
 	for !complete {
 		// func to defer cancel in
@@ -1157,7 +1266,7 @@ func (a *APIServer) mergeDatums(ctx context.Context, pachClient *client.APIClien
 				}
 				var tree *pfs.Object
 				var size uint64
-				if jobInfo.DataFailed == 0 {
+				if jobInfo.DataFailed == 0 || jobInfo.DatumFailurePolicy.GetAction() != pps.DatumFailureAction_DATUM_FAIL_JOB {
 					rs, err := a.getHashtrees(ctx, pachClient, objClient, tags, hashtree.NewFilter(plan.Merges, merge))
 					if err != nil {
 						return err
@@ -1184,7 +1293,7 @@ func (a *APIServer) mergeDatums(ctx context.Context, pachClient *client.APIClien
 				var statsSize uint64
 				if a.pipelineInfo.EnableStats {
 					var statsTags []*pfs.Tag
-					for _, tag := range tags {
+					for _, tag := range append(tags, skippedTags...) {
 						statsTags = append(statsTags, client.NewTag(tag.Name+statsTagSuffix))
 					}
 					rs, err := a.getHashtrees(ctx, pachClient, objClient, statsTags, hashtree.NewFilter(plan.Merges, merge))
@@ -1587,13 +1696,23 @@ func (a *APIServer) worker() {
 				}
 				return fmt.Errorf("acquire/process datums for job %s exited with err: %v", jobID, err)
 			}
-			var tags []*pfs.Tag
+			skippedDatums, err := a.skippedDatums(jobCtx, jobID, plan)
+			if err != nil {
+				return err
+			}
+			// Failed datums that were skipped have no output, but they may have
+			// stats
+			var tags, skippedTags []*pfs.Tag
 			for i := 0; i < df.Len(); i++ {
 				files := df.Datum(int(i))
 				datumHash := HashDatum(a.pipelineInfo.Pipeline.Name, a.pipelineInfo.Salt, files)
 				if _, ok := skip[datumHash]; ok && useParentHashTree {
 					continue
 				}
+				if skippedDatums[a.DatumID(files)] {
+					skippedTags = append(skippedTags, client.NewTag(datumHash))
+					continue
+				}
 				tags = append(tags, client.NewTag(datumHash))
 			}
 			skip = nil
@@ -1601,7 +1720,7 @@ func (a *APIServer) worker() {
 			if err != nil {
 				return err
 			}
-			if err := a.mergeDatums(jobCtx, pachClient, jobInfo, jobID, plan, logger, tags, useParentHashTree); err != nil {
+			if err := a.mergeDatums(jobCtx, pachClient, jobInfo, jobID, plan, logger, tags, skippedTags, useParentHashTree); err != nil {
 				if jobCtx.Err() == context.Canceled {
 					continue NextJob // job cancelled--don't restart, just wait for next job
 				}
@@ -1615,6 +1734,23 @@ func (a *APIServer) worker() {
 	})
 }
 
+// skippedDatums returns the IDs of the datums that failed in the job 'jobID',
+// but were skipped by the job's DatumFailurePolicy
+func (a *APIServer) skippedDatums(ctx context.Context, jobID string, plan *Plan) (map[string]bool, error) {
+	chunks := a.chunks(jobID).ReadOnly(ctx)
+	result := make(map[string]bool)
+	for _, high := range plan.Chunks {
+		var chunkState ChunkState
+		if err := chunks.Get(fmt.Sprint(high), &chunkState); err != nil {
+			return nil, err
+		}
+		for _, datumID := range chunkState.SkippedDatumIDs {
+			result[datumID] = true
+		}
+	}
+	return result, nil
+}
+
 // processDatums processes datums from low to high in df, if a datum fails it
 // returns the id of the failed datum it also may return a variety of errors
 // such as network errors.
@@ -1624,9 +1760,14 @@ func (a *APIServer) processDatums(pachClient *client.APIClient, logger *taggedLo
 	if err != nil {
 		return nil, err
 	}
+	storage, err := a.getOutputStorage(pachClient)
+	if err != nil {
+		return nil, err
+	}
 	stats := &pps.ProcessStats{}
 	var statsMu sync.Mutex
 	result := &processResult{}
+	var resultMu sync.Mutex
 	var eg errgroup.Group
 	limiter := limit.New(int(a.pipelineInfo.MaxQueueSize))
 	for i := low; i < high; i++ {
@@ -1639,7 +1780,11 @@ func (a *APIServer) processDatums(pachClient *client.APIClient, logger *taggedLo
 			defer atomic.AddInt64(&a.queueSize, -1)
 
 			data := df.Datum(int(i))
-			logger, err := a.getTaggedLogger(pachClient, jobInfo.Job.ID, data, a.pipelineInfo.EnableStats)
+			var statsStorage *outputStorage
+			if a.pipelineInfo.EnableStats {
+				statsStorage = storage
+			}
+			logger, err := a.getTaggedLogger(pachClient, jobInfo.Job.ID, data, statsStorage)
 			if err != nil {
 				return err
 			}
@@ -1666,7 +1811,7 @@ func (a *APIServer) processDatums(pachClient *client.APIClient, logger *taggedLo
 				// Write job id to stats tree
 				statsTree.PutFile(fmt.Sprintf("job:%s", jobInfo.Job.ID), nil, 0)
 				// Write index in datum factory to stats tree
-				object, size, err := pachClient.PutObject(strings.NewReader(fmt.Sprint(int(i))))
+				object, size, err := pachClient.PutObjectEncrypted(strings.NewReader(fmt.Sprint(int(i))), storage.compression, storage.encryption)
 				if err != nil {
 					return err
 				}
@@ -1680,7 +1825,7 @@ func (a *APIServer) processDatums(pachClient *client.APIClient, logger *taggedLo
 				}
 				statsTree.PutFile("index", h, size, objectInfo.BlockRef)
 				defer func() {
-					if err := a.writeStats(pachClient, objClient, tag, subStats, logger, inputTree, outputTree, statsTree); err != nil && retErr == nil {
+					if err := a.writeStats(pachClient, objClient, storage, tag, subStats, logger, inputTree, outputTree, statsTree); err != nil && retErr == nil {
 						retErr = err
 					}
 				}()
@@ -1689,6 +1834,9 @@ func (a *APIServer) processDatums(pachClient *client.APIClient, logger *taggedLo
 			env := a.userCodeEnv(jobInfo.Job.ID, jobInfo.OutputCommit.ID, data)
 			var dir string
 			var failures int64
+			// stderr is the end of the user code's stderr in the datum's last try,
+			// which is quarantined with the datum if it fails
+			var stderr *tailWriter
 			if err := backoff.RetryNotify(func() error {
 				if isDone(ctx) {
 					return ctx.Err() // timeout or cancelled job--don't run datum
@@ -1749,7 +1897,13 @@ func (a *APIServer) processDatums(pachClient *client.APIClient, logger *taggedLo
 						return err
 					})
 				}
-				if err := a.runUserCode(ctx, logger, env, subStats, jobInfo.DatumTimeout); err != nil {
+				// Secrets are loaded on each try, so that rotated values are used
+				secretEnv, err := a.secrets.env(logger)
+				if err != nil {
+					return fmt.Errorf("error loading secrets: %v", err)
+				}
+				stderr = newTailWriter(quarantinedStderrBytes)
+				if err := a.runUserCode(ctx, logger, append(env, secretEnv...), subStats, jobInfo.DatumTimeout, stderr); err != nil {
 					return fmt.Errorf("error runUserCode: %v", err)
 				}
 				// CleanUp is idempotent so we can call it however many times we want.
@@ -1765,8 +1919,8 @@ func (a *APIServer) processDatums(pachClient *client.APIClient, logger *taggedLo
 				}
 				atomic.AddUint64(&subStats.DownloadBytes, uint64(downSize))
 				a.reportDownloadSizeStats(float64(downSize), logger)
-				return a.uploadOutput(pachClient, dir, tag, logger, data, subStats, outputTree)
-			}, &backoff.ZeroBackOff{}, func(err error, d time.Duration) error {
+				return a.uploadOutput(pachClient, storage, dir, tag, logger, data, subStats, outputTree)
+			}, ppsutil.DatumRetryBackOff(jobInfo.DatumFailurePolicy), func(err error, d time.Duration) error {
 				if isDone(ctx) {
 					return ctx.Err() // timeout or cancelled job, err out and don't retry
 				}
@@ -1774,7 +1928,7 @@ func (a *APIServer) processDatums(pachClient *client.APIClient, logger *taggedLo
 				if failures >= jobInfo.DatumTries {
 					logger.Logf("failed to process datum with error: %+v", err)
 					if statsTree != nil {
-						object, size, err := pachClient.PutObject(strings.NewReader(err.Error()))
+						object, size, err := pachClient.PutObjectEncrypted(strings.NewReader(err.Error()), storage.compression, storage.encryption)
 						if err != nil {
 							logger.stderrLog.Printf("could not put error object: %s\n", err)
 						} else {
@@ -1794,8 +1948,20 @@ func (a *APIServer) processDatums(pachClient *client.APIClient, logger *taggedLo
 				logger.Logf("failed processing datum: %v, retrying in %v", err, d)
 				return nil
 			}); err != nil {
-				result.failedDatumID = a.DatumID(data)
 				atomic.AddInt64(&result.datumsFailed, 1)
+				if isDone(ctx) || jobInfo.DatumFailurePolicy.GetAction() == pps.DatumFailureAction_DATUM_FAIL_JOB {
+					result.failedDatumID = a.DatumID(data)
+					return nil
+				}
+				if jobInfo.DatumFailurePolicy.Action == pps.DatumFailureAction_DATUM_QUARANTINE {
+					if err := a.quarantineDatum(pachClient, jobInfo, data, err, stderr); err != nil {
+						return fmt.Errorf("could not quarantine failed datum: %v", err)
+					}
+				}
+				logger.Logf("skipping failed datum")
+				resultMu.Lock()
+				defer resultMu.Unlock()
+				result.skippedDatumIDs = append(result.skippedDatumIDs, a.DatumID(data))
 				return nil
 			}
 			statsMu.Lock()
@@ -1830,7 +1996,7 @@ func (a *APIServer) processDatums(pachClient *client.APIClient, logger *taggedLo
 	return result, nil
 }
 
-func (a *APIServer) writeStats(pachClient *client.APIClient, objClient obj.Client, tag string, stats *pps.ProcessStats, logger *taggedLogger, inputTree, outputTree *hashtree.Ordered, statsTree *hashtree.Unordered) error {
+func (a *APIServer) writeStats(pachClient *client.APIClient, objClient obj.Client, storage *outputStorage, tag string, stats *pps.ProcessStats, logger *taggedLogger, inputTree, outputTree *hashtree.Ordered, statsTree *hashtree.Unordered) error {
 	// Store stats and add stats file
 	marshaler := &jsonpb.Marshaler{}
 	statsString, err := marshaler.MarshalToString(stats)
@@ -1838,7 +2004,7 @@ func (a *APIServer) writeStats(pachClient *client.APIClient, objClient obj.Clien
 		logger.stderrLog.Printf("could not serialize stats: %s\n", err)
 		return err
 	}
-	object, size, err := pachClient.PutObject(strings.NewReader(statsString))
+	object, size, err := pachClient.PutObjectEncrypted(strings.NewReader(statsString), storage.compression, storage.encryption)
 	if err != nil {
 		logger.stderrLog.Printf("could not put stats object: %s\n", err)
 		return err
@@ -8,6 +8,7 @@ import (
 	"github.com/pachyderm/pachyderm/src/client"
 	"github.com/pachyderm/pachyderm/src/client/pfs"
 	"github.com/pachyderm/pachyderm/src/client/pps"
+	"github.com/pachyderm/pachyderm/src/server/pkg/ppsutil"
 )
 
 // DatumFactory is an interface which allows you to iterate through the datums
@@ -29,8 +30,9 @@ func newAtomDatumFactory(pachClient *client.APIClient, input *pps.AtomInput) (Da
 		return result, nil
 	}
 	fs, err := pachClient.GlobFileStream(pachClient.Ctx(), &pfs.GlobFileRequest{
-		Commit:  client.NewCommit(input.Repo, input.Commit),
-		Pattern: input.Glob,
+		Commit:      client.NewCommit(input.Repo, input.Commit),
+		Pattern:     input.Glob,
+		PatternType: input.GlobType,
 	})
 	if err != nil {
 		return nil, err
@@ -77,19 +79,38 @@ type pfsDatumFactory struct {
 }
 
 func newPFSDatumFactory(pachClient *client.APIClient, input *pps.PFSInput) (DatumFactory, error) {
-	result := &pfsDatumFactory{}
+	glob := input.Glob
+	if input.JoinOn != "" || input.GroupBy != "" {
+		// the glob may have capture groups, which PFS doesn't understand
+		var err error
+		if glob, _, err = ppsutil.CaptureGlob(input.Glob, input.GlobType); err != nil {
+			return nil, err
+		}
+	}
+	inputs, err := globPFSInput(pachClient, input, glob)
+	if err != nil {
+		return nil, err
+	}
+	return &pfsDatumFactory{inputs: inputs}, nil
+}
+
+// globPFSInput returns the files in the PFS input 'input' that match 'glob'
+// (which is the input's glob, minus any capture groups)
+func globPFSInput(pachClient *client.APIClient, input *pps.PFSInput, glob string) ([]*Input, error) {
 	if input.Commit == "" {
 		// this can happen if a pipeline with multiple inputs has been triggered
 		// before all commits have inputs
-		return result, nil
+		return nil, nil
 	}
 	fs, err := pachClient.GlobFileStream(pachClient.Ctx(), &pfs.GlobFileRequest{
-		Commit:  client.NewCommit(input.Repo, input.Commit),
-		Pattern: input.Glob,
+		Commit:      client.NewCommit(input.Repo, input.Commit),
+		Pattern:     glob,
+		PatternType: input.GlobType,
 	})
 	if err != nil {
 		return nil, err
 	}
+	var result []*Input
 	for {
 		fileInfo, err := fs.Recv()
 		if err == io.EOF {
@@ -97,7 +118,7 @@ func newPFSDatumFactory(pachClient *client.APIClient, input *pps.PFSInput) (Datu
 		} else if err != nil {
 			return nil, err
 		}
-		result.inputs = append(result.inputs, &Input{
+		result = append(result, &Input{
 			FileInfo:   fileInfo,
 			Name:       input.Name,
 			Lazy:       input.Lazy,
@@ -108,13 +129,13 @@ func newPFSDatumFactory(pachClient *client.APIClient, input *pps.PFSInput) (Datu
 	// We sort the inputs so that the order is deterministic. Note that it's
 	// not possible for 2 inputs to have the same path so this is guaranteed to
 	// produce a deterministic order.
-	sort.Slice(result.inputs, func(i, j int) bool {
+	sort.Slice(result, func(i, j int) bool {
 		// We sort by descending size first because it can boost performance to
 		// process the biggest datums first.
-		if result.inputs[i].FileInfo.SizeBytes != result.inputs[j].FileInfo.SizeBytes {
-			return result.inputs[i].FileInfo.SizeBytes > result.inputs[j].FileInfo.SizeBytes
+		if result[i].FileInfo.SizeBytes != result[j].FileInfo.SizeBytes {
+			return result[i].FileInfo.SizeBytes > result[j].FileInfo.SizeBytes
 		}
-		return result.inputs[i].FileInfo.File.Path < result.inputs[j].FileInfo.File.Path
+		return result[i].FileInfo.File.Path < result[j].FileInfo.File.Path
 	})
 	return result, nil
 }
@@ -224,6 +245,123 @@ func (d *gitDatumFactory) Datum(i int) []*Input {
 	return []*Input{d.inputs[i]}
 }
 
+// keyedDatumFactory is the datum factory of join and group inputs, whose
+// datums are computed up front
+type keyedDatumFactory struct {
+	datums [][]*Input
+}
+
+func (d *keyedDatumFactory) Len() int {
+	return len(d.datums)
+}
+
+func (d *keyedDatumFactory) Datum(i int) []*Input {
+	return d.datums[i]
+}
+
+// keyedInputs returns the files in the PFS input 'input' by their keys
+// ('template' is the input's join_on or group_by key), and the keys in the
+// order of the input's files
+func keyedInputs(pachClient *client.APIClient, input *pps.PFSInput, template string) (map[string][]*Input, []string, error) {
+	glob, re, err := ppsutil.CaptureGlob(input.Glob, input.GlobType)
+	if err != nil {
+		return nil, nil, err
+	}
+	inputs, err := globPFSInput(pachClient, input, glob)
+	if err != nil {
+		return nil, nil, err
+	}
+	result := make(map[string][]*Input)
+	var keys []string
+	for _, input := range inputs {
+		key, ok := ppsutil.DatumKey(re, template, input.FileInfo.File.Path)
+		if !ok {
+			continue
+		}
+		if _, ok := result[key]; !ok {
+			keys = append(keys, key)
+		}
+		result[key] = append(result[key], input)
+	}
+	return result, keys, nil
+}
+
+// newJoinDatumFactory returns the datum factory of a join input. For each key
+// of the first input's files, its datums are the cross product of the
+// inputs' files with that key, so keys that some inputs don't have produce
+// no datums.
+func newJoinDatumFactory(pachClient *client.APIClient, join []*pps.Input) (DatumFactory, error) {
+	if err := ppsutil.ValidateJoin(join); err != nil {
+		return nil, err
+	}
+	var keys []string
+	var inputs []map[string][]*Input
+	for i, input := range join {
+		byKey, inputKeys, err := keyedInputs(pachClient, input.Pfs, input.Pfs.JoinOn)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			keys = inputKeys
+		}
+		inputs = append(inputs, byKey)
+	}
+	result := &keyedDatumFactory{}
+	for _, key := range keys {
+		datums := [][]*Input{nil}
+		for _, byKey := range inputs {
+			var crossed [][]*Input
+			for _, datum := range datums {
+				for _, input := range byKey[key] {
+					crossed = append(crossed, append(append([]*Input{}, datum...), input))
+				}
+			}
+			datums = crossed
+		}
+		for _, datum := range datums {
+			sortInputs(datum)
+		}
+		result.datums = append(result.datums, datums...)
+	}
+	return result, nil
+}
+
+// newGroupDatumFactory returns the datum factory of a group input, which has
+// one datum for each key, made of all of the inputs' files with that key
+func newGroupDatumFactory(pachClient *client.APIClient, group []*pps.Input) (DatumFactory, error) {
+	if err := ppsutil.ValidateGroup(group); err != nil {
+		return nil, err
+	}
+	groups := make(map[string][]*Input)
+	var keys []string
+	for _, input := range group {
+		byKey, inputKeys, err := keyedInputs(pachClient, input.Pfs, input.Pfs.GroupBy)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range inputKeys {
+			if _, ok := groups[key]; !ok {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], byKey[key]...)
+		}
+	}
+	result := &keyedDatumFactory{}
+	for _, key := range keys {
+		datum := groups[key]
+		// A datum has several files from the same input, so they're also
+		// sorted by path so that the datum's hash is deterministic
+		sort.SliceStable(datum, func(i, j int) bool {
+			if datum[i].Name != datum[j].Name {
+				return datum[i].Name < datum[j].Name
+			}
+			return datum[i].FileInfo.File.Path < datum[j].FileInfo.File.Path
+		})
+		result.datums = append(result.datums, datum)
+	}
+	return result, nil
+}
+
 func newCrossDatumFactory(pachClient *client.APIClient, cross []*pps.Input) (DatumFactory, error) {
 	result := &crossDatumFactory{}
 	for _, input := range cross {
@@ -257,6 +395,10 @@ func NewDatumFactory(pachClient *client.APIClient, input *pps.Input) (DatumFacto
 		return newUnionDatumFactory(pachClient, input.Union)
 	case input.Cross != nil:
 		return newCrossDatumFactory(pachClient, input.Cross)
+	case input.Join != nil:
+		return newJoinDatumFactory(pachClient, input.Join)
+	case input.Group != nil:
+		return newGroupDatumFactory(pachClient, input.Group)
 	case input.Cron != nil:
 		return newCronDatumFactory(pachClient, input.Cron)
 	case input.Git != nil:
@@ -23,9 +23,11 @@ import (
 	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
 	"github.com/pachyderm/pachyderm/src/server/pkg/dlock"
 	"github.com/pachyderm/pachyderm/src/server/pkg/obj"
+	"github.com/pachyderm/pachyderm/src/server/pkg/ppsdb"
 	"github.com/pachyderm/pachyderm/src/server/pkg/ppsutil"
 	filesync "github.com/pachyderm/pachyderm/src/server/pkg/sync"
 	pfs_sync "github.com/pachyderm/pachyderm/src/server/pkg/sync"
+	"github.com/pachyderm/pachyderm/src/server/pkg/watch"
 )
 
 const (
@@ -239,7 +241,7 @@ func (a *APIServer) serviceSpawner(pachClient *client.APIClient) error {
 			return fmt.Errorf("services must have a single datum")
 		}
 		data := df.Datum(0)
-		logger, err := a.getTaggedLogger(pachClient, job.ID, data, false)
+		logger, err := a.getTaggedLogger(pachClient, job.ID, data, nil)
 		puller := filesync.NewPuller()
 		// If this is our second time through the loop cleanup the old data.
 		if dir != "" {
@@ -395,6 +397,43 @@ func (a *APIServer) failedInputs(ctx context.Context, jobInfo *pps.JobInfo) ([]s
 	return failedInputs, vistErr
 }
 
+// waitForAdmission blocks until the PPS scheduler admits the job in 'jobInfo'
+// (i.e. until running it won't exceed the cluster's scheduler limits). It
+// returns immediately if no limits are set, or if the job is already running
+// (e.g. because this worker is recovering from a crash).
+func (a *APIServer) waitForAdmission(ctx context.Context, jobInfo *pps.JobInfo, logger *taggedLogger) error {
+	if jobInfo.State != pps.JobState_JOB_STARTING {
+		return nil
+	}
+	limits := &pps.SchedulerLimits{}
+	if err := a.schedulerLimits.ReadOnly(ctx).Get(ppsdb.SchedulerLimitsKey, limits); err != nil {
+		if col.IsErrNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	watcher, err := a.admissions.ReadOnly(ctx).WatchOne(jobInfo.Job.ID)
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	logger.Logf("waiting for the scheduler to admit job %s", jobInfo.Job.ID)
+	for {
+		select {
+		case e := <-watcher.Watch():
+			switch e.Type {
+			case watch.EventPut:
+				logger.Logf("job %s was admitted by the scheduler", jobInfo.Job.ID)
+				return nil
+			case watch.EventError:
+				return fmt.Errorf("error watching job admission: %v", e.Err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
 // waitJob waits for the job in 'jobInfo' to finish, and then it collects the
 // output from the job's workers and merges it into a commit (and may merge
 // stats into a commit in the stats branch as well)
@@ -498,6 +537,11 @@ func (a *APIServer) waitJob(pachClient *client.APIClient, jobInfo *pps.JobInfo,
 			return err
 		}
 
+		// Don't start the job until the scheduler allows it to run
+		if err := a.waitForAdmission(ctx, jobInfo, logger); err != nil {
+			return err
+		}
+
 		// Create a datum factory pointing at the job's inputs and split up the
 		// input data into chunks
 		df, err := NewDatumFactory(pachClient, jobInfo.Input)
@@ -579,6 +623,7 @@ func (a *APIServer) waitJob(pachClient *client.APIClient, jobInfo *pps.JobInfo,
 		// Watch the chunks in order
 		chunks := a.chunks(jobInfo.Job.ID).ReadOnly(ctx)
 		var failedDatumID string
+		skippedDatums := make(map[string]bool)
 		for _, high := range plan.Chunks {
 			// Watch this chunk's lock and when it's finished, handle the result
 			// (merge chunk output into commit trees, fail if chunk failed, etc)
@@ -604,6 +649,9 @@ func (a *APIServer) waitJob(pachClient *client.APIClient, jobInfo *pps.JobInfo,
 							if chunkState.State == State_FAILED {
 								failedDatumID = chunkState.DatumID
 							}
+							for _, datumID := range chunkState.SkippedDatumIDs {
+								skippedDatums[datumID] = true
+							}
 							break EventLoop
 						}
 					case <-ctx.Done():
@@ -682,11 +730,16 @@ func (a *APIServer) waitJob(pachClient *client.APIClient, jobInfo *pps.JobInfo,
 			})
 			return err
 		}
-		// Write out the datums processed/skipped and merged for this job
+		// Write out the datums processed/skipped and merged for this job. Failed
+		// datums that were skipped are left out, so that the next job processes
+		// them again.
 		buf := &bytes.Buffer{}
 		pbw := pbutil.NewWriter(buf)
 		for i := 0; i < df.Len(); i++ {
 			files := df.Datum(i)
+			if skippedDatums[a.DatumID(files)] {
+				continue
+			}
 			datumHash := HashDatum(a.pipelineInfo.Pipeline.Name, a.pipelineInfo.Salt, files)
 			if _, err := pbw.WriteBytes([]byte(datumHash)); err != nil {
 				return err
@@ -821,7 +874,11 @@ func (a *APIServer) egress(pachClient *client.APIClient, logger *taggedLogger, j
 
 func (a *APIServer) runService(ctx context.Context, logger *taggedLogger) error {
 	return backoff.RetryNotify(func() error {
-		return a.runUserCode(ctx, logger, nil, &pps.ProcessStats{}, nil)
+		secretEnv, err := a.secrets.env(logger)
+		if err != nil {
+			return err
+		}
+		return a.runUserCode(ctx, logger, append(os.Environ(), secretEnv...), &pps.ProcessStats{}, nil, nil)
 	}, backoff.NewInfiniteBackOff(), func(err error, d time.Duration) error {
 		select {
 		case <-ctx.Done():
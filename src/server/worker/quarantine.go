@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+)
+
+// quarantinedStderrBytes is how much of the end of a failed datum's stderr is
+// quarantined with it
+const quarantinedStderrBytes = 64 * 1024
+
+// QuarantinedDatum is the record of a failed datum that's written to
+// /<job ID>/<datum ID> in the quarantine branch of a pipeline's output repo
+// (as JSON), if the pipeline's DatumFailurePolicy quarantines failed datums.
+type QuarantinedDatum struct {
+	Pipeline string `json:"pipeline"`
+	Job      string `json:"job"`
+	DatumID  string `json:"datum_id"`
+	// Error is the error that the datum's last try failed with
+	Error string `json:"error"`
+	// Stderr is the end of the user code's stderr in the datum's last try
+	Stderr string                  `json:"stderr"`
+	Inputs []*QuarantinedDatumFile `json:"inputs"`
+}
+
+// QuarantinedDatumFile is one of a quarantined datum's input files, which can
+// be used to process the datum again
+type QuarantinedDatumFile struct {
+	Name   string `json:"name"`
+	Repo   string `json:"repo"`
+	Commit string `json:"commit"`
+	Path   string `json:"path"`
+}
+
+// quarantineDatum records the datum 'data', which failed with 'datumErr' and
+// wrote 'stderr' (which may be nil), in the quarantine branch of the job's
+// output repo
+func (a *APIServer) quarantineDatum(pachClient *client.APIClient, jobInfo *pps.JobInfo, data []*Input, datumErr error, stderr *tailWriter) error {
+	datumID := a.DatumID(data)
+	record := &QuarantinedDatum{
+		Pipeline: jobInfo.Pipeline.Name,
+		Job:      jobInfo.Job.ID,
+		DatumID:  datumID,
+		Error:    datumErr.Error(),
+	}
+	if stderr != nil {
+		record.Stderr = stderr.String()
+	}
+	for _, input := range data {
+		file := input.FileInfo.File
+		record.Inputs = append(record.Inputs, &QuarantinedDatumFile{
+			Name:   input.Name,
+			Repo:   file.Commit.Repo.Name,
+			Commit: file.Commit.ID,
+			Path:   file.Path,
+		})
+	}
+	buf, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = pachClient.PutFileOverwrite(jobInfo.OutputCommit.Repo.Name, jobInfo.DatumFailurePolicy.QuarantineBranch,
+		path.Join(jobInfo.Job.ID, datumID), bytes.NewReader(buf), 0)
+	return err
+}
+
+// tailWriter is an io.Writer that keeps the last 'max' bytes written to it
+type tailWriter struct {
+	max int
+	buf []byte
+}
+
+func newTailWriter(max int) *tailWriter {
+	return &tailWriter{max: max}
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	if len(p) >= w.max {
+		w.buf = append(w.buf[:0], p[len(p)-w.max:]...)
+		return len(p), nil
+	}
+	if overflow := len(w.buf) + len(p) - w.max; overflow > 0 {
+		w.buf = append(w.buf[:0], w.buf[overflow:]...)
+	}
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+// String returns the bytes that 'w' kept
+func (w *tailWriter) String() string {
+	return string(w.buf)
+}
@@ -0,0 +1,30 @@
+package worker
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+)
+
+func TestTailWriter(t *testing.T) {
+	w := newTailWriter(10)
+	fmt.Fprint(w, "abc")
+	require.Equal(t, "abc", w.String())
+	fmt.Fprint(w, "defghij")
+	require.Equal(t, "abcdefghij", w.String())
+	fmt.Fprint(w, "kl")
+	require.Equal(t, "cdefghijkl", w.String())
+	fmt.Fprint(w, "0123456789abcdef")
+	require.Equal(t, "6789abcdef", w.String())
+
+	// Many small writes
+	w = newTailWriter(100)
+	n, err := io.Copy(w, iotest.OneByteReader(strings.NewReader(strings.Repeat("x", 1000)+"end")))
+	require.NoError(t, err)
+	require.Equal(t, int64(1003), n)
+	require.Equal(t, strings.Repeat("x", 97)+"end", w.String())
+}
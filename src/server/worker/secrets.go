@@ -0,0 +1,260 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+)
+
+// secretRefreshPeriod is how often workers re-read the pipeline's Vault
+// secrets
+const secretRefreshPeriod = time.Minute
+
+// kubeTokenPath is where kubernetes mounts the token of the worker's service
+// account, which workers log in to Vault with
+const kubeTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// secretLoader loads the current values of the pipeline's secrets that can
+// change while the worker runs: secrets in Vault, and kubernetes secrets
+// loaded into env vars (as kubernetes never updates a pod's env vars, workers
+// re-read them from where they're mounted under client.PPSSecretsPrefix).
+// Kubernetes secrets with mount paths need no help, as kubernetes updates the
+// mounted files itself.
+type secretLoader struct {
+	secrets []*pps.Secret
+	// secretsDir is where the kubernetes secrets loaded into env vars are
+	// mounted
+	secretsDir string
+	// readVault reads a secret from Vault
+	readVault func(*pps.VaultSecret) (map[string]string, error)
+
+	mu sync.Mutex
+	// vaultData is the data of each Vault secret, by its index in 'secrets',
+	// as of 'refreshed'
+	vaultData map[int]map[string]string
+	refreshed time.Time
+}
+
+func newSecretLoader(secrets []*pps.Secret) *secretLoader {
+	return &secretLoader{
+		secrets:    secrets,
+		secretsDir: client.PPSSecretsPrefix,
+		readVault:  readVaultSecret,
+		vaultData:  make(map[int]map[string]string),
+	}
+}
+
+// hasVault returns true if any of the pipeline's secrets are in Vault
+func (l *secretLoader) hasVault() bool {
+	for _, secret := range l.secrets {
+		if secret.Vault != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// refresh re-reads the Vault secrets if they haven't been read in the last
+// secretRefreshPeriod, and writes the values of those with mount paths to
+// their mount paths. If a secret can't be read, the secrets' old values are
+// kept (if they were read before) and the error is returned.
+func (l *secretLoader) refresh() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.hasVault() || time.Since(l.refreshed) < secretRefreshPeriod {
+		return nil
+	}
+	vaultData := make(map[int]map[string]string)
+	for i, secret := range l.secrets {
+		if secret.Vault == nil {
+			continue
+		}
+		data, err := l.readVault(secret.Vault)
+		if err != nil {
+			return fmt.Errorf("could not read Vault secret %s: %v", secret.Vault.Path, err)
+		}
+		vaultData[i] = data
+	}
+	for i, data := range vaultData {
+		if mountPath := l.secrets[i].MountPath; mountPath != "" {
+			if err := writeSecretFiles(mountPath, data); err != nil {
+				return fmt.Errorf("could not write Vault secret %s to %s: %v", l.secrets[i].Vault.Path, mountPath, err)
+			}
+		}
+	}
+	l.vaultData = vaultData
+	l.refreshed = time.Now()
+	return nil
+}
+
+// env returns the env vars that the secrets are loaded into, with the
+// secrets' current values. If the Vault secrets can't be re-read, their last
+// values are used.
+func (l *secretLoader) env(logger *taggedLogger) ([]string, error) {
+	if err := l.refresh(); err != nil {
+		l.mu.Lock()
+		read := !l.refreshed.IsZero()
+		l.mu.Unlock()
+		if !read {
+			return nil, err
+		}
+		logger.Logf("using the last values of the pipeline's Vault secrets: %v", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var result []string
+	for i, secret := range l.secrets {
+		if secret.EnvVar == "" {
+			continue
+		}
+		if secret.Vault != nil {
+			value, ok := l.vaultData[i][secret.Key]
+			if !ok {
+				return nil, fmt.Errorf("Vault secret %s has no key %s", secret.Vault.Path, secret.Key)
+			}
+			result = append(result, fmt.Sprintf("%s=%s", secret.EnvVar, value))
+			continue
+		}
+		value, err := ioutil.ReadFile(filepath.Join(l.secretsDir, secret.Name, secret.Key))
+		if err != nil {
+			if os.IsNotExist(err) {
+				// The secret isn't mounted (because the worker was created by an
+				// older version of pachd), so its value in the env is used
+				continue
+			}
+			return nil, err
+		}
+		result = append(result, fmt.Sprintf("%s=%s", secret.EnvVar, value))
+	}
+	return result, nil
+}
+
+// watch refreshes the Vault secrets every secretRefreshPeriod until 'ctx' is
+// done, so that the files at their mount paths stay current between datums
+// (and while services run)
+func (l *secretLoader) watch(ctx context.Context, logger *taggedLogger) {
+	if !l.hasVault() {
+		return
+	}
+	ticker := time.NewTicker(secretRefreshPeriod)
+	defer ticker.Stop()
+	for {
+		if err := l.refresh(); err != nil {
+			logger.Logf("error refreshing the pipeline's Vault secrets: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// readVaultSecret logs in to Vault with the worker's service account and
+// returns the data of the secret 'spec'
+func readVaultSecret(spec *pps.VaultSecret) (map[string]string, error) {
+	config := vault.DefaultConfig()
+	if spec.Address != "" {
+		config.Address = spec.Address
+	}
+	vaultClient, err := vault.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("could not create vault client: %v", err)
+	}
+	jwt, err := ioutil.ReadFile(kubeTokenPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the worker's service account token: %v", err)
+	}
+	authPath := spec.AuthPath
+	if authPath == "" {
+		authPath = "kubernetes"
+	}
+	login, err := vaultClient.Logical().Write(path.Join("auth", authPath, "login"), map[string]interface{}{
+		"role": spec.Role,
+		"jwt":  string(jwt),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not log in to vault as role %s: %v", spec.Role, err)
+	}
+	if login == nil || login.Auth == nil {
+		return nil, fmt.Errorf("empty response logging in to vault as role %s", spec.Role)
+	}
+	vaultClient.SetToken(login.Auth.ClientToken)
+	secret, err := vaultClient.Logical().Read(spec.Path)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("secret not found")
+	}
+	return vaultSecretData(secret.Data)
+}
+
+// vaultSecretData returns the keys and values in the data of a Vault secret.
+// Version 2 of Vault's KV secrets engine nests them under "data", next to the
+// secret's "metadata".
+func vaultSecretData(data map[string]interface{}) (map[string]string, error) {
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+	result := make(map[string]string)
+	for key, value := range data {
+		if s, ok := value.(string); ok {
+			result[key] = s
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = string(encoded)
+	}
+	return result, nil
+}
+
+// writeSecretFiles writes each value in 'data' to a file named after its key
+// in 'dir', like kubernetes does for secrets, and removes the files of keys
+// that aren't in 'data'. Files are replaced by renaming, so user code never
+// reads a partially written value.
+func writeSecretFiles(dir string, data map[string]string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for key, value := range data {
+		if key == "" || key == "." || key == ".." || filepath.Base(key) != key {
+			return fmt.Errorf("invalid key %q", key)
+		}
+		tmp := filepath.Join(dir, "."+key+".tmp")
+		if err := ioutil.WriteFile(tmp, []byte(value), 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, filepath.Join(dir, key)); err != nil {
+			return err
+		}
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if _, ok := data[file.Name()]; !ok && !file.IsDir() {
+			if err := os.Remove(filepath.Join(dir, file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
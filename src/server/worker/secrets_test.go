@@ -0,0 +1,128 @@
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/client/pkg/require"
+	"github.com/pachyderm/pachyderm/src/client/pps"
+)
+
+func TestVaultSecretData(t *testing.T) {
+	// KV version 1
+	data, err := vaultSecretData(map[string]interface{}{
+		"user": "admin",
+		"port": json.Number("5432"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"user": "admin", "port": "5432"}, data)
+
+	// KV version 2
+	data, err = vaultSecretData(map[string]interface{}{
+		"data":     map[string]interface{}{"password": "hunter2"},
+		"metadata": map[string]interface{}{"version": json.Number("3")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"password": "hunter2"}, data)
+
+	// A KV version 1 secret with a key named "data"
+	data, err = vaultSecretData(map[string]interface{}{
+		"data": map[string]interface{}{"a": "b"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"data": `{"a":"b"}`}, data)
+}
+
+func TestWriteSecretFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, writeSecretFiles(dir, map[string]string{"user": "admin", "password": "hunter2"}))
+	requireSecretFiles(t, dir, map[string]string{"user": "admin", "password": "hunter2"})
+
+	// Rotated values replace the old ones, and removed keys are removed
+	require.NoError(t, writeSecretFiles(dir, map[string]string{"password": "correcthorse"}))
+	requireSecretFiles(t, dir, map[string]string{"password": "correcthorse"})
+
+	require.YesError(t, writeSecretFiles(dir, map[string]string{"../escape": "x"}))
+	require.YesError(t, writeSecretFiles(dir, map[string]string{"..": "x"}))
+}
+
+func TestSecretLoaderEnv(t *testing.T) {
+	secretsDir, err := ioutil.TempDir("", "pach-secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(secretsDir)
+	mountDir, err := ioutil.TempDir("", "vault")
+	require.NoError(t, err)
+	defer os.RemoveAll(mountDir)
+	require.NoError(t, os.MkdirAll(filepath.Join(secretsDir, "db"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(secretsDir, "db", "password"), []byte("hunter2"), 0644))
+
+	vaultData := map[string]string{"token": "abc"}
+	loader := newSecretLoader([]*pps.Secret{{
+		Name:   "db",
+		Key:    "password",
+		EnvVar: "DB_PASSWORD",
+	}, {
+		// Not mounted, so kubernetes's value in the env is used
+		Name:   "old",
+		Key:    "key",
+		EnvVar: "OLD",
+	}, {
+		Key:       "token",
+		EnvVar:    "API_TOKEN",
+		MountPath: mountDir,
+		Vault:     &pps.VaultSecret{Path: "secret/data/api", Role: "pipeline"},
+	}})
+	loader.secretsDir = secretsDir
+	loader.readVault = func(spec *pps.VaultSecret) (map[string]string, error) {
+		require.Equal(t, "secret/data/api", spec.Path)
+		return vaultData, nil
+	}
+
+	env, err := loader.env(nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"DB_PASSWORD=hunter2", "API_TOKEN=abc"}, env)
+	requireSecretFiles(t, mountDir, map[string]string{"token": "abc"})
+
+	// Rotate both secrets
+	require.NoError(t, ioutil.WriteFile(filepath.Join(secretsDir, "db", "password"), []byte("correcthorse"), 0644))
+	vaultData = map[string]string{"token": "def"}
+	loader.refreshed = time.Time{}
+	env, err = loader.env(nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"DB_PASSWORD=correcthorse", "API_TOKEN=def"}, env)
+	requireSecretFiles(t, mountDir, map[string]string{"token": "def"})
+
+	// A missing key is an error
+	vaultData = map[string]string{"other": "x"}
+	loader.refreshed = time.Time{}
+	_, err = loader.env(nil)
+	require.YesError(t, err)
+
+	// Vault secrets that have never been read are an error
+	loader = newSecretLoader(loader.secrets[2:])
+	loader.readVault = func(*pps.VaultSecret) (map[string]string, error) {
+		return nil, fmt.Errorf("permission denied")
+	}
+	_, err = loader.env(nil)
+	require.YesError(t, err)
+}
+
+func requireSecretFiles(t *testing.T, dir string, expected map[string]string) {
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	actual := make(map[string]string)
+	for _, file := range files {
+		value, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		require.NoError(t, err)
+		actual[file.Name()] = string(value)
+	}
+	require.Equal(t, expected, actual)
+}
@@ -52,7 +52,7 @@ func (x State) String() string {
 	return proto.EnumName(State_name, int32(x))
 }
 func (State) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_worker_service_43bc3c346f155e64, []int{0}
+	return fileDescriptor_worker_service_f0c58a8f1980e780, []int{0}
 }
 
 type Input struct {
@@ -72,7 +72,7 @@ func (m *Input) Reset()         { *m = Input{} }
 func (m *Input) String() string { return proto.CompactTextString(m) }
 func (*Input) ProtoMessage()    {}
 func (*Input) Descriptor() ([]byte, []int) {
-	return fileDescriptor_worker_service_43bc3c346f155e64, []int{0}
+	return fileDescriptor_worker_service_f0c58a8f1980e780, []int{0}
 }
 func (m *Input) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -162,7 +162,7 @@ func (m *CancelRequest) Reset()         { *m = CancelRequest{} }
 func (m *CancelRequest) String() string { return proto.CompactTextString(m) }
 func (*CancelRequest) ProtoMessage()    {}
 func (*CancelRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_worker_service_43bc3c346f155e64, []int{1}
+	return fileDescriptor_worker_service_f0c58a8f1980e780, []int{1}
 }
 func (m *CancelRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -216,7 +216,7 @@ func (m *CancelResponse) Reset()         { *m = CancelResponse{} }
 func (m *CancelResponse) String() string { return proto.CompactTextString(m) }
 func (*CancelResponse) ProtoMessage()    {}
 func (*CancelResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_worker_service_43bc3c346f155e64, []int{2}
+	return fileDescriptor_worker_service_f0c58a8f1980e780, []int{2}
 }
 func (m *CancelResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -253,8 +253,11 @@ func (m *CancelResponse) GetSuccess() bool {
 }
 
 type ChunkState struct {
-	State                State    `protobuf:"varint,1,opt,name=state,proto3,enum=worker.State" json:"state,omitempty"`
-	DatumID              string   `protobuf:"bytes,2,opt,name=datum_id,json=datumId,proto3" json:"datum_id,omitempty"`
+	State   State  `protobuf:"varint,1,opt,name=state,proto3,enum=worker.State" json:"state,omitempty"`
+	DatumID string `protobuf:"bytes,2,opt,name=datum_id,json=datumId,proto3" json:"datum_id,omitempty"`
+	// skipped_datum_ids are the datums in the chunk that failed, but were
+	// skipped by the pipeline's DatumFailurePolicy
+	SkippedDatumIDs      []string `protobuf:"bytes,3,rep,name=skipped_datum_ids,json=skippedDatumIds,proto3" json:"skipped_datum_ids,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -264,7 +267,7 @@ func (m *ChunkState) Reset()         { *m = ChunkState{} }
 func (m *ChunkState) String() string { return proto.CompactTextString(m) }
 func (*ChunkState) ProtoMessage()    {}
 func (*ChunkState) Descriptor() ([]byte, []int) {
-	return fileDescriptor_worker_service_43bc3c346f155e64, []int{3}
+	return fileDescriptor_worker_service_f0c58a8f1980e780, []int{3}
 }
 func (m *ChunkState) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -307,6 +310,13 @@ func (m *ChunkState) GetDatumID() string {
 	return ""
 }
 
+func (m *ChunkState) GetSkippedDatumIDs() []string {
+	if m != nil {
+		return m.SkippedDatumIDs
+	}
+	return nil
+}
+
 type MergeState struct {
 	State                State       `protobuf:"varint,1,opt,name=state,proto3,enum=worker.State" json:"state,omitempty"`
 	Tree                 *pfs.Object `protobuf:"bytes,2,opt,name=tree,proto3" json:"tree,omitempty"`
@@ -322,7 +332,7 @@ func (m *MergeState) Reset()         { *m = MergeState{} }
 func (m *MergeState) String() string { return proto.CompactTextString(m) }
 func (*MergeState) ProtoMessage()    {}
 func (*MergeState) Descriptor() ([]byte, []int) {
-	return fileDescriptor_worker_service_43bc3c346f155e64, []int{4}
+	return fileDescriptor_worker_service_f0c58a8f1980e780, []int{4}
 }
 func (m *MergeState) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -398,7 +408,7 @@ func (m *Plan) Reset()         { *m = Plan{} }
 func (m *Plan) String() string { return proto.CompactTextString(m) }
 func (*Plan) ProtoMessage()    {}
 func (*Plan) Descriptor() ([]byte, []int) {
-	return fileDescriptor_worker_service_43bc3c346f155e64, []int{5}
+	return fileDescriptor_worker_service_f0c58a8f1980e780, []int{5}
 }
 func (m *Plan) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -734,6 +744,21 @@ func (m *ChunkState) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintWorkerService(dAtA, i, uint64(len(m.DatumID)))
 		i += copy(dAtA[i:], m.DatumID)
 	}
+	if len(m.SkippedDatumIDs) > 0 {
+		for _, s := range m.SkippedDatumIDs {
+			dAtA[i] = 0x1a
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
 	if m.XXX_unrecognized != nil {
 		i += copy(dAtA[i:], m.XXX_unrecognized)
 	}
@@ -937,6 +962,12 @@ func (m *ChunkState) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovWorkerService(uint64(l))
 	}
+	if len(m.SkippedDatumIDs) > 0 {
+		for _, s := range m.SkippedDatumIDs {
+			l = len(s)
+			n += 1 + l + sovWorkerService(uint64(l))
+		}
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -1508,6 +1539,35 @@ func (m *ChunkState) Unmarshal(dAtA []byte) error {
 			}
 			m.DatumID = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SkippedDatumIDs", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowWorkerService
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthWorkerService
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SkippedDatumIDs = append(m.SkippedDatumIDs, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipWorkerService(dAtA[iNdEx:])
@@ -1953,51 +2013,54 @@ var (
 )
 
 func init() {
-	proto.RegisterFile("server/worker/worker_service.proto", fileDescriptor_worker_service_43bc3c346f155e64)
-}
-
-var fileDescriptor_worker_service_43bc3c346f155e64 = []byte{
-	// 669 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x94, 0xcd, 0x6e, 0xd3, 0x40,
-	0x10, 0xc7, 0x63, 0x92, 0x38, 0xc9, 0xa4, 0xa9, 0xc2, 0x0a, 0x2a, 0xab, 0x88, 0x24, 0xb8, 0x12,
-	0x8a, 0x72, 0x70, 0xaa, 0x22, 0x90, 0x38, 0x92, 0x8f, 0x56, 0x46, 0xfd, 0xd2, 0xb6, 0x15, 0x82,
-	0x8b, 0x65, 0x3b, 0x1b, 0xc7, 0xad, 0xed, 0x35, 0xde, 0x35, 0x28, 0x7d, 0x12, 0xde, 0x08, 0x8e,
-	0x3c, 0x41, 0x85, 0xc2, 0x91, 0x97, 0x40, 0xbb, 0x1b, 0xd3, 0x16, 0x4e, 0x1c, 0xac, 0xcc, 0xfc,
-	0x66, 0xfc, 0xf7, 0x7c, 0xec, 0x06, 0x4c, 0x46, 0xb2, 0x4f, 0x24, 0x1b, 0x7e, 0xa6, 0xd9, 0xd5,
-	0x9f, 0x1f, 0x47, 0xc0, 0xd0, 0x27, 0x56, 0x9a, 0x51, 0x4e, 0x91, 0xae, 0xe8, 0xf6, 0x23, 0x3f,
-	0x0a, 0x49, 0xc2, 0x87, 0xe9, 0x9c, 0x89, 0x47, 0x45, 0x6f, 0x69, 0xca, 0xc4, 0x53, 0xd0, 0x80,
-	0x06, 0x54, 0x9a, 0x43, 0x61, 0xad, 0xe9, 0x93, 0x80, 0xd2, 0x20, 0x22, 0x43, 0xe9, 0x79, 0xf9,
-	0x7c, 0x48, 0xe2, 0x94, 0x2f, 0x55, 0xd0, 0xfc, 0xa5, 0x41, 0xd5, 0x4e, 0xd2, 0x9c, 0xa3, 0x01,
-	0x34, 0xe6, 0x61, 0x44, 0x9c, 0x30, 0x99, 0x53, 0x43, 0xeb, 0x69, 0xfd, 0xe6, 0x5e, 0xcb, 0x12,
-	0x5f, 0xdc, 0x0f, 0x23, 0x62, 0x27, 0x73, 0x8a, 0xeb, 0xf3, 0xb5, 0x85, 0x10, 0x54, 0x12, 0x37,
-	0x26, 0xc6, 0x83, 0x9e, 0xd6, 0x6f, 0x60, 0x69, 0x0b, 0x16, 0xb9, 0xd7, 0x4b, 0xa3, 0xdc, 0xd3,
-	0xfa, 0x75, 0x2c, 0x6d, 0xb4, 0x05, 0xba, 0x97, 0xb9, 0x89, 0xbf, 0x30, 0x2a, 0x32, 0x73, 0xed,
-	0xa1, 0x5d, 0x68, 0xa5, 0x6e, 0x46, 0x12, 0xee, 0xf8, 0x34, 0x8e, 0x43, 0x6e, 0x54, 0xe5, 0xf7,
-	0x9a, 0xf2, 0x7b, 0x63, 0x89, 0xf0, 0x86, 0xca, 0x50, 0x1e, 0xda, 0x81, 0x5a, 0x10, 0x72, 0x27,
-	0xcf, 0x22, 0x43, 0x17, 0x52, 0x23, 0x58, 0xdd, 0x74, 0xf5, 0x83, 0x90, 0x5f, 0xe0, 0x43, 0xac,
-	0x07, 0x21, 0xbf, 0xc8, 0x22, 0xd4, 0x85, 0xa6, 0xec, 0xcd, 0x11, 0x85, 0x32, 0xa3, 0x26, 0x2b,
-	0x01, 0x89, 0x44, 0x13, 0xcc, 0x3c, 0x87, 0xd6, 0xd8, 0x4d, 0x7c, 0x12, 0x61, 0xf2, 0x31, 0x27,
-	0x8c, 0xa3, 0x67, 0xb0, 0x31, 0x73, 0xb9, 0x2b, 0x5e, 0xe0, 0x24, 0x63, 0x86, 0xd6, 0x2b, 0xf7,
-	0x1b, 0xb8, 0x29, 0xd8, 0xbe, 0x42, 0xa8, 0x07, 0xfa, 0x25, 0xf5, 0x9c, 0x70, 0xa6, 0xba, 0x1d,
-	0x35, 0x56, 0x37, 0xdd, 0xea, 0x5b, 0xea, 0xd9, 0x13, 0x5c, 0xbd, 0xa4, 0x9e, 0x3d, 0x33, 0x07,
-	0xb0, 0x59, 0xa8, 0xb2, 0x94, 0x26, 0x8c, 0x20, 0x03, 0x6a, 0x2c, 0xf7, 0x7d, 0xc2, 0x98, 0x9c,
-	0x64, 0x1d, 0x17, 0xae, 0xf9, 0x1e, 0x60, 0xbc, 0xc8, 0x93, 0xab, 0x33, 0xee, 0x72, 0x82, 0x76,
-	0xa0, 0xca, 0x84, 0x21, 0xb3, 0x36, 0xf7, 0x5a, 0x96, 0x5a, 0xba, 0x25, 0xa3, 0x58, 0xc5, 0xd0,
-	0x73, 0xa8, 0xcf, 0x5c, 0x9e, 0xc7, 0xb7, 0x25, 0x34, 0x57, 0x37, 0xdd, 0xda, 0x44, 0x30, 0x7b,
-	0x82, 0x6b, 0x32, 0x68, 0xcf, 0xcc, 0xaf, 0x1a, 0xc0, 0x11, 0xc9, 0x02, 0xf2, 0x1f, 0xda, 0x5d,
-	0xa8, 0xf0, 0x8c, 0xa8, 0x45, 0x16, 0xf3, 0x3f, 0xf1, 0x2e, 0x89, 0xcf, 0xb1, 0x0c, 0xa0, 0xa7,
-	0x00, 0x2c, 0xbc, 0x26, 0x8e, 0xb7, 0xe4, 0x84, 0xc9, 0xdd, 0x56, 0x70, 0x43, 0x90, 0x91, 0x00,
-	0x68, 0x00, 0x20, 0x84, 0x98, 0x23, 0x55, 0x2a, 0xff, 0xaa, 0x34, 0x64, 0xf8, 0x5c, 0x48, 0xf5,
-	0xa1, 0xad, 0x72, 0xef, 0x08, 0x56, 0xa5, 0xe0, 0xa6, 0xe4, 0x67, 0x85, 0xaa, 0xf9, 0x0a, 0x2a,
-	0xa7, 0x91, 0x9b, 0x88, 0xe3, 0xe3, 0x8b, 0x61, 0xa9, 0xbd, 0x94, 0xf1, 0xda, 0x13, 0x3c, 0x16,
-	0x8d, 0x32, 0x59, 0x77, 0x19, 0xaf, 0xbd, 0x81, 0x05, 0x55, 0xd5, 0x7b, 0x13, 0x6a, 0xf8, 0xe2,
-	0xf8, 0xd8, 0x3e, 0x3e, 0x68, 0x97, 0xd0, 0x06, 0xd4, 0xc7, 0x27, 0x47, 0xa7, 0x87, 0xd3, 0xf3,
-	0x69, 0x5b, 0x43, 0x00, 0xfa, 0xfe, 0x1b, 0xfb, 0x70, 0x3a, 0x69, 0x97, 0xf7, 0xae, 0x41, 0x7f,
-	0x27, 0x87, 0x82, 0x5e, 0x82, 0x2e, 0xde, 0xcc, 0x19, 0xda, 0xb2, 0xd4, 0x75, 0xb1, 0x8a, 0xeb,
-	0x62, 0x4d, 0xc5, 0xf9, 0xd9, 0x7e, 0x68, 0x89, 0x7b, 0xa6, 0xd2, 0x55, 0xaa, 0x59, 0x42, 0xaf,
-	0x41, 0x57, 0x9b, 0x47, 0x8f, 0x8b, 0xf1, 0xde, 0x3b, 0x5f, 0xdb, 0x5b, 0x7f, 0x63, 0x75, 0x40,
-	0xcc, 0xd2, 0x68, 0xf4, 0x6d, 0xd5, 0xd1, 0xbe, 0xaf, 0x3a, 0xda, 0x8f, 0x55, 0x47, 0xfb, 0xf2,
-	0xb3, 0x53, 0xfa, 0xb0, 0x1b, 0x84, 0x7c, 0x91, 0x7b, 0x96, 0x4f, 0xe3, 0x61, 0xea, 0xfa, 0x8b,
-	0xe5, 0x8c, 0x64, 0x77, 0x2d, 0x96, 0xf9, 0xc3, 0x7b, 0x7f, 0x1c, 0x9e, 0x2e, 0x6b, 0x7c, 0xf1,
-	0x3b, 0x00, 0x00, 0xff, 0xff, 0x2f, 0x29, 0x6d, 0xb9, 0x50, 0x04, 0x00, 0x00,
+	proto.RegisterFile("server/worker/worker_service.proto", fileDescriptor_worker_service_f0c58a8f1980e780)
+}
+
+var fileDescriptor_worker_service_f0c58a8f1980e780 = []byte{
+	// 709 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x94, 0xcd, 0x6e, 0xdb, 0x46,
+	0x10, 0xc7, 0xc5, 0x4a, 0xa2, 0xa4, 0x91, 0x65, 0xcb, 0xdb, 0xd6, 0x20, 0x5c, 0x54, 0x52, 0x69,
+	0xa0, 0x10, 0x74, 0xa0, 0x0c, 0x17, 0x2d, 0xd0, 0x53, 0x51, 0x7d, 0xd8, 0x60, 0xe0, 0x2f, 0xac,
+	0x6d, 0x04, 0xc8, 0x85, 0xe0, 0xc7, 0x8a, 0xa2, 0x4d, 0x71, 0x19, 0xee, 0x32, 0x81, 0xfc, 0x24,
+	0x41, 0x5e, 0x28, 0x39, 0xe6, 0x09, 0x84, 0x40, 0x39, 0xe6, 0x25, 0x82, 0xdd, 0x15, 0x63, 0x3b,
+	0x39, 0xe5, 0x40, 0x68, 0xe6, 0x37, 0xb3, 0xff, 0x9d, 0xd9, 0x9d, 0x15, 0x98, 0x8c, 0x64, 0xaf,
+	0x48, 0x36, 0x7c, 0x4d, 0xb3, 0xbb, 0xaf, 0x3f, 0x8e, 0x80, 0x91, 0x4f, 0xac, 0x34, 0xa3, 0x9c,
+	0x22, 0x5d, 0xd1, 0xfd, 0x5f, 0xfc, 0x38, 0x22, 0x09, 0x1f, 0xa6, 0x33, 0x26, 0x3e, 0x15, 0x7d,
+	0xa0, 0x29, 0x13, 0x5f, 0x41, 0x43, 0x1a, 0x52, 0x69, 0x0e, 0x85, 0xb5, 0xa1, 0xbf, 0x85, 0x94,
+	0x86, 0x31, 0x19, 0x4a, 0xcf, 0xcb, 0x67, 0x43, 0xb2, 0x48, 0xf9, 0x52, 0x05, 0xcd, 0xcf, 0x1a,
+	0x54, 0xed, 0x24, 0xcd, 0x39, 0x1a, 0x40, 0x63, 0x16, 0xc5, 0xc4, 0x89, 0x92, 0x19, 0x35, 0xb4,
+	0x9e, 0xd6, 0x6f, 0x1e, 0xb5, 0x2c, 0xb1, 0xe3, 0x71, 0x14, 0x13, 0x3b, 0x99, 0x51, 0x5c, 0x9f,
+	0x6d, 0x2c, 0x84, 0xa0, 0x92, 0xb8, 0x0b, 0x62, 0xfc, 0xd4, 0xd3, 0xfa, 0x0d, 0x2c, 0x6d, 0xc1,
+	0x62, 0xf7, 0x7e, 0x69, 0x94, 0x7b, 0x5a, 0xbf, 0x8e, 0xa5, 0x8d, 0xf6, 0x40, 0xf7, 0x32, 0x37,
+	0xf1, 0xe7, 0x46, 0x45, 0x66, 0x6e, 0x3c, 0x74, 0x08, 0xad, 0xd4, 0xcd, 0x48, 0xc2, 0x1d, 0x9f,
+	0x2e, 0x16, 0x11, 0x37, 0xaa, 0x72, 0xbf, 0xa6, 0xdc, 0x6f, 0x2c, 0x11, 0xde, 0x52, 0x19, 0xca,
+	0x43, 0x07, 0x50, 0x0b, 0x23, 0xee, 0xe4, 0x59, 0x6c, 0xe8, 0x42, 0x6a, 0x04, 0xeb, 0x55, 0x57,
+	0x3f, 0x89, 0xf8, 0x0d, 0x3e, 0xc5, 0x7a, 0x18, 0xf1, 0x9b, 0x2c, 0x46, 0x5d, 0x68, 0xca, 0xde,
+	0x1c, 0x51, 0x28, 0x33, 0x6a, 0xb2, 0x12, 0x90, 0x48, 0x34, 0xc1, 0xcc, 0x6b, 0x68, 0x8d, 0xdd,
+	0xc4, 0x27, 0x31, 0x26, 0x2f, 0x73, 0xc2, 0x38, 0xfa, 0x03, 0xb6, 0x02, 0x97, 0xbb, 0x62, 0x01,
+	0x27, 0x19, 0x33, 0xb4, 0x5e, 0xb9, 0xdf, 0xc0, 0x4d, 0xc1, 0x8e, 0x15, 0x42, 0x3d, 0xd0, 0x6f,
+	0xa9, 0xe7, 0x44, 0x81, 0xea, 0x76, 0xd4, 0x58, 0xaf, 0xba, 0xd5, 0x67, 0xd4, 0xb3, 0x27, 0xb8,
+	0x7a, 0x4b, 0x3d, 0x3b, 0x30, 0x07, 0xb0, 0x5d, 0xa8, 0xb2, 0x94, 0x26, 0x8c, 0x20, 0x03, 0x6a,
+	0x2c, 0xf7, 0x7d, 0xc2, 0x98, 0x3c, 0xc9, 0x3a, 0x2e, 0x5c, 0xf3, 0xad, 0x06, 0x30, 0x9e, 0xe7,
+	0xc9, 0xdd, 0x15, 0x77, 0x39, 0x41, 0x07, 0x50, 0x65, 0xc2, 0x90, 0x69, 0xdb, 0x47, 0x2d, 0x4b,
+	0xdd, 0xba, 0x25, 0xa3, 0x58, 0xc5, 0xd0, 0x9f, 0x50, 0x0f, 0x5c, 0x9e, 0x2f, 0x1e, 0x6a, 0x68,
+	0xae, 0x57, 0xdd, 0xda, 0x44, 0x30, 0x7b, 0x82, 0x6b, 0x32, 0x68, 0x07, 0xe8, 0x3f, 0xd8, 0x65,
+	0x77, 0x51, 0x9a, 0x92, 0xc0, 0x29, 0xf2, 0x99, 0x51, 0x16, 0x1d, 0x8d, 0x7e, 0x5e, 0xaf, 0xba,
+	0x3b, 0x57, 0x2a, 0xb8, 0x59, 0xc7, 0xf0, 0x0e, 0x7b, 0x0c, 0x02, 0x66, 0xbe, 0xd3, 0x00, 0xce,
+	0x48, 0x16, 0x92, 0x1f, 0x28, 0xae, 0x0b, 0x15, 0x9e, 0x11, 0x35, 0x0a, 0xc5, 0x0d, 0x5e, 0x78,
+	0xb7, 0xc4, 0xe7, 0x58, 0x06, 0xd0, 0xef, 0x00, 0x2c, 0xba, 0x27, 0x8e, 0xb7, 0xe4, 0x84, 0xc9,
+	0xe9, 0xa8, 0xe0, 0x86, 0x20, 0x23, 0x01, 0xd0, 0x00, 0x40, 0x08, 0x31, 0x47, 0xaa, 0x54, 0xbe,
+	0x57, 0x69, 0xc8, 0xf0, 0xb5, 0x90, 0xea, 0x43, 0x5b, 0xe5, 0x3e, 0x12, 0xac, 0x4a, 0xc1, 0x6d,
+	0xc9, 0xaf, 0x0a, 0x55, 0xf3, 0x1f, 0xa8, 0x5c, 0xc6, 0x6e, 0x22, 0x06, 0xd0, 0x17, 0xa7, 0xad,
+	0x6e, 0xb6, 0x8c, 0x37, 0x9e, 0xe0, 0x0b, 0xd1, 0x28, 0x93, 0x75, 0x97, 0xf1, 0xc6, 0x1b, 0x58,
+	0x50, 0x55, 0xbd, 0x37, 0xa1, 0x86, 0x6f, 0xce, 0xcf, 0xed, 0xf3, 0x93, 0x76, 0x09, 0x6d, 0x41,
+	0x7d, 0x7c, 0x71, 0x76, 0x79, 0x3a, 0xbd, 0x9e, 0xb6, 0x35, 0x04, 0xa0, 0x1f, 0xff, 0x6f, 0x9f,
+	0x4e, 0x27, 0xed, 0xf2, 0xd1, 0x3d, 0xe8, 0xcf, 0xe5, 0xa1, 0xa0, 0xbf, 0x41, 0x17, 0x2b, 0x73,
+	0x86, 0xf6, 0x2c, 0xf5, 0xe0, 0xac, 0xe2, 0xc1, 0x59, 0x53, 0x31, 0x81, 0xfb, 0xbb, 0x96, 0x78,
+	0xa9, 0x2a, 0x5d, 0xa5, 0x9a, 0x25, 0xf4, 0x2f, 0xe8, 0x6a, 0x76, 0xd0, 0xaf, 0xc5, 0xf1, 0x3e,
+	0x99, 0xd0, 0xfd, 0xbd, 0x6f, 0xb1, 0x1a, 0x31, 0xb3, 0x34, 0x1a, 0xbd, 0x5f, 0x77, 0xb4, 0x0f,
+	0xeb, 0x8e, 0xf6, 0x71, 0xdd, 0xd1, 0xde, 0x7c, 0xea, 0x94, 0x5e, 0x1c, 0x86, 0x11, 0x9f, 0xe7,
+	0x9e, 0xe5, 0xd3, 0xc5, 0x30, 0x75, 0xfd, 0xf9, 0x32, 0x20, 0xd9, 0x63, 0x8b, 0x65, 0xfe, 0xf0,
+	0xc9, 0x5f, 0x8f, 0xa7, 0xcb, 0x1a, 0xff, 0xfa, 0x12, 0x00, 0x00, 0xff, 0xff, 0x74, 0x63, 0xca,
+	0x9d, 0x92, 0x04, 0x00, 0x00,
 }